@@ -222,14 +222,19 @@ func main() {
 
 	mux.HandleFunc(buildversion.Get, buildversion.Handler(version))
 
+	tlsProvider, err := rpc.NewTLSProvider(&cfg.TLS)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load TLS certificates")
+	}
+
 	inbounds := rpc.NewInbounds(
 		cfg.Archiver.HTTPPort,
 		cfg.Archiver.GRPCPort,
 		mux,
+		tlsProvider,
 	)
 
-	discovery, err := leader.NewZkServiceDiscovery(
-		cfg.Election.ZKServers, cfg.Election.Root)
+	discovery, err := leader.NewZkServiceDiscoveryFromConfig(cfg.Election)
 	if err != nil {
 		log.WithError(err).
 			Fatal("Could not create zk service discovery")
@@ -240,7 +245,8 @@ func main() {
 		rootScope,
 		mux,
 		discovery,
-		inbounds)
+		inbounds,
+		tlsProvider)
 	if err != nil {
 		log.WithError(err).
 			WithField("zkservers", cfg.Election.ZKServers).
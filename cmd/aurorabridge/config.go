@@ -20,6 +20,7 @@ import (
 	"github.com/uber/peloton/pkg/common/leader"
 	"github.com/uber/peloton/pkg/common/logging"
 	"github.com/uber/peloton/pkg/common/metrics"
+	"github.com/uber/peloton/pkg/common/rpc"
 )
 
 // Config defines aurorabridge configuration.
@@ -33,4 +34,5 @@ type Config struct {
 	Election       leader.ElectionConfig             `yaml:"election"`
 	RespoolLoader  aurorabridge.RespoolLoaderConfig  `yaml:"respool_loader"`
 	ServiceHandler aurorabridge.ServiceHandlerConfig `yaml:"service_handler"`
+	TLS            rpc.TLSConfig                     `yaml:"tls"`
 }
@@ -145,12 +145,17 @@ func main() {
 
 	// setup the discovery service to detect jobmgr leaders and
 	// configure the YARPC Peer dynamically
+	tlsProvider, err := rpc.NewTLSProvider(&cfg.TLS)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load TLS certificates")
+	}
+
 	jobmgrTransport := rpc.NewTransport()
 	jobmgrPeerChooser, err := peer.NewSmartChooser(
 		cfg.Election,
 		discoveryScope,
 		common.JobManagerRole,
-		jobmgrTransport,
+		rpc.NewPeerTransport(jobmgrTransport, tlsProvider),
 	)
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -165,7 +170,7 @@ func main() {
 		cfg.Election,
 		discoveryScope,
 		common.ResourceManagerRole,
-		resmgrTransport,
+		rpc.NewPeerTransport(resmgrTransport, tlsProvider),
 	)
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -27,6 +27,7 @@ import (
 	"github.com/uber/peloton/pkg/cli/config"
 	"github.com/uber/peloton/pkg/common"
 	"github.com/uber/peloton/pkg/common/leader"
+	"github.com/uber/peloton/pkg/common/rpc"
 	"github.com/uber/peloton/pkg/common/util"
 
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -98,6 +99,13 @@ var (
 		Envar("ZK_ROOT").
 		String()
 
+	zkNamespace = app.Flag(
+		"zknamespace",
+		"zookeeper election namespace under the root path for peloton "+
+			"service discovery(set $ZK_NAMESPACE to override)").
+		Envar("ZK_NAMESPACE").
+		String()
+
 	timeout = app.Flag(
 		"timeout",
 		"default RPC timeout (set $TIMEOUT to override)").
@@ -106,6 +114,27 @@ var (
 		Envar("TIMEOUT").
 		Duration()
 
+	tlsCertFile = app.Flag(
+		"tls-cert",
+		"certificate of the client for the peloton components which require "+
+			"mutual TLS (set $TLS_CERT_FILE to override)").
+		Envar("TLS_CERT_FILE").
+		String()
+
+	tlsKeyFile = app.Flag(
+		"tls-key",
+		"private key of the client certificate "+
+			"(set $TLS_KEY_FILE to override)").
+		Envar("TLS_KEY_FILE").
+		String()
+
+	tlsCAFile = app.Flag(
+		"tls-ca",
+		"CA certificates used to verify the peloton components "+
+			"(set $TLS_CA_FILE to override)").
+		Envar("TLS_CA_FILE").
+		String()
+
 	// Top level job command
 	job = app.Command("job", "manage jobs")
 
@@ -117,6 +146,21 @@ var (
 	jobCreateSecretPath = jobCreate.Flag("secret-path", "secret mount path").Default("").String()
 	jobCreateSecret     = jobCreate.Flag("secret-data", "secret data string").Default("").String()
 
+	jobLint               = job.Command("lint", "lint a job config against best-practice rules")
+	jobLintConfig         = jobLint.Arg("config", "YAML job configuration").Required().ExistingFile()
+	jobLintRuleSet        = jobLint.Flag("rule-set", "name of the configured rule set, all the rules are evaluated if unset").Default("").String()
+	jobLintFailOnWarnings = jobLint.Flag("fail-on-warnings", "fail if the config has warnings").Default("false").Bool()
+
+	jobCheckCompat                = job.Command("check-compat", "check job configs against the API of the running Peloton version")
+	jobCheckCompatJobs            = jobCheckCompat.Arg("job", "job identifiers, all the jobs are checked if unset").Strings()
+	jobCheckCompatIncludeTerminal = jobCheckCompat.Flag("include-terminal", "also check the jobs in a terminal state").Default("false").Bool()
+	jobCheckCompatConfig          = jobCheckCompat.Flag("config", "YAML job configuration to check offline instead of the stored configs").Default("").String()
+	jobCheckCompatMaxTasks        = jobCheckCompat.Flag("max-tasks-per-job", "maximum number of tasks per job when checking offline").Default("100000").Uint32()
+
+	jobStateDiff     = job.Command("state-diff", "export the changes made to the jobs, resource pools and hosts of the cluster as JSON")
+	jobStateDiffFrom = jobStateDiff.Arg("from", "start of the period in RFC3339 format").Required().String()
+	jobStateDiffTo   = jobStateDiff.Flag("to", "end of the period in RFC3339 format, defaults to the current state").Default("").String()
+
 	jobDelete     = job.Command("delete", "delete a job")
 	jobDeleteName = jobDelete.Arg("job", "job identifier").Required().String()
 
@@ -204,10 +248,25 @@ var (
 	watchPod         = watch.Command("pod", "watch pod runtime changes")
 	watchPodJobID    = watchPod.Arg("job", "job identifier").String()
 	watchPodPodNames = watchPod.Arg("pod", "pod name").Strings()
+	watchPodLabels   = watchPod.Flag("labels", "only watch the pods with all the labels, e.g. \"x=y,a=b\"").Default("").Short('l').String()
+	watchPodJobIDs   = watchPod.Flag("jobs", "also watch the pods of the jobs, e.g. \"id1,id2\"").Default("").String()
+	watchPodPrefix   = watchPod.Flag("prefix", "also watch the pods whose name starts with the prefix").Default("").String()
+	watchPodRespool  = watchPod.Flag("respool", "only watch the pods of the jobs in the resource pool and its descendants").Default("").String()
 
 	watchCancel        = watch.Command("cancel", "cancel watch")
 	watchCancelWatchID = watchCancel.Arg("id", "watch id").Required().String()
 
+	watchList      = watch.Command("list", "list the active watches with their buffer utilization and lag")
+	watchListToken = watchList.Flag("token", "token of the firehose").Envar("PELOTON_FIREHOSE_TOKEN").Required().String()
+
+	watchClose        = watch.Command("close", "force-close a watch, e.g. one leaked by its client")
+	watchCloseWatchID = watchClose.Arg("id", "watch id").Required().String()
+	watchCloseToken   = watchClose.Flag("token", "token of the firehose").Envar("PELOTON_FIREHOSE_TOKEN").Required().String()
+
+	watchReplay         = watch.Command("replay", "replay the pod changes missed after a revision")
+	watchReplayRevision = watchReplay.Arg("revision", "revision after which the changes are replayed").Required().Uint64()
+	watchReplayJobID    = watchReplay.Flag("job", "only replay the changes of the pods of the job").Default("").String()
+
 	workflow                   = stateless.Command("workflow", "manage workflow for stateless job")
 	workflowPause              = workflow.Command("pause", "pause a workflow")
 	workflowPauseName          = workflowPause.Arg("job", "job identifier").Required().String()
@@ -329,6 +388,10 @@ var (
 	statelessRestartBatchSize = statelessRestartJob.Flag("batch-size", "batch size for the restart").Default("0").Uint32()
 	statelessRestartInPlace   = statelessRestartJob.Flag("in-place",
 		"start the restart with best effort in-place restart").Default("false").Bool()
+	statelessRestartHealthWait = statelessRestartJob.Flag("health-wait",
+		"seconds a restarted pod must be running and healthy before the next batch is restarted").Default("0").Uint32()
+	statelessRestartOrder = statelessRestartJob.Flag("order",
+		"order in which the pods are restarted").Default("instance-id").Enum("instance-id", "reverse-instance-id", "unhealthy-first")
 
 	statelessStop              = stateless.Command("stop", "stop all pods in a job")
 	statelessStopJobID         = statelessStop.Arg("job", "job identifier").Required().String()
@@ -428,11 +491,22 @@ var (
 	taskGetEventsJobName    = taskGetEvents.Arg("job", "job identifier").Required().String()
 	taskGetEventsInstanceID = taskGetEvents.Arg("instance", "job instance id").Required().Uint32()
 
+	taskGetTimeline           = task.Command("timeline", "show the lifecycle of the recent runs of a task")
+	taskGetTimelineJobName    = taskGetTimeline.Arg("job", "job identifier").Required().String()
+	taskGetTimelineInstanceID = taskGetTimeline.Arg("instance", "job instance id").Required().Uint32()
+	taskGetTimelineLimit      = taskGetTimeline.Flag("limit", "number of most recent runs to show").Default("10").Uint32()
+
 	taskLogsGet           = task.Command("logs", "show task logs")
 	taskLogsGetFileName   = taskLogsGet.Flag("filename", "log filename to browse").Default("stdout").Short('f').String()
 	taskLogsGetJobName    = taskLogsGet.Arg("job", "job identifier").Required().String()
 	taskLogsGetInstanceID = taskLogsGet.Arg("instance", "job instance id").Required().Uint32()
 	taskLogsGetTaskID     = taskLogsGet.Arg("taskId", "task identifier").Default("").String()
+	taskLogsGetExecutor   = taskLogsGet.Flag("executor", "browse the sandbox of the executor of the task").Default("false").Bool()
+
+	taskExec           = task.Command("exec", "execute a command in the container of a running task")
+	taskExecJobName    = taskExec.Arg("job", "job identifier").Required().String()
+	taskExecInstanceID = taskExec.Arg("instance", "job instance id").Required().Uint32()
+	taskExecCommand    = taskExec.Arg("command", "command to execute with the shell of the container").Required().String()
 
 	taskList              = task.Command("list", "show tasks of a job")
 	taskListJobName       = taskList.Arg("job", "job identifier").Required().String()
@@ -482,6 +556,68 @@ var (
 	resMgrPendingTasksGetLimit = resMgrPendingTasks.Flag("limit",
 		"maximum number of gangs to return").Default("100").Uint32()
 
+	// Top level job manager command
+	jobMgr          = app.Command("jobmgr", "manage job manager")
+	jobMgrGoalState = jobMgr.Command("goalstate", "manage the goal state engine of job manager")
+
+	jobMgrGoalStatePause = jobMgrGoalState.Command("pause",
+		"pause the goal state actions, of all the job types unless --type is given")
+	jobMgrGoalStatePauseTypes = jobMgrGoalStatePause.Flag("type",
+		"job type to pause (specify multiple times)").Enums("batch", "service")
+	jobMgrGoalStatePauseToken = jobMgrGoalStatePause.Flag("token",
+		"admin token of job manager").Envar("PELOTON_JOBMGR_ADMIN_TOKEN").Required().String()
+
+	jobMgrGoalStateResume = jobMgrGoalState.Command("resume",
+		"resume the goal state actions, of all the job types unless --type is given")
+	jobMgrGoalStateResumeTypes = jobMgrGoalStateResume.Flag("type",
+		"job type to resume (specify multiple times)").Enums("batch", "service")
+	jobMgrGoalStateResumeToken = jobMgrGoalStateResume.Flag("token",
+		"admin token of job manager").Envar("PELOTON_JOBMGR_ADMIN_TOKEN").Required().String()
+
+	jobMgrGoalStateStatus = jobMgrGoalState.Command("status",
+		"show the job types whose goal state actions are paused")
+
+	jobMgrGoalStateQueues = jobMgrGoalState.Command("queues",
+		"show the jobs, tasks and updates in the goal state engine, "+
+			"when they will be evaluated and their retries")
+	jobMgrGoalStateQueuesJobID = jobMgrGoalStateQueues.Flag("job-id",
+		"only show the entities of the job").String()
+	jobMgrGoalStateQueuesFailed = jobMgrGoalStateQueues.Flag("failed",
+		"only show the entities whose last evaluation failed").Default("false").Bool()
+	jobMgrGoalStateQueuesLimit = jobMgrGoalStateQueues.Flag("limit",
+		"maximum number of entities to show per queue, 0 for all").Default("100").Uint32()
+
+	jobMgrGoalStateEvaluate = jobMgrGoalState.Command("evaluate",
+		"show the actions the goal state engine would run for a job and its tasks, "+
+			"along with the states considered, without running them")
+	jobMgrGoalStateEvaluateJobID = jobMgrGoalStateEvaluate.Arg("job",
+		"job identifier").Required().String()
+	jobMgrGoalStateEvaluateInstances = jobMgrGoalStateEvaluate.Flag("instance",
+		"instance to evaluate the task of (specify multiple times)").Uint32List()
+
+	jobMgrRefresh = jobMgr.Command("refresh", "reload the cache of job manager from storage")
+
+	jobMgrRefreshStart = jobMgrRefresh.Command("start",
+		"start refreshing the given jobs, or the jobs matching --state and --type")
+	jobMgrRefreshStartJobIDs = jobMgrRefreshStart.Flag("job-id",
+		"job to refresh (specify multiple times)").Strings()
+	jobMgrRefreshStartStates = jobMgrRefreshStart.Flag("state",
+		"state of the jobs to refresh (specify multiple times)").Strings()
+	jobMgrRefreshStartTypes = jobMgrRefreshStart.Flag("type",
+		"type of the jobs to refresh (specify multiple times)").Enums("batch", "service")
+	jobMgrRefreshStartRate = jobMgrRefreshStart.Flag("rate",
+		"maximum number of jobs refreshed per second, 0 for the default").Default("0").Uint32()
+
+	jobMgrRefreshStatus = jobMgrRefresh.Command("status",
+		"show the progress of a refresh")
+	jobMgrRefreshStatusID = jobMgrRefreshStatus.Arg("refresh-id",
+		"identifier of the refresh").Required().String()
+
+	jobMgrMetrics = jobMgr.Command("metrics",
+		"show the totals of the counters, gauges, timers and histograms of job manager")
+	jobMgrMetricsFilters = jobMgrMetrics.Arg("filter",
+		"only show the metrics whose name contains one of the filters").Strings()
+
 	// Top level resource pool command
 	resPool = app.Command("respool", "manage resource pools")
 
@@ -521,6 +657,21 @@ var (
 	hostQuery       = host.Command("query", "query hosts by state(s)")
 	hostQueryStates = hostQuery.Flag("states", "host state(s) to filter").Default("").Short('s').String()
 
+	hostGroup = host.Command("group", "drain groups of hosts one at a time, e.g. for host image rollouts")
+
+	hostGroupStart          = hostGroup.Command("start", "start draining a host group")
+	hostGroupStartName      = hostGroupStart.Arg("group", "name of the host group").Required().String()
+	hostGroupStartHostnames = hostGroupStart.Arg("hostnames", "comma separated hostnames").Required().String()
+
+	hostGroupStatus     = hostGroup.Command("status", "show the progress of the drain of a host group")
+	hostGroupStatusName = hostGroupStatus.Arg("group", "name of the host group").Required().String()
+
+	hostGroupComplete     = hostGroup.Command("complete", "complete a drained host group, bringing its hosts back up")
+	hostGroupCompleteName = hostGroupComplete.Arg("group", "name of the host group").Required().String()
+
+	hostHeadroom          = host.Command("headroom", "show the SLA headroom of the jobs running on a list of hosts")
+	hostHeadroomHostnames = hostHeadroom.Arg("hostnames", "comma separated hostnames").Required().String()
+
 	// Top level volume command
 	volume = app.Command("volume", "manage persistent volume")
 
@@ -728,7 +879,12 @@ func main() {
 	}
 	var discovery leader.Discovery
 	if len(*zkServers) > 0 {
-		discovery, err = leader.NewZkServiceDiscovery(*zkServers, *zkRoot)
+		discovery, err = leader.NewZkServiceDiscoveryFromConfig(
+			leader.ElectionConfig{
+				ZKServers: *zkServers,
+				Root:      *zkRoot,
+				Namespace: *zkNamespace,
+			})
 	} else {
 		discovery, err = leader.NewStaticServiceDiscovery(*jobMgrURL, *resMgrURL, *hostMgrURL)
 	}
@@ -736,13 +892,35 @@ func main() {
 		app.FatalIfError(err, "Fail to initialize service discovery")
 	}
 
-	client, err := pc.New(discovery, *timeout, *jsonFormat)
+	tlsProvider, err := rpc.NewTLSProvider(&rpc.TLSConfig{
+		Enabled:  len(*tlsCertFile) > 0,
+		CertFile: *tlsCertFile,
+		KeyFile:  *tlsKeyFile,
+		CAFile:   *tlsCAFile,
+	})
+	if err != nil {
+		app.FatalIfError(err, "Fail to load TLS certificates")
+	}
+
+	client, err := pc.New(discovery, *timeout, *jsonFormat, tlsProvider)
 	if err != nil {
 		app.FatalIfError(err, "Fail to initialize client")
 	}
 	defer client.Cleanup()
 
 	switch cmd {
+	case jobLint.FullCommand():
+		err = client.JobLintAction(*jobLintConfig, *jobLintRuleSet, *jobLintFailOnWarnings)
+	case jobStateDiff.FullCommand():
+		err = client.JobStateDiffAction(*jobStateDiffFrom, *jobStateDiffTo)
+	case jobCheckCompat.FullCommand():
+		if len(*jobCheckCompatConfig) != 0 {
+			err = client.JobCheckCompatConfigAction(
+				*jobCheckCompatConfig, *jobCheckCompatMaxTasks)
+		} else {
+			err = client.JobCheckCompatAction(
+				*jobCheckCompatJobs, *jobCheckCompatIncludeTerminal)
+		}
 	case jobCreate.FullCommand():
 		err = client.JobCreateAction(*jobCreateID, *jobCreateResPoolPath,
 			*jobCreateConfig, *jobCreateSecretPath, []byte(*jobCreateSecret))
@@ -783,8 +961,12 @@ func main() {
 		err = client.TaskGetCacheAction(*taskGetCacheName, *taskGetCacheInstanceID)
 	case taskGetEvents.FullCommand():
 		err = client.TaskGetEventsAction(*taskGetEventsJobName, *taskGetEventsInstanceID)
+	case taskGetTimeline.FullCommand():
+		err = client.TaskGetTimelineAction(*taskGetTimelineJobName, *taskGetTimelineInstanceID, *taskGetTimelineLimit)
 	case taskLogsGet.FullCommand():
-		err = client.TaskLogsGetAction(*taskLogsGetFileName, *taskLogsGetJobName, *taskLogsGetInstanceID, *taskLogsGetTaskID)
+		err = client.TaskLogsGetAction(*taskLogsGetFileName, *taskLogsGetJobName, *taskLogsGetInstanceID, *taskLogsGetTaskID, *taskLogsGetExecutor)
+	case taskExec.FullCommand():
+		err = client.TaskExecAction(*taskExecJobName, *taskExecInstanceID, *taskExecCommand)
 	case taskList.FullCommand():
 		err = client.TaskListAction(*taskListJobName, taskListInstanceRange)
 	case taskQuery.FullCommand():
@@ -804,6 +986,43 @@ func main() {
 		err = client.HostMaintenanceCompleteAction(*hostMaintenanceCompleteHostnames)
 	case hostQuery.FullCommand():
 		err = client.HostQueryAction(*hostQueryStates)
+	case hostGroupStart.FullCommand():
+		err = client.HostGroupDrainStartAction(*hostGroupStartName, *hostGroupStartHostnames)
+	case hostGroupStatus.FullCommand():
+		err = client.HostGroupDrainStatusAction(*hostGroupStatusName)
+	case hostGroupComplete.FullCommand():
+		err = client.HostGroupDrainCompleteAction(*hostGroupCompleteName)
+	case hostHeadroom.FullCommand():
+		err = client.HostDrainHeadroomAction(*hostHeadroomHostnames)
+	case jobMgrGoalStatePause.FullCommand():
+		err = client.JobMgrPauseGoalStateAction(
+			*jobMgrGoalStatePauseTypes,
+			*jobMgrGoalStatePauseToken)
+	case jobMgrGoalStateResume.FullCommand():
+		err = client.JobMgrResumeGoalStateAction(
+			*jobMgrGoalStateResumeTypes,
+			*jobMgrGoalStateResumeToken)
+	case jobMgrGoalStateStatus.FullCommand():
+		err = client.JobMgrGoalStateStatusAction()
+	case jobMgrGoalStateQueues.FullCommand():
+		err = client.JobMgrGoalStateQueuesAction(
+			*jobMgrGoalStateQueuesJobID,
+			*jobMgrGoalStateQueuesFailed,
+			*jobMgrGoalStateQueuesLimit)
+	case jobMgrGoalStateEvaluate.FullCommand():
+		err = client.JobMgrEvaluateGoalStateAction(
+			*jobMgrGoalStateEvaluateJobID,
+			*jobMgrGoalStateEvaluateInstances)
+	case jobMgrRefreshStart.FullCommand():
+		err = client.JobMgrStartRefreshAction(
+			*jobMgrRefreshStartJobIDs,
+			*jobMgrRefreshStartStates,
+			*jobMgrRefreshStartTypes,
+			*jobMgrRefreshStartRate)
+	case jobMgrRefreshStatus.FullCommand():
+		err = client.JobMgrRefreshStatusAction(*jobMgrRefreshStatusID)
+	case jobMgrMetrics.FullCommand():
+		err = client.JobMgrMetricsSnapshotAction(*jobMgrMetricsFilters)
 	case resMgrActiveTasks.FullCommand():
 		err = client.ResMgrGetActiveTasks(*resMgrActiveTasksGetJobName, *resMgrActiveTasksGetRespoolID, *resMgrActiveTasksGetStates)
 	case resMgrPendingTasks.FullCommand():
@@ -937,6 +1156,8 @@ func main() {
 			*statelessRestartInstanceRanges,
 			*statelessRestartOpaqueData,
 			*statelessRestartInPlace,
+			*statelessRestartHealthWait,
+			*statelessRestartOrder,
 		)
 	case statelessListUpdates.FullCommand():
 		err = client.StatelessListUpdatesAction(*statelessListUpdatesName)
@@ -976,9 +1197,15 @@ func main() {
 			*statelessDeleteForce,
 		)
 	case watchPod.FullCommand():
-		err = client.WatchPod(*watchPodJobID, *watchPodPodNames)
+		err = client.WatchPod(*watchPodJobID, *watchPodPodNames, *watchPodLabels, *watchPodJobIDs, *watchPodPrefix, *watchPodRespool)
 	case watchCancel.FullCommand():
 		err = client.CancelWatch(*watchCancelWatchID)
+	case watchList.FullCommand():
+		err = client.ListWatchers(*watchListToken)
+	case watchClose.FullCommand():
+		err = client.CloseWatcher(*watchCloseWatchID, *watchCloseToken)
+	case watchReplay.FullCommand():
+		err = client.ReplayWatch(*watchReplayJobID, *watchReplayRevision)
 	default:
 		app.Fatalf("Unknown command %s", cmd)
 	}
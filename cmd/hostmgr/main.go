@@ -44,6 +44,7 @@ import (
 	"github.com/uber/peloton/pkg/hostmgr/queue"
 	"github.com/uber/peloton/pkg/hostmgr/reconcile"
 	"github.com/uber/peloton/pkg/hostmgr/task"
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
 	"github.com/uber/peloton/pkg/storage/stores"
 
 	log "github.com/sirupsen/logrus"
@@ -292,11 +293,17 @@ func main() {
 
 	mux.HandleFunc(buildversion.Get, buildversion.Handler(version))
 
+	tlsProvider, err := rpc.NewTLSProvider(&cfg.TLS)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load TLS certificates")
+	}
+
 	// Create both HTTP and GRPC inbounds
 	inbounds := rpc.NewInbounds(
 		cfg.HostManager.HTTPPort,
 		cfg.HostManager.GRPCPort,
 		mux,
+		tlsProvider,
 	)
 
 	mesosMasterDetector, err := mesos.NewZKDetector(cfg.Mesos.ZkPath)
@@ -310,6 +317,12 @@ func main() {
 	rootScope.Counter("boot").Inc(1)
 
 	store := stores.MustCreateStore(&cfg.Storage, rootScope)
+	ormStore, ormErr := ormobjects.NewCassandraStore(
+		&cfg.Storage.Cassandra,
+		rootScope)
+	if ormErr != nil {
+		log.WithError(ormErr).Fatal("Failed to create ORM store for Cassandra")
+	}
 
 	authHeader, err := mesos.GetAuthHeader(&cfg.Mesos, *mesosSecretFile)
 	if err != nil {
@@ -354,11 +367,12 @@ func main() {
 	// Setup the discovery service to detect resmgr leaders and
 	// configure the YARPC Peer dynamically
 	t := rpc.NewTransport()
+	peerTransport := rpc.NewPeerTransport(t, tlsProvider)
 	resmgrPeerChooser, err := peer.NewSmartChooser(
 		cfg.Election,
 		discoveryScope,
 		common.ResourceManagerRole,
-		t,
+		peerTransport,
 	)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err, "role": common.ResourceManagerRole}).
@@ -439,6 +453,11 @@ func main() {
 					Func:   loader.Load,
 					Period: cfg.HostManager.HostmapRefreshInterval,
 				},
+				background.Work{
+					Name:   "hostmap_event_refresh",
+					Func:   loader.LoadIfRequested,
+					Period: cfg.HostManager.HostmapEventRefreshInterval,
+				},
 			)
 		}, backoff.NewRetryPolicy(cfg.HostManager.HostMgrBackoffRetryCount,
 			time.Duration(cfg.HostManager.HostMgrBackoffRetryIntervalSec)*time.Second),
@@ -528,6 +547,7 @@ func main() {
 		masterOperatorClient,
 		maintenanceQueue,
 		maintenanceHostInfoMap,
+		ormobjects.NewHostGroupOps(ormStore),
 	)
 
 	// Register background worker to start mesos task status update counter.
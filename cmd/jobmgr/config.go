@@ -19,6 +19,7 @@ import (
 	"github.com/uber/peloton/pkg/common/leader"
 	"github.com/uber/peloton/pkg/common/logging"
 	"github.com/uber/peloton/pkg/common/metrics"
+	"github.com/uber/peloton/pkg/common/rpc"
 	"github.com/uber/peloton/pkg/jobmgr"
 	storage "github.com/uber/peloton/pkg/storage/config"
 )
@@ -31,4 +32,5 @@ type Config struct {
 	JobManager   jobmgr.Config         `yaml:"job_manager"`
 	Health       health.Config         `yaml:"health"`
 	SentryConfig logging.SentryConfig  `yaml:"sentry"`
+	TLS          rpc.TLSConfig         `yaml:"tls"`
 }
@@ -15,11 +15,14 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 	"os"
 	"time"
 
+	host_svc "github.com/uber/peloton/.gen/peloton/api/v0/host/svc"
 	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/respool"
 	"github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc"
 
 	"github.com/uber/peloton/pkg/common"
@@ -33,20 +36,29 @@ import (
 	"github.com/uber/peloton/pkg/common/rpc"
 	"github.com/uber/peloton/pkg/hostmgr/mesos/yarpc/peer"
 	"github.com/uber/peloton/pkg/jobmgr"
+	"github.com/uber/peloton/pkg/jobmgr/adminsvc"
+	"github.com/uber/peloton/pkg/jobmgr/asyncop"
+	"github.com/uber/peloton/pkg/jobmgr/audit"
+	"github.com/uber/peloton/pkg/jobmgr/autodeploy"
 	"github.com/uber/peloton/pkg/jobmgr/cached"
 	"github.com/uber/peloton/pkg/jobmgr/goalstate"
+	"github.com/uber/peloton/pkg/jobmgr/job/lint"
+	"github.com/uber/peloton/pkg/jobmgr/job/provenance"
 	"github.com/uber/peloton/pkg/jobmgr/jobsvc"
 	"github.com/uber/peloton/pkg/jobmgr/jobsvc/stateless"
 	"github.com/uber/peloton/pkg/jobmgr/logmanager"
 	"github.com/uber/peloton/pkg/jobmgr/podsvc"
+	"github.com/uber/peloton/pkg/jobmgr/standby"
 	"github.com/uber/peloton/pkg/jobmgr/task/activermtask"
-	"github.com/uber/peloton/pkg/jobmgr/task/deadline"
 	"github.com/uber/peloton/pkg/jobmgr/task/event"
+	"github.com/uber/peloton/pkg/jobmgr/task/identity"
 	"github.com/uber/peloton/pkg/jobmgr/task/launcher"
 	"github.com/uber/peloton/pkg/jobmgr/task/placement"
 	"github.com/uber/peloton/pkg/jobmgr/task/preemptor"
+	"github.com/uber/peloton/pkg/jobmgr/task/registration"
 	"github.com/uber/peloton/pkg/jobmgr/tasksvc"
 	"github.com/uber/peloton/pkg/jobmgr/updatesvc"
+	"github.com/uber/peloton/pkg/jobmgr/verifier"
 	"github.com/uber/peloton/pkg/jobmgr/volumesvc"
 	"github.com/uber/peloton/pkg/jobmgr/watchsvc"
 	ormobjects "github.com/uber/peloton/pkg/storage/objects"
@@ -306,11 +318,17 @@ func main() {
 		log.WithError(ormErr).Fatal("Failed to create ORM store for Cassandra")
 	}
 
+	tlsProvider, err := rpc.NewTLSProvider(&cfg.TLS)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load TLS certificates")
+	}
+
 	// Create both HTTP and GRPC inbounds
 	inbounds := rpc.NewInbounds(
 		cfg.JobManager.HTTPPort,
 		cfg.JobManager.GRPCPort,
 		mux,
+		tlsProvider,
 	)
 
 	// all leader discovery metrics share a scope (and will be tagged
@@ -319,11 +337,12 @@ func main() {
 	// setup the discovery service to detect resmgr leaders and
 	// configure the YARPC Peer dynamically
 	t := rpc.NewTransport()
+	peerTransport := rpc.NewPeerTransport(t, tlsProvider)
 	resmgrPeerChooser, err := peer.NewSmartChooser(
 		cfg.Election,
 		discoveryScope,
 		common.ResourceManagerRole,
-		t,
+		peerTransport,
 	)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err, "role": common.ResourceManagerRole}).
@@ -339,7 +358,7 @@ func main() {
 		cfg.Election,
 		discoveryScope,
 		common.HostManagerRole,
-		t,
+		peerTransport,
 	)
 	if err != nil {
 		log.WithFields(log.Fields{"error": err, "role": common.HostManagerRole}).
@@ -354,10 +373,33 @@ func main() {
 			Unary: resmgrOutbound,
 		},
 		common.PelotonHostManager: transport.Outbounds{
-			Unary: hostmgrOutbound,
+			Unary:  hostmgrOutbound,
+			Stream: hostmgrOutbound,
 		},
 	}
 
+	if cfg.JobManager.Standby.Enabled {
+		// setup the discovery service to detect jobmgr leaders, so that
+		// the non-leader instances can follow the changes of the leader
+		jobmgrPeerChooser, err := peer.NewSmartChooser(
+			cfg.Election,
+			discoveryScope,
+			common.JobManagerRole,
+			peerTransport,
+		)
+		if err != nil {
+			log.WithFields(log.Fields{"error": err, "role": common.JobManagerRole}).
+				Fatal("Could not create smart peer chooser")
+		}
+		defer jobmgrPeerChooser.Stop()
+
+		jobmgrOutbound := t.NewOutbound(jobmgrPeerChooser)
+		outbounds[common.PelotonJobManager] = transport.Outbounds{
+			Unary:  jobmgrOutbound,
+			Stream: jobmgrOutbound,
+		}
+	}
+
 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
 		Name:      common.PelotonJobManager,
 		Inbounds:  inbounds,
@@ -370,24 +412,47 @@ func main() {
 	// Declare background works
 	backgroundManager := background.NewManager()
 
-	// Register UpdateActiveTasks function
+	// Active tasks cache filled by the task events from resmgr, and
+	// resynced with all the tasks of resmgr right after gaining leadership
+	// and periodically, as the events sent meanwhile are missed
 	activeJobCache := activermtask.NewActiveRMTasks(dispatcher, rootScope)
 
-	backgroundManager.RegisterWorks(
+	if err := backgroundManager.RegisterWorks(
 		background.Work{
 			Name: "ActiveCacheJob",
 			Func: func(_ *atomic.Bool) {
 				activeJobCache.UpdateActiveTasks()
 			},
-			Period: time.Duration(cfg.JobManager.ActiveTaskUpdatePeriod),
+			Period:       time.Duration(cfg.JobManager.ActiveTaskUpdatePeriod),
+			InitialDelay: time.Second,
 		},
-	)
+	); err != nil {
+		log.WithError(err).Fatal("Failed to register active tasks cache work")
+	}
 
-	watchProcessor := watchsvc.InitV1AlphaWatchServiceHandler(
-		dispatcher,
-		rootScope,
+	var watchJournalStore ormobjects.WatchJournalOps
+	if cfg.JobManager.Watch.Journal.Persist {
+		watchJournalStore = ormobjects.NewWatchJournalOps(ormStore)
+	}
+	watchsvc.InitWatchProcessor(
 		cfg.JobManager.Watch,
+		watchJournalStore,
+		rootScope,
 	)
+	watchProcessor := watchsvc.GetWatchProcessor()
+
+	listeners := []cached.JobTaskListener{
+		watchsvc.NewWatchListener(watchProcessor, store),
+	}
+	var registrationHooks registration.Hooks
+	if cfg.JobManager.Registration.Enabled {
+		registrationHooks = registration.NewHooks(
+			cfg.JobManager.Registration,
+			&http.Client{},
+			rootScope,
+		)
+		listeners = append(listeners, registrationHooks)
+	}
 
 	jobFactory := cached.InitJobFactory(
 		store, // store implements JobStore
@@ -396,9 +461,48 @@ func main() {
 		store, // store implements VolumeStore
 		ormStore,
 		rootScope,
-		[]cached.JobTaskListener{watchsvc.NewWatchListener(watchProcessor)},
+		listeners,
 	)
 
+	// the watches selecting the pods by resource pool resolve the
+	// resource pools with the resource manager
+	watchRespoolClient := respool.NewResourceManagerYARPCClient(
+		dispatcher.ClientConfig(common.PelotonResourceManager))
+
+	// the watches including the resource usage of the pods sample it
+	// from the agents through the host manager
+	watchHostmgrClient := hostsvc.NewInternalHostServiceYARPCClient(
+		dispatcher.ClientConfig(common.PelotonHostManager))
+
+	watchHandler := watchsvc.InitV1AlphaWatchServiceHandler(
+		dispatcher,
+		rootScope,
+		cfg.JobManager.Watch,
+		jobFactory,
+		watchRespoolClient,
+		watchHostmgrClient,
+	)
+
+	watchsvc.InitWatchGateway(
+		mux,
+		watchHandler,
+		cfg.JobManager.Watch,
+		tlsProvider != nil,
+	)
+
+	var identityProvider *identity.Provider
+	if cfg.JobManager.Identity.Enabled {
+		issuer, err := identity.NewIssuer(cfg.JobManager.Identity)
+		if err != nil {
+			log.WithError(err).Fatal("Cannot create task identity issuer")
+		}
+		identityProvider = identity.NewProvider(
+			cfg.JobManager.Identity,
+			issuer,
+			rootScope,
+		)
+	}
+
 	// TODO: We need to cleanup the client names
 	launcher.InitTaskLauncher(
 		dispatcher,
@@ -407,10 +511,40 @@ func main() {
 		store, // store implements TaskStore
 		store, // store implements VolumeStore
 		ormStore,
+		identityProvider,
 		rootScope,
 	)
 
-	goalStateDriver := goalstate.NewDriver(
+	// The goal state of the jobs is sharded across the job managers,
+	// which evaluate the jobs of the shards whose lease they hold while
+	// not leader using the warm standby
+	var goalStateDriver goalstate.Driver
+	var shardMember string
+	var shardLeases leader.ShardLeases
+	if cfg.JobManager.GoalState.Sharding.Enabled {
+		if !cfg.JobManager.Standby.Enabled {
+			log.Fatal("Sharding the goal state requires the warm standby")
+		}
+		hostname, err := os.Hostname()
+		if err != nil {
+			log.WithError(err).Fatal("Failed to get hostname")
+		}
+		shardMember = fmt.Sprintf("%s:%d", hostname, cfg.JobManager.HTTPPort)
+		shardLeases, err = leader.NewShardLeases(
+			cfg.Election,
+			rootScope,
+			common.JobManagerRole,
+			shardMember,
+			func(shard uint32) {
+				goalStateDriver.ShardLeaseAcquired(shard)
+			},
+		)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to create goal state shard leases")
+		}
+	}
+
+	goalStateDriver = goalstate.NewDriver(
 		dispatcher,
 		store, // store implements JobStore
 		store, // store implements TaskStore
@@ -418,12 +552,54 @@ func main() {
 		store, // store implements UpdateStore
 		jobFactory,
 		launcher.GetLauncher(),
+		registrationHooks,
+		ormobjects.NewGoalStatePauseOps(ormStore),
+		shardLeases,
 		job.JobType(job.JobType_value[*jobType]),
 		rootScope,
 		cfg.JobManager.GoalState,
 		cfg.JobManager.JobRuntimeCalculationViaCache,
 	)
 
+	// The shard leases are held for the shards placed on this job
+	// manager by the members sharing the goal state
+	var standbyDriver goalstate.Driver
+	var shardMembership leader.Membership
+	if cfg.JobManager.GoalState.Sharding.Enabled {
+		shardMembership, err = leader.NewMembership(
+			cfg.Election,
+			rootScope,
+			common.JobManagerRole,
+			shardMember,
+			leader.NewID(cfg.JobManager.HTTPPort, cfg.JobManager.GRPCPort),
+			func(members []string) {
+				goalStateDriver.UpdateShards(shardMember, members)
+			},
+		)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to create goal state shards membership")
+		}
+		standbyDriver = goalStateDriver
+	}
+
+	var standbyCache standby.Standby
+	if cfg.JobManager.Standby.Enabled {
+		if len(cfg.JobManager.Watch.Firehose.Token) == 0 {
+			log.Fatal("Warm standby requires the watch firehose token")
+		}
+		standbyCache = standby.New(
+			dispatcher,
+			store, // store implements JobStore
+			store, // store implements TaskStore
+			jobFactory,
+			standbyDriver,
+			job.JobType(job.JobType_value[*jobType]),
+			cfg.JobManager.Watch.Firehose.Token,
+			rootScope,
+			cfg.JobManager.Standby,
+		)
+	}
+
 	// Init placement processor
 	placementProcessor := placement.InitProcessor(
 		dispatcher,
@@ -446,17 +622,6 @@ func main() {
 		rootScope,
 	)
 
-	// Create a new Dead Line tracker for jobs
-	deadlineTracker := deadline.New(
-		dispatcher,
-		store, // store implements JobStore
-		store, // store implements TaskStore
-		jobFactory,
-		goalStateDriver,
-		rootScope,
-		&cfg.JobManager.Deadline,
-	)
-
 	// Create the Task status update which pulls task update events
 	// from HM once started after gaining leadership
 	statusUpdate := event.NewTaskStatusUpdate(
@@ -464,22 +629,31 @@ func main() {
 		store, // store implements JobStore
 		store, // store implements TaskStore
 		store, // store implements VolumeStore
+		ormStore,
 		jobFactory,
 		goalStateDriver,
 		[]event.Listener{},
+		activeJobCache,
 		rootScope,
 	)
 
+	// the asynchronous operations of the task service are cancelled when
+	// the job manager loses leadership
+	taskOperations := asyncop.NewTracker(0)
+
 	server := jobmgr.NewServer(
 		cfg.JobManager.HTTPPort,
 		cfg.JobManager.GRPCPort,
 		jobFactory,
 		goalStateDriver,
 		taskPreemptor,
-		deadlineTracker,
 		placementProcessor,
 		statusUpdate,
 		backgroundManager,
+		taskOperations,
+		standbyCache,
+		watchProcessor,
+		shardMembership,
 	)
 
 	candidate, err := leader.NewCandidate(
@@ -492,19 +666,109 @@ func main() {
 		log.Fatalf("Unable to create leader candidate: %v", err)
 	}
 
+	configVerifier, err := provenance.NewVerifier(
+		&cfg.JobManager.JobSvcCfg.Provenance)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to create job config verifier")
+	}
+
+	jobIDGenerator, err := jobsvc.NewJobIDGenerator(
+		cfg.JobManager.JobSvcCfg.JobIDGenerator)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to create job id generator")
+	}
+
+	configLinter, err := lint.NewLinter(&cfg.JobManager.JobSvcCfg.Lint)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to create job config linter")
+	}
+
+	// Snapshots of the cluster state are taken by the leader only, and
+	// exported by any job manager
+	var stateExporter audit.Exporter
+	if cfg.JobManager.Audit.Enabled {
+		recorder := audit.NewRecorder(
+			store, // store implements JobStore
+			store, // store implements ResourcePoolStore
+			host_svc.NewHostServiceYARPCClient(
+				dispatcher.ClientConfig(common.PelotonHostManager)),
+			ormobjects.NewClusterSnapshotOps(ormStore),
+			cfg.JobManager.Audit,
+			rootScope,
+		)
+		if err := backgroundManager.RegisterWorks(recorder.Work()); err != nil {
+			log.WithError(err).Fatal("Failed to register cluster snapshot work")
+		}
+		stateExporter = recorder
+	}
+
+	// The cached task runtimes are verified by the leader only
+	if cfg.JobManager.Verifier.Enabled {
+		cacheVerifier := verifier.NewVerifier(
+			jobFactory,
+			store, // store implements TaskStore
+			goalStateDriver,
+			cfg.JobManager.Verifier,
+			rootScope,
+		)
+		if err := backgroundManager.RegisterWorks(cacheVerifier.Work()); err != nil {
+			log.WithError(err).Fatal("Failed to register cache verifier work")
+		}
+	}
+
 	jobsvc.InitServiceHandler(
 		dispatcher,
 		rootScope,
 		store, // store implements JobStore
 		store, // store implements TaskStore
+		store, // store implements ResourcePoolStore
 		ormStore,
 		jobFactory,
 		goalStateDriver,
 		candidate,
 		common.PelotonResourceManager, // TODO: to be removed
 		cfg.JobManager.JobSvcCfg,
+		configVerifier,
+		jobIDGenerator,
+		configLinter,
+		stateExporter,
+		tlsProvider,
+	)
+
+	if cfg.JobManager.AutoDeploy.Enabled &&
+		len(cfg.JobManager.AutoDeploy.Token) == 0 {
+		log.Fatal("Auto-deploy webhook requires a token")
+	}
+	autodeploy.InitWebhookHandler(
+		mux,
+		rootScope,
+		store, // store implements JobStore
+		jobFactory,
+		goalStateDriver,
+		candidate,
+		configVerifier,
+		cfg.JobManager.AutoDeploy,
 	)
 
+	if identityProvider != nil && cfg.JobManager.Identity.RenewPort != 0 {
+		// the identities are renewed over mutual TLS only, so that the
+		// executors prove the possession of the identity of their task
+		if tlsProvider == nil {
+			log.Fatal("Renewing task identities requires TLS")
+		}
+		renewServer := identity.NewRenewServer(
+			fmt.Sprintf(":%d", cfg.JobManager.Identity.RenewPort),
+			tlsProvider.GetCertificate,
+			identityProvider,
+			jobFactory,
+			candidate,
+		)
+		go func() {
+			err := renewServer.ListenAndServeTLS("", "")
+			log.WithError(err).Fatal("Identity renew server stopped")
+		}()
+	}
+
 	stateless.InitV1AlphaJobServiceHandler(
 		dispatcher,
 		store,
@@ -516,6 +780,14 @@ func main() {
 		candidate,
 		cfg.JobManager.JobSvcCfg,
 		activeJobCache,
+		configVerifier,
+		tlsProvider,
+	)
+
+	sandboxLayouts := logmanager.NewSandboxLayoutResolver(
+		&http.Client{Timeout: _httpClientTimeout},
+		*mesosAgentWorkDir,
+		&cfg.JobManager.Sandbox,
 	)
 
 	tasksvc.InitServiceHandler(
@@ -525,13 +797,18 @@ func main() {
 		store, // store implements TaskStore
 		store, // store implements UpdateStore
 		store, // store implements FrameworkInfoStore
+		ormStore,
 		jobFactory,
 		goalStateDriver,
 		candidate,
-		*mesosAgentWorkDir,
+		sandboxLayouts,
 		common.PelotonHostManager,
 		logmanager.NewLogManager(&http.Client{Timeout: _httpClientTimeout}),
 		activeJobCache,
+		taskOperations,
+		configVerifier,
+		tlsProvider,
+		cfg.JobManager.TaskSvcCfg,
 	)
 
 	podsvc.InitV1AlphaPodServiceHandler(
@@ -543,8 +820,9 @@ func main() {
 		goalStateDriver,
 		candidate,
 		logmanager.NewLogManager(&http.Client{Timeout: _httpClientTimeout}),
-		*mesosAgentWorkDir,
+		sandboxLayouts,
 		hostsvc.NewInternalHostServiceYARPCClient(dispatcher.ClientConfig(common.PelotonHostManager)),
+		cfg.JobManager.TaskSvcCfg.Pagination,
 	)
 
 	volumesvc.InitServiceHandler(
@@ -559,9 +837,25 @@ func main() {
 		dispatcher,
 		rootScope,
 		store, // store implements JobStore
+		store, // store implements TaskStore
 		store, // store implements UpdateStore
+		ormStore,
+		goalStateDriver,
+		jobFactory,
+		configVerifier,
+		cfg.JobManager.JobSvcCfg.TaskBackoffBounds,
+		tlsProvider,
+	)
+
+	adminsvc.InitServiceHandler(
+		dispatcher,
+		rootScope,
 		goalStateDriver,
+		store, // store implements JobStore
+		store, // store implements TaskStore
 		jobFactory,
+		candidate,
+		cfg.JobManager.Admin,
 	)
 
 	// Start dispatch loop
@@ -569,6 +863,9 @@ func main() {
 		log.Fatalf("Could not start rpc server: %v", err)
 	}
 
+	// Keep the cache warm until this instance gains leadership
+	server.Start()
+
 	err = candidate.Start()
 	if err != nil {
 		log.Fatalf("Unable to start leader candidate: %v", err)
@@ -0,0 +1,222 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/uber/peloton/pkg/common/config"
+	"github.com/uber/peloton/pkg/storage/cassandra/migration"
+	storage_config "github.com/uber/peloton/pkg/storage/config"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Config is the storage section of the config of the Peloton daemons
+type Config struct {
+	Storage storage_config.Config `yaml:"storage"`
+}
+
+var (
+	version string
+	app     = kingpin.New(
+		"peloton-migrate", "Migrate the schema of the Peloton keyspace")
+
+	debug = app.Flag(
+		"debug", "enable debug logging").
+		Short('d').
+		Default("false").
+		Envar("ENABLE_DEBUG_LOGGING").
+		Bool()
+
+	cfgFiles = app.Flag(
+		"config",
+		"YAML config files (can be provided multiple times to merge configs)").
+		Short('c').
+		Required().
+		ExistingFiles()
+
+	cassandraHosts = app.Flag(
+		"cassandra-hosts", "Cassandra hosts").
+		Envar("CASSANDRA_HOSTS").
+		Strings()
+
+	cassandraStore = app.Flag(
+		"cassandra-store", "Cassandra store name").
+		Default("").
+		Envar("CASSANDRA_STORE").
+		String()
+
+	cassandraPort = app.Flag(
+		"cassandra-port", "Cassandra port to connect").
+		Default("0").
+		Envar("CASSANDRA_PORT").
+		Int()
+
+	cassandraUsername = app.Flag(
+		"cassandra-username", "Cassandra username").
+		Default("").
+		Envar("CASSANDRA_USERNAME").
+		String()
+
+	cassandraPassword = app.Flag(
+		"cassandra-password", "Cassandra password").
+		Default("").
+		Envar("CASSANDRA_PASSWORD").
+		String()
+
+	migrationsDir = app.Flag(
+		"migrations", "Directory of the migration files "+
+			"(storage.cassandra.migrations override)").
+		Envar("MIGRATIONS").
+		String()
+
+	pelotonSecretFile = app.Flag(
+		"peloton-secret-file",
+		"Secret file containing all Peloton secrets").
+		Default("").
+		Envar("PELOTON_SECRET_FILE").
+		String()
+
+	status = app.Command("status",
+		"List the migrations and when they were applied")
+
+	pending = app.Command("pending",
+		"List the migrations up would apply, without applying them")
+
+	up = app.Command("up", "Apply the pending migrations")
+
+	down        = app.Command("down", "Revert the applied migrations")
+	downVersion = down.Flag("to",
+		"Revert the migrations after this version, all of them if negative").
+		Required().
+		Int64()
+
+	baseline = app.Command("baseline",
+		"Record the migrations up to a version as applied without running "+
+			"them, for keyspaces migrated by the schema scripts")
+	baselineVersion = baseline.Arg("version",
+		"Last migration applied to the keyspace").
+		Required().
+		Uint64()
+)
+
+func main() {
+	app.Version(version)
+	app.HelpFlag.Short('h')
+	cmd := kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	if *debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	var cfg Config
+	if err := config.Parse(&cfg, *cfgFiles...); err != nil {
+		log.WithError(err).Fatal("Cannot parse yaml config")
+	}
+
+	if *pelotonSecretFile != "" {
+		var secretsCfg config.PelotonSecretsConfig
+		if err := config.Parse(&secretsCfg, *pelotonSecretFile); err != nil {
+			log.WithError(err).
+				WithField("peloton_secret_file", *pelotonSecretFile).
+				Fatal("Cannot parse secret config")
+		}
+		cfg.Storage.Cassandra.CassandraConn.Username =
+			secretsCfg.CassandraUsername
+		cfg.Storage.Cassandra.CassandraConn.Password =
+			secretsCfg.CassandraPassword
+	}
+
+	if *cassandraUsername != "" {
+		cfg.Storage.Cassandra.CassandraConn.Username = *cassandraUsername
+		cfg.Storage.Cassandra.CassandraConn.Password = *cassandraPassword
+	}
+	if len(*cassandraHosts) > 0 {
+		cfg.Storage.Cassandra.CassandraConn.ContactPoints = *cassandraHosts
+	}
+	if *cassandraStore != "" {
+		cfg.Storage.Cassandra.StoreName = *cassandraStore
+	}
+	if *cassandraPort != 0 {
+		cfg.Storage.Cassandra.CassandraConn.Port = *cassandraPort
+	}
+	if *migrationsDir != "" {
+		cfg.Storage.Cassandra.Migrations = *migrationsDir
+	}
+
+	migrator, err := cfg.Storage.Cassandra.NewMigrator()
+	if err != nil {
+		log.WithError(err).Fatal("Cannot create migrator")
+	}
+
+	switch cmd {
+	case status.FullCommand():
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.WithError(err).Fatal("Cannot read migrations status")
+		}
+		printStatuses(statuses)
+	case pending.FullCommand():
+		p, err := migrator.Pending()
+		if err != nil {
+			log.WithError(err).Fatal("Cannot read pending migrations")
+		}
+		printMigrations("Pending", p)
+	case up.FullCommand():
+		applied, err := migrator.Up()
+		printMigrations("Applied", applied)
+		if err != nil {
+			log.WithError(err).Fatal("Cannot apply migrations")
+		}
+	case down.FullCommand():
+		reverted, err := migrator.Down(*downVersion)
+		printMigrations("Reverted", reverted)
+		if err != nil {
+			log.WithError(err).Fatal("Cannot revert migrations")
+		}
+	case baseline.FullCommand():
+		recorded, err := migrator.Baseline(*baselineVersion)
+		printMigrations("Recorded", recorded)
+		if err != nil {
+			log.WithError(err).Fatal("Cannot record migrations")
+		}
+	}
+}
+
+func printStatuses(statuses []*migration.Status) {
+	tabWriter := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tabWriter, "VERSION\tNAME\tAPPLIED AT\tMODIFIED")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.Applied != nil {
+			appliedAt = s.Applied.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tabWriter, "%d\t%s\t%s\t%t\n",
+			s.Migration.Version, s.Migration.Name, appliedAt, s.Modified)
+	}
+	tabWriter.Flush()
+}
+
+func printMigrations(action string, migrations []*migration.Migration) {
+	fmt.Printf("%s %d migrations\n", action, len(migrations))
+	for _, m := range migrations {
+		fmt.Printf("  %04d_%s\n", m.Version, m.Name)
+	}
+}
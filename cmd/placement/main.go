@@ -32,6 +32,7 @@ import (
 	"github.com/uber/peloton/pkg/placement"
 	"github.com/uber/peloton/pkg/placement/config"
 	"github.com/uber/peloton/pkg/placement/hosts"
+	"github.com/uber/peloton/pkg/placement/locality"
 	tally_metrics "github.com/uber/peloton/pkg/placement/metrics"
 	"github.com/uber/peloton/pkg/placement/offers"
 	"github.com/uber/peloton/pkg/placement/plugins"
@@ -43,6 +44,7 @@ import (
 	"github.com/uber/peloton/.gen/peloton/private/resmgrsvc"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/uber-go/tally"
 	_ "go.uber.org/automaxprocs"
 	"go.uber.org/yarpc"
 	"go.uber.org/yarpc/api/transport"
@@ -217,13 +219,19 @@ func main() {
 	mux.HandleFunc(logging.LevelOverwrite, logging.LevelOverwriteHandler(initialLevel))
 	mux.HandleFunc(buildversion.Get, buildversion.Handler(version))
 
+	tlsProvider, err := rpc.NewTLSProvider(&cfg.TLS)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load TLS certificates")
+	}
+
 	log.Info("Connecting to HostManager")
 	t := rpc.NewTransport()
+	peerTransport := rpc.NewPeerTransport(t, tlsProvider)
 	hostmgrPeerChooser, err := peer.NewSmartChooser(
 		cfg.Election,
 		rootScope,
 		common.HostManagerRole,
-		t,
+		peerTransport,
 	)
 	if err != nil {
 		log.WithFields(
@@ -241,7 +249,7 @@ func main() {
 		cfg.Election,
 		rootScope,
 		common.ResourceManagerRole,
-		t,
+		peerTransport,
 	)
 	if err != nil {
 		log.WithFields(
@@ -270,6 +278,7 @@ func main() {
 		cfg.Placement.HTTPPort,
 		cfg.Placement.GRPCPort,
 		mux,
+		tlsProvider,
 	)
 
 	log.Debug("Creating new YARPC dispatcher")
@@ -310,7 +319,7 @@ func main() {
 		tallyMetrics,
 	)
 
-	strategy := initPlacementStrategy(cfg)
+	strategy := initPlacementStrategy(cfg, rootScope)
 
 	pool := async.NewPool(async.PoolOptions{
 		MaxWorkers: cfg.Placement.Concurrency,
@@ -343,11 +352,20 @@ func main() {
 	select {}
 }
 
-func initPlacementStrategy(cfg config.Config) plugins.Strategy {
+func initPlacementStrategy(
+	cfg config.Config,
+	scope tally.Scope) plugins.Strategy {
 	var strategy plugins.Strategy
 	switch cfg.Placement.Strategy {
 	case config.Batch:
-		strategy = batch.New()
+		hints := locality.NewHints(
+			cfg.Placement.Locality,
+			scope.SubScope("placement").SubScope("locality"))
+		if hints != nil {
+			strategy = batch.NewWithLocality(hints)
+		} else {
+			strategy = batch.New()
+		}
 	case config.Mimir:
 		// TODO avyas check mimir concurrency parameters
 		cfg.Placement.Concurrency = 1
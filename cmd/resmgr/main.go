@@ -237,11 +237,17 @@ func main() {
 
 	store := stores.MustCreateStore(&cfg.Storage, rootScope)
 
+	tlsProvider, err := rpc.NewTLSProvider(&cfg.TLS)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load TLS certificates")
+	}
+
 	// Create both HTTP and GRPC inbounds
 	inbounds := rpc.NewInbounds(
 		cfg.ResManager.HTTPPort,
 		cfg.ResManager.GRPCPort,
 		mux,
+		tlsProvider,
 	)
 
 	// all leader discovery metrics share a scope (and will be tagged
@@ -250,11 +256,12 @@ func main() {
 	// setup the discovery service to detect hostmgr leaders and
 	// configure the YARPC Peer dynamically
 	t := rpc.NewTransport()
+	peerTransport := rpc.NewPeerTransport(t, tlsProvider)
 	hostmgrPeerChooser, err := peer.NewSmartChooser(
 		cfg.Election,
 		discoveryScope,
 		common.HostManagerRole,
-		t,
+		peerTransport,
 	)
 	if err != nil {
 		log.
@@ -348,6 +355,14 @@ func main() {
 		task.GetTracker(),
 		preemptor)
 
+	// Initializing the priority inversion detector
+	inversionDetector := task.NewInversionDetector(
+		task.GetTracker(),
+		rootScope,
+		cfg.ResManager.PriorityInversionPeriod,
+		cfg.ResManager.PriorityInversionThreshold,
+	)
+
 	// Initialize resource manager service handlers
 	serviceHandler := resmgr.NewServiceHandler(
 		dispatcher,
@@ -382,6 +397,7 @@ func main() {
 		reconciler,
 		preemptor,
 		drainer,
+		inversionDetector,
 	)
 
 	candidate, err := leader.NewCandidate(
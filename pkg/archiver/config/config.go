@@ -21,6 +21,7 @@ import (
 	"github.com/uber/peloton/pkg/common/leader"
 	"github.com/uber/peloton/pkg/common/logging"
 	"github.com/uber/peloton/pkg/common/metrics"
+	"github.com/uber/peloton/pkg/common/rpc"
 )
 
 const (
@@ -53,6 +54,7 @@ type Config struct {
 	Archiver     ArchiverConfig        `yaml:"archiver"`
 	Health       health.Config         `yaml:"health"`
 	SentryConfig logging.SentryConfig  `yaml:"sentry"`
+	TLS          rpc.TLSConfig         `yaml:"tls"`
 }
 
 // ArchiverConfig contains archiver specific configuration
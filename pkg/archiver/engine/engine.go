@@ -29,6 +29,7 @@ import (
 	"github.com/uber/peloton/pkg/common"
 	"github.com/uber/peloton/pkg/common/backoff"
 	"github.com/uber/peloton/pkg/common/leader"
+	"github.com/uber/peloton/pkg/common/rpc"
 	"github.com/uber/peloton/pkg/common/util"
 
 	"github.com/golang/protobuf/ptypes"
@@ -92,13 +93,15 @@ type engine struct {
 	retryPolicy backoff.RetryPolicy
 }
 
-// New creates a new Archiver Engine.
+// New creates a new Archiver Engine. The job manager is called over
+// mutual TLS if tlsProvider is not nil.
 func New(
 	cfg config.Config,
 	scope tally.Scope,
 	mux *nethttp.ServeMux,
 	discovery leader.Discovery,
-	inbounds []transport.Inbound) (Engine, error) {
+	inbounds []transport.Inbound,
+	tlsProvider *rpc.TLSProvider) (Engine, error) {
 	cfg.Archiver.Normalize()
 
 	jobmgrURL, err := discovery.GetAppURL(common.JobManagerRole)
@@ -113,7 +116,7 @@ func New(
 		Inbounds: inbounds,
 		Outbounds: yarpc.Outbounds{
 			common.PelotonJobManager: transport.Outbounds{
-				Unary: t.NewSingleOutbound(jobmgrURL.Host),
+				Unary: rpc.NewSingleOutbound(t, jobmgrURL.Host, tlsProvider),
 			},
 		},
 		Metrics: yarpc.MetricsConfig{
@@ -86,7 +86,7 @@ func (suite *archiverEngineTestSuite) TestEngineNew() {
 		jobmgrURL, &url.URL{}, &url.URL{})
 	suite.NoError(err)
 	_, err = New(config.Config{}, tally.NoopScope, nethttp.NewServeMux(),
-		d, []transport.Inbound{})
+		d, []transport.Inbound{}, nil)
 	suite.NoError(err)
 }
 
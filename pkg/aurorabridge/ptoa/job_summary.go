@@ -126,6 +126,8 @@ func newJobStats(s *stateless.JobStatus) *api.JobStats {
 		// failed
 		case task.TaskState_FAILED.String():
 			fallthrough
+		case task.TaskState_FAILED_HOLD.String():
+			fallthrough
 		case task.TaskState_LOST.String():
 			failed += c
 		}
@@ -33,10 +33,12 @@ import (
 	podsvc "github.com/uber/peloton/.gen/peloton/api/v1alpha/pod/svc"
 	watchsvc "github.com/uber/peloton/.gen/peloton/api/v1alpha/watch/svc"
 	hostmgr_svc "github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc"
+	"github.com/uber/peloton/.gen/peloton/private/jobmgrsvc"
 	"github.com/uber/peloton/.gen/peloton/private/resmgrsvc"
 
 	"github.com/uber/peloton/pkg/common"
 	"github.com/uber/peloton/pkg/common/leader"
+	"github.com/uber/peloton/pkg/common/rpc"
 )
 
 // Client is a JSON Client with associated dispatcher and context
@@ -52,6 +54,7 @@ type Client struct {
 	volumeClient    volume_svc.VolumeServiceYARPCClient
 	hostMgrClient   hostmgr_svc.InternalHostServiceYARPCClient
 	hostClient      hostsvc.HostServiceYARPCClient
+	jobmgrClient    jobmgrsvc.JobManagerServiceYARPCClient
 	dispatcher      *yarpc.Dispatcher
 	ctx             context.Context
 	cancelFunc      context.CancelFunc
@@ -59,11 +62,13 @@ type Client struct {
 	Debug bool
 }
 
-// New returns a new RPC client given a framework URL and timeout and error
+// New returns a new RPC client given a framework URL and timeout and error.
+// The components are called over mutual TLS if tlsProvider is not nil.
 func New(
 	discovery leader.Discovery,
 	timeout time.Duration,
-	debug bool) (*Client, error) {
+	debug bool,
+	tlsProvider *rpc.TLSProvider) (*Client, error) {
 
 	jobmgrURL, err := discovery.GetAppURL(common.JobManagerRole)
 	if err != nil {
@@ -86,14 +91,14 @@ func New(
 		Name: common.PelotonCLI,
 		Outbounds: yarpc.Outbounds{
 			common.PelotonJobManager: transport.Outbounds{
-				Unary:  t.NewSingleOutbound(jobmgrURL.Host),
-				Stream: t.NewSingleOutbound(jobmgrURL.Host),
+				Unary:  rpc.NewSingleOutbound(t, jobmgrURL.Host, tlsProvider),
+				Stream: rpc.NewSingleOutbound(t, jobmgrURL.Host, tlsProvider),
 			},
 			common.PelotonResourceManager: transport.Outbounds{
-				Unary: t.NewSingleOutbound(resmgrURL.Host),
+				Unary: rpc.NewSingleOutbound(t, resmgrURL.Host, tlsProvider),
 			},
 			common.PelotonHostManager: transport.Outbounds{
-				Unary: t.NewSingleOutbound(hostmgrURL.Host),
+				Unary: rpc.NewSingleOutbound(t, hostmgrURL.Host, tlsProvider),
 			},
 		},
 	})
@@ -137,6 +142,9 @@ func New(
 		watchClient: watchsvc.NewWatchServiceYARPCClient(
 			dispatcher.ClientConfig(common.PelotonJobManager),
 		),
+		jobmgrClient: jobmgrsvc.NewJobManagerServiceYARPCClient(
+			dispatcher.ClientConfig(common.PelotonJobManager),
+		),
 		dispatcher: dispatcher,
 		ctx:        ctx,
 		cancelFunc: cancelFunc,
@@ -33,6 +33,9 @@ const (
 	hostSeparator         = ","
 	getHostsFormatHeader  = "Hostname\tCPU\tGPU\tMEM\tDisk\tState\t\n"
 	getHostsFormatBody    = "%s\t%.2f\t%.2f\t%.2f MB\t%.2f MB\t%s\t\n"
+
+	drainHeadroomFormatHeader = "Job Id\tInstances\tDrained\tUnavailable\tMax Unavailable\tHeadroom\tSLA Violated\t\n"
+	drainHeadroomFormatBody   = "%s\t%d\t%d\t%d\t%d\t%d\t%t\t\n"
 )
 
 // HostMaintenanceStartAction is the action for starting host maintenance. StartMaintenance puts the host(s)
@@ -82,6 +85,118 @@ func (c *Client) HostMaintenanceCompleteAction(hosts string) error {
 	return nil
 }
 
+// HostGroupDrainStartAction is the action for starting the drain of a
+// host group. Only one host group can be drained at a time.
+func (c *Client) HostGroupDrainStartAction(group string, hosts string) error {
+	hostnames, err := c.ExtractHostnames(hosts, hostSeparator)
+	if err != nil {
+		return err
+	}
+
+	request := &host_svc.StartHostGroupDrainRequest{
+		Group:     group,
+		Hostnames: hostnames,
+	}
+	_, err = c.hostClient.StartHostGroupDrain(c.ctx, request)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(tabWriter, "Started draining host group %s\n", group)
+	tabWriter.Flush()
+	return nil
+}
+
+// HostGroupDrainStatusAction is the action for getting the progress of
+// the drain of a host group.
+func (c *Client) HostGroupDrainStatusAction(group string) error {
+	request := &host_svc.GetHostGroupDrainStatusRequest{
+		Group: group,
+	}
+	response, err := c.hostClient.GetHostGroupDrainStatus(c.ctx, request)
+	if err != nil {
+		return err
+	}
+
+	if c.Debug {
+		printResponseJSON(response)
+		return nil
+	}
+	fmt.Fprintf(tabWriter, "State: %s\n", response.GetState())
+	fmt.Fprintf(tabWriter, "Start Time: %s\n", response.GetStartTime())
+	if len(response.GetCompletionTime()) != 0 {
+		fmt.Fprintf(tabWriter, "Completion Time: %s\n", response.GetCompletionTime())
+	}
+	printHostQueryResponse(&host_svc.QueryHostsResponse{
+		HostInfos: response.GetHostInfos(),
+	}, false)
+	return nil
+}
+
+// HostGroupDrainCompleteAction is the action for completing the drain of
+// a host group once its hosts have been upgraded, which brings the hosts
+// back UP.
+func (c *Client) HostGroupDrainCompleteAction(group string) error {
+	request := &host_svc.CompleteHostGroupDrainRequest{
+		Group: group,
+	}
+	_, err := c.hostClient.CompleteHostGroupDrain(c.ctx, request)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(tabWriter, "Completed host group %s\n", group)
+	tabWriter.Flush()
+	return nil
+}
+
+// HostDrainHeadroomAction is the action for checking the SLA headroom of
+// the jobs running on a list of hosts before draining them.
+func (c *Client) HostDrainHeadroomAction(hosts string) error {
+	hostnames, err := c.ExtractHostnames(hosts, hostSeparator)
+	if err != nil {
+		return err
+	}
+
+	request := &pb_task.GetDrainHeadroomRequest{
+		Hostnames: hostnames,
+	}
+	response, err := c.taskClient.GetDrainHeadroom(c.ctx, request)
+	if err != nil {
+		return err
+	}
+
+	printDrainHeadroomResponse(response, c.Debug)
+	return nil
+}
+
+func printDrainHeadroomResponse(r *pb_task.GetDrainHeadroomResponse, debug bool) {
+	defer tabWriter.Flush()
+
+	if debug {
+		printResponseJSON(r)
+		return
+	}
+	fmt.Fprintf(tabWriter, "Safe to drain: %t\n", r.GetSafe())
+	if len(r.GetJobs()) == 0 {
+		return
+	}
+	fmt.Fprint(tabWriter, drainHeadroomFormatHeader)
+	for _, j := range r.GetJobs() {
+		fmt.Fprintf(
+			tabWriter,
+			drainHeadroomFormatBody,
+			j.GetJobId().GetValue(),
+			j.GetInstanceCount(),
+			j.GetDrainedInstances(),
+			j.GetUnavailableInstances(),
+			j.GetMaximumUnavailableInstances(),
+			j.GetHeadroom(),
+			j.GetSlaViolated(),
+		)
+	}
+}
+
 // HostQueryAction is the action for querying hosts by states. This can be to used to monitor the state of the host(s)
 // Eg. When a list of hosts are put into maintenance (`host maintenance start`).
 // A host, at any given time, will be in one of the following states
@@ -23,7 +23,9 @@ import (
 	host "github.com/uber/peloton/.gen/peloton/api/v0/host"
 	hostsvc "github.com/uber/peloton/.gen/peloton/api/v0/host/svc"
 	hostmocks "github.com/uber/peloton/.gen/peloton/api/v0/host/svc/mocks"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
 	pb_task "github.com/uber/peloton/.gen/peloton/api/v0/task"
+	taskmocks "github.com/uber/peloton/.gen/peloton/api/v0/task/mocks"
 	hostmgrsvc "github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc"
 	hostmgrMocks "github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc/mocks"
 
@@ -179,6 +181,95 @@ func (suite *hostmgrActionsTestSuite) TestClientHostQueryAction() {
 	}
 }
 
+func (suite *hostmgrActionsTestSuite) TestClientHostGroupDrainActions() {
+	c := Client{
+		Debug:      false,
+		hostClient: suite.mockHostmgr,
+		dispatcher: nil,
+		ctx:        suite.ctx,
+	}
+
+	suite.mockHostmgr.EXPECT().
+		StartHostGroupDrain(gomock.Any(), &hostsvc.StartHostGroupDrainRequest{
+			Group:     "group1",
+			Hostnames: []string{"host1", "host2"},
+		}).
+		Return(&hostsvc.StartHostGroupDrainResponse{}, nil)
+	suite.NoError(c.HostGroupDrainStartAction("group1", "host1,host2"))
+
+	suite.mockHostmgr.EXPECT().
+		StartHostGroupDrain(gomock.Any(), gomock.Any()).
+		Return(nil, fmt.Errorf("fake StartHostGroupDrain error"))
+	suite.Error(c.HostGroupDrainStartAction("group1", "host1"))
+
+	// Test empty hostname error
+	suite.Error(c.HostGroupDrainStartAction("group1", ""))
+
+	for _, debug := range []bool{false, true} {
+		c.Debug = debug
+		suite.mockHostmgr.EXPECT().
+			GetHostGroupDrainStatus(gomock.Any(), gomock.Any()).
+			Return(&hostsvc.GetHostGroupDrainStatusResponse{
+				State: hostsvc.HostGroupDrainState_HOST_GROUP_DRAIN_STATE_DRAINED,
+				HostInfos: []*host.HostInfo{{
+					Hostname: "host1",
+					State:    host.HostState_HOST_STATE_DOWN,
+				}},
+			}, nil)
+		suite.NoError(c.HostGroupDrainStatusAction("group1"))
+	}
+
+	suite.mockHostmgr.EXPECT().
+		GetHostGroupDrainStatus(gomock.Any(), gomock.Any()).
+		Return(nil, fmt.Errorf("fake GetHostGroupDrainStatus error"))
+	suite.Error(c.HostGroupDrainStatusAction("group1"))
+
+	suite.mockHostmgr.EXPECT().
+		CompleteHostGroupDrain(gomock.Any(), &hostsvc.CompleteHostGroupDrainRequest{
+			Group: "group1",
+		}).
+		Return(&hostsvc.CompleteHostGroupDrainResponse{}, nil)
+	suite.NoError(c.HostGroupDrainCompleteAction("group1"))
+
+	suite.mockHostmgr.EXPECT().
+		CompleteHostGroupDrain(gomock.Any(), gomock.Any()).
+		Return(nil, fmt.Errorf("fake CompleteHostGroupDrain error"))
+	suite.Error(c.HostGroupDrainCompleteAction("group1"))
+}
+
+func (suite *hostmgrActionsTestSuite) TestClientHostDrainHeadroomAction() {
+	mockTask := taskmocks.NewMockTaskManagerYARPCClient(suite.mockCtrl)
+	c := Client{
+		Debug:      false,
+		taskClient: mockTask,
+		dispatcher: nil,
+		ctx:        suite.ctx,
+	}
+
+	mockTask.EXPECT().
+		GetDrainHeadroom(gomock.Any(), &pb_task.GetDrainHeadroomRequest{
+			Hostnames: []string{"host1"},
+		}).
+		Return(&pb_task.GetDrainHeadroomResponse{
+			Jobs: []*pb_task.DrainHeadroom{{
+				JobId:                       &peloton.JobID{Value: "job1"},
+				InstanceCount:               3,
+				DrainedInstances:            1,
+				MaximumUnavailableInstances: 1,
+			}},
+			Safe: true,
+		}, nil)
+	suite.NoError(c.HostDrainHeadroomAction("host1"))
+
+	mockTask.EXPECT().
+		GetDrainHeadroom(gomock.Any(), gomock.Any()).
+		Return(nil, fmt.Errorf("fake GetDrainHeadroom error"))
+	suite.Error(c.HostDrainHeadroomAction("host1"))
+
+	// Test empty hostname error
+	suite.Error(c.HostDrainHeadroomAction(""))
+}
+
 type hostmgrActionsInternalTestSuite struct {
 	suite.Suite
 	mockCtrl    *gomock.Controller
@@ -30,6 +30,7 @@ import (
 
 	"github.com/uber/peloton/pkg/common/stringset"
 	"github.com/uber/peloton/pkg/common/util"
+	"github.com/uber/peloton/pkg/jobmgr/job/compat"
 	jobmgrtask "github.com/uber/peloton/pkg/jobmgr/task"
 
 	"github.com/golang/protobuf/ptypes"
@@ -103,6 +104,123 @@ func (c *Client) JobCreateAction(
 	return nil
 }
 
+// JobLintAction is the action for linting a job config against the
+// best-practice rules of a rule set. It fails if the config is invalid,
+// or if it has warnings and failOnWarnings is set.
+func (c *Client) JobLintAction(
+	cfg, ruleSet string, failOnWarnings bool,
+) error {
+	var jobConfig job.JobConfig
+	buffer, err := ioutil.ReadFile(cfg)
+	if err != nil {
+		return fmt.Errorf("unable to open file %s: %v", cfg, err)
+	}
+	if err := yaml.Unmarshal(buffer, &jobConfig); err != nil {
+		return fmt.Errorf("unable to parse file %s: %v", cfg, err)
+	}
+
+	response, err := c.jobClient.LintJobConfig(c.ctx, &job.LintJobConfigRequest{
+		Config:  &jobConfig,
+		RuleSet: ruleSet,
+	})
+	if err != nil {
+		return err
+	}
+	printJobLintResponse(response, c.Debug)
+
+	if len(response.GetValidationError()) != 0 {
+		return fmt.Errorf("invalid job config: %s",
+			response.GetValidationError())
+	}
+	if failOnWarnings && len(response.GetWarnings()) != 0 {
+		return fmt.Errorf("job config has %d warnings",
+			len(response.GetWarnings()))
+	}
+	return nil
+}
+
+// JobCheckCompatAction is the action for checking the stored configs of
+// jobs against the API of the running Peloton version. All the jobs are
+// checked if no job is provided. It fails if any config has an issue.
+func (c *Client) JobCheckCompatAction(
+	jobIDs []string, includeTerminalJobs bool,
+) error {
+	request := &job.CheckJobConfigsRequest{
+		IncludeTerminalJobs: includeTerminalJobs,
+	}
+	for _, jobID := range jobIDs {
+		request.Ids = append(request.Ids, &peloton.JobID{Value: jobID})
+	}
+
+	// the jobs are checked a page at a time by job manager
+	response := &job.CheckJobConfigsResponse{}
+	for {
+		page, err := c.jobClient.CheckJobConfigs(c.ctx, request)
+		if err != nil {
+			return err
+		}
+		response.Jobs = append(response.Jobs, page.GetJobs()...)
+		response.CheckedJobs += page.GetCheckedJobs()
+		if page.GetNextOffset() == 0 {
+			break
+		}
+		request.Offset = page.GetNextOffset()
+	}
+	printJobCheckCompatResponse(response, c.Debug)
+
+	if len(response.GetJobs()) != 0 {
+		return fmt.Errorf("%d jobs have issues or failed to be checked",
+			len(response.GetJobs()))
+	}
+	return nil
+}
+
+// JobCheckCompatConfigAction is the action for checking a job config
+// against the API of this version of the CLI, without the stored configs.
+// It fails if the config has an issue.
+func (c *Client) JobCheckCompatConfigAction(
+	cfg string, maxTasksPerJob uint32,
+) error {
+	var jobConfig job.JobConfig
+	buffer, err := ioutil.ReadFile(cfg)
+	if err != nil {
+		return fmt.Errorf("unable to open file %s: %v", cfg, err)
+	}
+	if err := yaml.Unmarshal(buffer, &jobConfig); err != nil {
+		return fmt.Errorf("unable to parse file %s: %v", cfg, err)
+	}
+
+	response := &job.CheckJobConfigsResponse{CheckedJobs: 1}
+	if issues := compat.Check(&jobConfig, maxTasksPerJob); len(issues) != 0 {
+		response.Jobs = []*job.JobConfigCompatibility{{
+			ConfigVersion: jobConfig.GetChangeLog().GetVersion(),
+			Issues:        issues,
+		}}
+	}
+	printJobCheckCompatResponse(response, c.Debug)
+
+	if len(response.GetJobs()) != 0 {
+		return fmt.Errorf("job config has %d issues",
+			len(response.GetJobs()[0].GetIssues()))
+	}
+	return nil
+}
+
+// JobStateDiffAction is the action for exporting the changes made to the
+// jobs, resource pools and hosts of the cluster between two points in
+// time. The changes are always printed as JSON, for audit tooling.
+func (c *Client) JobStateDiffAction(from, to string) error {
+	response, err := c.jobClient.ExportStateDiff(c.ctx, &job.ExportStateDiffRequest{
+		FromTime: from,
+		ToTime:   to,
+	})
+	if err != nil {
+		return err
+	}
+	printResponseJSON(response)
+	return nil
+}
+
 // JobDeleteAction is the action for deleting a job
 func (c *Client) JobDeleteAction(jobID string) error {
 	var request = &job.DeleteRequest{
@@ -768,6 +886,59 @@ func printJobCreateResponse(r *job.CreateResponse, jsonFormat bool) {
 	}
 }
 
+func printJobLintResponse(r *job.LintJobConfigResponse, jsonFormat bool) {
+	defer tabWriter.Flush()
+
+	if jsonFormat {
+		printResponseJSON(r)
+		return
+	}
+
+	for _, warning := range r.GetWarnings() {
+		if len(warning.GetInstanceIds()) == 0 {
+			fmt.Fprintf(tabWriter, "Warning [%s]: %s\n",
+				warning.GetRule(), warning.GetMessage())
+			continue
+		}
+		fmt.Fprintf(tabWriter, "Warning [%s] for instances %v: %s\n",
+			warning.GetRule(), warning.GetInstanceIds(), warning.GetMessage())
+	}
+	if len(r.GetWarnings()) == 0 {
+		fmt.Fprint(tabWriter, "No warning\n")
+	}
+}
+
+func printJobCheckCompatResponse(
+	r *job.CheckJobConfigsResponse, jsonFormat bool) {
+	defer tabWriter.Flush()
+
+	if jsonFormat {
+		printResponseJSON(r)
+		return
+	}
+
+	for _, result := range r.GetJobs() {
+		jobID := result.GetId().GetValue()
+		if len(result.GetError()) != 0 {
+			fmt.Fprintf(tabWriter, "Job %s: failed to check: %s\n",
+				jobID, result.GetError())
+			continue
+		}
+		for _, issue := range result.GetIssues() {
+			fmt.Fprintf(tabWriter, "Job %s (version %d): %s [%s] %s: %s",
+				jobID, result.GetConfigVersion(), issue.GetKind(),
+				issue.GetCheck(), issue.GetField(), issue.GetMessage())
+			if len(issue.GetInstanceIds()) != 0 {
+				fmt.Fprintf(tabWriter, " for instances %v",
+					issue.GetInstanceIds())
+			}
+			fmt.Fprint(tabWriter, "\n")
+		}
+	}
+	fmt.Fprintf(tabWriter, "Checked %d jobs, %d with issues\n",
+		r.GetCheckedJobs(), len(r.GetJobs()))
+}
+
 func printJobGetResponse(r *job.GetResponse, jsonFormat bool) {
 	if r.GetJobInfo() == nil {
 		fmt.Fprint(tabWriter, "Unable to get job \n")
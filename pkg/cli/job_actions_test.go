@@ -1055,6 +1055,176 @@ func (suite *jobActionsTestSuite) TestClientJobRefreshAction() {
 }
 
 // TestClientJobDeleteAction tests deleting a job
+// TestClientJobLintAction tests linting a job config
+func (suite *jobActionsTestSuite) TestClientJobLintAction() {
+	req := &job.LintJobConfigRequest{
+		Config:  suite.getConfig(),
+		RuleSet: "production",
+	}
+	warnings := []*job.LintWarning{
+		{Rule: "disk", Message: "disk limit is not set"},
+		{Rule: "health_check", Message: "no health check", InstanceIds: []uint32{1}},
+	}
+
+	tt := []struct {
+		resp           *job.LintJobConfigResponse
+		err            error
+		failOnWarnings bool
+		expectErr      bool
+	}{
+		{
+			resp: &job.LintJobConfigResponse{},
+		},
+		{
+			resp: &job.LintJobConfigResponse{Warnings: warnings},
+		},
+		{
+			resp:           &job.LintJobConfigResponse{Warnings: warnings},
+			failOnWarnings: true,
+			expectErr:      true,
+		},
+		{
+			resp: &job.LintJobConfigResponse{
+				ValidationError: "missing command info for instance 0",
+			},
+			expectErr: true,
+		},
+		{
+			err:       errors.New("unable to lint job config"),
+			expectErr: true,
+		},
+	}
+
+	for _, t := range tt {
+		suite.mockJob.EXPECT().
+			LintJobConfig(gomock.Any(), req).
+			Return(t.resp, t.err)
+
+		err := suite.client.JobLintAction(
+			testJobConfig, "production", t.failOnWarnings)
+		if t.expectErr {
+			suite.Error(err)
+		} else {
+			suite.NoError(err)
+		}
+	}
+}
+
+// TestClientJobCheckCompatAction tests checking the stored configs
+// of jobs
+func (suite *jobActionsTestSuite) TestClientJobCheckCompatAction() {
+	req := &job.CheckJobConfigsRequest{
+		Ids:                 []*peloton.JobID{{Value: testJobID}},
+		IncludeTerminalJobs: true,
+	}
+
+	tt := []struct {
+		resp      *job.CheckJobConfigsResponse
+		err       error
+		expectErr bool
+	}{
+		{
+			resp: &job.CheckJobConfigsResponse{CheckedJobs: 1},
+		},
+		{
+			resp: &job.CheckJobConfigsResponse{
+				CheckedJobs: 1,
+				Jobs: []*job.JobConfigCompatibility{{
+					Id: &peloton.JobID{Value: testJobID},
+					Issues: []*job.CompatibilityIssue{{
+						Kind:        job.CompatibilityIssue_KIND_DEPRECATED,
+						Check:       "instance_config",
+						Field:       "instanceConfig",
+						InstanceIds: []uint32{5},
+					}},
+				}},
+			},
+			expectErr: true,
+		},
+		{
+			err:       errors.New("unable to check job configs"),
+			expectErr: true,
+		},
+	}
+
+	for _, t := range tt {
+		suite.mockJob.EXPECT().
+			CheckJobConfigs(gomock.Any(), req).
+			Return(t.resp, t.err)
+
+		err := suite.client.JobCheckCompatAction([]string{testJobID}, true)
+		if t.expectErr {
+			suite.Error(err)
+		} else {
+			suite.NoError(err)
+		}
+	}
+}
+
+// TestClientJobCheckCompatActionPages tests checking the stored configs
+// of all the jobs a page at a time
+func (suite *jobActionsTestSuite) TestClientJobCheckCompatActionPages() {
+	gomock.InOrder(
+		suite.mockJob.EXPECT().
+			CheckJobConfigs(gomock.Any(), &job.CheckJobConfigsRequest{}).
+			Return(&job.CheckJobConfigsResponse{
+				CheckedJobs: 2,
+				NextOffset:  2,
+			}, nil),
+		suite.mockJob.EXPECT().
+			CheckJobConfigs(gomock.Any(), &job.CheckJobConfigsRequest{
+				Offset: 2,
+			}).
+			Return(&job.CheckJobConfigsResponse{
+				CheckedJobs: 1,
+				Jobs: []*job.JobConfigCompatibility{{
+					Id:    &peloton.JobID{Value: testJobID},
+					Error: "DB error",
+				}},
+			}, nil),
+	)
+
+	err := suite.client.JobCheckCompatAction(nil, false)
+	suite.Error(err)
+}
+
+// TestClientJobStateDiffAction tests exporting the changes made to the
+// cluster between two points in time
+func (suite *jobActionsTestSuite) TestClientJobStateDiffAction() {
+	req := &job.ExportStateDiffRequest{
+		FromTime: "2019-01-01T00:00:00Z",
+	}
+	suite.mockJob.EXPECT().
+		ExportStateDiff(gomock.Any(), req).
+		Return(&job.ExportStateDiffResponse{
+			FromSnapshotTime: "2019-01-01T00:00:00Z",
+			Changes: []*job.StateChange{{
+				Kind: job.StateChange_KIND_JOB,
+				Id:   testJobID,
+				Type: job.StateChange_TYPE_ADDED,
+			}},
+		}, nil)
+	suite.NoError(
+		suite.client.JobStateDiffAction("2019-01-01T00:00:00Z", ""))
+
+	suite.mockJob.EXPECT().
+		ExportStateDiff(gomock.Any(), req).
+		Return(nil, errors.New("no snapshot"))
+	suite.Error(
+		suite.client.JobStateDiffAction("2019-01-01T00:00:00Z", ""))
+}
+
+// TestClientJobCheckCompatConfigAction tests checking a job config
+// offline
+func (suite *jobActionsTestSuite) TestClientJobCheckCompatConfigAction() {
+	suite.NoError(
+		suite.client.JobCheckCompatConfigAction(testJobConfig, 100000))
+	suite.Error(
+		suite.client.JobCheckCompatConfigAction(testJobConfig, 1))
+	suite.Error(
+		suite.client.JobCheckCompatConfigAction("not-a-file.yaml", 100000))
+}
+
 func (suite *jobActionsTestSuite) TestClientJobDeleteAction() {
 	tt := []struct {
 		req *job.DeleteRequest
@@ -0,0 +1,432 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/private/jobmgrsvc"
+
+	"go.uber.org/yarpc"
+)
+
+const (
+	goalStateStatusFormatHeader = "Paused Job Types\n"
+	goalStateStatusFormatBody   = "%s\n"
+
+	goalStateQueuesFormatHeader = "Queue\tID\tScheduled Time\tDelay\t" +
+		"Retries\tRunning\tLast Action\tLast Error\t\n"
+	goalStateQueuesFormatBody = "%s\t%s\t%s\t%.1fs\t%d\t%t\t%s\t%s\t\n"
+
+	goalStateEvaluationFormatHeader = "Entity\tID\tAction\tActions\t" +
+		"Skipped\tState\tGoal State\t\n"
+	goalStateEvaluationFormatBody = "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n"
+
+	refreshStatusFormatHeader = "State\tProcessed\tTotal\tFailed\t" +
+		"Start Time\tCompletion Time\t\n"
+	refreshStatusFormatBody  = "%s\t%d\t%d\t%d\t%s\t%s\t\n"
+	refreshFailureFormatBody = "%s\t%s\t\n"
+
+	metricsSnapshotFormatHeader = "Type\tName\tTags\tValue\t\n"
+	metricsSnapshotFormatBody   = "%s\t%s\t%s\t%v\t\n"
+)
+
+// parseJobTypes converts the given job type names to job types.
+func parseJobTypes(names []string) ([]job.JobType, error) {
+	var jobTypes []job.JobType
+	for _, name := range names {
+		jobType, ok := job.JobType_value[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown job type %s", name)
+		}
+		jobTypes = append(jobTypes, job.JobType(jobType))
+	}
+	return jobTypes, nil
+}
+
+// JobMgrPauseGoalStateAction pauses the goal state actions of the given
+// job types in job manager, or of all the job types if none is given,
+// authenticated by the admin token of job manager.
+func (c *Client) JobMgrPauseGoalStateAction(
+	jobTypeNames []string,
+	token string,
+) error {
+	jobTypes, err := parseJobTypes(jobTypeNames)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.jobmgrClient.PauseGoalState(
+		c.ctx,
+		&jobmgrsvc.PauseGoalStateRequest{JobTypes: jobTypes},
+		yarpc.WithHeader("authorization", "Bearer "+token),
+	)
+	if err != nil {
+		return err
+	}
+	printGoalStateStatus(resp.GetPausedJobTypes(), resp, c.Debug)
+	return nil
+}
+
+// JobMgrResumeGoalStateAction resumes the goal state actions of the given
+// job types in job manager, or of all the job types if none is given,
+// authenticated by the admin token of job manager.
+func (c *Client) JobMgrResumeGoalStateAction(
+	jobTypeNames []string,
+	token string,
+) error {
+	jobTypes, err := parseJobTypes(jobTypeNames)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.jobmgrClient.ResumeGoalState(
+		c.ctx,
+		&jobmgrsvc.ResumeGoalStateRequest{JobTypes: jobTypes},
+		yarpc.WithHeader("authorization", "Bearer "+token),
+	)
+	if err != nil {
+		return err
+	}
+	printGoalStateStatus(resp.GetPausedJobTypes(), resp, c.Debug)
+	return nil
+}
+
+// JobMgrGoalStateStatusAction prints the job types whose goal state
+// actions are paused in job manager.
+func (c *Client) JobMgrGoalStateStatusAction() error {
+	resp, err := c.jobmgrClient.GetGoalStateStatus(
+		c.ctx,
+		&jobmgrsvc.GetGoalStateStatusRequest{})
+	if err != nil {
+		return err
+	}
+	printGoalStateStatus(resp.GetPausedJobTypes(), resp, c.Debug)
+	return nil
+}
+
+func printGoalStateStatus(
+	pausedJobTypes []job.JobType,
+	resp interface{},
+	debug bool) {
+	if debug {
+		printResponseJSON(resp)
+		return
+	}
+
+	if len(pausedJobTypes) == 0 {
+		fmt.Fprintf(tabWriter, "Goal state is not paused\n")
+		tabWriter.Flush()
+		return
+	}
+
+	fmt.Fprint(tabWriter, goalStateStatusFormatHeader)
+	for _, jobType := range pausedJobTypes {
+		fmt.Fprintf(tabWriter, goalStateStatusFormatBody, jobType.String())
+	}
+	tabWriter.Flush()
+}
+
+// JobMgrGoalStateQueuesAction prints the entities tracked by the goal
+// state engine of job manager, of the given job or of all the jobs if
+// the job identifier is empty.
+func (c *Client) JobMgrGoalStateQueuesAction(
+	jobID string,
+	failedOnly bool,
+	limit uint32) error {
+	req := &jobmgrsvc.GetGoalStateQueuesRequest{
+		FailedOnly: failedOnly,
+		Limit:      limit,
+	}
+	if len(jobID) > 0 {
+		req.JobId = &peloton.JobID{Value: jobID}
+	}
+
+	resp, err := c.jobmgrClient.GetGoalStateQueues(c.ctx, req)
+	if err != nil {
+		return err
+	}
+	printGoalStateQueues(resp, c.Debug)
+	return nil
+}
+
+func printGoalStateQueues(
+	resp *jobmgrsvc.GetGoalStateQueuesResponse,
+	debug bool) {
+	if debug {
+		printResponseJSON(resp)
+		return
+	}
+
+	queues := []struct {
+		name     string
+		entities []*jobmgrsvc.GoalStateEntity
+	}{
+		{"job", resp.GetJobs()},
+		{"task", resp.GetTasks()},
+		{"update", resp.GetUpdates()},
+	}
+
+	fmt.Fprint(tabWriter, goalStateQueuesFormatHeader)
+	for _, queue := range queues {
+		for _, entity := range queue.entities {
+			fmt.Fprintf(
+				tabWriter,
+				goalStateQueuesFormatBody,
+				queue.name,
+				entity.GetId(),
+				entity.GetScheduledTime(),
+				entity.GetDelaySeconds(),
+				entity.GetRetries(),
+				entity.GetRunning(),
+				entity.GetLastAction(),
+				entity.GetLastError(),
+			)
+		}
+	}
+	tabWriter.Flush()
+}
+
+// JobMgrEvaluateGoalStateAction prints the actions which the goal state
+// engine of job manager would run for the given job, the tasks of the
+// given instances and the updates of the job, without running them.
+func (c *Client) JobMgrEvaluateGoalStateAction(
+	jobID string,
+	instanceIDs []uint32) error {
+	resp, err := c.jobmgrClient.EvaluateGoalState(
+		c.ctx,
+		&jobmgrsvc.EvaluateGoalStateRequest{
+			JobId:       &peloton.JobID{Value: jobID},
+			InstanceIds: instanceIDs,
+		})
+	if err != nil {
+		return err
+	}
+	printGoalStateEvaluations(resp, c.Debug)
+	return nil
+}
+
+func printGoalStateEvaluations(
+	resp *jobmgrsvc.EvaluateGoalStateResponse,
+	debug bool) {
+	if debug {
+		printResponseJSON(resp)
+		return
+	}
+
+	type entityEvaluation struct {
+		name       string
+		evaluation *jobmgrsvc.GoalStateEvaluation
+	}
+
+	evaluations := []entityEvaluation{{"job", resp.GetJob()}}
+	for _, evaluation := range resp.GetTasks() {
+		evaluations = append(evaluations, entityEvaluation{"task", evaluation})
+	}
+	for _, evaluation := range resp.GetUpdates() {
+		evaluations = append(evaluations, entityEvaluation{"update", evaluation})
+	}
+
+	fmt.Fprint(tabWriter, goalStateEvaluationFormatHeader)
+	for _, e := range evaluations {
+		fmt.Fprintf(
+			tabWriter,
+			goalStateEvaluationFormatBody,
+			e.name,
+			e.evaluation.GetId(),
+			e.evaluation.GetAction(),
+			strings.Join(e.evaluation.GetActions(), ","),
+			e.evaluation.GetSkippedReason(),
+			formatStateInputs(e.evaluation.GetState()),
+			formatStateInputs(e.evaluation.GetGoalState()),
+		)
+	}
+	tabWriter.Flush()
+}
+
+// formatStateInputs formats the inputs of a goal state evaluation as
+// key=value pairs sorted by key.
+func formatStateInputs(inputs map[string]string) string {
+	var pairs []string
+	for key, value := range inputs {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// JobMgrStartRefreshAction starts reloading the cache of job manager from
+// storage for the given jobs, or for the jobs in the given states and of
+// the given types if no job is given.
+func (c *Client) JobMgrStartRefreshAction(
+	jobIDs []string,
+	stateNames []string,
+	jobTypeNames []string,
+	rate uint32) error {
+	jobTypes, err := parseJobTypes(jobTypeNames)
+	if err != nil {
+		return err
+	}
+
+	req := &jobmgrsvc.StartRefreshRequest{
+		JobTypes: jobTypes,
+		Rate:     rate,
+	}
+	for _, jobID := range jobIDs {
+		req.JobIds = append(req.JobIds, &peloton.JobID{Value: jobID})
+	}
+	for _, name := range stateNames {
+		state, ok := job.JobState_value[strings.ToUpper(name)]
+		if !ok {
+			return fmt.Errorf("unknown job state %s", name)
+		}
+		req.JobStates = append(req.JobStates, job.JobState(state))
+	}
+
+	resp, err := c.jobmgrClient.StartRefresh(c.ctx, req)
+	if err != nil {
+		return err
+	}
+	if c.Debug {
+		printResponseJSON(resp)
+		return nil
+	}
+	fmt.Printf("Refreshing %d jobs, refresh id: %s\n",
+		resp.GetTotalJobs(), resp.GetRefreshId())
+	return nil
+}
+
+// JobMgrRefreshStatusAction prints the progress of a refresh of the
+// cache of job manager.
+func (c *Client) JobMgrRefreshStatusAction(refreshID string) error {
+	resp, err := c.jobmgrClient.GetRefreshStatus(
+		c.ctx,
+		&jobmgrsvc.GetRefreshStatusRequest{RefreshId: refreshID})
+	if err != nil {
+		return err
+	}
+	printRefreshStatus(resp, c.Debug)
+	return nil
+}
+
+func printRefreshStatus(
+	resp *jobmgrsvc.GetRefreshStatusResponse,
+	debug bool) {
+	if debug {
+		printResponseJSON(resp)
+		return
+	}
+
+	fmt.Fprint(tabWriter, refreshStatusFormatHeader)
+	fmt.Fprintf(
+		tabWriter,
+		refreshStatusFormatBody,
+		resp.GetState().String(),
+		resp.GetProcessedJobs(),
+		resp.GetTotalJobs(),
+		len(resp.GetFailures()),
+		resp.GetStartTime(),
+		resp.GetCompletionTime(),
+	)
+	for _, failure := range resp.GetFailures() {
+		fmt.Fprintf(
+			tabWriter,
+			refreshFailureFormatBody,
+			failure.GetJobId().GetValue(),
+			failure.GetMessage(),
+		)
+	}
+	tabWriter.Flush()
+}
+
+// JobMgrMetricsSnapshotAction prints the totals of the counters, gauges,
+// timers and histograms of job manager whose name contains one of the
+// given filters.
+func (c *Client) JobMgrMetricsSnapshotAction(filters []string) error {
+	resp, err := c.jobmgrClient.GetMetricsSnapshot(
+		c.ctx,
+		&jobmgrsvc.GetMetricsSnapshotRequest{Filters: filters})
+	if err != nil {
+		return err
+	}
+	printMetricsSnapshot(resp, c.Debug)
+	return nil
+}
+
+func printMetricsSnapshot(
+	resp *jobmgrsvc.GetMetricsSnapshotResponse,
+	debug bool) {
+	if debug {
+		printResponseJSON(resp)
+		return
+	}
+
+	fmt.Fprint(tabWriter, metricsSnapshotFormatHeader)
+	for _, counter := range resp.GetCounters() {
+		fmt.Fprintf(
+			tabWriter,
+			metricsSnapshotFormatBody,
+			"counter",
+			counter.GetName(),
+			formatStateInputs(counter.GetTags()),
+			counter.GetValue(),
+		)
+	}
+	for _, gauge := range resp.GetGauges() {
+		fmt.Fprintf(
+			tabWriter,
+			metricsSnapshotFormatBody,
+			"gauge",
+			gauge.GetName(),
+			formatStateInputs(gauge.GetTags()),
+			gauge.GetValue(),
+		)
+	}
+	for _, timer := range resp.GetTimers() {
+		value := fmt.Sprintf("count=%d sum=%v max=%v",
+			timer.GetCount(),
+			time.Duration(timer.GetSumNs()),
+			time.Duration(timer.GetMaxNs()))
+		fmt.Fprintf(
+			tabWriter,
+			metricsSnapshotFormatBody,
+			"timer",
+			timer.GetName(),
+			formatStateInputs(timer.GetTags()),
+			value,
+		)
+	}
+	for _, histogram := range resp.GetHistograms() {
+		var buckets []string
+		for _, bucket := range histogram.GetBuckets() {
+			buckets = append(buckets, fmt.Sprintf("<=%v:%d",
+				bucket.GetUpperBound(), bucket.GetSamples()))
+		}
+		fmt.Fprintf(
+			tabWriter,
+			metricsSnapshotFormatBody,
+			"histogram",
+			histogram.GetName(),
+			formatStateInputs(histogram.GetTags()),
+			strings.Join(buckets, " "),
+		)
+	}
+	tabWriter.Flush()
+}
@@ -0,0 +1,254 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/private/jobmgrsvc"
+	jobmgrsvcmocks "github.com/uber/peloton/.gen/peloton/private/jobmgrsvc/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+)
+
+type jobmgrActionsTestSuite struct {
+	suite.Suite
+	ctrl         *gomock.Controller
+	jobmgrClient *jobmgrsvcmocks.MockJobManagerServiceYARPCClient
+	client       Client
+}
+
+func TestJobmgrActions(t *testing.T) {
+	suite.Run(t, new(jobmgrActionsTestSuite))
+}
+
+func (suite *jobmgrActionsTestSuite) SetupTest() {
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.jobmgrClient = jobmgrsvcmocks.NewMockJobManagerServiceYARPCClient(suite.ctrl)
+	suite.client = Client{
+		Debug:        false,
+		jobmgrClient: suite.jobmgrClient,
+		dispatcher:   nil,
+		ctx:          context.Background(),
+	}
+}
+
+func (suite *jobmgrActionsTestSuite) TearDownTest() {
+	suite.ctrl.Finish()
+}
+
+// TestPauseGoalState tests pausing the goal state of a job type.
+func (suite *jobmgrActionsTestSuite) TestPauseGoalState() {
+	suite.jobmgrClient.EXPECT().
+		PauseGoalState(gomock.Any(), &jobmgrsvc.PauseGoalStateRequest{
+			JobTypes: []job.JobType{job.JobType_SERVICE},
+		}, gomock.Any()).
+		Return(&jobmgrsvc.PauseGoalStateResponse{
+			PausedJobTypes: []job.JobType{job.JobType_SERVICE},
+		}, nil)
+
+	suite.NoError(suite.client.JobMgrPauseGoalStateAction(
+		[]string{"service"}, "token"))
+}
+
+// TestPauseGoalStateUnknownJobType tests pausing the goal state of an
+// unknown job type fails without calling job manager.
+func (suite *jobmgrActionsTestSuite) TestPauseGoalStateUnknownJobType() {
+	suite.Error(suite.client.JobMgrPauseGoalStateAction(
+		[]string{"unknown"}, "token"))
+}
+
+// TestResumeGoalState tests resuming the goal state of all job types.
+func (suite *jobmgrActionsTestSuite) TestResumeGoalState() {
+	suite.jobmgrClient.EXPECT().
+		ResumeGoalState(
+			gomock.Any(), &jobmgrsvc.ResumeGoalStateRequest{}, gomock.Any()).
+		Return(&jobmgrsvc.ResumeGoalStateResponse{}, nil)
+
+	suite.NoError(suite.client.JobMgrResumeGoalStateAction(nil, "token"))
+}
+
+// TestGoalStateStatus tests printing the job types which are paused.
+func (suite *jobmgrActionsTestSuite) TestGoalStateStatus() {
+	suite.jobmgrClient.EXPECT().
+		GetGoalStateStatus(gomock.Any(), gomock.Any()).
+		Return(&jobmgrsvc.GetGoalStateStatusResponse{
+			PausedJobTypes: []job.JobType{job.JobType_BATCH},
+		}, nil)
+	suite.NoError(suite.client.JobMgrGoalStateStatusAction())
+
+	suite.jobmgrClient.EXPECT().
+		GetGoalStateStatus(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("test error"))
+	suite.Error(suite.client.JobMgrGoalStateStatusAction())
+}
+
+// TestGoalStateQueues tests printing the goal state entities of a job.
+func (suite *jobmgrActionsTestSuite) TestGoalStateQueues() {
+	suite.jobmgrClient.EXPECT().
+		GetGoalStateQueues(gomock.Any(), &jobmgrsvc.GetGoalStateQueuesRequest{
+			JobId:      &peloton.JobID{Value: "job"},
+			FailedOnly: true,
+			Limit:      10,
+		}).
+		Return(&jobmgrsvc.GetGoalStateQueuesResponse{
+			Tasks: []*jobmgrsvc.GoalStateEntity{
+				{
+					Id:           "job-0",
+					Scheduled:    true,
+					DelaySeconds: 10,
+					Retries:      1,
+					LastAction:   "StartTask",
+					LastError:    "timeout",
+				},
+			},
+		}, nil)
+
+	suite.NoError(suite.client.JobMgrGoalStateQueuesAction("job", true, 10))
+}
+
+// TestGoalStateQueuesError tests the failure to get the goal state
+// entities of all the jobs.
+func (suite *jobmgrActionsTestSuite) TestGoalStateQueuesError() {
+	suite.jobmgrClient.EXPECT().
+		GetGoalStateQueues(gomock.Any(), &jobmgrsvc.GetGoalStateQueuesRequest{}).
+		Return(nil, errors.New("unavailable"))
+
+	suite.Error(suite.client.JobMgrGoalStateQueuesAction("", false, 0))
+}
+
+// TestEvaluateGoalState tests printing the goal state evaluation of a
+// job and of one of its tasks.
+func (suite *jobmgrActionsTestSuite) TestEvaluateGoalState() {
+	suite.jobmgrClient.EXPECT().
+		EvaluateGoalState(gomock.Any(), &jobmgrsvc.EvaluateGoalStateRequest{
+			JobId:       &peloton.JobID{Value: "job"},
+			InstanceIds: []uint32{0},
+		}).
+		Return(&jobmgrsvc.EvaluateGoalStateResponse{
+			Job: &jobmgrsvc.GoalStateEvaluation{
+				Id:      "job",
+				Action:  "noop",
+				Actions: []string{"runtime_update"},
+			},
+			Tasks: []*jobmgrsvc.GoalStateEvaluation{
+				{
+					Id:        "job-0",
+					State:     map[string]string{"state": "INITIALIZED"},
+					GoalState: map[string]string{"state": "RUNNING"},
+					Action:    "start_task",
+					Actions:   []string{"start_task"},
+				},
+			},
+		}, nil)
+	suite.NoError(suite.client.JobMgrEvaluateGoalStateAction(
+		"job", []uint32{0}))
+
+	suite.jobmgrClient.EXPECT().
+		EvaluateGoalState(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("not found"))
+	suite.Error(suite.client.JobMgrEvaluateGoalStateAction("job", nil))
+}
+
+// TestStartRefresh tests starting to refresh the running service jobs.
+func (suite *jobmgrActionsTestSuite) TestStartRefresh() {
+	suite.jobmgrClient.EXPECT().
+		StartRefresh(gomock.Any(), &jobmgrsvc.StartRefreshRequest{
+			JobStates: []job.JobState{job.JobState_RUNNING},
+			JobTypes:  []job.JobType{job.JobType_SERVICE},
+			Rate:      20,
+		}).
+		Return(&jobmgrsvc.StartRefreshResponse{
+			RefreshId: "refresh",
+			TotalJobs: 2,
+		}, nil)
+
+	suite.NoError(suite.client.JobMgrStartRefreshAction(
+		nil, []string{"running"}, []string{"service"}, 20))
+}
+
+// TestStartRefreshUnknownState tests starting a refresh of the jobs in
+// an unknown state.
+func (suite *jobmgrActionsTestSuite) TestStartRefreshUnknownState() {
+	suite.Error(suite.client.JobMgrStartRefreshAction(
+		nil, []string{"unknown"}, nil, 0))
+}
+
+// TestRefreshStatus tests printing the progress of a refresh.
+func (suite *jobmgrActionsTestSuite) TestRefreshStatus() {
+	suite.jobmgrClient.EXPECT().
+		GetRefreshStatus(gomock.Any(), &jobmgrsvc.GetRefreshStatusRequest{
+			RefreshId: "refresh",
+		}).
+		Return(&jobmgrsvc.GetRefreshStatusResponse{
+			State:         jobmgrsvc.RefreshState_REFRESH_STATE_FAILED,
+			TotalJobs:     2,
+			ProcessedJobs: 2,
+			Failures: []*jobmgrsvc.RefreshFailure{
+				{JobId: &peloton.JobID{Value: "job"}, Message: "not found"},
+			},
+		}, nil)
+
+	suite.NoError(suite.client.JobMgrRefreshStatusAction("refresh"))
+}
+
+// TestMetricsSnapshot tests printing the metrics of job manager matching
+// a filter.
+func (suite *jobmgrActionsTestSuite) TestMetricsSnapshot() {
+	suite.jobmgrClient.EXPECT().
+		GetMetricsSnapshot(gomock.Any(), &jobmgrsvc.GetMetricsSnapshotRequest{
+			Filters: []string{"goalstate"},
+		}).
+		Return(&jobmgrsvc.GetMetricsSnapshotResponse{
+			Counters: []*jobmgrsvc.MetricSnapshot{
+				{Name: "jobmgr.goalstate.task_actions", Value: 2},
+			},
+			Gauges: []*jobmgrsvc.MetricSnapshot{
+				{
+					Name:  "jobmgr.goalstate.queue_depth",
+					Tags:  map[string]string{"queue": "task"},
+					Value: 10,
+				},
+			},
+			Timers: []*jobmgrsvc.TimerSnapshot{
+				{
+					Name:  "jobmgr.goalstate.run_duration",
+					Count: 2,
+					SumNs: 3000,
+					MaxNs: 2000,
+				},
+			},
+			Histograms: []*jobmgrsvc.HistogramSnapshot{
+				{
+					Name: "jobmgr.goalstate.run_duration_histogram",
+					Buckets: []*jobmgrsvc.HistogramBucketSnapshot{
+						{UpperBound: 1000, Samples: 1},
+					},
+				},
+			},
+		}, nil)
+	suite.NoError(suite.client.JobMgrMetricsSnapshotAction(
+		[]string{"goalstate"}))
+
+	suite.jobmgrClient.EXPECT().
+		GetMetricsSnapshot(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("unimplemented"))
+	suite.Error(suite.client.JobMgrMetricsSnapshotAction(nil))
+}
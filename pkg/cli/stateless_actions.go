@@ -856,12 +856,22 @@ func (c *Client) StatelessRestartJobAction(
 	instanceRanges []*task.InstanceRange,
 	opaqueData string,
 	inPlace bool,
+	healthWaitSeconds uint32,
+	order string,
 ) error {
 	var opaque *v1alphapeloton.OpaqueData
 	if len(opaqueData) > 0 {
 		opaque = &v1alphapeloton.OpaqueData{Data: opaqueData}
 	}
 
+	restartOrder := stateless.RestartOrder_RESTART_ORDER_INSTANCE_ID
+	switch order {
+	case "reverse-instance-id":
+		restartOrder = stateless.RestartOrder_RESTART_ORDER_REVERSE_INSTANCE_ID
+	case "unhealthy-first":
+		restartOrder = stateless.RestartOrder_RESTART_ORDER_UNHEALTHY_FIRST
+	}
+
 	var idInstanceRanges []*v1alphapod.InstanceIDRange
 	for _, instanceRange := range instanceRanges {
 		idInstanceRanges = append(idInstanceRanges, &v1alphapod.InstanceIDRange{
@@ -874,9 +884,11 @@ func (c *Client) StatelessRestartJobAction(
 		JobId:   &v1alphapeloton.JobID{Value: jobID},
 		Version: &v1alphapeloton.EntityVersion{Value: entityVersion},
 		RestartSpec: &stateless.RestartSpec{
-			BatchSize: batchSize,
-			Ranges:    idInstanceRanges,
-			InPlace:   inPlace,
+			BatchSize:         batchSize,
+			Ranges:            idInstanceRanges,
+			InPlace:           inPlace,
+			HealthWaitSeconds: healthWaitSeconds,
+			Order:             restartOrder,
 		},
 		OpaqueData: opaque,
 	}
@@ -841,7 +841,9 @@ func (suite *statelessActionsTestSuite) TestStatelessRestartJobActionSuccess() {
 				Ranges: []*v1alphapod.InstanceIDRange{
 					{From: 0, To: 10},
 				},
-				InPlace: true,
+				InPlace:           true,
+				HealthWaitSeconds: 30,
+				Order:             stateless.RestartOrder_RESTART_ORDER_UNHEALTHY_FIRST,
 			},
 			OpaqueData: &v1alphapeloton.OpaqueData{Data: opaque},
 		}).
@@ -854,6 +856,8 @@ func (suite *statelessActionsTestSuite) TestStatelessRestartJobActionSuccess() {
 		instanceRanges,
 		opaque,
 		true,
+		30,
+		"unhealthy-first",
 	))
 }
 
@@ -887,6 +891,8 @@ func (suite *statelessActionsTestSuite) TestStatelessRestartJobActionFailure() {
 		instanceRanges,
 		opaque,
 		false,
+		0,
+		"instance-id",
 	))
 }
 
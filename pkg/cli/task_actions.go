@@ -17,8 +17,10 @@ package cli
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"sort"
 	"strings"
 	"time"
@@ -31,9 +33,11 @@ import (
 const (
 	taskListFormatHeader = "Instance\tName\tState\tHealthy\tStart Time\tRun Time\t" +
 		"Host\tMessage\tReason\t\n"
-	taskListFormatBody    = "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n"
-	podEventsFormatHeader = "Mesos Task Id\tDesired Mesos Task Id\tActual State\tGoal State\tConfig Version\tDesired Config Version\tHealthy\tHost\tMessage\tReason\tUpdate Time\t\n"
-	podEventsFormatBody   = "%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t\n"
+	taskListFormatBody       = "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n"
+	podEventsFormatHeader    = "Mesos Task Id\tDesired Mesos Task Id\tActual State\tGoal State\tConfig Version\tDesired Config Version\tHealthy\tHost\tMessage\tReason\tUpdate Time\t\n"
+	podEventsFormatBody      = "%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t\n"
+	taskTimelineFormatHeader = "Time\tSource\tState\tHost\tMessage\tReason\t\n"
+	taskTimelineFormatBody   = "%s\t%s\t%s\t%s\t%s\t%s\t\n"
 )
 
 // sortedTaskInfoList makes TaskInfo implement sortable interface
@@ -78,7 +82,9 @@ func (c *Client) TaskGetCacheAction(jobID string, instanceID uint32) error {
 }
 
 // TaskLogsGetAction is the action to get logs files for given job instance.
-func (c *Client) TaskLogsGetAction(fileName string, jobID string, instanceID uint32, taskID string) error {
+// If executor is set, the logs are looked up in the sandbox of the executor
+// of the task.
+func (c *Client) TaskLogsGetAction(fileName string, jobID string, instanceID uint32, taskID string, executor bool) error {
 	var request = &task.BrowseSandboxRequest{
 		JobId: &peloton.JobID{
 			Value: jobID,
@@ -86,6 +92,9 @@ func (c *Client) TaskLogsGetAction(fileName string, jobID string, instanceID uin
 		InstanceId: instanceID,
 		TaskId:     taskID,
 	}
+	if executor {
+		request.Scope = task.SandboxScope_SANDBOX_SCOPE_EXECUTOR
+	}
 	response, err := c.taskClient.BrowseSandbox(c.ctx, request)
 	if err != nil {
 		return err
@@ -131,6 +140,38 @@ func (c *Client) TaskLogsGetAction(fileName string, jobID string, instanceID uin
 	return nil
 }
 
+// TaskExecAction is the action to execute a command in the container of
+// a running task. The output of the command is written to stdout and
+// stderr as it is streamed back.
+func (c *Client) TaskExecAction(jobID string, instanceID uint32, command string) error {
+	stream, err := c.taskClient.RunTaskCommand(c.ctx, &task.RunTaskCommandRequest{
+		JobId: &peloton.JobID{
+			Value: jobID,
+		},
+		InstanceId: instanceID,
+		Command:    command,
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		os.Stdout.Write(resp.GetStdout())
+		os.Stderr.Write(resp.GetStderr())
+		if resp.GetExited() && resp.GetExitCode() != 0 {
+			return fmt.Errorf("command exited with code %d", resp.GetExitCode())
+		}
+	}
+}
+
 // TaskGetEventsAction is the action to get a task instance
 func (c *Client) TaskGetEventsAction(jobID string, instanceID uint32) error {
 	var request = &task.GetPodEventsRequest{
@@ -171,6 +212,26 @@ func (c *Client) PodGetEventsAction(
 	return nil
 }
 
+// TaskGetTimelineAction returns the lifecycle of the recent runs of a task
+func (c *Client) TaskGetTimelineAction(
+	jobID string,
+	instanceID uint32,
+	limit uint32) error {
+	var request = &task.GetTaskTimelineRequest{
+		JobId: &peloton.JobID{
+			Value: jobID,
+		},
+		InstanceId: instanceID,
+		Limit:      limit,
+	}
+	response, err := c.taskClient.GetTaskTimeline(c.ctx, request)
+	if err != nil {
+		return err
+	}
+	printTaskGetTimelineResponse(response, c.Debug)
+	return nil
+}
+
 // TaskListAction is the action to list tasks
 func (c *Client) TaskListAction(jobID string, instanceRange *task.InstanceRange) error {
 	var request = &task.ListRequest{
@@ -446,6 +507,41 @@ func printPodGetEventsResponse(r *task.GetPodEventsResponse, debug bool) {
 	}
 }
 
+func printTaskGetTimelineResponse(r *task.GetTaskTimelineResponse, debug bool) {
+	defer tabWriter.Flush()
+
+	if debug {
+		printResponseJSON(r)
+		return
+	}
+
+	for _, run := range r.GetRuns() {
+		fmt.Fprintf(tabWriter, "Mesos Task Id: %s\n", run.GetTaskId().GetValue())
+		if run.GetTimeToPlaceSeconds() > 0 {
+			fmt.Fprintf(tabWriter, "Time to place: %s\n",
+				time.Duration(run.GetTimeToPlaceSeconds()*float64(time.Second)))
+		}
+		if run.GetTimeToLaunchSeconds() > 0 {
+			fmt.Fprintf(tabWriter, "Time to launch: %s\n",
+				time.Duration(run.GetTimeToLaunchSeconds()*float64(time.Second)))
+		}
+		fmt.Fprint(tabWriter, taskTimelineFormatHeader)
+		for _, event := range run.GetEvents() {
+			fmt.Fprintf(
+				tabWriter,
+				taskTimelineFormatBody,
+				event.GetTimestamp(),
+				strings.TrimPrefix(event.GetSource().String(), "SOURCE_"),
+				event.GetState().String(),
+				event.GetHostname(),
+				event.GetMessage(),
+				event.GetReason(),
+			)
+		}
+		fmt.Fprint(tabWriter, "\n")
+	}
+}
+
 func printTaskListResponse(r *task.ListResponse, debug bool) {
 	defer tabWriter.Flush()
 
@@ -17,6 +17,7 @@ package cli
 import (
 	"context"
 	"errors"
+	"io"
 	"testing"
 	"time"
 
@@ -317,6 +318,58 @@ func (suite *taskActionsTestSuite) TestGetPodEvents() {
 	suite.NoError(err)
 }
 
+func (suite *taskActionsTestSuite) TestClientTaskGetTimelineAction() {
+	c := Client{
+		Debug:      false,
+		taskClient: suite.mockTask,
+		dispatcher: nil,
+		ctx:        suite.ctx,
+	}
+
+	jobID := &peloton.JobID{
+		Value: uuid.New(),
+	}
+	runID := "taskid"
+	req := &task.GetTaskTimelineRequest{
+		JobId:      jobID,
+		InstanceId: 0,
+		Limit:      5,
+	}
+
+	suite.mockTask.EXPECT().GetTaskTimeline(context.Background(), req).
+		Return(nil, errors.New("get task timeline request failed"))
+	err := c.TaskGetTimelineAction(jobID.GetValue(), 0, 5)
+	suite.Error(err)
+
+	response := &task.GetTaskTimelineResponse{
+		Runs: []*task.TaskRunTimeline{
+			{
+				TaskId: &mesos.TaskID{
+					Value: &runID,
+				},
+				Events: []*task.TaskTimelineEvent{
+					{
+						Source:    task.TaskEvent_SOURCE_RESMGR,
+						State:     task.TaskState_PLACED,
+						Timestamp: "2019-01-01T00:00:00Z",
+					},
+				},
+				TimeToPlaceSeconds: 10,
+			},
+		},
+	}
+	suite.mockTask.EXPECT().GetTaskTimeline(context.Background(), req).
+		Return(response, nil)
+	err = c.TaskGetTimelineAction(jobID.GetValue(), 0, 5)
+	suite.NoError(err)
+
+	c.Debug = true
+	suite.mockTask.EXPECT().GetTaskTimeline(context.Background(), req).
+		Return(response, nil)
+	err = c.TaskGetTimelineAction(jobID.GetValue(), 0, 5)
+	suite.NoError(err)
+}
+
 func (suite *taskActionsTestSuite) TestClientTaskQueryAction() {
 	c := Client{
 		Debug:      false,
@@ -668,10 +721,56 @@ func (suite *taskActionsTestSuite) TestClientTaskBrowseSandboxAction() {
 			BrowseSandbox(gomock.Any(), t.req).
 			Return(t.resp, t.err)
 
-		suite.Error(c.TaskLogsGetAction("get", jobID.Value, instanceID, taskID))
+		suite.Error(c.TaskLogsGetAction("get", jobID.Value, instanceID, taskID, false))
 	}
 }
 
+// TestClientTaskExecAction tests executing a command in the container of
+// a task, and failing if the command exits with a non-zero code
+func (suite *taskActionsTestSuite) TestClientTaskExecAction() {
+	c := Client{
+		Debug:      false,
+		taskClient: suite.mockTask,
+		dispatcher: nil,
+		ctx:        suite.ctx,
+	}
+
+	jobID := uuid.New()
+	req := &task.RunTaskCommandRequest{
+		JobId:      &peloton.JobID{Value: jobID},
+		InstanceId: 1,
+		Command:    "ls",
+	}
+
+	for exitCode, expectErr := range map[int32]bool{0: false, 1: true} {
+		stream := taskmocks.NewMockTaskManagerServiceRunTaskCommandYARPCClient(
+			suite.mockCtrl)
+		gomock.InOrder(
+			suite.mockTask.EXPECT().
+				RunTaskCommand(gomock.Any(), req).
+				Return(stream, nil),
+			stream.EXPECT().Recv().
+				Return(&task.RunTaskCommandResponse{Stdout: []byte("out\n")}, nil),
+			stream.EXPECT().Recv().
+				Return(&task.RunTaskCommandResponse{
+					Exited:   true,
+					ExitCode: exitCode,
+				}, nil),
+		)
+		if !expectErr {
+			stream.EXPECT().Recv().Return(nil, io.EOF)
+		}
+
+		err := c.TaskExecAction(jobID, 1, "ls")
+		suite.Equal(expectErr, err != nil)
+	}
+
+	suite.mockTask.EXPECT().
+		RunTaskCommand(gomock.Any(), req).
+		Return(nil, errors.New("task not running"))
+	suite.Error(c.TaskExecAction(jobID, 1, "ls"))
+}
+
 func (suite *taskActionsTestSuite) TestClientTaskRefreshAction() {
 	c := Client{
 		Debug:      false,
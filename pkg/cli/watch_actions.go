@@ -17,15 +17,29 @@ package cli
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/uber/peloton/.gen/peloton/api/v1alpha/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/respool"
 	"github.com/uber/peloton/.gen/peloton/api/v1alpha/watch"
 	watchsvc "github.com/uber/peloton/.gen/peloton/api/v1alpha/watch/svc"
+
+	"go.uber.org/yarpc"
 )
 
 // WatchPod is the action for starting a watch stream for pod, specified
-// by job id and pod names.
-func (c *Client) WatchPod(jobID string, podNames []string) error {
+// by job id, pod names and labels. The pods of the comma separated jobIDs
+// and the pods whose name starts with podNamePrefix are watched as well.
+// If respoolPath is set, only the pods of the jobs in the resource pool
+// or in its descendants are watched.
+func (c *Client) WatchPod(
+	jobID string,
+	podNames []string,
+	labels string,
+	jobIDs string,
+	podNamePrefix string,
+	respoolPath string,
+) error {
 	var j *peloton.JobID
 	if jobID != "" {
 		j = &peloton.JobID{
@@ -33,6 +47,16 @@ func (c *Client) WatchPod(jobID string, podNames []string) error {
 		}
 	}
 
+	var js []*peloton.JobID
+	for _, id := range strings.Split(jobIDs, ",") {
+		if id == "" {
+			continue
+		}
+		js = append(js, &peloton.JobID{
+			Value: id,
+		})
+	}
+
 	var ps []*peloton.PodName
 	for _, p := range podNames {
 		ps = append(ps, &peloton.PodName{
@@ -40,12 +64,28 @@ func (c *Client) WatchPod(jobID string, podNames []string) error {
 		})
 	}
 
+	podLabels, err := parseLabels(labels)
+	if err != nil {
+		return err
+	}
+
+	var r *respool.ResourcePoolPath
+	if respoolPath != "" {
+		r = &respool.ResourcePoolPath{
+			Value: respoolPath,
+		}
+	}
+
 	stream, err := c.watchClient.Watch(
 		c.ctx,
 		&watchsvc.WatchRequest{
 			PodFilter: &watch.PodFilter{
-				JobId:    j,
-				PodNames: ps,
+				JobId:         j,
+				PodNames:      ps,
+				Labels:        podLabels,
+				JobIds:        js,
+				PodNamePrefix: podNamePrefix,
+				Respool:       r,
 			},
 		},
 	)
@@ -93,3 +133,83 @@ func (c *Client) CancelWatch(watchID string) error {
 
 	return nil
 }
+
+// ListWatchers is the action for listing the active watch streams,
+// authenticated by the token of the firehose.
+func (c *Client) ListWatchers(token string) error {
+	resp, err := c.watchClient.ListWatchers(
+		c.ctx,
+		&watchsvc.ListWatchersRequest{},
+		yarpc.WithHeader("authorization", "Bearer "+token),
+	)
+	if err != nil {
+		return err
+	}
+
+	out, err := marshallResponse(defaultResponseFormat, resp)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%v\n", string(out))
+	tabWriter.Flush()
+
+	return nil
+}
+
+// CloseWatcher is the action for force-closing an existing watch stream,
+// authenticated by the token of the firehose.
+func (c *Client) CloseWatcher(watchID string, token string) error {
+	resp, err := c.watchClient.CloseWatcher(
+		c.ctx,
+		&watchsvc.CloseWatcherRequest{WatchId: watchID},
+		yarpc.WithHeader("authorization", "Bearer "+token),
+	)
+	if err != nil {
+		return err
+	}
+
+	out, err := marshallResponse(defaultResponseFormat, resp)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%v\n", string(out))
+	tabWriter.Flush()
+
+	return nil
+}
+
+// ReplayWatch is the action for replaying the pod changes of a job after
+// a revision, page by page, until the changes are caught up.
+func (c *Client) ReplayWatch(jobID string, startRevision uint64) error {
+	var filter *watch.PodFilter
+	if jobID != "" {
+		filter = &watch.PodFilter{
+			JobId: &peloton.JobID{Value: jobID},
+		}
+	}
+
+	for {
+		resp, err := c.watchClient.Replay(
+			c.ctx,
+			&watchsvc.ReplayRequest{
+				StartRevision: startRevision,
+				PodFilter:     filter,
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		out, err := marshallResponse(defaultResponseFormat, resp)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%v\n", string(out))
+		tabWriter.Flush()
+
+		if !resp.GetHasMore() {
+			return nil
+		}
+		startRevision = resp.GetRevision()
+	}
+}
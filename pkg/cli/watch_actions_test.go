@@ -16,11 +16,14 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"io"
 	"testing"
 
 	"github.com/uber/peloton/.gen/peloton/api/v1alpha/peloton"
 	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/respool"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/watch"
 	watchsvc "github.com/uber/peloton/.gen/peloton/api/v1alpha/watch/svc"
 	"github.com/uber/peloton/.gen/peloton/api/v1alpha/watch/svc/mocks"
 
@@ -76,7 +79,25 @@ func (suite *watchActionsTestSuite) TestWatchPod() {
 	}
 
 	suite.watchClient.EXPECT().
-		Watch(gomock.Any(), gomock.Any()).
+		Watch(gomock.Any(), &watchsvc.WatchRequest{
+			PodFilter: &watch.PodFilter{
+				JobId: &peloton.JobID{Value: jobID},
+				PodNames: []*peloton.PodName{
+					{Value: podNames[0]},
+					{Value: podNames[1]},
+				},
+				Labels: []*peloton.Label{
+					{Key: "app", Value: "web"},
+					{Key: "env", Value: "prod"},
+				},
+				JobIds: []*peloton.JobID{
+					{Value: "test-job-id-2"},
+					{Value: "test-job-id-3"},
+				},
+				PodNamePrefix: "test-pod",
+				Respool:       &respool.ResourcePoolPath{Value: "/infra"},
+			},
+		}).
 		Return(stream, nil)
 
 	var calls []*gomock.Call
@@ -87,7 +108,19 @@ func (suite *watchActionsTestSuite) TestWatchPod() {
 
 	gomock.InOrder(calls...)
 
-	suite.NoError(suite.client.WatchPod(jobID, podNames))
+	suite.NoError(suite.client.WatchPod(
+		jobID,
+		podNames,
+		"app=web,env=prod",
+		"test-job-id-2,test-job-id-3",
+		"test-pod",
+		"/infra",
+	))
+}
+
+// TestWatchPodInvalidLabels tests watching pods fails on invalid labels
+func (suite *watchActionsTestSuite) TestWatchPodInvalidLabels() {
+	suite.Error(suite.client.WatchPod("test-job-id", nil, "app", "", "", ""))
 }
 
 func (suite *watchActionsTestSuite) TestCancelWatch() {
@@ -100,6 +133,62 @@ func (suite *watchActionsTestSuite) TestCancelWatch() {
 	suite.NoError(suite.client.CancelWatch(watchID))
 }
 
+func (suite *watchActionsTestSuite) TestListWatchers() {
+	suite.watchClient.EXPECT().
+		ListWatchers(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&watchsvc.ListWatchersResponse{
+			Watchers: []*watch.WatcherInfo{{WatchId: uuid.New()}},
+		}, nil)
+
+	suite.NoError(suite.client.ListWatchers("token"))
+}
+
+func (suite *watchActionsTestSuite) TestCloseWatcher() {
+	watchID := uuid.New()
+
+	suite.watchClient.EXPECT().
+		CloseWatcher(gomock.Any(), &watchsvc.CloseWatcherRequest{
+			WatchId: watchID,
+		}, gomock.Any()).
+		Return(nil, errors.New("watch not found"))
+
+	suite.Error(suite.client.CloseWatcher(watchID, "token"))
+}
+
+func (suite *watchActionsTestSuite) TestReplayWatch() {
+	jobID := uuid.New()
+	filter := &watch.PodFilter{JobId: &peloton.JobID{Value: jobID}}
+
+	gomock.InOrder(
+		suite.watchClient.EXPECT().
+			Replay(gomock.Any(), &watchsvc.ReplayRequest{
+				StartRevision: 10,
+				PodFilter:     filter,
+			}).
+			Return(&watchsvc.ReplayResponse{
+				Changes:  []*watchsvc.PodChange{{Revision: 11}},
+				Revision: 11,
+				HasMore:  true,
+			}, nil),
+		suite.watchClient.EXPECT().
+			Replay(gomock.Any(), &watchsvc.ReplayRequest{
+				StartRevision: 11,
+				PodFilter:     filter,
+			}).
+			Return(&watchsvc.ReplayResponse{Revision: 12}, nil),
+	)
+
+	suite.NoError(suite.client.ReplayWatch(jobID, 10))
+}
+
+func (suite *watchActionsTestSuite) TestReplayWatchError() {
+	suite.watchClient.EXPECT().
+		Replay(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("start revision is too old"))
+
+	suite.Error(suite.client.ReplayWatch("", 10))
+}
+
 func TestWatchActions(t *testing.T) {
 	suite.Run(t, new(watchActionsTestSuite))
 }
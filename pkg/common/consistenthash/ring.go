@@ -0,0 +1,111 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package consistenthash
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// DefaultReplicas is the default number of virtual nodes of each member
+// on the ring.
+const DefaultReplicas = 100
+
+// Ring is an immutable consistent hash ring which assigns keys to
+// members, so that only the keys of a member are reassigned when the
+// member joins or leaves the ring.
+type Ring struct {
+	// hashes of the virtual nodes on the ring, sorted
+	hashes []uint32
+	// member owning each virtual node
+	owners map[uint32]string
+	// members of the ring, sorted
+	members []string
+}
+
+// New creates a ring of the given members, each placed `replicas`
+// times on the ring. The members are deduplicated.
+func New(members []string, replicas int) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+
+	r := &Ring{owners: make(map[uint32]string)}
+	seen := make(map[string]bool)
+	for _, member := range members {
+		if seen[member] {
+			continue
+		}
+		seen[member] = true
+		r.members = append(r.members, member)
+	}
+	sort.Strings(r.members)
+
+	// members are placed in sorted order, so that hash collisions
+	// between virtual nodes are resolved the same way on every ring
+	for _, member := range r.members {
+		for i := 0; i < replicas; i++ {
+			h := hash(strconv.Itoa(i) + "-" + member)
+			if _, ok := r.owners[h]; ok {
+				continue
+			}
+			r.owners[h] = member
+			r.hashes = append(r.hashes, h)
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool {
+		return r.hashes[i] < r.hashes[j]
+	})
+	return r
+}
+
+// Get returns the member owning the key, which is the member of the
+// first virtual node following the hash of the key on the ring. It
+// returns an empty string if the ring has no member.
+func (r *Ring) Get(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+
+	h := hash(key)
+	i := sort.Search(len(r.hashes), func(i int) bool {
+		return r.hashes[i] >= h
+	})
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
+
+// Members returns the sorted members of the ring.
+func (r *Ring) Members() []string {
+	return append([]string(nil), r.members...)
+}
+
+// Shard returns the shard of a key among `numShards` shards, so that
+// the keys can be placed on the ring a shard at a time.
+func Shard(key string, numShards uint32) uint32 {
+	if numShards == 0 {
+		return 0
+	}
+	return hash(key) % numShards
+}
+
+// hash returns the position of a key on the ring
+func hash(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package consistenthash
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRingEmpty(t *testing.T) {
+	r := New(nil, 0)
+	assert.Empty(t, r.Get("key"))
+	assert.Empty(t, r.Members())
+}
+
+func TestRingMembers(t *testing.T) {
+	r := New([]string{"c", "a", "b", "a"}, 10)
+	assert.Equal(t, []string{"a", "b", "c"}, r.Members())
+
+	// every key is owned by a member, independently of the member order
+	other := New([]string{"b", "c", "a"}, 10)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		assert.Contains(t, r.Members(), r.Get(key))
+		assert.Equal(t, r.Get(key), other.Get(key))
+	}
+}
+
+func TestRingBalance(t *testing.T) {
+	members := []string{"m1", "m2", "m3", "m4"}
+	r := New(members, DefaultReplicas)
+
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[r.Get(fmt.Sprintf("key-%d", i))]++
+	}
+	for _, member := range members {
+		// each member owns a quarter of the keys, give or take
+		assert.InDelta(t, 2500, counts[member], 1000, member)
+	}
+}
+
+func TestRingReassignment(t *testing.T) {
+	before := New([]string{"m1", "m2", "m3"}, DefaultReplicas)
+	after := New([]string{"m1", "m2", "m3", "m4"}, DefaultReplicas)
+
+	// adding a member only moves keys to the new member
+	moved := 0
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if before.Get(key) != after.Get(key) {
+			assert.Equal(t, "m4", after.Get(key))
+			moved++
+		}
+	}
+	assert.True(t, moved > 0)
+	assert.True(t, moved < 5000)
+
+	// and removing it moves them back
+	removed := New([]string{"m1", "m2", "m3"}, DefaultReplicas)
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		assert.Equal(t, before.Get(key), removed.Get(key))
+	}
+}
+
+func TestShard(t *testing.T) {
+	assert.Equal(t, uint32(0), Shard("key", 0))
+
+	counts := make(map[uint32]int)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		shard := Shard(key, 4)
+		assert.True(t, shard < 4)
+		assert.Equal(t, shard, Shard(key, 4))
+		counts[shard]++
+	}
+	assert.Len(t, counts, 4)
+}
@@ -118,6 +118,12 @@ const (
 	SystemLabelJobType = "job_type"
 	// SystemLabelCluster is the system label key name for cluster
 	SystemLabelCluster = "cluster"
+	// SystemLabelProvenanceKeyID is the system label key name for the ID
+	// of the key which signed the job config
+	SystemLabelProvenanceKeyID = "provenance_key_id"
+	// SystemLabelProvenanceDigest is the system label key name for the
+	// digest of the signed job config
+	SystemLabelProvenanceDigest = "provenance_digest"
 	// ClusterEnvVar is the cluster environment variable
 	ClusterEnvVar = "CLUSTER"
 )
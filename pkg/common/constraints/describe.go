@@ -0,0 +1,151 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package constraints
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+)
+
+// Describe returns a readable description of each of the constraints which
+// must all be satisfied by a host, i.e. the constraints of a top-level
+// 'and' constraint are described separately.
+func Describe(constraint *task.Constraint) []string {
+	var descriptions []string
+	for _, c := range split(constraint) {
+		descriptions = append(descriptions, describe(c))
+	}
+	return descriptions
+}
+
+// DescribeEvaluation returns the description of each of the constraints
+// which must all be satisfied by a host, as Describe does, followed by the
+// result of evaluating it against the labels of the host and the labels of
+// the tasks on the host, e.g. `host label zone=dca1 count > 0: matched`.
+func DescribeEvaluation(
+	constraint *task.Constraint,
+	hostLabelValues LabelValues,
+	taskLabelValues LabelValues) []string {
+	hostEvaluator := NewEvaluator(task.LabelConstraint_HOST)
+	taskEvaluator := NewEvaluator(task.LabelConstraint_TASK)
+
+	var descriptions []string
+	for _, c := range split(constraint) {
+		var result string
+		hostResult, err := hostEvaluator.Evaluate(c, hostLabelValues)
+		if err == nil {
+			var taskResult EvaluateResult
+			taskResult, err = taskEvaluator.Evaluate(c, taskLabelValues)
+			result = describeResult(hostResult, taskResult)
+		}
+		if err != nil {
+			result = "evaluation failed: " + err.Error()
+		}
+		descriptions = append(descriptions, describe(c)+": "+result)
+	}
+	return descriptions
+}
+
+// split returns the constraints which must all be satisfied by a host, i.e.
+// the constraints of a top-level 'and' constraint.
+func split(constraint *task.Constraint) []*task.Constraint {
+	if constraint == nil {
+		return nil
+	}
+	if constraint.GetType() == task.Constraint_AND_CONSTRAINT {
+		var constraints []*task.Constraint
+		for _, c := range constraint.GetAndConstraint().GetConstraints() {
+			constraints = append(constraints, split(c)...)
+		}
+		return constraints
+	}
+	return []*task.Constraint{constraint}
+}
+
+// describeResult describes the result of evaluating a constraint against
+// both the host labels and the task labels, which must both hold.
+func describeResult(hostResult, taskResult EvaluateResult) string {
+	switch {
+	case hostResult == EvaluateResultMismatch ||
+		taskResult == EvaluateResultMismatch:
+		return "mismatched"
+	case hostResult == EvaluateResultMatch ||
+		taskResult == EvaluateResultMatch:
+		return "matched"
+	}
+	return "not applicable"
+}
+
+// describe returns a readable description of a constraint.
+func describe(constraint *task.Constraint) string {
+	switch constraint.GetType() {
+	case task.Constraint_AND_CONSTRAINT:
+		return describeAll(
+			constraint.GetAndConstraint().GetConstraints(), " and ")
+	case task.Constraint_OR_CONSTRAINT:
+		return describeAll(
+			constraint.GetOrConstraint().GetConstraints(), " or ")
+	case task.Constraint_LABEL_CONSTRAINT:
+		return describeLabelConstraint(constraint.GetLabelConstraint())
+	case task.Constraint_EXPRESSION_CONSTRAINT:
+		return "expression " +
+			constraint.GetExpressionConstraint().GetExpression()
+	}
+	return "unknown constraint"
+}
+
+func describeAll(constraints []*task.Constraint, sep string) string {
+	descriptions := make([]string, 0, len(constraints))
+	for _, c := range constraints {
+		descriptions = append(descriptions, describe(c))
+	}
+	return "(" + strings.Join(descriptions, sep) + ")"
+}
+
+// describeLabelConstraint describes a label constraint, e.g.
+// `host label zone=dca1 count > 0` or
+// `task label app=web count < 1`.
+func describeLabelConstraint(c *task.LabelConstraint) string {
+	var kind string
+	switch c.GetKind() {
+	case task.LabelConstraint_HOST:
+		kind = "host label"
+	case task.LabelConstraint_TASK:
+		kind = "task label"
+	default:
+		kind = "label"
+	}
+
+	var condition string
+	switch c.GetCondition() {
+	case task.LabelConstraint_CONDITION_LESS_THAN:
+		condition = "<"
+	case task.LabelConstraint_CONDITION_EQUAL:
+		condition = "=="
+	case task.LabelConstraint_CONDITION_GREATER_THAN:
+		condition = ">"
+	default:
+		condition = "?"
+	}
+
+	return fmt.Sprintf("%s %s=%s count %s %d",
+		kind,
+		c.GetLabel().GetKey(),
+		c.GetLabel().GetValue(),
+		condition,
+		c.GetRequirement())
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package constraints
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+)
+
+func labelConstraint(
+	kind task.LabelConstraint_Kind,
+	condition task.LabelConstraint_Condition,
+	key, value string,
+	requirement uint32) *task.Constraint {
+	return &task.Constraint{
+		Type: task.Constraint_LABEL_CONSTRAINT,
+		LabelConstraint: &task.LabelConstraint{
+			Kind:        kind,
+			Condition:   condition,
+			Label:       &peloton.Label{Key: key, Value: value},
+			Requirement: requirement,
+		},
+	}
+}
+
+// TestDescribe tests describing the constraints of a task
+func TestDescribe(t *testing.T) {
+	zone := labelConstraint(
+		task.LabelConstraint_HOST,
+		task.LabelConstraint_CONDITION_GREATER_THAN,
+		"zone", "dca1", 0)
+	spread := labelConstraint(
+		task.LabelConstraint_TASK,
+		task.LabelConstraint_CONDITION_LESS_THAN,
+		"app", "web", 1)
+	rack := labelConstraint(
+		task.LabelConstraint_HOST,
+		task.LabelConstraint_CONDITION_EQUAL,
+		_rackLabel, _testRack, 1)
+
+	assert.Nil(t, Describe(nil))
+	assert.Equal(t,
+		[]string{"host label zone=dca1 count > 0"},
+		Describe(zone))
+	assert.Equal(t,
+		[]string{
+			"host label zone=dca1 count > 0",
+			"(task label app=web count < 1 or host label rack=test-rack count == 1)",
+		},
+		Describe(&task.Constraint{
+			Type: task.Constraint_AND_CONSTRAINT,
+			AndConstraint: &task.AndConstraint{
+				Constraints: []*task.Constraint{
+					zone,
+					{
+						Type: task.Constraint_OR_CONSTRAINT,
+						OrConstraint: &task.OrConstraint{
+							Constraints: []*task.Constraint{spread, rack},
+						},
+					},
+				},
+			},
+		}))
+}
+
+// TestDescribeEvaluation tests describing the result of evaluating the
+// constraints of a task against a host
+func TestDescribeEvaluation(t *testing.T) {
+	zone := labelConstraint(
+		task.LabelConstraint_HOST,
+		task.LabelConstraint_CONDITION_GREATER_THAN,
+		"zone", "dca1", 0)
+	spread := labelConstraint(
+		task.LabelConstraint_TASK,
+		task.LabelConstraint_CONDITION_LESS_THAN,
+		"app", "web", 1)
+	rack := labelConstraint(
+		task.LabelConstraint_HOST,
+		task.LabelConstraint_CONDITION_EQUAL,
+		_rackLabel, _testRack, 1)
+	constraint := &task.Constraint{
+		Type: task.Constraint_AND_CONSTRAINT,
+		AndConstraint: &task.AndConstraint{
+			Constraints: []*task.Constraint{zone, spread, rack},
+		},
+	}
+	hostLabelValues := LabelValues{
+		"zone": {"dca1": 1},
+	}
+	taskLabelValues := LabelValues{
+		"app": {"web": 1},
+	}
+
+	assert.Nil(t, DescribeEvaluation(nil, hostLabelValues, taskLabelValues))
+	assert.Equal(t,
+		[]string{
+			"host label zone=dca1 count > 0: matched",
+			"task label app=web count < 1: mismatched",
+			"host label rack=test-rack count == 1: mismatched",
+		},
+		DescribeEvaluation(constraint, hostLabelValues, taskLabelValues))
+	assert.Equal(t,
+		[]string{
+			"host label zone=dca1 count > 0: mismatched",
+			"task label app=web count < 1: matched",
+			"host label rack=test-rack count == 1: mismatched",
+		},
+		DescribeEvaluation(constraint, LabelValues{}, LabelValues{}))
+
+	descriptions := DescribeEvaluation(&task.Constraint{
+		Type: task.Constraint_EXPRESSION_CONSTRAINT,
+		ExpressionConstraint: &task.ExpressionConstraint{
+			Expression: "(",
+		},
+	}, hostLabelValues, taskLabelValues)
+	assert.Len(t, descriptions, 1)
+	assert.Contains(t, descriptions[0], "expression (: evaluation failed: ")
+}
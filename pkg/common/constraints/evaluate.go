@@ -86,6 +86,9 @@ func (e evaluator) Evaluate(
 	case task.Constraint_LABEL_CONSTRAINT:
 		return e.evaluateLabelConstraint(
 			constraint.GetLabelConstraint(), labelValues)
+	case task.Constraint_EXPRESSION_CONSTRAINT:
+		return e.evaluateExpressionConstraint(
+			constraint.GetExpressionConstraint(), labelValues)
 	}
 
 	log.WithField("type", constraint.GetType()).
@@ -172,6 +175,66 @@ func (e evaluator) evaluateLabelConstraint(
 	return EvaluateResultMismatch, nil
 }
 
+// evaluateExpressionConstraint evaluates the expression against the host
+// labels for the HOST kind. The expressions reading the labels of the tasks
+// on the host are evaluated with the labels of the tasks unknown, and are
+// not applicable if their result depends on them. Expressions are not
+// applicable for the TASK kind because both the host and task labels are
+// needed to evaluate them.
+func (e evaluator) evaluateExpressionConstraint(
+	expressionConstraint *task.ExpressionConstraint,
+	labelValues LabelValues,
+) (EvaluateResult, error) {
+
+	expr, err := CompileExpression(expressionConstraint.GetExpression())
+	if err != nil {
+		return EvaluateResultNotApplicable, err
+	}
+
+	if task.LabelConstraint_Kind(e) != task.LabelConstraint_HOST {
+		return EvaluateResultNotApplicable, nil
+	}
+
+	match, known, err := expr.EvaluateHost(labelValues)
+	if err == nil && !known {
+		return EvaluateResultNotApplicable, nil
+	}
+	if err != nil {
+		// the expression cannot be evaluated on this host, e.g. a label
+		// compared with a number is not set on the host
+		log.WithError(err).Debug("failed to evaluate constraint expression")
+		return EvaluateResultMismatch, nil
+	}
+	if match {
+		return EvaluateResultMatch, nil
+	}
+	return EvaluateResultMismatch, nil
+}
+
+// Validate returns an error if one of the expressions of the constraint
+// is not valid.
+func Validate(constraint *task.Constraint) error {
+	switch constraint.GetType() {
+	case task.Constraint_AND_CONSTRAINT:
+		for _, c := range constraint.GetAndConstraint().GetConstraints() {
+			if err := Validate(c); err != nil {
+				return err
+			}
+		}
+	case task.Constraint_OR_CONSTRAINT:
+		for _, c := range constraint.GetOrConstraint().GetConstraints() {
+			if err := Validate(c); err != nil {
+				return err
+			}
+		}
+	case task.Constraint_EXPRESSION_CONSTRAINT:
+		_, err := CompileExpression(
+			constraint.GetExpressionConstraint().GetExpression())
+		return err
+	}
+	return nil
+}
+
 func valueCount(label *peloton.Label, labelValues LabelValues) uint32 {
 	return labelValues[label.GetKey()][label.GetValue()]
 }
@@ -0,0 +1,810 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package constraints
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// _maxCachedExpressions is the maximum number of compiled expressions
+	// kept in the cache before it is cleared.
+	_maxCachedExpressions = 1024
+
+	// _maxExpressionLength is the maximum length of an expression.
+	_maxExpressionLength = 4096
+
+	// _maxExpressionDepth is the maximum nesting depth of an expression,
+	// which bounds the recursion of the parser and of the evaluation.
+	_maxExpressionDepth = 32
+)
+
+// ExpressionContext provides the labels an expression is evaluated against.
+type ExpressionContext interface {
+	// HostLabelValues returns the values of the host label with the key.
+	HostLabelValues(key string) []string
+	// TaskLabelCount returns the number of tasks on the host with the
+	// label, or with the label key and any value if value is empty.
+	TaskLabelCount(key, value string) uint32
+}
+
+// Expression is a compiled constraint expression, see
+// task.ExpressionConstraint for the syntax of the expressions.
+type Expression struct {
+	text string
+	root exprNode
+	// whether the expression reads the labels of the tasks on the host
+	usesTaskLabels bool
+}
+
+var (
+	expressionCacheLock sync.RWMutex
+	expressionCache     = make(map[string]*Expression)
+)
+
+// CompileExpression parses the expression, returning an error if it is
+// not valid. Compiled expressions are cached, so it can be called for
+// every evaluation of a constraint.
+func CompileExpression(text string) (*Expression, error) {
+	expressionCacheLock.RLock()
+	expr, ok := expressionCache[text]
+	expressionCacheLock.RUnlock()
+	if ok {
+		return expr, nil
+	}
+
+	p := &exprParser{text: text}
+	if len(text) > _maxExpressionLength {
+		return nil, p.errorf("longer than %d characters", _maxExpressionLength)
+	}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, p.errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+
+	expr = &Expression{
+		text:           text,
+		root:           root,
+		usesTaskLabels: p.usesTaskLabels,
+	}
+
+	expressionCacheLock.Lock()
+	if len(expressionCache) >= _maxCachedExpressions {
+		expressionCache = make(map[string]*Expression)
+	}
+	expressionCache[text] = expr
+	expressionCacheLock.Unlock()
+	return expr, nil
+}
+
+// String returns the text of the expression.
+func (e *Expression) String() string {
+	return e.text
+}
+
+// UsesTaskLabels returns true if the expression reads the labels of the
+// tasks on the host, in which case it can only be evaluated where the
+// tasks running on the host are known.
+func (e *Expression) UsesTaskLabels() bool {
+	return e.usesTaskLabels
+}
+
+// Evaluate returns whether the expression is satisfied by the labels of
+// the context.
+func (e *Expression) Evaluate(ctx ExpressionContext) (bool, error) {
+	b, known, err := evalCondition(e.root, ctx)
+	if err != nil {
+		return false, fmt.Errorf("expression %q: %v", e.text, err)
+	}
+	if !known {
+		return false, fmt.Errorf("expression %q: result is unknown", e.text)
+	}
+	return b, nil
+}
+
+// EvaluateHost evaluates the expression against the labels of a host only,
+// the labels of the tasks on the host being unknown. The second result is
+// false if the result of the expression depends on the labels of the tasks,
+// e.g. `host("zone") == "dca1" && tasks("app") < 1` on a host in zone dca1.
+func (e *Expression) EvaluateHost(host LabelValues) (bool, bool, error) {
+	b, known, err := evalCondition(e.root, hostOnlyContext{
+		labelValuesContext: labelValuesContext{host: host},
+	})
+	if err != nil {
+		return false, false, fmt.Errorf("expression %q: %v", e.text, err)
+	}
+	return b, known, nil
+}
+
+// labelValuesContext is an ExpressionContext for the label values of
+// a host and of the tasks on the host.
+type labelValuesContext struct {
+	host  LabelValues
+	tasks LabelValues
+}
+
+// NewExpressionContext returns an ExpressionContext for the label values
+// of a host and of the tasks on the host, either may be nil.
+func NewExpressionContext(host, tasks LabelValues) ExpressionContext {
+	return labelValuesContext{host: host, tasks: tasks}
+}
+
+func (c labelValuesContext) HostLabelValues(key string) []string {
+	var values []string
+	for value, count := range c.host[key] {
+		if count > 0 {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// hostOnlyContext is an ExpressionContext for the label values of a host,
+// in which the functions reading the labels of the tasks return unknown.
+type hostOnlyContext struct {
+	labelValuesContext
+}
+
+// unknownValue is the value of the calls reading the labels of the tasks
+// when they are not known, and of the nodes depending on them.
+type unknownValue struct{}
+
+var _unknown = unknownValue{}
+
+func isUnknown(v interface{}) bool {
+	_, ok := v.(unknownValue)
+	return ok
+}
+
+func (c labelValuesContext) TaskLabelCount(key, value string) uint32 {
+	if len(value) > 0 {
+		return c.tasks[key][value]
+	}
+	var count uint32
+	for _, n := range c.tasks[key] {
+		count += n
+	}
+	return count
+}
+
+// exprNode is a node of the syntax tree of an expression. Values are
+// either a string, a float64, a bool or a list of values.
+type exprNode interface {
+	eval(ctx ExpressionContext) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n literalNode) eval(ctx ExpressionContext) (interface{}, error) {
+	return n.value, nil
+}
+
+type listNode struct {
+	items []exprNode
+}
+
+func (n listNode) eval(ctx ExpressionContext) (interface{}, error) {
+	values := make([]interface{}, 0, len(n.items))
+	for _, item := range n.items {
+		v, err := item.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if isUnknown(v) {
+			return _unknown, nil
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+type notNode struct {
+	operand exprNode
+}
+
+func (n notNode) eval(ctx ExpressionContext) (interface{}, error) {
+	b, known, err := evalCondition(n.operand, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !known {
+		return _unknown, nil
+	}
+	return !b, nil
+}
+
+// logicalNode is a short-circuit && or || of two operands. An unknown
+// operand makes the result unknown, unless the other operand alone
+// determines it.
+type logicalNode struct {
+	and         bool
+	left, right exprNode
+}
+
+func (n logicalNode) eval(ctx ExpressionContext) (interface{}, error) {
+	left, leftKnown, err := evalCondition(n.left, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if leftKnown && left != n.and {
+		return left, nil
+	}
+	right, rightKnown, err := evalCondition(n.right, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rightKnown && (leftKnown || right != n.and) {
+		return right, nil
+	}
+	return _unknown, nil
+}
+
+type compareNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n compareNode) eval(ctx ExpressionContext) (interface{}, error) {
+	left, err := n.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if isUnknown(left) || isUnknown(right) {
+		return _unknown, nil
+	}
+
+	if n.op == "in" {
+		list, ok := right.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("right operand of in is not a list")
+		}
+		for _, item := range list {
+			c, err := compareValues(left, item)
+			if err != nil {
+				return nil, err
+			}
+			if c == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	c, err := compareValues(left, right)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	}
+	if _, ok := left.(bool); ok {
+		return nil, fmt.Errorf("booleans cannot be compared with %s", n.op)
+	}
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+type callNode struct {
+	fn   exprFunc
+	args []exprNode
+}
+
+func (n callNode) eval(ctx ExpressionContext) (interface{}, error) {
+	args := make([]string, 0, len(n.args))
+	for _, arg := range n.args {
+		v, err := arg.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if isUnknown(v) {
+			return _unknown, nil
+		}
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf(
+				"argument %v of %s is not a string", v, n.fn.name)
+		}
+		args = append(args, s)
+	}
+	if _, ok := ctx.(hostOnlyContext); ok && n.fn.usesTaskLabels {
+		return _unknown, nil
+	}
+	return n.fn.call(ctx, args)
+}
+
+// exprFunc is a function which can be called in expressions, all the
+// arguments of the functions are strings.
+type exprFunc struct {
+	name    string
+	minArgs int
+	maxArgs int
+	// whether the function reads the labels of the tasks on the host
+	usesTaskLabels bool
+	call           func(ctx ExpressionContext, args []string) (interface{}, error)
+}
+
+var _exprFuncs = map[string]exprFunc{
+	"host": {
+		minArgs: 1,
+		maxArgs: 1,
+		call: func(ctx ExpressionContext, args []string) (interface{}, error) {
+			values := ctx.HostLabelValues(args[0])
+			if len(values) == 0 {
+				return "", nil
+			}
+			sort.Strings(values)
+			return values[0], nil
+		},
+	},
+	"hostHas": {
+		minArgs: 2,
+		maxArgs: 2,
+		call: func(ctx ExpressionContext, args []string) (interface{}, error) {
+			for _, value := range ctx.HostLabelValues(args[0]) {
+				if value == args[1] {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+	},
+	"tasks": {
+		minArgs:        1,
+		maxArgs:        2,
+		usesTaskLabels: true,
+		call: func(ctx ExpressionContext, args []string) (interface{}, error) {
+			var value string
+			if len(args) > 1 {
+				value = args[1]
+			}
+			return float64(ctx.TaskLabelCount(args[0], value)), nil
+		},
+	},
+	"startsWith": {
+		minArgs: 2,
+		maxArgs: 2,
+		call: func(ctx ExpressionContext, args []string) (interface{}, error) {
+			return strings.HasPrefix(args[0], args[1]), nil
+		},
+	},
+	"endsWith": {
+		minArgs: 2,
+		maxArgs: 2,
+		call: func(ctx ExpressionContext, args []string) (interface{}, error) {
+			return strings.HasSuffix(args[0], args[1]), nil
+		},
+	},
+	"contains": {
+		minArgs: 2,
+		maxArgs: 2,
+		call: func(ctx ExpressionContext, args []string) (interface{}, error) {
+			return strings.Contains(args[0], args[1]), nil
+		},
+	},
+	"number": {
+		minArgs: 1,
+		maxArgs: 1,
+		call: func(ctx ExpressionContext, args []string) (interface{}, error) {
+			return toNumber(args[0])
+		},
+	},
+}
+
+// evalCondition evaluates a node to a boolean, the second result is false
+// if the value of the node is unknown.
+func evalCondition(n exprNode, ctx ExpressionContext) (bool, bool, error) {
+	v, err := n.eval(ctx)
+	if err != nil {
+		return false, false, err
+	}
+	if isUnknown(v) {
+		return false, false, nil
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, false, fmt.Errorf("%v is not a boolean", v)
+	}
+	return b, true, nil
+}
+
+func toNumber(v interface{}) (float64, error) {
+	switch value := v.(type) {
+	case float64:
+		return value, nil
+	case string:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", value)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("%v is not a number", v)
+}
+
+// compareValues returns -1, 0 or 1 if the left value is respectively
+// less than, equal to or greater than the right value. A string compared
+// with a number is converted to a number.
+func compareValues(left, right interface{}) (int, error) {
+	_, leftNumber := left.(float64)
+	_, rightNumber := right.(float64)
+	if leftNumber || rightNumber {
+		l, err := toNumber(left)
+		if err != nil {
+			return 0, err
+		}
+		r, err := toNumber(right)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case l < r:
+			return -1, nil
+		case l > r:
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	switch l := left.(type) {
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return 0, fmt.Errorf("cannot compare %q with %v", l, right)
+		}
+		return strings.Compare(l, r), nil
+	case bool:
+		r, ok := right.(bool)
+		if !ok {
+			return 0, fmt.Errorf("cannot compare %v with %v", l, right)
+		}
+		if l == r {
+			return 0, nil
+		}
+		return 1, nil
+	}
+	return 0, fmt.Errorf("cannot compare %v with %v", left, right)
+}
+
+type tokenKind int
+
+const (
+	tokenOperator tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+)
+
+type exprToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// exprParser is a recursive descent parser of expressions:
+//
+//	or      := and { "||" and }
+//	and     := not { "&&" not }
+//	not     := "!" not | compare
+//	compare := primary [ ("=="|"!="|"<"|"<="|">"|">="|"in") primary ]
+//	primary := number | string | "true" | "false" | "(" or ")" |
+//	           "[" [ primary { "," primary } ] "]" |
+//	           ident "(" [ or { "," or } ] ")"
+type exprParser struct {
+	text   string
+	tokens []exprToken
+	pos    int
+	// current nesting depth of the parsed expression
+	depth int
+	// whether a function reading the labels of the tasks is called
+	usesTaskLabels bool
+}
+
+var _exprOperators = []string{
+	"||", "&&", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ",", "-",
+}
+
+// enter increases the nesting depth of the parser, returning an error if
+// the expression is nested too deeply. leave is called once the nested
+// expression is parsed.
+func (p *exprParser) enter() error {
+	p.depth++
+	if p.depth > _maxExpressionDepth {
+		return p.errorf("nested deeper than %d", _maxExpressionDepth)
+	}
+	return nil
+}
+
+func (p *exprParser) leave() {
+	p.depth--
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid expression %q: %s",
+		p.text, fmt.Sprintf(format, args...))
+}
+
+func (p *exprParser) tokenize() error {
+	text := p.text
+	i := 0
+OUTER:
+	for i < len(text) {
+		c := rune(text[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			var sb strings.Builder
+			for j := i + 1; j < len(text); j++ {
+				switch text[j] {
+				case byte(c):
+					p.tokens = append(p.tokens,
+						exprToken{kind: tokenString, text: sb.String(), pos: i})
+					i = j + 1
+					continue OUTER
+				case '\\':
+					if j+1 < len(text) {
+						j++
+					}
+				}
+				sb.WriteByte(text[j])
+			}
+			return p.errorf("unterminated string at %d", i)
+		case unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(text) && (unicode.IsDigit(rune(text[j])) || text[j] == '.') {
+				j++
+			}
+			p.tokens = append(p.tokens,
+				exprToken{kind: tokenNumber, text: text[i:j], pos: i})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(text) && (unicode.IsLetter(rune(text[j])) ||
+				unicode.IsDigit(rune(text[j])) || text[j] == '_') {
+				j++
+			}
+			p.tokens = append(p.tokens,
+				exprToken{kind: tokenIdent, text: text[i:j], pos: i})
+			i = j
+		default:
+			for _, op := range _exprOperators {
+				if strings.HasPrefix(text[i:], op) {
+					p.tokens = append(p.tokens,
+						exprToken{kind: tokenOperator, text: op, pos: i})
+					i += len(op)
+					continue OUTER
+				}
+			}
+			return p.errorf("unexpected character %q at %d", c, i)
+		}
+	}
+	return nil
+}
+
+// peek returns true if the next token is the given operator or keyword.
+func (p *exprParser) peek(text string) bool {
+	if p.pos >= len(p.tokens) {
+		return false
+	}
+	t := p.tokens[p.pos]
+	return t.text == text && (t.kind == tokenOperator || t.kind == tokenIdent)
+}
+
+// accept consumes the next token if it is the given operator or keyword.
+func (p *exprParser) accept(text string) bool {
+	if !p.peek(text) {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+func (p *exprParser) expect(text string) error {
+	if p.accept(text) {
+		return nil
+	}
+	if p.pos >= len(p.tokens) {
+		return p.errorf("expected %q at end of expression", text)
+	}
+	t := p.tokens[p.pos]
+	return p.errorf("expected %q at %d, got %q", text, t.pos, t.text)
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.accept("!") {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">", "in"} {
+		if p.accept(op) {
+			right, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			return compareNode{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	if p.pos >= len(p.tokens) {
+		return nil, p.errorf("unexpected end of expression")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+
+	switch t.kind {
+	case tokenString:
+		return literalNode{value: t.text}, nil
+	case tokenNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q at %d", t.text, t.pos)
+		}
+		return literalNode{value: f}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		}
+		return p.parseCall(t)
+	}
+
+	switch t.text {
+	case "-":
+		if p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenNumber {
+			n, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			return literalNode{value: -n.(literalNode).value.(float64)}, nil
+		}
+	case "(":
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(")")
+	case "[":
+		var items []exprNode
+		for !p.accept("]") {
+			if len(items) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			item, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return listNode{items: items}, nil
+	}
+	return nil, p.errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	fn, ok := _exprFuncs[name.text]
+	if !ok {
+		return nil, p.errorf("unknown function %q at %d", name.text, name.pos)
+	}
+	fn.name = name.text
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	var args []exprNode
+	for !p.accept(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if len(args) < fn.minArgs || len(args) > fn.maxArgs {
+		return nil, p.errorf("wrong number of arguments for %s at %d",
+			name.text, name.pos)
+	}
+
+	p.usesTaskLabels = p.usesTaskLabels || fn.usesTaskLabels
+	return callNode{fn: fn, args: args}, nil
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package constraints
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+)
+
+type ExpressionTestSuite struct {
+	suite.Suite
+
+	ctx ExpressionContext
+}
+
+func TestExpressionTestSuite(t *testing.T) {
+	suite.Run(t, new(ExpressionTestSuite))
+}
+
+func (suite *ExpressionTestSuite) SetupTest() {
+	suite.ctx = NewExpressionContext(
+		LabelValues{
+			HostNameKey: {_testHost1: 1},
+			_rackLabel:  {_testRack: 1},
+			"zone":      {"dca1": 1},
+			"cores":     {"32.000000": 1},
+			"pools":     {"shared": 1, "batch": 1},
+		},
+		LabelValues{
+			"app": {"web": 2, "db": 1},
+		},
+	)
+}
+
+func expressionConstraint(expression string) *task.Constraint {
+	return &task.Constraint{
+		Type: task.Constraint_EXPRESSION_CONSTRAINT,
+		ExpressionConstraint: &task.ExpressionConstraint{
+			Expression: expression,
+		},
+	}
+}
+
+// TestEvaluate tests evaluating valid expressions.
+func (suite *ExpressionTestSuite) TestEvaluate() {
+	tt := []struct {
+		expression string
+		expected   bool
+	}{
+		{`host("zone") == "dca1"`, true},
+		{`host('zone') != "dca1"`, false},
+		{`host("hostname") == "test-host1" && host("rack") == "test-rack"`, true},
+		{`host("zone") in ["phx2", "dca1"]`, true},
+		{`host("zone") in []`, false},
+		{`host("cores") >= 16`, true},
+		{`host("cores") < 16.5`, false},
+		{`number(host("cores")) == 32`, true},
+		{`host("missing") == ""`, true},
+		{`host("pools") == "batch"`, true},
+		{`hostHas("pools", "shared") && hostHas("pools", "batch")`, true},
+		{`hostHas("pools", "gpu")`, false},
+		{`tasks("app", "web") < 2`, false},
+		{`tasks("app", "web") == 2 && tasks("app") == 3`, true},
+		{`tasks("app", "cache") == 0`, true},
+		{`!(tasks("app", "db") > 0) || startsWith(host("hostname"), "test-")`, true},
+		{`endsWith(host("hostname"), "host1") && contains(host("rack"), "rack")`, true},
+		{`host("zone") == "phx2" || host("zone") == "dca1" && true`, true},
+		{`!true == false`, true},
+		{`-1 < 0`, true},
+		{`"a\"b" == 'a"b'`, true},
+	}
+
+	for _, test := range tt {
+		expr, err := CompileExpression(test.expression)
+		suite.NoError(err, test.expression)
+		match, err := expr.Evaluate(suite.ctx)
+		suite.NoError(err, test.expression)
+		suite.Equal(test.expected, match, test.expression)
+	}
+}
+
+// TestEvaluateError tests evaluating expressions which cannot be
+// evaluated against the labels.
+func (suite *ExpressionTestSuite) TestEvaluateError() {
+	for _, expression := range []string{
+		`host("zone") > 1`,
+		`host("zone")`,
+		`tasks("app") && true`,
+		`host("zone") in "dca1"`,
+		`true < false`,
+		`startsWith(1, "a")`,
+	} {
+		expr, err := CompileExpression(expression)
+		suite.NoError(err, expression)
+		_, err = expr.Evaluate(suite.ctx)
+		suite.Error(err, expression)
+	}
+}
+
+// TestCompileError tests compiling invalid expressions.
+func (suite *ExpressionTestSuite) TestCompileError() {
+	for _, expression := range []string{
+		``,
+		`host("zone") ==`,
+		`host("zone"`,
+		`host("zone) == "dca1"`,
+		`unknown("zone")`,
+		`host()`,
+		`tasks("a", "b", "c")`,
+		`host("zone") == "dca1" "dca1"`,
+		`host("zone") = "dca1"`,
+		`[1, 2`,
+		`1.2.3 > 0`,
+		strings.Repeat("(", 40) + "true" + strings.Repeat(")", 40),
+		strings.Repeat("!", 40) + "true",
+		strings.Repeat("[", 40) + strings.Repeat("]", 40),
+		strings.Repeat(`host("zone") == "dca1" && `, 200) + "true",
+	} {
+		_, err := CompileExpression(expression)
+		suite.Error(err, expression)
+	}
+}
+
+// TestEvaluateHost tests evaluating expressions against the labels of a
+// host only.
+func (suite *ExpressionTestSuite) TestEvaluateHost() {
+	host := LabelValues{"zone": {"dca1": 1}}
+	tt := []struct {
+		expression string
+		expected   bool
+		known      bool
+	}{
+		{`host("zone") == "dca1"`, true, true},
+		{`host("zone") == "phx2" && tasks("app") < 1`, false, true},
+		{`tasks("app") < 1 && host("zone") == "phx2"`, false, true},
+		{`host("zone") == "dca1" || tasks("app") < 1`, true, true},
+		{`tasks("app") < 1 || host("zone") == "dca1"`, true, true},
+		{`host("zone") == "dca1" && tasks("app") < 1`, false, false},
+		{`host("zone") == "phx2" || tasks("app") < 1`, false, false},
+		{`!(tasks("app") < 1)`, false, false},
+		{`tasks("app") in [0, 1]`, false, false},
+	}
+
+	for _, test := range tt {
+		expr, err := CompileExpression(test.expression)
+		suite.NoError(err, test.expression)
+		match, known, err := expr.EvaluateHost(host)
+		suite.NoError(err, test.expression)
+		suite.Equal(test.known, known, test.expression)
+		suite.Equal(test.expected, match, test.expression)
+	}
+}
+
+// TestUsesTaskLabels tests detecting the expressions reading the labels
+// of the tasks on the host.
+func (suite *ExpressionTestSuite) TestUsesTaskLabels() {
+	expr, err := CompileExpression(`host("zone") == "dca1"`)
+	suite.NoError(err)
+	suite.False(expr.UsesTaskLabels())
+
+	expr, err = CompileExpression(`host("zone") == "dca1" && tasks("app") < 1`)
+	suite.NoError(err)
+	suite.True(expr.UsesTaskLabels())
+}
+
+// TestEvaluator tests evaluating expression constraints with the
+// evaluators of both kinds.
+func (suite *ExpressionTestSuite) TestEvaluator() {
+	hostEvaluator := NewEvaluator(task.LabelConstraint_HOST)
+	taskEvaluator := NewEvaluator(task.LabelConstraint_TASK)
+	lv := LabelValues{"zone": {"dca1": 1}}
+
+	result, err := hostEvaluator.Evaluate(
+		expressionConstraint(`host("zone") == "dca1"`), lv)
+	suite.NoError(err)
+	suite.Equal(EvaluateResultMatch, result)
+
+	result, err = hostEvaluator.Evaluate(
+		expressionConstraint(`host("zone") == "phx2"`), lv)
+	suite.NoError(err)
+	suite.Equal(EvaluateResultMismatch, result)
+
+	// an expression which cannot be evaluated on the host is a mismatch
+	result, err = hostEvaluator.Evaluate(
+		expressionConstraint(`host("cores") > 16`), lv)
+	suite.NoError(err)
+	suite.Equal(EvaluateResultMismatch, result)
+
+	// the labels of the tasks are not known by the evaluators
+	result, err = hostEvaluator.Evaluate(
+		expressionConstraint(`tasks("app", "web") < 1`), lv)
+	suite.NoError(err)
+	suite.Equal(EvaluateResultNotApplicable, result)
+
+	// but the host labels are still evaluated
+	result, err = hostEvaluator.Evaluate(
+		expressionConstraint(`host("zone") == "phx2" && tasks("app") < 1`), lv)
+	suite.NoError(err)
+	suite.Equal(EvaluateResultMismatch, result)
+
+	result, err = hostEvaluator.Evaluate(
+		expressionConstraint(`host("zone") == "dca1" && tasks("app") < 1`), lv)
+	suite.NoError(err)
+	suite.Equal(EvaluateResultNotApplicable, result)
+
+	result, err = taskEvaluator.Evaluate(
+		expressionConstraint(`host("zone") == "dca1"`), lv)
+	suite.NoError(err)
+	suite.Equal(EvaluateResultNotApplicable, result)
+
+	_, err = hostEvaluator.Evaluate(expressionConstraint(`host(`), lv)
+	suite.Error(err)
+}
+
+// TestValidate tests validating the expressions of nested constraints.
+func (suite *ExpressionTestSuite) TestValidate() {
+	suite.NoError(Validate(nil))
+	suite.NoError(Validate(&task.Constraint{
+		Type: task.Constraint_OR_CONSTRAINT,
+		OrConstraint: &task.OrConstraint{
+			Constraints: []*task.Constraint{
+				expressionConstraint(`host("zone") == "dca1"`),
+			},
+		},
+	}))
+	suite.Error(Validate(&task.Constraint{
+		Type: task.Constraint_AND_CONSTRAINT,
+		AndConstraint: &task.AndConstraint{
+			Constraints: []*task.Constraint{
+				expressionConstraint(`host("zone") == "dca1"`),
+				expressionConstraint(`host("zone") ==`),
+			},
+		},
+	}))
+}
@@ -80,3 +80,19 @@ OUTER:
 	}
 	return result
 }
+
+// GetTaskLabelValues returns label counts for the labels of the tasks on a
+// host, which can be used to evaluate a constraint on the tasks on the host.
+func GetTaskLabelValues(labels ...*mesos.Labels) LabelValues {
+	result := make(map[string]map[string]uint32)
+	for _, taskLabels := range labels {
+		for _, label := range taskLabels.GetLabels() {
+			key := label.GetKey()
+			if _, ok := result[key]; !ok {
+				result[key] = make(map[string]uint32)
+			}
+			result[key][label.GetValue()]++
+		}
+	}
+	return result
+}
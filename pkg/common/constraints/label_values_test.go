@@ -107,6 +107,21 @@ func (suite *LabelValuesTestSuite) TestGetHostLabelValues() {
 	suite.Equal(map[string]uint32{"1.000000": 1}, res[scalarName])
 }
 
+func (suite *LabelValuesTestSuite) TestGetTaskLabelValues() {
+	key := "app"
+	web := "web"
+	db := "db"
+	res := GetTaskLabelValues(
+		&mesos.Labels{Labels: []*mesos.Label{{Key: &key, Value: &web}}},
+		&mesos.Labels{Labels: []*mesos.Label{{Key: &key, Value: &web}}},
+		&mesos.Labels{Labels: []*mesos.Label{{Key: &key, Value: &db}}},
+		nil,
+	)
+	suite.Equal(LabelValues{
+		key: {web: 2, db: 1},
+	}, res)
+}
+
 func TestLabelValuesTestSuite(t *testing.T) {
 	suite.Run(t, new(LabelValuesTestSuite))
 }
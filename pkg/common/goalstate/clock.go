@@ -0,0 +1,85 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goalstate
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time used by the goal state engine to compute
+// deadlines and record when actions run. Production engines use the
+// wall clock, while replay harnesses use a ManualClock so that the same
+// sequence of events always produces the same sequence of actions.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// realClock implements Clock using the wall clock.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewRealClock returns a Clock backed by the wall clock.
+func NewRealClock() Clock {
+	return realClock{}
+}
+
+// ManualClock is a Clock which only moves when it is explicitly set or
+// advanced. It never moves backwards.
+type ManualClock struct {
+	sync.RWMutex // the mutex to synchronize access to this object
+
+	now time.Time
+}
+
+// NewManualClock returns a ManualClock starting at the provided time.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *ManualClock) Now() time.Time {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.now
+}
+
+// Set moves the clock to the provided time. It is a no-op if the
+// provided time is before the current time of the clock.
+func (c *ManualClock) Set(now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+
+	if now.After(c.now) {
+		c.now = now
+	}
+}
+
+// Advance moves the clock forward by the provided duration.
+func (c *ManualClock) Advance(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.now = c.now.Add(d)
+}
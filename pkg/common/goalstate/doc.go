@@ -24,5 +24,9 @@ an entity with a deadline of when it is should be dequeued for evaluation.
 When its deadline expires, the action list corresponding to its state and
 goal state are executed in order. If any of the actions return an error on
 execution, the entity is requeued for evaluation with an exponential backoff.
+Deadlines are computed from an injectable Clock, and every enqueue and
+executed action can be recorded in a Journal. The ReplayEngine evaluates
+entities synchronously against a ManualClock, so that a recorded sequence
+of events always results in the same sequence of actions.
 */
 package goalstate
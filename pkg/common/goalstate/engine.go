@@ -16,6 +16,7 @@ package goalstate
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
@@ -74,9 +75,19 @@ type Engine interface {
 	// Enqueue creates state in the goal state engine which will persist
 	// till the caller calls an explicit delete to clean up this state.
 	Enqueue(entity Entity, deadline time.Time)
+	// EnqueueWithPriority is used to enqueue an entity into the given
+	// priority lane of the goal state engine, so that the entities
+	// enqueued by users are evaluated ahead of background work. The
+	// entity is enqueued in the default lane if the engine has no
+	// workers for the priority.
+	EnqueueWithPriority(entity Entity, deadline time.Time, priority Priority)
 	// IsScheduled is used to determine if a given entity is queued in
 	// the deadline queue for evaluation
 	IsScheduled(entity Entity) bool
+	// Entities returns the state of all the entities tracked by the goal
+	// state engine, sorted by identifier, to debug entities which do not
+	// converge to their goal state.
+	Entities() []EntityInfo
 	// Delete is used clean up the state created in the goal state
 	// engine for the entity. It is the caller's responsibility to
 	// explicitly call delete when an entity is being removed from the system.
@@ -86,18 +97,60 @@ type Engine interface {
 	Stop()
 }
 
+// EngineOption is used to customize the goal state engine on creation.
+type EngineOption func(*engine)
+
+// WithClock sets the clock used by the goal state engine to compute
+// deadlines. Defaults to the wall clock.
+func WithClock(clock Clock) EngineOption {
+	return func(e *engine) {
+		e.clock = clock
+	}
+}
+
+// WithJournal sets the journal in which the goal state engine records
+// each enqueue and each executed action. Defaults to no journal.
+func WithJournal(journal Journal) EngineOption {
+	return func(e *engine) {
+		e.journal = journal
+	}
+}
+
+// WithRetryPolicy sets the policy computing the delay before evaluating
+// again an entity whose action failed. Defaults to a linear backoff by
+// the failure retry delay, capped at the max retry delay.
+func WithRetryPolicy(policy RetryPolicy) EngineOption {
+	return func(e *engine) {
+		e.retryPolicy = policy
+	}
+}
+
+// WithHighPriorityWorkers sets the number of workers evaluating the
+// entities enqueued with PriorityHigh, separately from the workers of
+// the default lane. Defaults to no high priority lane.
+func WithHighPriorityWorkers(numWorkerThreads int) EngineOption {
+	return func(e *engine) {
+		e.numHighPriorityWorkers = numWorkerThreads
+	}
+}
+
+// WithSlowActionThreshold sets the duration above which an action is
+// logged as slow, along with the entity it ran for. Defaults to no
+// slow action logs.
+func WithSlowActionThreshold(threshold time.Duration) EngineOption {
+	return func(e *engine) {
+		e.slowActionThreshold = threshold
+	}
+}
+
 // NewEngine returns a new goal state engine object.
 func NewEngine(
 	numWorkerThreads int,
 	failureRetryDelay time.Duration,
 	maxRetryDelay time.Duration,
-	parentScope tally.Scope) Engine {
-	e := &engine{
-		entityMap:         make(map[string]*entityMapItem),
-		failureRetryDelay: failureRetryDelay,
-		maxRetryDelay:     maxRetryDelay,
-		mtx:               NewMetrics(parentScope),
-	}
+	parentScope tally.Scope,
+	opts ...EngineOption) Engine {
+	e := newEngine(failureRetryDelay, maxRetryDelay, parentScope, opts...)
 
 	asyncQueue := &asyncWorkerQueue{
 		queue:  queue.NewDeadlineQueue(queue.NewQueueMetrics(parentScope)),
@@ -110,6 +163,39 @@ func NewEngine(
 	)
 	e.pool = pool
 
+	if e.numHighPriorityWorkers > 0 {
+		highPriorityScope := parentScope.SubScope("high_priority")
+		highPriorityQueue := &asyncWorkerQueue{
+			queue:  queue.NewDeadlineQueue(queue.NewQueueMetrics(highPriorityScope)),
+			engine: e,
+		}
+		e.highPriorityPool = async.NewPool(
+			async.PoolOptions{MaxWorkers: e.numHighPriorityWorkers},
+			highPriorityQueue,
+		)
+	}
+
+	return e
+}
+
+// newEngine returns an engine object without a worker pool.
+func newEngine(
+	failureRetryDelay time.Duration,
+	maxRetryDelay time.Duration,
+	parentScope tally.Scope,
+	opts ...EngineOption) *engine {
+	e := &engine{
+		entityMap:         make(map[string]*entityMapItem),
+		failureRetryDelay: failureRetryDelay,
+		maxRetryDelay:     maxRetryDelay,
+		clock:             NewRealClock(),
+		mtx:               NewMetrics(parentScope),
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
 	return e
 }
 
@@ -119,9 +205,61 @@ type entityMapItem struct {
 
 	entity    Entity      // the entity object
 	queueItem *queue.Item // the correspoing queue item in the deadline queue
+	// highPriorityQueueItem is the queue item of the entity in the deadline
+	// queue of the high priority lane, so that the entity can be scheduled
+	// in both lanes at the same time.
+	highPriorityQueueItem *queue.Item
 	// delay is used by goal state to track expoenential backoff of scheduling
 	// duration in case entity actions keep returning an error.
 	delay time.Duration
+	// retries is the number of consecutive evaluations of the entity
+	// whose actions returned an error.
+	retries uint32
+
+	// status of the last evaluations of the entity, which has its own
+	// lock so that it can be read while the entity actions are running.
+	status entityStatus
+}
+
+// entityStatus stores the outcome of the last evaluations of an entity.
+type entityStatus struct {
+	sync.RWMutex // the mutex to synchronize access to this object
+
+	running    bool          // whether the entity actions are running
+	delay      time.Duration // backoff delay after the last evaluation
+	retries    uint32        // number of consecutive evaluations which failed
+	lastAction string        // name of the last action which failed
+	lastError  string        // error returned by the last action which failed
+}
+
+// EntityInfo describes the state of an entity in the goal state engine.
+type EntityInfo struct {
+	// ID is the identifier of the entity.
+	ID string
+	// Scheduled is true if the entity is in the deadline queue.
+	Scheduled bool
+	// Deadline is the time at which the entity will be evaluated,
+	// zero if the entity is not scheduled.
+	Deadline time.Time
+	// Delay is the backoff delay applied after the last evaluation.
+	Delay time.Duration
+	// Retries is the number of consecutive evaluations which failed.
+	Retries uint32
+	// Running is true if the entity actions are being executed.
+	Running bool
+	// LastAction is the name of the last action which failed.
+	LastAction string
+	// LastError is the error returned by the last action which failed.
+	LastError string
+}
+
+// workerPool is the pool of workers evaluating entities after their
+// deadline expires. It is implemented by async.Pool, and by the replay
+// queue when entities are evaluated synchronously.
+type workerPool interface {
+	Start()
+	Stop()
+	Enqueue(job async.Job)
 }
 
 // engine implements the goal state engine interface
@@ -131,23 +269,54 @@ type engine struct {
 	entityMap map[string]*entityMapItem // map to store the entity items
 	stopChan  chan struct{}             // channel to indicate to deadline queue to stop processing
 
-	pool *async.Pool // worker pool to process queue items after dequeue
+	pool workerPool // worker pool to process queue items after dequeue
+	// highPriorityPool is the worker pool processing the entities enqueued
+	// with PriorityHigh, nil if the engine has no high priority lane.
+	highPriorityPool workerPool
+	// number of workers of the high priority lane
+	numHighPriorityWorkers int
 
 	// Global configuration for the delay for each retry on error.
 	failureRetryDelay time.Duration
 	// Global configuration for the absolute maximum duration between
 	// retries. Exponential backoff will be capped at this value.
 	maxRetryDelay time.Duration
+	// Policy of the delay before retrying the failed entities, the linear
+	// backoff configured by failureRetryDelay and maxRetryDelay if nil.
+	retryPolicy RetryPolicy
+	// Duration above which an action is logged as slow, no action is
+	// logged if zero.
+	slowActionThreshold time.Duration
+
+	clock   Clock   // clock used to compute deadlines, wall clock if nil
+	journal Journal // journal to record decisions into, if not nil
 
 	mtx *Metrics // goal state engine metrics
 }
 
+// now returns the current time of the engine clock.
+func (e *engine) now() time.Time {
+	if e.clock == nil {
+		return time.Now()
+	}
+	return e.clock.Now()
+}
+
+// record adds an entry to the engine journal, if one is configured.
+func (e *engine) record(entry JournalEntry) {
+	if e.journal == nil {
+		return
+	}
+	entry.Time = e.now()
+	e.journal.Record(entry)
+}
+
 // addItemToEntityMap stores an entity object in the entity map.
 // When a new enqueue request comes in, instead of doing a get and add
 // without a lock, this API should be used so that both get and add is
 // done while holding the lock. This ensures that concurrent enqueue
 // requests for the same entity get synchronized correctly.
-func (e *engine) addItemToEntityMap(id string, entity Entity) *queue.Item {
+func (e *engine) addItemToEntityMap(id string, entity Entity) *entityMapItem {
 	e.Lock()
 	defer e.Unlock()
 
@@ -155,17 +324,17 @@ func (e *engine) addItemToEntityMap(id string, entity Entity) *queue.Item {
 
 	entityItem, ok := e.entityMap[id]
 	if !ok {
-		queueItem := queue.NewItem(id)
 		entityItem = &entityMapItem{
-			entity:    entity,
-			queueItem: queueItem,
+			entity:                entity,
+			queueItem:             queue.NewItem(id),
+			highPriorityQueueItem: queue.NewItem(id),
 		}
 		e.entityMap[id] = entityItem
 		// Only update for adds for now. This is to prevent having to compute
 		// the length on every delete as well.
 		e.mtx.totalItems.Update(float64(len(e.entityMap)))
 	}
-	return entityItem.queueItem
+	return entityItem
 }
 
 // getItemFromEntityMap fetches an entity object from the entity map.
@@ -205,12 +374,33 @@ func (e *engine) getMaxRetryDelay() time.Duration {
 }
 
 func (e *engine) Enqueue(entity Entity, deadline time.Time) {
+	e.EnqueueWithPriority(entity, deadline, PriorityDefault)
+}
+
+func (e *engine) EnqueueWithPriority(
+	entity Entity,
+	deadline time.Time,
+	priority Priority) {
 	id := entity.GetID()
+	entityItem := e.addItemToEntityMap(id, entity)
+
+	pool := e.pool
 	asyncQueueItem := &asyncWorkerQueueItem{
-		item:     e.addItemToEntityMap(id, entity),
+		item:     entityItem.queueItem,
 		deadline: deadline,
 	}
-	e.pool.Enqueue(asyncQueueItem)
+	if priority == PriorityHigh && e.highPriorityPool != nil {
+		pool = e.highPriorityPool
+		asyncQueueItem.item = entityItem.highPriorityQueueItem
+		e.mtx.highPriorityEnqueues.Inc(1)
+	}
+
+	e.record(JournalEntry{
+		Type:     JournalEntryEnqueue,
+		EntityID: id,
+		Deadline: deadline,
+	})
+	pool.Enqueue(asyncQueueItem)
 }
 
 func (e *engine) IsScheduled(entity Entity) bool {
@@ -223,7 +413,80 @@ func (e *engine) IsScheduled(entity Entity) bool {
 	entityItem.RLock()
 	defer entityItem.RUnlock()
 
-	return entityItem.queueItem.IsScheduled()
+	return entityItem.queueItem.IsScheduled() ||
+		entityItem.highPriorityQueueItem.IsScheduled()
+}
+
+func (e *engine) Entities() []EntityInfo {
+	e.RLock()
+	entityItems := make([]*entityMapItem, 0, len(e.entityMap))
+	for _, entityItem := range e.entityMap {
+		entityItems = append(entityItems, entityItem)
+	}
+	e.RUnlock()
+
+	infos := make([]EntityInfo, 0, len(entityItems))
+	for _, entityItem := range entityItems {
+		infos = append(infos, entityItem.info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
+// info returns the state of the entity item. It does not acquire the
+// entity item lock to not wait for the entity actions to complete.
+func (entityItem *entityMapItem) info() EntityInfo {
+	entityItem.status.RLock()
+	defer entityItem.status.RUnlock()
+
+	info := EntityInfo{
+		ID:         entityItem.queueItem.GetString(),
+		Delay:      entityItem.status.delay,
+		Retries:    entityItem.status.retries,
+		Running:    entityItem.status.running,
+		LastAction: entityItem.status.lastAction,
+		LastError:  entityItem.status.lastError,
+	}
+	// the entity is evaluated at the earliest deadline of its lanes
+	for _, queueItem := range []*queue.Item{
+		entityItem.queueItem,
+		entityItem.highPriorityQueueItem,
+	} {
+		if !queueItem.IsScheduled() {
+			continue
+		}
+		deadline := queueItem.Deadline()
+		if !info.Scheduled || deadline.Before(info.Deadline) {
+			info.Deadline = deadline
+		}
+		info.Scheduled = true
+	}
+	return info
+}
+
+// setRunning records whether the entity actions are being executed.
+func (entityItem *entityMapItem) setRunning(running bool) {
+	entityItem.status.Lock()
+	defer entityItem.status.Unlock()
+
+	entityItem.status.running = running
+}
+
+// recordResult records the outcome of an evaluation of the entity.
+// It must be called with the entity item lock held.
+func (entityItem *entityMapItem) recordResult(action string, err error) {
+	entityItem.status.Lock()
+	defer entityItem.status.Unlock()
+
+	entityItem.status.delay = entityItem.delay
+	entityItem.status.retries = entityItem.retries
+	if err == nil {
+		return
+	}
+	entityItem.status.lastAction = action
+	entityItem.status.lastError = err.Error()
 }
 
 func (e *engine) Delete(entity Entity) {
@@ -233,7 +496,14 @@ func (e *engine) Delete(entity Entity) {
 
 // calculateDelay is a helper function to calculate the backoff delay
 // in case of error.
-func (e *engine) calculateDelay(entityItem *entityMapItem) {
+func (e *engine) calculateDelay(entityItem *entityMapItem, action string) {
+	entityItem.retries++
+	if e.retryPolicy != nil {
+		entityItem.delay = e.retryPolicy.Delay(
+			entityItem.entity, action, entityItem.retries)
+		return
+	}
+
 	entityItem.delay = entityItem.delay + e.getFailureRetryDelay()
 	if entityItem.delay > e.getMaxRetryDelay() {
 		entityItem.delay = e.getMaxRetryDelay()
@@ -243,7 +513,7 @@ func (e *engine) calculateDelay(entityItem *entityMapItem) {
 // runActions fetches the action list for an entity and then executes each action.
 // Return value reschedule indicates whether the entity needs to be rescheduled
 // in the deadline queue, while the return value delay indicates the deadline
-// from time.Now() when the entity needs to be evaluated again.
+// from the engine clock when the entity needs to be evaluated again.
 // // Enqueue should always happen outside entityItem lock, hence enqueue is not done here.
 func (e *engine) runActions(entityItem *entityMapItem) (reschedule bool, delay time.Duration) {
 	entityItem.Lock()
@@ -261,12 +531,24 @@ func (e *engine) runActions(entityItem *entityMapItem) (reschedule bool, delay t
 		return false, 0
 	}
 
+	entityItem.setRunning(true)
+	defer entityItem.setRunning(false)
+
 	// Execute each action.
 	for _, action := range actions {
-		tStart := time.Now()
+		tStart := e.now()
 		err := action.Execute(ctx, entityItem.entity)
-		e.mtx.scope.Tagged(map[string]string{"action": action.Name}).
-			Timer("run_duration").Record(time.Since(tStart))
+		e.recordActionDuration(
+			entityItem.entity.GetID(), action.Name, e.now().Sub(tStart))
+		entry := JournalEntry{
+			Type:     JournalEntryAction,
+			EntityID: entityItem.entity.GetID(),
+			Action:   action.Name,
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		e.record(entry)
 		if err != nil {
 			log.WithError(err).
 				WithFields(log.Fields{
@@ -275,15 +557,40 @@ func (e *engine) runActions(entityItem *entityMapItem) (reschedule bool, delay t
 				}).
 				Info("goal state action failed to execute")
 			// Backoff and reevaluate the entity again.
-			e.calculateDelay(entityItem)
+			e.calculateDelay(entityItem, action.Name)
+			entityItem.recordResult(action.Name, err)
 			return true, entityItem.delay
 		}
 		// set delay to 0
 		entityItem.delay = 0
+		entityItem.retries = 0
 	}
+	entityItem.recordResult("", nil)
 	return false, 0
 }
 
+// recordActionDuration records the duration of an action executed for
+// an entity in the metrics of the action, and logs the action if it is
+// slower than the slow action threshold.
+func (e *engine) recordActionDuration(
+	entityID string,
+	actionName string,
+	elapsed time.Duration) {
+	scope := e.mtx.scope.Tagged(map[string]string{"action": actionName})
+	scope.Timer("run_duration").Record(elapsed)
+
+	if e.slowActionThreshold <= 0 || elapsed <= e.slowActionThreshold {
+		return
+	}
+	scope.Counter("slow_actions").Inc(1)
+	log.WithFields(log.Fields{
+		"entity_id":   entityID,
+		"action_name": actionName,
+		"elapsed":     elapsed.String(),
+		"threshold":   e.slowActionThreshold.String(),
+	}).Warn("goal state action is slow")
+}
+
 // processEntityAfterDequeue is a helper function to evaluate
 // an entity dequeued from the deadline queue, and execute the
 // corresponding actions.
@@ -304,10 +611,18 @@ func (e *engine) processEntityAfterDequeue(queueItem *queue.Item) {
 
 	reschedule, delay := e.runActions(entityItem)
 	if reschedule == true {
+		// failed entities are retried in the default lane, so that the
+		// retries do not delay the entities enqueued with high priority
+		deadline := e.now().Add(delay)
 		asyncQueueItem := &asyncWorkerQueueItem{
-			item:     queueItem,
-			deadline: time.Now().Add(delay),
+			item:     entityItem.queueItem,
+			deadline: deadline,
 		}
+		e.record(JournalEntry{
+			Type:     JournalEntryEnqueue,
+			EntityID: queueItem.GetString(),
+			Deadline: deadline,
+		})
 		e.pool.Enqueue(asyncQueueItem)
 	}
 }
@@ -317,6 +632,9 @@ func (e *engine) Start() {
 	defer e.Unlock()
 
 	e.pool.Start()
+	if e.highPriorityPool != nil {
+		e.highPriorityPool.Start()
+	}
 	log.Info("goalstate.Engine started")
 }
 
@@ -325,5 +643,8 @@ func (e *engine) Stop() {
 	defer e.Unlock()
 
 	e.pool.Stop()
+	if e.highPriorityPool != nil {
+		e.highPriorityPool.Stop()
+	}
 	log.Info("goalstate.Engine stopped")
 }
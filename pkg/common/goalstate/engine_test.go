@@ -371,3 +371,209 @@ func TestMultiRequeue(t *testing.T) {
 	e.pool.Stop()
 	assert.Equal(t, count, len(idList))
 }
+
+// TestEngineEntities tests listing the state of the entities tracked by
+// the goal state engine.
+func TestEngineEntities(t *testing.T) {
+	idList = []string{}
+	failCount = 0
+	e := &engine{
+		entityMap:         make(map[string]*entityMapItem),
+		failureRetryDelay: 100 * time.Millisecond,
+		maxRetryDelay:     200 * time.Millisecond,
+		mtx:               NewMetrics(tally.NoopScope),
+	}
+
+	asyncQueue := &asyncWorkerQueue{
+		queue:  queue.NewDeadlineQueue(queue.NewQueueMetrics(tally.NoopScope)),
+		engine: e,
+	}
+
+	pool := async.NewPool(
+		async.PoolOptions{MaxWorkers: numWorkerThreads},
+		asyncQueue,
+	)
+	e.pool = pool
+
+	deadline := time.Now().Add(30 * time.Second)
+	e.Enqueue(newTestEntity("1", stateValue, goalStateValueFail), deadline)
+	e.Enqueue(newTestEntity("0", stateValue, goalStateValue), deadline)
+
+	infos := e.Entities()
+	assert.Len(t, infos, 2)
+	assert.Equal(t, "0", infos[0].ID)
+	assert.Equal(t, "1", infos[1].ID)
+	for _, info := range infos {
+		assert.True(t, info.Scheduled)
+		assert.True(t, deadline.Equal(info.Deadline))
+		assert.Equal(t, uint32(0), info.Retries)
+		assert.False(t, info.Running)
+	}
+
+	// Evaluate the failing entity twice without the worker pool.
+	entityItem := e.getItemFromEntityMap("1")
+	for i := 0; i < 2; i++ {
+		reschedule, _ := e.runActions(entityItem)
+		assert.True(t, reschedule)
+	}
+
+	infos = e.Entities()
+	assert.Equal(t, uint32(2), infos[1].Retries)
+	assert.Equal(t, 200*time.Millisecond, infos[1].Delay)
+	assert.Equal(t, "testActionFailure", infos[1].LastAction)
+	assert.Equal(t, "fake error", infos[1].LastError)
+	failCount = 0
+}
+
+// TestEngineRetryPolicy tests that the delay of a failed entity is
+// computed by the retry policy of the engine.
+func TestEngineRetryPolicy(t *testing.T) {
+	idList = []string{}
+	failCount = 0
+	e := newEngine(
+		100*time.Millisecond,
+		200*time.Millisecond,
+		tally.NoopScope,
+		WithRetryPolicy(NewExponentialRetryPolicy(
+			time.Second, 3, time.Minute, 0)))
+	e.pool = async.NewPool(
+		async.PoolOptions{MaxWorkers: numWorkerThreads},
+		&asyncWorkerQueue{
+			queue:  queue.NewDeadlineQueue(queue.NewQueueMetrics(tally.NoopScope)),
+			engine: e,
+		},
+	)
+	e.Enqueue(newTestEntity("0", stateValue, goalStateValueFail), time.Now())
+
+	entityItem := e.getItemFromEntityMap("0")
+	for _, expected := range []time.Duration{
+		time.Second, 3 * time.Second, 9 * time.Second} {
+		reschedule, delay := e.runActions(entityItem)
+		assert.True(t, reschedule)
+		assert.Equal(t, expected, delay)
+	}
+
+	// the fourth evaluation succeeds and resets the backoff
+	wg.Add(1)
+	reschedule, _ := e.runActions(entityItem)
+	assert.False(t, reschedule)
+	assert.Equal(t, uint32(0), e.Entities()[0].Retries)
+	assert.Equal(t, time.Duration(0), e.Entities()[0].Delay)
+}
+
+// TestEngineHighPriority tests that an entity enqueued with high priority
+// is evaluated by the workers of the high priority lane, ahead of its
+// deadline in the default lane.
+func TestEngineHighPriority(t *testing.T) {
+	idList = []string{}
+	failCount = 0
+	e := NewEngine(
+		numWorkerThreads,
+		time.Second,
+		time.Second,
+		tally.NoopScope,
+		WithHighPriorityWorkers(1)).(*engine)
+
+	ent := newTestEntity("0", stateValue, goalStateValue)
+	deadline := time.Now().Add(time.Hour)
+	e.Enqueue(ent, deadline)
+	e.EnqueueWithPriority(ent, time.Now(), PriorityHigh)
+	assert.True(t, e.IsScheduled(ent))
+	assert.True(t, e.Entities()[0].Deadline.Before(deadline))
+
+	wg.Add(1)
+	e.Start()
+	wg.Wait()
+	e.Stop()
+	assert.Equal(t, []string{"0"}, idList)
+
+	// the entity is still scheduled in the default lane
+	infos := e.Entities()
+	assert.True(t, infos[0].Scheduled)
+	assert.Equal(t, deadline, infos[0].Deadline)
+}
+
+// TestEngineHighPriorityWithoutLane tests that an entity enqueued with
+// high priority is enqueued in the default lane if the engine has no
+// high priority lane.
+func TestEngineHighPriorityWithoutLane(t *testing.T) {
+	e := NewEngine(
+		numWorkerThreads,
+		time.Second,
+		time.Second,
+		tally.NoopScope).(*engine)
+
+	ent := newTestEntity("0", stateValue, goalStateValue)
+	e.EnqueueWithPriority(ent, time.Now().Add(time.Hour), PriorityHigh)
+	entityItem := e.getItemFromEntityMap("0")
+	assert.True(t, entityItem.queueItem.IsScheduled())
+	assert.False(t, entityItem.highPriorityQueueItem.IsScheduled())
+}
+
+// slowTestEntity is an entity whose only action advances the clock of
+// the engine by the given duration.
+type slowTestEntity struct {
+	clock   *ManualClock
+	elapsed time.Duration
+}
+
+func (te *slowTestEntity) GetID() string {
+	return "slow"
+}
+
+func (te *slowTestEntity) GetState() interface{} {
+	return stateValue
+}
+
+func (te *slowTestEntity) GetGoalState() interface{} {
+	return goalStateValue
+}
+
+func (te *slowTestEntity) GetActionList(state interface{}, goalstate interface{}) (context.Context, context.CancelFunc, []Action) {
+	return context.Background(), nil, []Action{{
+		Name: "slowAction",
+		Execute: func(ctx context.Context, entity Entity) error {
+			te.clock.Advance(te.elapsed)
+			return nil
+		},
+	}}
+}
+
+// TestEngineSlowAction tests that the duration of the actions is recorded
+// by action name, and that the actions slower than the slow action
+// threshold are counted.
+func TestEngineSlowAction(t *testing.T) {
+	testScope := tally.NewTestScope("", map[string]string{})
+	clock := NewManualClock(time.Now())
+	e := newEngine(
+		time.Second,
+		time.Second,
+		testScope,
+		WithClock(clock),
+		WithSlowActionThreshold(time.Second))
+	e.pool = async.NewPool(
+		async.PoolOptions{MaxWorkers: numWorkerThreads},
+		&asyncWorkerQueue{
+			queue:  queue.NewDeadlineQueue(queue.NewQueueMetrics(tally.NoopScope)),
+			engine: e,
+		},
+	)
+
+	ent := &slowTestEntity{clock: clock}
+	e.Enqueue(ent, clock.Now())
+	entityItem := e.getItemFromEntityMap(ent.GetID())
+
+	// an action as long as the threshold is not slow
+	for _, elapsed := range []time.Duration{
+		time.Millisecond, time.Second, 2 * time.Second} {
+		ent.elapsed = elapsed
+		reschedule, _ := e.runActions(entityItem)
+		assert.False(t, reschedule)
+	}
+
+	snapshot := testScope.Snapshot()
+	assert.Equal(t, int64(1),
+		snapshot.Counters()["slow_actions+action=slowAction"].Value())
+	assert.Len(t,
+		snapshot.Timers()["run_duration+action=slowAction"].Values(), 3)
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goalstate
+
+import (
+	"sync"
+	"time"
+)
+
+// JournalEntryType is the type of an entry recorded in the journal.
+type JournalEntryType int
+
+const (
+	// JournalEntryEnqueue is recorded when an entity is scheduled
+	// for evaluation.
+	JournalEntryEnqueue JournalEntryType = iota + 1
+	// JournalEntryAction is recorded when an action of an entity
+	// has been executed.
+	JournalEntryAction
+)
+
+// String returns the name of the journal entry type.
+func (t JournalEntryType) String() string {
+	switch t {
+	case JournalEntryEnqueue:
+		return "enqueue"
+	case JournalEntryAction:
+		return "action"
+	}
+	return "unknown"
+}
+
+// JournalEntry records a single decision taken by the goal state engine.
+type JournalEntry struct {
+	// Type of the entry.
+	Type JournalEntryType
+	// Time at which the entry was recorded, as reported by the engine clock.
+	Time time.Time
+	// EntityID is the identifier of the entity the entry is about.
+	EntityID string
+	// Deadline at which the entity is to be evaluated. Only set for
+	// JournalEntryEnqueue entries.
+	Deadline time.Time
+	// Action is the name of the executed action. Only set for
+	// JournalEntryAction entries.
+	Action string
+	// Error is the error returned by the executed action, if any.
+	Error string
+}
+
+// Journal records the decisions taken by a goal state engine, so that
+// the sequence of actions run for an entity can be inspected or compared
+// against a replay of the same entity.
+type Journal interface {
+	// Record adds an entry to the journal.
+	Record(entry JournalEntry)
+}
+
+// MemoryJournal is a Journal which keeps the most recent entries in memory.
+type MemoryJournal struct {
+	sync.RWMutex // the mutex to synchronize access to this object
+
+	entries  []JournalEntry
+	next     int // index at which the next entry is written once full
+	capacity int
+}
+
+// NewMemoryJournal returns a MemoryJournal which retains at most
+// capacity entries. A non-positive capacity retains all entries.
+func NewMemoryJournal(capacity int) *MemoryJournal {
+	return &MemoryJournal{capacity: capacity}
+}
+
+// Record adds an entry to the journal, evicting the oldest one
+// if the journal is full.
+func (j *MemoryJournal) Record(entry JournalEntry) {
+	j.Lock()
+	defer j.Unlock()
+
+	if j.capacity <= 0 || len(j.entries) < j.capacity {
+		j.entries = append(j.entries, entry)
+		return
+	}
+
+	j.entries[j.next] = entry
+	j.next = (j.next + 1) % j.capacity
+}
+
+// Entries returns the entries in the journal, oldest first.
+func (j *MemoryJournal) Entries() []JournalEntry {
+	j.RLock()
+	defer j.RUnlock()
+
+	entries := make([]JournalEntry, 0, len(j.entries))
+	entries = append(entries, j.entries[j.next:]...)
+	entries = append(entries, j.entries[:j.next]...)
+	return entries
+}
+
+// EntityEntries returns the entries in the journal for the given
+// entity identifier, oldest first.
+func (j *MemoryJournal) EntityEntries(id string) []JournalEntry {
+	var entries []JournalEntry
+	for _, entry := range j.Entries() {
+		if entry.EntityID == id {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goalstate
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMemoryJournal tests recording entries in an unbounded journal.
+func TestMemoryJournal(t *testing.T) {
+	j := NewMemoryJournal(0)
+	for i := 0; i < 5; i++ {
+		j.Record(JournalEntry{
+			Type:     JournalEntryAction,
+			EntityID: fmt.Sprintf("entity%d", i%2),
+			Action:   fmt.Sprintf("action%d", i),
+		})
+	}
+
+	assert.Len(t, j.Entries(), 5)
+	entries := j.EntityEntries("entity1")
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "action1", entries[0].Action)
+	assert.Equal(t, "action3", entries[1].Action)
+}
+
+// TestMemoryJournalCapacity tests that the oldest entries are evicted
+// once the journal is full.
+func TestMemoryJournalCapacity(t *testing.T) {
+	j := NewMemoryJournal(3)
+	for i := 0; i < 5; i++ {
+		j.Record(JournalEntry{Action: fmt.Sprintf("action%d", i)})
+	}
+
+	var actions []string
+	for _, entry := range j.Entries() {
+		actions = append(actions, entry.Action)
+	}
+	assert.Equal(t, []string{"action2", "action3", "action4"}, actions)
+}
+
+// TestJournalEntryTypeString tests the names of the journal entry types.
+func TestJournalEntryTypeString(t *testing.T) {
+	assert.Equal(t, "enqueue", JournalEntryEnqueue.String())
+	assert.Equal(t, "action", JournalEntryAction.String())
+	assert.Equal(t, "unknown", JournalEntryType(0).String())
+}
@@ -26,13 +26,16 @@ type Metrics struct {
 	missingItems tally.Counter
 	// counter to track total items in the goal state engine
 	totalItems tally.Gauge
+	// counter to track the entities enqueued in the high priority lane
+	highPriorityEnqueues tally.Counter
 }
 
 // NewMetrics returns a new Metrics struct.
 func NewMetrics(scope tally.Scope) *Metrics {
 	return &Metrics{
-		scope:        scope,
-		missingItems: scope.Counter("missing_items"),
-		totalItems:   scope.Gauge("total_items"),
+		scope:                scope,
+		missingItems:         scope.Counter("missing_items"),
+		totalItems:           scope.Gauge("total_items"),
+		highPriorityEnqueues: scope.Counter("high_priority_enqueues"),
 	}
 }
@@ -0,0 +1,215 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goalstate
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"github.com/uber/peloton/pkg/common/async"
+	queue "github.com/uber/peloton/pkg/common/deadline_queue"
+
+	"github.com/uber-go/tally"
+)
+
+// ReplayEngine is a goal state engine which does not evaluate entities in
+// the background. Instead, entities are evaluated on the caller's goroutine
+// in deadline order when RunUntil is called, and the manual clock of the
+// engine is moved to the deadline of each evaluated entity. Entities with
+// the same deadline are evaluated in the order they were enqueued.
+// Given the same entities and the same sequence of calls, a replay engine
+// always executes the same sequence of actions, which makes it possible
+// to replay the decisions of a production engine in a test harness.
+type ReplayEngine interface {
+	Engine
+	// RunUntil evaluates all entities whose deadline is not after the
+	// provided time, including the ones enqueued while evaluating, and
+	// then moves the clock to the provided time.
+	// It returns the number of entities evaluated.
+	RunUntil(t time.Time) int
+	// NextDeadline returns the earliest deadline of the scheduled entities,
+	// or the zero time if no entity is scheduled.
+	NextDeadline() time.Time
+}
+
+// NewReplayEngine returns a new replay engine object, which uses the
+// provided clock to compute deadlines and records its decisions in the
+// provided journal, if not nil.
+func NewReplayEngine(
+	clock *ManualClock,
+	journal Journal,
+	failureRetryDelay time.Duration,
+	maxRetryDelay time.Duration,
+	parentScope tally.Scope) ReplayEngine {
+	e := newEngine(
+		failureRetryDelay,
+		maxRetryDelay,
+		parentScope,
+		WithClock(clock),
+		WithJournal(journal))
+	q := &replayQueue{}
+	e.pool = q
+
+	return &replayEngine{
+		engine: e,
+		clock:  clock,
+		queue:  q,
+	}
+}
+
+// replayEngine implements the ReplayEngine interface.
+type replayEngine struct {
+	*engine
+
+	clock *ManualClock
+	queue *replayQueue
+}
+
+func (r *replayEngine) NextDeadline() time.Time {
+	return r.queue.nextDeadline()
+}
+
+func (r *replayEngine) RunUntil(t time.Time) int {
+	count := 0
+	for {
+		item := r.queue.pop(t)
+		if item == nil {
+			break
+		}
+		r.clock.Set(item.deadline)
+		r.processEntityAfterDequeue(item.item)
+		count++
+	}
+	r.clock.Set(t)
+	return count
+}
+
+// replayQueueItem is an entity scheduled in the replay queue.
+type replayQueueItem struct {
+	item     *queue.Item
+	deadline time.Time
+	seq      uint64 // order of enqueue, used to break ties on deadline
+	index    int
+}
+
+// replayQueue implements the workerPool interface by storing the
+// enqueued entities in a heap ordered by deadline and enqueue order.
+// Like the deadline queue, an entity is scheduled at most once, and
+// enqueueing it again only moves its deadline earlier.
+type replayQueue struct {
+	sync.Mutex // the mutex to synchronize access to this object
+
+	items   replayHeap
+	byID    map[string]*replayQueueItem
+	nextSeq uint64
+}
+
+func (q *replayQueue) Start() {}
+
+func (q *replayQueue) Stop() {}
+
+func (q *replayQueue) Enqueue(job async.Job) {
+	w := job.(*asyncWorkerQueueItem)
+	item := w.item.(*queue.Item)
+
+	q.Lock()
+	defer q.Unlock()
+
+	if q.byID == nil {
+		q.byID = make(map[string]*replayQueueItem)
+	}
+
+	if existing, ok := q.byID[item.GetString()]; ok {
+		if w.deadline.Before(existing.deadline) {
+			existing.deadline = w.deadline
+			existing.seq = q.nextSeq
+			q.nextSeq++
+			item.SetDeadline(w.deadline)
+			heap.Fix(&q.items, existing.index)
+		}
+		return
+	}
+
+	qi := &replayQueueItem{
+		item:     item,
+		deadline: w.deadline,
+		seq:      q.nextSeq,
+	}
+	q.nextSeq++
+	item.SetDeadline(w.deadline)
+	q.byID[item.GetString()] = qi
+	heap.Push(&q.items, qi)
+}
+
+// pop removes and returns the entity with the earliest deadline if the
+// deadline is not after the provided time.
+func (q *replayQueue) pop(t time.Time) *replayQueueItem {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.items.Len() == 0 || q.items[0].deadline.After(t) {
+		return nil
+	}
+
+	qi := heap.Pop(&q.items).(*replayQueueItem)
+	delete(q.byID, qi.item.GetString())
+	qi.item.SetDeadline(time.Time{})
+	return qi
+}
+
+func (q *replayQueue) nextDeadline() time.Time {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.items.Len() == 0 {
+		return time.Time{}
+	}
+	return q.items[0].deadline
+}
+
+// replayHeap implements container/heap.Interface for replay queue items.
+type replayHeap []*replayQueueItem
+
+func (h replayHeap) Len() int { return len(h) }
+
+func (h replayHeap) Less(i, j int) bool {
+	if h[i].deadline.Equal(h[j].deadline) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].deadline.Before(h[j].deadline)
+}
+
+func (h replayHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *replayHeap) Push(x interface{}) {
+	item := x.(*replayQueueItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *replayHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*h = old[0 : n-1]
+	return item
+}
@@ -0,0 +1,247 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goalstate
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally"
+)
+
+// replayTestEntity is an entity whose actions append to a shared
+// list of executed actions, and fail a configurable number of times.
+type replayTestEntity struct {
+	id       string
+	failures int
+	executed *[]string
+	engine   Engine
+	clock    Clock
+	// requeueAfter, if not zero, is the delay after which the
+	// entity enqueues itself again the first time it is evaluated.
+	requeueAfter time.Duration
+}
+
+func (te *replayTestEntity) GetID() string {
+	return te.id
+}
+
+func (te *replayTestEntity) GetState() interface{} {
+	return stateValue
+}
+
+func (te *replayTestEntity) GetGoalState() interface{} {
+	return goalStateValue
+}
+
+func (te *replayTestEntity) GetActionList(
+	state interface{},
+	goalstate interface{}) (context.Context, context.CancelFunc, []Action) {
+	return context.Background(), nil, []Action{{
+		Name: "replayAction",
+		Execute: func(ctx context.Context, entity Entity) error {
+			*te.executed = append(*te.executed, te.id)
+			if te.failures > 0 {
+				te.failures--
+				return fmt.Errorf("fake error")
+			}
+			if te.requeueAfter > 0 {
+				te.engine.Enqueue(te, te.clock.Now().Add(te.requeueAfter))
+				te.requeueAfter = 0
+			}
+			return nil
+		},
+	}}
+}
+
+// TestManualClock tests setting and advancing the manual clock.
+func TestManualClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	c := NewManualClock(start)
+	assert.Equal(t, start, c.Now())
+
+	c.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second), c.Now())
+
+	// the clock never moves backwards
+	c.Set(start)
+	c.Advance(-time.Second)
+	assert.Equal(t, start.Add(time.Second), c.Now())
+
+	c.Set(start.Add(time.Minute))
+	assert.Equal(t, start.Add(time.Minute), c.Now())
+}
+
+// TestReplayEngineOrder tests that the replay engine evaluates entities in
+// deadline order, and in enqueue order for entities with the same deadline.
+func TestReplayEngineOrder(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewManualClock(start)
+	journal := NewMemoryJournal(0)
+	e := NewReplayEngine(clock, journal, time.Second, 10*time.Second, tally.NoopScope)
+
+	var executed []string
+	for i, delay := range []time.Duration{3, 1, 2, 1} {
+		e.Enqueue(&replayTestEntity{
+			id:       fmt.Sprintf("entity%d", i),
+			executed: &executed,
+		}, start.Add(delay*time.Second))
+	}
+	assert.True(t, e.IsScheduled(&replayTestEntity{id: "entity0"}))
+	assert.Equal(t, start.Add(time.Second), e.NextDeadline())
+
+	assert.Equal(t, 3, e.RunUntil(start.Add(2*time.Second)))
+	assert.Equal(t, []string{"entity1", "entity3", "entity2"}, executed)
+	assert.Equal(t, start.Add(2*time.Second), clock.Now())
+	assert.False(t, e.IsScheduled(&replayTestEntity{id: "entity1"}))
+
+	assert.Equal(t, 1, e.RunUntil(start.Add(time.Minute)))
+	assert.Equal(t, []string{"entity1", "entity3", "entity2", "entity0"}, executed)
+	assert.Equal(t, start.Add(time.Minute), clock.Now())
+	assert.True(t, e.NextDeadline().IsZero())
+
+	entries := journal.EntityEntries("entity0")
+	assert.Len(t, entries, 2)
+	assert.Equal(t, JournalEntryEnqueue, entries[0].Type)
+	assert.Equal(t, start.Add(3*time.Second), entries[0].Deadline)
+	assert.Equal(t, JournalEntryAction, entries[1].Type)
+	assert.Equal(t, "replayAction", entries[1].Action)
+	assert.Equal(t, start.Add(3*time.Second), entries[1].Time)
+}
+
+// TestReplayEngineEnqueueEarlierDeadline tests that enqueueing a scheduled
+// entity only moves its deadline earlier.
+func TestReplayEngineEnqueueEarlierDeadline(t *testing.T) {
+	start := time.Unix(1000, 0)
+	e := NewReplayEngine(NewManualClock(start), nil, time.Second, 10*time.Second, tally.NoopScope)
+
+	var executed []string
+	entity := &replayTestEntity{id: "entity", executed: &executed}
+	e.Enqueue(entity, start.Add(5*time.Second))
+	e.Enqueue(entity, start.Add(10*time.Second))
+	assert.Equal(t, start.Add(5*time.Second), e.NextDeadline())
+
+	e.Enqueue(entity, start.Add(time.Second))
+	assert.Equal(t, start.Add(time.Second), e.NextDeadline())
+
+	assert.Equal(t, 1, e.RunUntil(start.Add(time.Minute)))
+	assert.Equal(t, []string{"entity"}, executed)
+}
+
+// TestReplayEngineRetry tests that failed actions are retried with
+// backoff computed from the manual clock.
+func TestReplayEngineRetry(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewManualClock(start)
+	journal := NewMemoryJournal(0)
+	e := NewReplayEngine(clock, journal, time.Second, 2*time.Second, tally.NoopScope)
+
+	var executed []string
+	entity := &replayTestEntity{id: "entity", failures: 3, executed: &executed}
+	e.Enqueue(entity, start)
+
+	assert.Equal(t, 4, e.RunUntil(start.Add(time.Minute)))
+	assert.Len(t, executed, 4)
+
+	var deadlines []time.Time
+	var errors []string
+	for _, entry := range journal.Entries() {
+		switch entry.Type {
+		case JournalEntryEnqueue:
+			deadlines = append(deadlines, entry.Deadline)
+		case JournalEntryAction:
+			errors = append(errors, entry.Error)
+		}
+	}
+	assert.Equal(t, []time.Time{
+		start,
+		start.Add(time.Second),
+		start.Add(3 * time.Second),
+		start.Add(5 * time.Second),
+	}, deadlines)
+	assert.Equal(t, []string{"fake error", "fake error", "fake error", ""}, errors)
+}
+
+// TestReplayEngineRequeueWhileRunning tests that entities enqueued while
+// running actions are evaluated in the same call to RunUntil.
+func TestReplayEngineRequeueWhileRunning(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewManualClock(start)
+	e := NewReplayEngine(clock, nil, time.Second, 10*time.Second, tally.NoopScope)
+
+	var executed []string
+	entity := &replayTestEntity{
+		id:           "entity",
+		executed:     &executed,
+		engine:       e,
+		clock:        clock,
+		requeueAfter: 10 * time.Second,
+	}
+	e.Enqueue(entity, start)
+
+	assert.Equal(t, 1, e.RunUntil(start.Add(5*time.Second)))
+	assert.Equal(t, start.Add(10*time.Second), e.NextDeadline())
+	assert.Equal(t, 1, e.RunUntil(start.Add(10*time.Second)))
+	assert.Equal(t, []string{"entity", "entity"}, executed)
+}
+
+// TestReplayEngineDeletedEntity tests that entities deleted from the
+// engine are skipped when their deadline expires.
+func TestReplayEngineDeletedEntity(t *testing.T) {
+	start := time.Unix(1000, 0)
+	e := NewReplayEngine(NewManualClock(start), nil, time.Second, 10*time.Second, tally.NoopScope)
+
+	var executed []string
+	entity := &replayTestEntity{id: "entity", executed: &executed}
+	e.Enqueue(entity, start)
+	e.Delete(entity)
+
+	assert.Equal(t, 1, e.RunUntil(start))
+	assert.Empty(t, executed)
+}
+
+// TestEngineWithClockAndJournal tests that the engine records its decisions
+// in the journal using the injected clock.
+func TestEngineWithClockAndJournal(t *testing.T) {
+	now := time.Now()
+	clock := NewManualClock(now)
+	journal := NewMemoryJournal(0)
+	e := NewEngine(
+		1,
+		time.Second,
+		time.Second,
+		tally.NoopScope,
+		WithClock(clock),
+		WithJournal(journal)).(*engine)
+
+	var executed []string
+	entity := &replayTestEntity{id: "entity", failures: 1, executed: &executed}
+	e.Enqueue(entity, now)
+	e.processEntityAfterDequeue(e.getItemFromEntityMap("entity").queueItem)
+
+	entries := journal.Entries()
+	assert.Len(t, entries, 3)
+	assert.Equal(t, JournalEntryEnqueue, entries[0].Type)
+	assert.Equal(t, JournalEntryAction, entries[1].Type)
+	assert.Equal(t, "fake error", entries[1].Error)
+	assert.Equal(t, JournalEntryEnqueue, entries[2].Type)
+	assert.Equal(t, now.Add(time.Second), entries[2].Deadline)
+	for _, entry := range entries {
+		assert.Equal(t, now, entry.Time)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goalstate
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy computes the delay before evaluating again an entity
+// whose action failed.
+type RetryPolicy interface {
+	// Delay returns the delay before evaluating again the entity after
+	// the given action failed, where retries is the number of consecutive
+	// evaluations of the entity which failed, including this one.
+	Delay(entity Entity, action string, retries uint32) time.Duration
+}
+
+// NewLinearRetryPolicy returns a retry policy whose delay grows by step
+// after each failure, up to max. It is the default policy of the goal
+// state engine, using the failure retry delay and the max retry delay.
+func NewLinearRetryPolicy(step time.Duration, max time.Duration) RetryPolicy {
+	return &linearRetryPolicy{
+		step: step,
+		max:  max,
+	}
+}
+
+// linearRetryPolicy implements a linear backoff.
+type linearRetryPolicy struct {
+	step time.Duration
+	max  time.Duration
+}
+
+func (p *linearRetryPolicy) Delay(
+	entity Entity,
+	action string,
+	retries uint32) time.Duration {
+	if p.step <= 0 {
+		return p.max
+	}
+	if int64(retries) >= int64(p.max/p.step) {
+		return p.max
+	}
+	return p.step * time.Duration(retries)
+}
+
+// NewExponentialRetryPolicy returns a retry policy whose delay is
+// initial after the first failure and is multiplied by multiplier after
+// each failure, up to max. The delay is then reduced by a random amount
+// of up to jitter times the delay, with jitter in [0, 1], so that the
+// entities which failed at the same time, for example during a storage
+// outage, are not all retried at the same time.
+func NewExponentialRetryPolicy(
+	initial time.Duration,
+	multiplier float64,
+	max time.Duration,
+	jitter float64) RetryPolicy {
+	return &exponentialRetryPolicy{
+		initial:    initial,
+		multiplier: multiplier,
+		max:        max,
+		jitter:     math.Max(0, math.Min(jitter, 1)),
+		random:     rand.Float64,
+	}
+}
+
+// exponentialRetryPolicy implements an exponential backoff with jitter.
+type exponentialRetryPolicy struct {
+	initial    time.Duration
+	multiplier float64
+	max        time.Duration
+	jitter     float64
+
+	// random returns a random number in [0, 1)
+	random func() float64
+}
+
+func (p *exponentialRetryPolicy) Delay(
+	entity Entity,
+	action string,
+	retries uint32) time.Duration {
+	exponent := 0.0
+	if retries > 0 {
+		exponent = float64(retries - 1)
+	}
+	delay := float64(p.initial) * math.Pow(p.multiplier, exponent)
+	if delay > float64(p.max) {
+		delay = float64(p.max)
+	}
+	if p.jitter > 0 {
+		delay -= delay * p.jitter * p.random()
+	}
+	return time.Duration(delay)
+}
+
+// NewActionRetryPolicy returns a retry policy which uses the policy of
+// the failed action in overrides, keyed by action name, and the default
+// policy for the other actions.
+func NewActionRetryPolicy(
+	defaultPolicy RetryPolicy,
+	overrides map[string]RetryPolicy) RetryPolicy {
+	return &actionRetryPolicy{
+		defaultPolicy: defaultPolicy,
+		overrides:     overrides,
+	}
+}
+
+// actionRetryPolicy implements per-action overrides of a retry policy.
+type actionRetryPolicy struct {
+	defaultPolicy RetryPolicy
+	overrides     map[string]RetryPolicy
+}
+
+func (p *actionRetryPolicy) Delay(
+	entity Entity,
+	action string,
+	retries uint32) time.Duration {
+	if policy, ok := p.overrides[action]; ok {
+		return policy.Delay(entity, action, retries)
+	}
+	return p.defaultPolicy.Delay(entity, action, retries)
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goalstate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLinearRetryPolicy tests the delay of the linear retry policy.
+func TestLinearRetryPolicy(t *testing.T) {
+	policy := NewLinearRetryPolicy(10*time.Second, 35*time.Second)
+	entity := newTestEntity("0", stateValue, goalStateValueFail)
+
+	assert.Equal(t, 10*time.Second, policy.Delay(entity, "action", 1))
+	assert.Equal(t, 30*time.Second, policy.Delay(entity, "action", 3))
+	assert.Equal(t, 35*time.Second, policy.Delay(entity, "action", 4))
+	assert.Equal(t, 35*time.Second, policy.Delay(entity, "action", 1<<31))
+
+	policy = NewLinearRetryPolicy(0, 35*time.Second)
+	assert.Equal(t, 35*time.Second, policy.Delay(entity, "action", 1))
+}
+
+// TestExponentialRetryPolicy tests the delay of the exponential retry
+// policy, with and without jitter.
+func TestExponentialRetryPolicy(t *testing.T) {
+	entity := newTestEntity("0", stateValue, goalStateValueFail)
+
+	policy := NewExponentialRetryPolicy(time.Second, 2, time.Minute, 0)
+	assert.Equal(t, time.Second, policy.Delay(entity, "action", 1))
+	assert.Equal(t, 8*time.Second, policy.Delay(entity, "action", 4))
+	assert.Equal(t, time.Minute, policy.Delay(entity, "action", 10))
+	assert.Equal(t, time.Minute, policy.Delay(entity, "action", 1<<31))
+
+	policy = NewExponentialRetryPolicy(time.Second, 2, time.Minute, 0.5)
+	policy.(*exponentialRetryPolicy).random = func() float64 { return 0.5 }
+	assert.Equal(t, 6*time.Second, policy.Delay(entity, "action", 4))
+	assert.Equal(t, 45*time.Second, policy.Delay(entity, "action", 10))
+
+	policy = NewExponentialRetryPolicy(time.Second, 2, time.Minute, 1)
+	for i := 0; i < 10; i++ {
+		delay := policy.Delay(entity, "action", 4)
+		assert.True(t, delay >= 0 && delay <= 8*time.Second)
+	}
+}
+
+// TestActionRetryPolicy tests overriding the retry policy of an action.
+func TestActionRetryPolicy(t *testing.T) {
+	entity := newTestEntity("0", stateValue, goalStateValueFail)
+	policy := NewActionRetryPolicy(
+		NewLinearRetryPolicy(10*time.Second, time.Minute),
+		map[string]RetryPolicy{
+			"fast": NewLinearRetryPolicy(time.Second, 5*time.Second),
+		})
+
+	assert.Equal(t, 20*time.Second, policy.Delay(entity, "slow", 2))
+	assert.Equal(t, 2*time.Second, policy.Delay(entity, "fast", 2))
+}
@@ -34,6 +34,20 @@ type Entity interface {
 		context.Context, context.CancelFunc, []Action)
 }
 
+// Priority defines the lane in which an entity is evaluated by the goal
+// state engine. Each lane has its own workers, so that the entities of
+// a lane are not delayed by the entities of the other lanes.
+type Priority int
+
+const (
+	// PriorityDefault is the lane of the background evaluations, such as
+	// the reconciliation of the entities and the retries of failed actions.
+	PriorityDefault Priority = iota
+	// PriorityHigh is the lane of the evaluations requested by users,
+	// such as starting, stopping or restarting tasks.
+	PriorityHigh
+)
+
 // ActionExecute defines the interface for the function to be used by the
 // goal state engine clients to implement the execution of an action.
 type ActionExecute func(ctx context.Context, entity Entity) error
@@ -76,9 +76,22 @@ func (s *staticDiscovery) GetAppURL(role string) (*url.URL, error) {
 func NewZkServiceDiscovery(
 	zkServers []string,
 	zkRoot string) (Discovery, error) {
+	return NewZkServiceDiscoveryFromConfig(ElectionConfig{
+		ZKServers: zkServers,
+		Root:      zkRoot,
+	})
+}
+
+// NewZkServiceDiscoveryFromConfig creates a zkDiscovery object that
+// looks up leaders in the election namespace of the given config
+func NewZkServiceDiscoveryFromConfig(cfg ElectionConfig) (Discovery, error) {
+	zkRoot, err := cfg.ElectionRoot()
+	if err != nil {
+		return nil, err
+	}
 
 	zkClient, err := zookeeper.New(
-		zkServers,
+		cfg.ZKServers,
 		&store.Config{ConnectionTimeout: zkConnErrRetry},
 	)
 	if err != nil {
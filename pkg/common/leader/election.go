@@ -16,6 +16,7 @@ package leader
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path"
 	"strings"
@@ -53,6 +54,19 @@ type ElectionConfig struct {
 	// The root path in ZK to use for role leader election. This will
 	// be something like /peloton/YOURCLUSTERHERE
 	Root string `yaml:"root"`
+	// Optional namespace appended to Root so that several control planes
+	// (e.g. dev, staging and prod) can share a ZK ensemble without their
+	// elections colliding. Must be a single path element.
+	Namespace string `yaml:"namespace"`
+}
+
+// ElectionRoot returns the ZK path under which the role elections of
+// this config are held, which is Root joined with the Namespace.
+func (c ElectionConfig) ElectionRoot() (string, error) {
+	if err := validateNamespace(c.Namespace); err != nil {
+		return "", err
+	}
+	return path.Join(c.Root, c.Namespace), nil
 }
 
 // election holds the state of the zkelection
@@ -80,6 +94,11 @@ func NewCandidate(
 			"for that isnt the empty string")
 	}
 
+	root, err := cfg.ElectionRoot()
+	if err != nil {
+		return nil, err
+	}
+
 	client, err := zookeeper.New(
 		cfg.ZKServers,
 		&store.Config{ConnectionTimeout: znodeEphemeralTimeout},
@@ -89,9 +108,9 @@ func NewCandidate(
 	}
 
 	if role == common.PelotonAuroraBridgeRole {
-		leaderPath = leaderBridgeZKPath(cfg.Root, role)
+		leaderPath = leaderBridgeZKPath(root, role)
 	} else {
-		leaderPath = leaderZkPath(cfg.Root, role)
+		leaderPath = leaderZkPath(root, role)
 	}
 	log.WithFields(log.Fields{
 		"id":          nomination.GetID(),
@@ -292,6 +311,20 @@ func leaderZkPath(rootPath string, role string) string {
 	return strings.TrimPrefix(path.Join(rootPath, role, "leader"), "/")
 }
 
+// validateNamespace checks that an election namespace is empty or a
+// single path element, so that it cannot escape the election root
+func validateNamespace(namespace string) error {
+	if namespace == "" {
+		return nil
+	}
+	if strings.Contains(namespace, "/") ||
+		namespace == "." ||
+		namespace == ".." {
+		return fmt.Errorf("invalid election namespace %q", namespace)
+	}
+	return nil
+}
+
 // leaderBridgeZKPath returns the zk path for Peloton-Aurora Bridge
 func leaderBridgeZKPath(rootPath string, role string) string {
 	return strings.TrimPrefix(path.Join(rootPath, role, "member_0000000001"), "/")
@@ -93,6 +93,54 @@ func TestNewCandidate(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestNewCandidateInvalidNamespace(t *testing.T) {
+	config := ElectionConfig{
+		ZKServers: []string{"1.1.1.1:2181"},
+		Root:      "peloton",
+		Namespace: "prod/jobmgr",
+	}
+
+	_, err := NewCandidate(
+		config,
+		tally.NoopScope,
+		"testrole",
+		&testComponent{host: "testhost", port: "666"},
+	)
+	assert.Error(t, err)
+}
+
+func TestElectionRoot(t *testing.T) {
+	tt := []struct {
+		root      string
+		namespace string
+		expected  string
+		err       bool
+	}{
+		{root: "/peloton", expected: "/peloton"},
+		{root: "/peloton", namespace: "prod", expected: "/peloton/prod"},
+		{root: "/peloton/", namespace: "staging", expected: "/peloton/staging"},
+		{root: "/peloton", namespace: "prod/jobmgr", err: true},
+		{root: "/peloton", namespace: "..", err: true},
+		{root: "/peloton", namespace: ".", err: true},
+	}
+
+	for _, test := range tt {
+		root, err := ElectionConfig{
+			Root:      test.root,
+			Namespace: test.namespace,
+		}.ElectionRoot()
+		if test.err {
+			assert.Error(t, err, test.namespace)
+			continue
+		}
+		assert.NoError(t, err, test.namespace)
+		assert.Equal(t, test.expected, root)
+		assert.Equal(t,
+			strings.TrimPrefix(test.expected, "/")+"/testrole/leader",
+			leaderZkPath(root, "testrole"))
+	}
+}
+
 func TestLeaderElection(t *testing.T) {
 	// the zkservers will be replaced with the mock libkv client, dont worry :)
 	role := "testrole"
@@ -0,0 +1,391 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package leader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/samuel/go-zookeeper/zk"
+	log "github.com/sirupsen/logrus"
+	"github.com/uber-go/tally"
+)
+
+// _leaseRecheckInterval is the period after which a member waiting for
+// the lease of a shard held by another member checks again whether it
+// still wants the lease
+const _leaseRecheckInterval = 10 * time.Second
+
+// ShardLeases fences the shards of a role across its members, such as the
+// job managers sharing the goal state, so that at most one member acts on
+// a shard at any time. The lease of a shard is an ephemeral ZK node,
+// which is removed by ZK when the session of its holder expires.
+type ShardLeases interface {
+	// Hold sets the shards whose lease should be held by the member. The
+	// leases of the other shards are released right away, and the leases
+	// of the given shards are acquired in the background once they are
+	// released by their previous holder.
+	Hold(shards []uint32)
+	// Holds returns true if the lease of the shard is held by the member.
+	// It should be checked before acting on the shard. All the leases are
+	// considered lost as soon as the connection to ZK is lost, which is
+	// before ZK expires the session and grants them to another member.
+	Holds(shard uint32) bool
+	// Stop releases all the leases and closes the connection to ZK.
+	Stop()
+}
+
+// zkConn is the subset of zk.Conn used by the leases, so that it can
+// be faked in tests.
+type zkConn interface {
+	Create(path string, data []byte, flags int32, acl []zk.ACL) (string, error)
+	Get(path string) ([]byte, *zk.Stat, error)
+	ExistsW(path string) (bool, *zk.Stat, <-chan zk.Event, error)
+	Delete(path string, version int32) error
+	Close()
+}
+
+type shardLeases struct {
+	sync.Mutex
+	conn zkConn
+	dir  string
+	// token identifies the leases held by this process, so that they are
+	// recognized again after a reconnection, and not confused with the
+	// leases of a previous process of the same member
+	token    []byte
+	acquired func(shard uint32)
+	metrics  shardLeaseMetrics
+
+	// connected is true while the ZK session is known to be alive
+	connected bool
+	// wanted are the shards whose lease should be held
+	wanted map[uint32]bool
+	// held are the shards whose lease is held
+	held map[uint32]bool
+	// acquiring are the shards whose lease is being acquired
+	acquiring map[uint32]bool
+	stopped   bool
+	stopChan  chan struct{}
+}
+
+// NewShardLeases creates the leases of the shards of a role for the
+// member `name`. The callback is invoked with a shard whenever its lease
+// is acquired. The leases are held under the election root of the config,
+// in the namespace of the config if any.
+func NewShardLeases(
+	cfg ElectionConfig,
+	scope tally.Scope,
+	role string,
+	name string,
+	acquiredCallback func(shard uint32)) (ShardLeases, error) {
+	if role == "" {
+		return nil, errors.New("role of the shard leases cannot be empty")
+	}
+	if name == "" {
+		return nil, fmt.Errorf("invalid member name %q", name)
+	}
+	root, err := cfg.ElectionRoot()
+	if err != nil {
+		return nil, err
+	}
+	conn, events, err := zk.Connect(cfg.ZKServers, znodeEphemeralTimeout)
+	if err != nil {
+		return nil, err
+	}
+	l := newShardLeases(
+		conn,
+		leasesZkPath(root, role),
+		name,
+		newShardLeaseMetrics(namespaceScope(scope, cfg.Namespace), role),
+		acquiredCallback,
+	)
+	go l.watchSession(events)
+	return l, nil
+}
+
+func newShardLeases(
+	conn zkConn,
+	dir string,
+	name string,
+	metrics shardLeaseMetrics,
+	acquiredCallback func(shard uint32)) *shardLeases {
+	return &shardLeases{
+		conn:      conn,
+		dir:       dir,
+		token:     []byte(name + " " + uuid.New()),
+		acquired:  acquiredCallback,
+		metrics:   metrics,
+		wanted:    make(map[uint32]bool),
+		held:      make(map[uint32]bool),
+		acquiring: make(map[uint32]bool),
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Hold releases the leases not wanted anymore, and acquires the others
+func (l *shardLeases) Hold(shards []uint32) {
+	wanted := make(map[uint32]bool)
+	for _, shard := range shards {
+		wanted[shard] = true
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	if l.stopped {
+		return
+	}
+	l.wanted = wanted
+	// the leases are released while locked, so that a lease released is
+	// not acquired again before its node is removed
+	for shard := range l.held {
+		if !wanted[shard] {
+			delete(l.held, shard)
+			l.release(shard)
+		}
+	}
+	l.metrics.Held.Update(float64(len(l.held)))
+	if l.connected {
+		l.acquireWanted()
+	}
+}
+
+// Holds returns true if the lease of the shard is held
+func (l *shardLeases) Holds(shard uint32) bool {
+	l.Lock()
+	defer l.Unlock()
+	return l.held[shard]
+}
+
+// Stop releases the leases held and closes the connection
+func (l *shardLeases) Stop() {
+	l.Lock()
+	if l.stopped {
+		l.Unlock()
+		return
+	}
+	l.stopped = true
+	l.connected = false
+	l.wanted = make(map[uint32]bool)
+	held := l.held
+	l.held = make(map[uint32]bool)
+	l.metrics.Held.Update(0)
+	close(l.stopChan)
+	l.Unlock()
+
+	for shard := range held {
+		l.release(shard)
+	}
+	l.conn.Close()
+}
+
+// watchSession handles the changes of state of the ZK session. The
+// leases are lost as soon as the client is disconnected, and are
+// acquired again once it has a session, which may still be the same.
+func (l *shardLeases) watchSession(events <-chan zk.Event) {
+	for event := range events {
+		if event.Type != zk.EventSession {
+			continue
+		}
+		switch event.State {
+		case zk.StateHasSession:
+			l.connect()
+		case zk.StateDisconnected, zk.StateExpired:
+			l.disconnect()
+		}
+	}
+}
+
+// connect acquires the leases wanted once the client has a session
+func (l *shardLeases) connect() {
+	l.Lock()
+	defer l.Unlock()
+	if l.stopped {
+		return
+	}
+	l.connected = true
+	l.acquireWanted()
+}
+
+// disconnect drops all the leases held, since ZK may grant them to
+// another member once the session expires
+func (l *shardLeases) disconnect() {
+	l.Lock()
+	defer l.Unlock()
+	if len(l.held) != 0 {
+		l.metrics.Lost.Inc(int64(len(l.held)))
+		log.WithField("leases", len(l.held)).
+			Warn("Shard leases lost on disconnection from ZK")
+	}
+	l.connected = false
+	l.held = make(map[uint32]bool)
+	l.metrics.Held.Update(0)
+}
+
+// acquireWanted starts acquiring the leases wanted which are neither
+// held nor being acquired. It must be called with the lock held.
+func (l *shardLeases) acquireWanted() {
+	for shard := range l.wanted {
+		if l.held[shard] || l.acquiring[shard] {
+			continue
+		}
+		l.acquiring[shard] = true
+		go l.acquire(shard)
+	}
+}
+
+// acquire creates the ephemeral node of the lease of a shard, waiting
+// for it to be removed if it is held by another member, until the lease
+// is acquired or not wanted anymore
+func (l *shardLeases) acquire(shard uint32) {
+	key := l.key(shard)
+	for l.wants(shard) {
+		_, err := l.conn.Create(
+			key, l.token, zk.FlagEphemeral, zk.WorldACL(zk.PermAll))
+		if err == zk.ErrNoNode {
+			err = l.createDir()
+			if err == nil {
+				continue
+			}
+		}
+		if err == nil {
+			l.grant(shard)
+			return
+		}
+		if err != zk.ErrNodeExists {
+			l.retry(shard, err)
+			continue
+		}
+
+		// the node of the lease is left by this process if the session
+		// survived a disconnection
+		data, _, err := l.conn.Get(key)
+		if err == nil && bytes.Equal(data, l.token) {
+			l.grant(shard)
+			return
+		}
+		if err != nil && err != zk.ErrNoNode {
+			l.retry(shard, err)
+			continue
+		}
+
+		exists, _, eventCh, err := l.conn.ExistsW(key)
+		if err != nil {
+			l.retry(shard, err)
+			continue
+		}
+		if !exists {
+			continue
+		}
+		select {
+		case <-eventCh:
+		case <-l.stopChan:
+		case <-time.After(_leaseRecheckInterval):
+		}
+	}
+}
+
+// wants returns true if the lease of the shard should still be acquired,
+// and otherwise records that it is not being acquired anymore
+func (l *shardLeases) wants(shard uint32) bool {
+	l.Lock()
+	defer l.Unlock()
+	if l.connected && l.wanted[shard] && !l.held[shard] {
+		return true
+	}
+	delete(l.acquiring, shard)
+	return false
+}
+
+// grant records the lease of a shard as held, or releases it if it is
+// not wanted anymore, and notifies the callback
+func (l *shardLeases) grant(shard uint32) {
+	l.Lock()
+	delete(l.acquiring, shard)
+	if !l.connected || !l.wanted[shard] {
+		l.release(shard)
+		l.Unlock()
+		return
+	}
+	l.held[shard] = true
+	l.metrics.Acquired.Inc(1)
+	l.metrics.Held.Update(float64(len(l.held)))
+	l.Unlock()
+
+	log.WithField("shard", shard).Debug("Shard lease acquired")
+	if l.acquired != nil {
+		l.acquired(shard)
+	}
+}
+
+// release removes the ephemeral node of the lease of a shard, so that
+// another member can acquire it
+func (l *shardLeases) release(shard uint32) {
+	err := l.conn.Delete(l.key(shard), -1)
+	if err != nil && err != zk.ErrNoNode {
+		// ZK removes the node when the session expires
+		log.WithError(err).
+			WithField("shard", shard).
+			Warn("Failed to release shard lease")
+		l.metrics.Error.Inc(1)
+		return
+	}
+	l.metrics.Released.Inc(1)
+	log.WithField("shard", shard).Debug("Shard lease released")
+}
+
+// retry waits before trying to acquire the lease of a shard again after
+// an error
+func (l *shardLeases) retry(shard uint32, err error) {
+	log.WithError(err).
+		WithField("shard", shard).
+		Error("Failed to acquire shard lease; retrying")
+	l.metrics.Error.Inc(1)
+	select {
+	case <-l.stopChan:
+	case <-time.After(zkConnErrRetry):
+	}
+}
+
+// createDir creates the persistent nodes of the path of the leases
+func (l *shardLeases) createDir() error {
+	p := ""
+	for _, part := range strings.Split(strings.Trim(l.dir, "/"), "/") {
+		p += "/" + part
+		_, err := l.conn.Create(p, nil, 0, zk.WorldACL(zk.PermAll))
+		if err != nil && err != zk.ErrNodeExists {
+			return err
+		}
+	}
+	return nil
+}
+
+// key is the path of the ephemeral node of the lease of a shard
+func (l *shardLeases) key(shard uint32) string {
+	return path.Join(l.dir, strconv.FormatUint(uint64(shard), 10))
+}
+
+// leasesZkPath returns the zk path under which the leases of the shards
+// of a role are held
+func leasesZkPath(rootPath string, role string) string {
+	// unlike libkv, the ZK client requires a leading /
+	return path.Join("/", rootPath, role, "shards")
+}
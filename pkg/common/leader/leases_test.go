@@ -0,0 +1,181 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package leader
+
+import (
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-zookeeper/zk"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally"
+)
+
+// fakeZK is an in-memory ZK tree shared by the connections of the test
+type fakeZK struct {
+	sync.Mutex
+	nodes   map[string][]byte
+	watches map[string][]chan zk.Event
+}
+
+func newFakeZK() *fakeZK {
+	return &fakeZK{
+		nodes:   map[string][]byte{"/": nil},
+		watches: make(map[string][]chan zk.Event),
+	}
+}
+
+// fakeConn is a connection to the fake ZK tree
+type fakeConn struct {
+	zk *fakeZK
+}
+
+func (c *fakeConn) Create(
+	p string,
+	data []byte,
+	flags int32,
+	acl []zk.ACL) (string, error) {
+	c.zk.Lock()
+	defer c.zk.Unlock()
+	if _, ok := c.zk.nodes[path.Dir(p)]; !ok {
+		return "", zk.ErrNoNode
+	}
+	if _, ok := c.zk.nodes[p]; ok {
+		return "", zk.ErrNodeExists
+	}
+	c.zk.nodes[p] = data
+	return p, nil
+}
+
+func (c *fakeConn) Get(p string) ([]byte, *zk.Stat, error) {
+	c.zk.Lock()
+	defer c.zk.Unlock()
+	data, ok := c.zk.nodes[p]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	return data, &zk.Stat{}, nil
+}
+
+func (c *fakeConn) ExistsW(p string) (bool, *zk.Stat, <-chan zk.Event, error) {
+	c.zk.Lock()
+	defer c.zk.Unlock()
+	ch := make(chan zk.Event, 1)
+	c.zk.watches[p] = append(c.zk.watches[p], ch)
+	_, ok := c.zk.nodes[p]
+	return ok, &zk.Stat{}, ch, nil
+}
+
+func (c *fakeConn) Delete(p string, version int32) error {
+	c.zk.Lock()
+	defer c.zk.Unlock()
+	if _, ok := c.zk.nodes[p]; !ok {
+		return zk.ErrNoNode
+	}
+	delete(c.zk.nodes, p)
+	for _, ch := range c.zk.watches[p] {
+		ch <- zk.Event{Type: zk.EventNodeDeleted, Path: p}
+	}
+	delete(c.zk.watches, p)
+	return nil
+}
+
+func (c *fakeConn) Close() {}
+
+// waitAcquired waits for the leases of the given shards to be acquired
+func waitAcquired(t *testing.T, acquired chan uint32, shards ...uint32) {
+	var got []uint32
+	for range shards {
+		select {
+		case shard := <-acquired:
+			got = append(got, shard)
+		case <-time.After(5 * time.Second):
+			assert.FailNow(t, "shard leases not acquired", "%v", shards)
+		}
+	}
+	assert.ElementsMatch(t, shards, got)
+}
+
+func TestShardLeases(t *testing.T) {
+	dir := leasesZkPath("peloton/prod", "testrole")
+	assert.Equal(t, "/peloton/prod/testrole/shards", dir)
+
+	tree := newFakeZK()
+	newLeases := func(name string) (*shardLeases, chan uint32) {
+		acquired := make(chan uint32, 10)
+		l := newShardLeases(
+			&fakeConn{zk: tree},
+			dir,
+			name,
+			newShardLeaseMetrics(tally.NoopScope, "testrole"),
+			func(shard uint32) {
+				acquired <- shard
+			},
+		)
+		return l, acquired
+	}
+	l1, acquired1 := newLeases("m1")
+	l2, acquired2 := newLeases("m2")
+
+	// nothing is acquired until connected
+	l1.Hold([]uint32{1, 2})
+	assert.False(t, l1.Holds(1))
+	l1.connect()
+	waitAcquired(t, acquired1, 1, 2)
+	assert.True(t, l1.Holds(1))
+	assert.True(t, l1.Holds(2))
+
+	// the lease of a shard held by another member is not acquired
+	l2.connect()
+	l2.Hold([]uint32{2, 3})
+	waitAcquired(t, acquired2, 3)
+	assert.False(t, l2.Holds(2))
+
+	// the lease released is acquired by the member waiting for it
+	l1.Hold([]uint32{1})
+	assert.False(t, l1.Holds(2))
+	waitAcquired(t, acquired2, 2)
+	assert.True(t, l2.Holds(2))
+	assert.True(t, l1.Holds(1))
+
+	// the leases are lost on disconnection, and acquired again once the
+	// session is known to be alive
+	l2.disconnect()
+	assert.False(t, l2.Holds(2))
+	assert.False(t, l2.Holds(3))
+	l2.connect()
+	waitAcquired(t, acquired2, 2, 3)
+
+	// the leases are released when stopped
+	l1.Stop()
+	assert.False(t, l1.Holds(1))
+	l2.Hold([]uint32{1, 2, 3})
+	waitAcquired(t, acquired2, 1)
+	l2.Stop()
+	_, _, err := (&fakeConn{zk: tree}).Get(dir + "/1")
+	assert.Equal(t, zk.ErrNoNode, err)
+}
+
+func TestNewShardLeasesInvalid(t *testing.T) {
+	_, err := NewShardLeases(
+		ElectionConfig{}, tally.NoopScope, "", "m1", nil)
+	assert.Error(t, err)
+
+	_, err = NewShardLeases(
+		ElectionConfig{}, tally.NoopScope, "testrole", "", nil)
+	assert.Error(t, err)
+}
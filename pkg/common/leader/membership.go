@@ -0,0 +1,295 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package leader
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/zookeeper"
+	log "github.com/sirupsen/logrus"
+	"github.com/uber-go/tally"
+)
+
+// Membership registers a process as a member of a role, such as the job
+// managers sharing the goal state, and watches the members of the role.
+// Unlike an election, all the members are active.
+type Membership interface {
+	// Join registers the member, and starts watching the members of the
+	// role. The callback is invoked with the sorted names of the members
+	// whenever they change.
+	Join() error
+	// Leave unregisters the member and stops watching the members.
+	Leave()
+	// Members returns the sorted names of the members last observed,
+	// which is empty if the member has not joined.
+	Members() []string
+}
+
+type membership struct {
+	sync.Mutex
+	client   store.Store
+	dir      string
+	name     string
+	value    []byte
+	callback func([]string)
+	metrics  membershipMetrics
+	members  []string
+	running  bool
+	stopChan chan struct{}
+	doneChan chan struct{}
+}
+
+// NewMembership creates a new Membership registering the member `name`,
+// which must be unique among the members of the role, with `value` as
+// its data, such as its ID. The membership is held under the election
+// root of the config, in the namespace of the config if any.
+func NewMembership(
+	cfg ElectionConfig,
+	scope tally.Scope,
+	role string,
+	name string,
+	value string,
+	membersChangedCallback func([]string)) (Membership, error) {
+	if role == "" {
+		return nil, errors.New("role of the membership cannot be empty")
+	}
+	if name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid member name %q", name)
+	}
+	root, err := cfg.ElectionRoot()
+	if err != nil {
+		return nil, err
+	}
+	client, err := zookeeper.New(
+		cfg.ZKServers,
+		&store.Config{ConnectionTimeout: znodeEphemeralTimeout},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return newMembership(
+		client,
+		membersZkPath(root, role),
+		name,
+		value,
+		newMembershipMetrics(namespaceScope(scope, cfg.Namespace), role),
+		membersChangedCallback,
+	), nil
+}
+
+func newMembership(
+	client store.Store,
+	dir string,
+	name string,
+	value string,
+	metrics membershipMetrics,
+	membersChangedCallback func([]string)) *membership {
+	return &membership{
+		client:   client,
+		dir:      dir,
+		name:     name,
+		value:    []byte(value),
+		callback: membersChangedCallback,
+		metrics:  metrics,
+	}
+}
+
+// Join registers the member, and watches the members in the background
+func (m *membership) Join() error {
+	m.Lock()
+	defer m.Unlock()
+	if m.running {
+		return errors.New("Already a member, cannot Join again")
+	}
+	m.running = true
+	m.stopChan = make(chan struct{})
+	m.doneChan = make(chan struct{})
+	m.metrics.Join.Inc(1)
+
+	log.WithFields(log.Fields{"member": m.name, "path": m.dir}).
+		Info("Joining membership")
+
+	go m.watch(m.stopChan, m.doneChan)
+	return nil
+}
+
+// Leave unregisters the member, once it has stopped watching the members
+func (m *membership) Leave() {
+	m.Lock()
+	if !m.running {
+		m.Unlock()
+		return
+	}
+	m.running = false
+	m.members = nil
+	close(m.stopChan)
+	doneChan := m.doneChan
+	m.Unlock()
+
+	<-doneChan
+	if err := m.client.Delete(m.key()); err != nil {
+		log.WithError(err).
+			WithField("member", m.name).
+			Warn("Failed to unregister member")
+	}
+	m.metrics.Leave.Inc(1)
+	m.metrics.Members.Update(0)
+	log.WithField("member", m.name).Info("Left membership")
+}
+
+// Members returns the sorted names of the members last observed
+func (m *membership) Members() []string {
+	m.Lock()
+	defer m.Unlock()
+	return append([]string(nil), m.members...)
+}
+
+// key is the path of the ephemeral node registering the member
+func (m *membership) key() string {
+	return path.Join(m.dir, m.name)
+}
+
+// register creates the ephemeral node of the member, which is removed
+// by ZK when the session of the client expires
+func (m *membership) register() error {
+	return m.client.Put(
+		m.key(),
+		m.value,
+		&store.WriteOptions{TTL: znodeEphemeralTimeout},
+	)
+}
+
+// watch repeatedly calls waitForEvents(), and retries when errors are
+// encountered, until stopped
+func (m *membership) watch(stopChan chan struct{}, doneChan chan struct{}) {
+	defer close(doneChan)
+	for {
+		err := m.waitForEvents(stopChan)
+		select {
+		case <-stopChan:
+			return
+		default:
+		}
+		log.WithFields(log.Fields{
+			"member": m.name,
+			"error":  err,
+		}).Error("Failure watching members; retrying")
+		m.metrics.Error.Inc(1)
+
+		select {
+		case <-stopChan:
+			return
+		case <-time.After(zkConnErrRetry):
+		}
+	}
+}
+
+// waitForEvents registers the member and handles the changes of the
+// members until stopped or an error occurs. The member is registered
+// again if it is found missing, such as after its session expired.
+func (m *membership) waitForEvents(stopChan chan struct{}) error {
+	if err := m.register(); err != nil {
+		return err
+	}
+
+	eventCh, err := m.client.WatchTree(m.dir, stopChan)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stopChan:
+			return nil
+		case pairs, ok := <-eventCh:
+			if !ok {
+				return errors.New("watch of members closed")
+			}
+
+			var members []string
+			registered := false
+			for _, pair := range pairs {
+				name := path.Base(pair.Key)
+				registered = registered || name == m.name
+				members = append(members, name)
+			}
+			if !registered {
+				log.WithField("member", m.name).
+					Warn("Member is not registered, registering again")
+				if err := m.register(); err != nil {
+					return err
+				}
+				// the registration triggers another change
+				continue
+			}
+			m.update(members, stopChan)
+		}
+	}
+}
+
+// update records the members, and invokes the callback if they changed
+func (m *membership) update(members []string, stopChan chan struct{}) {
+	sort.Strings(members)
+
+	m.Lock()
+	// do not notify changes observed after leaving
+	select {
+	case <-stopChan:
+		m.Unlock()
+		return
+	default:
+	}
+	changed := !equalMembers(m.members, members)
+	m.members = members
+	m.Unlock()
+
+	if !changed {
+		return
+	}
+	log.WithFields(log.Fields{
+		"member":  m.name,
+		"members": members,
+	}).Info("Members changed")
+	m.metrics.MembersChanged.Inc(1)
+	m.metrics.Members.Update(float64(len(members)))
+	if m.callback != nil {
+		m.callback(append([]string(nil), members...))
+	}
+}
+
+// equalMembers returns true if both sorted lists have the same members
+func equalMembers(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// membersZkPath returns the zk path under which the members of a role
+// register
+func membersZkPath(rootPath string, role string) string {
+	return strings.TrimPrefix(path.Join(rootPath, role, "members"), "/")
+}
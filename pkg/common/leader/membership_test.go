@@ -0,0 +1,103 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package leader
+
+import (
+	"testing"
+
+	"github.com/docker/libkv/store"
+	libkvmock "github.com/docker/libkv/store/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/uber-go/tally"
+)
+
+func TestMembership(t *testing.T) {
+	dir := membersZkPath("/peloton/prod", "testrole")
+	assert.Equal(t, "peloton/prod/testrole/members", dir)
+
+	kv, err := libkvmock.New([]string{}, nil)
+	assert.NoError(t, err)
+	mockStore := kv.(*libkvmock.Mock)
+
+	kvCh := make(chan []*store.KVPair)
+	var mockKVCh <-chan []*store.KVPair = kvCh
+	registered := make(chan struct{}, 10)
+	mockStore.On("Put", dir+"/m1", []byte("id1"), mock.Anything).
+		Return(nil).
+		Run(func(mock.Arguments) { registered <- struct{}{} })
+	mockStore.On("WatchTree", dir, mock.Anything).Return(mockKVCh, nil)
+	mockStore.On("Delete", dir+"/m1").Return(nil)
+
+	changes := make(chan []string)
+	m := newMembership(
+		kv,
+		dir,
+		"m1",
+		"id1",
+		newMembershipMetrics(tally.NoopScope, "testrole"),
+		func(members []string) {
+			changes <- members
+		},
+	)
+
+	assert.Empty(t, m.Members())
+	assert.NoError(t, m.Join())
+	assert.Error(t, m.Join())
+	<-registered
+
+	pair := func(name string) *store.KVPair {
+		return &store.KVPair{Key: dir + "/" + name}
+	}
+
+	kvCh <- []*store.KVPair{pair("m2"), pair("m1")}
+	assert.Equal(t, []string{"m1", "m2"}, <-changes)
+
+	// the same members are not notified again
+	kvCh <- []*store.KVPair{pair("m1"), pair("m2")}
+	kvCh <- []*store.KVPair{pair("m1")}
+	assert.Equal(t, []string{"m1"}, <-changes)
+	assert.Equal(t, []string{"m1"}, m.Members())
+
+	// the member is registered again if missing
+	kvCh <- []*store.KVPair{pair("m2")}
+	<-registered
+	kvCh <- []*store.KVPair{pair("m1"), pair("m3")}
+	assert.Equal(t, []string{"m1", "m3"}, <-changes)
+
+	m.Leave()
+	assert.Empty(t, m.Members())
+	mockStore.AssertCalled(t, "Delete", dir+"/m1")
+
+	// leaving again is a no-op
+	m.Leave()
+}
+
+func TestNewMembershipInvalid(t *testing.T) {
+	cfg := ElectionConfig{
+		ZKServers: []string{"1.1.1.1:2181"},
+		Root:      "/peloton",
+	}
+
+	_, err := NewMembership(cfg, tally.NoopScope, "", "m1", "id1", nil)
+	assert.Error(t, err)
+	_, err = NewMembership(cfg, tally.NoopScope, "testrole", "", "id1", nil)
+	assert.Error(t, err)
+	_, err = NewMembership(cfg, tally.NoopScope, "testrole", "a/b", "id1", nil)
+	assert.Error(t, err)
+
+	cfg.Namespace = ".."
+	_, err = NewMembership(cfg, tally.NoopScope, "testrole", "m1", "id1", nil)
+	assert.Error(t, err)
+}
@@ -64,3 +64,51 @@ func newObserverMetrics(scope tally.Scope, role string) observerMetrics {
 		Error:         s.Counter("error"),
 	}
 }
+
+type membershipMetrics struct {
+	Join           tally.Counter
+	Leave          tally.Counter
+	MembersChanged tally.Counter
+	Members        tally.Gauge
+	Error          tally.Counter
+}
+
+func newMembershipMetrics(scope tally.Scope, role string) membershipMetrics {
+	s := scope.SubScope("membership").Tagged(map[string]string{"role": role})
+
+	return membershipMetrics{
+		Join:           s.Counter("join"),
+		Leave:          s.Counter("leave"),
+		MembersChanged: s.Counter("members_changed"),
+		Members:        s.Gauge("members"),
+		Error:          s.Counter("error"),
+	}
+}
+
+type shardLeaseMetrics struct {
+	Acquired tally.Counter
+	Released tally.Counter
+	Lost     tally.Counter
+	Held     tally.Gauge
+	Error    tally.Counter
+}
+
+func newShardLeaseMetrics(scope tally.Scope, role string) shardLeaseMetrics {
+	s := scope.SubScope("shard_leases").Tagged(map[string]string{"role": role})
+
+	return shardLeaseMetrics{
+		Acquired: s.Counter("acquired"),
+		Released: s.Counter("released"),
+		Lost:     s.Counter("lost"),
+		Held:     s.Gauge("held"),
+		Error:    s.Counter("error"),
+	}
+}
+
+// namespaceScope tags the scope with the election namespace, if any
+func namespaceScope(scope tally.Scope, namespace string) tally.Scope {
+	if namespace == "" {
+		return scope
+	}
+	return scope.Tagged(map[string]string{"namespace": namespace})
+}
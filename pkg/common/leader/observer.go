@@ -48,19 +48,39 @@ type observer struct {
 // NewObserver creates a new Observer that will watch and react to new leadership events for leaders in
 // a given `role`, and will call newLeaderCallback whenever leadership changes
 func NewObserver(cfg ElectionConfig, scope tally.Scope, role string, newLeaderCallback func(string) error) (Observer, error) {
-	log.WithFields(log.Fields{"role": role}).Debug("Creating new observer of election")
+	log.WithFields(log.Fields{"role": role, "namespace": cfg.Namespace}).Debug("Creating new observer of election")
+	root, err := cfg.ElectionRoot()
+	if err != nil {
+		return nil, err
+	}
 	client, err := zookeeper.New(cfg.ZKServers, &store.Config{ConnectionTimeout: zkConnErrRetry})
 	if err != nil {
 		return nil, err
 	}
-	obs := observer{
+	return newObserver(
+		client,
+		leaderZkPath(root, role),
+		role,
+		newObserverMetrics(namespaceScope(scope, cfg.Namespace), role),
+		newLeaderCallback,
+	), nil
+}
+
+// newObserver creates an observer following the election at `key` using
+// the given store client, which may be shared with other observers
+func newObserver(
+	client store.Store,
+	key string,
+	role string,
+	metrics observerMetrics,
+	newLeaderCallback func(string) error) *observer {
+	return &observer{
 		role:     role,
-		metrics:  newObserverMetrics(scope, role),
+		metrics:  metrics,
 		callback: newLeaderCallback,
-		follower: leadership.NewFollower(client, leaderZkPath(cfg.Root, role)),
+		follower: leadership.NewFollower(client, key),
 		stopChan: make(chan struct{}),
 	}
-	return &obs, nil
 }
 
 // Start begins observing the election results. When new leaders are detected, the callback will be invoked.
@@ -120,7 +140,10 @@ func (o *observer) waitForEvent() error {
 			log.WithFields(log.Fields{"role": o.role, "leader": leader}).Info("New leader detected")
 			o.metrics.LeaderChanged.Inc(1)
 			o.leader = leader
-			err := o.callback(leader)
+			var err error
+			if o.callback != nil {
+				err = o.callback(leader)
+			}
 			o.Unlock()
 			if err != nil {
 				log.WithFields(log.Fields{"role": o.role, "error": err}).Error("NewLeaderCallback failed")
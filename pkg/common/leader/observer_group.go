@@ -0,0 +1,190 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package leader
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/zookeeper"
+	log "github.com/sirupsen/logrus"
+	"github.com/uber-go/tally"
+)
+
+// ObservedLeader is the leader observed for a role in a namespace
+type ObservedLeader struct {
+	Namespace string
+	Role      string
+	// Leader is the current leader ID, empty if none has been observed
+	Leader string
+}
+
+// ObserverGroup observes the elections of several roles, possibly across
+// several namespaces, over a single ZK connection
+type ObserverGroup interface {
+	// Add creates an observer for the election of `role` in `namespace`,
+	// calling newLeaderCallback (if not nil) whenever leadership changes.
+	// The observer is started right away if the group is running.
+	Add(namespace string, role string, newLeaderCallback func(string) error) (Observer, error)
+	// Leaders returns the current leader of every observed election,
+	// sorted by namespace and role
+	Leaders() []ObservedLeader
+	// Start starts observing all elections added to the group
+	Start() error
+	// Stop stops all observers and closes the shared connection. A
+	// stopped group cannot be started again.
+	Stop()
+}
+
+type observerKey struct {
+	namespace string
+	role      string
+}
+
+type observerGroup struct {
+	sync.Mutex
+	client    store.Store
+	root      string
+	scope     tally.Scope
+	observers map[observerKey]*observer
+	running   bool
+	stopped   bool
+}
+
+// NewObserverGroup creates a new ObserverGroup sharing one ZK connection
+// for all its observers. Namespaces are given per election to Add, so
+// cfg.Namespace is ignored.
+func NewObserverGroup(cfg ElectionConfig, scope tally.Scope) (ObserverGroup, error) {
+	client, err := zookeeper.New(cfg.ZKServers, &store.Config{ConnectionTimeout: zkConnErrRetry})
+	if err != nil {
+		return nil, err
+	}
+	return newObserverGroup(client, cfg.Root, scope), nil
+}
+
+func newObserverGroup(client store.Store, root string, scope tally.Scope) *observerGroup {
+	return &observerGroup{
+		client:    client,
+		root:      root,
+		scope:     scope,
+		observers: make(map[observerKey]*observer),
+	}
+}
+
+// Add creates an observer for the election of a role in a namespace
+func (g *observerGroup) Add(
+	namespace string,
+	role string,
+	newLeaderCallback func(string) error) (Observer, error) {
+	if role == "" {
+		return nil, errors.New("role to observe cannot be empty")
+	}
+	if err := validateNamespace(namespace); err != nil {
+		return nil, err
+	}
+
+	g.Lock()
+	defer g.Unlock()
+	if g.stopped {
+		return nil, errors.New("observer group is stopped")
+	}
+	key := observerKey{namespace: namespace, role: role}
+	if _, ok := g.observers[key]; ok {
+		return nil, fmt.Errorf(
+			"already observing role %s in namespace %q", role, namespace)
+	}
+
+	o := newObserver(
+		g.client,
+		leaderZkPath(path.Join(g.root, namespace), role),
+		role,
+		newObserverMetrics(namespaceScope(g.scope, namespace), role),
+		newLeaderCallback,
+	)
+	if g.running {
+		if err := o.Start(); err != nil {
+			return nil, err
+		}
+	}
+	g.observers[key] = o
+	return o, nil
+}
+
+// Leaders returns the current leader of every observed election
+func (g *observerGroup) Leaders() []ObservedLeader {
+	g.Lock()
+	defer g.Unlock()
+	leaders := make([]ObservedLeader, 0, len(g.observers))
+	for key, o := range g.observers {
+		// the leader is left empty if the observer is not running
+		leader, _ := o.CurrentLeader()
+		leaders = append(leaders, ObservedLeader{
+			Namespace: key.namespace,
+			Role:      key.role,
+			Leader:    leader,
+		})
+	}
+	sort.Slice(leaders, func(i, j int) bool {
+		if leaders[i].Namespace != leaders[j].Namespace {
+			return leaders[i].Namespace < leaders[j].Namespace
+		}
+		return leaders[i].Role < leaders[j].Role
+	})
+	return leaders
+}
+
+// Start starts observing all elections added to the group
+func (g *observerGroup) Start() error {
+	g.Lock()
+	defer g.Unlock()
+	if g.stopped {
+		return errors.New("observer group is stopped")
+	}
+	if g.running {
+		return errors.New("Already observing elections, cannot Start again")
+	}
+	for key, o := range g.observers {
+		if err := o.Start(); err != nil {
+			log.WithFields(log.Fields{
+				"role":      key.role,
+				"namespace": key.namespace,
+				"error":     err,
+			}).Error("Failed to start observer")
+			return err
+		}
+	}
+	g.running = true
+	log.WithField("observers", len(g.observers)).
+		Info("Watching for leadership changes of observer group")
+	return nil
+}
+
+// Stop stops all observers and closes the shared connection
+func (g *observerGroup) Stop() {
+	g.Lock()
+	defer g.Unlock()
+	if g.stopped {
+		return
+	}
+	for _, o := range g.observers {
+		o.Stop()
+	}
+	g.running = false
+	g.stopped = true
+	g.client.Close()
+}
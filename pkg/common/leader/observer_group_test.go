@@ -0,0 +1,136 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package leader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/libkv/store"
+	libkvmock "github.com/docker/libkv/store/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/uber-go/tally"
+)
+
+func TestObserverGroup(t *testing.T) {
+	kv, err := libkvmock.New([]string{}, nil)
+	assert.NoError(t, err)
+	mockStore := kv.(*libkvmock.Mock)
+
+	// each namespace watches its own election path over the shared client
+	keys := map[string]string{
+		"staging": "peloton/staging/testrole/leader",
+		"prod":    "peloton/prod/testrole/leader",
+	}
+	kvChs := make(map[string]chan *store.KVPair)
+	for namespace, key := range keys {
+		kvCh := make(chan *store.KVPair)
+		var mockKVCh <-chan *store.KVPair = kvCh
+		mockStore.On("Watch", key, mock.Anything).Return(mockKVCh, nil)
+		kvChs[namespace] = kvCh
+	}
+
+	g := newObserverGroup(kv, "/peloton", tally.NoopScope)
+
+	events := make(chan string)
+	for namespace := range keys {
+		ns := namespace
+		_, err := g.Add(ns, "testrole", func(leader string) error {
+			events <- ns + ":" + leader
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+
+	// leaders are empty until the group is started
+	assert.Equal(t, []ObservedLeader{
+		{Namespace: "prod", Role: "testrole"},
+		{Namespace: "staging", Role: "testrole"},
+	}, g.Leaders())
+
+	assert.NoError(t, g.Start())
+	assert.Error(t, g.Start())
+
+	kvChs["prod"] <- &store.KVPair{Value: []byte("prodleader")}
+	assert.Equal(t, "prod:prodleader", <-events)
+	kvChs["staging"] <- &store.KVPair{Value: []byte("stagingleader")}
+	assert.Equal(t, "staging:stagingleader", <-events)
+
+	assert.Equal(t, []ObservedLeader{
+		{Namespace: "prod", Role: "testrole", Leader: "prodleader"},
+		{Namespace: "staging", Role: "testrole", Leader: "stagingleader"},
+	}, g.Leaders())
+
+	g.Stop()
+	assert.Error(t, g.Start())
+	_, err = g.Add("dev", "testrole", nil)
+	assert.Error(t, err)
+}
+
+func TestObserverGroupAddWhileRunning(t *testing.T) {
+	kv, err := libkvmock.New([]string{}, nil)
+	assert.NoError(t, err)
+	mockStore := kv.(*libkvmock.Mock)
+
+	kvCh := make(chan *store.KVPair)
+	var mockKVCh <-chan *store.KVPair = kvCh
+	mockStore.On("Watch", "peloton/testrole/leader", mock.Anything).
+		Return(mockKVCh, nil)
+
+	g := newObserverGroup(kv, "/peloton", tally.NoopScope)
+	assert.NoError(t, g.Start())
+	defer g.Stop()
+
+	// a nil callback is allowed when only polling the leaders
+	o, err := g.Add("", "testrole", nil)
+	assert.NoError(t, err)
+
+	kvCh <- &store.KVPair{Value: []byte("leader1")}
+	kvCh <- &store.KVPair{Value: []byte("leader2")}
+
+	// without a callback there is nothing to wait on, so poll the leader
+	var leader string
+	for i := 0; i < 100 && leader != "leader2"; i++ {
+		time.Sleep(10 * time.Millisecond)
+		leader, err = o.CurrentLeader()
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, "leader2", leader)
+}
+
+func TestObserverGroupAddInvalid(t *testing.T) {
+	kv, err := libkvmock.New([]string{}, nil)
+	assert.NoError(t, err)
+	mockStore := kv.(*libkvmock.Mock)
+	mockStore.On("Watch", mock.Anything, mock.Anything).
+		Return(make(<-chan *store.KVPair), nil)
+
+	g := newObserverGroup(kv, "/peloton", tally.NoopScope)
+
+	_, err = g.Add("prod", "", nil)
+	assert.Error(t, err)
+	_, err = g.Add("prod/jobmgr", "testrole", nil)
+	assert.Error(t, err)
+	_, err = g.Add("..", "testrole", nil)
+	assert.Error(t, err)
+
+	_, err = g.Add("prod", "testrole", nil)
+	assert.NoError(t, err)
+	_, err = g.Add("prod", "testrole", nil)
+	assert.Error(t, err)
+	_, err = g.Add("staging", "testrole", nil)
+	assert.NoError(t, err)
+	assert.Len(t, g.Leaders(), 2)
+}
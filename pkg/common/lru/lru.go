@@ -0,0 +1,134 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lru
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache defines the interface of a cache of a bounded number of entries,
+// which expire after a TTL. The least recently used entry is evicted when
+// an entry is added to a full cache.
+type Cache interface {
+	// Get returns the value of 'key', and false if the key is not in the
+	// cache or its entry expired
+	Get(key string) (interface{}, bool)
+	// Add adds or replaces the value of 'key'
+	Add(key string, value interface{})
+	// Remove removes 'key' from the cache
+	Remove(key string)
+	// Len returns the number of entries in the cache, including the
+	// expired entries not evicted yet
+	Len() int
+}
+
+// entry is an entry of the cache
+type entry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+// cache implements Cache interface. It is thread safe
+type cache struct {
+	sync.Mutex
+
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	// the entries, most recently used first
+	order *list.List
+	now   func() time.Time
+}
+
+// New creates a cache of at most 'size' entries, which expire 'ttl' after
+// they were added. The entries do not expire if 'ttl' is 0.
+func New(size int, ttl time.Duration) Cache {
+	return &cache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// Get returns the value of 'key', and false if the key is not in the
+// cache or its entry expired
+func (c *cache) Get(key string) (interface{}, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := elem.Value.(*entry)
+	if c.ttl > 0 && !c.now().Before(e.expiresAt) {
+		c.removeElement(elem)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return e.value, true
+}
+
+// Add adds or replaces the value of 'key'
+func (c *cache) Add(key string, value interface{}) {
+	c.Lock()
+	defer c.Unlock()
+
+	expiresAt := c.now().Add(c.ttl)
+	if elem, ok := c.entries[key]; ok {
+		e := elem.Value.(*entry)
+		e.value = value
+		e.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry{
+		key:       key,
+		value:     value,
+		expiresAt: expiresAt,
+	})
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// Remove removes 'key' from the cache
+func (c *cache) Remove(key string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// Len returns the number of entries in the cache
+func (c *cache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *cache) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*entry).key)
+}
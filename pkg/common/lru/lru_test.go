@@ -0,0 +1,82 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lru
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCacheEviction tests the least recently used entry is evicted when
+// the cache is full
+func TestCacheEviction(t *testing.T) {
+	c := New(2, 0)
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	// b is the least recently used entry
+	c.Add("c", 3)
+	assert.Equal(t, 2, c.Len())
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	v, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 3, v)
+
+	// replacing an entry does not evict any entry
+	c.Add("a", 4)
+	assert.Equal(t, 2, c.Len())
+	v, ok = c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 4, v)
+
+	c.Remove("a")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, c.Len())
+}
+
+// TestCacheExpiry tests the entries expire after the TTL
+func TestCacheExpiry(t *testing.T) {
+	c := New(10, time.Minute).(*cache)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Add("a", 1)
+	now = now.Add(30 * time.Second)
+	c.Add("b", 2)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	now = now.Add(40 * time.Second)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, c.Len())
+	_, ok = c.Get("b")
+	assert.True(t, ok)
+
+	// adding an entry again renews it
+	c.Add("b", 3)
+	now = now.Add(50 * time.Second)
+	v, ok := c.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 3, v)
+}
@@ -70,7 +70,8 @@ type statsdConfig struct {
 	Endpoint string `yaml:"endpoint"`
 }
 
-// InitMetricScope initialize a root scope and its closer, with a http server mux
+// InitMetricScope initialize a root scope and its closer, with a http server mux.
+// The root scope is a TotalsScope keeping the totals of its metrics.
 func InitMetricScope(
 	cfg *Config,
 	rootMetricScope string,
@@ -85,6 +86,7 @@ func InitMetricScope(
 
 	var metricScope tally.Scope
 	var scopeCloser io.Closer
+	totals := NewTotals()
 	if cfg.MultiReporter {
 		var m3Reporter tallym3.Reporter
 		var promReporter tallyprom.Reporter
@@ -124,7 +126,7 @@ func InitMetricScope(
 			tally.ScopeOptions{
 				Prefix:         rootMetricScope,
 				Tags:           map[string]string{},
-				CachedReporter: reporter,
+				CachedReporter: NewTotalsCachedReporter(reporter, totals),
 				Separator:      metricSeparator,
 			},
 			metricFlushInterval)
@@ -147,13 +149,13 @@ func InitMetricScope(
 			tally.ScopeOptions{
 				Prefix:    rootMetricScope,
 				Tags:      map[string]string{},
-				Reporter:  reporter,
+				Reporter:  NewTotalsReporter(reporter, totals),
 				Separator: metricSeparator,
 			},
 			metricFlushInterval)
 	}
 
-	return metricScope, scopeCloser, mux
+	return &totalsScope{Scope: metricScope, totals: totals}, scopeCloser, mux
 }
 
 // SafeScopeName returns a safe scope name that removes dash which is not
@@ -0,0 +1,462 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/uber-go/tally"
+)
+
+// Totals accumulates the values of the metrics reported by a root scope
+// since it was created, whereas tally only keeps the values of the counters
+// since they were last reported. The totals are updated when the metrics
+// are reported, i.e. every flush interval of the scope for the counters,
+// gauges and histograms, and on each record for the timers.
+type Totals struct {
+	sync.RWMutex
+
+	counters   map[string]*CounterTotal
+	gauges     map[string]*GaugeTotal
+	timers     map[string]*TimerTotal
+	histograms map[string]*HistogramTotal
+	flushTime  time.Time
+}
+
+// CounterTotal is the sum of the increments of a counter.
+type CounterTotal struct {
+	Name  string
+	Tags  map[string]string
+	Value int64
+}
+
+// GaugeTotal is the last value of a gauge.
+type GaugeTotal struct {
+	Name  string
+	Tags  map[string]string
+	Value float64
+}
+
+// TimerTotal sums up the durations recorded by a timer.
+type TimerTotal struct {
+	Name  string
+	Tags  map[string]string
+	Count int64
+	Sum   time.Duration
+	Max   time.Duration
+}
+
+// HistogramTotal is the number of samples of each bucket of a histogram.
+// The upper bounds of the buckets of duration histograms are in
+// nanoseconds.
+type HistogramTotal struct {
+	Name    string
+	Tags    map[string]string
+	Buckets map[float64]int64
+}
+
+// TotalsSnapshot is a copy of the totals of the metrics, sorted by name
+// and tags.
+type TotalsSnapshot struct {
+	Counters   []CounterTotal
+	Gauges     []GaugeTotal
+	Timers     []TimerTotal
+	Histograms []HistogramTotal
+	// FlushTime is the last time the metrics were reported
+	FlushTime time.Time
+}
+
+// NewTotals returns empty totals.
+func NewTotals() *Totals {
+	return &Totals{
+		counters:   make(map[string]*CounterTotal),
+		gauges:     make(map[string]*GaugeTotal),
+		timers:     make(map[string]*TimerTotal),
+		histograms: make(map[string]*HistogramTotal),
+	}
+}
+
+func (t *Totals) addCounter(name string, tags map[string]string, value int64) {
+	key := tally.KeyForPrefixedStringMap(name, tags)
+	t.Lock()
+	defer t.Unlock()
+	counter, ok := t.counters[key]
+	if !ok {
+		counter = &CounterTotal{Name: name, Tags: copyTags(tags)}
+		t.counters[key] = counter
+	}
+	counter.Value += value
+}
+
+func (t *Totals) updateGauge(name string, tags map[string]string, value float64) {
+	key := tally.KeyForPrefixedStringMap(name, tags)
+	t.Lock()
+	defer t.Unlock()
+	gauge, ok := t.gauges[key]
+	if !ok {
+		gauge = &GaugeTotal{Name: name, Tags: copyTags(tags)}
+		t.gauges[key] = gauge
+	}
+	gauge.Value = value
+}
+
+func (t *Totals) recordTimer(
+	name string,
+	tags map[string]string,
+	interval time.Duration) {
+	key := tally.KeyForPrefixedStringMap(name, tags)
+	t.Lock()
+	defer t.Unlock()
+	timer, ok := t.timers[key]
+	if !ok {
+		timer = &TimerTotal{Name: name, Tags: copyTags(tags)}
+		t.timers[key] = timer
+	}
+	timer.Count++
+	timer.Sum += interval
+	if interval > timer.Max {
+		timer.Max = interval
+	}
+}
+
+func (t *Totals) addSamples(
+	name string,
+	tags map[string]string,
+	upperBound float64,
+	samples int64) {
+	key := tally.KeyForPrefixedStringMap(name, tags)
+	t.Lock()
+	defer t.Unlock()
+	histogram, ok := t.histograms[key]
+	if !ok {
+		histogram = &HistogramTotal{
+			Name:    name,
+			Tags:    copyTags(tags),
+			Buckets: make(map[float64]int64),
+		}
+		t.histograms[key] = histogram
+	}
+	histogram.Buckets[upperBound] += samples
+}
+
+func (t *Totals) flushed() {
+	t.Lock()
+	defer t.Unlock()
+	t.flushTime = time.Now()
+}
+
+// Snapshot returns a copy of the totals of the metrics whose name
+// matches the filter.
+func (t *Totals) Snapshot(filter func(name string) bool) *TotalsSnapshot {
+	t.RLock()
+	defer t.RUnlock()
+
+	snapshot := &TotalsSnapshot{FlushTime: t.flushTime}
+	for _, key := range sortedKeys(t.counters) {
+		if counter := t.counters[key]; filter(counter.Name) {
+			snapshot.Counters = append(snapshot.Counters, *counter)
+		}
+	}
+	for _, key := range sortedKeys(t.gauges) {
+		if gauge := t.gauges[key]; filter(gauge.Name) {
+			snapshot.Gauges = append(snapshot.Gauges, *gauge)
+		}
+	}
+	for _, key := range sortedKeys(t.timers) {
+		if timer := t.timers[key]; filter(timer.Name) {
+			snapshot.Timers = append(snapshot.Timers, *timer)
+		}
+	}
+	for _, key := range sortedKeys(t.histograms) {
+		histogram := t.histograms[key]
+		if !filter(histogram.Name) {
+			continue
+		}
+		buckets := make(map[float64]int64, len(histogram.Buckets))
+		for upperBound, samples := range histogram.Buckets {
+			buckets[upperBound] = samples
+		}
+		snapshot.Histograms = append(snapshot.Histograms, HistogramTotal{
+			Name:    histogram.Name,
+			Tags:    histogram.Tags,
+			Buckets: buckets,
+		})
+	}
+	return snapshot
+}
+
+// sortedKeys returns the keys of a map of totals, which start with the
+// name of the metric followed by its tags, sorted.
+func sortedKeys(totals interface{}) []string {
+	var keys []string
+	switch m := totals.(type) {
+	case map[string]*CounterTotal:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*GaugeTotal:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*TimerTotal:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*HistogramTotal:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	result := make(map[string]string, len(tags))
+	for k, v := range tags {
+		result[k] = v
+	}
+	return result
+}
+
+// totalsReporter is a StatsReporter which accumulates the totals of the
+// metrics it reports.
+type totalsReporter struct {
+	tally.StatsReporter
+	totals *Totals
+}
+
+// NewTotalsReporter returns a reporter which accumulates the totals of
+// the metrics reported by the given reporter.
+func NewTotalsReporter(
+	reporter tally.StatsReporter,
+	totals *Totals) tally.StatsReporter {
+	return &totalsReporter{StatsReporter: reporter, totals: totals}
+}
+
+func (r *totalsReporter) ReportCounter(
+	name string,
+	tags map[string]string,
+	value int64) {
+	r.totals.addCounter(name, tags, value)
+	r.StatsReporter.ReportCounter(name, tags, value)
+}
+
+func (r *totalsReporter) ReportGauge(
+	name string,
+	tags map[string]string,
+	value float64) {
+	r.totals.updateGauge(name, tags, value)
+	r.StatsReporter.ReportGauge(name, tags, value)
+}
+
+func (r *totalsReporter) ReportTimer(
+	name string,
+	tags map[string]string,
+	interval time.Duration) {
+	r.totals.recordTimer(name, tags, interval)
+	r.StatsReporter.ReportTimer(name, tags, interval)
+}
+
+func (r *totalsReporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64) {
+	r.totals.addSamples(name, tags, bucketUpperBound, samples)
+	r.StatsReporter.ReportHistogramValueSamples(
+		name, tags, buckets, bucketLowerBound, bucketUpperBound, samples)
+}
+
+func (r *totalsReporter) ReportHistogramDurationSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64) {
+	r.totals.addSamples(name, tags, float64(bucketUpperBound), samples)
+	r.StatsReporter.ReportHistogramDurationSamples(
+		name, tags, buckets, bucketLowerBound, bucketUpperBound, samples)
+}
+
+func (r *totalsReporter) Flush() {
+	r.StatsReporter.Flush()
+	r.totals.flushed()
+}
+
+// totalsCachedReporter is a CachedStatsReporter which accumulates the
+// totals of the metrics it reports.
+type totalsCachedReporter struct {
+	tally.CachedStatsReporter
+	totals *Totals
+}
+
+// NewTotalsCachedReporter returns a cached reporter which accumulates the
+// totals of the metrics reported by the given reporter.
+func NewTotalsCachedReporter(
+	reporter tally.CachedStatsReporter,
+	totals *Totals) tally.CachedStatsReporter {
+	return &totalsCachedReporter{CachedStatsReporter: reporter, totals: totals}
+}
+
+func (r *totalsCachedReporter) AllocateCounter(
+	name string,
+	tags map[string]string) tally.CachedCount {
+	return &totalsCount{
+		CachedCount: r.CachedStatsReporter.AllocateCounter(name, tags),
+		totals:      r.totals,
+		name:        name,
+		tags:        tags,
+	}
+}
+
+func (r *totalsCachedReporter) AllocateGauge(
+	name string,
+	tags map[string]string) tally.CachedGauge {
+	return &totalsGauge{
+		CachedGauge: r.CachedStatsReporter.AllocateGauge(name, tags),
+		totals:      r.totals,
+		name:        name,
+		tags:        tags,
+	}
+}
+
+func (r *totalsCachedReporter) AllocateTimer(
+	name string,
+	tags map[string]string) tally.CachedTimer {
+	return &totalsTimer{
+		CachedTimer: r.CachedStatsReporter.AllocateTimer(name, tags),
+		totals:      r.totals,
+		name:        name,
+		tags:        tags,
+	}
+}
+
+func (r *totalsCachedReporter) AllocateHistogram(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets) tally.CachedHistogram {
+	return &totalsHistogram{
+		CachedHistogram: r.CachedStatsReporter.AllocateHistogram(
+			name, tags, buckets),
+		totals: r.totals,
+		name:   name,
+		tags:   tags,
+	}
+}
+
+func (r *totalsCachedReporter) Flush() {
+	r.CachedStatsReporter.Flush()
+	r.totals.flushed()
+}
+
+type totalsCount struct {
+	tally.CachedCount
+	totals *Totals
+	name   string
+	tags   map[string]string
+}
+
+func (c *totalsCount) ReportCount(value int64) {
+	c.totals.addCounter(c.name, c.tags, value)
+	c.CachedCount.ReportCount(value)
+}
+
+type totalsGauge struct {
+	tally.CachedGauge
+	totals *Totals
+	name   string
+	tags   map[string]string
+}
+
+func (g *totalsGauge) ReportGauge(value float64) {
+	g.totals.updateGauge(g.name, g.tags, value)
+	g.CachedGauge.ReportGauge(value)
+}
+
+type totalsTimer struct {
+	tally.CachedTimer
+	totals *Totals
+	name   string
+	tags   map[string]string
+}
+
+func (t *totalsTimer) ReportTimer(interval time.Duration) {
+	t.totals.recordTimer(t.name, t.tags, interval)
+	t.CachedTimer.ReportTimer(interval)
+}
+
+type totalsHistogram struct {
+	tally.CachedHistogram
+	totals *Totals
+	name   string
+	tags   map[string]string
+}
+
+func (h *totalsHistogram) ValueBucket(
+	bucketLowerBound,
+	bucketUpperBound float64) tally.CachedHistogramBucket {
+	return &totalsHistogramBucket{
+		CachedHistogramBucket: h.CachedHistogram.ValueBucket(
+			bucketLowerBound, bucketUpperBound),
+		histogram:  h,
+		upperBound: bucketUpperBound,
+	}
+}
+
+func (h *totalsHistogram) DurationBucket(
+	bucketLowerBound,
+	bucketUpperBound time.Duration) tally.CachedHistogramBucket {
+	return &totalsHistogramBucket{
+		CachedHistogramBucket: h.CachedHistogram.DurationBucket(
+			bucketLowerBound, bucketUpperBound),
+		histogram:  h,
+		upperBound: float64(bucketUpperBound),
+	}
+}
+
+type totalsHistogramBucket struct {
+	tally.CachedHistogramBucket
+	histogram  *totalsHistogram
+	upperBound float64
+}
+
+func (b *totalsHistogramBucket) ReportSamples(value int64) {
+	b.histogram.totals.addSamples(
+		b.histogram.name, b.histogram.tags, b.upperBound, value)
+	b.CachedHistogramBucket.ReportSamples(value)
+}
+
+// TotalsScope is a root scope which keeps the totals of its metrics.
+type TotalsScope interface {
+	tally.Scope
+
+	// Totals returns the totals of the metrics of the scope.
+	Totals() *Totals
+}
+
+type totalsScope struct {
+	tally.Scope
+	totals *Totals
+}
+
+func (s *totalsScope) Totals() *Totals {
+	return s.totals
+}
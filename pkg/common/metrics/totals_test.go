@@ -0,0 +1,70 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally"
+)
+
+func TestTotalsReporter(t *testing.T) {
+	totals := NewTotals()
+	r := NewTotalsReporter(tally.NullStatsReporter, totals)
+	tags := map[string]string{"type": "batch"}
+
+	r.ReportCounter("jobmgr.launched", tags, 2)
+	r.ReportCounter("jobmgr.launched", tags, 3)
+	r.ReportCounter("jobmgr.launched", nil, 1)
+	r.ReportGauge("jobmgr.queue_depth", nil, 5)
+	r.ReportGauge("jobmgr.queue_depth", nil, 4)
+	r.ReportTimer("jobmgr.run", nil, time.Second)
+	r.ReportTimer("jobmgr.run", nil, 3*time.Second)
+	buckets := tally.DurationBuckets{time.Second, 2 * time.Second}
+	r.ReportHistogramDurationSamples(
+		"jobmgr.run_histogram", nil, buckets, 0, time.Second, 2)
+	r.ReportHistogramDurationSamples(
+		"jobmgr.run_histogram", nil, buckets, 0, time.Second, 1)
+	r.ReportHistogramValueSamples(
+		"resmgr.size", nil, tally.ValueBuckets{1}, 0, 1, 1)
+	r.Flush()
+
+	snapshot := totals.Snapshot(func(name string) bool {
+		return strings.HasPrefix(name, "jobmgr.")
+	})
+	assert.False(t, snapshot.FlushTime.IsZero())
+	assert.Equal(t, []CounterTotal{
+		{Name: "jobmgr.launched", Tags: map[string]string{}, Value: 1},
+		{Name: "jobmgr.launched", Tags: tags, Value: 5},
+	}, snapshot.Counters)
+	assert.Equal(t, []GaugeTotal{
+		{Name: "jobmgr.queue_depth", Tags: map[string]string{}, Value: 4},
+	}, snapshot.Gauges)
+	assert.Equal(t, []TimerTotal{{
+		Name:  "jobmgr.run",
+		Tags:  map[string]string{},
+		Count: 2,
+		Sum:   4 * time.Second,
+		Max:   3 * time.Second,
+	}}, snapshot.Timers)
+	assert.Equal(t, []HistogramTotal{{
+		Name:    "jobmgr.run_histogram",
+		Tags:    map[string]string{},
+		Buckets: map[float64]int64{float64(time.Second): 3},
+	}}, snapshot.Histograms)
+}
@@ -20,9 +20,13 @@ import (
 	nethttp "net/http"
 
 	"github.com/uber/peloton/pkg/common"
+	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
+	yarpcpeer "go.uber.org/yarpc/peer"
+	"go.uber.org/yarpc/peer/hostport"
 	"go.uber.org/yarpc/transport/grpc"
 	"go.uber.org/yarpc/transport/http"
+	"google.golang.org/grpc/credentials"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -40,6 +44,32 @@ func NewTransport() *grpc.Transport {
 	)
 }
 
+// NewPeerTransport returns the transport used to dial the peers of the
+// gRPC outbounds, over mutual TLS if tlsProvider is not nil.
+func NewPeerTransport(
+	t *grpc.Transport,
+	tlsProvider *TLSProvider) peer.Transport {
+	if tlsProvider == nil {
+		return t
+	}
+	return t.NewDialer(grpc.DialerCredentials(
+		credentials.NewTLS(tlsProvider.ClientConfig())))
+}
+
+// NewSingleOutbound returns a gRPC outbound to the peer at the address,
+// dialed over mutual TLS if tlsProvider is not nil.
+func NewSingleOutbound(
+	t *grpc.Transport,
+	address string,
+	tlsProvider *TLSProvider) *grpc.Outbound {
+	if tlsProvider == nil {
+		return t.NewSingleOutbound(address)
+	}
+	return t.NewOutbound(yarpcpeer.NewSingle(
+		hostport.PeerIdentifier(address),
+		NewPeerTransport(t, tlsProvider)))
+}
+
 // NewAuroraBridgeInbounds creates both HTTP and gRPC inbounds for the given ports
 func NewAuroraBridgeInbounds(
 	httpPort int,
@@ -66,11 +96,16 @@ func NewAuroraBridgeInbounds(
 	return inbounds
 }
 
-// NewInbounds creates both HTTP and gRPC inbounds for the given ports
+// NewInbounds creates both HTTP and gRPC inbounds for the given ports.
+// If tlsProvider is not nil, the gRPC inbound is served over mutual TLS,
+// and the procedures are not served over HTTP, since the HTTP inbound is
+// plaintext. The HTTP port then only serves the handlers of the mux, such
+// as the health and metrics endpoints.
 func NewInbounds(
 	httpPort int,
 	grpcPort int,
-	mux *nethttp.ServeMux) []transport.Inbound {
+	mux *nethttp.ServeMux,
+	tlsProvider *TLSProvider) []transport.Inbound {
 
 	// Create both HTTP and gRPC transport
 	ht := http.NewTransport()
@@ -80,6 +115,14 @@ func NewInbounds(
 	if err != nil {
 		log.WithError(err).Fatal("failed to listen to gRPC port")
 	}
+	if tlsProvider != nil {
+		gl = tlsProvider.NewListener(gl)
+		go func() {
+			err := nethttp.ListenAndServe(fmt.Sprintf(":%d", httpPort), mux)
+			log.WithError(err).Fatal("failed to serve HTTP port")
+		}()
+		return []transport.Inbound{gt.NewInbound(gl)}
+	}
 
 	inbounds := []transport.Inbound{
 		ht.NewInbound(
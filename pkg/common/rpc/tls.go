@@ -0,0 +1,443 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	// _defaultTLSReloadInterval is the default interval at which the
+	// certificate files are checked for changes
+	_defaultTLSReloadInterval = time.Minute
+)
+
+// TLSConfig is the config for mutual TLS on the gRPC inbounds and outbounds
+// between Peloton components. When enabled, every client of the gRPC
+// inbounds must present a certificate signed by one of the CAs.
+//
+// When enabled, the procedures of the components are only served over
+// gRPC, as the HTTP inbounds do not support TLS. The HTTP ports keep
+// serving the health, metrics and debug endpoints in plaintext. The HTTP
+// inbound of the Aurora bridge, which serves the Aurora clients, and the
+// outbounds of the host manager to Mesos are not covered, and should be
+// protected by a TLS terminating proxy.
+//
+// The files are reloaded when they change on disk, so certificates can be
+// rotated without restarting the components. X.509 SVIDs fetched from the
+// SPIFFE workload API, e.g. by spiffe-helper, are supported the same way by
+// pointing the config at the files the helper keeps up to date.
+type TLSConfig struct {
+	// Enabled turns on mutual TLS
+	Enabled bool `yaml:"enabled"`
+
+	// CertFile is the PEM encoded certificate chain of the component
+	CertFile string `yaml:"cert_file"`
+
+	// KeyFile is the PEM encoded private key of the component
+	KeyFile string `yaml:"key_file"`
+
+	// CAFile is the PEM encoded bundle of CA certificates used to verify
+	// the certificates of the peers
+	CAFile string `yaml:"ca_file"`
+
+	// AllowedPeerIDs are the SPIFFE IDs (URI SANs) or DNS names accepted
+	// in the certificates of the peers. Any certificate signed by one of
+	// the CAs is accepted if empty.
+	AllowedPeerIDs []string `yaml:"allowed_peer_ids"`
+
+	// ReloadInterval is how often the files are checked for changes
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+// TLSProvider provides the TLS configs of the gRPC inbounds and outbounds,
+// reloading the certificates from disk when they are rotated.
+type TLSProvider struct {
+	sync.RWMutex
+
+	config         *TLSConfig
+	reloadInterval time.Duration
+
+	cert    *tls.Certificate
+	roots   *x509.CertPool
+	modTime time.Time
+	// lastCheck is the time the files were last checked for changes, in
+	// nanoseconds since the epoch, so that the certificates are read
+	// without taking the write lock until they change
+	lastCheck int64
+
+	// peerIDs are the identities of the verified client certificates of
+	// the open connections of the inbounds, keyed by remote address
+	peersLock sync.RWMutex
+	peerIDs   map[string]string
+}
+
+// NewTLSProvider returns a TLSProvider for the config, or nil if TLS is
+// disabled.
+func NewTLSProvider(config *TLSConfig) (*TLSProvider, error) {
+	if config == nil || !config.Enabled {
+		return nil, nil
+	}
+
+	p := &TLSProvider{
+		config:         config,
+		reloadInterval: config.ReloadInterval,
+		peerIDs:        make(map[string]string),
+	}
+	if p.reloadInterval <= 0 {
+		p.reloadInterval = _defaultTLSReloadInterval
+	}
+
+	modTime, err := p.getModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.load(modTime); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ServerConfig returns the TLS config of the gRPC inbounds, which requires
+// the clients to present a certificate signed by one of the CAs. The
+// identity of the verified certificate of each connection is recorded, so
+// that it can be looked up with PeerID.
+func (p *TLSProvider) ServerConfig() *tls.Config {
+	config := p.serverConfig("")
+	config.GetConfigForClient = func(
+		hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		return p.serverConfig(hello.Conn.RemoteAddr().String()), nil
+	}
+	return config
+}
+
+// serverConfig returns the TLS config of a connection of the gRPC inbounds
+// from the remote address, which records the identity of the client
+// certificate if addr is not empty.
+func (p *TLSProvider) serverConfig(addr string) *tls.Config {
+	return &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return p.getCertificate(), nil
+		},
+		// The client certificates are verified by verifyPeer against the
+		// CAs currently loaded, instead of a fixed ClientCAs pool
+		ClientAuth: tls.RequireAnyClientCert,
+		// VerifyPeerCertificate is not called on resumed sessions, so the
+		// sessions are not resumed to record the identity of every
+		// connection
+		SessionTicketsDisabled: true,
+		VerifyPeerCertificate: func(
+			rawCerts [][]byte,
+			_ [][]*x509.Certificate) error {
+			cert, err := p.verifyPeerCertificate(rawCerts)
+			if err != nil {
+				return err
+			}
+			if len(addr) > 0 {
+				p.peersLock.Lock()
+				p.peerIDs[addr] = getPeerID(cert)
+				p.peersLock.Unlock()
+			}
+			return nil
+		},
+		NextProtos: []string{"h2"},
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+// NewListener returns a listener which serves the connections accepted by
+// the listener over mutual TLS with the server config, and forgets the
+// identity of the client certificate of each connection once it is closed.
+func (p *TLSProvider) NewListener(l net.Listener) net.Listener {
+	return &tlsListener{
+		Listener: tls.NewListener(l, p.ServerConfig()),
+		provider: p,
+	}
+}
+
+// PeerID returns the identity of the verified client certificate of the
+// gRPC request of the context: the first URI SAN, e.g. a SPIFFE ID, else
+// the first DNS name, else the common name of the certificate. Returns an
+// empty string if the provider is nil, i.e. TLS is disabled, or the
+// request was not received over mutual TLS.
+func (p *TLSProvider) PeerID(ctx context.Context) string {
+	if p == nil {
+		return ""
+	}
+	pr, ok := peer.FromContext(ctx)
+	if !ok || pr.Addr == nil {
+		return ""
+	}
+
+	p.peersLock.RLock()
+	defer p.peersLock.RUnlock()
+	return p.peerIDs[pr.Addr.String()]
+}
+
+// forgetPeer forgets the identity of the client certificate of the
+// connection from the remote address.
+func (p *TLSProvider) forgetPeer(addr string) {
+	p.peersLock.Lock()
+	defer p.peersLock.Unlock()
+	delete(p.peerIDs, addr)
+}
+
+// ClientConfig returns the TLS config of the gRPC outbounds.
+func (p *TLSProvider) ClientConfig() *tls.Config {
+	return &tls.Config{
+		GetClientCertificate: func(
+			*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return p.getCertificate(), nil
+		},
+		// The peers are discovered by address, so the server certificates
+		// are verified by verifyPeer against the CAs currently loaded and
+		// the allowed peer IDs instead of the server name
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: p.verifyPeer,
+		MinVersion:            tls.VersionTLS12,
+	}
+}
+
+// GetCertificate returns the current certificate of the component, so
+// that it can serve the endpoints whose clients are not verified against
+// the CAs of the provider, such as the executors of the tasks.
+func (p *TLSProvider) GetCertificate(
+	*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return p.getCertificate(), nil
+}
+
+// getCertificate returns the current certificate of the component.
+func (p *TLSProvider) getCertificate() *tls.Certificate {
+	p.maybeReload()
+
+	p.RLock()
+	defer p.RUnlock()
+	return p.cert
+}
+
+// getRoots returns the current CA certificates.
+func (p *TLSProvider) getRoots() *x509.CertPool {
+	p.maybeReload()
+
+	p.RLock()
+	defer p.RUnlock()
+	return p.roots
+}
+
+// verifyPeer verifies the certificate chain presented by a peer against
+// the current CA certificates and the allowed peer IDs.
+func (p *TLSProvider) verifyPeer(
+	rawCerts [][]byte,
+	_ [][]*x509.Certificate) error {
+	_, err := p.verifyPeerCertificate(rawCerts)
+	return err
+}
+
+// verifyPeerCertificate verifies the certificate chain presented by a peer
+// and returns the certificate of the peer.
+func (p *TLSProvider) verifyPeerCertificate(
+	rawCerts [][]byte) (*x509.Certificate, error) {
+	if len(rawCerts) == 0 {
+		return nil, errors.New("no peer certificate")
+	}
+
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, rawCert := range rawCerts {
+		cert, err := x509.ParseCertificate(rawCert)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse peer certificate")
+		}
+		certs = append(certs, cert)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         p.getRoots(),
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to verify peer certificate")
+	}
+
+	if !p.isPeerAllowed(certs[0]) {
+		return nil, errors.New("peer certificate is not allowed")
+	}
+	return certs[0], nil
+}
+
+// isPeerAllowed returns true if the certificate has one of the allowed
+// peer IDs.
+func (p *TLSProvider) isPeerAllowed(cert *x509.Certificate) bool {
+	if len(p.config.AllowedPeerIDs) == 0 {
+		return true
+	}
+
+	ids := getPeerIDs(cert)
+	for _, allowed := range p.config.AllowedPeerIDs {
+		for _, id := range ids {
+			if id == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getPeerIDs returns the URI SANs and DNS names of a certificate.
+func getPeerIDs(cert *x509.Certificate) []string {
+	var ids []string
+	for _, uri := range cert.URIs {
+		ids = append(ids, uri.String())
+	}
+	return append(ids, cert.DNSNames...)
+}
+
+// getPeerID returns the identity of a certificate: the first URI SAN, else
+// the first DNS name, else the common name.
+func getPeerID(cert *x509.Certificate) string {
+	if ids := getPeerIDs(cert); len(ids) > 0 {
+		return ids[0]
+	}
+	return cert.Subject.CommonName
+}
+
+// maybeReload reloads the certificates if the reload interval has passed
+// since the last check and any of the files has changed. The files are
+// checked by a single caller at a time, and the write lock is only taken
+// to reload them, so that the handshakes are not serialized. The current
+// certificates are kept if the reload fails.
+func (p *TLSProvider) maybeReload() {
+	lastCheck := atomic.LoadInt64(&p.lastCheck)
+	now := time.Now().UnixNano()
+	if time.Duration(now-lastCheck) < p.reloadInterval {
+		return
+	}
+	if !atomic.CompareAndSwapInt64(&p.lastCheck, lastCheck, now) {
+		// the files are being checked by another caller
+		return
+	}
+
+	modTime, err := p.getModTime()
+	if err != nil {
+		log.WithError(err).Warn("Failed to check TLS certificates")
+		return
+	}
+	p.RLock()
+	changed := modTime.After(p.modTime)
+	p.RUnlock()
+	if !changed {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	if !modTime.After(p.modTime) {
+		return
+	}
+	if err := p.load(modTime); err != nil {
+		log.WithError(err).Warn("Failed to reload TLS certificates")
+		return
+	}
+	log.Info("Reloaded TLS certificates")
+}
+
+// load loads the certificates from disk. The lock must be held by the
+// caller, if any.
+func (p *TLSProvider) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(p.config.CertFile, p.config.KeyFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to load certificate")
+	}
+
+	caBuffer, err := ioutil.ReadFile(p.config.CAFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to read CA certificates")
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caBuffer) {
+		return errors.Errorf(
+			"no CA certificate found in %s", p.config.CAFile)
+	}
+
+	p.cert = &cert
+	p.roots = roots
+	p.modTime = modTime
+	return nil
+}
+
+// getModTime returns the latest modification time of the files.
+func (p *TLSProvider) getModTime() (time.Time, error) {
+	var modTime time.Time
+	for _, file := range []string{
+		p.config.CertFile,
+		p.config.KeyFile,
+		p.config.CAFile,
+	} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return modTime, nil
+}
+
+// tlsListener is a TLS listener which forgets the identity of the client
+// certificate of each connection once it is closed.
+type tlsListener struct {
+	net.Listener
+
+	provider *TLSProvider
+}
+
+// Accept waits for and returns the next connection to the listener.
+func (l *tlsListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &tlsConn{Conn: conn, provider: l.provider}, nil
+}
+
+// tlsConn is a TLS connection which forgets the identity of the client
+// certificate once it is closed.
+type tlsConn struct {
+	net.Conn
+
+	provider *TLSProvider
+}
+
+// Close closes the connection.
+func (c *tlsConn) Close() error {
+	c.provider.forgetPeer(c.RemoteAddr().String())
+	return c.Conn.Close()
+}
@@ -0,0 +1,289 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/peer"
+)
+
+type TLSProviderTestSuite struct {
+	suite.Suite
+
+	dir    string
+	config *TLSConfig
+}
+
+func (suite *TLSProviderTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "peloton-tls")
+	suite.NoError(err)
+	suite.dir = dir
+
+	suite.config = &TLSConfig{
+		Enabled:  true,
+		CertFile: filepath.Join(dir, "cert.pem"),
+		KeyFile:  filepath.Join(dir, "key.pem"),
+		CAFile:   filepath.Join(dir, "ca.pem"),
+	}
+	suite.writeCertificates("spiffe://peloton/jobmgr", time.Now())
+}
+
+func (suite *TLSProviderTestSuite) TearDownTest() {
+	os.RemoveAll(suite.dir)
+}
+
+func TestTLSProvider(t *testing.T) {
+	suite.Run(t, new(TLSProviderTestSuite))
+}
+
+// writeCertificates writes a new CA and a certificate with the SPIFFE ID
+// signed by it, with the given modification time.
+func (suite *TLSProviderTestSuite) writeCertificates(
+	spiffeID string,
+	modTime time.Time) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	suite.NoError(err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "peloton-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(
+		rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	suite.NoError(err)
+	ca, err := x509.ParseCertificate(caDER)
+	suite.NoError(err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	suite.NoError(err)
+	uri, err := url.Parse(spiffeID)
+	suite.NoError(err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "peloton"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		URIs:         []*url.URL{uri},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth,
+			x509.ExtKeyUsageClientAuth,
+		},
+	}
+	certDER, err := x509.CreateCertificate(
+		rand.Reader, template, ca, &key.PublicKey, caKey)
+	suite.NoError(err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	suite.NoError(err)
+
+	for file, block := range map[string]*pem.Block{
+		suite.config.CAFile:   {Type: "CERTIFICATE", Bytes: caDER},
+		suite.config.CertFile: {Type: "CERTIFICATE", Bytes: certDER},
+		suite.config.KeyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		suite.NoError(ioutil.WriteFile(file, pem.EncodeToMemory(block), 0600))
+		suite.NoError(os.Chtimes(file, modTime, modTime))
+	}
+}
+
+// handshake runs a TLS handshake between a server and a client using the
+// configs of the providers.
+func (suite *TLSProviderTestSuite) handshake(
+	server *TLSProvider,
+	client *TLSProvider) (serverErr error, clientErr error) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		conn := tls.Server(serverConn, server.ServerConfig())
+		err := conn.Handshake()
+		// unblock the client if the handshake failed on the server side
+		serverConn.Close()
+		done <- err
+	}()
+	clientErr = tls.Client(clientConn, client.ClientConfig()).Handshake()
+	clientConn.Close()
+	return <-done, clientErr
+}
+
+// TestNewTLSProviderDisabled tests that no provider is returned when TLS
+// is disabled
+func (suite *TLSProviderTestSuite) TestNewTLSProviderDisabled() {
+	p, err := NewTLSProvider(&TLSConfig{})
+	suite.NoError(err)
+	suite.Nil(p)
+}
+
+// TestNewTLSProviderMissingFiles tests that creating a provider fails if
+// the certificates cannot be loaded
+func (suite *TLSProviderTestSuite) TestNewTLSProviderMissingFiles() {
+	suite.config.CAFile = filepath.Join(suite.dir, "missing.pem")
+	_, err := NewTLSProvider(suite.config)
+	suite.Error(err)
+}
+
+// TestHandshake tests mutual TLS between two components sharing a CA
+func (suite *TLSProviderTestSuite) TestHandshake() {
+	suite.config.AllowedPeerIDs = []string{"spiffe://peloton/jobmgr"}
+	p, err := NewTLSProvider(suite.config)
+	suite.NoError(err)
+
+	serverErr, clientErr := suite.handshake(p, p)
+	suite.NoError(serverErr)
+	suite.NoError(clientErr)
+}
+
+// TestHandshakePeerNotAllowed tests that peers whose ID is not allowed are
+// rejected
+func (suite *TLSProviderTestSuite) TestHandshakePeerNotAllowed() {
+	suite.config.AllowedPeerIDs = []string{"spiffe://peloton/resmgr"}
+	p, err := NewTLSProvider(suite.config)
+	suite.NoError(err)
+
+	_, clientErr := suite.handshake(p, p)
+	suite.Error(clientErr)
+}
+
+// connect opens a connection to the listener with the client config, and
+// returns the client and server sides of the connection once the handshake
+// is complete.
+func (suite *TLSProviderTestSuite) connect(
+	l net.Listener,
+	config *tls.Config) (clientConn net.Conn, serverConn net.Conn) {
+	done := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		suite.NoError(err)
+		// the client certificate is verified during the handshake, which
+		// completes once the first bytes are read
+		_, err = conn.Read(make([]byte, 1))
+		suite.NoError(err)
+		_, err = conn.Write([]byte{0})
+		suite.NoError(err)
+		done <- conn
+	}()
+	clientConn, err := tls.Dial("tcp", l.Addr().String(), config)
+	suite.NoError(err)
+	_, err = clientConn.Write([]byte{0})
+	suite.NoError(err)
+	// the session tickets, if any, are received along with the response
+	_, err = clientConn.Read(make([]byte, 1))
+	suite.NoError(err)
+	return clientConn, <-done
+}
+
+// TestPeerID tests that the identity of the client certificate of a
+// connection accepted by the listener is recorded until it is closed
+func (suite *TLSProviderTestSuite) TestPeerID() {
+	p, err := NewTLSProvider(suite.config)
+	suite.NoError(err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.NoError(err)
+	l = p.NewListener(l)
+	defer l.Close()
+
+	clientConn, serverConn := suite.connect(l, p.ClientConfig())
+	defer clientConn.Close()
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: serverConn.RemoteAddr(),
+	})
+	suite.Equal("spiffe://peloton/jobmgr", p.PeerID(ctx))
+	suite.Empty(p.PeerID(context.Background()))
+	var disabled *TLSProvider
+	suite.Empty(disabled.PeerID(ctx))
+
+	suite.NoError(serverConn.Close())
+	suite.Empty(p.PeerID(ctx))
+}
+
+// TestPeerIDSessionResumption tests that the identity of the client
+// certificate is recorded for every connection of a client caching its
+// sessions, since the sessions are not resumed
+func (suite *TLSProviderTestSuite) TestPeerIDSessionResumption() {
+	p, err := NewTLSProvider(suite.config)
+	suite.NoError(err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.NoError(err)
+	l = p.NewListener(l)
+	defer l.Close()
+
+	config := p.ClientConfig()
+	config.ClientSessionCache = tls.NewLRUClientSessionCache(1)
+	for i := 0; i < 2; i++ {
+		clientConn, serverConn := suite.connect(l, config)
+		suite.False(serverConn.(*tlsConn).Conn.(*tls.Conn).
+			ConnectionState().DidResume)
+		ctx := peer.NewContext(context.Background(), &peer.Peer{
+			Addr: serverConn.RemoteAddr(),
+		})
+		suite.Equal("spiffe://peloton/jobmgr", p.PeerID(ctx))
+		clientConn.Close()
+		serverConn.Close()
+	}
+}
+
+// TestReload tests that rotated certificates are picked up
+func (suite *TLSProviderTestSuite) TestReload() {
+	suite.config.ReloadInterval = time.Hour
+	p, err := NewTLSProvider(suite.config)
+	suite.NoError(err)
+	oldCert := p.getCertificate()
+
+	// rotate the certificates and the CA
+	suite.writeCertificates(
+		"spiffe://peloton/jobmgr", time.Now().Add(time.Minute))
+	rotated, err := NewTLSProvider(suite.config)
+	suite.NoError(err)
+
+	// the rotated certificates are rejected until the reload interval
+	// has passed
+	serverErr, _ := suite.handshake(p, rotated)
+	suite.Error(serverErr)
+	suite.Equal(oldCert, p.getCertificate())
+
+	p.reloadInterval = time.Millisecond
+	time.Sleep(2 * time.Millisecond)
+	suite.NotEqual(oldCert, p.getCertificate())
+
+	serverErr, clientErr := suite.handshake(p, rotated)
+	suite.NoError(serverErr)
+	suite.NoError(clientErr)
+}
@@ -263,6 +263,7 @@ func (sm *statemachine) TransitTo(to State, options ...Option) error {
 	for _, option := range options {
 		option(sm)
 	}
+	t.Reason = sm.reason
 
 	// invoking callback function
 	if sm.rules[curState].Callback != nil {
@@ -474,6 +475,7 @@ func (sm *statemachine) rollbackState() error {
 
 	// Doing actual transition
 	sm.reason = fmt.Sprintf("rollback from state %s to state %s due to timeout", sm.current, t.To)
+	t.Reason = sm.reason
 	sm.current = t.To
 	sm.lastUpdatedTime = time.Now()
 
@@ -33,4 +33,8 @@ type Transition struct {
 	// Arguments passed during the transition
 	// which will be passed to callback function
 	Params []interface{}
+
+	// Reason for the transition, set before the transition callback
+	// is invoked
+	Reason string
 }
@@ -16,6 +16,7 @@ package task
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/uber/peloton/.gen/peloton/api/v0/job"
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
@@ -40,6 +41,9 @@ func ConvertToResMgrGangs(
 	// and harder to place than gangs comprising a single task.
 	var multiTaskGangs []*resmgrsvc.Gang
 
+	// weights of the tasks of the gangs comprising one task
+	var weights []uint32
+
 	for _, t := range tasks {
 		resmgrtask := ConvertTaskToResMgrTask(t, jobConfig)
 		// Currently a job has at most 1 gang comprising multiple tasks;
@@ -57,14 +61,39 @@ func ConvertToResMgrGangs(
 			var gang resmgrsvc.Gang
 			gang.Tasks = append(gang.Tasks, resmgrtask)
 			gangs = append(gangs, &gang)
+			weights = append(weights, t.GetConfig().GetWeight())
 		}
 	}
+	// Gangs comprising one task are ordered by decreasing task weight, so
+	// that the most important instances of the job are admitted and placed
+	// first when only partial capacity is available. The resource manager
+	// keeps the gangs of the same priority in the order they are enqueued.
+	sort.Stable(&gangsByWeight{gangs: gangs, weights: weights})
 	if len(multiTaskGangs) > 0 {
 		gangs = append(multiTaskGangs, gangs...)
 	}
 	return gangs
 }
 
+// gangsByWeight sorts gangs by decreasing weight of their task.
+type gangsByWeight struct {
+	gangs   []*resmgrsvc.Gang
+	weights []uint32
+}
+
+func (g *gangsByWeight) Len() int {
+	return len(g.gangs)
+}
+
+func (g *gangsByWeight) Less(i, j int) bool {
+	return g.weights[i] > g.weights[j]
+}
+
+func (g *gangsByWeight) Swap(i, j int) {
+	g.gangs[i], g.gangs[j] = g.gangs[j], g.gangs[i]
+	g.weights[i], g.weights[j] = g.weights[j], g.weights[i]
+}
+
 // ConvertTaskToResMgrTask converts taskinfo to resmgr task.
 func ConvertTaskToResMgrTask(
 	taskInfo *task.TaskInfo,
@@ -121,6 +150,7 @@ func ConvertTaskToResMgrTask(
 		Controller:   taskInfo.GetConfig().GetController(),
 		Revocable:    taskInfo.GetConfig().GetRevocable(),
 		DesiredHost:  taskInfo.GetRuntime().GetDesiredHost(),
+		StartAfter:   jobConfig.GetStartAfter(),
 	}
 
 	taskState := taskInfo.GetRuntime().GetState()
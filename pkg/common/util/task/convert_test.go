@@ -168,6 +168,51 @@ func TestConvertToResMgrGangs(t *testing.T) {
 	assert.Len(t, gangs, 3)
 }
 
+// TestConvertToResMgrGangsByWeight tests that gangs of one task are ordered
+// by decreasing task weight
+func TestConvertToResMgrGangsByWeight(t *testing.T) {
+	jobConfig := &job.JobConfig{
+		SLA: &job.SlaConfig{
+			MinimumRunningInstances: 2,
+		},
+	}
+
+	gangs := ConvertToResMgrGangs(
+		[]*task.TaskInfo{
+			{
+				InstanceId: 0,
+			},
+			{
+				InstanceId: 1,
+			},
+			{
+				InstanceId: 2,
+				Config:     &task.TaskConfig{Weight: 1},
+			},
+			{
+				InstanceId: 3,
+				Config:     &task.TaskConfig{Weight: 5},
+			},
+			{
+				InstanceId: 4,
+			},
+			{
+				InstanceId: 5,
+				Config:     &task.TaskConfig{Weight: 1},
+			}},
+		jobConfig)
+
+	assert.Len(t, gangs, 5)
+	// the multi-task gang is always placed first
+	assert.Len(t, gangs[0].GetTasks(), 2)
+	var instances []string
+	for _, gang := range gangs[1:] {
+		assert.Len(t, gang.GetTasks(), 1)
+		instances = append(instances, gang.GetTasks()[0].GetId().GetValue())
+	}
+	assert.Equal(t, []string{"-3", "-2", "-5", "-4"}, instances)
+}
+
 func TestConvertTaskToResMgrTaskPreemptible(t *testing.T) {
 	tt := []struct {
 		name        string
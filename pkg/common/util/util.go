@@ -304,7 +304,7 @@ func IsPelotonStateTerminal(state task.TaskState) bool {
 	switch state {
 	case task.TaskState_SUCCEEDED, task.TaskState_FAILED,
 		task.TaskState_KILLED, task.TaskState_LOST,
-		task.TaskState_DELETED:
+		task.TaskState_DELETED, task.TaskState_FAILED_HOLD:
 		return true
 	default:
 		return false
@@ -344,6 +344,21 @@ func CreateMesosTaskID(jobID *peloton.JobID,
 	return &mesos.TaskID{Value: &mesosID}
 }
 
+// CustomExecutorIDPrefix is the prefix of the ID of the custom executors,
+// e.g. Aurora thermos, Peloton launches the Mesos tasks with. The ID of
+// the Mesos task of the executor follows the prefix.
+const CustomExecutorIDPrefix = "thermos-"
+
+// CreateExecutorID returns the ID of the executor Peloton launches a Mesos
+// task with. The ID of a custom executor is the ID of its task with a
+// prefix, and the ID of the command executor the ID of its task.
+func CreateExecutorID(mesosTaskID string, executor *mesos.ExecutorInfo) string {
+	if executor.GetType() == mesos.ExecutorInfo_CUSTOM {
+		return CustomExecutorIDPrefix + mesosTaskID
+	}
+	return mesosTaskID
+}
+
 // CreatePelotonTaskID creates a PelotonTaskID given jobID and instanceID
 func CreatePelotonTaskID(
 	jobID string,
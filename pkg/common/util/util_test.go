@@ -500,11 +500,12 @@ func TestJobTerminalState(t *testing.T) {
 // Test check for task state being terminal
 func TestTaskTerminalState(t *testing.T) {
 	taskTerminalStates := map[task.TaskState]bool{
-		task.TaskState_FAILED:    true,
-		task.TaskState_KILLED:    true,
-		task.TaskState_SUCCEEDED: true,
-		task.TaskState_LOST:      true,
-		task.TaskState_DELETED:   true,
+		task.TaskState_FAILED:      true,
+		task.TaskState_KILLED:      true,
+		task.TaskState_SUCCEEDED:   true,
+		task.TaskState_LOST:        true,
+		task.TaskState_DELETED:     true,
+		task.TaskState_FAILED_HOLD: true,
 	}
 	for s := range task.TaskState_name {
 		_, isTerm := taskTerminalStates[task.TaskState(s)]
@@ -548,3 +549,16 @@ func TestCreateMesosTaskID(t *testing.T) {
 		assert.Equal(t, mesosTaskID.GetValue(), test.result)
 	}
 }
+
+// TestCreateExecutorID tests CreateExecutorID
+func TestCreateExecutorID(t *testing.T) {
+	taskID := "5f9b61a6-b290-49ef-899e-6e42dc5aabd3-3-1"
+	defaultType := mesos.ExecutorInfo_DEFAULT
+	customType := mesos.ExecutorInfo_CUSTOM
+
+	assert.Equal(t, taskID, CreateExecutorID(taskID, nil))
+	assert.Equal(t, taskID,
+		CreateExecutorID(taskID, &mesos.ExecutorInfo{Type: &defaultType}))
+	assert.Equal(t, "thermos-"+taskID,
+		CreateExecutorID(taskID, &mesos.ExecutorInfo{Type: &customType}))
+}
@@ -0,0 +1,267 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+
+	mesos "github.com/uber/peloton/.gen/mesos/v1"
+	mesos_agent "github.com/uber/peloton/.gen/mesos/v1/agent"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	_contentTypeProtobuf = "application/x-protobuf"
+	_contentTypeRecordIO = "application/recordio"
+
+	// _maxFrameLength is the maximal length of a RecordIO frame of the
+	// output of a command, to protect against a corrupted stream.
+	_maxFrameLength = 16 * 1024 * 1024
+)
+
+// ErrContainerNotFound is returned if the container of the executor is not
+// running on the agent.
+var ErrContainerNotFound = errors.New("container not found on the agent")
+
+// ExecClient executes commands in the containers running on the Mesos
+// agents, through the agent operator API.
+type ExecClient interface {
+	// Exec runs the command in a nested container of the container of the
+	// executor of the framework on the agent at address, and calls send
+	// with every chunk of its stdout and stderr. It returns the exit code
+	// of the command once it exits. The command is killed by the agent if
+	// the context is done before it exits.
+	Exec(
+		ctx context.Context,
+		address string,
+		frameworkID string,
+		executorID string,
+		command *mesos.CommandInfo,
+		send func(data *mesos_agent.ProcessIO_Data) error) (int32, error)
+}
+
+type execClient struct {
+	client *http.Client
+}
+
+// NewExecClient returns a client executing commands in the containers on
+// the Mesos agents.
+func NewExecClient(client *http.Client) ExecClient {
+	return &execClient{client: client}
+}
+
+// Exec implements ExecClient.
+func (c *execClient) Exec(
+	ctx context.Context,
+	address string,
+	frameworkID string,
+	executorID string,
+	command *mesos.CommandInfo,
+	send func(data *mesos_agent.ProcessIO_Data) error) (int32, error) {
+	parentID, err := c.getContainerID(ctx, address, frameworkID, executorID)
+	if err != nil {
+		return 0, err
+	}
+
+	containerID := &mesos.ContainerID{
+		Value:  proto.String(uuid.New()),
+		Parent: parentID,
+	}
+	callType := mesos_agent.Call_LAUNCH_NESTED_CONTAINER_SESSION
+	resp, err := c.post(ctx, address, &mesos_agent.Call{
+		Type: &callType,
+		LaunchNestedContainerSession: &mesos_agent.Call_LaunchNestedContainerSession{
+			ContainerId: containerID,
+			Command:     command,
+		},
+	}, _contentTypeRecordIO)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// The output is streamed until the command exits, and the agent
+	// closes the stream
+	reader := bufio.NewReader(resp.Body)
+	for {
+		frame, err := readFrame(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		processIO := &mesos_agent.ProcessIO{}
+		if err := proto.Unmarshal(frame, processIO); err != nil {
+			return 0, errors.Wrap(err, "failed to unmarshal process output")
+		}
+		if processIO.GetType() != mesos_agent.ProcessIO_DATA {
+			continue
+		}
+		if err := send(processIO.GetData()); err != nil {
+			return 0, err
+		}
+	}
+
+	return c.waitContainer(ctx, address, containerID)
+}
+
+// getContainerID returns the ID of the container of the executor of the
+// framework on the agent.
+func (c *execClient) getContainerID(
+	ctx context.Context,
+	address string,
+	frameworkID string,
+	executorID string) (*mesos.ContainerID, error) {
+	callType := mesos_agent.Call_GET_CONTAINERS
+	response, err := c.call(ctx, address, &mesos_agent.Call{
+		Type:          &callType,
+		GetContainers: &mesos_agent.Call_GetContainers{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, container := range response.GetGetContainers().GetContainers() {
+		if container.GetFrameworkId().GetValue() == frameworkID &&
+			container.GetExecutorId().GetValue() == executorID {
+			return container.GetContainerId(), nil
+		}
+	}
+	return nil, ErrContainerNotFound
+}
+
+// waitContainer waits for the nested container of a command to exit, and
+// returns the exit code of the command.
+func (c *execClient) waitContainer(
+	ctx context.Context,
+	address string,
+	containerID *mesos.ContainerID) (int32, error) {
+	callType := mesos_agent.Call_WAIT_CONTAINER
+	response, err := c.call(ctx, address, &mesos_agent.Call{
+		Type: &callType,
+		WaitContainer: &mesos_agent.Call_WaitContainer{
+			ContainerId: containerID,
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// The exit status is the value returned by wait(2)
+	status := syscall.WaitStatus(response.GetWaitContainer().GetExitStatus())
+	if status.Signaled() {
+		return 128 + int32(status.Signal()), nil
+	}
+	return int32(status.ExitStatus()), nil
+}
+
+// call makes a call to the agent operator API and returns its response.
+func (c *execClient) call(
+	ctx context.Context,
+	address string,
+	call *mesos_agent.Call) (*mesos_agent.Response, error) {
+	resp, err := c.post(ctx, address, call, _contentTypeProtobuf)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s response",
+			call.GetType())
+	}
+	response := &mesos_agent.Response{}
+	if err := proto.Unmarshal(body, response); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal %s response",
+			call.GetType())
+	}
+	return response, nil
+}
+
+// post posts a call to the agent operator API, and fails if the agent
+// does not return a 200.
+func (c *execClient) post(
+	ctx context.Context,
+	address string,
+	call *mesos_agent.Call,
+	accept string) (*http.Response, error) {
+	body, err := proto.Marshal(call)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal %s call",
+			call.GetType())
+	}
+
+	url := fmt.Sprintf("http://%s/api/v1", address)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", _contentTypeProtobuf)
+	req.Header.Set("Accept", accept)
+	if accept == _contentTypeRecordIO {
+		req.Header.Set("Message-Accept", _contentTypeProtobuf)
+	}
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to call %s on %s",
+			call.GetType(), address)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		message, _ := ioutil.ReadAll(resp.Body)
+		return nil, errors.Errorf("%s on %s failed with status %d: %s",
+			call.GetType(), address, resp.StatusCode,
+			strings.TrimSpace(string(message)))
+	}
+	return resp, nil
+}
+
+// readFrame reads the next RecordIO frame, made of its length in ASCII
+// followed by a newline and the frame itself.
+func readFrame(reader *bufio.Reader) ([]byte, error) {
+	line, err := reader.ReadString('\n')
+	if err == io.EOF && len(line) == 0 {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read frame length")
+	}
+
+	length, err := strconv.ParseUint(strings.TrimSpace(line), 10, 64)
+	if err != nil || length > _maxFrameLength {
+		return nil, errors.Errorf("invalid frame length %q", line)
+	}
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(reader, frame); err != nil {
+		return nil, errors.Wrap(err, "failed to read frame")
+	}
+	return frame, nil
+}
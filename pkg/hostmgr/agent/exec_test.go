@@ -0,0 +1,152 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mesos "github.com/uber/peloton/.gen/mesos/v1"
+	mesos_agent "github.com/uber/peloton/.gen/mesos/v1/agent"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	_frameworkID = "framework"
+	_executorID  = "executor"
+	_containerID = "container"
+)
+
+// newAgentServer returns an agent serving the calls used to run a command
+// which writes to stdout and stderr, and exits with exitStatus.
+func newAgentServer(t *testing.T, exitStatus int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/v1", r.URL.Path)
+			body, err := ioutil.ReadAll(r.Body)
+			assert.NoError(t, err)
+			call := &mesos_agent.Call{}
+			assert.NoError(t, proto.Unmarshal(body, call))
+
+			var response proto.Message
+			switch call.GetType() {
+			case mesos_agent.Call_GET_CONTAINERS:
+				response = &mesos_agent.Response{
+					GetContainers: &mesos_agent.Response_GetContainers{
+						Containers: []*mesos_agent.Response_GetContainers_Container{
+							{
+								FrameworkId: &mesos.FrameworkID{Value: proto.String(_frameworkID)},
+								ExecutorId:  &mesos.ExecutorID{Value: proto.String(_executorID)},
+								ContainerId: &mesos.ContainerID{Value: proto.String(_containerID)},
+							},
+						},
+					},
+				}
+			case mesos_agent.Call_LAUNCH_NESTED_CONTAINER_SESSION:
+				session := call.GetLaunchNestedContainerSession()
+				assert.Equal(t, _containerID,
+					session.GetContainerId().GetParent().GetValue())
+				assert.Equal(t, "ls", session.GetCommand().GetValue())
+				assert.Equal(t, _contentTypeRecordIO, r.Header.Get("Accept"))
+				writeProcessIO(t, w, mesos_agent.ProcessIO_Data_STDOUT, "out")
+				writeProcessIO(t, w, mesos_agent.ProcessIO_Data_STDERR, "err")
+				return
+			case mesos_agent.Call_WAIT_CONTAINER:
+				assert.Equal(t, _containerID,
+					call.GetWaitContainer().GetContainerId().GetParent().GetValue())
+				response = &mesos_agent.Response{
+					WaitContainer: &mesos_agent.Response_WaitContainer{
+						ExitStatus: proto.Int32(exitStatus),
+					},
+				}
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			data, err := proto.Marshal(response)
+			assert.NoError(t, err)
+			w.Write(data)
+		}))
+}
+
+// writeProcessIO writes the output of a command as a RecordIO frame.
+func writeProcessIO(
+	t *testing.T,
+	w http.ResponseWriter,
+	dataType mesos_agent.ProcessIO_Data_Type,
+	data string) {
+	ioType := mesos_agent.ProcessIO_DATA
+	frame, err := proto.Marshal(&mesos_agent.ProcessIO{
+		Type: &ioType,
+		Data: &mesos_agent.ProcessIO_Data{
+			Type: &dataType,
+			Data: []byte(data),
+		},
+	})
+	assert.NoError(t, err)
+	fmt.Fprintf(w, "%d\n%s", len(frame), frame)
+}
+
+// TestExec tests running a command in the container of an executor
+func TestExec(t *testing.T) {
+	server := newAgentServer(t, 3<<8)
+	defer server.Close()
+
+	var stdout, stderr []string
+	c := NewExecClient(&http.Client{})
+	exitCode, err := c.Exec(
+		context.Background(),
+		strings.TrimPrefix(server.URL, "http://"),
+		_frameworkID,
+		_executorID,
+		&mesos.CommandInfo{Shell: proto.Bool(true), Value: proto.String("ls")},
+		func(data *mesos_agent.ProcessIO_Data) error {
+			if data.GetType() == mesos_agent.ProcessIO_Data_STDOUT {
+				stdout = append(stdout, string(data.GetData()))
+			} else {
+				stderr = append(stderr, string(data.GetData()))
+			}
+			return nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), exitCode)
+	assert.Equal(t, []string{"out"}, stdout)
+	assert.Equal(t, []string{"err"}, stderr)
+}
+
+// TestExecContainerNotFound tests running a command for an executor which
+// is not running on the agent
+func TestExecContainerNotFound(t *testing.T) {
+	server := newAgentServer(t, 0)
+	defer server.Close()
+
+	c := NewExecClient(&http.Client{})
+	_, err := c.Exec(
+		context.Background(),
+		strings.TrimPrefix(server.URL, "http://"),
+		_frameworkID,
+		"other-executor",
+		&mesos.CommandInfo{Value: proto.String("ls")},
+		func(*mesos_agent.ProcessIO_Data) error { return nil })
+	assert.Equal(t, ErrContainerNotFound, err)
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"net/http"
+
+	mesos "github.com/uber/peloton/.gen/mesos/v1"
+	mesos_agent "github.com/uber/peloton/.gen/mesos/v1/agent"
+)
+
+// UsageClient gets the resource usage of the containers running on the
+// Mesos agents, through the agent operator API.
+type UsageClient interface {
+	// GetResourceUsage returns the resource statistics of the containers
+	// of the executors of the framework on the agent at address, keyed by
+	// executor ID. The containers without statistics are skipped.
+	GetResourceUsage(
+		ctx context.Context,
+		address string,
+		frameworkID string) (map[string]*mesos.ResourceStatistics, error)
+}
+
+type usageClient struct {
+	execClient
+}
+
+// NewUsageClient returns a client getting the resource usage of the
+// containers on the Mesos agents.
+func NewUsageClient(client *http.Client) UsageClient {
+	return &usageClient{execClient{client: client}}
+}
+
+// GetResourceUsage implements UsageClient.
+func (c *usageClient) GetResourceUsage(
+	ctx context.Context,
+	address string,
+	frameworkID string) (map[string]*mesos.ResourceStatistics, error) {
+	callType := mesos_agent.Call_GET_CONTAINERS
+	response, err := c.call(ctx, address, &mesos_agent.Call{
+		Type:          &callType,
+		GetContainers: &mesos_agent.Call_GetContainers{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make(map[string]*mesos.ResourceStatistics)
+	for _, container := range response.GetGetContainers().GetContainers() {
+		if container.GetFrameworkId().GetValue() != frameworkID ||
+			container.GetResourceStatistics() == nil {
+			continue
+		}
+		usage[container.GetExecutorId().GetValue()] =
+			container.GetResourceStatistics()
+	}
+	return usage, nil
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mesos "github.com/uber/peloton/.gen/mesos/v1"
+	mesos_agent "github.com/uber/peloton/.gen/mesos/v1/agent"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetResourceUsage tests getting the resource usage of the containers
+// of the executors of a framework.
+func TestGetResourceUsage(t *testing.T) {
+	statistics := &mesos.ResourceStatistics{
+		Timestamp:             proto.Float64(1000),
+		CpusThrottledTimeSecs: proto.Float64(1.5),
+		MemRssBytes:           proto.Uint64(1024),
+	}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			assert.NoError(t, err)
+			call := &mesos_agent.Call{}
+			assert.NoError(t, proto.Unmarshal(body, call))
+			assert.Equal(t, mesos_agent.Call_GET_CONTAINERS, call.GetType())
+
+			data, err := proto.Marshal(&mesos_agent.Response{
+				GetContainers: &mesos_agent.Response_GetContainers{
+					Containers: []*mesos_agent.Response_GetContainers_Container{
+						{
+							FrameworkId:        &mesos.FrameworkID{Value: proto.String(_frameworkID)},
+							ExecutorId:         &mesos.ExecutorID{Value: proto.String(_executorID)},
+							ContainerId:        &mesos.ContainerID{Value: proto.String(_containerID)},
+							ResourceStatistics: statistics,
+						},
+						{
+							FrameworkId:        &mesos.FrameworkID{Value: proto.String("other-framework")},
+							ExecutorId:         &mesos.ExecutorID{Value: proto.String("other-executor")},
+							ContainerId:        &mesos.ContainerID{Value: proto.String("other-container")},
+							ResourceStatistics: statistics,
+						},
+						{
+							FrameworkId: &mesos.FrameworkID{Value: proto.String(_frameworkID)},
+							ExecutorId:  &mesos.ExecutorID{Value: proto.String("no-statistics")},
+							ContainerId: &mesos.ContainerID{Value: proto.String("no-statistics")},
+						},
+					},
+				},
+			})
+			assert.NoError(t, err)
+			w.Write(data)
+		}))
+	defer server.Close()
+
+	c := NewUsageClient(&http.Client{})
+	usage, err := c.GetResourceUsage(
+		context.Background(),
+		strings.TrimPrefix(server.URL, "http://"),
+		_frameworkID)
+	assert.NoError(t, err)
+	assert.Len(t, usage, 1)
+	assert.True(t, proto.Equal(statistics, usage[_executorID]))
+}
+
+// TestGetResourceUsageFailure tests getting the resource usage from an
+// agent failing the call.
+func TestGetResourceUsageFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+	defer server.Close()
+
+	c := NewUsageClient(&http.Client{})
+	_, err := c.GetResourceUsage(
+		context.Background(),
+		strings.TrimPrefix(server.URL, "http://"),
+		_frameworkID)
+	assert.Error(t, err)
+}
@@ -50,6 +50,10 @@ type Config struct {
 
 	HostmapRefreshInterval time.Duration `yaml:"hostmap_refresh_interval"`
 
+	// Interval at which the hostmap is loaded again when a refresh is
+	// requested by the Mesos events, e.g. when an agent re-registered
+	HostmapEventRefreshInterval time.Duration `yaml:"hostmap_event_refresh_interval"`
+
 	// Period in sec for running host pruning
 	HostPruningPeriodSec time.Duration `yaml:"host_pruning_period_sec"`
 
@@ -75,4 +79,16 @@ type Config struct {
 	BinPacking string `yaml:"bin_packing"`
 	// Bin Packing Refresh Interval
 	BinPackingRefreshIntervalSec time.Duration `yaml:"bin_packing_refresh_interval"`
+
+	// Config of ExecTaskCommand, executing commands in the containers
+	// of the tasks
+	TaskCommand TaskCommandConfig `yaml:"task_command"`
+}
+
+// TaskCommandConfig for ExecTaskCommand
+type TaskCommandConfig struct {
+	// Token authenticating the job managers, which must send it with the
+	// "Authorization: Bearer <token>" header. ExecTaskCommand is disabled
+	// if no token is configured.
+	Token string `yaml:"token"`
 }
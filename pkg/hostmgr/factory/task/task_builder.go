@@ -17,6 +17,7 @@ package task
 import (
 	"math"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gogo/protobuf/proto"
@@ -41,13 +42,13 @@ const (
 	PelotonInstanceIDLabelKey = "peloton.instance_id"
 	// PelotonTaskIDLabelKey is the task label key for task ID
 	PelotonTaskIDLabelKey = "peloton.task_id"
+	// PelotonHostnameLabelKey is the discovery label key for the hostname
+	// of the container of the task
+	PelotonHostnameLabelKey = "peloton.hostname"
 
 	// Set default task kill grace period to 30 seconds
 	_defaultTaskKillGracePeriod = 30 * time.Second
 
-	// Default custom executor id prefix
-	_defaultCustomExecutorPrefix = "thermos-"
-
 	// Default custom executor name
 	_defaultCustomExecutorName = "AuroraExecutor"
 )
@@ -266,7 +267,12 @@ func (tb *Builder) Build(
 		taskID,
 	)
 	tb.populateKillPolicy(mesosTask, taskConfig.GetKillGracePeriodSeconds())
-	tb.populateDiscoveryInfo(mesosTask, pick.selectedPorts, jobID)
+	tb.populateDiscoveryInfo(
+		mesosTask,
+		pick.selectedPorts,
+		jobID,
+		taskConfig.GetContainer().GetHostname(),
+	)
 	tb.populateCommandInfo(
 		mesosTask,
 		taskConfig.GetCommand(),
@@ -323,6 +329,13 @@ func populateReservationVolumeInfo(
 	return resources, nil
 }
 
+// GetTaskIDFromExecutorID returns the ID of the Mesos task of an executor
+// launched by Peloton. The ID of a custom executor is the ID of its task
+// with a prefix, and the ID of the command executor the ID of its task.
+func GetTaskIDFromExecutorID(executorID string) string {
+	return strings.TrimPrefix(executorID, util.CustomExecutorIDPrefix)
+}
+
 // populateExecutorInfo sets up the ExecutorInfo of a Mesos task and copys
 // executor data to Mesos task if present.
 func (tb *Builder) populateExecutorInfo(
@@ -340,7 +353,7 @@ func (tb *Builder) populateExecutorInfo(
 	// Make a deep copy of pass through fields to avoid changing input.
 	executorInfo := proto.Clone(executor).(*mesos.ExecutorInfo)
 
-	executorIDValue := _defaultCustomExecutorPrefix + taskID.GetValue()
+	executorIDValue := util.CreateExecutorID(taskID.GetValue(), executorInfo)
 	executorInfo.ExecutorId = &mesos.ExecutorID{
 		Value: &executorIDValue,
 	}
@@ -508,43 +521,55 @@ func (tb *Builder) populateKillPolicy(mesosTask *mesos.TaskInfo,
 }
 
 // populateDiscoveryInfo populates the `DiscoveryInfo` field of the task
-// so service discovery integration can find which ports the task is using.
+// so service discovery integration can find which ports the task is using,
+// and the hostname of its container if it is set.
 func (tb *Builder) populateDiscoveryInfo(
 	mesosTask *mesos.TaskInfo,
 	selectedPorts map[string]uint32,
 	jobID string,
+	hostname string,
 ) {
-	if len(selectedPorts) == 0 {
+	if len(selectedPorts) == 0 && len(hostname) == 0 {
 		return
 	}
 
 	// Visibility field on DiscoveryInfo is required.
 	defaultVisibility := mesos.DiscoveryInfo_EXTERNAL
-	portSlice := []*mesos.Port{}
-	for name, value := range selectedPorts {
-		// NOTE: we need tmp for both name and value,
-		// as taking address during iterator is unsafe.
-		tmpName := name
-		tmpValue := value
-		portSlice = append(portSlice, &mesos.Port{
-			Name:       &tmpName,
-			Number:     &tmpValue,
-			Visibility: &defaultVisibility,
-			// TODO: Consider add protocol, visibility and labels.
-		})
-	}
 
 	// Note that this overrides any DiscoveryInfo even if it's in input.
 	mesosTask.Discovery = &mesos.DiscoveryInfo{
 		Name:       &jobID,
 		Visibility: &defaultVisibility,
-		Ports: &mesos.Ports{
-			Ports: portSlice,
-		},
 		// TODO:
-		// 1. add Environment, Location, Version, and Labels;
+		// 1. add Environment, Location and Version;
 		// 2. Determine how to find this in bridge.
 	}
+
+	if len(selectedPorts) != 0 {
+		portSlice := []*mesos.Port{}
+		for name, value := range selectedPorts {
+			// NOTE: we need tmp for both name and value,
+			// as taking address during iterator is unsafe.
+			tmpName := name
+			tmpValue := value
+			portSlice = append(portSlice, &mesos.Port{
+				Name:       &tmpName,
+				Number:     &tmpValue,
+				Visibility: &defaultVisibility,
+				// TODO: Consider add protocol, visibility and labels.
+			})
+		}
+		mesosTask.Discovery.Ports = &mesos.Ports{
+			Ports: portSlice,
+		}
+	}
+
+	if len(hostname) != 0 {
+		key := PelotonHostnameLabelKey
+		mesosTask.Discovery.Labels = &mesos.Labels{
+			Labels: []*mesos.Label{{Key: &key, Value: &hostname}},
+		}
+	}
 }
 
 // populateHealthCheck properly sets up the health check part of a Mesos task.
@@ -199,6 +199,33 @@ func (suite *BuilderTestSuite) TestNoPortTasks() {
 	suite.Equal(err, ErrNotEnoughResource)
 }
 
+// TestContainerHostname tests that the hostname of the container is
+// launched with the task and published in its discovery info.
+func (suite *BuilderTestSuite) TestContainerHostname() {
+	resources := suite.getResources(1)
+	builder := NewBuilder(resources)
+	tids := suite.createTestTaskIDs(1)
+	config := createTestTaskConfigs(1)[0]
+	hostname := "kafka-0"
+	containerType := mesos.ContainerInfo_MESOS
+	config.Container = &mesos.ContainerInfo{
+		Type:     &containerType,
+		Hostname: &hostname,
+	}
+
+	info, err := builder.Build(&hostsvc.LaunchableTask{
+		TaskId: tids[0],
+		Config: config,
+	}, nil, nil)
+	suite.NoError(err)
+	suite.Equal(hostname, info.GetContainer().GetHostname())
+	suite.Nil(info.GetDiscovery().GetPorts())
+	suite.Len(info.GetDiscovery().GetLabels().GetLabels(), 1)
+	label := info.GetDiscovery().GetLabels().GetLabels()[0]
+	suite.Equal(PelotonHostnameLabelKey, label.GetKey())
+	suite.Equal(hostname, label.GetValue())
+}
+
 // This tests several tasks requiring ports can be created.
 func (suite *BuilderTestSuite) TestPortTasks() {
 	portToRole := map[uint32]string{
@@ -943,6 +970,13 @@ func (suite *BuilderTestSuite) TestExtractScalarResourcesOverflow() {
 	suite.Error(err)
 }
 
+// TestGetTaskIDFromExecutorID tests getting the task ID of the executors
+// launched by the builder
+func (suite *BuilderTestSuite) TestGetTaskIDFromExecutorID() {
+	suite.Equal("task-1", GetTaskIDFromExecutorID("thermos-task-1"))
+	suite.Equal("task-1", GetTaskIDFromExecutorID("task-1"))
+}
+
 func TestBuilderTestSuite(t *testing.T) {
 	suite.Run(t, new(BuilderTestSuite))
 }
@@ -16,13 +16,16 @@ package hostmgr
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
 
 	mesos "github.com/uber/peloton/.gen/mesos/v1"
+	mesos_agent "github.com/uber/peloton/.gen/mesos/v1/agent"
 	sched "github.com/uber/peloton/.gen/mesos/v1/scheduler"
 	hpb "github.com/uber/peloton/.gen/peloton/api/v0/host"
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
@@ -36,6 +39,7 @@ import (
 	"github.com/uber/peloton/pkg/common/reservation"
 	"github.com/uber/peloton/pkg/common/stringset"
 	"github.com/uber/peloton/pkg/common/util"
+	"github.com/uber/peloton/pkg/hostmgr/agent"
 	"github.com/uber/peloton/pkg/hostmgr/config"
 	"github.com/uber/peloton/pkg/hostmgr/factory/operation"
 	"github.com/uber/peloton/pkg/hostmgr/factory/task"
@@ -53,16 +57,21 @@ import (
 	hmutil "github.com/uber/peloton/pkg/hostmgr/util"
 	"github.com/uber/peloton/pkg/storage"
 
+	"github.com/gogo/protobuf/proto"
 	log "github.com/sirupsen/logrus"
 	"github.com/uber-go/tally"
 	"go.uber.org/multierr"
 	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/yarpcerrors"
 )
 
 const (
 	// This is the number of completed reservations which
 	// will be fetched in one call from the reserver.
 	_completedReservationLimit = 10
+
+	// The port of the Mesos agents if it is not part of their PID.
+	_defaultAgentPort = "5051"
 )
 
 // validation errors
@@ -97,6 +106,9 @@ type ServiceHandler struct {
 	slackResourceTypes     []string
 	maintenanceHostInfoMap host.MaintenanceHostInfoMap
 	taskStateManager       taskStateManager.StateManager
+	agentExecClient        agent.ExecClient
+	agentUsageClient       agent.UsageClient
+	taskCommandToken       string
 }
 
 // NewServiceHandler creates a new ServiceHandler.
@@ -128,6 +140,9 @@ func NewServiceHandler(
 		slackResourceTypes:     slackResourceTypes,
 		maintenanceHostInfoMap: maintenanceHostInfoMap,
 		taskStateManager:       taskStateManager,
+		agentExecClient:        agent.NewExecClient(&http.Client{}),
+		agentUsageClient:       agent.NewUsageClient(&http.Client{}),
+		taskCommandToken:       hmConfig.TaskCommand.Token,
 	}
 	// Creating Reserver object for handler
 	handler.reserver = reserver.NewReserver(
@@ -972,7 +987,8 @@ func (h *ServiceHandler) KillAndReserveTasks(
 	}
 
 	// then kill the tasks
-	invalidTaskIDs, killFailure := h.killTasks(ctx, taskIDs)
+	invalidTaskIDs, killFailure := h.killTasks(
+		ctx, taskIDs, body.GetKillPolicy())
 	if invalidTaskIDs == nil && killFailure == nil {
 		return &hostsvc.KillAndReserveTasksResponse{}, nil
 	}
@@ -1009,7 +1025,8 @@ func (h *ServiceHandler) KillTasks(
 
 	log.WithField("request", body).Debug("KillTasks called.")
 
-	invalidTaskIDs, killFailure := h.killTasks(ctx, body.GetTaskIds())
+	invalidTaskIDs, killFailure := h.killTasks(
+		ctx, body.GetTaskIds(), body.GetKillPolicy())
 
 	if invalidTaskIDs != nil || killFailure != nil {
 		return &hostsvc.KillTasksResponse{
@@ -1023,9 +1040,12 @@ func (h *ServiceHandler) KillTasks(
 	return &hostsvc.KillTasksResponse{}, nil
 }
 
+// killTasks kills the tasks in Mesos, with the kill policy overriding the
+// one the tasks were launched with if it is set.
 func (h *ServiceHandler) killTasks(
 	ctx context.Context,
-	taskIds []*mesos.TaskID) (
+	taskIds []*mesos.TaskID,
+	killPolicy *mesos.KillPolicy) (
 	*hostsvc.InvalidTaskIDs, *hostsvc.KillFailure) {
 	if len(taskIds) == 0 {
 		return &hostsvc.InvalidTaskIDs{Message: "Empty task ids"}, nil
@@ -1044,7 +1064,8 @@ func (h *ServiceHandler) killTasks(
 				FrameworkId: h.frameworkInfoProvider.GetFrameworkID(ctx),
 				Type:        &callType,
 				Kill: &sched.Call_Kill{
-					TaskId: taskID,
+					TaskId:     taskID,
+					KillPolicy: killPolicy,
 				},
 			}
 
@@ -1416,17 +1437,31 @@ func (h *ServiceHandler) GetMesosAgentInfo(
 	request *hostsvc.GetMesosAgentInfoRequest,
 ) (*hostsvc.GetMesosAgentInfoResponse, error) {
 	r := &hostsvc.GetMesosAgentInfoResponse{}
-	hostname := request.GetHostname()
+	hostnames := request.GetHostnames()
+	if request.GetHostname() != "" {
+		hostnames = append([]string{request.GetHostname()}, hostnames...)
+	}
 
 	agentMap := host.GetAgentMap()
 	if agentMap != nil {
-		if hostname != "" {
-			if info, ok := agentMap.RegisteredAgents[hostname]; ok {
-				r.Agents = append(r.Agents, info)
-			} else {
+		if len(hostnames) != 0 {
+			var notFound []string
+			for _, hostname := range hostnames {
+				if info, ok := agentMap.RegisteredAgents[hostname]; ok {
+					r.Agents = append(r.Agents, info)
+				} else {
+					notFound = append(notFound, hostname)
+				}
+			}
+			if len(notFound) != 0 {
+				message := "host not found"
+				if len(hostnames) > 1 {
+					message = fmt.Sprintf(
+						"hosts not found: %s", strings.Join(notFound, ","))
+				}
 				r.Error = &hostsvc.GetMesosAgentInfoResponse_Error{
 					HostNotFound: &hostsvc.HostNotFound{
-						Message: "host not found",
+						Message: message,
 					},
 				}
 			}
@@ -1506,3 +1541,195 @@ func toHostStatus(hostStatus summary.HostStatus) string {
 	}
 	return status
 }
+
+// ExecTaskCommand executes a command in the container of a running task,
+// and streams its output until the command exits.
+func (h *ServiceHandler) ExecTaskCommand(
+	request *hostsvc.ExecTaskCommandRequest,
+	stream hostsvc.InternalHostServiceServiceExecTaskCommandYARPCServer,
+) error {
+	h.metrics.ExecTaskCommand.Inc(1)
+	ctx := stream.Context()
+	if err := h.authenticateTaskCommand(ctx); err != nil {
+		h.metrics.ExecTaskCommandFail.Inc(1)
+		return err
+	}
+	if request.GetHostname() == "" {
+		h.metrics.ExecTaskCommandFail.Inc(1)
+		return yarpcerrors.InvalidArgumentErrorf("%s", errEmptyHostName)
+	}
+	if request.GetTaskId().GetValue() == "" || request.GetCommand() == "" {
+		h.metrics.ExecTaskCommandFail.Inc(1)
+		return yarpcerrors.InvalidArgumentErrorf(
+			"task id and command should be provided")
+	}
+
+	address, err := getAgentAddress(request.GetHostname())
+	if err != nil {
+		h.metrics.ExecTaskCommandFail.Inc(1)
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"caller":   yarpcCaller(ctx),
+		"hostname": request.GetHostname(),
+		"task_id":  request.GetTaskId().GetValue(),
+		"command":  request.GetCommand(),
+	}).Info("executing task command")
+
+	exitCode, err := h.agentExecClient.Exec(
+		ctx,
+		address,
+		h.frameworkInfoProvider.GetFrameworkID(ctx).GetValue(),
+		request.GetTaskId().GetValue(),
+		&mesos.CommandInfo{
+			Shell: proto.Bool(true),
+			Value: proto.String(request.GetCommand()),
+		},
+		func(data *mesos_agent.ProcessIO_Data) error {
+			response := &hostsvc.ExecTaskCommandResponse{}
+			switch data.GetType() {
+			case mesos_agent.ProcessIO_Data_STDOUT:
+				response.Stdout = data.GetData()
+			case mesos_agent.ProcessIO_Data_STDERR:
+				response.Stderr = data.GetData()
+			default:
+				return nil
+			}
+			return stream.Send(response)
+		})
+	if err == agent.ErrContainerNotFound {
+		h.metrics.ExecTaskCommandFail.Inc(1)
+		return yarpcerrors.NotFoundErrorf(
+			"task %s is not running on host %s",
+			request.GetTaskId().GetValue(), request.GetHostname())
+	}
+	if err != nil {
+		h.metrics.ExecTaskCommandFail.Inc(1)
+		log.WithError(err).
+			WithField("request", request).
+			Info("failed to execute task command")
+		return yarpcerrors.InternalErrorf(
+			"failed to execute command on host %s: %v",
+			request.GetHostname(), err)
+	}
+
+	return stream.Send(&hostsvc.ExecTaskCommandResponse{
+		Exited:   true,
+		ExitCode: exitCode,
+	})
+}
+
+// authenticateTaskCommand returns an error unless the call has the task
+// command token of the config in its authorization header.
+func (h *ServiceHandler) authenticateTaskCommand(ctx context.Context) error {
+	if len(h.taskCommandToken) == 0 {
+		return yarpcerrors.UnimplementedErrorf("task commands are not enabled")
+	}
+
+	var authorization string
+	if call := yarpc.CallFromContext(ctx); call != nil {
+		authorization = call.Header("authorization")
+	}
+
+	if subtle.ConstantTimeCompare(
+		[]byte(authorization),
+		[]byte("Bearer "+h.taskCommandToken)) != 1 {
+		log.WithField("caller", yarpcCaller(ctx)).
+			Warn("unauthenticated task command rejected")
+		return yarpcerrors.UnauthenticatedErrorf("invalid task command token")
+	}
+	return nil
+}
+
+// yarpcCaller returns the name of the caller of the call in the context.
+func yarpcCaller(ctx context.Context) string {
+	if call := yarpc.CallFromContext(ctx); call != nil {
+		return call.Caller()
+	}
+	return ""
+}
+
+// GetTasksResourceUsage returns the resource usage of the containers of
+// the tasks running on an agent.
+func (h *ServiceHandler) GetTasksResourceUsage(
+	ctx context.Context,
+	request *hostsvc.GetTasksResourceUsageRequest,
+) (*hostsvc.GetTasksResourceUsageResponse, error) {
+	h.metrics.GetTasksResourceUsage.Inc(1)
+	if request.GetHostname() == "" {
+		h.metrics.GetTasksResourceUsageFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf("%s", errEmptyHostName)
+	}
+
+	address, err := getAgentAddress(request.GetHostname())
+	if err != nil {
+		h.metrics.GetTasksResourceUsageFail.Inc(1)
+		return nil, err
+	}
+
+	usage, err := h.agentUsageClient.GetResourceUsage(
+		ctx,
+		address,
+		h.frameworkInfoProvider.GetFrameworkID(ctx).GetValue())
+	if err != nil {
+		h.metrics.GetTasksResourceUsageFail.Inc(1)
+		log.WithError(err).
+			WithField("request", request).
+			Info("failed to get tasks resource usage")
+		return nil, yarpcerrors.InternalErrorf(
+			"failed to get resource usage on host %s: %v",
+			request.GetHostname(), err)
+	}
+
+	// the usage is keyed by executor ID, which differs from the task ID
+	// for the custom executors
+	usageByTask := make(map[string]*mesos.ResourceStatistics, len(usage))
+	for executorID, statistics := range usage {
+		usageByTask[task.GetTaskIDFromExecutorID(executorID)] = statistics
+	}
+	usage = usageByTask
+
+	response := &hostsvc.GetTasksResourceUsageResponse{}
+	if len(request.GetTaskIds()) == 0 {
+		for taskID, statistics := range usage {
+			response.Usages = append(response.Usages, &hostsvc.TaskResourceUsage{
+				TaskId:     &mesos.TaskID{Value: proto.String(taskID)},
+				Statistics: statistics,
+			})
+		}
+		return response, nil
+	}
+	for _, taskID := range request.GetTaskIds() {
+		statistics, ok := usage[taskID.GetValue()]
+		if !ok {
+			continue
+		}
+		response.Usages = append(response.Usages, &hostsvc.TaskResourceUsage{
+			TaskId:     taskID,
+			Statistics: statistics,
+		})
+	}
+	return response, nil
+}
+
+// getAgentAddress returns the address of the operator API of the agent
+// registered with the hostname.
+func getAgentAddress(hostname string) (string, error) {
+	agentMap := host.GetAgentMap()
+	if agentMap == nil {
+		return "", yarpcerrors.UnavailableErrorf("agents are not loaded yet")
+	}
+	info, ok := agentMap.RegisteredAgents[hostname]
+	if !ok {
+		return "", yarpcerrors.NotFoundErrorf("host %s not found", hostname)
+	}
+	ip, port, err := util.ExtractIPAndPortFromMesosAgentPID(info.GetPid())
+	if err != nil {
+		return "", yarpcerrors.InternalErrorf("%s", err)
+	}
+	if port == "" {
+		port = _defaultAgentPort
+	}
+	return fmt.Sprintf("%s:%s", ip, port), nil
+}
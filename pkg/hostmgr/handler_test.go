@@ -24,6 +24,7 @@ import (
 	"time"
 
 	mesos "github.com/uber/peloton/.gen/mesos/v1"
+	mesos_agent "github.com/uber/peloton/.gen/mesos/v1/agent"
 	mesos_maintenance "github.com/uber/peloton/.gen/mesos/v1/maintenance"
 	mesos_master "github.com/uber/peloton/.gen/mesos/v1/master"
 	sched "github.com/uber/peloton/.gen/mesos/v1/scheduler"
@@ -33,10 +34,13 @@ import (
 	"github.com/uber/peloton/.gen/peloton/api/v0/volume"
 	pb_eventstream "github.com/uber/peloton/.gen/peloton/private/eventstream"
 	"github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc"
+	hostsvc_mocks "github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc/mocks"
 
 	"github.com/uber/peloton/pkg/common/queue"
 	"github.com/uber/peloton/pkg/common/reservation"
 	"github.com/uber/peloton/pkg/common/util"
+	"github.com/uber/peloton/pkg/hostmgr/agent"
+	agent_mocks "github.com/uber/peloton/pkg/hostmgr/agent/mocks"
 	bin_packing "github.com/uber/peloton/pkg/hostmgr/binpacking"
 	"github.com/uber/peloton/pkg/hostmgr/config"
 	"github.com/uber/peloton/pkg/hostmgr/host"
@@ -58,6 +62,8 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/suite"
 	"github.com/uber-go/tally"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/yarpc/yarpctest"
 	"golang.org/x/net/context"
 )
 
@@ -1421,6 +1427,44 @@ func (suite *HostMgrHandlerTestSuite) TestKillTask() {
 		suite.testScope.Snapshot().Counters()["kill_tasks+"].Value())
 }
 
+// Test killing task with a kill policy overriding the launch one
+func (suite *HostMgrHandlerTestSuite) TestKillTaskWithKillPolicy() {
+	defer suite.ctrl.Finish()
+
+	t1 := "t1"
+	gracePeriodNsec := int64(0)
+	killPolicy := &mesos.KillPolicy{
+		GracePeriod: &mesos.DurationInfo{Nanoseconds: &gracePeriodNsec},
+	}
+	killReq := &hostsvc.KillTasksRequest{
+		TaskIds:    []*mesos.TaskID{{Value: &t1}},
+		KillPolicy: killPolicy,
+	}
+
+	suite.provider.EXPECT().GetFrameworkID(context.Background()).Return(
+		suite.frameworkID,
+	)
+	suite.provider.EXPECT().GetMesosStreamID(context.Background()).Return(
+		_streamID,
+	)
+	suite.schedulerClient.EXPECT().
+		Call(
+			gomock.Eq(_streamID),
+			gomock.Any(),
+		).
+		Do(func(_ string, msg proto.Message) {
+			call := msg.(*sched.Call)
+			suite.Equal(sched.Call_KILL, call.GetType())
+			suite.Equal(t1, call.GetKill().GetTaskId().GetValue())
+			suite.Equal(killPolicy, call.GetKill().GetKillPolicy())
+		}).
+		Return(nil)
+
+	resp, err := suite.handler.KillTasks(rootCtx, killReq)
+	suite.NoError(err)
+	suite.Nil(resp.GetError())
+}
+
 // Test some failure cases of killing task
 func (suite *HostMgrHandlerTestSuite) TestKillTaskFailure() {
 	defer suite.ctrl.Finish()
@@ -2857,10 +2901,11 @@ func (suite *HostMgrHandlerTestSuite) TestGetMesosAgentInfo() {
 	loader.Load(nil)
 
 	testcases := []struct {
-		name     string
-		hostname string
-		result   []*mesos_master.Response_GetAgents_Agent
-		err      *hostsvc.GetMesosAgentInfoResponse_Error
+		name      string
+		hostname  string
+		hostnames []string
+		result    []*mesos_master.Response_GetAgents_Agent
+		err       *hostsvc.GetMesosAgentInfoResponse_Error
 	}{
 		{
 			name:     "specific agent",
@@ -2880,18 +2925,227 @@ func (suite *HostMgrHandlerTestSuite) TestGetMesosAgentInfo() {
 				},
 			},
 		},
+		{
+			name:      "multiple agents",
+			hostnames: []string{"id-0", "id-2"},
+			result:    AgentSlice{agentInfo[0], agentInfo[2]},
+		},
+		{
+			name:      "partially unknown agents",
+			hostname:  "id-1",
+			hostnames: []string{"foo", "bar"},
+			result:    agentInfo[1:2],
+			err: &hostsvc.GetMesosAgentInfoResponse_Error{
+				HostNotFound: &hostsvc.HostNotFound{
+					Message: "hosts not found: foo,bar",
+				},
+			},
+		},
 	}
 	for _, tc := range testcases {
 		resp, err := suite.handler.GetMesosAgentInfo(rootCtx,
-			&hostsvc.GetMesosAgentInfoRequest{Hostname: tc.hostname})
+			&hostsvc.GetMesosAgentInfoRequest{
+				Hostname:  tc.hostname,
+				Hostnames: tc.hostnames,
+			})
 		suite.NoError(err, tc.name)
 		if tc.err == nil {
 			suite.Nil(resp.GetError(), tc.name)
+		} else {
+			suite.Equal(tc.err, resp.GetError(), tc.name)
+		}
+		if tc.result != nil {
 			actualAgentInfo := AgentSlice(resp.GetAgents())
 			sort.Sort(actualAgentInfo)
 			suite.EqualValues(tc.result, actualAgentInfo, tc.name)
-		} else {
-			suite.Equal(tc.err, resp.GetError(), tc.name)
 		}
 	}
 }
+
+// TestExecTaskCommand tests streaming the output and the exit code of a
+// command executed in the container of a task
+func (suite *HostMgrHandlerTestSuite) TestExecTaskCommand() {
+	response := makeAgentsResponse(1)
+	response.Agents[0].Pid = proto.String("slave(1)@10.0.0.1:5052")
+	suite.masterOperatorClient.EXPECT().Agents().Return(response, nil)
+	suite.maintenanceHostInfoMap.EXPECT().GetDrainingHostInfos(gomock.Any()).
+		Return([]*hpb.HostInfo{})
+	loader := &host.Loader{
+		OperatorClient:         suite.masterOperatorClient,
+		Scope:                  suite.testScope,
+		MaintenanceHostInfoMap: suite.maintenanceHostInfoMap,
+	}
+	loader.Load(nil)
+
+	execClient := agent_mocks.NewMockExecClient(suite.ctrl)
+	suite.handler.agentExecClient = execClient
+	suite.handler.taskCommandToken = "token"
+	stream := hostsvc_mocks.NewMockInternalHostServiceServiceExecTaskCommandYARPCServer(
+		suite.ctrl)
+	ctx := yarpctest.ContextWithCall(
+		rootCtx,
+		&yarpctest.Call{
+			Headers: map[string]string{"authorization": "Bearer token"},
+		},
+	)
+
+	stdout := mesos_agent.ProcessIO_Data_STDOUT
+	gomock.InOrder(
+		stream.EXPECT().Context().Return(ctx),
+		suite.provider.EXPECT().GetFrameworkID(ctx).
+			Return(&mesos.FrameworkID{Value: proto.String(_frameworkID)}),
+		execClient.EXPECT().
+			Exec(ctx, "10.0.0.1:5052", _frameworkID, "task-1",
+				&mesos.CommandInfo{
+					Shell: proto.Bool(true),
+					Value: proto.String("ls"),
+				}, gomock.Any()).
+			DoAndReturn(func(
+				_ context.Context,
+				_, _, _ string,
+				_ *mesos.CommandInfo,
+				send func(*mesos_agent.ProcessIO_Data) error) (int32, error) {
+				return 2, send(&mesos_agent.ProcessIO_Data{
+					Type: &stdout,
+					Data: []byte("out"),
+				})
+			}),
+		stream.EXPECT().
+			Send(&hostsvc.ExecTaskCommandResponse{Stdout: []byte("out")}).
+			Return(nil),
+		stream.EXPECT().
+			Send(&hostsvc.ExecTaskCommandResponse{Exited: true, ExitCode: 2}).
+			Return(nil),
+	)
+
+	suite.NoError(suite.handler.ExecTaskCommand(
+		&hostsvc.ExecTaskCommandRequest{
+			Hostname: "id-0",
+			TaskId:   &mesos.TaskID{Value: proto.String("task-1")},
+			Command:  "ls",
+		}, stream))
+
+	// the task is not running on the host
+	stream.EXPECT().Context().Return(ctx)
+	suite.provider.EXPECT().GetFrameworkID(ctx).
+		Return(&mesos.FrameworkID{Value: proto.String(_frameworkID)})
+	execClient.EXPECT().
+		Exec(ctx, "10.0.0.1:5052", _frameworkID, "task-2",
+			gomock.Any(), gomock.Any()).
+		Return(int32(0), agent.ErrContainerNotFound)
+	err := suite.handler.ExecTaskCommand(
+		&hostsvc.ExecTaskCommandRequest{
+			Hostname: "id-0",
+			TaskId:   &mesos.TaskID{Value: proto.String("task-2")},
+			Command:  "ls",
+		}, stream)
+	suite.True(yarpcerrors.IsNotFound(err))
+
+	// the host is unknown
+	stream.EXPECT().Context().Return(ctx)
+	err = suite.handler.ExecTaskCommand(
+		&hostsvc.ExecTaskCommandRequest{
+			Hostname: "unknown",
+			TaskId:   &mesos.TaskID{Value: proto.String("task-1")},
+			Command:  "ls",
+		}, stream)
+	suite.True(yarpcerrors.IsNotFound(err))
+	// the call does not have the task command token
+	stream.EXPECT().Context().Return(rootCtx)
+	err = suite.handler.ExecTaskCommand(
+		&hostsvc.ExecTaskCommandRequest{
+			Hostname: "id-0",
+			TaskId:   &mesos.TaskID{Value: proto.String("task-1")},
+			Command:  "ls",
+		}, stream)
+	suite.True(yarpcerrors.IsUnauthenticated(err))
+
+	// task commands are not enabled
+	suite.handler.taskCommandToken = ""
+	stream.EXPECT().Context().Return(ctx)
+	err = suite.handler.ExecTaskCommand(
+		&hostsvc.ExecTaskCommandRequest{
+			Hostname: "id-0",
+			TaskId:   &mesos.TaskID{Value: proto.String("task-1")},
+			Command:  "ls",
+		}, stream)
+	suite.True(yarpcerrors.IsUnimplemented(err))
+}
+
+// TestGetTasksResourceUsage tests getting the resource usage of the
+// containers of the tasks running on an agent
+func (suite *HostMgrHandlerTestSuite) TestGetTasksResourceUsage() {
+	response := makeAgentsResponse(1)
+	response.Agents[0].Pid = proto.String("slave(1)@10.0.0.1:5052")
+	suite.masterOperatorClient.EXPECT().Agents().Return(response, nil)
+	suite.maintenanceHostInfoMap.EXPECT().GetDrainingHostInfos(gomock.Any()).
+		Return([]*hpb.HostInfo{})
+	loader := &host.Loader{
+		OperatorClient:         suite.masterOperatorClient,
+		Scope:                  suite.testScope,
+		MaintenanceHostInfoMap: suite.maintenanceHostInfoMap,
+	}
+	loader.Load(nil)
+
+	usageClient := agent_mocks.NewMockUsageClient(suite.ctrl)
+	suite.handler.agentUsageClient = usageClient
+
+	statistics := &mesos.ResourceStatistics{
+		Timestamp:   proto.Float64(1000),
+		MemRssBytes: proto.Uint64(1024),
+	}
+	suite.provider.EXPECT().GetFrameworkID(rootCtx).
+		Return(&mesos.FrameworkID{Value: proto.String(_frameworkID)})
+	usageClient.EXPECT().
+		GetResourceUsage(rootCtx, "10.0.0.1:5052", _frameworkID).
+		Return(map[string]*mesos.ResourceStatistics{
+			"task-1":         statistics,
+			"task-3":         statistics,
+			"thermos-task-4": statistics,
+		}, nil)
+
+	resp, err := suite.handler.GetTasksResourceUsage(
+		rootCtx,
+		&hostsvc.GetTasksResourceUsageRequest{
+			Hostname: "id-0",
+			TaskIds: []*mesos.TaskID{
+				{Value: proto.String("task-1")},
+				{Value: proto.String("task-2")},
+				{Value: proto.String("task-4")},
+			},
+		})
+	suite.NoError(err)
+	suite.Equal([]*hostsvc.TaskResourceUsage{
+		{
+			TaskId:     &mesos.TaskID{Value: proto.String("task-1")},
+			Statistics: statistics,
+		},
+		{
+			TaskId:     &mesos.TaskID{Value: proto.String("task-4")},
+			Statistics: statistics,
+		},
+	}, resp.GetUsages())
+
+	// the agent fails the call
+	suite.provider.EXPECT().GetFrameworkID(rootCtx).
+		Return(&mesos.FrameworkID{Value: proto.String(_frameworkID)})
+	usageClient.EXPECT().
+		GetResourceUsage(rootCtx, "10.0.0.1:5052", _frameworkID).
+		Return(nil, errors.New("agent unavailable"))
+	_, err = suite.handler.GetTasksResourceUsage(
+		rootCtx,
+		&hostsvc.GetTasksResourceUsageRequest{Hostname: "id-0"})
+	suite.True(yarpcerrors.IsInternal(err))
+
+	// the host is unknown
+	_, err = suite.handler.GetTasksResourceUsage(
+		rootCtx,
+		&hostsvc.GetTasksResourceUsageRequest{Hostname: "unknown"})
+	suite.True(yarpcerrors.IsNotFound(err))
+
+	// the host is not provided
+	_, err = suite.handler.GetTasksResourceUsage(
+		rootCtx,
+		&hostsvc.GetTasksResourceUsageRequest{})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
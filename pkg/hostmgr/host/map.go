@@ -41,6 +41,10 @@ type AgentMap struct {
 
 	Capacity      scalar.Resources
 	SlackCapacity scalar.Resources
+
+	// hostnames of the registered agents left out of the map
+	// as their hosts are draining
+	drainingHosts map[string]struct{}
 }
 
 // ReportCapacityMetrics into given metric scope.
@@ -56,6 +60,10 @@ func (a *AgentMap) ReportCapacityMetrics(scope tally.Scope) {
 // Atomic pointer to singleton instance.
 var agentInfoMap atomic.Value
 
+// refreshRequested is set when the agent map needs to be loaded again
+// before its next periodic refresh.
+var refreshRequested uatomic.Bool
+
 // GetAgentInfo return agent info from global map.
 func GetAgentInfo(hostname string) *mesos.AgentInfo {
 	m := GetAgentMap()
@@ -81,6 +89,32 @@ func GetAgentMap() *AgentMap {
 	return v
 }
 
+// RequestRefresh requests the agent map to be loaded again from the Mesos
+// master, e.g. when an agent registered, re-registered or was removed.
+func RequestRefresh() {
+	refreshRequested.Store(true)
+}
+
+// RefreshIfAgentChanged requests the agent map to be loaded again if the
+// agent on the host is not the one in the agent map, i.e. the agent has
+// registered or re-registered since the agent map was loaded.
+func RefreshIfAgentChanged(hostname string, agentID string) {
+	m := GetAgentMap()
+	if m == nil {
+		return
+	}
+	if _, ok := m.drainingHosts[hostname]; ok {
+		return
+	}
+	if m.RegisteredAgents[hostname].GetAgentInfo().GetId().GetValue() != agentID {
+		log.WithFields(log.Fields{
+			"hostname": hostname,
+			"agent_id": agentID,
+		}).Debug("agent changed, refreshing agent map")
+		RequestRefresh()
+	}
+}
+
 // Loader loads hostmap from Mesos and stores in global singleton.
 type Loader struct {
 	sync.Mutex
@@ -90,8 +124,23 @@ type Loader struct {
 	Scope                  tally.Scope
 }
 
+// LoadIfRequested loads hostmap into singleton if a refresh has been
+// requested since it was last loaded.
+func (loader *Loader) LoadIfRequested(running *uatomic.Bool) {
+	if refreshRequested.Load() {
+		loader.Load(running)
+	}
+}
+
 // Load hostmap into singleton.
 func (loader *Loader) Load(_ *uatomic.Bool) {
+	loader.Lock()
+	defer loader.Unlock()
+
+	// changes requesting a refresh from now on may not be in the
+	// agents returned by the master
+	refreshRequested.Store(false)
+
 	agents, err := loader.OperatorClient.Agents()
 	if err != nil {
 		log.WithError(err).Warn("Cannot refresh agent map from master")
@@ -102,6 +151,7 @@ func (loader *Loader) Load(_ *uatomic.Bool) {
 		RegisteredAgents: make(map[string]*mesos_master.Response_GetAgents_Agent),
 		Capacity:         scalar.Resources{},
 		SlackCapacity:    scalar.Resources{},
+		drainingHosts:    make(map[string]struct{}),
 	}
 
 	outchan := make(chan func() (scalar.Resources, scalar.Resources))
@@ -110,6 +160,7 @@ func (loader *Loader) Load(_ *uatomic.Bool) {
 	for _, agent := range agents.GetAgents() {
 		hostname := agent.GetAgentInfo().GetHostname()
 		if len(loader.MaintenanceHostInfoMap.GetDrainingHostInfos([]string{hostname})) != 0 {
+			m.drainingHosts[hostname] = struct{}{}
 			continue
 		}
 		m.RegisteredAgents[hostname] = agent
@@ -163,6 +163,52 @@ func (suite *HostMapTestSuite) TestRefresh() {
 	suite.Equal(float64(numRegisteredAgents*_defaultResourceValue), gauges["gpus+"].Value())
 }
 
+// TestRefreshIfAgentChanged tests the agent map is loaded again only
+// when a refresh is requested because of a changed agent.
+func (suite *HostMapTestSuite) TestRefreshIfAgentChanged() {
+	defer suite.ctrl.Finish()
+
+	mockMaintenanceMap := hm.NewMockMaintenanceHostInfoMap(suite.ctrl)
+	loader := &Loader{
+		OperatorClient:         suite.operatorClient,
+		Scope:                  suite.testScope,
+		MaintenanceHostInfoMap: mockMaintenanceMap,
+	}
+
+	response := makeAgentsResponse(2)
+	drainingHost := response.Agents[1].GetAgentInfo().GetHostname()
+	mockMaintenanceMap.EXPECT().
+		GetDrainingHostInfos([]string{"id-0"}).
+		Return([]*host.HostInfo{}).
+		Times(3)
+	mockMaintenanceMap.EXPECT().
+		GetDrainingHostInfos([]string{drainingHost}).
+		Return([]*host.HostInfo{
+			{
+				Hostname: drainingHost,
+				State:    host.HostState_HOST_STATE_DRAINING,
+			},
+		}).
+		Times(3)
+	suite.operatorClient.EXPECT().Agents().Return(response, nil).Times(3)
+	loader.Load(nil)
+
+	// same agent, and agent on a draining host
+	RefreshIfAgentChanged("id-0", "")
+	RefreshIfAgentChanged(drainingHost, "agent-1")
+	loader.LoadIfRequested(nil)
+
+	// agent re-registered with a new agent id
+	RefreshIfAgentChanged("id-0", "agent-0")
+	loader.LoadIfRequested(nil)
+	loader.LoadIfRequested(nil)
+
+	// agent removed
+	RequestRefresh()
+	loader.LoadIfRequested(nil)
+	loader.LoadIfRequested(nil)
+}
+
 func (suite *HostMapTestSuite) TestMaintenanceHostInfoMap() {
 	maintenanceHostInfoMap := NewMaintenanceHostInfoMap(tally.NoopScope)
 	suite.NotNil(maintenanceHostInfoMap)
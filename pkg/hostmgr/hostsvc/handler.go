@@ -17,6 +17,7 @@ package hostsvc
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	mesos "github.com/uber/peloton/.gen/mesos/v1"
@@ -29,6 +30,7 @@ import (
 	"github.com/uber/peloton/pkg/hostmgr/host"
 	"github.com/uber/peloton/pkg/hostmgr/mesos/yarpc/encoding/mpb"
 	"github.com/uber/peloton/pkg/hostmgr/queue"
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/uber-go/tally"
@@ -41,6 +43,11 @@ type serviceHandler struct {
 	metrics                *Metrics
 	operatorMasterClient   mpb.MasterOperatorClient
 	maintenanceHostInfoMap host.MaintenanceHostInfoMap
+
+	// host groups drained by the rollout systems, the lock serializes
+	// the changes made to them
+	hostGroupsLock sync.Mutex
+	hostGroupOps   ormobjects.HostGroupOps
 }
 
 // InitServiceHandler initializes the HostService
@@ -49,12 +56,14 @@ func InitServiceHandler(
 	parent tally.Scope,
 	operatorMasterClient mpb.MasterOperatorClient,
 	maintenanceQueue queue.MaintenanceQueue,
-	hostInfoMap host.MaintenanceHostInfoMap) {
+	hostInfoMap host.MaintenanceHostInfoMap,
+	hostGroupOps ormobjects.HostGroupOps) {
 	handler := &serviceHandler{
 		maintenanceQueue:       maintenanceQueue,
 		metrics:                NewMetrics(parent.SubScope("hostsvc")),
 		operatorMasterClient:   operatorMasterClient,
 		maintenanceHostInfoMap: hostInfoMap,
+		hostGroupOps:           hostGroupOps,
 	}
 	d.Register(host_svc.BuildHostServiceYARPCProcedures(handler))
 	log.Info("Hostsvc handler initialized")
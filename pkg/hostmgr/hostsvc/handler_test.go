@@ -30,6 +30,7 @@ import (
 	hm "github.com/uber/peloton/pkg/hostmgr/host/mocks"
 	ym "github.com/uber/peloton/pkg/hostmgr/mesos/yarpc/encoding/mpb/mocks"
 	qm "github.com/uber/peloton/pkg/hostmgr/queue/mocks"
+	objectmocks "github.com/uber/peloton/pkg/storage/objects/mocks"
 
 	"github.com/golang/mock/gomock"
 	log "github.com/sirupsen/logrus"
@@ -50,6 +51,7 @@ type HostSvcHandlerTestSuite struct {
 	mockMasterOperatorClient *ym.MockMasterOperatorClient
 	mockMaintenanceQueue     *qm.MockMaintenanceQueue
 	mockMaintenanceMap       *hm.MockMaintenanceHostInfoMap
+	mockHostGroupOps         *objectmocks.MockHostGroupOps
 }
 
 func (suite *HostSvcHandlerTestSuite) SetupSuite() {
@@ -119,6 +121,8 @@ func (suite *HostSvcHandlerTestSuite) SetupTest() {
 	suite.handler.operatorMasterClient = suite.mockMasterOperatorClient
 	suite.handler.maintenanceQueue = suite.mockMaintenanceQueue
 	suite.handler.maintenanceHostInfoMap = suite.mockMaintenanceMap
+	suite.mockHostGroupOps = objectmocks.NewMockHostGroupOps(suite.mockCtrl)
+	suite.handler.hostGroupOps = suite.mockHostGroupOps
 
 	response := suite.makeAgentsResponse()
 	loader := &host.Loader{
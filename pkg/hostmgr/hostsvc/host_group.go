@@ -0,0 +1,251 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostsvc
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	hpb "github.com/uber/peloton/.gen/peloton/api/v0/host"
+	host_svc "github.com/uber/peloton/.gen/peloton/api/v0/host/svc"
+
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
+
+	"github.com/gocql/gocql"
+	log "github.com/sirupsen/logrus"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// _maxCompletedHostGroups is the number of completed host groups kept
+// around, so that the rollout systems can still get their status
+const _maxCompletedHostGroups = 1000
+
+// StartHostGroupDrain starts maintenance on a group of hosts. Only one
+// group can be drained at a time, the next group can be drained once the
+// current one is completed by CompleteHostGroupDrain. The groups are
+// persisted, so that a host manager gaining leadership can complete the
+// drain of the current group.
+func (m *serviceHandler) StartHostGroupDrain(
+	ctx context.Context,
+	request *host_svc.StartHostGroupDrainRequest,
+) (*host_svc.StartHostGroupDrainResponse, error) {
+	m.metrics.StartHostGroupDrainAPI.Inc(1)
+
+	if len(request.GetGroup()) == 0 || len(request.GetHostnames()) == 0 {
+		m.metrics.StartHostGroupDrainFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"group and hostnames must be provided")
+	}
+
+	// the lock is held while the hosts are put into maintenance, so that
+	// two groups can not be started concurrently
+	m.hostGroupsLock.Lock()
+	defer m.hostGroupsLock.Unlock()
+
+	groups, err := m.hostGroupOps.GetAll(ctx)
+	if err != nil {
+		m.metrics.StartHostGroupDrainFail.Inc(1)
+		return nil, err
+	}
+	for _, g := range groups {
+		if !g.Completed() {
+			m.metrics.StartHostGroupDrainFail.Inc(1)
+			return nil, yarpcerrors.FailedPreconditionErrorf(
+				"host group %s is being drained", g.Name)
+		}
+	}
+
+	// the group is persisted before its hosts are put into maintenance,
+	// so that the hosts are never DOWN without a group to bring them up
+	err = m.hostGroupOps.Create(ctx, &ormobjects.HostGroup{
+		Name:      request.GetGroup(),
+		Hostnames: request.GetHostnames(),
+		StartTime: time.Now().UTC(),
+	})
+	if err != nil {
+		m.metrics.StartHostGroupDrainFail.Inc(1)
+		return nil, err
+	}
+
+	_, err = m.StartMaintenance(ctx, &host_svc.StartMaintenanceRequest{
+		Hostnames: request.GetHostnames(),
+	})
+	if err != nil {
+		if err := m.hostGroupOps.Delete(ctx, request.GetGroup()); err != nil {
+			log.WithError(err).
+				WithField("group", request.GetGroup()).
+				Error("Failed to delete host group which failed to drain")
+		}
+		m.metrics.StartHostGroupDrainFail.Inc(1)
+		return nil, err
+	}
+
+	m.evictCompletedHostGroups(ctx, groups)
+	log.WithField("group", request.GetGroup()).
+		WithField("hostnames", request.GetHostnames()).
+		Info("Started draining host group")
+
+	m.metrics.StartHostGroupDrainSuccess.Inc(1)
+	return &host_svc.StartHostGroupDrainResponse{}, nil
+}
+
+// GetHostGroupDrainStatus returns the state of each host of a group, the
+// group is drained once all of its hosts are DOWN.
+func (m *serviceHandler) GetHostGroupDrainStatus(
+	ctx context.Context,
+	request *host_svc.GetHostGroupDrainStatusRequest,
+) (*host_svc.GetHostGroupDrainStatusResponse, error) {
+	m.metrics.GetHostGroupDrainStatusAPI.Inc(1)
+
+	g, err := m.getHostGroup(ctx, request.GetGroup())
+	if err != nil {
+		m.metrics.GetHostGroupDrainStatusFail.Inc(1)
+		return nil, err
+	}
+
+	resp := &host_svc.GetHostGroupDrainStatusResponse{
+		StartTime: g.StartTime.Format(time.RFC3339),
+	}
+	if g.Completed() {
+		resp.State = host_svc.HostGroupDrainState_HOST_GROUP_DRAIN_STATE_COMPLETED
+		resp.CompletionTime = g.CompletionTime.Format(time.RFC3339)
+		for _, hostname := range g.Hostnames {
+			resp.HostInfos = append(resp.HostInfos, &hpb.HostInfo{
+				Hostname: hostname,
+				State:    hpb.HostState_HOST_STATE_UP,
+			})
+		}
+		m.metrics.GetHostGroupDrainStatusSuccess.Inc(1)
+		return resp, nil
+	}
+
+	hostInfos := make(map[string]*hpb.HostInfo)
+	for _, hostInfo := range m.maintenanceHostInfoMap.GetDrainingHostInfos(g.Hostnames) {
+		hostInfos[hostInfo.GetHostname()] = hostInfo
+	}
+	for _, hostInfo := range m.maintenanceHostInfoMap.GetDownHostInfos(g.Hostnames) {
+		hostInfos[hostInfo.GetHostname()] = hostInfo
+	}
+
+	resp.State = host_svc.HostGroupDrainState_HOST_GROUP_DRAIN_STATE_DRAINED
+	for _, hostname := range g.Hostnames {
+		hostInfo, ok := hostInfos[hostname]
+		if !ok {
+			// the host was brought back up outside of the rollout
+			hostInfo = &hpb.HostInfo{
+				Hostname: hostname,
+				State:    hpb.HostState_HOST_STATE_UP,
+			}
+		}
+		if hostInfo.GetState() != hpb.HostState_HOST_STATE_DOWN {
+			resp.State = host_svc.HostGroupDrainState_HOST_GROUP_DRAIN_STATE_DRAINING
+		}
+		resp.HostInfos = append(resp.HostInfos, hostInfo)
+	}
+
+	m.metrics.GetHostGroupDrainStatusSuccess.Inc(1)
+	return resp, nil
+}
+
+// CompleteHostGroupDrain completes maintenance on the hosts of a drained
+// group once they have been upgraded, which lets the next group be
+// drained.
+func (m *serviceHandler) CompleteHostGroupDrain(
+	ctx context.Context,
+	request *host_svc.CompleteHostGroupDrainRequest,
+) (*host_svc.CompleteHostGroupDrainResponse, error) {
+	m.metrics.CompleteHostGroupDrainAPI.Inc(1)
+
+	m.hostGroupsLock.Lock()
+	defer m.hostGroupsLock.Unlock()
+
+	g, err := m.getHostGroup(ctx, request.GetGroup())
+	if err != nil {
+		m.metrics.CompleteHostGroupDrainFail.Inc(1)
+		return nil, err
+	}
+	if g.Completed() {
+		m.metrics.CompleteHostGroupDrainSuccess.Inc(1)
+		return &host_svc.CompleteHostGroupDrainResponse{}, nil
+	}
+
+	downHosts := make(map[string]bool)
+	for _, hostInfo := range m.maintenanceHostInfoMap.GetDownHostInfos(g.Hostnames) {
+		downHosts[hostInfo.GetHostname()] = true
+	}
+	for _, hostname := range g.Hostnames {
+		if !downHosts[hostname] {
+			m.metrics.CompleteHostGroupDrainFail.Inc(1)
+			return nil, yarpcerrors.FailedPreconditionErrorf(
+				"host %s of group %s is not DOWN", hostname, request.GetGroup())
+		}
+	}
+
+	_, err = m.CompleteMaintenance(ctx, &host_svc.CompleteMaintenanceRequest{
+		Hostnames: g.Hostnames,
+	})
+	if err != nil {
+		m.metrics.CompleteHostGroupDrainFail.Inc(1)
+		return nil, err
+	}
+
+	err = m.hostGroupOps.Complete(ctx, request.GetGroup(), time.Now().UTC())
+	if err != nil {
+		m.metrics.CompleteHostGroupDrainFail.Inc(1)
+		return nil, err
+	}
+	log.WithField("group", request.GetGroup()).
+		Info("Completed host group drain")
+
+	m.metrics.CompleteHostGroupDrainSuccess.Inc(1)
+	return &host_svc.CompleteHostGroupDrainResponse{}, nil
+}
+
+// getHostGroup returns a host group from DB.
+func (m *serviceHandler) getHostGroup(
+	ctx context.Context,
+	name string,
+) (*ormobjects.HostGroup, error) {
+	g, err := m.hostGroupOps.Get(ctx, name)
+	if err == gocql.ErrNotFound {
+		return nil, yarpcerrors.NotFoundErrorf("host group %s not found", name)
+	}
+	return g, err
+}
+
+// evictCompletedHostGroups deletes the oldest completed host groups once
+// there are more than _maxCompletedHostGroups of them. The groups are the
+// ones which existed before a new group was started.
+func (m *serviceHandler) evictCompletedHostGroups(
+	ctx context.Context,
+	groups []*ormobjects.HostGroup,
+) {
+	if len(groups) < _maxCompletedHostGroups {
+		return
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].CompletionTime.Before(groups[j].CompletionTime)
+	})
+	for _, g := range groups[:len(groups)-_maxCompletedHostGroups+1] {
+		if err := m.hostGroupOps.Delete(ctx, g.Name); err != nil {
+			log.WithError(err).
+				WithField("group", g.Name).
+				Warn("Failed to delete completed host group")
+			return
+		}
+	}
+}
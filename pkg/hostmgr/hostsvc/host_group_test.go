@@ -0,0 +1,240 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostsvc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	mesosmaintenance "github.com/uber/peloton/.gen/mesos/v1/maintenance"
+	mesosmaster "github.com/uber/peloton/.gen/mesos/v1/master"
+	hpb "github.com/uber/peloton/.gen/peloton/api/v0/host"
+	"github.com/uber/peloton/.gen/peloton/api/v0/host/svc"
+
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
+
+	"github.com/gocql/gocql"
+	"github.com/golang/mock/gomock"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// TestHostGroupDrain tests draining a host group, getting its status
+// while it drains, and completing it
+func (suite *HostSvcHandlerTestSuite) TestHostGroupDrain() {
+	machine := suite.upMachines[0]
+	hosts := []string{machine.GetHostname()}
+	hostInfo := &hpb.HostInfo{
+		Hostname: machine.GetHostname(),
+		Ip:       machine.GetIp(),
+		State:    hpb.HostState_HOST_STATE_DRAINING,
+	}
+	group := &ormobjects.HostGroup{}
+
+	gomock.InOrder(
+		suite.mockHostGroupOps.EXPECT().GetAll(gomock.Any()).
+			Return([]*ormobjects.HostGroup{{
+				Name:           "group0",
+				CompletionTime: time.Now(),
+			}}, nil),
+		suite.mockHostGroupOps.EXPECT().Create(gomock.Any(), gomock.Any()).
+			Do(func(_ context.Context, g *ormobjects.HostGroup) {
+				*group = *g
+			}).
+			Return(nil),
+		suite.mockMasterOperatorClient.EXPECT().GetMaintenanceSchedule().
+			Return(&mesosmaster.Response_GetMaintenanceSchedule{
+				Schedule: &mesosmaintenance.Schedule{},
+			}, nil),
+		suite.mockMasterOperatorClient.EXPECT().
+			UpdateMaintenanceSchedule(gomock.Any()).Return(nil),
+		suite.mockMaintenanceMap.EXPECT().
+			AddHostInfos([]*hpb.HostInfo{hostInfo}),
+		suite.mockMaintenanceQueue.EXPECT().
+			Enqueue(hosts).Return(nil),
+	)
+	_, err := suite.handler.StartHostGroupDrain(suite.ctx,
+		&svcpb.StartHostGroupDrainRequest{
+			Group:     "group1",
+			Hostnames: hosts,
+		})
+	suite.NoError(err)
+	suite.Equal("group1", group.Name)
+	suite.Equal(hosts, group.Hostnames)
+	suite.False(group.Completed())
+
+	// only one group is drained at a time
+	suite.mockHostGroupOps.EXPECT().GetAll(gomock.Any()).
+		Return([]*ormobjects.HostGroup{group}, nil)
+	_, err = suite.handler.StartHostGroupDrain(suite.ctx,
+		&svcpb.StartHostGroupDrainRequest{
+			Group:     "group2",
+			Hostnames: []string{"host2"},
+		})
+	suite.True(yarpcerrors.IsFailedPrecondition(err))
+
+	suite.mockHostGroupOps.EXPECT().Get(gomock.Any(), "group1").
+		Return(group, nil).
+		AnyTimes()
+
+	// the group is draining until its hosts are down
+	suite.mockMaintenanceMap.EXPECT().
+		GetDrainingHostInfos(hosts).
+		Return([]*hpb.HostInfo{hostInfo})
+	suite.mockMaintenanceMap.EXPECT().
+		GetDownHostInfos(hosts).
+		Return(nil)
+	resp, err := suite.handler.GetHostGroupDrainStatus(suite.ctx,
+		&svcpb.GetHostGroupDrainStatusRequest{Group: "group1"})
+	suite.NoError(err)
+	suite.Equal(
+		svcpb.HostGroupDrainState_HOST_GROUP_DRAIN_STATE_DRAINING,
+		resp.GetState())
+	suite.Equal([]*hpb.HostInfo{hostInfo}, resp.GetHostInfos())
+
+	suite.mockMaintenanceMap.EXPECT().
+		GetDownHostInfos(hosts).
+		Return(nil)
+	_, err = suite.handler.CompleteHostGroupDrain(suite.ctx,
+		&svcpb.CompleteHostGroupDrainRequest{Group: "group1"})
+	suite.True(yarpcerrors.IsFailedPrecondition(err))
+
+	downHostInfo := &hpb.HostInfo{
+		Hostname: machine.GetHostname(),
+		Ip:       machine.GetIp(),
+		State:    hpb.HostState_HOST_STATE_DOWN,
+	}
+	suite.mockMaintenanceMap.EXPECT().
+		GetDrainingHostInfos(hosts).
+		Return(nil)
+	suite.mockMaintenanceMap.EXPECT().
+		GetDownHostInfos(hosts).
+		Return([]*hpb.HostInfo{downHostInfo})
+	resp, err = suite.handler.GetHostGroupDrainStatus(suite.ctx,
+		&svcpb.GetHostGroupDrainStatusRequest{Group: "group1"})
+	suite.NoError(err)
+	suite.Equal(
+		svcpb.HostGroupDrainState_HOST_GROUP_DRAIN_STATE_DRAINED,
+		resp.GetState())
+
+	gomock.InOrder(
+		suite.mockMaintenanceMap.EXPECT().
+			GetDownHostInfos(hosts).
+			Return([]*hpb.HostInfo{downHostInfo}),
+		suite.mockMaintenanceMap.EXPECT().
+			GetDownHostInfos([]string{}).
+			Return([]*hpb.HostInfo{downHostInfo}),
+		suite.mockMasterOperatorClient.EXPECT().
+			StopMaintenance(suite.upMachines).Return(nil),
+		suite.mockMaintenanceMap.EXPECT().
+			RemoveHostInfos(hosts),
+		suite.mockHostGroupOps.EXPECT().
+			Complete(gomock.Any(), "group1", gomock.Any()).
+			Do(func(_ context.Context, _ string, completionTime time.Time) {
+				group.CompletionTime = completionTime
+			}).
+			Return(nil),
+	)
+	_, err = suite.handler.CompleteHostGroupDrain(suite.ctx,
+		&svcpb.CompleteHostGroupDrainRequest{Group: "group1"})
+	suite.NoError(err)
+
+	resp, err = suite.handler.GetHostGroupDrainStatus(suite.ctx,
+		&svcpb.GetHostGroupDrainStatusRequest{Group: "group1"})
+	suite.NoError(err)
+	suite.Equal(
+		svcpb.HostGroupDrainState_HOST_GROUP_DRAIN_STATE_COMPLETED,
+		resp.GetState())
+	suite.NotEmpty(resp.GetCompletionTime())
+	suite.Equal(hpb.HostState_HOST_STATE_UP, resp.GetHostInfos()[0].GetState())
+}
+
+// TestStartHostGroupDrainError tests failing to start draining a host group
+func (suite *HostSvcHandlerTestSuite) TestStartHostGroupDrainError() {
+	_, err := suite.handler.StartHostGroupDrain(suite.ctx,
+		&svcpb.StartHostGroupDrainRequest{Group: "group1"})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+
+	request := &svcpb.StartHostGroupDrainRequest{
+		Group:     "group1",
+		Hostnames: []string{suite.upMachines[0].GetHostname()},
+	}
+
+	suite.mockHostGroupOps.EXPECT().GetAll(gomock.Any()).
+		Return(nil, fmt.Errorf("fake GetAll error"))
+	_, err = suite.handler.StartHostGroupDrain(suite.ctx, request)
+	suite.Error(err)
+
+	suite.mockHostGroupOps.EXPECT().GetAll(gomock.Any()).Return(nil, nil)
+	suite.mockHostGroupOps.EXPECT().Create(gomock.Any(), gomock.Any()).
+		Return(fmt.Errorf("fake Create error"))
+	_, err = suite.handler.StartHostGroupDrain(suite.ctx, request)
+	suite.Error(err)
+
+	// the group is deleted if its hosts can not be drained
+	gomock.InOrder(
+		suite.mockHostGroupOps.EXPECT().GetAll(gomock.Any()).Return(nil, nil),
+		suite.mockHostGroupOps.EXPECT().Create(gomock.Any(), gomock.Any()).
+			Return(nil),
+		suite.mockMasterOperatorClient.EXPECT().
+			GetMaintenanceSchedule().
+			Return(nil, fmt.Errorf("fake GetMaintenanceSchedule error")),
+		suite.mockHostGroupOps.EXPECT().Delete(gomock.Any(), "group1").
+			Return(nil),
+	)
+	_, err = suite.handler.StartHostGroupDrain(suite.ctx, request)
+	suite.Error(err)
+}
+
+// TestHostGroupNotFound tests getting the status of and completing a
+// host group which does not exist
+func (suite *HostSvcHandlerTestSuite) TestHostGroupNotFound() {
+	suite.mockHostGroupOps.EXPECT().Get(gomock.Any(), "group1").
+		Return(nil, gocql.ErrNotFound).
+		Times(2)
+
+	_, err := suite.handler.GetHostGroupDrainStatus(suite.ctx,
+		&svcpb.GetHostGroupDrainStatusRequest{Group: "group1"})
+	suite.True(yarpcerrors.IsNotFound(err))
+
+	_, err = suite.handler.CompleteHostGroupDrain(suite.ctx,
+		&svcpb.CompleteHostGroupDrainRequest{Group: "group1"})
+	suite.True(yarpcerrors.IsNotFound(err))
+}
+
+// TestEvictCompletedHostGroups tests that the oldest completed host groups
+// are deleted
+func (suite *HostSvcHandlerTestSuite) TestEvictCompletedHostGroups() {
+	now := time.Now()
+	var groups []*ormobjects.HostGroup
+	for i := _maxCompletedHostGroups; i >= 0; i-- {
+		groups = append(groups, &ormobjects.HostGroup{
+			Name:           fmt.Sprintf("group%d", i),
+			CompletionTime: now.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	gomock.InOrder(
+		suite.mockHostGroupOps.EXPECT().Delete(gomock.Any(), "group0").
+			Return(nil),
+		suite.mockHostGroupOps.EXPECT().Delete(gomock.Any(), "group1").
+			Return(nil),
+	)
+	suite.handler.evictCompletedHostGroups(suite.ctx, groups)
+
+	// nothing is deleted while there are not too many groups
+	suite.handler.evictCompletedHostGroups(
+		suite.ctx, groups[:_maxCompletedHostGroups-1])
+}
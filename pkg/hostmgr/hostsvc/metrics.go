@@ -29,6 +29,18 @@ type Metrics struct {
 	QueryHostsAPI     tally.Counter
 	QueryHostsSuccess tally.Counter
 	QueryHostsFail    tally.Counter
+
+	StartHostGroupDrainAPI     tally.Counter
+	StartHostGroupDrainSuccess tally.Counter
+	StartHostGroupDrainFail    tally.Counter
+
+	GetHostGroupDrainStatusAPI     tally.Counter
+	GetHostGroupDrainStatusSuccess tally.Counter
+	GetHostGroupDrainStatusFail    tally.Counter
+
+	CompleteHostGroupDrainAPI     tally.Counter
+	CompleteHostGroupDrainSuccess tally.Counter
+	CompleteHostGroupDrainFail    tally.Counter
 }
 
 // NewMetrics returns a new instance of host.svc.Metrics
@@ -48,5 +60,17 @@ func NewMetrics(scope tally.Scope) *Metrics {
 		QueryHostsAPI:     apiScope.Counter("query_hosts"),
 		QueryHostsSuccess: successScope.Counter("query_hosts"),
 		QueryHostsFail:    failScope.Counter("query_hosts"),
+
+		StartHostGroupDrainAPI:     apiScope.Counter("start_host_group_drain"),
+		StartHostGroupDrainSuccess: successScope.Counter("start_host_group_drain"),
+		StartHostGroupDrainFail:    failScope.Counter("start_host_group_drain"),
+
+		GetHostGroupDrainStatusAPI:     apiScope.Counter("get_host_group_drain_status"),
+		GetHostGroupDrainStatusSuccess: successScope.Counter("get_host_group_drain_status"),
+		GetHostGroupDrainStatusFail:    failScope.Counter("get_host_group_drain_status"),
+
+		CompleteHostGroupDrainAPI:     apiScope.Counter("complete_host_group_drain"),
+		CompleteHostGroupDrainSuccess: successScope.Counter("complete_host_group_drain"),
+		CompleteHostGroupDrainFail:    failScope.Counter("complete_host_group_drain"),
 	}
 }
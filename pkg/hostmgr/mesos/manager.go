@@ -22,6 +22,7 @@ import (
 
 	sched "github.com/uber/peloton/.gen/mesos/v1/scheduler"
 
+	"github.com/uber/peloton/pkg/hostmgr/host"
 	"github.com/uber/peloton/pkg/hostmgr/mesos/yarpc/encoding/mpb"
 	"github.com/uber/peloton/pkg/storage"
 )
@@ -93,6 +94,10 @@ func (m *mesosManager) Message(ctx context.Context, body *sched.Event) error {
 func (m *mesosManager) Failure(ctx context.Context, body *sched.Event) error {
 	failure := body.GetFailure()
 	log.WithField("failure", failure).Debug("mesosManager: failure called")
+	// a failure without an executor is the removal of an agent
+	if failure.GetAgentId() != nil && failure.GetExecutorId() == nil {
+		host.RequestRefresh()
+	}
 	return nil
 }
 
@@ -68,6 +68,12 @@ type Metrics struct {
 	MarkHostsDrained     tally.Counter
 	MarkHostsDrainedFail tally.Counter
 
+	ExecTaskCommand     tally.Counter
+	ExecTaskCommandFail tally.Counter
+
+	GetTasksResourceUsage     tally.Counter
+	GetTasksResourceUsageFail tally.Counter
+
 	scope tally.Scope
 }
 
@@ -120,6 +126,12 @@ func NewMetrics(scope tally.Scope) *Metrics {
 		MarkHostsDrained:     scope.Counter("mark_hosts_drained"),
 		MarkHostsDrainedFail: scope.Counter("mark_hosts_drained_fail"),
 
+		ExecTaskCommand:     scope.Counter("exec_task_command"),
+		ExecTaskCommandFail: scope.Counter("exec_task_command_fail"),
+
+		GetTasksResourceUsage:     scope.Counter("get_tasks_resource_usage"),
+		GetTasksResourceUsageFail: scope.Counter("get_tasks_resource_usage_fail"),
+
 		scope: scope,
 	}
 }
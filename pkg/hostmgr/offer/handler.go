@@ -26,6 +26,7 @@ import (
 	sched "github.com/uber/peloton/.gen/mesos/v1/scheduler"
 	"github.com/uber/peloton/pkg/common/background"
 	"github.com/uber/peloton/pkg/hostmgr/binpacking"
+	"github.com/uber/peloton/pkg/hostmgr/host"
 	hostmgr_mesos "github.com/uber/peloton/pkg/hostmgr/mesos"
 	"github.com/uber/peloton/pkg/hostmgr/mesos/yarpc/encoding/mpb"
 	"github.com/uber/peloton/pkg/hostmgr/offer/offerpool"
@@ -197,6 +198,10 @@ func GetEventHandler() EventHandler {
 func (h *eventHandler) Offers(ctx context.Context, body *sched.Event) error {
 	event := body.GetOffers()
 	log.WithField("event", event).Debug("OfferManager: processing Offers event")
+	for _, offer := range event.GetOffers() {
+		host.RefreshIfAgentChanged(
+			offer.GetHostname(), offer.GetAgentId().GetValue())
+	}
 	h.offerPool.AddOffers(ctx, event.Offers)
 
 	return nil
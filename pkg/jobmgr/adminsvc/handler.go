@@ -0,0 +1,575 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adminsvc
+
+import (
+	"context"
+	"crypto/subtle"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/private/jobmgrsvc"
+
+	commongoalstate "github.com/uber/peloton/pkg/common/goalstate"
+	"github.com/uber/peloton/pkg/common/leader"
+	"github.com/uber/peloton/pkg/common/metrics"
+	"github.com/uber/peloton/pkg/jobmgr/asyncop"
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+	"github.com/uber/peloton/pkg/jobmgr/goalstate"
+	"github.com/uber/peloton/pkg/storage"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/uber-go/tally"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// serviceHandler implements peloton.private.jobmgrsvc.JobManagerService
+type serviceHandler struct {
+	config          Config
+	metrics         *Metrics
+	goalStateDriver goalstate.Driver
+	jobStore        storage.JobStore
+	taskStore       storage.TaskStore
+	jobFactory      cached.JobFactory
+	candidate       leader.Candidate
+	refreshes       *asyncop.Tracker
+	// totals are the totals of the metrics of Job Manager, nil if the
+	// metrics scope does not keep them
+	totals *metrics.Totals
+}
+
+// InitServiceHandler initializes the handler of the internal
+// Job Manager service for the operators of the cluster.
+func InitServiceHandler(
+	d *yarpc.Dispatcher,
+	parent tally.Scope,
+	goalStateDriver goalstate.Driver,
+	jobStore storage.JobStore,
+	taskStore storage.TaskStore,
+	jobFactory cached.JobFactory,
+	candidate leader.Candidate,
+	config Config,
+) {
+	handler := &serviceHandler{
+		config:          config,
+		metrics:         NewMetrics(parent),
+		goalStateDriver: goalStateDriver,
+		jobStore:        jobStore,
+		taskStore:       taskStore,
+		jobFactory:      jobFactory,
+		candidate:       candidate,
+		refreshes:       asyncop.NewTracker(_maxRunningRefreshes),
+	}
+	if s, ok := parent.(metrics.TotalsScope); ok {
+		handler.totals = s.Totals()
+	}
+
+	d.Register(jobmgrsvc.BuildJobManagerServiceYARPCProcedures(handler))
+}
+
+// validateJobTypes returns an error if one of the job types is unknown.
+func validateJobTypes(jobTypes []job.JobType) error {
+	for _, jobType := range jobTypes {
+		if _, ok := job.JobType_name[int32(jobType)]; !ok {
+			return yarpcerrors.InvalidArgumentErrorf(
+				"unknown job type %d", jobType)
+		}
+	}
+	return nil
+}
+
+// authenticate returns an error unless the call has the token of the
+// config in its authorization header.
+func (h *serviceHandler) authenticate(ctx context.Context) error {
+	if len(h.config.Token) == 0 {
+		return yarpcerrors.UnimplementedErrorf(
+			"pausing and resuming the goal state is not enabled")
+	}
+
+	var authorization string
+	if call := yarpc.CallFromContext(ctx); call != nil {
+		authorization = call.Header("authorization")
+	}
+
+	if subtle.ConstantTimeCompare(
+		[]byte(authorization),
+		[]byte("Bearer "+h.config.Token)) != 1 {
+		return yarpcerrors.UnauthenticatedErrorf("invalid admin token")
+	}
+	return nil
+}
+
+// PauseGoalState implements JobManagerService.PauseGoalState.
+func (h *serviceHandler) PauseGoalState(
+	ctx context.Context,
+	req *jobmgrsvc.PauseGoalStateRequest,
+) (*jobmgrsvc.PauseGoalStateResponse, error) {
+	h.metrics.PauseGoalStateAPI.Inc(1)
+
+	if err := h.authenticate(ctx); err != nil {
+		h.metrics.PauseGoalStateFail.Inc(1)
+		log.WithError(err).Warn("failed to authenticate PauseGoalState")
+		return nil, err
+	}
+
+	if err := validateJobTypes(req.GetJobTypes()); err != nil {
+		h.metrics.PauseGoalStateFail.Inc(1)
+		return nil, err
+	}
+
+	if err := h.goalStateDriver.Pause(ctx, req.GetJobTypes()...); err != nil {
+		h.metrics.PauseGoalStateFail.Inc(1)
+		log.WithError(err).
+			WithField("request", req).
+			Error("JobManagerService.PauseGoalState failed")
+		return nil, err
+	}
+
+	log.WithField("request", req).Warn("JobManagerService.PauseGoalState succeeded")
+	h.metrics.PauseGoalState.Inc(1)
+	return &jobmgrsvc.PauseGoalStateResponse{
+		PausedJobTypes: h.goalStateDriver.PausedJobTypes(),
+	}, nil
+}
+
+// ResumeGoalState implements JobManagerService.ResumeGoalState.
+func (h *serviceHandler) ResumeGoalState(
+	ctx context.Context,
+	req *jobmgrsvc.ResumeGoalStateRequest,
+) (*jobmgrsvc.ResumeGoalStateResponse, error) {
+	h.metrics.ResumeGoalStateAPI.Inc(1)
+
+	if err := h.authenticate(ctx); err != nil {
+		h.metrics.ResumeGoalStateFail.Inc(1)
+		log.WithError(err).Warn("failed to authenticate ResumeGoalState")
+		return nil, err
+	}
+
+	if err := validateJobTypes(req.GetJobTypes()); err != nil {
+		h.metrics.ResumeGoalStateFail.Inc(1)
+		return nil, err
+	}
+
+	if err := h.goalStateDriver.Resume(ctx, req.GetJobTypes()...); err != nil {
+		h.metrics.ResumeGoalStateFail.Inc(1)
+		log.WithError(err).
+			WithField("request", req).
+			Error("JobManagerService.ResumeGoalState failed")
+		return nil, err
+	}
+
+	log.WithField("request", req).Info("JobManagerService.ResumeGoalState succeeded")
+	h.metrics.ResumeGoalState.Inc(1)
+	return &jobmgrsvc.ResumeGoalStateResponse{
+		PausedJobTypes: h.goalStateDriver.PausedJobTypes(),
+	}, nil
+}
+
+// GetGoalStateStatus implements JobManagerService.GetGoalStateStatus.
+func (h *serviceHandler) GetGoalStateStatus(
+	ctx context.Context,
+	req *jobmgrsvc.GetGoalStateStatusRequest,
+) (*jobmgrsvc.GetGoalStateStatusResponse, error) {
+	h.metrics.GetGoalStateStatusAPI.Inc(1)
+	return &jobmgrsvc.GetGoalStateStatusResponse{
+		PausedJobTypes: h.goalStateDriver.PausedJobTypes(),
+	}, nil
+}
+
+// GetGoalStateQueues implements JobManagerService.GetGoalStateQueues.
+func (h *serviceHandler) GetGoalStateQueues(
+	ctx context.Context,
+	req *jobmgrsvc.GetGoalStateQueuesRequest,
+) (*jobmgrsvc.GetGoalStateQueuesResponse, error) {
+	h.metrics.GetGoalStateQueuesAPI.Inc(1)
+
+	if req.GetJobId() != nil && len(req.GetJobId().GetValue()) == 0 {
+		h.metrics.GetGoalStateQueuesFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf("empty job id")
+	}
+
+	entities := h.goalStateDriver.Entities(req.GetJobId())
+
+	h.metrics.GetGoalStateQueues.Inc(1)
+	return &jobmgrsvc.GetGoalStateQueuesResponse{
+		Jobs:    convertEntities(entities.Jobs, req),
+		Tasks:   convertEntities(entities.Tasks, req),
+		Updates: convertEntities(entities.Updates, req),
+	}, nil
+}
+
+// convertEntities converts the entities of a goal state engine to the
+// entities returned by GetGoalStateQueues. The scheduled entities are
+// returned first, by the time at which they will be evaluated.
+func convertEntities(
+	infos []commongoalstate.EntityInfo,
+	req *jobmgrsvc.GetGoalStateQueuesRequest,
+) []*jobmgrsvc.GoalStateEntity {
+	var selected []commongoalstate.EntityInfo
+	for _, info := range infos {
+		if req.GetFailedOnly() && info.Retries == 0 {
+			continue
+		}
+		selected = append(selected, info)
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		if selected[i].Scheduled != selected[j].Scheduled {
+			return selected[i].Scheduled
+		}
+		return selected[i].Deadline.Before(selected[j].Deadline)
+	})
+
+	if req.GetLimit() > 0 && len(selected) > int(req.GetLimit()) {
+		selected = selected[:req.GetLimit()]
+	}
+
+	var entities []*jobmgrsvc.GoalStateEntity
+	for _, info := range selected {
+		entity := &jobmgrsvc.GoalStateEntity{
+			Id:           info.ID,
+			Scheduled:    info.Scheduled,
+			DelaySeconds: info.Delay.Seconds(),
+			Retries:      info.Retries,
+			Running:      info.Running,
+			LastAction:   info.LastAction,
+			LastError:    info.LastError,
+		}
+		if info.Scheduled {
+			entity.ScheduledTime = info.Deadline.Format(time.RFC3339)
+		}
+		entities = append(entities, entity)
+	}
+	return entities
+}
+
+// EvaluateGoalState implements JobManagerService.EvaluateGoalState.
+func (h *serviceHandler) EvaluateGoalState(
+	ctx context.Context,
+	req *jobmgrsvc.EvaluateGoalStateRequest,
+) (*jobmgrsvc.EvaluateGoalStateResponse, error) {
+	h.metrics.EvaluateGoalStateAPI.Inc(1)
+
+	if len(req.GetJobId().GetValue()) == 0 {
+		h.metrics.EvaluateGoalStateFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf("empty job id")
+	}
+
+	evaluations, err := h.goalStateDriver.Evaluate(
+		req.GetJobId(), req.GetInstanceIds())
+	if err != nil {
+		h.metrics.EvaluateGoalStateFail.Inc(1)
+		return nil, err
+	}
+
+	resp := &jobmgrsvc.EvaluateGoalStateResponse{
+		Job: convertEvaluation(evaluations.Job),
+	}
+	for _, evaluation := range evaluations.Tasks {
+		resp.Tasks = append(resp.Tasks, convertEvaluation(evaluation))
+	}
+	for _, evaluation := range evaluations.Updates {
+		resp.Updates = append(resp.Updates, convertEvaluation(evaluation))
+	}
+
+	h.metrics.EvaluateGoalState.Inc(1)
+	return resp, nil
+}
+
+// convertEvaluation converts the evaluation of an entity by the goal state
+// driver to the evaluation returned by EvaluateGoalState.
+func convertEvaluation(
+	evaluation *goalstate.Evaluation,
+) *jobmgrsvc.GoalStateEvaluation {
+	return &jobmgrsvc.GoalStateEvaluation{
+		Id:            evaluation.ID,
+		State:         evaluation.State,
+		GoalState:     evaluation.GoalState,
+		Action:        evaluation.Action,
+		Actions:       evaluation.Actions,
+		SkippedReason: evaluation.SkippedReason,
+	}
+}
+
+// StartRefresh implements JobManagerService.StartRefresh.
+func (h *serviceHandler) StartRefresh(
+	ctx context.Context,
+	req *jobmgrsvc.StartRefreshRequest,
+) (*jobmgrsvc.StartRefreshResponse, error) {
+	h.metrics.StartRefreshAPI.Inc(1)
+
+	if !h.candidate.IsLeader() {
+		h.metrics.StartRefreshFail.Inc(1)
+		return nil, yarpcerrors.UnavailableErrorf(
+			"StartRefresh is not supported on non-leader")
+	}
+
+	if err := validateStartRefresh(req); err != nil {
+		h.metrics.StartRefreshFail.Inc(1)
+		return nil, err
+	}
+
+	jobIDs, err := h.getJobsToRefresh(ctx, req)
+	if err != nil {
+		h.metrics.StartRefreshFail.Inc(1)
+		return nil, err
+	}
+
+	rate := req.GetRate()
+	if rate == 0 {
+		rate = _defaultRefreshRate
+	}
+	op, err := h.refreshes.Add(_refreshOperation, "", uint32(len(jobIDs)))
+	if err != nil {
+		h.metrics.StartRefreshFail.Inc(1)
+		return nil, err
+	}
+	go runRefresh(op, jobIDs, time.Second/time.Duration(rate), h.refreshJob)
+
+	log.WithField("request", req).
+		WithField("refresh_id", op.ID()).
+		WithField("total_jobs", len(jobIDs)).
+		Info("JobManagerService.StartRefresh succeeded")
+	h.metrics.StartRefresh.Inc(1)
+	return &jobmgrsvc.StartRefreshResponse{
+		RefreshId: op.ID(),
+		TotalJobs: uint32(len(jobIDs)),
+	}, nil
+}
+
+// GetRefreshStatus implements JobManagerService.GetRefreshStatus.
+func (h *serviceHandler) GetRefreshStatus(
+	ctx context.Context,
+	req *jobmgrsvc.GetRefreshStatusRequest,
+) (*jobmgrsvc.GetRefreshStatusResponse, error) {
+	h.metrics.GetRefreshStatusAPI.Inc(1)
+
+	if !h.candidate.IsLeader() {
+		h.metrics.GetRefreshStatusFail.Inc(1)
+		return nil, yarpcerrors.UnavailableErrorf(
+			"GetRefreshStatus is not supported on non-leader")
+	}
+
+	if len(req.GetRefreshId()) == 0 {
+		h.metrics.GetRefreshStatusFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"refresh id is not provided")
+	}
+
+	op := h.refreshes.Get(req.GetRefreshId())
+	if op == nil {
+		h.metrics.GetRefreshStatusFail.Inc(1)
+		return nil, yarpcerrors.NotFoundErrorf(
+			"refresh %s not found", req.GetRefreshId())
+	}
+
+	h.metrics.GetRefreshStatus.Inc(1)
+	return refreshStatus(op), nil
+}
+
+// validateStartRefresh returns an error if the jobs to refresh or the
+// rate of the refresh are invalid.
+func validateStartRefresh(req *jobmgrsvc.StartRefreshRequest) error {
+	if len(req.GetJobIds()) > 0 &&
+		(len(req.GetJobStates()) > 0 || len(req.GetJobTypes()) > 0) {
+		return yarpcerrors.InvalidArgumentErrorf(
+			"job ids cannot be set along with job states or job types")
+	}
+	for _, jobID := range req.GetJobIds() {
+		if len(jobID.GetValue()) == 0 {
+			return yarpcerrors.InvalidArgumentErrorf("empty job id")
+		}
+	}
+	for _, state := range req.GetJobStates() {
+		if _, ok := job.JobState_name[int32(state)]; !ok {
+			return yarpcerrors.InvalidArgumentErrorf(
+				"unknown job state %d", state)
+		}
+	}
+	if req.GetRate() > _maxRefreshRate {
+		return yarpcerrors.InvalidArgumentErrorf(
+			"rate cannot be more than %d jobs per second", _maxRefreshRate)
+	}
+	return validateJobTypes(req.GetJobTypes())
+}
+
+// getJobsToRefresh returns the jobs of the request, or the jobs in
+// storage matching the job states and types of the request.
+func (h *serviceHandler) getJobsToRefresh(
+	ctx context.Context,
+	req *jobmgrsvc.StartRefreshRequest,
+) ([]*peloton.JobID, error) {
+	var jobIDs []*peloton.JobID
+	if len(req.GetJobIds()) > 0 {
+		seen := make(map[string]bool)
+		for _, jobID := range req.GetJobIds() {
+			if seen[jobID.GetValue()] {
+				continue
+			}
+			seen[jobID.GetValue()] = true
+			jobIDs = append(jobIDs, jobID)
+		}
+		return jobIDs, nil
+	}
+
+	states := make(map[job.JobState]bool)
+	for _, state := range req.GetJobStates() {
+		states[state] = true
+	}
+	types := make(map[job.JobType]bool)
+	for _, jobType := range req.GetJobTypes() {
+		types[jobType] = true
+	}
+
+	summaries, err := h.jobStore.GetAllJobsInJobIndex(ctx)
+	if err != nil {
+		return nil, yarpcerrors.InternalErrorf(
+			"failed to read the jobs to refresh: %v", err)
+	}
+	for _, summary := range summaries {
+		if len(states) > 0 && !states[summary.GetRuntime().GetState()] {
+			continue
+		}
+		if len(types) > 0 && !types[summary.GetType()] {
+			continue
+		}
+		jobIDs = append(jobIDs, summary.GetId())
+	}
+	return jobIDs, nil
+}
+
+// refreshJob reloads the config, the runtime and the task runtimes of a
+// job from storage into the cache, and evaluates the job again. The task
+// runtimes only replace the cached ones of older versions, so that the
+// runtimes updated since they were read are not overwritten.
+func (h *serviceHandler) refreshJob(
+	ctx context.Context,
+	jobID *peloton.JobID,
+) error {
+	if !h.candidate.IsLeader() {
+		return errRefreshNotLeader
+	}
+
+	jobConfig, configAddOn, err := h.jobStore.GetJobConfig(ctx, jobID.GetValue())
+	if err != nil {
+		return err
+	}
+	jobRuntime, err := h.jobStore.GetJobRuntime(ctx, jobID.GetValue())
+	if err != nil {
+		return err
+	}
+	taskRuntimes, err := h.taskStore.GetTaskRuntimesForJobByRange(ctx, jobID, nil)
+	if err != nil {
+		return err
+	}
+
+	cachedJob := h.jobFactory.AddJob(jobID)
+	if err := cachedJob.Update(
+		ctx,
+		&job.JobInfo{Config: jobConfig, Runtime: jobRuntime},
+		configAddOn,
+		cached.UpdateCacheOnly); err != nil {
+		return err
+	}
+	if err := cachedJob.ReplaceTasks(taskRuntimes, false); err != nil {
+		return err
+	}
+
+	h.goalStateDriver.EnqueueJob(jobID, time.Now())
+	return nil
+}
+
+// GetMetricsSnapshot implements JobManagerService.GetMetricsSnapshot.
+func (h *serviceHandler) GetMetricsSnapshot(
+	ctx context.Context,
+	req *jobmgrsvc.GetMetricsSnapshotRequest,
+) (*jobmgrsvc.GetMetricsSnapshotResponse, error) {
+	h.metrics.GetMetricsSnapshotAPI.Inc(1)
+
+	if h.totals == nil {
+		h.metrics.GetMetricsSnapshotFail.Inc(1)
+		return nil, yarpcerrors.UnimplementedErrorf(
+			"metrics snapshots are not supported by the metrics scope")
+	}
+
+	snapshot := h.totals.Snapshot(func(name string) bool {
+		return matchesMetricFilters(name, req.GetFilters())
+	})
+	resp := &jobmgrsvc.GetMetricsSnapshotResponse{
+		FlushTime: snapshot.FlushTime.UTC().Format(time.RFC3339Nano),
+	}
+	for _, counter := range snapshot.Counters {
+		resp.Counters = append(resp.Counters, &jobmgrsvc.MetricSnapshot{
+			Name:  counter.Name,
+			Tags:  counter.Tags,
+			Value: float64(counter.Value),
+		})
+	}
+	for _, gauge := range snapshot.Gauges {
+		resp.Gauges = append(resp.Gauges, &jobmgrsvc.MetricSnapshot{
+			Name:  gauge.Name,
+			Tags:  gauge.Tags,
+			Value: gauge.Value,
+		})
+	}
+	for _, timer := range snapshot.Timers {
+		resp.Timers = append(resp.Timers, &jobmgrsvc.TimerSnapshot{
+			Name:  timer.Name,
+			Tags:  timer.Tags,
+			Count: timer.Count,
+			SumNs: int64(timer.Sum),
+			MaxNs: int64(timer.Max),
+		})
+	}
+	for _, histogram := range snapshot.Histograms {
+		result := &jobmgrsvc.HistogramSnapshot{
+			Name: histogram.Name,
+			Tags: histogram.Tags,
+		}
+		for upperBound, samples := range histogram.Buckets {
+			result.Buckets = append(result.Buckets,
+				&jobmgrsvc.HistogramBucketSnapshot{
+					UpperBound: upperBound,
+					Samples:    samples,
+				})
+		}
+		sort.Slice(result.Buckets, func(i, j int) bool {
+			return result.Buckets[i].GetUpperBound() <
+				result.Buckets[j].GetUpperBound()
+		})
+		resp.Histograms = append(resp.Histograms, result)
+	}
+
+	h.metrics.GetMetricsSnapshot.Inc(1)
+	return resp, nil
+}
+
+// matchesMetricFilters returns true if the name of a metric contains one
+// of the filters, or if there is no filter.
+func matchesMetricFilters(name string, filters []string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, filter := range filters {
+		if strings.Contains(name, filter) {
+			return true
+		}
+	}
+	return false
+}
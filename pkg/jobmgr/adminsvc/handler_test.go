@@ -0,0 +1,675 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adminsvc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/private/jobmgrsvc"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	"github.com/uber/peloton/.gen/peloton/private/models"
+
+	commongoalstate "github.com/uber/peloton/pkg/common/goalstate"
+	leadermocks "github.com/uber/peloton/pkg/common/leader/mocks"
+	"github.com/uber/peloton/pkg/common/metrics"
+	"github.com/uber/peloton/pkg/jobmgr/asyncop"
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
+	"github.com/uber/peloton/pkg/jobmgr/goalstate"
+	goalstatemocks "github.com/uber/peloton/pkg/jobmgr/goalstate/mocks"
+	storemocks "github.com/uber/peloton/pkg/storage/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+const _testAdminToken = "admin-token"
+
+type adminServiceHandlerTestSuite struct {
+	suite.Suite
+
+	ctrl            *gomock.Controller
+	goalStateDriver *goalstatemocks.MockDriver
+	jobStore        *storemocks.MockJobStore
+	taskStore       *storemocks.MockTaskStore
+	jobFactory      *cachedmocks.MockJobFactory
+	cachedJob       *cachedmocks.MockJob
+	candidate       *leadermocks.MockCandidate
+	handler         *serviceHandler
+	// adminCtx is a context of a call authenticated by the admin token
+	adminCtx context.Context
+}
+
+func (suite *adminServiceHandlerTestSuite) SetupTest() {
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.goalStateDriver = goalstatemocks.NewMockDriver(suite.ctrl)
+	suite.jobStore = storemocks.NewMockJobStore(suite.ctrl)
+	suite.taskStore = storemocks.NewMockTaskStore(suite.ctrl)
+	suite.jobFactory = cachedmocks.NewMockJobFactory(suite.ctrl)
+	suite.cachedJob = cachedmocks.NewMockJob(suite.ctrl)
+	suite.candidate = leadermocks.NewMockCandidate(suite.ctrl)
+	suite.handler = &serviceHandler{
+		config:          Config{Token: _testAdminToken},
+		metrics:         NewMetrics(tally.NoopScope),
+		goalStateDriver: suite.goalStateDriver,
+		jobStore:        suite.jobStore,
+		taskStore:       suite.taskStore,
+		jobFactory:      suite.jobFactory,
+		candidate:       suite.candidate,
+		refreshes:       asyncop.NewTracker(_maxRunningRefreshes),
+	}
+	suite.adminCtx = yarpctest.ContextWithCall(
+		context.Background(),
+		&yarpctest.Call{
+			Headers: map[string]string{
+				"authorization": "Bearer " + _testAdminToken,
+			},
+		},
+	)
+}
+
+func (suite *adminServiceHandlerTestSuite) TearDownTest() {
+	suite.ctrl.Finish()
+}
+
+func TestAdminServiceHandler(t *testing.T) {
+	suite.Run(t, new(adminServiceHandlerTestSuite))
+}
+
+// TestPauseGoalState tests pausing the goal state of a job type.
+func (suite *adminServiceHandlerTestSuite) TestPauseGoalState() {
+	suite.goalStateDriver.EXPECT().
+		Pause(suite.adminCtx, job.JobType_SERVICE).
+		Return(nil)
+	suite.goalStateDriver.EXPECT().
+		PausedJobTypes().
+		Return([]job.JobType{job.JobType_SERVICE})
+
+	resp, err := suite.handler.PauseGoalState(
+		suite.adminCtx,
+		&jobmgrsvc.PauseGoalStateRequest{
+			JobTypes: []job.JobType{job.JobType_SERVICE},
+		})
+	suite.NoError(err)
+	suite.Equal([]job.JobType{job.JobType_SERVICE}, resp.GetPausedJobTypes())
+}
+
+// TestPauseGoalStateAll tests pausing the goal state of all job types.
+func (suite *adminServiceHandlerTestSuite) TestPauseGoalStateAll() {
+	suite.goalStateDriver.EXPECT().
+		Pause(suite.adminCtx).
+		Return(nil)
+	suite.goalStateDriver.EXPECT().
+		PausedJobTypes().
+		Return([]job.JobType{job.JobType_BATCH, job.JobType_SERVICE})
+
+	resp, err := suite.handler.PauseGoalState(
+		suite.adminCtx,
+		&jobmgrsvc.PauseGoalStateRequest{})
+	suite.NoError(err)
+	suite.Len(resp.GetPausedJobTypes(), 2)
+}
+
+// TestPauseGoalStatePersistFail tests pausing the goal state fails
+// when the pause cannot be persisted.
+func (suite *adminServiceHandlerTestSuite) TestPauseGoalStatePersistFail() {
+	suite.goalStateDriver.EXPECT().
+		Pause(suite.adminCtx, job.JobType_SERVICE).
+		Return(errors.New("test error"))
+
+	_, err := suite.handler.PauseGoalState(
+		suite.adminCtx,
+		&jobmgrsvc.PauseGoalStateRequest{
+			JobTypes: []job.JobType{job.JobType_SERVICE},
+		})
+	suite.Error(err)
+}
+
+// TestPauseResumeGoalStateUnauthenticated tests pausing and resuming
+// the goal state fails without the admin token, and is disabled when
+// no token is configured.
+func (suite *adminServiceHandlerTestSuite) TestPauseResumeGoalStateUnauthenticated() {
+	_, err := suite.handler.PauseGoalState(
+		context.Background(),
+		&jobmgrsvc.PauseGoalStateRequest{})
+	suite.True(yarpcerrors.IsUnauthenticated(err))
+
+	_, err = suite.handler.ResumeGoalState(
+		yarpctest.ContextWithCall(
+			context.Background(),
+			&yarpctest.Call{
+				Headers: map[string]string{
+					"authorization": "Bearer wrong-token",
+				},
+			},
+		),
+		&jobmgrsvc.ResumeGoalStateRequest{})
+	suite.True(yarpcerrors.IsUnauthenticated(err))
+
+	suite.handler.config.Token = ""
+	_, err = suite.handler.PauseGoalState(
+		suite.adminCtx,
+		&jobmgrsvc.PauseGoalStateRequest{})
+	suite.True(yarpcerrors.IsUnimplemented(err))
+	_, err = suite.handler.ResumeGoalState(
+		suite.adminCtx,
+		&jobmgrsvc.ResumeGoalStateRequest{})
+	suite.True(yarpcerrors.IsUnimplemented(err))
+}
+
+// TestPauseGoalStateInvalidJobType tests pausing the goal state of an
+// unknown job type fails.
+func (suite *adminServiceHandlerTestSuite) TestPauseGoalStateInvalidJobType() {
+	_, err := suite.handler.PauseGoalState(
+		suite.adminCtx,
+		&jobmgrsvc.PauseGoalStateRequest{
+			JobTypes: []job.JobType{job.JobType(100)},
+		})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestResumeGoalState tests resuming the goal state of a job type.
+func (suite *adminServiceHandlerTestSuite) TestResumeGoalState() {
+	suite.goalStateDriver.EXPECT().
+		Resume(suite.adminCtx, job.JobType_BATCH).
+		Return(nil)
+	suite.goalStateDriver.EXPECT().
+		PausedJobTypes().
+		Return([]job.JobType{job.JobType_SERVICE})
+
+	resp, err := suite.handler.ResumeGoalState(
+		suite.adminCtx,
+		&jobmgrsvc.ResumeGoalStateRequest{
+			JobTypes: []job.JobType{job.JobType_BATCH},
+		})
+	suite.NoError(err)
+	suite.Equal([]job.JobType{job.JobType_SERVICE}, resp.GetPausedJobTypes())
+}
+
+// TestResumeGoalStateInvalidJobType tests resuming the goal state of an
+// unknown job type fails.
+func (suite *adminServiceHandlerTestSuite) TestResumeGoalStateInvalidJobType() {
+	_, err := suite.handler.ResumeGoalState(
+		suite.adminCtx,
+		&jobmgrsvc.ResumeGoalStateRequest{
+			JobTypes: []job.JobType{job.JobType(100)},
+		})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestGetGoalStateStatus tests getting the job types which are paused.
+func (suite *adminServiceHandlerTestSuite) TestGetGoalStateStatus() {
+	suite.goalStateDriver.EXPECT().
+		PausedJobTypes().
+		Return(nil)
+
+	resp, err := suite.handler.GetGoalStateStatus(
+		context.Background(),
+		&jobmgrsvc.GetGoalStateStatusRequest{})
+	suite.NoError(err)
+	suite.Empty(resp.GetPausedJobTypes())
+}
+
+// TestGetGoalStateQueues tests getting the entities of the goal state
+// engines, filtered by failure and limited per queue.
+func (suite *adminServiceHandlerTestSuite) TestGetGoalStateQueues() {
+	jobID := &peloton.JobID{Value: "job"}
+	now := time.Now()
+
+	suite.goalStateDriver.EXPECT().
+		Entities(jobID).
+		Return(&goalstate.Entities{
+			Jobs: []commongoalstate.EntityInfo{
+				{ID: "job", Scheduled: true, Deadline: now},
+			},
+			Tasks: []commongoalstate.EntityInfo{
+				{ID: "job-0"},
+				{
+					ID:         "job-1",
+					Scheduled:  true,
+					Deadline:   now.Add(time.Minute),
+					Delay:      30 * time.Second,
+					Retries:    3,
+					LastAction: "StartTask",
+					LastError:  "timeout",
+				},
+				{
+					ID:        "job-2",
+					Scheduled: true,
+					Deadline:  now,
+					Retries:   1,
+				},
+			},
+		})
+
+	resp, err := suite.handler.GetGoalStateQueues(
+		context.Background(),
+		&jobmgrsvc.GetGoalStateQueuesRequest{
+			JobId:      jobID,
+			FailedOnly: true,
+			Limit:      2,
+		})
+	suite.NoError(err)
+	suite.Empty(resp.GetJobs())
+	suite.Empty(resp.GetUpdates())
+	suite.Len(resp.GetTasks(), 2)
+	suite.Equal("job-2", resp.GetTasks()[0].GetId())
+	suite.Equal(now.Format(time.RFC3339), resp.GetTasks()[0].GetScheduledTime())
+
+	task := resp.GetTasks()[1]
+	suite.Equal("job-1", task.GetId())
+	suite.True(task.GetScheduled())
+	suite.Equal(float64(30), task.GetDelaySeconds())
+	suite.Equal(uint32(3), task.GetRetries())
+	suite.Equal("StartTask", task.GetLastAction())
+	suite.Equal("timeout", task.GetLastError())
+}
+
+// TestGetGoalStateQueuesAll tests getting the entities of all the jobs,
+// with the entities which are not scheduled returned last.
+func (suite *adminServiceHandlerTestSuite) TestGetGoalStateQueuesAll() {
+	suite.goalStateDriver.EXPECT().
+		Entities(nil).
+		Return(&goalstate.Entities{
+			Jobs: []commongoalstate.EntityInfo{
+				{ID: "job1"},
+				{ID: "job2", Scheduled: true, Deadline: time.Now()},
+			},
+		})
+
+	resp, err := suite.handler.GetGoalStateQueues(
+		context.Background(),
+		&jobmgrsvc.GetGoalStateQueuesRequest{})
+	suite.NoError(err)
+	suite.Len(resp.GetJobs(), 2)
+	suite.Equal("job2", resp.GetJobs()[0].GetId())
+	suite.Empty(resp.GetJobs()[1].GetScheduledTime())
+}
+
+// TestGetGoalStateQueuesEmptyJobID tests getting the entities of a job
+// with an empty identifier fails.
+func (suite *adminServiceHandlerTestSuite) TestGetGoalStateQueuesEmptyJobID() {
+	_, err := suite.handler.GetGoalStateQueues(
+		context.Background(),
+		&jobmgrsvc.GetGoalStateQueuesRequest{
+			JobId: &peloton.JobID{},
+		})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestEvaluateGoalState tests evaluating the goal state of a job and
+// of its tasks.
+func (suite *adminServiceHandlerTestSuite) TestEvaluateGoalState() {
+	jobID := &peloton.JobID{Value: "job"}
+
+	suite.goalStateDriver.EXPECT().
+		Evaluate(jobID, []uint32{1}).
+		Return(&goalstate.Evaluations{
+			Job: &goalstate.Evaluation{
+				ID:        "job",
+				State:     map[string]string{"state": "RUNNING"},
+				GoalState: map[string]string{"state": "RUNNING"},
+				Action:    "noop",
+				Actions:   []string{"runtime_update"},
+			},
+			Tasks: []*goalstate.Evaluation{
+				{
+					ID:            "job-1",
+					State:         map[string]string{"state": "INITIALIZED"},
+					GoalState:     map[string]string{"state": "RUNNING"},
+					Action:        "start_task",
+					Actions:       []string{"start_task"},
+					SkippedReason: goalstate.SkippedPaused,
+				},
+			},
+		}, nil)
+
+	resp, err := suite.handler.EvaluateGoalState(
+		context.Background(),
+		&jobmgrsvc.EvaluateGoalStateRequest{
+			JobId:       jobID,
+			InstanceIds: []uint32{1},
+		})
+	suite.NoError(err)
+	suite.Equal("job", resp.GetJob().GetId())
+	suite.Equal("noop", resp.GetJob().GetAction())
+	suite.Equal([]string{"runtime_update"}, resp.GetJob().GetActions())
+	suite.Empty(resp.GetUpdates())
+
+	suite.Len(resp.GetTasks(), 1)
+	task := resp.GetTasks()[0]
+	suite.Equal("job-1", task.GetId())
+	suite.Equal("INITIALIZED", task.GetState()["state"])
+	suite.Equal("RUNNING", task.GetGoalState()["state"])
+	suite.Equal("start_task", task.GetAction())
+	suite.Equal(goalstate.SkippedPaused, task.GetSkippedReason())
+}
+
+// TestEvaluateGoalStateFailure tests evaluating the goal state fails
+// without a job identifier or if the job is not in the cache.
+func (suite *adminServiceHandlerTestSuite) TestEvaluateGoalStateFailure() {
+	_, err := suite.handler.EvaluateGoalState(
+		context.Background(),
+		&jobmgrsvc.EvaluateGoalStateRequest{})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+
+	jobID := &peloton.JobID{Value: "job"}
+	suite.goalStateDriver.EXPECT().
+		Evaluate(jobID, gomock.Any()).
+		Return(nil, yarpcerrors.NotFoundErrorf("job not found"))
+
+	_, err = suite.handler.EvaluateGoalState(
+		context.Background(),
+		&jobmgrsvc.EvaluateGoalStateRequest{JobId: jobID})
+	suite.True(yarpcerrors.IsNotFound(err))
+}
+
+// expectRefreshJob sets the expectations of refreshing a job, which
+// fails to be read from storage if err is set.
+func (suite *adminServiceHandlerTestSuite) expectRefreshJob(
+	jobID *peloton.JobID,
+	err error,
+) {
+	if err != nil {
+		suite.jobStore.EXPECT().
+			GetJobConfig(gomock.Any(), jobID.GetValue()).
+			Return(nil, nil, err)
+		return
+	}
+
+	jobConfig := &job.JobConfig{InstanceCount: 1}
+	jobRuntime := &job.RuntimeInfo{State: job.JobState_RUNNING}
+	taskRuntimes := map[uint32]*task.RuntimeInfo{
+		0: {State: task.TaskState_RUNNING},
+	}
+	suite.jobStore.EXPECT().
+		GetJobConfig(gomock.Any(), jobID.GetValue()).
+		Return(jobConfig, &models.ConfigAddOn{}, nil)
+	suite.jobStore.EXPECT().
+		GetJobRuntime(gomock.Any(), jobID.GetValue()).
+		Return(jobRuntime, nil)
+	suite.taskStore.EXPECT().
+		GetTaskRuntimesForJobByRange(gomock.Any(), jobID, nil).
+		Return(taskRuntimes, nil)
+	suite.jobFactory.EXPECT().
+		AddJob(jobID).
+		Return(suite.cachedJob)
+	suite.cachedJob.EXPECT().
+		Update(
+			gomock.Any(),
+			&job.JobInfo{Config: jobConfig, Runtime: jobRuntime},
+			&models.ConfigAddOn{},
+			cached.UpdateCacheOnly).
+		Return(nil)
+	suite.cachedJob.EXPECT().
+		ReplaceTasks(taskRuntimes, false).
+		Return(nil)
+	suite.goalStateDriver.EXPECT().
+		EnqueueJob(jobID, gomock.Any())
+}
+
+// waitRefresh waits for a refresh to complete, and returns its status.
+func (suite *adminServiceHandlerTestSuite) waitRefresh(
+	refreshID string,
+) *jobmgrsvc.GetRefreshStatusResponse {
+	for i := 0; i < 100; i++ {
+		resp, err := suite.handler.GetRefreshStatus(
+			context.Background(),
+			&jobmgrsvc.GetRefreshStatusRequest{RefreshId: refreshID})
+		suite.NoError(err)
+		if resp.GetState() != jobmgrsvc.RefreshState_REFRESH_STATE_RUNNING {
+			return resp
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	suite.Fail("refresh did not complete")
+	return nil
+}
+
+// TestStartRefreshJobIDs tests refreshing a list of jobs.
+func (suite *adminServiceHandlerTestSuite) TestStartRefreshJobIDs() {
+	jobID1 := &peloton.JobID{Value: "job1"}
+	jobID2 := &peloton.JobID{Value: "job2"}
+	suite.candidate.EXPECT().IsLeader().Return(true).AnyTimes()
+	suite.expectRefreshJob(jobID1, nil)
+	suite.expectRefreshJob(jobID2, nil)
+
+	resp, err := suite.handler.StartRefresh(
+		context.Background(),
+		&jobmgrsvc.StartRefreshRequest{
+			JobIds: []*peloton.JobID{jobID1, jobID2, jobID1},
+			Rate:   _maxRefreshRate,
+		})
+	suite.NoError(err)
+	suite.NotEmpty(resp.GetRefreshId())
+	suite.Equal(uint32(2), resp.GetTotalJobs())
+
+	status := suite.waitRefresh(resp.GetRefreshId())
+	suite.Equal(jobmgrsvc.RefreshState_REFRESH_STATE_SUCCEEDED, status.GetState())
+	suite.Equal(uint32(2), status.GetTotalJobs())
+	suite.Equal(uint32(2), status.GetProcessedJobs())
+	suite.Empty(status.GetFailures())
+	suite.NotEmpty(status.GetCompletionTime())
+}
+
+// TestStartRefreshFilter tests refreshing the jobs matching a filter,
+// one of which fails to be refreshed.
+func (suite *adminServiceHandlerTestSuite) TestStartRefreshFilter() {
+	jobID1 := &peloton.JobID{Value: "job1"}
+	jobID2 := &peloton.JobID{Value: "job2"}
+	suite.candidate.EXPECT().IsLeader().Return(true).AnyTimes()
+	suite.jobStore.EXPECT().
+		GetAllJobsInJobIndex(gomock.Any()).
+		Return([]*job.JobSummary{
+			{
+				Id:      jobID1,
+				Type:    job.JobType_SERVICE,
+				Runtime: &job.RuntimeInfo{State: job.JobState_RUNNING},
+			},
+			{
+				Id:      jobID2,
+				Type:    job.JobType_BATCH,
+				Runtime: &job.RuntimeInfo{State: job.JobState_RUNNING},
+			},
+			{
+				Id:      &peloton.JobID{Value: "job3"},
+				Type:    job.JobType_SERVICE,
+				Runtime: &job.RuntimeInfo{State: job.JobState_KILLED},
+			},
+		}, nil)
+	suite.expectRefreshJob(jobID1, nil)
+	suite.expectRefreshJob(jobID2, errors.New("test error"))
+
+	resp, err := suite.handler.StartRefresh(
+		context.Background(),
+		&jobmgrsvc.StartRefreshRequest{
+			JobStates: []job.JobState{job.JobState_RUNNING},
+			Rate:      _maxRefreshRate,
+		})
+	suite.NoError(err)
+	suite.Equal(uint32(2), resp.GetTotalJobs())
+
+	status := suite.waitRefresh(resp.GetRefreshId())
+	suite.Equal(jobmgrsvc.RefreshState_REFRESH_STATE_FAILED, status.GetState())
+	suite.Equal(uint32(2), status.GetProcessedJobs())
+	suite.Equal([]*jobmgrsvc.RefreshFailure{
+		{JobId: jobID2, Message: "test error"},
+	}, status.GetFailures())
+}
+
+// TestStartRefreshNotLeader tests that refreshes are only started by
+// the leader.
+func (suite *adminServiceHandlerTestSuite) TestStartRefreshNotLeader() {
+	suite.candidate.EXPECT().IsLeader().Return(false)
+
+	_, err := suite.handler.StartRefresh(
+		context.Background(),
+		&jobmgrsvc.StartRefreshRequest{})
+	suite.True(yarpcerrors.IsUnavailable(err))
+}
+
+// TestStartRefreshRunning tests that a refresh is not started while
+// another one is running.
+func (suite *adminServiceHandlerTestSuite) TestStartRefreshRunning() {
+	suite.candidate.EXPECT().IsLeader().Return(true)
+	_, err := suite.handler.refreshes.Add(_refreshOperation, "", 0)
+	suite.NoError(err)
+
+	_, err = suite.handler.StartRefresh(
+		context.Background(),
+		&jobmgrsvc.StartRefreshRequest{
+			JobIds: []*peloton.JobID{{Value: "job1"}},
+		})
+	suite.True(yarpcerrors.IsResourceExhausted(err))
+}
+
+// TestStartRefreshInvalidRequest tests starting a refresh with an
+// invalid request.
+func (suite *adminServiceHandlerTestSuite) TestStartRefreshInvalidRequest() {
+	suite.candidate.EXPECT().IsLeader().Return(true).AnyTimes()
+
+	requests := []*jobmgrsvc.StartRefreshRequest{
+		{
+			JobIds:    []*peloton.JobID{{Value: "job1"}},
+			JobStates: []job.JobState{job.JobState_RUNNING},
+		},
+		{JobIds: []*peloton.JobID{{}}},
+		{JobTypes: []job.JobType{job.JobType(100)}},
+		{Rate: _maxRefreshRate + 1},
+	}
+	for _, req := range requests {
+		_, err := suite.handler.StartRefresh(context.Background(), req)
+		suite.True(yarpcerrors.IsInvalidArgument(err))
+	}
+}
+
+// TestGetRefreshStatusErrors tests getting the status of a refresh
+// which is not tracked.
+func (suite *adminServiceHandlerTestSuite) TestGetRefreshStatusErrors() {
+	suite.candidate.EXPECT().IsLeader().Return(true).AnyTimes()
+
+	_, err := suite.handler.GetRefreshStatus(
+		context.Background(),
+		&jobmgrsvc.GetRefreshStatusRequest{})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+
+	_, err = suite.handler.GetRefreshStatus(
+		context.Background(),
+		&jobmgrsvc.GetRefreshStatusRequest{RefreshId: "unknown"})
+	suite.True(yarpcerrors.IsNotFound(err))
+}
+
+// TestGetMetricsSnapshot tests getting the totals of the metrics
+// matching filters.
+func (suite *adminServiceHandlerTestSuite) TestGetMetricsSnapshot() {
+	totals := metrics.NewTotals()
+	reporter := metrics.NewTotalsReporter(tally.NullStatsReporter, totals)
+	reporter.ReportGauge("jobmgr.goalstate.task_queue_depth", nil, 10)
+	reporter.ReportCounter("jobmgr.goalstate.task_actions", nil, 2)
+	reporter.ReportCounter("jobmgr.goalstate.task_actions", nil, 3)
+	reporter.ReportGauge("jobmgr.cached_jobs",
+		map[string]string{"type": "batch"}, 3)
+	reporter.ReportGauge("jobmgr.cached_jobs",
+		map[string]string{"type": "service"}, 4)
+	reporter.ReportCounter("jobmgr.launched_tasks", nil, 1)
+	reporter.ReportTimer("jobmgr.goalstate.run_duration", nil, time.Second)
+	reporter.ReportTimer("jobmgr.goalstate.run_duration", nil, time.Minute)
+	buckets := tally.DurationBuckets{time.Second, time.Minute}
+	reporter.ReportHistogramDurationSamples(
+		"jobmgr.goalstate.run_duration_histogram", nil,
+		buckets, time.Second, time.Minute, 2)
+	reporter.ReportHistogramDurationSamples(
+		"jobmgr.goalstate.run_duration_histogram", nil,
+		buckets, 0, time.Second, 1)
+	reporter.Flush()
+	suite.handler.totals = totals
+
+	resp, err := suite.handler.GetMetricsSnapshot(
+		context.Background(),
+		&jobmgrsvc.GetMetricsSnapshotRequest{
+			Filters: []string{"goalstate", "cached_jobs"},
+		})
+	suite.NoError(err)
+
+	suite.NotEmpty(resp.GetFlushTime())
+	suite.Equal([]*jobmgrsvc.MetricSnapshot{
+		{
+			Name:  "jobmgr.goalstate.task_actions",
+			Tags:  map[string]string{},
+			Value: 5,
+		},
+	}, resp.GetCounters())
+	suite.Equal([]*jobmgrsvc.MetricSnapshot{
+		{
+			Name:  "jobmgr.cached_jobs",
+			Tags:  map[string]string{"type": "batch"},
+			Value: 3,
+		},
+		{
+			Name:  "jobmgr.cached_jobs",
+			Tags:  map[string]string{"type": "service"},
+			Value: 4,
+		},
+		{
+			Name:  "jobmgr.goalstate.task_queue_depth",
+			Tags:  map[string]string{},
+			Value: 10,
+		},
+	}, resp.GetGauges())
+	suite.Equal([]*jobmgrsvc.TimerSnapshot{
+		{
+			Name:  "jobmgr.goalstate.run_duration",
+			Tags:  map[string]string{},
+			Count: 2,
+			SumNs: int64(time.Second + time.Minute),
+			MaxNs: int64(time.Minute),
+		},
+	}, resp.GetTimers())
+	suite.Equal([]*jobmgrsvc.HistogramSnapshot{
+		{
+			Name: "jobmgr.goalstate.run_duration_histogram",
+			Tags: map[string]string{},
+			Buckets: []*jobmgrsvc.HistogramBucketSnapshot{
+				{UpperBound: float64(time.Second), Samples: 1},
+				{UpperBound: float64(time.Minute), Samples: 2},
+			},
+		},
+	}, resp.GetHistograms())
+
+	resp, err = suite.handler.GetMetricsSnapshot(
+		context.Background(),
+		&jobmgrsvc.GetMetricsSnapshotRequest{})
+	suite.NoError(err)
+	suite.Len(resp.GetCounters(), 2)
+	suite.Len(resp.GetGauges(), 3)
+}
+
+// TestGetMetricsSnapshotNotSupported tests getting a snapshot of the
+// metrics when the metrics scope does not support snapshots.
+func (suite *adminServiceHandlerTestSuite) TestGetMetricsSnapshotNotSupported() {
+	_, err := suite.handler.GetMetricsSnapshot(
+		context.Background(),
+		&jobmgrsvc.GetMetricsSnapshotRequest{})
+	suite.True(yarpcerrors.IsUnimplemented(err))
+}
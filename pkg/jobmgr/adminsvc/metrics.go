@@ -0,0 +1,89 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adminsvc
+
+import (
+	"github.com/uber-go/tally"
+)
+
+// Metrics is the struct containing all the counters that track the
+// internal Job Manager service.
+type Metrics struct {
+	PauseGoalStateAPI  tally.Counter
+	PauseGoalState     tally.Counter
+	PauseGoalStateFail tally.Counter
+
+	ResumeGoalStateAPI  tally.Counter
+	ResumeGoalState     tally.Counter
+	ResumeGoalStateFail tally.Counter
+
+	GetGoalStateStatusAPI tally.Counter
+
+	GetGoalStateQueuesAPI  tally.Counter
+	GetGoalStateQueues     tally.Counter
+	GetGoalStateQueuesFail tally.Counter
+
+	EvaluateGoalStateAPI  tally.Counter
+	EvaluateGoalState     tally.Counter
+	EvaluateGoalStateFail tally.Counter
+
+	StartRefreshAPI  tally.Counter
+	StartRefresh     tally.Counter
+	StartRefreshFail tally.Counter
+
+	GetRefreshStatusAPI  tally.Counter
+	GetRefreshStatus     tally.Counter
+	GetRefreshStatusFail tally.Counter
+
+	GetMetricsSnapshotAPI  tally.Counter
+	GetMetricsSnapshot     tally.Counter
+	GetMetricsSnapshotFail tally.Counter
+}
+
+// NewMetrics returns a new instance of adminsvc.Metrics.
+func NewMetrics(scope tally.Scope) *Metrics {
+	subScope := scope.SubScope("admin")
+	return &Metrics{
+		PauseGoalStateAPI:  subScope.Counter("pause_goal_state_api"),
+		PauseGoalState:     subScope.Counter("pause_goal_state"),
+		PauseGoalStateFail: subScope.Counter("pause_goal_state_fail"),
+
+		ResumeGoalStateAPI:  subScope.Counter("resume_goal_state_api"),
+		ResumeGoalState:     subScope.Counter("resume_goal_state"),
+		ResumeGoalStateFail: subScope.Counter("resume_goal_state_fail"),
+
+		GetGoalStateStatusAPI: subScope.Counter("get_goal_state_status_api"),
+
+		GetGoalStateQueuesAPI:  subScope.Counter("get_goal_state_queues_api"),
+		GetGoalStateQueues:     subScope.Counter("get_goal_state_queues"),
+		GetGoalStateQueuesFail: subScope.Counter("get_goal_state_queues_fail"),
+
+		EvaluateGoalStateAPI:  subScope.Counter("evaluate_goal_state_api"),
+		EvaluateGoalState:     subScope.Counter("evaluate_goal_state"),
+		EvaluateGoalStateFail: subScope.Counter("evaluate_goal_state_fail"),
+
+		StartRefreshAPI:  subScope.Counter("start_refresh_api"),
+		StartRefresh:     subScope.Counter("start_refresh"),
+		StartRefreshFail: subScope.Counter("start_refresh_fail"),
+
+		GetRefreshStatusAPI:  subScope.Counter("get_refresh_status_api"),
+		GetRefreshStatus:     subScope.Counter("get_refresh_status"),
+		GetRefreshStatusFail: subScope.Counter("get_refresh_status_fail"),
+
+		GetMetricsSnapshotAPI:  subScope.Counter("get_metrics_snapshot_api"),
+		GetMetricsSnapshot:     subScope.Counter("get_metrics_snapshot"),
+		GetMetricsSnapshotFail: subScope.Counter("get_metrics_snapshot_fail"),
+	}
+}
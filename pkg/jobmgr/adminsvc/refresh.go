@@ -0,0 +1,140 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adminsvc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/private/jobmgrsvc"
+
+	"github.com/uber/peloton/pkg/jobmgr/asyncop"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// _defaultRefreshRate is the number of jobs refreshed per second if
+	// the rate is not set in the request
+	_defaultRefreshRate = 10
+	// _maxRefreshRate is the maximum number of jobs refreshed per second,
+	// so that a refresh does not overload storage
+	_maxRefreshRate = 200
+	// _refreshJobTimeout is the timeout of refreshing a job
+	_refreshJobTimeout = 30 * time.Second
+	// _maxRunningRefreshes is the maximum number of refreshes running at
+	// the same time, so that the rate of the refreshes is not bypassed
+	// by starting more of them
+	_maxRunningRefreshes = 1
+	// _refreshOperation is the name of the asynchronous operation of a
+	// refresh
+	_refreshOperation = "Refresh"
+)
+
+// errRefreshNotLeader is returned when a job cannot be refreshed because
+// the job manager is not the leader anymore.
+var errRefreshNotLeader = errors.New("job manager is not the leader")
+
+// refreshJobFunc reloads the cache of a job from storage.
+type refreshJobFunc func(ctx context.Context, jobID *peloton.JobID) error
+
+// recordRefreshJob adds the result of refreshing a job to the refresh.
+func recordRefreshJob(
+	op *asyncop.Operation,
+	jobID *peloton.JobID,
+	err error) {
+	var outcomes []asyncop.Outcome
+	if err != nil {
+		outcomes = append(outcomes, asyncop.Outcome{
+			Item:    jobID,
+			Message: err.Error(),
+		})
+	}
+	op.Record(1, outcomes, nil)
+}
+
+// refreshStatus returns the progress of a refresh.
+func refreshStatus(op *asyncop.Operation) *jobmgrsvc.GetRefreshStatusResponse {
+	status := op.Status()
+
+	state := jobmgrsvc.RefreshState_REFRESH_STATE_RUNNING
+	switch status.State {
+	case asyncop.Succeeded:
+		state = jobmgrsvc.RefreshState_REFRESH_STATE_SUCCEEDED
+	case asyncop.Failed:
+		state = jobmgrsvc.RefreshState_REFRESH_STATE_FAILED
+	}
+
+	resp := &jobmgrsvc.GetRefreshStatusResponse{
+		State:         state,
+		TotalJobs:     status.Total,
+		ProcessedJobs: status.Processed,
+		Failures:      []*jobmgrsvc.RefreshFailure{},
+		StartTime:     status.StartTime.UTC().Format(time.RFC3339),
+	}
+	for _, outcome := range status.Outcomes {
+		resp.Failures = append(resp.Failures, &jobmgrsvc.RefreshFailure{
+			JobId:   outcome.Item.(*peloton.JobID),
+			Message: outcome.Message,
+		})
+	}
+	if !status.CompletionTime.IsZero() {
+		resp.CompletionTime = status.CompletionTime.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
+
+// runRefresh refreshes the jobs one after the other, waiting interval
+// between two jobs. The remaining jobs fail if the job manager loses
+// leadership.
+func runRefresh(
+	op *asyncop.Operation,
+	jobIDs []*peloton.JobID,
+	interval time.Duration,
+	refreshJob refreshJobFunc) {
+	defer op.Complete()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for i, jobID := range jobIDs {
+		if i > 0 {
+			<-ticker.C
+		}
+
+		ctx, cancelFunc := context.WithTimeout(
+			op.Context(),
+			_refreshJobTimeout,
+		)
+		err := refreshJob(ctx, jobID)
+		cancelFunc()
+
+		if err == errRefreshNotLeader {
+			for _, remaining := range jobIDs[i:] {
+				recordRefreshJob(op, remaining, err)
+			}
+			return
+		}
+		if err != nil {
+			log.WithError(err).
+				WithField("job_id", jobID.GetValue()).
+				WithField("refresh_id", op.ID()).
+				Warn("failed to refresh job")
+		}
+		recordRefreshJob(op, jobID, err)
+	}
+}
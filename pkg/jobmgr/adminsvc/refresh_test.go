@@ -0,0 +1,104 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adminsvc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/private/jobmgrsvc"
+
+	"github.com/uber/peloton/pkg/jobmgr/asyncop"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshRunNotLeader(t *testing.T) {
+	refreshes := asyncop.NewTracker(_maxRunningRefreshes)
+	jobIDs := []*peloton.JobID{
+		{Value: "job1"},
+		{Value: "job2"},
+		{Value: "job3"},
+	}
+	op, err := refreshes.Add(_refreshOperation, "", uint32(len(jobIDs)))
+	assert.NoError(t, err)
+
+	refreshed := 0
+	runRefresh(op, jobIDs, time.Millisecond, func(
+		context.Context,
+		*peloton.JobID,
+	) error {
+		refreshed++
+		if refreshed > 1 {
+			return errRefreshNotLeader
+		}
+		return nil
+	})
+	assert.Equal(t, 2, refreshed)
+
+	status := refreshStatus(op)
+	assert.Equal(t, jobmgrsvc.RefreshState_REFRESH_STATE_FAILED, status.GetState())
+	assert.Equal(t, uint32(3), status.GetTotalJobs())
+	assert.Equal(t, uint32(3), status.GetProcessedJobs())
+	assert.Len(t, status.GetFailures(), 2)
+	assert.Equal(t, "job2", status.GetFailures()[0].GetJobId().GetValue())
+	assert.Equal(t, errRefreshNotLeader.Error(), status.GetFailures()[0].GetMessage())
+	assert.NotEmpty(t, status.GetCompletionTime())
+}
+
+// TestRefreshRunningFailure tests that a refresh keeps running after a
+// job fails to be refreshed, and fails once completed
+func TestRefreshRunningFailure(t *testing.T) {
+	refreshes := asyncop.NewTracker(_maxRunningRefreshes)
+	jobIDs := []*peloton.JobID{
+		{Value: "job1"},
+		{Value: "job2"},
+	}
+	op, err := refreshes.Add(_refreshOperation, "", uint32(len(jobIDs)))
+	assert.NoError(t, err)
+
+	runRefresh(op, jobIDs, time.Millisecond, func(
+		_ context.Context,
+		jobID *peloton.JobID,
+	) error {
+		status := refreshStatus(op)
+		assert.Equal(t,
+			jobmgrsvc.RefreshState_REFRESH_STATE_RUNNING, status.GetState())
+		assert.Empty(t, status.GetCompletionTime())
+		if jobID.GetValue() == "job2" {
+			return nil
+		}
+		return errors.New("test error")
+	})
+	assert.Equal(t,
+		jobmgrsvc.RefreshState_REFRESH_STATE_FAILED, refreshStatus(op).GetState())
+}
+
+// TestRefreshSucceeded tests that a refresh succeeds once all its jobs
+// are refreshed
+func TestRefreshSucceeded(t *testing.T) {
+	refreshes := asyncop.NewTracker(_maxRunningRefreshes)
+	op, err := refreshes.Add(_refreshOperation, "", 0)
+	assert.NoError(t, err)
+
+	runRefresh(op, nil, time.Millisecond, nil)
+	status := refreshStatus(op)
+	assert.Equal(t,
+		jobmgrsvc.RefreshState_REFRESH_STATE_SUCCEEDED, status.GetState())
+	assert.Empty(t, status.GetFailures())
+}
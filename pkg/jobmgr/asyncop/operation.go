@@ -0,0 +1,273 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asyncop
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pborman/uuid"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// _retention is how long a completed operation is kept for its status
+// to be read
+const _retention = time.Hour
+
+// ErrStopped fails the items of an operation not processed when the job
+// manager loses leadership or shuts down.
+var ErrStopped = yarpcerrors.AbortedErrorf(
+	"operation stopped as the job manager is not the leader")
+
+// State is the state of an operation.
+type State int
+
+const (
+	// Running is the state of an operation whose items are being
+	// processed.
+	Running State = iota + 1
+	// Succeeded is the state of an operation whose items have all been
+	// processed successfully.
+	Succeeded
+	// Failed is the state of an operation whose items have all been
+	// processed, and some of them failed.
+	Failed
+)
+
+// Outcome is the outcome of processing an item of an operation.
+type Outcome struct {
+	// Item is the item processed, such as the instance ID of a task, or
+	// the ID of a job
+	Item interface{}
+	// Succeeded is true if the item was processed successfully
+	Succeeded bool
+	// Message is the reason of the failure of the item
+	Message string
+}
+
+// Status is the progress of an operation.
+type Status struct {
+	// Name of the operation, such as Stop
+	Name string
+	// Subject the operation applies to, such as the ID of a job
+	Subject        string
+	State          State
+	Total          uint32
+	Processed      uint32
+	Outcomes       []Outcome
+	StartTime      time.Time
+	CompletionTime time.Time
+}
+
+// Operation tracks the progress of an asynchronous operation over a
+// number of items.
+type Operation struct {
+	sync.RWMutex
+
+	// ctx is cancelled when the tracker of the operation is stopped
+	ctx       context.Context
+	id        string
+	name      string
+	subject   string
+	state     State
+	total     uint32
+	processed uint32
+	outcomes  []Outcome
+	// failed is true once any of the items failed
+	failed         bool
+	startTime      time.Time
+	completionTime time.Time
+}
+
+// ID returns the ID of the operation.
+func (op *Operation) ID() string {
+	return op.id
+}
+
+// Name returns the name of the operation.
+func (op *Operation) Name() string {
+	return op.name
+}
+
+// Subject returns the subject the operation applies to.
+func (op *Operation) Subject() string {
+	return op.subject
+}
+
+// Context returns the context of the operation, which is cancelled when
+// the job manager loses leadership or shuts down.
+func (op *Operation) Context() context.Context {
+	return op.ctx
+}
+
+// Record adds the outcomes of processing a number of items. The
+// operation fails once completed if any of the items failed, or if err
+// is not nil.
+func (op *Operation) Record(processed uint32, outcomes []Outcome, err error) {
+	op.Lock()
+	defer op.Unlock()
+
+	op.processed += processed
+	op.outcomes = append(op.outcomes, outcomes...)
+	for _, outcome := range outcomes {
+		if !outcome.Succeeded {
+			op.failed = true
+		}
+	}
+	if err != nil {
+		op.failed = true
+	}
+}
+
+// Complete marks all the items of the operation processed. The
+// operation keeps running until completed, even if some items failed.
+func (op *Operation) Complete() {
+	op.Lock()
+	defer op.Unlock()
+
+	op.state = Succeeded
+	if op.failed {
+		op.state = Failed
+	}
+	op.completionTime = time.Now()
+}
+
+// Status returns the progress of the operation.
+func (op *Operation) Status() *Status {
+	op.RLock()
+	defer op.RUnlock()
+
+	return &Status{
+		Name:           op.name,
+		Subject:        op.subject,
+		State:          op.state,
+		Total:          op.total,
+		Processed:      op.processed,
+		Outcomes:       append([]Outcome{}, op.outcomes...),
+		StartTime:      op.startTime,
+		CompletionTime: op.completionTime,
+	}
+}
+
+// running returns true if the operation has not completed yet.
+func (op *Operation) running() bool {
+	op.RLock()
+	defer op.RUnlock()
+
+	return op.completionTime.IsZero()
+}
+
+// expired returns true if the operation completed long enough ago to
+// be forgotten.
+func (op *Operation) expired(now time.Time) bool {
+	op.RLock()
+	defer op.RUnlock()
+
+	return !op.completionTime.IsZero() &&
+		now.Sub(op.completionTime) > _retention
+}
+
+// Tracker tracks the asynchronous operations of a service of the job
+// manager, so that their progress can be read after the call starting
+// them returns. The operations are tracked in memory, and are forgotten
+// an hour after they complete. The context of the operations is
+// cancelled when the tracker is stopped, when the job manager loses
+// leadership or shuts down.
+type Tracker struct {
+	sync.RWMutex
+
+	// maxRunning is the maximum number of operations running at the same
+	// time, 0 if unlimited
+	maxRunning int
+	operations map[string]*Operation
+	// ctx of the operations, cancelled by Stop
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewTracker returns a Tracker ready to run operations, at most
+// maxRunning of them at the same time unless it is 0.
+func NewTracker(maxRunning int) *Tracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Tracker{
+		maxRunning: maxRunning,
+		operations: make(map[string]*Operation),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Start lets the operations added from now on run, once the job manager
+// gains leadership.
+func (t *Tracker) Start() {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.ctx.Err() != nil {
+		t.ctx, t.cancel = context.WithCancel(context.Background())
+	}
+}
+
+// Stop cancels the running operations, so that they do not change the
+// jobs after the job manager loses leadership or shuts down.
+func (t *Tracker) Stop() {
+	t.Lock()
+	defer t.Unlock()
+
+	t.cancel()
+}
+
+// Add starts tracking a new operation over total items, and forgets the
+// expired ones. Returns a resource exhausted error if the maximum number
+// of operations are running already.
+func (t *Tracker) Add(name, subject string, total uint32) (*Operation, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	now := time.Now()
+	running := 0
+	for id, op := range t.operations {
+		if op.expired(now) {
+			delete(t.operations, id)
+		} else if op.running() {
+			running++
+		}
+	}
+	if t.maxRunning > 0 && running >= t.maxRunning {
+		return nil, yarpcerrors.ResourceExhaustedErrorf(
+			"%d %s operations are running already", running, name)
+	}
+
+	op := &Operation{
+		ctx:       t.ctx,
+		id:        uuid.New(),
+		name:      name,
+		subject:   subject,
+		state:     Running,
+		total:     total,
+		startTime: now,
+	}
+	t.operations[op.id] = op
+	return op, nil
+}
+
+// Get returns the operation with the id, or nil if it is not tracked.
+func (t *Tracker) Get(id string) *Operation {
+	t.RLock()
+	defer t.RUnlock()
+
+	return t.operations[id]
+}
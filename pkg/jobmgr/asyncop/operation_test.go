@@ -0,0 +1,98 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asyncop
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+func TestOperationRecord(t *testing.T) {
+	tracker := NewTracker(0)
+	op, err := tracker.Add("Stop", "job", 4)
+	assert.NoError(t, err)
+	assert.Equal(t, op, tracker.Get(op.ID()))
+	assert.Equal(t, Running, op.Status().State)
+
+	op.Record(2, []Outcome{{Item: uint32(0), Succeeded: true}}, nil)
+	assert.Equal(t, Running, op.Status().State)
+	op.Record(2, nil, errors.New("test error"))
+	// the operation keeps running until completed
+	assert.Equal(t, Running, op.Status().State)
+	op.Complete()
+
+	status := op.Status()
+	assert.Equal(t, "Stop", status.Name)
+	assert.Equal(t, "job", status.Subject)
+	assert.Equal(t, Failed, status.State)
+	assert.Equal(t, uint32(4), status.Total)
+	assert.Equal(t, uint32(4), status.Processed)
+	assert.Equal(t,
+		[]Outcome{{Item: uint32(0), Succeeded: true}}, status.Outcomes)
+	assert.False(t, status.CompletionTime.IsZero())
+
+	op, err = tracker.Add("Stop", "job", 1)
+	assert.NoError(t, err)
+	op.Record(1, []Outcome{{Item: uint32(0), Message: "failed"}}, nil)
+	op.Complete()
+	assert.Equal(t, Failed, op.Status().State)
+}
+
+func TestTrackerExpire(t *testing.T) {
+	tracker := NewTracker(0)
+	op, err := tracker.Add("Start", "job", 0)
+	assert.NoError(t, err)
+	op.Complete()
+	assert.Equal(t, Succeeded, op.Status().State)
+
+	op.completionTime = time.Now().Add(-2 * _retention)
+	_, err = tracker.Add("Start", "job", 0)
+	assert.NoError(t, err)
+	assert.Nil(t, tracker.Get(op.ID()))
+}
+
+// TestTrackerMaxRunning tests that an operation is not started while
+// the maximum number of operations are running
+func TestTrackerMaxRunning(t *testing.T) {
+	tracker := NewTracker(1)
+	op, err := tracker.Add("Refresh", "", 0)
+	assert.NoError(t, err)
+
+	_, err = tracker.Add("Refresh", "", 0)
+	assert.True(t, yarpcerrors.IsResourceExhausted(err))
+
+	op.Complete()
+	_, err = tracker.Add("Refresh", "", 0)
+	assert.NoError(t, err)
+}
+
+func TestTrackerStop(t *testing.T) {
+	tracker := NewTracker(0)
+	op, err := tracker.Add("Stop", "job", 4)
+	assert.NoError(t, err)
+
+	tracker.Stop()
+	assert.Error(t, op.Context().Err())
+
+	// the operations added once the job manager gains leadership again run
+	tracker.Start()
+	op, err = tracker.Add("Stop", "job", 2)
+	assert.NoError(t, err)
+	assert.NoError(t, op.Context().Err())
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import "time"
+
+const (
+	_defaultSnapshotInterval = time.Hour
+	// snapshots are looked up one day at a time, so they must be taken
+	// at least daily to be found
+	_maxSnapshotInterval = 24 * time.Hour
+)
+
+// Config for the snapshots of the cluster state
+type Config struct {
+	// Whether snapshots of the cluster state are taken by the leader,
+	// which enables exporting the changes made to the cluster
+	Enabled bool `yaml:"enabled"`
+
+	// Interval at which the snapshots are taken. The changes are exported
+	// at the granularity of the snapshots. Must be at most a day.
+	SnapshotInterval time.Duration `yaml:"snapshot_interval"`
+}
+
+func (c *Config) normalize() {
+	if c.SnapshotInterval <= 0 {
+		c.SnapshotInterval = _defaultSnapshotInterval
+	}
+	if c.SnapshotInterval > _maxSnapshotInterval {
+		c.SnapshotInterval = _maxSnapshotInterval
+	}
+}
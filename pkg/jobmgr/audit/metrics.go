@@ -12,27 +12,28 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package deadline
+package audit
 
-import (
-	"github.com/uber-go/tally"
-)
+import "github.com/uber-go/tally"
 
-// Metrics is the struct containing all the counters that track internal state
-// of deadline tracker.
+// Metrics is the struct containing all the counters that track the
+// snapshots of the cluster state
 type Metrics struct {
-	TaskKillSuccess tally.Counter
-	TaskKillFail    tally.Counter
+	SnapshotSuccess tally.Counter
+	SnapshotFail    tally.Counter
+	// Size of the encoded snapshots in bytes
+	SnapshotSize tally.Gauge
 }
 
 // NewMetrics returns a new Metrics struct, with all metrics
 // initialized and rooted at the given tally.Scope
 func NewMetrics(scope tally.Scope) *Metrics {
-	taskSuccessScope := scope.Tagged(map[string]string{"result": "success"})
-	taskFailScope := scope.Tagged(map[string]string{"result": "fail"})
+	successScope := scope.Tagged(map[string]string{"result": "success"})
+	failScope := scope.Tagged(map[string]string{"result": "fail"})
 
 	return &Metrics{
-		TaskKillSuccess: taskSuccessScope.Counter("deadline"),
-		TaskKillFail:    taskFailScope.Counter("deadline"),
+		SnapshotSuccess: successScope.Counter("snapshot"),
+		SnapshotFail:    failScope.Counter("snapshot"),
+		SnapshotSize:    scope.Gauge("snapshot_size"),
 	}
 }
@@ -0,0 +1,269 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/host/svc"
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/respool"
+
+	"github.com/uber/peloton/pkg/common/background"
+	"github.com/uber/peloton/pkg/storage"
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/uber-go/atomic"
+	"github.com/uber-go/tally"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const (
+	// _snapshotWorkName is the name of the background work taking the
+	// snapshots
+	_snapshotWorkName = "cluster_state_snapshot"
+
+	// _snapshotTimeout is the timeout of taking a snapshot, of reading
+	// the times of the snapshots of a day, and of reading a snapshot
+	_snapshotTimeout = time.Minute
+)
+
+// Exporter exports the changes made to the cluster between two points
+// in time.
+type Exporter interface {
+	// ExportDiff returns the changes made to the cluster between the
+	// latest snapshots taken before from and to. The current state of
+	// the cluster is used if to is zero.
+	ExportDiff(
+		ctx context.Context,
+		from time.Time,
+		to time.Time,
+	) (*job.ExportStateDiffResponse, error)
+}
+
+// ensure that Recorder satisfies the Exporter interface
+var _ Exporter = (*Recorder)(nil)
+
+// Recorder takes periodic snapshots of the cluster state, and exports
+// the changes made to the cluster between two snapshots.
+type Recorder struct {
+	jobStore     storage.JobStore
+	respoolStore storage.ResourcePoolStore
+	hostClient   svc.HostServiceYARPCClient
+	snapshotOps  ormobjects.ClusterSnapshotOps
+
+	interval time.Duration
+	metrics  *Metrics
+}
+
+// NewRecorder returns a Recorder reading the jobs and resource pools
+// from the stores and the hosts from the host manager.
+func NewRecorder(
+	jobStore storage.JobStore,
+	respoolStore storage.ResourcePoolStore,
+	hostClient svc.HostServiceYARPCClient,
+	snapshotOps ormobjects.ClusterSnapshotOps,
+	cfg Config,
+	parent tally.Scope,
+) *Recorder {
+	cfg.normalize()
+	return &Recorder{
+		jobStore:     jobStore,
+		respoolStore: respoolStore,
+		hostClient:   hostClient,
+		snapshotOps:  snapshotOps,
+		interval:     cfg.SnapshotInterval,
+		metrics:      NewMetrics(parent.SubScope("jobmgr").SubScope("audit")),
+	}
+}
+
+// Work returns the background work taking the snapshots, which is meant
+// to run on the leader only.
+func (r *Recorder) Work() background.Work {
+	return background.Work{
+		Name: _snapshotWorkName,
+		Func: func(_ *atomic.Bool) {
+			if err := r.snapshot(); err != nil {
+				log.WithError(err).Warn("Failed to snapshot cluster state")
+				r.metrics.SnapshotFail.Inc(1)
+				return
+			}
+			r.metrics.SnapshotSuccess.Inc(1)
+		},
+		Period: r.interval,
+	}
+}
+
+// snapshot writes the current state of the cluster to the store.
+func (r *Recorder) snapshot() error {
+	ctx, cancel := context.WithTimeout(context.Background(), _snapshotTimeout)
+	defer cancel()
+
+	state, err := r.collect(ctx)
+	if err != nil {
+		return err
+	}
+	data, err := state.encode()
+	if err != nil {
+		return err
+	}
+	r.metrics.SnapshotSize.Update(float64(len(data)))
+
+	return r.snapshotOps.Add(ctx, &ormobjects.ClusterSnapshotObject{
+		SnapshotTime: state.Time,
+		Data:         data,
+	})
+}
+
+// collect returns the current state of the cluster.
+func (r *Recorder) collect(ctx context.Context) (*ClusterState, error) {
+	state := &ClusterState{
+		Time:     time.Now().UTC(),
+		Jobs:     make(map[string]*JobState),
+		Respools: make(map[string]*respool.ResourcePoolConfig),
+		Hosts:    make(map[string]*HostState),
+	}
+
+	summaries, err := r.jobStore.GetAllJobsInJobIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, summary := range summaries {
+		state.Jobs[summary.GetId().GetValue()] = newJobState(summary)
+	}
+
+	respools, err := r.respoolStore.GetAllResourcePools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for id, config := range respools {
+		state.Respools[id] = config
+	}
+
+	hosts, err := r.hostClient.QueryHosts(ctx, &svc.QueryHostsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	for _, hostInfo := range hosts.GetHostInfos() {
+		state.Hosts[hostInfo.GetHostname()] = &HostState{
+			IP:    hostInfo.GetIp(),
+			State: hostInfo.GetState().String(),
+		}
+	}
+	return state, nil
+}
+
+// ExportDiff returns the changes made to the cluster between the latest
+// snapshots taken before from and to. The current state of the cluster
+// is used if to is zero.
+func (r *Recorder) ExportDiff(
+	ctx context.Context,
+	from time.Time,
+	to time.Time,
+) (*job.ExportStateDiffResponse, error) {
+	if !to.IsZero() && to.Before(from) {
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"end of the period is before its start")
+	}
+
+	fromState, err := r.getSnapshot(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+
+	var toState *ClusterState
+	if to.IsZero() {
+		toState, err = r.collect(ctx)
+		if err != nil {
+			return nil, yarpcerrors.InternalErrorf(
+				"failed to read cluster state: %v", err)
+		}
+	} else {
+		toState, err = r.getSnapshot(ctx, to)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	changes, err := diffStates(fromState, toState)
+	if err != nil {
+		return nil, yarpcerrors.InternalErrorf(
+			"failed to diff cluster states: %v", err)
+	}
+	return &job.ExportStateDiffResponse{
+		FromSnapshotTime: fromState.Time.UTC().Format(time.RFC3339),
+		ToSnapshotTime:   toState.Time.UTC().Format(time.RFC3339),
+		Changes:          changes,
+	}, nil
+}
+
+// getSnapshot returns the latest snapshot taken at or before t. Only the
+// times of the snapshots of the day of t, and of the day before if none
+// was taken before t that day, are read to find it, as snapshots are taken
+// at least daily.
+func (r *Recorder) getSnapshot(
+	ctx context.Context,
+	t time.Time,
+) (*ClusterState, error) {
+	snapshotTime, err := r.getSnapshotTime(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, _snapshotTimeout)
+	defer cancel()
+	snapshot, err := r.snapshotOps.Get(readCtx, snapshotTime)
+	if err != nil {
+		return nil, yarpcerrors.InternalErrorf(
+			"failed to read cluster snapshot of %s: %v",
+			snapshotTime.UTC().Format(time.RFC3339), err)
+	}
+	state, err := decodeClusterState(snapshot.Data)
+	if err != nil {
+		return nil, yarpcerrors.InternalErrorf(
+			"failed to decode cluster snapshot of %s: %v",
+			snapshotTime.UTC().Format(time.RFC3339), err)
+	}
+	return state, nil
+}
+
+// getSnapshotTime returns the time of the latest snapshot taken at or
+// before t.
+func (r *Recorder) getSnapshotTime(
+	ctx context.Context,
+	t time.Time,
+) (time.Time, error) {
+	day := ormobjects.ClusterSnapshotDay(t)
+	for d := day; d >= day-1; d-- {
+		readCtx, cancel := context.WithTimeout(ctx, _snapshotTimeout)
+		times, err := r.snapshotOps.GetTimes(readCtx, d)
+		cancel()
+		if err != nil {
+			return time.Time{}, yarpcerrors.InternalErrorf(
+				"failed to read cluster snapshot times: %v", err)
+		}
+
+		for i := len(times) - 1; i >= 0; i-- {
+			if !times[i].After(t) {
+				return times[i], nil
+			}
+		}
+	}
+	return time.Time{}, yarpcerrors.NotFoundErrorf(
+		"no snapshot of the cluster state taken before %s",
+		t.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	hpb "github.com/uber/peloton/.gen/peloton/api/v0/host"
+	"github.com/uber/peloton/.gen/peloton/api/v0/host/svc"
+	hostmocks "github.com/uber/peloton/.gen/peloton/api/v0/host/svc/mocks"
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/respool"
+
+	storemocks "github.com/uber/peloton/pkg/storage/mocks"
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
+	objectmocks "github.com/uber/peloton/pkg/storage/objects/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+type RecorderTestSuite struct {
+	suite.Suite
+
+	ctrl         *gomock.Controller
+	jobStore     *storemocks.MockJobStore
+	respoolStore *storemocks.MockResourcePoolStore
+	hostClient   *hostmocks.MockHostServiceYARPCClient
+	snapshotOps  *objectmocks.MockClusterSnapshotOps
+	recorder     *Recorder
+}
+
+func (suite *RecorderTestSuite) SetupTest() {
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.jobStore = storemocks.NewMockJobStore(suite.ctrl)
+	suite.respoolStore = storemocks.NewMockResourcePoolStore(suite.ctrl)
+	suite.hostClient = hostmocks.NewMockHostServiceYARPCClient(suite.ctrl)
+	suite.snapshotOps = objectmocks.NewMockClusterSnapshotOps(suite.ctrl)
+	suite.recorder = NewRecorder(
+		suite.jobStore,
+		suite.respoolStore,
+		suite.hostClient,
+		suite.snapshotOps,
+		Config{Enabled: true},
+		tally.NoopScope,
+	)
+}
+
+func (suite *RecorderTestSuite) TearDownTest() {
+	suite.ctrl.Finish()
+}
+
+func TestRecorder(t *testing.T) {
+	suite.Run(t, new(RecorderTestSuite))
+}
+
+// expectState sets the expectations of reading the given cluster state
+func (suite *RecorderTestSuite) expectState(
+	configVersion uint64,
+	respoolName string,
+	hostState hpb.HostState,
+) {
+	suite.jobStore.EXPECT().
+		GetAllJobsInJobIndex(gomock.Any()).
+		Return([]*job.JobSummary{{
+			Id:            &peloton.JobID{Value: "job1"},
+			Name:          "job1",
+			Type:          job.JobType_SERVICE,
+			InstanceCount: 2,
+			RespoolID:     &peloton.ResourcePoolID{Value: "respool1"},
+			Runtime: &job.RuntimeInfo{
+				State:                job.JobState_RUNNING,
+				GoalState:            job.JobState_RUNNING,
+				ConfigurationVersion: configVersion,
+			},
+		}}, nil)
+	suite.respoolStore.EXPECT().
+		GetAllResourcePools(gomock.Any()).
+		Return(map[string]*respool.ResourcePoolConfig{
+			"respool1": {Name: respoolName},
+		}, nil)
+	suite.hostClient.EXPECT().
+		QueryHosts(gomock.Any(), &svc.QueryHostsRequest{}).
+		Return(&svc.QueryHostsResponse{
+			HostInfos: []*hpb.HostInfo{{
+				Hostname: "host1",
+				Ip:       "10.0.0.1",
+				State:    hostState,
+			}},
+		}, nil)
+}
+
+// TestSnapshotAndExportDiff tests taking a snapshot of the cluster state,
+// and exporting the changes made to the cluster since then
+func (suite *RecorderTestSuite) TestSnapshotAndExportDiff() {
+	var snapshot *ormobjects.ClusterSnapshotObject
+	suite.expectState(
+		1,
+		"respool1",
+		hpb.HostState_HOST_STATE_UP)
+	suite.snapshotOps.EXPECT().
+		Add(gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context, obj *ormobjects.ClusterSnapshotObject) {
+			snapshot = obj
+		}).
+		Return(nil)
+	suite.recorder.Work().Func(nil)
+	suite.NotNil(snapshot)
+
+	suite.snapshotOps.EXPECT().
+		GetTimes(gomock.Any(), ormobjects.ClusterSnapshotDay(snapshot.SnapshotTime)).
+		Return([]time.Time{snapshot.SnapshotTime}, nil)
+	suite.snapshotOps.EXPECT().
+		Get(gomock.Any(), snapshot.SnapshotTime).
+		Return(snapshot, nil)
+	suite.expectState(
+		2,
+		"respool1",
+		hpb.HostState_HOST_STATE_DRAINING)
+	resp, err := suite.recorder.ExportDiff(
+		context.Background(), snapshot.SnapshotTime, time.Time{})
+	suite.NoError(err)
+	suite.Equal(snapshot.SnapshotTime.Format(time.RFC3339), resp.GetFromSnapshotTime())
+
+	suite.Len(resp.GetChanges(), 2)
+	suite.Equal(job.StateChange_KIND_JOB, resp.GetChanges()[0].GetKind())
+	suite.Equal("job1", resp.GetChanges()[0].GetId())
+	suite.Equal(job.StateChange_TYPE_MODIFIED, resp.GetChanges()[0].GetType())
+	suite.Contains(resp.GetChanges()[0].GetBefore(), `"configVersion":1`)
+	suite.Contains(resp.GetChanges()[0].GetAfter(), `"configVersion":2`)
+	suite.Equal(job.StateChange_KIND_HOST, resp.GetChanges()[1].GetKind())
+	suite.Equal("host1", resp.GetChanges()[1].GetId())
+	suite.Contains(resp.GetChanges()[1].GetAfter(), "HOST_STATE_DRAINING")
+}
+
+// TestSnapshotFail tests that no snapshot is written if the cluster state
+// can not be read
+func (suite *RecorderTestSuite) TestSnapshotFail() {
+	suite.jobStore.EXPECT().
+		GetAllJobsInJobIndex(gomock.Any()).
+		Return(nil, errors.New("test error"))
+	suite.recorder.Work().Func(nil)
+}
+
+// TestExportDiffErrors tests exporting the changes of invalid periods or
+// of periods before the first snapshot
+func (suite *RecorderTestSuite) TestExportDiffErrors() {
+	now := time.Now()
+	_, err := suite.recorder.ExportDiff(
+		context.Background(), now, now.Add(-time.Hour))
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+
+	// only the snapshots taken before the start of the period are used
+	suite.snapshotOps.EXPECT().
+		GetTimes(gomock.Any(), ormobjects.ClusterSnapshotDay(now)).
+		Return([]time.Time{now.Add(time.Minute)}, nil)
+	suite.snapshotOps.EXPECT().
+		GetTimes(gomock.Any(), ormobjects.ClusterSnapshotDay(now)-1).
+		Return(nil, nil)
+	_, err = suite.recorder.ExportDiff(context.Background(), now, time.Time{})
+	suite.True(yarpcerrors.IsNotFound(err))
+
+	suite.snapshotOps.EXPECT().
+		GetTimes(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("test error"))
+	_, err = suite.recorder.ExportDiff(context.Background(), now, time.Time{})
+	suite.True(yarpcerrors.IsInternal(err))
+
+	suite.snapshotOps.EXPECT().
+		GetTimes(gomock.Any(), ormobjects.ClusterSnapshotDay(now)).
+		Return([]time.Time{now.Add(-time.Minute)}, nil)
+	suite.snapshotOps.EXPECT().
+		Get(gomock.Any(), now.Add(-time.Minute)).
+		Return(nil, errors.New("test error"))
+	_, err = suite.recorder.ExportDiff(context.Background(), now, time.Time{})
+	suite.True(yarpcerrors.IsInternal(err))
+}
+
+// TestDiffStates tests the changes between two cluster states
+func (suite *RecorderTestSuite) TestDiffStates() {
+	from := &ClusterState{
+		Jobs: map[string]*JobState{
+			"job1": {Name: "job1", ConfigVersion: 1},
+			"job2": {Name: "job2"},
+		},
+		Respools: map[string]*respool.ResourcePoolConfig{
+			"respool1": {Name: "respool1"},
+		},
+	}
+	to := &ClusterState{
+		Jobs: map[string]*JobState{
+			"job1": {Name: "job1", ConfigVersion: 2},
+			"job3": {Name: "job3"},
+		},
+		Respools: map[string]*respool.ResourcePoolConfig{
+			"respool1": {Name: "respool1"},
+		},
+	}
+
+	data, err := to.encode()
+	suite.NoError(err)
+	decoded, err := decodeClusterState(data)
+	suite.NoError(err)
+
+	changes, err := diffStates(from, decoded)
+	suite.NoError(err)
+	suite.Len(changes, 3)
+	suite.Equal("job1", changes[0].GetId())
+	suite.Equal(job.StateChange_TYPE_MODIFIED, changes[0].GetType())
+	suite.Equal("job2", changes[1].GetId())
+	suite.Equal(job.StateChange_TYPE_REMOVED, changes[1].GetType())
+	suite.Empty(changes[1].GetAfter())
+	suite.Equal("job3", changes[2].GetId())
+	suite.Equal(job.StateChange_TYPE_ADDED, changes[2].GetType())
+	suite.Empty(changes[2].GetBefore())
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/respool"
+)
+
+// ClusterState is a snapshot of the jobs, resource pools and hosts of
+// the cluster. Only the fields which are changed by users or operators
+// are kept, so that the snapshots are not different merely because
+// tasks ran.
+type ClusterState struct {
+	// Time the snapshot was taken at
+	Time time.Time `json:"time"`
+	// Jobs by job ID
+	Jobs map[string]*JobState `json:"jobs"`
+	// Resource pools by resource pool ID
+	Respools map[string]*respool.ResourcePoolConfig `json:"respools"`
+	// Hosts by hostname
+	Hosts map[string]*HostState `json:"hosts"`
+}
+
+// JobState is the state of a job in a snapshot. The state and goal state
+// of the job are not kept, as they change whenever its tasks run.
+type JobState struct {
+	Name          string           `json:"name"`
+	Type          string           `json:"type"`
+	Owner         string           `json:"owner,omitempty"`
+	OwningTeam    string           `json:"owningTeam,omitempty"`
+	Labels        []*peloton.Label `json:"labels,omitempty"`
+	InstanceCount uint32           `json:"instanceCount"`
+	RespoolID     string           `json:"respoolId"`
+	ConfigVersion uint64           `json:"configVersion"`
+}
+
+// HostState is the state of a host in a snapshot.
+type HostState struct {
+	IP    string `json:"ip"`
+	State string `json:"state"`
+}
+
+// newJobState returns the state of a job from its summary.
+func newJobState(summary *job.JobSummary) *JobState {
+	return &JobState{
+		Name:          summary.GetName(),
+		Type:          summary.GetType().String(),
+		Owner:         summary.GetOwner(),
+		OwningTeam:    summary.GetOwningTeam(),
+		Labels:        summary.GetLabels(),
+		InstanceCount: summary.GetInstanceCount(),
+		RespoolID:     summary.GetRespoolID().GetValue(),
+		ConfigVersion: summary.GetRuntime().GetConfigurationVersion(),
+	}
+}
+
+// encode returns the gzipped JSON of the state.
+func (s *ClusterState) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(w).Encode(s); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeClusterState returns the state encoded by encode.
+func decodeClusterState(data []byte) (*ClusterState, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err = ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	s := &ClusterState{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// diffStates returns the changes made to the cluster between the states,
+// sorted by kind and ID.
+func diffStates(from *ClusterState, to *ClusterState) ([]*job.StateChange, error) {
+	var changes []*job.StateChange
+	add := func(kind job.StateChange_Kind, before, after map[string]interface{}) error {
+		c, err := diffEntities(kind, before, after)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, c...)
+		return nil
+	}
+
+	if err := add(
+		job.StateChange_KIND_JOB,
+		jobEntities(from.Jobs),
+		jobEntities(to.Jobs),
+	); err != nil {
+		return nil, err
+	}
+	if err := add(
+		job.StateChange_KIND_RESPOOL,
+		respoolEntities(from.Respools),
+		respoolEntities(to.Respools),
+	); err != nil {
+		return nil, err
+	}
+	if err := add(
+		job.StateChange_KIND_HOST,
+		hostEntities(from.Hosts),
+		hostEntities(to.Hosts),
+	); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// diffEntities returns the changes made to the entities of a kind,
+// sorted by ID. The entities are compared by their JSON, which is
+// returned along with the changes.
+func diffEntities(
+	kind job.StateChange_Kind,
+	before map[string]interface{},
+	after map[string]interface{},
+) ([]*job.StateChange, error) {
+	ids := make(map[string]bool)
+	for id := range before {
+		ids[id] = true
+	}
+	for id := range after {
+		ids[id] = true
+	}
+
+	var changes []*job.StateChange
+	for id := range ids {
+		b, err := marshalEntity(before[id])
+		if err != nil {
+			return nil, err
+		}
+		a, err := marshalEntity(after[id])
+		if err != nil {
+			return nil, err
+		}
+
+		change := &job.StateChange{
+			Kind:   kind,
+			Id:     id,
+			Before: b,
+			After:  a,
+		}
+		switch {
+		case b == a:
+			continue
+		case len(b) == 0:
+			change.Type = job.StateChange_TYPE_ADDED
+		case len(a) == 0:
+			change.Type = job.StateChange_TYPE_REMOVED
+		default:
+			change.Type = job.StateChange_TYPE_MODIFIED
+		}
+		changes = append(changes, change)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].GetId() < changes[j].GetId()
+	})
+	return changes, nil
+}
+
+// marshalEntity returns the JSON of an entity, or an empty string if
+// the entity does not exist.
+func marshalEntity(entity interface{}) (string, error) {
+	if entity == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func jobEntities(jobs map[string]*JobState) map[string]interface{} {
+	entities := make(map[string]interface{}, len(jobs))
+	for id, j := range jobs {
+		entities[id] = j
+	}
+	return entities
+}
+
+func respoolEntities(
+	respools map[string]*respool.ResourcePoolConfig,
+) map[string]interface{} {
+	entities := make(map[string]interface{}, len(respools))
+	for id, r := range respools {
+		entities[id] = r
+	}
+	return entities
+}
+
+func hostEntities(hosts map[string]*HostState) map[string]interface{} {
+	entities := make(map[string]interface{}, len(hosts))
+	for hostname, h := range hosts {
+		entities[hostname] = h
+	}
+	return entities
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autodeploy
+
+const (
+	_defaultBatchSize uint32 = 1
+)
+
+// Config is the config for the container registry webhook which updates
+// the jobs bound to a repository when a new image is pushed to it.
+type Config struct {
+	// Enabled turns on the webhook
+	Enabled bool `yaml:"enabled"`
+
+	// Token authenticates the notifications of the registry, which must
+	// be sent with the "Authorization: Bearer <token>" header. It is
+	// required when the webhook is enabled.
+	Token string `yaml:"token"`
+
+	// BatchSize is the number of instances updated at a time by the
+	// rolling updates created by the webhook
+	BatchSize uint32 `yaml:"batch_size"`
+
+	// Bindings are the repositories and the jobs to update when a new
+	// image is pushed to them
+	Bindings []Binding `yaml:"bindings"`
+}
+
+// Binding binds the jobs to update to a repository.
+type Binding struct {
+	// Repository is the name of the image without tag as used in the job
+	// configs, e.g. registry.example.com/team/service
+	Repository string `yaml:"repository"`
+
+	// JobIDs are the IDs of the service jobs to update
+	JobIDs []string `yaml:"job_ids"`
+}
+
+func (c *Config) normalize() {
+	if c.BatchSize == 0 {
+		c.BatchSize = _defaultBatchSize
+	}
+}
@@ -0,0 +1,402 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autodeploy
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	pbupdate "github.com/uber/peloton/.gen/peloton/api/v0/update"
+	"github.com/uber/peloton/.gen/peloton/private/models"
+
+	"github.com/uber/peloton/pkg/common"
+	"github.com/uber/peloton/pkg/common/leader"
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+	"github.com/uber/peloton/pkg/jobmgr/goalstate"
+	"github.com/uber/peloton/pkg/jobmgr/job/provenance"
+	jobutil "github.com/uber/peloton/pkg/jobmgr/util/job"
+	"github.com/uber/peloton/pkg/storage"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/uber-go/tally"
+)
+
+const (
+	// WebhookPath is the HTTP path of the container registry webhook
+	WebhookPath = "/autodeploy/registry"
+
+	// _pushAction is the action of the registry events sent when an image
+	// is pushed
+	_pushAction = "push"
+
+	// _maxNotificationSize is the maximum size of the notifications read
+	_maxNotificationSize = 1 << 20
+
+	// _deployTimeout is the timeout to create the updates of a notification
+	_deployTimeout = 30 * time.Second
+)
+
+// registryNotification is the envelope of the notifications sent by the
+// Docker registry to its endpoints.
+type registryNotification struct {
+	Events []registryEvent `json:"events"`
+}
+
+// registryEvent is an event of a registry notification.
+type registryEvent struct {
+	Action string `json:"action"`
+	Target struct {
+		Repository string `json:"repository"`
+		Tag        string `json:"tag"`
+	} `json:"target"`
+	Request struct {
+		Host string `json:"host"`
+	} `json:"request"`
+}
+
+// webhookHandler handles the notifications of the container registry, and
+// creates rolling updates bumping the image tag of the bound jobs.
+type webhookHandler struct {
+	jobStore        storage.JobStore
+	jobFactory      cached.JobFactory
+	goalStateDriver goalstate.Driver
+	candidate       leader.Candidate
+	configVerifier  provenance.Verifier
+	metrics         *Metrics
+	config          Config
+
+	// jobs bound to each repository
+	bindings map[string][]string
+}
+
+// InitWebhookHandler registers the container registry webhook on the mux
+// if it is enabled. The webhook is not registered if no token is
+// configured, as anyone could otherwise trigger deploys.
+func InitWebhookHandler(
+	mux *http.ServeMux,
+	parent tally.Scope,
+	jobStore storage.JobStore,
+	jobFactory cached.JobFactory,
+	goalStateDriver goalstate.Driver,
+	candidate leader.Candidate,
+	configVerifier provenance.Verifier,
+	config Config) {
+	if !config.Enabled {
+		return
+	}
+	if len(config.Token) == 0 {
+		log.Error("Auto-deploy webhook is not started as no token is configured")
+		return
+	}
+
+	mux.Handle(WebhookPath, newWebhookHandler(
+		parent,
+		jobStore,
+		jobFactory,
+		goalStateDriver,
+		candidate,
+		configVerifier,
+		config))
+}
+
+func newWebhookHandler(
+	parent tally.Scope,
+	jobStore storage.JobStore,
+	jobFactory cached.JobFactory,
+	goalStateDriver goalstate.Driver,
+	candidate leader.Candidate,
+	configVerifier provenance.Verifier,
+	config Config) *webhookHandler {
+	config.normalize()
+
+	h := &webhookHandler{
+		jobStore:        jobStore,
+		jobFactory:      jobFactory,
+		goalStateDriver: goalStateDriver,
+		candidate:       candidate,
+		configVerifier:  configVerifier,
+		metrics:         NewMetrics(parent.SubScope("jobmgr").SubScope("autodeploy")),
+		config:          config,
+		bindings:        make(map[string][]string),
+	}
+	for _, binding := range config.Bindings {
+		h.bindings[binding.Repository] = append(
+			h.bindings[binding.Repository], binding.JobIDs...)
+	}
+	return h
+}
+
+// ServeHTTP handles a notification of the registry. A failure is returned
+// if any update could not be created, so that the registry retries the
+// notification. Jobs already using the pushed image are not updated again.
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.metrics.WebhookAPINotify.Inc(1)
+
+	if r.Method != http.MethodPost {
+		h.metrics.WebhookNotifyFail.Inc(1)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.isAuthorized(r) {
+		h.metrics.WebhookNotifyFail.Inc(1)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !h.candidate.IsLeader() {
+		h.metrics.WebhookNotifyFail.Inc(1)
+		http.Error(w, "not the leader", http.StatusServiceUnavailable)
+		return
+	}
+
+	var notification registryNotification
+	if err := json.NewDecoder(
+		io.LimitReader(r.Body, _maxNotificationSize)).
+		Decode(&notification); err != nil {
+		h.metrics.WebhookNotifyFail.Inc(1)
+		http.Error(w, "invalid notification", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), _deployTimeout)
+	defer cancel()
+
+	var failed bool
+	for _, event := range notification.Events {
+		if event.Action != _pushAction || len(event.Target.Tag) == 0 {
+			continue
+		}
+
+		repository, jobIDs := h.getBinding(event)
+		for _, jobID := range jobIDs {
+			if err := h.deploy(
+				ctx, jobID, repository, event.Target.Tag); err != nil {
+				log.WithError(err).
+					WithField("job_id", jobID).
+					WithField("repository", repository).
+					WithField("tag", event.Target.Tag).
+					Warn("Failed to deploy pushed image")
+				h.metrics.DeployCreateFail.Inc(1)
+				failed = true
+			}
+		}
+	}
+
+	if failed {
+		h.metrics.WebhookNotifyFail.Inc(1)
+		http.Error(w, "failed to deploy image", http.StatusInternalServerError)
+		return
+	}
+	h.metrics.WebhookNotify.Inc(1)
+	w.WriteHeader(http.StatusOK)
+}
+
+// isAuthorized returns true if the request has the token of the config.
+// Every request is rejected if no token is configured.
+func (h *webhookHandler) isAuthorized(r *http.Request) bool {
+	if len(h.config.Token) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare(
+		[]byte(r.Header.Get("Authorization")),
+		[]byte("Bearer "+h.config.Token)) == 1
+}
+
+// getBinding returns the repository of the event as used in the job configs
+// and the jobs bound to it.
+func (h *webhookHandler) getBinding(
+	event registryEvent) (string, []string) {
+	var names []string
+	if len(event.Request.Host) != 0 {
+		names = append(names,
+			event.Request.Host+"/"+event.Target.Repository)
+	}
+	names = append(names, event.Target.Repository)
+
+	for _, name := range names {
+		if jobIDs, ok := h.bindings[name]; ok {
+			return name, jobIDs
+		}
+	}
+	return "", nil
+}
+
+// deploy creates a rolling update of the job bumping the tag of the images
+// of the repository.
+func (h *webhookHandler) deploy(
+	ctx context.Context,
+	jobID string,
+	repository string,
+	tag string) error {
+	pelotonJobID := &peloton.JobID{Value: jobID}
+
+	jobRuntime, err := h.jobStore.GetJobRuntime(ctx, jobID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get job runtime")
+	}
+	// cannot update a job which is still being created
+	if jobRuntime.GetState() == job.JobState_INITIALIZED {
+		return errors.New("cannot update partially created job")
+	}
+
+	prevJobConfig, prevConfigAddOn, err := h.jobStore.GetJobConfig(ctx, jobID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get job config")
+	}
+
+	if prevJobConfig.GetType() != job.JobType_SERVICE {
+		log.WithField("job_id", jobID).
+			Warn("Only service jobs can be bound to a repository")
+		h.metrics.DeploySkip.Inc(1)
+		return nil
+	}
+
+	jobConfig := proto.Clone(prevJobConfig).(*job.JobConfig)
+	if !setImageTag(jobConfig, repository, tag) {
+		// the job is already using the pushed image
+		h.metrics.DeploySkip.Inc(1)
+		return nil
+	}
+	jobConfig.ChangeLog = nil
+
+	// the config bumped by the webhook is not signed, so it cannot be
+	// deployed if the cluster only accepts signed configs
+	if h.configVerifier != nil {
+		if _, err := h.configVerifier.Verify(jobConfig, nil); err != nil {
+			return errors.Wrap(err, "failed to verify job config")
+		}
+	}
+
+	var respoolPath string
+	for _, label := range prevConfigAddOn.GetSystemLabels() {
+		if label.GetKey() == common.SystemLabelResourcePool {
+			respoolPath = label.GetValue()
+		}
+	}
+	configAddOn := &models.ConfigAddOn{
+		SystemLabels: jobutil.ConstructSystemLabels(jobConfig, respoolPath),
+	}
+
+	cachedJob := h.jobFactory.AddJob(pelotonJobID)
+	updateID, _, err := cachedJob.CreateWorkflow(
+		ctx,
+		models.WorkflowType_UPDATE,
+		&pbupdate.UpdateConfig{
+			BatchSize: h.config.BatchSize,
+		},
+		jobutil.GetJobEntityVersion(
+			jobRuntime.GetConfigurationVersion(),
+			jobRuntime.GetDesiredStateVersion(),
+			jobRuntime.GetWorkflowVersion()),
+		cached.WithConfig(jobConfig, prevJobConfig, configAddOn),
+	)
+
+	// In case of error, since it is not clear if job runtime was
+	// persisted with the update ID or not, enqueue the update to
+	// the goal state. If the update ID got persisted, update should
+	// start running, else, it should be aborted.
+	if len(updateID.GetValue()) > 0 {
+		h.goalStateDriver.EnqueueUpdate(pelotonJobID, updateID, time.Now())
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to create update")
+	}
+
+	log.WithField("job_id", jobID).
+		WithField("update_id", updateID.GetValue()).
+		WithField("repository", repository).
+		WithField("tag", tag).
+		Info("Deploying pushed image")
+	h.metrics.DeployCreate.Inc(1)
+	return nil
+}
+
+// setImageTag sets the tag of the images of the repository in the task
+// configs of the job config. It returns true if any image was changed.
+func setImageTag(
+	jobConfig *job.JobConfig,
+	repository string,
+	tag string) bool {
+	changed := setTaskConfigImageTag(
+		jobConfig.GetDefaultConfig(), repository, tag)
+	for _, taskConfig := range jobConfig.GetInstanceConfig() {
+		if setTaskConfigImageTag(taskConfig, repository, tag) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// setTaskConfigImageTag sets the tag of the images of the repository in the
+// Docker and Mesos containers of the task config.
+func setTaskConfigImageTag(
+	taskConfig *task.TaskConfig,
+	repository string,
+	tag string) bool {
+	container := taskConfig.GetContainer()
+	changed := false
+
+	if docker := container.GetDocker(); docker != nil {
+		if image, ok := replaceImageTag(
+			docker.GetImage(), repository, tag); ok {
+			docker.Image = &image
+			changed = true
+		}
+	}
+
+	if docker := container.GetMesos().GetImage().GetDocker(); docker != nil {
+		if image, ok := replaceImageTag(
+			docker.GetName(), repository, tag); ok {
+			docker.Name = &image
+			changed = true
+		}
+	}
+	return changed
+}
+
+// replaceImageTag returns the image of the repository with the tag, and
+// true if the image is of the repository and has a different tag or a
+// digest.
+func replaceImageTag(
+	image string,
+	repository string,
+	tag string) (string, bool) {
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		// strip the digest
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		// strip the tag, but not the port of the registry host
+		name = name[:i]
+	}
+
+	if name != repository {
+		return "", false
+	}
+	newImage := name + ":" + tag
+	return newImage, newImage != image
+}
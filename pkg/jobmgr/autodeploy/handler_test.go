@@ -0,0 +1,374 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autodeploy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mesos "github.com/uber/peloton/.gen/mesos/v1"
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	"github.com/uber/peloton/.gen/peloton/api/v0/update"
+	"github.com/uber/peloton/.gen/peloton/private/models"
+
+	leadermocks "github.com/uber/peloton/pkg/common/leader/mocks"
+	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
+	goalstatemocks "github.com/uber/peloton/pkg/jobmgr/goalstate/mocks"
+	"github.com/uber/peloton/pkg/jobmgr/job/provenance"
+	storemocks "github.com/uber/peloton/pkg/storage/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+)
+
+const (
+	_testToken      = "test-token"
+	_testRepository = "registry.example.com/team/service"
+)
+
+type WebhookHandlerTestSuite struct {
+	suite.Suite
+
+	ctrl            *gomock.Controller
+	jobStore        *storemocks.MockJobStore
+	jobFactory      *cachedmocks.MockJobFactory
+	cachedJob       *cachedmocks.MockJob
+	goalStateDriver *goalstatemocks.MockDriver
+	candidate       *leadermocks.MockCandidate
+	h               *webhookHandler
+
+	jobID      *peloton.JobID
+	updateID   *peloton.UpdateID
+	jobRuntime *job.RuntimeInfo
+	jobConfig  *job.JobConfig
+}
+
+func TestWebhookHandler(t *testing.T) {
+	suite.Run(t, new(WebhookHandlerTestSuite))
+}
+
+func (suite *WebhookHandlerTestSuite) SetupTest() {
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.jobStore = storemocks.NewMockJobStore(suite.ctrl)
+	suite.jobFactory = cachedmocks.NewMockJobFactory(suite.ctrl)
+	suite.cachedJob = cachedmocks.NewMockJob(suite.ctrl)
+	suite.goalStateDriver = goalstatemocks.NewMockDriver(suite.ctrl)
+	suite.candidate = leadermocks.NewMockCandidate(suite.ctrl)
+
+	suite.jobID = &peloton.JobID{Value: uuid.NewRandom().String()}
+	suite.updateID = &peloton.UpdateID{Value: uuid.NewRandom().String()}
+	suite.jobRuntime = &job.RuntimeInfo{
+		State:                job.JobState_RUNNING,
+		ConfigurationVersion: 2,
+		WorkflowVersion:      1,
+	}
+
+	image := _testRepository + ":v1"
+	suite.jobConfig = &job.JobConfig{
+		Type:          job.JobType_SERVICE,
+		InstanceCount: 3,
+		DefaultConfig: &task.TaskConfig{
+			Container: &mesos.ContainerInfo{
+				Docker: &mesos.ContainerInfo_DockerInfo{Image: &image},
+			},
+		},
+	}
+
+	suite.h = newWebhookHandler(
+		tally.NoopScope,
+		suite.jobStore,
+		suite.jobFactory,
+		suite.goalStateDriver,
+		suite.candidate,
+		nil,
+		Config{
+			Enabled: true,
+			Token:   _testToken,
+			Bindings: []Binding{
+				{
+					Repository: _testRepository,
+					JobIDs:     []string{suite.jobID.GetValue()},
+				},
+			},
+		})
+}
+
+func (suite *WebhookHandlerTestSuite) TearDownTest() {
+	suite.ctrl.Finish()
+}
+
+// notify sends a notification with a push event of the tag to the webhook
+func (suite *WebhookHandlerTestSuite) notify(
+	token string,
+	tag string) *httptest.ResponseRecorder {
+	body := fmt.Sprintf(`{"events": [{
+		"action": "push",
+		"target": {"repository": "team/service", "tag": %q},
+		"request": {"host": "registry.example.com"}
+	}]}`, tag)
+	req := httptest.NewRequest(
+		http.MethodPost, WebhookPath, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	suite.h.ServeHTTP(w, req)
+	return w
+}
+
+// TestNotifyDeploy tests creating an update of a bound job when a new
+// image is pushed
+func (suite *WebhookHandlerTestSuite) TestNotifyDeploy() {
+	suite.candidate.EXPECT().IsLeader().Return(true)
+	suite.jobStore.EXPECT().
+		GetJobRuntime(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobRuntime, nil)
+	suite.jobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobConfig, &models.ConfigAddOn{}, nil)
+	suite.jobFactory.EXPECT().
+		AddJob(suite.jobID).
+		Return(suite.cachedJob)
+	suite.cachedJob.EXPECT().
+		CreateWorkflow(
+			gomock.Any(),
+			models.WorkflowType_UPDATE,
+			&update.UpdateConfig{BatchSize: _defaultBatchSize},
+			gomock.Any(),
+			gomock.Any()).
+		Return(suite.updateID, nil, nil)
+	suite.goalStateDriver.EXPECT().
+		EnqueueUpdate(suite.jobID, suite.updateID, gomock.Any())
+
+	w := suite.notify(_testToken, "v2")
+	suite.Equal(http.StatusOK, w.Code)
+	// the stored config is left as is
+	suite.Equal(
+		_testRepository+":v1",
+		suite.jobConfig.GetDefaultConfig().GetContainer().GetDocker().GetImage())
+}
+
+// TestNotifyAlreadyDeployed tests that no update is created if the job
+// already uses the pushed image
+func (suite *WebhookHandlerTestSuite) TestNotifyAlreadyDeployed() {
+	suite.candidate.EXPECT().IsLeader().Return(true)
+	suite.jobStore.EXPECT().
+		GetJobRuntime(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobRuntime, nil)
+	suite.jobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobConfig, &models.ConfigAddOn{}, nil)
+
+	w := suite.notify(_testToken, "v1")
+	suite.Equal(http.StatusOK, w.Code)
+}
+
+// TestNotifyDeployFailure tests that a failure is returned to the registry
+// if the update cannot be created
+func (suite *WebhookHandlerTestSuite) TestNotifyDeployFailure() {
+	suite.candidate.EXPECT().IsLeader().Return(true)
+	suite.jobStore.EXPECT().
+		GetJobRuntime(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobRuntime, nil)
+	suite.jobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobConfig, &models.ConfigAddOn{}, nil)
+	suite.jobFactory.EXPECT().
+		AddJob(suite.jobID).
+		Return(suite.cachedJob)
+	suite.cachedJob.EXPECT().
+		CreateWorkflow(
+			gomock.Any(),
+			models.WorkflowType_UPDATE,
+			gomock.Any(),
+			gomock.Any(),
+			gomock.Any()).
+		Return(nil, nil, fmt.Errorf("test error"))
+
+	w := suite.notify(_testToken, "v2")
+	suite.Equal(http.StatusInternalServerError, w.Code)
+}
+
+// TestNotifyUnsignedConfig tests that bound jobs are not updated if the
+// cluster only accepts signed configs, as the bumped config is unsigned
+func (suite *WebhookHandlerTestSuite) TestNotifyUnsignedConfig() {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	suite.NoError(err)
+	buffer, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	suite.NoError(err)
+	suite.h.configVerifier, err = provenance.NewVerifier(&provenance.Config{
+		RequireSignature: true,
+		PublicKeys: map[string]string{
+			"ci": string(pem.EncodeToMemory(&pem.Block{
+				Type:  "PUBLIC KEY",
+				Bytes: buffer,
+			})),
+		},
+	})
+	suite.NoError(err)
+
+	suite.candidate.EXPECT().IsLeader().Return(true)
+	suite.jobStore.EXPECT().
+		GetJobRuntime(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobRuntime, nil)
+	suite.jobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobConfig, &models.ConfigAddOn{}, nil)
+
+	w := suite.notify(_testToken, "v2")
+	suite.Equal(http.StatusInternalServerError, w.Code)
+}
+
+// TestNotifyUnboundRepository tests that pushes to other repositories
+// are ignored
+func (suite *WebhookHandlerTestSuite) TestNotifyUnboundRepository() {
+	suite.h.bindings = make(map[string][]string)
+	suite.candidate.EXPECT().IsLeader().Return(true)
+
+	w := suite.notify(_testToken, "v2")
+	suite.Equal(http.StatusOK, w.Code)
+}
+
+// TestNotifyUnauthorized tests rejecting notifications without the token
+func (suite *WebhookHandlerTestSuite) TestNotifyUnauthorized() {
+	w := suite.notify("invalid-token", "v2")
+	suite.Equal(http.StatusUnauthorized, w.Code)
+}
+
+// TestNotifyNoToken tests rejecting every notification if no token is
+// configured, and not registering the webhook
+func (suite *WebhookHandlerTestSuite) TestNotifyNoToken() {
+	suite.h.config.Token = ""
+	w := suite.notify("", "v2")
+	suite.Equal(http.StatusUnauthorized, w.Code)
+
+	mux := http.NewServeMux()
+	InitWebhookHandler(
+		mux,
+		tally.NoopScope,
+		suite.jobStore,
+		suite.jobFactory,
+		suite.goalStateDriver,
+		suite.candidate,
+		nil,
+		Config{Enabled: true},
+	)
+	_, pattern := mux.Handler(httptest.NewRequest(
+		http.MethodPost, WebhookPath, nil))
+	suite.Empty(pattern)
+}
+
+// TestNotifyNonLeader tests rejecting notifications on non-leaders so that
+// the registry retries them
+func (suite *WebhookHandlerTestSuite) TestNotifyNonLeader() {
+	suite.candidate.EXPECT().IsLeader().Return(false)
+
+	w := suite.notify(_testToken, "v2")
+	suite.Equal(http.StatusServiceUnavailable, w.Code)
+}
+
+// TestNotifyInvalidNotification tests rejecting invalid notifications
+func (suite *WebhookHandlerTestSuite) TestNotifyInvalidNotification() {
+	suite.candidate.EXPECT().IsLeader().Return(true)
+
+	req := httptest.NewRequest(
+		http.MethodPost, WebhookPath, strings.NewReader("{invalid"))
+	req.Header.Set("Authorization", "Bearer "+_testToken)
+	w := httptest.NewRecorder()
+	suite.h.ServeHTTP(w, req)
+	suite.Equal(http.StatusBadRequest, w.Code)
+}
+
+// TestSetImageTag tests setting the tag of the Docker and Mesos images of
+// the task configs
+func (suite *WebhookHandlerTestSuite) TestSetImageTag() {
+	otherImage := "registry.example.com/team/other:v1"
+	mesosImage := _testRepository + "@sha256:abcd"
+	suite.jobConfig.InstanceConfig = map[uint32]*task.TaskConfig{
+		0: {
+			Container: &mesos.ContainerInfo{
+				Mesos: &mesos.ContainerInfo_MesosInfo{
+					Image: &mesos.Image{
+						Docker: &mesos.Image_Docker{Name: &mesosImage},
+					},
+				},
+			},
+		},
+		1: {
+			Container: &mesos.ContainerInfo{
+				Docker: &mesos.ContainerInfo_DockerInfo{Image: &otherImage},
+			},
+		},
+	}
+
+	suite.True(setImageTag(suite.jobConfig, _testRepository, "v2"))
+	suite.Equal(
+		_testRepository+":v2",
+		suite.jobConfig.GetDefaultConfig().GetContainer().GetDocker().GetImage())
+	suite.Equal(
+		_testRepository+":v2",
+		suite.jobConfig.GetInstanceConfig()[0].GetContainer().GetMesos().
+			GetImage().GetDocker().GetName())
+	suite.Equal(
+		otherImage,
+		suite.jobConfig.GetInstanceConfig()[1].GetContainer().GetDocker().
+			GetImage())
+
+	suite.False(setImageTag(suite.jobConfig, _testRepository, "v2"))
+}
+
+// TestReplaceImageTag tests replacing the tag of images
+func (suite *WebhookHandlerTestSuite) TestReplaceImageTag() {
+	tt := []struct {
+		image      string
+		repository string
+		expected   string
+		ok         bool
+	}{
+		{"team/service:v1", "team/service", "team/service:v2", true},
+		{"team/service", "team/service", "team/service:v2", true},
+		{"team/service:v2", "team/service", "", false},
+		{"team/other:v1", "team/service", "", false},
+		{
+			"localhost:5000/team/service:v1",
+			"localhost:5000/team/service",
+			"localhost:5000/team/service:v2",
+			true,
+		},
+		{
+			"localhost:5000/team/service",
+			"localhost:5000/team/service",
+			"localhost:5000/team/service:v2",
+			true,
+		},
+	}
+
+	for _, t := range tt {
+		image, ok := replaceImageTag(t.image, t.repository, "v2")
+		suite.Equal(t.ok, ok, t.image)
+		suite.Equal(t.expected, image, t.image)
+	}
+}
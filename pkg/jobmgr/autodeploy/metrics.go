@@ -0,0 +1,49 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autodeploy
+
+import (
+	"github.com/uber-go/tally"
+)
+
+// Metrics is the struct containing all the counters that track
+// internal state of the registry webhook
+type Metrics struct {
+	WebhookAPINotify  tally.Counter
+	WebhookNotify     tally.Counter
+	WebhookNotifyFail tally.Counter
+
+	DeployCreate     tally.Counter
+	DeployCreateFail tally.Counter
+	DeploySkip       tally.Counter
+}
+
+// NewMetrics returns a new Metrics struct, with all metrics
+// initialized and rooted at the given tally.Scope
+func NewMetrics(scope tally.Scope) *Metrics {
+	successScope := scope.Tagged(map[string]string{"result": "success"})
+	failScope := scope.Tagged(map[string]string{"result": "fail"})
+	apiScope := scope.SubScope("api")
+
+	return &Metrics{
+		WebhookAPINotify:  apiScope.Counter("notify"),
+		WebhookNotify:     successScope.Counter("notify"),
+		WebhookNotifyFail: failScope.Counter("notify"),
+
+		DeployCreate:     successScope.Counter("deploy"),
+		DeployCreateFail: failScope.Counter("deploy"),
+		DeploySkip:       scope.Counter("deploy_skip"),
+	}
+}
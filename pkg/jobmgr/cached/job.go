@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	pbjob "github.com/uber/peloton/.gen/peloton/api/v0/job"
@@ -192,6 +193,14 @@ type Job interface {
 	// GetStateCount returns the state/goal state count of all
 	// tasks in a job
 	GetStateCount() map[pbtask.TaskState]map[pbtask.TaskState]int
+
+	// GetTaskLabels returns the labels of a task of the job,
+	// or nil if the job config is not cached.
+	GetTaskLabels(instanceID uint32) []*peloton.Label
+
+	// GetTaskRespoolID returns the resource pool of the tasks of the
+	// job, or nil if the job config is not cached.
+	GetTaskRespoolID() *peloton.ResourcePoolID
 }
 
 // WorkflowOps defines operations on workflow
@@ -275,12 +284,15 @@ func newJob(id *peloton.JobID, jobFactory *jobFactory) *job {
 
 // cachedConfig structure holds the config fields need to be cached
 type cachedConfig struct {
-	instanceCount     uint32                  // Instance count in the job configuration
-	sla               *pbjob.SlaConfig        // SLA configuration in the job configuration
-	jobType           pbjob.JobType           // Job type (batch or service) in the job configuration
-	changeLog         *peloton.ChangeLog      // ChangeLog in the job configuration
-	respoolID         *peloton.ResourcePoolID // Resource Pool ID in the job configuration
-	hasControllerTask bool                    // if the job contains any task which is controller task
+	instanceCount     uint32                   // Instance count in the job configuration
+	sla               *pbjob.SlaConfig         // SLA configuration in the job configuration
+	jobType           pbjob.JobType            // Job type (batch or service) in the job configuration
+	changeLog         *peloton.ChangeLog       // ChangeLog in the job configuration
+	respoolID         *peloton.ResourcePoolID  // Resource Pool ID in the job configuration
+	hasControllerTask bool                     // if the job contains any task which is controller task
+	startAfter        string                   // Time before which the job is not started in the job configuration
+	taskBackoff       *pbjob.TaskBackoffPolicy // Task backoff policy in the job configuration
+	maxTaskActions    uint32                   // Maximum number of tasks launched or killed concurrently in the job configuration
 }
 
 // job structure holds the information about a given active job
@@ -316,6 +328,55 @@ type job struct {
 	resourceUsage map[string]float64
 
 	workflows map[string]*update // map of all job workflows
+
+	// labels of the job tasks as per the cached config, which can be
+	// read without the job lock since task listeners may be notified
+	// with the job lock held
+	taskLabels atomic.Value // *cachedTaskLabels
+	// resource pool of the job tasks as per the cached config, read
+	// without the job lock for the same reason
+	taskRespoolID atomic.Value // *peloton.ResourcePoolID
+}
+
+// cachedTaskLabels holds the labels of the tasks of a job
+type cachedTaskLabels struct {
+	defaultLabels  []*peloton.Label
+	instanceLabels map[uint32][]*peloton.Label
+}
+
+// newCachedTaskLabels returns the task labels of the job config.
+// The labels of the instance config override the default labels.
+func newCachedTaskLabels(config *pbjob.JobConfig) *cachedTaskLabels {
+	labels := &cachedTaskLabels{
+		defaultLabels:  config.GetDefaultConfig().GetLabels(),
+		instanceLabels: make(map[uint32][]*peloton.Label),
+	}
+	for id, instanceConfig := range config.GetInstanceConfig() {
+		if len(instanceConfig.GetLabels()) > 0 {
+			labels.instanceLabels[id] = instanceConfig.GetLabels()
+		}
+	}
+	return labels
+}
+
+// GetTaskLabels returns the labels of a task of the job,
+// or nil if the job config is not cached.
+func (j *job) GetTaskLabels(instanceID uint32) []*peloton.Label {
+	labels, ok := j.taskLabels.Load().(*cachedTaskLabels)
+	if !ok {
+		return nil
+	}
+	if instanceLabels, ok := labels.instanceLabels[instanceID]; ok {
+		return instanceLabels
+	}
+	return labels.defaultLabels
+}
+
+// GetTaskRespoolID returns the resource pool of the tasks of the job,
+// or nil if the job config is not cached.
+func (j *job) GetTaskRespoolID() *peloton.ResourcePoolID {
+	respoolID, _ := j.taskRespoolID.Load().(*peloton.ResourcePoolID)
+	return respoolID
 }
 
 func (j *job) ID() *peloton.JobID {
@@ -1141,8 +1202,19 @@ func (j *job) populateJobConfigCache(config *pbjob.JobConfig) {
 
 	j.config.hasControllerTask = hasControllerTask(config)
 
+	j.config.startAfter = config.GetStartAfter()
+
+	j.config.taskBackoff = config.GetTaskBackoffPolicy()
+
+	j.config.maxTaskActions = config.GetMaxConcurrentTaskActions()
+
 	j.config.jobType = config.GetType()
 	j.jobType = j.config.jobType
+
+	j.taskLabels.Store(newCachedTaskLabels(config))
+	if j.config.respoolID != nil {
+		j.taskRespoolID.Store(j.config.respoolID)
+	}
 }
 
 // getUpdatedJobRuntimeCache validates the runtime input and
@@ -1955,6 +2027,22 @@ func (c *cachedConfig) GetSLA() *pbjob.SlaConfig {
 	return &tmpSLA
 }
 
+func (c *cachedConfig) GetStartAfter() string {
+	return c.startAfter
+}
+
+func (c *cachedConfig) GetTaskBackoffPolicy() *pbjob.TaskBackoffPolicy {
+	if c.taskBackoff == nil {
+		return nil
+	}
+	tmpTaskBackoff := *c.taskBackoff
+	return &tmpTaskBackoff
+}
+
+func (c *cachedConfig) GetMaxConcurrentTaskActions() uint32 {
+	return c.maxTaskActions
+}
+
 func (c *cachedConfig) HasControllerTask() bool {
 	return c.hasControllerTask
 }
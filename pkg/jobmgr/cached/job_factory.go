@@ -21,6 +21,8 @@ import (
 	pbjob "github.com/uber/peloton/.gen/peloton/api/v0/job"
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
 	pbtask "github.com/uber/peloton/.gen/peloton/api/v0/task"
+	"github.com/uber/peloton/.gen/peloton/private/models"
+	"github.com/uber/peloton/pkg/common/goalstate"
 	"github.com/uber/peloton/pkg/storage"
 	ormobjects "github.com/uber/peloton/pkg/storage/objects"
 
@@ -70,6 +72,19 @@ type jobFactory struct {
 	listeners []JobTaskListener
 	// channel to indicate that the job factory needs to stop
 	stopChan chan struct{}
+	// clock used to track when task runtimes were last updated
+	clock goalstate.Clock
+}
+
+// JobFactoryOption is used to customize the job factory on creation.
+type JobFactoryOption func(*jobFactory)
+
+// WithClock sets the clock used by the cache to track when task runtimes
+// were last updated. Defaults to the wall clock.
+func WithClock(clock goalstate.Clock) JobFactoryOption {
+	return func(f *jobFactory) {
+		f.clock = clock
+	}
 }
 
 // InitJobFactory initializes the job factory object.
@@ -80,8 +95,9 @@ func InitJobFactory(
 	volumeStore storage.PersistentVolumeStore,
 	ormStore *ormobjects.Store,
 	parentScope tally.Scope,
-	listeners []JobTaskListener) JobFactory {
-	return &jobFactory{
+	listeners []JobTaskListener,
+	opts ...JobFactoryOption) JobFactory {
+	f := &jobFactory{
 		jobs:           map[string]*job{},
 		jobStore:       jobStore,
 		taskStore:      taskStore,
@@ -91,7 +107,22 @@ func InitJobFactory(
 		jobNameToIDOps: ormobjects.NewJobNameToIDOps(ormStore),
 		mtx:            NewMetrics(parentScope.SubScope("cache")),
 		listeners:      listeners,
+		clock:          goalstate.NewRealClock(),
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+// now returns the current time of the job factory clock.
+func (f *jobFactory) now() time.Time {
+	if f == nil || f.clock == nil {
+		return time.Now()
 	}
+	return f.clock.Now()
 }
 
 func (f *jobFactory) AddJob(id *peloton.JobID) Job {
@@ -125,16 +156,21 @@ func (f *jobFactory) ClearJob(id *peloton.JobID) {
 }
 
 func (f *jobFactory) GetJob(id *peloton.JobID) Job {
-	f.RLock()
-	defer f.RUnlock()
-
-	if j, ok := f.jobs[id.GetValue()]; ok {
+	if j := f.getJob(id); j != nil {
 		return j
 	}
 
 	return nil
 }
 
+// getJob returns the cached job object, or nil if not present
+func (f *jobFactory) getJob(id *peloton.JobID) *job {
+	f.RLock()
+	defer f.RUnlock()
+
+	return f.jobs[id.GetValue()]
+}
+
 func (f *jobFactory) GetAllJobs() map[string]Job {
 	f.RLock()
 	defer f.RUnlock()
@@ -257,8 +293,28 @@ func (f *jobFactory) notifyTaskRuntimeChanged(
 	runtime *pbtask.RuntimeInfo) {
 
 	if runtime != nil {
+		var labels []*peloton.Label
+		var respoolID *peloton.ResourcePoolID
+		if j := f.getJob(jobID); j != nil {
+			labels = j.GetTaskLabels(instanceID)
+			respoolID = j.GetTaskRespoolID()
+		}
+		for _, l := range f.listeners {
+			l.TaskRuntimeChanged(
+				jobID, instanceID, jobType, runtime, labels, respoolID)
+		}
+		// TODO add metric for listener execution latency
+	}
+}
+
+func (f *jobFactory) notifyUpdateChanged(
+	jobID *peloton.JobID,
+	updateInfo *models.UpdateModel,
+	instancesDone []uint32) {
+
+	if updateInfo != nil {
 		for _, l := range f.listeners {
-			l.TaskRuntimeChanged(jobID, instanceID, jobType, runtime)
+			l.UpdateChanged(jobID, updateInfo, instancesDone)
 		}
 		// TODO add metric for listener execution latency
 	}
@@ -18,6 +18,7 @@ import (
 	pbjob "github.com/uber/peloton/.gen/peloton/api/v0/job"
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
 	pbtask "github.com/uber/peloton/.gen/peloton/api/v0/task"
+	"github.com/uber/peloton/.gen/peloton/private/models"
 )
 
 // JobTaskListener defines an interface that must to be implemented by
@@ -47,10 +48,24 @@ type JobTaskListener interface {
 		runtime *pbjob.RuntimeInfo)
 
 	// TaskRuntimeChanged is invoked when the runtime for a task is updated
-	// in cache and persistent store.
+	// in cache and persistent store. The labels and the resource pool of
+	// the task are as per the cached config of the job, and are nil if the
+	// config is not cached.
 	TaskRuntimeChanged(
 		jobID *peloton.JobID,
 		instanceID uint32,
 		jobType pbjob.JobType,
-		runtime *pbtask.RuntimeInfo)
+		runtime *pbtask.RuntimeInfo,
+		labels []*peloton.Label,
+		respoolID *peloton.ResourcePoolID)
+
+	// UpdateChanged is invoked when the state or the progress of an
+	// update of a job is updated in cache and persistent store. The
+	// update model holds the state of the update after the change, and
+	// instancesDone are the instances which completed the update with
+	// the change.
+	UpdateChanged(
+		jobID *peloton.JobID,
+		updateInfo *models.UpdateModel,
+		instancesDone []uint32)
 }
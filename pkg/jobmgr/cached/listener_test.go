@@ -18,6 +18,7 @@ import (
 	pbjob "github.com/uber/peloton/.gen/peloton/api/v0/job"
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
 	pbtask "github.com/uber/peloton/.gen/peloton/api/v0/task"
+	"github.com/uber/peloton/.gen/peloton/private/models"
 )
 
 type FakeJobListener struct {
@@ -43,7 +44,14 @@ func (l *FakeJobListener) TaskRuntimeChanged(
 	jobID *peloton.JobID,
 	instanceID uint32,
 	jobType pbjob.JobType,
-	runtime *pbtask.RuntimeInfo) {
+	runtime *pbtask.RuntimeInfo,
+	labels []*peloton.Label) {
+}
+
+func (l *FakeJobListener) UpdateChanged(
+	jobID *peloton.JobID,
+	updateInfo *models.UpdateModel,
+	instancesDone []uint32) {
 }
 
 func (l *FakeJobListener) Reset() {
@@ -56,6 +64,8 @@ type FakeTaskListener struct {
 	jobType     pbjob.JobType
 	instanceID  uint32
 	taskRuntime *pbtask.RuntimeInfo
+	taskLabels  []*peloton.Label
+	respoolID   *peloton.ResourcePoolID
 }
 
 func (l *FakeTaskListener) Name() string {
@@ -72,9 +82,54 @@ func (l *FakeTaskListener) TaskRuntimeChanged(
 	jobID *peloton.JobID,
 	instanceID uint32,
 	jobType pbjob.JobType,
-	runtime *pbtask.RuntimeInfo) {
+	runtime *pbtask.RuntimeInfo,
+	labels []*peloton.Label,
+	respoolID *peloton.ResourcePoolID) {
 	l.jobID = jobID
 	l.instanceID = instanceID
 	l.jobType = jobType
 	l.taskRuntime = runtime
+	l.taskLabels = labels
+	l.respoolID = respoolID
+}
+
+func (l *FakeTaskListener) UpdateChanged(
+	jobID *peloton.JobID,
+	updateInfo *models.UpdateModel,
+	instancesDone []uint32) {
+}
+
+type FakeUpdateListener struct {
+	jobID         *peloton.JobID
+	updateInfo    *models.UpdateModel
+	instancesDone []uint32
+	calls         int
+}
+
+func (l *FakeUpdateListener) Name() string {
+	return "fake_update_listener"
+}
+
+func (l *FakeUpdateListener) JobRuntimeChanged(
+	jobID *peloton.JobID,
+	jobType pbjob.JobType,
+	runtime *pbjob.RuntimeInfo) {
+}
+
+func (l *FakeUpdateListener) TaskRuntimeChanged(
+	jobID *peloton.JobID,
+	instanceID uint32,
+	jobType pbjob.JobType,
+	runtime *pbtask.RuntimeInfo,
+	labels []*peloton.Label) {
+}
+
+func (l *FakeUpdateListener) UpdateChanged(
+	jobID *peloton.JobID,
+	updateInfo *models.UpdateModel,
+	instancesDone []uint32) {
+	l.jobID = jobID
+	l.updateInfo = updateInfo
+	l.instancesDone = instancesDone
+	l.calls++
 }
@@ -85,6 +85,12 @@ type Task interface {
 	// forceReplace is used for Refresh, which is for debugging only
 	ReplaceRuntime(runtime *pbtask.RuntimeInfo, forceReplace bool) error
 
+	// ReplaceRuntimeIfVersion replaces cache with runtime only if the version
+	// of the runtime in cache is still version, and returns whether it was
+	// replaced. It is used to reload a runtime which diverged from DB
+	// without overwriting a concurrent update of the cache.
+	ReplaceRuntimeIfVersion(runtime *pbtask.RuntimeInfo, version uint64) (bool, error)
+
 	// GetRuntime returns the task run time
 	GetRuntime(ctx context.Context) (*pbtask.RuntimeInfo, error)
 
@@ -217,6 +223,12 @@ func (t *task) validateState(newRuntime *pbtask.RuntimeInfo) bool {
 
 	//TBD replace if's with more structured checks
 
+	// a crash-looping task is held after its failure
+	if currentRuntime.GetState() == pbtask.TaskState_FAILED &&
+		newRuntime.GetState() == pbtask.TaskState_FAILED_HOLD {
+		return true
+	}
+
 	if util.IsPelotonStateTerminal(currentRuntime.GetState()) {
 		// cannot overwrite terminal state without changing the mesos task id
 		return false
@@ -293,7 +305,7 @@ func (t *task) CreateRuntime(ctx context.Context, runtime *pbtask.RuntimeInfo, o
 	}
 
 	t.runtime = runtime
-	t.lastRuntimeUpdateTime = time.Now()
+	t.lastRuntimeUpdateTime = t.jobFactory.now()
 	runtimeCopy = proto.Clone(t.runtime).(*pbtask.RuntimeInfo)
 	return nil
 }
@@ -359,7 +371,7 @@ func (t *task) PatchRuntime(ctx context.Context, diff jobmgrcommon.RuntimeDiff)
 	}
 	// Store the new runtime in cache
 	t.runtime = newRuntimePtr
-	t.lastRuntimeUpdateTime = time.Now()
+	t.lastRuntimeUpdateTime = t.jobFactory.now()
 	runtimeCopy = proto.Clone(t.runtime).(*pbtask.RuntimeInfo)
 	return nil
 }
@@ -425,7 +437,7 @@ func (t *task) CompareAndSetRuntime(
 
 	// Store the new runtime in cache
 	t.runtime = runtime
-	t.lastRuntimeUpdateTime = time.Now()
+	t.lastRuntimeUpdateTime = t.jobFactory.now()
 	runtimeCopy = proto.Clone(t.runtime).(*pbtask.RuntimeInfo)
 	return runtimeCopy, nil
 }
@@ -481,6 +493,28 @@ func (t *task) ReplaceRuntime(runtime *pbtask.RuntimeInfo, forceReplace bool) er
 	return nil
 }
 
+// ReplaceRuntimeIfVersion replaces runtime in cache with runtime input,
+// only if the version of the runtime in cache is still version. The check
+// and the replace are done under the task lock, so that the cache cannot
+// regress to an older runtime written concurrently.
+func (t *task) ReplaceRuntimeIfVersion(
+	runtime *pbtask.RuntimeInfo,
+	version uint64) (bool, error) {
+	if runtime == nil || runtime.GetRevision() == nil {
+		return false, yarpcerrors.InvalidArgumentErrorf(
+			"ReplaceRuntimeIfVersion expects a non-nil runtime with non-nil Revision")
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	if t.runtime == nil || t.runtime.GetRevision().GetVersion() != version {
+		return false, nil
+	}
+	t.runtime = runtime
+	return true, nil
+}
+
 func (t *task) updateRevision(runtime *pbtask.RuntimeInfo) {
 	if runtime.Revision == nil {
 		// should never enter here
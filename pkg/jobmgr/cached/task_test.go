@@ -595,6 +595,32 @@ func (suite *TaskTestSuite) TestReplaceRuntime_StaleRuntime() {
 	suite.checkListenersNotCalled()
 }
 
+// TestReplaceRuntimeIfVersion tests replacing runtime in the cache only
+// if its version is unchanged
+func (suite *TaskTestSuite) TestReplaceRuntimeIfVersion() {
+	runtime := initializeTaskRuntime(pbtask.TaskState_LAUNCHED, 3)
+	tt := suite.initializeTask(suite.taskStore, suite.jobID, suite.instanceID,
+		runtime)
+
+	// the cached runtime was updated, so an older runtime is not replaced
+	replaced, err := tt.ReplaceRuntimeIfVersion(
+		initializeTaskRuntime(pbtask.TaskState_KILLED, 2), 2)
+	suite.NoError(err)
+	suite.False(replaced)
+	suite.Equal(pbtask.TaskState_LAUNCHED, tt.runtime.GetState())
+
+	// the runtime can regress to an older version if it is unchanged
+	replaced, err = tt.ReplaceRuntimeIfVersion(
+		initializeTaskRuntime(pbtask.TaskState_KILLED, 2), 3)
+	suite.NoError(err)
+	suite.True(replaced)
+	suite.Equal(pbtask.TaskState_KILLED, tt.runtime.GetState())
+
+	_, err = tt.ReplaceRuntimeIfVersion(nil, 2)
+	suite.Error(err)
+	suite.checkListenersNotCalled()
+}
+
 func (suite *TaskTestSuite) TestValidateState() {
 	mesosIDWithRunID1 := "b64fd26b-0e39-41b7-b22a-205b69f247bd-0-1"
 	mesosIDWithRunID2 := "b64fd26b-0e39-41b7-b22a-205b69f247bd-0-2"
@@ -738,6 +764,38 @@ func (suite *TaskTestSuite) TestDeleteTask() {
 	suite.checkListeners(tt, tt.jobType)
 }
 
+// TestTaskListenersReceiveLabels tests that listeners receive the labels
+// and the resource pool of the task as per the cached job config
+func (suite *TaskTestSuite) TestTaskListenersReceiveLabels() {
+	runtime := initializeTaskRuntime(pbtask.TaskState_DELETED, 2)
+	runtime.GoalState = pbtask.TaskState_DELETED
+	tt := suite.initializeTask(suite.taskStore, suite.jobID,
+		suite.instanceID, runtime)
+
+	defaultLabels := []*peloton.Label{{Key: "app", Value: "default"}}
+	instanceLabels := []*peloton.Label{{Key: "app", Value: "canary"}}
+	tt.jobFactory.jobs[suite.jobID.GetValue()].taskLabels.Store(
+		newCachedTaskLabels(&pbjob.JobConfig{
+			DefaultConfig: &pbtask.TaskConfig{Labels: defaultLabels},
+			InstanceConfig: map[uint32]*pbtask.TaskConfig{
+				suite.instanceID:     {Labels: instanceLabels},
+				suite.instanceID + 1: {Name: "no-labels"},
+			},
+		}))
+	respoolID := &peloton.ResourcePoolID{Value: "respool-1"}
+	tt.jobFactory.jobs[suite.jobID.GetValue()].taskRespoolID.Store(respoolID)
+
+	tt.DeleteTask()
+	suite.checkListeners(tt, tt.jobType)
+	for _, l := range suite.listeners {
+		suite.Equal(instanceLabels, l.taskLabels)
+		suite.Equal(respoolID, l.respoolID)
+	}
+
+	job := tt.jobFactory.jobs[suite.jobID.GetValue()]
+	suite.Equal(defaultLabels, job.GetTaskLabels(suite.instanceID+1))
+}
+
 // TestDeleteTaskNoRuntime tests that listeners receive no event
 // when a task with no runtime is deleted
 func (suite *TaskTestSuite) TestDeleteTaskNoRuntime() {
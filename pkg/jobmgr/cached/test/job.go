@@ -31,5 +31,6 @@ func NewMockJobConfig(ctrl *gomock.Controller, config *pbjob.JobConfig) *cachedm
 	mockJobConfig.EXPECT().GetChangeLog().Return(config.GetChangeLog()).AnyTimes()
 	mockJobConfig.EXPECT().GetInstanceCount().Return(config.GetInstanceCount()).AnyTimes()
 	mockJobConfig.EXPECT().GetType().Return(config.GetType()).AnyTimes()
+	mockJobConfig.EXPECT().GetStartAfter().Return(config.GetStartAfter()).AnyTimes()
 	return mockJobConfig
 }
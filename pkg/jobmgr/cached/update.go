@@ -17,6 +17,7 @@ package cached
 import (
 	"context"
 	"sync"
+	"time"
 
 	pbjob "github.com/uber/peloton/.gen/peloton/api/v0/job"
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
@@ -284,6 +285,7 @@ func (u *update) Create(
 	}
 
 	u.populateCache(updateModel)
+	u.notifyChanged(nil)
 
 	return nil
 }
@@ -493,11 +495,27 @@ func (u *update) writeProgress(
 		u.workflowType,
 		state)
 
+	// only notify the listeners if the state changed or some instances
+	// completed the update, since the progress is written periodically
+	newInstancesDone := util.SubtractSlice(instancesDone, u.instancesDone)
+	stateChanged := u.state != state
+
+	// the health wait of a restart is measured from the time the
+	// instances were added to the instances being restarted
+	if s, ok := u.WorkflowStrategy.(*restartStrategy); ok &&
+		len(util.SubtractSlice(instancesCurrent, u.instancesCurrent)) > 0 {
+		s.startWave(time.Now())
+	}
+
 	u.prevState = prevState
 	u.instancesCurrent = instancesCurrent
 	u.instancesFailed = instancesFailed
 	u.state = state
 	u.instancesDone = instancesDone
+
+	if stateChanged || len(newInstancesDone) > 0 {
+		u.notifyChanged(newInstancesDone)
+	}
 	return nil
 }
 
@@ -647,6 +665,7 @@ func (u *update) Rollback(
 	u.instancesDone = []uint32{}
 	u.instancesFailed = []uint32{}
 	u.populateCache(updateModel)
+	u.notifyChanged(nil)
 
 	return nil
 }
@@ -790,12 +809,54 @@ func (u *update) populateCache(updateModel *models.UpdateModel) {
 	u.instancesCurrent = updateModel.GetInstancesCurrent()
 	u.instancesAdded = updateModel.GetInstancesAdded()
 	u.instancesRemoved = updateModel.GetInstancesRemoved()
-	u.instancesUpdated = updateModel.GetInstancesUpdated()
+	u.instancesUpdated = orderInstances(
+		updateModel.GetInstancesUpdated(),
+		updateModel.GetUpdateConfig().GetInstancesOrder())
 	u.jobVersion = updateModel.GetJobConfigVersion()
 	u.jobPrevVersion = updateModel.GetPrevJobConfigVersion()
-	u.instancesTotal = append(updateModel.GetInstancesUpdated(), updateModel.GetInstancesAdded()...)
+	u.instancesTotal = append(u.instancesUpdated, updateModel.GetInstancesAdded()...)
 	u.instancesTotal = append(u.instancesTotal, updateModel.GetInstancesRemoved()...)
-	u.WorkflowStrategy = getWorkflowStrategy(updateModel.GetState(), updateModel.GetType())
+	u.WorkflowStrategy = getWorkflowStrategy(
+		updateModel.GetState(),
+		updateModel.GetType(),
+		updateModel.GetUpdateConfig())
+}
+
+// orderInstances returns the instances in the given order. The instances
+// to update are persisted as a set, so the order they are processed in is
+// persisted separately in the update config when it is not the increasing
+// instance ID order. The instances not in the order are returned last.
+func orderInstances(instances []uint32, order []uint32) []uint32 {
+	if len(order) == 0 {
+		return instances
+	}
+
+	ordered := util.IntersectSlice(order, instances)
+	return append(ordered, util.SubtractSlice(instances, ordered)...)
+}
+
+// notifyChanged notifies the listeners of the job factory about the
+// cached state of the update, along with the instances which completed
+// the update with the change.
+// It is not concurrency safe and must be called with lock held.
+func (u *update) notifyChanged(instancesDone []uint32) {
+	u.jobFactory.notifyUpdateChanged(
+		u.jobID,
+		&models.UpdateModel{
+			UpdateID:             u.id,
+			JobID:                u.jobID,
+			Type:                 u.workflowType,
+			State:                u.state,
+			PrevState:            u.prevState,
+			JobConfigVersion:     u.jobVersion,
+			PrevJobConfigVersion: u.jobPrevVersion,
+			InstancesTotal:       uint32(len(u.instancesTotal)),
+			InstancesDone:        uint32(len(u.instancesDone)),
+			InstancesFailed:      uint32(len(u.instancesFailed)),
+			InstancesCurrent:     u.instancesCurrent,
+		},
+		instancesDone,
+	)
 }
 
 func (u *update) clearCache() {
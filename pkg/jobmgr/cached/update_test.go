@@ -319,6 +319,79 @@ func (suite *UpdateTestSuite) TestWriteProgressRecoverFail() {
 	suite.Error(suite.update.WriteProgress(context.Background(), pbupdate.State_ROLLING_FORWARD, nil, nil, nil))
 }
 
+// TestWriteProgressNotifyListeners tests that the listeners are notified
+// when the state of the update changes or instances complete the update,
+// but not when the progress is written again without change.
+func (suite *UpdateTestSuite) TestWriteProgressNotifyListeners() {
+	listener := &FakeUpdateListener{}
+	suite.update.jobFactory.listeners = []JobTaskListener{listener}
+	suite.update.jobID = suite.jobID
+	suite.update.workflowType = models.WorkflowType_UPDATE
+	suite.update.state = pbupdate.State_INITIALIZED
+	suite.update.instancesTotal = []uint32{0, 1, 2}
+	suite.update.instancesDone = []uint32{}
+
+	suite.updateStore.EXPECT().
+		AddJobUpdateEvent(
+			gomock.Any(),
+			suite.updateID,
+			models.WorkflowType_UPDATE,
+			pbupdate.State_ROLLING_FORWARD).
+		Return(nil)
+	suite.updateStore.EXPECT().
+		WriteUpdateProgress(gomock.Any(), gomock.Any()).
+		Return(nil).
+		AnyTimes()
+	suite.updateStore.EXPECT().
+		AddWorkflowEvent(
+			gomock.Any(),
+			suite.updateID,
+			gomock.Any(),
+			gomock.Any(),
+			gomock.Any()).
+		Return(nil).
+		AnyTimes()
+
+	// state changed
+	suite.NoError(suite.update.WriteProgress(
+		context.Background(),
+		pbupdate.State_ROLLING_FORWARD,
+		[]uint32{},
+		[]uint32{},
+		[]uint32{0},
+	))
+	suite.Equal(1, listener.calls)
+	suite.Equal(suite.jobID, listener.jobID)
+	suite.Equal(suite.updateID, listener.updateInfo.GetUpdateID())
+	suite.Equal(pbupdate.State_ROLLING_FORWARD, listener.updateInfo.GetState())
+	suite.Equal(pbupdate.State_INITIALIZED, listener.updateInfo.GetPrevState())
+	suite.Equal(uint32(3), listener.updateInfo.GetInstancesTotal())
+	suite.Empty(listener.instancesDone)
+
+	// no change
+	suite.NoError(suite.update.WriteProgress(
+		context.Background(),
+		pbupdate.State_ROLLING_FORWARD,
+		[]uint32{},
+		[]uint32{},
+		[]uint32{0},
+	))
+	suite.Equal(1, listener.calls)
+
+	// instance done
+	suite.NoError(suite.update.WriteProgress(
+		context.Background(),
+		pbupdate.State_ROLLING_FORWARD,
+		[]uint32{0},
+		[]uint32{},
+		[]uint32{1},
+	))
+	suite.Equal(2, listener.calls)
+	suite.Equal([]uint32{0}, listener.instancesDone)
+	suite.Equal(uint32(1), listener.updateInfo.GetInstancesDone())
+	suite.Equal([]uint32{1}, listener.updateInfo.GetInstancesCurrent())
+}
+
 // TestConsecutiveWriteProgressPrevState tests after consecutive call to
 // WriteProgress, prevState is correcct
 func (suite *UpdateTestSuite) TestConsecutiveWriteProgressPrevState() {
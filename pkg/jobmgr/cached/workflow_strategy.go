@@ -15,6 +15,9 @@
 package cached
 
 import (
+	"sync/atomic"
+	"time"
+
 	pbjob "github.com/uber/peloton/.gen/peloton/api/v0/job"
 	pbtask "github.com/uber/peloton/.gen/peloton/api/v0/task"
 	pbupdate "github.com/uber/peloton/.gen/peloton/api/v0/update"
@@ -56,14 +59,16 @@ type WorkflowStrategy interface {
 
 func getWorkflowStrategy(
 	updateState pbupdate.State,
-	workflowType models.WorkflowType) WorkflowStrategy {
+	workflowType models.WorkflowType,
+	updateConfig *pbupdate.UpdateConfig) WorkflowStrategy {
 	switch workflowType {
 	case models.WorkflowType_START:
 		return newStartStrategy()
 	case models.WorkflowType_STOP:
 		return newStopStrategy()
 	case models.WorkflowType_RESTART:
-		return newRestartStrategy()
+		return newRestartStrategy(
+			time.Duration(updateConfig.GetHealthWaitSeconds()) * time.Second)
 	}
 
 	if updateState == pbupdate.State_ROLLING_BACKWARD {
@@ -146,12 +151,55 @@ func (s *rollbackStrategy) GetRuntimeDiff(jobConfig *pbjob.JobConfig) jobmgrcomm
 }
 
 // restartStrategy inherits upgradeStrategy
-func newRestartStrategy() *restartStrategy {
-	return &restartStrategy{newUpdateStrategy()}
+func newRestartStrategy(healthWait time.Duration) *restartStrategy {
+	s := &restartStrategy{
+		WorkflowStrategy: newUpdateStrategy(),
+		healthWait:       healthWait,
+	}
+	// the wave start is not persisted, so a recovered restart waits
+	// from the time it is recovered
+	s.startWave(time.Now())
+	return s
 }
 
 type restartStrategy struct {
 	WorkflowStrategy
+
+	// the time the instances being restarted must have been running
+	// since the start of their wave before they are considered restarted
+	healthWait time.Duration
+
+	// the time in unix nanoseconds the last instances were added to the
+	// instances being restarted. It is read without the lock of the
+	// update, so it is accessed atomically.
+	waveStartTime int64
+}
+
+// startWave records the time new instances were added to the instances
+// being restarted.
+func (s *restartStrategy) startWave(t time.Time) {
+	atomic.StoreInt64(&s.waveStartTime, t.UnixNano())
+}
+
+func (s *restartStrategy) IsInstanceComplete(desiredConfigVersion uint64, runtime *pbtask.RuntimeInfo) bool {
+	if !s.WorkflowStrategy.IsInstanceComplete(desiredConfigVersion, runtime) {
+		return false
+	}
+
+	// a running task is complete only once the health wait has passed
+	// since its wave started
+	if s.healthWait == 0 || runtime.GetState() != pbtask.TaskState_RUNNING {
+		return true
+	}
+	waveStartTime := time.Unix(0, atomic.LoadInt64(&s.waveStartTime))
+	return time.Since(waveStartTime) >= s.healthWait
+}
+
+func (s *restartStrategy) IsInstanceInProgress(desiredConfigVersion uint64, runtime *pbtask.RuntimeInfo) bool {
+	// runtime desired config version has been set to the desired,
+	// but restart has not completed
+	return runtime.GetDesiredConfigVersion() == desiredConfigVersion &&
+		!s.IsInstanceComplete(desiredConfigVersion, runtime)
 }
 
 func (s *restartStrategy) GetRuntimeDiff(jobConfig *pbjob.JobConfig) jobmgrcommon.RuntimeDiff {
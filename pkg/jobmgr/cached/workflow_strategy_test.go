@@ -16,6 +16,7 @@ package cached
 
 import (
 	"testing"
+	"time"
 
 	pbjob "github.com/uber/peloton/.gen/peloton/api/v0/job"
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
@@ -355,7 +356,7 @@ func TestRestartStrategyIsInstanceComplete(t *testing.T) {
 	}
 
 	for id, test := range tests {
-		strategy := newRestartStrategy()
+		strategy := newRestartStrategy(0)
 		assert.Equal(
 			t,
 			strategy.IsInstanceComplete(test.desiredConfigVersion, test.taskRuntime),
@@ -364,6 +365,51 @@ func TestRestartStrategyIsInstanceComplete(t *testing.T) {
 	}
 }
 
+// TestRestartStrategyHealthWait tests that a restarted instance is
+// complete only once the health wait has passed since its wave started
+func TestRestartStrategyHealthWait(t *testing.T) {
+	strategy := newRestartStrategy(time.Minute)
+	running := &pbtask.RuntimeInfo{
+		State:                pbtask.TaskState_RUNNING,
+		GoalState:            pbtask.TaskState_RUNNING,
+		Healthy:              pbtask.HealthState_HEALTHY,
+		ConfigVersion:        2,
+		DesiredConfigVersion: 2,
+		// the wait is not measured from the start of the task
+		StartTime: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339Nano),
+	}
+
+	strategy.startWave(time.Now().Add(-30 * time.Second))
+	assert.False(t, strategy.IsInstanceComplete(2, running))
+	assert.True(t, strategy.IsInstanceInProgress(2, running))
+
+	strategy.startWave(time.Now().Add(-2 * time.Minute))
+	assert.True(t, strategy.IsInstanceComplete(2, running))
+	assert.False(t, strategy.IsInstanceInProgress(2, running))
+
+	killed := &pbtask.RuntimeInfo{
+		State:                pbtask.TaskState_KILLED,
+		GoalState:            pbtask.TaskState_KILLED,
+		ConfigVersion:        1,
+		DesiredConfigVersion: 2,
+	}
+	assert.True(t, strategy.IsInstanceComplete(2, killed))
+}
+
+// TestOrderInstances tests the instances to update are returned in the
+// persisted order
+func TestOrderInstances(t *testing.T) {
+	assert.Equal(t,
+		[]uint32{0, 1, 2},
+		orderInstances([]uint32{0, 1, 2}, nil))
+	assert.Equal(t,
+		[]uint32{2, 0, 1},
+		orderInstances([]uint32{0, 1, 2}, []uint32{2, 0, 1}))
+	assert.Equal(t,
+		[]uint32{2, 0, 1},
+		orderInstances([]uint32{0, 1, 2}, []uint32{2, 0}))
+}
+
 // TestRestartStrategyIsInstanceInProgress tests IsInstanceInProgress
 // for restartStrategy
 func TestRestartStrategyIsInstanceInProgress(t *testing.T) {
@@ -449,7 +495,7 @@ func TestRestartStrategyIsInstanceInProgress(t *testing.T) {
 	}
 
 	for id, test := range tests {
-		strategy := newRestartStrategy()
+		strategy := newRestartStrategy(0)
 		assert.Equal(
 			t,
 			strategy.IsInstanceInProgress(test.desiredConfigVersion, test.taskRuntime),
@@ -472,7 +518,7 @@ func TestRestartStrategyIsInstanceFailed(t *testing.T) {
 	}
 
 	for id, test := range tests {
-		strategy := newRestartStrategy()
+		strategy := newRestartStrategy(0)
 		assert.Equal(
 			t,
 			strategy.IsInstanceFailed(
@@ -492,7 +538,7 @@ func TestRestartStrategyGetRuntimeDiff(t *testing.T) {
 		ChangeLog: &peloton.ChangeLog{Version: configVersion},
 		Type:      pbjob.JobType_SERVICE,
 	}
-	strategy := newRestartStrategy()
+	strategy := newRestartStrategy(0)
 	runtimeDiff := strategy.GetRuntimeDiff(jobConfig)
 	assert.Equal(
 		t,
@@ -17,27 +17,35 @@ package common
 // Name of the fields in pbtask.RuntimeInfo, which is used by job/task cache
 // update request. This list is maintained in sorted order.
 const (
-	AgentIDField              = "AgentID"
-	CompletionTimeField       = "CompletionTime"
-	ConfigVersionField        = "ConfigVersion"
-	DesiredConfigVersionField = "DesiredConfigVersion"
-	DesiredHostField          = "DesiredHost"
-	DesiredMesosTaskIDField   = "DesiredMesosTaskId"
-	FailureCountField         = "FailureCount"
-	GoalStateField            = "GoalState"
-	HealthyField              = "Healthy"
-	HostField                 = "Host"
-	MesosTaskIDField          = "MesosTaskId"
-	MessageField              = "Message"
-	PortsField                = "Ports"
-	PrevMesosTaskIDField      = "PrevMesosTaskId"
-	ReasonField               = "Reason"
-	ResourceUsageField        = "ResourceUsage"
-	RevisionField             = "Revision"
-	StartTimeField            = "StartTime"
-	StateField                = "State"
-	VolumeIDField             = "VolumeID"
-	TerminationStatusField    = "TerminationStatus"
+	AgentAddressField             = "AgentAddress"
+	AgentIDField                  = "AgentID"
+	CompletionTimeField           = "CompletionTime"
+	ConfigVersionField            = "ConfigVersion"
+	ContainerHostnameField        = "ContainerHostname"
+	CrashLoopFailureCountField    = "CrashLoopFailureCount"
+	CrashLoopWindowStartTimeField = "CrashLoopWindowStartTime"
+	DesiredConfigVersionField     = "DesiredConfigVersion"
+	DesiredHostField              = "DesiredHost"
+	DesiredMesosTaskIDField       = "DesiredMesosTaskId"
+	FailureCountField             = "FailureCount"
+	GoalStateField                = "GoalState"
+	HealthyField                  = "Healthy"
+	HostField                     = "Host"
+	KillGracePeriodField          = "KillGracePeriod"
+	MesosTaskIDField              = "MesosTaskId"
+	MessageField                  = "Message"
+	OperationContextField         = "OperationContext"
+	PlacementInfoField            = "PlacementInfo"
+	PortsField                    = "Ports"
+	PrevMesosTaskIDField          = "PrevMesosTaskId"
+	ReasonField                   = "Reason"
+	ResourceUsageField            = "ResourceUsage"
+	RevisionField                 = "Revision"
+	StartTimeField                = "StartTime"
+	StateField                    = "State"
+	SuspendedField                = "Suspended"
+	VolumeIDField                 = "VolumeID"
+	TerminationStatusField        = "TerminationStatus"
 )
 
 const (
@@ -49,6 +57,17 @@ const (
 	// MaxSystemFailureAttempts indicates the maximum retries on mesos
 	// system failures
 	MaxSystemFailureAttempts = 4
+
+	// MaxTaskRejectedAttempts indicates the maximum retries of a task
+	// rejected by its resource pool, regardless of its restart policy,
+	// since the task is rejected again unless a config changes
+	MaxTaskRejectedAttempts = 3
+)
+
+const (
+	// ReasonTaskRejected is the reason of the failure of a task rejected
+	// by its resource pool when it was enqueued to resource manager
+	ReasonTaskRejected = "REASON_TASK_REJECTED"
 )
 
 const (
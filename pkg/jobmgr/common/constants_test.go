@@ -25,6 +25,7 @@ import (
 
 func TestTaskRuntimeInfoFieldNames(t *testing.T) {
 	fieldNames := []string{
+		AgentAddressField,
 		AgentIDField,
 		CompletionTimeField,
 		FailureCountField,
@@ -42,6 +43,8 @@ func TestTaskRuntimeInfoFieldNames(t *testing.T) {
 		ConfigVersionField,
 		DesiredConfigVersionField,
 		HealthyField,
+		PlacementInfoField,
+		ContainerHostnameField,
 	}
 
 	taskRuntimeType := reflect.TypeOf(pbtask.RuntimeInfo{})
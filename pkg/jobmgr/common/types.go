@@ -41,6 +41,15 @@ type JobConfig interface {
 	GetSLA() *pbjob.SlaConfig
 	// GetChangeLog returns the changeLog in the job config stored in the cache
 	GetChangeLog() *peloton.ChangeLog
+	// GetStartAfter returns the time before which the job is not
+	// started in the job config stored in the cache
+	GetStartAfter() string
+	// GetTaskBackoffPolicy returns the task backoff policy
+	// in the job config stored in the cache
+	GetTaskBackoffPolicy() *pbjob.TaskBackoffPolicy
+	// GetMaxConcurrentTaskActions returns the maximum number of tasks
+	// launched or killed concurrently in the job config stored in the cache
+	GetMaxConcurrentTaskActions() uint32
 }
 
 // RuntimeDiff to be applied to the runtime struct.
@@ -17,11 +17,19 @@ package jobmgr
 import (
 	"time"
 
+	"github.com/uber/peloton/pkg/jobmgr/adminsvc"
+	"github.com/uber/peloton/pkg/jobmgr/audit"
+	"github.com/uber/peloton/pkg/jobmgr/autodeploy"
 	"github.com/uber/peloton/pkg/jobmgr/goalstate"
 	"github.com/uber/peloton/pkg/jobmgr/jobsvc"
-	"github.com/uber/peloton/pkg/jobmgr/task/deadline"
+	"github.com/uber/peloton/pkg/jobmgr/logmanager"
+	"github.com/uber/peloton/pkg/jobmgr/standby"
+	"github.com/uber/peloton/pkg/jobmgr/task/identity"
 	"github.com/uber/peloton/pkg/jobmgr/task/placement"
 	"github.com/uber/peloton/pkg/jobmgr/task/preemptor"
+	"github.com/uber/peloton/pkg/jobmgr/task/registration"
+	"github.com/uber/peloton/pkg/jobmgr/tasksvc"
+	"github.com/uber/peloton/pkg/jobmgr/verifier"
 	"github.com/uber/peloton/pkg/jobmgr/watchsvc"
 )
 
@@ -48,15 +56,40 @@ type Config struct {
 	// Preemption related config
 	Preemptor preemptor.Config `yaml:"task_preemptor"`
 
-	Deadline deadline.Config `yaml:"deadline"`
-
 	// Job service specific configuration
 	JobSvcCfg jobsvc.Config `yaml:"job_service"`
 
+	// Task service specific configuration
+	TaskSvcCfg tasksvc.Config `yaml:"task_service"`
+
+	// Sandbox layout of the Mesos agents
+	Sandbox logmanager.SandboxConfig `yaml:"sandbox"`
+
 	// Watch API specific configuration
 	Watch watchsvc.Config `yaml:"watch"`
 
-	// Period in sec for updating active cache
+	// Container registry webhook specific configuration
+	AutoDeploy autodeploy.Config `yaml:"auto_deploy"`
+
+	// Discovery registration hooks specific configuration
+	Registration registration.Config `yaml:"registration"`
+
+	// Warm standby specific configuration
+	Standby standby.Config `yaml:"standby"`
+
+	// Identities issued to the tasks at launch
+	Identity identity.Config `yaml:"identity"`
+
+	// Snapshots of the cluster state exported for audits
+	Audit audit.Config `yaml:"audit"`
+
+	// Verification of the cached task runtimes against storage
+	Verifier verifier.Config `yaml:"verifier"`
+
+	// Internal Job Manager service specific configuration
+	Admin adminsvc.Config `yaml:"admin"`
+
+	// Period in sec for resyncing the active tasks cache with resmgr
 	ActiveTaskUpdatePeriod time.Duration `yaml:"active_task_update_period"`
 
 	// Enable job runtime re-calculation via cache,
@@ -14,7 +14,11 @@
 
 package goalstate
 
-import "time"
+import (
+	"time"
+
+	"github.com/uber/peloton/pkg/common/consistenthash"
+)
 
 const (
 	_defaultMaxRetryDelay            = 60 * time.Minute
@@ -24,6 +28,8 @@ const (
 	_defaultJobRuntimeUpdateInterval = 1 * time.Second
 	_defaultInitialTaskBackoff       = 30 * time.Second
 	_defaultMaxTaskBackoff           = 60 * time.Minute
+	_defaultTaskBackoffMultiplier    = 2
+	_defaultSlowActionThreshold      = 10 * time.Second
 
 	// Job worker threads should be small because job create and job kill
 	// actions create 1000 parallel threads to update the DB, and if too
@@ -32,9 +38,17 @@ const (
 	// Setting it to 50 based on experiments in production.
 	_defaultJobWorkerThreads  = 50
 	_defaultTaskWorkerThreads = 1000
+	// Tasks enqueued by users, such as to start, stop or restart them, are
+	// processed by a separate pool so they are not delayed by the other
+	// tasks.
+	_defaultHighPriorityTaskWorkerThreads = 100
 	// TODO determine the correct value of the number of
 	// parallel threads to run job updates.
 	_defaultUpdateWorkerThreads = 100
+
+	// The jobs are hashed to enough shards to be evenly spread across
+	// the job managers, and few enough to hold a lease for each of them.
+	_defaultNumShards = 256
 )
 
 // Config for the goalstate engine.
@@ -70,6 +84,11 @@ type Config struct {
 	// serving the task goal state engine. This number indicates the maximum
 	// number of tasks which can be parallely processed by the goal state engine.
 	NumWorkerTaskThreads int `yaml:"task_worker_thread_count"`
+	// NumWorkerHighPriorityTaskThreads is the number of worker threads in
+	// the pool serving the tasks enqueued with high priority into the task
+	// goal state engine, such as the tasks started, stopped or restarted
+	// by users, separately from the other tasks.
+	NumWorkerHighPriorityTaskThreads int `yaml:"task_high_priority_worker_thread_count"`
 	// NumWorkerJobThreads is the number of worker threads in the pool
 	// serving the job update goal state engine. This number indicates
 	// the maximum number of job updates which can be parallely processed
@@ -78,18 +97,72 @@ type Config struct {
 
 	// InitialTaskBackoff defines the initial back-off delay to recreate
 	// failed tasks. Back off is calculated as
-	// min(InitialTaskBackOff * 2 ^ (failureCount - 1), MaxBackoff).
-	// Default to 30s.
+	// min(InitialTaskBackOff * TaskBackoffMultiplier ^ (failureCount - 1), MaxBackoff).
+	// Default to 30s. Can be overridden by the task backoff policy of a job.
 	InitialTaskBackoff time.Duration `yaml:"initial_task_backoff"`
 
 	// InitialTaskBackoff defines the max back-off delay to recreate
 	// failed tasks. Back off is calculated as
-	// min(InitialTaskBackOff * 2 ^ (failureCount - 1), MaxBackoff).
-	// Default to 1h.
+	// min(InitialTaskBackOff * TaskBackoffMultiplier ^ (failureCount - 1), MaxBackoff).
+	// Default to 1h. Can be overridden by the task backoff policy of a job.
 	MaxTaskBackoff time.Duration `yaml:"max_task_backoff"`
 
+	// TaskBackoffMultiplier defines the factor by which the back-off delay
+	// to recreate failed tasks grows after each failure.
+	// Default to 2. Can be overridden by the task backoff policy of a job.
+	TaskBackoffMultiplier float64 `yaml:"task_backoff_multiplier"`
+
+	// MaxConcurrentTaskActionsPerJob is the maximum number of tasks of a
+	// job launched or killed concurrently by the goal state engine, so
+	// that a large job does not starve the other jobs. The tasks being
+	// launched or killed on the hosts count towards the limit. It can be
+	// overridden by the job config. Not limited if zero.
+	MaxConcurrentTaskActionsPerJob uint32 `yaml:"max_concurrent_task_actions_per_job"`
+
+	// SlowActionThreshold is the duration above which a goal state action
+	// is logged as slow, along with the entity it ran for, and counted in
+	// the slow actions of its name. Default to 10s if zero. The slow
+	// actions are neither logged nor counted if negative, e.g. -1s.
+	SlowActionThreshold time.Duration `yaml:"slow_action_threshold"`
+
+	// RetryPolicies are the policies of the delay before evaluating again
+	// the jobs, tasks and updates whose goal state action failed, by job
+	// type, either "batch" or "service". The entities of the job types
+	// without a policy are retried with a linear backoff by
+	// FailureRetryDelay, capped at MaxRetryDelay.
+	RetryPolicies map[string]*RetryPolicyConfig `yaml:"retry_policies"`
+
 	// RecoveryConfig to recover jobs on jobmgr restart
 	RecoveryConfig *RecoveryConfig `yaml:"recovery"`
+
+	// Sharding is the config of the sharding of the goal state of the
+	// jobs across the job managers
+	Sharding ShardingConfig `yaml:"sharding"`
+}
+
+// RetryPolicyConfig is the config of the policy of the delay before
+// evaluating again an entity whose goal state action failed.
+type RetryPolicyConfig struct {
+	// Type of the policy, either "linear" or "exponential".
+	// Default to linear.
+	Type string `yaml:"type"`
+	// InitialDelay is the delay after the first failure, which is the
+	// increment of the delay after each failure for the linear policy.
+	// Default to FailureRetryDelay.
+	InitialDelay time.Duration `yaml:"initial_delay"`
+	// Multiplier is the factor by which the delay grows after each
+	// failure for the exponential policy. Default to 2.
+	Multiplier float64 `yaml:"multiplier"`
+	// MaxDelay caps the delay. Default to MaxRetryDelay.
+	MaxDelay time.Duration `yaml:"max_delay"`
+	// Jitter is the fraction of the delay, in [0, 1], by which the delay
+	// of the exponential policy is randomly reduced, so that the entities
+	// which failed together are not retried together.
+	Jitter float64 `yaml:"jitter"`
+	// Actions overrides the policy of the actions with the given names,
+	// such as "start_task". The unset fields of an override take the
+	// value of the policy.
+	Actions map[string]*RetryPolicyConfig `yaml:"actions"`
 }
 
 // RecoveryConfig is the container for recovery related config
@@ -129,6 +202,9 @@ func (c *Config) normalize() {
 	if c.NumWorkerTaskThreads == 0 {
 		c.NumWorkerTaskThreads = _defaultTaskWorkerThreads
 	}
+	if c.NumWorkerHighPriorityTaskThreads == 0 {
+		c.NumWorkerHighPriorityTaskThreads = _defaultHighPriorityTaskWorkerThreads
+	}
 	if c.NumWorkerUpdateThreads == 0 {
 		c.NumWorkerUpdateThreads = _defaultUpdateWorkerThreads
 	}
@@ -140,4 +216,19 @@ func (c *Config) normalize() {
 	if c.MaxTaskBackoff == 0 {
 		c.MaxTaskBackoff = _defaultMaxTaskBackoff
 	}
+
+	if c.TaskBackoffMultiplier == 0 {
+		c.TaskBackoffMultiplier = _defaultTaskBackoffMultiplier
+	}
+
+	if c.SlowActionThreshold == 0 {
+		c.SlowActionThreshold = _defaultSlowActionThreshold
+	}
+
+	if c.Sharding.NumShards == 0 {
+		c.Sharding.NumShards = _defaultNumShards
+	}
+	if c.Sharding.VirtualNodes == 0 {
+		c.Sharding.VirtualNodes = consistenthash.DefaultReplicas
+	}
 }
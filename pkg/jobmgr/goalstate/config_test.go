@@ -16,6 +16,9 @@ package goalstate
 
 import (
 	"testing"
+	"time"
+
+	"github.com/uber/peloton/pkg/common/consistenthash"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -33,5 +36,19 @@ func TestConfigNormalize(t *testing.T) {
 	assert.Equal(t, _defaultJobRuntimeUpdateInterval, c.JobServiceRuntimeUpdateInterval)
 	assert.Equal(t, _defaultJobWorkerThreads, c.NumWorkerJobThreads)
 	assert.Equal(t, _defaultTaskWorkerThreads, c.NumWorkerTaskThreads)
+	assert.Equal(t, _defaultHighPriorityTaskWorkerThreads, c.NumWorkerHighPriorityTaskThreads)
 	assert.Equal(t, _defaultUpdateWorkerThreads, c.NumWorkerUpdateThreads)
+	assert.Equal(t, _defaultSlowActionThreshold, c.SlowActionThreshold)
+	assert.Equal(t, uint32(_defaultNumShards), c.Sharding.NumShards)
+	assert.Equal(t, consistenthash.DefaultReplicas, c.Sharding.VirtualNodes)
+}
+
+// TestConfigNormalizeSlowActionsDisabled tests that a negative slow action
+// threshold, which disables the slow action logs, is kept
+func TestConfigNormalizeSlowActionsDisabled(t *testing.T) {
+	c := Config{SlowActionThreshold: -time.Second}
+
+	c.normalize()
+
+	assert.Equal(t, -time.Second, c.SlowActionThreshold)
 }
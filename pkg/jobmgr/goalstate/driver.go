@@ -16,6 +16,8 @@ package goalstate
 
 import (
 	"context"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -28,10 +30,13 @@ import (
 
 	"github.com/uber/peloton/pkg/common"
 	"github.com/uber/peloton/pkg/common/goalstate"
+	"github.com/uber/peloton/pkg/common/leader"
 	"github.com/uber/peloton/pkg/common/recovery"
 	"github.com/uber/peloton/pkg/jobmgr/cached"
 	"github.com/uber/peloton/pkg/jobmgr/task/launcher"
+	"github.com/uber/peloton/pkg/jobmgr/task/registration"
 	"github.com/uber/peloton/pkg/storage"
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/uber-go/tally"
@@ -101,10 +106,26 @@ type Driver interface {
 	// EnqueueJob is used to enqueue a job into the goal state. It takes the job identifier
 	// and the time at which the job should be evaluated by the goal state engine as inputs.
 	EnqueueJob(jobID *peloton.JobID, deadline time.Time)
+	// EnqueueJobWithDefaultDelay is used to enqueue a job into the goal
+	// state with the default interval at which the job runtime updater is
+	// run for the job type. It ensures that the same job does not get
+	// enqueued too many times when multiple task updates for the job are
+	// received in a short duration of time.
+	EnqueueJobWithDefaultDelay(jobID *peloton.JobID, jobType job.JobType)
 	// EnqueueTask is used to enqueue a task into the goal state. It takes the job identifier,
 	// the instance identifier and the time at which the task should be evaluated by the
 	// goal state engine as inputs.
 	EnqueueTask(jobID *peloton.JobID, instanceID uint32, deadline time.Time)
+	// EnqueueTaskWithPriority is used to enqueue a task into the given
+	// priority lane of the goal state. The tasks started, stopped or
+	// restarted by users are enqueued with high priority, so that they
+	// are evaluated ahead of the background work.
+	EnqueueTaskWithPriority(
+		jobID *peloton.JobID,
+		instanceID uint32,
+		deadline time.Time,
+		priority goalstate.Priority,
+	)
 	// EnqueueUpdate is used to enqueue a job update into the goal state. As
 	// its input, it takes the job identifier, update identifier, and the
 	// time at which the job update should be evaluated by the
@@ -128,8 +149,48 @@ type Driver interface {
 	JobRuntimeDuration(jobType job.JobType) time.Duration
 	// Start is used to start processing items in the goal state engine.
 	Start()
+	// StartFromCache is used to start processing items in the goal state
+	// engine using the jobs and tasks already present in the cache, instead
+	// of recovering them from DB. It is used when a warm standby job
+	// manager gains leadership.
+	StartFromCache()
 	// Stop is used to clean all items and then stop the goal state engine.
 	Stop()
+	// Pause stops running the actions of the jobs, tasks and updates of
+	// the given job types, or of all the job types if none is given.
+	// Entities of the paused job types are still enqueued but are dropped
+	// without running any action when they are dequeued. Actions already
+	// running when the driver is paused are not interrupted. The pause is
+	// persisted, so that it survives a change of leader.
+	Pause(ctx context.Context, jobTypes ...job.JobType) error
+	// Resume resumes running the actions of the jobs, tasks and updates of
+	// the given job types, or of all the job types if none is given. The
+	// jobs of the resumed job types in the cache are enqueued again, along
+	// with their tasks and updates, so that they are evaluated.
+	Resume(ctx context.Context, jobTypes ...job.JobType) error
+	// PausedJobTypes returns the job types whose actions are paused.
+	PausedJobTypes() []job.JobType
+	// IsPaused returns true if the actions of the given job are paused.
+	// The components launching or killing the tasks of the job outside
+	// of the goal state engine must not do so either.
+	IsPaused(jobID *peloton.JobID) bool
+	// Entities returns the state of the jobs, tasks and updates tracked
+	// by the goal state engines of the given job, or of all the jobs if
+	// the job identifier is nil.
+	Entities(jobID *peloton.JobID) *Entities
+	// Evaluate returns the actions which the goal state engine would run
+	// right now for the given job, its tasks of the given instances and
+	// its updates, along with the states and goal states considered,
+	// without running any of them. The job must be in the cache.
+	Evaluate(jobID *peloton.JobID, instanceIDs []uint32) (*Evaluations, error)
+}
+
+// Entities are the entities tracked by the goal state engines of the
+// driver, along with their scheduled time and their backoff.
+type Entities struct {
+	Jobs    []goalstate.EntityInfo
+	Tasks   []goalstate.EntityInfo
+	Updates []goalstate.EntityInfo
 }
 
 // NewDriver returns a new goal state driver object.
@@ -141,6 +202,9 @@ func NewDriver(
 	updateStore storage.UpdateStore,
 	jobFactory cached.JobFactory,
 	taskLauncher launcher.Launcher,
+	registrationHooks registration.Hooks,
+	pauseOps ormobjects.GoalStatePauseOps,
+	shardLeases leader.ShardLeases,
 	jobType job.JobType,
 	parentScope tally.Scope,
 	cfg Config,
@@ -150,22 +214,7 @@ func NewDriver(
 	jobScope := scope.SubScope("job")
 	taskScope := scope.SubScope("task")
 
-	return &driver{
-		jobEngine: goalstate.NewEngine(
-			cfg.NumWorkerJobThreads,
-			cfg.FailureRetryDelay,
-			cfg.MaxRetryDelay,
-			jobScope),
-		taskEngine: goalstate.NewEngine(
-			cfg.NumWorkerTaskThreads,
-			cfg.FailureRetryDelay,
-			cfg.MaxRetryDelay,
-			taskScope),
-		updateEngine: goalstate.NewEngine(
-			cfg.NumWorkerUpdateThreads,
-			cfg.FailureRetryDelay,
-			cfg.MaxRetryDelay,
-			jobScope),
+	goalStateDriver := &driver{
 		hostmgrClient: hostsvc.NewInternalHostServiceYARPCClient(
 			d.ClientConfig(common.PelotonHostManager)),
 		resmgrClient: resmgrsvc.NewResourceManagerServiceYARPCClient(
@@ -176,27 +225,56 @@ func NewDriver(
 		updateStore:                   updateStore,
 		jobFactory:                    jobFactory,
 		taskLauncher:                  taskLauncher,
+		registrationHooks:             registrationHooks,
+		pauseOps:                      pauseOps,
+		shardLeases:                   shardLeases,
 		mtx:                           NewMetrics(scope),
 		cfg:                           &cfg,
 		jobType:                       jobType,
 		jobRuntimeCalculationViaCache: jobRuntimeCalculationViaCache,
 		jobScope:                      jobScope,
+		clock:                         goalstate.NewRealClock(),
+		pausedJobTypes:                make(map[job.JobType]bool),
 	}
+
+	opts := []goalstate.EngineOption{
+		goalstate.WithSlowActionThreshold(cfg.SlowActionThreshold),
+	}
+	if retryPolicy := newRetryPolicy(goalStateDriver, &cfg); retryPolicy != nil {
+		opts = append(opts, goalstate.WithRetryPolicy(retryPolicy))
+	}
+
+	goalStateDriver.jobEngine = goalstate.NewEngine(
+		cfg.NumWorkerJobThreads,
+		cfg.FailureRetryDelay,
+		cfg.MaxRetryDelay,
+		jobScope,
+		opts...)
+	taskOpts := append([]goalstate.EngineOption{
+		goalstate.WithHighPriorityWorkers(cfg.NumWorkerHighPriorityTaskThreads),
+	}, opts...)
+	goalStateDriver.taskEngine = goalstate.NewEngine(
+		cfg.NumWorkerTaskThreads,
+		cfg.FailureRetryDelay,
+		cfg.MaxRetryDelay,
+		taskScope,
+		taskOpts...)
+	goalStateDriver.updateEngine = goalstate.NewEngine(
+		cfg.NumWorkerUpdateThreads,
+		cfg.FailureRetryDelay,
+		cfg.MaxRetryDelay,
+		jobScope,
+		opts...)
+	return goalStateDriver
 }
 
-// EnqueueJobWithDefaultDelay is a helper function to enqueue a job into the
-// goal state engine with the default interval at which the job runtime
-// updater is run. Using this function ensures that same job does not
-// get enqueued too many times when multiple task updates for the job
-// are received in a short duration of time.
-// TODO(zhixin): pass in job type instead of cachedJob,
-// remove GetJobType from the interface
-func EnqueueJobWithDefaultDelay(
-	jobID *peloton.JobID,
-	goalStateDriver Driver,
-	cachedJob cached.Job) {
-	goalStateDriver.EnqueueJob(jobID, time.Now().Add(
-		goalStateDriver.JobRuntimeDuration(cachedJob.GetJobType())))
+// JobStatesToRecover returns the job states which need recovery when a job
+// manager instance of the given job type gains leadership.
+func JobStatesToRecover(jobType job.JobType) []job.JobState {
+	if jobType == job.JobType_SERVICE {
+		return serviceJobStatesToRecover
+	}
+	return batchJobStatesToRecover
 }
 
 type driver struct {
@@ -232,6 +310,11 @@ type driver struct {
 	// taskLauncher is used to launch tasks to host manager
 	taskLauncher launcher.Launcher
 
+	// registrationHooks deregisters the tasks from the external discovery
+	// system before they are killed, and recovers their registrations,
+	// nil if not enabled
+	registrationHooks registration.Hooks
+
 	cfg     *Config  // goal state engine configuration
 	mtx     *Metrics // goal state metrics
 	running int32    // whether driver is running or not
@@ -241,9 +324,42 @@ type driver struct {
 	jobRuntimeCalculationViaCache bool
 	// job scope for goalstate driver
 	jobScope tally.Scope
+	// clock used to compute deadlines and timeouts of goal state actions
+	clock goalstate.Clock
+
+	// pauseOps persists the job types whose actions are paused, nil if
+	// the pauses are not persisted
+	pauseOps ormobjects.GoalStatePauseOps
+	// pauseLock protects pausedJobTypes
+	pauseLock sync.RWMutex
+	// pausedJobTypes are the job types whose actions are paused
+	pausedJobTypes map[job.JobType]bool
+
+	// taskActionLimiter limits the tasks of each job launched or
+	// killed concurrently
+	taskActionLimiter jobActionLimiter
+
+	// shardLock protects shards
+	shardLock sync.RWMutex
+	// shards of the job managers sharing the goal state, nil until known
+	shards *shards
+	// shardLeases fence the shards across the job managers, nil if the
+	// goal state is not sharded
+	shardLeases leader.ShardLeases
+}
+
+// now returns the current time of the driver clock.
+func (d *driver) now() time.Time {
+	if d.clock == nil {
+		return time.Now()
+	}
+	return d.clock.Now()
 }
 
 func (d *driver) EnqueueJob(jobID *peloton.JobID, deadline time.Time) {
+	if !d.isOwned(jobID) {
+		return
+	}
 	jobEntity := NewJobEntity(jobID, d)
 
 	d.RLock()
@@ -252,7 +368,16 @@ func (d *driver) EnqueueJob(jobID *peloton.JobID, deadline time.Time) {
 	d.jobEngine.Enqueue(jobEntity, deadline)
 }
 
+func (d *driver) EnqueueJobWithDefaultDelay(
+	jobID *peloton.JobID,
+	jobType job.JobType) {
+	d.EnqueueJob(jobID, d.now().Add(d.JobRuntimeDuration(jobType)))
+}
+
 func (d *driver) EnqueueTask(jobID *peloton.JobID, instanceID uint32, deadline time.Time) {
+	if !d.isOwned(jobID) {
+		return
+	}
 	taskEntity := NewTaskEntity(jobID, instanceID, d)
 
 	d.RLock()
@@ -261,10 +386,29 @@ func (d *driver) EnqueueTask(jobID *peloton.JobID, instanceID uint32, deadline t
 	d.taskEngine.Enqueue(taskEntity, deadline)
 }
 
+func (d *driver) EnqueueTaskWithPriority(
+	jobID *peloton.JobID,
+	instanceID uint32,
+	deadline time.Time,
+	priority goalstate.Priority) {
+	if !d.isOwned(jobID) {
+		return
+	}
+	taskEntity := NewTaskEntity(jobID, instanceID, d)
+
+	d.RLock()
+	defer d.RUnlock()
+
+	d.taskEngine.EnqueueWithPriority(taskEntity, deadline, priority)
+}
+
 func (d *driver) EnqueueUpdate(
 	jobID *peloton.JobID,
 	updateID *peloton.UpdateID,
 	deadline time.Time) {
+	if !d.isOwned(jobID) {
+		return
+	}
 	updateEntity := NewUpdateEntity(updateID, jobID, d)
 
 	d.RLock()
@@ -343,7 +487,7 @@ func (d *driver) recoverTasks(
 	}
 
 	// Enqueue job into goal state
-	d.EnqueueJob(jobID, time.Now().Add(d.JobRuntimeDuration(jobConfig.GetType())))
+	d.EnqueueJob(jobID, d.now().Add(d.JobRuntimeDuration(jobConfig.GetType())))
 
 	runtimes, err := d.taskStore.GetTaskRuntimesForJobByRange(
 		ctx,
@@ -369,9 +513,14 @@ func (d *driver) recoverTasks(
 			cachedJob.ReplaceTasks(map[uint32]*task.RuntimeInfo{instanceID: runtime}, false)
 		}
 
+		if d.registrationHooks != nil {
+			d.registrationHooks.Recover(
+				jobID, instanceID, jobConfig.GetType(), runtime)
+		}
+
 		// Do not evaluate goal state for tasks which will be evaluated using job create tasks action.
 		if runtime.GetState() != task.TaskState_INITIALIZED || jobRuntime.GetState() != job.JobState_INITIALIZED {
-			d.EnqueueTask(jobID, instanceID, time.Now())
+			d.EnqueueTask(jobID, instanceID, d.now())
 		}
 	}
 
@@ -387,7 +536,7 @@ func (d *driver) recoverTasks(
 		d.EnqueueUpdate(
 			jobID,
 			updateID,
-			time.Now().Add(d.JobRuntimeDuration(jobConfig.GetType())))
+			d.now().Add(d.JobRuntimeDuration(jobConfig.GetType())))
 	}
 	return
 }
@@ -399,15 +548,11 @@ func (d *driver) syncFromDB(ctx context.Context) error {
 	log.Info("syncing cache and goal state with db")
 	startRecoveryTime := time.Now()
 
-	jobStatesToRecover := batchJobStatesToRecover
-	if d.jobType == job.JobType_SERVICE {
-		jobStatesToRecover = serviceJobStatesToRecover
-	}
 	err := recovery.RecoverJobsByState(
 		ctx,
 		d.jobScope,
 		d.jobStore,
-		jobStatesToRecover,
+		JobStatesToRecover(d.jobType),
 		d.recoverTasks,
 		d.cfg.RecoveryConfig.RecoverFromActiveJobs,
 		// Jobmgr should not backfill active jobs. It will be done by resmgr
@@ -431,7 +576,80 @@ func (d *driver) runningState() int32 {
 	return atomic.LoadInt32(&d.running)
 }
 
+// syncFromCache enqueues the jobs, tasks and updates already present in the
+// cache into the goal state engine. The cache is expected to have been kept
+// in sync with DB by a warm standby, so no task runtime is read from DB.
+func (d *driver) syncFromCache(ctx context.Context) error {
+	log.Info("syncing goal state with warm cache")
+	startRecoveryTime := time.Now()
+
+	for id, cachedJob := range d.jobFactory.GetAllJobs() {
+		jobID := &peloton.JobID{Value: id}
+		jobRuntime, err := cachedJob.GetRuntime(ctx)
+		if err != nil {
+			log.WithError(err).
+				WithField("job_id", id).
+				Error("failed to get job runtime from cache")
+			return err
+		}
+		jobType := cachedJob.GetJobType()
+
+		d.EnqueueJob(jobID, d.now().Add(d.JobRuntimeDuration(jobType)))
+
+		for instanceID, cachedTask := range cachedJob.GetAllTasks() {
+			d.mtx.taskMetrics.TaskRecovered.Inc(1)
+
+			// The registrations of the previous leader are not known, so
+			// they are rebuilt from the cache as when recovering from DB.
+			if d.registrationHooks != nil {
+				runtime, err := cachedTask.GetRuntime(ctx)
+				if err != nil {
+					log.WithError(err).
+						WithField("job_id", id).
+						WithField("instance_id", instanceID).
+						Error("failed to get task runtime from cache")
+					return err
+				}
+				d.registrationHooks.Recover(
+					jobID, instanceID, jobType, runtime)
+			}
+
+			// Do not evaluate goal state for tasks which will be evaluated using job create tasks action.
+			if cachedTask.CurrentState().State != task.TaskState_INITIALIZED ||
+				jobRuntime.GetState() != job.JobState_INITIALIZED {
+				d.EnqueueTask(jobID, instanceID, d.now())
+			}
+		}
+
+		cachedJob.RecalculateResourceUsage(ctx)
+
+		updateID := jobRuntime.GetUpdateID()
+		if len(updateID.GetValue()) > 0 {
+			d.EnqueueUpdate(
+				jobID,
+				updateID,
+				d.now().Add(d.JobRuntimeDuration(jobType)))
+		}
+	}
+
+	log.WithField("time_spent", time.Since(startRecoveryTime)).
+		Info("syncing goal state with warm cache is finished")
+	d.mtx.jobMetrics.JobRecoveryDuration.Update(float64(time.Since(startRecoveryTime) / time.Millisecond))
+
+	return nil
+}
+
 func (d *driver) Start() {
+	d.start(d.syncFromDB)
+}
+
+func (d *driver) StartFromCache() {
+	d.start(d.syncFromCache)
+}
+
+// start syncs the goal state using syncFunc and then starts
+// the goal state engines.
+func (d *driver) start(syncFunc func(ctx context.Context) error) {
 	// Ensure that driver is not already running
 	for {
 		if d.runningState() != int32(running) {
@@ -440,7 +658,12 @@ func (d *driver) Start() {
 		time.Sleep(_sleepRetryCheckRunningState)
 	}
 
-	if err := d.syncFromDB(context.Background()); err != nil {
+	if err := d.loadPausedJobTypes(context.Background()); err != nil {
+		log.WithError(err).
+			Fatal("failed to load paused job types from DB")
+	}
+
+	if err := syncFunc(context.Background()); err != nil {
 		log.WithError(err).
 			Fatal("failed to sync job manager with DB")
 	}
@@ -497,3 +720,206 @@ func (d *driver) Stop() {
 	atomic.StoreInt32(&d.running, int32(notRunning))
 	log.Info("goalstate driver stopped")
 }
+
+// allJobTypes returns the job types when none is given.
+func allJobTypes(jobTypes []job.JobType) []job.JobType {
+	if len(jobTypes) > 0 {
+		return jobTypes
+	}
+	for t := range job.JobType_name {
+		jobTypes = append(jobTypes, job.JobType(t))
+	}
+	return jobTypes
+}
+
+func (d *driver) Pause(ctx context.Context, jobTypes ...job.JobType) error {
+	for _, jobType := range allJobTypes(jobTypes) {
+		// persist the pause first, so that a paused job type is never
+		// resumed by a change of leader
+		if err := d.persistPause(ctx, jobType, true); err != nil {
+			return err
+		}
+
+		d.pauseLock.Lock()
+		if d.pausedJobTypes == nil {
+			d.pausedJobTypes = make(map[job.JobType]bool)
+		}
+		d.pausedJobTypes[jobType] = true
+		d.mtx.pausedJobTypes.Update(float64(len(d.pausedJobTypes)))
+		d.pauseLock.Unlock()
+
+		log.WithField("job_type", jobType.String()).
+			Warn("goalstate driver paused")
+	}
+	return nil
+}
+
+func (d *driver) Resume(ctx context.Context, jobTypes ...job.JobType) error {
+	resumed := make(map[job.JobType]bool)
+
+	var err error
+	for _, jobType := range allJobTypes(jobTypes) {
+		d.pauseLock.RLock()
+		paused := d.pausedJobTypes[jobType]
+		d.pauseLock.RUnlock()
+		if !paused {
+			continue
+		}
+
+		if err = d.persistPause(ctx, jobType, false); err != nil {
+			break
+		}
+
+		d.pauseLock.Lock()
+		delete(d.pausedJobTypes, jobType)
+		d.mtx.pausedJobTypes.Update(float64(len(d.pausedJobTypes)))
+		d.pauseLock.Unlock()
+
+		resumed[jobType] = true
+		log.WithField("job_type", jobType.String()).
+			Info("goalstate driver resumed")
+	}
+
+	// The entities dropped while paused are not in the goal state
+	// engine anymore, evaluate all the entities of the resumed job types
+	// again. Start enqueues all of them if the driver is not running.
+	if len(resumed) != 0 && d.runningState() == int32(running) {
+		d.enqueueCachedJobs(func(_ string, cachedJob cached.Job) bool {
+			return resumed[cachedJob.GetJobType()]
+		})
+	}
+	return err
+}
+
+// persistPause persists whether the actions of the given job type
+// are paused.
+func (d *driver) persistPause(
+	ctx context.Context,
+	jobType job.JobType,
+	paused bool,
+) error {
+	if d.pauseOps == nil {
+		return nil
+	}
+	return d.pauseOps.Set(ctx, jobType, paused)
+}
+
+// loadPausedJobTypes loads the job types whose actions are paused from
+// DB, which may have been paused or resumed by the previous leader.
+func (d *driver) loadPausedJobTypes(ctx context.Context) error {
+	if d.pauseOps == nil {
+		return nil
+	}
+
+	pausedJobTypes := make(map[job.JobType]bool)
+	for _, jobType := range allJobTypes(nil) {
+		paused, err := d.pauseOps.Get(ctx, jobType)
+		if err != nil {
+			return err
+		}
+		if paused {
+			pausedJobTypes[jobType] = true
+			log.WithField("job_type", jobType.String()).
+				Warn("goalstate driver paused by previous leader")
+		}
+	}
+
+	d.pauseLock.Lock()
+	defer d.pauseLock.Unlock()
+	d.pausedJobTypes = pausedJobTypes
+	d.mtx.pausedJobTypes.Update(float64(len(d.pausedJobTypes)))
+	return nil
+}
+
+func (d *driver) PausedJobTypes() []job.JobType {
+	d.pauseLock.RLock()
+	defer d.pauseLock.RUnlock()
+
+	var jobTypes []job.JobType
+	for jobType := range d.pausedJobTypes {
+		jobTypes = append(jobTypes, jobType)
+	}
+	sort.Slice(jobTypes, func(i, j int) bool {
+		return jobTypes[i] < jobTypes[j]
+	})
+	return jobTypes
+}
+
+// IsPaused returns true if the actions of the given job are paused,
+// in which case the entities of the job should not run any action.
+func (d *driver) IsPaused(jobID *peloton.JobID) bool {
+	d.pauseLock.RLock()
+	defer d.pauseLock.RUnlock()
+
+	if len(d.pausedJobTypes) == 0 {
+		return false
+	}
+
+	paused := len(d.pausedJobTypes) == len(job.JobType_name)
+	if !paused {
+		cachedJob := d.jobFactory.GetJob(jobID)
+		paused = cachedJob != nil && d.pausedJobTypes[cachedJob.GetJobType()]
+	}
+	if paused {
+		d.mtx.pausedSkipped.Inc(1)
+		log.WithField("job_id", jobID.GetValue()).
+			Debug("skipping actions of paused job")
+	}
+	return paused
+}
+
+// enqueueCachedJobs enqueues the jobs in the cache matching the filter
+// into the goal state engine, along with their tasks and updates.
+func (d *driver) enqueueCachedJobs(
+	match func(id string, cachedJob cached.Job) bool) {
+	now := d.now()
+	for id, cachedJob := range d.jobFactory.GetAllJobs() {
+		if !match(id, cachedJob) {
+			continue
+		}
+
+		jobID := &peloton.JobID{Value: id}
+		d.EnqueueJob(jobID, now)
+		for instanceID := range cachedJob.GetAllTasks() {
+			d.EnqueueTask(jobID, instanceID, now)
+		}
+		for updateID := range cachedJob.GetAllWorkflows() {
+			d.EnqueueUpdate(jobID, &peloton.UpdateID{Value: updateID}, now)
+		}
+	}
+}
+
+func (d *driver) Entities(jobID *peloton.JobID) *Entities {
+	d.RLock()
+	defer d.RUnlock()
+
+	// The job and the update entities are identified by the job
+	// identifier, and the task entities by the job identifier
+	// followed by the instance identifier.
+	isJob := func(id string) bool {
+		return jobID == nil || id == jobID.GetValue()
+	}
+	isTask := func(id string) bool {
+		return jobID == nil || strings.HasPrefix(id, jobID.GetValue()+"-")
+	}
+
+	return &Entities{
+		Jobs:    filterEntities(d.jobEngine.Entities(), isJob),
+		Tasks:   filterEntities(d.taskEngine.Entities(), isTask),
+		Updates: filterEntities(d.updateEngine.Entities(), isJob),
+	}
+}
+
+// filterEntities returns the entities whose identifier matches.
+func filterEntities(
+	infos []goalstate.EntityInfo,
+	match func(id string) bool,
+) []goalstate.EntityInfo {
+	var result []goalstate.EntityInfo
+	for _, info := range infos {
+		if match(info.ID) {
+			result = append(result, info)
+		}
+	}
+	return result
+}
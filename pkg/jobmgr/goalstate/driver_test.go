@@ -24,14 +24,19 @@ import (
 	"github.com/uber/peloton/.gen/peloton/api/v0/job"
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
 	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	"github.com/uber/peloton/.gen/peloton/api/v0/update"
 
 	"github.com/uber/peloton/pkg/common"
+	"github.com/uber/peloton/pkg/common/consistenthash"
 	"github.com/uber/peloton/pkg/common/goalstate"
 	goalstatemocks "github.com/uber/peloton/pkg/common/goalstate/mocks"
+	leadermocks "github.com/uber/peloton/pkg/common/leader/mocks"
 	"github.com/uber/peloton/pkg/jobmgr/cached"
 	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
 	launchermocks "github.com/uber/peloton/pkg/jobmgr/task/launcher/mocks"
+	registrationmocks "github.com/uber/peloton/pkg/jobmgr/task/registration/mocks"
 	storemocks "github.com/uber/peloton/pkg/storage/mocks"
+	objectmocks "github.com/uber/peloton/pkg/storage/objects/mocks"
 
 	"github.com/golang/mock/gomock"
 	"github.com/pborman/uuid"
@@ -41,6 +46,7 @@ import (
 	"go.uber.org/yarpc"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/transport/http"
+	"go.uber.org/yarpc/yarpcerrors"
 )
 
 type DriverTestSuite struct {
@@ -124,6 +130,9 @@ func (suite *DriverTestSuite) TestNewDriver() {
 		updateStore,
 		suite.jobFactory,
 		taskLauncher,
+		nil,
+		nil,
+		nil,
 		job.JobType_SERVICE,
 		tally.NoopScope,
 		config,
@@ -148,6 +157,25 @@ func (suite *DriverTestSuite) TestEnqueueJob() {
 	suite.goalStateDriver.EnqueueJob(suite.jobID, time.Now())
 }
 
+// TestEnqueueJobWithDefaultDelay tests enqueuing job into goal state engine
+// after the runtime update interval of the job type, using the driver clock.
+func (suite *DriverTestSuite) TestEnqueueJobWithDefaultDelay() {
+	now := time.Now().Add(-time.Hour)
+	suite.goalStateDriver.clock = goalstate.NewManualClock(now)
+
+	suite.jobGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any()).
+		Do(func(jobEntity goalstate.Entity, deadline time.Time) {
+			suite.Equal(suite.jobID.GetValue(), jobEntity.GetID())
+			suite.Equal(
+				now.Add(suite.goalStateDriver.cfg.JobServiceRuntimeUpdateInterval),
+				deadline)
+		})
+
+	suite.goalStateDriver.EnqueueJobWithDefaultDelay(
+		suite.jobID, job.JobType_SERVICE)
+}
+
 // TestEnqueueTask tests enqueuing task into goal state engine.
 func (suite *DriverTestSuite) TestEnqueueTask() {
 	taskID := fmt.Sprintf("%s-%d", suite.jobID.GetValue(), suite.instanceID)
@@ -161,6 +189,24 @@ func (suite *DriverTestSuite) TestEnqueueTask() {
 	suite.goalStateDriver.EnqueueTask(suite.jobID, suite.instanceID, time.Now())
 }
 
+// TestEnqueueTaskWithPriority tests enqueuing task into the high
+// priority lane of the goal state engine.
+func (suite *DriverTestSuite) TestEnqueueTaskWithPriority() {
+	taskID := fmt.Sprintf("%s-%d", suite.jobID.GetValue(), suite.instanceID)
+
+	suite.taskGoalStateEngine.EXPECT().
+		EnqueueWithPriority(gomock.Any(), gomock.Any(), goalstate.PriorityHigh).
+		Do(func(
+			taskEntity goalstate.Entity,
+			deadline time.Time,
+			priority goalstate.Priority) {
+			suite.Equal(taskID, taskEntity.GetID())
+		})
+
+	suite.goalStateDriver.EnqueueTaskWithPriority(
+		suite.jobID, suite.instanceID, time.Now(), goalstate.PriorityHigh)
+}
+
 // TestEnqueueUpdate tests enqueuing job update into goal state engine.
 func (suite *DriverTestSuite) TestEnqueueUpdate() {
 	suite.updateGoalStateEngine.EXPECT().
@@ -371,6 +417,35 @@ func (suite *DriverTestSuite) TestSyncFromDBForServiceCluster() {
 	suite.NoError(suite.goalStateDriver.syncFromDB(context.Background()))
 }
 
+// TestSyncFromDBRecoverRegistrations tests that the registrations of
+// the recovered tasks are rebuilt.
+func (suite *DriverTestSuite) TestSyncFromDBRecoverRegistrations() {
+	registrationHooks := registrationmocks.NewMockHooks(suite.ctrl)
+	suite.goalStateDriver.registrationHooks = registrationHooks
+	suite.goalStateDriver.jobType = job.JobType_SERVICE
+	suite.prepareTestSyncDB(job.JobType_SERVICE)
+	runtime := &task.RuntimeInfo{
+		State:     task.TaskState_RUNNING,
+		GoalState: task.TaskState_RUNNING,
+	}
+	suite.taskStore.EXPECT().
+		GetTaskRuntimesForJobByRange(gomock.Any(), suite.jobID, gomock.Any()).
+		Return(map[uint32]*task.RuntimeInfo{suite.instanceID: runtime}, nil)
+	suite.cachedJob.EXPECT().
+		GetTask(suite.instanceID).Return(nil)
+	suite.cachedJob.EXPECT().
+		ReplaceTasks(gomock.Any(), false).Return(nil)
+	registrationHooks.EXPECT().
+		Recover(suite.jobID, suite.instanceID, gomock.Any(), runtime)
+	suite.taskGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any()).
+		Return()
+	suite.cachedJob.EXPECT().
+		RecalculateResourceUsage(gomock.Any())
+
+	suite.NoError(suite.goalStateDriver.syncFromDB(context.Background()))
+}
+
 // TestSyncFromDB tests syncing job manager with jobs and tasks in DB.
 func (suite *DriverTestSuite) TestSyncFromDBWithMaxRunningInstancesSLA() {
 	instanceID1 := uint32(0)
@@ -653,3 +728,607 @@ func (suite *DriverTestSuite) TestEngineStartStop() {
 
 	suite.goalStateDriver.Stop()
 }
+
+// TestSyncFromCache tests syncing goal state with the jobs and tasks
+// already present in the cache.
+func (suite *DriverTestSuite) TestSyncFromCache() {
+	initializedTask := cachedmocks.NewMockTask(suite.ctrl)
+	runningTask := cachedmocks.NewMockTask(suite.ctrl)
+
+	suite.jobFactory.EXPECT().
+		GetAllJobs().
+		Return(map[string]cached.Job{suite.jobID.GetValue(): suite.cachedJob})
+	suite.cachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(&job.RuntimeInfo{
+			State:    job.JobState_RUNNING,
+			UpdateID: suite.updateID,
+		}, nil)
+	suite.cachedJob.EXPECT().
+		GetJobType().
+		Return(job.JobType_SERVICE)
+	suite.cachedJob.EXPECT().
+		GetAllTasks().
+		Return(map[uint32]cached.Task{
+			0: initializedTask,
+			1: runningTask,
+		})
+	initializedTask.EXPECT().
+		CurrentState().
+		Return(cached.TaskStateVector{State: task.TaskState_INITIALIZED})
+	runningTask.EXPECT().
+		CurrentState().
+		Return(cached.TaskStateVector{State: task.TaskState_RUNNING})
+
+	suite.jobGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any())
+	// tasks of a running job are enqueued even if they are initialized
+	suite.taskGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any()).
+		Times(2)
+	suite.cachedJob.EXPECT().
+		RecalculateResourceUsage(gomock.Any())
+	suite.updateGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any())
+
+	suite.NoError(suite.goalStateDriver.syncFromCache(context.Background()))
+}
+
+// TestSyncFromCacheRecoverRegistrations tests that the registrations of
+// the cached tasks are rebuilt when a warm standby gains leadership.
+func (suite *DriverTestSuite) TestSyncFromCacheRecoverRegistrations() {
+	registrationHooks := registrationmocks.NewMockHooks(suite.ctrl)
+	suite.goalStateDriver.registrationHooks = registrationHooks
+	cachedTask := cachedmocks.NewMockTask(suite.ctrl)
+	runtime := &task.RuntimeInfo{
+		State:     task.TaskState_RUNNING,
+		GoalState: task.TaskState_RUNNING,
+	}
+
+	suite.jobFactory.EXPECT().
+		GetAllJobs().
+		Return(map[string]cached.Job{suite.jobID.GetValue(): suite.cachedJob})
+	suite.cachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(&job.RuntimeInfo{State: job.JobState_RUNNING}, nil)
+	suite.cachedJob.EXPECT().
+		GetJobType().
+		Return(job.JobType_SERVICE)
+	suite.cachedJob.EXPECT().
+		GetAllTasks().
+		Return(map[uint32]cached.Task{suite.instanceID: cachedTask})
+	cachedTask.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(runtime, nil)
+	registrationHooks.EXPECT().
+		Recover(suite.jobID, suite.instanceID, job.JobType_SERVICE, runtime)
+	cachedTask.EXPECT().
+		CurrentState().
+		Return(cached.TaskStateVector{State: task.TaskState_RUNNING})
+
+	suite.jobGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any())
+	suite.taskGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any())
+	suite.cachedJob.EXPECT().
+		RecalculateResourceUsage(gomock.Any())
+
+	suite.NoError(suite.goalStateDriver.syncFromCache(context.Background()))
+
+	// syncing fails if the runtime of a cached task cannot be read
+	suite.jobFactory.EXPECT().
+		GetAllJobs().
+		Return(map[string]cached.Job{suite.jobID.GetValue(): suite.cachedJob})
+	suite.cachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(&job.RuntimeInfo{State: job.JobState_RUNNING}, nil)
+	suite.cachedJob.EXPECT().
+		GetJobType().
+		Return(job.JobType_SERVICE)
+	suite.cachedJob.EXPECT().
+		GetAllTasks().
+		Return(map[uint32]cached.Task{suite.instanceID: cachedTask})
+	cachedTask.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(nil, errors.New("test error"))
+	suite.jobGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any())
+
+	suite.Error(suite.goalStateDriver.syncFromCache(context.Background()))
+}
+
+// TestSyncFromCacheGetRuntimeError tests syncing goal state from the cache
+// fails if the runtime of a cached job cannot be read.
+func (suite *DriverTestSuite) TestSyncFromCacheGetRuntimeError() {
+	suite.jobFactory.EXPECT().
+		GetAllJobs().
+		Return(map[string]cached.Job{suite.jobID.GetValue(): suite.cachedJob})
+	suite.cachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(nil, errors.New("test error"))
+
+	suite.Error(suite.goalStateDriver.syncFromCache(context.Background()))
+}
+
+// TestEngineStartFromCache tests starting the goal state engine from the
+// cache does not recover jobs from DB.
+func (suite *DriverTestSuite) TestEngineStartFromCache() {
+	suite.jobFactory.EXPECT().
+		GetAllJobs().
+		Return(map[string]cached.Job{})
+	suite.jobGoalStateEngine.EXPECT().Start()
+	suite.taskGoalStateEngine.EXPECT().Start()
+	suite.updateGoalStateEngine.EXPECT().Start()
+
+	suite.goalStateDriver.StartFromCache()
+	suite.Equal(int32(running), suite.goalStateDriver.runningState())
+}
+
+// TestPauseResume tests pausing and resuming the actions of all the
+// job types and of a single job type.
+func (suite *DriverTestSuite) TestPauseResume() {
+	suite.Empty(suite.goalStateDriver.PausedJobTypes())
+
+	suite.NoError(suite.goalStateDriver.Pause(context.Background()))
+	suite.Equal(
+		[]job.JobType{
+			job.JobType_BATCH,
+			job.JobType_SERVICE,
+			job.JobType_DAEMON,
+		},
+		suite.goalStateDriver.PausedJobTypes())
+	suite.True(suite.goalStateDriver.IsPaused(suite.jobID))
+
+	// the driver is not running, so nothing is enqueued on resume
+	suite.NoError(suite.goalStateDriver.Resume(
+		context.Background(), job.JobType_BATCH))
+	suite.Equal(
+		[]job.JobType{job.JobType_SERVICE, job.JobType_DAEMON},
+		suite.goalStateDriver.PausedJobTypes())
+
+	suite.jobFactory.EXPECT().
+		GetJob(suite.jobID).
+		Return(suite.cachedJob).
+		Times(2)
+	suite.cachedJob.EXPECT().
+		GetJobType().
+		Return(job.JobType_SERVICE)
+	suite.True(suite.goalStateDriver.IsPaused(suite.jobID))
+	suite.cachedJob.EXPECT().
+		GetJobType().
+		Return(job.JobType_BATCH)
+	suite.False(suite.goalStateDriver.IsPaused(suite.jobID))
+
+	suite.NoError(suite.goalStateDriver.Resume(context.Background()))
+	suite.Empty(suite.goalStateDriver.PausedJobTypes())
+	suite.False(suite.goalStateDriver.IsPaused(suite.jobID))
+}
+
+// TestResumeEnqueuesCachedJobs tests that the jobs of the resumed job
+// types are enqueued again along with their tasks and updates.
+func (suite *DriverTestSuite) TestResumeEnqueuesCachedJobs() {
+	batchJob := cachedmocks.NewMockJob(suite.ctrl)
+	batchJobID := &peloton.JobID{Value: uuid.NewRandom().String()}
+
+	suite.goalStateDriver.running = int32(running)
+	suite.NoError(suite.goalStateDriver.Pause(
+		context.Background(), job.JobType_SERVICE))
+
+	// resuming a job type which is not paused does nothing
+	suite.NoError(suite.goalStateDriver.Resume(
+		context.Background(), job.JobType_BATCH))
+
+	suite.jobFactory.EXPECT().
+		GetAllJobs().
+		Return(map[string]cached.Job{
+			suite.jobID.GetValue(): suite.cachedJob,
+			batchJobID.GetValue():  batchJob,
+		})
+	suite.cachedJob.EXPECT().
+		GetJobType().
+		Return(job.JobType_SERVICE)
+	batchJob.EXPECT().
+		GetJobType().
+		Return(job.JobType_BATCH)
+	suite.cachedJob.EXPECT().
+		GetAllTasks().
+		Return(map[uint32]cached.Task{
+			0: cachedmocks.NewMockTask(suite.ctrl),
+			1: cachedmocks.NewMockTask(suite.ctrl),
+		})
+	suite.cachedJob.EXPECT().
+		GetAllWorkflows().
+		Return(map[string]cached.Update{
+			suite.updateID.GetValue(): cachedmocks.NewMockUpdate(suite.ctrl),
+		})
+	suite.jobGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any())
+	suite.taskGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any()).
+		Times(2)
+	suite.updateGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any())
+
+	suite.NoError(suite.goalStateDriver.Resume(
+		context.Background(), job.JobType_SERVICE))
+	suite.Empty(suite.goalStateDriver.PausedJobTypes())
+}
+
+// shardedJobIDs returns a job hashed to a shard placed on the member m1
+// and a job hashed to a shard placed on the member m2, along with the
+// shards placed on m1.
+func (suite *DriverTestSuite) shardedJobIDs() (
+	*peloton.JobID, *peloton.JobID, []uint32) {
+	s := &shards{
+		ring: consistenthash.New(
+			[]string{"m1", "m2"},
+			suite.goalStateDriver.cfg.Sharding.VirtualNodes),
+		member:    "m1",
+		numShards: suite.goalStateDriver.cfg.Sharding.NumShards,
+	}
+	var ownedID, otherID *peloton.JobID
+	for ownedID == nil || otherID == nil {
+		id := &peloton.JobID{Value: uuid.NewRandom().String()}
+		if s.owns(s.shardOf(id.GetValue())) {
+			ownedID = id
+		} else {
+			otherID = id
+		}
+	}
+	return ownedID, otherID, s.owned()
+}
+
+// TestShards tests that only the jobs of the shards placed on the job
+// manager, whose lease is held, are enqueued and evaluated when the goal
+// state is sharded.
+func (suite *DriverTestSuite) TestShards() {
+	shardLeases := leadermocks.NewMockShardLeases(suite.ctrl)
+	suite.goalStateDriver.shardLeases = shardLeases
+	suite.goalStateDriver.cfg.Sharding.Enabled = true
+	ownedID, otherID, owned := suite.shardedJobIDs()
+	ownedShard := consistenthash.Shard(
+		ownedID.GetValue(), suite.goalStateDriver.cfg.Sharding.NumShards)
+
+	// nothing is owned until the members are known
+	suite.False(suite.goalStateDriver.isOwned(ownedID))
+	suite.True(suite.goalStateDriver.skipActions(ownedID))
+	suite.goalStateDriver.EnqueueJob(ownedID, time.Now())
+
+	// the leases of the shards placed on the job manager are held
+	shardLeases.EXPECT().Hold(owned)
+	suite.goalStateDriver.UpdateShards("m1", []string{"m2", "m1"})
+
+	// nothing is owned until the lease of its shard is acquired
+	shardLeases.EXPECT().Holds(ownedShard).Return(false)
+	suite.True(suite.goalStateDriver.skipActions(ownedID))
+
+	shardLeases.EXPECT().Holds(ownedShard).Return(true).AnyTimes()
+	suite.True(suite.goalStateDriver.isOwned(ownedID))
+	suite.False(suite.goalStateDriver.skipActions(ownedID))
+	suite.False(suite.goalStateDriver.isOwned(otherID))
+	suite.True(suite.goalStateDriver.skipActions(otherID))
+
+	suite.jobGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any())
+	suite.goalStateDriver.EnqueueJob(ownedID, time.Now())
+	suite.goalStateDriver.EnqueueJob(otherID, time.Now())
+	suite.goalStateDriver.EnqueueTask(otherID, 0, time.Now())
+	suite.goalStateDriver.EnqueueUpdate(otherID, suite.updateID, time.Now())
+
+	// the leases of the shards placed on another job manager are
+	// released once they are not owned anymore
+	shardLeases.EXPECT().
+		Hold(nil).
+		Do(func([]uint32) {
+			suite.False(suite.goalStateDriver.isOwned(ownedID))
+		})
+	suite.goalStateDriver.UpdateShards("m1", []string{"m2"})
+}
+
+// TestShardLeaseAcquired tests that the jobs of a shard are enqueued once
+// its lease is acquired, along with their tasks and updates.
+func (suite *DriverTestSuite) TestShardLeaseAcquired() {
+	shardLeases := leadermocks.NewMockShardLeases(suite.ctrl)
+	suite.goalStateDriver.shardLeases = shardLeases
+	suite.goalStateDriver.cfg.Sharding.Enabled = true
+	ownedID, otherID, owned := suite.shardedJobIDs()
+	ownedShard := consistenthash.Shard(
+		ownedID.GetValue(), suite.goalStateDriver.cfg.Sharding.NumShards)
+
+	shardLeases.EXPECT().Hold(owned)
+	suite.goalStateDriver.UpdateShards("m1", []string{"m1", "m2"})
+
+	// the driver is not running, so nothing is enqueued
+	suite.goalStateDriver.ShardLeaseAcquired(ownedShard)
+
+	suite.goalStateDriver.running = int32(running)
+	otherJob := cachedmocks.NewMockJob(suite.ctrl)
+	suite.jobFactory.EXPECT().
+		GetAllJobs().
+		Return(map[string]cached.Job{
+			ownedID.GetValue(): suite.cachedJob,
+			otherID.GetValue(): otherJob,
+		})
+	suite.cachedJob.EXPECT().
+		GetAllTasks().
+		Return(map[uint32]cached.Task{
+			0: cachedmocks.NewMockTask(suite.ctrl),
+		})
+	suite.cachedJob.EXPECT().
+		GetAllWorkflows().
+		Return(map[string]cached.Update{
+			suite.updateID.GetValue(): cachedmocks.NewMockUpdate(suite.ctrl),
+		})
+	shardLeases.EXPECT().Holds(ownedShard).Return(true).Times(3)
+	suite.jobGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any())
+	suite.taskGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any())
+	suite.updateGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any())
+
+	suite.goalStateDriver.ShardLeaseAcquired(ownedShard)
+}
+
+// TestPauseResumePersisted tests that pausing and resuming a job type
+// is persisted, and that the job type is not paused or resumed when
+// persisting fails.
+func (suite *DriverTestSuite) TestPauseResumePersisted() {
+	pauseOps := objectmocks.NewMockGoalStatePauseOps(suite.ctrl)
+	suite.goalStateDriver.pauseOps = pauseOps
+
+	pauseOps.EXPECT().
+		Set(gomock.Any(), job.JobType_SERVICE, true).
+		Return(errors.New("test error"))
+	suite.Error(suite.goalStateDriver.Pause(
+		context.Background(), job.JobType_SERVICE))
+	suite.Empty(suite.goalStateDriver.PausedJobTypes())
+
+	pauseOps.EXPECT().
+		Set(gomock.Any(), job.JobType_SERVICE, true).
+		Return(nil)
+	suite.NoError(suite.goalStateDriver.Pause(
+		context.Background(), job.JobType_SERVICE))
+	suite.Equal(
+		[]job.JobType{job.JobType_SERVICE},
+		suite.goalStateDriver.PausedJobTypes())
+
+	pauseOps.EXPECT().
+		Set(gomock.Any(), job.JobType_SERVICE, false).
+		Return(errors.New("test error"))
+	suite.Error(suite.goalStateDriver.Resume(
+		context.Background(), job.JobType_SERVICE))
+	suite.Equal(
+		[]job.JobType{job.JobType_SERVICE},
+		suite.goalStateDriver.PausedJobTypes())
+
+	pauseOps.EXPECT().
+		Set(gomock.Any(), job.JobType_SERVICE, false).
+		Return(nil)
+	suite.NoError(suite.goalStateDriver.Resume(
+		context.Background(), job.JobType_SERVICE))
+	suite.Empty(suite.goalStateDriver.PausedJobTypes())
+}
+
+// TestLoadPausedJobTypes tests that the job types paused by the
+// previous leader are loaded from DB.
+func (suite *DriverTestSuite) TestLoadPausedJobTypes() {
+	pauseOps := objectmocks.NewMockGoalStatePauseOps(suite.ctrl)
+	suite.goalStateDriver.pauseOps = pauseOps
+	suite.goalStateDriver.pausedJobTypes = map[job.JobType]bool{
+		job.JobType_BATCH: true,
+	}
+
+	pauseOps.EXPECT().
+		Get(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, jobType job.JobType) (bool, error) {
+			return jobType == job.JobType_SERVICE, nil
+		}).
+		Times(len(job.JobType_name))
+	suite.NoError(suite.goalStateDriver.loadPausedJobTypes(
+		context.Background()))
+	suite.Equal(
+		[]job.JobType{job.JobType_SERVICE},
+		suite.goalStateDriver.PausedJobTypes())
+
+	pauseOps.EXPECT().
+		Get(gomock.Any(), gomock.Any()).
+		Return(false, errors.New("test error"))
+	suite.Error(suite.goalStateDriver.loadPausedJobTypes(
+		context.Background()))
+	suite.Equal(
+		[]job.JobType{job.JobType_SERVICE},
+		suite.goalStateDriver.PausedJobTypes())
+}
+
+// TestEntities tests listing the entities of the goal state engines
+// for all the jobs and for a single job.
+func (suite *DriverTestSuite) TestEntities() {
+	otherJobID := &peloton.JobID{Value: uuid.NewRandom().String()}
+	taskID := fmt.Sprintf("%s-%d", suite.jobID.GetValue(), suite.instanceID)
+	otherTaskID := fmt.Sprintf("%s-%d", otherJobID.GetValue(), suite.instanceID)
+
+	suite.jobGoalStateEngine.EXPECT().
+		Entities().
+		Return([]goalstate.EntityInfo{
+			{ID: suite.jobID.GetValue(), Scheduled: true},
+			{ID: otherJobID.GetValue(), Retries: 2},
+		}).
+		Times(2)
+	suite.taskGoalStateEngine.EXPECT().
+		Entities().
+		Return([]goalstate.EntityInfo{
+			{ID: taskID},
+			{ID: otherTaskID},
+		}).
+		Times(2)
+	suite.updateGoalStateEngine.EXPECT().
+		Entities().
+		Return(nil).
+		Times(2)
+
+	entities := suite.goalStateDriver.Entities(nil)
+	suite.Len(entities.Jobs, 2)
+	suite.Len(entities.Tasks, 2)
+	suite.Empty(entities.Updates)
+
+	entities = suite.goalStateDriver.Entities(otherJobID)
+	suite.Len(entities.Jobs, 1)
+	suite.Equal(uint32(2), entities.Jobs[0].Retries)
+	suite.Len(entities.Tasks, 1)
+	suite.Equal(otherTaskID, entities.Tasks[0].ID)
+	suite.Empty(entities.Updates)
+}
+
+// TestEvaluate tests evaluating the goal state of a job, its tasks and
+// its updates without running any action.
+func (suite *DriverTestSuite) TestEvaluate() {
+	cachedTask := cachedmocks.NewMockTask(suite.ctrl)
+	cachedUpdate := cachedmocks.NewMockUpdate(suite.ctrl)
+
+	// the job is not added to the cache if it is missing
+	suite.jobFactory.EXPECT().
+		GetJob(suite.jobID).
+		Return(nil)
+	_, err := suite.goalStateDriver.Evaluate(suite.jobID, nil)
+	suite.True(yarpcerrors.IsNotFound(err))
+
+	suite.jobFactory.EXPECT().
+		GetJob(suite.jobID).
+		Return(suite.cachedJob).
+		Times(2)
+	suite.cachedJob.EXPECT().
+		CurrentState().
+		Return(cached.JobStateVector{State: job.JobState_RUNNING}).
+		Times(2)
+	suite.cachedJob.EXPECT().
+		GoalState().
+		Return(cached.JobStateVector{State: job.JobState_RUNNING}).
+		Times(2)
+	suite.cachedJob.EXPECT().
+		GetTask(uint32(0)).
+		Return(cachedTask).
+		Times(2)
+	suite.cachedJob.EXPECT().
+		GetTask(uint32(1)).
+		Return(nil).
+		Times(2)
+	cachedTask.EXPECT().
+		CurrentState().
+		Return(cached.TaskStateVector{State: task.TaskState_INITIALIZED}).
+		Times(2)
+	cachedTask.EXPECT().
+		GoalState().
+		Return(cached.TaskStateVector{State: task.TaskState_RUNNING}).
+		Times(2)
+	suite.cachedJob.EXPECT().
+		GetAllWorkflows().
+		Return(map[string]cached.Update{
+			suite.updateID.GetValue(): cachedUpdate,
+		}).
+		Times(2)
+	cachedUpdate.EXPECT().
+		GetState().
+		Return(&cached.UpdateStateVector{
+			State:     update.State_ROLLING_FORWARD,
+			Instances: []uint32{0},
+		}).
+		Times(2)
+	cachedUpdate.EXPECT().
+		GetGoalState().
+		Return(&cached.UpdateStateVector{
+			JobVersion: 2,
+			Instances:  []uint32{0, 1},
+		}).
+		Times(2)
+
+	evaluations, err := suite.goalStateDriver.Evaluate(
+		suite.jobID, []uint32{0, 1})
+	suite.NoError(err)
+
+	suite.Equal(suite.jobID.GetValue(), evaluations.Job.ID)
+	suite.Equal(string(NoJobAction), evaluations.Job.Action)
+	suite.Equal([]string{
+		"EnqueueJobUpdate",
+		string(RuntimeUpdateAction),
+		string(EvaluateSLAAction),
+		string(DeleteFromActiveJobsAction),
+	}, evaluations.Job.Actions)
+	suite.Equal("RUNNING", evaluations.Job.State["state"])
+	suite.Empty(evaluations.Job.SkippedReason)
+
+	suite.Len(evaluations.Tasks, 2)
+	suite.Equal(string(StartAction), evaluations.Tasks[0].Action)
+	suite.Equal([]string{string(StartAction)}, evaluations.Tasks[0].Actions)
+	suite.Equal("INITIALIZED", evaluations.Tasks[0].State["state"])
+	suite.Equal("RUNNING", evaluations.Tasks[0].GoalState["state"])
+	// the task missing from the cache would be reloaded
+	suite.Equal(string(ReloadTaskRuntime), evaluations.Tasks[1].Action)
+
+	suite.Len(evaluations.Updates, 1)
+	suite.Equal(suite.updateID.GetValue(), evaluations.Updates[0].ID)
+	suite.Equal([]string{
+		string(CheckForAbortAction),
+		string(RunUpdateAction),
+	}, evaluations.Updates[0].Actions)
+	suite.Equal("2", evaluations.Updates[0].GoalState["job_version"])
+
+	// the actions of a paused job would be skipped
+	suite.NoError(suite.goalStateDriver.Pause(
+		context.Background(), job.JobType_SERVICE))
+	suite.cachedJob.EXPECT().
+		GetJobType().
+		Return(job.JobType_SERVICE)
+
+	evaluations, err = suite.goalStateDriver.Evaluate(
+		suite.jobID, []uint32{0, 1})
+	suite.NoError(err)
+	suite.Equal(SkippedPaused, evaluations.Job.SkippedReason)
+	suite.Equal(SkippedPaused, evaluations.Tasks[0].SkippedReason)
+	suite.Equal(SkippedPaused, evaluations.Updates[0].SkippedReason)
+}
+
+// TestRetryPolicy tests the retry policy of the goal state engines uses
+// the policy configured for the job type of the entity.
+func (suite *DriverTestSuite) TestRetryPolicy() {
+	cfg := &Config{
+		FailureRetryDelay: time.Second,
+		MaxRetryDelay:     time.Minute,
+	}
+	suite.Nil(newRetryPolicy(suite.goalStateDriver, cfg))
+
+	cfg.RetryPolicies = map[string]*RetryPolicyConfig{
+		"service": {
+			Type:         _exponentialRetryPolicy,
+			InitialDelay: 2 * time.Second,
+			Actions: map[string]*RetryPolicyConfig{
+				"start_task": {
+					Type: _linearRetryPolicy,
+				},
+			},
+		},
+		"unknown": {},
+	}
+	policy := newRetryPolicy(suite.goalStateDriver, cfg)
+	suite.NotNil(policy)
+
+	serviceJobID := &peloton.JobID{Value: uuid.NewRandom().String()}
+	serviceEntity := NewTaskEntity(serviceJobID, 0, suite.goalStateDriver)
+	suite.jobFactory.EXPECT().
+		GetJob(serviceJobID).
+		Return(suite.cachedJob).
+		Times(3)
+	suite.cachedJob.EXPECT().
+		GetJobType().
+		Return(job.JobType_SERVICE).
+		Times(3)
+	suite.Equal(2*time.Second, policy.Delay(serviceEntity, "", 1))
+	suite.Equal(8*time.Second, policy.Delay(serviceEntity, "", 3))
+	suite.Equal(6*time.Second, policy.Delay(serviceEntity, "start_task", 3))
+
+	batchEntity := NewJobEntity(suite.jobID, suite.goalStateDriver)
+	suite.jobFactory.EXPECT().
+		GetJob(suite.jobID).
+		Return(nil)
+	suite.Equal(3*time.Second, policy.Delay(batchEntity, "", 3))
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package goalstate
+
+import (
+	"strconv"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/uber/peloton/pkg/common/goalstate"
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const (
+	// SkippedPaused is the reason for not running the actions of an
+	// entity whose job type is paused.
+	SkippedPaused = "paused"
+	// SkippedNotOwned is the reason for not running the actions of an
+	// entity whose job is hashed to another job manager, or whose shard
+	// lease is not held.
+	SkippedNotOwned = "not_owned"
+)
+
+// Evaluation is the outcome of evaluating the goal state of a job, a task
+// or an update, without running any of its actions.
+type Evaluation struct {
+	// ID is the identifier of the entity in the goal state engine.
+	ID string
+	// State and GoalState are the inputs considered by the evaluation,
+	// keyed by name.
+	State     map[string]string
+	GoalState map[string]string
+	// Action is the action suggested for the state and the goal state.
+	Action string
+	// Actions are the names of all the actions which would be run,
+	// in order.
+	Actions []string
+	// SkippedReason is the reason why the actions would not be run,
+	// empty if they would be.
+	SkippedReason string
+}
+
+// Evaluations are the evaluations of a job, along with its tasks and
+// its updates.
+type Evaluations struct {
+	Job     *Evaluation
+	Tasks   []*Evaluation
+	Updates []*Evaluation
+}
+
+func (d *driver) Evaluate(
+	jobID *peloton.JobID,
+	instanceIDs []uint32,
+) (*Evaluations, error) {
+	// Unlike the goal state engine, do not add the job to the cache
+	// if it is missing, the evaluation must not change anything.
+	cachedJob := d.jobFactory.GetJob(jobID)
+	if cachedJob == nil {
+		return nil, yarpcerrors.NotFoundErrorf(
+			"job %s not found in cache", jobID.GetValue())
+	}
+
+	skippedReason := d.skippedReason(jobID, cachedJob)
+
+	jobEntity := &jobEntity{id: jobID, driver: d}
+	evaluations := &Evaluations{
+		Job: evaluateJob(jobEntity, cachedJob, skippedReason),
+	}
+
+	for _, instanceID := range instanceIDs {
+		taskEntity := &taskEntity{
+			jobID:      jobID,
+			instanceID: instanceID,
+			driver:     d,
+		}
+		evaluations.Tasks = append(evaluations.Tasks,
+			evaluateTask(taskEntity, cachedJob.GetTask(instanceID), skippedReason))
+	}
+
+	for updateID, cachedUpdate := range cachedJob.GetAllWorkflows() {
+		updateEntity := &updateEntity{
+			jobID:  jobID,
+			id:     &peloton.UpdateID{Value: updateID},
+			driver: d,
+		}
+		evaluations.Updates = append(evaluations.Updates,
+			evaluateUpdate(updateEntity, cachedUpdate, skippedReason))
+	}
+
+	return evaluations, nil
+}
+
+// skippedReason returns why the entities of the given job would not run
+// any action, or an empty string if they would. Unlike skipActions, it
+// does not count the skipped entities.
+func (d *driver) skippedReason(jobID *peloton.JobID, cachedJob cached.Job) string {
+	d.pauseLock.RLock()
+	paused := len(d.pausedJobTypes) > 0 &&
+		(len(d.pausedJobTypes) == len(job.JobType_name) ||
+			d.pausedJobTypes[cachedJob.GetJobType()])
+	d.pauseLock.RUnlock()
+
+	if paused {
+		return SkippedPaused
+	}
+	if !d.isOwned(jobID) {
+		return SkippedNotOwned
+	}
+	return ""
+}
+
+// evaluateJob evaluates the goal state of a job.
+func evaluateJob(
+	j *jobEntity,
+	cachedJob cached.Job,
+	skippedReason string,
+) *Evaluation {
+	state := cachedJob.CurrentState()
+	goalState := cachedJob.GoalState()
+	actionStr, actions := j.actions(state, goalState)
+
+	return newEvaluation(
+		j.GetID(),
+		jobStateInputs(state),
+		jobStateInputs(goalState),
+		string(actionStr),
+		actions,
+		skippedReason,
+	)
+}
+
+// evaluateTask evaluates the goal state of a task, which is nil if
+// the task is not in the cache.
+func evaluateTask(
+	t *taskEntity,
+	cachedTask cached.Task,
+	skippedReason string,
+) *Evaluation {
+	// a task missing from the cache is reloaded, as in GetState
+	state := cached.TaskStateVector{State: task.TaskState_UNKNOWN}
+	goalState := cached.TaskStateVector{State: task.TaskState_UNKNOWN}
+	if cachedTask != nil {
+		state = cachedTask.CurrentState()
+		goalState = cachedTask.GoalState()
+	}
+	actionStr, actions := t.actions(state, goalState)
+
+	return newEvaluation(
+		t.GetID(),
+		taskStateInputs(state),
+		taskStateInputs(goalState),
+		string(actionStr),
+		actions,
+		skippedReason,
+	)
+}
+
+// evaluateUpdate evaluates the goal state of an update.
+func evaluateUpdate(
+	u *updateEntity,
+	cachedUpdate cached.Update,
+	skippedReason string,
+) *Evaluation {
+	state := cachedUpdate.GetState()
+	goalState := cachedUpdate.GetGoalState()
+	actionStr, actions := u.actions(state, goalState)
+
+	// the update entities are identified by their job in the goal state
+	// engine, use the update identifier to tell the updates apart
+	return newEvaluation(
+		u.id.GetValue(),
+		updateStateInputs(state),
+		updateStateInputs(goalState),
+		string(actionStr),
+		actions,
+		skippedReason,
+	)
+}
+
+func newEvaluation(
+	id string,
+	state map[string]string,
+	goalState map[string]string,
+	action string,
+	actions []goalstate.Action,
+	skippedReason string,
+) *Evaluation {
+	evaluation := &Evaluation{
+		ID:            id,
+		State:         state,
+		GoalState:     goalState,
+		Action:        action,
+		SkippedReason: skippedReason,
+	}
+	for _, action := range actions {
+		evaluation.Actions = append(evaluation.Actions, action.Name)
+	}
+	return evaluation
+}
+
+func jobStateInputs(state cached.JobStateVector) map[string]string {
+	return map[string]string{
+		"state":         state.State.String(),
+		"state_version": strconv.FormatUint(state.StateVersion, 10),
+	}
+}
+
+func taskStateInputs(state cached.TaskStateVector) map[string]string {
+	return map[string]string{
+		"state":          state.State.String(),
+		"config_version": strconv.FormatUint(state.ConfigVersion, 10),
+		"mesos_task_id":  state.MesosTaskID.GetValue(),
+	}
+}
+
+func updateStateInputs(state *cached.UpdateStateVector) map[string]string {
+	return map[string]string{
+		"state":       state.State.String(),
+		"job_version": strconv.FormatUint(state.JobVersion, 10),
+		"instances":   strconv.Itoa(len(state.Instances)),
+	}
+}
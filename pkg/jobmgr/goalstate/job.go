@@ -159,9 +159,33 @@ func (j *jobEntity) GetActionList(
 ) (context.Context, context.CancelFunc, []goalstate.Action) {
 	var actions []goalstate.Action
 
+	if j.driver.skipActions(j.id) {
+		return context.Background(), nil, actions
+	}
+
 	jobState := state.(cached.JobStateVector)
 	jobGoalState := goalState.(cached.JobStateVector)
 
+	actionStr, actions := j.actions(jobState, jobGoalState)
+	log.WithField("job_id", j.id.GetValue()).
+		WithField("current_state", jobState.State.String()).
+		WithField("goal_state", jobGoalState.State.String()).
+		WithField("current_state_version", jobState.StateVersion).
+		WithField("goal_state_version", jobGoalState.StateVersion).
+		WithField("job_action", actionStr).
+		Info("running job action")
+
+	return context.Background(), nil, actions
+}
+
+// actions returns the actions to run to bring the job from its state to
+// its goal state, along with the name of the suggested job action.
+func (j *jobEntity) actions(
+	jobState cached.JobStateVector,
+	jobGoalState cached.JobStateVector,
+) (JobAction, []goalstate.Action) {
+	var actions []goalstate.Action
+
 	if jobState.State == job.JobState_UNKNOWN ||
 		jobGoalState.State == job.JobState_UNKNOWN {
 		// State or goal state could not be loaded from DB, so
@@ -170,20 +194,12 @@ func (j *jobEntity) GetActionList(
 			Name:    string(ReloadRuntimeAction),
 			Execute: JobReloadRuntime,
 		})
-		return context.Background(), nil, actions
+		return ReloadRuntimeAction, actions
 	}
 
 	actionStr := j.suggestJobAction(jobState, jobGoalState)
 	action := _jobActionsMaps[actionStr]
 
-	log.WithField("job_id", j.id.GetValue()).
-		WithField("current_state", jobState.State.String()).
-		WithField("goal_state", jobGoalState.State.String()).
-		WithField("current_state_version", jobState.StateVersion).
-		WithField("goal_state_version", jobGoalState.StateVersion).
-		WithField("job_action", actionStr).
-		Info("running job action")
-
 	if action != nil {
 		// nil action is returned for noop
 		actions = append(actions, goalstate.Action{
@@ -218,7 +234,7 @@ func (j *jobEntity) GetActionList(
 
 	}
 
-	return context.Background(), nil, actions
+	return actionStr, actions
 }
 
 // suggestJobAction provides the job action for a given state and goal state
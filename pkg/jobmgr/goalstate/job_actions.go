@@ -160,7 +160,7 @@ func JobRecover(ctx context.Context, entity goalstate.Entity) error {
 		ctx, &job.RuntimeInfo{State: jobState}); err != nil {
 		return err
 	}
-	goalStateDriver.EnqueueJob(jobEnt.id, time.Now())
+	goalStateDriver.EnqueueJob(jobEnt.id, goalStateDriver.now())
 	return nil
 }
 
@@ -223,7 +223,7 @@ func JobStart(
 	}
 
 	for i := range runtimeDiff {
-		goalStateDriver.EnqueueTask(cachedJob.ID(), i, time.Now())
+		goalStateDriver.EnqueueTask(cachedJob.ID(), i, goalStateDriver.now())
 	}
 
 	var jobRuntime *job.RuntimeInfo
@@ -249,7 +249,7 @@ func JobStart(
 			return errors.Wrap(err, "fail to update job runtime")
 		}
 
-		goalStateDriver.EnqueueJob(cachedJob.ID(), time.Now())
+		goalStateDriver.EnqueueJob(cachedJob.ID(), goalStateDriver.now())
 		return nil
 	}
 }
@@ -309,7 +309,7 @@ func JobReloadRuntime(
 		return err
 	}
 
-	goalStateDriver.EnqueueJob(jobEnt.id, time.Now())
+	goalStateDriver.EnqueueJob(jobEnt.id, goalStateDriver.now())
 
 	return nil
 }
@@ -352,7 +352,7 @@ func EnqueueJobUpdate(
 	}
 
 	if !cached.IsUpdateStateTerminal(updateInfo.GetState()) {
-		goalStateDriver.EnqueueUpdate(jobEnt.id, jobRuntime.GetUpdateID(), time.Now())
+		goalStateDriver.EnqueueUpdate(jobEnt.id, jobRuntime.GetUpdateID(), goalStateDriver.now())
 	}
 	return nil
 }
@@ -122,7 +122,9 @@ func JobCreateTasks(ctx context.Context, entity goalstate.Entity) error {
 }
 
 // sendTasksToResMgr is a utility function to enqueue tasks in
-// a single batch to resource manager.
+// a single batch to resource manager. Only the tasks within the limit of
+// the tasks of the job launched concurrently are sent, the others are
+// started later by the task goal state engine.
 func sendTasksToResMgr(
 	ctx context.Context,
 	jobID *peloton.JobID,
@@ -130,6 +132,7 @@ func sendTasksToResMgr(
 	jobConfig *job.JobConfig,
 	goalStateDriver *driver) error {
 
+	tasks = goalStateDriver.limitTasksToStart(jobID, jobConfig, tasks)
 	if len(tasks) == 0 {
 		return nil
 	}
@@ -140,17 +143,27 @@ func sendTasksToResMgr(
 		tasks,
 		jobConfig,
 		goalStateDriver.resmgrClient)
-	if err != nil {
+	// the rejected tasks are failed, the other tasks of their gangs are
+	// started later by the task goal state engine
+	rejected, _ := err.(*jobmgr_task.TaskRejectedError)
+	if err != nil && rejected == nil {
 		log.WithError(err).
 			WithField("job_id", jobID.GetValue()).
 			Error("failed to enqueue tasks to rm")
 		return err
 	}
 
-	// Move all task states to pending
+	// Move all enqueued task states to pending
 	runtimeDiffs := make(map[uint32]jobmgrcommon.RuntimeDiff)
 	for _, tt := range tasks {
 		instID := tt.GetInstanceId()
+		if rejected != nil && rejected.Failed[instID] {
+			if message, ok := rejected.Rejected[instID]; ok {
+				runtimeDiffs[instID] = taskRejectedRuntimeDiff(
+					tt.GetRuntime(), message, goalStateDriver.now())
+			}
+			continue
+		}
 		runtimeDiff := jobmgrcommon.RuntimeDiff{
 			jobmgrcommon.StateField:   task.TaskState_PENDING,
 			jobmgrcommon.MessageField: "Task sent for placement",
@@ -171,6 +184,12 @@ func sendTasksToResMgr(
 			Error("failed to update task runtime to pending")
 		return err
 	}
+
+	if rejected != nil {
+		for instID := range rejected.Failed {
+			goalStateDriver.EnqueueTask(jobID, instID, goalStateDriver.now())
+		}
+	}
 	return nil
 }
 
@@ -184,20 +203,20 @@ func recoverTasks(
 	var tasks []*task.TaskInfo
 
 	cachedJob := goalStateDriver.jobFactory.AddJob(jobID)
-	maxRunningInstances := jobConfig.GetSLA().GetMaximumRunningInstances()
+	maxStartingInstances := getMaxStartingInstances(jobConfig.GetSLA())
 	taskRuntimeInfoMap := make(map[uint32]*task.RuntimeInfo)
 	for i := uint32(0); i < jobConfig.InstanceCount; i++ {
 		if _, ok := taskInfos[i]; ok {
 			if taskInfos[i].GetRuntime().GetState() == task.TaskState_INITIALIZED {
 				// Task exists, just send to resource manager
-				if maxRunningInstances > 0 && taskInfos[i].GetRuntime().GetState() == task.TaskState_INITIALIZED {
+				if maxStartingInstances > 0 && taskInfos[i].GetRuntime().GetState() == task.TaskState_INITIALIZED {
 					// add task to cache if not already present
 					if cachedJob.GetTask(i) == nil {
 						cachedJob.ReplaceTasks(map[uint32]*task.RuntimeInfo{i: taskInfos[i].GetRuntime()}, false)
 					}
 					// run the runtime updater to start instances
-					EnqueueJobWithDefaultDelay(
-						jobID, goalStateDriver, cachedJob)
+					goalStateDriver.EnqueueJobWithDefaultDelay(
+						jobID, cachedJob.GetJobType())
 				} else {
 					runtimes := make(map[uint32]*task.RuntimeInfo)
 					runtimes[i] = taskInfos[i].GetRuntime()
@@ -225,7 +244,7 @@ func recoverTasks(
 		runtime := jobmgr_task.CreateInitializingTask(jobID, i, jobConfig)
 		taskRuntimeInfoMap[i] = runtime
 
-		if maxRunningInstances == 0 {
+		if maxStartingInstances == 0 {
 			taskInfo := &task.TaskInfo{
 				JobId:      jobID,
 				InstanceId: i,
@@ -243,9 +262,10 @@ func recoverTasks(
 		return err
 	}
 
-	if maxRunningInstances > 0 {
+	if maxStartingInstances > 0 {
 		// run the runtime updater to start instances
-		EnqueueJobWithDefaultDelay(jobID, goalStateDriver, cachedJob)
+		goalStateDriver.EnqueueJobWithDefaultDelay(
+			jobID, cachedJob.GetJobType())
 	}
 
 	return sendTasksToResMgr(ctx, jobID, tasks, jobConfig, goalStateDriver)
@@ -287,12 +307,12 @@ func createAndEnqueueTasks(
 	}
 	goalStateDriver.mtx.taskMetrics.TaskCreate.Inc(nTasks)
 
-	maxRunningInstances := jobConfig.GetSLA().GetMaximumRunningInstances()
+	maxStartingInstances := getMaxStartingInstances(jobConfig.GetSLA())
 
-	if maxRunningInstances > 0 {
+	if maxStartingInstances > 0 {
 		var uTasks []*task.TaskInfo
-		for i := uint32(0); i < maxRunningInstances; i++ {
-			// Only send maxRunningInstances number of tasks to resource manager
+		for i := uint32(0); i < maxStartingInstances && i < instances; i++ {
+			// Only send maxStartingInstances number of tasks to resource manager
 			uTasks = append(uTasks, tasks[i])
 		}
 		return sendTasksToResMgr(ctx, jobID, uTasks, jobConfig, goalStateDriver)
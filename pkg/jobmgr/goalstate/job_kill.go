@@ -73,7 +73,7 @@ func JobKill(ctx context.Context, entity goalstate.Entity) error {
 	// terminated. Otherwise, those tasks would not be enqueued
 	// into goal state engine in JobKill retry.
 	for instanceID := range runtimeDiffNonTerminatedTasks {
-		goalStateDriver.EnqueueTask(jobID, instanceID, time.Now())
+		goalStateDriver.EnqueueTask(jobID, instanceID, goalStateDriver.now())
 	}
 
 	if err != nil {
@@ -86,7 +86,8 @@ func JobKill(ctx context.Context, entity goalstate.Entity) error {
 	// Only enqueue the job into goal state if any of the
 	// non terminated tasks need to be killed.
 	if len(runtimeDiffNonTerminatedTasks) > 0 {
-		EnqueueJobWithDefaultDelay(jobID, goalStateDriver, cachedJob)
+		goalStateDriver.EnqueueJobWithDefaultDelay(
+			jobID, cachedJob.GetJobType())
 	}
 
 	jobState := calculateJobState(
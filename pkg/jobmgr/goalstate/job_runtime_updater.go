@@ -17,6 +17,7 @@ package goalstate
 import (
 	"context"
 	"fmt"
+	"math"
 	"reflect"
 	"time"
 
@@ -57,6 +58,7 @@ var taskStatesAfterStart = []task.TaskState{
 	task.TaskState_RUNNING,
 	task.TaskState_SUCCEEDED,
 	task.TaskState_FAILED,
+	task.TaskState_FAILED_HOLD,
 	task.TaskState_LOST,
 	task.TaskState_PREEMPTING,
 	task.TaskState_KILLING,
@@ -81,6 +83,18 @@ var taskStatesScheduled = []task.TaskState{
 	task.TaskState_KILLING,
 }
 
+// taskStatesLaunching is the subset of taskStatesScheduled which indicate
+// a task has been sent to resource manager, but is not running yet.
+var taskStatesLaunching = []task.TaskState{
+	task.TaskState_PENDING,
+	task.TaskState_READY,
+	task.TaskState_PLACING,
+	task.TaskState_PLACED,
+	task.TaskState_LAUNCHING,
+	task.TaskState_LAUNCHED,
+	task.TaskState_STARTING,
+}
+
 var allTaskStates = []task.TaskState{
 	task.TaskState_UNKNOWN,
 	task.TaskState_INITIALIZED,
@@ -99,6 +113,7 @@ var allTaskStates = []task.TaskState{
 	task.TaskState_KILLING,
 	task.TaskState_KILLED,
 	task.TaskState_DELETED,
+	task.TaskState_FAILED_HOLD,
 }
 
 // formatTime converts a Unix timestamp to a string format of the
@@ -113,8 +128,23 @@ func formatTime(timestamp float64, layout string) string {
 	return time.Unix(seconds, nanoSec).UTC().Format(layout)
 }
 
-// JobEvaluateMaxRunningInstancesSLA evaluates the maximum running instances job SLA
-// and determines instances to start if any.
+// getMaxStartingInstances returns the maximum number of instances of a job
+// which can be sent to resource manager at once, as limited by the maximum
+// running and maximum launching instances of the job SLA. It returns 0 if
+// the number of instances is not limited.
+func getMaxStartingInstances(sla *job.SlaConfig) uint32 {
+	maxRunningInstances := sla.GetMaximumRunningInstances()
+	maxLaunchingInstances := sla.GetMaximumLaunchingInstances()
+	if maxRunningInstances == 0 ||
+		(maxLaunchingInstances > 0 && maxLaunchingInstances < maxRunningInstances) {
+		return maxLaunchingInstances
+	}
+	return maxRunningInstances
+}
+
+// JobEvaluateMaxRunningInstancesSLA evaluates the maximum running and
+// maximum launching instances job SLA and determines instances to start
+// if any.
 func JobEvaluateMaxRunningInstancesSLA(ctx context.Context, entity goalstate.Entity) error {
 	id := entity.GetID()
 	jobID := &peloton.JobID{Value: id}
@@ -128,11 +158,13 @@ func JobEvaluateMaxRunningInstancesSLA(ctx context.Context, entity goalstate.Ent
 		return err
 	}
 
-	// Save a read to DB if maxRunningInstances is 0
-	maxRunningInstances := cachedConfig.GetSLA().GetMaximumRunningInstances()
-	if maxRunningInstances == 0 {
+	// Save a read to DB if neither maxRunningInstances nor
+	// maxLaunchingInstances is set
+	if getMaxStartingInstances(cachedConfig.GetSLA()) == 0 {
 		return nil
 	}
+	maxRunningInstances := cachedConfig.GetSLA().GetMaximumRunningInstances()
+	maxLaunchingInstances := cachedConfig.GetSLA().GetMaximumLaunchingInstances()
 
 	jobConfig, _, err := goalStateDriver.jobStore.GetJobConfig(ctx, jobID.GetValue())
 	if err != nil {
@@ -160,8 +192,13 @@ func JobEvaluateMaxRunningInstancesSLA(ctx context.Context, entity goalstate.Ent
 	for _, state := range taskStatesScheduled {
 		currentScheduledInstances += stateCounts[state.String()]
 	}
+	currentLaunchingInstances := uint32(0)
+	for _, state := range taskStatesLaunching {
+		currentLaunchingInstances += stateCounts[state.String()]
+	}
 
-	if currentScheduledInstances >= maxRunningInstances {
+	tasksToStart := uint32(math.MaxUint32)
+	if maxRunningInstances > 0 && currentScheduledInstances >= maxRunningInstances {
 		if currentScheduledInstances > maxRunningInstances {
 			log.WithFields(log.Fields{
 				"current_scheduled_tasks": currentScheduledInstances,
@@ -175,7 +212,21 @@ func JobEvaluateMaxRunningInstancesSLA(ctx context.Context, entity goalstate.Ent
 			Debug("no instances to start")
 		return nil
 	}
-	tasksToStart := maxRunningInstances - currentScheduledInstances
+	if maxRunningInstances > 0 {
+		tasksToStart = maxRunningInstances - currentScheduledInstances
+	}
+
+	if maxLaunchingInstances > 0 {
+		if currentLaunchingInstances >= maxLaunchingInstances {
+			log.WithField("current_launching_tasks", currentLaunchingInstances).
+				WithField("job_id", id).
+				Debug("no instances to start, max launching instances reached")
+			return nil
+		}
+		if maxLaunchingInstances-currentLaunchingInstances < tasksToStart {
+			tasksToStart = maxLaunchingInstances - currentLaunchingInstances
+		}
+	}
 
 	initializedTasks, err := goalStateDriver.taskStore.GetTaskIDsForJobAndState(ctx, jobID, task.TaskState_INITIALIZED.String())
 	if err != nil {
@@ -188,7 +239,9 @@ func JobEvaluateMaxRunningInstancesSLA(ctx context.Context, entity goalstate.Ent
 	log.WithFields(log.Fields{
 		"job_id":                      id,
 		"max_running_instances":       maxRunningInstances,
+		"max_launching_instances":     maxLaunchingInstances,
 		"current_scheduled_instances": currentScheduledInstances,
+		"current_launching_instances": currentLaunchingInstances,
 		"length_initialized_tasks":    len(initializedTasks),
 		"tasks_to_start":              tasksToStart,
 	}).Debug("find tasks to start")
@@ -290,38 +343,77 @@ func (d *jobStateDeterminer) getState(
 		return job.JobState_PENDING, nil
 	}
 
+	if state, ok := d.getTerminalState(); ok {
+		// the tasks of a suspended job are killed by the suspend, and are
+		// started again when the job is resumed, unless the job is stopped
+		if state == job.JobState_KILLED &&
+			jobRuntime.GetSuspended() &&
+			jobRuntime.GetGoalState() != job.JobState_KILLED &&
+			jobRuntime.GetGoalState() != job.JobState_DELETED {
+			return job.JobState_PENDING, nil
+		}
+		return state, nil
+	}
+
+	if jobRuntime.State == job.JobState_KILLING {
+		// jobState is set to KILLING in JobKill to avoid materialized view delay,
+		// should keep the state to be KILLING unless job transits to terminal state
+		return job.JobState_KILLING, nil
+	}
+
+	if d.stateCounts[task.TaskState_RUNNING.String()] > 0 {
+		return job.JobState_RUNNING, nil
+	}
+	return job.JobState_PENDING, nil
+
+}
+
+// getTerminalState returns the terminal state of the job, and false if the
+// tasks of the job have not all completed.
+func (d *jobStateDeterminer) getTerminalState() (job.JobState, bool) {
+	totalInstanceCount := d.config.GetInstanceCount()
+
 	// all succeeded -> succeeded
 	if d.stateCounts[task.TaskState_SUCCEEDED.String()] == totalInstanceCount {
-		return job.JobState_SUCCEEDED, nil
+		return job.JobState_SUCCEEDED, true
 	}
 
-	// some succeeded, some failed, some lost -> failed
+	// some succeeded, some failed, some lost -> failed, unless enough
+	// succeeded for the job to tolerate the loss of the others
 	if d.stateCounts[task.TaskState_SUCCEEDED.String()]+
-		d.stateCounts[task.TaskState_FAILED.String()]+
+		getFailedInstanceCount(d.stateCounts)+
 		d.stateCounts[task.TaskState_LOST.String()] == totalInstanceCount {
-		return job.JobState_FAILED, nil
+		if hasMinimumSuccess(
+			d.stateCounts[task.TaskState_SUCCEEDED.String()],
+			totalInstanceCount,
+			d.config.GetSLA().GetMinimumSuccessPercent()) {
+			return job.JobState_SUCCEEDED, true
+		}
+		return job.JobState_FAILED, true
 	}
 
 	// some killed, some succeeded, some failed, some lost -> killed
 	if d.stateCounts[task.TaskState_KILLED.String()] > 0 &&
 		(d.stateCounts[task.TaskState_SUCCEEDED.String()]+
-			d.stateCounts[task.TaskState_FAILED.String()]+
+			getFailedInstanceCount(d.stateCounts)+
 			d.stateCounts[task.TaskState_KILLED.String()]+
 			d.stateCounts[task.TaskState_LOST.String()] == totalInstanceCount) {
-		return job.JobState_KILLED, nil
-	}
-
-	if jobRuntime.State == job.JobState_KILLING {
-		// jobState is set to KILLING in JobKill to avoid materialized view delay,
-		// should keep the state to be KILLING unless job transits to terminal state
-		return job.JobState_KILLING, nil
+		return job.JobState_KILLED, true
 	}
+	return job.JobState_UNKNOWN, false
+}
 
-	if d.stateCounts[task.TaskState_RUNNING.String()] > 0 {
-		return job.JobState_RUNNING, nil
+// hasMinimumSuccess returns true if the percentage of the instances
+// which succeeded reaches the minimum success percent of the job. It is
+// always false if the minimum success percent is not set.
+func hasMinimumSuccess(
+	succeeded uint32,
+	instanceCount uint32,
+	minSuccessPercent uint32) bool {
+	if minSuccessPercent == 0 {
+		return false
 	}
-	return job.JobState_PENDING, nil
-
+	return uint64(succeeded)*100 >= uint64(instanceCount)*uint64(minSuccessPercent)
 }
 
 func newPartiallyCreatedJobStateDeterminer(
@@ -358,7 +450,7 @@ func (d *partiallyCreatedJobStateDeterminer) getState(
 			d.stateCounts[task.TaskState_KILLED.String()] > 0 &&
 			(d.stateCounts[task.TaskState_KILLED.String()]+
 				d.stateCounts[task.TaskState_SUCCEEDED.String()]+
-				d.stateCounts[task.TaskState_FAILED.String()]+
+				getFailedInstanceCount(d.stateCounts)+
 				d.stateCounts[task.TaskState_LOST.String()] == instanceCount) {
 			return job.JobState_KILLED, nil
 		}
@@ -372,7 +464,7 @@ func (d *partiallyCreatedJobStateDeterminer) getState(
 		// job goal state is terminal &&
 		// some failed + some succeeded + some lost -> failed
 		if util.IsPelotonJobStateTerminal(jobRuntime.GetGoalState()) &&
-			(d.stateCounts[task.TaskState_FAILED.String()]+
+			(getFailedInstanceCount(d.stateCounts)+
 				d.stateCounts[task.TaskState_SUCCEEDED.String()]+
 				d.stateCounts[task.TaskState_LOST.String()] == instanceCount) {
 			return job.JobState_FAILED, nil
@@ -618,6 +710,7 @@ func JobRuntimeUpdater(ctx context.Context, entity goalstate.Entity) error {
 		cachedJob,
 		jobState,
 		jobRuntimeUpdate,
+		goalStateDriver.now(),
 	)
 
 	jobRuntimeUpdate.TaskStats = currStateCounts
@@ -662,7 +755,7 @@ func JobRuntimeUpdater(ctx context.Context, entity goalstate.Entity) error {
 	if util.IsPelotonJobStateTerminal(jobRuntimeUpdate.GetState()) ||
 		(cachedJob.IsPartiallyCreated(config) &&
 			!updateutil.HasUpdate(jobRuntime)) {
-		goalStateDriver.EnqueueJob(jobID, time.Now())
+		goalStateDriver.EnqueueJob(jobID, goalStateDriver.now())
 	}
 
 	log.WithField("job_id", id).
@@ -681,6 +774,13 @@ func getTotalInstanceCount(stateCounts map[string]uint32) uint32 {
 	return totalInstanceCount
 }
 
+// getFailedInstanceCount returns the number of failed instances, including
+// the crash-looping instances held after their failure.
+func getFailedInstanceCount(stateCounts map[string]uint32) uint32 {
+	return stateCounts[task.TaskState_FAILED.String()] +
+		stateCounts[task.TaskState_FAILED_HOLD.String()]
+}
+
 // setStartTime adds start time to jobRuntimeUpdate, if the job
 // first starts. It returns the updated jobRuntimeUpdate.
 func setStartTime(
@@ -707,13 +807,14 @@ func setStartTime(
 func setCompletionTime(
 	cachedJob cached.Job,
 	jobState job.JobState,
-	jobRuntimeUpdate *job.RuntimeInfo) *job.RuntimeInfo {
+	jobRuntimeUpdate *job.RuntimeInfo,
+	now time.Time) *job.RuntimeInfo {
 	if util.IsPelotonJobStateTerminal(jobState) {
 		// In case a job moved from PENDING/INITIALIZED to KILLED state,
 		// the lastTaskUpdateTime will be 0. In this case, we will use
-		// time.Now() as default completion time since a job in terminal
+		// the current time as default completion time since a job in terminal
 		// state should always have a completion time
-		completionTime := time.Now().UTC().Format(time.RFC3339Nano)
+		completionTime := now.UTC().Format(time.RFC3339Nano)
 		lastTaskUpdateTime := cachedJob.GetLastTaskUpdateTime()
 		if lastTaskUpdateTime != 0 {
 			completionTime = formatTime(lastTaskUpdateTime, time.RFC3339Nano)
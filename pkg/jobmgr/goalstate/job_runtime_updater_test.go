@@ -484,6 +484,11 @@ func (suite *JobRuntimeUpdaterTestSuite) TestJobRuntimeUpdater_Batch_FAILED() {
 		Return(instanceCount).
 		AnyTimes()
 
+	suite.cachedConfig.EXPECT().
+		GetSLA().
+		Return(nil).
+		AnyTimes()
+
 	suite.cachedConfig.EXPECT().
 		HasControllerTask().
 		Return(false)
@@ -559,6 +564,11 @@ func (suite *JobRuntimeUpdaterTestSuite) TestJobRuntimeUpdater_Batch_LOST() {
 		Return(instanceCount).
 		AnyTimes()
 
+	suite.cachedConfig.EXPECT().
+		GetSLA().
+		Return(nil).
+		AnyTimes()
+
 	suite.cachedConfig.EXPECT().
 		HasControllerTask().
 		Return(false)
@@ -1203,6 +1213,11 @@ func (suite *JobRuntimeUpdaterTestSuite) TestJobRuntimeUpdater_ControllerTaskSuc
 
 	suite.cachedConfig.EXPECT().GetType().Return(pbjob.JobType_BATCH)
 
+	suite.cachedConfig.EXPECT().
+		GetSLA().
+		Return(nil).
+		AnyTimes()
+
 	suite.cachedConfig.EXPECT().
 		HasControllerTask().
 		Return(true)
@@ -1283,6 +1298,11 @@ func (suite *JobRuntimeUpdaterTestSuite) TestJobRuntimeUpdaterControllerTaskFail
 		GetConfig(gomock.Any()).
 		Return(suite.cachedConfig, nil)
 
+	suite.cachedConfig.EXPECT().
+		GetSLA().
+		Return(nil).
+		AnyTimes()
+
 	suite.cachedConfig.EXPECT().
 		HasControllerTask().
 		Return(true)
@@ -1335,6 +1355,11 @@ func (suite *JobRuntimeUpdaterTestSuite) TestJobRuntimeUpdaterControllerTaskFail
 		GetConfig(gomock.Any()).
 		Return(suite.cachedConfig, nil)
 
+	suite.cachedConfig.EXPECT().
+		GetSLA().
+		Return(nil).
+		AnyTimes()
+
 	suite.cachedConfig.EXPECT().
 		HasControllerTask().
 		Return(true)
@@ -1398,6 +1423,11 @@ func (suite *JobRuntimeUpdaterTestSuite) TestJobRuntimeUpdater_ControllerTaskFai
 		GetType().
 		Return(pbjob.JobType_BATCH)
 
+	suite.cachedConfig.EXPECT().
+		GetSLA().
+		Return(nil).
+		AnyTimes()
+
 	suite.cachedConfig.EXPECT().
 		HasControllerTask().
 		Return(true)
@@ -1485,6 +1515,11 @@ func (suite *JobRuntimeUpdaterTestSuite) TestJobRuntimeUpdater_ControllerTaskLos
 		GetType().
 		Return(pbjob.JobType_BATCH)
 
+	suite.cachedConfig.EXPECT().
+		GetSLA().
+		Return(nil).
+		AnyTimes()
+
 	suite.cachedConfig.EXPECT().
 		HasControllerTask().
 		Return(true)
@@ -2094,6 +2129,7 @@ func (suite *JobRuntimeUpdaterTestSuite) TestDetermineBatchJobRuntimeState() {
 			Return(test.configuredInstanceCount).AnyTimes()
 
 		cachedConfig.EXPECT().HasControllerTask().Return(false).AnyTimes()
+		cachedConfig.EXPECT().GetSLA().Return(nil).AnyTimes()
 
 		cachedJob.EXPECT().IsPartiallyCreated(gomock.Any()).
 			Return(getTotalInstanceCount(test.stateCounts) <
@@ -2117,6 +2153,165 @@ func (suite *JobRuntimeUpdaterTestSuite) TestDetermineBatchJobRuntimeState() {
 	}
 }
 
+// TestDetermineBatchJobRuntimeStateMinimumSuccess tests that a batch job
+// with a minimum success percent succeeds once enough of its instances
+// succeeded, and the others failed or are lost
+func (suite *JobRuntimeUpdaterTestSuite) TestDetermineBatchJobRuntimeStateMinimumSuccess() {
+	var instanceCount uint32 = 100
+	tests := []struct {
+		stateCounts       map[string]uint32
+		minSuccessPercent uint32
+		expectedState     pbjob.JobState
+		message           string
+	}{
+		{
+			map[string]uint32{
+				pbtask.TaskState_SUCCEEDED.String(): 95,
+				pbtask.TaskState_FAILED.String():    3,
+				pbtask.TaskState_LOST.String():      2,
+			},
+			95,
+			pbjob.JobState_SUCCEEDED,
+			"Batch job with enough tasks succeeded should be SUCCEEDED",
+		},
+		{
+			map[string]uint32{
+				pbtask.TaskState_SUCCEEDED.String(): 94,
+				pbtask.TaskState_FAILED.String():    6,
+			},
+			95,
+			pbjob.JobState_FAILED,
+			"Batch job with too many tasks failed should be FAILED",
+		},
+		{
+			map[string]uint32{
+				pbtask.TaskState_SUCCEEDED.String(): 96,
+				pbtask.TaskState_RUNNING.String():   4,
+			},
+			95,
+			pbjob.JobState_RUNNING,
+			"Batch job with tasks running should be RUNNING",
+		},
+		{
+			map[string]uint32{
+				pbtask.TaskState_SUCCEEDED.String(): 99,
+				pbtask.TaskState_FAILED.String():    1,
+			},
+			0,
+			pbjob.JobState_FAILED,
+			"Batch job without minimum success percent should be FAILED",
+		},
+	}
+
+	for index, test := range tests {
+		ctrl := gomock.NewController(suite.T())
+		jobRuntime := &pbjob.RuntimeInfo{
+			State: pbjob.JobState_RUNNING,
+		}
+		cachedConfig := cachedmocks.NewMockJobConfigCache(ctrl)
+		cachedJob := cachedmocks.NewMockJob(ctrl)
+
+		cachedConfig.EXPECT().GetType().Return(pbjob.JobType_BATCH).AnyTimes()
+		cachedJob.EXPECT().GetJobType().Return(pbjob.JobType_BATCH).AnyTimes()
+		cachedConfig.EXPECT().GetInstanceCount().Return(instanceCount).AnyTimes()
+		cachedConfig.EXPECT().HasControllerTask().Return(false).AnyTimes()
+		cachedConfig.EXPECT().GetSLA().Return(&pbjob.SlaConfig{
+			MinimumSuccessPercent: test.minSuccessPercent,
+		}).AnyTimes()
+		cachedJob.EXPECT().IsPartiallyCreated(gomock.Any()).
+			Return(false).AnyTimes()
+		cachedJob.EXPECT().GetLastTaskUpdateTime().
+			Return(suite.lastUpdateTs).AnyTimes()
+
+		jobState, _, _, _, _ := determineJobRuntimeStateAndCounts(
+			context.Background(),
+			jobRuntime,
+			test.stateCounts,
+			cachedConfig,
+			suite.goalStateDriver,
+			cachedJob,
+		)
+
+		suite.Equal(test.expectedState, jobState, "Test %d: %s", index, test.message)
+
+		ctrl.Finish()
+	}
+}
+
+// TestDetermineSuspendedBatchJobRuntimeState tests that a suspended batch
+// job whose tasks are killed is not terminated
+func (suite *JobRuntimeUpdaterTestSuite) TestDetermineSuspendedBatchJobRuntimeState() {
+	var instanceCount uint32 = 100
+	stateCounts := map[string]uint32{
+		pbtask.TaskState_SUCCEEDED.String(): instanceCount / 2,
+		pbtask.TaskState_KILLED.String():    instanceCount / 2,
+	}
+	jobRuntime := &pbjob.RuntimeInfo{
+		State:     pbjob.JobState_RUNNING,
+		Suspended: true,
+	}
+
+	cachedConfig := cachedmocks.NewMockJobConfigCache(suite.ctrl)
+	cachedConfig.EXPECT().GetType().Return(pbjob.JobType_BATCH).AnyTimes()
+	cachedConfig.EXPECT().GetInstanceCount().Return(instanceCount).AnyTimes()
+	cachedConfig.EXPECT().HasControllerTask().Return(false).AnyTimes()
+	suite.cachedJob.EXPECT().GetLastTaskUpdateTime().
+		Return(suite.lastUpdateTs).AnyTimes()
+
+	jobState, _, _, _, err := determineJobRuntimeStateAndCounts(
+		context.Background(),
+		jobRuntime,
+		stateCounts,
+		cachedConfig,
+		suite.goalStateDriver,
+		suite.cachedJob,
+	)
+	suite.NoError(err)
+	suite.Equal(pbjob.JobState_PENDING, jobState)
+
+	// the job is killed once it is resumed
+	jobRuntime.Suspended = false
+	jobState, _, _, _, err = determineJobRuntimeStateAndCounts(
+		context.Background(),
+		jobRuntime,
+		stateCounts,
+		cachedConfig,
+		suite.goalStateDriver,
+		suite.cachedJob,
+	)
+	suite.NoError(err)
+	suite.Equal(pbjob.JobState_KILLED, jobState)
+
+	// the job is killed once it is stopped, even if still suspended
+	jobRuntime.Suspended = true
+	jobRuntime.GoalState = pbjob.JobState_KILLED
+	jobState, _, _, _, err = determineJobRuntimeStateAndCounts(
+		context.Background(),
+		jobRuntime,
+		stateCounts,
+		cachedConfig,
+		suite.goalStateDriver,
+		suite.cachedJob,
+	)
+	suite.NoError(err)
+	suite.Equal(pbjob.JobState_KILLED, jobState)
+
+	// the job succeeds if all its tasks succeeded before the suspend
+	jobRuntime.GoalState = pbjob.JobState_SUCCEEDED
+	jobState, _, _, _, err = determineJobRuntimeStateAndCounts(
+		context.Background(),
+		jobRuntime,
+		map[string]uint32{
+			pbtask.TaskState_SUCCEEDED.String(): instanceCount,
+		},
+		cachedConfig,
+		suite.goalStateDriver,
+		suite.cachedJob,
+	)
+	suite.NoError(err)
+	suite.Equal(pbjob.JobState_SUCCEEDED, jobState)
+}
+
 // TestDetermineServiceJobRuntimeState tests determining JobRuntimeState for service jobs
 func (suite *JobRuntimeUpdaterTestSuite) TestDetermineServiceJobRuntimeState() {
 	var instanceCount uint32 = 100
@@ -2257,6 +2452,7 @@ func (suite *JobRuntimeUpdaterTestSuite) TestDetermineServiceJobRuntimeState() {
 			Return(test.configuredInstanceCount).AnyTimes()
 
 		cachedConfig.EXPECT().HasControllerTask().Return(false).AnyTimes()
+		cachedConfig.EXPECT().GetSLA().Return(nil).AnyTimes()
 
 		cachedJob.EXPECT().
 			GetAllTasks().
@@ -2482,6 +2678,148 @@ func (suite *JobRuntimeUpdaterTestSuite) TestJobEvaluateMaxRunningInstances() {
 	suite.NoError(err)
 }
 
+// TestJobEvaluateMaxLaunchingInstances tests
+// evaluating max launching instances
+func (suite *JobRuntimeUpdaterTestSuite) TestJobEvaluateMaxLaunchingInstances() {
+	instanceCount := uint32(100)
+	maxLaunchingInstances := uint32(5)
+	jobConfig := pbjob.JobConfig{
+		OwningTeam:    "team6",
+		LdapGroups:    []string{"team1", "team2", "team3"},
+		InstanceCount: instanceCount,
+		Type:          pbjob.JobType_BATCH,
+		SLA: &pbjob.SlaConfig{
+			MaximumLaunchingInstances: maxLaunchingInstances,
+		},
+	}
+
+	jobRuntime := pbjob.RuntimeInfo{
+		State:     pbjob.JobState_RUNNING,
+		GoalState: pbjob.JobState_SUCCEEDED,
+	}
+
+	suite.cachedConfig.EXPECT().
+		GetSLA().
+		Return(jobConfig.SLA).AnyTimes()
+
+	// Simulate RUNNING job with some instances still launching
+	launchingInstances := uint32(2)
+	stateCounts := make(map[string]uint32)
+	stateCounts[pbtask.TaskState_PENDING.String()] = launchingInstances
+	stateCounts[pbtask.TaskState_RUNNING.String()] = instanceCount / 2
+	stateCounts[pbtask.TaskState_INITIALIZED.String()] = instanceCount/2 - launchingInstances
+	jobRuntime.TaskStats = stateCounts
+
+	var initializedTasks []uint32
+	for i := uint32(0); i < instanceCount/2-launchingInstances; i++ {
+		initializedTasks = append(initializedTasks, i)
+	}
+
+	suite.jobFactory.EXPECT().
+		AddJob(suite.jobID).
+		Return(suite.cachedJob)
+
+	suite.jobFactory.EXPECT().
+		GetJob(suite.jobID).
+		Return(suite.cachedJob)
+
+	suite.cachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(&jobRuntime, nil)
+
+	suite.cachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(suite.cachedConfig, nil).
+		Times(2)
+
+	suite.jobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.jobID.GetValue()).
+		Return(&jobConfig, &models.ConfigAddOn{}, nil)
+
+	suite.taskStore.EXPECT().
+		GetTaskIDsForJobAndState(gomock.Any(), suite.jobID, pbtask.TaskState_INITIALIZED.String()).
+		Return(initializedTasks, nil)
+
+	for i := uint32(0); i < maxLaunchingInstances-launchingInstances; i++ {
+		suite.taskStore.EXPECT().
+			GetTaskRuntime(gomock.Any(), suite.jobID, gomock.Any()).
+			Return(&pbtask.RuntimeInfo{
+				State: pbtask.TaskState_INITIALIZED,
+			}, nil)
+		suite.cachedJob.EXPECT().
+			GetTask(gomock.Any()).Return(suite.cachedTask)
+		suite.taskGoalStateEngine.EXPECT().
+			IsScheduled(gomock.Any()).
+			Return(false)
+	}
+
+	suite.resmgrClient.EXPECT().
+		EnqueueGangs(gomock.Any(), gomock.Any()).
+		Return(&resmgrsvc.EnqueueGangsResponse{}, nil)
+
+	suite.cachedJob.EXPECT().
+		PatchTasks(gomock.Any(), gomock.Any()).
+		Do(func(ctx context.Context, runtimeDiffs map[uint32]jobmgrcommon.RuntimeDiff) {
+			suite.Equal(maxLaunchingInstances-launchingInstances, uint32(len(runtimeDiffs)))
+			for _, runtimeDiff := range runtimeDiffs {
+				suite.Equal(runtimeDiff[jobmgrcommon.StateField], pbtask.TaskState_PENDING)
+			}
+		}).
+		Return(nil)
+
+	err := JobEvaluateMaxRunningInstancesSLA(context.Background(), suite.jobEnt)
+	suite.NoError(err)
+
+	// Simulate when max launching instances are already launching
+	stateCounts = make(map[string]uint32)
+	stateCounts[pbtask.TaskState_INITIALIZED.String()] = instanceCount - maxLaunchingInstances
+	stateCounts[pbtask.TaskState_LAUNCHED.String()] = maxLaunchingInstances
+	jobRuntime.TaskStats = stateCounts
+
+	suite.jobFactory.EXPECT().
+		AddJob(suite.jobID).
+		Return(suite.cachedJob)
+
+	suite.jobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.jobID.GetValue()).
+		Return(&jobConfig, &models.ConfigAddOn{}, nil)
+
+	suite.cachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(suite.cachedConfig, nil)
+
+	suite.cachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(&jobRuntime, nil)
+
+	err = JobEvaluateMaxRunningInstancesSLA(context.Background(), suite.jobEnt)
+	suite.NoError(err)
+}
+
+// TestGetMaxStartingInstances tests the number of instances which can be
+// sent to resource manager at once for a job SLA
+func (suite *JobRuntimeUpdaterTestSuite) TestGetMaxStartingInstances() {
+	tt := []struct {
+		maxRunningInstances   uint32
+		maxLaunchingInstances uint32
+		expected              uint32
+	}{
+		{0, 0, 0},
+		{10, 0, 10},
+		{0, 5, 5},
+		{10, 5, 5},
+		{5, 10, 5},
+	}
+
+	for _, test := range tt {
+		suite.Equal(test.expected, getMaxStartingInstances(&pbjob.SlaConfig{
+			MaximumRunningInstances:   test.maxRunningInstances,
+			MaximumLaunchingInstances: test.maxLaunchingInstances,
+		}))
+	}
+	suite.Equal(uint32(0), getMaxStartingInstances(nil))
+}
+
 // TestShouldRecalculateJobStateNonBatch tests shouldRecalculateJobStateFromCache
 // function for a service job
 func (suite *JobRuntimeUpdaterTestSuite) TestShouldRecalculateJobStateNonBatch() {
@@ -15,6 +15,7 @@
 package goalstate
 
 import (
+	"context"
 	"testing"
 
 	"github.com/uber/peloton/.gen/peloton/api/v0/job"
@@ -86,7 +87,8 @@ func TestJobGetActionList(t *testing.T) {
 	jobID := &peloton.JobID{Value: uuid.NewRandom().String()}
 
 	jobEnt := &jobEntity{
-		id: jobID,
+		id:     jobID,
+		driver: &driver{},
 	}
 	_, _, actions := jobEnt.GetActionList(
 		cached.JobStateVector{State: job.JobState_UNKNOWN},
@@ -125,6 +127,42 @@ func TestJobGetActionList(t *testing.T) {
 	assert.Equal(t, 5, len(actions))
 }
 
+// TestJobGetActionListPaused tests that no action is run for a job
+// while the actions of its job type are paused.
+func TestJobGetActionListPaused(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	jobFactory := cachedmocks.NewMockJobFactory(ctrl)
+	cachedJob := cachedmocks.NewMockJob(ctrl)
+	jobID := &peloton.JobID{Value: uuid.NewRandom().String()}
+
+	jobEnt := &jobEntity{
+		id: jobID,
+		driver: &driver{
+			jobFactory: jobFactory,
+			mtx:        NewMetrics(tally.NoopScope),
+		},
+	}
+	assert.NoError(t, jobEnt.driver.Pause(
+		context.Background(), job.JobType_SERVICE))
+
+	jobFactory.EXPECT().GetJob(jobID).Return(cachedJob).Times(2)
+	cachedJob.EXPECT().GetJobType().Return(job.JobType_SERVICE)
+	_, _, actions := jobEnt.GetActionList(
+		cached.JobStateVector{State: job.JobState_RUNNING},
+		cached.JobStateVector{State: job.JobState_KILLED},
+	)
+	assert.Empty(t, actions)
+
+	cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
+	_, _, actions = jobEnt.GetActionList(
+		cached.JobStateVector{State: job.JobState_RUNNING},
+		cached.JobStateVector{State: job.JobState_KILLED},
+	)
+	assert.Equal(t, 5, len(actions))
+}
+
 func TestEngineJobSuggestAction(t *testing.T) {
 	jobID := &peloton.JobID{Value: uuid.NewRandom().String()}
 	jobEnt := &jobEntity{
@@ -49,6 +49,10 @@ type TaskMetrics struct {
 	RetryFailedLaunchTotal tally.Counter
 	RetryFailedTasksTotal  tally.Counter
 	RetryLostTasksTotal    tally.Counter
+	RetryHostFailureTotal  tally.Counter
+	CrashLoopHoldTotal     tally.Counter
+	TaskActionThrottled    tally.Counter
+	TaskDeadlineExceeded   tally.Counter
 }
 
 // UpdateMetrics contains all counters to track
@@ -72,6 +76,16 @@ type Metrics struct {
 	jobMetrics    *JobMetrics
 	taskMetrics   *TaskMetrics
 	updateMetrics *UpdateMetrics
+
+	// number of job types whose actions are paused
+	pausedJobTypes tally.Gauge
+	// entities dequeued without running actions because they are paused
+	pausedSkipped tally.Counter
+	// number of job managers sharing the goal state
+	shardMembers tally.Gauge
+	// entities dequeued without running actions because their job is
+	// owned by another job manager
+	shardSkipped tally.Counter
 }
 
 // NewMetrics returns a new Metrics struct, with all metrics
@@ -108,6 +122,10 @@ func NewMetrics(scope tally.Scope) *Metrics {
 		RetryFailedLaunchTotal: taskScope.Counter("retry_system_failure_total"),
 		RetryFailedTasksTotal:  taskScope.Counter("retry_failed_total"),
 		RetryLostTasksTotal:    taskScope.Counter("retry_lost_total"),
+		RetryHostFailureTotal:  taskScope.Counter("retry_host_failure_total"),
+		CrashLoopHoldTotal:     taskScope.Counter("crash_loop_hold_total"),
+		TaskActionThrottled:    taskScope.Counter("action_throttled"),
+		TaskDeadlineExceeded:   taskScope.Counter("deadline_exceeded"),
 	}
 
 	updateMetrics := &UpdateMetrics{
@@ -124,8 +142,12 @@ func NewMetrics(scope tally.Scope) *Metrics {
 	}
 
 	return &Metrics{
-		jobMetrics:    jobMetrics,
-		taskMetrics:   taskMetrics,
-		updateMetrics: updateMetrics,
+		jobMetrics:     jobMetrics,
+		taskMetrics:    taskMetrics,
+		updateMetrics:  updateMetrics,
+		pausedJobTypes: scope.Gauge("paused_job_types"),
+		pausedSkipped:  scope.Counter("paused_skipped"),
+		shardMembers:   scope.Gauge("shard_members"),
+		shardSkipped:   scope.Counter("shard_skipped"),
 	}
 }
@@ -0,0 +1,253 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goalstate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	mesosv1 "github.com/uber/peloton/.gen/mesos/v1"
+	pbjob "github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	pbtask "github.com/uber/peloton/.gen/peloton/api/v0/task"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
+	"github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc"
+	"github.com/uber/peloton/.gen/peloton/private/resmgrsvc"
+
+	"github.com/uber/peloton/pkg/common/goalstate"
+	"github.com/uber/peloton/pkg/common/util"
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+	jobmgrcommon "github.com/uber/peloton/pkg/jobmgr/common"
+	"github.com/uber/peloton/pkg/jobmgr/task/launcher"
+	"github.com/uber/peloton/pkg/jobmgr/task/registration"
+	"github.com/uber/peloton/pkg/storage"
+
+	"github.com/uber-go/tally"
+)
+
+// Replayer replays the pod events recorded for a job through the goal
+// state engines, so that the actions taken by the job manager for the
+// job can be reproduced offline in a test harness.
+// The engines of the replayer evaluate entities synchronously, and the
+// driver, the engines and the cache share a manual clock which is moved
+// to the timestamp of each replayed event. Given the same pod events and
+// the same responses from the stores and clients, the replayer always
+// executes the same sequence of actions, which it records in its journal.
+type Replayer struct {
+	driver  *driver
+	clock   *goalstate.ManualClock
+	engines []goalstate.ReplayEngine
+}
+
+// NewReplayer returns a new replayer object. The job factory should have
+// been created with the same clock, using cached.WithClock.
+func NewReplayer(
+	hostmgrClient hostsvc.InternalHostServiceYARPCClient,
+	resmgrClient resmgrsvc.ResourceManagerServiceYARPCClient,
+	jobStore storage.JobStore,
+	taskStore storage.TaskStore,
+	volumeStore storage.PersistentVolumeStore,
+	updateStore storage.UpdateStore,
+	jobFactory cached.JobFactory,
+	taskLauncher launcher.Launcher,
+	registrationHooks registration.Hooks,
+	jobType pbjob.JobType,
+	parentScope tally.Scope,
+	cfg Config,
+	jobRuntimeCalculationViaCache bool,
+	clock *goalstate.ManualClock,
+	journal goalstate.Journal) *Replayer {
+	cfg.normalize()
+	// the journal records the entities of a single job manager,
+	// which are all replayed
+	cfg.Sharding.Enabled = false
+	scope := parentScope.SubScope("goalstate")
+	jobScope := scope.SubScope("job")
+	taskScope := scope.SubScope("task")
+
+	jobEngine := goalstate.NewReplayEngine(
+		clock, journal, cfg.FailureRetryDelay, cfg.MaxRetryDelay, jobScope)
+	taskEngine := goalstate.NewReplayEngine(
+		clock, journal, cfg.FailureRetryDelay, cfg.MaxRetryDelay, taskScope)
+	updateEngine := goalstate.NewReplayEngine(
+		clock, journal, cfg.FailureRetryDelay, cfg.MaxRetryDelay, jobScope)
+
+	return &Replayer{
+		driver: &driver{
+			jobEngine:                     jobEngine,
+			taskEngine:                    taskEngine,
+			updateEngine:                  updateEngine,
+			hostmgrClient:                 hostmgrClient,
+			resmgrClient:                  resmgrClient,
+			jobStore:                      jobStore,
+			taskStore:                     taskStore,
+			volumeStore:                   volumeStore,
+			updateStore:                   updateStore,
+			jobFactory:                    jobFactory,
+			taskLauncher:                  taskLauncher,
+			registrationHooks:             registrationHooks,
+			mtx:                           NewMetrics(scope),
+			cfg:                           &cfg,
+			jobType:                       jobType,
+			jobRuntimeCalculationViaCache: jobRuntimeCalculationViaCache,
+			jobScope:                      jobScope,
+			clock:                         clock,
+		},
+		clock:   clock,
+		engines: []goalstate.ReplayEngine{jobEngine, taskEngine, updateEngine},
+	}
+}
+
+// Driver returns the goal state driver of the replayer, which can be used
+// to enqueue jobs, tasks and updates before replaying the pod events.
+func (r *Replayer) Driver() Driver {
+	return r.driver
+}
+
+// RunUntil evaluates all jobs, tasks and updates whose deadline is not
+// after the provided time, in deadline order, and then moves the clock to
+// the provided time. When several engines have entities with the same
+// deadline, jobs are evaluated first, then tasks and then updates.
+// It returns the number of entities evaluated.
+func (r *Replayer) RunUntil(t time.Time) int {
+	count := 0
+	for {
+		var next goalstate.ReplayEngine
+		var nextDeadline time.Time
+		for _, e := range r.engines {
+			deadline := e.NextDeadline()
+			if deadline.IsZero() || deadline.After(t) {
+				continue
+			}
+			if next == nil || deadline.Before(nextDeadline) {
+				next = e
+				nextDeadline = deadline
+			}
+		}
+		if next == nil {
+			break
+		}
+		count += next.RunUntil(nextDeadline)
+	}
+	r.clock.Set(t)
+	return count
+}
+
+// ReplayPodEvents replays the pod events of a job. The events are expected
+// in the order returned by the task store, most recent first. For each
+// event, in timestamp order, the entities due before the event are
+// evaluated, the runtime of the task in the cache is patched with the
+// state recorded in the event, and the task and its job are enqueued the
+// same way a task event received from the host manager would enqueue them.
+func (r *Replayer) ReplayPodEvents(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	events []*pod.PodEvent) error {
+	type replayEvent struct {
+		instanceID uint32
+		timestamp  time.Time
+		event      *pod.PodEvent
+	}
+
+	replayEvents := make([]replayEvent, 0, len(events))
+	for i := len(events) - 1; i >= 0; i-- {
+		event := events[i]
+		id, instanceID, err := util.ParseJobAndInstanceID(
+			event.GetPodId().GetValue())
+		if err != nil {
+			return err
+		}
+		if id != jobID.GetValue() {
+			return fmt.Errorf("pod %s does not belong to job %s",
+				event.GetPodId().GetValue(), jobID.GetValue())
+		}
+		timestamp, err := time.Parse(time.RFC3339, event.GetTimestamp())
+		if err != nil {
+			return err
+		}
+		replayEvents = append(replayEvents, replayEvent{
+			instanceID: instanceID,
+			timestamp:  timestamp,
+			event:      event,
+		})
+	}
+	sort.SliceStable(replayEvents, func(i, j int) bool {
+		return replayEvents[i].timestamp.Before(replayEvents[j].timestamp)
+	})
+
+	cachedJob := r.driver.jobFactory.AddJob(jobID)
+	for _, e := range replayEvents {
+		r.RunUntil(e.timestamp)
+
+		if err := r.applyPodEvent(ctx, cachedJob, e.instanceID, e.event); err != nil {
+			return err
+		}
+
+		r.driver.EnqueueTask(jobID, e.instanceID, e.timestamp)
+		r.driver.EnqueueJob(jobID, e.timestamp.Add(
+			r.driver.JobRuntimeDuration(r.driver.jobType)))
+		r.RunUntil(e.timestamp)
+	}
+	return nil
+}
+
+// applyPodEvent patches the runtime of the task in the cache with
+// the state recorded in the pod event.
+func (r *Replayer) applyPodEvent(
+	ctx context.Context,
+	cachedJob cached.Job,
+	instanceID uint32,
+	event *pod.PodEvent) error {
+	configVersion, err := strconv.ParseUint(
+		event.GetVersion().GetValue(), 10, 64)
+	if err != nil {
+		return err
+	}
+	desiredConfigVersion, err := strconv.ParseUint(
+		event.GetDesiredVersion().GetValue(), 10, 64)
+	if err != nil {
+		return err
+	}
+
+	podID := event.GetPodId().GetValue()
+	prevPodID := event.GetPrevPodId().GetValue()
+	desiredPodID := event.GetDesiredPodId().GetValue()
+	agentID := event.GetAgentId()
+
+	runtimeDiff := jobmgrcommon.RuntimeDiff{
+		jobmgrcommon.StateField: pbtask.TaskState(
+			pbtask.TaskState_value[event.GetActualState()]),
+		jobmgrcommon.GoalStateField: pbtask.TaskState(
+			pbtask.TaskState_value[event.GetDesiredState()]),
+		jobmgrcommon.MesosTaskIDField:          &mesosv1.TaskID{Value: &podID},
+		jobmgrcommon.PrevMesosTaskIDField:      &mesosv1.TaskID{Value: &prevPodID},
+		jobmgrcommon.DesiredMesosTaskIDField:   &mesosv1.TaskID{Value: &desiredPodID},
+		jobmgrcommon.ConfigVersionField:        configVersion,
+		jobmgrcommon.DesiredConfigVersionField: desiredConfigVersion,
+		jobmgrcommon.AgentIDField:              &mesosv1.AgentID{Value: &agentID},
+		jobmgrcommon.HostField:                 event.GetHostname(),
+		jobmgrcommon.MessageField:              event.GetMessage(),
+		jobmgrcommon.ReasonField:               event.GetReason(),
+		jobmgrcommon.HealthyField: pbtask.HealthState(
+			pbtask.HealthState_value[event.GetHealthy()]),
+	}
+
+	return cachedJob.PatchTasks(
+		ctx,
+		map[uint32]jobmgrcommon.RuntimeDiff{instanceID: runtimeDiff})
+}
@@ -0,0 +1,231 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goalstate
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	pbjob "github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	pbtask "github.com/uber/peloton/.gen/peloton/api/v0/task"
+	v1alphapeloton "github.com/uber/peloton/.gen/peloton/api/v1alpha/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
+
+	"github.com/uber/peloton/pkg/common/goalstate"
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
+	jobmgrcommon "github.com/uber/peloton/pkg/jobmgr/common"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+)
+
+type replayerTestSuite struct {
+	suite.Suite
+
+	ctrl       *gomock.Controller
+	jobFactory *cachedmocks.MockJobFactory
+	cachedJob  *cachedmocks.MockJob
+	cachedTask *cachedmocks.MockTask
+
+	jobID    *peloton.JobID
+	start    time.Time
+	clock    *goalstate.ManualClock
+	journal  *goalstate.MemoryJournal
+	replayer *Replayer
+}
+
+func TestReplayer(t *testing.T) {
+	suite.Run(t, new(replayerTestSuite))
+}
+
+func (suite *replayerTestSuite) SetupTest() {
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.jobFactory = cachedmocks.NewMockJobFactory(suite.ctrl)
+	suite.cachedJob = cachedmocks.NewMockJob(suite.ctrl)
+	suite.cachedTask = cachedmocks.NewMockTask(suite.ctrl)
+
+	suite.jobID = &peloton.JobID{Value: uuid.NewRandom().String()}
+	suite.start = time.Unix(1546300800, 0).UTC()
+	suite.clock = goalstate.NewManualClock(suite.start.Add(-time.Hour))
+	suite.journal = goalstate.NewMemoryJournal(0)
+	suite.replayer = NewReplayer(
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		suite.jobFactory,
+		nil,
+		nil,
+		pbjob.JobType_BATCH,
+		tally.NoopScope,
+		Config{JobBatchRuntimeUpdateInterval: time.Hour},
+		false,
+		suite.clock,
+		suite.journal,
+	)
+}
+
+func (suite *replayerTestSuite) TearDownTest() {
+	suite.ctrl.Finish()
+}
+
+// podEvent returns a pod event of the test job.
+func (suite *replayerTestSuite) podEvent(
+	instanceID uint32,
+	runID uint64,
+	state pbtask.TaskState,
+	timestamp time.Time) *pod.PodEvent {
+	podID := func(runID uint64) *v1alphapeloton.PodID {
+		return &v1alphapeloton.PodID{
+			Value: fmt.Sprintf("%s-%d-%d", suite.jobID.GetValue(), instanceID, runID),
+		}
+	}
+	return &pod.PodEvent{
+		PodId:          podID(runID),
+		PrevPodId:      podID(runID - 1),
+		DesiredPodId:   podID(runID),
+		ActualState:    state.String(),
+		DesiredState:   pbtask.TaskState_RUNNING.String(),
+		Timestamp:      timestamp.Format(time.RFC3339),
+		Version:        &v1alphapeloton.EntityVersion{Value: "1"},
+		DesiredVersion: &v1alphapeloton.EntityVersion{Value: "2"},
+		AgentId:        "agent",
+		Hostname:       "host",
+		Healthy:        pbtask.HealthState_HEALTH_UNKNOWN.String(),
+	}
+}
+
+// TestReplayPodEvents tests replaying pod events in timestamp order.
+func (suite *replayerTestSuite) TestReplayPodEvents() {
+	// events are returned by the store most recent first
+	events := []*pod.PodEvent{
+		suite.podEvent(0, 2, pbtask.TaskState_RUNNING, suite.start),
+		suite.podEvent(1, 1, pbtask.TaskState_RUNNING, suite.start),
+		suite.podEvent(0, 2, pbtask.TaskState_STARTING, suite.start),
+		suite.podEvent(0, 1, pbtask.TaskState_FAILED, suite.start.Add(-time.Minute)),
+	}
+
+	var patched []string
+	suite.jobFactory.EXPECT().
+		AddJob(suite.jobID).
+		Return(suite.cachedJob).
+		AnyTimes()
+	suite.cachedJob.EXPECT().
+		PatchTasks(gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context, diffs map[uint32]jobmgrcommon.RuntimeDiff) {
+			suite.Len(diffs, 1)
+			for instanceID, diff := range diffs {
+				suite.Equal(uint64(1), diff[jobmgrcommon.ConfigVersionField])
+				suite.Equal(uint64(2), diff[jobmgrcommon.DesiredConfigVersionField])
+				suite.Equal(pbtask.TaskState_RUNNING, diff[jobmgrcommon.GoalStateField])
+				suite.Equal("host", diff[jobmgrcommon.HostField])
+				patched = append(patched, fmt.Sprintf("%d-%s",
+					instanceID, diff[jobmgrcommon.StateField]))
+			}
+		}).
+		Return(nil).
+		Times(len(events))
+	suite.cachedJob.EXPECT().
+		GetTask(gomock.Any()).
+		Return(suite.cachedTask).
+		AnyTimes()
+	// the running tasks have no deadline
+	jobConfig := cachedmocks.NewMockJobConfigCache(suite.ctrl)
+	suite.jobFactory.EXPECT().
+		GetJob(suite.jobID).
+		Return(suite.cachedJob).
+		AnyTimes()
+	suite.cachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(jobConfig, nil).
+		AnyTimes()
+	jobConfig.EXPECT().
+		GetSLA().
+		Return(nil).
+		AnyTimes()
+	suite.cachedTask.EXPECT().
+		CurrentState().
+		Return(cached.TaskStateVector{State: pbtask.TaskState_RUNNING}).
+		AnyTimes()
+	suite.cachedTask.EXPECT().
+		GoalState().
+		Return(cached.TaskStateVector{State: pbtask.TaskState_RUNNING}).
+		AnyTimes()
+
+	suite.NoError(suite.replayer.ReplayPodEvents(
+		context.Background(), suite.jobID, events))
+
+	suite.Equal([]string{
+		"0-FAILED",
+		"0-STARTING",
+		"1-RUNNING",
+		"0-RUNNING",
+	}, patched)
+	suite.Equal(suite.start, suite.clock.Now())
+
+	// the task is evaluated right after each of its events
+	taskID := fmt.Sprintf("%s-%d", suite.jobID.GetValue(), 0)
+	entries := suite.journal.EntityEntries(taskID)
+	suite.Len(entries, 3)
+	suite.Equal(suite.start.Add(-time.Minute), entries[0].Deadline)
+	suite.Equal(suite.start, entries[1].Deadline)
+	suite.Equal(suite.start, entries[2].Deadline)
+	suite.False(suite.replayer.Driver().IsScheduledTask(suite.jobID, 0))
+
+	// the job is scheduled to be evaluated after the runtime update
+	// interval following the first event.
+	suite.Equal(
+		suite.start.Add(-time.Minute).Add(time.Hour),
+		suite.replayer.engines[0].NextDeadline())
+}
+
+// TestReplayPodEventsInvalidPodID tests replaying a pod event
+// with an invalid pod identifier.
+func (suite *replayerTestSuite) TestReplayPodEventsInvalidPodID() {
+	event := suite.podEvent(0, 1, pbtask.TaskState_RUNNING, suite.start)
+	event.PodId.Value = "invalid"
+
+	suite.Error(suite.replayer.ReplayPodEvents(
+		context.Background(), suite.jobID, []*pod.PodEvent{event}))
+}
+
+// TestReplayPodEventsOtherJob tests replaying a pod event
+// of another job.
+func (suite *replayerTestSuite) TestReplayPodEventsOtherJob() {
+	event := suite.podEvent(0, 1, pbtask.TaskState_RUNNING, suite.start)
+
+	suite.Error(suite.replayer.ReplayPodEvents(
+		context.Background(),
+		&peloton.JobID{Value: uuid.NewRandom().String()},
+		[]*pod.PodEvent{event}))
+}
+
+// TestReplayPodEventsInvalidTimestamp tests replaying a pod event
+// with an invalid timestamp.
+func (suite *replayerTestSuite) TestReplayPodEventsInvalidTimestamp() {
+	event := suite.podEvent(0, 1, pbtask.TaskState_RUNNING, suite.start)
+	event.Timestamp = "invalid"
+
+	suite.Error(suite.replayer.ReplayPodEvents(
+		context.Background(), suite.jobID, []*pod.PodEvent{event}))
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goalstate
+
+import (
+	"strings"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+
+	"github.com/uber/peloton/pkg/common/goalstate"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	_linearRetryPolicy      = "linear"
+	_exponentialRetryPolicy = "exponential"
+
+	_defaultRetryMultiplier = 2
+)
+
+// withDefaults returns the retry policy config with the unset fields
+// set to the value of the given config.
+func (c RetryPolicyConfig) withDefaults(
+	defaults RetryPolicyConfig) RetryPolicyConfig {
+	if c.Type == "" {
+		c.Type = defaults.Type
+	}
+	if c.InitialDelay == 0 {
+		c.InitialDelay = defaults.InitialDelay
+	}
+	if c.Multiplier == 0 {
+		c.Multiplier = defaults.Multiplier
+	}
+	if c.MaxDelay == 0 {
+		c.MaxDelay = defaults.MaxDelay
+	}
+	if c.Jitter == 0 {
+		c.Jitter = defaults.Jitter
+	}
+	return c
+}
+
+// build returns the retry policy of the config, whose fields have
+// been set to their default values.
+func (c RetryPolicyConfig) build() goalstate.RetryPolicy {
+	var policy goalstate.RetryPolicy
+	switch strings.ToLower(c.Type) {
+	case _exponentialRetryPolicy:
+		policy = goalstate.NewExponentialRetryPolicy(
+			c.InitialDelay, c.Multiplier, c.MaxDelay, c.Jitter)
+	default:
+		if c.Type != _linearRetryPolicy {
+			log.WithField("type", c.Type).
+				Error("unknown goal state retry policy, using linear policy")
+		}
+		policy = goalstate.NewLinearRetryPolicy(c.InitialDelay, c.MaxDelay)
+	}
+
+	if len(c.Actions) == 0 {
+		return policy
+	}
+
+	overrides := make(map[string]goalstate.RetryPolicy)
+	for action, actionConfig := range c.Actions {
+		if actionConfig == nil {
+			continue
+		}
+		override := actionConfig.withDefaults(c)
+		override.Actions = nil
+		overrides[action] = override.build()
+	}
+	return goalstate.NewActionRetryPolicy(policy, overrides)
+}
+
+// jobTypeRetryPolicy implements the retry policy of the goal state
+// engines, which uses the policy configured for the job type of the
+// job of the entity.
+type jobTypeRetryPolicy struct {
+	driver        *driver
+	policies      map[job.JobType]goalstate.RetryPolicy
+	defaultPolicy goalstate.RetryPolicy
+}
+
+// newRetryPolicy returns the retry policy of the goal state engines of
+// the driver, nil if no retry policy is configured.
+func newRetryPolicy(d *driver, cfg *Config) goalstate.RetryPolicy {
+	if len(cfg.RetryPolicies) == 0 {
+		return nil
+	}
+
+	defaults := RetryPolicyConfig{
+		Type:         _linearRetryPolicy,
+		InitialDelay: cfg.FailureRetryDelay,
+		Multiplier:   _defaultRetryMultiplier,
+		MaxDelay:     cfg.MaxRetryDelay,
+	}
+
+	policies := make(map[job.JobType]goalstate.RetryPolicy)
+	for name, policyConfig := range cfg.RetryPolicies {
+		jobType, ok := job.JobType_value[strings.ToUpper(name)]
+		if !ok || policyConfig == nil {
+			log.WithField("job_type", name).
+				Error("ignoring goal state retry policy of unknown job type")
+			continue
+		}
+		policies[job.JobType(jobType)] = policyConfig.withDefaults(defaults).build()
+	}
+
+	return &jobTypeRetryPolicy{
+		driver:        d,
+		policies:      policies,
+		defaultPolicy: defaults.build(),
+	}
+}
+
+func (p *jobTypeRetryPolicy) Delay(
+	entity goalstate.Entity,
+	action string,
+	retries uint32) time.Duration {
+	policy, ok := p.policies[p.driver.entityJobType(entity)]
+	if !ok {
+		policy = p.defaultPolicy
+	}
+	return policy.Delay(entity, action, retries)
+}
+
+// entityJobType returns the type of the job of a job, task or update
+// entity, or the job type of the driver if the job is not in the cache.
+func (d *driver) entityJobType(entity goalstate.Entity) job.JobType {
+	var jobID *peloton.JobID
+	switch e := entity.(type) {
+	case *jobEntity:
+		jobID = e.id
+	case *taskEntity:
+		jobID = e.jobID
+	case *updateEntity:
+		jobID = e.jobID
+	}
+
+	if jobID != nil {
+		if cachedJob := d.jobFactory.GetJob(jobID); cachedJob != nil {
+			return cachedJob.GetJobType()
+		}
+	}
+	return d.jobType
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package goalstate
+
+import (
+	"strconv"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+
+	"github.com/uber/peloton/pkg/common/consistenthash"
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ShardingConfig is the config of the sharding of the goal state of the
+// jobs across the job managers.
+type ShardingConfig struct {
+	// Enabled shards the jobs across all the job managers, instead of
+	// evaluating all of them on the leader. The jobs are hashed to a fixed
+	// number of shards, and each job manager evaluates the jobs of the
+	// shards placed on it by a consistent hash ring of the job managers,
+	// along with their tasks and updates. The job managers which are not
+	// the leader evaluate their jobs using the cache kept warm by the
+	// standby, which must be enabled.
+	Enabled bool `yaml:"enabled"`
+	// NumShards is the number of shards the jobs are hashed to. Each
+	// shard is fenced by a lease in ZK, which must be held by a job
+	// manager before running any action of the jobs of the shard, so
+	// that two job managers never act on the same job while the job
+	// managers change. It must be the same on all the job managers.
+	NumShards uint32 `yaml:"num_shards"`
+	// VirtualNodes is the number of times each job manager is placed on
+	// the consistent hash ring, so that the shards are evenly spread.
+	VirtualNodes int `yaml:"virtual_nodes"`
+}
+
+// shards is the consistent hash ring of the job managers sharing the goal
+// state, along with the member name of this job manager on the ring.
+type shards struct {
+	ring      *consistenthash.Ring
+	member    string
+	numShards uint32
+}
+
+// shardOf returns the shard the job is hashed to
+func (s *shards) shardOf(jobID string) uint32 {
+	return consistenthash.Shard(jobID, s.numShards)
+}
+
+// owns returns true if the shard is placed on this job manager
+func (s *shards) owns(shard uint32) bool {
+	return s != nil &&
+		s.ring.Get(strconv.FormatUint(uint64(shard), 10)) == s.member
+}
+
+// owned returns the shards placed on this job manager
+func (s *shards) owned() []uint32 {
+	var owned []uint32
+	for shard := uint32(0); shard < s.numShards; shard++ {
+		if s.owns(shard) {
+			owned = append(owned, shard)
+		}
+	}
+	return owned
+}
+
+func (d *driver) UpdateShards(member string, members []string) {
+	current := &shards{
+		ring:      consistenthash.New(members, d.cfg.Sharding.VirtualNodes),
+		member:    member,
+		numShards: d.cfg.Sharding.NumShards,
+	}
+
+	d.shardLock.Lock()
+	d.shards = current
+	d.shardLock.Unlock()
+
+	d.mtx.shardMembers.Update(float64(len(current.ring.Members())))
+	log.WithFields(log.Fields{
+		"member":  member,
+		"members": current.ring.Members(),
+	}).Info("goalstate shards updated")
+
+	// The shards not placed on this job manager anymore are not owned
+	// from now on, so their leases are released only once no action of
+	// their jobs can start anymore. The jobs of the shards newly placed
+	// on this job manager are enqueued once their lease is acquired.
+	if d.shardLeases != nil {
+		d.shardLeases.Hold(current.owned())
+	}
+}
+
+func (d *driver) ShardLeaseAcquired(shard uint32) {
+	log.WithField("shard", shard).Info("goalstate shard lease acquired")
+
+	// The entities of the jobs of the shard were dropped while its lease
+	// was not held, evaluate them along with their tasks and updates.
+	// Start enqueues all of them if the driver is not running.
+	if d.runningState() != int32(running) {
+		return
+	}
+	d.shardLock.RLock()
+	current := d.shards
+	d.shardLock.RUnlock()
+	if current == nil {
+		return
+	}
+	d.enqueueCachedJobs(func(id string, _ cached.Job) bool {
+		return current.shardOf(id) == shard
+	})
+}
+
+// isOwned returns true if the goal state of the job is evaluated by this
+// job manager, which is always the case if the goal state is not sharded.
+// When sharded, the shard of the job must be placed on this job manager,
+// and its lease must be held.
+func (d *driver) isOwned(jobID *peloton.JobID) bool {
+	if d.cfg == nil || !d.cfg.Sharding.Enabled {
+		return true
+	}
+
+	d.shardLock.RLock()
+	defer d.shardLock.RUnlock()
+	// nothing is owned until the members are known
+	if d.shards == nil || d.shardLeases == nil {
+		return false
+	}
+	shard := d.shards.shardOf(jobID.GetValue())
+	return d.shards.owns(shard) && d.shardLeases.Holds(shard)
+}
+
+// skipActions returns true if the entities of the given job should not run
+// any action, since the job is paused or is not owned by this job manager.
+// It is checked before running the actions of every entity.
+func (d *driver) skipActions(jobID *peloton.JobID) bool {
+	if d.IsPaused(jobID) {
+		return true
+	}
+	if !d.isOwned(jobID) {
+		d.mtx.shardSkipped.Inc(1)
+		log.WithField("job_id", jobID.GetValue()).
+			Debug("skipping goal state actions of job not owned by this shard")
+		return true
+	}
+	return false
+}
@@ -58,6 +58,9 @@ const (
 	// TerminatedRetryAction helps restart terminated tasks with throttling as well as
 	// fail the task update if the task does not come up for max instance retries.
 	TerminatedRetryAction TaskAction = "terminated_retry"
+	// EnforceDeadlineAction kills the task if it runs longer than the
+	// max running time of its job
+	EnforceDeadlineAction TaskAction = "enforce_deadline"
 	// DeleteAction deletes the task from cache and its runtime from the DB
 	DeleteAction TaskAction = "delete_task"
 	// TaskStateInvalidAction is executed when a task enters
@@ -77,6 +80,7 @@ var (
 		TerminatedRetryAction:  TaskTerminatedRetry,
 		FailRetryAction:        TaskFailRetry,
 		ExecutorShutdownAction: TaskExecutorShutdown,
+		EnforceDeadlineAction:  TaskEnforceDeadline,
 		DeleteAction:           TaskDelete,
 		TaskStateInvalidAction: TaskStateInvalid,
 	}
@@ -95,6 +99,7 @@ var (
 			task.TaskState_INITIALIZED: StartAction,
 			task.TaskState_LAUNCHED:    LaunchRetryAction,
 			task.TaskState_STARTING:    LaunchRetryAction,
+			task.TaskState_RUNNING:     EnforceDeadlineAction,
 			task.TaskState_SUCCEEDED:   TerminatedRetryAction,
 			task.TaskState_FAILED:      TerminatedRetryAction,
 			task.TaskState_KILLED:      TerminatedRetryAction,
@@ -105,6 +110,7 @@ var (
 			task.TaskState_INITIALIZED: StartAction,
 			task.TaskState_LAUNCHED:    LaunchRetryAction,
 			task.TaskState_STARTING:    LaunchRetryAction,
+			task.TaskState_RUNNING:     EnforceDeadlineAction,
 			task.TaskState_FAILED:      FailRetryAction,
 			task.TaskState_KILLED:      FailRetryAction,
 			task.TaskState_LOST:        FailRetryAction,
@@ -128,6 +134,7 @@ var (
 			task.TaskState_LOST:        DeleteAction,
 			task.TaskState_SUCCEEDED:   DeleteAction,
 			task.TaskState_FAILED:      DeleteAction,
+			task.TaskState_FAILED_HOLD: DeleteAction,
 			task.TaskState_KILLED:      DeleteAction,
 		},
 		task.TaskState_FAILED: {
@@ -212,38 +219,57 @@ func (t *taskEntity) GetActionList(
 	[]goalstate.Action) {
 	var actions []goalstate.Action
 
+	if t.driver.skipActions(t.jobID) {
+		return context.Background(), nil, actions
+	}
+
 	taskState := state.(cached.TaskStateVector)
 	taskGoalState := goalState.(cached.TaskStateVector)
 
 	ctx, cancel := context.WithTimeout(context.Background(), _defaultTaskActionTimeout)
 
+	actionStr, actions := t.actions(taskState, taskGoalState)
+	log.WithField("job_id", t.jobID.GetValue()).
+		WithField("instance_id", t.instanceID).
+		WithField("current_state", taskState.State.String()).
+		WithField("goal_state", taskGoalState.State.String()).
+		WithField("task_action", actionStr).
+		Info("running task action")
+
+	return ctx, cancel, actions
+}
+
+// actions returns the actions to run to bring the task from its state to
+// its goal state, along with the name of the suggested task action.
+func (t *taskEntity) actions(
+	taskState cached.TaskStateVector,
+	taskGoalState cached.TaskStateVector,
+) (TaskAction, []goalstate.Action) {
+	var actions []goalstate.Action
+
 	if taskState.State == task.TaskState_UNKNOWN || taskGoalState.State == task.TaskState_UNKNOWN {
 		// no runtime in cache, reload the task runtime
 		actions = append(actions, goalstate.Action{
 			Name:    string(ReloadTaskRuntime),
 			Execute: TaskReloadRuntime,
 		})
-		return ctx, cancel, actions
+		return ReloadTaskRuntime, actions
 	}
 
 	actionStr := t.suggestTaskAction(taskState, taskGoalState)
 	action := _taskActionsMaps[actionStr]
 
-	log.WithField("job_id", t.jobID.GetValue()).
-		WithField("instance_id", t.instanceID).
-		WithField("current_state", taskState.State.String()).
-		WithField("goal_state", taskGoalState.State.String()).
-		WithField("task_action", actionStr).
-		Info("running task action")
-
 	if action != nil {
+		if _limitedTaskActions[actionStr] {
+			action = t.driver.limitTaskAction(action)
+		}
 		actions = append(actions, goalstate.Action{
 			Name:    string(actionStr),
 			Execute: action,
 		})
 	}
 
-	return ctx, cancel, actions
+	return actionStr, actions
 }
 
 // suggestTaskAction provides the task action for a given state and goal state
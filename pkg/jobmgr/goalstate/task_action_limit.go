@@ -0,0 +1,228 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package goalstate
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/uber/peloton/pkg/common/goalstate"
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// _taskActionThrottleDelay is the delay before evaluating again a task
+// whose action was throttled because too many tasks of its job were
+// launched or killed concurrently.
+const _taskActionThrottleDelay = 1 * time.Second
+
+// _limitedTaskActions are the task actions which launch or kill tasks,
+// whose concurrency is limited per job.
+var _limitedTaskActions = map[TaskAction]bool{
+	StartAction:            true,
+	StopAction:             true,
+	ExecutorShutdownAction: true,
+}
+
+// _transitTaskStates are the states of the tasks being launched or killed
+// on the hosts, which count towards the limit of the tasks of a job
+// launched or killed concurrently.
+var _transitTaskStates = map[task.TaskState]bool{
+	task.TaskState_LAUNCHING: true,
+	task.TaskState_LAUNCHED:  true,
+	task.TaskState_STARTING:  true,
+	task.TaskState_KILLING:   true,
+}
+
+// jobActionLimiter counts the actions running concurrently for each job.
+// The zero value is ready to use.
+type jobActionLimiter struct {
+	sync.Mutex
+	// number of actions running for each job
+	running map[string]uint32
+}
+
+// tryAcquire counts an action running for the job, and returns false if
+// the job has already reached the limit of actions running concurrently.
+func (l *jobActionLimiter) tryAcquire(jobID string, limit uint32) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.running == nil {
+		l.running = make(map[string]uint32)
+	}
+	if l.running[jobID] >= limit {
+		return false
+	}
+	l.running[jobID]++
+	return true
+}
+
+// release counts an action of the job as done.
+func (l *jobActionLimiter) release(jobID string) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.running[jobID] <= 1 {
+		delete(l.running, jobID)
+		return
+	}
+	l.running[jobID]--
+}
+
+// getMaxConcurrentTaskActions returns the maximum number of tasks of a job
+// launched or killed concurrently, which is zero if not limited. The
+// limit of the job overrides the limit of the cluster.
+func (d *driver) getMaxConcurrentTaskActions(
+	ctx context.Context,
+	jobID *peloton.JobID,
+) uint32 {
+	cachedJob := d.jobFactory.GetJob(jobID)
+	if cachedJob == nil {
+		return d.cfg.MaxConcurrentTaskActionsPerJob
+	}
+	jobConfig, err := cachedJob.GetConfig(ctx)
+	if err != nil {
+		return d.cfg.MaxConcurrentTaskActionsPerJob
+	}
+	return d.getJobMaxConcurrentTaskActions(jobConfig)
+}
+
+// getJobMaxConcurrentTaskActions returns the maximum number of tasks of a
+// job launched or killed concurrently given its config.
+func (d *driver) getJobMaxConcurrentTaskActions(
+	jobConfig interface {
+		GetMaxConcurrentTaskActions() uint32
+	},
+) uint32 {
+	if jobConfig.GetMaxConcurrentTaskActions() == 0 {
+		return d.cfg.MaxConcurrentTaskActionsPerJob
+	}
+	return jobConfig.GetMaxConcurrentTaskActions()
+}
+
+// getTransitTaskCount returns the number of tasks of a job being launched
+// or killed on the hosts.
+func getTransitTaskCount(cachedJob cached.Job) uint32 {
+	var count uint32
+	for state, goalStates := range cachedJob.GetStateCount() {
+		if !_transitTaskStates[state] {
+			continue
+		}
+		for _, n := range goalStates {
+			count += uint32(n)
+		}
+	}
+	return count
+}
+
+// getTaskActionSlots returns how many more actions can be run for the task
+// of a job given the limit of the job. The tasks of the job being launched
+// or killed on the hosts take up slots, except for the actions of such a
+// task, which are needed to complete the transition.
+func (d *driver) getTaskActionSlots(
+	jobID *peloton.JobID,
+	instanceID uint32,
+	limit uint32,
+) uint32 {
+	cachedJob := d.jobFactory.GetJob(jobID)
+	if cachedJob == nil {
+		return limit
+	}
+	if cachedTask := cachedJob.GetTask(instanceID); cachedTask != nil &&
+		_transitTaskStates[cachedTask.CurrentState().State] {
+		return limit
+	}
+	if transit := getTransitTaskCount(cachedJob); transit < limit {
+		return limit - transit
+	}
+	return 0
+}
+
+// limitTasksToStart returns the first tasks of a job which can be sent to
+// resource manager within the limit of the job, and evaluates the others
+// again after a delay, so that they are started by the limited task start
+// action.
+func (d *driver) limitTasksToStart(
+	jobID *peloton.JobID,
+	jobConfig *job.JobConfig,
+	tasks []*task.TaskInfo,
+) []*task.TaskInfo {
+	limit := d.getJobMaxConcurrentTaskActions(jobConfig)
+	if limit == 0 || uint32(len(tasks)) <= limit {
+		return tasks
+	}
+
+	slots := limit
+	if cachedJob := d.jobFactory.GetJob(jobID); cachedJob != nil {
+		if transit := getTransitTaskCount(cachedJob); transit < limit {
+			slots = limit - transit
+		} else {
+			slots = 0
+		}
+	}
+	if uint32(len(tasks)) <= slots {
+		return tasks
+	}
+
+	d.mtx.taskMetrics.TaskActionThrottled.Inc(int64(uint32(len(tasks)) - slots))
+	for _, t := range tasks[slots:] {
+		d.EnqueueTask(
+			jobID,
+			t.GetInstanceId(),
+			d.now().Add(_taskActionThrottleDelay))
+	}
+	return tasks[:slots]
+}
+
+// limitTaskAction wraps a task action launching or killing a task, so
+// that it is run only if the job of the task has not reached its limit of
+// tasks launched or killed concurrently, which counts both the actions
+// running and the tasks being launched or killed on the hosts. Otherwise
+// the task is evaluated again after a delay, without running the action.
+func (d *driver) limitTaskAction(
+	execute goalstate.ActionExecute,
+) goalstate.ActionExecute {
+	return func(ctx context.Context, entity goalstate.Entity) error {
+		taskEnt := entity.(*taskEntity)
+		limit := d.getMaxConcurrentTaskActions(ctx, taskEnt.jobID)
+		if limit == 0 {
+			return execute(ctx, entity)
+		}
+
+		slots := d.getTaskActionSlots(taskEnt.jobID, taskEnt.instanceID, limit)
+		if slots == 0 ||
+			!d.taskActionLimiter.tryAcquire(taskEnt.jobID.GetValue(), slots) {
+			d.mtx.taskMetrics.TaskActionThrottled.Inc(1)
+			log.WithField("job_id", taskEnt.jobID.GetValue()).
+				WithField("instance_id", taskEnt.instanceID).
+				WithField("limit", limit).
+				Debug("task action throttled by the job concurrency limit")
+			d.EnqueueTask(
+				taskEnt.jobID,
+				taskEnt.instanceID,
+				d.now().Add(_taskActionThrottleDelay))
+			return nil
+		}
+		defer d.taskActionLimiter.release(taskEnt.jobID.GetValue())
+
+		return execute(ctx, entity)
+	}
+}
@@ -0,0 +1,288 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package goalstate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/uber/peloton/pkg/common/goalstate"
+	goalstatemocks "github.com/uber/peloton/pkg/common/goalstate/mocks"
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally"
+)
+
+// TestJobActionLimiter tests counting the actions running for each job
+func TestJobActionLimiter(t *testing.T) {
+	var l jobActionLimiter
+
+	assert.True(t, l.tryAcquire("job1", 2))
+	assert.True(t, l.tryAcquire("job1", 2))
+	assert.False(t, l.tryAcquire("job1", 2))
+
+	// other jobs have their own count
+	assert.True(t, l.tryAcquire("job2", 1))
+
+	l.release("job1")
+	assert.True(t, l.tryAcquire("job1", 2))
+
+	l.release("job1")
+	l.release("job1")
+	l.release("job2")
+	assert.Empty(t, l.running)
+}
+
+// TestLimitTaskAction tests that the launch and kill actions of the tasks
+// of a job are throttled by the concurrency limit of the job
+func TestLimitTaskAction(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	jobFactory := cachedmocks.NewMockJobFactory(ctrl)
+	cachedJob := cachedmocks.NewMockJob(ctrl)
+	cachedConfig := cachedmocks.NewMockJobConfigCache(ctrl)
+	taskEngine := goalstatemocks.NewMockEngine(ctrl)
+
+	goalStateDriver := &driver{
+		jobFactory: jobFactory,
+		taskEngine: taskEngine,
+		mtx:        NewMetrics(tally.NoopScope),
+		cfg:        &Config{MaxConcurrentTaskActionsPerJob: 2},
+	}
+	goalStateDriver.cfg.normalize()
+
+	jobID := &peloton.JobID{Value: uuid.NewRandom().String()}
+	taskEnt := &taskEntity{
+		jobID:      jobID,
+		instanceID: 0,
+		driver:     goalStateDriver,
+	}
+
+	executed := 0
+	action := goalStateDriver.limitTaskAction(
+		func(ctx context.Context, entity goalstate.Entity) error {
+			executed++
+			return nil
+		})
+
+	jobFactory.EXPECT().
+		GetJob(jobID).
+		Return(cachedJob).
+		AnyTimes()
+	cachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(cachedConfig, nil).
+		AnyTimes()
+	cachedTask := cachedmocks.NewMockTask(ctrl)
+	cachedJob.EXPECT().
+		GetTask(uint32(0)).
+		Return(cachedTask).
+		AnyTimes()
+	cachedTask.EXPECT().
+		CurrentState().
+		Return(cached.TaskStateVector{State: task.TaskState_INITIALIZED}).
+		AnyTimes()
+	cachedJob.EXPECT().
+		GetStateCount().
+		Return(map[task.TaskState]map[task.TaskState]int{}).
+		AnyTimes()
+
+	// the limit of the job overrides the limit of the cluster
+	cachedConfig.EXPECT().
+		GetMaxConcurrentTaskActions().
+		Return(uint32(1)).
+		AnyTimes()
+	assert.Equal(t, uint32(1), goalStateDriver.getMaxConcurrentTaskActions(
+		context.Background(), jobID))
+
+	// another task of the job is being launched, so the task is
+	// evaluated again later without running its action
+	assert.True(t, goalStateDriver.taskActionLimiter.tryAcquire(
+		jobID.GetValue(), 1))
+	taskEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any())
+	assert.NoError(t, action(context.Background(), taskEnt))
+	assert.Equal(t, 0, executed)
+
+	goalStateDriver.taskActionLimiter.release(jobID.GetValue())
+	assert.NoError(t, action(context.Background(), taskEnt))
+	assert.Equal(t, 1, executed)
+	assert.Empty(t, goalStateDriver.taskActionLimiter.running)
+}
+
+// TestGetMaxConcurrentTaskActionsDefault tests that the limit of the
+// cluster is used for the jobs which do not set one
+func TestGetMaxConcurrentTaskActionsDefault(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	jobFactory := cachedmocks.NewMockJobFactory(ctrl)
+	cachedJob := cachedmocks.NewMockJob(ctrl)
+	cachedConfig := cachedmocks.NewMockJobConfigCache(ctrl)
+
+	goalStateDriver := &driver{
+		jobFactory: jobFactory,
+		mtx:        NewMetrics(tally.NoopScope),
+		cfg:        &Config{MaxConcurrentTaskActionsPerJob: 10},
+	}
+	jobID := &peloton.JobID{Value: uuid.NewRandom().String()}
+
+	jobFactory.EXPECT().
+		GetJob(jobID).
+		Return(nil)
+	assert.Equal(t, uint32(10), goalStateDriver.getMaxConcurrentTaskActions(
+		context.Background(), jobID))
+
+	jobFactory.EXPECT().
+		GetJob(jobID).
+		Return(cachedJob)
+	cachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(cachedConfig, nil)
+	cachedConfig.EXPECT().
+		GetMaxConcurrentTaskActions().
+		Return(uint32(0))
+	assert.Equal(t, uint32(10), goalStateDriver.getMaxConcurrentTaskActions(
+		context.Background(), jobID))
+}
+
+// TestLimitTaskActionTransitTasks tests that the tasks of a job being
+// launched or killed on the hosts count towards the limit of the job,
+// except for the actions of those tasks
+func TestLimitTaskActionTransitTasks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	jobFactory := cachedmocks.NewMockJobFactory(ctrl)
+	cachedJob := cachedmocks.NewMockJob(ctrl)
+	cachedConfig := cachedmocks.NewMockJobConfigCache(ctrl)
+	cachedTask := cachedmocks.NewMockTask(ctrl)
+	taskEngine := goalstatemocks.NewMockEngine(ctrl)
+
+	goalStateDriver := &driver{
+		jobFactory: jobFactory,
+		taskEngine: taskEngine,
+		mtx:        NewMetrics(tally.NoopScope),
+		cfg:        &Config{MaxConcurrentTaskActionsPerJob: 2},
+	}
+	goalStateDriver.cfg.normalize()
+
+	jobID := &peloton.JobID{Value: uuid.NewRandom().String()}
+	taskEnt := &taskEntity{
+		jobID:      jobID,
+		instanceID: 0,
+		driver:     goalStateDriver,
+	}
+
+	executed := 0
+	action := goalStateDriver.limitTaskAction(
+		func(ctx context.Context, entity goalstate.Entity) error {
+			executed++
+			return nil
+		})
+
+	jobFactory.EXPECT().
+		GetJob(jobID).
+		Return(cachedJob).
+		AnyTimes()
+	cachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(cachedConfig, nil).
+		AnyTimes()
+	cachedConfig.EXPECT().
+		GetMaxConcurrentTaskActions().
+		Return(uint32(0)).
+		AnyTimes()
+	cachedJob.EXPECT().
+		GetTask(uint32(0)).
+		Return(cachedTask).
+		AnyTimes()
+	cachedJob.EXPECT().
+		GetStateCount().
+		Return(map[task.TaskState]map[task.TaskState]int{
+			task.TaskState_LAUNCHED: {task.TaskState_RUNNING: 1},
+			task.TaskState_KILLING:  {task.TaskState_KILLED: 1},
+			task.TaskState_PENDING:  {task.TaskState_RUNNING: 5},
+		}).
+		AnyTimes()
+
+	// two other tasks are being launched or killed
+	cachedTask.EXPECT().
+		CurrentState().
+		Return(cached.TaskStateVector{State: task.TaskState_INITIALIZED})
+	taskEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any())
+	assert.NoError(t, action(context.Background(), taskEnt))
+	assert.Equal(t, 0, executed)
+
+	// the task is being killed itself
+	cachedTask.EXPECT().
+		CurrentState().
+		Return(cached.TaskStateVector{State: task.TaskState_KILLING})
+	assert.NoError(t, action(context.Background(), taskEnt))
+	assert.Equal(t, 1, executed)
+}
+
+// TestLimitTasksToStart tests that only the tasks within the limit of the
+// job are sent to resource manager, and that the others are evaluated
+// again later
+func TestLimitTasksToStart(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	jobFactory := cachedmocks.NewMockJobFactory(ctrl)
+	cachedJob := cachedmocks.NewMockJob(ctrl)
+	taskEngine := goalstatemocks.NewMockEngine(ctrl)
+
+	goalStateDriver := &driver{
+		jobFactory: jobFactory,
+		taskEngine: taskEngine,
+		mtx:        NewMetrics(tally.NoopScope),
+		cfg:        &Config{},
+	}
+	goalStateDriver.cfg.normalize()
+
+	jobID := &peloton.JobID{Value: uuid.NewRandom().String()}
+	var tasks []*task.TaskInfo
+	for i := uint32(0); i < 5; i++ {
+		tasks = append(tasks, &task.TaskInfo{JobId: jobID, InstanceId: i})
+	}
+
+	// not limited
+	assert.Len(t, goalStateDriver.limitTasksToStart(
+		jobID, &job.JobConfig{}, tasks), 5)
+
+	jobFactory.EXPECT().
+		GetJob(jobID).
+		Return(cachedJob)
+	cachedJob.EXPECT().
+		GetStateCount().
+		Return(map[task.TaskState]map[task.TaskState]int{
+			task.TaskState_LAUNCHING: {task.TaskState_RUNNING: 1},
+		})
+	taskEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any()).
+		Times(3)
+	assert.Equal(t, tasks[:2], goalStateDriver.limitTasksToStart(
+		jobID, &job.JobConfig{MaxConcurrentTaskActions: 3}, tasks))
+}
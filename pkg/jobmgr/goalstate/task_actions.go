@@ -54,7 +54,7 @@ func TaskReloadRuntime(ctx context.Context, entity goalstate.Entity) error {
 	// This function is called when the runtime in cache is nil.
 	// The task needs to re-enqueued into the goal state engine
 	// so that it the corresponding action can be executed.
-	goalStateDriver.EnqueueTask(taskEnt.jobID, taskEnt.instanceID, time.Now())
+	goalStateDriver.EnqueueTask(taskEnt.jobID, taskEnt.instanceID, goalStateDriver.now())
 	return nil
 }
 
@@ -98,6 +98,7 @@ func TaskDelete(ctx context.Context, entity goalstate.Entity) error {
 	// enqueue the job in case the delete is due to an update,
 	// this is done even if db op has error, because the entry may
 	// actually be removed
-	EnqueueJobWithDefaultDelay(taskEnt.jobID, goalStateDriver, cachedJob)
+	goalStateDriver.EnqueueJobWithDefaultDelay(
+		taskEnt.jobID, cachedJob.GetJobType())
 	return err
 }
@@ -0,0 +1,113 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goalstate
+
+import (
+	"context"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/uber/peloton/pkg/common/goalstate"
+	jobmgrcommon "github.com/uber/peloton/pkg/jobmgr/common"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TaskEnforceDeadline kills a running task which has been running for
+// longer than the maximum running time in the SLA of its job. The task
+// is marked FAILED with a deadline exceeded termination status once it
+// has been killed. Tasks which did not reach their deadline yet are
+// enqueued again to be checked at the deadline. It runs for the running
+// tasks of all the jobs, whether their goal state is RUNNING or SUCCEEDED.
+func TaskEnforceDeadline(ctx context.Context, entity goalstate.Entity) error {
+	taskEnt := entity.(*taskEntity)
+	goalStateDriver := taskEnt.driver
+	cachedJob := goalStateDriver.jobFactory.GetJob(taskEnt.jobID)
+	if cachedJob == nil {
+		return nil
+	}
+	cachedTask := cachedJob.GetTask(taskEnt.instanceID)
+	if cachedTask == nil {
+		log.WithFields(log.Fields{
+			"job_id":      taskEnt.jobID.GetValue(),
+			"instance_id": taskEnt.instanceID,
+		}).Error("task is nil in cache with valid job")
+		return nil
+	}
+
+	config, err := cachedJob.GetConfig(ctx)
+	if err != nil {
+		return err
+	}
+	maxRunningTime := time.Duration(
+		config.GetSLA().GetMaxRunningTime()) * time.Second
+	if maxRunningTime == 0 {
+		return nil
+	}
+
+	runtime, err := cachedTask.GetRuntime(ctx)
+	if err != nil {
+		return err
+	}
+	if runtime.GetState() != task.TaskState_RUNNING {
+		return nil
+	}
+
+	startTime, err := time.Parse(time.RFC3339Nano, runtime.GetStartTime())
+	if err != nil {
+		log.WithError(err).
+			WithFields(log.Fields{
+				"job_id":      taskEnt.jobID.GetValue(),
+				"instance_id": taskEnt.instanceID,
+				"start_time":  runtime.GetStartTime(),
+			}).Warn("failed to parse start time of running task")
+		return nil
+	}
+
+	deadline := startTime.Add(maxRunningTime)
+	if goalStateDriver.now().Before(deadline) {
+		goalStateDriver.EnqueueTask(taskEnt.jobID, taskEnt.instanceID, deadline)
+		return nil
+	}
+
+	log.WithFields(log.Fields{
+		"job_id":           taskEnt.jobID.GetValue(),
+		"instance_id":      taskEnt.instanceID,
+		"start_time":       runtime.GetStartTime(),
+		"max_running_time": maxRunningTime.String(),
+	}).Info("task exceeded the max running time, killing the task")
+
+	runtimeDiff := jobmgrcommon.RuntimeDiff{
+		jobmgrcommon.GoalStateField: task.TaskState_KILLED,
+		jobmgrcommon.ReasonField:    "Deadline exceeded",
+		jobmgrcommon.MessageField: "Task exceeded the max running time of " +
+			maxRunningTime.String(),
+		jobmgrcommon.TerminationStatusField: &task.TerminationStatus{
+			Reason: task.TerminationStatus_TERMINATION_STATUS_REASON_DEADLINE_TIMEOUT_EXCEEDED,
+		},
+	}
+	err = cachedJob.PatchTasks(ctx,
+		map[uint32]jobmgrcommon.RuntimeDiff{taskEnt.instanceID: runtimeDiff})
+	if err != nil {
+		return err
+	}
+
+	goalStateDriver.mtx.taskMetrics.TaskDeadlineExceeded.Inc(1)
+	goalStateDriver.EnqueueTask(taskEnt.jobID, taskEnt.instanceID, goalStateDriver.now())
+	goalStateDriver.EnqueueJobWithDefaultDelay(
+		taskEnt.jobID, cachedJob.GetJobType())
+	return nil
+}
@@ -0,0 +1,242 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goalstate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pbjob "github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	pbtask "github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/uber/peloton/pkg/common/goalstate"
+	goalstatemocks "github.com/uber/peloton/pkg/common/goalstate/mocks"
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
+	jobmgrcommon "github.com/uber/peloton/pkg/jobmgr/common"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+)
+
+type TaskDeadlineTestSuite struct {
+	suite.Suite
+
+	ctrl                *gomock.Controller
+	jobGoalStateEngine  *goalstatemocks.MockEngine
+	taskGoalStateEngine *goalstatemocks.MockEngine
+	jobFactory          *cachedmocks.MockJobFactory
+	cachedJob           *cachedmocks.MockJob
+	cachedTask          *cachedmocks.MockTask
+	jobConfig           *cachedmocks.MockJobConfigCache
+	clock               *goalstate.ManualClock
+	goalStateDriver     *driver
+	jobID               *peloton.JobID
+	instanceID          uint32
+	taskEnt             *taskEntity
+}
+
+func TestTaskDeadline(t *testing.T) {
+	suite.Run(t, new(TaskDeadlineTestSuite))
+}
+
+func (suite *TaskDeadlineTestSuite) SetupTest() {
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.jobGoalStateEngine = goalstatemocks.NewMockEngine(suite.ctrl)
+	suite.taskGoalStateEngine = goalstatemocks.NewMockEngine(suite.ctrl)
+	suite.jobFactory = cachedmocks.NewMockJobFactory(suite.ctrl)
+	suite.cachedJob = cachedmocks.NewMockJob(suite.ctrl)
+	suite.cachedTask = cachedmocks.NewMockTask(suite.ctrl)
+	suite.jobConfig = cachedmocks.NewMockJobConfigCache(suite.ctrl)
+	suite.clock = goalstate.NewManualClock(time.Now())
+
+	suite.goalStateDriver = &driver{
+		jobEngine:  suite.jobGoalStateEngine,
+		taskEngine: suite.taskGoalStateEngine,
+		jobFactory: suite.jobFactory,
+		mtx:        NewMetrics(tally.NoopScope),
+		cfg:        &Config{},
+		clock:      suite.clock,
+	}
+	suite.goalStateDriver.cfg.normalize()
+
+	suite.jobID = &peloton.JobID{Value: uuid.NewRandom().String()}
+	suite.instanceID = uint32(0)
+	suite.taskEnt = &taskEntity{
+		jobID:      suite.jobID,
+		instanceID: suite.instanceID,
+		driver:     suite.goalStateDriver,
+	}
+}
+
+func (suite *TaskDeadlineTestSuite) TearDownTest() {
+	suite.ctrl.Finish()
+}
+
+// expectTask sets up the expectations to fetch the task of a job with
+// the given max running time from the cache.
+func (suite *TaskDeadlineTestSuite) expectTask(maxRunningTime uint32) {
+	suite.jobFactory.EXPECT().
+		GetJob(suite.jobID).
+		Return(suite.cachedJob)
+	suite.cachedJob.EXPECT().
+		GetTask(suite.instanceID).
+		Return(suite.cachedTask)
+	suite.cachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(suite.jobConfig, nil)
+	suite.jobConfig.EXPECT().
+		GetSLA().
+		Return(&pbjob.SlaConfig{MaxRunningTime: maxRunningTime})
+}
+
+// expectRuntime sets up the expectations to fetch the runtime of a task
+// which started running for the given duration.
+func (suite *TaskDeadlineTestSuite) expectRuntime(running time.Duration) {
+	suite.cachedTask.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(&pbtask.RuntimeInfo{
+			State:     pbtask.TaskState_RUNNING,
+			GoalState: pbtask.TaskState_SUCCEEDED,
+			StartTime: suite.clock.Now().Add(-running).
+				Format(time.RFC3339Nano),
+		}, nil)
+}
+
+// TestTaskEnforceDeadlineNoMaxRunningTime tests that tasks of jobs
+// without a max running time are not tracked.
+func (suite *TaskDeadlineTestSuite) TestTaskEnforceDeadlineNoMaxRunningTime() {
+	suite.expectTask(0)
+
+	suite.NoError(TaskEnforceDeadline(context.Background(), suite.taskEnt))
+}
+
+// TestTaskEnforceDeadlineNotExceeded tests that a task which did not reach
+// its deadline yet is enqueued again at its deadline.
+func (suite *TaskDeadlineTestSuite) TestTaskEnforceDeadlineNotExceeded() {
+	suite.expectTask(60)
+	suite.expectRuntime(20 * time.Second)
+
+	suite.taskGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any()).
+		Do(func(_ goalstate.Entity, deadline time.Time) {
+			suite.True(
+				deadline.Sub(suite.clock.Now()) > 39*time.Second)
+			suite.True(
+				deadline.Sub(suite.clock.Now()) <= 40*time.Second)
+		})
+
+	suite.NoError(TaskEnforceDeadline(context.Background(), suite.taskEnt))
+}
+
+// TestTaskEnforceDeadlineExceeded tests that a task which ran past its
+// deadline is killed with a deadline exceeded termination status.
+func (suite *TaskDeadlineTestSuite) TestTaskEnforceDeadlineExceeded() {
+	suite.expectTask(60)
+	suite.expectRuntime(2 * time.Minute)
+
+	suite.cachedJob.EXPECT().
+		PatchTasks(gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context,
+			runtimeDiffs map[uint32]jobmgrcommon.RuntimeDiff) {
+			runtimeDiff := runtimeDiffs[suite.instanceID]
+			suite.Equal(
+				pbtask.TaskState_KILLED,
+				runtimeDiff[jobmgrcommon.GoalStateField])
+			suite.Equal(
+				pbtask.TerminationStatus_TERMINATION_STATUS_REASON_DEADLINE_TIMEOUT_EXCEEDED,
+				runtimeDiff[jobmgrcommon.TerminationStatusField].(*pbtask.TerminationStatus).GetReason())
+		}).
+		Return(nil)
+	suite.taskGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), suite.clock.Now())
+	suite.cachedJob.EXPECT().
+		GetJobType().
+		Return(pbjob.JobType_BATCH)
+	suite.jobGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any())
+
+	suite.NoError(TaskEnforceDeadline(context.Background(), suite.taskEnt))
+}
+
+// TestTaskEnforceDeadlineGoalRunning tests that a task whose goal state is
+// RUNNING, such as the tasks of stateless jobs, is killed once it ran past
+// its deadline.
+func (suite *TaskDeadlineTestSuite) TestTaskEnforceDeadlineGoalRunning() {
+	suite.Equal(EnforceDeadlineAction, suite.taskEnt.suggestTaskAction(
+		cached.TaskStateVector{State: pbtask.TaskState_RUNNING},
+		cached.TaskStateVector{State: pbtask.TaskState_RUNNING}))
+
+	suite.expectTask(60)
+	suite.cachedTask.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(&pbtask.RuntimeInfo{
+			State:     pbtask.TaskState_RUNNING,
+			GoalState: pbtask.TaskState_RUNNING,
+			StartTime: suite.clock.Now().Add(-2 * time.Minute).
+				Format(time.RFC3339Nano),
+		}, nil)
+
+	suite.cachedJob.EXPECT().
+		PatchTasks(gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context,
+			runtimeDiffs map[uint32]jobmgrcommon.RuntimeDiff) {
+			suite.Equal(
+				pbtask.TaskState_KILLED,
+				runtimeDiffs[suite.instanceID][jobmgrcommon.GoalStateField])
+		}).
+		Return(nil)
+	suite.taskGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), suite.clock.Now())
+	suite.cachedJob.EXPECT().
+		GetJobType().
+		Return(pbjob.JobType_SERVICE)
+	suite.jobGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any())
+
+	suite.NoError(TaskEnforceDeadline(context.Background(), suite.taskEnt))
+}
+
+// TestTaskEnforceDeadlinePatchFailure tests that failing to kill a task
+// which ran past its deadline returns an error to retry the action.
+func (suite *TaskDeadlineTestSuite) TestTaskEnforceDeadlinePatchFailure() {
+	suite.expectTask(60)
+	suite.expectRuntime(2 * time.Minute)
+
+	suite.cachedJob.EXPECT().
+		PatchTasks(gomock.Any(), gomock.Any()).
+		Return(errors.New("test error"))
+
+	suite.Error(TaskEnforceDeadline(context.Background(), suite.taskEnt))
+}
+
+// TestTaskEnforceDeadlineNotRunning tests that tasks which are no longer
+// running are ignored.
+func (suite *TaskDeadlineTestSuite) TestTaskEnforceDeadlineNotRunning() {
+	suite.expectTask(60)
+	suite.cachedTask.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(&pbtask.RuntimeInfo{
+			State:     pbtask.TaskState_SUCCEEDED,
+			GoalState: pbtask.TaskState_SUCCEEDED,
+		}, nil)
+
+	suite.NoError(TaskEnforceDeadline(context.Background(), suite.taskEnt))
+}
@@ -30,13 +30,17 @@ import (
 )
 
 const (
-	_rescheduleMessage = "Rescheduled after task terminated"
-	_throttleMessage   = "Task throttled due to failure"
+	_rescheduleMessage            = "Rescheduled after task terminated"
+	_hostFailureRescheduleMessage = "Rescheduled after host failure"
+	_throttleMessage              = "Task throttled due to failure"
+	_crashLoopHoldMessage         = "Task held after failing repeatedly"
 )
 
 // rescheduleTask patch the new job runtime and enqueue the task into goalstate engine
 // When JobMgr restarts, the task would be throttled again. Therefore, a task can be throttled
 // for more than the duration returned by getBackoff.
+// If hostFailure is set, the task is rescheduled right away without any
+// throttling since the failure is not caused by the task itself.
 func rescheduleTask(
 	ctx context.Context,
 	cachedJob cached.Job,
@@ -44,7 +48,8 @@ func rescheduleTask(
 	taskRuntime *task.RuntimeInfo,
 	taskConfig *task.TaskConfig,
 	goalStateDriver *driver,
-	throttleOnFailure bool) error {
+	throttleOnFailure bool,
+	hostFailure bool) error {
 
 	jobID := cachedJob.ID()
 	healthState := taskutil.GetInitialHealthState(taskConfig)
@@ -55,14 +60,27 @@ func rescheduleTask(
 		goalStateDriver.mtx.taskMetrics.RetryFailedTasksTotal.Inc(1)
 	}
 
+	rescheduleMessage := _rescheduleMessage
+	if hostFailure {
+		goalStateDriver.mtx.taskMetrics.RetryHostFailureTotal.Inc(1)
+		rescheduleMessage = _hostFailureRescheduleMessage
+		throttleOnFailure = false
+	}
+
 	var runtimeDiff jobmgrcommon.RuntimeDiff
-	scheduleDelay := getScheduleDelay(
-		cachedTask,
-		taskRuntime,
-		goalStateDriver.cfg.InitialTaskBackoff,
-		goalStateDriver.cfg.MaxTaskBackoff,
-		throttleOnFailure,
-	)
+	scheduleDelay := time.Duration(0)
+	if throttleOnFailure {
+		policy, err := getTaskBackoffPolicy(ctx, cachedJob, goalStateDriver.cfg)
+		if err != nil {
+			return err
+		}
+		scheduleDelay = getScheduleDelay(
+			cachedTask,
+			taskRuntime,
+			policy,
+			goalStateDriver.now(),
+		)
+	}
 
 	if scheduleDelay <= time.Duration(0) {
 		// scheduleDelay is negative, which means the task
@@ -72,9 +90,10 @@ func rescheduleTask(
 			cachedTask.ID(),
 			taskRuntime,
 			healthState)
-		runtimeDiff[jobmgrcommon.MessageField] = _rescheduleMessage
+		runtimeDiff[jobmgrcommon.MessageField] = rescheduleMessage
 		log.WithField("job_id", jobID).
 			WithField("instance_id", cachedTask.ID()).
+			WithField("host_failure", hostFailure).
 			Debug("restarting terminated task")
 	} else if taskRuntime.GetMessage() != _throttleMessage {
 		// only update the message when the throttled task enters
@@ -92,12 +111,60 @@ func rescheduleTask(
 		}
 	}
 
-	goalStateDriver.EnqueueTask(jobID, cachedTask.ID(), time.Now().Add(scheduleDelay))
-	EnqueueJobWithDefaultDelay(jobID, goalStateDriver, cachedJob)
+	goalStateDriver.EnqueueTask(jobID, cachedTask.ID(), goalStateDriver.now().Add(scheduleDelay))
+	goalStateDriver.EnqueueJobWithDefaultDelay(
+		jobID, cachedJob.GetJobType())
 
 	return nil
 }
 
+// taskBackoffPolicy is the policy of the delay before recreating the
+// failed tasks of a job.
+type taskBackoffPolicy struct {
+	initialDelay time.Duration
+	multiplier   float64
+	maxDelay     time.Duration
+}
+
+// getTaskBackoffPolicy returns the task backoff policy of a job, which
+// is the policy in the goal state config overridden by the fields set
+// in the task backoff policy of the job config. The policy is validated
+// when the job is created or updated, but is clamped again since the job
+// may have been configured before the policy was validated.
+func getTaskBackoffPolicy(
+	ctx context.Context,
+	cachedJob cached.Job,
+	cfg *Config,
+) (taskBackoffPolicy, error) {
+	policy := taskBackoffPolicy{
+		initialDelay: cfg.InitialTaskBackoff,
+		multiplier:   cfg.TaskBackoffMultiplier,
+		maxDelay:     cfg.MaxTaskBackoff,
+	}
+
+	jobConfig, err := cachedJob.GetConfig(ctx)
+	if err != nil {
+		return policy, err
+	}
+
+	jobPolicy := jobConfig.GetTaskBackoffPolicy()
+	if jobPolicy.GetInitialDelayMs() > 0 {
+		policy.initialDelay =
+			time.Duration(jobPolicy.GetInitialDelayMs()) * time.Millisecond
+	}
+	if m := jobPolicy.GetMultiplier(); m >= 1 && !math.IsInf(m, 0) {
+		policy.multiplier = m
+	}
+	if jobPolicy.GetMaxDelayMs() > 0 {
+		policy.maxDelay =
+			time.Duration(jobPolicy.GetMaxDelayMs()) * time.Millisecond
+	}
+	if policy.maxDelay < policy.initialDelay {
+		policy.maxDelay = policy.initialDelay
+	}
+	return policy, nil
+}
+
 // getScheduleDelay returns how much delay
 // the task should be scheduled after.
 // zero or negative value means no delay,
@@ -105,36 +172,30 @@ func rescheduleTask(
 func getScheduleDelay(
 	cachedTask cached.Task,
 	taskRuntime *task.RuntimeInfo,
-	initialTaskBackOff time.Duration,
-	maxTaskBackOff time.Duration,
-	throttleOnFailure bool,
+	policy taskBackoffPolicy,
+	now time.Time,
 ) time.Duration {
-	if !throttleOnFailure {
-		return time.Duration(0)
-	}
-
-	backOff := getBackoff(taskRuntime, initialTaskBackOff, maxTaskBackOff)
+	backOff := getBackoff(taskRuntime, policy)
 	ddl := cachedTask.GetLastRuntimeUpdateTime().Add(backOff)
 
-	return ddl.Sub(time.Now())
+	return ddl.Sub(now)
 }
 
 func getBackoff(
 	taskRuntime *task.RuntimeInfo,
-	initialTaskBackOff time.Duration,
-	maxTaskBackOff time.Duration) time.Duration {
+	policy taskBackoffPolicy) time.Duration {
 	if taskRuntime.GetFailureCount() == 0 {
 		return time.Duration(0)
 	}
 
-	// backOff = _initialTaskBackOff * 2 ^ (failureCount - 1)
-	backOff := time.Duration(float64(initialTaskBackOff.Nanoseconds()) *
-		math.Pow(2, float64(taskRuntime.GetFailureCount()-1)))
+	// backOff = initialDelay * multiplier ^ (failureCount - 1)
+	backOff := float64(policy.initialDelay.Nanoseconds()) *
+		math.Pow(policy.multiplier, float64(taskRuntime.GetFailureCount()-1))
 
-	if backOff > maxTaskBackOff {
-		return maxTaskBackOff
+	if math.IsNaN(backOff) || backOff > float64(policy.maxDelay.Nanoseconds()) {
+		return policy.maxDelay
 	}
-	return backOff
+	return time.Duration(backOff)
 }
 
 // TaskFailRetry retries on task failure
@@ -177,6 +238,11 @@ func TaskFailRetry(ctx context.Context, entity goalstate.Entity) error {
 		goalStateDriver.mtx.taskMetrics.RetryFailedLaunchTotal.Inc(1)
 	}
 
+	if runtime.GetReason() == jobmgrcommon.ReasonTaskRejected &&
+		maxAttempts > jobmgrcommon.MaxTaskRejectedAttempts {
+		maxAttempts = jobmgrcommon.MaxTaskRejectedAttempts
+	}
+
 	if runtime.GetFailureCount() >= maxAttempts {
 		// do not retry the task
 		return nil
@@ -189,5 +255,6 @@ func TaskFailRetry(ctx context.Context, entity goalstate.Entity) error {
 		runtime,
 		taskConfig,
 		goalStateDriver,
+		false,
 		false)
 }
@@ -364,6 +364,36 @@ func (suite *TaskFailRetryTestSuite) TestTaskFailSystemFailure() {
 	}
 }
 
+// TestTaskFailRejectedNoRetry tests a task rejected by its resource pool
+// is not retried beyond MaxTaskRejectedAttempts, regardless of its
+// restart policy
+func (suite *TaskFailRetryTestSuite) TestTaskFailRejectedNoRetry() {
+	suite.taskRuntime.Reason = jobmgrcommon.ReasonTaskRejected
+	suite.taskRuntime.FailureCount = jobmgrcommon.MaxTaskRejectedAttempts
+
+	taskConfig := pbtask.TaskConfig{
+		RestartPolicy: &pbtask.RestartPolicy{
+			MaxFailures: 100,
+		},
+	}
+
+	suite.jobFactory.EXPECT().
+		GetJob(suite.jobID).Return(suite.cachedJob)
+
+	suite.cachedJob.EXPECT().
+		GetTask(suite.instanceID).Return(suite.cachedTask)
+
+	suite.cachedTask.EXPECT().
+		GetRuntime(gomock.Any()).Return(suite.taskRuntime, nil)
+
+	suite.taskStore.EXPECT().
+		GetTaskConfig(gomock.Any(), suite.jobID, suite.instanceID, gomock.Any()).
+		Return(&taskConfig, &models.ConfigAddOn{}, nil)
+
+	err := TaskFailRetry(context.Background(), suite.taskEnt)
+	suite.NoError(err)
+}
+
 // TestTaskFailDBError tests DB failure
 func (suite *TaskFailRetryTestSuite) TestTaskFailDBError() {
 	suite.jobFactory.EXPECT().
@@ -73,13 +73,17 @@ func TaskInitialize(ctx context.Context, entity goalstate.Entity) error {
 		// TBD should the failure count be cleaned up as well?
 		runtimeDiff[jobmgrcommon.ConfigVersionField] =
 			runtime.GetDesiredConfigVersion()
+		// a config change gives the task a new crash-loop budget
+		runtimeDiff[jobmgrcommon.CrashLoopFailureCountField] = uint32(0)
+		runtimeDiff[jobmgrcommon.CrashLoopWindowStartTimeField] = ""
 	}
 
 	err = cachedJob.PatchTasks(ctx,
 		map[uint32]jobmgrcommon.RuntimeDiff{taskEnt.instanceID: runtimeDiff})
 	if err == nil {
-		goalStateDriver.EnqueueTask(taskEnt.jobID, taskEnt.instanceID, time.Now())
-		EnqueueJobWithDefaultDelay(taskEnt.jobID, goalStateDriver, cachedJob)
+		goalStateDriver.EnqueueTask(taskEnt.jobID, taskEnt.instanceID, goalStateDriver.now())
+		goalStateDriver.EnqueueJobWithDefaultDelay(
+			taskEnt.jobID, cachedJob.GetJobType())
 	}
 	return err
 }
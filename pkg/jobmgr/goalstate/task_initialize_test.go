@@ -174,6 +174,10 @@ func (suite *TestTaskInitializeSuite) TestTaskInitialize() {
 						tt.healthState,
 						runtimeDiff[jobmgrcommon.HealthyField],
 					)
+					suite.Equal(
+						uint32(0),
+						runtimeDiff[jobmgrcommon.CrashLoopFailureCountField],
+					)
 
 				}
 			}).Return(nil)
@@ -58,7 +58,7 @@ func sendLaunchInfoToResMgr(
 	// Starting timeout as we need to track if the task is
 	// launched within timeout period
 	taskEnt.driver.EnqueueTask(taskEnt.jobID, taskEnt.instanceID,
-		time.Now().Add(taskEnt.driver.cfg.LaunchTimeout))
+		taskEnt.driver.now().Add(taskEnt.driver.cfg.LaunchTimeout))
 
 	return nil
 }
@@ -88,7 +88,7 @@ func TaskLaunchRetry(ctx context.Context, entity goalstate.Entity) error {
 
 	switch cachedRuntime.State {
 	case task.TaskState_LAUNCHED:
-		if time.Now().Sub(cachedTask.GetLastRuntimeUpdateTime()) < goalStateDriver.cfg.LaunchTimeout {
+		if goalStateDriver.now().Sub(cachedTask.GetLastRuntimeUpdateTime()) < goalStateDriver.cfg.LaunchTimeout {
 			// LAUNCHED not times out, just send it to resource manager
 			return sendLaunchInfoToResMgr(
 				ctx,
@@ -98,10 +98,10 @@ func TaskLaunchRetry(ctx context.Context, entity goalstate.Entity) error {
 		}
 		goalStateDriver.mtx.taskMetrics.TaskLaunchTimeout.Inc(1)
 	case task.TaskState_STARTING:
-		if time.Now().Sub(cachedTask.GetLastRuntimeUpdateTime()) < goalStateDriver.cfg.StartTimeout {
+		if goalStateDriver.now().Sub(cachedTask.GetLastRuntimeUpdateTime()) < goalStateDriver.cfg.StartTimeout {
 			// the job is STARTING on mesos, enqueue the task in case the start timeout
 			goalStateDriver.EnqueueTask(taskEnt.jobID, taskEnt.instanceID,
-				time.Now().Add(goalStateDriver.cfg.StartTimeout))
+				goalStateDriver.now().Add(goalStateDriver.cfg.StartTimeout))
 			return nil
 		}
 		goalStateDriver.mtx.taskMetrics.TaskStartTimeout.Inc(1)
@@ -111,7 +111,7 @@ func TaskLaunchRetry(ctx context.Context, entity goalstate.Entity) error {
 			"instance_id": taskEnt.instanceID,
 			"state":       cachedRuntime.State,
 		}).Error("unexpected task state, expecting LAUNCHED or STARTING state")
-		goalStateDriver.EnqueueTask(taskEnt.jobID, taskEnt.instanceID, time.Now())
+		goalStateDriver.EnqueueTask(taskEnt.jobID, taskEnt.instanceID, goalStateDriver.now())
 		return nil
 	}
 
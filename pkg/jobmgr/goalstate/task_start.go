@@ -166,14 +166,14 @@ func TaskStart(ctx context.Context, entity goalstate.Entity) error {
 		return err
 	}
 
-	if cachedConfig.GetSLA().GetMaximumRunningInstances() > 0 {
+	if getMaxStartingInstances(cachedConfig.GetSLA()) > 0 {
 		// Tasks are enqueued into goal state in INITIALiZED state either
 		// during recovery or due to task restart due to failure/task lost
 		// or due to launch/starting state timeouts. In all these cases,
 		// job is enqueued into goal state as well. So, this is merely a safety
 		// check, hence enqueue with a large delay to prevent too many
 		// enqueues of the same job during recovery.
-		goalStateDriver.EnqueueJob(taskEnt.jobID, time.Now().Add(
+		goalStateDriver.EnqueueJob(taskEnt.jobID, goalStateDriver.now().Add(
 			_jobEnqueueMultiplierOnTaskStart*
 				goalStateDriver.JobRuntimeDuration(cachedConfig.GetType())))
 		return nil
@@ -215,6 +215,23 @@ func TaskStart(ctx context.Context, entity goalstate.Entity) error {
 		[]*task.TaskInfo{taskInfo},
 		cachedConfig,
 		goalStateDriver.resmgrClient)
+	if rejected, ok := err.(*jobmgr_task.TaskRejectedError); ok {
+		err = cachedJob.PatchTasks(ctx,
+			map[uint32]jobmgrcommon.RuntimeDiff{
+				taskEnt.instanceID: taskRejectedRuntimeDiff(
+					taskInfo.GetRuntime(),
+					rejected.Rejected[taskEnt.instanceID],
+					goalStateDriver.now()),
+			})
+		if err != nil {
+			return err
+		}
+		goalStateDriver.EnqueueTask(
+			taskEnt.jobID, taskEnt.instanceID, goalStateDriver.now())
+		goalStateDriver.EnqueueJobWithDefaultDelay(
+			taskEnt.jobID, cachedJob.GetJobType())
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -230,3 +247,25 @@ func TaskStart(ctx context.Context, entity goalstate.Entity) error {
 	}
 	return err
 }
+
+// taskRejectedRuntimeDiff returns the runtime diff failing a task rejected
+// by its resource pool, so that it is retried as per its restart policy,
+// at most MaxTaskRejectedAttempts times, rather than enqueued again and
+// again.
+func taskRejectedRuntimeDiff(
+	runtime *task.RuntimeInfo,
+	message string,
+	now time.Time) jobmgrcommon.RuntimeDiff {
+	return jobmgrcommon.RuntimeDiff{
+		jobmgrcommon.StateField:  task.TaskState_FAILED,
+		jobmgrcommon.ReasonField: jobmgrcommon.ReasonTaskRejected,
+		jobmgrcommon.MessageField: "Task rejected by resource pool: " +
+			message,
+		jobmgrcommon.FailureCountField: runtime.GetFailureCount() + 1,
+		jobmgrcommon.CompletionTimeField: now.UTC().
+			Format(time.RFC3339Nano),
+		jobmgrcommon.TerminationStatusField: &task.TerminationStatus{
+			Reason: task.TerminationStatus_TERMINATION_STATUS_REASON_FAILED,
+		},
+	}
+}
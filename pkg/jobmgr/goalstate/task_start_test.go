@@ -25,6 +25,7 @@ import (
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
 	pbtask "github.com/uber/peloton/.gen/peloton/api/v0/task"
 	"github.com/uber/peloton/.gen/peloton/api/v0/volume"
+	"github.com/uber/peloton/.gen/peloton/private/resmgr"
 	"github.com/uber/peloton/.gen/peloton/private/resmgrsvc"
 	resmocks "github.com/uber/peloton/.gen/peloton/private/resmgrsvc/mocks"
 
@@ -146,6 +147,11 @@ func (suite *TaskStartTestSuite) TestTaskStartStateless() {
 		Return(job2.JobType_SERVICE).
 		AnyTimes()
 
+	suite.cachedConfig.EXPECT().
+		GetStartAfter().
+		Return("").
+		AnyTimes()
+
 	suite.taskStore.EXPECT().
 		GetTaskByID(gomock.Any(), fmt.Sprintf("%s-%d", suite.jobID.GetValue(), suite.instanceID)).
 		Return(taskInfo, nil)
@@ -172,6 +178,92 @@ func (suite *TaskStartTestSuite) TestTaskStartStateless() {
 	suite.NoError(err)
 }
 
+// TestTaskStartRejected tests that a task rejected by its resource pool
+// is failed instead of being enqueued again
+func (suite *TaskStartTestSuite) TestTaskStartRejected() {
+	jobConfig := &job2.JobConfig{
+		RespoolID: &peloton.ResourcePoolID{
+			Value: "my-respool-id",
+		},
+	}
+	taskInfo := &pbtask.TaskInfo{
+		InstanceId: suite.instanceID,
+		Config:     &pbtask.TaskConfig{},
+		Runtime: &pbtask.RuntimeInfo{
+			FailureCount: 1,
+		},
+	}
+
+	suite.jobFactory.EXPECT().
+		GetJob(suite.jobID).
+		Return(suite.cachedJob)
+	suite.cachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(suite.cachedConfig, nil)
+	suite.cachedConfig.EXPECT().
+		GetSLA().
+		Return(&job2.SlaConfig{}).
+		AnyTimes()
+	suite.cachedConfig.EXPECT().
+		GetRespoolID().
+		Return(jobConfig.RespoolID).
+		AnyTimes()
+	suite.cachedConfig.EXPECT().
+		GetType().
+		Return(job2.JobType_BATCH).
+		AnyTimes()
+	suite.cachedConfig.EXPECT().
+		GetStartAfter().
+		Return("").
+		AnyTimes()
+
+	taskID := fmt.Sprintf("%s-%d", suite.jobID.GetValue(), suite.instanceID)
+	suite.taskStore.EXPECT().
+		GetTaskByID(gomock.Any(), taskID).
+		Return(taskInfo, nil)
+
+	suite.resmgrClient.EXPECT().
+		EnqueueGangs(gomock.Any(), gomock.Any()).
+		Return(&resmgrsvc.EnqueueGangsResponse{
+			Error: &resmgrsvc.EnqueueGangsResponse_Error{
+				Failure: &resmgrsvc.EnqueueGangsFailure{
+					Failed: []*resmgrsvc.EnqueueGangsFailure_FailedTask{{
+						Task: &resmgr.Task{
+							Id: &peloton.TaskID{Value: taskID},
+						},
+						Message:   "task too large",
+						Errorcode: resmgrsvc.EnqueueGangsFailure_ENQUEUE_GANGS_FAILURE_ERROR_CODE_TASK_REJECTED,
+					}},
+				},
+			},
+		}, nil)
+
+	suite.cachedJob.EXPECT().
+		PatchTasks(gomock.Any(), gomock.Any()).
+		Do(func(ctx context.Context, runtimeDiffs map[uint32]jobmgrcommon.RuntimeDiff) {
+			runtimeDiff := runtimeDiffs[suite.instanceID]
+			suite.Equal(pbtask.TaskState_FAILED,
+				runtimeDiff[jobmgrcommon.StateField])
+			suite.Equal(jobmgrcommon.ReasonTaskRejected,
+				runtimeDiff[jobmgrcommon.ReasonField])
+			suite.Equal("Task rejected by resource pool: task too large",
+				runtimeDiff[jobmgrcommon.MessageField])
+			suite.Equal(uint32(2),
+				runtimeDiff[jobmgrcommon.FailureCountField])
+		}).Return(nil)
+	suite.taskGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any()).
+		Return()
+	suite.cachedJob.EXPECT().
+		GetJobType().
+		Return(job2.JobType_BATCH)
+	suite.jobGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any()).
+		Return()
+
+	suite.NoError(TaskStart(context.Background(), suite.taskEnt))
+}
+
 func (suite *TaskStartTestSuite) TestTaskStartWithSlaMaxRunningInstances() {
 	jobConfig := &job2.JobConfig{
 		InstanceCount: 2,
@@ -196,6 +288,10 @@ func (suite *TaskStartTestSuite) TestTaskStartWithSlaMaxRunningInstances() {
 		GetType().
 		Return(job2.JobType_BATCH)
 
+	suite.cachedConfig.EXPECT().
+		GetStartAfter().
+		Return("")
+
 	suite.jobGoalStateEngine.EXPECT().
 		Enqueue(gomock.Any(), gomock.Any()).
 		Return()
@@ -561,6 +657,11 @@ func (suite *TaskStartTestSuite) TestTaskStartStatefulWithoutVolume() {
 		Return(job2.JobType_SERVICE).
 		AnyTimes()
 
+	suite.cachedConfig.EXPECT().
+		GetStartAfter().
+		Return("").
+		AnyTimes()
+
 	suite.cachedConfig.EXPECT().
 		GetRespoolID().
 		Return(jobConfig.RespoolID)
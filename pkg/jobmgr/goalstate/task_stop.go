@@ -129,7 +129,7 @@ func stopInitializedTask(ctx context.Context, taskEnt *taskEntity) error {
 	// If it had changed, update to current and abort.
 	if !cached.IsResMgrOwnedState(runtime.GetState()) &&
 		runtime.GetState() != task.TaskState_INITIALIZED {
-		goalStateDriver.EnqueueTask(taskEnt.jobID, taskEnt.instanceID, time.Now())
+		goalStateDriver.EnqueueTask(taskEnt.jobID, taskEnt.instanceID, goalStateDriver.now())
 		return nil
 	}
 
@@ -142,13 +142,19 @@ func stopInitializedTask(ctx context.Context, taskEnt *taskEntity) error {
 	if runtime.GetConfigVersion() != runtime.GetDesiredConfigVersion() {
 		// Kill is due to update, reset failure count
 		runtimeDiff[jobmgrcommon.FailureCountField] = uint32(0)
+		if runtime.GetTerminationStatus() == nil {
+			runtimeDiff[jobmgrcommon.TerminationStatusField] = &task.TerminationStatus{
+				Reason: task.TerminationStatus_TERMINATION_STATUS_REASON_UPDATE_REPLACED,
+			}
+		}
 	}
 
 	err = cachedJob.PatchTasks(ctx,
 		map[uint32]jobmgrcommon.RuntimeDiff{taskEnt.instanceID: runtimeDiff})
 	if err == nil {
-		goalStateDriver.EnqueueTask(taskEnt.jobID, taskEnt.instanceID, time.Now())
-		EnqueueJobWithDefaultDelay(taskEnt.jobID, goalStateDriver, cachedJob)
+		goalStateDriver.EnqueueTask(taskEnt.jobID, taskEnt.instanceID, goalStateDriver.now())
+		goalStateDriver.EnqueueJobWithDefaultDelay(
+			taskEnt.jobID, cachedJob.GetJobType())
 	}
 	return err
 }
@@ -169,12 +175,27 @@ func stopMesosTask(ctx context.Context, taskEnt *taskEntity, runtime *task.Runti
 		return nil
 	}
 
+	// Deregister the task before killing it, so that it does not receive
+	// traffic while draining. The task is killed even if it fails to be
+	// deregistered, the deregistration is retried in the background.
+	if goalStateDriver.registrationHooks != nil {
+		if err := goalStateDriver.registrationHooks.Deregister(
+			ctx, taskEnt.jobID, taskEnt.instanceID); err != nil {
+			log.WithError(err).
+				WithFields(log.Fields{
+					"job_id":      taskEnt.jobID.GetValue(),
+					"instance_id": taskEnt.instanceID,
+				}).Warn("failed to deregister task before killing it")
+		}
+	}
+
 	// Send kill signal to mesos first time
 	err := jobmgrtask.KillTask(
 		ctx,
 		goalStateDriver.hostmgrClient,
 		runtime.GetMesosTaskId(),
 		runtime.GetDesiredHost(),
+		runtime.GetKillGracePeriod(),
 	)
 	if err != nil {
 		return err
@@ -185,6 +206,14 @@ func stopMesosTask(ctx context.Context, taskEnt *taskEntity, runtime *task.Runti
 		jobmgrcommon.MessageField: "Killing the task",
 		jobmgrcommon.ReasonField:  "",
 	}
+	// Kills requested through the API already set the termination status,
+	// otherwise a new config version means the task is replaced by an update
+	if runtime.GetTerminationStatus() == nil &&
+		runtime.GetConfigVersion() != runtime.GetDesiredConfigVersion() {
+		runtimeDiff[jobmgrcommon.TerminationStatusField] = &task.TerminationStatus{
+			Reason: task.TerminationStatus_TERMINATION_STATUS_REASON_UPDATE_REPLACED,
+		}
+	}
 
 	err = cachedJob.PatchTasks(ctx,
 		map[uint32]jobmgrcommon.RuntimeDiff{taskEnt.instanceID: runtimeDiff})
@@ -192,8 +221,9 @@ func stopMesosTask(ctx context.Context, taskEnt *taskEntity, runtime *task.Runti
 	if err == nil {
 		// timeout for task kill
 		goalStateDriver.EnqueueTask(taskEnt.jobID, taskEnt.instanceID,
-			time.Now().Add(_defaultShutdownExecutorTimeout))
-		EnqueueJobWithDefaultDelay(taskEnt.jobID, goalStateDriver, cachedJob)
+			goalStateDriver.now().Add(_defaultShutdownExecutorTimeout))
+		goalStateDriver.EnqueueJobWithDefaultDelay(
+			taskEnt.jobID, cachedJob.GetJobType())
 	}
 	return err
 }
@@ -39,8 +39,8 @@ func TaskExecutorShutdown(ctx context.Context, entity goalstate.Entity) error {
 
 	// It is possible that jobmgr crashes or leader election changes when the task waiting on timeout
 	// Need to reenqueue the task after jobmgr recovers.
-	if time.Now().Sub(cachedTask.GetLastRuntimeUpdateTime()) < _defaultShutdownExecutorTimeout {
-		goalStateDriver.EnqueueTask(cachedTask.JobID(), cachedTask.ID(), time.Now().Add(_defaultShutdownExecutorTimeout))
+	if goalStateDriver.now().Sub(cachedTask.GetLastRuntimeUpdateTime()) < _defaultShutdownExecutorTimeout {
+		goalStateDriver.EnqueueTask(cachedTask.JobID(), cachedTask.ID(), goalStateDriver.now().Add(_defaultShutdownExecutorTimeout))
 		return nil
 	}
 
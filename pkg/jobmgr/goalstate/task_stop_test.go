@@ -32,6 +32,7 @@ import (
 	"github.com/uber/peloton/pkg/common/goalstate"
 	goalstatemocks "github.com/uber/peloton/pkg/common/goalstate/mocks"
 	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
+	registrationmocks "github.com/uber/peloton/pkg/jobmgr/task/registration/mocks"
 	storemocks "github.com/uber/peloton/pkg/storage/mocks"
 
 	jobmgrcommon "github.com/uber/peloton/pkg/jobmgr/common"
@@ -138,6 +139,81 @@ func TestTaskStop(t *testing.T) {
 	assert.EqualError(t, err, "fake error")
 }
 
+// TestTaskStopDeregistersBeforeKill tests that a task is deregistered
+// from the discovery system before it is killed
+func TestTaskStopDeregistersBeforeKill(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	jobGoalStateEngine := goalstatemocks.NewMockEngine(ctrl)
+	taskGoalStateEngine := goalstatemocks.NewMockEngine(ctrl)
+	jobFactory := cachedmocks.NewMockJobFactory(ctrl)
+	cachedJob := cachedmocks.NewMockJob(ctrl)
+	cachedTask := cachedmocks.NewMockTask(ctrl)
+	hostMock := hostmocks.NewMockInternalHostServiceYARPCClient(ctrl)
+	registrationHooks := registrationmocks.NewMockHooks(ctrl)
+
+	goalStateDriver := &driver{
+		jobEngine:         jobGoalStateEngine,
+		taskEngine:        taskGoalStateEngine,
+		jobFactory:        jobFactory,
+		hostmgrClient:     hostMock,
+		registrationHooks: registrationHooks,
+		mtx:               NewMetrics(tally.NoopScope),
+		cfg:               &Config{},
+	}
+	goalStateDriver.cfg.normalize()
+
+	jobID := &peloton.JobID{Value: uuid.NewRandom().String()}
+	instanceID := uint32(0)
+
+	taskEnt := &taskEntity{
+		jobID:      jobID,
+		instanceID: instanceID,
+		driver:     goalStateDriver,
+	}
+
+	taskID := &mesos_v1.TaskID{
+		Value: &[]string{"3c8a3c3e-71e3-49c5-9aed-2929823f595c-1-3c8a3c3e-71e3-49c5-9aed-2929823f5957"}[0],
+	}
+
+	runtime := &pbtask.RuntimeInfo{
+		State:       pbtask.TaskState_RUNNING,
+		MesosTaskId: taskID,
+	}
+
+	jobFactory.EXPECT().
+		GetJob(jobID).Return(cachedJob).AnyTimes()
+	cachedJob.EXPECT().
+		GetTask(instanceID).Return(cachedTask).AnyTimes()
+	cachedTask.EXPECT().
+		GetRuntime(gomock.Any()).Return(runtime, nil).AnyTimes()
+
+	// The task is killed even if it fails to be deregistered
+	for _, deregisterErr := range []error{nil, fmt.Errorf("fake error")} {
+		gomock.InOrder(
+			registrationHooks.EXPECT().
+				Deregister(gomock.Any(), jobID, instanceID).
+				Return(deregisterErr),
+			hostMock.EXPECT().KillTasks(gomock.Any(), &hostsvc.KillTasksRequest{
+				TaskIds: []*mesos_v1.TaskID{taskID},
+			}).Return(nil, nil),
+		)
+		cachedJob.EXPECT().PatchTasks(gomock.Any(), gomock.Any()).Return(nil)
+		cachedJob.EXPECT().
+			GetJobType().Return(pbjob.JobType_SERVICE)
+		taskGoalStateEngine.EXPECT().
+			Enqueue(gomock.Any(), gomock.Any()).
+			Return()
+		jobGoalStateEngine.EXPECT().
+			Enqueue(gomock.Any(), gomock.Any()).
+			Return()
+
+		err := TaskStop(context.Background(), taskEnt)
+		assert.NoError(t, err)
+	}
+}
+
 func TestTaskStopForInPlaceUpdate(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -221,6 +297,86 @@ func TestTaskStopForInPlaceUpdate(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+// TestTaskStopForUpdateSetsTerminationStatus tests that a task killed
+// to move it to a new config version is marked as replaced by an update
+func TestTaskStopForUpdateSetsTerminationStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	jobGoalStateEngine := goalstatemocks.NewMockEngine(ctrl)
+	taskGoalStateEngine := goalstatemocks.NewMockEngine(ctrl)
+	jobFactory := cachedmocks.NewMockJobFactory(ctrl)
+	cachedJob := cachedmocks.NewMockJob(ctrl)
+	cachedTask := cachedmocks.NewMockTask(ctrl)
+	hostMock := hostmocks.NewMockInternalHostServiceYARPCClient(ctrl)
+
+	goalStateDriver := &driver{
+		jobEngine:     jobGoalStateEngine,
+		taskEngine:    taskGoalStateEngine,
+		jobFactory:    jobFactory,
+		hostmgrClient: hostMock,
+		mtx:           NewMetrics(tally.NoopScope),
+		cfg:           &Config{},
+	}
+	goalStateDriver.cfg.normalize()
+
+	jobID := &peloton.JobID{Value: uuid.NewRandom().String()}
+	instanceID := uint32(0)
+
+	taskEnt := &taskEntity{
+		jobID:      jobID,
+		instanceID: instanceID,
+		driver:     goalStateDriver,
+	}
+
+	taskID := &mesos_v1.TaskID{
+		Value: &[]string{"3c8a3c3e-71e3-49c5-9aed-2929823f595c-1-3c8a3c3e-71e3-49c5-9aed-2929823f5957"}[0],
+	}
+
+	runtime := &pbtask.RuntimeInfo{
+		State:                pbtask.TaskState_RUNNING,
+		MesosTaskId:          taskID,
+		DesiredHost:          "host1",
+		ConfigVersion:        1,
+		DesiredConfigVersion: 2,
+	}
+
+	jobFactory.EXPECT().
+		GetJob(jobID).Return(cachedJob).Times(2)
+
+	cachedJob.EXPECT().
+		GetTask(instanceID).Return(cachedTask).Times(2)
+
+	cachedTask.EXPECT().
+		GetRuntime(gomock.Any()).Return(runtime, nil)
+
+	hostMock.EXPECT().KillAndReserveTasks(gomock.Any(), gomock.Any()).Return(nil, nil)
+
+	cachedJob.EXPECT().PatchTasks(gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context,
+			runtimeDiffs map[uint32]jobmgrcommon.RuntimeDiff) {
+			termStatus := runtimeDiffs[instanceID][jobmgrcommon.TerminationStatusField]
+			assert.Equal(t,
+				pbtask.TerminationStatus_TERMINATION_STATUS_REASON_UPDATE_REPLACED,
+				termStatus.(*pbtask.TerminationStatus).GetReason())
+		}).
+		Return(nil)
+
+	cachedJob.EXPECT().
+		GetJobType().Return(pbjob.JobType_BATCH)
+
+	taskGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any()).
+		Return()
+
+	jobGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any()).
+		Return()
+
+	err := TaskStop(context.Background(), taskEnt)
+	assert.NoError(t, err)
+}
+
 func TestTaskStopIfInitializedCallsKillOnResmgr(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -24,6 +24,7 @@ import (
 
 	"github.com/uber/peloton/pkg/common/goalstate"
 	"github.com/uber/peloton/pkg/jobmgr/cached"
+	jobmgrcommon "github.com/uber/peloton/pkg/jobmgr/common"
 	taskutil "github.com/uber/peloton/pkg/jobmgr/util/task"
 	updateutil "github.com/uber/peloton/pkg/jobmgr/util/update"
 
@@ -75,6 +76,15 @@ func TaskTerminatedRetry(ctx context.Context, entity goalstate.Entity) error {
 		return err
 	}
 
+	if shouldRetry && isCrashLooping(taskRuntime, taskConfig) {
+		return holdCrashLoopingTask(
+			ctx,
+			cachedJob,
+			taskEnt.instanceID,
+			taskRuntime,
+			goalStateDriver)
+	}
+
 	if shouldRetry {
 		return rescheduleTask(
 			ctx,
@@ -83,12 +93,69 @@ func TaskTerminatedRetry(ctx context.Context, entity goalstate.Entity) error {
 			taskRuntime,
 			taskConfig,
 			goalStateDriver,
-			true)
+			true,
+			isStatelessHostFailure(cachedJob, taskRuntime))
+	}
+
+	return nil
+}
+
+// isCrashLooping returns whether a failed task has failed more often than
+// allowed by the crash-loop budget of its restart policy.
+func isCrashLooping(
+	taskRuntime *pbtask.RuntimeInfo,
+	taskConfig *pbtask.TaskConfig,
+) bool {
+	maxFailures := taskConfig.GetRestartPolicy().GetMaxCrashLoopFailures()
+	return taskRuntime.GetState() == pbtask.TaskState_FAILED &&
+		maxFailures > 0 &&
+		taskRuntime.GetCrashLoopFailureCount() > maxFailures
+}
+
+// holdCrashLoopingTask holds a crash-looping task in the FAILED_HOLD state
+// instead of restarting it. The task is restarted only by an explicit start
+// or a config change.
+func holdCrashLoopingTask(
+	ctx context.Context,
+	cachedJob cached.Job,
+	instanceID uint32,
+	taskRuntime *pbtask.RuntimeInfo,
+	goalStateDriver *driver,
+) error {
+	log.WithField("job_id", cachedJob.ID().GetValue()).
+		WithField("instance_id", instanceID).
+		WithField("crash_loop_failure_count", taskRuntime.GetCrashLoopFailureCount()).
+		Info("holding crash-looping task")
+
+	err := cachedJob.PatchTasks(ctx,
+		map[uint32]jobmgrcommon.RuntimeDiff{
+			instanceID: {
+				jobmgrcommon.StateField:   pbtask.TaskState_FAILED_HOLD,
+				jobmgrcommon.MessageField: _crashLoopHoldMessage,
+			},
+		})
+	if err != nil {
+		return err
 	}
 
+	goalStateDriver.mtx.taskMetrics.CrashLoopHoldTotal.Inc(1)
+	goalStateDriver.EnqueueJobWithDefaultDelay(
+		cachedJob.ID(), cachedJob.GetJobType())
 	return nil
 }
 
+// isStatelessHostFailure returns whether the task is an instance of a
+// stateless job which was lost because of a host failure. Such instances
+// are replaced right away instead of going through the failure backoff,
+// since the application itself did not fail.
+func isStatelessHostFailure(
+	cachedJob cached.Job,
+	taskRuntime *pbtask.RuntimeInfo,
+) bool {
+	return taskutil.IsHostFailure(taskRuntime) &&
+		cachedJob.GetJobType() == pbjob.JobType_SERVICE
+}
+
 // shouldTaskRetry returns whether a terminated task should retry given its
 // MaxInstanceAttempts config
 func shouldTaskRetry(
@@ -118,7 +185,7 @@ func shouldTaskRetry(
 		goalStateDriver.EnqueueUpdate(
 			cachedJob.ID(),
 			cachedWorkflow.ID(),
-			time.Now())
+			goalStateDriver.now())
 		return true, nil
 	}
 
@@ -17,6 +17,7 @@ package goalstate
 import (
 	"context"
 	"fmt"
+	"math"
 	"testing"
 	"time"
 
@@ -28,6 +29,7 @@ import (
 	"github.com/uber/peloton/.gen/peloton/private/models"
 	"github.com/uber/peloton/pkg/jobmgr/cached"
 
+	"github.com/uber/peloton/pkg/common/goalstate"
 	goalstatemocks "github.com/uber/peloton/pkg/common/goalstate/mocks"
 	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
 	jobmgrcommon "github.com/uber/peloton/pkg/jobmgr/common"
@@ -59,6 +61,7 @@ type TaskTerminatedRetryTestSuite struct {
 	cachedJob    *cachedmocks.MockJob
 	cachedUpdate *cachedmocks.MockUpdate
 	cachedTask   *cachedmocks.MockTask
+	cachedConfig *cachedmocks.MockJobConfigCache
 
 	jobRuntime      *pbjob.RuntimeInfo
 	taskConfig      *pbtask.TaskConfig
@@ -86,6 +89,7 @@ func (suite *TaskTerminatedRetryTestSuite) SetupTest() {
 	suite.cachedJob = cachedmocks.NewMockJob(suite.ctrl)
 	suite.cachedTask = cachedmocks.NewMockTask(suite.ctrl)
 	suite.cachedUpdate = cachedmocks.NewMockUpdate(suite.ctrl)
+	suite.cachedConfig = cachedmocks.NewMockJobConfigCache(suite.ctrl)
 	suite.goalStateDriver = &driver{
 		jobEngine:    suite.jobGoalStateEngine,
 		taskEngine:   suite.taskGoalStateEngine,
@@ -228,6 +232,13 @@ func (suite *TaskTerminatedRetryTestSuite) TestTaskTerminatedRetryNoUpdate() {
 	suite.cachedJob.EXPECT().
 		ID().Return(suite.jobID)
 
+	suite.cachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(suite.cachedConfig, nil)
+	suite.cachedConfig.EXPECT().
+		GetTaskBackoffPolicy().
+		Return(nil)
+
 	suite.cachedTask.EXPECT().
 		GetLastRuntimeUpdateTime().
 		Return(time.Now().Add(-3 * time.Minute))
@@ -304,6 +315,13 @@ func (suite *TaskTerminatedRetryTestSuite) TestTaskTerminatedRetryNoFailure() {
 	suite.cachedJob.EXPECT().
 		ID().Return(suite.jobID)
 
+	suite.cachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(suite.cachedConfig, nil)
+	suite.cachedConfig.EXPECT().
+		GetTaskBackoffPolicy().
+		Return(nil)
+
 	suite.cachedTask.EXPECT().
 		GetLastRuntimeUpdateTime().
 		Return(time.Now())
@@ -420,6 +438,13 @@ func (suite *TaskTerminatedRetryTestSuite) TestLostTaskRetry() {
 	suite.cachedJob.EXPECT().
 		ID().Return(suite.jobID)
 
+	suite.cachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(suite.cachedConfig, nil)
+	suite.cachedConfig.EXPECT().
+		GetTaskBackoffPolicy().
+		Return(nil)
+
 	suite.cachedTask.EXPECT().
 		GetLastRuntimeUpdateTime().
 		Return(time.Now())
@@ -438,7 +463,8 @@ func (suite *TaskTerminatedRetryTestSuite) TestLostTaskRetry() {
 		Return(nil)
 
 	suite.cachedJob.EXPECT().
-		GetJobType().Return(pbjob.JobType_BATCH)
+		GetJobType().Return(pbjob.JobType_BATCH).
+		Times(2)
 
 	suite.taskGoalStateEngine.EXPECT().
 		Enqueue(gomock.Any(), gomock.Any()).
@@ -452,6 +478,67 @@ func (suite *TaskTerminatedRetryTestSuite) TestLostTaskRetry() {
 	suite.Nil(err)
 }
 
+// TestLostTaskRetryStatelessHostFailure tests that an instance of a
+// stateless job lost because of a host failure is replaced right away
+// without any throttling
+func (suite *TaskTerminatedRetryTestSuite) TestLostTaskRetryStatelessHostFailure() {
+	suite.lostTaskRuntime.FailureCount = 5
+
+	suite.cachedTask.EXPECT().
+		ID().
+		Return(uint32(0)).
+		AnyTimes()
+
+	suite.jobFactory.EXPECT().
+		GetJob(suite.jobID).Return(suite.cachedJob)
+	suite.cachedJob.EXPECT().
+		GetRuntime(gomock.Any()).Return(&pbjob.RuntimeInfo{}, nil)
+	suite.cachedJob.EXPECT().
+		AddTask(gomock.Any(), suite.instanceID).Return(suite.cachedTask, nil)
+	suite.cachedTask.EXPECT().
+		GetRuntime(gomock.Any()).Return(suite.lostTaskRuntime, nil)
+	suite.taskStore.EXPECT().GetTaskConfig(
+		gomock.Any(),
+		suite.jobID,
+		suite.instanceID,
+		gomock.Any()).Return(suite.taskConfig, &models.ConfigAddOn{}, nil)
+
+	suite.cachedJob.EXPECT().
+		ID().Return(suite.jobID)
+
+	suite.cachedJob.EXPECT().
+		GetJobType().Return(pbjob.JobType_SERVICE).
+		Times(2)
+
+	suite.cachedJob.EXPECT().
+		PatchTasks(gomock.Any(), gomock.Any()).
+		Do(func(ctx context.Context, runtimeDiffs map[uint32]jobmgrcommon.RuntimeDiff) {
+			runtimeDiff := runtimeDiffs[suite.instanceID]
+			suite.True(
+				runtimeDiff[jobmgrcommon.MesosTaskIDField].(*mesosv1.TaskID).GetValue() != suite.mesosTaskID)
+			suite.True(
+				runtimeDiff[jobmgrcommon.StateField].(pbtask.TaskState) == pbtask.TaskState_INITIALIZED)
+			suite.Equal(
+				_hostFailureRescheduleMessage,
+				runtimeDiff[jobmgrcommon.MessageField])
+		}).
+		Return(nil)
+
+	suite.taskGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any()).
+		Do(func(entity goalstate.Entity, deadline time.Time) {
+			suite.False(deadline.After(time.Now()))
+		}).
+		Return()
+
+	suite.jobGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any()).
+		Return()
+
+	err := TaskTerminatedRetry(context.Background(), suite.taskEnt)
+	suite.Nil(err)
+}
+
 // TestLostTaskTooManyFailures tests that lost task with too many update
 // failures is not retried
 func (suite *TaskTerminatedRetryTestSuite) TestLostTaskTooManyFailures() {
@@ -531,6 +618,13 @@ func (suite *TaskTerminatedRetryTestSuite) TestTaskTerminatedRetryUpdateTerminat
 	suite.cachedJob.EXPECT().
 		ID().
 		Return(suite.jobID)
+	suite.cachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(suite.cachedConfig, nil)
+	suite.cachedConfig.EXPECT().
+		GetTaskBackoffPolicy().
+		Return(nil)
+
 	suite.cachedTask.EXPECT().
 		GetLastRuntimeUpdateTime().
 		Return(time.Now().Add(-3 * time.Minute))
@@ -567,3 +661,162 @@ func (suite *TaskTerminatedRetryTestSuite) TestTaskTerminatedRetryUpdateTerminat
 	err := TaskTerminatedRetry(context.Background(), suite.taskEnt)
 	suite.Nil(err)
 }
+
+// TestTaskTerminatedRetryCrashLoopHold tests that a task which exceeded
+// its crash-loop budget is held in FAILED_HOLD instead of being restarted
+func (suite *TaskTerminatedRetryTestSuite) TestTaskTerminatedRetryCrashLoopHold() {
+	jobRuntime := &pbjob.RuntimeInfo{}
+
+	suite.jobFactory.EXPECT().
+		GetJob(suite.jobID).Return(suite.cachedJob)
+	suite.cachedJob.EXPECT().
+		GetRuntime(gomock.Any()).Return(jobRuntime, nil)
+	suite.cachedJob.EXPECT().
+		AddTask(gomock.Any(), suite.instanceID).Return(suite.cachedTask, nil)
+	suite.taskRuntime.CrashLoopFailureCount = 4
+	suite.cachedTask.EXPECT().
+		GetRuntime(gomock.Any()).Return(suite.taskRuntime, nil)
+	suite.taskConfig = &pbtask.TaskConfig{
+		RestartPolicy: &pbtask.RestartPolicy{
+			MaxFailures:          10,
+			MaxCrashLoopFailures: 3,
+		},
+	}
+	suite.taskStore.EXPECT().GetTaskConfig(
+		gomock.Any(),
+		suite.jobID,
+		suite.instanceID,
+		gomock.Any()).Return(suite.taskConfig, &models.ConfigAddOn{}, nil)
+
+	suite.cachedJob.EXPECT().
+		ID().Return(suite.jobID).
+		AnyTimes()
+
+	suite.cachedJob.EXPECT().
+		PatchTasks(gomock.Any(), gomock.Any()).
+		Do(func(ctx context.Context, runtimeDiffs map[uint32]jobmgrcommon.RuntimeDiff) {
+			runtimeDiff := runtimeDiffs[suite.instanceID]
+			suite.Equal(
+				pbtask.TaskState_FAILED_HOLD,
+				runtimeDiff[jobmgrcommon.StateField])
+			suite.Equal(
+				_crashLoopHoldMessage,
+				runtimeDiff[jobmgrcommon.MessageField])
+		}).
+		Return(nil)
+
+	suite.cachedJob.EXPECT().
+		GetJobType().Return(pbjob.JobType_BATCH)
+
+	suite.jobGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any()).
+		Return()
+
+	err := TaskTerminatedRetry(context.Background(), suite.taskEnt)
+	suite.Nil(err)
+}
+
+// TestIsCrashLooping tests the crash-loop budget check
+func (suite *TaskTerminatedRetryTestSuite) TestIsCrashLooping() {
+	taskConfig := &pbtask.TaskConfig{
+		RestartPolicy: &pbtask.RestartPolicy{
+			MaxCrashLoopFailures: 3,
+		},
+	}
+
+	suite.False(isCrashLooping(&pbtask.RuntimeInfo{
+		State:                 pbtask.TaskState_FAILED,
+		CrashLoopFailureCount: 3,
+	}, taskConfig))
+	suite.True(isCrashLooping(&pbtask.RuntimeInfo{
+		State:                 pbtask.TaskState_FAILED,
+		CrashLoopFailureCount: 4,
+	}, taskConfig))
+	suite.False(isCrashLooping(&pbtask.RuntimeInfo{
+		State:                 pbtask.TaskState_LOST,
+		CrashLoopFailureCount: 4,
+	}, taskConfig))
+	suite.False(isCrashLooping(&pbtask.RuntimeInfo{
+		State:                 pbtask.TaskState_FAILED,
+		CrashLoopFailureCount: 4,
+	}, &pbtask.TaskConfig{}))
+}
+
+// TestGetTaskBackoffPolicy tests overriding the task backoff policy of
+// the cluster with the task backoff policy of the job.
+func (suite *TaskTerminatedRetryTestSuite) TestGetTaskBackoffPolicy() {
+	suite.cachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(suite.cachedConfig, nil)
+	suite.cachedConfig.EXPECT().
+		GetTaskBackoffPolicy().
+		Return(&pbjob.TaskBackoffPolicy{
+			InitialDelayMs: 1000,
+			Multiplier:     1.5,
+		})
+
+	policy, err := getTaskBackoffPolicy(
+		context.Background(), suite.cachedJob, suite.goalStateDriver.cfg)
+	suite.NoError(err)
+	suite.Equal(taskBackoffPolicy{
+		initialDelay: time.Second,
+		multiplier:   1.5,
+		maxDelay:     60 * time.Minute,
+	}, policy)
+
+	suite.cachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(nil, fmt.Errorf("fake db error"))
+
+	_, err = getTaskBackoffPolicy(
+		context.Background(), suite.cachedJob, suite.goalStateDriver.cfg)
+	suite.Error(err)
+}
+
+// TestGetTaskBackoffPolicyClamped tests that an invalid task backoff
+// policy of the job is clamped to the policy of the cluster
+func (suite *TaskTerminatedRetryTestSuite) TestGetTaskBackoffPolicyClamped() {
+	for _, multiplier := range []float64{math.NaN(), math.Inf(1), 0.5, -2} {
+		suite.cachedJob.EXPECT().
+			GetConfig(gomock.Any()).
+			Return(suite.cachedConfig, nil)
+		suite.cachedConfig.EXPECT().
+			GetTaskBackoffPolicy().
+			Return(&pbjob.TaskBackoffPolicy{
+				InitialDelayMs: 5000,
+				Multiplier:     multiplier,
+				MaxDelayMs:     1000,
+			})
+
+		policy, err := getTaskBackoffPolicy(
+			context.Background(), suite.cachedJob, suite.goalStateDriver.cfg)
+		suite.NoError(err)
+		suite.Equal(taskBackoffPolicy{
+			initialDelay: 5 * time.Second,
+			multiplier:   suite.goalStateDriver.cfg.TaskBackoffMultiplier,
+			maxDelay:     5 * time.Second,
+		}, policy)
+	}
+}
+
+// TestGetBackoff tests computing the delay before recreating a failed task
+func (suite *TaskTerminatedRetryTestSuite) TestGetBackoff() {
+	policy := taskBackoffPolicy{
+		initialDelay: time.Second,
+		multiplier:   3,
+		maxDelay:     time.Minute,
+	}
+
+	suite.Equal(time.Duration(0),
+		getBackoff(&pbtask.RuntimeInfo{}, policy))
+	suite.Equal(time.Second,
+		getBackoff(&pbtask.RuntimeInfo{FailureCount: 1}, policy))
+	suite.Equal(9*time.Second,
+		getBackoff(&pbtask.RuntimeInfo{FailureCount: 3}, policy))
+	suite.Equal(time.Minute,
+		getBackoff(&pbtask.RuntimeInfo{FailureCount: 100}, policy))
+
+	policy.multiplier = math.NaN()
+	suite.Equal(time.Minute,
+		getBackoff(&pbtask.RuntimeInfo{FailureCount: 3}, policy))
+}
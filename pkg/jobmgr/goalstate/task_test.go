@@ -125,6 +125,7 @@ func TestTaskActionList(t *testing.T) {
 	taskEnt := &taskEntity{
 		jobID:      jobID,
 		instanceID: instanceID,
+		driver:     &driver{},
 	}
 
 	tt := []struct {
@@ -135,7 +136,7 @@ func TestTaskActionList(t *testing.T) {
 		{
 			currentState: pbtask.TaskState_RUNNING,
 			goalState:    pbtask.TaskState_SUCCEEDED,
-			lengthAction: 0,
+			lengthAction: 1,
 		},
 		{
 			currentState: pbtask.TaskState_INITIALIZED,
@@ -245,6 +246,21 @@ func TestEngineSuggestActionLaunchedState(t *testing.T) {
 	assert.Equal(t, LaunchRetryAction, a)
 }
 
+func TestEngineSuggestActionRunningBatchTask(t *testing.T) {
+	jobID := &peloton.JobID{Value: uuid.NewRandom().String()}
+	instanceID := uint32(0)
+
+	taskEnt := &taskEntity{
+		jobID:      jobID,
+		instanceID: instanceID,
+	}
+
+	a := taskEnt.suggestTaskAction(
+		cached.TaskStateVector{State: pbtask.TaskState_RUNNING, ConfigVersion: 0},
+		cached.TaskStateVector{State: pbtask.TaskState_SUCCEEDED, ConfigVersion: 0})
+	assert.Equal(t, EnforceDeadlineAction, a)
+}
+
 func TestEngineSuggestActionGoalRunning(t *testing.T) {
 	jobID := &peloton.JobID{Value: uuid.NewRandom().String()}
 	instanceID := uint32(0)
@@ -264,7 +280,7 @@ func TestEngineSuggestActionGoalRunning(t *testing.T) {
 			currentState:         pbtask.TaskState_RUNNING,
 			configVersion:        0,
 			desiredConfigVersion: 0,
-			action:               NoTaskAction,
+			action:               EnforceDeadlineAction,
 		},
 		{
 			currentState:         pbtask.TaskState_RUNNING,
@@ -124,11 +124,14 @@ func (u *updateEntity) GetActionList(
 	[]goalstate.Action) {
 	var actions []goalstate.Action
 
+	if u.driver.skipActions(u.jobID) {
+		return context.Background(), nil, actions
+	}
+
 	updateState := state.(*cached.UpdateStateVector)
 	updateGoalState := goalState.(*cached.UpdateStateVector)
 
-	actionStr := u.suggestUpdateAction(updateState, updateGoalState)
-	action := _updateActionsMaps[actionStr]
+	actionStr, actions := u.actions(updateState, updateGoalState)
 
 	log.WithFields(
 		log.Fields{
@@ -140,6 +143,20 @@ func (u *updateEntity) GetActionList(
 			"update_action":   actionStr,
 		}).Info("running update action")
 
+	return context.Background(), nil, actions
+}
+
+// actions returns the actions to run to bring the update from its state
+// to its goal state, along with the name of the suggested update action.
+func (u *updateEntity) actions(
+	updateState *cached.UpdateStateVector,
+	updateGoalState *cached.UpdateStateVector,
+) (UpdateAction, []goalstate.Action) {
+	var actions []goalstate.Action
+
+	actionStr := u.suggestUpdateAction(updateState, updateGoalState)
+	action := _updateActionsMaps[actionStr]
+
 	if actionStr != ClearUpdateAction && actionStr != ReloadUpdateAction {
 		actions = append(actions, goalstate.Action{
 			Name:    string(CheckForAbortAction),
@@ -154,7 +171,7 @@ func (u *updateEntity) GetActionList(
 		})
 	}
 
-	return context.Background(), nil, actions
+	return actionStr, actions
 }
 
 func (u *updateEntity) suggestUpdateAction(
@@ -50,7 +50,7 @@ func UpdateAbortIfNeeded(ctx context.Context, entity goalstate.Entity) error {
 	// maybe abort a workflow of a terminated job, reenenque job goal
 	// state engine to untrack
 	if util.IsPelotonJobStateTerminal(runtime.GetState()) {
-		goalStateDriver.EnqueueJob(updateEnt.jobID, time.Now())
+		goalStateDriver.EnqueueJob(updateEnt.jobID, goalStateDriver.now())
 	}
 
 	if runtime.GetUpdateID().GetValue() == updateEnt.id.GetValue() {
@@ -86,7 +86,7 @@ func UpdateReload(ctx context.Context, entity goalstate.Entity) error {
 		// update not found in DB, just clean up from cache and goal state
 		return UpdateUntrack(ctx, entity)
 	}
-	goalStateDriver.EnqueueUpdate(updateEnt.jobID, updateEnt.id, time.Now())
+	goalStateDriver.EnqueueUpdate(updateEnt.jobID, updateEnt.id, goalStateDriver.now())
 	return nil
 }
 
@@ -124,7 +124,7 @@ func UpdateComplete(ctx context.Context, entity goalstate.Entity) error {
 	}
 
 	// enqueue to the goal state engine to untrack the update
-	goalStateDriver.EnqueueUpdate(updateEnt.jobID, updateEnt.id, time.Now())
+	goalStateDriver.EnqueueUpdate(updateEnt.jobID, updateEnt.id, goalStateDriver.now())
 	goalStateDriver.mtx.updateMetrics.UpdateComplete.Inc(1)
 	return nil
 }
@@ -151,14 +151,14 @@ func UpdateUntrack(ctx context.Context, entity goalstate.Entity) error {
 	// check if we have another job update to run
 	if len(runtime.GetUpdateID().GetValue()) > 0 &&
 		runtime.GetUpdateID().GetValue() != updateEnt.id.GetValue() {
-		goalStateDriver.EnqueueUpdate(jobID, runtime.GetUpdateID(), time.Now())
+		goalStateDriver.EnqueueUpdate(jobID, runtime.GetUpdateID(), goalStateDriver.now())
 		return nil
 	}
 
 	// update can be applied to a terminated job,
 	// need to remove job from cache upon completion
 	if util.IsPelotonJobStateTerminal(runtime.GetState()) {
-		goalStateDriver.EnqueueJob(jobID, time.Now())
+		goalStateDriver.EnqueueJob(jobID, goalStateDriver.now())
 	}
 
 	// No more job update to run, so use the time to clean up any old
@@ -176,7 +176,7 @@ func UpdateUntrack(ctx context.Context, entity goalstate.Entity) error {
 		}
 		if !cached.IsUpdateStateTerminal(updateModel.GetState()) {
 			// just enqueue one and let it untrack first
-			goalStateDriver.EnqueueUpdate(jobID, prevUpdateID, time.Now())
+			goalStateDriver.EnqueueUpdate(jobID, prevUpdateID, goalStateDriver.now())
 			return nil
 		}
 	}
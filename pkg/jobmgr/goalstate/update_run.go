@@ -230,7 +230,7 @@ func processFailedUpdate(
 			return err
 		}
 	}
-	driver.EnqueueUpdate(cachedJob.ID(), cachedUpdate.ID(), time.Now())
+	driver.EnqueueUpdate(cachedJob.ID(), cachedUpdate.ID(), driver.now())
 
 	return nil
 }
@@ -259,17 +259,27 @@ func postUpdateAction(
 	instancesRemovedInCurrentRun []uint32,
 	instancesDone []uint32,
 	instancesFailed []uint32,
-	goalStateDriver Driver,
+	goalStateDriver *driver,
 ) error {
 	// update finishes, reenqueue the update
 	if len(cachedUpdate.GetGoalState().Instances) == len(instancesDone)+len(instancesFailed) {
 		goalStateDriver.EnqueueUpdate(
 			cachedJob.ID(),
 			cachedUpdate.ID(),
-			time.Now())
+			goalStateDriver.now())
 		return nil
 	}
 
+	// instances waiting for the health wait of a restart to pass do
+	// not receive any more task events, so check the restart again
+	// once the health wait has passed.
+	if healthWait := cachedUpdate.GetUpdateConfig().GetHealthWaitSeconds(); healthWait > 0 {
+		goalStateDriver.EnqueueUpdate(
+			cachedJob.ID(),
+			cachedUpdate.ID(),
+			goalStateDriver.now().Add(time.Duration(healthWait)*time.Second))
+	}
+
 	instancesInCurrentRun :=
 		append(instancesAddedInCurrentRun,
 			append(instancesUpdatedInCurrentRun, instancesRemovedInCurrentRun...)...)
@@ -291,7 +301,7 @@ func postUpdateAction(
 		if isTaskUpdateCompleted(cachedUpdate, runtime) ||
 			isTaskTerminated(runtime) {
 			goalStateDriver.EnqueueUpdate(
-				cachedJob.ID(), cachedUpdate.ID(), time.Now())
+				cachedJob.ID(), cachedUpdate.ID(), goalStateDriver.now())
 			return nil
 		}
 	}
@@ -564,7 +574,7 @@ func processInstancesInUpdate(
 	}
 
 	for _, instID := range instancesToUpdate {
-		goalStateDriver.EnqueueTask(cachedJob.ID(), instID, time.Now())
+		goalStateDriver.EnqueueTask(cachedJob.ID(), instID, goalStateDriver.now())
 	}
 
 	return nil
@@ -616,7 +626,7 @@ func removeInstancesInUpdate(
 	}
 
 	for _, instID := range instancesToRemove {
-		goalStateDriver.EnqueueTask(cachedJob.ID(), instID, time.Now())
+		goalStateDriver.EnqueueTask(cachedJob.ID(), instID, goalStateDriver.now())
 	}
 
 	return nil
@@ -2948,3 +2948,44 @@ func newSlice(start uint32, end uint32) []uint32 {
 	}
 	return result
 }
+
+// TestPostUpdateActionHealthWait tests that a restart with a health wait
+// is checked again once the health wait has passed
+func (suite *UpdateRunTestSuite) TestPostUpdateActionHealthWait() {
+	suite.cachedUpdate.EXPECT().
+		GetGoalState().
+		Return(&cached.UpdateStateVector{
+			Instances: []uint32{0, 1, 2},
+		})
+	suite.cachedUpdate.EXPECT().
+		GetUpdateConfig().
+		Return(&pbupdate.UpdateConfig{
+			HealthWaitSeconds: 30,
+		})
+	suite.cachedJob.EXPECT().
+		ID().
+		Return(suite.jobID)
+	suite.cachedUpdate.EXPECT().
+		ID().
+		Return(suite.updateID)
+	suite.updateGoalStateEngine.EXPECT().
+		Enqueue(gomock.Any(), gomock.Any()).
+		Do(func(_ goalstate.Entity, deadline time.Time) {
+			suite.True(deadline.After(time.Now().Add(20 * time.Second)))
+		})
+	suite.cachedJob.EXPECT().
+		GetTask(uint32(1)).
+		Return(nil)
+
+	suite.NoError(postUpdateAction(
+		context.Background(),
+		suite.cachedJob,
+		suite.cachedUpdate,
+		nil,
+		[]uint32{1},
+		nil,
+		[]uint32{0},
+		nil,
+		suite.goalStateDriver,
+	))
+}
@@ -50,10 +50,10 @@ func fetchWorkflowAndJobFromCache(
 		cachedJob = goalStateDriver.jobFactory.AddJob(jobID)
 		err = cachedJob.AddWorkflow(updateID).Cancel(ctx, nil)
 		if err == nil {
-			goalStateDriver.EnqueueUpdate(jobID, updateID, time.Now())
+			goalStateDriver.EnqueueUpdate(jobID, updateID, goalStateDriver.now())
 		}
 		// clean up the job since it is untracked before
-		goalStateDriver.EnqueueJob(jobID, time.Now())
+		goalStateDriver.EnqueueJob(jobID, goalStateDriver.now())
 		cachedJob = nil
 		return
 	}
@@ -211,7 +211,7 @@ func UpdateStart(ctx context.Context, entity goalstate.Entity) error {
 		return err
 	}
 
-	goalStateDriver.EnqueueUpdate(jobID, updateEnt.id, time.Now())
+	goalStateDriver.EnqueueUpdate(jobID, updateEnt.id, goalStateDriver.now())
 
 	goalStateDriver.mtx.updateMetrics.UpdateStart.Inc(1)
 	return nil
@@ -0,0 +1,197 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/uber/peloton/pkg/common"
+	"github.com/uber/peloton/pkg/common/taskconfig"
+	jobconfig "github.com/uber/peloton/pkg/jobmgr/job/config"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// Names of the checks stored job configs are run against
+const (
+	// CheckValidation reports configs rejected by the current validation
+	CheckValidation = "validation"
+	// CheckSystemLabels reports labels using the reserved system prefix
+	CheckSystemLabels = "system_labels"
+	// CheckHealthCheckType reports enabled health checks of a type which
+	// is not launched
+	CheckHealthCheckType = "health_check_type"
+	// CheckMaxFailures reports restart policies capped at creation
+	CheckMaxFailures = "max_failures"
+	// CheckInstanceConfig reports instance configs beyond the instance count
+	CheckInstanceConfig = "instance_config"
+)
+
+// taskCheck checks the task config of an instance, or the default config,
+// and returns the issue without instance ids if the task config is not
+// compatible with the current API.
+type taskCheck func(taskConfig *task.TaskConfig) *job.CompatibilityIssue
+
+var (
+	// _taskChecks are run against the default config and the instance
+	// configs merged with the default config, in the order of the issues
+	_taskChecks = []struct {
+		name  string
+		check taskCheck
+	}{
+		{CheckSystemLabels, checkSystemLabels},
+		{CheckHealthCheckType, checkHealthCheckType},
+		{CheckMaxFailures, checkMaxFailures},
+	}
+)
+
+// Check returns the issues of a job config, stored by an earlier version
+// of Peloton, with the current API. It does not modify the config, and
+// can be run offline against exported configs.
+func Check(
+	jobConfig *job.JobConfig,
+	maxTasksPerJob uint32) []*job.CompatibilityIssue {
+	var issues []*job.CompatibilityIssue
+
+	// the validation caps the restart policy in place
+	if err := jobconfig.ValidateConfig(
+		proto.Clone(jobConfig).(*job.JobConfig), maxTasksPerJob); err != nil {
+		issues = append(issues, &job.CompatibilityIssue{
+			Check:   CheckValidation,
+			Kind:    job.CompatibilityIssue_KIND_REJECTED,
+			Message: err.Error(),
+		})
+	}
+
+	var instanceIDs, staleInstanceIDs []uint32
+	for id := range jobConfig.GetInstanceConfig() {
+		if id < jobConfig.GetInstanceCount() {
+			instanceIDs = append(instanceIDs, id)
+		} else {
+			staleInstanceIDs = append(staleInstanceIDs, id)
+		}
+	}
+	sortInstanceIDs(instanceIDs)
+	sortInstanceIDs(staleInstanceIDs)
+
+	defaultConfig := jobConfig.GetDefaultConfig()
+	for _, c := range _taskChecks {
+		if defaultConfig != nil {
+			if issue := c.check(defaultConfig); issue != nil {
+				issue.Check = c.name
+				issue.Field = "defaultConfig." + issue.Field
+				issues = append(issues, issue)
+			}
+		}
+
+		// report the instances overriding the default config with the
+		// same issue once
+		var issue *job.CompatibilityIssue
+		for _, id := range instanceIDs {
+			i := c.check(taskconfig.Merge(
+				defaultConfig, jobConfig.GetInstanceConfig()[id]))
+			if i == nil {
+				continue
+			}
+			if issue == nil {
+				issue = i
+				issue.Check = c.name
+				issue.Field = "instanceConfig." + issue.Field
+				issues = append(issues, issue)
+			}
+			issue.InstanceIds = append(issue.InstanceIds, id)
+		}
+	}
+
+	if len(staleInstanceIDs) != 0 {
+		issues = append(issues, &job.CompatibilityIssue{
+			Check: CheckInstanceConfig,
+			Kind:  job.CompatibilityIssue_KIND_DEPRECATED,
+			Field: "instanceConfig",
+			Message: fmt.Sprintf(
+				"instance configs beyond the instance count %d are ignored, "+
+					"and should be removed",
+				jobConfig.GetInstanceCount()),
+			InstanceIds: staleInstanceIDs,
+		})
+	}
+	return issues
+}
+
+// checkSystemLabels checks that no label uses the prefix reserved for the
+// labels added by Peloton, which will be rejected once all the clients
+// stopped adding them.
+func checkSystemLabels(taskConfig *task.TaskConfig) *job.CompatibilityIssue {
+	for _, label := range taskConfig.GetLabels() {
+		if strings.HasPrefix(label.GetKey(), common.SystemLabelPrefix+".") {
+			return &job.CompatibilityIssue{
+				Kind:  job.CompatibilityIssue_KIND_DEPRECATED,
+				Field: "labels",
+				Message: fmt.Sprintf(
+					"label %q uses the prefix '%s.' reserved for system labels",
+					label.GetKey(), common.SystemLabelPrefix),
+			}
+		}
+	}
+	return nil
+}
+
+// checkHealthCheckType checks that an enabled health check has a type the
+// host manager launches, as the health checks of the other types were
+// silently dropped.
+func checkHealthCheckType(taskConfig *task.TaskConfig) *job.CompatibilityIssue {
+	healthCheck := taskConfig.GetHealthCheck()
+	if !healthCheck.GetEnabled() {
+		return nil
+	}
+	switch healthCheck.GetType() {
+	case task.HealthCheckConfig_COMMAND, task.HealthCheckConfig_HTTP:
+		return nil
+	}
+	return &job.CompatibilityIssue{
+		Kind:  job.CompatibilityIssue_KIND_MIGRATION_REQUIRED,
+		Field: "healthCheck.type",
+		Message: fmt.Sprintf(
+			"health check of type %s is not launched, set the type to "+
+				"COMMAND or HTTP", healthCheck.GetType()),
+	}
+}
+
+// checkMaxFailures checks that the restart policy does not retry failed
+// tasks more times than the cap applied when the config is validated.
+func checkMaxFailures(taskConfig *task.TaskConfig) *job.CompatibilityIssue {
+	maxFailures := taskConfig.GetRestartPolicy().GetMaxFailures()
+	if maxFailures <= jobconfig.MaxTaskRetries {
+		return nil
+	}
+	return &job.CompatibilityIssue{
+		Kind:  job.CompatibilityIssue_KIND_DEPRECATED,
+		Field: "restartPolicy.maxFailures",
+		Message: fmt.Sprintf(
+			"max failures %d is capped to %d",
+			maxFailures, jobconfig.MaxTaskRetries),
+	}
+}
+
+func sortInstanceIDs(instanceIDs []uint32) {
+	sort.Slice(instanceIDs, func(i, j int) bool {
+		return instanceIDs[i] < instanceIDs[j]
+	})
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compat
+
+import (
+	"testing"
+
+	mesos "github.com/uber/peloton/.gen/mesos/v1"
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const _maxTasksPerJob = 1000
+
+type CheckerTestSuite struct {
+	suite.Suite
+
+	config *job.JobConfig
+}
+
+func (suite *CheckerTestSuite) SetupTest() {
+	cmd := "echo hello"
+
+	// a config compatible with the current API
+	suite.config = &job.JobConfig{
+		Name:          "test-job",
+		Type:          job.JobType_SERVICE,
+		InstanceCount: 3,
+		DefaultConfig: &task.TaskConfig{
+			Command: &mesos.CommandInfo{Value: &cmd},
+			Resource: &task.ResourceConfig{
+				CpuLimit:   1,
+				MemLimitMb: 128,
+			},
+			HealthCheck: &task.HealthCheckConfig{
+				Enabled: true,
+				Type:    task.HealthCheckConfig_COMMAND,
+				CommandCheck: &task.HealthCheckConfig_CommandCheck{
+					Command: "true",
+				},
+			},
+			RestartPolicy: &task.RestartPolicy{
+				MaxFailures: 3,
+			},
+			Labels: []*peloton.Label{{Key: "job", Value: "test-job"}},
+		},
+	}
+}
+
+func TestCheckerTestSuite(t *testing.T) {
+	suite.Run(t, new(CheckerTestSuite))
+}
+
+// TestCheckCompatible tests that a compatible config has no issue
+func (suite *CheckerTestSuite) TestCheckCompatible() {
+	suite.Empty(Check(suite.config, _maxTasksPerJob))
+}
+
+// TestCheckValidation tests that a config rejected by the validation is
+// reported along with the other issues
+func (suite *CheckerTestSuite) TestCheckValidation() {
+	suite.config.DefaultConfig.Command = nil
+	suite.config.DefaultConfig.RestartPolicy.MaxFailures = 200
+
+	issues := Check(suite.config, _maxTasksPerJob)
+	suite.Len(issues, 2)
+	suite.Equal(CheckValidation, issues[0].GetCheck())
+	suite.Equal(job.CompatibilityIssue_KIND_REJECTED, issues[0].GetKind())
+	suite.Equal(CheckMaxFailures, issues[1].GetCheck())
+
+	// the validation does not cap the restart policy of the config
+	suite.Equal(uint32(200),
+		suite.config.GetDefaultConfig().GetRestartPolicy().GetMaxFailures())
+}
+
+// TestCheckDefaultConfig tests the issues of the default config
+func (suite *CheckerTestSuite) TestCheckDefaultConfig() {
+	suite.config.DefaultConfig.Labels = append(
+		suite.config.DefaultConfig.Labels,
+		&peloton.Label{Key: "peloton.job_name", Value: "test-job"})
+	suite.config.DefaultConfig.HealthCheck.Type = task.HealthCheckConfig_UNKNOWN
+
+	issues := Check(suite.config, _maxTasksPerJob)
+	suite.Len(issues, 2)
+
+	suite.Equal(CheckSystemLabels, issues[0].GetCheck())
+	suite.Equal(job.CompatibilityIssue_KIND_DEPRECATED, issues[0].GetKind())
+	suite.Equal("defaultConfig.labels", issues[0].GetField())
+	suite.Empty(issues[0].GetInstanceIds())
+
+	suite.Equal(CheckHealthCheckType, issues[1].GetCheck())
+	suite.Equal(
+		job.CompatibilityIssue_KIND_MIGRATION_REQUIRED, issues[1].GetKind())
+	suite.Equal("defaultConfig.healthCheck.type", issues[1].GetField())
+}
+
+// TestCheckInstanceConfig tests that the instance configs with the same
+// issue are reported once, and that the instance configs beyond the
+// instance count are reported
+func (suite *CheckerTestSuite) TestCheckInstanceConfig() {
+	grpcHealthCheck := &task.HealthCheckConfig{
+		Enabled: true,
+		Type:    task.HealthCheckConfig_GRPC,
+	}
+	suite.config.InstanceConfig = map[uint32]*task.TaskConfig{
+		0: {HealthCheck: grpcHealthCheck},
+		1: {Name: "instance-1"},
+		2: {HealthCheck: grpcHealthCheck},
+		5: {Name: "instance-5"},
+		4: {Name: "instance-4"},
+	}
+
+	issues := Check(suite.config, _maxTasksPerJob)
+	suite.Len(issues, 2)
+
+	suite.Equal(CheckHealthCheckType, issues[0].GetCheck())
+	suite.Equal("instanceConfig.healthCheck.type", issues[0].GetField())
+	suite.Equal([]uint32{0, 2}, issues[0].GetInstanceIds())
+
+	suite.Equal(CheckInstanceConfig, issues[1].GetCheck())
+	suite.Equal(job.CompatibilityIssue_KIND_DEPRECATED, issues[1].GetKind())
+	suite.Equal([]uint32{4, 5}, issues[1].GetInstanceIds())
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobconfig
+
+import (
+	"math"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const (
+	_defaultMinTaskBackoffDelay      = 1 * time.Second
+	_defaultMaxTaskBackoffDelay      = 60 * time.Minute
+	_defaultMaxTaskBackoffMultiplier = 10
+)
+
+// TaskBackoffBounds are the bounds of the cluster on the task backoff
+// policy of the jobs, so that a job can neither restart its failed tasks
+// in a tight loop nor hold them for too long.
+type TaskBackoffBounds struct {
+	// MinDelay is the minimum delay before restarting a failed task.
+	// Default to 1s.
+	MinDelay time.Duration `yaml:"min_delay"`
+
+	// MaxDelay is the maximum delay before restarting a failed task.
+	// Default to 1h.
+	MaxDelay time.Duration `yaml:"max_delay"`
+
+	// MaxMultiplier is the maximum factor by which the delay can grow
+	// after each failure. Default to 10.
+	MaxMultiplier float64 `yaml:"max_multiplier"`
+}
+
+// normalize returns the bounds with the unset fields set to their
+// default values.
+func (b TaskBackoffBounds) normalize() TaskBackoffBounds {
+	if b.MinDelay == 0 {
+		b.MinDelay = _defaultMinTaskBackoffDelay
+	}
+	if b.MaxDelay == 0 {
+		b.MaxDelay = _defaultMaxTaskBackoffDelay
+	}
+	if b.MaxMultiplier == 0 {
+		b.MaxMultiplier = _defaultMaxTaskBackoffMultiplier
+	}
+	return b
+}
+
+// ValidateTaskBackoffPolicy validates the task backoff policy of a job
+// against the bounds of the cluster. The fields of the policy which are
+// unset are not validated since they take the value of the cluster policy.
+func ValidateTaskBackoffPolicy(
+	policy *job.TaskBackoffPolicy,
+	bounds TaskBackoffBounds,
+) error {
+	if policy == nil {
+		return nil
+	}
+
+	bounds = bounds.normalize()
+	initialDelay := time.Duration(policy.GetInitialDelayMs()) * time.Millisecond
+	maxDelay := time.Duration(policy.GetMaxDelayMs()) * time.Millisecond
+
+	if initialDelay != 0 &&
+		(initialDelay < bounds.MinDelay || initialDelay > bounds.MaxDelay) {
+		return yarpcerrors.InvalidArgumentErrorf(
+			"task backoff initial delay %v should be between %v and %v",
+			initialDelay, bounds.MinDelay, bounds.MaxDelay)
+	}
+
+	if maxDelay != 0 &&
+		(maxDelay < bounds.MinDelay || maxDelay > bounds.MaxDelay) {
+		return yarpcerrors.InvalidArgumentErrorf(
+			"task backoff max delay %v should be between %v and %v",
+			maxDelay, bounds.MinDelay, bounds.MaxDelay)
+	}
+
+	if initialDelay != 0 && maxDelay != 0 && maxDelay < initialDelay {
+		return yarpcerrors.InvalidArgumentErrorf(
+			"task backoff max delay %v should be >= initial delay %v",
+			maxDelay, initialDelay)
+	}
+
+	if math.IsNaN(policy.GetMultiplier()) ||
+		math.IsInf(policy.GetMultiplier(), 0) {
+		return yarpcerrors.InvalidArgumentErrorf(
+			"task backoff multiplier %v should be a finite number",
+			policy.GetMultiplier())
+	}
+
+	if policy.GetMultiplier() != 0 &&
+		(policy.GetMultiplier() < 1 ||
+			policy.GetMultiplier() > bounds.MaxMultiplier) {
+		return yarpcerrors.InvalidArgumentErrorf(
+			"task backoff multiplier %v should be between 1 and %v",
+			policy.GetMultiplier(), bounds.MaxMultiplier)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobconfig
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// TestValidateTaskBackoffPolicy tests validating the task backoff policy
+// of a job against the bounds of the cluster.
+func TestValidateTaskBackoffPolicy(t *testing.T) {
+	bounds := TaskBackoffBounds{
+		MinDelay:      time.Second,
+		MaxDelay:      10 * time.Minute,
+		MaxMultiplier: 4,
+	}
+
+	tests := []struct {
+		name   string
+		policy *job.TaskBackoffPolicy
+		valid  bool
+	}{
+		{"no policy", nil, true},
+		{"empty policy", &job.TaskBackoffPolicy{}, true},
+		{"fast retries", &job.TaskBackoffPolicy{
+			InitialDelayMs: 1000,
+			Multiplier:     1,
+			MaxDelayMs:     5000,
+		}, true},
+		{"only max delay", &job.TaskBackoffPolicy{MaxDelayMs: 600000}, true},
+		{"initial delay too small", &job.TaskBackoffPolicy{InitialDelayMs: 100}, false},
+		{"max delay too large", &job.TaskBackoffPolicy{MaxDelayMs: 3600000}, false},
+		{"max delay smaller than initial delay", &job.TaskBackoffPolicy{
+			InitialDelayMs: 5000,
+			MaxDelayMs:     2000,
+		}, false},
+		{"multiplier too small", &job.TaskBackoffPolicy{Multiplier: 0.5}, false},
+		{"multiplier too large", &job.TaskBackoffPolicy{Multiplier: 5}, false},
+		{"NaN multiplier", &job.TaskBackoffPolicy{Multiplier: math.NaN()}, false},
+		{"infinite multiplier", &job.TaskBackoffPolicy{Multiplier: math.Inf(1)}, false},
+		{"negative infinite multiplier", &job.TaskBackoffPolicy{Multiplier: math.Inf(-1)}, false},
+	}
+
+	for _, test := range tests {
+		err := ValidateTaskBackoffPolicy(test.policy, bounds)
+		if test.valid {
+			assert.NoError(t, err, test.name)
+		} else {
+			assert.True(t, yarpcerrors.IsInvalidArgument(err), test.name)
+		}
+	}
+}
+
+// TestValidateTaskBackoffPolicyDefaultBounds tests validating the task
+// backoff policy of a job against the default bounds.
+func TestValidateTaskBackoffPolicyDefaultBounds(t *testing.T) {
+	assert.NoError(t, ValidateTaskBackoffPolicy(
+		&job.TaskBackoffPolicy{
+			InitialDelayMs: 1000,
+			Multiplier:     10,
+			MaxDelayMs:     3600000,
+		}, TaskBackoffBounds{}))
+	assert.Error(t, ValidateTaskBackoffPolicy(
+		&job.TaskBackoffPolicy{Multiplier: 11}, TaskBackoffBounds{}))
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobconfig
+
+import (
+	"context"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/respool"
+
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// AddedTaskLabels returns the labels of the task configs of a job config
+// which are not in the task configs of its previous config. All the
+// labels are returned if there is no previous config.
+func AddedTaskLabels(
+	config *job.JobConfig,
+	prevConfig *job.JobConfig,
+) []*peloton.Label {
+	type keyValue struct{ key, value string }
+	prev := make(map[keyValue]bool)
+	for _, label := range taskLabels(prevConfig) {
+		prev[keyValue{label.GetKey(), label.GetValue()}] = true
+	}
+
+	var added []*peloton.Label
+	for _, label := range taskLabels(config) {
+		if !prev[keyValue{label.GetKey(), label.GetValue()}] {
+			added = append(added, label)
+		}
+	}
+	return added
+}
+
+// taskLabels returns the labels of the default and instance task configs
+// of a job config.
+func taskLabels(config *job.JobConfig) []*peloton.Label {
+	labels := append([]*peloton.Label{}, config.GetDefaultConfig().GetLabels()...)
+	for _, instanceConfig := range config.GetInstanceConfig() {
+		labels = append(labels, instanceConfig.GetLabels()...)
+	}
+	return labels
+}
+
+// ValidateTaskSizeOverride validates that the labels added to the task
+// configs of a job do not include the key of the override label of the
+// task resource limit of its resource pool, unless the caller is one of
+// the override principals of the pool. The principal of the caller is
+// the identity of its verified client certificate, and is empty when
+// TLS is disabled.
+func ValidateTaskSizeOverride(
+	addedLabels []*peloton.Label,
+	limit *respool.TaskResourceLimit,
+	principal string,
+) error {
+	key := limit.GetOverrideLabel().GetKey()
+	if len(key) == 0 {
+		return nil
+	}
+
+	for _, label := range addedLabels {
+		if label.GetKey() != key {
+			continue
+		}
+		for _, p := range limit.GetOverridePrincipals() {
+			if len(principal) > 0 && p == principal {
+				return nil
+			}
+		}
+		return yarpcerrors.PermissionDeniedErrorf(
+			"only the override principals of the resource pool can set "+
+				"the task resource limit override label %q", key)
+	}
+	return nil
+}
+
+// ValidateTaskSizeOverrideUpdate validates the labels added to the task
+// configs of a job by an update of its config, as ValidateTaskSizeOverride
+// does. The config of the resource pool of the job is only read if labels
+// are added.
+func ValidateTaskSizeOverrideUpdate(
+	ctx context.Context,
+	respoolClient respool.ResourceManagerYARPCClient,
+	principal string,
+	config *job.JobConfig,
+	prevConfig *job.JobConfig,
+) error {
+	addedLabels := AddedTaskLabels(config, prevConfig)
+	if len(addedLabels) == 0 {
+		return nil
+	}
+
+	respoolID := config.GetRespoolID()
+	if respoolID == nil {
+		respoolID = prevConfig.GetRespoolID()
+	}
+	resp, err := respoolClient.GetResourcePool(
+		ctx,
+		&respool.GetRequest{Id: respoolID},
+	)
+	if err != nil {
+		return err
+	}
+	if resp.GetError() != nil {
+		return yarpcerrors.NotFoundErrorf(
+			"resource pool %s not found", respoolID.GetValue())
+	}
+	return ValidateTaskSizeOverride(
+		addedLabels,
+		resp.GetPoolinfo().GetConfig().GetTaskResourceLimit(),
+		principal)
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobconfig
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/respool"
+	respoolmocks "github.com/uber/peloton/.gen/peloton/api/v0/respool/mocks"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// TestAddedTaskLabels tests getting the labels added to the task configs
+// of a job config.
+func TestAddedTaskLabels(t *testing.T) {
+	team := &peloton.Label{Key: "team", Value: "compute"}
+	override := &peloton.Label{Key: "override", Value: "true"}
+
+	prevConfig := &job.JobConfig{
+		DefaultConfig: &task.TaskConfig{
+			Labels: []*peloton.Label{team},
+		},
+	}
+	config := &job.JobConfig{
+		DefaultConfig: &task.TaskConfig{
+			Labels: []*peloton.Label{team},
+		},
+		InstanceConfig: map[uint32]*task.TaskConfig{
+			0: {Labels: []*peloton.Label{override}},
+		},
+	}
+
+	assert.Equal(
+		t,
+		[]*peloton.Label{override},
+		AddedTaskLabels(config, prevConfig))
+	assert.Equal(
+		t,
+		[]*peloton.Label{team, override},
+		AddedTaskLabels(config, nil))
+	assert.Empty(t, AddedTaskLabels(config, config))
+}
+
+// TestValidateTaskSizeOverride tests validating that only the override
+// principals of a resource pool set its task resource limit override label.
+func TestValidateTaskSizeOverride(t *testing.T) {
+	limit := &respool.TaskResourceLimit{
+		CpuLimit:           32,
+		OverrideLabel:      &peloton.Label{Key: "override", Value: "true"},
+		OverridePrincipals: []string{"spiffe://peloton/admin"},
+	}
+	override := []*peloton.Label{{Key: "override", Value: "false"}}
+	team := []*peloton.Label{{Key: "team", Value: "compute"}}
+
+	tests := []struct {
+		name      string
+		labels    []*peloton.Label
+		limit     *respool.TaskResourceLimit
+		principal string
+		valid     bool
+	}{
+		{"no limit", override, nil, "", true},
+		{"no override label", override, &respool.TaskResourceLimit{}, "", true},
+		{"other labels", team, limit, "", true},
+		{"override principal", override, limit, "spiffe://peloton/admin", true},
+		{"other principal", override, limit, "spiffe://peloton/user", false},
+		{"no principal", override, limit, "", false},
+	}
+
+	for _, test := range tests {
+		err := ValidateTaskSizeOverride(test.labels, test.limit, test.principal)
+		if test.valid {
+			assert.NoError(t, err, test.name)
+		} else {
+			assert.True(t, yarpcerrors.IsPermissionDenied(err), test.name)
+		}
+	}
+}
+
+// TestValidateTaskSizeOverrideUpdate tests validating the labels added to
+// the task configs of a job by an update of its config.
+func TestValidateTaskSizeOverrideUpdate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	respoolClient := respoolmocks.NewMockResourceManagerYARPCClient(ctrl)
+
+	respoolID := &peloton.ResourcePoolID{Value: "respool"}
+	prevConfig := &job.JobConfig{
+		RespoolID:     respoolID,
+		DefaultConfig: &task.TaskConfig{},
+	}
+	config := &job.JobConfig{
+		DefaultConfig: &task.TaskConfig{
+			Labels: []*peloton.Label{{Key: "override", Value: "true"}},
+		},
+	}
+
+	// the resource pool is not read if no label is added
+	assert.NoError(t, ValidateTaskSizeOverrideUpdate(
+		context.Background(), respoolClient, "", prevConfig, prevConfig))
+
+	respoolClient.EXPECT().
+		GetResourcePool(gomock.Any(), &respool.GetRequest{Id: respoolID}).
+		Return(&respool.GetResponse{
+			Poolinfo: &respool.ResourcePoolInfo{
+				Id: respoolID,
+				Config: &respool.ResourcePoolConfig{
+					TaskResourceLimit: &respool.TaskResourceLimit{
+						OverrideLabel: &peloton.Label{Key: "override"},
+					},
+				},
+			},
+		}, nil)
+
+	err := ValidateTaskSizeOverrideUpdate(
+		context.Background(), respoolClient, "", config, prevConfig)
+	assert.True(t, yarpcerrors.IsPermissionDenied(err))
+}
@@ -18,12 +18,15 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"time"
 
 	mesos "github.com/uber/peloton/.gen/mesos/v1"
 	"github.com/uber/peloton/.gen/peloton/api/v0/job"
 	"github.com/uber/peloton/.gen/peloton/api/v0/task"
 
+	"github.com/uber/peloton/pkg/common/constraints"
 	"github.com/uber/peloton/pkg/common/taskconfig"
+	taskutil "github.com/uber/peloton/pkg/jobmgr/util/task"
 
 	"github.com/hashicorp/go-multierror"
 	"go.uber.org/yarpc/yarpcerrors"
@@ -31,8 +34,8 @@ import (
 
 const (
 	_updateNotSupported = "updating %s not supported"
-	// Max retries on task failures.
-	_maxTaskRetries = 100
+	// MaxTaskRetries is the cap of the max failures of the restart policy.
+	MaxTaskRetries = 100
 )
 
 var (
@@ -40,6 +43,8 @@ var (
 		"Port name is missing")
 	errPortEnvNameMissing = yarpcerrors.InvalidArgumentErrorf(
 		"Env name is missing for dynamic port")
+	errHostnameTemplateWithoutContainer = yarpcerrors.InvalidArgumentErrorf(
+		"Hostname template requires the container config")
 	errMaxInstancesTooBig = yarpcerrors.InvalidArgumentErrorf(
 		"Job specified MaximumRunningInstances > InstanceCount")
 	errIncorrectMaxInstancesSLA = yarpcerrors.InvalidArgumentErrorf(
@@ -66,6 +71,18 @@ var (
 		"can't override the preemption policy of a task" +
 			" which is going to be a part of a gang having tasks with" +
 			" a different preemption policy")
+	errInvalidStartAfter = yarpcerrors.InvalidArgumentErrorf(
+		"StartAfter should be a RFC3339 timestamp")
+	errIncorrectStartAfter = yarpcerrors.InvalidArgumentErrorf(
+		"StartAfter should not be set for stateless job")
+	errMinSuccessPercentTooBig = yarpcerrors.InvalidArgumentErrorf(
+		"MinimumSuccessPercent should be <= 100")
+	errIncorrectMinSuccessPercentSLA = yarpcerrors.InvalidArgumentErrorf(
+		"MinimumSuccessPercent should be 0 for stateless job")
+	errMaxLaunchingInstancesTooSmall = yarpcerrors.InvalidArgumentErrorf(
+		"MaximumLaunchingInstances should be >= MinimumRunningInstances")
+	errIncorrectMaxLaunchingInstancesSLA = yarpcerrors.InvalidArgumentErrorf(
+		"MaximumLaunchingInstances should be 0 for stateless job")
 
 	_jobTypeTaskValidate = map[job.JobType]func(*task.TaskConfig) error{
 		job.JobType_BATCH:   validateBatchTaskConfig,
@@ -87,6 +104,16 @@ func ValidateConfig(jobConfig *job.JobConfig, maxTasksPerJob uint32) error {
 	)
 }
 
+// ValidateInstanceConfig validates the config of a single instance of a
+// job whose instance count is not changed
+func ValidateInstanceConfig(jobConfig *job.JobConfig, instanceID uint32) error {
+	return validateTaskConfigWithRange(
+		jobConfig,
+		jobConfig.GetInstanceCount(),
+		instanceID, instanceID+1,
+	)
+}
+
 // ValidateUpdatedConfig validates the changes in the new config
 func ValidateUpdatedConfig(oldConfig *job.JobConfig,
 	newConfig *job.JobConfig,
@@ -218,8 +245,8 @@ func validateTaskConfigWithRange(jobConfig *job.JobConfig, maxTasksPerJob uint32
 		//}
 
 		restartPolicy := taskConfig.GetRestartPolicy()
-		if restartPolicy.GetMaxFailures() > _maxTaskRetries {
-			restartPolicy.MaxFailures = _maxTaskRetries
+		if restartPolicy.GetMaxFailures() > MaxTaskRetries {
+			restartPolicy.MaxFailures = MaxTaskRetries
 		}
 
 		if err := validatePortConfig(taskConfig.GetPorts()); err != nil {
@@ -241,6 +268,14 @@ func validateTaskConfigWithRange(jobConfig *job.JobConfig, maxTasksPerJob uint32
 		if err := validatePreemptionPolicy(i, taskConfig, jobConfig); err != nil {
 			return errInvalidTaskConfig(i, err)
 		}
+
+		if err := validateHostnameTemplate(taskConfig, jobConfig); err != nil {
+			return errInvalidTaskConfig(i, err)
+		}
+
+		if err := constraints.Validate(taskConfig.GetConstraint()); err != nil {
+			return errInvalidTaskConfig(i, err)
+		}
 	}
 
 	// Validate sla max/min running instances wrt instanceCount
@@ -256,9 +291,32 @@ func validateTaskConfigWithRange(jobConfig *job.JobConfig, maxTasksPerJob uint32
 		return errMinInstancesTooBig
 	}
 
+	// Gangs of minRunningInstances are launched together, so they have to
+	// fit within maxLaunchingInstances
+	maxLaunchingInstances := jobConfig.GetSLA().GetMaximumLaunchingInstances()
+	if maxLaunchingInstances != 0 && maxLaunchingInstances < minRunningInstances {
+		return errMaxLaunchingInstancesTooSmall
+	}
+
 	return nil
 }
 
+// validateHostnameTemplate validates the hostname template of the task
+// config, if it is set
+func validateHostnameTemplate(
+	taskConfig *task.TaskConfig,
+	jobConfig *job.JobConfig) error {
+	template := taskConfig.GetHostnameTemplate()
+	if len(template) == 0 {
+		return nil
+	}
+	if taskConfig.GetContainer() == nil {
+		return errHostnameTemplateWithoutContainer
+	}
+	return taskutil.ValidateHostnameTemplate(
+		template, jobConfig.GetInstanceCount())
+}
+
 func errInvalidTaskConfig(instanceID uint32, err error) error {
 	return yarpcerrors.InvalidArgumentErrorf(
 		"Invalid config for instance %v, %v", instanceID, err)
@@ -321,6 +379,15 @@ func validateBatchTaskConfig(taskConfig *task.TaskConfig) error {
 
 // validateBatchJobConfig validate jobconfig for batch job
 func validateBatchJobConfig(jobConfig *job.JobConfig) error {
+	if jobConfig.GetStartAfter() != "" {
+		if _, err := time.Parse(
+			time.RFC3339, jobConfig.GetStartAfter()); err != nil {
+			return errInvalidStartAfter
+		}
+	}
+	if jobConfig.GetSLA().GetMinimumSuccessPercent() > 100 {
+		return errMinSuccessPercentTooBig
+	}
 	return nil
 }
 
@@ -357,15 +424,30 @@ func validateStatelessJobConfig(jobConfig *job.JobConfig) error {
 		return errIncorrectMinInstancesSLA
 	}
 
+	// stateless job should not set MaximumLaunchingInstances
+	if configSLA.GetMaximumLaunchingInstances() != 0 {
+		return errIncorrectMaxLaunchingInstancesSLA
+	}
+
 	// stateless job should not set MaxRunningTime
 	if configSLA.GetMaxRunningTime() != 0 {
 		return errIncorrectMaxRunningTimeSLA
 	}
 
+	// stateless job never completes, so should not set MinimumSuccessPercent
+	if configSLA.GetMinimumSuccessPercent() != 0 {
+		return errIncorrectMinSuccessPercentSLA
+	}
+
 	if configSLA.GetRevocable() == true &&
 		configSLA.GetPreemptible() != true {
 		return errIncorrectRevocableSLA
 	}
 
+	// stateless job should not be deferred
+	if jobConfig.GetStartAfter() != "" {
+		return errIncorrectStartAfter
+	}
+
 	return nil
 }
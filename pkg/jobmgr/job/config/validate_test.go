@@ -302,7 +302,7 @@ func TestValidateTaskConfigMaxFailureRetries(t *testing.T) {
 	assert.Equal(
 		t,
 		int(jobConfig.GetInstanceConfig()[0].GetRestartPolicy().GetMaxFailures()),
-		_maxTaskRetries,
+		MaxTaskRetries,
 	)
 	assert.Equal(
 		t,
@@ -349,6 +349,40 @@ func TestValidateTaskConfigFailureMinInstances(t *testing.T) {
 	assert.EqualError(t, err, errMinInstancesTooBig.Error())
 }
 
+// TestValidateTaskConfigMaxLaunchingInstances tests that the maximum
+// launching instances of a job fit its gangs of minimum running instances
+func TestValidateTaskConfigMaxLaunchingInstances(t *testing.T) {
+	taskConfig := task.TaskConfig{
+		Resource: &task.ResourceConfig{
+			CpuLimit:    0.8,
+			MemLimitMb:  800,
+			DiskLimitMb: 1500,
+			FdLimit:     1000,
+		},
+		Command: &mesos.CommandInfo{
+			Value: util.PtrPrintf("echo Hello"),
+		},
+	}
+	testMap := map[uint32]error{
+		0: nil,
+		4: nil,
+		3: errMaxLaunchingInstancesTooSmall,
+	}
+	for maxLaunchingInstances, errExp := range testMap {
+		jobConfig := job.JobConfig{
+			Name:          fmt.Sprintf("TestJob_1"),
+			InstanceCount: 10,
+			SLA: &job.SlaConfig{
+				MinimumRunningInstances:   4,
+				MaximumLaunchingInstances: maxLaunchingInstances,
+			},
+			DefaultConfig: &taskConfig,
+		}
+		err := ValidateConfig(&jobConfig, maxTasksPerJob)
+		assert.Equal(t, errExp, err)
+	}
+}
+
 func TestValidateTaskConfigFailureForPortConfig(t *testing.T) {
 	taskConfig := task.TaskConfig{
 		Resource: &task.ResourceConfig{
@@ -423,6 +457,28 @@ func TestValidateInvalidUpdateConfig(t *testing.T) {
 	assert.Equal(t, err.Error(), expectedErrors)
 }
 
+// TestValidateInstanceConfig tests validating the config of one instance,
+// regardless of the configs of the other instances
+func TestValidateInstanceConfig(t *testing.T) {
+	taskConfig := task.TaskConfig{
+		Command: &mesos.CommandInfo{
+			Value: util.PtrPrintf("echo Hello"),
+		},
+	}
+	jobConfig := job.JobConfig{
+		Name:          fmt.Sprintf("TestJob_1"),
+		InstanceCount: 3,
+		InstanceConfig: map[uint32]*task.TaskConfig{
+			0: &taskConfig,
+			1: {Name: "no-command"},
+		},
+	}
+
+	assert.NoError(t, ValidateInstanceConfig(&jobConfig, 0))
+	assert.Error(t, ValidateInstanceConfig(&jobConfig, 1))
+	assert.Error(t, ValidateInstanceConfig(&jobConfig, 2))
+}
+
 func TestValidateValidUpdateConfig(t *testing.T) {
 	oldConfig := getConfig(oldConfig, t)
 	validNewConfig := getConfig(newConfig, t)
@@ -476,6 +532,12 @@ func TestValidateStatelessJobConfig(t *testing.T) {
 		{
 			MaxRunningTime: 1,
 		}: errIncorrectMaxRunningTimeSLA,
+		{
+			MinimumSuccessPercent: 90,
+		}: errIncorrectMinSuccessPercentSLA,
+		{
+			MaximumLaunchingInstances: 5,
+		}: errIncorrectMaxLaunchingInstancesSLA,
 		{
 			Revocable:   true,
 			Preemptible: false,
@@ -528,6 +590,53 @@ func TestValidateBatchTaskConfig(t *testing.T) {
 	}
 }
 
+// TestValidateStartAfter tests validation of the start time of batch and
+// stateless jobs.
+func TestValidateStartAfter(t *testing.T) {
+	testMap := map[string]error{
+		"":                          nil,
+		"2019-06-01T10:00:00Z":      nil,
+		"2019-06-01T10:00:00-07:00": nil,
+		"2019-06-01 10:00":          errInvalidStartAfter,
+	}
+	for startAfter, errExp := range testMap {
+		jobConfig := job.JobConfig{
+			Type:       job.JobType_BATCH,
+			StartAfter: startAfter,
+		}
+		err := validateBatchJobConfig(&jobConfig)
+		assert.Equal(t, errExp, err)
+	}
+
+	jobConfig := job.JobConfig{
+		Type:       job.JobType_SERVICE,
+		StartAfter: "2019-06-01T10:00:00Z",
+	}
+	assert.Equal(t, errIncorrectStartAfter,
+		validateStatelessJobConfig(&jobConfig))
+}
+
+// TestValidateMinimumSuccessPercent tests validation of the minimum
+// success percent of batch jobs.
+func TestValidateMinimumSuccessPercent(t *testing.T) {
+	testMap := map[uint32]error{
+		0:   nil,
+		90:  nil,
+		100: nil,
+		101: errMinSuccessPercentTooBig,
+	}
+	for percent, errExp := range testMap {
+		jobConfig := job.JobConfig{
+			Type: job.JobType_BATCH,
+			SLA: &job.SlaConfig{
+				MinimumSuccessPercent: percent,
+			},
+		}
+		err := validateBatchJobConfig(&jobConfig)
+		assert.Equal(t, errExp, err)
+	}
+}
+
 // TestValidateTaskConfigFailureBatchExecutorConfig tests validation of
 // batch job config, and verifies it throws an error when executor info
 // is present.
@@ -713,3 +822,92 @@ func TestValidatePreemptionPolicy(t *testing.T) {
 		assert.EqualError(t, test.wantErr, err.Error(), test.name)
 	}
 }
+
+func TestValidateHostnameTemplate(t *testing.T) {
+	jobConfig := &job.JobConfig{InstanceCount: 3}
+	tt := []struct {
+		name       string
+		taskConfig *task.TaskConfig
+		wantErr    bool
+	}{
+		{
+			name:       "no hostname template should pass",
+			taskConfig: &task.TaskConfig{},
+		},
+		{
+			name: "hostname template with container should pass",
+			taskConfig: &task.TaskConfig{
+				HostnameTemplate: "kafka-{instance}",
+				Container:        &mesos.ContainerInfo{},
+			},
+		},
+		{
+			name: "hostname template without container should fail",
+			taskConfig: &task.TaskConfig{
+				HostnameTemplate: "kafka-{instance}",
+			},
+			wantErr: true,
+		},
+		{
+			name: "hostname template without instance should fail",
+			taskConfig: &task.TaskConfig{
+				HostnameTemplate: "kafka",
+				Container:        &mesos.ContainerInfo{},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tt {
+		err := validateHostnameTemplate(test.taskConfig, jobConfig)
+		assert.Equal(t, test.wantErr, err != nil, test.name)
+	}
+}
+
+// TestValidateTaskConfigConstraintExpression tests that the expressions
+// of the constraints of the task configs are validated.
+func TestValidateTaskConfigConstraintExpression(t *testing.T) {
+	tt := []struct {
+		expression string
+		wantErr    bool
+	}{
+		{
+			expression: `host("zone") == "dca1" && tasks("app", "web") < 2`,
+		},
+		{
+			expression: `host("zone") ==`,
+			wantErr:    true,
+		},
+		{
+			expression: `unknown("zone")`,
+			wantErr:    true,
+		},
+	}
+
+	for _, test := range tt {
+		jobConfig := job.JobConfig{
+			Name:          "TestJob_1",
+			InstanceCount: 1,
+			DefaultConfig: &task.TaskConfig{
+				Command: &mesos.CommandInfo{
+					Value: util.PtrPrintf("echo Hello"),
+				},
+				Constraint: &task.Constraint{
+					Type: task.Constraint_AND_CONSTRAINT,
+					AndConstraint: &task.AndConstraint{
+						Constraints: []*task.Constraint{
+							{
+								Type: task.Constraint_EXPRESSION_CONSTRAINT,
+								ExpressionConstraint: &task.ExpressionConstraint{
+									Expression: test.expression,
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		err := ValidateConfig(&jobConfig, maxTasksPerJob)
+		assert.Equal(t, test.wantErr, err != nil, test.expression)
+	}
+}
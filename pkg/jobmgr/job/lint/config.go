@@ -0,0 +1,38 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+const (
+	_defaultMaxFailures uint32 = 10
+)
+
+// Config is the config of the linting of job configs against best
+// practices.
+type Config struct {
+	// RuleSets are the named sets of rules a job config can be linted
+	// against, e.g. a stricter set for production jobs. All the rules are
+	// evaluated if no rule set is requested.
+	RuleSets map[string][]string `yaml:"rule_sets"`
+
+	// MaxFailures is the maximum number of retries of a failed task which
+	// is considered sensible, as more retries hide crash looping tasks.
+	MaxFailures uint32 `yaml:"max_failures"`
+}
+
+func (c *Config) normalize() {
+	if c.MaxFailures == 0 {
+		c.MaxFailures = _defaultMaxFailures
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/uber/peloton/pkg/common/taskconfig"
+
+	"github.com/pkg/errors"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// Names of the rules job configs are linted against
+const (
+	// RuleHealthCheck warns about stateless tasks without health check
+	RuleHealthCheck = "health_check"
+	// RuleDisk warns about tasks without disk limit
+	RuleDisk = "disk"
+	// RuleRestartPolicy warns about tasks retried too little or too much
+	RuleRestartPolicy = "restart_policy"
+	// RuleAntiAffinity warns about jobs with more than one instance which
+	// can all be placed on the same host
+	RuleAntiAffinity = "anti_affinity"
+)
+
+// rule checks the task config of a job, and returns the description of
+// the warning if the task config does not follow the rule.
+type rule func(
+	config *Config,
+	jobConfig *job.JobConfig,
+	taskConfig *task.TaskConfig) string
+
+var (
+	_rules = map[string]rule{
+		RuleHealthCheck:   checkHealthCheck,
+		RuleDisk:          checkDisk,
+		RuleRestartPolicy: checkRestartPolicy,
+		RuleAntiAffinity:  checkAntiAffinity,
+	}
+
+	// _allRules are the rules evaluated when no rule set is requested,
+	// in the order of the warnings
+	_allRules = []string{
+		RuleHealthCheck,
+		RuleDisk,
+		RuleRestartPolicy,
+		RuleAntiAffinity,
+	}
+)
+
+// Linter lints job configs against best-practice rules.
+type Linter interface {
+	// Lint returns the warnings of the rules of the rule set for the job
+	// config. All the rules are evaluated if the rule set is empty.
+	Lint(jobConfig *job.JobConfig, ruleSet string) ([]*job.LintWarning, error)
+}
+
+// linter implements the Linter interface with the rule sets of the config.
+type linter struct {
+	config Config
+}
+
+// NewLinter returns a Linter for the config. It fails if a rule set
+// contains an unknown rule.
+func NewLinter(config *Config) (Linter, error) {
+	l := &linter{config: *config}
+	l.config.normalize()
+
+	for name, rules := range l.config.RuleSets {
+		if len(rules) == 0 {
+			return nil, errors.Errorf("rule set %q has no rule", name)
+		}
+		for _, r := range rules {
+			if _, ok := _rules[r]; !ok {
+				return nil, errors.Errorf(
+					"rule set %q has unknown rule %q", name, r)
+			}
+		}
+	}
+	return l, nil
+}
+
+// Lint lints the default config of the job, and the instance configs
+// merged with the default config.
+func (l *linter) Lint(
+	jobConfig *job.JobConfig,
+	ruleSet string) ([]*job.LintWarning, error) {
+	rules := _allRules
+	if len(ruleSet) != 0 {
+		var ok bool
+		if rules, ok = l.config.RuleSets[ruleSet]; !ok {
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"rule set %q is not configured", ruleSet)
+		}
+	}
+
+	var instanceIDs []uint32
+	for id := range jobConfig.GetInstanceConfig() {
+		if id < jobConfig.GetInstanceCount() {
+			instanceIDs = append(instanceIDs, id)
+		}
+	}
+	sort.Slice(instanceIDs, func(i, j int) bool {
+		return instanceIDs[i] < instanceIDs[j]
+	})
+
+	var warnings []*job.LintWarning
+	for _, name := range rules {
+		check := _rules[name]
+
+		defaultConfig := jobConfig.GetDefaultConfig()
+		if defaultConfig != nil {
+			if message := check(&l.config, jobConfig, defaultConfig); len(message) != 0 {
+				warnings = append(warnings, &job.LintWarning{
+					Rule:    name,
+					Message: message,
+				})
+			}
+		}
+
+		// report the instances overriding the default config which do not
+		// follow the rule with a single warning
+		var warning *job.LintWarning
+		for _, id := range instanceIDs {
+			taskConfig := taskconfig.Merge(
+				defaultConfig, jobConfig.GetInstanceConfig()[id])
+			message := check(&l.config, jobConfig, taskConfig)
+			if len(message) == 0 {
+				continue
+			}
+			if warning == nil {
+				warning = &job.LintWarning{
+					Rule:    name,
+					Message: message,
+				}
+				warnings = append(warnings, warning)
+			}
+			warning.InstanceIds = append(warning.InstanceIds, id)
+		}
+	}
+	return warnings, nil
+}
+
+// checkHealthCheck checks that the tasks of stateless jobs have a health
+// check, without which updates cannot tell whether the tasks are healthy.
+func checkHealthCheck(
+	config *Config,
+	jobConfig *job.JobConfig,
+	taskConfig *task.TaskConfig) string {
+	if jobConfig.GetType() != job.JobType_SERVICE {
+		return ""
+	}
+	if !taskConfig.GetHealthCheck().GetEnabled() {
+		return "health check is not enabled, so updates cannot wait for " +
+			"the tasks to become healthy"
+	}
+	return ""
+}
+
+// checkDisk checks that the tasks have a disk limit.
+func checkDisk(
+	config *Config,
+	jobConfig *job.JobConfig,
+	taskConfig *task.TaskConfig) string {
+	if taskConfig.GetResource().GetDiskLimitMb() == 0 {
+		return "disk limit is not set, so the disk space of the tasks " +
+			"is not accounted for"
+	}
+	return ""
+}
+
+// checkRestartPolicy checks that failed batch tasks are retried, and that
+// no task is retried so many times that crash loops go unnoticed.
+func checkRestartPolicy(
+	config *Config,
+	jobConfig *job.JobConfig,
+	taskConfig *task.TaskConfig) string {
+	maxFailures := taskConfig.GetRestartPolicy().GetMaxFailures()
+	if maxFailures > config.MaxFailures {
+		return fmt.Sprintf(
+			"restart policy retries failed tasks %d times, more than %d "+
+				"retries hide crash looping tasks",
+			maxFailures, config.MaxFailures)
+	}
+	if jobConfig.GetType() == job.JobType_BATCH && maxFailures == 0 {
+		return "restart policy does not retry failed tasks, so a " +
+			"transient failure fails the job"
+	}
+	return ""
+}
+
+// checkAntiAffinity checks that the tasks of jobs with more than one
+// instance have a constraint spreading them over hosts.
+func checkAntiAffinity(
+	config *Config,
+	jobConfig *job.JobConfig,
+	taskConfig *task.TaskConfig) string {
+	if jobConfig.GetInstanceCount() <= 1 {
+		return ""
+	}
+	if !hasAntiAffinity(taskConfig.GetConstraint()) {
+		return fmt.Sprintf(
+			"job has %d instances but no anti-affinity constraint, so "+
+				"all of them can be placed on the same host",
+			jobConfig.GetInstanceCount())
+	}
+	return ""
+}
+
+// hasAntiAffinity returns true if the constraint limits the number of
+// tasks with a label on a host.
+func hasAntiAffinity(constraint *task.Constraint) bool {
+	switch constraint.GetType() {
+	case task.Constraint_LABEL_CONSTRAINT:
+		labelConstraint := constraint.GetLabelConstraint()
+		if labelConstraint.GetKind() != task.LabelConstraint_TASK {
+			return false
+		}
+		switch labelConstraint.GetCondition() {
+		case task.LabelConstraint_CONDITION_LESS_THAN:
+			return true
+		case task.LabelConstraint_CONDITION_EQUAL:
+			return labelConstraint.GetRequirement() == 0
+		}
+	case task.Constraint_AND_CONSTRAINT:
+		for _, c := range constraint.GetAndConstraint().GetConstraints() {
+			if hasAntiAffinity(c) {
+				return true
+			}
+		}
+	}
+	return false
+}
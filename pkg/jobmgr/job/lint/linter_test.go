@@ -0,0 +1,222 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"testing"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+type LinterTestSuite struct {
+	suite.Suite
+
+	config *job.JobConfig
+	linter Linter
+}
+
+func (suite *LinterTestSuite) SetupTest() {
+	var err error
+	suite.linter, err = NewLinter(&Config{
+		RuleSets: map[string][]string{
+			"resources": {RuleDisk},
+		},
+	})
+	suite.NoError(err)
+
+	// a config following all the rules
+	suite.config = &job.JobConfig{
+		Name:          "test-job",
+		Type:          job.JobType_SERVICE,
+		InstanceCount: 3,
+		DefaultConfig: &task.TaskConfig{
+			Resource: &task.ResourceConfig{
+				CpuLimit:    1,
+				MemLimitMb:  128,
+				DiskLimitMb: 256,
+			},
+			HealthCheck: &task.HealthCheckConfig{
+				Enabled: true,
+			},
+			RestartPolicy: &task.RestartPolicy{
+				MaxFailures: 3,
+			},
+			Labels: []*peloton.Label{{Key: "job", Value: "test-job"}},
+			Constraint: &task.Constraint{
+				Type: task.Constraint_AND_CONSTRAINT,
+				AndConstraint: &task.AndConstraint{
+					Constraints: []*task.Constraint{
+						{
+							Type: task.Constraint_LABEL_CONSTRAINT,
+							LabelConstraint: &task.LabelConstraint{
+								Kind:        task.LabelConstraint_TASK,
+								Condition:   task.LabelConstraint_CONDITION_LESS_THAN,
+								Label:       &peloton.Label{Key: "job", Value: "test-job"},
+								Requirement: 1,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestLinter(t *testing.T) {
+	suite.Run(t, new(LinterTestSuite))
+}
+
+// TestNewLinterUnknownRule tests that rule sets with unknown rules
+// are rejected
+func (suite *LinterTestSuite) TestNewLinterUnknownRule() {
+	_, err := NewLinter(&Config{
+		RuleSets: map[string][]string{
+			"production": {RuleDisk, "unknown"},
+		},
+	})
+	suite.Error(err)
+
+	_, err = NewLinter(&Config{
+		RuleSets: map[string][]string{
+			"production": {},
+		},
+	})
+	suite.Error(err)
+}
+
+// TestLintNoWarning tests linting a config following all the rules
+func (suite *LinterTestSuite) TestLintNoWarning() {
+	warnings, err := suite.linter.Lint(suite.config, "")
+	suite.NoError(err)
+	suite.Empty(warnings)
+}
+
+// TestLintDefaultConfig tests linting a default config breaking
+// all the rules
+func (suite *LinterTestSuite) TestLintDefaultConfig() {
+	suite.config.DefaultConfig = &task.TaskConfig{
+		RestartPolicy: &task.RestartPolicy{
+			MaxFailures: 50,
+		},
+	}
+
+	warnings, err := suite.linter.Lint(suite.config, "")
+	suite.NoError(err)
+	var rules []string
+	for _, warning := range warnings {
+		rules = append(rules, warning.GetRule())
+		suite.NotEmpty(warning.GetMessage())
+		suite.Empty(warning.GetInstanceIds())
+	}
+	suite.Equal([]string{
+		RuleHealthCheck,
+		RuleDisk,
+		RuleRestartPolicy,
+		RuleAntiAffinity,
+	}, rules)
+}
+
+// TestLintInstanceConfig tests that the instance configs overriding the
+// default config are reported in a single warning per rule
+func (suite *LinterTestSuite) TestLintInstanceConfig() {
+	noDisk := &task.TaskConfig{
+		Resource: &task.ResourceConfig{CpuLimit: 1, MemLimitMb: 128},
+	}
+	suite.config.InstanceConfig = map[uint32]*task.TaskConfig{
+		2: noDisk,
+		0: noDisk,
+		1: {Name: "instance-1"},
+		// beyond the instance count
+		5: noDisk,
+	}
+
+	warnings, err := suite.linter.Lint(suite.config, "")
+	suite.NoError(err)
+	suite.Len(warnings, 1)
+	suite.Equal(RuleDisk, warnings[0].GetRule())
+	suite.Equal([]uint32{0, 2}, warnings[0].GetInstanceIds())
+}
+
+// TestLintRuleSet tests that only the rules of the requested rule set
+// are evaluated
+func (suite *LinterTestSuite) TestLintRuleSet() {
+	suite.config.DefaultConfig = &task.TaskConfig{}
+
+	warnings, err := suite.linter.Lint(suite.config, "resources")
+	suite.NoError(err)
+	suite.Len(warnings, 1)
+	suite.Equal(RuleDisk, warnings[0].GetRule())
+
+	_, err = suite.linter.Lint(suite.config, "unknown")
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestCheckRestartPolicy tests the restart policy rule for batch jobs
+func (suite *LinterTestSuite) TestCheckRestartPolicy() {
+	config := &Config{}
+	config.normalize()
+	batchJob := &job.JobConfig{Type: job.JobType_BATCH}
+
+	suite.NotEmpty(checkRestartPolicy(config, batchJob, &task.TaskConfig{}))
+	suite.Empty(checkRestartPolicy(config, batchJob, &task.TaskConfig{
+		RestartPolicy: &task.RestartPolicy{MaxFailures: 1},
+	}))
+	suite.Empty(checkRestartPolicy(config, suite.config, &task.TaskConfig{}))
+}
+
+// TestHasAntiAffinity tests detecting anti-affinity constraints
+func (suite *LinterTestSuite) TestHasAntiAffinity() {
+	labelConstraint := func(
+		kind task.LabelConstraint_Kind,
+		condition task.LabelConstraint_Condition,
+		requirement uint32) *task.Constraint {
+		return &task.Constraint{
+			Type: task.Constraint_LABEL_CONSTRAINT,
+			LabelConstraint: &task.LabelConstraint{
+				Kind:        kind,
+				Condition:   condition,
+				Requirement: requirement,
+			},
+		}
+	}
+
+	suite.False(hasAntiAffinity(nil))
+	suite.True(hasAntiAffinity(labelConstraint(
+		task.LabelConstraint_TASK,
+		task.LabelConstraint_CONDITION_EQUAL,
+		0)))
+	suite.False(hasAntiAffinity(labelConstraint(
+		task.LabelConstraint_TASK,
+		task.LabelConstraint_CONDITION_EQUAL,
+		1)))
+	suite.False(hasAntiAffinity(labelConstraint(
+		task.LabelConstraint_HOST,
+		task.LabelConstraint_CONDITION_LESS_THAN,
+		1)))
+	suite.False(hasAntiAffinity(&task.Constraint{
+		Type: task.Constraint_OR_CONSTRAINT,
+		OrConstraint: &task.OrConstraint{
+			Constraints: []*task.Constraint{labelConstraint(
+				task.LabelConstraint_TASK,
+				task.LabelConstraint_CONDITION_LESS_THAN,
+				1)},
+		},
+	}))
+}
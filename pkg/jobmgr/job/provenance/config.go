@@ -0,0 +1,27 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provenance
+
+// Config is the config for the verification of the signatures of job
+// configs at create and update time.
+type Config struct {
+	// RequireSignature rejects the job configs which are not signed by one
+	// of the public keys, e.g. on production-only clusters.
+	RequireSignature bool `yaml:"require_signature"`
+
+	// PublicKeys are the PEM encoded public keys, indexed by key ID, which
+	// the signatures of the job configs are verified against.
+	PublicKeys map[string]string `yaml:"public_keys"`
+}
@@ -0,0 +1,240 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provenance
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/job/stateless"
+
+	"github.com/uber/peloton/pkg/common"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrUnsigned is returned when a job config is not signed while
+	// signatures are required.
+	ErrUnsigned = errors.New("job config is not signed")
+
+	// ErrInvalidSignature is returned when the signature of a job config
+	// does not match the config.
+	ErrInvalidSignature = errors.New("job config signature is invalid")
+)
+
+// Provenance is the provenance of a job config whose signature was
+// verified.
+type Provenance struct {
+	// KeyID is the ID of the public key which verified the signature
+	KeyID string
+
+	// Digest is the hex encoded SHA-256 digest of the signed config
+	Digest string
+}
+
+// Labels returns the system labels recording the provenance in the job.
+func (p *Provenance) Labels() []*peloton.Label {
+	if p == nil {
+		return nil
+	}
+	return []*peloton.Label{
+		{
+			Key: fmt.Sprintf(
+				common.SystemLabelKeyTemplate,
+				common.SystemLabelPrefix,
+				common.SystemLabelProvenanceKeyID),
+			Value: p.KeyID,
+		},
+		{
+			Key: fmt.Sprintf(
+				common.SystemLabelKeyTemplate,
+				common.SystemLabelPrefix,
+				common.SystemLabelProvenanceDigest),
+			Value: p.Digest,
+		},
+	}
+}
+
+// Verifier verifies the signatures of job configs.
+type Verifier interface {
+	// Verify verifies the signature of the job config. It returns a nil
+	// provenance if the config is not signed and signatures are not
+	// required.
+	Verify(
+		config *job.JobConfig,
+		signature *job.ConfigSignature) (*Provenance, error)
+
+	// VerifySpec verifies the signature of the stateless job spec. It
+	// returns a nil provenance if the spec is not signed and signatures
+	// are not required.
+	VerifySpec(
+		spec *stateless.JobSpec,
+		signature *stateless.SpecSignature) (*Provenance, error)
+}
+
+// verifier implements the Verifier interface with the public keys of the
+// config.
+type verifier struct {
+	requireSignature bool
+	keys             map[string]crypto.PublicKey
+}
+
+// ecdsaSignature is the ASN.1 structure of ECDSA signatures.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// NewVerifier returns a Verifier for the config. ECDSA and RSA public keys
+// are supported.
+func NewVerifier(config *Config) (Verifier, error) {
+	v := &verifier{
+		requireSignature: config.RequireSignature,
+		keys:             make(map[string]crypto.PublicKey),
+	}
+	for keyID, encodedKey := range config.PublicKeys {
+		block, _ := pem.Decode([]byte(encodedKey))
+		if block == nil {
+			return nil, errors.Errorf("no PEM data found in key %q", keyID)
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse key %q", keyID)
+		}
+		switch key.(type) {
+		case *ecdsa.PublicKey, *rsa.PublicKey:
+		default:
+			return nil, errors.Errorf(
+				"key %q has unsupported type %T", keyID, key)
+		}
+		v.keys[keyID] = key
+	}
+
+	if v.requireSignature && len(v.keys) == 0 {
+		return nil, errors.New(
+			"signatures are required but no public key is configured")
+	}
+	return v, nil
+}
+
+// Verify verifies the signature of the job config.
+func (v *verifier) Verify(
+	config *job.JobConfig,
+	signature *job.ConfigSignature) (*Provenance, error) {
+	return v.verify(
+		signature.GetKeyId(),
+		signature.GetSignature(),
+		func() ([]byte, error) { return Digest(config) })
+}
+
+// VerifySpec verifies the signature of the stateless job spec.
+func (v *verifier) VerifySpec(
+	spec *stateless.JobSpec,
+	signature *stateless.SpecSignature) (*Provenance, error) {
+	return v.verify(
+		signature.GetKeyId(),
+		signature.GetSignature(),
+		func() ([]byte, error) { return SpecDigest(spec) })
+}
+
+// verify verifies the signature by the key of the digest returned by
+// the digest function.
+func (v *verifier) verify(
+	keyID string,
+	signature []byte,
+	digestFn func() ([]byte, error)) (*Provenance, error) {
+	if len(signature) == 0 {
+		if v.requireSignature {
+			return nil, ErrUnsigned
+		}
+		return nil, nil
+	}
+
+	key, ok := v.keys[keyID]
+	if !ok {
+		return nil, errors.Errorf(
+			"job config is signed by unknown key %q", keyID)
+	}
+
+	digest, err := digestFn()
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		var sig ecdsaSignature
+		if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+			return nil, ErrInvalidSignature
+		}
+		if !ecdsa.Verify(key, digest, sig.R, sig.S) {
+			return nil, ErrInvalidSignature
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(
+			key, crypto.SHA256, digest, signature); err != nil {
+			return nil, ErrInvalidSignature
+		}
+	}
+
+	return &Provenance{
+		KeyID:  keyID,
+		Digest: hex.EncodeToString(digest),
+	}, nil
+}
+
+// Digest returns the SHA-256 digest of the job config which is signed. The
+// digest is computed over the protobuf encoding of the config without its
+// resource pool ID, which is resolved from the resource pool path when the
+// config is submitted.
+func Digest(config *job.JobConfig) ([]byte, error) {
+	signed := proto.Clone(config).(*job.JobConfig)
+	signed.RespoolID = nil
+
+	buffer, err := proto.Marshal(signed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal job config")
+	}
+	digest := sha256.Sum256(buffer)
+	return digest[:], nil
+}
+
+// SpecDigest returns the SHA-256 digest of the stateless job spec which is
+// signed. The digest is computed over the protobuf encoding of the spec
+// without its revision, which is set by Peloton, and without its resource
+// pool ID, which is resolved from the resource pool path.
+func SpecDigest(spec *stateless.JobSpec) ([]byte, error) {
+	signed := proto.Clone(spec).(*stateless.JobSpec)
+	signed.Revision = nil
+	signed.RespoolId = nil
+
+	buffer, err := proto.Marshal(signed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal job spec")
+	}
+	digest := sha256.Sum256(buffer)
+	return digest[:], nil
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provenance
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"testing"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/job/stateless"
+	v1alphapeloton "github.com/uber/peloton/.gen/peloton/api/v1alpha/peloton"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type VerifierTestSuite struct {
+	suite.Suite
+
+	ecdsaKey *ecdsa.PrivateKey
+	rsaKey   *rsa.PrivateKey
+	config   *job.JobConfig
+	verifier Verifier
+}
+
+func (suite *VerifierTestSuite) SetupTest() {
+	var err error
+	suite.ecdsaKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	suite.NoError(err)
+	suite.rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	suite.NoError(err)
+
+	suite.verifier, err = NewVerifier(&Config{
+		RequireSignature: true,
+		PublicKeys: map[string]string{
+			"ci-ecdsa": suite.encodePublicKey(&suite.ecdsaKey.PublicKey),
+			"ci-rsa":   suite.encodePublicKey(&suite.rsaKey.PublicKey),
+		},
+	})
+	suite.NoError(err)
+
+	suite.config = &job.JobConfig{
+		Name:          "test-job",
+		InstanceCount: 10,
+		Type:          job.JobType_BATCH,
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	suite.Run(t, new(VerifierTestSuite))
+}
+
+func (suite *VerifierTestSuite) encodePublicKey(key crypto.PublicKey) string {
+	buffer, err := x509.MarshalPKIXPublicKey(key)
+	suite.NoError(err)
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: buffer,
+	}))
+}
+
+func (suite *VerifierTestSuite) sign(
+	signer crypto.Signer,
+	keyID string,
+	config *job.JobConfig) *job.ConfigSignature {
+	digest, err := Digest(config)
+	suite.NoError(err)
+	signature, err := signer.Sign(rand.Reader, digest, crypto.SHA256)
+	suite.NoError(err)
+	return &job.ConfigSignature{
+		KeyId:     keyID,
+		Signature: signature,
+	}
+}
+
+// TestVerify tests verifying configs signed with ECDSA and RSA keys
+func (suite *VerifierTestSuite) TestVerify() {
+	digest, err := Digest(suite.config)
+	suite.NoError(err)
+
+	for keyID, signer := range map[string]crypto.Signer{
+		"ci-ecdsa": suite.ecdsaKey,
+		"ci-rsa":   suite.rsaKey,
+	} {
+		p, err := suite.verifier.Verify(
+			suite.config, suite.sign(signer, keyID, suite.config))
+		suite.NoError(err)
+		suite.Equal(keyID, p.KeyID)
+		suite.Equal(hex.EncodeToString(digest), p.Digest)
+		suite.Len(p.Labels(), 2)
+	}
+}
+
+// TestVerifyIgnoresResourcePool tests that the resource pool ID set at
+// submission time is not part of the signed config
+func (suite *VerifierTestSuite) TestVerifyIgnoresResourcePool() {
+	signature := suite.sign(suite.ecdsaKey, "ci-ecdsa", suite.config)
+	suite.config.RespoolID = &peloton.ResourcePoolID{Value: "respool"}
+
+	_, err := suite.verifier.Verify(suite.config, signature)
+	suite.NoError(err)
+}
+
+// TestVerifyModifiedConfig tests that a config modified after it was
+// signed is rejected
+func (suite *VerifierTestSuite) TestVerifyModifiedConfig() {
+	for keyID, signer := range map[string]crypto.Signer{
+		"ci-ecdsa": suite.ecdsaKey,
+		"ci-rsa":   suite.rsaKey,
+	} {
+		signature := suite.sign(signer, keyID, suite.config)
+		modified := *suite.config
+		modified.InstanceCount = 100
+
+		_, err := suite.verifier.Verify(&modified, signature)
+		suite.Equal(ErrInvalidSignature, err)
+	}
+}
+
+// TestVerifyUnknownKey tests that a config signed by an unknown key is
+// rejected
+func (suite *VerifierTestSuite) TestVerifyUnknownKey() {
+	_, err := suite.verifier.Verify(
+		suite.config, suite.sign(suite.ecdsaKey, "unknown", suite.config))
+	suite.Error(err)
+}
+
+// TestVerifyUnsigned tests unsigned configs with and without signatures
+// being required
+func (suite *VerifierTestSuite) TestVerifyUnsigned() {
+	_, err := suite.verifier.Verify(suite.config, nil)
+	suite.Equal(ErrUnsigned, err)
+
+	v, err := NewVerifier(&Config{})
+	suite.NoError(err)
+	p, err := v.Verify(suite.config, nil)
+	suite.NoError(err)
+	suite.Nil(p)
+	suite.Nil(p.Labels())
+}
+
+// TestVerifySpec tests verifying stateless job specs, whose revision and
+// resource pool ID set by Peloton are not part of the signed spec
+func (suite *VerifierTestSuite) TestVerifySpec() {
+	spec := &stateless.JobSpec{
+		Name:          "test-job",
+		InstanceCount: 10,
+	}
+	digest, err := SpecDigest(spec)
+	suite.NoError(err)
+	sig, err := suite.ecdsaKey.Sign(rand.Reader, digest, crypto.SHA256)
+	suite.NoError(err)
+	signature := &stateless.SpecSignature{
+		KeyId:     "ci-ecdsa",
+		Signature: sig,
+	}
+
+	spec.Revision = &v1alphapeloton.Revision{Version: 2}
+	spec.RespoolId = &v1alphapeloton.ResourcePoolID{Value: "respool"}
+	p, err := suite.verifier.VerifySpec(spec, signature)
+	suite.NoError(err)
+	suite.Equal(hex.EncodeToString(digest), p.Digest)
+
+	spec.InstanceCount = 100
+	_, err = suite.verifier.VerifySpec(spec, signature)
+	suite.Equal(ErrInvalidSignature, err)
+
+	_, err = suite.verifier.VerifySpec(spec, nil)
+	suite.Equal(ErrUnsigned, err)
+}
+
+// TestNewVerifierInvalidConfig tests failing to create a verifier with an
+// invalid config
+func (suite *VerifierTestSuite) TestNewVerifierInvalidConfig() {
+	_, err := NewVerifier(&Config{RequireSignature: true})
+	suite.Error(err)
+
+	_, err = NewVerifier(&Config{
+		PublicKeys: map[string]string{"invalid": "not a key"},
+	})
+	suite.Error(err)
+}
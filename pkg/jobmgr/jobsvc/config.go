@@ -14,8 +14,18 @@
 
 package jobsvc
 
+import (
+	jobconfig "github.com/uber/peloton/pkg/jobmgr/job/config"
+	"github.com/uber/peloton/pkg/jobmgr/job/lint"
+	"github.com/uber/peloton/pkg/jobmgr/job/provenance"
+	"github.com/uber/peloton/pkg/jobmgr/util/handler"
+)
+
 const (
 	_defaultMaxTasksPerJob uint32 = 100000
+
+	_defaultMaxCheckJobConfigsLimit    uint32 = 1000
+	_defaultCheckJobConfigsConcurrency        = 10
 )
 
 // Config for job service
@@ -25,10 +35,40 @@ type Config struct {
 
 	// Flag to enable handling peloton secrets
 	EnableSecrets bool `yaml:"enable_secrets"`
+
+	// Config for the verification of the signatures of job configs
+	Provenance provenance.Config `yaml:"provenance"`
+
+	// Name of the generator of the IDs of jobs created without an ID,
+	// either "random" (default) or "external_ref"
+	JobIDGenerator string `yaml:"job_id_generator"`
+
+	// Config for the linting of job configs against best practices
+	Lint lint.Config `yaml:"lint"`
+
+	// Bounds on the task backoff policy of the jobs
+	TaskBackoffBounds jobconfig.TaskBackoffBounds `yaml:"task_backoff_bounds"`
+
+	// Limits on the results of the queries of jobs and pods
+	Pagination handler.PaginationConfig `yaml:"pagination"`
+
+	// Maximum number of jobs whose config is checked by a call of
+	// CheckJobConfigs, the jobs beyond it are checked by the next pages
+	MaxCheckJobConfigsLimit uint32 `yaml:"max_check_job_configs_limit"`
+
+	// Number of job configs read and checked in parallel by a call of
+	// CheckJobConfigs
+	CheckJobConfigsConcurrency int `yaml:"check_job_configs_concurrency"`
 }
 
 func (c *Config) normalize() {
 	if c.MaxTasksPerJob == 0 {
 		c.MaxTasksPerJob = _defaultMaxTasksPerJob
 	}
+	if c.MaxCheckJobConfigsLimit == 0 {
+		c.MaxCheckJobConfigsLimit = _defaultMaxCheckJobConfigsLimit
+	}
+	if c.CheckJobConfigsConcurrency <= 0 {
+		c.CheckJobConfigsConcurrency = _defaultCheckJobConfigsConcurrency
+	}
 }
@@ -24,4 +24,7 @@ func TestConfigNormalize(t *testing.T) {
 	c := Config{}
 	c.normalize()
 	assert.Equal(t, _defaultMaxTasksPerJob, c.MaxTasksPerJob)
+	assert.Equal(t, _defaultMaxCheckJobConfigsLimit, c.MaxCheckJobConfigsLimit)
+	assert.Equal(t, _defaultCheckJobConfigsConcurrency,
+		c.CheckJobConfigsConcurrency)
 }
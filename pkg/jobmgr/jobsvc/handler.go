@@ -18,6 +18,8 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	mesos "github.com/uber/peloton/.gen/mesos/v1"
@@ -33,16 +35,25 @@ import (
 
 	"github.com/uber/peloton/pkg/common"
 	"github.com/uber/peloton/pkg/common/leader"
+	"github.com/uber/peloton/pkg/common/rpc"
 	"github.com/uber/peloton/pkg/common/util"
+	"github.com/uber/peloton/pkg/jobmgr/audit"
 	"github.com/uber/peloton/pkg/jobmgr/cached"
+	jobmgrcommon "github.com/uber/peloton/pkg/jobmgr/common"
 	"github.com/uber/peloton/pkg/jobmgr/goalstate"
+	"github.com/uber/peloton/pkg/jobmgr/job/compat"
 	jobconfig "github.com/uber/peloton/pkg/jobmgr/job/config"
+	"github.com/uber/peloton/pkg/jobmgr/job/lint"
+	"github.com/uber/peloton/pkg/jobmgr/job/provenance"
 	jobmgrtask "github.com/uber/peloton/pkg/jobmgr/task"
 	"github.com/uber/peloton/pkg/jobmgr/util/handler"
 	jobutil "github.com/uber/peloton/pkg/jobmgr/util/job"
+	taskutil "github.com/uber/peloton/pkg/jobmgr/util/task"
 	"github.com/uber/peloton/pkg/storage"
 	ormobjects "github.com/uber/peloton/pkg/storage/objects"
 
+	"github.com/gocql/gocql"
+	"github.com/gogo/protobuf/proto"
 	"github.com/pborman/uuid"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -59,33 +70,51 @@ var (
 		"resource pool")
 )
 
+// _maxSnapshotAttempts is the number of times the respool jobs snapshot
+// is read before giving up on the resource pools changing meanwhile.
+const _maxSnapshotAttempts = 3
+
 // InitServiceHandler initializes the job manager
 func InitServiceHandler(
 	d *yarpc.Dispatcher,
 	parent tally.Scope,
 	jobStore storage.JobStore,
 	taskStore storage.TaskStore,
+	respoolStore storage.ResourcePoolStore,
 	ormStore *ormobjects.Store,
 	jobFactory cached.JobFactory,
 	goalStateDriver goalstate.Driver,
 	candidate leader.Candidate,
 	clientName string,
-	jobSvcCfg Config) {
+	jobSvcCfg Config,
+	configVerifier provenance.Verifier,
+	jobIDGenerator JobIDGenerator,
+	configLinter lint.Linter,
+	stateExporter audit.Exporter,
+	tlsProvider *rpc.TLSProvider) {
 
 	jobSvcCfg.normalize()
 	handler := &serviceHandler{
-		jobStore:        jobStore,
-		taskStore:       taskStore,
-		jobIndexOps:     ormobjects.NewJobIndexOps(ormStore),
-		secretInfoOps:   ormobjects.NewSecretInfoOps(ormStore),
-		respoolClient:   respool.NewResourceManagerYARPCClient(d.ClientConfig(clientName)),
-		resmgrClient:    resmgrsvc.NewResourceManagerServiceYARPCClient(d.ClientConfig(clientName)),
-		rootCtx:         context.Background(),
-		jobFactory:      jobFactory,
-		goalStateDriver: goalStateDriver,
-		candidate:       candidate,
-		metrics:         NewMetrics(parent.SubScope("jobmgr").SubScope("job")),
-		jobSvcCfg:       jobSvcCfg,
+		jobStore:          jobStore,
+		taskStore:         taskStore,
+		respoolStore:      respoolStore,
+		jobIndexOps:       ormobjects.NewJobIndexOps(ormStore),
+		jobExternalRefOps: ormobjects.NewJobExternalRefOps(ormStore),
+		secretInfoOps:     ormobjects.NewSecretInfoOps(ormStore),
+		runDurationOps:    ormobjects.NewRunDurationOps(ormStore),
+		respoolClient:     respool.NewResourceManagerYARPCClient(d.ClientConfig(clientName)),
+		resmgrClient:      resmgrsvc.NewResourceManagerServiceYARPCClient(d.ClientConfig(clientName)),
+		rootCtx:           context.Background(),
+		jobFactory:        jobFactory,
+		goalStateDriver:   goalStateDriver,
+		candidate:         candidate,
+		metrics:           NewMetrics(parent.SubScope("jobmgr").SubScope("job")),
+		jobSvcCfg:         jobSvcCfg,
+		configVerifier:    configVerifier,
+		jobIDGenerator:    jobIDGenerator,
+		configLinter:      configLinter,
+		stateExporter:     stateExporter,
+		tlsProvider:       tlsProvider,
 	}
 
 	d.Register(job.BuildJobManagerYARPCProcedures(handler))
@@ -93,18 +122,30 @@ func InitServiceHandler(
 
 // serviceHandler implements peloton.api.job.JobManager
 type serviceHandler struct {
-	jobStore        storage.JobStore
-	taskStore       storage.TaskStore
-	jobIndexOps     ormobjects.JobIndexOps
-	secretInfoOps   ormobjects.SecretInfoOps
-	respoolClient   respool.ResourceManagerYARPCClient
-	resmgrClient    resmgrsvc.ResourceManagerServiceYARPCClient
-	rootCtx         context.Context
-	jobFactory      cached.JobFactory
-	goalStateDriver goalstate.Driver
-	candidate       leader.Candidate
-	metrics         *Metrics
-	jobSvcCfg       Config
+	jobStore          storage.JobStore
+	taskStore         storage.TaskStore
+	respoolStore      storage.ResourcePoolStore
+	jobIndexOps       ormobjects.JobIndexOps
+	jobExternalRefOps ormobjects.JobExternalRefOps
+	secretInfoOps     ormobjects.SecretInfoOps
+	runDurationOps    ormobjects.RunDurationOps
+	respoolClient     respool.ResourceManagerYARPCClient
+	resmgrClient      resmgrsvc.ResourceManagerServiceYARPCClient
+	rootCtx           context.Context
+	jobFactory        cached.JobFactory
+	goalStateDriver   goalstate.Driver
+	candidate         leader.Candidate
+	metrics           *Metrics
+	jobSvcCfg         Config
+	configVerifier    provenance.Verifier
+	jobIDGenerator    JobIDGenerator
+	configLinter      lint.Linter
+	// exports the changes made to the cluster, nil if the snapshots of
+	// the cluster state are not enabled
+	stateExporter audit.Exporter
+	// identifies the callers by their client certificates, nil if TLS
+	// is disabled
+	tlsProvider *rpc.TLSProvider
 }
 
 // Create creates a job object for a given job configuration and
@@ -124,7 +165,7 @@ func (h *serviceHandler) Create(
 	jobID := req.GetId()
 	// It is possible that jobId is nil since protobuf doesn't enforce it
 	if jobID == nil || len(jobID.GetValue()) == 0 {
-		jobID = &peloton.JobID{Value: uuid.New()}
+		jobID = h.jobIDGenerator.NewJobID(req)
 	}
 
 	if uuid.Parse(jobID.GetValue()) == nil {
@@ -142,7 +183,22 @@ func (h *serviceHandler) Create(
 
 	jobConfig := req.GetConfig()
 
-	respoolPath, err := h.validateResourcePool(jobConfig.GetRespoolID())
+	// Verify the signature before the config is modified by Peloton
+	provenanceLabels, err := h.verifyConfigSignature(
+		jobConfig, req.GetSignature())
+	if err != nil {
+		h.metrics.JobCreateFail.Inc(1)
+		return &job.CreateResponse{
+			Error: &job.CreateResponse_Error{
+				InvalidConfig: &job.InvalidJobConfig{
+					Id:      jobID,
+					Message: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	respoolInfo, err := h.validateResourcePool(jobConfig.GetRespoolID())
 	if err != nil {
 		h.metrics.JobCreateFail.Inc(1)
 		return &job.CreateResponse{
@@ -159,6 +215,10 @@ func (h *serviceHandler) Create(
 
 	// Validate job config with default task configs
 	err = jobconfig.ValidateConfig(jobConfig, h.jobSvcCfg.MaxTasksPerJob)
+	if err == nil {
+		err = jobconfig.ValidateTaskBackoffPolicy(
+			jobConfig.GetTaskBackoffPolicy(), h.jobSvcCfg.TaskBackoffBounds)
+	}
 	if err != nil {
 		h.metrics.JobCreateFail.Inc(1)
 		return &job.CreateResponse{
@@ -177,17 +237,55 @@ func (h *serviceHandler) Create(
 		return &job.CreateResponse{}, err
 	}
 
+	if err = jobconfig.ValidateTaskSizeOverride(
+		jobconfig.AddedTaskLabels(jobConfig, nil),
+		respoolInfo.GetConfig().GetTaskResourceLimit(),
+		h.tlsProvider.PeerID(ctx)); err != nil {
+		h.metrics.JobCreateFail.Inc(1)
+		return &job.CreateResponse{}, err
+	}
+
+	// map the external reference ID to the job before anything of the job
+	// is persisted, so that no two jobs get the same external reference ID.
+	// The mapping is removed if the job fails to be created.
+	if len(req.GetExternalRefId()) > 0 {
+		if err = h.jobExternalRefOps.Create(
+			ctx, req.GetExternalRefId(), jobID); err != nil {
+			h.metrics.JobCreateFail.Inc(1)
+			if !yarpcerrors.IsAlreadyExists(err) {
+				return &job.CreateResponse{}, err
+			}
+			existingJobID, _ := h.jobExternalRefOps.Get(
+				ctx, req.GetExternalRefId())
+			return &job.CreateResponse{
+				Error: &job.CreateResponse_Error{
+					AlreadyExists: &job.JobAlreadyExists{
+						Id: existingJobID,
+						Message: fmt.Sprintf(
+							"external reference id %s is already used by job %s",
+							req.GetExternalRefId(),
+							existingJobID.GetValue()),
+					},
+				},
+				JobId: existingJobID,
+			}, nil
+		}
+	}
+
 	// create secrets in the DB and add them as secret volumes to defaultconfig
 	err = h.handleCreateSecrets(ctx, jobID, jobConfig, req.GetSecrets())
 	if err != nil {
 		h.metrics.JobCreateFail.Inc(1)
+		h.deleteExternalRef(req.GetExternalRefId(), jobID)
 		return &job.CreateResponse{}, err
 	}
 
 	// Create job in cache and db
 	cachedJob := h.jobFactory.AddJob(jobID)
 
-	systemLabels := jobutil.ConstructSystemLabels(jobConfig, respoolPath.GetValue())
+	systemLabels := append(
+		jobutil.ConstructSystemLabels(jobConfig, respoolInfo.GetPath().GetValue()),
+		provenanceLabels...)
 	configAddOn := &models.ConfigAddOn{
 		SystemLabels: systemLabels,
 	}
@@ -199,6 +297,7 @@ func (h *serviceHandler) Create(
 
 	if err != nil {
 		h.metrics.JobCreateFail.Inc(1)
+		h.deleteExternalRef(req.GetExternalRefId(), jobID)
 		return &job.CreateResponse{
 			Error: &job.CreateResponse_Error{
 				AlreadyExists: &job.JobAlreadyExists{
@@ -217,6 +316,29 @@ func (h *serviceHandler) Create(
 	}, nil
 }
 
+// deleteExternalRef removes the mapping of the external reference ID to
+// the job which failed to be created, so that the external reference ID
+// can be used again. A context detached from the request is used, as the
+// request may have failed because its context expired.
+func (h *serviceHandler) deleteExternalRef(
+	externalRefID string,
+	jobID *peloton.JobID) {
+	if len(externalRefID) == 0 {
+		return
+	}
+
+	ctx, cancelFunc := context.WithTimeout(h.rootCtx, 10*time.Second)
+	defer cancelFunc()
+	if err := h.jobExternalRefOps.Delete(ctx, externalRefID); err != nil {
+		log.WithError(err).
+			WithFields(log.Fields{
+				"job_id":          jobID.GetValue(),
+				"external_ref_id": externalRefID,
+			}).
+			Error("failed to delete external reference id of job")
+	}
+}
+
 // Update updates a job object for a given job configuration and
 // performs the appropriate action based on the change
 func (h *serviceHandler) Update(
@@ -248,6 +370,13 @@ func (h *serviceHandler) Update(
 	}
 
 	newConfig := req.GetConfig()
+	provenanceLabels, err := h.verifyConfigSignature(
+		newConfig, req.GetSignature())
+	if err != nil {
+		h.metrics.JobUpdateFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf("%v", err)
+	}
+
 	oldConfig, oldConfigAddOn, err := h.jobStore.GetJobConfig(ctx, jobID.GetValue())
 
 	if err != nil {
@@ -282,6 +411,18 @@ func (h *serviceHandler) Update(
 		h.metrics.JobUpdateFail.Inc(1)
 		return nil, yarpcerrors.InvalidArgumentErrorf(err.Error())
 	}
+	err = jobconfig.ValidateTaskBackoffPolicy(
+		newConfig.GetTaskBackoffPolicy(), h.jobSvcCfg.TaskBackoffBounds)
+	if err != nil {
+		h.metrics.JobUpdateFail.Inc(1)
+		return nil, err
+	}
+	err = jobconfig.ValidateTaskSizeOverrideUpdate(
+		ctx, h.respoolClient, h.tlsProvider.PeerID(ctx), newConfig, oldConfig)
+	if err != nil {
+		h.metrics.JobUpdateFail.Inc(1)
+		return nil, err
+	}
 
 	if err = h.handleUpdateSecrets(ctx, jobID, existingSecretVolumes, newConfig,
 		req.GetSecrets()); err != nil {
@@ -306,7 +447,9 @@ func (h *serviceHandler) Update(
 		}
 	}
 	newConfigAddOn := &models.ConfigAddOn{
-		SystemLabels: jobutil.ConstructSystemLabels(newConfig, respoolPath),
+		SystemLabels: append(
+			jobutil.ConstructSystemLabels(newConfig, respoolPath),
+			provenanceLabels...),
 	}
 	// first persist the configuration
 	newUpdatedConfig, err := cachedJob.CompareAndSetConfig(
@@ -450,7 +593,19 @@ func (h *serviceHandler) Query(ctx context.Context, req *job.QueryRequest) (*job
 	h.metrics.JobAPIQuery.Inc(1)
 	callStart := time.Now()
 
-	jobConfigs, jobSummary, total, err := h.jobStore.QueryJobs(ctx, req.GetRespoolID(), req.GetSpec(), req.GetSummaryOnly())
+	pagination, err := h.jobSvcCfg.Pagination.JobQueryPagination(
+		req.GetSpec().GetPagination())
+	if err != nil {
+		h.metrics.JobQueryFail.Inc(1)
+		return nil, err
+	}
+	spec := &job.QuerySpec{}
+	if req.GetSpec() != nil {
+		spec = proto.Clone(req.GetSpec()).(*job.QuerySpec)
+	}
+	spec.Pagination = pagination
+
+	jobConfigs, jobSummary, total, err := h.jobStore.QueryJobs(ctx, req.GetRespoolID(), spec, req.GetSummaryOnly())
 	if err != nil {
 		h.metrics.JobQueryFail.Inc(1)
 		log.WithError(err).Error("Query job failed with error")
@@ -464,13 +619,17 @@ func (h *serviceHandler) Query(ctx context.Context, req *job.QueryRequest) (*job
 		}, nil
 	}
 
+	if req.GetIncludeAllocatedResources() {
+		h.setAllocatedResources(ctx, jobConfigs, jobSummary)
+	}
+
 	h.metrics.JobQuery.Inc(1)
 	resp := &job.QueryResponse{
 		Records: jobConfigs,
 		Results: jobSummary,
 		Pagination: &query.Pagination{
-			Offset: req.GetSpec().GetPagination().GetOffset(),
-			Limit:  req.GetSpec().GetPagination().GetLimit(),
+			Offset: pagination.GetOffset(),
+			Limit:  pagination.GetLimit(),
 			Total:  total,
 		},
 		Spec: req.GetSpec(),
@@ -481,6 +640,59 @@ func (h *serviceHandler) Query(ctx context.Context, req *job.QueryRequest) (*job
 	return resp, nil
 }
 
+// setAllocatedResources sets the resources allocated to the running
+// instances of each job in the summaries. The configs of the returned
+// job infos are used if present, otherwise the config is loaded from DB.
+// The task runtimes are read only for the jobs with instance configs, to
+// find which instances are running. A job whose config or task runtimes
+// cannot be loaded is returned without resources.
+func (h *serviceHandler) setAllocatedResources(
+	ctx context.Context,
+	jobInfos []*job.JobInfo,
+	summaries []*job.JobSummary) {
+	configs := make(map[string]*job.JobConfig)
+	for _, jobInfo := range jobInfos {
+		configs[jobInfo.GetId().GetValue()] = jobInfo.GetConfig()
+	}
+
+	for _, summary := range summaries {
+		jobID := summary.GetId().GetValue()
+		config, ok := configs[jobID]
+		if !ok {
+			var err error
+			config, _, err = h.jobStore.GetJobConfig(ctx, jobID)
+			if err != nil {
+				log.WithError(err).
+					WithField("job_id", jobID).
+					Warn("Failed to get job config for allocated resources")
+				continue
+			}
+		}
+		if len(config.GetInstanceConfig()) == 0 {
+			summary.AllocatedResources = jobutil.GetAllocatedResources(
+				config, summary.GetRuntime())
+			continue
+		}
+
+		runtimes, err := h.taskStore.GetTaskRuntimesForJobByRange(
+			ctx, summary.GetId(), nil)
+		if err != nil {
+			log.WithError(err).
+				WithField("job_id", jobID).
+				Warn("Failed to get task runtimes for allocated resources")
+			continue
+		}
+		var running []uint32
+		for instanceID, runtime := range runtimes {
+			if runtime.GetState() == task.TaskState_RUNNING {
+				running = append(running, instanceID)
+			}
+		}
+		summary.AllocatedResources = jobutil.GetInstancesAllocatedResources(
+			config, running)
+	}
+}
+
 // Delete kills all running tasks in a job
 func (h *serviceHandler) Delete(
 	ctx context.Context,
@@ -604,6 +816,15 @@ func (h *serviceHandler) Stop(
 		return nil, err
 	}
 
+	// a job whose instances are all stopped is not resumed
+	if len(req.GetRanges()) == 0 {
+		cachedJob := h.jobFactory.AddJob(req.GetId())
+		if err := setJobSuspended(ctx, cachedJob, false); err != nil {
+			h.metrics.JobStopFail.Inc(1)
+			return nil, err
+		}
+	}
+
 	h.metrics.JobStop.Inc(1)
 	return &job.StopResponse{
 		UpdateID:        updateID,
@@ -763,10 +984,936 @@ func (h *serviceHandler) GetActiveJobs(
 	}, nil
 }
 
-// validateResourcePool validates the resource pool before submitting job
+// UpdateStartAfter changes the time before which a batch job, which has not
+// started yet, is not admitted by the resource manager
+func (h *serviceHandler) UpdateStartAfter(
+	ctx context.Context,
+	req *job.UpdateStartAfterRequest) (*job.UpdateStartAfterResponse, error) {
+
+	h.metrics.JobAPIUpdateStartAfter.Inc(1)
+
+	if !h.candidate.IsLeader() {
+		h.metrics.JobUpdateStartAfterFail.Inc(1)
+		return nil, yarpcerrors.UnavailableErrorf(
+			"Job UpdateStartAfter API not suppported on non-leader")
+	}
+
+	if req.GetStartAfter() != "" {
+		if _, err := time.Parse(time.RFC3339, req.GetStartAfter()); err != nil {
+			h.metrics.JobUpdateStartAfterFail.Inc(1)
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"invalid start time: %s", req.GetStartAfter())
+		}
+	}
+
+	jobID := req.GetId()
+	cachedJob := h.jobFactory.AddJob(jobID)
+	jobRuntime, err := cachedJob.GetRuntime(ctx)
+	if err != nil {
+		log.WithError(err).
+			WithField("job_id", jobID.GetValue()).
+			Error("Failed to get runtime")
+		h.metrics.JobUpdateStartAfterFail.Inc(1)
+		return nil, err
+	}
+
+	jobConfig, configAddOn, err := h.jobStore.GetJobConfig(
+		ctx, jobID.GetValue())
+	if err != nil {
+		log.WithError(err).
+			WithField("job_id", jobID.GetValue()).
+			Error("Failed to GetJobConfig")
+		h.metrics.JobUpdateStartAfterFail.Inc(1)
+		return nil, err
+	}
+
+	if jobConfig.GetType() != job.JobType_BATCH {
+		h.metrics.JobUpdateStartAfterFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"start time is only supported for batch jobs")
+	}
+
+	if !isJobDeferred(jobConfig, jobRuntime, time.Now()) {
+		h.metrics.JobUpdateStartAfterFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"start time can only be changed before the job starts")
+	}
+
+	newConfig := proto.Clone(jobConfig).(*job.JobConfig)
+	newConfig.StartAfter = req.GetStartAfter()
+
+	// first persist the configuration
+	newUpdatedConfig, err := cachedJob.CompareAndSetConfig(
+		ctx,
+		newConfig,
+		configAddOn)
+	if err != nil {
+		h.metrics.JobUpdateStartAfterFail.Inc(1)
+		return nil, err
+	}
+
+	// next persist the new configuration version
+	err = cachedJob.Update(ctx, &job.JobInfo{
+		Runtime: &job.RuntimeInfo{
+			ConfigurationVersion: newUpdatedConfig.GetChangeLog().GetVersion(),
+		},
+	}, nil,
+		cached.UpdateCacheAndDB)
+	if err != nil {
+		h.metrics.JobUpdateStartAfterFail.Inc(1)
+		return nil, err
+	}
+
+	// finally update the tasks already held by the resource manager, the
+	// tasks enqueued later pick up the start time from the job config
+	_, err = h.resmgrClient.UpdateStartAfter(
+		ctx,
+		&resmgrsvc.UpdateStartAfterRequest{
+			JobId:      jobID,
+			RespoolId:  jobConfig.GetRespoolID(),
+			StartAfter: req.GetStartAfter(),
+		})
+	if err != nil {
+		log.WithError(err).
+			WithField("job_id", jobID.GetValue()).
+			Error("Failed to update start time in resource manager")
+		h.metrics.JobUpdateStartAfterFail.Inc(1)
+		return nil, err
+	}
+
+	h.metrics.JobUpdateStartAfter.Inc(1)
+	return &job.UpdateStartAfterResponse{
+		ConfigVersion: newUpdatedConfig.GetChangeLog().GetVersion(),
+	}, nil
+}
+
+// isJobDeferred returns true if the job has not started yet, and is
+// scheduled to start after now
+func isJobDeferred(
+	jobConfig *job.JobConfig,
+	jobRuntime *job.RuntimeInfo,
+	now time.Time) bool {
+	if jobRuntime.GetState() != job.JobState_INITIALIZED &&
+		jobRuntime.GetState() != job.JobState_PENDING {
+		return false
+	}
+
+	startAfter, err := time.Parse(time.RFC3339, jobConfig.GetStartAfter())
+	if err != nil {
+		return false
+	}
+	return startAfter.After(now)
+}
+
+// Suspend stops all the tasks of a job. Unlike Stop, the goal state of the
+// job is kept, and the stopped tasks are marked so that Resume starts them
+// again.
+func (h *serviceHandler) Suspend(
+	ctx context.Context,
+	req *job.SuspendRequest) (*job.SuspendResponse, error) {
+
+	h.metrics.JobAPISuspend.Inc(1)
+
+	if !h.candidate.IsLeader() {
+		h.metrics.JobSuspendFail.Inc(1)
+		return nil, yarpcerrors.UnavailableErrorf(
+			"Job Suspend API not suppported on non-leader")
+	}
+
+	jobID := req.GetId()
+	cachedJob := h.jobFactory.AddJob(jobID)
+	jobRuntime, err := cachedJob.GetRuntime(ctx)
+	if err != nil {
+		log.WithError(err).
+			WithField("job_id", jobID.GetValue()).
+			Error("Failed to get runtime")
+		h.metrics.JobSuspendFail.Inc(1)
+		return nil, err
+	}
+	if util.IsPelotonJobStateTerminal(jobRuntime.GetState()) {
+		h.metrics.JobSuspendFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"cannot suspend a job in terminal state:%s", jobRuntime.GetState())
+	}
+	// the tasks created after the suspend would not be stopped
+	if jobRuntime.GetState() == job.JobState_INITIALIZED {
+		h.metrics.JobSuspendFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"cannot suspend a partially created job")
+	}
+
+	// mark the job as suspended first, so that it is not terminated
+	// once all its tasks are killed
+	if err := setJobSuspended(ctx, cachedJob, true); err != nil {
+		h.goalStateDriver.EnqueueJob(jobID, time.Now())
+		h.metrics.JobSuspendFail.Inc(1)
+		return nil, err
+	}
+
+	taskInfos, err := h.taskStore.GetTasksForJob(ctx, jobID)
+	if err != nil {
+		log.WithError(err).
+			WithField("job_id", jobID.GetValue()).
+			Error("Failed to get tasks")
+		h.metrics.JobSuspendFail.Inc(1)
+		return nil, err
+	}
+
+	var instanceIds []uint32
+	runtimeDiffs := make(map[uint32]jobmgrcommon.RuntimeDiff)
+	for instanceID, taskInfo := range taskInfos {
+		runtime := taskInfo.GetRuntime()
+		// tasks which are stopped or have completed are left as is
+		if runtime.GetGoalState() == task.TaskState_KILLED ||
+			runtime.GetGoalState() == task.TaskState_DELETED ||
+			runtime.GetState() == task.TaskState_SUCCEEDED {
+			continue
+		}
+
+		runtimeDiffs[instanceID] = jobmgrcommon.RuntimeDiff{
+			jobmgrcommon.GoalStateField: task.TaskState_KILLED,
+			jobmgrcommon.SuspendedField: true,
+			jobmgrcommon.MessageField:   "Job suspend API request",
+			jobmgrcommon.ReasonField:    "",
+			jobmgrcommon.TerminationStatusField: &task.TerminationStatus{
+				Reason: task.TerminationStatus_TERMINATION_STATUS_REASON_KILLED_ON_REQUEST,
+			},
+		}
+		instanceIds = append(instanceIds, instanceID)
+	}
+
+	if err := cachedJob.PatchTasks(ctx, runtimeDiffs); err != nil {
+		log.WithError(err).
+			WithField("job_id", jobID.GetValue()).
+			WithField("instance_ids", instanceIds).
+			Error("Failed to suspend tasks")
+		h.metrics.JobSuspendFail.Inc(1)
+		return nil, err
+	}
+
+	for _, instanceID := range instanceIds {
+		h.goalStateDriver.EnqueueTask(jobID, instanceID, time.Now())
+	}
+	h.goalStateDriver.EnqueueJobWithDefaultDelay(
+		jobID, cachedJob.GetJobType())
+
+	h.metrics.JobSuspend.Inc(1)
+	return &job.SuspendResponse{
+		SuspendedInstanceIds: instanceIds,
+	}, nil
+}
+
+// Resume starts again the tasks of a job which were stopped by Suspend.
+func (h *serviceHandler) Resume(
+	ctx context.Context,
+	req *job.ResumeRequest) (*job.ResumeResponse, error) {
+
+	h.metrics.JobAPIResume.Inc(1)
+
+	if !h.candidate.IsLeader() {
+		h.metrics.JobResumeFail.Inc(1)
+		return nil, yarpcerrors.UnavailableErrorf(
+			"Job Resume API not suppported on non-leader")
+	}
+
+	jobID := req.GetId()
+	cachedJob := h.jobFactory.AddJob(jobID)
+	jobRuntime, err := cachedJob.GetRuntime(ctx)
+	if err != nil {
+		log.WithError(err).
+			WithField("job_id", jobID.GetValue()).
+			Error("Failed to get runtime")
+		h.metrics.JobResumeFail.Inc(1)
+		return nil, err
+	}
+	if !jobRuntime.GetSuspended() {
+		h.metrics.JobResumeFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf("job is not suspended")
+	}
+
+	cachedConfig, err := cachedJob.GetConfig(ctx)
+	if err != nil {
+		log.WithError(err).
+			WithField("job_id", jobID.GetValue()).
+			Error("Failed to get job config")
+		h.metrics.JobResumeFail.Inc(1)
+		return nil, err
+	}
+
+	taskInfos, err := h.taskStore.GetTasksForJob(ctx, jobID)
+	if err != nil {
+		log.WithError(err).
+			WithField("job_id", jobID.GetValue()).
+			Error("Failed to get tasks")
+		h.metrics.JobResumeFail.Inc(1)
+		return nil, err
+	}
+
+	var resumedInstanceIds []uint32
+	var failedInstanceIds []uint32
+	for instanceID, taskInfo := range taskInfos {
+		if !taskInfo.GetRuntime().GetSuspended() {
+			continue
+		}
+
+		resumed, err := resumeTask(
+			ctx, cachedJob, cachedConfig.GetType(), taskInfo)
+		if err != nil {
+			log.WithError(err).
+				WithField("job_id", jobID.GetValue()).
+				WithField("instance_id", instanceID).
+				Info("Failed to resume task")
+			failedInstanceIds = append(failedInstanceIds, instanceID)
+			continue
+		}
+		if resumed {
+			resumedInstanceIds = append(resumedInstanceIds, instanceID)
+		}
+	}
+
+	for _, instanceID := range resumedInstanceIds {
+		h.goalStateDriver.EnqueueTask(jobID, instanceID, time.Now())
+	}
+
+	// the job is left suspended until all its tasks are resumed, so that
+	// the resume can be retried
+	if len(failedInstanceIds) > 0 {
+		h.metrics.JobResumeFail.Inc(1)
+		return nil, yarpcerrors.InternalErrorf(
+			"failed to resume instances: %v", failedInstanceIds)
+	}
+
+	if err := setJobSuspended(ctx, cachedJob, false); err != nil {
+		h.goalStateDriver.EnqueueJob(jobID, time.Now())
+		h.metrics.JobResumeFail.Inc(1)
+		return nil, err
+	}
+	h.goalStateDriver.EnqueueJobWithDefaultDelay(
+		jobID, cachedJob.GetJobType())
+
+	h.metrics.JobResume.Inc(1)
+	return &job.ResumeResponse{
+		ResumedInstanceIds: resumedInstanceIds,
+	}, nil
+}
+
+// setJobSuspended sets whether the job is suspended in the job runtime.
+func setJobSuspended(
+	ctx context.Context,
+	cachedJob cached.Job,
+	suspended bool) error {
+	count := 0
+	for {
+		jobRuntime, err := cachedJob.GetRuntime(ctx)
+		if err != nil {
+			return err
+		}
+		if jobRuntime.GetSuspended() == suspended {
+			return nil
+		}
+
+		jobRuntime.Suspended = suspended
+		_, err = cachedJob.CompareAndSetRuntime(ctx, jobRuntime)
+		if err == jobmgrcommon.UnexpectedVersionError {
+			// concurrency error; retry MaxConcurrencyErrorRetry times
+			count = count + 1
+			if count < jobmgrcommon.MaxConcurrencyErrorRetry {
+				continue
+			}
+		}
+		return err
+	}
+}
+
+// resumeTask regenerates the runtime of a task stopped by a suspend with the
+// default goal state of the job. It returns false if the task was already
+// resumed.
+func resumeTask(
+	ctx context.Context,
+	cachedJob cached.Job,
+	jobType job.JobType,
+	taskInfo *task.TaskInfo) (bool, error) {
+	cachedTask, err := cachedJob.AddTask(ctx, taskInfo.GetInstanceId())
+	if err != nil {
+		return false, err
+	}
+
+	count := 0
+	for {
+		taskRuntime, err := cachedTask.GetRuntime(ctx)
+		if err != nil {
+			return false, err
+		}
+		if !taskRuntime.GetSuspended() {
+			return false, nil
+		}
+
+		taskutil.RegenerateMesosTaskRuntime(
+			cachedJob.ID(),
+			taskInfo.GetInstanceId(),
+			taskRuntime,
+			taskutil.GetInitialHealthState(taskInfo.GetConfig()),
+		)
+		taskRuntime.GoalState = jobmgrtask.GetDefaultTaskGoalState(jobType)
+		taskRuntime.Message = "Job resume API request"
+
+		_, err = cachedTask.CompareAndSetRuntime(ctx, taskRuntime, jobType)
+		if err == jobmgrcommon.UnexpectedVersionError {
+			// concurrency error; retry MaxConcurrencyErrorRetry times
+			count = count + 1
+			if count < jobmgrcommon.MaxConcurrencyErrorRetry {
+				continue
+			}
+		}
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+}
+
+// DeleteInstances permanently removes terminated instances from a batch job.
+// The instance IDs of a job are expected to be contiguous, so the instances
+// above the new instance count are renumbered into the IDs of the removed
+// instances before the runtimes above the new instance count are deleted.
+// The writes are ordered so that an interrupted call can be retried: the
+// renumbered instances are copied again until the new instance count is
+// written, and the runtimes left above the instance count are removed by
+// the next call.
+func (h *serviceHandler) DeleteInstances(
+	ctx context.Context,
+	req *job.DeleteInstancesRequest) (*job.DeleteInstancesResponse, error) {
+
+	h.metrics.JobAPIDeleteInstances.Inc(1)
+
+	if !h.candidate.IsLeader() {
+		h.metrics.JobDeleteInstancesFail.Inc(1)
+		return nil, yarpcerrors.UnavailableErrorf(
+			"Job DeleteInstances API not suppported on non-leader")
+	}
+
+	if len(req.GetInstanceIds()) == 0 {
+		h.metrics.JobDeleteInstancesFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"no instance to delete")
+	}
+
+	jobID := req.GetId()
+	jobConfig, configAddOn, err := h.jobStore.GetJobConfig(
+		ctx, jobID.GetValue())
+	if err != nil {
+		log.WithError(err).
+			WithField("job_id", jobID.GetValue()).
+			Error("Failed to GetJobConfig")
+		h.metrics.JobDeleteInstancesFail.Inc(1)
+		return nil, err
+	}
+
+	if jobConfig.GetType() != job.JobType_BATCH {
+		h.metrics.JobDeleteInstancesFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"instances of a stateless job are removed by an update")
+	}
+
+	instanceCount := jobConfig.GetInstanceCount()
+	taskInfos, err := h.taskStore.GetTasksForJob(ctx, jobID)
+	if err != nil {
+		h.metrics.JobDeleteInstancesFail.Inc(1)
+		return nil, err
+	}
+
+	// remove the runtimes left above the instance count by a previous call
+	// interrupted after writing the new instance count
+	cachedJob := h.jobFactory.AddJob(jobID)
+	for instanceID := range taskInfos {
+		if instanceID < instanceCount {
+			continue
+		}
+		if err := h.deleteInstance(ctx, jobID, cachedJob, instanceID); err != nil {
+			h.metrics.JobDeleteInstancesFail.Inc(1)
+			return nil, err
+		}
+	}
+
+	instancesToDelete := make(map[uint32]bool)
+	for _, instanceID := range req.GetInstanceIds() {
+		if instanceID >= instanceCount {
+			h.metrics.JobDeleteInstancesFail.Inc(1)
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"instance %d is out of range", instanceID)
+		}
+		instancesToDelete[instanceID] = true
+	}
+	newInstanceCount := instanceCount - uint32(len(instancesToDelete))
+	if newInstanceCount == 0 {
+		h.metrics.JobDeleteInstancesFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"cannot delete all the instances of a job")
+	}
+
+	// the instances removed below the new instance count are replaced by
+	// the remaining instances above it
+	var holes, instancesToMove []uint32
+	for i := uint32(0); i < instanceCount; i++ {
+		if i < newInstanceCount && instancesToDelete[i] {
+			holes = append(holes, i)
+		} else if i >= newInstanceCount && !instancesToDelete[i] {
+			instancesToMove = append(instancesToMove, i)
+		}
+	}
+
+	// both the removed and the renumbered instances need to be terminated
+	instancesToCheck := append([]uint32{}, instancesToMove...)
+	for instanceID := range instancesToDelete {
+		instancesToCheck = append(instancesToCheck, instanceID)
+	}
+	for _, instanceID := range instancesToCheck {
+		if !isTaskStopped(taskInfos[instanceID].GetRuntime()) {
+			h.metrics.JobDeleteInstancesFail.Inc(1)
+			return nil, yarpcerrors.FailedPreconditionErrorf(
+				"instance %d is not terminated", instanceID)
+		}
+	}
+
+	renumbered := make(map[uint32]uint32)
+	for i, newInstanceID := range holes {
+		taskInfo := taskInfos[instancesToMove[i]]
+		runtime := renumberTaskRuntime(
+			jobID,
+			newInstanceID,
+			taskInfo.GetRuntime(),
+			taskInfos[newInstanceID].GetRuntime())
+		if err := h.taskStore.CreateTaskConfig(
+			ctx,
+			jobID,
+			int64(newInstanceID),
+			taskInfo.GetConfig(),
+			configAddOn,
+			taskInfo.GetRuntime().GetConfigVersion(),
+		); err != nil {
+			h.metrics.JobDeleteInstancesFail.Inc(1)
+			return nil, err
+		}
+
+		if err := h.taskStore.UpdateTaskRuntime(
+			ctx,
+			jobID,
+			newInstanceID,
+			runtime,
+			job.JobType_BATCH,
+		); err != nil {
+			h.metrics.JobDeleteInstancesFail.Inc(1)
+			return nil, err
+		}
+
+		// the runtime is loaded again from DB on the next access
+		cachedJob.RemoveTask(newInstanceID)
+		renumbered[instancesToMove[i]] = newInstanceID
+	}
+
+	newConfig := proto.Clone(jobConfig).(*job.JobConfig)
+	newConfig.InstanceCount = newInstanceCount
+	newConfig.InstanceConfig = make(map[uint32]*task.TaskConfig)
+	for instanceID, taskConfig := range jobConfig.GetInstanceConfig() {
+		if newInstanceID, ok := renumbered[instanceID]; ok {
+			newConfig.InstanceConfig[newInstanceID] = taskConfig
+		} else if instanceID < newInstanceCount &&
+			!instancesToDelete[instanceID] {
+			newConfig.InstanceConfig[instanceID] = taskConfig
+		}
+	}
+
+	newUpdatedConfig, err := cachedJob.CompareAndSetConfig(
+		ctx,
+		newConfig,
+		configAddOn)
+	if err != nil {
+		h.metrics.JobDeleteInstancesFail.Inc(1)
+		return nil, err
+	}
+
+	err = cachedJob.Update(ctx, &job.JobInfo{
+		Runtime: &job.RuntimeInfo{
+			ConfigurationVersion: newUpdatedConfig.GetChangeLog().GetVersion(),
+		},
+	}, nil,
+		cached.UpdateCacheAndDB)
+	if err != nil {
+		h.metrics.JobDeleteInstancesFail.Inc(1)
+		return nil, err
+	}
+
+	// finally remove the instances above the new instance count
+	for instanceID := newInstanceCount; instanceID < instanceCount; instanceID++ {
+		if err := h.deleteInstance(ctx, jobID, cachedJob, instanceID); err != nil {
+			h.metrics.JobDeleteInstancesFail.Inc(1)
+			return nil, err
+		}
+	}
+
+	h.goalStateDriver.EnqueueJobWithDefaultDelay(
+		jobID, cachedJob.GetJobType())
+
+	h.metrics.JobDeleteInstances.Inc(1)
+	return &job.DeleteInstancesResponse{
+		ConfigVersion:         newUpdatedConfig.GetChangeLog().GetVersion(),
+		RenumberedInstanceIds: renumbered,
+	}, nil
+}
+
+// deleteInstance removes the runtime of an instance of a job.
+func (h *serviceHandler) deleteInstance(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	cachedJob cached.Job,
+	instanceID uint32) error {
+	cachedJob.RemoveTask(instanceID)
+	return h.taskStore.DeleteTaskRuntime(ctx, jobID, instanceID)
+}
+
+// renumberTaskRuntime returns the runtime of a task renumbered into the
+// instance ID of a removed instance. The task gets a new run ID following
+// the runs of the removed instance, so that its mesos task ID neither
+// reuses the one of the previous instance nor the ones of the past runs
+// of the removed instance.
+func renumberTaskRuntime(
+	jobID *peloton.JobID,
+	instanceID uint32,
+	runtime *task.RuntimeInfo,
+	removedRuntime *task.RuntimeInfo) *task.RuntimeInfo {
+	runID, err := util.ParseRunID(removedRuntime.GetMesosTaskId().GetValue())
+	if err != nil {
+		runID = 0
+	}
+	mesosTaskID := util.CreateMesosTaskID(jobID, instanceID, runID+1)
+
+	newRuntime := proto.Clone(runtime).(*task.RuntimeInfo)
+	newRuntime.MesosTaskId = mesosTaskID
+	newRuntime.DesiredMesosTaskId = mesosTaskID
+	newRuntime.PrevMesosTaskId = removedRuntime.GetMesosTaskId()
+	return newRuntime
+}
+
+// isTaskStopped returns true if the task is terminated, and is not going
+// to be started again by the goal state engine.
+func isTaskStopped(runtime *task.RuntimeInfo) bool {
+	if !util.IsPelotonStateTerminal(runtime.GetState()) {
+		return false
+	}
+	return runtime.GetGoalState() == task.TaskState_KILLED ||
+		runtime.GetState() == runtime.GetGoalState()
+}
+
+// GetJobIDFromExternalRefID returns the job created with the external
+// reference ID.
+func (h *serviceHandler) GetJobIDFromExternalRefID(
+	ctx context.Context,
+	req *job.GetJobIDFromExternalRefIDRequest,
+) (*job.GetJobIDFromExternalRefIDResponse, error) {
+	log.WithField("request", req).
+		Debug("JobManager.GetJobIDFromExternalRefID called")
+	h.metrics.JobAPIGetJobIDFromExternalRefID.Inc(1)
+
+	if len(req.GetExternalRefId()) == 0 {
+		h.metrics.JobGetJobIDFromExternalRefIDFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"external reference id is not provided")
+	}
+
+	jobID, err := h.jobExternalRefOps.Get(ctx, req.GetExternalRefId())
+	if err != nil {
+		h.metrics.JobGetJobIDFromExternalRefIDFail.Inc(1)
+		if err == gocql.ErrNotFound {
+			return nil, yarpcerrors.NotFoundErrorf(
+				"job with external reference id %s not found",
+				req.GetExternalRefId())
+		}
+		return nil, errors.Wrap(err, "failed to get job id from DB")
+	}
+
+	h.metrics.JobGetJobIDFromExternalRefID.Inc(1)
+	return &job.GetJobIDFromExternalRefIDResponse{
+		JobId: jobID,
+	}, nil
+}
+
+// GetRunStatistics returns the percentiles of how long the recent runs
+// of the tasks of a job took to start and how long they ran.
+func (h *serviceHandler) GetRunStatistics(
+	ctx context.Context,
+	req *job.GetRunStatisticsRequest,
+) (*job.GetRunStatisticsResponse, error) {
+	log.WithField("request", req).
+		Debug("JobManager.GetRunStatistics called")
+	h.metrics.JobAPIGetRunStatistics.Inc(1)
+
+	if len(req.GetId().GetValue()) == 0 {
+		h.metrics.JobGetRunStatisticsFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf("job id is not provided")
+	}
+
+	runs, err := h.runDurationOps.GetAll(ctx, req.GetId())
+	if err != nil {
+		h.metrics.JobGetRunStatisticsFail.Inc(1)
+		return nil, errors.Wrap(err, "failed to get run durations from DB")
+	}
+
+	h.metrics.JobGetRunStatistics.Inc(1)
+	return &job.GetRunStatisticsResponse{
+		Statistics: jobutil.GetRunStatistics(runs),
+	}, nil
+}
+
+// GetRespoolJobsSnapshot returns the resource pool tree along with the
+// summaries of the jobs in each resource pool. Cassandra cannot read
+// multiple tables at one point in time, so the resource pools are read
+// again after the jobs, and the snapshot is retried if they changed.
+func (h *serviceHandler) GetRespoolJobsSnapshot(
+	ctx context.Context,
+	req *job.GetRespoolJobsSnapshotRequest,
+) (*job.GetRespoolJobsSnapshotResponse, error) {
+	log.WithField("request", req).
+		Debug("JobManager.GetRespoolJobsSnapshot called")
+	h.metrics.JobAPIGetRespoolJobsSnapshot.Inc(1)
+
+	for i := 0; i < _maxSnapshotAttempts; i++ {
+		respools, err := h.respoolStore.GetAllResourcePools(ctx)
+		if err != nil {
+			h.metrics.JobGetRespoolJobsSnapshotFail.Inc(1)
+			return nil, errors.Wrap(err, "failed to get resource pools from DB")
+		}
+
+		summaries, err := h.jobStore.GetAllJobsInJobIndex(ctx)
+		if err != nil {
+			h.metrics.JobGetRespoolJobsSnapshotFail.Inc(1)
+			return nil, errors.Wrap(err, "failed to get jobs from DB")
+		}
+		snapshotTime := time.Now()
+
+		respoolsAfter, err := h.respoolStore.GetAllResourcePools(ctx)
+		if err != nil {
+			h.metrics.JobGetRespoolJobsSnapshotFail.Inc(1)
+			return nil, errors.Wrap(err, "failed to get resource pools from DB")
+		}
+
+		if !isSameRespools(respools, respoolsAfter) {
+			log.WithField("attempt", i).
+				Info("Resource pools changed while reading jobs snapshot")
+			continue
+		}
+
+		root, orphans := jobutil.GetRespoolJobs(
+			respools, summaries, req.GetIncludeTerminalJobs())
+		h.metrics.JobGetRespoolJobsSnapshot.Inc(1)
+		return &job.GetRespoolJobsSnapshotResponse{
+			Root:         root,
+			OrphanJobs:   orphans,
+			SnapshotTime: snapshotTime.UTC().Format(time.RFC3339Nano),
+		}, nil
+	}
+
+	h.metrics.JobGetRespoolJobsSnapshotFail.Inc(1)
+	return nil, yarpcerrors.AbortedErrorf(
+		"resource pools kept changing while reading jobs snapshot")
+}
+
+// LintJobConfig lints a job config against the best-practice rules of
+// the requested rule set, and validates it as Create would.
+func (h *serviceHandler) LintJobConfig(
+	ctx context.Context,
+	req *job.LintJobConfigRequest,
+) (*job.LintJobConfigResponse, error) {
+	log.WithField("request", req).Debug("JobManager.LintJobConfig called")
+	h.metrics.JobAPILintJobConfig.Inc(1)
+
+	if req.GetConfig() == nil {
+		h.metrics.JobLintJobConfigFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf("config is not provided")
+	}
+
+	if h.configLinter == nil {
+		h.metrics.JobLintJobConfigFail.Inc(1)
+		return nil, yarpcerrors.UnimplementedErrorf(
+			"linting of job configs is not configured")
+	}
+
+	// lint before validating, as the validation caps the restart policy
+	warnings, err := h.configLinter.Lint(req.GetConfig(), req.GetRuleSet())
+	if err != nil {
+		h.metrics.JobLintJobConfigFail.Inc(1)
+		return nil, err
+	}
+
+	resp := &job.LintJobConfigResponse{Warnings: warnings}
+	if err := jobconfig.ValidateConfig(
+		req.GetConfig(), h.jobSvcCfg.MaxTasksPerJob); err != nil {
+		resp.ValidationError = err.Error()
+	}
+
+	h.metrics.JobLintJobConfig.Inc(1)
+	return resp, nil
+}
+
+// CheckJobConfigs checks the stored configs of jobs against the API of
+// the running version, so that the configs which need to be migrated are
+// found before upgrading the control plane. The jobs are checked a page at
+// a time, with a bounded number of configs read in parallel.
+func (h *serviceHandler) CheckJobConfigs(
+	ctx context.Context,
+	req *job.CheckJobConfigsRequest,
+) (*job.CheckJobConfigsResponse, error) {
+	log.WithField("request", req).Debug("JobManager.CheckJobConfigs called")
+	h.metrics.JobAPICheckJobConfigs.Inc(1)
+
+	limit := req.GetLimit()
+	if limit > h.jobSvcCfg.MaxCheckJobConfigsLimit {
+		h.metrics.JobCheckJobConfigsFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"limit %d exceeds the maximum of %d jobs per page",
+			limit, h.jobSvcCfg.MaxCheckJobConfigsLimit)
+	}
+	if limit == 0 {
+		limit = h.jobSvcCfg.MaxCheckJobConfigsLimit
+	}
+
+	jobIDs := req.GetIds()
+	if len(jobIDs) == 0 {
+		summaries, err := h.jobStore.GetAllJobsInJobIndex(ctx)
+		if err != nil {
+			h.metrics.JobCheckJobConfigsFail.Inc(1)
+			return nil, errors.Wrap(err, "failed to get jobs from DB")
+		}
+		for _, summary := range summaries {
+			if !req.GetIncludeTerminalJobs() &&
+				util.IsPelotonJobStateTerminal(summary.GetRuntime().GetState()) {
+				continue
+			}
+			jobIDs = append(jobIDs, summary.GetId())
+		}
+		// the order of the job index is not stable across calls
+		sort.Slice(jobIDs, func(i, j int) bool {
+			return jobIDs[i].GetValue() < jobIDs[j].GetValue()
+		})
+	}
+
+	resp := &job.CheckJobConfigsResponse{}
+	offset := req.GetOffset()
+	if offset >= uint32(len(jobIDs)) {
+		h.metrics.JobCheckJobConfigs.Inc(1)
+		return resp, nil
+	}
+	end := uint32(len(jobIDs))
+	if end-offset > limit {
+		end = offset + limit
+		resp.NextOffset = end
+	}
+	jobIDs = jobIDs[offset:end]
+
+	// the configs are read and checked by a bounded number of workers,
+	// each result is kept at the position of its job to keep the order
+	results := make([]*job.JobConfigCompatibility, len(jobIDs))
+	checked := make([]bool, len(jobIDs))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < h.jobSvcCfg.CheckJobConfigsConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				results[index], checked[index] =
+					h.checkJobConfig(ctx, jobIDs[index])
+			}
+		}()
+	}
+	for index := range jobIDs {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	for index, result := range results {
+		if checked[index] {
+			resp.CheckedJobs++
+		}
+		if result != nil {
+			resp.Jobs = append(resp.Jobs, result)
+		}
+	}
+
+	h.metrics.JobCheckJobConfigs.Inc(1)
+	return resp, nil
+}
+
+// checkJobConfig checks the stored config of a job against the current
+// API. It returns the result of the job if the config has issues or failed
+// to be read, and whether the config was checked.
+func (h *serviceHandler) checkJobConfig(
+	ctx context.Context,
+	jobID *peloton.JobID,
+) (*job.JobConfigCompatibility, bool) {
+	jobConfig, _, err := h.jobStore.GetJobConfig(ctx, jobID.GetValue())
+	if err != nil {
+		log.WithError(err).
+			WithField("job_id", jobID.GetValue()).
+			Info("failed to get job config to check")
+		return &job.JobConfigCompatibility{
+			Id:    jobID,
+			Error: err.Error(),
+		}, false
+	}
+
+	issues := compat.Check(jobConfig, h.jobSvcCfg.MaxTasksPerJob)
+	if len(issues) == 0 {
+		return nil, true
+	}
+	return &job.JobConfigCompatibility{
+		Id:            jobID,
+		ConfigVersion: jobConfig.GetChangeLog().GetVersion(),
+		Issues:        issues,
+	}, true
+}
+
+// isSameRespools returns true if both sets of resource pool configs
+// have the same resource pools with the same configs.
+func isSameRespools(
+	respools map[string]*respool.ResourcePoolConfig,
+	other map[string]*respool.ResourcePoolConfig) bool {
+	if len(respools) != len(other) {
+		return false
+	}
+	for id, config := range respools {
+		otherConfig, ok := other[id]
+		if !ok || !proto.Equal(config, otherConfig) {
+			return false
+		}
+	}
+	return true
+}
+
+// verifyConfigSignature verifies the signature of the job config, and
+// returns the system labels recording its provenance.
+func (h *serviceHandler) verifyConfigSignature(
+	config *job.JobConfig,
+	signature *job.ConfigSignature) ([]*peloton.Label, error) {
+	if h.configVerifier == nil {
+		return nil, nil
+	}
+
+	configProvenance, err := h.configVerifier.Verify(config, signature)
+	if err != nil {
+		log.WithError(err).
+			WithField("key_id", signature.GetKeyId()).
+			Warn("Failed to verify job config signature")
+		return nil, err
+	}
+	return configProvenance.Labels(), nil
+}
+
+// validateResourcePool validates the resource pool before submitting job,
+// and returns the info of the resource pool
 func (h *serviceHandler) validateResourcePool(
 	respoolID *peloton.ResourcePoolID,
-) (*respool.ResourcePoolPath, error) {
+) (*respool.ResourcePoolInfo, error) {
 	ctx, cancelFunc := context.WithTimeout(h.rootCtx, 10*time.Second)
 	defer cancelFunc()
 
@@ -802,7 +1949,7 @@ func (h *serviceHandler) validateResourcePool(
 		return nil, errNonLeafResourcePool
 	}
 
-	return response.GetPoolinfo().GetPath(), nil
+	return response.GetPoolinfo(), nil
 }
 
 // validateSecretsAndConfig checks the secrets for input sanity and makes sure
@@ -1076,3 +2223,44 @@ func convertRangesToSlice(ranges []*task.InstanceRange, instanceCount uint32) []
 	}
 	return result
 }
+
+// ExportStateDiff returns the changes made to the jobs, resource pools
+// and hosts of the cluster between two points in time.
+func (h *serviceHandler) ExportStateDiff(
+	ctx context.Context,
+	req *job.ExportStateDiffRequest,
+) (*job.ExportStateDiffResponse, error) {
+	log.WithField("request", req).Debug("JobManager.ExportStateDiff called")
+	h.metrics.JobAPIExportStateDiff.Inc(1)
+
+	if h.stateExporter == nil {
+		h.metrics.JobExportStateDiffFail.Inc(1)
+		return nil, yarpcerrors.UnimplementedErrorf(
+			"snapshots of the cluster state are not enabled")
+	}
+
+	from, err := time.Parse(time.RFC3339, req.GetFromTime())
+	if err != nil {
+		h.metrics.JobExportStateDiffFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"invalid from time: %v", err)
+	}
+	var to time.Time
+	if len(req.GetToTime()) != 0 {
+		to, err = time.Parse(time.RFC3339, req.GetToTime())
+		if err != nil {
+			h.metrics.JobExportStateDiffFail.Inc(1)
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"invalid to time: %v", err)
+		}
+	}
+
+	resp, err := h.stateExporter.ExportDiff(ctx, from, to)
+	if err != nil {
+		h.metrics.JobExportStateDiffFail.Inc(1)
+		return nil, err
+	}
+
+	h.metrics.JobExportStateDiff.Inc(1)
+	return resp, nil
+}
@@ -16,7 +16,13 @@ package jobsvc
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
 	"fmt"
 	"testing"
 	"time"
@@ -25,6 +31,7 @@ import (
 	apierrors "github.com/uber/peloton/.gen/peloton/api/v0/errors"
 	"github.com/uber/peloton/.gen/peloton/api/v0/job"
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/query"
 	"github.com/uber/peloton/.gen/peloton/api/v0/respool"
 	respoolmocks "github.com/uber/peloton/.gen/peloton/api/v0/respool/mocks"
 	"github.com/uber/peloton/.gen/peloton/api/v0/task"
@@ -39,12 +46,17 @@ import (
 	"github.com/uber/peloton/pkg/jobmgr/cached"
 	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
 	cachedtest "github.com/uber/peloton/pkg/jobmgr/cached/test"
+	jobmgrcommon "github.com/uber/peloton/pkg/jobmgr/common"
 	goalstatemocks "github.com/uber/peloton/pkg/jobmgr/goalstate/mocks"
+	"github.com/uber/peloton/pkg/jobmgr/job/lint"
+	"github.com/uber/peloton/pkg/jobmgr/job/provenance"
 	jobmgrtask "github.com/uber/peloton/pkg/jobmgr/task"
 	jobutil "github.com/uber/peloton/pkg/jobmgr/util/job"
 	storemocks "github.com/uber/peloton/pkg/storage/mocks"
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
 	objectmocks "github.com/uber/peloton/pkg/storage/objects/mocks"
 
+	"github.com/gocql/gocql"
 	"github.com/golang/mock/gomock"
 	"github.com/pborman/uuid"
 	"github.com/pkg/errors"
@@ -81,18 +93,21 @@ type JobHandlerTestSuite struct {
 	taskInfos     map[uint32]*task.TaskInfo
 	testRespoolID *peloton.ResourcePoolID
 
-	ctrl                  *gomock.Controller
-	mockedCandidate       *leadermocks.MockCandidate
-	mockedRespoolClient   *respoolmocks.MockResourceManagerYARPCClient
-	mockedResmgrClient    *resmocks.MockResourceManagerServiceYARPCClient
-	mockedJobFactory      *cachedmocks.MockJobFactory
-	mockedCachedJob       *cachedmocks.MockJob
-	mockedCachedUpdate    *cachedmocks.MockUpdate
-	mockedGoalStateDriver *goalstatemocks.MockDriver
-	mockedJobStore        *storemocks.MockJobStore
-	mockedTaskStore       *storemocks.MockTaskStore
-	mockedJobIndexOps     *objectmocks.MockJobIndexOps
-	mockedSecretInfoOps   *objectmocks.MockSecretInfoOps
+	ctrl                    *gomock.Controller
+	mockedCandidate         *leadermocks.MockCandidate
+	mockedRespoolClient     *respoolmocks.MockResourceManagerYARPCClient
+	mockedResmgrClient      *resmocks.MockResourceManagerServiceYARPCClient
+	mockedJobFactory        *cachedmocks.MockJobFactory
+	mockedCachedJob         *cachedmocks.MockJob
+	mockedCachedUpdate      *cachedmocks.MockUpdate
+	mockedGoalStateDriver   *goalstatemocks.MockDriver
+	mockedJobStore          *storemocks.MockJobStore
+	mockedTaskStore         *storemocks.MockTaskStore
+	mockedRespoolStore      *storemocks.MockResourcePoolStore
+	mockedJobIndexOps       *objectmocks.MockJobIndexOps
+	mockedSecretInfoOps     *objectmocks.MockSecretInfoOps
+	mockedJobExternalRefOps *objectmocks.MockJobExternalRefOps
+	mockedRunDurationOps    *objectmocks.MockRunDurationOps
 }
 
 // helper to initialize mocks in JobHandlerTestSuite
@@ -108,13 +123,19 @@ func (suite *JobHandlerTestSuite) initializeMocks() {
 	suite.mockedResmgrClient = resmocks.NewMockResourceManagerServiceYARPCClient(suite.ctrl)
 	suite.mockedCandidate = leadermocks.NewMockCandidate(suite.ctrl)
 	suite.mockedTaskStore = storemocks.NewMockTaskStore(suite.ctrl)
+	suite.mockedRespoolStore = storemocks.NewMockResourcePoolStore(suite.ctrl)
 	suite.mockedJobIndexOps = objectmocks.NewMockJobIndexOps(suite.ctrl)
 	suite.mockedSecretInfoOps = objectmocks.NewMockSecretInfoOps(suite.ctrl)
+	suite.mockedJobExternalRefOps = objectmocks.NewMockJobExternalRefOps(suite.ctrl)
+	suite.mockedRunDurationOps = objectmocks.NewMockRunDurationOps(suite.ctrl)
 
 	suite.handler.jobStore = suite.mockedJobStore
 	suite.handler.taskStore = suite.mockedTaskStore
+	suite.handler.respoolStore = suite.mockedRespoolStore
 	suite.handler.jobIndexOps = suite.mockedJobIndexOps
 	suite.handler.secretInfoOps = suite.mockedSecretInfoOps
+	suite.handler.jobExternalRefOps = suite.mockedJobExternalRefOps
+	suite.handler.runDurationOps = suite.mockedRunDurationOps
 	suite.handler.jobFactory = suite.mockedJobFactory
 	suite.handler.goalStateDriver = suite.mockedGoalStateDriver
 	suite.handler.respoolClient = suite.mockedRespoolClient
@@ -161,8 +182,8 @@ func (suite *JobHandlerTestSuite) setupMocks(
 	suite.mockedCachedJob.EXPECT().
 		Update(gomock.Any(), gomock.Any(), gomock.Any(), cached.UpdateCacheAndDB).
 		Return(nil).AnyTimes()
-	suite.mockedGoalStateDriver.EXPECT().JobRuntimeDuration(job.JobType_BATCH).
-		Return(1 * time.Second).AnyTimes()
+	suite.mockedGoalStateDriver.EXPECT().
+		EnqueueJobWithDefaultDelay(jobID, job.JobType_BATCH).AnyTimes()
 	suite.mockedGoalStateDriver.EXPECT().EnqueueJob(jobID, gomock.Any()).AnyTimes()
 	suite.mockedGoalStateDriver.EXPECT().EnqueueTask(
 		jobID, gomock.Any(), gomock.Any()).AnyTimes()
@@ -174,9 +195,14 @@ func (suite *JobHandlerTestSuite) setupMocks(
 func (suite *JobHandlerTestSuite) SetupTest() {
 	mtx := NewMetrics(tally.NoopScope)
 	suite.handler = &serviceHandler{
-		metrics:   mtx,
-		rootCtx:   context.Background(),
-		jobSvcCfg: Config{MaxTasksPerJob: _defaultMaxTasksPerJob},
+		metrics: mtx,
+		rootCtx: context.Background(),
+		jobSvcCfg: Config{
+			MaxTasksPerJob:             _defaultMaxTasksPerJob,
+			MaxCheckJobConfigsLimit:    _defaultMaxCheckJobConfigsLimit,
+			CheckJobConfigsConcurrency: _defaultCheckJobConfigsConcurrency,
+		},
+		jobIDGenerator: randomJobIDGenerator{},
 	}
 	suite.testJobID = &peloton.JobID{
 		Value: uuid.New(),
@@ -351,6 +377,129 @@ func (suite *JobHandlerTestSuite) TestCreateJob_JobAlreadyExistErr() {
 	suite.Equal(expectedErr, resp.GetError())
 }
 
+// TestCreateJob_ExternalRefID tests creating a job with an external
+// reference ID
+func (suite *JobHandlerTestSuite) TestCreateJob_ExternalRefID() {
+	testCmd := "echo test"
+	defaultConfig := &task.TaskConfig{
+		Command: &mesos.CommandInfo{Value: &testCmd},
+	}
+	jobConfig := &job.JobConfig{
+		DefaultConfig: defaultConfig,
+		RespoolID:     suite.testRespoolID,
+	}
+
+	suite.setupMocks(suite.testJobID, suite.testRespoolID)
+	gomock.InOrder(
+		suite.mockedJobExternalRefOps.EXPECT().
+			Create(gomock.Any(), "workflow-run-1", suite.testJobID).
+			Return(nil),
+		suite.mockedCachedJob.EXPECT().
+			Create(gomock.Any(), jobConfig, gomock.Any(), "peloton").
+			Return(nil),
+	)
+
+	resp, err := suite.handler.Create(suite.context, &job.CreateRequest{
+		Id:            suite.testJobID,
+		Config:        jobConfig,
+		ExternalRefId: "workflow-run-1",
+	})
+	suite.NoError(err)
+	suite.Nil(resp.GetError())
+	suite.Equal(suite.testJobID, resp.GetJobId())
+}
+
+// TestCreateJob_ExternalRefIDAlreadyUsed tests creating a job with an
+// external reference ID used by another job
+func (suite *JobHandlerTestSuite) TestCreateJob_ExternalRefIDAlreadyUsed() {
+	testCmd := "echo test"
+	defaultConfig := &task.TaskConfig{
+		Command: &mesos.CommandInfo{Value: &testCmd},
+	}
+	jobConfig := &job.JobConfig{
+		DefaultConfig: defaultConfig,
+		RespoolID:     suite.testRespoolID,
+	}
+	existingJobID := &peloton.JobID{Value: uuid.New()}
+
+	suite.setupMocks(suite.testJobID, suite.testRespoolID)
+	suite.mockedJobExternalRefOps.EXPECT().
+		Create(gomock.Any(), "workflow-run-1", suite.testJobID).
+		Return(yarpcerrors.AlreadyExistsErrorf("item already exists"))
+	suite.mockedJobExternalRefOps.EXPECT().
+		Get(gomock.Any(), "workflow-run-1").
+		Return(existingJobID, nil)
+
+	resp, err := suite.handler.Create(suite.context, &job.CreateRequest{
+		Id:            suite.testJobID,
+		Config:        jobConfig,
+		ExternalRefId: "workflow-run-1",
+	})
+	suite.NoError(err)
+	suite.Equal(existingJobID, resp.GetError().GetAlreadyExists().GetId())
+	suite.Equal(existingJobID, resp.GetJobId())
+}
+
+// TestCreateJob_ExternalRefIDDBError tests failing to map the external
+// reference ID to the job
+func (suite *JobHandlerTestSuite) TestCreateJob_ExternalRefIDDBError() {
+	testCmd := "echo test"
+	defaultConfig := &task.TaskConfig{
+		Command: &mesos.CommandInfo{Value: &testCmd},
+	}
+	jobConfig := &job.JobConfig{
+		DefaultConfig: defaultConfig,
+		RespoolID:     suite.testRespoolID,
+	}
+
+	suite.setupMocks(suite.testJobID, suite.testRespoolID)
+	suite.mockedJobExternalRefOps.EXPECT().
+		Create(gomock.Any(), "workflow-run-1", suite.testJobID).
+		Return(errors.New("test error"))
+
+	_, err := suite.handler.Create(suite.context, &job.CreateRequest{
+		Id:            suite.testJobID,
+		Config:        jobConfig,
+		ExternalRefId: "workflow-run-1",
+	})
+	suite.Error(err)
+}
+
+// TestCreateJob_ExternalRefIDJobCreateFail tests that the external
+// reference ID is unmapped when the job fails to be created
+func (suite *JobHandlerTestSuite) TestCreateJob_ExternalRefIDJobCreateFail() {
+	testCmd := "echo test"
+	defaultConfig := &task.TaskConfig{
+		Command: &mesos.CommandInfo{Value: &testCmd},
+	}
+	jobConfig := &job.JobConfig{
+		DefaultConfig: defaultConfig,
+		RespoolID:     suite.testRespoolID,
+	}
+
+	suite.setupMocks(suite.testJobID, suite.testRespoolID)
+	gomock.InOrder(
+		suite.mockedJobExternalRefOps.EXPECT().
+			Create(gomock.Any(), "workflow-run-1", suite.testJobID).
+			Return(nil),
+		suite.mockedCachedJob.EXPECT().
+			Create(gomock.Any(), jobConfig, gomock.Any(), "peloton").
+			Return(errors.New("test error")),
+		suite.mockedJobExternalRefOps.EXPECT().
+			Delete(gomock.Any(), "workflow-run-1").
+			Return(nil),
+	)
+
+	resp, err := suite.handler.Create(suite.context, &job.CreateRequest{
+		Id:            suite.testJobID,
+		Config:        jobConfig,
+		ExternalRefId: "workflow-run-1",
+	})
+	suite.NoError(err)
+	suite.NotNil(resp.GetError().GetAlreadyExists())
+	suite.Equal(suite.testJobID, resp.GetJobId())
+}
+
 // TestCreateJob_NilRespool tests job create with nil respool fail
 func (suite *JobHandlerTestSuite) TestCreateJob_NilRespool() {
 	testCmd := "echo test"
@@ -473,6 +622,116 @@ func (suite *JobHandlerTestSuite) TestCreateJob_RootRespoolFail() {
 	suite.Equal(expectedErr, resp.GetError())
 }
 
+// setupConfigVerifier sets up a verifier requiring the job configs to be
+// signed, and returns the key signing them.
+func (suite *JobHandlerTestSuite) setupConfigVerifier() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	suite.NoError(err)
+	buffer, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	suite.NoError(err)
+
+	suite.handler.configVerifier, err = provenance.NewVerifier(
+		&provenance.Config{
+			RequireSignature: true,
+			PublicKeys: map[string]string{
+				"ci": string(pem.EncodeToMemory(&pem.Block{
+					Type:  "PUBLIC KEY",
+					Bytes: buffer,
+				})),
+			},
+		})
+	suite.NoError(err)
+	return key
+}
+
+// TestCreateJob_SignedConfig tests creating a job with a signed config,
+// whose provenance is recorded in the system labels
+func (suite *JobHandlerTestSuite) TestCreateJob_SignedConfig() {
+	key := suite.setupConfigVerifier()
+	testCmd := "echo test"
+	jobConfig := &job.JobConfig{
+		DefaultConfig: &task.TaskConfig{
+			Command: &mesos.CommandInfo{Value: &testCmd},
+		},
+	}
+	digest, err := provenance.Digest(jobConfig)
+	suite.NoError(err)
+	signature, err := key.Sign(rand.Reader, digest, crypto.SHA256)
+	suite.NoError(err)
+	jobConfig.RespoolID = suite.testRespoolID
+
+	suite.setupMocks(suite.testJobID, suite.testRespoolID)
+	suite.mockedCachedJob.EXPECT().
+		Create(gomock.Any(), jobConfig, gomock.Any(), "peloton").
+		Do(func(
+			_ context.Context,
+			_ *job.JobConfig,
+			configAddOn *models.ConfigAddOn,
+			_ string) {
+			labels := make(map[string]string)
+			for _, label := range configAddOn.GetSystemLabels() {
+				labels[label.GetKey()] = label.GetValue()
+			}
+			suite.Equal("ci", labels[fmt.Sprintf(
+				common.SystemLabelKeyTemplate,
+				common.SystemLabelPrefix,
+				common.SystemLabelProvenanceKeyID)])
+		}).
+		Return(nil)
+
+	resp, err := suite.handler.Create(suite.context, &job.CreateRequest{
+		Id:     suite.testJobID,
+		Config: jobConfig,
+		Signature: &job.ConfigSignature{
+			KeyId:     "ci",
+			Signature: signature,
+		},
+	})
+	suite.NoError(err)
+	suite.Nil(resp.GetError())
+	suite.Equal(suite.testJobID, resp.GetJobId())
+}
+
+// TestCreateJob_UnsignedConfig tests job create fails with an unsigned
+// config when signatures are required
+func (suite *JobHandlerTestSuite) TestCreateJob_UnsignedConfig() {
+	suite.setupConfigVerifier()
+	testCmd := "echo test"
+	jobConfig := &job.JobConfig{
+		DefaultConfig: &task.TaskConfig{
+			Command: &mesos.CommandInfo{Value: &testCmd},
+		},
+		RespoolID: suite.testRespoolID,
+	}
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	resp, err := suite.handler.Create(suite.context, &job.CreateRequest{
+		Id:     suite.testJobID,
+		Config: jobConfig,
+	})
+	suite.NoError(err)
+	expectedErr := &job.CreateResponse_Error{
+		InvalidConfig: &job.InvalidJobConfig{
+			Id:      suite.testJobID,
+			Message: provenance.ErrUnsigned.Error(),
+		},
+	}
+	suite.Equal(expectedErr, resp.GetError())
+}
+
+// TestUpdateJob_UnsignedConfig tests job update fails with an unsigned
+// config when signatures are required
+func (suite *JobHandlerTestSuite) TestUpdateJob_UnsignedConfig() {
+	suite.setupConfigVerifier()
+	suite.setupMocks(suite.testJobID, suite.testRespoolID)
+
+	_, err := suite.handler.Update(suite.context, &job.UpdateRequest{
+		Id:     suite.testJobID,
+		Config: suite.testJobConfig,
+	})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
 // Test create job with one controller task as task 0
 func (suite *JobHandlerTestSuite) TestCreateJob_ControllerTaskSucceed() {
 	// setup job config
@@ -921,10 +1180,10 @@ func (suite *JobHandlerTestSuite) TestValidateResourcePool_Failure() {
 				gomock.Eq(request)).
 			Return(t.getRespoolResponse, t.getRespoolError).MaxTimes(1)
 
-		respoolPath, errResponse := suite.handler.validateResourcePool(respoolID)
+		respoolInfo, errResponse := suite.handler.validateResourcePool(respoolID)
 		suite.Error(errResponse)
 		suite.Equal(t.errMsg, errResponse.Error())
-		suite.Nil(respoolPath)
+		suite.Nil(respoolInfo)
 	}
 }
 
@@ -1640,10 +1899,194 @@ func getVolume() *mesos.Volume {
 // for store.QueryJobs()
 func (suite *JobHandlerTestSuite) TestJobQuery() {
 	// TODO: add more inputs
-	suite.mockedJobStore.EXPECT().QueryJobs(suite.context, nil, nil, false)
+	// the default page size and sort order are set
+	spec := &job.QuerySpec{
+		Pagination: &query.PaginationSpec{
+			Limit: 10,
+			OrderBy: []*query.OrderBy{
+				{
+					Order:    query.OrderBy_DESC,
+					Property: &query.PropertyPath{Value: "creation_time"},
+				},
+			},
+		},
+	}
+	suite.mockedJobStore.EXPECT().QueryJobs(suite.context, nil, spec, false)
 	resp, err := suite.handler.Query(suite.context, &job.QueryRequest{})
 	suite.NoError(err)
 	suite.NotNil(resp)
+	suite.Equal(uint32(10), resp.GetPagination().GetLimit())
+}
+
+// TestJobQueryExceedsLimits tests the queries of jobs exceeding the
+// limits of the pagination are rejected
+func (suite *JobHandlerTestSuite) TestJobQueryExceedsLimits() {
+	suite.handler.jobSvcCfg.Pagination.MaxJobQueryLimit = 100
+
+	_, err := suite.handler.Query(suite.context, &job.QueryRequest{
+		Spec: &job.QuerySpec{
+			Pagination: &query.PaginationSpec{Limit: 101},
+		},
+	})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+
+	_, err = suite.handler.Query(suite.context, &job.QueryRequest{
+		Spec: &job.QuerySpec{
+			Pagination: &query.PaginationSpec{MaxLimit: 1000000},
+		},
+	})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestJobQueryAllocatedResources tests the resources allocated to the
+// running instances are set in the job summaries when requested
+func (suite *JobHandlerTestSuite) TestJobQueryAllocatedResources() {
+	resource := &task.ResourceConfig{CpuLimit: 2, MemLimitMb: 100}
+	runtime := &job.RuntimeInfo{
+		TaskStats: map[string]uint32{task.TaskState_RUNNING.String(): 2},
+	}
+	jobInfos := []*job.JobInfo{
+		{
+			Id: &peloton.JobID{Value: "job-0"},
+			Config: &job.JobConfig{
+				DefaultConfig: &task.TaskConfig{Resource: resource},
+			},
+		},
+	}
+	summaries := []*job.JobSummary{
+		{Id: &peloton.JobID{Value: "job-0"}, Runtime: runtime},
+		{Id: &peloton.JobID{Value: "job-1"}, Runtime: runtime},
+		{Id: &peloton.JobID{Value: "job-2"}, Runtime: runtime},
+		{Id: &peloton.JobID{Value: "job-3"}, Runtime: runtime},
+	}
+
+	suite.mockedJobStore.EXPECT().
+		QueryJobs(suite.context, nil, gomock.Any(), false).
+		Return(jobInfos, summaries, uint32(4), nil)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(suite.context, "job-1").
+		Return(&job.JobConfig{
+			DefaultConfig: &task.TaskConfig{Resource: resource},
+		}, nil, nil)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(suite.context, "job-2").
+		Return(nil, nil, errors.New("DB error"))
+	// the resources of instance 1 of job-3 are overridden
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(suite.context, "job-3").
+		Return(&job.JobConfig{
+			DefaultConfig: &task.TaskConfig{Resource: resource},
+			InstanceConfig: map[uint32]*task.TaskConfig{
+				1: {Resource: &task.ResourceConfig{CpuLimit: 1, MemLimitMb: 10}},
+			},
+		}, nil, nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTaskRuntimesForJobByRange(
+			suite.context, &peloton.JobID{Value: "job-3"}, nil).
+		Return(map[uint32]*task.RuntimeInfo{
+			0: {State: task.TaskState_RUNNING},
+			1: {State: task.TaskState_RUNNING},
+			2: {State: task.TaskState_PENDING},
+		}, nil)
+
+	resp, err := suite.handler.Query(suite.context, &job.QueryRequest{
+		IncludeAllocatedResources: true,
+	})
+	suite.NoError(err)
+	suite.Nil(resp.GetError())
+	expected := &task.ResourceConfig{CpuLimit: 4, MemLimitMb: 200}
+	suite.Equal(expected, resp.GetResults()[0].GetAllocatedResources())
+	suite.Equal(expected, resp.GetResults()[1].GetAllocatedResources())
+	suite.Nil(resp.GetResults()[2].GetAllocatedResources())
+	suite.Equal(
+		&task.ResourceConfig{CpuLimit: 3, MemLimitMb: 110},
+		resp.GetResults()[3].GetAllocatedResources())
+}
+
+// TestGetRespoolJobsSnapshot tests the snapshot is retried if the
+// resource pools changed while reading the jobs
+func (suite *JobHandlerTestSuite) TestGetRespoolJobsSnapshot() {
+	respools := map[string]*respool.ResourcePoolConfig{
+		"pool-a": {
+			Name:   "a",
+			Parent: &peloton.ResourcePoolID{Value: "root"},
+		},
+	}
+	changedRespools := map[string]*respool.ResourcePoolConfig{
+		"pool-a": {
+			Name:   "a-renamed",
+			Parent: &peloton.ResourcePoolID{Value: "root"},
+		},
+	}
+	summaries := []*job.JobSummary{
+		{
+			Id:        &peloton.JobID{Value: "job-0"},
+			RespoolID: &peloton.ResourcePoolID{Value: "pool-a"},
+			Runtime:   &job.RuntimeInfo{State: job.JobState_RUNNING},
+		},
+	}
+
+	gomock.InOrder(
+		suite.mockedRespoolStore.EXPECT().
+			GetAllResourcePools(gomock.Any()).Return(respools, nil),
+		suite.mockedJobStore.EXPECT().
+			GetAllJobsInJobIndex(gomock.Any()).Return(summaries, nil),
+		suite.mockedRespoolStore.EXPECT().
+			GetAllResourcePools(gomock.Any()).Return(changedRespools, nil),
+		suite.mockedRespoolStore.EXPECT().
+			GetAllResourcePools(gomock.Any()).Return(changedRespools, nil),
+		suite.mockedJobStore.EXPECT().
+			GetAllJobsInJobIndex(gomock.Any()).Return(summaries, nil),
+		suite.mockedRespoolStore.EXPECT().
+			GetAllResourcePools(gomock.Any()).Return(changedRespools, nil),
+	)
+
+	resp, err := suite.handler.GetRespoolJobsSnapshot(
+		suite.context, &job.GetRespoolJobsSnapshotRequest{})
+	suite.NoError(err)
+	suite.NotEmpty(resp.GetSnapshotTime())
+	suite.Empty(resp.GetOrphanJobs())
+	suite.Len(resp.GetRoot().GetChildren(), 1)
+	pool := resp.GetRoot().GetChildren()[0]
+	suite.Equal("a-renamed", pool.GetConfig().GetName())
+	suite.Equal(summaries, pool.GetJobs())
+}
+
+// TestGetRespoolJobsSnapshotAborted tests the snapshot fails if the
+// resource pools keep changing while reading the jobs
+func (suite *JobHandlerTestSuite) TestGetRespoolJobsSnapshotAborted() {
+	for i := 0; i < _maxSnapshotAttempts; i++ {
+		suite.mockedRespoolStore.EXPECT().
+			GetAllResourcePools(gomock.Any()).
+			Return(map[string]*respool.ResourcePoolConfig{}, nil)
+		suite.mockedJobStore.EXPECT().
+			GetAllJobsInJobIndex(gomock.Any()).Return(nil, nil)
+		suite.mockedRespoolStore.EXPECT().
+			GetAllResourcePools(gomock.Any()).
+			Return(map[string]*respool.ResourcePoolConfig{
+				"pool-a": {Name: "a"},
+			}, nil)
+	}
+
+	_, err := suite.handler.GetRespoolJobsSnapshot(
+		suite.context, &job.GetRespoolJobsSnapshotRequest{})
+	suite.Error(err)
+	suite.True(yarpcerrors.IsAborted(err))
+}
+
+// TestGetRespoolJobsSnapshotDBError tests the snapshot fails if the
+// jobs cannot be read
+func (suite *JobHandlerTestSuite) TestGetRespoolJobsSnapshotDBError() {
+	suite.mockedRespoolStore.EXPECT().
+		GetAllResourcePools(gomock.Any()).
+		Return(map[string]*respool.ResourcePoolConfig{}, nil)
+	suite.mockedJobStore.EXPECT().
+		GetAllJobsInJobIndex(gomock.Any()).
+		Return(nil, errors.New("DB error"))
+
+	_, err := suite.handler.GetRespoolJobsSnapshot(
+		suite.context, &job.GetRespoolJobsSnapshotRequest{})
+	suite.Error(err)
 }
 
 // TestJobQuery tests failure case for Job Query API
@@ -1651,7 +2094,7 @@ func (suite *JobHandlerTestSuite) TestJobQuery() {
 // for store.QueryJobs()
 func (suite *JobHandlerTestSuite) TestJobQueryFailure() {
 	// TODO: add more inputs
-	suite.mockedJobStore.EXPECT().QueryJobs(suite.context, nil, nil, false).
+	suite.mockedJobStore.EXPECT().QueryJobs(suite.context, nil, gomock.Any(), false).
 		Return(nil, nil, uint32(0), errors.New("DB error"))
 	resp, err := suite.handler.Query(suite.context, &job.QueryRequest{})
 	suite.NoError(err)
@@ -2497,13 +2940,15 @@ func (suite *JobHandlerTestSuite) TestStopJobSuccess() {
 
 	suite.mockedJobFactory.EXPECT().
 		AddJob(suite.testJobID).
-		Return(suite.mockedCachedJob)
+		Return(suite.mockedCachedJob).
+		Times(2)
 
 	suite.mockedCachedJob.EXPECT().GetRuntime(gomock.Any()).
 		Return(&job.RuntimeInfo{
 			State:                job.JobState_PENDING,
 			ConfigurationVersion: configurationVersion,
-		}, nil)
+		}, nil).
+		Times(2)
 
 	suite.mockedJobStore.EXPECT().
 		GetJobConfigWithVersion(
@@ -2549,3 +2994,1016 @@ func (suite *JobHandlerTestSuite) TestStopJobSuccess() {
 	suite.Equal(resp.GetResourceVersion(),
 		newConfig.GetChangeLog().GetVersion())
 }
+
+// TestStopSuspendedJob tests that stopping all the instances of a suspended
+// job clears its suspension
+func (suite *JobHandlerTestSuite) TestStopSuspendedJob() {
+	var configurationVersion uint64 = 1
+	var workflowVersion uint64 = 2
+	var desiredStateVersion uint64 = 1
+	var batchSize uint32 = 1
+
+	suite.mockedCandidate.EXPECT().
+		IsLeader().
+		Return(true)
+
+	suite.testJobConfig.ChangeLog =
+		&peloton.ChangeLog{Version: configurationVersion}
+
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).
+		Return(suite.mockedCachedJob).
+		Times(2)
+
+	suite.mockedCachedJob.EXPECT().GetRuntime(gomock.Any()).
+		DoAndReturn(func(context.Context) (*job.RuntimeInfo, error) {
+			return &job.RuntimeInfo{
+				State:                job.JobState_PENDING,
+				ConfigurationVersion: configurationVersion,
+				Suspended:            true,
+			}, nil
+		}).
+		Times(2)
+
+	suite.mockedJobStore.EXPECT().
+		GetJobConfigWithVersion(
+			gomock.Any(),
+			suite.testJobID.GetValue(),
+			configurationVersion,
+		).
+		Return(suite.testJobConfig, &models.ConfigAddOn{}, nil)
+
+	newConfig := *suite.testJobConfig
+	newConfig.ChangeLog = &peloton.ChangeLog{Version: configurationVersion + 1}
+	suite.mockedCachedJob.EXPECT().
+		CreateWorkflow(
+			gomock.Any(),
+			models.WorkflowType_STOP,
+			gomock.Any(),
+			gomock.Any(),
+			gomock.Any(),
+		).
+		Return(
+			&peloton.UpdateID{Value: uuid.New()},
+			jobutil.GetJobEntityVersion(configurationVersion+1, desiredStateVersion, workflowVersion),
+			nil)
+
+	suite.mockedGoalStateDriver.EXPECT().
+		EnqueueUpdate(gomock.Any(), gomock.Any(), gomock.Any())
+
+	suite.mockedCachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(&newConfig, nil)
+
+	req := &job.StopRequest{
+		Id:              suite.testJobID,
+		ResourceVersion: configurationVersion,
+		StopConfig: &job.StopConfig{
+			BatchSize: batchSize,
+		},
+	}
+
+	suite.mockedCachedJob.EXPECT().
+		CompareAndSetRuntime(gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context, jobRuntime *job.RuntimeInfo) {
+			suite.False(jobRuntime.GetSuspended())
+		}).
+		Return(&job.RuntimeInfo{}, nil)
+
+	resp, err := suite.handler.Stop(context.Background(), req)
+	suite.NoError(err)
+	suite.NotNil(resp.GetUpdateID())
+}
+
+// TestUpdateStartAfter tests changing the start time of a deferred batch job
+func (suite *JobHandlerTestSuite) TestUpdateStartAfter() {
+	startAfter := time.Now().Add(2 * time.Hour).UTC().Format(time.RFC3339)
+	jobConfig := &job.JobConfig{
+		Type:       job.JobType_BATCH,
+		RespoolID:  suite.testRespoolID,
+		StartAfter: time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		ChangeLog:  &peloton.ChangeLog{Version: 1},
+	}
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).
+		Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(&job.RuntimeInfo{State: job.JobState_PENDING}, nil)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.testJobID.GetValue()).
+		Return(jobConfig, &models.ConfigAddOn{}, nil)
+	suite.mockedCachedJob.EXPECT().
+		CompareAndSetConfig(gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context,
+			config *job.JobConfig,
+			_ *models.ConfigAddOn) {
+			suite.Equal(startAfter, config.GetStartAfter())
+		}).
+		Return(&job.JobConfig{
+			ChangeLog: &peloton.ChangeLog{Version: 2},
+		}, nil)
+	suite.mockedCachedJob.EXPECT().
+		Update(gomock.Any(), &job.JobInfo{
+			Runtime: &job.RuntimeInfo{ConfigurationVersion: 2},
+		}, nil, cached.UpdateCacheAndDB).
+		Return(nil)
+	suite.mockedResmgrClient.EXPECT().
+		UpdateStartAfter(gomock.Any(), &resmgrsvc.UpdateStartAfterRequest{
+			JobId:      suite.testJobID,
+			RespoolId:  suite.testRespoolID,
+			StartAfter: startAfter,
+		}).
+		Return(&resmgrsvc.UpdateStartAfterResponse{NumGangs: 1}, nil)
+
+	resp, err := suite.handler.UpdateStartAfter(
+		context.Background(),
+		&job.UpdateStartAfterRequest{
+			Id:         suite.testJobID,
+			StartAfter: startAfter,
+		})
+	suite.NoError(err)
+	suite.Equal(uint64(2), resp.GetConfigVersion())
+	// the stored config is left as is
+	suite.NotEqual(startAfter, jobConfig.GetStartAfter())
+}
+
+// TestUpdateStartAfterJobStarted tests failing to change the start time
+// of a job which has already started
+func (suite *JobHandlerTestSuite) TestUpdateStartAfterJobStarted() {
+	startAfter := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	tt := []struct {
+		state      job.JobState
+		startAfter string
+	}{
+		{job.JobState_RUNNING, startAfter},
+		{job.JobState_PENDING, ""},
+		{
+			job.JobState_PENDING,
+			time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+		},
+	}
+
+	for _, t := range tt {
+		suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+		suite.mockedJobFactory.EXPECT().
+			AddJob(suite.testJobID).
+			Return(suite.mockedCachedJob)
+		suite.mockedCachedJob.EXPECT().
+			GetRuntime(gomock.Any()).
+			Return(&job.RuntimeInfo{State: t.state}, nil)
+		suite.mockedJobStore.EXPECT().
+			GetJobConfig(gomock.Any(), suite.testJobID.GetValue()).
+			Return(&job.JobConfig{
+				Type:       job.JobType_BATCH,
+				StartAfter: t.startAfter,
+			}, &models.ConfigAddOn{}, nil)
+
+		_, err := suite.handler.UpdateStartAfter(
+			context.Background(),
+			&job.UpdateStartAfterRequest{
+				Id:         suite.testJobID,
+				StartAfter: startAfter,
+			})
+		suite.True(yarpcerrors.IsInvalidArgument(err))
+	}
+}
+
+// TestUpdateStartAfterServiceJob tests failing to change the start time
+// of a service job
+func (suite *JobHandlerTestSuite) TestUpdateStartAfterServiceJob() {
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).
+		Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(&job.RuntimeInfo{State: job.JobState_PENDING}, nil)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.testJobID.GetValue()).
+		Return(&job.JobConfig{Type: job.JobType_SERVICE},
+			&models.ConfigAddOn{}, nil)
+
+	_, err := suite.handler.UpdateStartAfter(
+		context.Background(),
+		&job.UpdateStartAfterRequest{Id: suite.testJobID})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestUpdateStartAfterInvalidTime tests failing to change the start time
+// to an invalid time
+func (suite *JobHandlerTestSuite) TestUpdateStartAfterInvalidTime() {
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+
+	_, err := suite.handler.UpdateStartAfter(
+		context.Background(),
+		&job.UpdateStartAfterRequest{
+			Id:         suite.testJobID,
+			StartAfter: "tomorrow",
+		})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestUpdateStartAfterNonLeader tests failing to change the start time
+// on a non-leader
+func (suite *JobHandlerTestSuite) TestUpdateStartAfterNonLeader() {
+	suite.mockedCandidate.EXPECT().IsLeader().Return(false)
+
+	_, err := suite.handler.UpdateStartAfter(
+		context.Background(),
+		&job.UpdateStartAfterRequest{Id: suite.testJobID})
+	suite.True(yarpcerrors.IsUnavailable(err))
+}
+
+// TestSuspend tests suspending a job which stops its running tasks
+func (suite *JobHandlerTestSuite) TestSuspend() {
+	jobRuntime := &job.RuntimeInfo{
+		State:     job.JobState_RUNNING,
+		GoalState: job.JobState_SUCCEEDED,
+	}
+	taskInfos := map[uint32]*task.TaskInfo{
+		0: {
+			InstanceId: 0,
+			Runtime: &task.RuntimeInfo{
+				State:     task.TaskState_RUNNING,
+				GoalState: task.TaskState_SUCCEEDED,
+			},
+		},
+		1: {
+			InstanceId: 1,
+			Runtime: &task.RuntimeInfo{
+				State:     task.TaskState_SUCCEEDED,
+				GoalState: task.TaskState_SUCCEEDED,
+			},
+		},
+		2: {
+			InstanceId: 2,
+			Runtime: &task.RuntimeInfo{
+				State:     task.TaskState_RUNNING,
+				GoalState: task.TaskState_KILLED,
+			},
+		},
+	}
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).
+		Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(jobRuntime, nil).
+		Times(2)
+	suite.mockedCachedJob.EXPECT().
+		CompareAndSetRuntime(gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context, runtime *job.RuntimeInfo) {
+			suite.True(runtime.GetSuspended())
+			// the goal state of the job is kept
+			suite.Equal(job.JobState_SUCCEEDED, runtime.GetGoalState())
+		}).
+		Return(jobRuntime, nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTasksForJob(gomock.Any(), suite.testJobID).
+		Return(taskInfos, nil)
+	suite.mockedCachedJob.EXPECT().
+		PatchTasks(gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context,
+			runtimeDiffs map[uint32]jobmgrcommon.RuntimeDiff) {
+			suite.Len(runtimeDiffs, 1)
+			suite.Equal(task.TaskState_KILLED,
+				runtimeDiffs[0][jobmgrcommon.GoalStateField])
+			suite.Equal(true, runtimeDiffs[0][jobmgrcommon.SuspendedField])
+		}).
+		Return(nil)
+	suite.mockedGoalStateDriver.EXPECT().
+		EnqueueTask(suite.testJobID, uint32(0), gomock.Any())
+	suite.mockedCachedJob.EXPECT().
+		GetJobType().
+		Return(job.JobType_BATCH)
+	suite.mockedGoalStateDriver.EXPECT().
+		EnqueueJobWithDefaultDelay(suite.testJobID, job.JobType_BATCH)
+
+	resp, err := suite.handler.Suspend(
+		context.Background(),
+		&job.SuspendRequest{Id: suite.testJobID})
+	suite.NoError(err)
+	suite.Equal([]uint32{0}, resp.GetSuspendedInstanceIds())
+}
+
+// TestSuspendTerminalJob tests failing to suspend a terminated job
+func (suite *JobHandlerTestSuite) TestSuspendTerminalJob() {
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).
+		Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(&job.RuntimeInfo{State: job.JobState_SUCCEEDED}, nil)
+
+	_, err := suite.handler.Suspend(
+		context.Background(),
+		&job.SuspendRequest{Id: suite.testJobID})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestResume tests resuming a suspended job which starts the tasks
+// stopped by the suspend again
+func (suite *JobHandlerTestSuite) TestResume() {
+	jobRuntime := &job.RuntimeInfo{
+		State:     job.JobState_PENDING,
+		GoalState: job.JobState_SUCCEEDED,
+		Suspended: true,
+	}
+	mesosTaskID := fmt.Sprintf("%s-0-1", suite.testJobID.GetValue())
+	taskRuntime := &task.RuntimeInfo{
+		State:              task.TaskState_KILLED,
+		GoalState:          task.TaskState_KILLED,
+		MesosTaskId:        &mesos.TaskID{Value: &mesosTaskID},
+		DesiredMesosTaskId: &mesos.TaskID{Value: &mesosTaskID},
+		Suspended:          true,
+	}
+	taskInfos := map[uint32]*task.TaskInfo{
+		0: {
+			InstanceId: 0,
+			Config:     &task.TaskConfig{},
+			Runtime:    taskRuntime,
+		},
+		1: {
+			InstanceId: 1,
+			Config:     &task.TaskConfig{},
+			Runtime: &task.RuntimeInfo{
+				State:     task.TaskState_SUCCEEDED,
+				GoalState: task.TaskState_SUCCEEDED,
+			},
+		},
+	}
+	cachedConfig := cachedmocks.NewMockJobConfigCache(suite.ctrl)
+	cachedTask := cachedmocks.NewMockTask(suite.ctrl)
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).
+		Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(jobRuntime, nil).
+		Times(2)
+	suite.mockedCachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(cachedConfig, nil)
+	cachedConfig.EXPECT().
+		GetType().
+		Return(job.JobType_BATCH)
+	suite.mockedTaskStore.EXPECT().
+		GetTasksForJob(gomock.Any(), suite.testJobID).
+		Return(taskInfos, nil)
+	suite.mockedCachedJob.EXPECT().
+		AddTask(gomock.Any(), uint32(0)).
+		Return(cachedTask, nil)
+	suite.mockedCachedJob.EXPECT().
+		ID().
+		Return(suite.testJobID)
+	cachedTask.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(taskRuntime, nil)
+	cachedTask.EXPECT().
+		CompareAndSetRuntime(gomock.Any(), gomock.Any(), job.JobType_BATCH).
+		Do(func(_ context.Context,
+			runtime *task.RuntimeInfo,
+			_ job.JobType) {
+			suite.Equal(task.TaskState_INITIALIZED, runtime.GetState())
+			suite.Equal(task.TaskState_SUCCEEDED, runtime.GetGoalState())
+			suite.False(runtime.GetSuspended())
+		}).
+		Return(taskRuntime, nil)
+	suite.mockedGoalStateDriver.EXPECT().
+		EnqueueTask(suite.testJobID, uint32(0), gomock.Any())
+	suite.mockedCachedJob.EXPECT().
+		CompareAndSetRuntime(gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context, runtime *job.RuntimeInfo) {
+			suite.False(runtime.GetSuspended())
+		}).
+		Return(jobRuntime, nil)
+	suite.mockedCachedJob.EXPECT().
+		GetJobType().
+		Return(job.JobType_BATCH)
+	suite.mockedGoalStateDriver.EXPECT().
+		EnqueueJobWithDefaultDelay(suite.testJobID, job.JobType_BATCH)
+
+	resp, err := suite.handler.Resume(
+		context.Background(),
+		&job.ResumeRequest{Id: suite.testJobID})
+	suite.NoError(err)
+	suite.Equal([]uint32{0}, resp.GetResumedInstanceIds())
+}
+
+// TestResumeNotSuspended tests failing to resume a job which is not
+// suspended
+func (suite *JobHandlerTestSuite) TestResumeNotSuspended() {
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).
+		Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(&job.RuntimeInfo{State: job.JobState_RUNNING}, nil)
+
+	_, err := suite.handler.Resume(
+		context.Background(),
+		&job.ResumeRequest{Id: suite.testJobID})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestSuspendResumeNonLeader tests failing to suspend and resume a job on
+// a non-leader
+func (suite *JobHandlerTestSuite) TestSuspendResumeNonLeader() {
+	suite.mockedCandidate.EXPECT().IsLeader().Return(false).Times(2)
+
+	_, err := suite.handler.Suspend(
+		context.Background(),
+		&job.SuspendRequest{Id: suite.testJobID})
+	suite.True(yarpcerrors.IsUnavailable(err))
+
+	_, err = suite.handler.Resume(
+		context.Background(),
+		&job.ResumeRequest{Id: suite.testJobID})
+	suite.True(yarpcerrors.IsUnavailable(err))
+}
+
+// TestDeleteInstances tests removing instances from a batch job, and
+// renumbering the remaining instances above the new instance count
+func (suite *JobHandlerTestSuite) TestDeleteInstances() {
+	jobConfig := &job.JobConfig{
+		Type:          job.JobType_BATCH,
+		InstanceCount: 4,
+		InstanceConfig: map[uint32]*task.TaskConfig{
+			1: {Name: "instance-1"},
+			3: {Name: "instance-3"},
+		},
+		ChangeLog: &peloton.ChangeLog{Version: 1},
+	}
+	// instance 4 is left above the instance count by an interrupted call
+	taskInfos := make(map[uint32]*task.TaskInfo)
+	for i := uint32(0); i < 5; i++ {
+		mesosTaskID := util.CreateMesosTaskID(suite.testJobID, i, 2)
+		taskInfos[i] = &task.TaskInfo{
+			InstanceId: i,
+			Config:     &task.TaskConfig{Name: fmt.Sprintf("instance-%d", i)},
+			Runtime: &task.RuntimeInfo{
+				State:              task.TaskState_KILLED,
+				GoalState:          task.TaskState_KILLED,
+				ConfigVersion:      1,
+				MesosTaskId:        mesosTaskID,
+				DesiredMesosTaskId: mesosTaskID,
+			},
+		}
+	}
+	// instance 3 is renumbered as instance 1 with a new run ID
+	renumberedRuntime := &task.RuntimeInfo{
+		State:              task.TaskState_KILLED,
+		GoalState:          task.TaskState_KILLED,
+		ConfigVersion:      1,
+		MesosTaskId:        util.CreateMesosTaskID(suite.testJobID, 1, 3),
+		DesiredMesosTaskId: util.CreateMesosTaskID(suite.testJobID, 1, 3),
+		PrevMesosTaskId:    util.CreateMesosTaskID(suite.testJobID, 1, 2),
+	}
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.testJobID.GetValue()).
+		Return(jobConfig, &models.ConfigAddOn{}, nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTasksForJob(gomock.Any(), suite.testJobID).
+		Return(taskInfos, nil)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).
+		Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().RemoveTask(uint32(4))
+	suite.mockedTaskStore.EXPECT().
+		DeleteTaskRuntime(gomock.Any(), suite.testJobID, uint32(4)).
+		Return(nil)
+	suite.mockedTaskStore.EXPECT().
+		CreateTaskConfig(
+			gomock.Any(),
+			suite.testJobID,
+			int64(1),
+			taskInfos[3].GetConfig(),
+			gomock.Any(),
+			uint64(1)).
+		Return(nil)
+	suite.mockedTaskStore.EXPECT().
+		UpdateTaskRuntime(
+			gomock.Any(),
+			suite.testJobID,
+			uint32(1),
+			renumberedRuntime,
+			job.JobType_BATCH).
+		Return(nil)
+	suite.mockedCachedJob.EXPECT().RemoveTask(uint32(1))
+	suite.mockedCachedJob.EXPECT().
+		CompareAndSetConfig(gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context,
+			config *job.JobConfig,
+			_ *models.ConfigAddOn) {
+			suite.Equal(uint32(2), config.GetInstanceCount())
+			suite.Equal(map[uint32]*task.TaskConfig{
+				1: {Name: "instance-3"},
+			}, config.GetInstanceConfig())
+		}).
+		Return(&job.JobConfig{
+			ChangeLog: &peloton.ChangeLog{Version: 2},
+		}, nil)
+	suite.mockedCachedJob.EXPECT().
+		Update(gomock.Any(), &job.JobInfo{
+			Runtime: &job.RuntimeInfo{ConfigurationVersion: 2},
+		}, nil, cached.UpdateCacheAndDB).
+		Return(nil)
+	for _, instanceID := range []uint32{2, 3} {
+		suite.mockedCachedJob.EXPECT().RemoveTask(instanceID)
+		suite.mockedTaskStore.EXPECT().
+			DeleteTaskRuntime(gomock.Any(), suite.testJobID, instanceID).
+			Return(nil)
+	}
+	suite.mockedCachedJob.EXPECT().
+		GetJobType().
+		Return(job.JobType_BATCH)
+	suite.mockedGoalStateDriver.EXPECT().
+		EnqueueJobWithDefaultDelay(suite.testJobID, job.JobType_BATCH)
+
+	resp, err := suite.handler.DeleteInstances(
+		context.Background(),
+		&job.DeleteInstancesRequest{
+			Id:          suite.testJobID,
+			InstanceIds: []uint32{1, 2},
+		})
+	suite.NoError(err)
+	suite.Equal(uint64(2), resp.GetConfigVersion())
+	suite.Equal(map[uint32]uint32{3: 1}, resp.GetRenumberedInstanceIds())
+}
+
+// TestDeleteInstancesNotTerminated tests failing to remove instances from a
+// job if an instance to renumber is still running
+func (suite *JobHandlerTestSuite) TestDeleteInstancesNotTerminated() {
+	jobConfig := &job.JobConfig{
+		Type:          job.JobType_BATCH,
+		InstanceCount: 2,
+	}
+	taskInfos := map[uint32]*task.TaskInfo{
+		0: {
+			InstanceId: 0,
+			Runtime: &task.RuntimeInfo{
+				State:     task.TaskState_KILLED,
+				GoalState: task.TaskState_KILLED,
+			},
+		},
+		1: {
+			InstanceId: 1,
+			Runtime: &task.RuntimeInfo{
+				State:     task.TaskState_RUNNING,
+				GoalState: task.TaskState_SUCCEEDED,
+			},
+		},
+	}
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.testJobID.GetValue()).
+		Return(jobConfig, &models.ConfigAddOn{}, nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTasksForJob(gomock.Any(), suite.testJobID).
+		Return(taskInfos, nil)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).
+		Return(suite.mockedCachedJob)
+
+	_, err := suite.handler.DeleteInstances(
+		context.Background(),
+		&job.DeleteInstancesRequest{
+			Id:          suite.testJobID,
+			InstanceIds: []uint32{0},
+		})
+	suite.True(yarpcerrors.IsFailedPrecondition(err))
+}
+
+// TestDeleteInstancesInvalidArgument tests failing to remove instances with
+// an invalid request
+func (suite *JobHandlerTestSuite) TestDeleteInstancesInvalidArgument() {
+	tt := []struct {
+		name        string
+		jobType     job.JobType
+		instanceIDs []uint32
+	}{
+		{
+			name:        "stateless job",
+			jobType:     job.JobType_SERVICE,
+			instanceIDs: []uint32{0},
+		},
+		{
+			name:        "instance out of range",
+			jobType:     job.JobType_BATCH,
+			instanceIDs: []uint32{2},
+		},
+		{
+			name:        "all instances",
+			jobType:     job.JobType_BATCH,
+			instanceIDs: []uint32{0, 1},
+		},
+	}
+
+	for _, test := range tt {
+		suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+		suite.mockedJobStore.EXPECT().
+			GetJobConfig(gomock.Any(), suite.testJobID.GetValue()).
+			Return(&job.JobConfig{
+				Type:          test.jobType,
+				InstanceCount: 2,
+			}, &models.ConfigAddOn{}, nil)
+		if test.jobType == job.JobType_BATCH {
+			suite.mockedTaskStore.EXPECT().
+				GetTasksForJob(gomock.Any(), suite.testJobID).
+				Return(map[uint32]*task.TaskInfo{}, nil)
+			suite.mockedJobFactory.EXPECT().
+				AddJob(suite.testJobID).
+				Return(suite.mockedCachedJob)
+		}
+
+		_, err := suite.handler.DeleteInstances(
+			context.Background(),
+			&job.DeleteInstancesRequest{
+				Id:          suite.testJobID,
+				InstanceIds: test.instanceIDs,
+			})
+		suite.True(yarpcerrors.IsInvalidArgument(err), test.name)
+	}
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	_, err := suite.handler.DeleteInstances(
+		context.Background(),
+		&job.DeleteInstancesRequest{Id: suite.testJobID})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestGetJobIDFromExternalRefID tests looking up a job by its external
+// reference ID
+func (suite *JobHandlerTestSuite) TestGetJobIDFromExternalRefID() {
+	suite.mockedJobExternalRefOps.EXPECT().
+		Get(gomock.Any(), "workflow-run-1").
+		Return(suite.testJobID, nil)
+
+	resp, err := suite.handler.GetJobIDFromExternalRefID(
+		suite.context,
+		&job.GetJobIDFromExternalRefIDRequest{ExternalRefId: "workflow-run-1"})
+	suite.NoError(err)
+	suite.Equal(suite.testJobID, resp.GetJobId())
+}
+
+// TestGetJobIDFromExternalRefIDFailure tests the failure cases of looking
+// up a job by its external reference ID
+func (suite *JobHandlerTestSuite) TestGetJobIDFromExternalRefIDFailure() {
+	_, err := suite.handler.GetJobIDFromExternalRefID(
+		suite.context,
+		&job.GetJobIDFromExternalRefIDRequest{})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+
+	suite.mockedJobExternalRefOps.EXPECT().
+		Get(gomock.Any(), "workflow-run-1").
+		Return(nil, gocql.ErrNotFound)
+	_, err = suite.handler.GetJobIDFromExternalRefID(
+		suite.context,
+		&job.GetJobIDFromExternalRefIDRequest{ExternalRefId: "workflow-run-1"})
+	suite.True(yarpcerrors.IsNotFound(err))
+
+	suite.mockedJobExternalRefOps.EXPECT().
+		Get(gomock.Any(), "workflow-run-1").
+		Return(nil, errors.New("test error"))
+	_, err = suite.handler.GetJobIDFromExternalRefID(
+		suite.context,
+		&job.GetJobIDFromExternalRefIDRequest{ExternalRefId: "workflow-run-1"})
+	suite.Error(err)
+	suite.False(yarpcerrors.IsNotFound(err))
+}
+
+// TestGetRunStatistics tests getting the run duration statistics of a job
+func (suite *JobHandlerTestSuite) TestGetRunStatistics() {
+	suite.mockedRunDurationOps.EXPECT().
+		GetAll(gomock.Any(), suite.testJobID).
+		Return([]*ormobjects.RunDurationObject{
+			{InstanceID: 0, RunID: 1, StartLatencyMs: 3000, RunDurationMs: 60000},
+			{InstanceID: 1, RunID: 1, StartLatencyMs: 1000, RunDurationMs: 20000},
+		}, nil)
+
+	resp, err := suite.handler.GetRunStatistics(
+		suite.context,
+		&job.GetRunStatisticsRequest{Id: suite.testJobID})
+	suite.NoError(err)
+	suite.Equal(uint32(2), resp.GetStatistics().GetNumRuns())
+	suite.Equal(uint64(1000), resp.GetStatistics().GetStartLatency().GetP50Ms())
+	suite.Equal(uint64(3000), resp.GetStatistics().GetStartLatency().GetP95Ms())
+	suite.Equal(uint64(60000), resp.GetStatistics().GetRunDuration().GetMaxMs())
+}
+
+// TestGetRunStatisticsFailure tests the failure cases of getting the run
+// duration statistics of a job
+func (suite *JobHandlerTestSuite) TestGetRunStatisticsFailure() {
+	_, err := suite.handler.GetRunStatistics(
+		suite.context,
+		&job.GetRunStatisticsRequest{})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+
+	suite.mockedRunDurationOps.EXPECT().
+		GetAll(gomock.Any(), suite.testJobID).
+		Return(nil, errors.New("test error"))
+	_, err = suite.handler.GetRunStatistics(
+		suite.context,
+		&job.GetRunStatisticsRequest{Id: suite.testJobID})
+	suite.Error(err)
+}
+
+// TestLintJobConfig tests linting a job config against a rule set
+func (suite *JobHandlerTestSuite) TestLintJobConfig() {
+	var err error
+	suite.handler.configLinter, err = lint.NewLinter(&lint.Config{
+		RuleSets: map[string][]string{
+			"resources": {lint.RuleDisk},
+		},
+	})
+	suite.NoError(err)
+
+	cmd := "echo hello"
+	config := &job.JobConfig{
+		Name:          "test-job",
+		Type:          job.JobType_BATCH,
+		InstanceCount: 1,
+		DefaultConfig: &task.TaskConfig{
+			Command: &mesos.CommandInfo{Value: &cmd},
+			Resource: &task.ResourceConfig{
+				CpuLimit:   1,
+				MemLimitMb: 128,
+			},
+		},
+	}
+
+	resp, err := suite.handler.LintJobConfig(
+		suite.context,
+		&job.LintJobConfigRequest{
+			Config:  config,
+			RuleSet: "resources",
+		})
+	suite.NoError(err)
+	suite.Len(resp.GetWarnings(), 1)
+	suite.Equal(lint.RuleDisk, resp.GetWarnings()[0].GetRule())
+	suite.Empty(resp.GetValidationError())
+
+	// the warnings are returned along with the validation error
+	config.DefaultConfig.Command = nil
+	resp, err = suite.handler.LintJobConfig(
+		suite.context,
+		&job.LintJobConfigRequest{Config: config})
+	suite.NoError(err)
+	suite.Len(resp.GetWarnings(), 2)
+	suite.NotEmpty(resp.GetValidationError())
+}
+
+// TestLintJobConfigFailure tests the failure cases of linting
+// a job config
+func (suite *JobHandlerTestSuite) TestLintJobConfigFailure() {
+	config := &job.JobConfig{Name: "test-job"}
+
+	_, err := suite.handler.LintJobConfig(
+		suite.context,
+		&job.LintJobConfigRequest{Config: config})
+	suite.True(yarpcerrors.IsUnimplemented(err))
+
+	suite.handler.configLinter, err = lint.NewLinter(&lint.Config{})
+	suite.NoError(err)
+
+	_, err = suite.handler.LintJobConfig(
+		suite.context,
+		&job.LintJobConfigRequest{})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+
+	_, err = suite.handler.LintJobConfig(
+		suite.context,
+		&job.LintJobConfigRequest{
+			Config:  config,
+			RuleSet: "unknown",
+		})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestCheckJobConfigs tests checking the stored configs of the
+// non-terminal jobs against the current API
+func (suite *JobHandlerTestSuite) TestCheckJobConfigs() {
+	cmd := "echo hello"
+	compatibleConfig := &job.JobConfig{
+		Name:          "test-job",
+		Type:          job.JobType_BATCH,
+		InstanceCount: 1,
+		ChangeLog:     &peloton.ChangeLog{Version: 2},
+		DefaultConfig: &task.TaskConfig{
+			Command: &mesos.CommandInfo{Value: &cmd},
+		},
+	}
+	incompatibleConfig := &job.JobConfig{
+		Name:          "test-job",
+		Type:          job.JobType_BATCH,
+		InstanceCount: 1,
+		ChangeLog:     &peloton.ChangeLog{Version: 3},
+	}
+	summaries := []*job.JobSummary{
+		{
+			Id:      &peloton.JobID{Value: "job-0"},
+			Runtime: &job.RuntimeInfo{State: job.JobState_RUNNING},
+		},
+		{
+			Id:      &peloton.JobID{Value: "job-1"},
+			Runtime: &job.RuntimeInfo{State: job.JobState_PENDING},
+		},
+		{
+			Id:      &peloton.JobID{Value: "job-2"},
+			Runtime: &job.RuntimeInfo{State: job.JobState_SUCCEEDED},
+		},
+		{
+			Id:      &peloton.JobID{Value: "job-3"},
+			Runtime: &job.RuntimeInfo{State: job.JobState_RUNNING},
+		},
+	}
+
+	suite.mockedJobStore.EXPECT().
+		GetAllJobsInJobIndex(gomock.Any()).Return(summaries, nil)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), "job-0").
+		Return(compatibleConfig, &models.ConfigAddOn{}, nil)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), "job-1").
+		Return(incompatibleConfig, &models.ConfigAddOn{}, nil)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), "job-3").
+		Return(nil, nil, errors.New("DB error"))
+	// the resources of instance 1 of job-3 are overridden
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(suite.context, "job-3").
+		Return(&job.JobConfig{
+			DefaultConfig: &task.TaskConfig{Resource: resource},
+			InstanceConfig: map[uint32]*task.TaskConfig{
+				1: {Resource: &task.ResourceConfig{CpuLimit: 1, MemLimitMb: 10}},
+			},
+		}, nil, nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTaskRuntimesForJobByRange(
+			suite.context, &peloton.JobID{Value: "job-3"}, nil).
+		Return(map[uint32]*task.RuntimeInfo{
+			0: {State: task.TaskState_RUNNING},
+			1: {State: task.TaskState_RUNNING},
+			2: {State: task.TaskState_PENDING},
+		}, nil)
+
+	resp, err := suite.handler.CheckJobConfigs(
+		suite.context, &job.CheckJobConfigsRequest{})
+	suite.NoError(err)
+	suite.Equal(uint32(2), resp.GetCheckedJobs())
+	suite.Len(resp.GetJobs(), 2)
+
+	suite.Equal("job-1", resp.GetJobs()[0].GetId().GetValue())
+	suite.Equal(uint64(3), resp.GetJobs()[0].GetConfigVersion())
+	suite.Len(resp.GetJobs()[0].GetIssues(), 1)
+	suite.Equal(job.CompatibilityIssue_KIND_REJECTED,
+		resp.GetJobs()[0].GetIssues()[0].GetKind())
+
+	suite.Equal("job-3", resp.GetJobs()[1].GetId().GetValue())
+	suite.NotEmpty(resp.GetJobs()[1].GetError())
+}
+
+// TestCheckJobConfigsPages tests checking the stored configs of the jobs
+// a page at a time, in the order of their IDs
+func (suite *JobHandlerTestSuite) TestCheckJobConfigsPages() {
+	cmd := "echo hello"
+	config := &job.JobConfig{
+		Name:          "test-job",
+		Type:          job.JobType_BATCH,
+		InstanceCount: 1,
+		DefaultConfig: &task.TaskConfig{
+			Command: &mesos.CommandInfo{Value: &cmd},
+		},
+	}
+	var summaries []*job.JobSummary
+	for _, id := range []string{"job-2", "job-0", "job-3", "job-1"} {
+		summaries = append(summaries, &job.JobSummary{
+			Id:      &peloton.JobID{Value: id},
+			Runtime: &job.RuntimeInfo{State: job.JobState_RUNNING},
+		})
+	}
+	suite.mockedJobStore.EXPECT().
+		GetAllJobsInJobIndex(gomock.Any()).
+		Return(summaries, nil).
+		Times(3)
+
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), "job-0").
+		Return(config, &models.ConfigAddOn{}, nil)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), "job-1").
+		Return(config, &models.ConfigAddOn{}, nil)
+	resp, err := suite.handler.CheckJobConfigs(
+		suite.context, &job.CheckJobConfigsRequest{Limit: 2})
+	suite.NoError(err)
+	suite.Equal(uint32(2), resp.GetCheckedJobs())
+	suite.Equal(uint32(2), resp.GetNextOffset())
+
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), "job-2").
+		Return(config, &models.ConfigAddOn{}, nil)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), "job-3").
+		Return(nil, nil, errors.New("DB error"))
+	resp, err = suite.handler.CheckJobConfigs(
+		suite.context, &job.CheckJobConfigsRequest{Offset: 2, Limit: 2})
+	suite.NoError(err)
+	suite.Equal(uint32(1), resp.GetCheckedJobs())
+	suite.Zero(resp.GetNextOffset())
+	suite.Len(resp.GetJobs(), 1)
+	suite.Equal("job-3", resp.GetJobs()[0].GetId().GetValue())
+
+	resp, err = suite.handler.CheckJobConfigs(
+		suite.context, &job.CheckJobConfigsRequest{Offset: 4})
+	suite.NoError(err)
+	suite.Zero(resp.GetCheckedJobs())
+	suite.Zero(resp.GetNextOffset())
+
+	_, err = suite.handler.CheckJobConfigs(
+		suite.context, &job.CheckJobConfigsRequest{
+			Limit: _defaultMaxCheckJobConfigsLimit + 1,
+		})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestCheckJobConfigsDBError tests the check fails if the jobs
+// cannot be read
+func (suite *JobHandlerTestSuite) TestCheckJobConfigsDBError() {
+	suite.mockedJobStore.EXPECT().
+		GetAllJobsInJobIndex(gomock.Any()).
+		Return(nil, errors.New("DB error"))
+
+	_, err := suite.handler.CheckJobConfigs(
+		suite.context, &job.CheckJobConfigsRequest{})
+	suite.Error(err)
+}
+
+// fakeStateExporter records the period of the changes it exports
+type fakeStateExporter struct {
+	from time.Time
+	to   time.Time
+	resp *job.ExportStateDiffResponse
+	err  error
+}
+
+func (e *fakeStateExporter) ExportDiff(
+	ctx context.Context,
+	from time.Time,
+	to time.Time,
+) (*job.ExportStateDiffResponse, error) {
+	e.from, e.to = from, to
+	return e.resp, e.err
+}
+
+// TestExportStateDiff tests exporting the changes made to the cluster
+func (suite *JobHandlerTestSuite) TestExportStateDiff() {
+	_, err := suite.handler.ExportStateDiff(
+		suite.context, &job.ExportStateDiffRequest{})
+	suite.True(yarpcerrors.IsUnimplemented(err))
+
+	exporter := &fakeStateExporter{
+		resp: &job.ExportStateDiffResponse{
+			Changes: []*job.StateChange{{
+				Kind: job.StateChange_KIND_JOB,
+				Id:   "job1",
+				Type: job.StateChange_TYPE_ADDED,
+			}},
+		},
+	}
+	suite.handler.stateExporter = exporter
+	defer func() { suite.handler.stateExporter = nil }()
+
+	_, err = suite.handler.ExportStateDiff(
+		suite.context, &job.ExportStateDiffRequest{FromTime: "yesterday"})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+
+	resp, err := suite.handler.ExportStateDiff(
+		suite.context, &job.ExportStateDiffRequest{
+			FromTime: "2019-01-01T00:00:00Z",
+		})
+	suite.NoError(err)
+	suite.Equal(exporter.resp, resp)
+	suite.Equal(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), exporter.from)
+	suite.True(exporter.to.IsZero())
+
+	exporter.err = yarpcerrors.NotFoundErrorf("no snapshot")
+	_, err = suite.handler.ExportStateDiff(
+		suite.context, &job.ExportStateDiffRequest{
+			FromTime: "2019-01-01T00:00:00Z",
+			ToTime:   "2019-01-02T00:00:00Z",
+		})
+	suite.True(yarpcerrors.IsNotFound(err))
+	suite.Equal(time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC), exporter.to)
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobsvc
+
+import (
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// RandomJobIDGenerator generates a random UUID as the ID of a job
+	RandomJobIDGenerator = "random"
+	// ExternalRefJobIDGenerator derives the ID of a job from its external
+	// reference ID, so that creating a job again with the same external
+	// reference ID results in the same job ID
+	ExternalRefJobIDGenerator = "external_ref"
+)
+
+// _externalRefNamespace is the namespace of the name based UUIDs
+// generated from external reference IDs
+var _externalRefNamespace = uuid.Parse("5c3b0a4e-6f0e-4d8a-9a39-2f4ad5e4b1c7")
+
+// JobIDGenerator generates the ID of a job created without an ID.
+type JobIDGenerator interface {
+	// NewJobID returns the ID of the job to be created by the request.
+	NewJobID(req *job.CreateRequest) *peloton.JobID
+}
+
+// NewJobIDGenerator returns the JobIDGenerator with the given name.
+// The random generator is returned if the name is empty.
+func NewJobIDGenerator(name string) (JobIDGenerator, error) {
+	switch name {
+	case "", RandomJobIDGenerator:
+		return randomJobIDGenerator{}, nil
+	case ExternalRefJobIDGenerator:
+		return externalRefJobIDGenerator{}, nil
+	}
+	return nil, errors.Errorf("unknown job id generator %q", name)
+}
+
+// randomJobIDGenerator generates random UUIDs.
+type randomJobIDGenerator struct{}
+
+func (randomJobIDGenerator) NewJobID(req *job.CreateRequest) *peloton.JobID {
+	return &peloton.JobID{Value: uuid.New()}
+}
+
+// externalRefJobIDGenerator generates name based UUIDs from the external
+// reference IDs, and random UUIDs for jobs without an external reference ID.
+type externalRefJobIDGenerator struct{}
+
+func (externalRefJobIDGenerator) NewJobID(req *job.CreateRequest) *peloton.JobID {
+	if len(req.GetExternalRefId()) == 0 {
+		return &peloton.JobID{Value: uuid.New()}
+	}
+	return &peloton.JobID{
+		Value: uuid.NewSHA1(
+			_externalRefNamespace, []byte(req.GetExternalRefId())).String(),
+	}
+}
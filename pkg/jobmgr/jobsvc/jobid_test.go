@@ -0,0 +1,56 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobsvc
+
+import (
+	"testing"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandomJobIDGenerator(t *testing.T) {
+	g, err := NewJobIDGenerator("")
+	assert.NoError(t, err)
+
+	req := &job.CreateRequest{ExternalRefId: "run-1"}
+	id1 := g.NewJobID(req)
+	id2 := g.NewJobID(req)
+	assert.NotNil(t, uuid.Parse(id1.GetValue()))
+	assert.NotEqual(t, id1.GetValue(), id2.GetValue())
+}
+
+func TestExternalRefJobIDGenerator(t *testing.T) {
+	g, err := NewJobIDGenerator(ExternalRefJobIDGenerator)
+	assert.NoError(t, err)
+
+	id1 := g.NewJobID(&job.CreateRequest{ExternalRefId: "run-1"})
+	id2 := g.NewJobID(&job.CreateRequest{ExternalRefId: "run-1"})
+	id3 := g.NewJobID(&job.CreateRequest{ExternalRefId: "run-2"})
+	assert.NotNil(t, uuid.Parse(id1.GetValue()))
+	assert.Equal(t, id1.GetValue(), id2.GetValue())
+	assert.NotEqual(t, id1.GetValue(), id3.GetValue())
+
+	// a random id is generated without an external reference id
+	id4 := g.NewJobID(&job.CreateRequest{})
+	assert.NotNil(t, uuid.Parse(id4.GetValue()))
+}
+
+func TestUnknownJobIDGenerator(t *testing.T) {
+	_, err := NewJobIDGenerator("sequential")
+	assert.Error(t, err)
+}
@@ -53,6 +53,45 @@ type Metrics struct {
 	JobGetByRespoolID     tally.Counter
 	JobGetByRespoolIDFail tally.Counter
 
+	JobAPIUpdateStartAfter  tally.Counter
+	JobUpdateStartAfter     tally.Counter
+	JobUpdateStartAfterFail tally.Counter
+
+	JobAPISuspend  tally.Counter
+	JobSuspend     tally.Counter
+	JobSuspendFail tally.Counter
+	JobAPIResume   tally.Counter
+	JobResume      tally.Counter
+	JobResumeFail  tally.Counter
+
+	JobAPIDeleteInstances  tally.Counter
+	JobDeleteInstances     tally.Counter
+	JobDeleteInstancesFail tally.Counter
+
+	JobAPIGetJobIDFromExternalRefID  tally.Counter
+	JobGetJobIDFromExternalRefID     tally.Counter
+	JobGetJobIDFromExternalRefIDFail tally.Counter
+
+	JobAPIGetRunStatistics  tally.Counter
+	JobGetRunStatistics     tally.Counter
+	JobGetRunStatisticsFail tally.Counter
+
+	JobAPIGetRespoolJobsSnapshot  tally.Counter
+	JobGetRespoolJobsSnapshot     tally.Counter
+	JobGetRespoolJobsSnapshotFail tally.Counter
+
+	JobAPILintJobConfig  tally.Counter
+	JobLintJobConfig     tally.Counter
+	JobLintJobConfigFail tally.Counter
+
+	JobAPICheckJobConfigs  tally.Counter
+	JobCheckJobConfigs     tally.Counter
+	JobCheckJobConfigsFail tally.Counter
+
+	JobAPIExportStateDiff  tally.Counter
+	JobExportStateDiff     tally.Counter
+	JobExportStateDiffFail tally.Counter
+
 	// Timers
 	JobQueryHandlerDuration tally.Timer
 
@@ -110,5 +149,44 @@ func NewMetrics(scope tally.Scope) *Metrics {
 		JobAPIGetByRespoolID:  jobAPIScope.Counter("get_by_respool_id"),
 		JobGetByRespoolID:     jobSuccessScope.Counter("get_by_respool_id"),
 		JobGetByRespoolIDFail: jobFailScope.Counter("get_by_respool_id"),
+
+		JobAPIUpdateStartAfter:  jobAPIScope.Counter("update_start_after"),
+		JobUpdateStartAfter:     jobSuccessScope.Counter("update_start_after"),
+		JobUpdateStartAfterFail: jobFailScope.Counter("update_start_after"),
+
+		JobAPISuspend:  jobAPIScope.Counter("suspend"),
+		JobSuspend:     jobSuccessScope.Counter("suspend"),
+		JobSuspendFail: jobFailScope.Counter("suspend"),
+		JobAPIResume:   jobAPIScope.Counter("resume"),
+		JobResume:      jobSuccessScope.Counter("resume"),
+		JobResumeFail:  jobFailScope.Counter("resume"),
+
+		JobAPIDeleteInstances:  jobAPIScope.Counter("delete_instances"),
+		JobDeleteInstances:     jobSuccessScope.Counter("delete_instances"),
+		JobDeleteInstancesFail: jobFailScope.Counter("delete_instances"),
+
+		JobAPIGetJobIDFromExternalRefID:  jobAPIScope.Counter("get_job_id_from_external_ref_id"),
+		JobGetJobIDFromExternalRefID:     jobSuccessScope.Counter("get_job_id_from_external_ref_id"),
+		JobGetJobIDFromExternalRefIDFail: jobFailScope.Counter("get_job_id_from_external_ref_id"),
+
+		JobAPIGetRunStatistics:  jobAPIScope.Counter("get_run_statistics"),
+		JobGetRunStatistics:     jobSuccessScope.Counter("get_run_statistics"),
+		JobGetRunStatisticsFail: jobFailScope.Counter("get_run_statistics"),
+
+		JobAPIGetRespoolJobsSnapshot:  jobAPIScope.Counter("get_respool_jobs_snapshot"),
+		JobGetRespoolJobsSnapshot:     jobSuccessScope.Counter("get_respool_jobs_snapshot"),
+		JobGetRespoolJobsSnapshotFail: jobFailScope.Counter("get_respool_jobs_snapshot"),
+
+		JobAPILintJobConfig:  jobAPIScope.Counter("lint_job_config"),
+		JobLintJobConfig:     jobSuccessScope.Counter("lint_job_config"),
+		JobLintJobConfigFail: jobFailScope.Counter("lint_job_config"),
+
+		JobAPICheckJobConfigs:  jobAPIScope.Counter("check_job_configs"),
+		JobCheckJobConfigs:     jobSuccessScope.Counter("check_job_configs"),
+		JobCheckJobConfigsFail: jobFailScope.Counter("check_job_configs"),
+
+		JobAPIExportStateDiff:  jobAPIScope.Counter("export_state_diff"),
+		JobExportStateDiff:     jobSuccessScope.Counter("export_state_diff"),
+		JobExportStateDiffFail: jobFailScope.Counter("export_state_diff"),
 	}
 }
@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"sort"
 	"time"
 
 	mesos "github.com/uber/peloton/.gen/mesos/v1"
@@ -36,11 +37,13 @@ import (
 
 	"github.com/uber/peloton/pkg/common"
 	"github.com/uber/peloton/pkg/common/leader"
+	"github.com/uber/peloton/pkg/common/rpc"
 	"github.com/uber/peloton/pkg/common/util"
 	"github.com/uber/peloton/pkg/jobmgr/cached"
 	jobmgrcommon "github.com/uber/peloton/pkg/jobmgr/common"
 	"github.com/uber/peloton/pkg/jobmgr/goalstate"
 	jobconfig "github.com/uber/peloton/pkg/jobmgr/job/config"
+	"github.com/uber/peloton/pkg/jobmgr/job/provenance"
 	"github.com/uber/peloton/pkg/jobmgr/jobsvc"
 	jobmgrtask "github.com/uber/peloton/pkg/jobmgr/task"
 	"github.com/uber/peloton/pkg/jobmgr/task/activermtask"
@@ -71,6 +74,10 @@ type serviceHandler struct {
 	rootCtx         context.Context
 	jobSvcCfg       jobsvc.Config
 	activeRMTasks   activermtask.ActiveRMTasks
+	configVerifier  provenance.Verifier
+	// identifies the callers by their client certificates, nil if TLS
+	// is disabled
+	tlsProvider *rpc.TLSProvider
 }
 
 var (
@@ -97,6 +104,8 @@ func InitV1AlphaJobServiceHandler(
 	candidate leader.Candidate,
 	jobSvcCfg jobsvc.Config,
 	activeRMTasks activermtask.ActiveRMTasks,
+	configVerifier provenance.Verifier,
+	tlsProvider *rpc.TLSProvider,
 ) {
 	handler := &serviceHandler{
 		jobStore:       jobStore,
@@ -113,6 +122,8 @@ func InitV1AlphaJobServiceHandler(
 		candidate:       candidate,
 		jobSvcCfg:       jobSvcCfg,
 		activeRMTasks:   activeRMTasks,
+		configVerifier:  configVerifier,
+		tlsProvider:     tlsProvider,
 	}
 	d.Register(svc.BuildJobServiceYARPCProcedures(handler))
 }
@@ -161,7 +172,13 @@ func (h *serviceHandler) CreateJob(
 
 	jobSpec := req.GetSpec()
 
-	respoolPath, err := h.validateResourcePoolForJobCreation(ctx, jobSpec.GetRespoolId())
+	// Verify the signature before the spec is modified by Peloton
+	provenanceLabels, err := h.verifySpecSignature(jobSpec, req.GetSignature())
+	if err != nil {
+		return nil, err
+	}
+
+	respoolInfo, err := h.validateResourcePoolForJobCreation(ctx, jobSpec.GetRespoolId())
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to validate resource pool")
 	}
@@ -180,11 +197,26 @@ func (h *serviceHandler) CreateJob(
 		return nil, errors.Wrap(err, "invalid job spec")
 	}
 
+	err = jobconfig.ValidateTaskBackoffPolicy(
+		jobConfig.GetTaskBackoffPolicy(),
+		h.jobSvcCfg.TaskBackoffBounds,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid job spec")
+	}
+
 	// check secrets and config for input sanity
 	if err = h.validateSecretsAndConfig(jobSpec, req.GetSecrets()); err != nil {
 		return nil, errors.Wrap(err, "input cannot contain secret volume")
 	}
 
+	if err = jobconfig.ValidateTaskSizeOverride(
+		jobconfig.AddedTaskLabels(jobConfig, nil),
+		respoolInfo.GetConfig().GetTaskResourceLimit(),
+		h.tlsProvider.PeerID(ctx)); err != nil {
+		return nil, err
+	}
+
 	// create secrets in the DB and add them as secret volumes to defaultconfig
 	err = h.handleCreateSecrets(ctx, pelotonJobID.GetValue(), jobSpec, req.GetSecrets())
 	if err != nil {
@@ -194,7 +226,9 @@ func (h *serviceHandler) CreateJob(
 	// Create job in cache and db
 	cachedJob := h.jobFactory.AddJob(pelotonJobID)
 
-	systemLabels := jobutil.ConstructSystemLabels(jobConfig, respoolPath.GetValue())
+	systemLabels := append(
+		jobutil.ConstructSystemLabels(jobConfig, respoolInfo.GetPath().GetValue()),
+		provenanceLabels...)
 	configAddOn := &models.ConfigAddOn{
 		SystemLabels: systemLabels,
 	}
@@ -275,6 +309,12 @@ func (h *serviceHandler) ReplaceJob(
 			"JobID must be of UUID format")
 	}
 
+	provenanceLabels, err := h.verifySpecSignature(
+		req.GetSpec(), req.GetSignature())
+	if err != nil {
+		return nil, err
+	}
+
 	jobConfig, err := handlerutil.ConvertJobSpecToJobConfig(req.GetSpec())
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to convert job spec")
@@ -288,6 +328,14 @@ func (h *serviceHandler) ReplaceJob(
 		return nil, errors.Wrap(err, "invalid job spec")
 	}
 
+	err = jobconfig.ValidateTaskBackoffPolicy(
+		jobConfig.GetTaskBackoffPolicy(),
+		h.jobSvcCfg.TaskBackoffBounds,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid job spec")
+	}
+
 	jobID := &peloton.JobID{Value: req.GetJobId().GetValue()}
 
 	cachedJob := h.jobFactory.AddJob(jobID)
@@ -316,6 +364,15 @@ func (h *serviceHandler) ReplaceJob(
 		return nil, errors.Wrap(err, "failed to validate spec update")
 	}
 
+	if err := jobconfig.ValidateTaskSizeOverrideUpdate(
+		ctx,
+		h.respoolClient,
+		h.tlsProvider.PeerID(ctx),
+		jobConfig,
+		prevJobConfig); err != nil {
+		return nil, err
+	}
+
 	// get the new configAddOn
 	var respoolPath string
 	for _, label := range prevConfigAddOn.GetSystemLabels() {
@@ -324,7 +381,9 @@ func (h *serviceHandler) ReplaceJob(
 		}
 	}
 	configAddOn := &models.ConfigAddOn{
-		SystemLabels: jobutil.ConstructSystemLabels(jobConfig, respoolPath),
+		SystemLabels: append(
+			jobutil.ConstructSystemLabels(jobConfig, respoolPath),
+			provenanceLabels...),
 	}
 
 	opaque := cached.WithOpaqueData(nil)
@@ -433,12 +492,30 @@ func (h *serviceHandler) RestartJob(
 		}
 	}
 
+	instancesToUpdate, err = h.orderInstancesForRestart(
+		ctx,
+		jobID,
+		instancesToUpdate,
+		req.GetRestartSpec().GetOrder(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	// the order is persisted, so that it is kept across failovers
+	var instancesOrder []uint32
+	if req.GetRestartSpec().GetOrder() !=
+		stateless.RestartOrder_RESTART_ORDER_INSTANCE_ID {
+		instancesOrder = instancesToUpdate
+	}
+
 	updateID, newEntityVersion, err := cachedJob.CreateWorkflow(
 		ctx,
 		models.WorkflowType_RESTART,
 		&pbupdate.UpdateConfig{
-			BatchSize: req.GetRestartSpec().GetBatchSize(),
-			InPlace:   req.GetRestartSpec().GetInPlace(),
+			BatchSize:         req.GetRestartSpec().GetBatchSize(),
+			InPlace:           req.GetRestartSpec().GetInPlace(),
+			HealthWaitSeconds: req.GetRestartSpec().GetHealthWaitSeconds(),
+			InstancesOrder:    instancesOrder,
 		},
 		req.GetVersion(),
 		cached.WithInstanceToProcess(
@@ -721,6 +798,8 @@ func (h *serviceHandler) StopJob(
 
 		jobRuntime.GoalState = pbjob.JobState_KILLED
 		jobRuntime.DesiredStateVersion++
+		// a stopped job is not resumed
+		jobRuntime.Suspended = false
 
 		if jobRuntime, err = cachedJob.CompareAndSetRuntime(ctx, jobRuntime); err != nil {
 			if err == jobmgrcommon.UnexpectedVersionError {
@@ -795,6 +874,7 @@ func (h *serviceHandler) DeleteJob(
 
 		runtime.GoalState = pbjob.JobState_DELETED
 		runtime.DesiredStateVersion++
+		runtime.Suspended = false
 
 		if runtime, err = cachedJob.CompareAndSetRuntime(ctx, runtime); err != nil {
 			if err == jobmgrcommon.UnexpectedVersionError {
@@ -1127,6 +1207,13 @@ func (h *serviceHandler) QueryPods(
 	taskQuerySpec := handlerutil.ConvertPodQuerySpecToTaskQuerySpec(
 		req.GetSpec(),
 	)
+	taskQuerySpec.Pagination, err = h.jobSvcCfg.Pagination.TaskQueryPagination(
+		taskQuerySpec.GetPagination(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	taskInfos, total, err := h.taskStore.QueryTasks(ctx, pelotonJobID, taskQuerySpec)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to query tasks from DB")
@@ -1177,6 +1264,11 @@ func (h *serviceHandler) QueryJobs(
 	}
 
 	querySpec := handlerutil.ConvertStatelessQuerySpecToJobQuerySpec(req.GetSpec())
+	querySpec.Pagination, err = h.jobSvcCfg.Pagination.JobQueryPagination(
+		querySpec.GetPagination())
+	if err != nil {
+		return nil, err
+	}
 	log.WithField("spec", querySpec).Debug("converted spec")
 
 	_, jobSummaries, total, err := h.jobStore.QueryJobs(
@@ -1213,8 +1305,8 @@ func (h *serviceHandler) QueryJobs(
 	return &svc.QueryJobsResponse{
 		Records: statelessJobSummaries,
 		Pagination: &v1alphaquery.Pagination{
-			Offset: req.GetSpec().GetPagination().GetOffset(),
-			Limit:  req.GetSpec().GetPagination().GetLimit(),
+			Offset: querySpec.GetPagination().GetOffset(),
+			Limit:  querySpec.GetPagination().GetLimit(),
 			Total:  total,
 		},
 		Spec: req.GetSpec(),
@@ -1652,10 +1744,30 @@ func convertCacheToWorkflowStatus(
 }
 
 // validateResourcePoolForJobCreation validates the resource pool before submitting job
+// verifySpecSignature verifies the signature of the job spec, and returns
+// the system labels recording its provenance.
+func (h *serviceHandler) verifySpecSignature(
+	spec *stateless.JobSpec,
+	signature *stateless.SpecSignature,
+) ([]*peloton.Label, error) {
+	if h.configVerifier == nil {
+		return nil, nil
+	}
+
+	specProvenance, err := h.configVerifier.VerifySpec(spec, signature)
+	if err != nil {
+		log.WithError(err).
+			WithField("key_id", signature.GetKeyId()).
+			Warn("Failed to verify job spec signature")
+		return nil, yarpcerrors.InvalidArgumentErrorf("%v", err)
+	}
+	return specProvenance.Labels(), nil
+}
+
 func (h *serviceHandler) validateResourcePoolForJobCreation(
 	ctx context.Context,
 	respoolID *v1alphapeloton.ResourcePoolID,
-) (*respool.ResourcePoolPath, error) {
+) (*respool.ResourcePoolInfo, error) {
 	if respoolID == nil {
 		return nil, errNullResourcePoolID
 	}
@@ -1681,7 +1793,7 @@ func (h *serviceHandler) validateResourcePoolForJobCreation(
 		return nil, errNonLeafResourcePool
 	}
 
-	return response.GetPoolinfo().GetPath(), nil
+	return response.GetPoolinfo(), nil
 }
 
 // validateSecretsAndConfig checks the secrets for input sanity and makes sure
@@ -1817,6 +1929,50 @@ func (h *serviceHandler) addSecretsToDBAndConfig(
 	return nil
 }
 
+// orderInstancesForRestart sorts the instances of a job to restart in
+// the order they are restarted in. The restart workflow processes the
+// instances in the order they are provided.
+func (h *serviceHandler) orderInstancesForRestart(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	instances []uint32,
+	order stateless.RestartOrder,
+) ([]uint32, error) {
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i] < instances[j]
+	})
+
+	switch order {
+	case stateless.RestartOrder_RESTART_ORDER_INSTANCE_ID:
+		return instances, nil
+
+	case stateless.RestartOrder_RESTART_ORDER_REVERSE_INSTANCE_ID:
+		sort.Slice(instances, func(i, j int) bool {
+			return instances[i] > instances[j]
+		})
+		return instances, nil
+
+	case stateless.RestartOrder_RESTART_ORDER_UNHEALTHY_FIRST:
+		runtimes, err := h.taskStore.GetTaskRuntimesForJobByRange(ctx, jobID, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "fail to get task runtimes")
+		}
+		isHealthy := func(instanceID uint32) bool {
+			runtime := runtimes[instanceID]
+			return runtime.GetState() == task.TaskState_RUNNING &&
+				(runtime.GetHealthy() == task.HealthState_HEALTHY ||
+					runtime.GetHealthy() == task.HealthState_DISABLED)
+		}
+		sort.SliceStable(instances, func(i, j int) bool {
+			return !isHealthy(instances[i]) && isHealthy(instances[j])
+		})
+		return instances, nil
+	}
+
+	return nil, yarpcerrors.InvalidArgumentErrorf(
+		"unknown restart order %s", order)
+}
+
 // convertInstanceIDRangesToSlice merges ranges into a single slice and remove
 // any duplicated item. Instance count is needed because cli may send max uint32
 // when range is not specified.
@@ -1840,9 +1996,9 @@ func convertInstanceIDRangesToSlice(ranges []*pod.InstanceIDRange, instanceCount
 	return result
 }
 
-// TODO: remove this function once eventstream is enabled in RM
 // fillReasonForPendingTasksFromResMgr takes a list of taskinfo and
-// fills in the reason for pending tasks from ResourceManager.
+// fills in the reason for pending tasks from the task events
+// pushed by ResourceManager.
 // All the tasks in `taskInfos` should belong to the same job
 func (h *serviceHandler) fillReasonForPendingTasksFromResMgr(
 	ctx context.Context,
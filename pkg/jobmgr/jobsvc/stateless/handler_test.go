@@ -16,7 +16,13 @@ package stateless
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"testing"
@@ -25,6 +31,7 @@ import (
 	mesos "github.com/uber/peloton/.gen/mesos/v1"
 	pbjob "github.com/uber/peloton/.gen/peloton/api/v0/job"
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/query"
 	"github.com/uber/peloton/.gen/peloton/api/v0/respool"
 	pbtask "github.com/uber/peloton/.gen/peloton/api/v0/task"
 	pbupdate "github.com/uber/peloton/.gen/peloton/api/v0/update"
@@ -42,6 +49,7 @@ import (
 	"github.com/uber/peloton/pkg/common/util"
 	"github.com/uber/peloton/pkg/jobmgr/cached"
 	jobmgrcommon "github.com/uber/peloton/pkg/jobmgr/common"
+	"github.com/uber/peloton/pkg/jobmgr/job/provenance"
 	"github.com/uber/peloton/pkg/jobmgr/jobsvc"
 	handlerutil "github.com/uber/peloton/pkg/jobmgr/util/handler"
 	jobutil "github.com/uber/peloton/pkg/jobmgr/util/job"
@@ -954,6 +962,23 @@ func (suite *statelessHandlerTestSuite) TestQueryJobsSuccess() {
 	suite.NoError(err)
 }
 
+// TestQueryJobsExceedsLimit tests the failure case of query jobs
+// due to a page size larger than allowed
+func (suite *statelessHandlerTestSuite) TestQueryJobsExceedsLimit() {
+	suite.handler.jobSvcCfg.Pagination.MaxJobQueryLimit = 100
+
+	resp, err := suite.handler.QueryJobs(
+		context.Background(),
+		&statelesssvc.QueryJobsRequest{
+			Spec: &stateless.QuerySpec{
+				Pagination: &v1alphaquery.PaginationSpec{Limit: 101},
+			},
+		},
+	)
+	suite.Nil(resp)
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
 // TestQueryJobsGetRespoolIDFail tests the failure case of query jobs
 // due to get respool id
 func (suite *statelessHandlerTestSuite) TestQueryJobsGetRespoolIdFail() {
@@ -1910,6 +1935,125 @@ func (suite *statelessHandlerTestSuite) TestCreateJobFailNonLeader() {
 	suite.Error(err)
 }
 
+// setupConfigVerifier sets up a verifier requiring the job specs to be
+// signed, and returns the key signing them.
+func (suite *statelessHandlerTestSuite) setupConfigVerifier() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	suite.NoError(err)
+	buffer, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	suite.NoError(err)
+
+	suite.handler.configVerifier, err = provenance.NewVerifier(
+		&provenance.Config{
+			RequireSignature: true,
+			PublicKeys: map[string]string{
+				"ci": string(pem.EncodeToMemory(&pem.Block{
+					Type:  "PUBLIC KEY",
+					Bytes: buffer,
+				})),
+			},
+		})
+	suite.NoError(err)
+	return key
+}
+
+// TestCreateJobSignedSpec tests creating a job with a signed spec, whose
+// provenance is recorded in the system labels
+func (suite *statelessHandlerTestSuite) TestCreateJobSignedSpec() {
+	key := suite.setupConfigVerifier()
+	jobSpec := &stateless.JobSpec{
+		DefaultSpec: &pod.PodSpec{
+			Containers: []*pod.ContainerSpec{
+				{
+					Command: &mesos.CommandInfo{Value: &testCmd},
+				},
+			},
+		},
+		RespoolId: testRespoolID,
+	}
+	digest, err := provenance.SpecDigest(jobSpec)
+	suite.NoError(err)
+	signature, err := key.Sign(rand.Reader, digest, crypto.SHA256)
+	suite.NoError(err)
+
+	suite.candidate.EXPECT().IsLeader().Return(true)
+	suite.respoolClient.EXPECT().
+		GetResourcePool(gomock.Any(), gomock.Any()).
+		Return(&respool.GetResponse{
+			Poolinfo: &respool.ResourcePoolInfo{
+				Id: &peloton.ResourcePoolID{Value: testRespoolID.GetValue()},
+			},
+		}, nil)
+	suite.jobFactory.EXPECT().
+		AddJob(gomock.Any()).
+		Return(suite.cachedJob)
+	suite.cachedJob.EXPECT().
+		RollingCreate(
+			gomock.Any(), gomock.Any(), gomock.Any(),
+			gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(
+			_ context.Context,
+			_ *pbjob.JobConfig,
+			configAddOn *models.ConfigAddOn,
+			_ *pbupdate.UpdateConfig,
+			_ *peloton.OpaqueData,
+			_ string) {
+			labels := make(map[string]string)
+			for _, label := range configAddOn.GetSystemLabels() {
+				labels[label.GetKey()] = label.GetValue()
+			}
+			suite.Equal("ci", labels[fmt.Sprintf(
+				common.SystemLabelKeyTemplate,
+				common.SystemLabelPrefix,
+				common.SystemLabelProvenanceKeyID)])
+		}).
+		Return(nil)
+	suite.goalStateDriver.EXPECT().EnqueueJob(gomock.Any(), gomock.Any())
+	suite.cachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(&pbjob.RuntimeInfo{}, nil)
+
+	_, err = suite.handler.CreateJob(
+		context.Background(),
+		&statelesssvc.CreateJobRequest{
+			Spec: jobSpec,
+			Signature: &stateless.SpecSignature{
+				KeyId:     "ci",
+				Signature: signature,
+			},
+		})
+	suite.NoError(err)
+}
+
+// TestCreateJobUnsignedSpec tests creating a job fails with an unsigned
+// spec when signatures are required
+func (suite *statelessHandlerTestSuite) TestCreateJobUnsignedSpec() {
+	suite.setupConfigVerifier()
+	suite.candidate.EXPECT().IsLeader().Return(true)
+
+	_, err := suite.handler.CreateJob(
+		context.Background(),
+		&statelesssvc.CreateJobRequest{
+			Spec: &stateless.JobSpec{RespoolId: testRespoolID},
+		})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestReplaceJobUnsignedSpec tests replacing a job fails with an unsigned
+// spec when signatures are required
+func (suite *statelessHandlerTestSuite) TestReplaceJobUnsignedSpec() {
+	suite.setupConfigVerifier()
+	suite.candidate.EXPECT().IsLeader().Return(true)
+
+	_, err := suite.handler.ReplaceJob(
+		context.Background(),
+		&statelesssvc.ReplaceJobRequest{
+			JobId: &v1alphapeloton.JobID{Value: testJobID},
+			Spec:  &stateless.JobSpec{},
+		})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
 // TestCreateJobFailInvalidJobID tests the failure case of creating job
 // due to invalid JobID provided in the request
 func (suite *statelessHandlerTestSuite) TestCreateJobFailInvalidJobID() {
@@ -2700,6 +2844,7 @@ func (suite *statelessHandlerTestSuite) TestStopJobSuccess() {
 		ConfigurationVersion: testConfigurationVersion,
 		DesiredStateVersion:  testDesiredStateVersion,
 		WorkflowVersion:      testWorkflowVersion,
+		Suspended:            true,
 	}
 
 	suite.candidate.EXPECT().IsLeader().Return(true)
@@ -2722,6 +2867,7 @@ func (suite *statelessHandlerTestSuite) TestStopJobSuccess() {
 		Do(func(ctx context.Context, jobRuntime *pbjob.RuntimeInfo) {
 			suite.Equal(jobRuntime.GetGoalState(), pbjob.JobState_KILLED)
 			suite.Equal(jobRuntime.GetDesiredStateVersion(), testDesiredStateVersion+1)
+			suite.False(jobRuntime.GetSuspended())
 		}).Return(&pbjob.RuntimeInfo{
 		State:                pbjob.JobState_RUNNING,
 		GoalState:            pbjob.JobState_KILLED,
@@ -3203,6 +3349,145 @@ func (suite *statelessHandlerTestSuite) TestRestartJobNoRangeSuccess() {
 	suite.Equal(resp.GetVersion().GetValue(), newEntityVersion.GetValue())
 }
 
+// TestRestartJobUnhealthyFirstSuccess tests restarting the unhealthy
+// pods of a job first, with a health wait between the batches
+func (suite *statelessHandlerTestSuite) TestRestartJobUnhealthyFirstSuccess() {
+	entityVersion := &v1alphapeloton.EntityVersion{Value: "1-1-1"}
+	newEntityVersion := &v1alphapeloton.EntityVersion{Value: "2-1-2"}
+	configVersion := uint64(2)
+
+	suite.candidate.EXPECT().
+		IsLeader().
+		Return(true)
+
+	suite.jobFactory.EXPECT().
+		AddJob(&peloton.JobID{Value: testJobID}).
+		Return(suite.cachedJob)
+
+	suite.cachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(&pbjob.RuntimeInfo{
+			ConfigurationVersion: configVersion,
+		}, nil)
+
+	suite.jobStore.EXPECT().
+		GetJobConfigWithVersion(
+			gomock.Any(),
+			testJobID,
+			configVersion,
+		).
+		Return(&pbjob.JobConfig{
+			InstanceCount: 4,
+		}, nil, nil)
+
+	suite.taskStore.EXPECT().
+		GetTaskRuntimesForJobByRange(
+			gomock.Any(),
+			&peloton.JobID{Value: testJobID},
+			nil,
+		).
+		Return(map[uint32]*pbtask.RuntimeInfo{
+			0: {
+				State:   pbtask.TaskState_RUNNING,
+				Healthy: pbtask.HealthState_HEALTHY,
+			},
+			1: {
+				State:   pbtask.TaskState_RUNNING,
+				Healthy: pbtask.HealthState_UNHEALTHY,
+			},
+			2: {
+				State:   pbtask.TaskState_RUNNING,
+				Healthy: pbtask.HealthState_DISABLED,
+			},
+			3: {
+				State: pbtask.TaskState_FAILED,
+			},
+		}, nil)
+
+	suite.cachedJob.EXPECT().
+		CreateWorkflow(
+			gomock.Any(),
+			models.WorkflowType_RESTART,
+			&pbupdate.UpdateConfig{
+				BatchSize:         1,
+				HealthWaitSeconds: 30,
+				InstancesOrder:    []uint32{1, 3, 0, 2},
+			},
+			entityVersion,
+			gomock.Any(),
+			gomock.Any(),
+			gomock.Any()).
+		Do(
+			func(
+				_ context.Context,
+				_ models.WorkflowType,
+				_ *pbupdate.UpdateConfig,
+				_ *v1alphapeloton.EntityVersion,
+				option ...cached.Option,
+			) {
+				suite.Equal(
+					cached.WithInstanceToProcess(nil, []uint32{1, 3, 0, 2}, nil),
+					option[0],
+				)
+			},
+		).
+		Return(&peloton.UpdateID{Value: testUpdateID}, newEntityVersion, nil)
+
+	suite.goalStateDriver.EXPECT().
+		EnqueueUpdate(
+			&peloton.JobID{Value: testJobID},
+			&peloton.UpdateID{Value: testUpdateID},
+			gomock.Any(),
+		)
+
+	resp, err := suite.handler.RestartJob(
+		context.Background(),
+		&statelesssvc.RestartJobRequest{
+			JobId:   &v1alphapeloton.JobID{Value: testJobID},
+			Version: entityVersion,
+			RestartSpec: &stateless.RestartSpec{
+				BatchSize:         1,
+				HealthWaitSeconds: 30,
+				Order:             stateless.RestartOrder_RESTART_ORDER_UNHEALTHY_FIRST,
+			},
+		},
+	)
+	suite.NoError(err)
+	suite.Equal(resp.GetVersion().GetValue(), newEntityVersion.GetValue())
+}
+
+// TestOrderInstancesForRestart tests ordering the instances to restart
+func (suite *statelessHandlerTestSuite) TestOrderInstancesForRestart() {
+	instances, err := suite.handler.orderInstancesForRestart(
+		context.Background(),
+		&peloton.JobID{Value: testJobID},
+		[]uint32{4, 5, 0, 1},
+		stateless.RestartOrder_RESTART_ORDER_INSTANCE_ID,
+	)
+	suite.NoError(err)
+	suite.Equal([]uint32{0, 1, 4, 5}, instances)
+
+	instances, err = suite.handler.orderInstancesForRestart(
+		context.Background(),
+		&peloton.JobID{Value: testJobID},
+		[]uint32{4, 5, 0, 1},
+		stateless.RestartOrder_RESTART_ORDER_REVERSE_INSTANCE_ID,
+	)
+	suite.NoError(err)
+	suite.Equal([]uint32{5, 4, 1, 0}, instances)
+
+	suite.taskStore.EXPECT().
+		GetTaskRuntimesForJobByRange(gomock.Any(), gomock.Any(), nil).
+		Return(nil, errors.New("test error"))
+	_, err = suite.handler.orderInstancesForRestart(
+		context.Background(),
+		&peloton.JobID{Value: testJobID},
+		[]uint32{0, 1},
+		stateless.RestartOrder_RESTART_ORDER_UNHEALTHY_FIRST,
+	)
+	suite.Error(err)
+}
+
 // TestListJobWorkflowsSuccess tests the success case of list job updates
 func (suite *statelessHandlerTestSuite) TestListJobWorkflowsSuccess() {
 	testUpdateID1 := "941ff353-ba82-49fe-8f80-fb5bc649b04r"
@@ -3751,6 +4036,18 @@ func (suite *statelessHandlerTestSuite) TestQueryPodsSuccess() {
 		},
 	}
 
+	// the default page size and sort order are set
+	taskQuerySpec := handlerutil.ConvertPodQuerySpecToTaskQuerySpec(request.GetSpec())
+	taskQuerySpec.Pagination = &query.PaginationSpec{
+		Limit: 10,
+		OrderBy: []*query.OrderBy{
+			{
+				Order:    query.OrderBy_ASC,
+				Property: &query.PropertyPath{Value: "instanceId"},
+			},
+		},
+	}
+
 	gomock.InOrder(
 		suite.jobStore.EXPECT().
 			GetJobConfig(gomock.Any(), testJobID).
@@ -3760,7 +4057,7 @@ func (suite *statelessHandlerTestSuite) TestQueryPodsSuccess() {
 			QueryTasks(
 				gomock.Any(),
 				pelotonJobID,
-				handlerutil.ConvertPodQuerySpecToTaskQuerySpec(request.GetSpec()),
+				taskQuerySpec,
 			).Return(taskInfos, uint32(len(taskInfos)), nil),
 
 		suite.activeRMTasks.EXPECT().
@@ -3824,6 +4121,18 @@ func (suite *statelessHandlerTestSuite) TestQueryPodsFailureQueryTasksError() {
 		},
 	}
 
+	// the default page size and sort order are set
+	taskQuerySpec := handlerutil.ConvertPodQuerySpecToTaskQuerySpec(request.GetSpec())
+	taskQuerySpec.Pagination = &query.PaginationSpec{
+		Limit: 10,
+		OrderBy: []*query.OrderBy{
+			{
+				Order:    query.OrderBy_ASC,
+				Property: &query.PropertyPath{Value: "instanceId"},
+			},
+		},
+	}
+
 	gomock.InOrder(
 		suite.jobStore.EXPECT().
 			GetJobConfig(gomock.Any(), testJobID).
@@ -3833,7 +4142,7 @@ func (suite *statelessHandlerTestSuite) TestQueryPodsFailureQueryTasksError() {
 			QueryTasks(
 				gomock.Any(),
 				pelotonJobID,
-				handlerutil.ConvertPodQuerySpecToTaskQuerySpec(request.GetSpec()),
+				taskQuerySpec,
 			).Return(nil, uint32(0), yarpcerrors.InternalErrorf("test error")),
 	)
 
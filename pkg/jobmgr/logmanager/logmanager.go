@@ -15,14 +15,36 @@
 package logmanager
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
 const (
 	_slaveSandboxDir    = "%s/slaves/%s/frameworks/%s/executors/%s/runs/latest"
 	_slaveFileBrowseURL = "http://%s:%s/files/browse?path=%s"
+	_slaveFileReadURL   = "http://%s:%s/files/read?%s"
+
+	// _tailChunkSize is the maximum number of bytes read from the agent
+	// at once when tailing a file
+	_tailChunkSize = 64 * 1024
+	// _defaultTailPollInterval is how often the agent is polled for new
+	// data once the end of a tailed file has been reached
+	_defaultTailPollInterval = time.Second
+	// _maxReadLength is the maximum number of bytes returned when reading
+	// a sandbox file
+	_maxReadLength = 1024 * 1024
+	// _maxBrowseDepth is the maximum number of levels of nested
+	// directories listed when browsing a sandbox recursively
+	_maxBrowseDepth = 10
+	// _maxSandboxFiles is the maximum number of files returned when
+	// listing the files of a sandbox
+	_maxSandboxFiles = 10000
 )
 
 // TODO: (varung) Move this component to HostManger
@@ -32,35 +54,114 @@ const (
 type LogManager interface {
 
 	// ListSandboxFilesPaths lists all the sandbox files in the mesos agent executor run directory.
-	ListSandboxFilesPaths(mesosAgentWorDir,
+	ListSandboxFilesPaths(layout *SandboxLayout,
 		frameworkID,
 		hostname,
 		port,
 		agentID,
 		taskID string) ([]string, error)
+
+	// ListSandboxFiles lists the sandbox files in the mesos agent executor
+	// run directory along with their metadata. If recursive is set, the
+	// files of the nested directories are listed too.
+	ListSandboxFiles(layout *SandboxLayout,
+		frameworkID,
+		hostname,
+		port,
+		agentID,
+		taskID string,
+		recursive bool) ([]*SandboxFileInfo, error)
+
+	// TailSandboxFile follows the file at path, relative to the sandbox
+	// directory, and calls send with every chunk written to it starting at
+	// offset. A negative offset is relative to the end of the file. It
+	// returns once the context is done or send fails.
+	TailSandboxFile(
+		ctx context.Context,
+		layout *SandboxLayout,
+		frameworkID,
+		hostname,
+		port,
+		agentID,
+		taskID,
+		path string,
+		offset int64,
+		send func(offset uint64, data []byte) error) error
+
+	// ReadSandboxFile reads up to length bytes at offset of the file at
+	// path, relative to the sandbox directory. A negative offset is
+	// relative to the end of the file.
+	ReadSandboxFile(
+		ctx context.Context,
+		layout *SandboxLayout,
+		frameworkID,
+		hostname,
+		port,
+		agentID,
+		taskID,
+		path string,
+		offset int64,
+		length int) (*SandboxFileChunk, error)
+}
+
+// SandboxFileChunk is a chunk of a sandbox file.
+type SandboxFileChunk struct {
+	// The offset of the chunk in the file
+	Offset uint64
+	// The contents of the chunk
+	Data []byte
+	// The size of the file
+	Size uint64
+}
+
+// SandboxFileInfo is a file or directory of a sandbox.
+type SandboxFileInfo struct {
+	// The path of the file on the agent
+	Path string
+	// The size of the file in bytes
+	Size uint64
+	// The last modification time of the file
+	ModificationTime time.Time
+	// The permissions of the file, such as drwxr-xr-x
+	Mode string
+	// Whether the file is a directory
+	IsDirectory bool
 }
 
 // logManager is a wrapper to collect logs location by talking to mesos agents.
 type logManager struct {
-	client *http.Client
+	client           *http.Client
+	tailPollInterval time.Duration
 }
 
 // NewLogManager returns a logManager instance.
 func NewLogManager(client *http.Client) LogManager {
 	return &logManager{
-		client: client,
+		client:           client,
+		tailPollInterval: _defaultTailPollInterval,
 	}
 }
 
+// filePath is an entry of the response of the files/browse endpoint of
+// the agent
 type filePath struct {
-	Path string `json:"path"`
+	Path  string  `json:"path"`
+	Size  uint64  `json:"size"`
+	Mtime float64 `json:"mtime"`
+	Mode  string  `json:"mode"`
+}
+
+// fileChunk is the response of the files/read endpoint of the agent
+type fileChunk struct {
+	Data   string `json:"data"`
+	Offset int64  `json:"offset"`
 }
 
 // ListSandboxFilesPaths returns the list of logs url under sandbox directory for given task.
 func (l *logManager) ListSandboxFilesPaths(
-	mesosAgentWorDir, frameworkID, hostname, port,
+	layout *SandboxLayout, frameworkID, hostname, port,
 	agentID, taskID string) ([]string, error) {
-	slaveBrowseURL := getSlaveFileBrowseEndpointURL(mesosAgentWorDir,
+	slaveBrowseURL := getSlaveFileBrowseEndpointURL(layout,
 		frameworkID, hostname, port, agentID, taskID)
 
 	result, err := listTaskLogFiles(l.client, slaveBrowseURL)
@@ -71,10 +172,58 @@ func (l *logManager) ListSandboxFilesPaths(
 	return result, nil
 }
 
-func getSlaveFileBrowseEndpointURL(mesosAgentWorDir, frameworkID,
+// ListSandboxFiles returns the files under the sandbox directory of the
+// given task with their metadata. The nested directories are browsed up
+// to _maxBrowseDepth levels deep, and at most _maxSandboxFiles files are
+// returned.
+func (l *logManager) ListSandboxFiles(
+	layout *SandboxLayout, frameworkID, hostname, port,
+	agentID, taskID string,
+	recursive bool) ([]*SandboxFileInfo, error) {
+	sandboxDir := layout.getSandboxDir(frameworkID, agentID, taskID)
+
+	var result []*SandboxFileInfo
+	dirs := []string{sandboxDir}
+	for depth := 0; len(dirs) > 0 && depth < _maxBrowseDepth; depth++ {
+		var nested []string
+		for _, dir := range dirs {
+			entries, err := browseFiles(l.client, fmt.Sprintf(
+				_slaveFileBrowseURL, hostname, port, url.QueryEscape(dir)))
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				file := toSandboxFileInfo(entry)
+				result = append(result, file)
+				if len(result) >= _maxSandboxFiles {
+					return result, nil
+				}
+				if recursive && file.IsDirectory {
+					nested = append(nested, file.Path)
+				}
+			}
+		}
+		dirs = nested
+	}
+	return result, nil
+}
+
+// toSandboxFileInfo converts an entry of the files/browse endpoint of the
+// agent to a SandboxFileInfo.
+func toSandboxFileInfo(entry filePath) *SandboxFileInfo {
+	sec, frac := math.Modf(entry.Mtime)
+	return &SandboxFileInfo{
+		Path:             entry.Path,
+		Size:             entry.Size,
+		ModificationTime: time.Unix(int64(sec), int64(frac*1e9)),
+		Mode:             entry.Mode,
+		IsDirectory:      strings.HasPrefix(entry.Mode, "d"),
+	}
+}
+
+func getSlaveFileBrowseEndpointURL(layout *SandboxLayout, frameworkID,
 	hostname, port, agentID, taskID string) string {
-	sandboxDir := fmt.Sprintf(_slaveSandboxDir, mesosAgentWorDir,
-		agentID, frameworkID, taskID)
+	sandboxDir := layout.getSandboxDir(frameworkID, agentID, taskID)
 	return fmt.Sprintf(_slaveFileBrowseURL, hostname, port, sandboxDir)
 }
 
@@ -82,24 +231,183 @@ func getSlaveFileBrowseEndpointURL(mesosAgentWorDir, frameworkID,
 func listTaskLogFiles(client *http.Client, fileURL string) ([]string, error) {
 
 	var result []string
-	resp, err := client.Get(fileURL)
+	slaveResp, err := browseFiles(client, fileURL)
 	if err != nil {
 		return result, err
 	}
+
+	for _, file := range slaveResp {
+		result = append(result, file.Path)
+	}
+	return result, nil
+}
+
+// browseFiles returns the entries of a directory of an agent.
+func browseFiles(client *http.Client, fileURL string) ([]filePath, error) {
+	resp, err := client.Get(fileURL)
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return result, fmt.Errorf("HTTP GET failed for %s: %v", fileURL, resp)
+		return nil, fmt.Errorf("HTTP GET failed for %s: %v", fileURL, resp)
 	}
 
 	var slaveResp []filePath
 	if err = json.NewDecoder(resp.Body).Decode(&slaveResp); err != nil {
-		return result,
+		return nil,
 			fmt.Errorf("Failed to decode response for %s: %v", fileURL, resp)
 	}
+	return slaveResp, nil
+}
 
-	for _, file := range slaveResp {
-		result = append(result, file.Path)
+// TailSandboxFile follows the file at path, relative to the sandbox
+// directory, and calls send with every chunk written to it.
+func (l *logManager) TailSandboxFile(
+	ctx context.Context,
+	layout *SandboxLayout, frameworkID, hostname, port,
+	agentID, taskID, path string,
+	offset int64,
+	send func(offset uint64, data []byte) error) error {
+	sandboxFile := getSandboxFilePath(layout,
+		frameworkID, agentID, taskID, path)
+
+	if offset < 0 {
+		// An offset of -1 returns the size of the file without any data
+		chunk, err := readFileChunk(ctx, l.client, hostname, port, sandboxFile, -1, 0)
+		if err != nil {
+			return err
+		}
+		offset += chunk.Offset
+		if offset < 0 {
+			offset = 0
+		}
+	}
+
+	for {
+		chunk, err := readFileChunk(
+			ctx, l.client, hostname, port, sandboxFile, offset, _tailChunkSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if len(chunk.Data) > 0 {
+			if err := send(uint64(offset), []byte(chunk.Data)); err != nil {
+				return err
+			}
+			offset += int64(len(chunk.Data))
+		}
+
+		// Keep reading while the chunks are full, and wait for new
+		// data once the end of the file has been reached
+		if len(chunk.Data) == _tailChunkSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(l.tailPollInterval):
+		}
+	}
+}
+
+// ReadSandboxFile reads up to length bytes at offset of the file at path,
+// relative to the sandbox directory. At most _maxReadLength bytes are read.
+func (l *logManager) ReadSandboxFile(
+	ctx context.Context,
+	layout *SandboxLayout, frameworkID, hostname, port,
+	agentID, taskID, path string,
+	offset int64,
+	length int) (*SandboxFileChunk, error) {
+	sandboxFile := getSandboxFilePath(layout,
+		frameworkID, agentID, taskID, path)
+
+	// An offset of -1 returns the size of the file without any data
+	info, err := readFileChunk(ctx, l.client, hostname, port, sandboxFile, -1, 0)
+	if err != nil {
+		return nil, err
+	}
+	size := info.Offset
+
+	if offset < 0 {
+		offset += size
+		if offset < 0 {
+			offset = 0
+		}
+	}
+	if offset > size {
+		offset = size
+	}
+	if length <= 0 || length > _maxReadLength {
+		length = _maxReadLength
+	}
+	if remaining := size - offset; int64(length) > remaining {
+		length = int(remaining)
+	}
+
+	result := &SandboxFileChunk{
+		Offset: uint64(offset),
+		Size:   uint64(size),
+	}
+	// The agent may return less data than requested, so keep reading
+	// until length bytes have been read or the end of the file is reached
+	for len(result.Data) < length {
+		chunk, err := readFileChunk(ctx, l.client, hostname, port, sandboxFile,
+			offset+int64(len(result.Data)), length-len(result.Data))
+		if err != nil {
+			return nil, err
+		}
+		if len(chunk.Data) == 0 {
+			break
+		}
+		result.Data = append(result.Data, chunk.Data...)
 	}
 	return result, nil
 }
+
+// getSandboxFilePath returns the path on the agent of a sandbox file.
+func getSandboxFilePath(layout *SandboxLayout, frameworkID,
+	agentID, taskID, path string) string {
+	return layout.getSandboxDir(frameworkID, agentID, taskID) + "/" + path
+}
+
+// readFileChunk reads up to length bytes at offset of a file of an agent.
+func readFileChunk(
+	ctx context.Context,
+	client *http.Client,
+	hostname, port, path string,
+	offset int64,
+	length int) (*fileChunk, error) {
+	query := url.Values{}
+	query.Set("path", path)
+	query.Set("offset", fmt.Sprint(offset))
+	if length > 0 {
+		query.Set("length", fmt.Sprint(length))
+	}
+	fileURL := fmt.Sprintf(_slaveFileReadURL, hostname, port, query.Encode())
+
+	req, err := http.NewRequest(http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP GET failed for %s: %v", fileURL, resp)
+	}
+
+	chunk := &fileChunk{}
+	if err = json.NewDecoder(resp.Body).Decode(chunk); err != nil {
+		return nil,
+			fmt.Errorf("Failed to decode response for %s: %v", fileURL, resp)
+	}
+	return chunk, nil
+}
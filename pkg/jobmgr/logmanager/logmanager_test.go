@@ -15,9 +15,15 @@
 package logmanager
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"testing"
 	"time"
 
@@ -34,6 +40,8 @@ const (
 	_testMesosWorkDir = "/var/lib/mesos/agent"
 )
 
+var _testLayout = &SandboxLayout{WorkDir: _testMesosWorkDir}
+
 type LogManagerTestSuite struct {
 	suite.Suite
 }
@@ -90,7 +98,7 @@ func (suite *LogManagerTestSuite) TestListSandboxFilesPaths() {
 		},
 	}
 	_, err := lm.ListSandboxFilesPaths(
-		_testMesosWorkDir,
+		_testLayout,
 		_testFrameworkID,
 		_testHostname,
 		_testPort,
@@ -99,9 +107,74 @@ func (suite *LogManagerTestSuite) TestListSandboxFilesPaths() {
 	suite.Error(err)
 }
 
+// sandboxBrowseMux returns an agent serving a sandbox with a nested
+// directory
+func sandboxBrowseMux(sandboxDir string) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/files/browse", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("path") {
+		case sandboxDir:
+			fmt.Fprintf(w, `[
+				{"path": "%[1]s/stdout", "size": 10, "mtime": 1546398245.5,
+				 "mode": "-rw-r--r--"},
+				{"path": "%[1]s/logs", "size": 4096, "mtime": 1546398245,
+				 "mode": "drwxr-xr-x"}]`, sandboxDir)
+		case sandboxDir + "/logs":
+			fmt.Fprintf(w, `[
+				{"path": "%s/logs/app.log", "size": 20, "mtime": 1546398246,
+				 "mode": "-rw-------"}]`, sandboxDir)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	return mux
+}
+
+// TestListSandboxFiles tests listing the files of a sandbox with their
+// metadata, with and without recursing into the nested directories
+func (suite *LogManagerTestSuite) TestListSandboxFiles() {
+	sandboxDir := fmt.Sprintf(_slaveSandboxDir, _testMesosWorkDir,
+		_testAgentID, _testFrameworkID, _testTaskID)
+	ts := httptest.NewServer(sandboxBrowseMux(sandboxDir))
+	defer ts.Close()
+	lm, hostname, port := newTailTestLogManager(ts)
+
+	files, err := lm.ListSandboxFiles(_testLayout, _testFrameworkID,
+		hostname, port, _testAgentID, _testTaskID, false)
+	suite.NoError(err)
+	suite.Len(files, 2)
+	suite.Equal(&SandboxFileInfo{
+		Path:             sandboxDir + "/stdout",
+		Size:             10,
+		ModificationTime: time.Unix(1546398245, 5e8),
+		Mode:             "-rw-r--r--",
+	}, files[0])
+	suite.True(files[1].IsDirectory)
+
+	files, err = lm.ListSandboxFiles(_testLayout, _testFrameworkID,
+		hostname, port, _testAgentID, _testTaskID, true)
+	suite.NoError(err)
+	suite.Len(files, 3)
+	suite.Equal(sandboxDir+"/logs/app.log", files[2].Path)
+	suite.Equal(uint64(20), files[2].Size)
+	suite.False(files[2].IsDirectory)
+}
+
+// TestListSandboxFilesFailure tests listing the files of a sandbox
+// which cannot be browsed
+func (suite *LogManagerTestSuite) TestListSandboxFilesFailure() {
+	ts := httptest.NewServer(sandboxBrowseMux("/other"))
+	defer ts.Close()
+	lm, hostname, port := newTailTestLogManager(ts)
+
+	_, err := lm.ListSandboxFiles(_testLayout, _testFrameworkID,
+		hostname, port, _testAgentID, _testTaskID, true)
+	suite.Error(err)
+}
+
 func (suite *LogManagerTestSuite) TestGetSlaveFileBrowseEndpointURL() {
 	sandboxDir := getSlaveFileBrowseEndpointURL(
-		_testMesosWorkDir, _testFrameworkID, _testHostname, _testPort,
+		_testLayout, _testFrameworkID, _testHostname, _testPort,
 		_testAgentID, _testTaskID)
 	suite.Equal(
 		"http://test-hostname:31002/files/browse?path="+
@@ -110,7 +183,152 @@ func (suite *LogManagerTestSuite) TestGetSlaveFileBrowseEndpointURL() {
 		sandboxDir)
 }
 
+// newTailTestLogManager returns a logManager and the hostname and port of
+// a test agent serving the sandbox files
+func newTailTestLogManager(ts *httptest.Server) (*logManager, string, string) {
+	u, _ := url.Parse(ts.URL)
+	hostname, port, _ := net.SplitHostPort(u.Host)
+	return &logManager{
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		tailPollInterval: time.Millisecond,
+	}, hostname, port
+}
+
+func (suite *LogManagerTestSuite) TestTailSandboxFile() {
+	ts := httptest.NewServer(slaveMux())
+	defer ts.Close()
+	lm, hostname, port := newTailTestLogManager(ts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var offsets []uint64
+	var data string
+	err := lm.TailSandboxFile(
+		ctx,
+		_testLayout,
+		_testFrameworkID,
+		hostname,
+		port,
+		_testAgentID,
+		_testTaskID,
+		"stdout",
+		-5,
+		func(offset uint64, chunk []byte) error {
+			offsets = append(offsets, offset)
+			data += string(chunk)
+			if data == "world" {
+				cancel()
+			}
+			return nil
+		})
+	suite.NoError(err)
+	suite.Equal([]uint64{6, 10}, offsets)
+	suite.Equal("world", data)
+}
+
+func (suite *LogManagerTestSuite) TestTailSandboxFileSendFailure() {
+	ts := httptest.NewServer(slaveMux())
+	defer ts.Close()
+	lm, hostname, port := newTailTestLogManager(ts)
+
+	err := lm.TailSandboxFile(
+		context.Background(),
+		_testLayout,
+		_testFrameworkID,
+		hostname,
+		port,
+		_testAgentID,
+		_testTaskID,
+		"stdout",
+		0,
+		func(offset uint64, chunk []byte) error {
+			suite.Equal(uint64(0), offset)
+			suite.Equal("hell", string(chunk))
+			return errors.New("stream closed")
+		})
+	suite.EqualError(err, "stream closed")
+}
+
+func (suite *LogManagerTestSuite) TestTailSandboxFileAgentFailure() {
+	lm := &logManager{
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+	err := lm.TailSandboxFile(
+		context.Background(),
+		_testLayout,
+		_testFrameworkID,
+		_testHostname,
+		_testPort,
+		_testAgentID,
+		_testTaskID,
+		"stdout",
+		0,
+		func(offset uint64, chunk []byte) error {
+			return nil
+		})
+	suite.Error(err)
+}
+
+func (suite *LogManagerTestSuite) TestReadSandboxFile() {
+	ts := httptest.NewServer(slaveMux())
+	defer ts.Close()
+	lm, hostname, port := newTailTestLogManager(ts)
+
+	tests := []struct {
+		offset int64
+		length int
+		chunk  *SandboxFileChunk
+	}{
+		{0, 0, &SandboxFileChunk{Offset: 0, Data: []byte("hello world")}},
+		{-5, 3, &SandboxFileChunk{Offset: 6, Data: []byte("wor")}},
+		{-100, 5, &SandboxFileChunk{Offset: 0, Data: []byte("hello")}},
+		{100, 5, &SandboxFileChunk{Offset: 11}},
+	}
+	for _, test := range tests {
+		test.chunk.Size = uint64(len(_slaveFileContent))
+		chunk, err := lm.ReadSandboxFile(
+			context.Background(),
+			_testLayout,
+			_testFrameworkID,
+			hostname,
+			port,
+			_testAgentID,
+			_testTaskID,
+			"stdout",
+			test.offset,
+			test.length)
+		suite.NoError(err)
+		suite.Equal(test.chunk, chunk)
+	}
+}
+
+func (suite *LogManagerTestSuite) TestReadSandboxFileAgentFailure() {
+	lm := &logManager{
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+	_, err := lm.ReadSandboxFile(
+		context.Background(),
+		_testLayout,
+		_testFrameworkID,
+		_testHostname,
+		_testPort,
+		_testAgentID,
+		_testTaskID,
+		"stdout",
+		0,
+		0)
+	suite.Error(err)
+}
+
 var (
+	_slaveFileContent   = "hello world"
 	_slaveFileBrowseStr = `[{"path": "/var/lib/path1"}, {"path": "/var/lib/path2"}]`
 	_NonJSONResponse    = `error`
 )
@@ -124,6 +342,28 @@ func slaveMux() *http.ServeMux {
 		return
 	})
 
+	mux.HandleFunc("/files/read", func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		length, _ := strconv.Atoi(r.URL.Query().Get("length"))
+		chunk := fileChunk{Offset: int64(offset)}
+		if offset < 0 {
+			chunk.Offset = int64(len(_slaveFileContent))
+		} else if offset < len(_slaveFileContent) {
+			chunk.Data = _slaveFileContent[offset:]
+			// return at most 4 bytes at once like an agent with a small
+			// read limit would
+			if length > 4 {
+				length = 4
+			}
+			if length > 0 && length < len(chunk.Data) {
+				chunk.Data = chunk.Data[:length]
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(chunk)
+		return
+	})
+
 	mux.HandleFunc("/failed", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
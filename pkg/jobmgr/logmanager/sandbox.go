@@ -0,0 +1,280 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	_slaveFlagsURL = "http://%s:%s/flags"
+
+	// _taskIDPlaceholder is replaced by the task id in the nested sandbox
+	// directory of a layout
+	_taskIDPlaceholder = "{task_id}"
+
+	// _defaultWorkDirTTL is how long the work dir discovered from the
+	// flags of an agent is cached
+	_defaultWorkDirTTL = 10 * time.Minute
+)
+
+// SandboxConfig is the config of the layout of the sandboxes on the
+// Mesos agents, for fleets where not all the agents share the same layout.
+type SandboxConfig struct {
+	// DiscoverWorkDir discovers the work dir of the agents, which do not
+	// match any of the HostLayouts, from the flags of the agent.
+	DiscoverWorkDir bool `yaml:"discover_work_dir"`
+
+	// NestedSandboxDir is the default nested sandbox dir of the layouts.
+	NestedSandboxDir string `yaml:"nested_sandbox_dir"`
+
+	// HostLayouts overrides the layout of the agents on the hosts. The keys
+	// are hostnames, or hostname patterns as matched by path.Match to
+	// override the layout of a pool of hosts, e.g. "gpu-*". An exact
+	// hostname is matched before the patterns.
+	HostLayouts map[string]*SandboxLayout `yaml:"host_layouts"`
+}
+
+// SandboxLayout is the layout of the sandboxes on a Mesos agent.
+type SandboxLayout struct {
+	// WorkDir is the work dir of the Mesos agent.
+	WorkDir string `yaml:"work_dir"`
+
+	// NestedSandboxDir is the directory, relative to the sandbox of the
+	// executor, of the sandbox of a task running in a nested container of
+	// its executor, e.g. "tasks/{task_id}", where {task_id} is replaced by
+	// the task id. Empty if the tasks run in the container of the executor.
+	NestedSandboxDir string `yaml:"nested_sandbox_dir"`
+
+	// executorID is the ID of the executor of the task, the ID of the
+	// task is used if empty
+	executorID string
+}
+
+// WithExecutorID returns the layout of the sandboxes of the task run by
+// the executor with executorID, which differs from the ID of the task for
+// custom executors, see util.CreateExecutorID.
+func (l *SandboxLayout) WithExecutorID(executorID string) *SandboxLayout {
+	layout := *l
+	layout.executorID = executorID
+	return &layout
+}
+
+// ExecutorLayout returns the layout of the sandboxes of the executors,
+// which is the layout of the sandboxes of the tasks unless the tasks run
+// in nested containers of their executors.
+func (l *SandboxLayout) ExecutorLayout() *SandboxLayout {
+	return &SandboxLayout{WorkDir: l.WorkDir, executorID: l.executorID}
+}
+
+// getSandboxDir returns the sandbox directory of a task on the agent.
+func (l *SandboxLayout) getSandboxDir(
+	frameworkID, agentID, taskID string) string {
+	executorID := l.executorID
+	if executorID == "" {
+		executorID = taskID
+	}
+	sandboxDir := fmt.Sprintf(_slaveSandboxDir, l.WorkDir,
+		agentID, frameworkID, executorID)
+	if l.NestedSandboxDir == "" {
+		return sandboxDir
+	}
+	return sandboxDir + "/" +
+		strings.Replace(l.NestedSandboxDir, _taskIDPlaceholder, taskID, -1)
+}
+
+// SandboxLayoutResolver resolves the layout of the sandboxes on the
+// Mesos agents.
+type SandboxLayoutResolver interface {
+	// GetSandboxLayout returns the layout of the sandboxes on the agent
+	// on the host, which is reachable at agentIP:agentPort.
+	GetSandboxLayout(hostname, agentIP, agentPort string) *SandboxLayout
+}
+
+// cachedWorkDir is a work dir discovered from the flags of an agent
+type cachedWorkDir struct {
+	workDir string
+	expiry  time.Time
+}
+
+// sandboxLayoutResolver implements SandboxLayoutResolver
+type sandboxLayoutResolver struct {
+	sync.Mutex
+
+	client        *http.Client
+	defaultLayout *SandboxLayout
+	config        *SandboxConfig
+	// hostname patterns of config.HostLayouts, sorted to match
+	// them in a deterministic order
+	patterns []string
+	ttl      time.Duration
+	now      func() time.Time
+
+	workDirs map[string]*cachedWorkDir
+}
+
+// NewSandboxLayoutResolver returns a SandboxLayoutResolver resolving the
+// layouts from the config, defaulting to defaultWorkDir as the work dir.
+func NewSandboxLayoutResolver(
+	client *http.Client,
+	defaultWorkDir string,
+	config *SandboxConfig,
+) SandboxLayoutResolver {
+	return newSandboxLayoutResolver(
+		client, defaultWorkDir, config, _defaultWorkDirTTL, time.Now)
+}
+
+func newSandboxLayoutResolver(
+	client *http.Client,
+	defaultWorkDir string,
+	config *SandboxConfig,
+	ttl time.Duration,
+	now func() time.Time,
+) *sandboxLayoutResolver {
+	if config == nil {
+		config = &SandboxConfig{}
+	}
+
+	var patterns []string
+	for pattern := range config.HostLayouts {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	return &sandboxLayoutResolver{
+		client: client,
+		defaultLayout: &SandboxLayout{
+			WorkDir:          defaultWorkDir,
+			NestedSandboxDir: config.NestedSandboxDir,
+		},
+		config:   config,
+		patterns: patterns,
+		ttl:      ttl,
+		now:      now,
+		workDirs: make(map[string]*cachedWorkDir),
+	}
+}
+
+// GetSandboxLayout returns the layout of the host overriding the default
+// layout, else the default layout with the work dir discovered from the
+// flags of the agent if enabled.
+func (r *sandboxLayoutResolver) GetSandboxLayout(
+	hostname, agentIP, agentPort string) *SandboxLayout {
+	if layout := r.getHostLayout(hostname); layout != nil {
+		return layout
+	}
+	if !r.config.DiscoverWorkDir {
+		return r.defaultLayout
+	}
+
+	return &SandboxLayout{
+		WorkDir:          r.getWorkDir(hostname, agentIP, agentPort),
+		NestedSandboxDir: r.defaultLayout.NestedSandboxDir,
+	}
+}
+
+// getHostLayout returns the layout overriding the layout of the host,
+// nil if none.
+func (r *sandboxLayoutResolver) getHostLayout(hostname string) *SandboxLayout {
+	if layout, ok := r.config.HostLayouts[hostname]; ok {
+		return r.withDefaults(layout)
+	}
+	for _, pattern := range r.patterns {
+		if ok, _ := path.Match(pattern, hostname); ok {
+			return r.withDefaults(r.config.HostLayouts[pattern])
+		}
+	}
+	return nil
+}
+
+// withDefaults fills the fields of the layout which are not set
+// from the default layout.
+func (r *sandboxLayoutResolver) withDefaults(
+	layout *SandboxLayout) *SandboxLayout {
+	result := *layout
+	if result.WorkDir == "" {
+		result.WorkDir = r.defaultLayout.WorkDir
+	}
+	if result.NestedSandboxDir == "" {
+		result.NestedSandboxDir = r.defaultLayout.NestedSandboxDir
+	}
+	return &result
+}
+
+// getWorkDir returns the work dir of the agent discovered from its
+// flags, or the default work dir if the agent cannot be queried.
+func (r *sandboxLayoutResolver) getWorkDir(
+	hostname, agentIP, agentPort string) string {
+	r.Lock()
+	if cached, ok := r.workDirs[hostname]; ok &&
+		r.now().Before(cached.expiry) {
+		r.Unlock()
+		return cached.workDir
+	}
+	r.Unlock()
+
+	workDir, err := getAgentWorkDir(r.client, agentIP, agentPort)
+	if err != nil || workDir == "" {
+		log.WithError(err).
+			WithField("hostname", hostname).
+			Info("Could not discover the work dir of the Mesos agent")
+		return r.defaultLayout.WorkDir
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	r.workDirs[hostname] = &cachedWorkDir{
+		workDir: workDir,
+		expiry:  r.now().Add(r.ttl),
+	}
+	return workDir
+}
+
+// agentFlags is the response of the flags endpoint of the agent
+type agentFlags struct {
+	Flags struct {
+		WorkDir string `json:"work_dir"`
+	} `json:"flags"`
+}
+
+// getAgentWorkDir returns the work dir in the flags of an agent.
+func getAgentWorkDir(client *http.Client, hostname, port string) (string, error) {
+	flagsURL := fmt.Sprintf(_slaveFlagsURL, hostname, port)
+	resp, err := client.Get(flagsURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP GET failed for %s: %v", flagsURL, resp)
+	}
+
+	var flags agentFlags
+	if err = json.NewDecoder(resp.Body).Decode(&flags); err != nil {
+		return "",
+			fmt.Errorf("Failed to decode response for %s: %v", flagsURL, resp)
+	}
+	return flags.Flags.WorkDir, nil
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logmanager
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SandboxLayoutResolverTestSuite struct {
+	suite.Suite
+
+	now        time.Time
+	flagsCalls int
+	server     *httptest.Server
+	hostname   string
+	port       string
+}
+
+func TestSandboxLayoutResolver(t *testing.T) {
+	suite.Run(t, new(SandboxLayoutResolverTestSuite))
+}
+
+func (suite *SandboxLayoutResolverTestSuite) SetupTest() {
+	suite.now = time.Now()
+	suite.flagsCalls = 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/flags", func(w http.ResponseWriter, r *http.Request) {
+		suite.flagsCalls++
+		fmt.Fprint(w, `{"flags": {"work_dir": "/mnt/mesos"}}`)
+	})
+	suite.server = httptest.NewServer(mux)
+	_, suite.hostname, suite.port = newTailTestLogManager(suite.server)
+}
+
+func (suite *SandboxLayoutResolverTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *SandboxLayoutResolverTestSuite) newResolver(
+	config *SandboxConfig) *sandboxLayoutResolver {
+	return newSandboxLayoutResolver(
+		&http.Client{Timeout: 10 * time.Second},
+		_testMesosWorkDir,
+		config,
+		time.Minute,
+		func() time.Time { return suite.now },
+	)
+}
+
+// TestGetSandboxLayoutDefault tests the default layout is returned
+// without any config.
+func (suite *SandboxLayoutResolverTestSuite) TestGetSandboxLayoutDefault() {
+	r := suite.newResolver(nil)
+	suite.Equal(_testLayout, r.GetSandboxLayout(
+		_testHostname, suite.hostname, suite.port))
+	suite.Equal(0, suite.flagsCalls)
+}
+
+// TestGetSandboxLayoutHostOverrides tests the layout of a host is
+// overridden by its hostname first, then by a hostname pattern.
+func (suite *SandboxLayoutResolverTestSuite) TestGetSandboxLayoutHostOverrides() {
+	r := suite.newResolver(&SandboxConfig{
+		DiscoverWorkDir:  true,
+		NestedSandboxDir: "tasks/{task_id}",
+		HostLayouts: map[string]*SandboxLayout{
+			"gpu-1": {WorkDir: "/gpu1"},
+			"gpu-*": {WorkDir: "/gpu", NestedSandboxDir: "containers"},
+		},
+	})
+
+	suite.Equal(&SandboxLayout{
+		WorkDir:          "/gpu1",
+		NestedSandboxDir: "tasks/{task_id}",
+	}, r.GetSandboxLayout("gpu-1", suite.hostname, suite.port))
+	suite.Equal(&SandboxLayout{
+		WorkDir:          "/gpu",
+		NestedSandboxDir: "containers",
+	}, r.GetSandboxLayout("gpu-2", suite.hostname, suite.port))
+	suite.Equal(0, suite.flagsCalls)
+}
+
+// TestGetSandboxLayoutDiscoverWorkDir tests the work dir of an agent is
+// discovered from its flags, and cached until it expires.
+func (suite *SandboxLayoutResolverTestSuite) TestGetSandboxLayoutDiscoverWorkDir() {
+	r := suite.newResolver(&SandboxConfig{DiscoverWorkDir: true})
+
+	for i := 0; i < 2; i++ {
+		layout := r.GetSandboxLayout(_testHostname, suite.hostname, suite.port)
+		suite.Equal("/mnt/mesos", layout.WorkDir)
+	}
+	suite.Equal(1, suite.flagsCalls)
+
+	suite.now = suite.now.Add(2 * time.Minute)
+	r.GetSandboxLayout(_testHostname, suite.hostname, suite.port)
+	suite.Equal(2, suite.flagsCalls)
+}
+
+// TestGetSandboxLayoutDiscoverWorkDirFailure tests the default work dir
+// is returned if the flags of the agent cannot be queried.
+func (suite *SandboxLayoutResolverTestSuite) TestGetSandboxLayoutDiscoverWorkDirFailure() {
+	r := suite.newResolver(&SandboxConfig{DiscoverWorkDir: true})
+
+	layout := r.GetSandboxLayout(_testHostname, _testHostname, _testPort)
+	suite.Equal(_testMesosWorkDir, layout.WorkDir)
+}
+
+// TestGetSandboxDirNested tests the sandbox dir of a task running in a
+// nested container of its executor.
+func (suite *SandboxLayoutResolverTestSuite) TestGetSandboxDirNested() {
+	layout := &SandboxLayout{
+		WorkDir:          _testMesosWorkDir,
+		NestedSandboxDir: "tasks/{task_id}",
+	}
+	suite.Equal(
+		"/var/lib/mesos/agent/slaves/test-agent-id/frameworks"+
+			"/test-framework-id/executors/test-task-id/runs/latest"+
+			"/tasks/test-task-id",
+		layout.getSandboxDir(_testFrameworkID, _testAgentID, _testTaskID))
+}
+
+// TestExecutorLayout tests the sandbox dir of the executor of a task
+// running in a nested container.
+func (suite *SandboxLayoutResolverTestSuite) TestExecutorLayout() {
+	layout := &SandboxLayout{
+		WorkDir:          _testMesosWorkDir,
+		NestedSandboxDir: "tasks/{task_id}",
+	}
+	suite.Equal(
+		"/var/lib/mesos/agent/slaves/test-agent-id/frameworks"+
+			"/test-framework-id/executors/test-task-id/runs/latest",
+		layout.ExecutorLayout().getSandboxDir(
+			_testFrameworkID, _testAgentID, _testTaskID))
+}
+
+// TestCustomExecutorLayout tests the sandbox dirs of a task run by a
+// custom executor, whose ID differs from the ID of the task.
+func (suite *SandboxLayoutResolverTestSuite) TestCustomExecutorLayout() {
+	layout := (&SandboxLayout{
+		WorkDir:          _testMesosWorkDir,
+		NestedSandboxDir: "tasks/{task_id}",
+	}).WithExecutorID("thermos-" + _testTaskID)
+	suite.Equal(
+		"/var/lib/mesos/agent/slaves/test-agent-id/frameworks"+
+			"/test-framework-id/executors/thermos-test-task-id/runs/latest"+
+			"/tasks/test-task-id",
+		layout.getSandboxDir(_testFrameworkID, _testAgentID, _testTaskID))
+	suite.Equal(
+		"/var/lib/mesos/agent/slaves/test-agent-id/frameworks"+
+			"/test-framework-id/executors/thermos-test-task-id/runs/latest",
+		layout.ExecutorLayout().getSandboxDir(
+			_testFrameworkID, _testAgentID, _testTaskID))
+}
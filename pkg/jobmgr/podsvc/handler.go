@@ -58,8 +58,10 @@ type serviceHandler struct {
 	goalStateDriver    goalstate.Driver
 	candidate          leader.Candidate
 	logManager         logmanager.LogManager
-	mesosAgentWorkDir  string
+	sandboxLayouts     logmanager.SandboxLayoutResolver
 	hostMgrClient      hostsvc.InternalHostServiceYARPCClient
+	sandboxInfo        handlerutil.SandboxInfoCache
+	pagination         handlerutil.PaginationConfig
 }
 
 // InitV1AlphaPodServiceHandler initializes the Pod Service Handler
@@ -72,8 +74,9 @@ func InitV1AlphaPodServiceHandler(
 	goalStateDriver goalstate.Driver,
 	candidate leader.Candidate,
 	logManager logmanager.LogManager,
-	mesosAgentWorkDir string,
+	sandboxLayouts logmanager.SandboxLayoutResolver,
 	hostMgrClient hostsvc.InternalHostServiceYARPCClient,
+	pagination handlerutil.PaginationConfig,
 ) {
 	handler := &serviceHandler{
 		jobStore:           jobStore,
@@ -83,8 +86,11 @@ func InitV1AlphaPodServiceHandler(
 		goalStateDriver:    goalStateDriver,
 		candidate:          candidate,
 		logManager:         logManager,
-		mesosAgentWorkDir:  mesosAgentWorkDir,
+		sandboxLayouts:     sandboxLayouts,
 		hostMgrClient:      hostMgrClient,
+		sandboxInfo: handlerutil.NewSandboxInfoCache(
+			_frameworkName, frameworkInfoStore, hostMgrClient),
+		pagination: pagination,
 	}
 	d.Register(svc.BuildPodServiceYARPCProcedures(handler))
 }
@@ -127,8 +133,8 @@ func (h *serviceHandler) StartPod(
 	if err := h.startJob(ctx, cachedJob, cachedConfig); err != nil {
 		// enqueue job state to goal state engine and let goal state engine
 		// decide if the job state needs to be changed
-		goalstate.EnqueueJobWithDefaultDelay(
-			&v0peloton.JobID{Value: jobID}, h.goalStateDriver, cachedJob)
+		h.goalStateDriver.EnqueueJobWithDefaultDelay(
+			&v0peloton.JobID{Value: jobID}, cachedJob.GetJobType())
 		return nil, err
 	}
 
@@ -142,8 +148,8 @@ func (h *serviceHandler) StartPod(
 	// enqueue the pod/job into goal state engine even in failure case.
 	// Because the state may be updated, let goal state engine decide what to do
 	h.goalStateDriver.EnqueueTask(&v0peloton.JobID{Value: jobID}, instanceID, time.Now())
-	goalstate.EnqueueJobWithDefaultDelay(
-		&v0peloton.JobID{Value: jobID}, h.goalStateDriver, cachedJob)
+	h.goalStateDriver.EnqueueJobWithDefaultDelay(
+		&v0peloton.JobID{Value: jobID}, cachedJob.GetJobType())
 	if err != nil {
 		return nil, err
 	}
@@ -481,6 +487,11 @@ func (h *serviceHandler) GetPodEvents(
 	if err != nil {
 		return nil, err
 	}
+	// the events are sorted from the most recent one, the oldest events
+	// of a run with more events than the limit are dropped
+	if limit := h.pagination.PodEventsOfRunLimit(); uint32(len(events)) > limit {
+		events = events[:limit]
+	}
 	return &svc.GetPodEventsResponse{
 		Events: events,
 	}, nil
@@ -509,7 +520,7 @@ func (h *serviceHandler) BrowsePodSandbox(
 		return nil, err
 	}
 
-	hostname, agentID, podID, frameworkID, err :=
+	hostname, agentID, podID, executorID, frameworkID, err :=
 		h.getSandboxPathInfo(
 			ctx,
 			jobID,
@@ -522,27 +533,13 @@ func (h *serviceHandler) BrowsePodSandbox(
 
 	// Extract the IP address + port of the agent, if possible,
 	// because the hostname may not be resolvable on the network
-	agentIP := hostname
-	agentPort := "5051"
-	agentResponse, err := h.hostMgrClient.GetMesosAgentInfo(ctx,
-		&hostsvc.GetMesosAgentInfoRequest{Hostname: hostname})
-	if err == nil && len(agentResponse.Agents) > 0 {
-		ip, port, err := util.ExtractIPAndPortFromMesosAgentPID(
-			agentResponse.Agents[0].GetPid())
-		if err == nil {
-			agentIP = ip
-			if port != "" {
-				agentPort = port
-			}
-		}
-	} else {
-		log.WithField("hostname", hostname).
-			Info("Could not get Mesos agent info")
-	}
+	agentIP, agentPort := h.sandboxInfo.GetAgentAddress(ctx, hostname, agentID)
 
 	var logPaths []string
 	logPaths, err = h.logManager.ListSandboxFilesPaths(
-		h.mesosAgentWorkDir,
+		h.sandboxLayouts.
+			GetSandboxLayout(hostname, agentIP, agentPort).
+			WithExecutorID(executorID),
 		frameworkID,
 		agentIP,
 		agentPort,
@@ -551,13 +548,13 @@ func (h *serviceHandler) BrowsePodSandbox(
 	)
 
 	if err != nil {
+		// the agent, the Mesos master or the framework may have
+		// re-registered since they were cached
+		h.sandboxInfo.Invalidate()
 		return nil, err
 	}
 
-	mesosMasterHostPortResponse, err := h.hostMgrClient.GetMesosMasterHostPort(
-		ctx,
-		&hostsvc.MesosMasterHostPortRequest{},
-	)
+	mesosMasterHostPortResponse, err := h.sandboxInfo.GetMesosMasterHostPort(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -615,8 +612,8 @@ func (h *serviceHandler) RefreshPod(
 	}
 
 	h.goalStateDriver.EnqueueTask(pelotonJobID, instanceID, time.Now())
-	goalstate.EnqueueJobWithDefaultDelay(
-		pelotonJobID, h.goalStateDriver, cachedJob)
+	h.goalStateDriver.EnqueueJobWithDefaultDelay(
+		pelotonJobID, cachedJob.GetJobType())
 
 	return &svc.RefreshPodResponse{}, nil
 }
@@ -713,10 +710,10 @@ func (h *serviceHandler) getHostInfo(
 	jobID string,
 	instanceID uint32,
 	podID string,
-) (hostname, podid, agentID string, err error) {
+) (hostname, podid, agentID string, version *v1alphapeloton.EntityVersion, err error) {
 	events, err := h.podStore.GetPodEvents(ctx, jobID, instanceID, podID)
 	if err != nil {
-		return "", "", "", errors.Wrap(err, "failed to get pod events")
+		return "", "", "", nil, errors.Wrap(err, "failed to get pod events")
 	}
 
 	hostname = ""
@@ -726,21 +723,57 @@ func (h *serviceHandler) getHostInfo(
 		if event.GetActualState() == jobmgrtask.GetDefaultTaskGoalState(pbjob.JobType_SERVICE).String() {
 			hostname = event.GetHostname()
 			agentID = event.GetAgentId()
+			version = event.GetVersion()
 			break
 		}
 	}
 
-	return hostname, podid, agentID, nil
+	return hostname, podid, agentID, version, nil
+}
+
+// getExecutorID returns the ID of the executor of the pod run with the
+// entity version. It is the ID of the pod if the config of the run can
+// not be read, as only custom executors have an ID of their own.
+func (h *serviceHandler) getExecutorID(
+	ctx context.Context,
+	jobID string,
+	instanceID uint32,
+	podID string,
+	version *v1alphapeloton.EntityVersion,
+) string {
+	configVersion, err := jobutil.ParsePodEntityVersion(version)
+	if err != nil {
+		return podID
+	}
+
+	taskConfig, _, err := h.podStore.GetTaskConfig(
+		ctx,
+		&v0peloton.JobID{Value: jobID},
+		instanceID,
+		configVersion,
+	)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"job_id":         jobID,
+			"instance_id":    instanceID,
+			"config_version": configVersion,
+		}).WithError(err).
+			Warn("failed to get the executor of the pod")
+		return podID
+	}
+	return util.CreateExecutorID(podID, taskConfig.GetExecutor())
 }
 
 // getSandboxPathInfo - return details such as hostname, agentID,
-// frameworkID and podName to create sandbox path.
+// frameworkID, podName and the ID of the executor of the pod to create
+// sandbox path.
 func (h *serviceHandler) getSandboxPathInfo(ctx context.Context,
 	jobID string,
 	instanceID uint32,
 	podID string,
-) (hostname, agentID, podid, frameworkID string, err error) {
-	hostname, podid, agentID, err = h.getHostInfo(
+) (hostname, agentID, podid, executorID, frameworkID string, err error) {
+	var version *v1alphapeloton.EntityVersion
+	hostname, podid, agentID, version, err = h.getHostInfo(
 		ctx,
 		jobID,
 		instanceID,
@@ -748,24 +781,26 @@ func (h *serviceHandler) getSandboxPathInfo(ctx context.Context,
 	)
 
 	if err != nil {
-		return "", "", "", "", err
+		return "", "", "", "", "", err
 	}
 
 	if len(hostname) == 0 || len(agentID) == 0 {
-		return "", "", "", "", yarpcerrors.AbortedErrorf("pod has not been run")
+		return "", "", "", "", "", yarpcerrors.AbortedErrorf("pod has not been run")
 	}
 
 	// get framework ID.
 	frameworkid, err := h.getFrameworkID(ctx)
 	if err != nil {
-		return "", "", "", "", err
+		return "", "", "", "", "", err
 	}
-	return hostname, agentID, podid, frameworkid, nil
+
+	executorID = h.getExecutorID(ctx, jobID, instanceID, podid, version)
+	return hostname, agentID, podid, executorID, frameworkid, nil
 }
 
 // GetFrameworkID returns the frameworkID.
 func (h *serviceHandler) getFrameworkID(ctx context.Context) (string, error) {
-	frameworkIDVal, err := h.frameworkInfoStore.GetFrameworkID(ctx, _frameworkName)
+	frameworkIDVal, err := h.sandboxInfo.GetFrameworkID(ctx)
 	if err != nil {
 		return frameworkIDVal, err
 	}
@@ -18,7 +18,6 @@ import (
 	"context"
 	"fmt"
 	"testing"
-	"time"
 
 	mesos "github.com/uber/peloton/.gen/mesos/v1"
 	mesosmaster "github.com/uber/peloton/.gen/mesos/v1/master"
@@ -37,7 +36,9 @@ import (
 	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
 	jobmgrcommon "github.com/uber/peloton/pkg/jobmgr/common"
 	goalstatemocks "github.com/uber/peloton/pkg/jobmgr/goalstate/mocks"
+	"github.com/uber/peloton/pkg/jobmgr/logmanager"
 	logmanagermocks "github.com/uber/peloton/pkg/jobmgr/logmanager/mocks"
+	handlerutil "github.com/uber/peloton/pkg/jobmgr/util/handler"
 	"github.com/uber/peloton/pkg/jobmgr/util/job"
 	storemocks "github.com/uber/peloton/pkg/storage/mocks"
 
@@ -93,7 +94,10 @@ func (suite *podHandlerTestSuite) SetupTest() {
 		frameworkInfoStore: suite.frameworkInfoStore,
 		hostMgrClient:      suite.hostmgrClient,
 		logManager:         suite.logmanager,
-		mesosAgentWorkDir:  suite.mesosAgentWorkDir,
+		sandboxLayouts: logmanager.NewSandboxLayoutResolver(
+			nil, suite.mesosAgentWorkDir, nil),
+		sandboxInfo: handlerutil.NewSandboxInfoCache(
+			_frameworkName, suite.frameworkInfoStore, suite.hostmgrClient),
 	}
 }
 
@@ -241,12 +245,7 @@ func (suite *podHandlerTestSuite) TestRefreshPodSuccess() {
 			Return(pbjob.JobType_SERVICE),
 
 		suite.goalStateDriver.EXPECT().
-			JobRuntimeDuration(pbjob.JobType_SERVICE).
-			Return(time.Second),
-
-		suite.goalStateDriver.EXPECT().
-			EnqueueJob(pelotonJobID, gomock.Any()).
-			Return(),
+			EnqueueJobWithDefaultDelay(pelotonJobID, pbjob.JobType_SERVICE),
 	)
 
 	resp, err := suite.handler.RefreshPod(context.Background(),
@@ -429,12 +428,7 @@ func (suite *podHandlerTestSuite) TestStartPodSuccess() {
 			Return(pbjob.JobType_SERVICE),
 
 		suite.goalStateDriver.EXPECT().
-			JobRuntimeDuration(pbjob.JobType_SERVICE).
-			Return(time.Second),
-
-		suite.goalStateDriver.EXPECT().
-			EnqueueJob(&peloton.JobID{Value: testJobID}, gomock.Any()).
-			Return(),
+			EnqueueJobWithDefaultDelay(&peloton.JobID{Value: testJobID}, pbjob.JobType_SERVICE),
 	)
 
 	resp, err := suite.handler.StartPod(context.Background(), &svc.StartPodRequest{
@@ -550,12 +544,7 @@ func (suite *podHandlerTestSuite) TestStartPodSuccessWithJobRuntimeUnexpectedVer
 			Return(pbjob.JobType_SERVICE),
 
 		suite.goalStateDriver.EXPECT().
-			JobRuntimeDuration(pbjob.JobType_SERVICE).
-			Return(time.Second),
-
-		suite.goalStateDriver.EXPECT().
-			EnqueueJob(&peloton.JobID{Value: testJobID}, gomock.Any()).
-			Return(),
+			EnqueueJobWithDefaultDelay(&peloton.JobID{Value: testJobID}, pbjob.JobType_SERVICE),
 	)
 
 	resp, err := suite.handler.StartPod(context.Background(), &svc.StartPodRequest{
@@ -671,12 +660,7 @@ func (suite *podHandlerTestSuite) TestStartPodSuccessWithPodRuntimeUnexpectedVer
 			Return(pbjob.JobType_SERVICE),
 
 		suite.goalStateDriver.EXPECT().
-			JobRuntimeDuration(pbjob.JobType_SERVICE).
-			Return(time.Second),
-
-		suite.goalStateDriver.EXPECT().
-			EnqueueJob(&peloton.JobID{Value: testJobID}, gomock.Any()).
-			Return(),
+			EnqueueJobWithDefaultDelay(&peloton.JobID{Value: testJobID}, pbjob.JobType_SERVICE),
 	)
 
 	resp, err := suite.handler.StartPod(context.Background(), &svc.StartPodRequest{
@@ -732,12 +716,7 @@ func (suite *podHandlerTestSuite) TestStartPodFailToSetJobRuntime() {
 			Return(pbjob.JobType_SERVICE),
 
 		suite.goalStateDriver.EXPECT().
-			JobRuntimeDuration(pbjob.JobType_SERVICE).
-			Return(time.Second),
-
-		suite.goalStateDriver.EXPECT().
-			EnqueueJob(&peloton.JobID{Value: testJobID}, gomock.Any()).
-			Return(),
+			EnqueueJobWithDefaultDelay(&peloton.JobID{Value: testJobID}, pbjob.JobType_SERVICE),
 	)
 
 	resp, err := suite.handler.StartPod(context.Background(), &svc.StartPodRequest{
@@ -1460,6 +1439,32 @@ func (suite *podHandlerTestSuite) TestGetPodEvents() {
 	suite.Equal(events, response.GetEvents())
 }
 
+// TestGetPodEventsLimit tests the oldest pod events of a run beyond
+// the limit are dropped
+func (suite *podHandlerTestSuite) TestGetPodEventsLimit() {
+	suite.handler.pagination.MaxPodEvents = 2
+	request := &svc.GetPodEventsRequest{
+		PodName: &v1alphapeloton.PodName{
+			Value: testPodName,
+		},
+	}
+
+	var events []*pod.PodEvent
+	for _, state := range []string{"RUNNING", "STARTING", "LAUNCHED"} {
+		events = append(events, &pod.PodEvent{
+			PodId:        &v1alphapeloton.PodID{Value: testPodID},
+			ActualState:  state,
+			DesiredState: "RUNNING",
+		})
+	}
+	suite.podStore.EXPECT().
+		GetPodEvents(gomock.Any(), testJobID, uint32(testInstanceID), "").
+		Return(events, nil)
+	response, err := suite.handler.GetPodEvents(context.Background(), request)
+	suite.NoError(err)
+	suite.Equal(events[:2], response.GetEvents())
+}
+
 // TestGetPodEventsPodNameParseError tests PodName parse error
 // while getting pod events for a given pod
 func (suite *podHandlerTestSuite) TestGetPodEventsPodNameParseError() {
@@ -1510,6 +1515,9 @@ func (suite *podHandlerTestSuite) TestBrowsePodSandboxSuccess() {
 			PodId: &v1alphapeloton.PodID{
 				Value: testPodID,
 			},
+			Version: &v1alphapeloton.EntityVersion{
+				Value: "2",
+			},
 			ActualState: pbtask.TaskState_RUNNING.String(),
 			Hostname:    hostname,
 			AgentId:     agentID,
@@ -1526,6 +1534,18 @@ func (suite *podHandlerTestSuite) TestBrowsePodSandboxSuccess() {
 			GetFrameworkID(gomock.Any(), _frameworkName).
 			Return(frameworkID, nil),
 
+		suite.podStore.EXPECT().
+			GetTaskConfig(
+				gomock.Any(),
+				&peloton.JobID{Value: testJobID},
+				uint32(testInstanceID),
+				uint64(2),
+			).Return(&pbtask.TaskConfig{
+			Executor: &mesos.ExecutorInfo{
+				Type: mesos.ExecutorInfo_CUSTOM.Enum(),
+			},
+		}, nil, nil),
+
 		suite.hostmgrClient.EXPECT().
 			GetMesosAgentInfo(
 				gomock.Any(),
@@ -1534,7 +1554,8 @@ func (suite *podHandlerTestSuite) TestBrowsePodSandboxSuccess() {
 
 		suite.logmanager.EXPECT().
 			ListSandboxFilesPaths(
-				suite.mesosAgentWorkDir,
+				(&logmanager.SandboxLayout{WorkDir: suite.mesosAgentWorkDir}).
+					WithExecutorID(util.CustomExecutorIDPrefix+testPodID),
 				frameworkID,
 				agentIP,
 				agentPort,
@@ -1693,7 +1714,8 @@ func (suite *podHandlerTestSuite) TestBrowsePodSandboxListSandboxFilesPathsFailu
 
 		suite.logmanager.EXPECT().
 			ListSandboxFilesPaths(
-				suite.mesosAgentWorkDir,
+				(&logmanager.SandboxLayout{WorkDir: suite.mesosAgentWorkDir}).
+					WithExecutorID(testPodID),
 				frameworkID,
 				gomock.Any(),
 				gomock.Any(),
@@ -1758,7 +1780,8 @@ func (suite *podHandlerTestSuite) TestBrowsePodSandboxGetMesosMasterHostPortFail
 
 		suite.logmanager.EXPECT().
 			ListSandboxFilesPaths(
-				suite.mesosAgentWorkDir,
+				(&logmanager.SandboxLayout{WorkDir: suite.mesosAgentWorkDir}).
+					WithExecutorID(testPodID),
 				frameworkID,
 				agentIP,
 				agentPort,
@@ -21,12 +21,14 @@ import (
 	"github.com/uber/peloton/pkg/common"
 	"github.com/uber/peloton/pkg/common/background"
 	"github.com/uber/peloton/pkg/common/leader"
+	"github.com/uber/peloton/pkg/jobmgr/asyncop"
 	"github.com/uber/peloton/pkg/jobmgr/cached"
 	"github.com/uber/peloton/pkg/jobmgr/goalstate"
-	"github.com/uber/peloton/pkg/jobmgr/task/deadline"
+	"github.com/uber/peloton/pkg/jobmgr/standby"
 	"github.com/uber/peloton/pkg/jobmgr/task/event"
 	"github.com/uber/peloton/pkg/jobmgr/task/placement"
 	"github.com/uber/peloton/pkg/jobmgr/task/preemptor"
+	"github.com/uber/peloton/pkg/jobmgr/watchsvc"
 )
 
 // Server contains all structs necessary to run a jobmgr server.
@@ -42,10 +44,18 @@ type Server struct {
 	jobFactory         cached.JobFactory
 	taskPreemptor      preemptor.Preemptor
 	goalstateDriver    goalstate.Driver
-	deadlineTracker    deadline.Tracker
 	placementProcessor placement.Processor
 	statusUpdate       event.StatusUpdate
 	backgroundManager  background.Manager
+	// taskOperations are the asynchronous operations of the task service
+	taskOperations *asyncop.Tracker
+	// standby keeps the cache warm while not leader, nil if not enabled
+	standby standby.Standby
+	// watchProcessor streams the changes of the pods to the watch clients
+	watchProcessor watchsvc.WatchProcessor
+	// membership of the job managers sharing the goal state, nil if the
+	// goal state is not sharded
+	membership leader.Membership
 }
 
 // NewServer creates a job manager Server instance.
@@ -54,10 +64,13 @@ func NewServer(
 	jobFactory cached.JobFactory,
 	goalstateDriver goalstate.Driver,
 	taskPreemptor preemptor.Preemptor,
-	deadlineTracker deadline.Tracker,
 	placementProcessor placement.Processor,
 	statusUpdate event.StatusUpdate,
 	backgroundManager background.Manager,
+	taskOperations *asyncop.Tracker,
+	standby standby.Standby,
+	watchProcessor watchsvc.WatchProcessor,
+	membership leader.Membership,
 ) *Server {
 	return &Server{
 		ID:                 leader.NewID(httpPort, grpcPort),
@@ -65,19 +78,56 @@ func NewServer(
 		jobFactory:         jobFactory,
 		taskPreemptor:      taskPreemptor,
 		goalstateDriver:    goalstateDriver,
-		deadlineTracker:    deadlineTracker,
 		placementProcessor: placementProcessor,
 		statusUpdate:       statusUpdate,
 		backgroundManager:  backgroundManager,
+		taskOperations:     taskOperations,
+		standby:            standby,
+		watchProcessor:     watchProcessor,
+		membership:         membership,
 	}
 }
 
+// Start starts keeping the cache warm while the job manager is not
+// the leader, if enabled, and joins the job managers sharing the goal
+// state, if sharded. It should be called before leader election is
+// started.
+func (s *Server) Start() {
+	if s.standby != nil {
+		s.standby.Start()
+	}
+	if s.membership != nil {
+		s.startShard()
+		if err := s.membership.Join(); err != nil {
+			log.WithError(err).Error("Failed to join goal state shards")
+		}
+	}
+}
+
+// startShard starts evaluating the goal state of the jobs owned by this
+// job manager while not leader, using the cache kept warm by the standby.
+func (s *Server) startShard() {
+	s.jobFactory.Start()
+	s.goalstateDriver.StartFromCache()
+}
+
 // GainedLeadershipCallback is the callback when the current node
 // becomes the leader
 func (s *Server) GainedLeadershipCallback() error {
 
 	log.WithFields(log.Fields{"role": s.role}).Info("Gained leadership")
 
+	// the revisions of the pod changes streamed by the previous leader
+	// may be ahead of the revision of the watch processor
+	s.watchProcessor.Reset()
+	s.watchProcessor.Start()
+
+	// The goal state of the shard evaluated while not leader is started
+	// again along with the cache promoted, or recovered from DB.
+	if s.membership != nil {
+		s.goalstateDriver.Stop()
+	}
+
 	s.jobFactory.Start()
 
 	// goalstateDriver will perform recovery of jobs from DB as
@@ -86,12 +136,18 @@ func (s *Server) GainedLeadershipCallback() error {
 	// be completed before any other routines start. This ensures
 	// job manager cache has the baseline state of all jobs recovered
 	// from DB before handling any events which can modify this state.
-	s.goalstateDriver.Start()
+	// If the cache has been kept warm while not leader, and is verified
+	// to be consistent with DB, the recovery from DB is skipped.
+	if s.standby != nil && s.standby.Promote() {
+		s.goalstateDriver.StartFromCache()
+	} else {
+		s.goalstateDriver.Start()
+	}
 	s.taskPreemptor.Start()
 	s.placementProcessor.Start()
-	s.deadlineTracker.Start()
 	s.statusUpdate.Start()
 	s.backgroundManager.Start()
+	s.taskOperations.Start()
 
 	return nil
 }
@@ -102,14 +158,22 @@ func (s *Server) LostLeadershipCallback() error {
 
 	log.WithField("role", s.role).Info("Lost leadership")
 
+	s.watchProcessor.Stop()
+	s.taskOperations.Stop()
 	s.statusUpdate.Stop()
 	s.placementProcessor.Stop()
 	s.taskPreemptor.Stop()
-	s.deadlineTracker.Stop()
 	s.backgroundManager.Stop()
 	s.goalstateDriver.Stop()
 	s.jobFactory.Stop()
 
+	if s.standby != nil {
+		s.standby.Start()
+	}
+	if s.membership != nil {
+		s.startShard()
+	}
+
 	return nil
 }
 
@@ -118,10 +182,17 @@ func (s *Server) ShutDownCallback() error {
 
 	log.WithFields(log.Fields{"role": s.role}).Info("Quitting election")
 
+	if s.membership != nil {
+		s.membership.Leave()
+	}
+	if s.standby != nil {
+		s.standby.Stop()
+	}
+	s.watchProcessor.Stop()
+	s.taskOperations.Stop()
 	s.statusUpdate.Stop()
 	s.placementProcessor.Stop()
 	s.taskPreemptor.Stop()
-	s.deadlineTracker.Stop()
 	s.backgroundManager.Stop()
 	s.goalstateDriver.Stop()
 	s.jobFactory.Stop()
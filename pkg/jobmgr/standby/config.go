@@ -0,0 +1,65 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package standby
+
+import "time"
+
+const (
+	_defaultRefreshInterval   = 5 * time.Second
+	_defaultRetryInterval     = 10 * time.Second
+	_defaultVerifyTasksPerJob = 10
+	_defaultMaxStaleJobsRatio = 0.1
+)
+
+// Config for keeping the cache of a non-leader job manager warm.
+type Config struct {
+	// Enabled makes non-leader job manager instances keep their job and
+	// task caches in sync with the leader, so that the bulk recovery from
+	// DB can be skipped on failover. Requires the watch firehose token.
+	Enabled bool `yaml:"enabled"`
+
+	// RefreshInterval is the interval at which the jobs and tasks changed
+	// on the leader are reloaded from DB into the cache.
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+
+	// RetryInterval is the delay before subscribing to the change feed of
+	// the leader again after the subscription failed.
+	RetryInterval time.Duration `yaml:"retry_interval"`
+
+	// VerifyTasksPerJob is the number of tasks per job whose cached
+	// runtime is compared against DB on promotion.
+	VerifyTasksPerJob uint32 `yaml:"verify_tasks_per_job"`
+
+	// MaxStaleJobsRatio is the ratio of jobs found stale on promotion
+	// above which the cache is discarded and the jobs are recovered from
+	// DB instead.
+	MaxStaleJobsRatio float64 `yaml:"max_stale_jobs_ratio"`
+}
+
+// normalize configuration by setting unassigned fields to default values.
+func (c *Config) normalize() {
+	if c.RefreshInterval == 0 {
+		c.RefreshInterval = _defaultRefreshInterval
+	}
+	if c.RetryInterval == 0 {
+		c.RetryInterval = _defaultRetryInterval
+	}
+	if c.VerifyTasksPerJob == 0 {
+		c.VerifyTasksPerJob = _defaultVerifyTasksPerJob
+	}
+	if c.MaxStaleJobsRatio == 0 {
+		c.MaxStaleJobsRatio = _defaultMaxStaleJobsRatio
+	}
+}
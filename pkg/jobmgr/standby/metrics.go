@@ -0,0 +1,58 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package standby
+
+import (
+	"github.com/uber-go/tally"
+)
+
+// Metrics is the struct containing all the counters that track internal
+// state of the warm standby.
+type Metrics struct {
+	PrimeSuccess tally.Counter
+	PrimeFail    tally.Counter
+
+	FeedEvents  tally.Counter
+	FeedFailure tally.Counter
+
+	RefreshSuccess tally.Counter
+	RefreshFail    tally.Counter
+
+	PromoteWarm tally.Counter
+	PromoteCold tally.Counter
+	StaleJobs   tally.Gauge
+}
+
+// NewMetrics returns a new Metrics struct, with all metrics
+// initialized and rooted at the given tally.Scope
+func NewMetrics(scope tally.Scope) *Metrics {
+	successScope := scope.Tagged(map[string]string{"result": "success"})
+	failScope := scope.Tagged(map[string]string{"result": "fail"})
+
+	return &Metrics{
+		PrimeSuccess: successScope.Counter("prime"),
+		PrimeFail:    failScope.Counter("prime"),
+
+		FeedEvents:  scope.Counter("feed_events"),
+		FeedFailure: scope.Counter("feed_failure"),
+
+		RefreshSuccess: successScope.Counter("refresh"),
+		RefreshFail:    failScope.Counter("refresh"),
+
+		PromoteWarm: scope.Counter("promote_warm"),
+		PromoteCold: scope.Counter("promote_cold"),
+		StaleJobs:   scope.Gauge("stale_jobs"),
+	}
+}
@@ -0,0 +1,650 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package standby
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	watchsvc "github.com/uber/peloton/.gen/peloton/api/v1alpha/watch/svc"
+	"github.com/uber/peloton/.gen/peloton/private/models"
+
+	"github.com/uber/peloton/pkg/common"
+	"github.com/uber/peloton/pkg/common/lifecycle"
+	"github.com/uber/peloton/pkg/common/recovery"
+	"github.com/uber/peloton/pkg/common/util"
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+	"github.com/uber/peloton/pkg/jobmgr/goalstate"
+	"github.com/uber/peloton/pkg/storage"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/uber-go/tally"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// _podNameFieldMask limits the pods streamed by the leader to their name,
+// since the changed tasks are reloaded from DB anyway.
+var _podNameFieldMask = []string{"pod_name"}
+
+// Standby keeps the job and task cache of a non-leader job manager in
+// sync with the leader, so that the bulk recovery of jobs and tasks from
+// DB can be skipped when it gains leadership.
+type Standby interface {
+	// Start primes the cache from DB and keeps it in sync with the
+	// changes streamed by the leader.
+	Start()
+	// Stop stops keeping the cache in sync with the leader.
+	Stop()
+	// Promote stops keeping the cache in sync with the leader, and
+	// verifies the cache against DB. It returns true if the goal state
+	// engine can be started from the cache; otherwise the cache is
+	// cleared and the jobs need to be recovered from DB.
+	Promote() bool
+}
+
+// standby implements the Standby interface
+type standby struct {
+	// mutex to access dirty
+	sync.Mutex
+
+	jobStore    storage.JobStore
+	taskStore   storage.TaskStore
+	jobFactory  cached.JobFactory
+	watchClient watchsvc.WatchServiceYARPCClient
+	// goal state driver evaluating the jobs and tasks reloaded into the
+	// cache when the goal state is sharded, nil otherwise
+	goalStateDriver goalstate.Driver
+	// token authenticating against the firehose of the leader
+	firehoseToken string
+	// job states recovered by the goal state engine of the leader
+	jobStates []job.JobState
+	config    *Config
+	scope     tally.Scope
+	metrics   *Metrics
+	lifeCycle lifecycle.LifeCycle
+
+	// warm is set to 1 once the cache has been primed from DB
+	warm int32
+	// instances of each job which have changed on the leader
+	// and are yet to be reloaded from DB
+	dirty map[string]map[uint32]struct{}
+}
+
+// New creates a warm standby for the job and task cache
+func New(
+	d *yarpc.Dispatcher,
+	jobStore storage.JobStore,
+	taskStore storage.TaskStore,
+	jobFactory cached.JobFactory,
+	goalStateDriver goalstate.Driver,
+	jobType job.JobType,
+	firehoseToken string,
+	parent tally.Scope,
+	config Config,
+) Standby {
+	config.normalize()
+	scope := parent.SubScope("standby")
+
+	return &standby{
+		jobStore:   jobStore,
+		taskStore:  taskStore,
+		jobFactory: jobFactory,
+		watchClient: watchsvc.NewWatchServiceYARPCClient(
+			d.ClientConfig(common.PelotonJobManager)),
+		goalStateDriver: goalStateDriver,
+		firehoseToken:   firehoseToken,
+		jobStates:       goalstate.JobStatesToRecover(jobType),
+		config:          &config,
+		scope:           scope,
+		metrics:         NewMetrics(scope),
+		lifeCycle:       lifecycle.NewLifeCycle(),
+		dirty:           make(map[string]map[uint32]struct{}),
+	}
+}
+
+// Start primes the cache and starts following the changes of the leader
+func (s *standby) Start() {
+	if !s.lifeCycle.Start() {
+		return
+	}
+
+	// the cache is expected to be empty, since it is cleared
+	// when the job manager loses leadership
+	atomic.StoreInt32(&s.warm, 0)
+	go s.run(s.lifeCycle.StopCh())
+	log.Info("standby started")
+}
+
+// Stop stops following the changes of the leader
+func (s *standby) Stop() {
+	if !s.lifeCycle.Stop() {
+		return
+	}
+
+	s.lifeCycle.Wait()
+	log.Info("standby stopped")
+}
+
+// Promote stops following the changes of the leader, and verifies the
+// cache against DB.
+func (s *standby) Promote() bool {
+	s.Stop()
+
+	ctx := context.Background()
+	if atomic.LoadInt32(&s.warm) == 0 {
+		log.Info("standby cache is not primed, recovering from DB")
+		s.metrics.PromoteCold.Inc(1)
+		s.clear()
+		return false
+	}
+
+	// reload the changes received before the promotion
+	s.refresh(ctx)
+
+	if err := s.verify(ctx); err != nil {
+		log.WithError(err).
+			Warn("standby cache verification failed, recovering from DB")
+		s.metrics.PromoteCold.Inc(1)
+		s.clear()
+		return false
+	}
+
+	// the cache now belongs to the leader, and is no longer kept in
+	// sync by the standby
+	atomic.StoreInt32(&s.warm, 0)
+
+	log.Info("standby cache verified, skipping recovery from DB")
+	s.metrics.PromoteWarm.Inc(1)
+	return true
+}
+
+// run follows the changes of the leader until stopped,
+// subscribing again if the subscription fails
+func (s *standby) run(stopCh <-chan struct{}) {
+	defer s.lifeCycle.StopComplete()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		err := s.follow(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		s.metrics.FeedFailure.Inc(1)
+		log.WithError(err).
+			Warn("failed to follow job manager leader")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.config.RetryInterval):
+		}
+	}
+}
+
+// follow subscribes to the firehose of the leader, primes the cache from
+// DB, and then periodically reloads the tasks changed on the leader.
+// The cache is primed again on every subscription, since changes may have
+// been missed while not subscribed.
+func (s *standby) follow(ctx context.Context) error {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := s.watchClient.Firehose(
+		streamCtx,
+		&watchsvc.FirehoseRequest{FieldMask: _podNameFieldMask},
+		yarpc.WithHeader("authorization", "Bearer "+s.firehoseToken),
+	)
+	if err != nil {
+		return err
+	}
+
+	// The first response only carries the watch id, and is sent once
+	// the firehose is registered on the leader. Priming starts after it,
+	// so that no change is missed.
+	if _, err := stream.Recv(); err != nil {
+		return err
+	}
+
+	feedErr := make(chan error, 1)
+	go func() {
+		feedErr <- s.readFeed(stream)
+	}()
+
+	if err := s.prime(ctx); err != nil {
+		s.metrics.PrimeFail.Inc(1)
+		return err
+	}
+	s.metrics.PrimeSuccess.Inc(1)
+	atomic.StoreInt32(&s.warm, 1)
+
+	ticker := time.NewTicker(s.config.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-feedErr:
+			return err
+		case <-ticker.C:
+			s.refresh(ctx)
+		}
+	}
+}
+
+// readFeed marks the tasks streamed by the leader as changed
+func (s *standby) readFeed(
+	stream watchsvc.WatchServiceServiceFirehoseYARPCClient,
+) error {
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		for _, p := range resp.GetPods() {
+			jobID, instanceID, err := util.ParseTaskID(
+				p.GetPodName().GetValue())
+			if err != nil {
+				log.WithError(err).
+					WithField("pod_name", p.GetPodName().GetValue()).
+					Warn("failed to parse pod name")
+				continue
+			}
+			s.markDirty(jobID, instanceID)
+		}
+		s.metrics.FeedEvents.Inc(int64(len(resp.GetPods())))
+	}
+}
+
+// prime loads the jobs and tasks which would be recovered by
+// the leader from DB into the cache
+func (s *standby) prime(ctx context.Context) error {
+	startTime := time.Now()
+
+	err := recovery.RecoverJobsByState(
+		ctx,
+		s.scope,
+		s.jobStore,
+		s.jobStates,
+		s.primeTasks,
+		false,
+		false,
+	)
+	if err != nil {
+		return err
+	}
+
+	log.WithField("time_spent", time.Since(startTime)).
+		Info("standby cache primed")
+	return nil
+}
+
+// primeTasks loads a batch of tasks of a job from DB into the cache
+func (s *standby) primeTasks(
+	ctx context.Context,
+	id string,
+	jobConfig *job.JobConfig,
+	configAddOn *models.ConfigAddOn,
+	jobRuntime *job.RuntimeInfo,
+	batch recovery.TasksBatch,
+	errChan chan<- error,
+) {
+	jobID := &peloton.JobID{Value: id}
+
+	cachedJob := s.jobFactory.AddJob(jobID)
+	if err := cachedJob.Update(ctx, &job.JobInfo{
+		Runtime: jobRuntime,
+		Config:  jobConfig,
+	}, configAddOn,
+		cached.UpdateCacheOnly); err != nil {
+		errChan <- err
+		return
+	}
+
+	runtimes, err := s.taskStore.GetTaskRuntimesForJobByRange(
+		ctx,
+		jobID,
+		&task.InstanceRange{
+			From: batch.From,
+			To:   batch.To,
+		})
+	if err != nil {
+		log.WithError(err).
+			WithField("job_id", id).
+			WithField("from", batch.From).
+			WithField("to", batch.To).
+			Error("failed to fetch task runtimes")
+		errChan <- err
+		return
+	}
+
+	if err := cachedJob.ReplaceTasks(runtimes, true); err != nil {
+		errChan <- err
+		return
+	}
+	s.enqueue(jobID, jobRuntime.GetUpdateID(), runtimes)
+}
+
+// markDirty marks a task as changed on the leader
+func (s *standby) markDirty(jobID string, instanceID uint32) {
+	s.Lock()
+	defer s.Unlock()
+
+	instances, ok := s.dirty[jobID]
+	if !ok {
+		instances = make(map[uint32]struct{})
+		s.dirty[jobID] = instances
+	}
+	instances[instanceID] = struct{}{}
+}
+
+// refresh reloads the jobs and tasks changed on the leader from DB.
+// Jobs failing to reload are kept to be reloaded on the next refresh.
+func (s *standby) refresh(ctx context.Context) {
+	s.Lock()
+	dirty := s.dirty
+	s.dirty = make(map[string]map[uint32]struct{})
+	s.Unlock()
+
+	for id, instances := range dirty {
+		if err := s.refreshJob(
+			ctx, &peloton.JobID{Value: id}, instances); err != nil {
+			log.WithError(err).
+				WithField("job_id", id).
+				Warn("failed to refresh job in standby cache")
+			s.metrics.RefreshFail.Inc(1)
+
+			for instanceID := range instances {
+				s.markDirty(id, instanceID)
+			}
+			continue
+		}
+		s.metrics.RefreshSuccess.Inc(1)
+	}
+}
+
+// refreshJob reloads the runtime of a job and of its changed tasks from DB.
+// The job is loaded completely if it is not in the cache yet.
+func (s *standby) refreshJob(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	instances map[uint32]struct{},
+) error {
+	cachedJob := s.jobFactory.GetJob(jobID)
+	if cachedJob == nil {
+		return s.loadJob(ctx, jobID)
+	}
+
+	jobRuntime, err := s.jobStore.GetJobRuntime(ctx, jobID.GetValue())
+	if err != nil {
+		return err
+	}
+
+	// only reload the job config if it has changed
+	jobInfo := &job.JobInfo{Runtime: jobRuntime}
+	var configAddOn *models.ConfigAddOn
+	cachedConfig, err := cachedJob.GetConfig(ctx)
+	if err != nil ||
+		cachedConfig.GetChangeLog().GetVersion() !=
+			jobRuntime.GetConfigurationVersion() {
+		jobInfo.Config, configAddOn, err = s.jobStore.GetJobConfig(
+			ctx, jobID.GetValue())
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := cachedJob.Update(
+		ctx, jobInfo, configAddOn, cached.UpdateCacheOnly); err != nil {
+		return err
+	}
+
+	runtimes := make(map[uint32]*task.RuntimeInfo)
+	for instanceID := range instances {
+		runtime, err := s.taskStore.GetTaskRuntime(ctx, jobID, instanceID)
+		if err != nil {
+			return err
+		}
+		runtimes[instanceID] = runtime
+	}
+	if err := cachedJob.ReplaceTasks(runtimes, true); err != nil {
+		return err
+	}
+	s.enqueue(jobID, jobRuntime.GetUpdateID(), runtimes)
+	return nil
+}
+
+// loadJob loads a job and all its tasks from DB into the cache, replacing
+// any cached state of the job.
+func (s *standby) loadJob(ctx context.Context, jobID *peloton.JobID) error {
+	jobRuntime, err := s.jobStore.GetJobRuntime(ctx, jobID.GetValue())
+	if err != nil {
+		return err
+	}
+
+	// Do not cache jobs which would not be recovered by the leader.
+	if !isRecoverable(jobRuntime) {
+		s.jobFactory.ClearJob(jobID)
+		return nil
+	}
+
+	jobConfig, configAddOn, err := s.jobStore.GetJobConfig(
+		ctx, jobID.GetValue())
+	if err != nil {
+		// the job is partially created and cannot be recovered
+		if yarpcerrors.IsNotFound(err) &&
+			jobRuntime.GetState() == job.JobState_UNINITIALIZED {
+			s.jobFactory.ClearJob(jobID)
+			return nil
+		}
+		return err
+	}
+
+	// drop any stale state of the job before loading it
+	s.jobFactory.ClearJob(jobID)
+	cachedJob := s.jobFactory.AddJob(jobID)
+	if err := cachedJob.Update(ctx, &job.JobInfo{
+		Runtime: jobRuntime,
+		Config:  jobConfig,
+	}, configAddOn,
+		cached.UpdateCacheOnly); err != nil {
+		return err
+	}
+
+	runtimes, err := s.taskStore.GetTaskRuntimesForJobByRange(
+		ctx,
+		jobID,
+		&task.InstanceRange{
+			From: 0,
+			To:   jobConfig.GetInstanceCount(),
+		})
+	if err != nil {
+		return err
+	}
+	if err := cachedJob.ReplaceTasks(runtimes, true); err != nil {
+		return err
+	}
+	s.enqueue(jobID, jobRuntime.GetUpdateID(), runtimes)
+	return nil
+}
+
+// enqueue evaluates the goal state of a job reloaded into the cache,
+// along with its update and its reloaded tasks, when the goal state is
+// sharded across the job managers. Only the jobs owned by this job
+// manager are enqueued by the driver.
+func (s *standby) enqueue(
+	jobID *peloton.JobID,
+	updateID *peloton.UpdateID,
+	runtimes map[uint32]*task.RuntimeInfo,
+) {
+	if s.goalStateDriver == nil {
+		return
+	}
+
+	now := time.Now()
+	s.goalStateDriver.EnqueueJob(jobID, now)
+	for instanceID := range runtimes {
+		s.goalStateDriver.EnqueueTask(jobID, instanceID, now)
+	}
+	if len(updateID.GetValue()) > 0 {
+		s.goalStateDriver.EnqueueUpdate(jobID, updateID, now)
+	}
+}
+
+// verify checks the cache against DB. The runtime of every job to be
+// recovered, and of a sample of its tasks, is compared by revision with
+// DB. Stale jobs are reloaded from DB, and an error is returned if the
+// ratio of stale jobs exceeds the configured limit, since unsampled
+// tasks are likely to be stale as well.
+func (s *standby) verify(ctx context.Context) error {
+	jobIDs, err := s.jobStore.GetJobsByStates(ctx, s.jobStates)
+	if err != nil {
+		return err
+	}
+
+	// drop the cached jobs which the leader would not recover
+	toRecover := make(map[string]struct{})
+	for _, jobID := range jobIDs {
+		toRecover[jobID.GetValue()] = struct{}{}
+	}
+	for id := range s.jobFactory.GetAllJobs() {
+		if _, ok := toRecover[id]; !ok {
+			s.jobFactory.ClearJob(&peloton.JobID{Value: id})
+		}
+	}
+
+	staleJobs := 0
+	for i := range jobIDs {
+		jobID := &jobIDs[i]
+		consistent, err := s.verifyJob(ctx, jobID)
+		if err != nil {
+			return err
+		}
+		if consistent {
+			continue
+		}
+
+		staleJobs++
+		if err := s.loadJob(ctx, jobID); err != nil {
+			return err
+		}
+	}
+
+	s.metrics.StaleJobs.Update(float64(staleJobs))
+	log.WithFields(log.Fields{
+		"total_jobs": len(jobIDs),
+		"stale_jobs": staleJobs,
+	}).Info("standby cache verified")
+
+	if len(jobIDs) > 0 &&
+		float64(staleJobs)/float64(len(jobIDs)) > s.config.MaxStaleJobsRatio {
+		return yarpcerrors.InternalErrorf(
+			"%d of %d jobs are stale in cache", staleJobs, len(jobIDs))
+	}
+	return nil
+}
+
+// verifyJob compares the cached runtime of a job, and of a sample of its
+// tasks, with DB. It returns false if any of them is stale.
+func (s *standby) verifyJob(
+	ctx context.Context,
+	jobID *peloton.JobID,
+) (bool, error) {
+	jobRuntime, err := s.jobStore.GetJobRuntime(ctx, jobID.GetValue())
+	if err != nil {
+		return false, err
+	}
+
+	if !isRecoverable(jobRuntime) {
+		s.jobFactory.ClearJob(jobID)
+		return true, nil
+	}
+
+	cachedJob := s.jobFactory.GetJob(jobID)
+	if cachedJob == nil {
+		return false, nil
+	}
+
+	cachedRuntime, err := cachedJob.GetRuntime(ctx)
+	if err != nil {
+		return false, err
+	}
+	if cachedRuntime.GetRevision().GetVersion() !=
+		jobRuntime.GetRevision().GetVersion() {
+		return false, nil
+	}
+
+	// map iteration order is random, which samples the tasks
+	var verified uint32
+	for instanceID, cachedTask := range cachedJob.GetAllTasks() {
+		if verified >= s.config.VerifyTasksPerJob {
+			break
+		}
+		verified++
+
+		runtime, err := s.taskStore.GetTaskRuntime(ctx, jobID, instanceID)
+		if err != nil {
+			return false, err
+		}
+		cachedTaskRuntime, err := cachedTask.GetRuntime(ctx)
+		if err != nil {
+			return false, err
+		}
+		if cachedTaskRuntime.GetRevision().GetVersion() !=
+			runtime.GetRevision().GetVersion() {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// clear removes all the jobs from the cache, along with the changes
+// yet to be reloaded, so that the cache needs to be primed again
+func (s *standby) clear() {
+	atomic.StoreInt32(&s.warm, 0)
+
+	s.Lock()
+	s.dirty = make(map[string]map[uint32]struct{})
+	s.Unlock()
+
+	for id := range s.jobFactory.GetAllJobs() {
+		s.jobFactory.ClearJob(&peloton.JobID{Value: id})
+	}
+}
+
+// isRecoverable returns true if a job would be recovered by the
+// goal state engine, which skips terminal jobs without an update.
+func isRecoverable(jobRuntime *job.RuntimeInfo) bool {
+	return !util.IsPelotonJobStateTerminal(jobRuntime.GetState()) ||
+		!util.IsPelotonJobStateTerminal(jobRuntime.GetGoalState()) ||
+		len(jobRuntime.GetUpdateID().GetValue()) > 0
+}
@@ -0,0 +1,420 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package standby
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	v1alphapeloton "github.com/uber/peloton/.gen/peloton/api/v1alpha/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
+	watchsvc "github.com/uber/peloton/.gen/peloton/api/v1alpha/watch/svc"
+	watchmocks "github.com/uber/peloton/.gen/peloton/api/v1alpha/watch/svc/mocks"
+	"github.com/uber/peloton/.gen/peloton/private/models"
+
+	"github.com/uber/peloton/pkg/common/lifecycle"
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
+	goalstatemocks "github.com/uber/peloton/pkg/jobmgr/goalstate/mocks"
+	storemocks "github.com/uber/peloton/pkg/storage/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+)
+
+type StandbyTestSuite struct {
+	suite.Suite
+
+	ctrl       *gomock.Controller
+	jobStore   *storemocks.MockJobStore
+	taskStore  *storemocks.MockTaskStore
+	jobFactory *cachedmocks.MockJobFactory
+	cachedJob  *cachedmocks.MockJob
+	cachedTask *cachedmocks.MockTask
+	jobID      *peloton.JobID
+	standby    *standby
+}
+
+func TestStandby(t *testing.T) {
+	suite.Run(t, new(StandbyTestSuite))
+}
+
+func (suite *StandbyTestSuite) SetupTest() {
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.jobStore = storemocks.NewMockJobStore(suite.ctrl)
+	suite.taskStore = storemocks.NewMockTaskStore(suite.ctrl)
+	suite.jobFactory = cachedmocks.NewMockJobFactory(suite.ctrl)
+	suite.cachedJob = cachedmocks.NewMockJob(suite.ctrl)
+	suite.cachedTask = cachedmocks.NewMockTask(suite.ctrl)
+	suite.jobID = &peloton.JobID{Value: uuid.NewRandom().String()}
+
+	config := Config{Enabled: true}
+	config.normalize()
+	suite.standby = &standby{
+		jobStore:   suite.jobStore,
+		taskStore:  suite.taskStore,
+		jobFactory: suite.jobFactory,
+		jobStates:  []job.JobState{job.JobState_RUNNING},
+		config:     &config,
+		scope:      tally.NoopScope,
+		metrics:    NewMetrics(tally.NoopScope),
+		lifeCycle:  lifecycle.NewLifeCycle(),
+		dirty:      make(map[string]map[uint32]struct{}),
+	}
+}
+
+func (suite *StandbyTestSuite) TearDownTest() {
+	suite.ctrl.Finish()
+}
+
+// expectVerifyJob sets up the expectations to verify the cached job, whose
+// runtime has the given revision in DB.
+func (suite *StandbyTestSuite) expectVerifyJob(dbVersion uint64) {
+	suite.jobStore.EXPECT().
+		GetJobsByStates(gomock.Any(), suite.standby.jobStates).
+		Return([]peloton.JobID{*suite.jobID}, nil)
+	suite.jobFactory.EXPECT().
+		GetAllJobs().
+		Return(map[string]cached.Job{suite.jobID.GetValue(): suite.cachedJob})
+	suite.jobStore.EXPECT().
+		GetJobRuntime(gomock.Any(), suite.jobID.GetValue()).
+		Return(&job.RuntimeInfo{
+			State:    job.JobState_RUNNING,
+			Revision: &peloton.ChangeLog{Version: dbVersion},
+		}, nil)
+	suite.jobFactory.EXPECT().
+		GetJob(suite.jobID).
+		Return(suite.cachedJob)
+	suite.cachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(&job.RuntimeInfo{
+			State:    job.JobState_RUNNING,
+			Revision: &peloton.ChangeLog{Version: 3},
+		}, nil)
+}
+
+// expectLoadJob sets up the expectations to load the job from DB.
+func (suite *StandbyTestSuite) expectLoadJob() {
+	suite.jobStore.EXPECT().
+		GetJobRuntime(gomock.Any(), suite.jobID.GetValue()).
+		Return(&job.RuntimeInfo{State: job.JobState_RUNNING}, nil)
+	suite.jobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.jobID.GetValue()).
+		Return(&job.JobConfig{InstanceCount: 2}, &models.ConfigAddOn{}, nil)
+	suite.jobFactory.EXPECT().ClearJob(suite.jobID)
+	suite.jobFactory.EXPECT().
+		AddJob(suite.jobID).
+		Return(suite.cachedJob)
+	suite.cachedJob.EXPECT().
+		Update(gomock.Any(), gomock.Any(), gomock.Any(), cached.UpdateCacheOnly).
+		Return(nil)
+	suite.taskStore.EXPECT().
+		GetTaskRuntimesForJobByRange(
+			gomock.Any(),
+			suite.jobID,
+			&task.InstanceRange{From: 0, To: 2}).
+		Return(map[uint32]*task.RuntimeInfo{0: {}, 1: {}}, nil)
+	suite.cachedJob.EXPECT().
+		ReplaceTasks(gomock.Any(), true).
+		Return(nil)
+}
+
+// TestPromoteNotPrimed tests promoting a standby whose cache has not been
+// primed clears the cache.
+func (suite *StandbyTestSuite) TestPromoteNotPrimed() {
+	suite.jobFactory.EXPECT().
+		GetAllJobs().
+		Return(map[string]cached.Job{suite.jobID.GetValue(): suite.cachedJob})
+	suite.jobFactory.EXPECT().ClearJob(suite.jobID)
+
+	suite.False(suite.standby.Promote())
+}
+
+// TestPromoteVerified tests promoting a standby whose cache is consistent
+// with DB.
+func (suite *StandbyTestSuite) TestPromoteVerified() {
+	suite.standby.warm = 1
+	suite.expectVerifyJob(3)
+	suite.cachedJob.EXPECT().
+		GetAllTasks().
+		Return(map[uint32]cached.Task{0: suite.cachedTask})
+	suite.taskStore.EXPECT().
+		GetTaskRuntime(gomock.Any(), suite.jobID, uint32(0)).
+		Return(&task.RuntimeInfo{
+			Revision: &peloton.ChangeLog{Version: 2},
+		}, nil)
+	suite.cachedTask.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(&task.RuntimeInfo{
+			Revision: &peloton.ChangeLog{Version: 2},
+		}, nil)
+
+	suite.True(suite.standby.Promote())
+	suite.Equal(int32(0), suite.standby.warm)
+}
+
+// TestPromoteStaleTask tests promoting a standby with a stale task
+// reloads the job, and falls back to recovery from DB since too many
+// jobs are stale.
+func (suite *StandbyTestSuite) TestPromoteStaleTask() {
+	suite.standby.warm = 1
+	suite.expectVerifyJob(3)
+	suite.cachedJob.EXPECT().
+		GetAllTasks().
+		Return(map[uint32]cached.Task{0: suite.cachedTask})
+	suite.taskStore.EXPECT().
+		GetTaskRuntime(gomock.Any(), suite.jobID, uint32(0)).
+		Return(&task.RuntimeInfo{
+			Revision: &peloton.ChangeLog{Version: 4},
+		}, nil)
+	suite.cachedTask.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(&task.RuntimeInfo{
+			Revision: &peloton.ChangeLog{Version: 2},
+		}, nil)
+	suite.expectLoadJob()
+
+	suite.jobFactory.EXPECT().
+		GetAllJobs().
+		Return(map[string]cached.Job{suite.jobID.GetValue(): suite.cachedJob})
+	suite.jobFactory.EXPECT().ClearJob(suite.jobID)
+
+	suite.False(suite.standby.Promote())
+	suite.Equal(int32(0), suite.standby.warm)
+	suite.Empty(suite.standby.dirty)
+}
+
+// TestPromoteStaleJobBelowRatio tests promoting a standby with a stale job
+// reloads the job and keeps the cache, if the ratio of stale jobs is
+// within the limit.
+func (suite *StandbyTestSuite) TestPromoteStaleJobBelowRatio() {
+	suite.standby.warm = 1
+	suite.standby.config.MaxStaleJobsRatio = 1
+	suite.expectVerifyJob(4)
+	suite.expectLoadJob()
+
+	suite.True(suite.standby.Promote())
+}
+
+// TestVerifyDropsJobsNotRecovered tests verifying the cache drops the
+// cached jobs which would not be recovered from DB.
+func (suite *StandbyTestSuite) TestVerifyDropsJobsNotRecovered() {
+	suite.jobStore.EXPECT().
+		GetJobsByStates(gomock.Any(), suite.standby.jobStates).
+		Return(nil, nil)
+	suite.jobFactory.EXPECT().
+		GetAllJobs().
+		Return(map[string]cached.Job{suite.jobID.GetValue(): suite.cachedJob})
+	suite.jobFactory.EXPECT().ClearJob(suite.jobID)
+
+	suite.NoError(suite.standby.verify(context.Background()))
+}
+
+// TestVerifyGetJobsError tests verifying the cache fails if the jobs to
+// recover cannot be read from DB.
+func (suite *StandbyTestSuite) TestVerifyGetJobsError() {
+	suite.jobStore.EXPECT().
+		GetJobsByStates(gomock.Any(), suite.standby.jobStates).
+		Return(nil, errors.New("test error"))
+
+	suite.Error(suite.standby.verify(context.Background()))
+}
+
+// TestRefresh tests reloading the changed tasks of a cached job.
+func (suite *StandbyTestSuite) TestRefresh() {
+	cachedConfig := cachedmocks.NewMockJobConfigCache(suite.ctrl)
+	suite.standby.markDirty(suite.jobID.GetValue(), 1)
+
+	suite.jobFactory.EXPECT().
+		GetJob(suite.jobID).
+		Return(suite.cachedJob)
+	suite.jobStore.EXPECT().
+		GetJobRuntime(gomock.Any(), suite.jobID.GetValue()).
+		Return(&job.RuntimeInfo{
+			State:                job.JobState_RUNNING,
+			ConfigurationVersion: 2,
+		}, nil)
+	suite.cachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(cachedConfig, nil)
+	cachedConfig.EXPECT().
+		GetChangeLog().
+		Return(&peloton.ChangeLog{Version: 2})
+	suite.cachedJob.EXPECT().
+		Update(gomock.Any(), gomock.Any(), gomock.Nil(), cached.UpdateCacheOnly).
+		Do(func(
+			_ context.Context,
+			jobInfo *job.JobInfo,
+			_ *models.ConfigAddOn,
+			_ cached.UpdateRequest) {
+			suite.Nil(jobInfo.GetConfig())
+		}).
+		Return(nil)
+	runtime := &task.RuntimeInfo{State: task.TaskState_RUNNING}
+	suite.taskStore.EXPECT().
+		GetTaskRuntime(gomock.Any(), suite.jobID, uint32(1)).
+		Return(runtime, nil)
+	suite.cachedJob.EXPECT().
+		ReplaceTasks(map[uint32]*task.RuntimeInfo{1: runtime}, true).
+		Return(nil)
+
+	suite.standby.refresh(context.Background())
+	suite.Empty(suite.standby.dirty)
+}
+
+// TestRefreshShardedGoalState tests that the reloaded job, along with its
+// update and its reloaded tasks, is evaluated when the goal state is
+// sharded.
+func (suite *StandbyTestSuite) TestRefreshShardedGoalState() {
+	goalStateDriver := goalstatemocks.NewMockDriver(suite.ctrl)
+	suite.standby.goalStateDriver = goalStateDriver
+	suite.standby.markDirty(suite.jobID.GetValue(), 1)
+	updateID := &peloton.UpdateID{Value: uuid.NewRandom().String()}
+
+	suite.jobFactory.EXPECT().
+		GetJob(suite.jobID).
+		Return(suite.cachedJob)
+	suite.jobStore.EXPECT().
+		GetJobRuntime(gomock.Any(), suite.jobID.GetValue()).
+		Return(&job.RuntimeInfo{
+			State:    job.JobState_RUNNING,
+			UpdateID: updateID,
+		}, nil)
+	suite.cachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(nil, errors.New("test error"))
+	suite.jobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.jobID.GetValue()).
+		Return(&job.JobConfig{}, &models.ConfigAddOn{}, nil)
+	suite.cachedJob.EXPECT().
+		Update(gomock.Any(), gomock.Any(), gomock.Any(), cached.UpdateCacheOnly).
+		Return(nil)
+	suite.taskStore.EXPECT().
+		GetTaskRuntime(gomock.Any(), suite.jobID, uint32(1)).
+		Return(&task.RuntimeInfo{State: task.TaskState_RUNNING}, nil)
+	suite.cachedJob.EXPECT().
+		ReplaceTasks(gomock.Any(), true).
+		Return(nil)
+	goalStateDriver.EXPECT().
+		EnqueueJob(suite.jobID, gomock.Any())
+	goalStateDriver.EXPECT().
+		EnqueueTask(suite.jobID, uint32(1), gomock.Any())
+	goalStateDriver.EXPECT().
+		EnqueueUpdate(suite.jobID, updateID, gomock.Any())
+
+	suite.standby.refresh(context.Background())
+	suite.Empty(suite.standby.dirty)
+}
+
+// TestRefreshNewJob tests reloading the changed tasks of a job which is
+// not cached loads the complete job.
+func (suite *StandbyTestSuite) TestRefreshNewJob() {
+	suite.standby.markDirty(suite.jobID.GetValue(), 1)
+
+	suite.jobFactory.EXPECT().
+		GetJob(suite.jobID).
+		Return(nil)
+	suite.expectLoadJob()
+
+	suite.standby.refresh(context.Background())
+	suite.Empty(suite.standby.dirty)
+}
+
+// TestRefreshError tests the tasks which fail to be reloaded are kept to
+// be reloaded on the next refresh.
+func (suite *StandbyTestSuite) TestRefreshError() {
+	suite.standby.markDirty(suite.jobID.GetValue(), 1)
+
+	suite.jobFactory.EXPECT().
+		GetJob(suite.jobID).
+		Return(nil)
+	suite.jobStore.EXPECT().
+		GetJobRuntime(gomock.Any(), suite.jobID.GetValue()).
+		Return(nil, errors.New("test error"))
+
+	suite.standby.refresh(context.Background())
+	suite.Equal(map[string]map[uint32]struct{}{
+		suite.jobID.GetValue(): {1: {}},
+	}, suite.standby.dirty)
+}
+
+// TestLoadTerminalJob tests a terminal job without update is not cached.
+func (suite *StandbyTestSuite) TestLoadTerminalJob() {
+	suite.jobStore.EXPECT().
+		GetJobRuntime(gomock.Any(), suite.jobID.GetValue()).
+		Return(&job.RuntimeInfo{
+			State:     job.JobState_SUCCEEDED,
+			GoalState: job.JobState_SUCCEEDED,
+		}, nil)
+	suite.jobFactory.EXPECT().ClearJob(suite.jobID)
+
+	suite.NoError(suite.standby.loadJob(context.Background(), suite.jobID))
+}
+
+// TestReadFeed tests the pods streamed by the leader are marked as changed.
+func (suite *StandbyTestSuite) TestReadFeed() {
+	stream := watchmocks.NewMockWatchServiceServiceFirehoseYARPCClient(
+		suite.ctrl)
+	testErr := errors.New("test error")
+
+	gomock.InOrder(
+		stream.EXPECT().
+			Recv().
+			Return(&watchsvc.FirehoseResponse{
+				Pods: []*pod.PodSummary{
+					{
+						PodName: &v1alphapeloton.PodName{
+							Value: fmt.Sprintf("%s-%d", suite.jobID.GetValue(), 3),
+						},
+					},
+					{
+						PodName: &v1alphapeloton.PodName{Value: "invalid"},
+					},
+				},
+			}, nil),
+		stream.EXPECT().
+			Recv().
+			Return(nil, testErr),
+	)
+
+	suite.Equal(testErr, suite.standby.readFeed(stream))
+	suite.Equal(map[string]map[uint32]struct{}{
+		suite.jobID.GetValue(): {3: {}},
+	}, suite.standby.dirty)
+}
+
+// TestIsRecoverable tests the jobs recovered by the goal state engine.
+func (suite *StandbyTestSuite) TestIsRecoverable() {
+	suite.True(isRecoverable(&job.RuntimeInfo{
+		State:     job.JobState_RUNNING,
+		GoalState: job.JobState_SUCCEEDED,
+	}))
+	suite.True(isRecoverable(&job.RuntimeInfo{
+		State:     job.JobState_KILLED,
+		GoalState: job.JobState_KILLED,
+		UpdateID:  &peloton.UpdateID{Value: uuid.NewRandom().String()},
+	}))
+	suite.False(isRecoverable(&job.RuntimeInfo{
+		State:     job.JobState_KILLED,
+		GoalState: job.JobState_KILLED,
+	}))
+}
@@ -18,11 +18,15 @@ import (
 	"context"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	pbeventstream "github.com/uber/peloton/.gen/peloton/private/eventstream"
 	"github.com/uber/peloton/.gen/peloton/private/resmgrsvc"
 
 	"github.com/uber/peloton/pkg/common"
+	"github.com/uber/peloton/pkg/common/eventstream"
 	"github.com/uber/peloton/pkg/jobmgr/cached"
 
 	log "github.com/sirupsen/logrus"
@@ -31,21 +35,64 @@ import (
 )
 
 // ActiveRMTasks is the entrypoint object into the cache which store active tasks from ResMgr.
+// The cache is filled with the task events pushed by ResMgr on its event
+// stream whenever a task transitions to a new state, and is resynced with
+// all the tasks of ResMgr on recovery and periodically, as the events sent
+// while the job manager was not the leader are missed.
 type ActiveRMTasks interface {
+	eventstream.EventHandler
+
 	// GetTask returns the task entry for the given taskID
 	GetTask(taskID string) *resmgrsvc.GetActiveTasksResponse_TaskEntry
 
 	// UpdateActiveTasks fills the cache with all tasks from Resmgr
 	UpdateActiveTasks()
+
+	// GetTaskEvents returns the recent task events of ResMgr for the
+	// given taskID, oldest first
+	GetTaskEvents(taskID string) []*task.TaskEvent
+}
+
+const (
+	// _maxTaskEvents is the number of ResMgr task events kept per task
+	_maxTaskEvents = 20
+	// _taskEventsRetention is how long the task events of a task are kept
+	// after its last event
+	_taskEventsRetention = 24 * time.Hour
+	// _taskEventsPruneInterval is how often the expired task events
+	// are removed
+	_taskEventsPruneInterval = time.Hour
+	// _updateActiveTasksTimeout is the timeout of the query of all the
+	// tasks from ResMgr
+	_updateActiveTasksTimeout = 30 * time.Second
+)
+
+// taskEvents are the recent ResMgr task events of a task
+type taskEvents struct {
+	events     []*task.TaskEvent
+	lastUpdate time.Time
 }
 
 // activeTasksCache is the implementation of ActiveTasksCache
 type activeRMTasks struct {
 	sync.RWMutex
+	// updateMutex serializes the updates of the cache with all the tasks
+	updateMutex sync.Mutex
 	// taskCache is the in-memory cache with key: taskID, value: taskEntry
 	taskCache map[string]*resmgrsvc.GetActiveTasksResponse_TaskEntry
+	// updatedTasks are the tasks changed by an event while the cache is
+	// updated with all the tasks, nil if it is not being updated. Their
+	// entry in the cache is newer than the one returned by ResMgr.
+	updatedTasks map[string]struct{}
 	// resmgrClient is the Resource Manager Client
 	resmgrClient resmgrsvc.ResourceManagerServiceYARPCClient
+	// taskEvents is the in-memory history of the task events with
+	// key: taskID, which is kept after the task leaves ResMgr
+	taskEvents map[string]*taskEvents
+	// lastPrune is the time the expired task events were last removed
+	lastPrune time.Time
+	// progress is the offset of the last event processed
+	progress uint64
 	// metrics is the metrics for ActiveRMTasks
 	metrics *Metrics
 }
@@ -59,8 +106,9 @@ func NewActiveRMTasks(
 	return &activeRMTasks{
 		resmgrClient: resmgrsvc.NewResourceManagerServiceYARPCClient(
 			d.ClientConfig(common.PelotonResourceManager)),
-		taskCache: taskCache,
-		metrics:   NewMetrics(parent.SubScope("jobmgr").SubScope("activermtask")),
+		taskCache:  taskCache,
+		taskEvents: make(map[string]*taskEvents),
+		metrics:    NewMetrics(parent.SubScope("jobmgr").SubScope("activermtask")),
 	}
 }
 
@@ -72,12 +120,106 @@ func (cache *activeRMTasks) GetTask(
 	return cache.taskCache[taskID]
 }
 
-// UpdateActiveTasks fills the cache with all tasks from Resmgr
+// GetTaskEvents returns the recent task events of the task with taskID
+func (cache *activeRMTasks) GetTaskEvents(taskID string) []*task.TaskEvent {
+	cache.RLock()
+	defer cache.RUnlock()
+	history, ok := cache.taskEvents[taskID]
+	if !ok {
+		return nil
+	}
+	events := make([]*task.TaskEvent, len(history.events))
+	copy(events, history.events)
+	return events
+}
+
+// OnEvent updates the cache with a task event of ResMgr. The task is
+// removed from the cache once it is not processed by ResMgr anymore.
+func (cache *activeRMTasks) OnEvent(event *pbeventstream.Event) {
+	defer atomic.StoreUint64(&cache.progress, event.GetOffset())
+
+	taskEvent := event.GetPelotonTaskEvent()
+	if event.GetType() != pbeventstream.Event_PELOTON_TASK_EVENT ||
+		taskEvent.GetSource() != task.TaskEvent_SOURCE_RESMGR {
+		cache.metrics.ActiveTaskEventSkip.Inc(1)
+		return
+	}
+	cache.metrics.ActiveTaskEvent.Inc(1)
+
+	taskID := taskEvent.GetTaskId().GetValue()
+
+	cache.Lock()
+	defer cache.Unlock()
+
+	cache.addTaskEvent(taskID, taskEvent)
+	if cache.updatedTasks != nil {
+		cache.updatedTasks[taskID] = struct{}{}
+	}
+
+	if cached.IsResMgrOwnedState(taskEvent.GetState()) {
+		cache.taskCache[taskID] = &resmgrsvc.GetActiveTasksResponse_TaskEntry{
+			TaskID:         taskID,
+			TaskState:      taskEvent.GetState().String(),
+			Reason:         taskEvent.GetReason(),
+			LastUpdateTime: taskEvent.GetTimestamp(),
+		}
+	} else {
+		delete(cache.taskCache, taskID)
+	}
+	cache.metrics.ActiveTasks.Update(float64(len(cache.taskCache)))
+}
+
+// addTaskEvent adds a task event to the history of the task, and removes
+// the expired task events of all the tasks once in a while.
+// It must be called with the lock held.
+func (cache *activeRMTasks) addTaskEvent(taskID string, event *task.TaskEvent) {
+	now := time.Now()
+	if cache.taskEvents == nil {
+		cache.taskEvents = make(map[string]*taskEvents)
+	}
+
+	history, ok := cache.taskEvents[taskID]
+	if !ok {
+		history = &taskEvents{}
+		cache.taskEvents[taskID] = history
+	}
+	history.events = append(history.events, event)
+	if len(history.events) > _maxTaskEvents {
+		history.events = history.events[len(history.events)-_maxTaskEvents:]
+	}
+	history.lastUpdate = now
+
+	if now.Sub(cache.lastPrune) < _taskEventsPruneInterval {
+		return
+	}
+	for id, history := range cache.taskEvents {
+		if now.Sub(history.lastUpdate) > _taskEventsRetention {
+			delete(cache.taskEvents, id)
+		}
+	}
+	cache.lastPrune = now
+}
+
+// UpdateActiveTasks fills the cache with all tasks from Resmgr. The tasks
+// changed by an event while ResMgr is queried keep their entry from the
+// event, as it is newer.
 func (cache *activeRMTasks) UpdateActiveTasks() {
+	cache.updateMutex.Lock()
+	defer cache.updateMutex.Unlock()
+
 	callStart := time.Now()
 
-	taskCache := make(map[string]*resmgrsvc.GetActiveTasksResponse_TaskEntry)
-	ctx, cancelFunc := context.WithTimeout(context.Background(), 30*time.Second)
+	cache.Lock()
+	cache.updatedTasks = make(map[string]struct{})
+	cache.Unlock()
+	defer func() {
+		cache.Lock()
+		cache.updatedTasks = nil
+		cache.Unlock()
+	}()
+
+	ctx, cancelFunc := context.WithTimeout(
+		context.Background(), _updateActiveTasksTimeout)
 	defer cancelFunc()
 
 	// TODO: resmgrsvc.GetActiveTasksRequest.States takes a slice of TaskState instead of string
@@ -89,29 +231,39 @@ func (cache *activeRMTasks) UpdateActiveTasks() {
 			States: states,
 		})
 	if err != nil {
-		// record failed
 		cache.metrics.ActiveTaskQueryFail.Inc(1)
 		log.WithError(err).
 			Info("failed to get active tasks from ResourceManager")
 		return
 	}
 
-	// iterate through the result
+	taskCache := make(map[string]*resmgrsvc.GetActiveTasksResponse_TaskEntry)
 	for _, taskEntries := range rmResp.GetTasksByState() {
 		for _, taskEntry := range taskEntries.GetTaskEntry() {
-			taskID := taskEntry.GetTaskID()
-			taskCache[taskID] = taskEntry
+			taskCache[taskEntry.GetTaskID()] = taskEntry
 		}
 	}
 
-	// update the cache
 	cache.Lock()
 	defer cache.Unlock()
+	for taskID := range cache.updatedTasks {
+		if taskEntry, ok := cache.taskCache[taskID]; ok {
+			taskCache[taskID] = taskEntry
+		} else {
+			delete(taskCache, taskID)
+		}
+	}
 	cache.taskCache = taskCache
 
-	callDuration := time.Since(callStart)
-	cache.metrics.UpdaterRMTasksDuraion.Record(callDuration)
-
-	// record succeed
+	cache.metrics.ActiveTasks.Update(float64(len(cache.taskCache)))
+	cache.metrics.UpdateActiveTasksDuration.Record(time.Since(callStart))
 	cache.metrics.ActiveTaskQuerySuccess.Inc(1)
 }
+
+// OnEvents is the callback function notifying a batch of events
+func (cache *activeRMTasks) OnEvents(events []*pbeventstream.Event) {}
+
+// GetEventProgress returns the offset of the last event processed
+func (cache *activeRMTasks) GetEventProgress() uint64 {
+	return atomic.LoadUint64(&cache.progress)
+}
@@ -15,12 +15,16 @@
 package activermtask
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sort"
 	"testing"
+	"time"
 
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
 	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	pbeventstream "github.com/uber/peloton/.gen/peloton/private/eventstream"
 	"github.com/uber/peloton/.gen/peloton/private/resmgrsvc"
 	resmocks "github.com/uber/peloton/.gen/peloton/private/resmgrsvc/mocks"
 	"github.com/uber/peloton/pkg/jobmgr/cached"
@@ -70,6 +74,53 @@ func (suite *TestActiveRMTasks) TearDownTest() {
 	suite.ctrl.Finish()
 }
 
+// expectGetActiveTasks expects the query of all the tasks from resmgr
+func (suite *TestActiveRMTasks) expectGetActiveTasks() *gomock.Call {
+	states := cached.GetResourceManagerProcessingStates()
+	sort.Strings(states)
+	return suite.mockResmgr.EXPECT().
+		GetActiveTasks(gomock.Any(), &resmgrsvc.GetActiveTasksRequest{
+			States: states,
+		})
+}
+
+// getActiveTasksResponse returns the response of resmgr with the tasks
+func getActiveTasksResponse(taskIDs ...string) *resmgrsvc.GetActiveTasksResponse {
+	var taskEntries []*resmgrsvc.GetActiveTasksResponse_TaskEntry
+	for _, taskID := range taskIDs {
+		taskEntries = append(taskEntries, &resmgrsvc.GetActiveTasksResponse_TaskEntry{
+			TaskID:    taskID,
+			TaskState: task.TaskState_PLACING.String(),
+			Reason:    "REASON_RESMGR",
+		})
+	}
+	return &resmgrsvc.GetActiveTasksResponse{
+		TasksByState: map[string]*resmgrsvc.GetActiveTasksResponse_TaskEntries{
+			task.TaskState_PLACING.String(): {TaskEntry: taskEntries},
+		},
+	}
+}
+
+// createTaskEvent creates an event of the resmgr event stream
+func createTaskEvent(
+	offset uint64,
+	taskID string,
+	state task.TaskState,
+	reason string,
+) *pbeventstream.Event {
+	return &pbeventstream.Event{
+		Offset: offset,
+		Type:   pbeventstream.Event_PELOTON_TASK_EVENT,
+		PelotonTaskEvent: &task.TaskEvent{
+			TaskId:    &peloton.TaskID{Value: taskID},
+			State:     state,
+			Reason:    reason,
+			Source:    task.TaskEvent_SOURCE_RESMGR,
+			Timestamp: "2019-01-01T00:00:00Z",
+		},
+	}
+}
+
 func (suite *TestActiveRMTasks) TestGetActiveTasks() {
 	taskEntry := suite.activeRMTasks.GetTask("TASK_3")
 	assert.NotNil(suite.T(), taskEntry)
@@ -79,43 +130,148 @@ func (suite *TestActiveRMTasks) TestGetActiveTasks() {
 	assert.Nil(suite.T(), taskEntry)
 
 	emptyActiveRMTasks := activeRMTasks{
-		resmgrClient: suite.mockResmgr,
-		metrics:      nil,
-		taskCache:    nil,
+		metrics:   nil,
+		taskCache: nil,
 	}
 	taskEntry = emptyActiveRMTasks.GetTask("TASK_0")
 	assert.Nil(suite.T(), taskEntry)
 }
 
-func (suite *TestActiveRMTasks) TestUpdateActiveTasks() {
+// TestOnEventAddsTask tests that a task in a resmgr owned state
+// is added to the cache
+func (suite *TestActiveRMTasks) TestOnEventAddsTask() {
+	suite.activeRMTasks.OnEvent(
+		createTaskEvent(5, "TASK_RESMGR", task.TaskState_PLACING, "REASON_RESMGR"))
 
-	taskEntries := []*resmgrsvc.GetActiveTasksResponse_TaskEntry{
-		{
-			TaskID: "TASK_RESMGR",
-			Reason: "REASON_RESMGR",
-		},
+	taskEntry := suite.activeRMTasks.GetTask("TASK_RESMGR")
+	suite.NotNil(taskEntry)
+	suite.Equal("TASK_RESMGR", taskEntry.GetTaskID())
+	suite.Equal(task.TaskState_PLACING.String(), taskEntry.GetTaskState())
+	suite.Equal("REASON_RESMGR", taskEntry.GetReason())
+	suite.Equal("2019-01-01T00:00:00Z", taskEntry.GetLastUpdateTime())
+	suite.Equal(uint64(5), suite.activeRMTasks.GetEventProgress())
+
+	// a later transition overwrites the reason
+	suite.activeRMTasks.OnEvent(
+		createTaskEvent(6, "TASK_3", task.TaskState_READY, "REASON_READY"))
+	suite.Equal("REASON_READY", suite.activeRMTasks.GetTask("TASK_3").GetReason())
+	suite.Equal(uint64(6), suite.activeRMTasks.GetEventProgress())
+}
+
+// TestOnEventRemovesTask tests that a task which leaves resmgr
+// is removed from the cache
+func (suite *TestActiveRMTasks) TestOnEventRemovesTask() {
+	suite.activeRMTasks.OnEvent(
+		createTaskEvent(1, "TASK_1", task.TaskState_LAUNCHED, ""))
+	suite.Nil(suite.activeRMTasks.GetTask("TASK_1"))
+
+	suite.activeRMTasks.OnEvent(
+		createTaskEvent(2, "TASK_2", task.TaskState_UNKNOWN, ""))
+	suite.Nil(suite.activeRMTasks.GetTask("TASK_2"))
+	suite.Equal(uint64(2), suite.activeRMTasks.GetEventProgress())
+}
+
+// TestOnEventSkipsOtherEvents tests that events not generated by resmgr
+// are ignored but still acknowledged
+func (suite *TestActiveRMTasks) TestOnEventSkipsOtherEvents() {
+	event := createTaskEvent(3, "TASK_4", task.TaskState_RUNNING, "")
+	event.PelotonTaskEvent.Source = task.TaskEvent_SOURCE_JOBMGR
+	suite.activeRMTasks.OnEvent(event)
+	suite.Equal("REASON_4", suite.activeRMTasks.GetTask("TASK_4").GetReason())
+
+	suite.activeRMTasks.OnEvent(&pbeventstream.Event{
+		Offset: 4,
+		Type:   pbeventstream.Event_MESOS_TASK_STATUS,
+	})
+	suite.Equal(uint64(4), suite.activeRMTasks.GetEventProgress())
+}
+
+// TestOnEventRecordsTaskEvents tests that the task events of resmgr are
+// kept after the task leaves resmgr, up to a maximum number per task
+func (suite *TestActiveRMTasks) TestOnEventRecordsTaskEvents() {
+	suite.Empty(suite.activeRMTasks.GetTaskEvents("TASK_RESMGR"))
+
+	suite.activeRMTasks.OnEvent(
+		createTaskEvent(1, "TASK_RESMGR", task.TaskState_PENDING, ""))
+	suite.activeRMTasks.OnEvent(
+		createTaskEvent(2, "TASK_RESMGR", task.TaskState_PLACED, ""))
+	suite.activeRMTasks.OnEvent(
+		createTaskEvent(3, "TASK_RESMGR", task.TaskState_LAUNCHED, ""))
+	suite.Nil(suite.activeRMTasks.GetTask("TASK_RESMGR"))
+
+	events := suite.activeRMTasks.GetTaskEvents("TASK_RESMGR")
+	suite.Len(events, 3)
+	suite.Equal(task.TaskState_PENDING, events[0].GetState())
+	suite.Equal(task.TaskState_LAUNCHED, events[2].GetState())
+
+	for i := 0; i < _maxTaskEvents; i++ {
+		suite.activeRMTasks.OnEvent(
+			createTaskEvent(uint64(4+i), "TASK_RESMGR", task.TaskState_READY, ""))
 	}
-	states := cached.GetResourceManagerProcessingStates()
-	sort.Strings(states)
-	suite.mockResmgr.EXPECT().
-		GetActiveTasks(gomock.Any(), &resmgrsvc.GetActiveTasksRequest{
-			States: states,
-		}).Return(&resmgrsvc.GetActiveTasksResponse{
-		TasksByState: map[string]*resmgrsvc.GetActiveTasksResponse_TaskEntries{
-			task.TaskState_PLACING.String(): {TaskEntry: taskEntries}},
-	}, nil)
+	events = suite.activeRMTasks.GetTaskEvents("TASK_RESMGR")
+	suite.Len(events, _maxTaskEvents)
+	suite.Equal(task.TaskState_READY, events[0].GetState())
+}
+
+// TestOnEventPrunesTaskEvents tests that the task events of a task are
+// removed once they expire
+func (suite *TestActiveRMTasks) TestOnEventPrunesTaskEvents() {
+	suite.activeRMTasks.OnEvent(
+		createTaskEvent(1, "TASK_OLD", task.TaskState_PENDING, ""))
+	suite.activeRMTasks.taskEvents["TASK_OLD"].lastUpdate =
+		time.Now().Add(-2 * _taskEventsRetention)
+	suite.activeRMTasks.lastPrune = time.Time{}
+
+	suite.activeRMTasks.OnEvent(
+		createTaskEvent(2, "TASK_NEW", task.TaskState_PENDING, ""))
+	suite.Empty(suite.activeRMTasks.GetTaskEvents("TASK_OLD"))
+	suite.Len(suite.activeRMTasks.GetTaskEvents("TASK_NEW"), 1)
+}
+
+// TestUpdateActiveTasks tests that the cache is replaced with all the
+// tasks of resmgr
+func (suite *TestActiveRMTasks) TestUpdateActiveTasks() {
+	suite.expectGetActiveTasks().
+		Return(getActiveTasksResponse("TASK_3", "TASK_RESMGR"), nil)
 
-	suite.activeRMTasks.resmgrClient = suite.mockResmgr
 	suite.activeRMTasks.UpdateActiveTasks()
+	suite.Equal("REASON_RESMGR", suite.activeRMTasks.GetTask("TASK_3").GetReason())
+	suite.Equal(
+		task.TaskState_PLACING.String(),
+		suite.activeRMTasks.GetTask("TASK_RESMGR").GetTaskState())
+	suite.Nil(suite.activeRMTasks.GetTask("TASK_1"))
+	suite.Nil(suite.activeRMTasks.updatedTasks)
 }
 
+// TestUpdateActiveTasksKeepsNewerEvents tests that the tasks changed by an
+// event while resmgr is queried keep their entry from the event
+func (suite *TestActiveRMTasks) TestUpdateActiveTasksKeepsNewerEvents() {
+	suite.expectGetActiveTasks().
+		DoAndReturn(func(
+			_ context.Context,
+			_ *resmgrsvc.GetActiveTasksRequest,
+		) (*resmgrsvc.GetActiveTasksResponse, error) {
+			suite.activeRMTasks.OnEvent(
+				createTaskEvent(1, "TASK_NEW", task.TaskState_READY, "REASON_NEW"))
+			suite.activeRMTasks.OnEvent(
+				createTaskEvent(2, "TASK_RESMGR", task.TaskState_LAUNCHED, ""))
+			return getActiveTasksResponse("TASK_RESMGR", "TASK_OTHER"), nil
+		})
+
+	suite.activeRMTasks.UpdateActiveTasks()
+	suite.Equal("REASON_NEW", suite.activeRMTasks.GetTask("TASK_NEW").GetReason())
+	suite.Nil(suite.activeRMTasks.GetTask("TASK_RESMGR"))
+	suite.NotNil(suite.activeRMTasks.GetTask("TASK_OTHER"))
+	suite.Nil(suite.activeRMTasks.GetTask("TASK_1"))
+}
+
+// TestUpdateActiveTasksError tests that the cache is kept if resmgr
+// cannot be queried
 func (suite *TestActiveRMTasks) TestUpdateActiveTasksError() {
-	states := cached.GetResourceManagerProcessingStates()
-	sort.Strings(states)
-	suite.mockResmgr.EXPECT().
-		GetActiveTasks(gomock.Any(), &resmgrsvc.GetActiveTasksRequest{
-			States: states,
-		}).Return(nil, errors.New("ResMgr Error"))
-	// UpdateActiveTasks will not failed
+	suite.expectGetActiveTasks().
+		Return(nil, errors.New("ResMgr Error"))
+
 	suite.activeRMTasks.UpdateActiveTasks()
+	suite.Equal("REASON_1", suite.activeRMTasks.GetTask("TASK_1").GetReason())
+	suite.Nil(suite.activeRMTasks.updatedTasks)
 }
@@ -20,9 +20,12 @@ import (
 
 // Metrics is the struct containing all the counters and timers that track
 type Metrics struct {
-	ActiveTaskQuerySuccess tally.Counter
-	ActiveTaskQueryFail    tally.Counter
-	UpdaterRMTasksDuraion  tally.Timer
+	ActiveTaskEvent           tally.Counter
+	ActiveTaskEventSkip       tally.Counter
+	ActiveTasks               tally.Gauge
+	ActiveTaskQuerySuccess    tally.Counter
+	ActiveTaskQueryFail       tally.Counter
+	UpdateActiveTasksDuration tally.Timer
 }
 
 // NewMetrics returns a new Metrics struct, with all metrics
@@ -32,9 +35,11 @@ func NewMetrics(scope tally.Scope) *Metrics {
 	activeTaskQueryFailed := scope.Tagged(map[string]string{"result": "fail"})
 
 	return &Metrics{
-		ActiveTaskQuerySuccess: activeTaskQuerySuccess.Counter("active_task_query"),
-		ActiveTaskQueryFail:    activeTaskQueryFailed.Counter("active_task_query"),
-		UpdaterRMTasksDuraion:  scope.Timer("update_rm_tasks_duration"),
+		ActiveTaskEvent:           scope.Counter("active_task_event"),
+		ActiveTaskEventSkip:       scope.Counter("active_task_event_skip"),
+		ActiveTasks:               scope.Gauge("active_tasks"),
+		ActiveTaskQuerySuccess:    activeTaskQuerySuccess.Counter("active_task_query"),
+		ActiveTaskQueryFail:       activeTaskQueryFailed.Counter("active_task_query"),
+		UpdateActiveTasksDuration: scope.Timer("update_rm_tasks_duration"),
 	}
-
 }
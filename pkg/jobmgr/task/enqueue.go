@@ -16,6 +16,7 @@ package task
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/pkg/errors"
@@ -24,10 +25,61 @@ import (
 	"github.com/uber/peloton/.gen/peloton/api/v0/task"
 	"github.com/uber/peloton/.gen/peloton/private/resmgrsvc"
 
+	"github.com/uber/peloton/pkg/common/util"
 	taskutil "github.com/uber/peloton/pkg/common/util/task"
 	jobmgrcommon "github.com/uber/peloton/pkg/jobmgr/common"
 )
 
+// TaskRejectedError is returned by EnqueueGangs when tasks are rejected
+// by the resource pool, e.g. because they request more resources than a
+// single task is allowed to. Enqueuing them again fails the same way,
+// unless the config of the task or of the resource pool changes.
+type TaskRejectedError struct {
+	// Rejected is the reason of the rejection of each rejected task,
+	// keyed by instance id
+	Rejected map[uint32]string
+	// Failed holds the instance ids of all the tasks which were not
+	// enqueued, the rejected tasks and the other tasks of their gangs
+	Failed map[uint32]bool
+}
+
+// Error returns the error message of the rejection.
+func (e *TaskRejectedError) Error() string {
+	return fmt.Sprintf("%d tasks rejected by the resource pool",
+		len(e.Rejected))
+}
+
+// newTaskRejectedError returns the error of the rejected tasks of the
+// failure, or nil if some of the tasks failed to be enqueued for another
+// reason.
+func newTaskRejectedError(
+	failure *resmgrsvc.EnqueueGangsFailure) *TaskRejectedError {
+	e := &TaskRejectedError{
+		Rejected: make(map[uint32]string),
+		Failed:   make(map[uint32]bool),
+	}
+	for _, failed := range failure.GetFailed() {
+		_, instanceID, err := util.ParseTaskID(
+			failed.GetTask().GetId().GetValue())
+		if err != nil {
+			return nil
+		}
+
+		switch failed.GetErrorcode() {
+		case resmgrsvc.EnqueueGangsFailure_ENQUEUE_GANGS_FAILURE_ERROR_CODE_TASK_REJECTED:
+			e.Rejected[instanceID] = failed.GetMessage()
+		case resmgrsvc.EnqueueGangsFailure_ENQUEUE_GANGS_FAILURE_ERROR_CODE_FAILED_DUE_TO_GANG_FAILED:
+		default:
+			return nil
+		}
+		e.Failed[instanceID] = true
+	}
+	if len(e.Rejected) == 0 {
+		return nil
+	}
+	return e
+}
+
 // EnqueueGangs enqueues all tasks organized in gangs to respool in resmgr.
 func EnqueueGangs(
 	ctx context.Context,
@@ -51,6 +103,14 @@ func EnqueueGangs(
 		return err
 	}
 	if response.GetError() != nil {
+		if rejected := newTaskRejectedError(
+			response.GetError().GetFailure()); rejected != nil {
+			log.WithFields(log.Fields{
+				"rejected":   rejected.Rejected,
+				"respool_id": jobConfig.GetRespoolID().GetValue(),
+			}).Info("tasks rejected by resource manager")
+			return rejected
+		}
 		log.WithFields(log.Fields{
 			"resp_error": response.GetError().String(),
 			"request":    request,
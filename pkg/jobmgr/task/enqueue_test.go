@@ -28,6 +28,7 @@ import (
 	"github.com/uber/peloton/.gen/peloton/api/v0/job"
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
 	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	"github.com/uber/peloton/.gen/peloton/private/resmgr"
 	"github.com/uber/peloton/.gen/peloton/private/resmgrsvc"
 
 	res_mocks "github.com/uber/peloton/.gen/peloton/private/resmgrsvc/mocks"
@@ -172,3 +173,69 @@ func (suite *TaskUtilTestSuite) TestEnqueueGangsFailure() {
 		mockResmgrClient)
 	suite.Error(err)
 }
+
+func (suite *TaskUtilTestSuite) TestEnqueueGangsTaskRejected() {
+	ctrl := gomock.NewController(suite.T())
+	defer ctrl.Finish()
+
+	mockResmgrClient := res_mocks.NewMockResourceManagerServiceYARPCClient(ctrl)
+	jobID := "941ff353-ba82-49fe-8f80-fb5bc649b04d"
+	failed := func(
+		instanceID int,
+		code resmgrsvc.EnqueueGangsFailure_ErrorCode,
+	) *resmgrsvc.EnqueueGangsFailure_FailedTask {
+		return &resmgrsvc.EnqueueGangsFailure_FailedTask{
+			Task: &resmgr.Task{
+				Id: &peloton.TaskID{
+					Value: fmt.Sprintf("%s-%d", jobID, instanceID),
+				},
+			},
+			Message:   "task too large",
+			Errorcode: code,
+		}
+	}
+
+	mockResmgrClient.EXPECT().
+		EnqueueGangs(gomock.Any(), gomock.Any()).
+		Return(&resmgrsvc.EnqueueGangsResponse{
+			Error: &resmgrsvc.EnqueueGangsResponse_Error{
+				Failure: &resmgrsvc.EnqueueGangsFailure{
+					Failed: []*resmgrsvc.EnqueueGangsFailure_FailedTask{
+						failed(0, resmgrsvc.EnqueueGangsFailure_ENQUEUE_GANGS_FAILURE_ERROR_CODE_TASK_REJECTED),
+						failed(1, resmgrsvc.EnqueueGangsFailure_ENQUEUE_GANGS_FAILURE_ERROR_CODE_FAILED_DUE_TO_GANG_FAILED),
+					},
+				},
+			},
+		}, nil)
+	err := EnqueueGangs(
+		context.Background(),
+		nil,
+		suite.testJobConfig,
+		mockResmgrClient)
+	rejected, ok := err.(*TaskRejectedError)
+	suite.True(ok)
+	suite.Equal(map[uint32]string{0: "task too large"}, rejected.Rejected)
+	suite.Equal(map[uint32]bool{0: true, 1: true}, rejected.Failed)
+
+	// a task failing for another reason is not a rejection
+	mockResmgrClient.EXPECT().
+		EnqueueGangs(gomock.Any(), gomock.Any()).
+		Return(&resmgrsvc.EnqueueGangsResponse{
+			Error: &resmgrsvc.EnqueueGangsResponse_Error{
+				Failure: &resmgrsvc.EnqueueGangsFailure{
+					Failed: []*resmgrsvc.EnqueueGangsFailure_FailedTask{
+						failed(0, resmgrsvc.EnqueueGangsFailure_ENQUEUE_GANGS_FAILURE_ERROR_CODE_TASK_REJECTED),
+						failed(1, resmgrsvc.EnqueueGangsFailure_ENQUEUE_GANGS_FAILURE_ERROR_CODE_ALREADY_EXIST),
+					},
+				},
+			},
+		}, nil)
+	err = EnqueueGangs(
+		context.Background(),
+		nil,
+		suite.testJobConfig,
+		mockResmgrClient)
+	suite.Error(err)
+	_, ok = err.(*TaskRejectedError)
+	suite.False(ok)
+}
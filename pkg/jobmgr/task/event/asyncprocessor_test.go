@@ -18,7 +18,6 @@ import (
 	"context"
 	"fmt"
 	"testing"
-	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/pborman/uuid"
@@ -105,9 +104,7 @@ func (suite *BucketEventProcessorTestSuite) TestBucketEventProcessor_MesosEvents
 		suite.cachedJob.EXPECT().UpdateResourceUsage(gomock.Any()).Return().Times(3)
 		suite.cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH).Times(3)
 		suite.goalStateDriver.EXPECT().
-			JobRuntimeDuration(job.JobType_BATCH).
-			Return(1 * time.Second).Times(3)
-		suite.goalStateDriver.EXPECT().EnqueueJob(jobID, gomock.Any()).Return().Times(3)
+			EnqueueJobWithDefaultDelay(jobID, job.JobType_BATCH).Times(3)
 	}
 	for i := uint32(0); i < n; i++ {
 		mesosTaskID := fmt.Sprintf("%s-%d-%s", jobID.GetValue(), i, uuidStr)
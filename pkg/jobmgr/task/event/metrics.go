@@ -40,6 +40,10 @@ type Metrics struct {
 
 	TasksReconciledTotal tally.Counter
 
+	// the completed runs whose durations were not recorded because the
+	// queue of the runs to record was full
+	RunDurationsDropped tally.Counter
+
 	// metrics for in-place update/restart success rate
 	TasksInPlacePlacementTotal   tally.Counter
 	TasksInPlacePlacementSuccess tally.Counter
@@ -71,6 +75,7 @@ func NewMetrics(scope tally.Scope) *Metrics {
 		TasksInPlacePlacementSuccess: scope.Counter("tasks_in_place_placement_success"),
 
 		TasksReconciledTotal: scope.Counter("tasks_reconciled_total"),
+		RunDurationsDropped:  scope.Counter("run_durations_dropped"),
 		TasksFailedReason:    newTasksFailedReasonScope(scope),
 	}
 }
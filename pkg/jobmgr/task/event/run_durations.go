@@ -0,0 +1,208 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package event
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	pb_task "github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/uber/peloton/pkg/common/lifecycle"
+	"github.com/uber/peloton/pkg/common/util"
+	"github.com/uber/peloton/pkg/storage"
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// _runDurationQueueSize is the number of completed runs waiting for
+	// their durations to be recorded, beyond which runs are dropped
+	_runDurationQueueSize = 10000
+	// _runDurationWorkers is the number of goroutines recording the
+	// durations of the completed runs
+	_runDurationWorkers = 4
+	// _runDurationTimeout is the timeout of recording the durations of
+	// a completed run
+	_runDurationTimeout = 10 * time.Second
+)
+
+// completedRun is a run of a task which just completed
+type completedRun struct {
+	jobID          *peloton.JobID
+	instanceID     uint32
+	mesosTaskID    string
+	startTime      string
+	completionTime string
+}
+
+// runDurationRecorder persists how long the completed runs of the tasks
+// took to start and how long they ran. The durations are recorded in the
+// background, so that the status updates are not held back by the reads
+// and writes to the DB. The durations are only used for statistics, so
+// the runs completing while the queue is full are dropped.
+type runDurationRecorder struct {
+	taskStore      storage.TaskStore
+	runDurationOps ormobjects.RunDurationOps
+	metrics        *Metrics
+
+	runs      chan *completedRun
+	lifecycle lifecycle.LifeCycle
+	workersWg sync.WaitGroup
+}
+
+// newRunDurationRecorder returns a recorder of the durations of the runs.
+func newRunDurationRecorder(
+	taskStore storage.TaskStore,
+	runDurationOps ormobjects.RunDurationOps,
+	metrics *Metrics) *runDurationRecorder {
+	return &runDurationRecorder{
+		taskStore:      taskStore,
+		runDurationOps: runDurationOps,
+		metrics:        metrics,
+		runs:           make(chan *completedRun, _runDurationQueueSize),
+		lifecycle:      lifecycle.NewLifeCycle(),
+	}
+}
+
+// start starts recording the durations of the completed runs.
+func (r *runDurationRecorder) start() {
+	if !r.lifecycle.Start() {
+		return
+	}
+	stopCh := r.lifecycle.StopCh()
+	for i := 0; i < _runDurationWorkers; i++ {
+		r.workersWg.Add(1)
+		go func() {
+			defer r.workersWg.Done()
+			for {
+				select {
+				case run := <-r.runs:
+					ctx, cancelFunc := context.WithTimeout(
+						context.Background(), _runDurationTimeout)
+					r.record(ctx, run)
+					cancelFunc()
+				case <-stopCh:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// stop stops recording the durations of the completed runs. The runs
+// still queued are dropped.
+func (r *runDurationRecorder) stop() {
+	if r.lifecycle.Stop() {
+		r.workersWg.Wait()
+	}
+}
+
+// enqueue queues the run of the task which just completed for its
+// durations to be recorded, unless the run never started running. The
+// run is dropped if the queue is full.
+func (r *runDurationRecorder) enqueue(
+	taskInfo *pb_task.TaskInfo,
+	completionTime string) {
+	runtime := taskInfo.GetRuntime()
+	if len(runtime.GetStartTime()) == 0 {
+		return
+	}
+
+	run := &completedRun{
+		jobID:          taskInfo.GetJobId(),
+		instanceID:     taskInfo.GetInstanceId(),
+		mesosTaskID:    runtime.GetMesosTaskId().GetValue(),
+		startTime:      runtime.GetStartTime(),
+		completionTime: completionTime,
+	}
+	select {
+	case r.runs <- run:
+	default:
+		r.metrics.RunDurationsDropped.Inc(1)
+	}
+}
+
+// record persists how long the run took to start and how long it ran.
+// Failing to record them is only logged.
+func (r *runDurationRecorder) record(ctx context.Context, run *completedRun) {
+	logFields := log.Fields{
+		"job_id":        run.jobID.GetValue(),
+		"instance_id":   run.instanceID,
+		"mesos_task_id": run.mesosTaskID,
+	}
+
+	startTime, err := time.Parse(time.RFC3339Nano, run.startTime)
+	if err != nil {
+		log.WithError(err).WithFields(logFields).
+			Debug("failed to parse start time of the run")
+		return
+	}
+	endTime, err := time.Parse(time.RFC3339Nano, run.completionTime)
+	if err != nil {
+		log.WithError(err).WithFields(logFields).
+			Debug("failed to parse completion time of the run")
+		return
+	}
+	runID, err := util.ParseRunID(run.mesosTaskID)
+	if err != nil {
+		log.WithError(err).WithFields(logFields).
+			Debug("failed to parse run id")
+		return
+	}
+
+	// the first event of the run is when it was initialized
+	events, err := r.taskStore.GetPodEvents(
+		ctx,
+		run.jobID.GetValue(),
+		run.instanceID,
+		run.mesosTaskID)
+	if err != nil || len(events) == 0 {
+		log.WithError(err).WithFields(logFields).
+			Info("failed to get pod events to record run durations")
+		return
+	}
+	initTime, err := time.Parse(
+		time.RFC3339, events[len(events)-1].GetTimestamp())
+	if err != nil {
+		log.WithError(err).WithFields(logFields).
+			Debug("failed to parse initialization time of the run")
+		return
+	}
+
+	var startLatency time.Duration
+	if startTime.After(initTime) {
+		startLatency = startTime.Sub(initTime)
+	}
+	var runDuration time.Duration
+	if endTime.After(startTime) {
+		runDuration = endTime.Sub(startTime)
+	}
+
+	if err := r.runDurationOps.Create(
+		ctx,
+		run.jobID,
+		run.instanceID,
+		runID,
+		startLatency,
+		runDuration,
+	); err != nil {
+		log.WithError(err).WithFields(logFields).
+			Info("failed to record run durations")
+	}
+}
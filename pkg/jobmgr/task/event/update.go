@@ -20,6 +20,7 @@ import (
 	"time"
 
 	mesos_v1 "github.com/uber/peloton/.gen/mesos/v1"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
 	pb_task "github.com/uber/peloton/.gen/peloton/api/v0/task"
 	"github.com/uber/peloton/.gen/peloton/api/v0/volume"
 	pb_eventstream "github.com/uber/peloton/.gen/peloton/private/eventstream"
@@ -34,6 +35,7 @@ import (
 	jobmgr_task "github.com/uber/peloton/pkg/jobmgr/task"
 	taskutil "github.com/uber/peloton/pkg/jobmgr/util/task"
 	"github.com/uber/peloton/pkg/storage"
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -45,6 +47,9 @@ import (
 const (
 	// Mesos event message that indicates duplicate task ID
 	_msgMesosDuplicateID = "Task has duplicate ID"
+
+	// Default length of the crash-loop window of a task
+	_defaultCrashLoopWindow = 10 * time.Minute
 )
 
 // Declare a Now function so that we can mock it in unit tests.
@@ -69,6 +74,7 @@ type statusUpdate struct {
 	jobStore        storage.JobStore
 	taskStore       storage.TaskStore
 	volumeStore     storage.PersistentVolumeStore
+	runDurations    *runDurationRecorder
 	eventClients    map[string]*eventstream.Client
 	hostmgrClient   hostsvc.InternalHostServiceYARPCClient
 	applier         *asyncEventProcessor
@@ -85,9 +91,11 @@ func NewTaskStatusUpdate(
 	jobStore storage.JobStore,
 	taskStore storage.TaskStore,
 	volumeStore storage.PersistentVolumeStore,
+	ormStore *ormobjects.Store,
 	jobFactory cached.JobFactory,
 	goalStateDriver goalstate.Driver,
 	listeners []Listener,
+	rmEventHandler eventstream.EventHandler,
 	parentScope tally.Scope) StatusUpdate {
 
 	statusUpdater := &statusUpdate{
@@ -102,6 +110,10 @@ func NewTaskStatusUpdate(
 		listeners:       listeners,
 		hostmgrClient:   hostsvc.NewInternalHostServiceYARPCClient(d.ClientConfig(common.PelotonHostManager)),
 	}
+	statusUpdater.runDurations = newRunDurationRecorder(
+		taskStore,
+		ormobjects.NewRunDurationOps(ormStore),
+		statusUpdater.metrics)
 	// TODO: add config for BucketEventProcessor
 	statusUpdater.applier = newBucketEventProcessor(statusUpdater, 100, 10000)
 
@@ -113,11 +125,14 @@ func NewTaskStatusUpdate(
 		parentScope.SubScope("HostmgrEventStreamClient"))
 	statusUpdater.eventClients[common.PelotonHostManager] = eventClient
 
+	// Task events from RM only carry the state and reason of the tasks
+	// while they are processed by RM, so they are not applied to the task
+	// runtime but are consumed by a separate handler.
 	eventClientRM := eventstream.NewEventStreamClient(
 		d,
 		common.PelotonJobManager,
 		common.PelotonResourceManager,
-		statusUpdater,
+		rmEventHandler,
 		parentScope.SubScope("ResmgrEventStreamClient"))
 	statusUpdater.eventClients[common.PelotonResourceManager] = eventClientRM
 	return statusUpdater
@@ -137,6 +152,7 @@ func (p *statusUpdate) GetEventProgress() uint64 {
 // ProcessStatusUpdate processes the actual task status
 func (p *statusUpdate) ProcessStatusUpdate(ctx context.Context, event *pb_eventstream.Event) error {
 	var currTaskResourceUsage map[string]float64
+	var completionTime string
 	updateEvent, err := convertEvent(event)
 	if err != nil {
 		return err
@@ -150,6 +166,14 @@ func (p *statusUpdate) ProcessStatusUpdate(ctx context.Context, event *pb_events
 	}
 	if isOrphanTask {
 		p.metrics.SkipOrphanTasksTotal.Inc(1)
+		// The tasks of a paused job are not killed. The orphan task is
+		// killed when its status is reconciled after the job is resumed.
+		if jobID, _, err := util.ParseTaskID(updateEvent.taskID); err == nil &&
+			p.goalStateDriver.IsPaused(&peloton.JobID{Value: jobID}) {
+			log.WithField("task_id", updateEvent.taskID).
+				Info("skipping kill of orphan task of paused job")
+			return nil
+		}
 		taskInfo := &pb_task.TaskInfo{
 			Runtime: &pb_task.RuntimeInfo{
 				State:       updateEvent.state,
@@ -188,6 +212,13 @@ func (p *statusUpdate) ProcessStatusUpdate(ctx context.Context, event *pb_events
 	// Update FailureCount
 	updateFailureCount(updateEvent.state, taskInfo.GetRuntime(), runtimeDiff)
 
+	// The operation which set the operation context is complete, so the
+	// context is not recorded with the events which follow
+	if taskInfo.GetRuntime().GetOperationContext() != nil &&
+		isOperationComplete(taskInfo.GetRuntime()) {
+		runtimeDiff[jobmgrcommon.OperationContextField] = nil
+	}
+
 	// Persist the reason and message for mesos updates
 	runtimeDiff[jobmgrcommon.MessageField] = updateEvent.statusMsg
 	runtimeDiff[jobmgrcommon.ReasonField] = ""
@@ -206,7 +237,7 @@ func (p *statusUpdate) ProcessStatusUpdate(ctx context.Context, event *pb_events
 		runtimeDiff[jobmgrcommon.StateField] = updateEvent.state
 		runtimeDiff[jobmgrcommon.MessageField] = msg
 		termStatus := &pb_task.TerminationStatus{
-			Reason: pb_task.TerminationStatus_TERMINATION_STATUS_REASON_FAILED,
+			Reason: getTerminationReason(event.GetMesosTaskStatus()),
 		}
 		if code, err := taskutil.GetExitStatusFromMessage(msg); err == nil {
 			termStatus.ExitCode = code
@@ -223,6 +254,8 @@ func (p *statusUpdate) ProcessStatusUpdate(ctx context.Context, event *pb_events
 				Debug("Failed to extract termination signal from message")
 		}
 		runtimeDiff[jobmgrcommon.TerminationStatusField] = termStatus
+		updateCrashLoopFailureCount(
+			taskInfo, event.GetMesosTaskStatus(), runtimeDiff)
 
 	case pb_task.TaskState_LOST:
 		runtimeDiff[jobmgrcommon.ReasonField] = event.GetMesosTaskStatus().GetReason().String()
@@ -275,6 +308,24 @@ func (p *statusUpdate) ProcessStatusUpdate(ctx context.Context, event *pb_events
 			taskInfo.GetRuntime().GetStartTime(),
 			now().UTC().Format(time.RFC3339Nano))
 
+	case pb_task.TaskState_KILLED:
+		runtimeDiff[jobmgrcommon.StateField] = updateEvent.state
+		// Kills requested by Peloton already set the termination status,
+		// otherwise the task was killed by the agent, e.g. by the executor
+		// on health check failures.
+		// Tasks killed for running past their deadline did not complete,
+		// so they are marked FAILED rather than KILLED.
+		if taskInfo.GetRuntime().GetTerminationStatus().GetReason() ==
+			pb_task.TerminationStatus_TERMINATION_STATUS_REASON_DEADLINE_TIMEOUT_EXCEEDED {
+			runtimeDiff[jobmgrcommon.StateField] = pb_task.TaskState_FAILED
+		} else if taskInfo.GetRuntime().GetTerminationStatus() == nil {
+			reason := getTerminationReason(event.GetMesosTaskStatus())
+			if reason != pb_task.TerminationStatus_TERMINATION_STATUS_REASON_FAILED {
+				runtimeDiff[jobmgrcommon.TerminationStatusField] =
+					&pb_task.TerminationStatus{Reason: reason}
+			}
+		}
+
 	default:
 		runtimeDiff[jobmgrcommon.StateField] = updateEvent.state
 	}
@@ -305,7 +356,7 @@ func (p *statusUpdate) ProcessStatusUpdate(ctx context.Context, event *pb_events
 		}
 
 	} else if util.IsPelotonStateTerminal(runtimeDiff[jobmgrcommon.StateField].(pb_task.TaskState)) {
-		completionTime := now().UTC().Format(time.RFC3339Nano)
+		completionTime = now().UTC().Format(time.RFC3339Nano)
 		runtimeDiff[jobmgrcommon.CompletionTimeField] = completionTime
 
 		currTaskResourceUsage = getCurrTaskResourceUsage(
@@ -346,8 +397,8 @@ func (p *statusUpdate) ProcessStatusUpdate(ctx context.Context, event *pb_events
 		taskInfo.GetInstanceId(),
 		time.Now())
 	// Enqueue job to goal state as well
-	goalstate.EnqueueJobWithDefaultDelay(
-		taskInfo.GetJobId(), p.goalStateDriver, cachedJob)
+	p.goalStateDriver.EnqueueJobWithDefaultDelay(
+		taskInfo.GetJobId(), cachedJob.GetJobType())
 
 	// Update job's resource usage with the current task resource usage.
 	// This is a noop in case currTaskResourceUsage is nil
@@ -356,9 +407,24 @@ func (p *statusUpdate) ProcessStatusUpdate(ctx context.Context, event *pb_events
 	// In case of errors in PatchTasks(), ProcessStatusUpdate will be retried
 	// indefinitely until errors are resolved.
 	cachedJob.UpdateResourceUsage(currTaskResourceUsage)
+
+	if len(completionTime) > 0 {
+		p.runDurations.enqueue(taskInfo, completionTime)
+	}
 	return nil
 }
 
+// isOperationComplete returns true if the operation last made on the task
+// is complete: the task was stopped, or the run started by the operation
+// reached RUNNING or a terminal state.
+func isOperationComplete(runtime *pb_task.RuntimeInfo) bool {
+	if runtime.GetGoalState() == pb_task.TaskState_KILLED {
+		return util.IsPelotonStateTerminal(runtime.GetState())
+	}
+	return runtime.GetState() == pb_task.TaskState_RUNNING ||
+		util.IsPelotonStateTerminal(runtime.GetState())
+}
+
 type statusUpateEvent struct {
 	taskID    string
 	state     pb_task.TaskState
@@ -525,6 +591,7 @@ func (p *statusUpdate) OnEvents(events []*pb_eventstream.Event) {}
 
 // Start starts processing status update events
 func (p *statusUpdate) Start() {
+	p.runDurations.start()
 	p.applier.start()
 	for _, client := range p.eventClients {
 		client.Start()
@@ -545,6 +612,7 @@ func (p *statusUpdate) Stop() {
 		listener.Stop()
 	}
 	p.applier.drainAndShutdown()
+	p.runDurations.stop()
 }
 
 func getCurrTaskResourceUsage(taskID string, state pb_task.TaskState,
@@ -591,6 +659,19 @@ func (p *statusUpdate) persistHealthyField(
 	}
 }
 
+// getTerminationReason returns the termination reason of a task which
+// was terminated by the agent rather than stopped by Peloton.
+func getTerminationReason(
+	status *mesos_v1.TaskStatus) pb_task.TerminationStatus_Reason {
+	switch {
+	case status.GetReason() == mesos_v1.TaskStatus_REASON_CONTAINER_LIMITATION_MEMORY:
+		return pb_task.TerminationStatus_TERMINATION_STATUS_REASON_OOM
+	case status.Healthy != nil && !status.GetHealthy():
+		return pb_task.TerminationStatus_TERMINATION_STATUS_REASON_HEALTH_CHECK_FAILED
+	}
+	return pb_task.TerminationStatus_TERMINATION_STATUS_REASON_FAILED
+}
+
 func updateFailureCount(
 	eventState pb_task.TaskState,
 	runtime *pb_task.RuntimeInfo,
@@ -621,6 +702,47 @@ func updateFailureCount(
 	}
 }
 
+// updateCrashLoopFailureCount counts the application failures of the task
+// within the crash-loop window of its restart policy. The window starts
+// again at the first failure after the window has passed.
+func updateCrashLoopFailureCount(
+	taskInfo *pb_task.TaskInfo,
+	status *mesos_v1.TaskStatus,
+	runtimeDiff map[string]interface{}) {
+	policy := taskInfo.GetConfig().GetRestartPolicy()
+	runtime := taskInfo.GetRuntime()
+	if policy.GetMaxCrashLoopFailures() == 0 ||
+		runtime.GetConfigVersion() != runtime.GetDesiredConfigVersion() {
+		return
+	}
+
+	// system failures are retried without counting against the
+	// crash-loop budget of the task
+	if taskutil.IsSystemFailure(&pb_task.RuntimeInfo{
+		Reason:  status.GetReason().String(),
+		Message: status.GetMessage(),
+	}) {
+		return
+	}
+
+	window := _defaultCrashLoopWindow
+	if policy.GetCrashLoopWindowSeconds() > 0 {
+		window = time.Duration(policy.GetCrashLoopWindowSeconds()) * time.Second
+	}
+
+	currentTime := now().UTC()
+	windowStart, err := time.Parse(
+		time.RFC3339Nano, runtime.GetCrashLoopWindowStartTime())
+	if err != nil || currentTime.Sub(windowStart) > window {
+		runtimeDiff[jobmgrcommon.CrashLoopWindowStartTimeField] =
+			currentTime.Format(time.RFC3339Nano)
+		runtimeDiff[jobmgrcommon.CrashLoopFailureCountField] = uint32(1)
+		return
+	}
+	runtimeDiff[jobmgrcommon.CrashLoopFailureCountField] =
+		runtime.GetCrashLoopFailureCount() + 1
+}
+
 // isDuplicateStateUpdate validates if the current instance state is left unchanged
 // by this status update.
 // If it is left unchanged, then the status update should be ignored.
@@ -35,6 +35,7 @@ import (
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
 	"github.com/uber/peloton/.gen/peloton/api/v0/task"
 	"github.com/uber/peloton/.gen/peloton/api/v0/volume"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
 	pb_eventstream "github.com/uber/peloton/.gen/peloton/private/eventstream"
 	"github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc"
 	host_mocks "github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc/mocks"
@@ -45,8 +46,10 @@ import (
 	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
 	goalstatemocks "github.com/uber/peloton/pkg/jobmgr/goalstate/mocks"
 	jobmgrtask "github.com/uber/peloton/pkg/jobmgr/task"
+	"github.com/uber/peloton/pkg/jobmgr/task/activermtask"
 	event_mocks "github.com/uber/peloton/pkg/jobmgr/task/event/mocks"
 	store_mocks "github.com/uber/peloton/pkg/storage/mocks"
+	objectmocks "github.com/uber/peloton/pkg/storage/objects/mocks"
 )
 
 const (
@@ -115,6 +118,8 @@ func (suite *TaskUpdaterTestSuite) SetupTest() {
 		metrics:         NewMetrics(suite.testScope.SubScope("status_updater")),
 		hostmgrClient:   suite.mockHostMgrClient,
 	}
+	suite.updater.runDurations = newRunDurationRecorder(
+		suite.mockTaskStore, nil, suite.updater.metrics)
 	suite.updater.applier = newBucketEventProcessor(suite.updater, 10, 10)
 }
 
@@ -143,9 +148,11 @@ func (suite *TaskUpdaterTestSuite) TestNewTaskStatusUpdate() {
 		suite.mockJobStore,
 		suite.mockTaskStore,
 		suite.mockVolumeStore,
+		nil,
 		suite.jobFactory,
 		suite.goalStateDriver,
 		[]Listener{},
+		activermtask.NewActiveRMTasks(dispatcher, tally.NoopScope),
 		tally.NoopScope,
 	)
 	suite.NotNil(statusUpdater)
@@ -266,9 +273,7 @@ func (suite *TaskUpdaterTestSuite) TestProcessStatusUpdate() {
 		suite.goalStateDriver.EXPECT().EnqueueTask(_pelotonJobID, _instanceID, gomock.Any()).Return(),
 		cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH),
 		suite.goalStateDriver.EXPECT().
-			JobRuntimeDuration(job.JobType_BATCH).
-			Return(1*time.Second),
-		suite.goalStateDriver.EXPECT().EnqueueJob(_pelotonJobID, gomock.Any()).Return(),
+			EnqueueJobWithDefaultDelay(_pelotonJobID, job.JobType_BATCH),
 		cachedJob.EXPECT().UpdateResourceUsage(gomock.Any()).Return(),
 	)
 
@@ -280,6 +285,67 @@ func (suite *TaskUpdaterTestSuite) TestProcessStatusUpdate() {
 		suite.testScope.Snapshot().Counters()["status_updater.tasks_running_total+"].Value())
 }
 
+// TestProcessStatusUpdateOperationContext tests that the operation context
+// of a task is kept until the operation which set it is complete, and is
+// cleared by the status update which follows
+func (suite *TaskUpdaterTestSuite) TestProcessStatusUpdateOperationContext() {
+	defer suite.ctrl.Finish()
+
+	tt := []struct {
+		name         string
+		prevState    task.TaskState
+		eventState   mesos.TaskState
+		clearContext bool
+	}{
+		{
+			name:         "task not started yet",
+			prevState:    task.TaskState_INITIALIZED,
+			eventState:   mesos.TaskState_TASK_RUNNING,
+			clearContext: false,
+		},
+		{
+			name:         "task started by the operation",
+			prevState:    task.TaskState_RUNNING,
+			eventState:   mesos.TaskState_TASK_FINISHED,
+			clearContext: true,
+		},
+	}
+
+	for _, test := range tt {
+		cachedJob := cachedmocks.NewMockJob(suite.ctrl)
+		event := createTestTaskUpdateEvent(test.eventState)
+		taskInfo := createTestTaskInfo(test.prevState)
+		taskInfo.Runtime.OperationContext = &task.OperationContext{
+			IncidentId: "incident",
+		}
+
+		suite.mockTaskStore.EXPECT().
+			GetTaskByID(context.Background(), _pelotonTaskID).
+			Return(taskInfo, nil)
+		suite.jobFactory.EXPECT().AddJob(_pelotonJobID).Return(cachedJob)
+		cachedJob.EXPECT().SetTaskUpdateTime(gomock.Any()).Return()
+		cachedJob.EXPECT().
+			PatchTasks(context.Background(), gomock.Any()).
+			Do(func(_ context.Context,
+				runtimeDiffs map[uint32]jobmgrcommon.RuntimeDiff) {
+				value, ok := runtimeDiffs[_instanceID][jobmgrcommon.OperationContextField]
+				suite.Equal(test.clearContext, ok, test.name)
+				suite.Nil(value, test.name)
+			}).
+			Return(nil)
+		suite.goalStateDriver.EXPECT().
+			EnqueueTask(_pelotonJobID, _instanceID, gomock.Any()).Return()
+		cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
+		suite.goalStateDriver.EXPECT().
+			EnqueueJobWithDefaultDelay(_pelotonJobID, job.JobType_BATCH)
+		cachedJob.EXPECT().UpdateResourceUsage(gomock.Any()).Return()
+
+		suite.NoError(
+			suite.updater.ProcessStatusUpdate(context.Background(), event),
+			test.name)
+	}
+}
+
 // Test case of processing status update for a task going through in-place update
 func (suite *TaskUpdaterTestSuite) TestProcessStatusUpdateInPlaceUpdateTask() {
 	defer suite.ctrl.Finish()
@@ -316,9 +382,7 @@ func (suite *TaskUpdaterTestSuite) TestProcessStatusUpdateInPlaceUpdateTask() {
 		suite.goalStateDriver.EXPECT().EnqueueTask(_pelotonJobID, _instanceID, gomock.Any()).Return(),
 		cachedJob.EXPECT().GetJobType().Return(job.JobType_SERVICE),
 		suite.goalStateDriver.EXPECT().
-			JobRuntimeDuration(job.JobType_SERVICE).
-			Return(1*time.Second),
-		suite.goalStateDriver.EXPECT().EnqueueJob(_pelotonJobID, gomock.Any()).Return(),
+			EnqueueJobWithDefaultDelay(_pelotonJobID, job.JobType_SERVICE),
 		cachedJob.EXPECT().UpdateResourceUsage(gomock.Any()).Return(),
 	)
 
@@ -359,9 +423,7 @@ func (suite *TaskUpdaterTestSuite) TestProcessStatusUpdateInPlaceUpdateTask() {
 		suite.goalStateDriver.EXPECT().EnqueueTask(_pelotonJobID, _instanceID, gomock.Any()).Return(),
 		cachedJob.EXPECT().GetJobType().Return(job.JobType_SERVICE),
 		suite.goalStateDriver.EXPECT().
-			JobRuntimeDuration(job.JobType_SERVICE).
-			Return(1*time.Second),
-		suite.goalStateDriver.EXPECT().EnqueueJob(_pelotonJobID, gomock.Any()).Return(),
+			EnqueueJobWithDefaultDelay(_pelotonJobID, job.JobType_SERVICE),
 		cachedJob.EXPECT().UpdateResourceUsage(gomock.Any()).Return(),
 	)
 
@@ -494,9 +556,7 @@ func (suite *TaskUpdaterTestSuite) TestProcessStatusUpdateHealthy() {
 			suite.goalStateDriver.EXPECT().EnqueueTask(_pelotonJobID, _instanceID, gomock.Any()).Return(),
 			cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH),
 			suite.goalStateDriver.EXPECT().
-				JobRuntimeDuration(job.JobType_BATCH).
-				Return(1*time.Second),
-			suite.goalStateDriver.EXPECT().EnqueueJob(_pelotonJobID, gomock.Any()).Return(),
+				EnqueueJobWithDefaultDelay(_pelotonJobID, job.JobType_BATCH),
 			cachedJob.EXPECT().UpdateResourceUsage(gomock.Any()).Return(),
 		)
 
@@ -715,9 +775,7 @@ func (suite *TaskUpdaterTestSuite) doTestProcessTaskFailedStatusUpdate(
 	cachedJob.EXPECT().UpdateResourceUsage(gomock.Any()).Return()
 	cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
 	suite.goalStateDriver.EXPECT().
-		JobRuntimeDuration(job.JobType_BATCH).
-		Return(1 * time.Second)
-	suite.goalStateDriver.EXPECT().EnqueueJob(_pelotonJobID, gomock.Any()).Return()
+		EnqueueJobWithDefaultDelay(_pelotonJobID, job.JobType_BATCH)
 
 	suite.NoError(suite.updater.ProcessStatusUpdate(context.Background(), event))
 	suite.Equal(
@@ -760,9 +818,7 @@ func (suite *TaskUpdaterTestSuite) TestProcessTaskLostStatusUpdateWithRetry() {
 	cachedJob.EXPECT().UpdateResourceUsage(gomock.Any()).Return()
 	cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
 	suite.goalStateDriver.EXPECT().
-		JobRuntimeDuration(job.JobType_BATCH).
-		Return(1 * time.Second)
-	suite.goalStateDriver.EXPECT().EnqueueJob(_pelotonJobID, gomock.Any()).Return()
+		EnqueueJobWithDefaultDelay(_pelotonJobID, job.JobType_BATCH)
 
 	suite.NoError(suite.updater.ProcessStatusUpdate(context.Background(), event))
 	time.Sleep(_waitTime)
@@ -878,9 +934,7 @@ func (suite *TaskUpdaterTestSuite) TestProcessTaskFailureCountUpdate() {
 		cachedJob.EXPECT().UpdateResourceUsage(gomock.Any()).Return()
 		cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
 		suite.goalStateDriver.EXPECT().
-			JobRuntimeDuration(job.JobType_BATCH).
-			Return(1 * time.Second)
-		suite.goalStateDriver.EXPECT().EnqueueJob(_pelotonJobID, gomock.Any()).Return()
+			EnqueueJobWithDefaultDelay(_pelotonJobID, job.JobType_BATCH)
 
 		suite.NoError(suite.updater.ProcessStatusUpdate(context.Background(), event))
 		time.Sleep(_waitTime)
@@ -924,9 +978,7 @@ func (suite *TaskUpdaterTestSuite) TestProcessTaskLostStatusUpdateNoRetryForStat
 	cachedJob.EXPECT().UpdateResourceUsage(gomock.Any()).Return()
 	cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
 	suite.goalStateDriver.EXPECT().
-		JobRuntimeDuration(job.JobType_BATCH).
-		Return(1 * time.Second)
-	suite.goalStateDriver.EXPECT().EnqueueJob(_pelotonJobID, gomock.Any()).Return()
+		EnqueueJobWithDefaultDelay(_pelotonJobID, job.JobType_BATCH)
 
 	suite.NoError(suite.updater.ProcessStatusUpdate(context.Background(), event))
 	time.Sleep(_waitTime)
@@ -971,9 +1023,7 @@ func (suite *TaskUpdaterTestSuite) TestProcessStoppedTaskLostStatusUpdate() {
 		suite.goalStateDriver.EXPECT().EnqueueTask(_pelotonJobID, _instanceID, gomock.Any()).Return(),
 		cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH),
 		suite.goalStateDriver.EXPECT().
-			JobRuntimeDuration(job.JobType_BATCH).
-			Return(1*time.Second),
-		suite.goalStateDriver.EXPECT().EnqueueJob(_pelotonJobID, gomock.Any()).Return(),
+			EnqueueJobWithDefaultDelay(_pelotonJobID, job.JobType_BATCH),
 		cachedJob.EXPECT().UpdateResourceUsage(gomock.Any()).Return(),
 	)
 
@@ -981,6 +1031,47 @@ func (suite *TaskUpdaterTestSuite) TestProcessStoppedTaskLostStatusUpdate() {
 	time.Sleep(_waitTime)
 }
 
+// Test processing task KILLED status update of a task killed for exceeding
+// its deadline marks the task FAILED.
+func (suite *TaskUpdaterTestSuite) TestProcessDeadlineExceededTaskKilledStatusUpdate() {
+	defer suite.ctrl.Finish()
+
+	cachedJob := cachedmocks.NewMockJob(suite.ctrl)
+	event := createTestTaskUpdateEvent(mesos.TaskState_TASK_KILLED)
+	taskInfo := createTestTaskInfo(task.TaskState_KILLING)
+	taskInfo.Runtime.GoalState = task.TaskState_KILLED
+	taskInfo.Runtime.TerminationStatus = &task.TerminationStatus{
+		Reason: task.TerminationStatus_TERMINATION_STATUS_REASON_DEADLINE_TIMEOUT_EXCEEDED,
+	}
+
+	suite.mockTaskStore.EXPECT().
+		GetTaskByID(context.Background(), _pelotonTaskID).
+		Return(taskInfo, nil)
+	suite.jobFactory.EXPECT().
+		AddJob(_pelotonJobID).Return(cachedJob)
+	cachedJob.EXPECT().
+		SetTaskUpdateTime(gomock.Any()).Return()
+	cachedJob.EXPECT().
+		PatchTasks(context.Background(), gomock.Any()).
+		Do(func(ctx context.Context, runtimeDiffs map[uint32]jobmgrcommon.RuntimeDiff) {
+			runtimeDiff := runtimeDiffs[_instanceID]
+			suite.Equal(
+				task.TaskState_FAILED,
+				runtimeDiff[jobmgrcommon.StateField],
+			)
+			suite.Nil(runtimeDiff[jobmgrcommon.TerminationStatusField])
+		}).
+		Return(nil)
+	suite.goalStateDriver.EXPECT().EnqueueTask(_pelotonJobID, _instanceID, gomock.Any()).Return()
+	cachedJob.EXPECT().UpdateResourceUsage(gomock.Any()).Return()
+	cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
+	suite.goalStateDriver.EXPECT().
+		EnqueueJobWithDefaultDelay(_pelotonJobID, job.JobType_BATCH)
+
+	suite.NoError(suite.updater.ProcessStatusUpdate(context.Background(), event))
+	time.Sleep(_waitTime)
+}
+
 // Test processing task status update failure because of error in resource usage calculation.
 func (suite *TaskUpdaterTestSuite) TestProcessStatusUpdateResourceUsageError() {
 	defer suite.ctrl.Finish()
@@ -1013,9 +1104,7 @@ func (suite *TaskUpdaterTestSuite) TestProcessStatusUpdateResourceUsageError() {
 			suite.goalStateDriver.EXPECT().EnqueueTask(_pelotonJobID, _instanceID, gomock.Any()).Return(),
 			cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH),
 			suite.goalStateDriver.EXPECT().
-				JobRuntimeDuration(job.JobType_BATCH).
-				Return(1*time.Second),
-			suite.goalStateDriver.EXPECT().EnqueueJob(_pelotonJobID, gomock.Any()).Return(),
+				EnqueueJobWithDefaultDelay(_pelotonJobID, job.JobType_BATCH),
 			cachedJob.EXPECT().UpdateResourceUsage(gomock.Any()).Return(),
 		)
 
@@ -1054,9 +1143,7 @@ func (suite *TaskUpdaterTestSuite) TestProcessStatusUpdateResourceUsageNil() {
 		suite.goalStateDriver.EXPECT().EnqueueTask(_pelotonJobID, _instanceID, gomock.Any()).Return(),
 		cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH),
 		suite.goalStateDriver.EXPECT().
-			JobRuntimeDuration(job.JobType_BATCH).
-			Return(1*time.Second),
-		suite.goalStateDriver.EXPECT().EnqueueJob(_pelotonJobID, gomock.Any()).Return(),
+			EnqueueJobWithDefaultDelay(_pelotonJobID, job.JobType_BATCH),
 		cachedJob.EXPECT().UpdateResourceUsage(gomock.Any()).Return(),
 	)
 
@@ -1081,6 +1168,9 @@ func (suite *TaskUpdaterTestSuite) TestProcessOrphanTaskRunningStatusUpdate() {
 	suite.mockTaskStore.EXPECT().
 		GetTaskByID(context.Background(), _pelotonTaskID).
 		Return(taskInfo, nil)
+	suite.goalStateDriver.EXPECT().
+		IsPaused(_pelotonJobID).
+		Return(false)
 	suite.mockHostMgrClient.EXPECT().KillTasks(gomock.Any(), &hostsvc.KillTasksRequest{
 		TaskIds: []*mesos.TaskID{orphanTaskID},
 	}).
@@ -1092,6 +1182,25 @@ func (suite *TaskUpdaterTestSuite) TestProcessOrphanTaskRunningStatusUpdate() {
 	suite.NoError(suite.updater.ProcessStatusUpdate(context.Background(), event))
 }
 
+// Test processing orphan RUNNING task status update of a paused job does
+// not kill the task.
+func (suite *TaskUpdaterTestSuite) TestProcessOrphanTaskOfPausedJob() {
+	defer suite.ctrl.Finish()
+
+	event := createTestTaskUpdateEvent(mesos.TaskState_TASK_RUNNING)
+	taskInfo := createTestTaskInfo(task.TaskState_FAILED)
+	dbMesosTaskID := fmt.Sprintf("%s-%d-%s", _jobID, _instanceID, uuid.NewUUID().String())
+	taskInfo.GetRuntime().MesosTaskId = &mesos.TaskID{Value: &dbMesosTaskID}
+
+	suite.mockTaskStore.EXPECT().
+		GetTaskByID(context.Background(), _pelotonTaskID).
+		Return(taskInfo, nil)
+	suite.goalStateDriver.EXPECT().
+		IsPaused(_pelotonJobID).
+		Return(true)
+	suite.NoError(suite.updater.ProcessStatusUpdate(context.Background(), event))
+}
+
 // Test processing orphan task LOST status update.
 func (suite *TaskUpdaterTestSuite) TestProcessOrphanTaskLostStatusUpdate() {
 	defer suite.ctrl.Finish()
@@ -1106,6 +1215,9 @@ func (suite *TaskUpdaterTestSuite) TestProcessOrphanTaskLostStatusUpdate() {
 	suite.mockTaskStore.EXPECT().
 		GetTaskByID(context.Background(), _pelotonTaskID).
 		Return(taskInfo, nil)
+	suite.goalStateDriver.EXPECT().
+		IsPaused(_pelotonJobID).
+		Return(false)
 	suite.NoError(suite.updater.ProcessStatusUpdate(context.Background(), event))
 }
 
@@ -1128,6 +1240,9 @@ func (suite *TaskUpdaterTestSuite) TestProcessMissingTaskStatusUpdate() {
 	suite.mockTaskStore.EXPECT().
 		GetTaskByID(context.Background(), _pelotonTaskID).
 		Return(nil, yarpcerrors.NotFoundErrorf("task:%s not found", _pelotonTaskID))
+	suite.goalStateDriver.EXPECT().
+		IsPaused(_pelotonJobID).
+		Return(false)
 	suite.mockHostMgrClient.EXPECT().
 		KillTasks(gomock.Any(), gomock.Any()).
 		Return(&hostsvc.KillTasksResponse{}, nil)
@@ -1180,9 +1295,7 @@ func (suite *TaskUpdaterTestSuite) TestProcessStatusUpdateVolumeUponRunning() {
 		suite.goalStateDriver.EXPECT().EnqueueTask(_pelotonJobID, _instanceID, gomock.Any()).Return(),
 		cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH),
 		suite.goalStateDriver.EXPECT().
-			JobRuntimeDuration(job.JobType_BATCH).
-			Return(1*time.Second),
-		suite.goalStateDriver.EXPECT().EnqueueJob(_pelotonJobID, gomock.Any()).Return(),
+			EnqueueJobWithDefaultDelay(_pelotonJobID, job.JobType_BATCH),
 		cachedJob.EXPECT().UpdateResourceUsage(gomock.Any()).Return(),
 	)
 
@@ -1235,9 +1348,7 @@ func (suite *TaskUpdaterTestSuite) TestProcessStatusUpdateSkipVolumeUponRunningI
 		suite.goalStateDriver.EXPECT().EnqueueTask(_pelotonJobID, _instanceID, gomock.Any()).Return(),
 		cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH),
 		suite.goalStateDriver.EXPECT().
-			JobRuntimeDuration(job.JobType_BATCH).
-			Return(1*time.Second),
-		suite.goalStateDriver.EXPECT().EnqueueJob(_pelotonJobID, gomock.Any()).Return(),
+			EnqueueJobWithDefaultDelay(_pelotonJobID, job.JobType_BATCH),
 		cachedJob.EXPECT().UpdateResourceUsage(gomock.Any()).Return(),
 	)
 
@@ -1279,9 +1390,7 @@ func (suite *TaskUpdaterTestSuite) TestProcessFailedTaskRunningStatusUpdate() {
 	cachedJob.EXPECT().UpdateResourceUsage(gomock.Any()).Return()
 	cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
 	suite.goalStateDriver.EXPECT().
-		JobRuntimeDuration(job.JobType_BATCH).
-		Return(1 * time.Second)
-	suite.goalStateDriver.EXPECT().EnqueueJob(_pelotonJobID, gomock.Any()).Return()
+		EnqueueJobWithDefaultDelay(_pelotonJobID, job.JobType_BATCH)
 	suite.NoError(suite.updater.ProcessStatusUpdate(context.Background(), event))
 }
 
@@ -1311,9 +1420,7 @@ func (suite *TaskUpdaterTestSuite) TestProcessLostEventStatusUpdate() {
 		suite.goalStateDriver.EXPECT().EnqueueTask(_pelotonJobID, _instanceID, gomock.Any()).Return(),
 		cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH),
 		suite.goalStateDriver.EXPECT().
-			JobRuntimeDuration(job.JobType_BATCH).
-			Return(1*time.Second),
-		suite.goalStateDriver.EXPECT().EnqueueJob(_pelotonJobID, gomock.Any()).Return(),
+			EnqueueJobWithDefaultDelay(_pelotonJobID, job.JobType_BATCH),
 		cachedJob.EXPECT().UpdateResourceUsage(gomock.Any()).Return(),
 	)
 
@@ -1343,3 +1450,201 @@ func (suite *TaskUpdaterTestSuite) TestUpdaterStartStop() {
 
 	suite.updater.Stop()
 }
+
+// TestRecordRunDurations tests recording in the background how long a
+// completed run took to start and how long it ran
+func (suite *TaskUpdaterTestSuite) TestRecordRunDurations() {
+	defer suite.ctrl.Finish()
+
+	runDurationOps := objectmocks.NewMockRunDurationOps(suite.ctrl)
+	recorder := newRunDurationRecorder(
+		suite.mockTaskStore, runDurationOps, suite.updater.metrics)
+	recorder.start()
+	defer recorder.stop()
+	recorded := make(chan struct{})
+
+	completionTime := nowMock()
+	mesosTaskID := fmt.Sprintf("%s-%d-%d", _jobID, _instanceID, 3)
+	taskInfo := createTestTaskInfo(task.TaskState_RUNNING)
+	taskInfo.Runtime.MesosTaskId = &mesos.TaskID{Value: &mesosTaskID}
+	taskInfo.Runtime.StartTime = completionTime.Add(-10 * time.Minute).
+		Format(time.RFC3339Nano)
+
+	suite.mockTaskStore.EXPECT().
+		GetPodEvents(gomock.Any(), _jobID, _instanceID, mesosTaskID).
+		Return([]*pod.PodEvent{
+			{
+				ActualState: task.TaskState_RUNNING.String(),
+				Timestamp: completionTime.Add(-10 * time.Minute).
+					Format(time.RFC3339),
+			},
+			{
+				ActualState: task.TaskState_INITIALIZED.String(),
+				Timestamp: completionTime.Add(-11 * time.Minute).
+					Format(time.RFC3339),
+			},
+		}, nil)
+
+	runDurationOps.EXPECT().
+		Create(
+			gomock.Any(),
+			_pelotonJobID,
+			_instanceID,
+			uint64(3),
+			gomock.Any(),
+			10*time.Minute,
+		).
+		Do(func(
+			_ context.Context,
+			_ *peloton.JobID,
+			_ uint32,
+			_ uint64,
+			startLatency time.Duration,
+			_ time.Duration) {
+			// pod event timestamps are truncated to the second
+			suite.True(startLatency > 59*time.Second)
+			suite.True(startLatency <= 61*time.Second)
+			close(recorded)
+		}).
+		Return(nil)
+
+	recorder.enqueue(taskInfo, completionTime.Format(time.RFC3339Nano))
+	select {
+	case <-recorded:
+	case <-time.After(5 * time.Second):
+		suite.Fail("run durations not recorded")
+	}
+}
+
+// TestRecordRunDurationsNotStarted tests that no durations are recorded
+// for a run which never started running
+func (suite *TaskUpdaterTestSuite) TestRecordRunDurationsNotStarted() {
+	defer suite.ctrl.Finish()
+
+	recorder := newRunDurationRecorder(
+		suite.mockTaskStore,
+		objectmocks.NewMockRunDurationOps(suite.ctrl),
+		suite.updater.metrics)
+
+	recorder.enqueue(createTestTaskInfo(task.TaskState_LAUNCHED), _currentTime)
+	suite.Empty(recorder.runs)
+}
+
+// TestRecordRunDurationsQueueFull tests that the completed runs are
+// dropped instead of holding back the status updates when the queue of
+// the runs to record is full
+func (suite *TaskUpdaterTestSuite) TestRecordRunDurationsQueueFull() {
+	defer suite.ctrl.Finish()
+
+	recorder := newRunDurationRecorder(
+		suite.mockTaskStore,
+		objectmocks.NewMockRunDurationOps(suite.ctrl),
+		suite.updater.metrics)
+	recorder.runs = make(chan *completedRun, 1)
+
+	taskInfo := createTestTaskInfo(task.TaskState_RUNNING)
+	taskInfo.Runtime.StartTime = _currentTime
+	recorder.enqueue(taskInfo, _currentTime)
+	recorder.enqueue(taskInfo, _currentTime)
+	suite.Len(recorder.runs, 1)
+	suite.Equal(
+		int64(1),
+		suite.testScope.Snapshot().Counters()["status_updater.run_durations_dropped+"].Value())
+}
+
+// TestGetTerminationReason tests the termination reason derived
+// from the mesos status of a task terminated by the agent
+func (suite *TaskUpdaterTestSuite) TestGetTerminationReason() {
+	memoryReason := mesos.TaskStatus_REASON_CONTAINER_LIMITATION_MEMORY
+	commandReason := mesos.TaskStatus_REASON_COMMAND_EXECUTOR_FAILED
+	unhealthy := false
+	healthy := true
+
+	tests := []struct {
+		status *mesos.TaskStatus
+		reason task.TerminationStatus_Reason
+	}{
+		{
+			status: &mesos.TaskStatus{Reason: &memoryReason},
+			reason: task.TerminationStatus_TERMINATION_STATUS_REASON_OOM,
+		},
+		{
+			status: &mesos.TaskStatus{Reason: &commandReason, Healthy: &unhealthy},
+			reason: task.TerminationStatus_TERMINATION_STATUS_REASON_HEALTH_CHECK_FAILED,
+		},
+		{
+			status: &mesos.TaskStatus{Reason: &commandReason, Healthy: &healthy},
+			reason: task.TerminationStatus_TERMINATION_STATUS_REASON_FAILED,
+		},
+		{
+			status: &mesos.TaskStatus{},
+			reason: task.TerminationStatus_TERMINATION_STATUS_REASON_FAILED,
+		},
+	}
+
+	for _, test := range tests {
+		suite.Equal(test.reason, getTerminationReason(test.status))
+	}
+}
+
+// TestUpdateCrashLoopFailureCount tests counting application failures
+// within the crash-loop window of a task
+func (suite *TaskUpdaterTestSuite) TestUpdateCrashLoopFailureCount() {
+	now = nowMock
+	commandReason := mesos.TaskStatus_REASON_COMMAND_EXECUTOR_FAILED
+	launchReason := mesos.TaskStatus_REASON_CONTAINER_LAUNCH_FAILED
+	currentTime := nowMock().UTC()
+
+	newTaskInfo := func(windowStart time.Time, count uint32) *task.TaskInfo {
+		return &task.TaskInfo{
+			Config: &task.TaskConfig{
+				RestartPolicy: &task.RestartPolicy{
+					MaxCrashLoopFailures:   3,
+					CrashLoopWindowSeconds: 60,
+				},
+			},
+			Runtime: &task.RuntimeInfo{
+				CrashLoopFailureCount:    count,
+				CrashLoopWindowStartTime: windowStart.Format(time.RFC3339Nano),
+			},
+		}
+	}
+
+	// failure within the window increments the count
+	runtimeDiff := make(map[string]interface{})
+	updateCrashLoopFailureCount(
+		newTaskInfo(currentTime.Add(-30*time.Second), 2),
+		&mesos.TaskStatus{Reason: &commandReason},
+		runtimeDiff)
+	suite.Equal(uint32(3), runtimeDiff[jobmgrcommon.CrashLoopFailureCountField])
+	suite.Nil(runtimeDiff[jobmgrcommon.CrashLoopWindowStartTimeField])
+
+	// failure after the window starts a new window
+	runtimeDiff = make(map[string]interface{})
+	updateCrashLoopFailureCount(
+		newTaskInfo(currentTime.Add(-2*time.Minute), 2),
+		&mesos.TaskStatus{Reason: &commandReason},
+		runtimeDiff)
+	suite.Equal(uint32(1), runtimeDiff[jobmgrcommon.CrashLoopFailureCountField])
+	suite.Equal(
+		currentTime.Format(time.RFC3339Nano),
+		runtimeDiff[jobmgrcommon.CrashLoopWindowStartTimeField])
+
+	// system failures are not counted
+	runtimeDiff = make(map[string]interface{})
+	updateCrashLoopFailureCount(
+		newTaskInfo(currentTime.Add(-30*time.Second), 2),
+		&mesos.TaskStatus{Reason: &launchReason},
+		runtimeDiff)
+	suite.Empty(runtimeDiff)
+
+	// nothing is counted without a crash-loop budget
+	runtimeDiff = make(map[string]interface{})
+	taskInfo := newTaskInfo(currentTime.Add(-30*time.Second), 2)
+	taskInfo.Config.RestartPolicy.MaxCrashLoopFailures = 0
+	updateCrashLoopFailureCount(
+		taskInfo,
+		&mesos.TaskStatus{Reason: &commandReason},
+		runtimeDiff)
+	suite.Empty(runtimeDiff)
+}
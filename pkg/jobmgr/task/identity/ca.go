@@ -0,0 +1,212 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// CAIssuerName is the name of the issuer signing the identities with
+	// a certificate authority held by the job manager
+	CAIssuerName = "ca"
+
+	// _clockSkew is how long before its issuance a certificate is valid,
+	// to account for the clock skew between the hosts
+	_clockSkew = time.Minute
+)
+
+// caIssuer issues X.509 SVIDs, i.e. certificates with the SPIFFE ID of
+// the task as URI SAN, signed by the CA of the config. The credential
+// holds the certificate, followed by the certificate of the CA and by
+// the private key of the task, unless the public key of the task was
+// requested to be certified.
+type caIssuer struct {
+	cert    *x509.Certificate
+	certPEM []byte
+	key     crypto.Signer
+}
+
+func newCAIssuer(cfg Config) (Issuer, error) {
+	certPEM, err := ioutil.ReadFile(cfg.CA.CertFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read CA certificate")
+	}
+	keyPEM, err := ioutil.ReadFile(cfg.CA.KeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read CA key")
+	}
+	return newCAIssuerFromPEM(certPEM, keyPEM)
+}
+
+func newCAIssuerFromPEM(certPEM []byte, keyPEM []byte) (*caIssuer, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, errors.New("no PEM block in CA certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse CA certificate")
+	}
+
+	block, _ = pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("no PEM block in CA key")
+	}
+	key, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// the certificate of the CA is sent along with the certificate of
+	// the task, so that peers can verify the chain
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	return &caIssuer{
+		cert:    cert,
+		certPEM: caPEM,
+		key:     key,
+	}, nil
+}
+
+// parsePrivateKey parses a PKCS #8, EC or PKCS #1 private key.
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+		return nil, errors.New("unsupported CA key type")
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("failed to parse CA key")
+}
+
+// Issue returns a new certificate for the task, along with a new private
+// key unless the public key of the request is set.
+func (i *caIssuer) Issue(
+	ctx context.Context,
+	req *Request,
+) (*Credential, error) {
+	id, err := url.Parse(req.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid SPIFFE ID")
+	}
+
+	publicKey := req.PublicKey
+	var key *ecdsa.PrivateKey
+	if publicKey == nil {
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to generate key")
+		}
+		publicKey = key.Public()
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate serial number")
+	}
+
+	now := time.Now()
+	notBefore := req.NotBefore
+	if notBefore.IsZero() {
+		notBefore = now.Add(-_clockSkew)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		URIs:         []*url.URL{id},
+		NotBefore:    notBefore,
+		NotAfter:     now.Add(req.TTL),
+		KeyUsage: x509.KeyUsageDigitalSignature |
+			x509.KeyUsageKeyEncipherment |
+			x509.KeyUsageKeyAgreement,
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth,
+			x509.ExtKeyUsageClientAuth,
+		},
+		BasicConstraintsValid: true,
+	}
+	if template.NotAfter.After(i.cert.NotAfter) {
+		template.NotAfter = i.cert.NotAfter
+	}
+
+	der, err := x509.CreateCertificate(
+		rand.Reader, template, i.cert, publicKey, i.key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign certificate")
+	}
+
+	var data bytes.Buffer
+	pem.Encode(&data, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	data.Write(i.certPEM)
+	if key != nil {
+		keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal key")
+		}
+		pem.Encode(&data, &pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	}
+
+	return &Credential{
+		Data:      data.Bytes(),
+		ExpiresAt: template.NotAfter,
+	}, nil
+}
+
+// Verify returns the SPIFFE ID of a certificate chain presented by a task,
+// once its certificate is verified to be signed by the CA and valid at now.
+// The possession of the private key of the certificate must have been
+// proven by the caller.
+func (i *caIssuer) Verify(
+	chain []*x509.Certificate,
+	now time.Time,
+) (string, error) {
+	if len(chain) == 0 {
+		return "", errors.New("no certificate")
+	}
+	cert := chain[0]
+
+	roots := x509.NewCertPool()
+	roots.AddCert(i.cert)
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:       roots,
+		CurrentTime: now,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return "", errors.Wrap(err, "invalid certificate")
+	}
+
+	if len(cert.URIs) != 1 {
+		return "", errors.New("certificate has no SPIFFE ID")
+	}
+	return cert.URIs[0].String(), nil
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"time"
+)
+
+const (
+	_defaultIssuer        = CAIssuerName
+	_defaultTrustDomain   = "peloton"
+	_defaultTTL           = 1 * time.Hour
+	_defaultContainerPath = "/run/peloton/identity/svid.pem"
+	_defaultTimeout       = 5 * time.Second
+	_defaultMaxLifetime   = 7 * 24 * time.Hour
+)
+
+// Config is the config of the identities issued to the tasks when they
+// are launched.
+type Config struct {
+	// Enabled turns on the issuance of the identities
+	Enabled bool `yaml:"enabled"`
+
+	// Issuer is the name of the issuer of the identities, see
+	// RegisterIssuer. Defaults to the CA issuer.
+	Issuer string `yaml:"issuer"`
+
+	// TrustDomain is the trust domain of the SPIFFE IDs of the tasks
+	TrustDomain string `yaml:"trust_domain"`
+
+	// TTL is how long an identity is valid for. The executor is expected
+	// to rotate the identity of a task before it expires, by renewing it
+	// at the renew URL.
+	TTL time.Duration `yaml:"ttl"`
+
+	// RenewURL is the HTTPS URL of the job manager endpoint renewing the
+	// identities on the renew port, see RenewPath, exported to the tasks
+	// so that their executor can rotate their identity. It should route
+	// to the leader.
+	RenewURL string `yaml:"renew_url"`
+
+	// RenewPort is the port serving the endpoint renewing the identities,
+	// which is not served if zero. It is served over TLS with the
+	// certificate of the job manager, so TLS must be enabled, and the
+	// executors authenticate with the current identity of their task.
+	RenewPort int `yaml:"renew_port"`
+
+	// MaxLifetime is how long after the first identity is issued to a
+	// task its identity can be renewed for. The task must be restarted
+	// to get a new identity once it is reached.
+	MaxLifetime time.Duration `yaml:"max_lifetime"`
+
+	// ContainerPath is the path in the container of the task of the file
+	// holding its identity
+	ContainerPath string `yaml:"container_path"`
+
+	// Timeout is the timeout of the issuance of an identity
+	Timeout time.Duration `yaml:"timeout"`
+
+	// CA is the config of the CA issuer
+	CA CAConfig `yaml:"ca"`
+}
+
+// CAConfig is the config of the issuer signing the identities with a
+// certificate authority held by the job manager.
+type CAConfig struct {
+	// CertFile is the PEM file of the certificate of the CA
+	CertFile string `yaml:"cert_file"`
+
+	// KeyFile is the PEM file of the private key of the CA
+	KeyFile string `yaml:"key_file"`
+}
+
+func (c *Config) normalize() {
+	if c.Issuer == "" {
+		c.Issuer = _defaultIssuer
+	}
+	if c.TrustDomain == "" {
+		c.TrustDomain = _defaultTrustDomain
+	}
+	if c.TTL <= 0 {
+		c.TTL = _defaultTTL
+	}
+	if c.ContainerPath == "" {
+		c.ContainerPath = _defaultContainerPath
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = _defaultTimeout
+	}
+	if c.MaxLifetime <= 0 {
+		c.MaxLifetime = _defaultMaxLifetime
+	}
+}
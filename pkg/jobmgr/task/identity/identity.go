@@ -0,0 +1,330 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mesos "github.com/uber/peloton/.gen/mesos/v1"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/uber/peloton/pkg/common/util"
+
+	"github.com/pkg/errors"
+	"github.com/uber-go/tally"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const (
+	// IDEnvName is the environment variable holding the SPIFFE ID of
+	// the task
+	IDEnvName = "PELOTON_IDENTITY_ID"
+
+	// PathEnvName is the environment variable holding the path of the
+	// file holding the identity of the task
+	PathEnvName = "PELOTON_IDENTITY_PATH"
+
+	// ExpiresAtEnvName is the environment variable holding the time the
+	// identity of the task expires at, in RFC3339 form
+	ExpiresAtEnvName = "PELOTON_IDENTITY_EXPIRES_AT"
+
+	// RenewURLEnvName is the environment variable holding the URL at
+	// which the executor renews the identity of the task, if configured
+	RenewURLEnvName = "PELOTON_IDENTITY_RENEW_URL"
+)
+
+// Request is the request for the identity of a task.
+type Request struct {
+	// ID is the SPIFFE ID of the task
+	ID         string
+	JobID      string
+	InstanceID uint32
+	// TTL is how long the identity is valid for
+	TTL time.Duration
+	// PublicKey is the public key of the task to certify, if the task
+	// holds its private key, e.g. when its identity is renewed. Otherwise
+	// a new private key is issued along with the identity.
+	PublicKey crypto.PublicKey
+	// NotBefore is the time the identity is valid from, now if zero. The
+	// renewed identities keep the time of the first identity issued to
+	// the task, which bounds how long its identity can be renewed for.
+	NotBefore time.Time
+}
+
+// Credential is the identity issued to a task, delivered to the sandbox
+// of the task as a file.
+type Credential struct {
+	// Data is the content of the file, e.g. the PEM encoded certificate
+	// and private key of the task, or a token. It holds no private key
+	// if the public key of the task was requested to be certified.
+	Data      []byte
+	ExpiresAt time.Time
+}
+
+// Issuer issues the identities of the tasks.
+type Issuer interface {
+	// Issue returns a new identity for the task of the request.
+	Issue(ctx context.Context, req *Request) (*Credential, error)
+}
+
+// Verifier is implemented by the issuers whose identities can be renewed,
+// which certify the public key of the request if set.
+type Verifier interface {
+	// Verify returns the SPIFFE ID of the certificate chain presented by
+	// a task, whose possession of the private key of the certificate has
+	// been proven, e.g. by a TLS handshake. It returns an error if the
+	// certificate was not issued by the issuer or is not valid at now.
+	Verify(chain []*x509.Certificate, now time.Time) (string, error)
+}
+
+// IssuerFactory creates an issuer from the config.
+type IssuerFactory func(cfg Config) (Issuer, error)
+
+var (
+	issuersLock sync.Mutex
+	issuers     = map[string]IssuerFactory{
+		CAIssuerName: newCAIssuer,
+	}
+)
+
+// RegisterIssuer registers the factory of an issuer, so that it can be
+// selected by name in the config.
+func RegisterIssuer(name string, factory IssuerFactory) {
+	issuersLock.Lock()
+	defer issuersLock.Unlock()
+	issuers[name] = factory
+}
+
+// NewIssuer creates the issuer selected by the config.
+func NewIssuer(cfg Config) (Issuer, error) {
+	cfg.normalize()
+
+	issuersLock.Lock()
+	factory, ok := issuers[cfg.Issuer]
+	issuersLock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown identity issuer %q", cfg.Issuer)
+	}
+	return factory(cfg)
+}
+
+// SpiffeID returns the SPIFFE ID of an instance of a job.
+func SpiffeID(trustDomain string, jobID string, instanceID uint32) string {
+	return fmt.Sprintf("spiffe://%s/job/%s/instance/%d",
+		trustDomain, jobID, instanceID)
+}
+
+// ParseSpiffeID returns the job and the instance of a SPIFFE ID returned
+// by SpiffeID for the trust domain.
+func ParseSpiffeID(
+	trustDomain string,
+	id string,
+) (jobID string, instanceID uint32, err error) {
+	prefix := fmt.Sprintf("spiffe://%s/job/", trustDomain)
+	parts := strings.Split(strings.TrimPrefix(id, prefix), "/")
+	if !strings.HasPrefix(id, prefix) ||
+		len(parts) != 3 ||
+		len(parts[0]) == 0 ||
+		parts[1] != "instance" {
+		return "", 0, fmt.Errorf("invalid SPIFFE ID %q", id)
+	}
+	instance, err := strconv.ParseUint(parts[2], 10, 32)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid SPIFFE ID %q", id)
+	}
+	return parts[0], uint32(instance), nil
+}
+
+// Provider issues the identity of the tasks when they are launched, and
+// adds it to their config.
+type Provider struct {
+	cfg     Config
+	issuer  Issuer
+	metrics *Metrics
+}
+
+// NewProvider returns a provider of the identities issued by the issuer.
+func NewProvider(cfg Config, issuer Issuer, parent tally.Scope) *Provider {
+	cfg.normalize()
+	return &Provider{
+		cfg:     cfg,
+		issuer:  issuer,
+		metrics: NewMetrics(parent.SubScope("identity")),
+	}
+}
+
+// Populate issues the identity of an instance of a job and adds it to
+// the task config, as a secret volume and environment variables telling
+// the task and its executor where the identity is and when it expires.
+// The identity can only be delivered to the tasks using the Mesos
+// containerizer, the other tasks are left as is.
+func (p *Provider) Populate(
+	ctx context.Context,
+	taskConfig *task.TaskConfig,
+	jobID string,
+	instanceID uint32,
+) error {
+	if taskConfig.GetContainer().GetType() != mesos.ContainerInfo_MESOS {
+		p.metrics.IssueSkipped.Inc(1)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+
+	id := SpiffeID(p.cfg.TrustDomain, jobID, instanceID)
+	sw := p.metrics.IssueDuration.Start()
+	credential, err := p.issuer.Issue(ctx, &Request{
+		ID:         id,
+		JobID:      jobID,
+		InstanceID: instanceID,
+		TTL:        p.cfg.TTL,
+	})
+	sw.Stop()
+	if err != nil {
+		p.metrics.IssueFail.Inc(1)
+		return errors.Wrapf(err, "failed to issue identity %s", id)
+	}
+	p.metrics.Issue.Inc(1)
+
+	taskConfig.Container.Volumes = append(
+		taskConfig.Container.Volumes,
+		util.CreateSecretVolume(p.cfg.ContainerPath, string(credential.Data)),
+	)
+
+	if taskConfig.GetCommand() != nil {
+		if taskConfig.GetCommand().GetEnvironment() == nil {
+			taskConfig.Command.Environment = &mesos.Environment{}
+		}
+		env := taskConfig.Command.Environment
+		for _, v := range [][2]string{
+			{IDEnvName, id},
+			{PathEnvName, p.cfg.ContainerPath},
+			{ExpiresAtEnvName, credential.ExpiresAt.UTC().Format(time.RFC3339)},
+			{RenewURLEnvName, p.cfg.RenewURL},
+		} {
+			if len(v[1]) == 0 {
+				continue
+			}
+			name := v[0]
+			value := v[1]
+			env.Variables = append(env.Variables, &mesos.Environment_Variable{
+				Name:  &name,
+				Value: &value,
+			})
+		}
+	}
+	return nil
+}
+
+// Renew issues a new certificate to the task holding an identity issued
+// to it before, so that the executor can rotate the identity of a long
+// running task before it expires. The task authenticates with the
+// certificate chain of its current identity, and sends a certificate
+// signing request signed with the private key to certify, which may be
+// new, so that no private key is ever sent. The certificate must not have
+// expired, and isActive must report the instance of the job as still
+// running, so that the identities of the stopped tasks are not renewed.
+// The identity of a task is not renewed past the maximum lifetime after
+// the first identity was issued to the task.
+func (p *Provider) Renew(
+	ctx context.Context,
+	chain []*x509.Certificate,
+	csrDER []byte,
+	isActive func(ctx context.Context, jobID string, instanceID uint32) bool,
+) (*Credential, error) {
+	verifier, ok := p.issuer.(Verifier)
+	if !ok {
+		p.metrics.RenewFail.Inc(1)
+		return nil, yarpcerrors.UnimplementedErrorf(
+			"identities of issuer %s cannot be renewed", p.cfg.Issuer)
+	}
+
+	now := time.Now()
+	id, err := verifier.Verify(chain, now)
+	if err != nil {
+		p.metrics.RenewFail.Inc(1)
+		return nil, yarpcerrors.PermissionDeniedErrorf(
+			"invalid identity: %v", err)
+	}
+	jobID, instanceID, err := ParseSpiffeID(p.cfg.TrustDomain, id)
+	if err != nil {
+		p.metrics.RenewFail.Inc(1)
+		return nil, yarpcerrors.PermissionDeniedErrorf(
+			"invalid identity: %v", err)
+	}
+
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	if err == nil {
+		err = csr.CheckSignature()
+	}
+	if err != nil {
+		p.metrics.RenewFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"invalid certificate signing request: %v", err)
+	}
+	for _, uri := range csr.URIs {
+		if uri.String() != id {
+			p.metrics.RenewFail.Inc(1)
+			return nil, yarpcerrors.PermissionDeniedErrorf(
+				"certificate signing request for %s by %s", uri, id)
+		}
+	}
+
+	// the renewed identities keep the time the first identity was
+	// issued to the task
+	notBefore := chain[0].NotBefore
+	ttl := p.cfg.TTL
+	if remaining := notBefore.Add(p.cfg.MaxLifetime).Sub(now); remaining < ttl {
+		ttl = remaining
+	}
+	if ttl <= 0 {
+		p.metrics.RenewFail.Inc(1)
+		return nil, yarpcerrors.PermissionDeniedErrorf(
+			"identity %s issued at %s cannot be renewed anymore",
+			id, notBefore.UTC().Format(time.RFC3339))
+	}
+
+	if !isActive(ctx, jobID, instanceID) {
+		p.metrics.RenewFail.Inc(1)
+		return nil, yarpcerrors.PermissionDeniedErrorf(
+			"task of identity %s is not running", id)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+
+	credential, err := p.issuer.Issue(ctx, &Request{
+		ID:         id,
+		JobID:      jobID,
+		InstanceID: instanceID,
+		TTL:        ttl,
+		PublicKey:  csr.PublicKey,
+		NotBefore:  notBefore,
+	})
+	if err != nil {
+		p.metrics.RenewFail.Inc(1)
+		return nil, errors.Wrapf(err, "failed to renew identity %s", id)
+	}
+	p.metrics.Renew.Inc(1)
+	return credential, nil
+}
@@ -0,0 +1,422 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	mesos "github.com/uber/peloton/.gen/mesos/v1"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// fakeIssuer returns the same credential for all the tasks
+type fakeIssuer struct {
+	credential *Credential
+	err        error
+	requests   []*Request
+}
+
+func (i *fakeIssuer) Issue(
+	ctx context.Context,
+	req *Request,
+) (*Credential, error) {
+	i.requests = append(i.requests, req)
+	return i.credential, i.err
+}
+
+type IdentityTestSuite struct {
+	suite.Suite
+
+	caCert  *x509.Certificate
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func (suite *IdentityTestSuite) SetupSuite() {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	suite.NoError(err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "peloton-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(
+		rand.Reader, template, template, key.Public(), key)
+	suite.NoError(err)
+	suite.caCert, err = x509.ParseCertificate(der)
+	suite.NoError(err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	suite.NoError(err)
+
+	suite.certPEM = pem.EncodeToMemory(
+		&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	suite.keyPEM = pem.EncodeToMemory(
+		&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestIdentity(t *testing.T) {
+	suite.Run(t, new(IdentityTestSuite))
+}
+
+func newMesosTaskConfig() *task.TaskConfig {
+	containerType := mesos.ContainerInfo_MESOS
+	cmd := "echo"
+	return &task.TaskConfig{
+		Container: &mesos.ContainerInfo{Type: &containerType},
+		Command:   &mesos.CommandInfo{Value: &cmd},
+	}
+}
+
+// parseCredential returns the PEM blocks of a credential
+func parseCredential(data []byte) []*pem.Block {
+	var blocks []*pem.Block
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return blocks
+		}
+		blocks = append(blocks, block)
+	}
+}
+
+// parseChain returns the certificate chain of a credential
+func (suite *IdentityTestSuite) parseChain(data []byte) []*x509.Certificate {
+	var chain []*x509.Certificate
+	for _, block := range parseCredential(data) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		suite.NoError(err)
+		chain = append(chain, cert)
+	}
+	return chain
+}
+
+// newCSR returns a new private key along with a certificate signing
+// request of the given ID signed with it
+func (suite *IdentityTestSuite) newCSR(id string) (crypto.Signer, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	suite.NoError(err)
+	template := &x509.CertificateRequest{}
+	if id != "" {
+		uri, err := url.Parse(id)
+		suite.NoError(err)
+		template.URIs = []*url.URL{uri}
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	suite.NoError(err)
+	return key, der
+}
+
+// TestCAIssuer tests that the CA issuer issues a certificate with the
+// SPIFFE ID of the task, signed by the CA
+func (suite *IdentityTestSuite) TestCAIssuer() {
+	issuer, err := newCAIssuerFromPEM(suite.certPEM, suite.keyPEM)
+	suite.NoError(err)
+
+	id := SpiffeID("peloton", "job-1", 3)
+	suite.Equal("spiffe://peloton/job/job-1/instance/3", id)
+	credential, err := issuer.Issue(context.Background(), &Request{
+		ID:  id,
+		TTL: time.Hour,
+	})
+	suite.NoError(err)
+
+	blocks := parseCredential(credential.Data)
+	suite.Len(blocks, 3)
+	suite.Equal("CERTIFICATE", blocks[0].Type)
+	suite.Equal("CERTIFICATE", blocks[1].Type)
+	suite.Equal("PRIVATE KEY", blocks[2].Type)
+
+	cert, err := x509.ParseCertificate(blocks[0].Bytes)
+	suite.NoError(err)
+	suite.Len(cert.URIs, 1)
+	suite.Equal(id, cert.URIs[0].String())
+	suite.Equal(credential.ExpiresAt.Unix(), cert.NotAfter.Unix())
+
+	roots := x509.NewCertPool()
+	roots.AddCert(suite.caCert)
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	suite.NoError(err)
+
+	// the certificate does not outlive the CA
+	credential, err = issuer.Issue(context.Background(), &Request{
+		ID:  id,
+		TTL: 48 * time.Hour,
+	})
+	suite.NoError(err)
+	suite.Equal(suite.caCert.NotAfter, credential.ExpiresAt)
+
+	// only the certificate is returned for a given public key
+	key, _ := suite.newCSR("")
+	notBefore := time.Now().Add(-time.Hour).Truncate(time.Second)
+	credential, err = issuer.Issue(context.Background(), &Request{
+		ID:        id,
+		TTL:       time.Hour,
+		PublicKey: key.Public(),
+		NotBefore: notBefore,
+	})
+	suite.NoError(err)
+	blocks = parseCredential(credential.Data)
+	suite.Len(blocks, 2)
+	chain := suite.parseChain(credential.Data)
+	suite.Equal(key.Public(), chain[0].PublicKey)
+	suite.Equal(notBefore.Unix(), chain[0].NotBefore.Unix())
+}
+
+// TestNewIssuer tests creating the issuer selected by the config
+func (suite *IdentityTestSuite) TestNewIssuer() {
+	_, err := NewIssuer(Config{Issuer: "unknown"})
+	suite.Error(err)
+
+	_, err = NewIssuer(Config{CA: CAConfig{CertFile: "/does/not/exist"}})
+	suite.Error(err)
+
+	issuer := &fakeIssuer{}
+	RegisterIssuer("fake", func(cfg Config) (Issuer, error) {
+		return issuer, nil
+	})
+	i, err := NewIssuer(Config{Issuer: "fake"})
+	suite.NoError(err)
+	suite.Equal(issuer, i)
+}
+
+// TestPopulate tests that the identity is added to the task config as a
+// secret volume and environment variables
+func (suite *IdentityTestSuite) TestPopulate() {
+	expiresAt := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	issuer := &fakeIssuer{credential: &Credential{
+		Data:      []byte("svid"),
+		ExpiresAt: expiresAt,
+	}}
+	scope := tally.NewTestScope("", nil)
+	provider := NewProvider(Config{TrustDomain: "example.org"}, issuer, scope)
+
+	taskConfig := newMesosTaskConfig()
+	suite.NoError(provider.Populate(
+		context.Background(), taskConfig, "job-1", 2))
+
+	suite.Len(issuer.requests, 1)
+	suite.Equal("spiffe://example.org/job/job-1/instance/2",
+		issuer.requests[0].ID)
+	suite.Equal(_defaultTTL, issuer.requests[0].TTL)
+
+	volumes := taskConfig.GetContainer().GetVolumes()
+	suite.Len(volumes, 1)
+	suite.Equal(_defaultContainerPath, volumes[0].GetContainerPath())
+	suite.Equal([]byte("svid"),
+		volumes[0].GetSource().GetSecret().GetValue().GetData())
+
+	env := make(map[string]string)
+	for _, v := range taskConfig.GetCommand().GetEnvironment().GetVariables() {
+		env[v.GetName()] = v.GetValue()
+	}
+	suite.Equal(map[string]string{
+		IDEnvName:        "spiffe://example.org/job/job-1/instance/2",
+		PathEnvName:      _defaultContainerPath,
+		ExpiresAtEnvName: "2019-01-02T03:04:05Z",
+	}, env)
+
+	// the renew URL is exported to the task if configured
+	provider = NewProvider(
+		Config{RenewURL: "http://jobmgr/identity/renew"}, issuer, tally.NoopScope)
+	taskConfig = newMesosTaskConfig()
+	suite.NoError(provider.Populate(
+		context.Background(), taskConfig, "job-1", 2))
+	var renewURL string
+	for _, v := range taskConfig.GetCommand().GetEnvironment().GetVariables() {
+		if v.GetName() == RenewURLEnvName {
+			renewURL = v.GetValue()
+		}
+	}
+	suite.Equal("http://jobmgr/identity/renew", renewURL)
+	suite.Equal(int64(1), scope.Snapshot().
+		Counters()["identity.issue+result=success"].Value())
+}
+
+// TestPopulateSkipped tests that no identity is issued to the tasks not
+// using the Mesos containerizer
+func (suite *IdentityTestSuite) TestPopulateSkipped() {
+	issuer := &fakeIssuer{}
+	provider := NewProvider(Config{}, issuer, tally.NoopScope)
+
+	taskConfig := &task.TaskConfig{}
+	suite.NoError(provider.Populate(
+		context.Background(), taskConfig, "job-1", 0))
+	suite.Empty(issuer.requests)
+	suite.Nil(taskConfig.GetContainer())
+}
+
+// TestPopulateError tests that the task config is left as is if the
+// identity can not be issued
+func (suite *IdentityTestSuite) TestPopulateError() {
+	issuer := &fakeIssuer{err: errors.New("issuer unavailable")}
+	provider := NewProvider(Config{}, issuer, tally.NoopScope)
+
+	taskConfig := newMesosTaskConfig()
+	suite.Error(provider.Populate(
+		context.Background(), taskConfig, "job-1", 0))
+	suite.Empty(taskConfig.GetContainer().GetVolumes())
+	suite.Nil(taskConfig.GetCommand().GetEnvironment())
+}
+
+// TestCAIssuerVerify tests that the CA issuer verifies the certificates
+// it issued and which have not expired
+func (suite *IdentityTestSuite) TestCAIssuerVerify() {
+	issuer, err := newCAIssuerFromPEM(suite.certPEM, suite.keyPEM)
+	suite.NoError(err)
+
+	id := SpiffeID("peloton", "job-1", 3)
+	credential, err := issuer.Issue(context.Background(), &Request{
+		ID:  id,
+		TTL: time.Hour,
+	})
+	suite.NoError(err)
+	chain := suite.parseChain(credential.Data)
+
+	verified, err := issuer.Verify(chain, time.Now())
+	suite.NoError(err)
+	suite.Equal(id, verified)
+
+	// expired
+	_, err = issuer.Verify(chain, time.Now().Add(2*time.Hour))
+	suite.Error(err)
+
+	// no certificate
+	_, err = issuer.Verify(nil, time.Now())
+	suite.Error(err)
+
+	// not issued by the CA
+	_, err = issuer.Verify(
+		[]*x509.Certificate{suite.caCert}, time.Now())
+	suite.Error(err)
+}
+
+// TestParseSpiffeID tests parsing the SPIFFE ID of an instance of a job
+func (suite *IdentityTestSuite) TestParseSpiffeID() {
+	jobID, instanceID, err := ParseSpiffeID(
+		"peloton", SpiffeID("peloton", "job-1", 3))
+	suite.NoError(err)
+	suite.Equal("job-1", jobID)
+	suite.Equal(uint32(3), instanceID)
+
+	for _, id := range []string{
+		SpiffeID("other", "job-1", 3),
+		"spiffe://peloton/job/job-1",
+		"spiffe://peloton/job//instance/3",
+		"spiffe://peloton/job/job-1/instance/x",
+		"spiffe://peloton/job/job-1/pod/3",
+	} {
+		_, _, err := ParseSpiffeID("peloton", id)
+		suite.Error(err, id)
+	}
+}
+
+// TestRenew tests that the identity of a running task is renewed for the
+// public key of its certificate signing request, and that the identities
+// of the stopped tasks are not
+func (suite *IdentityTestSuite) TestRenew() {
+	issuer, err := newCAIssuerFromPEM(suite.certPEM, suite.keyPEM)
+	suite.NoError(err)
+	provider := NewProvider(Config{}, issuer, tally.NoopScope)
+
+	id := SpiffeID(_defaultTrustDomain, "job-1", 3)
+	credential, err := issuer.Issue(context.Background(), &Request{
+		ID:  id,
+		TTL: time.Minute,
+	})
+	suite.NoError(err)
+	chain := suite.parseChain(credential.Data)
+	key, csr := suite.newCSR(id)
+
+	var active bool
+	isActive := func(
+		_ context.Context,
+		jobID string,
+		instanceID uint32,
+	) bool {
+		suite.Equal("job-1", jobID)
+		suite.Equal(uint32(3), instanceID)
+		return active
+	}
+
+	_, err = provider.Renew(context.Background(), chain, csr, isActive)
+	suite.True(yarpcerrors.IsPermissionDenied(err))
+
+	active = true
+	renewed, err := provider.Renew(
+		context.Background(), chain, csr, isActive)
+	suite.NoError(err)
+	suite.True(renewed.ExpiresAt.After(credential.ExpiresAt))
+	// no private key is returned
+	suite.Len(parseCredential(renewed.Data), 2)
+	renewedChain := suite.parseChain(renewed.Data)
+	suite.Equal(key.Public(), renewedChain[0].PublicKey)
+	suite.Equal(chain[0].NotBefore, renewedChain[0].NotBefore)
+	verified, err := issuer.Verify(renewedChain, time.Now())
+	suite.NoError(err)
+	suite.Equal(id, verified)
+
+	// not a certificate signing request
+	_, err = provider.Renew(
+		context.Background(), chain, []byte("csr"), isActive)
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+
+	// the certificate signing request of another identity
+	_, otherCSR := suite.newCSR(SpiffeID(_defaultTrustDomain, "job-1", 4))
+	_, err = provider.Renew(context.Background(), chain, otherCSR, isActive)
+	suite.True(yarpcerrors.IsPermissionDenied(err))
+
+	// not issued by the CA
+	_, err = provider.Renew(
+		context.Background(), []*x509.Certificate{suite.caCert}, csr, isActive)
+	suite.True(yarpcerrors.IsPermissionDenied(err))
+
+	// the identity is not renewed past its maximum lifetime
+	provider = NewProvider(
+		Config{MaxLifetime: time.Second}, issuer, tally.NoopScope)
+	_, err = provider.Renew(context.Background(), chain, csr, isActive)
+	suite.True(yarpcerrors.IsPermissionDenied(err))
+
+	// the identities of the issuers which cannot verify them are not renewed
+	provider = NewProvider(Config{}, &fakeIssuer{}, tally.NoopScope)
+	_, err = provider.Renew(context.Background(), chain, csr, isActive)
+	suite.True(yarpcerrors.IsUnimplemented(err))
+}
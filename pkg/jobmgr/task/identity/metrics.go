@@ -0,0 +1,52 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"github.com/uber-go/tally"
+)
+
+// Metrics is the struct containing all the counters that track
+// internal state of the identity provider
+type Metrics struct {
+	Issue         tally.Counter
+	IssueFail     tally.Counter
+	IssueDuration tally.Timer
+
+	Renew     tally.Counter
+	RenewFail tally.Counter
+
+	// Increment this counter when the identity can not be delivered
+	// to the task, as it does not use the Mesos containerizer
+	IssueSkipped tally.Counter
+}
+
+// NewMetrics returns a new Metrics struct, with all metrics
+// initialized and rooted at the given tally.Scope
+func NewMetrics(scope tally.Scope) *Metrics {
+	successScope := scope.Tagged(map[string]string{"result": "success"})
+	failScope := scope.Tagged(map[string]string{"result": "fail"})
+
+	return &Metrics{
+		Issue:         successScope.Counter("issue"),
+		IssueFail:     failScope.Counter("issue"),
+		IssueDuration: scope.Timer("issue_duration"),
+
+		Renew:     successScope.Counter("renew"),
+		RenewFail: failScope.Counter("renew"),
+
+		IssueSkipped: scope.Counter("issue_skipped"),
+	}
+}
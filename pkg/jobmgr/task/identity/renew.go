@@ -0,0 +1,173 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+
+	"github.com/uber/peloton/pkg/common/leader"
+	"github.com/uber/peloton/pkg/common/util"
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+
+	log "github.com/sirupsen/logrus"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const (
+	// RenewPath is the HTTP path at which the executors renew the
+	// identity of their task
+	RenewPath = "/identity/renew"
+
+	// _maxRequestSize is the maximum size of the certificate signing
+	// requests read
+	_maxRequestSize = 64 << 10
+)
+
+// renewResponse is the response of the renewal of an identity
+type renewResponse struct {
+	// Certificate is the PEM encoded certificate of the task, followed by
+	// the certificate of the CA
+	Certificate string `json:"certificate"`
+	// ExpiresAt is the time the new identity expires at, in RFC3339 form
+	ExpiresAt string `json:"expires_at"`
+}
+
+// renewHandler renews the identities of the running tasks. The caller
+// authenticates with the certificate of the current identity of the task
+// over mutual TLS, and the body of the request is the PEM encoded
+// certificate signing request of the task.
+type renewHandler struct {
+	provider   *Provider
+	jobFactory cached.JobFactory
+	candidate  leader.Candidate
+}
+
+// NewRenewServer returns the server of the endpoint renewing the
+// identities of the tasks at addr, so that their executor can rotate them
+// before they expire. It must be served over TLS, with the certificate
+// returned by getCertificate, e.g. the one of the job manager. The client
+// certificates are required, and are verified by the issuer of the
+// provider instead of a pool of CAs, since they are the identities of the
+// tasks.
+func NewRenewServer(
+	addr string,
+	getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error),
+	provider *Provider,
+	jobFactory cached.JobFactory,
+	candidate leader.Candidate,
+) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(RenewPath, &renewHandler{
+		provider:   provider,
+		jobFactory: jobFactory,
+		candidate:  candidate,
+	})
+	return &http.Server{
+		Addr:    addr,
+		Handler: mux,
+		TLSConfig: &tls.Config{
+			GetCertificate: getCertificate,
+			ClientAuth:     tls.RequireAnyClientCert,
+			MinVersion:     tls.VersionTLS12,
+		},
+	}
+}
+
+// ServeHTTP renews the identity of the client certificate of the request,
+// certifying the public key of the certificate signing request in its body.
+func (h *renewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// never renew an identity whose possession was not proven by a TLS
+	// handshake with its private key
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		http.Error(w, "client certificate required", http.StatusUnauthorized)
+		return
+	}
+
+	if !h.candidate.IsLeader() {
+		http.Error(w, "not the leader", http.StatusServiceUnavailable)
+		return
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, _maxRequestSize))
+	if err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		http.Error(w, "no certificate signing request", http.StatusBadRequest)
+		return
+	}
+
+	credential, err := h.provider.Renew(
+		r.Context(), r.TLS.PeerCertificates, block.Bytes, h.isActive)
+	if err != nil {
+		log.WithError(err).Info("failed to renew task identity")
+		switch {
+		case yarpcerrors.IsInvalidArgument(err):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case yarpcerrors.IsPermissionDenied(err):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case yarpcerrors.IsUnimplemented(err):
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&renewResponse{
+		Certificate: string(credential.Data),
+		ExpiresAt:   credential.ExpiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// isActive returns true if the instance of the job is still running, or
+// is about to.
+func (h *renewHandler) isActive(
+	ctx context.Context,
+	jobID string,
+	instanceID uint32,
+) bool {
+	cachedJob := h.jobFactory.GetJob(&peloton.JobID{Value: jobID})
+	if cachedJob == nil {
+		return false
+	}
+	cachedTask := cachedJob.GetTask(instanceID)
+	if cachedTask == nil {
+		return false
+	}
+	runtime, err := cachedTask.GetRuntime(ctx)
+	if err != nil {
+		return false
+	}
+	return !util.IsPelotonStateTerminal(runtime.GetState()) &&
+		!util.IsPelotonStateTerminal(runtime.GetGoalState())
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	leadermocks "github.com/uber/peloton/pkg/common/leader/mocks"
+	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/uber-go/tally"
+)
+
+// TestRenewHandler tests renewing the identity of a task over HTTP
+func (suite *IdentityTestSuite) TestRenewHandler() {
+	ctrl := gomock.NewController(suite.T())
+	defer ctrl.Finish()
+
+	jobFactory := cachedmocks.NewMockJobFactory(ctrl)
+	cachedJob := cachedmocks.NewMockJob(ctrl)
+	cachedTask := cachedmocks.NewMockTask(ctrl)
+	candidate := leadermocks.NewMockCandidate(ctrl)
+
+	issuer, err := newCAIssuerFromPEM(suite.certPEM, suite.keyPEM)
+	suite.NoError(err)
+	server := NewRenewServer(
+		":0",
+		nil,
+		NewProvider(Config{}, issuer, tally.NoopScope),
+		jobFactory,
+		candidate,
+	)
+	suite.Equal(tls.RequireAnyClientCert, server.TLSConfig.ClientAuth)
+
+	id := SpiffeID(_defaultTrustDomain, "job-1", 3)
+	credential, err := issuer.Issue(context.Background(), &Request{
+		ID:  id,
+		TTL: time.Minute,
+	})
+	suite.NoError(err)
+	chain := suite.parseChain(credential.Data)
+	key, csrDER := suite.newCSR(id)
+	csr := pem.EncodeToMemory(
+		&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER})
+
+	renew := func(
+		method string,
+		state *tls.ConnectionState,
+		body []byte,
+	) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, RenewPath, bytes.NewReader(body))
+		r.TLS = state
+		server.Handler.ServeHTTP(w, r)
+		return w
+	}
+	peer := &tls.ConnectionState{PeerCertificates: chain}
+
+	suite.Equal(http.StatusMethodNotAllowed,
+		renew(http.MethodGet, peer, csr).Code)
+
+	// the identity must be presented over mutual TLS
+	suite.Equal(http.StatusUnauthorized,
+		renew(http.MethodPost, nil, csr).Code)
+	suite.Equal(http.StatusUnauthorized,
+		renew(http.MethodPost, &tls.ConnectionState{}, csr).Code)
+
+	candidate.EXPECT().IsLeader().Return(false)
+	suite.Equal(http.StatusServiceUnavailable,
+		renew(http.MethodPost, peer, csr).Code)
+
+	candidate.EXPECT().IsLeader().Return(true).Times(3)
+	suite.Equal(http.StatusBadRequest,
+		renew(http.MethodPost, peer, credential.Data).Code)
+
+	jobFactory.EXPECT().
+		GetJob(&peloton.JobID{Value: "job-1"}).
+		Return(cachedJob).
+		Times(2)
+	cachedJob.EXPECT().GetTask(uint32(3)).Return(cachedTask).Times(2)
+	gomock.InOrder(
+		cachedTask.EXPECT().GetRuntime(gomock.Any()).
+			Return(&task.RuntimeInfo{
+				State:     task.TaskState_RUNNING,
+				GoalState: task.TaskState_RUNNING,
+			}, nil),
+		cachedTask.EXPECT().GetRuntime(gomock.Any()).
+			Return(&task.RuntimeInfo{
+				State:     task.TaskState_KILLED,
+				GoalState: task.TaskState_KILLED,
+			}, nil),
+	)
+
+	w := renew(http.MethodPost, peer, csr)
+	suite.Equal(http.StatusOK, w.Code)
+	var resp renewResponse
+	suite.NoError(json.NewDecoder(w.Body).Decode(&resp))
+	suite.Len(parseCredential([]byte(resp.Certificate)), 2)
+	renewed := suite.parseChain([]byte(resp.Certificate))
+	suite.Equal(key.Public(), renewed[0].PublicKey)
+	verified, err := issuer.Verify(renewed, time.Now())
+	suite.NoError(err)
+	suite.Equal(id, verified)
+	suite.NotEmpty(resp.ExpiresAt)
+
+	suite.Equal(http.StatusForbidden,
+		renew(http.MethodPost, peer, csr).Code)
+}
@@ -19,6 +19,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"net"
 	"strings"
 	"sync"
 	"time"
@@ -37,6 +38,8 @@ import (
 	"github.com/uber/peloton/pkg/common/util"
 	"github.com/uber/peloton/pkg/jobmgr/cached"
 	jobmgrcommon "github.com/uber/peloton/pkg/jobmgr/common"
+	"github.com/uber/peloton/pkg/jobmgr/task/identity"
+	taskutil "github.com/uber/peloton/pkg/jobmgr/util/task"
 	"github.com/uber/peloton/pkg/storage"
 	ormobjects "github.com/uber/peloton/pkg/storage/objects"
 
@@ -135,12 +138,30 @@ type launcher struct {
 	secretInfoOps ormobjects.SecretInfoOps
 	metrics       *Metrics
 	retryPolicy   backoff.RetryPolicy
+	// identity issues the identity of the tasks at launch, nil if the
+	// issuance of the identities is not enabled
+	identity *identity.Provider
+
+	// agentAddresses caches the address of the Mesos agent per hostname,
+	// so that it is looked up once per agent rather than per launch
+	agentAddressLock sync.Mutex
+	agentAddresses   map[string]*agentAddress
+}
+
+// agentAddress is the ip:port of the Mesos agent with the given agent id
+type agentAddress struct {
+	agentID string
+	address string
 }
 
 const (
 	// Time out for the function to time out
 	_rpcTimeout = 10 * time.Second
 
+	// Time out for looking up the Mesos agent address of a host. The
+	// address is only a hint, so do not hold up the launch for long.
+	_agentInfoTimeout = 1 * time.Second
+
 	// default secret operations cassandra timeout
 	_defaultSecretInfoOpsTimeout = 10 * time.Second
 )
@@ -161,6 +182,7 @@ func InitTaskLauncher(
 	taskStore storage.TaskStore,
 	volumeStore storage.PersistentVolumeStore,
 	ormStore *ormobjects.Store,
+	identityProvider *identity.Provider,
 	parent tally.Scope,
 ) {
 	onceInitTaskLauncher.Do(func() {
@@ -178,6 +200,7 @@ func InitTaskLauncher(
 			metrics:       NewMetrics(parent.SubScope("jobmgr").SubScope("task")),
 			// TODO: make launch retry policy config.
 			retryPolicy: backoff.NewRetryPolicy(3, 15*time.Second),
+			identity:    identityProvider,
 		}
 	})
 }
@@ -253,6 +276,9 @@ func (l *launcher) GetLaunchableTasks(
 	skippedTasks := make([]*peloton.TaskID, 0)
 	getTaskInfoStart := time.Now()
 
+	// the address of the Mesos agent, looked up only if a task is launched
+	var agentAddr *string
+
 	for _, taskID := range tasks {
 		id, instanceID, err := util.ParseTaskID(taskID.GetValue())
 		jobID := &peloton.JobID{Value: id}
@@ -313,6 +339,18 @@ func (l *launcher) GetLaunchableTasks(
 			runtimeDiff[jobmgrcommon.HostField] = hostname
 			runtimeDiff[jobmgrcommon.AgentIDField] = agentID
 			runtimeDiff[jobmgrcommon.StateField] = task.TaskState_LAUNCHED
+
+			if agentAddr == nil {
+				addr := l.getAgentAddress(ctx, hostname, agentID)
+				agentAddr = &addr
+			}
+			runtimeDiff[jobmgrcommon.AgentAddressField] = *agentAddr
+
+			if template := taskConfig.GetHostnameTemplate(); len(template) != 0 {
+				runtimeDiff[jobmgrcommon.ContainerHostnameField] =
+					taskutil.RenderHostname(
+						template, jobID.GetValue(), uint32(instanceID))
+			}
 		}
 
 		if selectedPorts != nil {
@@ -364,6 +402,62 @@ func (l *launcher) GetLaunchableTasks(
 	return launchableTasks, skippedTasks, nil
 }
 
+// getAgentAddress returns the ip:port of the Mesos agent with the given
+// agent id on the host. The address is recorded in the task runtime so that
+// the sandbox of the task can be browsed without looking up the agent, so
+// an empty address is returned if the lookup fails rather than an error.
+func (l *launcher) getAgentAddress(
+	ctx context.Context,
+	hostname string,
+	agentID *mesos.AgentID) string {
+	if hostname == "" {
+		return ""
+	}
+
+	l.agentAddressLock.Lock()
+	if addr, ok := l.agentAddresses[hostname]; ok &&
+		addr.agentID == agentID.GetValue() {
+		l.agentAddressLock.Unlock()
+		return addr.address
+	}
+	l.agentAddressLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, _agentInfoTimeout)
+	defer cancel()
+
+	resp, err := l.hostMgrClient.GetMesosAgentInfo(
+		ctx,
+		&hostsvc.GetMesosAgentInfoRequest{Hostname: hostname})
+	if err != nil || len(resp.GetAgents()) == 0 {
+		log.WithError(err).
+			WithField("hostname", hostname).
+			Debug("cannot get Mesos agent info")
+		return ""
+	}
+
+	agent := resp.GetAgents()[0]
+	if agent.GetAgentInfo().GetId().GetValue() != agentID.GetValue() {
+		// agent map in host manager has not caught up with the agent yet
+		return ""
+	}
+	ip, port, err := util.ExtractIPAndPortFromMesosAgentPID(agent.GetPid())
+	if err != nil || port == "" {
+		return ""
+	}
+	address := net.JoinHostPort(ip, port)
+
+	l.agentAddressLock.Lock()
+	defer l.agentAddressLock.Unlock()
+	if l.agentAddresses == nil {
+		l.agentAddresses = make(map[string]*agentAddress)
+	}
+	l.agentAddresses[hostname] = &agentAddress{
+		agentID: agentID.GetValue(),
+		address: address,
+	}
+	return address
+}
+
 // updateTaskRuntime updates task runtime with goalstate, reason and message
 // for the given task id.
 func (l *launcher) updateTaskRuntime(
@@ -436,6 +530,22 @@ func (l *launcher) CreateLaunchableTasks(
 			continue
 		}
 
+		// issue the identity of the task, the task is launched again
+		// by resmgr if the issuer is not available
+		if l.identity != nil {
+			err = l.identity.Populate(
+				ctx,
+				launchableTaskInfo.Config,
+				launchableTaskInfo.GetJobId().GetValue(),
+				launchableTaskInfo.GetInstanceId())
+			if err != nil {
+				log.WithError(err).WithField("task_id", id).
+					Error("failed to issue task identity. skipping task")
+				skippedTaskInfos[id] = launchableTaskInfo
+				continue
+			}
+		}
+
 		// Strip off labels with prefix common.SystemLabelPrefix. This is a temporary
 		// fix to ensure job creates dont fail on clients adding the system labels
 		// TODO: remove this once all Peloton clients have been modified
@@ -454,6 +564,18 @@ func (l *launcher) CreateLaunchableTasks(
 				launchableTaskInfo.Config.Labels,
 				label)
 		}
+		// Set the hostname of the container, so that the instance keeps
+		// the same hostname wherever it is launched
+		config := launchableTaskInfo.Config
+		if len(config.GetHostnameTemplate()) != 0 &&
+			config.GetContainer() != nil {
+			hostname := taskutil.RenderHostname(
+				config.GetHostnameTemplate(),
+				launchableTaskInfo.GetJobId().GetValue(),
+				launchableTaskInfo.GetInstanceId())
+			config.Container.Hostname = &hostname
+		}
+
 		// Add volume info into launchable task if task config has volume.
 		launchableTask := hostsvc.LaunchableTask{
 			TaskId: launchableTaskInfo.Runtime.GetMesosTaskId(),
@@ -32,6 +32,7 @@ import (
 	"go.uber.org/yarpc/yarpcerrors"
 
 	mesos "github.com/uber/peloton/.gen/mesos/v1"
+	mesos_master "github.com/uber/peloton/.gen/mesos/v1/master"
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
 	"github.com/uber/peloton/.gen/peloton/api/v0/task"
 	"github.com/uber/peloton/.gen/peloton/api/v0/volume"
@@ -47,6 +48,7 @@ import (
 	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
 	jobmgrcommon "github.com/uber/peloton/pkg/jobmgr/common"
 	jobmgrtask "github.com/uber/peloton/pkg/jobmgr/task"
+	"github.com/uber/peloton/pkg/jobmgr/task/identity"
 	store_mocks "github.com/uber/peloton/pkg/storage/mocks"
 )
 
@@ -191,6 +193,7 @@ func (suite *LauncherTestSuite) TestGetLaunchableTasks() {
 	taskInfos := make(map[string]*LaunchableTaskInfo)
 	for i := 0; i < numTasks; i++ {
 		tmp := createTestTask(i)
+		tmp.GetConfig().HostnameTemplate = "kafka-{instance}"
 		taskID := &peloton.TaskID{
 			Value: tmp.JobId.Value + "-" + fmt.Sprint(tmp.InstanceId),
 		}
@@ -227,18 +230,40 @@ func (suite *LauncherTestSuite) TestGetLaunchableTasks() {
 			GetJob(&peloton.JobID{Value: jobID}).Return(nil)
 	}
 
+	pid := "slave(1)@1.2.3.4:5051"
+	suite.mockHostMgr.EXPECT().
+		GetMesosAgentInfo(gomock.Any(), &hostsvc.GetMesosAgentInfoRequest{
+			Hostname: hostOffer.Hostname,
+		}).
+		Return(&hostsvc.GetMesosAgentInfoResponse{
+			Agents: []*mesos_master.Response_GetAgents_Agent{
+				{
+					AgentInfo: &mesos.AgentInfo{Id: hostOffer.AgentId},
+					Pid:       &pid,
+				},
+			},
+		}, nil)
+
 	tasks = append(tasks, unknownTasks...)
 	launchableTasks, skippedTasks, err := suite.taskLauncher.GetLaunchableTasks(
 		context.Background(), tasks, hostOffer.Hostname,
 		hostOffer.AgentId, selectedPorts)
 	suite.NoError(err)
-	for _, launchableTask := range launchableTasks {
+	for taskID, launchableTask := range launchableTasks {
 		runtimeDiff := launchableTask.RuntimeDiff
 		suite.Equal(task.TaskState_LAUNCHED, runtimeDiff[jobmgrcommon.StateField])
 		suite.Equal(hostOffer.Hostname, runtimeDiff[jobmgrcommon.HostField])
 		suite.Equal(hostOffer.AgentId, runtimeDiff[jobmgrcommon.AgentIDField])
+		suite.Equal("1.2.3.4:5051", runtimeDiff[jobmgrcommon.AgentAddressField])
+		suite.Equal(
+			fmt.Sprintf("kafka-%d", taskInfos[taskID].GetInstanceId()),
+			runtimeDiff[jobmgrcommon.ContainerHostnameField])
 	}
 	suite.EqualValues(unknownTasks, skippedTasks)
+
+	// the agent address is cached for the next launch on the host
+	suite.Equal("1.2.3.4:5051", suite.taskLauncher.getAgentAddress(
+		context.Background(), hostOffer.Hostname, hostOffer.AgentId))
 }
 func (suite *LauncherTestSuite) TestGetLaunchableTasksStateful() {
 	unknownTasks := []*peloton.TaskID{
@@ -289,6 +314,10 @@ func (suite *LauncherTestSuite) TestGetLaunchableTasksStateful() {
 			GetJob(&peloton.JobID{Value: jobID}).Return(nil)
 	}
 
+	suite.mockHostMgr.EXPECT().
+		GetMesosAgentInfo(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("fake error"))
+
 	tasks = append(tasks, unknownTasks...)
 	launchableTasks, skippedTasks, err := suite.taskLauncher.GetLaunchableTasks(
 		context.Background(), tasks, hostOffer.Hostname,
@@ -299,6 +328,7 @@ func (suite *LauncherTestSuite) TestGetLaunchableTasksStateful() {
 		suite.Equal(task.TaskState_LAUNCHED, runtimeDiff[jobmgrcommon.StateField])
 		suite.Equal(hostOffer.Hostname, runtimeDiff[jobmgrcommon.HostField])
 		suite.Equal(hostOffer.AgentId, runtimeDiff[jobmgrcommon.AgentIDField])
+		suite.Equal("", runtimeDiff[jobmgrcommon.AgentAddressField])
 		suite.NotNil(runtimeDiff[jobmgrcommon.VolumeIDField], "Volume ID should not be null")
 	}
 	suite.EqualValues(unknownTasks, skippedTasks)
@@ -667,6 +697,81 @@ func (suite *LauncherTestSuite) TestProcessPlacementsWithNoTasksReleasesOffers()
 	time.Sleep(1 * time.Second)
 }
 
+// TestCreateLaunchableTasksHostname tests that the hostname of the
+// container is rendered from the hostname template of the task config
+func (suite *LauncherTestSuite) TestCreateLaunchableTasksHostname() {
+	taskInfos := make(map[string]*LaunchableTaskInfo)
+	for i := 0; i < 2; i++ {
+		tmp := createTestTask(i)
+		tmp.GetConfig().HostnameTemplate = "kafka-{instance}"
+		// the hostname is only set on the tasks with a container
+		if i == 0 {
+			tmp.GetConfig().Container = &mesos.ContainerInfo{}
+		}
+		taskInfos[fmt.Sprintf("%s-%d", _testJobID, i)] = tmp
+	}
+
+	launchableTasks, skippedTaskInfos := suite.taskLauncher.CreateLaunchableTasks(
+		context.Background(), taskInfos)
+	suite.Len(launchableTasks, 2)
+	suite.Empty(skippedTaskInfos)
+	for _, launchableTask := range launchableTasks {
+		if launchableTask.GetConfig().GetContainer() == nil {
+			continue
+		}
+		suite.Equal(fmt.Sprintf("%s-0", _testJobID),
+			launchableTask.GetId().GetValue())
+		suite.Equal("kafka-0",
+			launchableTask.GetConfig().GetContainer().GetHostname())
+	}
+}
+
+// identityIssuer fails to issue the identity of the first instance
+type identityIssuer struct{}
+
+func (i identityIssuer) Issue(
+	ctx context.Context,
+	req *identity.Request,
+) (*identity.Credential, error) {
+	if req.InstanceID == 0 {
+		return nil, errors.New("issuer unavailable")
+	}
+	return &identity.Credential{
+		Data:      []byte(req.ID),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}, nil
+}
+
+// TestCreateLaunchableTasksIdentity tests that the identity of the tasks is
+// added to their config, and that the tasks are skipped if their identity
+// can not be issued
+func (suite *LauncherTestSuite) TestCreateLaunchableTasksIdentity() {
+	mesosContainerizer := mesos.ContainerInfo_MESOS
+	suite.taskLauncher.identity = identity.NewProvider(
+		identity.Config{}, identityIssuer{}, suite.testScope)
+
+	taskInfos := make(map[string]*LaunchableTaskInfo)
+	for i := 0; i < 2; i++ {
+		tmp := createTestTask(i)
+		tmp.GetConfig().Container = &mesos.ContainerInfo{
+			Type: &mesosContainerizer,
+		}
+		taskInfos[fmt.Sprintf("%s-%d", _testJobID, i)] = tmp
+	}
+
+	launchableTasks, skippedTaskInfos := suite.taskLauncher.CreateLaunchableTasks(
+		context.Background(), taskInfos)
+	suite.Len(launchableTasks, 1)
+	suite.Len(skippedTaskInfos, 1)
+	suite.Contains(skippedTaskInfos, fmt.Sprintf("%s-0", _testJobID))
+
+	volumes := launchableTasks[0].GetConfig().GetContainer().GetVolumes()
+	suite.Len(volumes, 1)
+	suite.Equal(
+		identity.SpiffeID("peloton", _testJobID, 1),
+		string(volumes[0].GetSource().GetSecret().GetValue().GetData()))
+}
+
 // TestCreateLaunchableTasks tests the CreateLaunchableTasks function
 // to make sure that all the tasks in launchableTasks list
 // that contain a volume/secret will be populated with
@@ -178,9 +178,27 @@ func (p *processor) processPlacement(ctx context.Context, placement *resmgr.Plac
 		return
 	}
 
+	// record why the host was chosen for the tasks in their runtime
+	for taskID, launchableTask := range lauchableTasks {
+		if info, ok := placement.GetPlacementInfo()[taskID]; ok {
+			launchableTask.RuntimeDiff[jobmgrcommon.PlacementInfoField] = info
+		}
+	}
+
 	launchableTaskInfos, skippedTasks1 := p.createTaskInfos(ctx, lauchableTasks)
 	skippedTasks = append(skippedTasks, skippedTasks1...)
 
+	// The tasks of the jobs whose actions are paused are not launched.
+	// They are removed from resmgr along with the skipped tasks, and
+	// enqueued to resmgr again by goal state once the job is resumed.
+	pausedTaskInfos := p.removePausedTasks(launchableTaskInfos)
+	if len(pausedTaskInfos) > 0 {
+		p.enqueueTasksToResMgr(ctx, pausedTaskInfos)
+		for taskID := range pausedTaskInfos {
+			skippedTasks = append(skippedTasks, &peloton.TaskID{Value: taskID})
+		}
+	}
+
 	if len(launchableTaskInfos) > 0 {
 
 		// CreateLaunchableTasks returns a list of launchableTasks and taskInfo
@@ -208,6 +226,24 @@ func (p *processor) processPlacement(ctx context.Context, placement *resmgr.Plac
 	p.KillResManagerTasks(ctx, skippedTasks)
 }
 
+// removePausedTasks removes the tasks of the jobs whose actions are
+// paused from the given tasks, and returns them.
+func (p *processor) removePausedTasks(
+	taskInfos map[string]*launcher.LaunchableTaskInfo,
+) map[string]*launcher.LaunchableTaskInfo {
+	pausedTaskInfos := make(map[string]*launcher.LaunchableTaskInfo)
+	for taskID, taskInfo := range taskInfos {
+		if !p.goalStateDriver.IsPaused(taskInfo.JobId) {
+			continue
+		}
+		log.WithField("task_id", taskID).
+			Info("skipping launch of task of paused job")
+		pausedTaskInfos[taskID] = taskInfo
+		delete(taskInfos, taskID)
+	}
+	return pausedTaskInfos
+}
+
 func (p *processor) enqueueTaskToGoalState(taskInfos map[string]*launcher.LaunchableTaskInfo) {
 	for id := range taskInfos {
 		jobID, instanceID, err := util.ParseTaskID(id)
@@ -224,10 +260,8 @@ func (p *processor) enqueueTaskToGoalState(taskInfos map[string]*launcher.Launch
 			time.Now())
 
 		cachedJob := p.jobFactory.AddJob(&peloton.JobID{Value: jobID})
-		goalstate.EnqueueJobWithDefaultDelay(
-			&peloton.JobID{Value: jobID},
-			p.goalStateDriver,
-			cachedJob)
+		p.goalStateDriver.EnqueueJobWithDefaultDelay(
+			&peloton.JobID{Value: jobID}, cachedJob.GetJobType())
 	}
 }
 
@@ -382,8 +416,8 @@ func (p *processor) enqueueTasksToResMgr(ctx context.Context, tasks map[string]*
 			)
 			if err == nil {
 				p.goalStateDriver.EnqueueTask(t.JobId, t.InstanceId, time.Now())
-				goalstate.EnqueueJobWithDefaultDelay(
-					t.JobId, p.goalStateDriver, cachedJob)
+				p.goalStateDriver.EnqueueJobWithDefaultDelay(
+					t.JobId, cachedJob.GetJobType())
 				break
 			}
 			if common.IsTransientError(err) {
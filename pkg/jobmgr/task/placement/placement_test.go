@@ -219,6 +219,13 @@ func (suite *PlacementTestSuite) TestTaskPlacementNoError() {
 	taskID := &peloton.TaskID{
 		Value: testTask.JobId.Value + "-" + fmt.Sprint(testTask.InstanceId),
 	}
+	placementInfo := &task.PlacementInfo{
+		MatchedConstraints: []string{"host label zone=dca1 count > 0: matched"},
+		ScoreSummary:       "first fit, host 1 of 1 candidate hosts",
+	}
+	p.PlacementInfo = map[string]*task.PlacementInfo{
+		taskID.Value: placementInfo,
+	}
 
 	gomock.InOrder(
 		suite.taskLauncher.EXPECT().
@@ -240,9 +247,17 @@ func (suite *PlacementTestSuite) TestTaskPlacementNoError() {
 		suite.cachedTask.EXPECT().
 			GetRuntime(gomock.Any()).Return(testTask.Runtime, nil),
 		suite.cachedJob.EXPECT().
-			PatchTasks(gomock.Any(), gomock.Any()).Return(nil),
+			PatchTasks(gomock.Any(), gomock.Any()).
+			Do(func(_ context.Context, diffs map[uint32]jobmgrcommon.RuntimeDiff) {
+				// the placement info is recorded in the runtime of the task
+				suite.Equal(placementInfo,
+					diffs[0][jobmgrcommon.PlacementInfoField])
+			}).
+			Return(nil),
 		suite.cachedTask.EXPECT().
 			GetRuntime(gomock.Any()).Return(testTask.Runtime, nil),
+		suite.goalStateDriver.EXPECT().
+			IsPaused(testTask.JobId).Return(false),
 		suite.taskLauncher.EXPECT().
 			CreateLaunchableTasks(gomock.Any(), gomock.Any()).Return(nil, nil),
 		suite.taskLauncher.EXPECT().
@@ -253,10 +268,7 @@ func (suite *PlacementTestSuite) TestTaskPlacementNoError() {
 			AddJob(testTask.JobId).Return(suite.cachedJob),
 		suite.cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH),
 		suite.goalStateDriver.EXPECT().
-			JobRuntimeDuration(job.JobType_BATCH).
-			Return(1*time.Second),
-		suite.goalStateDriver.EXPECT().
-			EnqueueJob(testTask.JobId, gomock.Any()).Return(),
+			EnqueueJobWithDefaultDelay(testTask.JobId, job.JobType_BATCH),
 	)
 
 	suite.pp.processPlacement(context.Background(), p)
@@ -472,6 +484,8 @@ func (suite *PlacementTestSuite) TestTaskPlacementError() {
 			PatchTasks(gomock.Any(), gomock.Any()).Return(nil),
 		suite.cachedTask.EXPECT().
 			GetRuntime(gomock.Any()).Return(testTask.Runtime, nil),
+		suite.goalStateDriver.EXPECT().
+			IsPaused(testTask.JobId).Return(false),
 		suite.taskLauncher.EXPECT().
 			CreateLaunchableTasks(gomock.Any(), gomock.Any()).Return(nil, nil),
 		suite.taskLauncher.EXPECT().
@@ -484,10 +498,63 @@ func (suite *PlacementTestSuite) TestTaskPlacementError() {
 			EnqueueTask(testTask.JobId, testTask.InstanceId, gomock.Any()).Return(),
 		suite.cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH),
 		suite.goalStateDriver.EXPECT().
-			JobRuntimeDuration(job.JobType_BATCH).
-			Return(1*time.Second),
+			EnqueueJobWithDefaultDelay(testTask.JobId, job.JobType_BATCH),
+	)
+
+	suite.pp.processPlacement(context.Background(), p)
+}
+
+// TestTaskPlacementPausedJob tests that the tasks of a paused job are
+// not launched, but sent back to resmgr once the job is resumed.
+func (suite *PlacementTestSuite) TestTaskPlacementPausedJob() {
+	testTask, runtimeDiff := createTestTask(0) // taskinfo
+	rs := createResources(float64(1))
+	hostOffer := createHostOffer(0, rs)
+	p := createPlacements(testTask, hostOffer)
+
+	taskID := &peloton.TaskID{
+		Value: testTask.JobId.Value + "-" + fmt.Sprint(testTask.InstanceId),
+	}
+
+	gomock.InOrder(
+		suite.taskLauncher.EXPECT().
+			GetLaunchableTasks(gomock.Any(), p.Tasks, p.Hostname, p.AgentId, p.Ports).
+			Return(
+				map[string]*launcher.LaunchableTask{
+					taskID.Value: {
+						RuntimeDiff: runtimeDiff,
+						Config:      testTask.Config,
+					},
+				},
+				nil,
+				nil),
+		suite.jobFactory.EXPECT().
+			AddJob(testTask.JobId).Return(suite.cachedJob),
+		suite.cachedJob.EXPECT().
+			AddTask(gomock.Any(), uint32(0)).
+			Return(suite.cachedTask, nil),
+		suite.cachedTask.EXPECT().
+			GetRuntime(gomock.Any()).Return(testTask.Runtime, nil),
+		suite.cachedJob.EXPECT().
+			PatchTasks(gomock.Any(), gomock.Any()).Return(nil),
+		suite.cachedTask.EXPECT().
+			GetRuntime(gomock.Any()).Return(testTask.Runtime, nil),
 		suite.goalStateDriver.EXPECT().
-			EnqueueJob(testTask.JobId, gomock.Any()).Return(),
+			IsPaused(testTask.JobId).Return(true),
+		suite.jobFactory.EXPECT().
+			AddJob(testTask.JobId).Return(suite.cachedJob),
+		suite.cachedJob.EXPECT().
+			PatchTasks(gomock.Any(), gomock.Any()).Return(nil),
+		suite.goalStateDriver.EXPECT().
+			EnqueueTask(testTask.JobId, testTask.InstanceId, gomock.Any()).Return(),
+		suite.cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH),
+		suite.goalStateDriver.EXPECT().
+			EnqueueJobWithDefaultDelay(testTask.JobId, job.JobType_BATCH),
+		suite.resMgrClient.EXPECT().
+			KillTasks(gomock.Any(), &resmgrsvc.KillTasksRequest{
+				Tasks: []*peloton.TaskID{taskID},
+			}).
+			Return(&resmgrsvc.KillTasksResponse{}, nil),
 	)
 
 	suite.pp.processPlacement(context.Background(), p)
@@ -525,6 +592,8 @@ func (suite *PlacementTestSuite) TestTaskPlacementPlacementResMgrError() {
 			PatchTasks(gomock.Any(), gomock.Any()).Return(nil),
 		suite.cachedTask.EXPECT().
 			GetRuntime(gomock.Any()).Return(testTask.Runtime, nil),
+		suite.goalStateDriver.EXPECT().
+			IsPaused(testTask.JobId).Return(false),
 		suite.taskLauncher.EXPECT().
 			CreateLaunchableTasks(gomock.Any(), gomock.Any()).Return(nil, nil),
 		suite.taskLauncher.EXPECT().
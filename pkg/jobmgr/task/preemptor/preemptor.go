@@ -214,8 +214,8 @@ func (p *preemptor) preemptTasks(
 			errs = multierror.Append(errs, err)
 		} else {
 			p.goalStateDriver.EnqueueTask(jobID, uint32(instanceID), time.Now())
-			goalstate.EnqueueJobWithDefaultDelay(
-				jobID, p.goalStateDriver, cachedJob)
+			p.goalStateDriver.EnqueueJobWithDefaultDelay(
+				jobID, cachedJob.GetJobType())
 		}
 	}
 	return errs.ErrorOrNil()
@@ -297,9 +297,22 @@ func getRuntimeDiffForPreempt(
 	taskReason resmgr.PreemptionReason,
 	taskRuntime *pbtask.RuntimeInfo,
 	preemptPolicy *pbtask.PreemptionPolicy) jobmgrcommon.RuntimeDiff {
+	tsReason := pbtask.TerminationStatus_TERMINATION_STATUS_REASON_INVALID
+	switch taskReason {
+	case resmgr.PreemptionReason_PREEMPTION_REASON_HOST_MAINTENANCE:
+		tsReason = pbtask.TerminationStatus_TERMINATION_STATUS_REASON_KILLED_HOST_MAINTENANCE
+	case resmgr.PreemptionReason_PREEMPTION_REASON_REVOKE_RESOURCES:
+		tsReason = pbtask.TerminationStatus_TERMINATION_STATUS_REASON_PREEMPTED_RESOURCES
+	}
+
+	// the termination status is set whether the task is restarted or not,
+	// as the current run of the task is terminated in both cases
 	runtimeDiff := jobmgrcommon.RuntimeDiff{
 		jobmgrcommon.MessageField: _msgPreemptingRunningTask,
 		jobmgrcommon.ReasonField:  taskReason.String(),
+		jobmgrcommon.TerminationStatusField: &pbtask.TerminationStatus{
+			Reason: tsReason,
+		},
 	}
 
 	if preemptPolicy != nil && preemptPolicy.GetKillOnPreempt() {
@@ -312,15 +325,6 @@ func getRuntimeDiffForPreempt(
 			// kill the task if GetKillOnPreempt is true
 			runtimeDiff[jobmgrcommon.GoalStateField] = pbtask.TaskState_KILLED
 		}
-		tsReason := pbtask.TerminationStatus_TERMINATION_STATUS_REASON_INVALID
-		switch taskReason {
-		case resmgr.PreemptionReason_PREEMPTION_REASON_HOST_MAINTENANCE:
-			tsReason = pbtask.TerminationStatus_TERMINATION_STATUS_REASON_KILLED_HOST_MAINTENANCE
-		case resmgr.PreemptionReason_PREEMPTION_REASON_REVOKE_RESOURCES:
-			tsReason = pbtask.TerminationStatus_TERMINATION_STATUS_REASON_PREEMPTED_RESOURCES
-		}
-		runtimeDiff[jobmgrcommon.TerminationStatusField] =
-			&pbtask.TerminationStatus{Reason: tsReason}
 		return runtimeDiff
 	}
 
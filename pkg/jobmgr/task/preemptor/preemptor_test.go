@@ -268,12 +268,7 @@ func (suite *PreemptorTestSuite) TestPreemptionCycle() {
 		Times(3)
 	cachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH).Times(6)
 	suite.goalStateDriver.EXPECT().
-		JobRuntimeDuration(job.JobType_BATCH).
-		Return(1 * time.Second).Times(3)
-	suite.goalStateDriver.EXPECT().
-		EnqueueJob(gomock.Any(), gomock.Any()).
-		Return().
-		Times(3)
+		EnqueueJobWithDefaultDelay(gomock.Any(), job.JobType_BATCH).Times(3)
 
 	err := suite.preemptor.performPreemptionCycle()
 	suite.NoError(err)
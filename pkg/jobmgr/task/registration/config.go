@@ -0,0 +1,85 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registration
+
+import (
+	"time"
+)
+
+const (
+	_defaultMaxAttempts      = 3
+	_defaultRetryInterval    = 1 * time.Second
+	_defaultMaxRetryInterval = 1 * time.Minute
+	_defaultTimeout          = 10 * time.Second
+	_defaultNumWorkers       = 16
+)
+
+// Config is the config of the hooks registering the instances of the
+// service jobs in an external discovery system, such as a DNS or a load
+// balancer, once they are ready, and deregistering them before they are
+// killed.
+type Config struct {
+	// Enabled turns on the registration hooks
+	Enabled bool `yaml:"enabled"`
+
+	// RegisterURL is the endpoint called with a POST of the instance when
+	// it becomes ready, i.e. running and healthy if it has a health check
+	RegisterURL string `yaml:"register_url"`
+
+	// DeregisterURL is the endpoint called with a POST of the instance
+	// when it is not ready anymore, and before it is killed
+	DeregisterURL string `yaml:"deregister_url"`
+
+	// MaxAttempts is the number of times a call is attempted in a row.
+	// A call failing all its attempts is queued again later, until it
+	// succeeds or is superseded by another call for the instance.
+	MaxAttempts int `yaml:"max_attempts"`
+
+	// RetryInterval is the delay after the first failed attempt of a
+	// call, the delay doubles after each failed attempt
+	RetryInterval time.Duration `yaml:"retry_interval"`
+
+	// MaxRetryInterval is the maximum delay between two attempts of a
+	// call
+	MaxRetryInterval time.Duration `yaml:"max_retry_interval"`
+
+	// Timeout is the timeout of each attempt of a call
+	Timeout time.Duration `yaml:"timeout"`
+
+	// NumWorkers is the number of calls made concurrently. The calls for
+	// the same instance are always made in order by the same worker.
+	NumWorkers int `yaml:"num_workers"`
+}
+
+func (c *Config) normalize() {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = _defaultMaxAttempts
+	}
+	if c.RetryInterval == 0 {
+		c.RetryInterval = _defaultRetryInterval
+	}
+	if c.MaxRetryInterval == 0 {
+		c.MaxRetryInterval = _defaultMaxRetryInterval
+	}
+	if c.MaxRetryInterval < c.RetryInterval {
+		c.MaxRetryInterval = c.RetryInterval
+	}
+	if c.Timeout == 0 {
+		c.Timeout = _defaultTimeout
+	}
+	if c.NumWorkers <= 0 {
+		c.NumWorkers = _defaultNumWorkers
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registration
+
+import (
+	"github.com/uber-go/tally"
+)
+
+// Metrics is the struct containing all the counters that track
+// internal state of the registration hooks
+type Metrics struct {
+	Register     tally.Counter
+	RegisterFail tally.Counter
+
+	Deregister     tally.Counter
+	DeregisterFail tally.Counter
+
+	CallRetry tally.Counter
+}
+
+// NewMetrics returns a new Metrics struct, with all metrics
+// initialized and rooted at the given tally.Scope
+func NewMetrics(scope tally.Scope) *Metrics {
+	successScope := scope.Tagged(map[string]string{"result": "success"})
+	failScope := scope.Tagged(map[string]string{"result": "fail"})
+
+	return &Metrics{
+		Register:     successScope.Counter("register"),
+		RegisterFail: failScope.Counter("register"),
+
+		Deregister:     successScope.Counter("deregister"),
+		DeregisterFail: failScope.Counter("deregister"),
+
+		CallRetry: scope.Counter("call_retry"),
+	}
+}
@@ -0,0 +1,389 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	"github.com/uber/peloton/.gen/peloton/private/models"
+
+	"github.com/uber/peloton/pkg/common/util"
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/uber-go/tally"
+)
+
+const _listenerName = "RegistrationHooks"
+
+// Instance is the body of the registration and deregistration calls.
+type Instance struct {
+	JobID      string            `json:"job_id"`
+	InstanceID uint32            `json:"instance_id"`
+	TaskID     string            `json:"task_id"`
+	Host       string            `json:"host"`
+	Ports      map[string]uint32 `json:"ports,omitempty"`
+}
+
+// Hooks registers the instances of the service jobs in an external
+// discovery system once they are ready, and deregisters them once they
+// are not. It is notified of the changes of the tasks as a job and task
+// listener of the cache.
+type Hooks interface {
+	cached.JobTaskListener
+
+	// Deregister deregisters the instance if it is registered, and waits
+	// for the deregistration to complete. It is called before killing the
+	// instance, so that it does not receive traffic while draining. A
+	// failed deregistration is retried in the background.
+	Deregister(ctx context.Context, jobID *peloton.JobID, instanceID uint32) error
+
+	// Recover rebuilds the registration of an instance recovered from
+	// storage when the job manager gains leadership, as the instances
+	// registered by the previous leader are not known. The instance is
+	// registered again if it is ready, so the register endpoint must
+	// accept registering an instance twice.
+	Recover(
+		jobID *peloton.JobID,
+		instanceID uint32,
+		jobType job.JobType,
+		runtime *task.RuntimeInfo)
+}
+
+// call is a registration or deregistration call queued to a worker.
+type call struct {
+	// Peloton task ID of the instance
+	taskID   string
+	url      string
+	register bool
+	instance *Instance
+	// failures is the number of failed attempts of the call
+	failures int
+	// done receives the result of the call if set
+	done chan error
+}
+
+// worker makes the calls queued to it in order.
+type worker struct {
+	sync.Mutex
+	calls  []*call
+	signal chan struct{}
+}
+
+type hooks struct {
+	sync.Mutex
+
+	cfg     Config
+	client  *http.Client
+	workers []*worker
+	metrics *Metrics
+
+	// registered are the registered instances, or being registered,
+	// indexed by their Peloton task ID
+	registered map[string]*Instance
+}
+
+// NewHooks returns the registration hooks, and starts their workers.
+func NewHooks(cfg Config, client *http.Client, parentScope tally.Scope) Hooks {
+	cfg.normalize()
+	h := &hooks{
+		cfg:        cfg,
+		client:     client,
+		metrics:    NewMetrics(parentScope.SubScope("registration")),
+		registered: make(map[string]*Instance),
+	}
+	for i := 0; i < cfg.NumWorkers; i++ {
+		w := &worker{signal: make(chan struct{}, 1)}
+		h.workers = append(h.workers, w)
+		go h.run(w)
+	}
+	return h
+}
+
+// Name returns a user-friendly name for the listener
+func (h *hooks) Name() string {
+	return _listenerName
+}
+
+// JobRuntimeChanged is invoked when the runtime for a job is updated
+// in cache and persistent store.
+func (h *hooks) JobRuntimeChanged(
+	jobID *peloton.JobID,
+	jobType job.JobType,
+	runtime *job.RuntimeInfo) {
+}
+
+// UpdateChanged is invoked when the state or the progress of an update
+// of a job is updated in cache and persistent store.
+func (h *hooks) UpdateChanged(
+	jobID *peloton.JobID,
+	updateInfo *models.UpdateModel,
+	instancesDone []uint32) {
+}
+
+// TaskRuntimeChanged is invoked when the runtime for a task is updated
+// in cache and persistent store. It queues the registration of the task
+// if it became ready, and its deregistration if it is not ready anymore.
+func (h *hooks) TaskRuntimeChanged(
+	jobID *peloton.JobID,
+	instanceID uint32,
+	jobType job.JobType,
+	runtime *task.RuntimeInfo,
+	labels []*peloton.Label,
+	respoolID *peloton.ResourcePoolID) {
+	if jobType != job.JobType_SERVICE || jobID == nil || runtime == nil {
+		return
+	}
+
+	taskID := util.CreatePelotonTaskID(jobID.GetValue(), instanceID)
+	mesosTaskID := runtime.GetMesosTaskId().GetValue()
+
+	h.Lock()
+	defer h.Unlock()
+
+	registered := h.registered[taskID]
+	if registered != nil &&
+		(!isReady(runtime) || registered.TaskID != mesosTaskID) {
+		delete(h.registered, taskID)
+		h.enqueue(taskID, &call{url: h.cfg.DeregisterURL, instance: registered})
+		registered = nil
+	}
+
+	if registered == nil && isReady(runtime) {
+		instance := &Instance{
+			JobID:      jobID.GetValue(),
+			InstanceID: instanceID,
+			TaskID:     mesosTaskID,
+			Host:       runtime.GetHost(),
+			Ports:      runtime.GetPorts(),
+		}
+		h.registered[taskID] = instance
+		h.enqueue(taskID, &call{
+			url:      h.cfg.RegisterURL,
+			register: true,
+			instance: instance,
+		})
+	}
+}
+
+// Recover implements Hooks.
+func (h *hooks) Recover(
+	jobID *peloton.JobID,
+	instanceID uint32,
+	jobType job.JobType,
+	runtime *task.RuntimeInfo) {
+	h.TaskRuntimeChanged(jobID, instanceID, jobType, runtime, nil, nil)
+}
+
+// Deregister implements Hooks.
+func (h *hooks) Deregister(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	instanceID uint32) error {
+	taskID := util.CreatePelotonTaskID(jobID.GetValue(), instanceID)
+
+	// If the instance is not registered, an empty call is still queued to
+	// wait for the calls of the instance already queued to complete.
+	c := &call{done: make(chan error, 1)}
+	h.Lock()
+	registered := h.registered[taskID]
+	if registered != nil {
+		delete(h.registered, taskID)
+		c.url = h.cfg.DeregisterURL
+		c.instance = registered
+	}
+	h.enqueue(taskID, c)
+	h.Unlock()
+
+	select {
+	case err := <-c.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enqueue queues a call to the worker of the task, so that the calls of
+// a task are made in order. Must be called with the lock held.
+func (h *hooks) enqueue(taskID string, c *call) {
+	hash := fnv.New32a()
+	hash.Write([]byte(taskID))
+	w := h.workers[hash.Sum32()%uint32(len(h.workers))]
+
+	c.taskID = taskID
+	w.Lock()
+	w.calls = append(w.calls, c)
+	w.Unlock()
+
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+// run makes the calls queued to the worker.
+func (h *hooks) run(w *worker) {
+	for range w.signal {
+		for {
+			w.Lock()
+			if len(w.calls) == 0 {
+				w.Unlock()
+				break
+			}
+			c := w.calls[0]
+			w.calls = w.calls[1:]
+			w.Unlock()
+
+			err := h.send(c)
+			if c.done != nil {
+				c.done <- err
+			}
+			if err != nil {
+				h.retry(c)
+			}
+		}
+	}
+}
+
+// retry queues a failed call again after a backoff, unless the instance
+// was registered or deregistered by another call since.
+func (h *hooks) retry(c *call) {
+	time.AfterFunc(h.backoff(c.failures), func() {
+		h.Lock()
+		defer h.Unlock()
+
+		registered := h.registered[c.taskID]
+		if c.register && registered != c.instance {
+			return
+		}
+		if !c.register && registered != nil &&
+			registered.TaskID == c.instance.TaskID {
+			return
+		}
+		h.metrics.CallRetry.Inc(1)
+		h.enqueue(c.taskID, &call{
+			url:      c.url,
+			register: c.register,
+			instance: c.instance,
+			failures: c.failures,
+		})
+	})
+}
+
+// backoff returns the delay before attempting a call again after a
+// number of failed attempts.
+func (h *hooks) backoff(failures int) time.Duration {
+	delay := h.cfg.RetryInterval
+	for i := 1; i < failures && delay < h.cfg.MaxRetryInterval; i++ {
+		delay *= 2
+	}
+	if delay > h.cfg.MaxRetryInterval {
+		delay = h.cfg.MaxRetryInterval
+	}
+	return delay
+}
+
+// send makes a call, retrying it up to the maximum number of attempts.
+func (h *hooks) send(c *call) error {
+	if c.instance == nil || len(c.url) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(c.instance)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = h.post(c.url, body)
+		if err == nil {
+			break
+		}
+		c.failures++
+		if attempt >= h.cfg.MaxAttempts {
+			break
+		}
+		h.metrics.CallRetry.Inc(1)
+		time.Sleep(h.backoff(c.failures))
+	}
+
+	switch {
+	case c.register && err == nil:
+		h.metrics.Register.Inc(1)
+	case c.register:
+		h.metrics.RegisterFail.Inc(1)
+	case err == nil:
+		h.metrics.Deregister.Inc(1)
+	default:
+		h.metrics.DeregisterFail.Inc(1)
+	}
+
+	if err != nil {
+		log.WithError(err).
+			WithFields(log.Fields{
+				"job_id":      c.instance.JobID,
+				"instance_id": c.instance.InstanceID,
+				"task_id":     c.instance.TaskID,
+				"register":    c.register,
+			}).
+			Error("failed to call registration hook, retrying later")
+	}
+	return err
+}
+
+// post posts the instance to the url, and fails if it does not return a
+// 2xx status.
+func (h *hooks) post(url string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+// isReady returns whether the task can receive traffic, i.e. it is
+// running and healthy if it has a health check.
+func isReady(runtime *task.RuntimeInfo) bool {
+	return runtime.GetState() == task.TaskState_RUNNING &&
+		(runtime.GetHealthy() == task.HealthState_DISABLED ||
+			runtime.GetHealthy() == task.HealthState_HEALTHY)
+}
@@ -0,0 +1,259 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registration
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	mesos "github.com/uber/peloton/.gen/mesos/v1"
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+)
+
+// registryCall is a call received by the test registry.
+type registryCall struct {
+	path     string
+	instance Instance
+}
+
+type RegistrationTestSuite struct {
+	suite.Suite
+
+	server *httptest.Server
+	hooks  Hooks
+
+	mu             sync.Mutex
+	calls          []registryCall
+	succeeded      map[string]int
+	failRegister   bool
+	failDeregister bool
+
+	jobID *peloton.JobID
+}
+
+func TestRegistration(t *testing.T) {
+	suite.Run(t, new(RegistrationTestSuite))
+}
+
+func (suite *RegistrationTestSuite) SetupTest() {
+	suite.calls = nil
+	suite.succeeded = make(map[string]int)
+	suite.failRegister = false
+	suite.failDeregister = false
+	suite.server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var instance Instance
+			suite.NoError(json.NewDecoder(r.Body).Decode(&instance))
+
+			suite.mu.Lock()
+			defer suite.mu.Unlock()
+			suite.calls = append(suite.calls, registryCall{
+				path:     r.URL.Path,
+				instance: instance,
+			})
+			if (suite.failRegister && r.URL.Path == "/register") ||
+				(suite.failDeregister && r.URL.Path == "/deregister") {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			suite.succeeded[r.URL.Path]++
+		}))
+	suite.hooks = NewHooks(Config{
+		Enabled:       true,
+		RegisterURL:   suite.server.URL + "/register",
+		DeregisterURL: suite.server.URL + "/deregister",
+		MaxAttempts:   2,
+		RetryInterval: 1,
+		// failed calls are retried right away by the tests
+		MaxRetryInterval: time.Millisecond,
+	}, &http.Client{}, tally.NoopScope)
+	suite.jobID = &peloton.JobID{Value: "c8e8ec27-6bb6-4c1d-a27f-e4e4a25b1a4d"}
+}
+
+func (suite *RegistrationTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+// getCalls returns the paths of the calls received by the registry.
+func (suite *RegistrationTestSuite) getCalls() []string {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	var paths []string
+	for _, c := range suite.calls {
+		paths = append(paths, c.path)
+	}
+	return paths
+}
+
+// getSucceeded returns the number of successful calls of a path
+// received by the registry.
+func (suite *RegistrationTestSuite) getSucceeded(path string) int {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	return suite.succeeded[path]
+}
+
+// setFail sets whether the registry fails the calls.
+func (suite *RegistrationTestSuite) setFail(register, deregister bool) {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	suite.failRegister = register
+	suite.failDeregister = deregister
+}
+
+// waitFor waits for cond to be true, and fails the test if it is not
+// within a second.
+func (suite *RegistrationTestSuite) waitFor(cond func() bool) {
+	for i := 0; i < 1000; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	suite.Fail("condition not met")
+}
+
+// newRuntime returns the runtime of a run of a task.
+func newRuntime(
+	mesosTaskID string,
+	state task.TaskState,
+	healthy task.HealthState) *task.RuntimeInfo {
+	return &task.RuntimeInfo{
+		MesosTaskId: &mesos.TaskID{Value: &mesosTaskID},
+		State:       state,
+		Healthy:     healthy,
+		Host:        "host1",
+		Ports:       map[string]uint32{"http": 31000},
+	}
+}
+
+// TestRegisterOnReadiness tests that an instance is registered once it is
+// running and healthy, and deregistered once it is unhealthy
+func (suite *RegistrationTestSuite) TestRegisterOnReadiness() {
+	for _, runtime := range []*task.RuntimeInfo{
+		newRuntime("run-1", task.TaskState_RUNNING, task.HealthState_HEALTH_UNKNOWN),
+		newRuntime("run-1", task.TaskState_RUNNING, task.HealthState_HEALTHY),
+		newRuntime("run-1", task.TaskState_RUNNING, task.HealthState_HEALTHY),
+		newRuntime("run-1", task.TaskState_RUNNING, task.HealthState_UNHEALTHY),
+	} {
+		suite.hooks.TaskRuntimeChanged(
+			suite.jobID, 0, job.JobType_SERVICE, runtime, nil, nil)
+	}
+
+	// wait for the queued calls
+	suite.NoError(suite.hooks.Deregister(context.Background(), suite.jobID, 0))
+	suite.Equal([]string{"/register", "/deregister"}, suite.getCalls())
+	suite.Equal(Instance{
+		JobID:      suite.jobID.GetValue(),
+		InstanceID: 0,
+		TaskID:     "run-1",
+		Host:       "host1",
+		Ports:      map[string]uint32{"http": 31000},
+	}, suite.calls[0].instance)
+}
+
+// TestRegisterNewRun tests that the previous run of an instance is
+// deregistered before its new run is registered
+func (suite *RegistrationTestSuite) TestRegisterNewRun() {
+	suite.hooks.TaskRuntimeChanged(suite.jobID, 0, job.JobType_SERVICE,
+		newRuntime("run-1", task.TaskState_RUNNING, task.HealthState_DISABLED), nil, nil)
+	suite.hooks.TaskRuntimeChanged(suite.jobID, 0, job.JobType_SERVICE,
+		newRuntime("run-2", task.TaskState_RUNNING, task.HealthState_DISABLED), nil, nil)
+
+	suite.NoError(suite.hooks.Deregister(context.Background(), suite.jobID, 0))
+	suite.Equal(
+		[]string{"/register", "/deregister", "/register", "/deregister"},
+		suite.getCalls())
+	suite.Equal("run-1", suite.calls[1].instance.TaskID)
+	suite.Equal("run-2", suite.calls[3].instance.TaskID)
+}
+
+// TestDeregisterFailure tests that a failed deregistration is retried
+// in the background
+func (suite *RegistrationTestSuite) TestDeregisterFailure() {
+	suite.hooks.TaskRuntimeChanged(suite.jobID, 0, job.JobType_SERVICE,
+		newRuntime("run-1", task.TaskState_RUNNING, task.HealthState_DISABLED), nil, nil)
+
+	suite.setFail(false, true)
+	suite.Error(suite.hooks.Deregister(context.Background(), suite.jobID, 0))
+	suite.Equal(
+		[]string{"/register", "/deregister", "/deregister"},
+		suite.getCalls()[:3])
+	suite.waitFor(func() bool {
+		return len(suite.getCalls()) > 3
+	})
+
+	suite.setFail(false, false)
+	suite.waitFor(func() bool {
+		return suite.getSucceeded("/deregister") == 1
+	})
+
+	// the instance is not registered anymore
+	calls := len(suite.getCalls())
+	suite.NoError(suite.hooks.Deregister(context.Background(), suite.jobID, 0))
+	suite.Len(suite.getCalls(), calls)
+}
+
+// TestRegisterFailure tests that a failed registration is retried in
+// the background
+func (suite *RegistrationTestSuite) TestRegisterFailure() {
+	suite.setFail(true, false)
+	suite.hooks.TaskRuntimeChanged(suite.jobID, 0, job.JobType_SERVICE,
+		newRuntime("run-1", task.TaskState_RUNNING, task.HealthState_DISABLED), nil, nil)
+	suite.waitFor(func() bool {
+		return len(suite.getCalls()) > 2
+	})
+
+	suite.setFail(false, false)
+	suite.waitFor(func() bool {
+		return suite.getSucceeded("/register") == 1
+	})
+
+	suite.NoError(suite.hooks.Deregister(context.Background(), suite.jobID, 0))
+	suite.Equal(1, suite.getSucceeded("/deregister"))
+}
+
+// TestRecover tests that the registration of a recovered instance is
+// rebuilt
+func (suite *RegistrationTestSuite) TestRecover() {
+	suite.hooks.Recover(suite.jobID, 0, job.JobType_SERVICE,
+		newRuntime("run-1", task.TaskState_RUNNING, task.HealthState_DISABLED))
+	suite.hooks.Recover(suite.jobID, 1, job.JobType_SERVICE,
+		newRuntime("run-1", task.TaskState_KILLED, task.HealthState_DISABLED))
+
+	suite.NoError(suite.hooks.Deregister(context.Background(), suite.jobID, 0))
+	suite.NoError(suite.hooks.Deregister(context.Background(), suite.jobID, 1))
+	suite.Equal([]string{"/register", "/deregister"}, suite.getCalls())
+}
+
+// TestSkipBatchJobs tests that the instances of batch jobs are not
+// registered
+func (suite *RegistrationTestSuite) TestSkipBatchJobs() {
+	suite.hooks.TaskRuntimeChanged(suite.jobID, 0, job.JobType_BATCH,
+		newRuntime("run-1", task.TaskState_RUNNING, task.HealthState_DISABLED), nil, nil)
+
+	suite.NoError(suite.hooks.Deregister(context.Background(), suite.jobID, 0))
+	suite.Empty(suite.getCalls())
+}
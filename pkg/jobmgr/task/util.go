@@ -95,7 +95,7 @@ func KillOrphanTask(
 		if state == task.TaskState_KILLING {
 			err = ShutdownMesosExecutor(ctx, hostmgrClient, mesosTaskID, agentID)
 		} else {
-			err = KillTask(ctx, hostmgrClient, mesosTaskID, "")
+			err = KillTask(ctx, hostmgrClient, mesosTaskID, "", nil)
 		}
 		if err != nil {
 			log.WithError(err).
@@ -107,12 +107,14 @@ func KillOrphanTask(
 	return nil
 }
 
-// KillTask kills a task given its mesos task ID
+// KillTask kills a task given its mesos task ID. The kill grace period,
+// if set, overrides the one the task was launched with.
 func KillTask(
 	ctx context.Context,
 	hostmgrClient hostsvc.InternalHostServiceYARPCClient,
 	taskID *mesos_v1.TaskID,
 	hostToReserve string,
+	killGracePeriod *task.KillGracePeriod,
 ) error {
 	newCtx := ctx
 	_, ok := ctx.Deadline()
@@ -122,19 +124,39 @@ func KillTask(
 		defer cancelFunc()
 	}
 
+	killPolicy := newKillPolicy(killGracePeriod)
 	if len(hostToReserve) != 0 {
-		return killAndReserveHost(newCtx, hostmgrClient, taskID, hostToReserve)
+		return killAndReserveHost(
+			newCtx, hostmgrClient, taskID, hostToReserve, killPolicy)
 	}
 
-	return killHost(newCtx, hostmgrClient, taskID)
+	return killHost(newCtx, hostmgrClient, taskID, killPolicy)
+}
+
+// newKillPolicy returns the Mesos kill policy for the kill grace period,
+// or nil to use the kill policy the task was launched with.
+func newKillPolicy(
+	killGracePeriod *task.KillGracePeriod) *mesos_v1.KillPolicy {
+	if killGracePeriod == nil {
+		return nil
+	}
+	gracePeriodNsec := (time.Duration(killGracePeriod.GetSeconds()) *
+		time.Second).Nanoseconds()
+	return &mesos_v1.KillPolicy{
+		GracePeriod: &mesos_v1.DurationInfo{
+			Nanoseconds: &gracePeriodNsec,
+		},
+	}
 }
 
 func killHost(
 	ctx context.Context,
 	hostmgrClient hostsvc.InternalHostServiceYARPCClient,
-	taskID *mesos_v1.TaskID) error {
+	taskID *mesos_v1.TaskID,
+	killPolicy *mesos_v1.KillPolicy) error {
 	req := &hostsvc.KillTasksRequest{
-		TaskIds: []*mesos_v1.TaskID{taskID},
+		TaskIds:    []*mesos_v1.TaskID{taskID},
+		KillPolicy: killPolicy,
 	}
 	res, err := hostmgrClient.KillTasks(ctx, req)
 	if err != nil {
@@ -157,6 +179,7 @@ func killAndReserveHost(
 	hostmgrClient hostsvc.InternalHostServiceYARPCClient,
 	mesosTaskID *mesos_v1.TaskID,
 	hostToReserve string,
+	killPolicy *mesos_v1.KillPolicy,
 ) error {
 	taskID, err := util.ParseTaskIDFromMesosTaskID(mesosTaskID.GetValue())
 	if err != nil {
@@ -167,6 +190,7 @@ func killAndReserveHost(
 		Entries: []*hostsvc.KillAndReserveTasksRequest_Entry{
 			{Id: &peloton.TaskID{Value: taskID}, TaskId: mesosTaskID, HostToReserve: hostToReserve},
 		},
+		KillPolicy: killPolicy,
 	}
 	res, err := hostmgrClient.KillAndReserveTasks(ctx, req)
 	if err != nil {
@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	mesos "github.com/uber/peloton/.gen/mesos/v1"
 	"github.com/uber/peloton/.gen/peloton/api/v0/job"
@@ -169,6 +170,27 @@ func (suite *JobmgrTaskUtilTestSuite) TestKillOrphanTaskKilling() {
 	suite.Error(err)
 }
 
+// TestKillTaskWithKillGracePeriod tests that the kill grace period of
+// KillTask is sent as the kill policy of the task
+func (suite *JobmgrTaskUtilTestSuite) TestKillTaskWithKillGracePeriod() {
+	taskID := &mesos.TaskID{Value: &suite.mesosTaskID}
+	gracePeriodNsec := int64(5 * time.Second)
+
+	req := suite.buildKillTasksReq()
+	req.KillPolicy = &mesos.KillPolicy{
+		GracePeriod: &mesos.DurationInfo{Nanoseconds: &gracePeriodNsec},
+	}
+	suite.mockHostMgr.EXPECT().KillTasks(gomock.Any(), req).
+		Return(&hostsvc.KillTasksResponse{}, nil)
+	err := KillTask(
+		suite.ctx,
+		suite.mockHostMgr,
+		taskID,
+		"",
+		&task.KillGracePeriod{Seconds: 5})
+	suite.NoError(err)
+}
+
 // TestKillTaskInvalidTaskIDs tests InvalidTaskIDs error in KillTask
 func (suite *JobmgrTaskUtilTestSuite) TestKillTaskInvalidTaskIDs() {
 	taskID := &mesos.TaskID{Value: &suite.mesosTaskID}
@@ -183,7 +205,7 @@ func (suite *JobmgrTaskUtilTestSuite) TestKillTaskInvalidTaskIDs() {
 	}
 	suite.mockHostMgr.EXPECT().KillTasks(
 		gomock.Any(), suite.buildKillTasksReq()).Return(resp, nil)
-	err := KillTask(suite.ctx, suite.mockHostMgr, taskID, "")
+	err := KillTask(suite.ctx, suite.mockHostMgr, taskID, "", nil)
 	suite.Error(err)
 	suite.Equal(err.Error(), randomErrorStr)
 }
@@ -202,7 +224,7 @@ func (suite *JobmgrTaskUtilTestSuite) TestKillTaskKillFailure() {
 	}
 	suite.mockHostMgr.EXPECT().KillTasks(
 		gomock.Any(), suite.buildKillTasksReq()).Return(resp, nil)
-	err := KillTask(suite.ctx, suite.mockHostMgr, taskID, "")
+	err := KillTask(suite.ctx, suite.mockHostMgr, taskID, "", nil)
 	suite.Error(err)
 	suite.Equal(err.Error(), randomErrorStr)
 }
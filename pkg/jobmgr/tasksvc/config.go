@@ -0,0 +1,163 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasksvc
+
+import (
+	"time"
+
+	"github.com/uber/peloton/pkg/jobmgr/util/handler"
+)
+
+// Config for task service
+type Config struct {
+	// Rate limits of the task service procedures
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+
+	// Deprecation of the task service procedures
+	Deprecation DeprecationConfig `yaml:"deprecation"`
+
+	// Limits on the results of the queries of tasks and pod events
+	Pagination handler.PaginationConfig `yaml:"pagination"`
+
+	// Config of RunTaskCommand, executing commands in the containers
+	// of the tasks
+	TaskCommand TaskCommandConfig `yaml:"task_command"`
+
+	// Config of the restarts of tasks in waves
+	Restart RestartConfig `yaml:"restart"`
+}
+
+// TaskCommandConfig for RunTaskCommand. RunTaskCommand is disabled unless
+// grants are configured, and every command run is written to the audit
+// log with the name of the grant of its caller.
+type TaskCommandConfig struct {
+	// Grants of the callers allowed to run task commands
+	Grants []TaskCommandGrant `yaml:"grants"`
+
+	// Token sent to the host manager, which must match the task command
+	// token of its config
+	HostManagerToken string `yaml:"host_manager_token"`
+}
+
+// TaskCommandGrant allows the callers authenticated by its token to run
+// commands in the tasks of its jobs
+type TaskCommandGrant struct {
+	// Name of the grant, recorded in the audit log
+	Name string `yaml:"name"`
+
+	// Token authenticating the callers, which must send it with the
+	// "Authorization: Bearer <token>" header
+	Token string `yaml:"token"`
+
+	// IDs of the jobs whose tasks the callers can run commands in.
+	// All the jobs are allowed if it contains "*".
+	Jobs []string `yaml:"jobs"`
+}
+
+// allowsJob returns whether the grant allows running commands in the
+// tasks of the job.
+func (g *TaskCommandGrant) allowsJob(jobID string) bool {
+	for _, id := range g.Jobs {
+		if id == "*" || id == jobID {
+			return true
+		}
+	}
+	return false
+}
+
+// RateLimitConfig for the task service procedures. Each caller of a
+// procedure is rate limited separately with a token bucket.
+type RateLimitConfig struct {
+	// Flag to enable rate limiting
+	Enabled bool `yaml:"enabled"`
+
+	// Rate limit of the procedures which are not in Procedures
+	Default ProcedureRateLimit `yaml:"default"`
+
+	// Rate limits keyed by procedure name, such as "Query"
+	// or "GetPodEvents"
+	Procedures map[string]ProcedureRateLimit `yaml:"procedures"`
+
+	// Maximum number of token buckets kept, one per caller of a procedure.
+	// The buckets of the least recent callers are evicted beyond it, which
+	// resets their limits. Defaults to 10000.
+	MaxBuckets int `yaml:"max_buckets"`
+
+	// Time a token bucket is kept after the last request of its caller.
+	// It should be longer than the time to refill a bucket, burst / rate,
+	// so that expiring a bucket does not reset the limit of its caller.
+	// Defaults to 10m.
+	BucketTTL time.Duration `yaml:"bucket_ttl"`
+}
+
+// ProcedureRateLimit is the rate limit of each caller of a procedure
+type ProcedureRateLimit struct {
+	// Number of requests per second allowed for each caller.
+	// The procedure is not rate limited if zero.
+	Rate float64 `yaml:"rate"`
+
+	// Maximum number of requests of a caller allowed at once.
+	// Defaults to the rate, with a minimum of 1.
+	Burst int `yaml:"burst"`
+}
+
+// getProcedureRateLimit returns the rate limit of the procedure.
+func (c *RateLimitConfig) getProcedureRateLimit(
+	procedure string) ProcedureRateLimit {
+	limit, ok := c.Procedures[procedure]
+	if !ok {
+		limit = c.Default
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = int(limit.Rate)
+		if limit.Burst < 1 {
+			limit.Burst = 1
+		}
+	}
+	return limit
+}
+
+// DeprecationConfig for the task service procedures, which are replaced
+// by the v1alpha pod service. The requests to the deprecated procedures
+// are counted by caller, and the requests of the rejected callers can be
+// rejected to enforce the deprecation. The caller is advisory, as it is
+// the name the client sets for itself.
+type DeprecationConfig struct {
+	// Flag to enable counting the requests to the deprecated procedures
+	Enabled bool `yaml:"enabled"`
+
+	// Names of the deprecated procedures, such as "Query" or "Start".
+	// All the procedures are deprecated if empty.
+	Procedures []string `yaml:"procedures"`
+
+	// Flag to reject the requests of the rejected callers to the
+	// deprecated procedures
+	Enforce bool `yaml:"enforce"`
+
+	// Names of the callers, as set by their yarpc client, whose requests
+	// are rejected when enforced. All the callers are rejected if it
+	// contains "*". The names are not authenticated, so a caller can
+	// avoid the rejection by changing its name.
+	RejectedCallers []string `yaml:"rejected_callers"`
+
+	// Maximum number of callers whose requests are counted and logged by
+	// name, the requests of the other callers are counted as "other".
+	// Defaults to 100.
+	MaxCallers int `yaml:"max_callers"`
+
+	// Message returned to the rejected callers, such as the procedure
+	// replacing the deprecated one
+	Message string `yaml:"message"`
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tasksvc
+
+import (
+	"context"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/uber-go/tally"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const (
+	// _allCallers matches all the callers in the rejected callers
+	_allCallers = "*"
+	// _unknownCaller tags the requests without a caller name
+	_unknownCaller = "unknown"
+	// _otherCaller tags the requests of the callers beyond the maximum
+	// number of callers tracked
+	_otherCaller = "other"
+
+	// _defaultMaxDeprecationCallers is the default number of callers
+	// tracked by the deprecation gate
+	_defaultMaxDeprecationCallers = 100
+)
+
+// deprecationKey identifies a caller of a deprecated procedure
+type deprecationKey struct {
+	procedure string
+	caller    string
+}
+
+// deprecationGate is a yarpc unary inbound middleware which counts the
+// requests to the deprecated procedures by caller, and rejects the
+// requests of the rejected callers when the deprecation is enforced.
+//
+// The caller is the name set by the client in the rpc-caller header, which
+// is not authenticated. The counts are therefore advisory, and the
+// rejection only steers the well-behaved callers to the replacement
+// procedures, it does not control access. Only the first callers up to
+// the maximum are tracked by name, the others are tagged as other callers,
+// so that the metric tags and the callers logged are bounded.
+type deprecationGate struct {
+	sync.Mutex
+
+	config     DeprecationConfig
+	procedures map[string]bool
+	rejected   map[string]bool
+	scope      tally.Scope
+	maxCallers int
+
+	// callers tracked by name
+	callers map[string]bool
+	// callers seen for each deprecated procedure, logged once
+	seen map[deprecationKey]bool
+}
+
+// ensure that deprecationGate implements the yarpc unary inbound middleware
+var _ middleware.UnaryInbound = (*deprecationGate)(nil)
+
+// newDeprecationGate returns a new deprecation gate for the config.
+func newDeprecationGate(
+	config DeprecationConfig,
+	scope tally.Scope,
+) *deprecationGate {
+	g := &deprecationGate{
+		config:     config,
+		procedures: make(map[string]bool),
+		rejected:   make(map[string]bool),
+		scope:      scope.SubScope("deprecation"),
+		maxCallers: config.MaxCallers,
+		callers:    make(map[string]bool),
+		seen:       make(map[deprecationKey]bool),
+	}
+	if g.maxCallers <= 0 {
+		g.maxCallers = _defaultMaxDeprecationCallers
+	}
+	for _, procedure := range config.Procedures {
+		g.procedures[procedure] = true
+	}
+	for _, caller := range config.RejectedCallers {
+		g.rejected[caller] = true
+	}
+	return g
+}
+
+// Handle counts the request if its procedure is deprecated, and rejects
+// it with an unimplemented error if its caller is rejected. Other
+// requests are passed to the handler.
+func (g *deprecationGate) Handle(
+	ctx context.Context,
+	req *transport.Request,
+	resw transport.ResponseWriter,
+	h transport.UnaryHandler,
+) error {
+	procedure := getProcedureName(req.Procedure)
+	if !g.isDeprecated(procedure) {
+		return h.Handle(ctx, req, resw)
+	}
+
+	caller := req.Caller
+	if caller == "" {
+		caller = _unknownCaller
+	}
+	callerTag := g.getCallerTag(caller)
+	scope := g.scope.Tagged(map[string]string{
+		"procedure": procedure,
+		"caller":    callerTag,
+	})
+	scope.Counter("calls").Inc(1)
+
+	rejected := g.isRejected(caller)
+	g.logFirstCall(procedure, callerTag, rejected)
+	if rejected {
+		scope.Counter("rejected").Inc(1)
+		if g.config.Message == "" {
+			return yarpcerrors.UnimplementedErrorf(
+				"%s is deprecated", procedure)
+		}
+		return yarpcerrors.UnimplementedErrorf(
+			"%s is deprecated: %s", procedure, g.config.Message)
+	}
+	return h.Handle(ctx, req, resw)
+}
+
+// isDeprecated returns true if the procedure is deprecated
+func (g *deprecationGate) isDeprecated(procedure string) bool {
+	return len(g.procedures) == 0 || g.procedures[procedure]
+}
+
+// isRejected returns true if the requests of the caller to the
+// deprecated procedures are rejected
+func (g *deprecationGate) isRejected(caller string) bool {
+	return g.config.Enforce && (g.rejected[_allCallers] || g.rejected[caller])
+}
+
+// getCallerTag returns the caller to tag the metrics and the logs of a
+// request with, which is the caller itself unless the maximum number of
+// callers are already tracked.
+func (g *deprecationGate) getCallerTag(caller string) string {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.callers[caller] {
+		return caller
+	}
+	if len(g.callers) >= g.maxCallers {
+		return _otherCaller
+	}
+	g.callers[caller] = true
+	return caller
+}
+
+// logFirstCall logs the first request of a caller to a deprecated
+// procedure, so that the callers to migrate can be found in the logs
+// without logging every request.
+func (g *deprecationGate) logFirstCall(
+	procedure string,
+	caller string,
+	rejected bool,
+) {
+	key := deprecationKey{procedure: procedure, caller: caller}
+
+	g.Lock()
+	seen := g.seen[key]
+	g.seen[key] = true
+	g.Unlock()
+
+	if seen {
+		return
+	}
+	log.WithFields(log.Fields{
+		"procedure": procedure,
+		"caller":    caller,
+		"rejected":  rejected,
+	}).Warn("deprecated procedure called")
+}
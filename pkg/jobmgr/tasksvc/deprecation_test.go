@@ -0,0 +1,143 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tasksvc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// TestDeprecationGateTelemetry tests the requests to the deprecated
+// procedures are counted by caller without being rejected
+func TestDeprecationGateTelemetry(t *testing.T) {
+	scope := tally.NewTestScope("", map[string]string{})
+	gate := newDeprecationGate(DeprecationConfig{
+		Enabled:         true,
+		Procedures:      []string{"Query"},
+		RejectedCallers: []string{"client-a"},
+	}, scope)
+	h := &testUnaryHandler{}
+
+	for _, req := range []*transport.Request{
+		{Caller: "client-a", Procedure: "peloton.api.v0.task.TaskManager::Query"},
+		{Caller: "client-a", Procedure: "peloton.api.v0.task.TaskManager::Query"},
+		{Caller: "", Procedure: "peloton.api.v0.task.TaskManager::Query"},
+		{Caller: "client-a", Procedure: "peloton.api.v0.task.TaskManager::Get"},
+	} {
+		assert.NoError(t, gate.Handle(context.Background(), req, nil, h))
+	}
+	assert.Equal(t, 4, h.calls)
+
+	counters := scope.Snapshot().Counters()
+	assert.Equal(t, int64(2),
+		counters["deprecation.calls+caller=client-a,procedure=Query"].Value())
+	assert.Equal(t, int64(1),
+		counters["deprecation.calls+caller=unknown,procedure=Query"].Value())
+	assert.Nil(t,
+		counters["deprecation.calls+caller=client-a,procedure=Get"])
+	assert.Nil(t,
+		counters["deprecation.rejected+caller=client-a,procedure=Query"])
+}
+
+// TestDeprecationGateEnforce tests the requests of the rejected callers
+// to the deprecated procedures are rejected when enforced
+func TestDeprecationGateEnforce(t *testing.T) {
+	scope := tally.NewTestScope("", map[string]string{})
+	gate := newDeprecationGate(DeprecationConfig{
+		Enabled:         true,
+		Enforce:         true,
+		RejectedCallers: []string{"client-a"},
+		Message:         "use peloton.api.v1alpha.pod.svc.PodService",
+	}, scope)
+	h := &testUnaryHandler{}
+
+	// all the procedures are deprecated when none is configured
+	err := gate.Handle(context.Background(), &transport.Request{
+		Caller:    "client-a",
+		Procedure: "peloton.api.v0.task.TaskManager::Start",
+	}, nil, h)
+	assert.True(t, yarpcerrors.IsUnimplemented(err))
+	assert.Contains(t, err.Error(), "Start is deprecated")
+	assert.Contains(t, err.Error(), "PodService")
+
+	// other callers are not rejected
+	assert.NoError(t, gate.Handle(context.Background(), &transport.Request{
+		Caller:    "client-b",
+		Procedure: "peloton.api.v0.task.TaskManager::Start",
+	}, nil, h))
+	assert.Equal(t, 1, h.calls)
+
+	counters := scope.Snapshot().Counters()
+	assert.Equal(t, int64(1),
+		counters["deprecation.rejected+caller=client-a,procedure=Start"].Value())
+	assert.Equal(t, int64(1),
+		counters["deprecation.calls+caller=client-b,procedure=Start"].Value())
+}
+
+// TestDeprecationGateRejectAll tests all the callers are rejected
+// with the wildcard
+func TestDeprecationGateRejectAll(t *testing.T) {
+	gate := newDeprecationGate(DeprecationConfig{
+		Enabled:         true,
+		Enforce:         true,
+		Procedures:      []string{"Query"},
+		RejectedCallers: []string{"*"},
+	}, tally.NoopScope)
+
+	assert.True(t, gate.isRejected("client-a"))
+	assert.True(t, gate.isRejected(_unknownCaller))
+	assert.True(t, gate.isDeprecated("Query"))
+	assert.False(t, gate.isDeprecated("Get"))
+
+	gate.config.Enforce = false
+	assert.False(t, gate.isRejected("client-a"))
+}
+
+// TestDeprecationGateMaxCallers tests the callers beyond the maximum are
+// counted as other callers, while their requests are still rejected
+func TestDeprecationGateMaxCallers(t *testing.T) {
+	scope := tally.NewTestScope("", map[string]string{})
+	gate := newDeprecationGate(DeprecationConfig{
+		Enabled:         true,
+		Enforce:         true,
+		RejectedCallers: []string{"client-c"},
+		MaxCallers:      2,
+	}, scope)
+	h := &testUnaryHandler{}
+
+	for _, caller := range []string{"client-a", "client-b", "client-c", "client-a"} {
+		gate.Handle(context.Background(), &transport.Request{
+			Caller:    caller,
+			Procedure: "peloton.api.v0.task.TaskManager::Query",
+		}, nil, h)
+	}
+	assert.Equal(t, 3, h.calls)
+	assert.Len(t, gate.callers, 2)
+	assert.Len(t, gate.seen, 3)
+
+	counters := scope.Snapshot().Counters()
+	assert.Equal(t, int64(2),
+		counters["deprecation.calls+caller=client-a,procedure=Query"].Value())
+	assert.Equal(t, int64(1),
+		counters["deprecation.calls+caller=client-b,procedure=Query"].Value())
+	assert.Equal(t, int64(1),
+		counters["deprecation.rejected+caller=other,procedure=Query"].Value())
+	assert.Nil(t,
+		counters["deprecation.calls+caller=client-c,procedure=Query"])
+}
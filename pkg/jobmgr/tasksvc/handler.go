@@ -16,9 +16,19 @@ package tasksvc
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math"
+	"net"
+	"path"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	mesosv1 "github.com/uber/peloton/.gen/mesos/v1"
@@ -29,14 +39,21 @@ import (
 	"github.com/uber/peloton/.gen/peloton/api/v0/task"
 	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
 	"github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc"
+	"github.com/uber/peloton/.gen/peloton/private/models"
 	"github.com/uber/peloton/.gen/peloton/private/resmgrsvc"
 
 	"github.com/uber/peloton/pkg/common"
+	commongoalstate "github.com/uber/peloton/pkg/common/goalstate"
 	"github.com/uber/peloton/pkg/common/leader"
+	"github.com/uber/peloton/pkg/common/rpc"
+	"github.com/uber/peloton/pkg/common/taskconfig"
 	"github.com/uber/peloton/pkg/common/util"
+	"github.com/uber/peloton/pkg/jobmgr/asyncop"
 	"github.com/uber/peloton/pkg/jobmgr/cached"
 	jobmgrcommon "github.com/uber/peloton/pkg/jobmgr/common"
 	"github.com/uber/peloton/pkg/jobmgr/goalstate"
+	jobconfig "github.com/uber/peloton/pkg/jobmgr/job/config"
+	"github.com/uber/peloton/pkg/jobmgr/job/provenance"
 	"github.com/uber/peloton/pkg/jobmgr/logmanager"
 	jobmgr_task "github.com/uber/peloton/pkg/jobmgr/task"
 	"github.com/uber/peloton/pkg/jobmgr/task/activermtask"
@@ -45,8 +62,12 @@ import (
 	"github.com/uber/peloton/pkg/jobmgr/util/handler"
 	jobutil "github.com/uber/peloton/pkg/jobmgr/util/job"
 	taskutil "github.com/uber/peloton/pkg/jobmgr/util/task"
+	updateutil "github.com/uber/peloton/pkg/jobmgr/util/update"
 	"github.com/uber/peloton/pkg/storage"
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
 
+	"github.com/gocql/gocql"
+	"github.com/gogo/protobuf/proto"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/uber-go/tally"
@@ -57,10 +78,68 @@ import (
 const (
 	_rpcTimeout    = 15 * time.Second
 	_frameworkName = "Peloton"
+
+	// _rackAttribute is the Mesos agent attribute holding the rack of a host
+	_rackAttribute = "rack"
+	// _defaultHostFailureLookback is the default lookback window for
+	// instances which have already been moved off the hosts which went down
+	_defaultHostFailureLookback = time.Hour
+	// _defaultTimelineRuns is the default number of runs of a task
+	// returned by GetTaskTimeline
+	_defaultTimelineRuns = 10
+	// _batchStopParallelism is the maximum number of jobs whose tasks are
+	// stopped in parallel by BatchStop
+	_batchStopParallelism = 10
+	// _maxOperationInstances is the maximum number of instances whose
+	// operations are read by GetTaskOperationHistory, and the maximum
+	// number of instances an operation is recorded for. An operation made
+	// on more instances is recorded as made on all the instances.
+	_maxOperationInstances = 100
+	// _unverifiedCallerPrefix prefixes the caller of an operation taken
+	// from the caller header of the call, which is set by the client
+	_unverifiedCallerPrefix = "unverified:"
 )
 
 var (
 	errEmptyFrameworkID = errors.New("framework id is empty")
+
+	// _requeueableStates are the states of a task which has been enqueued
+	// to the resource manager, but has not been reported running by Mesos.
+	_requeueableStates = map[task.TaskState]bool{
+		task.TaskState_PENDING:   true,
+		task.TaskState_READY:     true,
+		task.TaskState_PLACING:   true,
+		task.TaskState_PLACED:    true,
+		task.TaskState_LAUNCHING: true,
+		task.TaskState_LAUNCHED:  true,
+	}
+
+	// _hostmgrReportedStates are the states of a task which the job
+	// manager learns about through the host manager, from the launch of
+	// the task onwards.
+	_hostmgrReportedStates = map[task.TaskState]bool{
+		task.TaskState_LAUNCHED:  true,
+		task.TaskState_STARTING:  true,
+		task.TaskState_RUNNING:   true,
+		task.TaskState_SUCCEEDED: true,
+		task.TaskState_FAILED:    true,
+		task.TaskState_LOST:      true,
+	}
+
+	// _timelineStateOrder orders the events of a run of a task which have
+	// the same timestamp by the lifecycle of the task. Terminal states
+	// come last.
+	_timelineStateOrder = map[task.TaskState]int{
+		task.TaskState_INITIALIZED: 1,
+		task.TaskState_PENDING:     2,
+		task.TaskState_READY:       3,
+		task.TaskState_PLACING:     4,
+		task.TaskState_PLACED:      5,
+		task.TaskState_LAUNCHING:   6,
+		task.TaskState_LAUNCHED:    7,
+		task.TaskState_STARTING:    8,
+		task.TaskState_RUNNING:     9,
+	}
 )
 
 // InitServiceHandler initializes the TaskManager
@@ -71,31 +150,62 @@ func InitServiceHandler(
 	taskStore storage.TaskStore,
 	updateStore storage.UpdateStore,
 	frameworkInfoStore storage.FrameworkInfoStore,
+	ormStore *ormobjects.Store,
 	jobFactory cached.JobFactory,
 	goalStateDriver goalstate.Driver,
 	candidate leader.Candidate,
-	mesosAgentWorkDir string,
+	sandboxLayouts logmanager.SandboxLayoutResolver,
 	hostMgrClientName string,
 	logManager logmanager.LogManager,
-	activeRMTasks activermtask.ActiveRMTasks) {
-
+	activeRMTasks activermtask.ActiveRMTasks,
+	operations *asyncop.Tracker,
+	configVerifier provenance.Verifier,
+	tlsProvider *rpc.TLSProvider,
+	config Config) {
+
+	scope := parent.SubScope("jobmgr").SubScope("task")
+	hostMgrClient := hostsvc.NewInternalHostServiceYARPCClient(
+		d.ClientConfig(hostMgrClientName))
+	config.Restart.normalize()
+	sandboxInfo := handler.NewSandboxInfoCache(
+		_frameworkName, frameworkInfoStore, hostMgrClient)
 	handler := &serviceHandler{
 		taskStore:          taskStore,
 		jobStore:           jobStore,
 		updateStore:        updateStore,
 		frameworkInfoStore: frameworkInfoStore,
-		metrics:            NewMetrics(parent.SubScope("jobmgr").SubScope("task")),
+		metrics:            NewMetrics(scope),
 		resmgrClient:       resmgrsvc.NewResourceManagerServiceYARPCClient(d.ClientConfig(common.PelotonResourceManager)),
 		taskLauncher:       launcher.GetLauncher(),
 		jobFactory:         jobFactory,
 		goalStateDriver:    goalStateDriver,
 		candidate:          candidate,
-		mesosAgentWorkDir:  mesosAgentWorkDir,
-		hostMgrClient:      hostsvc.NewInternalHostServiceYARPCClient(d.ClientConfig(hostMgrClientName)),
+		sandboxLayouts:     sandboxLayouts,
+		hostMgrClient:      hostMgrClient,
 		logManager:         logManager,
 		activeRMTasks:      activeRMTasks,
+		taskOperationOps:   ormobjects.NewTaskOperationOps(ormStore),
+		idempotencyKeyOps:  ormobjects.NewTaskIdempotencyKeyOps(ormStore),
+		configVerifier:     configVerifier,
+		tlsProvider:        tlsProvider,
+		operations:         operations,
+		sandboxInfo:        sandboxInfo,
+		pagination:         config.Pagination,
+		taskCommand:        config.TaskCommand,
+		restart:            config.Restart,
 	}
-	d.Register(task.BuildTaskManagerYARPCProcedures(handler))
+	procedures := task.BuildTaskManagerYARPCProcedures(handler)
+	if config.RateLimit.Enabled {
+		procedures = applyUnaryInbound(
+			procedures, newRateLimiter(config.RateLimit, scope))
+	}
+	// the deprecation gate is applied last, so that the requests it
+	// rejects are not rate limited
+	if config.Deprecation.Enabled {
+		procedures = applyUnaryInbound(
+			procedures, newDeprecationGate(config.Deprecation, scope))
+	}
+	d.Register(procedures)
 }
 
 // serviceHandler implements peloton.api.task.TaskManager
@@ -110,10 +220,19 @@ type serviceHandler struct {
 	jobFactory         cached.JobFactory
 	goalStateDriver    goalstate.Driver
 	candidate          leader.Candidate
-	mesosAgentWorkDir  string
+	sandboxLayouts     logmanager.SandboxLayoutResolver
 	hostMgrClient      hostsvc.InternalHostServiceYARPCClient
 	logManager         logmanager.LogManager
 	activeRMTasks      activermtask.ActiveRMTasks
+	taskOperationOps   ormobjects.TaskOperationOps
+	idempotencyKeyOps  ormobjects.TaskIdempotencyKeyOps
+	operations         *asyncop.Tracker
+	configVerifier     provenance.Verifier
+	tlsProvider        *rpc.TLSProvider
+	sandboxInfo        handler.SandboxInfoCache
+	pagination         handler.PaginationConfig
+	taskCommand        TaskCommandConfig
+	restart            RestartConfig
 }
 
 func (m *serviceHandler) Get(
@@ -148,11 +267,13 @@ func (m *serviceHandler) Get(
 		break
 	}
 
-	eventList, err := m.getPodEvents(
+	eventList, hasMoreRuns, err := m.getPodEventsOfRuns(
 		ctx,
 		body.GetJobId(),
 		body.GetInstanceId(),
-		"") // get pod events for last run
+		"", // start from the last run
+		body.GetRunOffset(),
+		body.GetRunLimit())
 	if err != nil {
 		m.metrics.TaskGetFail.Inc(1)
 		return &task.GetResponse{
@@ -167,8 +288,9 @@ func (m *serviceHandler) Get(
 
 	m.metrics.TaskGet.Inc(1)
 	return &task.GetResponse{
-		Result:  lastTaskInfo,
-		Results: taskInfos,
+		Result:      lastTaskInfo,
+		Results:     taskInfos,
+		HasMoreRuns: hasMoreRuns,
 	}, nil
 }
 
@@ -177,6 +299,11 @@ func (m *serviceHandler) Get(
 func (m *serviceHandler) GetPodEvents(
 	ctx context.Context,
 	body *task.GetPodEventsRequest) (*task.GetPodEventsResponse, error) {
+	filter, err := newPodEventsFilter(body)
+	if err != nil {
+		return nil, err
+	}
+
 	// Limit defines the number of run id's to return, if the req is asking for
 	// a specific run id then limit is 1.
 	limit := body.GetLimit()
@@ -184,14 +311,25 @@ func (m *serviceHandler) GetPodEvents(
 		limit = 1
 	}
 
-	// Default to 10 run IDs
-	if limit == 0 {
+	// Default to 10 run IDs, unless the runs are walked until enough pod
+	// events are found or a pod event older than the start time is found
+	if limit == 0 && body.GetMaxEvents() == 0 && len(body.GetStartTime()) == 0 {
 		limit = 10
 	}
 
+	// The runs walked and the pod events returned are bounded, even if
+	// the request does not limit them
+	limit, maxEvents, err := m.pagination.PodEventsLimits(
+		limit, body.GetMaxEvents())
+	if err != nil {
+		return nil, err
+	}
+
 	podID := body.GetRunId()
 	var result []*task.PodEvent
-	for i := uint64(0); i < limit; i++ {
+	var skipped uint32
+	var hasMore, done bool
+	for i := uint64(0); !done && i < limit; i++ {
 		podEvents, err := m.taskStore.GetPodEvents(
 			ctx,
 			body.GetJobId().GetValue(),
@@ -216,7 +354,7 @@ func (m *serviceHandler) GetPodEvents(
 				return nil, errors.Wrap(err, "error parsing desired job version")
 			}
 
-			result = append(result, &task.PodEvent{
+			event := &task.PodEvent{
 				TaskId: &mesosv1.TaskID{
 					Value: &podID,
 				},
@@ -236,10 +374,35 @@ func (m *serviceHandler) GetPodEvents(
 				DesriedTaskId: &mesosv1.TaskID{
 					Value: &desiredPodID,
 				},
-			})
+				OperationContext: getPodEventOperationContext(e),
+			}
+
+			match, older, err := filter.match(event)
+			if err != nil {
+				return nil, err
+			}
+			if older {
+				// Pod events are sorted from the newest to the oldest, so
+				// the remaining ones are older than the start time as well
+				done = true
+				break
+			}
+			if !match {
+				continue
+			}
+			if skipped < body.GetOffset() {
+				skipped++
+				continue
+			}
+			if uint32(len(result)) == maxEvents {
+				hasMore = true
+				done = true
+				break
+			}
+			result = append(result, event)
 		}
 
-		if len(prevPodID) == 0 {
+		if done || len(prevPodID) == 0 {
 			break
 		}
 
@@ -255,10 +418,73 @@ func (m *serviceHandler) GetPodEvents(
 	}
 
 	return &task.GetPodEventsResponse{
-		Result: result,
+		Result:  result,
+		HasMore: hasMore,
 	}, nil
 }
 
+// podEventsFilter filters the pod events returned by GetPodEvents.
+type podEventsFilter struct {
+	startTime time.Time
+	endTime   time.Time
+	states    map[string]bool
+}
+
+// newPodEventsFilter returns the filter of a GetPodEvents request.
+func newPodEventsFilter(
+	body *task.GetPodEventsRequest) (*podEventsFilter, error) {
+	var err error
+	filter := &podEventsFilter{}
+
+	if len(body.GetStartTime()) != 0 {
+		filter.startTime, err = time.Parse(time.RFC3339, body.GetStartTime())
+		if err != nil {
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"invalid start time: %v", err)
+		}
+	}
+
+	if len(body.GetEndTime()) != 0 {
+		filter.endTime, err = time.Parse(time.RFC3339, body.GetEndTime())
+		if err != nil {
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"invalid end time: %v", err)
+		}
+	}
+
+	if len(body.GetStates()) != 0 {
+		filter.states = make(map[string]bool)
+		for _, state := range body.GetStates() {
+			filter.states[state.String()] = true
+		}
+	}
+	return filter, nil
+}
+
+// match returns true if the pod event matches the filter. It also returns
+// true if the pod event is older than the start time.
+func (f *podEventsFilter) match(
+	event *task.PodEvent) (match bool, older bool, err error) {
+	if !f.startTime.IsZero() || !f.endTime.IsZero() {
+		timestamp, err := time.Parse(time.RFC3339, event.GetTimestamp())
+		if err != nil {
+			return false, false, errors.Wrap(err,
+				"error parsing pod event timestamp")
+		}
+		if !f.startTime.IsZero() && timestamp.Before(f.startTime) {
+			return false, true, nil
+		}
+		if !f.endTime.IsZero() && !timestamp.Before(f.endTime) {
+			return false, false, nil
+		}
+	}
+
+	if f.states != nil && !f.states[event.GetActualState()] {
+		return false, false, nil
+	}
+	return true, false, nil
+}
+
 // DeletePodEvents, deletes the pod events for provided request, which is for
 // a jobID + instanceID + less than equal to runID.
 // Response will be successful or error on unable to delete events for input.
@@ -293,8 +519,9 @@ func (m *serviceHandler) List(
 		instanceRanges = append(instanceRanges, body.GetRange())
 	}
 	result, err := m.getTaskInfosByRangesFromDB(
-		ctx, body.GetJobId(), instanceRanges)
-	if err != nil || len(result) == 0 {
+		ctx, body.GetJobId(), instanceRanges, body.GetStates()...)
+	// an empty result is expected if no task matches the state filter
+	if err != nil || (len(result) == 0 && len(body.GetStates()) == 0) {
 		m.metrics.TaskListFail.Inc(1)
 		return &task.ListResponse{
 			NotFound: &pb_errors.JobNotFound{
@@ -317,8 +544,11 @@ func (m *serviceHandler) List(
 
 // Refresh loads the task runtime state from DB, updates the cache,
 // and enqueues it to goal state for evaluation.
-func (m *serviceHandler) Refresh(ctx context.Context, req *task.RefreshRequest) (*task.RefreshResponse, error) {
+func (m *serviceHandler) Refresh(ctx context.Context, req *task.RefreshRequest) (resp *task.RefreshResponse, err error) {
 	log.WithField("request", req).Debug("TaskSVC.Refresh called")
+	defer func() {
+		m.recordTaskOperation(ctx, "Refresh", req.GetJobId(), req, resp, err)
+	}()
 
 	m.metrics.TaskAPIRefresh.Inc(1)
 
@@ -372,18 +602,20 @@ func (m *serviceHandler) Refresh(ctx context.Context, req *task.RefreshRequest)
 		m.goalStateDriver.EnqueueTask(req.GetJobId(), instID, time.Now())
 	}
 
-	goalstate.EnqueueJobWithDefaultDelay(
-		req.GetJobId(), m.goalStateDriver, cachedJob)
+	m.goalStateDriver.EnqueueJobWithDefaultDelay(
+		req.GetJobId(), cachedJob.GetJobType())
 
 	m.metrics.TaskRefresh.Inc(1)
 	return &task.RefreshResponse{}, nil
 }
 
 // getTaskInfosByRangesFromDB get all the tasks infos for given job and ranges.
+// If states are provided, only the tasks in one of these states are returned.
 func (m *serviceHandler) getTaskInfosByRangesFromDB(
 	ctx context.Context,
 	jobID *peloton.JobID,
-	ranges []*task.InstanceRange) (map[uint32]*task.TaskInfo, error) {
+	ranges []*task.InstanceRange,
+	states ...task.TaskState) (map[uint32]*task.TaskInfo, error) {
 
 	taskInfos := make(map[uint32]*task.TaskInfo)
 	var err error
@@ -412,13 +644,32 @@ func (m *serviceHandler) getTaskInfosByRangesFromDB(
 		}
 	}
 
-	return taskInfos, err
+	if err != nil || len(states) == 0 {
+		return taskInfos, err
+	}
+
+	stateFilter := make(map[task.TaskState]bool)
+	for _, state := range states {
+		stateFilter[state] = true
+	}
+	for inst, taskInfo := range taskInfos {
+		if !stateFilter[taskInfo.GetRuntime().GetState()] {
+			delete(taskInfos, inst)
+		}
+	}
+	return taskInfos, nil
 }
 
 // Start implements TaskManager.Start, tries to start terminal tasks in a given job.
 func (m *serviceHandler) Start(
 	ctx context.Context,
-	body *task.StartRequest) (*task.StartResponse, error) {
+	body *task.StartRequest) (resp *task.StartResponse, err error) {
+
+	// record the operation with the context of the call, since the context
+	// with the timeout is cancelled before the deferred call runs
+	defer func(ctx context.Context) {
+		m.recordTaskOperation(ctx, "Start", body.GetJobId(), body, resp, err)
+	}(ctx)
 
 	m.metrics.TaskAPIStart.Inc(1)
 	ctx, cancelFunc := context.WithTimeout(
@@ -432,6 +683,60 @@ func (m *serviceHandler) Start(
 		return nil, yarpcerrors.UnavailableErrorf("Task Start API not suppported on non-leader")
 	}
 
+	prevResp := &task.StartResponse{}
+	found, err := m.reserveIdempotencyKey(
+		ctx, "Start", body.GetJobId(), body.GetIdempotencyKey(), body, prevResp)
+	if err != nil {
+		m.metrics.TaskStartFail.Inc(1)
+		return nil, err
+	}
+	if found {
+		return prevResp, nil
+	}
+	defer func() {
+		m.completeIdempotencyKey(
+			"Start", body.GetJobId(), body.GetIdempotencyKey(), resp, err)
+	}()
+
+	if body.GetAsync() {
+		operationID, err := m.startAsyncOperation(
+			ctx,
+			"Start",
+			body.GetJobId(),
+			body.GetRanges(),
+			_asyncOperationBatchSize,
+			func(
+				ctx context.Context,
+				instanceRange *task.InstanceRange,
+			) ([]*task.InstanceOutcome, error) {
+				resp, err := m.startTasks(ctx, &task.StartRequest{
+					JobId:            body.GetJobId(),
+					Ranges:           []*task.InstanceRange{instanceRange},
+					OperationContext: body.GetOperationContext(),
+				})
+				if err == nil && resp.GetError() != nil {
+					err = errors.New(resp.GetError().String())
+				}
+				return getInstanceOutcomes(
+					resp.GetStartedInstanceIds(),
+					resp.GetInvalidInstanceIds()), err
+			},
+			nil)
+		if err != nil {
+			m.metrics.TaskStartFail.Inc(1)
+			return nil, err
+		}
+		m.metrics.TaskStart.Inc(1)
+		return &task.StartResponse{OperationId: operationID}, nil
+	}
+
+	return m.startTasks(ctx, body)
+}
+
+// startTasks starts the terminal tasks in the given ranges of a job.
+func (m *serviceHandler) startTasks(
+	ctx context.Context,
+	body *task.StartRequest) (*task.StartResponse, error) {
 	cachedJob := m.jobFactory.AddJob(body.JobId)
 	cachedConfig, err := cachedJob.GetConfig(ctx)
 
@@ -523,88 +828,60 @@ func (m *serviceHandler) Start(
 		}, nil
 	}
 
+	var lock sync.Mutex
 	var startedInstanceIds []uint32
 	var failedInstanceIds []uint32
 
-	for _, taskInfo := range taskInfos {
-		cachedTask, err := cachedJob.AddTask(ctx, taskInfo.InstanceId)
-		if err != nil {
-			log.WithFields(log.Fields{
-				"job_id":      body.GetJobId().GetValue(),
-				"instance_id": taskInfo.InstanceId,
-			}).Info("failed to add task during task start")
-			failedInstanceIds = append(failedInstanceIds, taskInfo.InstanceId)
-			continue
-		}
-
-		count := 0
-		for {
-			taskRuntime, err := cachedTask.GetRuntime(ctx)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"job_id":      body.GetJobId().GetValue(),
-					"instance_id": taskInfo.InstanceId,
-				}).Info("failed to fetch runtime during task start")
-				failedInstanceIds = append(failedInstanceIds, taskInfo.InstanceId)
-				break
-			}
-
-			if taskRuntime.GetGoalState() != task.TaskState_KILLED {
-				// ignore start request for tasks with non-killed goal state
-				log.WithFields(log.Fields{
-					"instance_id": taskInfo.InstanceId,
-					"job_id":      body.GetJobId().GetValue(),
-					"goal_state":  taskRuntime.GetGoalState().String(),
-				}).Debug("task was not stopped")
-				break
-			}
-
-			// Regenerate the task and change the goalstate
-			healthState := taskutil.GetInitialHealthState(taskInfo.GetConfig())
-			taskutil.RegenerateMesosTaskRuntime(
-				body.GetJobId(),
-				taskInfo.InstanceId,
-				taskRuntime,
-				healthState,
-			)
-			taskRuntime.GoalState =
-				jobmgr_task.GetDefaultTaskGoalState(cachedConfig.GetType())
-			taskRuntime.Message = "Task start API request"
-
-			// Directly call task level APIs instead of calling job level API
-			// as one transaction (like PatchTasks calls) because
-			// compare and set calls cannot be batched as one transaction
-			// as if task runtime of only one task has changed, then it should
-			// not cause the entire transaction to fail and to be retried again.
-			_, err = cachedTask.CompareAndSetRuntime(
-				ctx, taskRuntime, cachedConfig.GetType())
+	instanceIDs := make([]uint32, 0, len(taskInfos))
+	for instID := range taskInfos {
+		instanceIDs = append(instanceIDs, instID)
+	}
 
-			if err == jobmgrcommon.UnexpectedVersionError {
-				count = count + 1
-				if count < jobmgrcommon.MaxConcurrencyErrorRetry {
-					continue
-				}
-			}
+	startSingleTask := func(id uint32) error {
+		started, err := m.startTask(
+			ctx,
+			body.GetJobId(),
+			cachedJob,
+			cachedConfig.GetType(),
+			taskInfos[id],
+			body.GetOperationContext(),
+		)
 
-			if err != nil {
-				log.WithError(err).
-					WithFields(log.Fields{
-						"job_id":      body.GetJobId().GetValue(),
-						"instance_id": taskInfo.InstanceId,
-					}).Info("failed to write runtime during task start")
-				failedInstanceIds = append(failedInstanceIds, taskInfo.InstanceId)
-			} else {
-				startedInstanceIds = append(startedInstanceIds, taskInfo.InstanceId)
-			}
-			break
+		lock.Lock()
+		defer lock.Unlock()
+		if err != nil {
+			failedInstanceIds = append(failedInstanceIds, id)
+		} else if started {
+			startedInstanceIds = append(startedInstanceIds, id)
 		}
+		// failures are recorded per instance, so never abort the
+		// remaining instances handled by the same go routine
+		return nil
 	}
 
+	// Directly call task level APIs instead of calling job level API
+	// as one transaction (like PatchTasks calls) because
+	// compare and set calls cannot be batched as one transaction
+	// as if task runtime of only one task has changed, then it should
+	// not cause the entire transaction to fail and to be retried again.
+	// The instances are split into batches which are written in parallel
+	// to keep the API latency bounded for jobs with many instances.
+	taskutil.RunInParallel(
+		body.GetJobId().GetValue(), instanceIDs, startSingleTask)
+
+	sort.Slice(startedInstanceIds, func(i, j int) bool {
+		return startedInstanceIds[i] < startedInstanceIds[j]
+	})
+	sort.Slice(failedInstanceIds, func(i, j int) bool {
+		return failedInstanceIds[i] < failedInstanceIds[j]
+	})
+
 	for _, instID := range startedInstanceIds {
-		m.goalStateDriver.EnqueueTask(body.GetJobId(), instID, time.Now())
+		m.goalStateDriver.EnqueueTaskWithPriority(
+			body.GetJobId(), instID, time.Now(), commongoalstate.PriorityHigh)
 	}
-	goalstate.EnqueueJobWithDefaultDelay(
-		body.GetJobId(), m.goalStateDriver, cachedJob)
+	m.goalStateDriver.EnqueueJobWithDefaultDelay(
+		body.GetJobId(), cachedJob.GetJobType())
 
 	m.metrics.TaskStart.Inc(1)
 	return &task.StartResponse{
@@ -613,6 +890,86 @@ func (m *serviceHandler) Start(
 	}, nil
 }
 
+// startTask moves a single stopped task back to its default goal state.
+// It returns true if the task runtime was updated, and false without
+// an error if the task was not stopped and hence was left untouched.
+func (m *serviceHandler) startTask(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	cachedJob cached.Job,
+	jobType pb_job.JobType,
+	taskInfo *task.TaskInfo,
+	operationContext *task.OperationContext) (bool, error) {
+	cachedTask, err := cachedJob.AddTask(ctx, taskInfo.InstanceId)
+	if err != nil {
+		log.WithError(err).
+			WithFields(log.Fields{
+				"job_id":      jobID.GetValue(),
+				"instance_id": taskInfo.InstanceId,
+			}).Info("failed to add task during task start")
+		return false, err
+	}
+
+	count := 0
+	for {
+		taskRuntime, err := cachedTask.GetRuntime(ctx)
+		if err != nil {
+			log.WithError(err).
+				WithFields(log.Fields{
+					"job_id":      jobID.GetValue(),
+					"instance_id": taskInfo.InstanceId,
+				}).Info("failed to fetch runtime during task start")
+			return false, err
+		}
+
+		if taskRuntime.GetGoalState() != task.TaskState_KILLED &&
+			taskRuntime.GetState() != task.TaskState_FAILED_HOLD {
+			// ignore start request for tasks with non-killed goal state,
+			// unless they are held after crash looping
+			log.WithFields(log.Fields{
+				"instance_id": taskInfo.InstanceId,
+				"job_id":      jobID.GetValue(),
+				"goal_state":  taskRuntime.GetGoalState().String(),
+			}).Debug("task was not stopped")
+			return false, nil
+		}
+
+		// Regenerate the task and change the goalstate
+		healthState := taskutil.GetInitialHealthState(taskInfo.GetConfig())
+		taskutil.RegenerateMesosTaskRuntime(
+			jobID,
+			taskInfo.InstanceId,
+			taskRuntime,
+			healthState,
+		)
+		taskRuntime.GoalState = jobmgr_task.GetDefaultTaskGoalState(jobType)
+		taskRuntime.Message = "Task start API request"
+		// an explicit start gives the task a new crash-loop budget
+		taskRuntime.CrashLoopFailureCount = 0
+		taskRuntime.CrashLoopWindowStartTime = ""
+		taskRuntime.OperationContext = operationContext
+
+		_, err = cachedTask.CompareAndSetRuntime(ctx, taskRuntime, jobType)
+		if err == jobmgrcommon.UnexpectedVersionError {
+			// concurrency error; retry MaxConcurrencyErrorRetry times
+			count = count + 1
+			if count < jobmgrcommon.MaxConcurrencyErrorRetry {
+				continue
+			}
+		}
+
+		if err != nil {
+			log.WithError(err).
+				WithFields(log.Fields{
+					"job_id":      jobID.GetValue(),
+					"instance_id": taskInfo.InstanceId,
+				}).Info("failed to write runtime during task start")
+			return false, err
+		}
+		return true, nil
+	}
+}
+
 func (m *serviceHandler) stopJob(
 	ctx context.Context,
 	jobID *peloton.JobID,
@@ -642,7 +999,8 @@ func (m *serviceHandler) stopJob(
 			}, nil
 		}
 
-		if jobRuntime.GoalState == pb_job.JobState_KILLED {
+		if jobRuntime.GoalState == pb_job.JobState_KILLED &&
+			!jobRuntime.GetSuspended() {
 			return &task.StopResponse{
 				StoppedInstanceIds: instanceList,
 			}, nil
@@ -650,6 +1008,8 @@ func (m *serviceHandler) stopJob(
 
 		jobRuntime.DesiredStateVersion++
 		jobRuntime.GoalState = pb_job.JobState_KILLED
+		// a stopped job is not resumed
+		jobRuntime.Suspended = false
 
 		_, err = cachedJob.CompareAndSetRuntime(ctx, jobRuntime)
 		if err != nil {
@@ -689,9 +1049,15 @@ func (m *serviceHandler) stopJob(
 // Stop implements TaskManager.Stop, tries to stop tasks in a given job.
 func (m *serviceHandler) Stop(
 	ctx context.Context,
-	body *task.StopRequest) (*task.StopResponse, error) {
+	body *task.StopRequest) (resp *task.StopResponse, err error) {
 
+	// the principal supplied by the client could be spoofed, the one of
+	// the client certificate of the request is recorded instead
+	body.Principal = m.tlsProvider.PeerID(ctx)
 	log.WithField("request", body).Info("TaskManager.Stop called")
+	defer func(ctx context.Context) {
+		m.recordTaskOperation(ctx, "Stop", body.GetJobId(), body, resp, err)
+	}(ctx)
 	m.metrics.TaskAPIStop.Inc(1)
 	ctx, cancelFunc := context.WithTimeout(
 		ctx,
@@ -704,6 +1070,172 @@ func (m *serviceHandler) Stop(
 		return nil, yarpcerrors.UnavailableErrorf("Task Stop API not suppported on non-leader")
 	}
 
+	prevResp := &task.StopResponse{}
+	found, err := m.reserveIdempotencyKey(
+		ctx, "Stop", body.GetJobId(), body.GetIdempotencyKey(), body, prevResp)
+	if err != nil {
+		m.metrics.TaskStopFail.Inc(1)
+		return nil, err
+	}
+	if found {
+		return prevResp, nil
+	}
+	defer func() {
+		m.completeIdempotencyKey(
+			"Stop", body.GetJobId(), body.GetIdempotencyKey(), resp, err)
+	}()
+
+	if body.GetAsync() {
+		batchSize := _asyncOperationBatchSize
+		if !stopsTaskByTask(body) && len(body.GetRanges()) == 0 {
+			// stopping all the tasks of a job only updates the job runtime
+			batchSize = 0
+		}
+
+		operationID, err := m.startAsyncOperation(
+			ctx,
+			"Stop",
+			body.GetJobId(),
+			body.GetRanges(),
+			batchSize,
+			func(
+				ctx context.Context,
+				instanceRange *task.InstanceRange,
+			) ([]*task.InstanceOutcome, error) {
+				resp, err := m.stopTasks(ctx, &task.StopRequest{
+					JobId:            body.GetJobId(),
+					Ranges:           []*task.InstanceRange{instanceRange},
+					KillGracePeriod:  body.GetKillGracePeriod(),
+					Reason:           body.GetReason(),
+					Principal:        body.GetPrincipal(),
+					LabelSelector:    body.GetLabelSelector(),
+					OperationContext: body.GetOperationContext(),
+				})
+				if err == nil && resp.GetError() != nil {
+					err = errors.New(resp.GetError().String())
+				}
+				return getInstanceOutcomes(
+					resp.GetStoppedInstanceIds(),
+					resp.GetInvalidInstanceIds()), err
+			},
+			nil)
+		if err != nil {
+			m.metrics.TaskStopFail.Inc(1)
+			return nil, err
+		}
+		m.metrics.TaskStop.Inc(1)
+		return &task.StopResponse{OperationId: operationID}, nil
+	}
+
+	return m.stopTasks(ctx, body)
+}
+
+// BatchStop implements TaskManager.BatchStop, tries to stop tasks across
+// multiple jobs. The tasks of each job are stopped independently, and the
+// tasks of up to _batchStopParallelism jobs in parallel, so a failure to
+// stop the tasks of one job is reported in the result of that job without
+// affecting the others.
+func (m *serviceHandler) BatchStop(
+	ctx context.Context,
+	body *task.BatchStopRequest) (resp *task.BatchStopResponse, err error) {
+
+	log.WithField("request", body).Info("TaskManager.BatchStop called")
+	defer func(ctx context.Context) {
+		// the stop of the tasks of each job is recorded once done, so
+		// only the requests rejected as a whole are recorded here
+		if err == nil {
+			return
+		}
+		for _, req := range body.GetJobs() {
+			m.recordTaskOperation(
+				ctx, "BatchStop", req.GetJobId(), req, nil, err)
+		}
+	}(ctx)
+
+	m.metrics.TaskAPIBatchStop.Inc(1)
+
+	if !m.candidate.IsLeader() {
+		m.metrics.TaskBatchStopFail.Inc(1)
+		return nil, yarpcerrors.UnavailableErrorf("Task BatchStop API not suppported on non-leader")
+	}
+
+	principal := m.tlsProvider.PeerID(ctx)
+	jobIDs := make(map[string]bool)
+	for _, req := range body.GetJobs() {
+		req.Principal = principal
+		jobID := req.GetJobId().GetValue()
+		if len(jobID) == 0 {
+			m.metrics.TaskBatchStopFail.Inc(1)
+			return nil, yarpcerrors.InvalidArgumentErrorf("job id is not provided")
+		}
+		if jobIDs[jobID] {
+			m.metrics.TaskBatchStopFail.Inc(1)
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"job %s is provided more than once", jobID)
+		}
+		jobIDs[jobID] = true
+	}
+
+	resp = &task.BatchStopResponse{
+		Results: make([]*task.BatchStopResponse_JobResult, len(body.GetJobs())),
+	}
+	wg := new(sync.WaitGroup)
+	sem := make(chan struct{}, _batchStopParallelism)
+	for i, req := range body.GetJobs() {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, req *task.StopRequest) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			result, err := m.stopJobTasks(ctx, req)
+			m.recordTaskOperation(
+				ctx, "BatchStop", req.GetJobId(), req, result, err)
+			if err != nil {
+				result = &task.StopResponse{
+					Error: &task.StopResponse_Error{
+						UpdateError: &task.TaskUpdateError{
+							Message: err.Error(),
+						},
+					},
+				}
+			}
+			if result.GetError() != nil {
+				m.metrics.TaskBatchStopJobFail.Inc(1)
+			}
+			resp.Results[i] = &task.BatchStopResponse_JobResult{
+				JobId:  req.GetJobId(),
+				Result: result,
+			}
+		}(i, req)
+	}
+	wg.Wait()
+
+	m.metrics.TaskBatchStop.Inc(1)
+	return resp, nil
+}
+
+// stopJobTasks stops the tasks of a single job of a batch stop request,
+// with its own timeout so that a slow job does not starve the others.
+func (m *serviceHandler) stopJobTasks(
+	ctx context.Context,
+	body *task.StopRequest) (*task.StopResponse, error) {
+	ctx, cancelFunc := context.WithTimeout(
+		ctx,
+		_rpcTimeout,
+	)
+	defer cancelFunc()
+
+	return m.stopTasks(ctx, body)
+}
+
+// stopTasks stops the tasks in the given ranges of a job, or the whole job
+// if no range covers only part of the job.
+func (m *serviceHandler) stopTasks(
+	ctx context.Context,
+	body *task.StopRequest) (*task.StopResponse, error) {
 	cachedJob := m.jobFactory.AddJob(body.JobId)
 	cachedConfig, err := cachedJob.GetConfig(ctx)
 
@@ -723,7 +1255,9 @@ func (m *serviceHandler) Stop(
 	}
 
 	taskRange := body.GetRanges()
-	if len(taskRange) == 0 || (len(taskRange) == 1 && taskRange[0].From == 0 && taskRange[0].To >= cachedConfig.GetInstanceCount()) {
+	stopsJob := body.GetLabelSelector() == nil &&
+		(len(taskRange) == 0 || (len(taskRange) == 1 && taskRange[0].From == 0 && taskRange[0].To >= cachedConfig.GetInstanceCount()))
+	if stopsJob && !stopsTaskByTask(body) {
 		// Stop all tasks in a job, stop entire job instead of task by task
 		log.WithField("job_id", body.GetJobId().GetValue()).
 			Info("stopping all tasks in the job")
@@ -758,15 +1292,33 @@ func (m *serviceHandler) Stop(
 		if taskInfo.GetRuntime().GoalState == task.TaskState_KILLED {
 			continue
 		}
+		// Skip the tasks not selected by their labels. The tasks read
+		// from DB have no config, their labels are read from the config
+		// cached above.
+		if body.GetLabelSelector() != nil && !matchesLabelSelector(
+			body.GetLabelSelector(),
+			cachedJob.GetTaskLabels(taskInfo.GetInstanceId())) {
+			continue
+		}
 
 		runtimeDiff := jobmgrcommon.RuntimeDiff{
 			jobmgrcommon.GoalStateField: task.TaskState_KILLED,
-			jobmgrcommon.MessageField:   "Task stop API request",
+			jobmgrcommon.MessageField:   getStopMessage(body),
 			jobmgrcommon.ReasonField:    "",
 			jobmgrcommon.TerminationStatusField: &task.TerminationStatus{
-				Reason: task.TerminationStatus_TERMINATION_STATUS_REASON_KILLED_ON_REQUEST,
+				Reason:    task.TerminationStatus_TERMINATION_STATUS_REASON_KILLED_ON_REQUEST,
+				Message:   body.GetReason(),
+				Principal: body.GetPrincipal(),
 			},
 		}
+		if body.GetKillGracePeriod() != nil {
+			runtimeDiff[jobmgrcommon.KillGracePeriodField] = body.GetKillGracePeriod()
+		}
+		// a stop without a context clears the one of a previous operation
+		if body.GetOperationContext() != nil ||
+			taskInfo.GetRuntime().GetOperationContext() != nil {
+			runtimeDiff[jobmgrcommon.OperationContextField] = body.GetOperationContext()
+		}
 		runtimeDiffs[taskInfo.InstanceId] = runtimeDiff
 		instanceIds = append(instanceIds, taskInfo.InstanceId)
 	}
@@ -785,11 +1337,18 @@ func (m *serviceHandler) Stop(
 	}
 
 	for _, instID := range stoppedInstanceIds {
-		m.goalStateDriver.EnqueueTask(body.GetJobId(), instID, time.Now())
+		m.goalStateDriver.EnqueueTaskWithPriority(
+			body.GetJobId(), instID, time.Now(), commongoalstate.PriorityHigh)
 	}
 
-	goalstate.EnqueueJobWithDefaultDelay(
-		body.GetJobId(), m.goalStateDriver, cachedJob)
+	// the goal state of the job is updated as well when all its tasks
+	// are stopped, once the fields of the stop are set on the tasks
+	if err == nil && stopsJob {
+		return m.stopJob(ctx, body.GetJobId(), cachedConfig.GetInstanceCount())
+	}
+
+	m.goalStateDriver.EnqueueJobWithDefaultDelay(
+		body.GetJobId(), cachedJob.GetJobType())
 
 	if err != nil {
 		return &task.StopResponse{
@@ -807,10 +1366,68 @@ func (m *serviceHandler) Stop(
 	}, nil
 }
 
+// stopsTaskByTask returns true if the stop request overrides fields which
+// are persisted in the runtime of each task, such as the kill grace period
+// or the reason of the stop. The tasks are then stopped one by one, and
+// the job is stopped afterwards if all its tasks are stopped. Only the
+// fields supplied by the caller are considered, the principal is set from
+// the client certificate of every request when TLS is enabled, and is not
+// recorded on the tasks of a job stopped as a whole.
+func stopsTaskByTask(body *task.StopRequest) bool {
+	return body.GetKillGracePeriod() != nil ||
+		len(body.GetReason()) > 0 ||
+		body.GetLabelSelector() != nil ||
+		body.GetOperationContext() != nil
+}
+
+// matchesLabelSelector returns true if the labels of a task config have all
+// the labels to match and none of the labels to exclude of the selector.
+// A nil selector matches all the tasks.
+func matchesLabelSelector(
+	selector *task.LabelSelector,
+	labels []*peloton.Label) bool {
+	hasLabel := func(label *peloton.Label) bool {
+		for _, l := range labels {
+			if l.GetKey() == label.GetKey() && l.GetValue() == label.GetValue() {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, label := range selector.GetMatchLabels() {
+		if !hasLabel(label) {
+			return false
+		}
+	}
+	for _, label := range selector.GetExcludeLabels() {
+		if hasLabel(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// getStopMessage returns the message recorded in the runtime and the pod
+// events of the tasks stopped by the stop request.
+func getStopMessage(body *task.StopRequest) string {
+	msg := "Task stop API request"
+	if len(body.GetPrincipal()) > 0 {
+		msg += " by " + body.GetPrincipal()
+	}
+	if len(body.GetReason()) > 0 {
+		msg += ": " + body.GetReason()
+	}
+	return msg
+}
+
 func (m *serviceHandler) Restart(
 	ctx context.Context,
-	req *task.RestartRequest) (*task.RestartResponse, error) {
+	req *task.RestartRequest) (resp *task.RestartResponse, err error) {
 	log.WithField("request", req).Debug("TaskSVC.Restart called")
+	defer func(ctx context.Context) {
+		m.recordTaskOperation(ctx, "Restart", req.GetJobId(), req, resp, err)
+	}(ctx)
 
 	m.metrics.TaskAPIRestart.Inc(1)
 
@@ -827,37 +1444,223 @@ func (m *serviceHandler) Restart(
 	)
 	defer cancelFunc()
 
+	prevResp := &task.RestartResponse{}
+	found, err := m.reserveIdempotencyKey(
+		ctx, "Restart", req.GetJobId(), req.GetIdempotencyKey(), req, prevResp)
+	if err != nil {
+		m.metrics.TaskRestartFail.Inc(1)
+		return nil, err
+	}
+	if found {
+		return prevResp, nil
+	}
+	defer func() {
+		m.completeIdempotencyKey(
+			"Restart", req.GetJobId(), req.GetIdempotencyKey(), resp, err)
+	}()
+
+	domainOf, err := m.getRestartFailureDomain(ctx, req)
+	if err != nil {
+		m.metrics.TaskRestartFail.Inc(1)
+		return nil, err
+	}
+
 	cachedJob := m.jobFactory.AddJob(req.JobId)
-	runtimeDiffs, err := m.getRuntimeDiffsForRestart(ctx,
+	if req.GetAsync() && !hasRestartWaves(req) {
+		return m.restartTasksAsync(ctx, cachedJob, req)
+	}
+
+	runtimeDiffs, hosts, err := m.getRuntimeDiffsForRestart(ctx,
 		cachedJob,
-		req.GetRanges())
+		req.GetRanges(),
+		req.GetOperationContext())
 	if err != nil {
 		m.metrics.TaskRestartFail.Inc(1)
 		return nil, err
 	}
-	if err := cachedJob.PatchTasks(ctx, runtimeDiffs); err != nil {
+	if hasRestartWaves(req) {
+		return m.restartTasksInWaves(
+			ctx,
+			cachedJob,
+			req,
+			runtimeDiffs,
+			getInstanceFailureDomains(hosts, domainOf))
+	}
+	if err := m.restartWave(
+		ctx, cachedJob, req.GetJobId(), runtimeDiffs); err != nil {
 		m.metrics.TaskRestartFail.Inc(1)
 		return nil, err
 	}
 
-	for instanceID := range runtimeDiffs {
-		m.goalStateDriver.EnqueueTask(req.JobId, instanceID, time.Now())
-	}
 	m.metrics.TaskRestart.Inc(1)
 	return &task.RestartResponse{}, nil
 }
 
-// getRuntimeDiffsForRestart returns runtimeDiffs to be applied to task to be
-// restarted. It updates the DesiredMesosTaskID field of task runtime.
-func (m *serviceHandler) getRuntimeDiffsForRestart(
+// getRestartFailureDomain returns the function returning the failure
+// domain of a host limited by the restart request, or nil if the number
+// of instances restarted per failure domain is not limited.
+func (m *serviceHandler) getRestartFailureDomain(
 	ctx context.Context,
-	cachedJob cached.Job,
-	instanceRanges []*task.InstanceRange) (map[uint32]jobmgrcommon.RuntimeDiff, error) {
+	req *task.RestartRequest,
+) (func(host string) string, error) {
+	if req.GetMaxInstancesPerFailureDomain() == 0 {
+		return nil, nil
+	}
+	if req.GetBatchDelaySeconds() == 0 {
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"batch delay is required to limit the instances restarted per failure domain")
+	}
+
+	switch req.GetFailureDomain() {
+	case task.RestartRequest_FAILURE_DOMAIN_HOST:
+		return func(host string) string { return host }, nil
+	case task.RestartRequest_FAILURE_DOMAIN_RACK:
+		hostRacks, err := m.getHostRacks(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return func(host string) string { return hostRacks[host] }, nil
+	default:
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"unknown failure domain %s", req.GetFailureDomain())
+	}
+}
+
+// restartTasksAsync restarts the tasks in the background. The tasks are
+// enqueued into the goal state engine once all of them have been patched.
+func (m *serviceHandler) restartTasksAsync(
+	ctx context.Context,
+	cachedJob cached.Job,
+	req *task.RestartRequest) (*task.RestartResponse, error) {
+	restarted := make(map[uint32]jobmgrcommon.RuntimeDiff)
+	operationID, err := m.startAsyncOperation(
+		ctx,
+		"Restart",
+		req.GetJobId(),
+		req.GetRanges(),
+		_asyncOperationBatchSize,
+		func(
+			ctx context.Context,
+			instanceRange *task.InstanceRange,
+		) ([]*task.InstanceOutcome, error) {
+			runtimeDiffs, _, err := m.getRuntimeDiffsForRestart(
+				ctx,
+				cachedJob,
+				[]*task.InstanceRange{instanceRange},
+				req.GetOperationContext())
+			if err != nil {
+				return nil, err
+			}
+			if err := cachedJob.PatchTasks(ctx, runtimeDiffs); err != nil {
+				return nil, err
+			}
+
+			var instanceIDs []uint32
+			for instanceID, runtimeDiff := range runtimeDiffs {
+				restarted[instanceID] = runtimeDiff
+				instanceIDs = append(instanceIDs, instanceID)
+			}
+			return getInstanceOutcomes(instanceIDs, nil), nil
+		},
+		func() {
+			now := time.Now()
+			for instanceID := range restarted {
+				m.goalStateDriver.EnqueueTaskWithPriority(
+					req.GetJobId(),
+					instanceID,
+					now,
+					commongoalstate.PriorityHigh)
+			}
+		})
+	if err != nil {
+		m.metrics.TaskRestartFail.Inc(1)
+		return nil, err
+	}
+	m.metrics.TaskRestart.Inc(1)
+	return &task.RestartResponse{OperationId: operationID}, nil
+}
+
+// getInstanceFailureDomains returns the failure domain of each instance
+// placed on a host, or nil if domainOf is nil.
+func getInstanceFailureDomains(
+	hosts map[uint32]string,
+	domainOf func(host string) string,
+) map[uint32]string {
+	if domainOf == nil {
+		return nil
+	}
+	domains := make(map[uint32]string)
+	for instanceID, host := range hosts {
+		if domain := domainOf(host); len(domain) != 0 {
+			domains[instanceID] = domain
+		}
+	}
+	return domains
+}
+
+// getRestartWaves returns the wave in which each of the sorted instances
+// is restarted. Each instance is added to the earliest wave which has
+// less than batchSize instances, and less than maxPerDomain instances of
+// the failure domain of the instance. The limits are ignored if 0, and the
+// instances without a failure domain are only limited by batchSize.
+func getRestartWaves(
+	instanceIDs []uint32,
+	domains map[uint32]string,
+	batchSize uint32,
+	maxPerDomain uint32,
+) []uint32 {
+	waves := make([]uint32, len(instanceIDs))
+	var waveSizes []uint32
+	domainWaveSizes := make(map[string][]uint32)
+	// the waves before firstOpenWave have batchSize instances
+	firstOpenWave := 0
+
+	isFull := func(sizes []uint32, wave int, limit uint32) bool {
+		return limit > 0 && wave < len(sizes) && sizes[wave] >= limit
+	}
+
+	for i, instanceID := range instanceIDs {
+		domain, hasDomain := domains[instanceID]
+		wave := firstOpenWave
+		for isFull(waveSizes, wave, batchSize) ||
+			(hasDomain && isFull(domainWaveSizes[domain], wave, maxPerDomain)) {
+			wave++
+		}
+
+		for len(waveSizes) <= wave {
+			waveSizes = append(waveSizes, 0)
+		}
+		waveSizes[wave]++
+		if hasDomain {
+			for len(domainWaveSizes[domain]) <= wave {
+				domainWaveSizes[domain] = append(domainWaveSizes[domain], 0)
+			}
+			domainWaveSizes[domain][wave]++
+		}
+		for isFull(waveSizes, firstOpenWave, batchSize) {
+			firstOpenWave++
+		}
+		waves[i] = uint32(wave)
+	}
+	return waves
+}
+
+// getRuntimeDiffsForRestart returns runtimeDiffs to be applied to task to be
+// restarted. It updates the DesiredMesosTaskID field of task runtime.
+// It also returns the host of the tasks which are placed on a host.
+// The operation context is recorded on the runtime of each task.
+func (m *serviceHandler) getRuntimeDiffsForRestart(
+	ctx context.Context,
+	cachedJob cached.Job,
+	instanceRanges []*task.InstanceRange,
+	operationContext *task.OperationContext,
+) (map[uint32]jobmgrcommon.RuntimeDiff, map[uint32]string, error) {
 	result := make(map[uint32]jobmgrcommon.RuntimeDiff)
+	hosts := make(map[uint32]string)
 	taskInfos, err := m.getTaskInfosByRangesFromDB(
 		ctx, cachedJob.ID(), instanceRanges)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	for _, taskInfo := range taskInfos {
@@ -871,9 +1674,186 @@ func (m *serviceHandler) getRuntimeDiffsForRestart(
 			jobmgrcommon.DesiredMesosTaskIDField: util.CreateMesosTaskID(
 				cachedJob.ID(), taskInfo.InstanceId, runID+1),
 		}
+		// a restart without a context clears the one of a previous
+		// operation
+		if operationContext != nil ||
+			taskInfo.GetRuntime().GetOperationContext() != nil {
+			result[taskInfo.InstanceId][jobmgrcommon.OperationContextField] =
+				operationContext
+		}
+		if host := taskInfo.GetRuntime().GetHost(); len(host) != 0 {
+			hosts[taskInfo.InstanceId] = host
+		}
 	}
 
-	return result, nil
+	return result, hosts, nil
+}
+
+// GetTaskTimeline returns the lifecycle of the recent runs of a task. The
+// pod events of the job manager are merged with the task events of the
+// resource manager, which are attributed to the run which started last
+// before them.
+func (m *serviceHandler) GetTaskTimeline(
+	ctx context.Context,
+	req *task.GetTaskTimelineRequest,
+) (*task.GetTaskTimelineResponse, error) {
+	log.WithField("request", req).Debug("TaskSVC.GetTaskTimeline called")
+
+	if len(req.GetJobId().GetValue()) == 0 {
+		return nil, yarpcerrors.InvalidArgumentErrorf("job id is not provided")
+	}
+
+	limit := req.GetLimit()
+	if limit == 0 {
+		limit = _defaultTimelineRuns
+	}
+
+	podEvents, err := m.GetPodEvents(ctx, &task.GetPodEventsRequest{
+		JobId:      req.GetJobId(),
+		InstanceId: req.GetInstanceId(),
+		Limit:      uint64(limit),
+	})
+	if yarpcerrors.IsInvalidArgument(err) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, yarpcerrors.InternalErrorf(
+			"failed to get pod events: %v", err)
+	}
+
+	// pod events are sorted from the newest run to the oldest one
+	var runs []*runTimeline
+	runsByID := make(map[string]*runTimeline)
+	for _, e := range podEvents.GetResult() {
+		timestamp, err := time.Parse(time.RFC3339, e.GetTimestamp())
+		if err != nil {
+			continue
+		}
+
+		run, ok := runsByID[e.GetTaskId().GetValue()]
+		if !ok {
+			run = &runTimeline{taskID: e.GetTaskId()}
+			runsByID[e.GetTaskId().GetValue()] = run
+			runs = append(runs, run)
+		}
+
+		state := task.TaskState(task.TaskState_value[e.GetActualState()])
+		source := task.TaskEvent_SOURCE_JOBMGR
+		if _hostmgrReportedStates[state] {
+			source = task.TaskEvent_SOURCE_HOSTMGR
+		}
+		run.add(timestamp, &task.TaskTimelineEvent{
+			Source:    source,
+			State:     state,
+			Timestamp: e.GetTimestamp(),
+			Hostname:  e.GetHostname(),
+			Message:   e.GetMessage(),
+			Reason:    e.GetReason(),
+		})
+	}
+
+	rmEvents := m.activeRMTasks.GetTaskEvents(
+		util.CreatePelotonTaskID(req.GetJobId().GetValue(), req.GetInstanceId()))
+	for _, e := range rmEvents {
+		timestamp, err := time.Parse(time.RFC3339, e.GetTimestamp())
+		if err != nil {
+			continue
+		}
+		for _, run := range runs {
+			if !run.startTime().After(timestamp) {
+				run.add(timestamp, &task.TaskTimelineEvent{
+					Source:    task.TaskEvent_SOURCE_RESMGR,
+					State:     e.GetState(),
+					Timestamp: e.GetTimestamp(),
+					Hostname:  e.GetHostname(),
+					Message:   e.GetMessage(),
+					Reason:    e.GetReason(),
+				})
+				break
+			}
+		}
+	}
+
+	resp := &task.GetTaskTimelineResponse{}
+	for _, run := range runs {
+		resp.Runs = append(resp.Runs, run.timeline())
+	}
+	return resp, nil
+}
+
+// runTimeline collects the events of a run of a task.
+type runTimeline struct {
+	taskID *mesosv1.TaskID
+	times  []time.Time
+	events []*task.TaskTimelineEvent
+}
+
+// add adds an event to the run, keeping the events sorted by time
+// and, for the same time, by the lifecycle of the task.
+func (r *runTimeline) add(t time.Time, event *task.TaskTimelineEvent) {
+	i := sort.Search(len(r.events), func(i int) bool {
+		if !r.times[i].Equal(t) {
+			return r.times[i].After(t)
+		}
+		return timelineStateOrder(r.events[i].GetState()) >
+			timelineStateOrder(event.GetState())
+	})
+	r.times = append(r.times, time.Time{})
+	copy(r.times[i+1:], r.times[i:])
+	r.times[i] = t
+	r.events = append(r.events, nil)
+	copy(r.events[i+1:], r.events[i:])
+	r.events[i] = event
+}
+
+// startTime returns the time of the first event of the run.
+func (r *runTimeline) startTime() time.Time {
+	return r.times[0]
+}
+
+// timeline returns the events of the run along with the time the task
+// spent waiting to be placed and launched.
+func (r *runTimeline) timeline() *task.TaskRunTimeline {
+	var enqueued, placed, launched time.Time
+	for i, event := range r.events {
+		switch event.GetState() {
+		case task.TaskState_PENDING:
+			if enqueued.IsZero() {
+				enqueued = r.times[i]
+			}
+		case task.TaskState_PLACED:
+			if placed.IsZero() {
+				placed = r.times[i]
+			}
+		case task.TaskState_LAUNCHED,
+			task.TaskState_STARTING,
+			task.TaskState_RUNNING:
+			if launched.IsZero() {
+				launched = r.times[i]
+			}
+		}
+	}
+
+	result := &task.TaskRunTimeline{
+		TaskId: r.taskID,
+		Events: r.events,
+	}
+	if !enqueued.IsZero() && !placed.IsZero() {
+		result.TimeToPlaceSeconds = placed.Sub(enqueued).Seconds()
+	}
+	if !placed.IsZero() && !launched.IsZero() {
+		result.TimeToLaunchSeconds = launched.Sub(placed).Seconds()
+	}
+	return result
+}
+
+// timelineStateOrder returns the position of a state in the lifecycle
+// of a task.
+func timelineStateOrder(state task.TaskState) int {
+	if order, ok := _timelineStateOrder[state]; ok {
+		return order
+	}
+	return len(_timelineStateOrder) + 1
 }
 
 // List/Query API should not use cachedJob
@@ -900,7 +1880,23 @@ func (m *serviceHandler) Query(ctx context.Context, req *task.QueryRequest) (*ta
 		}, nil
 	}
 
-	result, total, err := m.taskStore.QueryTasks(ctx, req.GetJobId(), req.GetSpec())
+	pagination, err := m.pagination.TaskQueryPagination(
+		req.GetSpec().GetPagination())
+	if err != nil {
+		m.metrics.TaskQueryFail.Inc(1)
+		return nil, err
+	}
+	spec := &task.QuerySpec{}
+	if req.GetSpec() != nil {
+		spec = proto.Clone(req.GetSpec()).(*task.QuerySpec)
+	}
+	spec.Pagination = pagination
+
+	result, total, err := m.taskStore.QueryTasks(ctx, req.GetJobId(), spec)
+	if yarpcerrors.IsInvalidArgument(err) {
+		m.metrics.TaskQueryFail.Inc(1)
+		return nil, err
+	}
 	if err != nil {
 		m.metrics.TaskQueryFail.Inc(1)
 		return &task.QueryResponse{
@@ -918,8 +1914,8 @@ func (m *serviceHandler) Query(ctx context.Context, req *task.QueryRequest) (*ta
 	resp := &task.QueryResponse{
 		Records: result,
 		Pagination: &query.Pagination{
-			Offset: req.GetSpec().GetPagination().GetOffset(),
-			Limit:  req.GetSpec().GetPagination().GetLimit(),
+			Offset: pagination.GetOffset(),
+			Limit:  pagination.GetLimit(),
 			Total:  total,
 		},
 	}
@@ -950,8 +1946,26 @@ func (m *serviceHandler) GetCache(
 			yarpcerrors.InternalErrorf("Cannot get task cache with err: %v", err)
 	}
 
+	// the config is not cached with the task, so load the config
+	// version of the cached runtime to compare it against the DB
+	taskConfig, _, err := m.taskStore.GetTaskConfig(
+		ctx, req.GetJobId(), req.GetInstanceId(), runtime.GetConfigVersion())
+	if err != nil {
+		return nil,
+			yarpcerrors.InternalErrorf("Cannot get task config with err: %v", err)
+	}
+
+	var lastUpdateTime string
+	if t := cachedTask.GetLastRuntimeUpdateTime(); !t.IsZero() {
+		lastUpdateTime = t.UTC().Format(time.RFC3339Nano)
+	}
+
 	return &task.GetCacheResponse{
-		Runtime: runtime,
+		Runtime:        runtime,
+		Config:         taskConfig,
+		Labels:         taskConfig.GetLabels(),
+		HealthState:    runtime.GetHealthy(),
+		LastUpdateTime: lastUpdateTime,
 	}, nil
 }
 
@@ -959,14 +1973,14 @@ func (m *serviceHandler) getHostInfoWithTaskID(
 	ctx context.Context,
 	jobID *peloton.JobID,
 	instanceID uint32,
-	taskID string) (hostname string, agentID string, err error) {
+	taskID string) (hostname string, agentID string, configVersion uint64, err error) {
 	events, err := m.getPodEvents(ctx, jobID, instanceID, taskID)
 	if err != nil {
-		return "", "", err
+		return "", "", 0, err
 	}
 
 	if len(events) == 0 {
-		return "", "",
+		return "", "", 0,
 			yarpcerrors.NotFoundErrorf("no pod events present for job_id: %s, instance_id: %d, run_id: %s",
 				jobID.GetValue(), instanceID, taskID)
 	}
@@ -981,21 +1995,21 @@ func (m *serviceHandler) getHostInfoWithTaskID(
 	}
 	hostname = terminalEvent.GetHostname()
 	agentID = terminalEvent.GetAgentID()
-	return hostname, agentID, nil
+	return hostname, agentID, terminalEvent.GetConfigVersion(), nil
 }
 
 func (m *serviceHandler) getHostInfoCurrentTask(
 	ctx context.Context,
 	jobID *peloton.JobID,
-	instanceID uint32) (hostname string, agentID string, taskID string, err error) {
+	instanceID uint32) (hostname string, agentID string, taskID string, executor *mesosv1.ExecutorInfo, err error) {
 	result, err := m.taskStore.GetTaskForJob(ctx, jobID.GetValue(), instanceID)
 
 	if err != nil {
-		return "", "", "", err
+		return "", "", "", nil, err
 	}
 
 	if len(result) != 1 {
-		return "", "", "", yarpcerrors.NotFoundErrorf("task not found")
+		return "", "", "", nil, yarpcerrors.NotFoundErrorf("task not found")
 	}
 
 	var taskInfo *task.TaskInfo
@@ -1005,35 +2019,68 @@ func (m *serviceHandler) getHostInfoCurrentTask(
 
 	hostname, agentID = taskInfo.GetRuntime().GetHost(), taskInfo.GetRuntime().GetAgentID().GetValue()
 	taskID = taskInfo.GetRuntime().GetMesosTaskId().GetValue()
-	return hostname, agentID, taskID, nil
+	executor = taskInfo.GetConfig().GetExecutor()
+
+	// the address of the agent recorded at launch saves looking it up
+	if addr := taskInfo.GetRuntime().GetAgentAddress(); addr != "" {
+		if agentIP, agentPort, err := net.SplitHostPort(addr); err == nil {
+			m.sandboxInfo.AddAgentAddress(hostname, agentID, agentIP, agentPort)
+		}
+	}
+	return hostname, agentID, taskID, executor, nil
+}
+
+// getExecutorOfRun returns the executor of the run of an instance with the
+// config version, or nil if the config of the run can not be read, in which
+// case the ID of the task is used as the ID of its executor.
+func (m *serviceHandler) getExecutorOfRun(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	instanceID uint32,
+	configVersion uint64) *mesosv1.ExecutorInfo {
+	taskConfig, _, err := m.taskStore.GetTaskConfig(
+		ctx, jobID, instanceID, configVersion)
+	if err != nil {
+		log.WithError(err).
+			WithFields(log.Fields{
+				"job_id":         jobID.GetValue(),
+				"instance_id":    instanceID,
+				"config_version": configVersion,
+			}).
+			Warn("failed to get the executor of the task")
+		return nil
+	}
+	return taskConfig.GetExecutor()
 }
 
-// getSandboxPathInfo - return details such as hostname, agentID, frameworkID and taskID to create sandbox path.
+// getSandboxPathInfo - return details such as hostname, agentID, frameworkID, taskID and
+// the ID of the executor of the task to create sandbox path.
 func (m *serviceHandler) getSandboxPathInfo(
 	ctx context.Context,
 	instanceCount uint32,
-	req *task.BrowseSandboxRequest) (hostname, agentID, taskID, frameworkID string, resp *task.BrowseSandboxResponse) {
+	req *task.BrowseSandboxRequest) (hostname, agentID, taskID, executorID, frameworkID string, resp *task.BrowseSandboxResponse) {
 	var host string
 	var agentid string
+	var configVersion uint64
+	var executor *mesosv1.ExecutorInfo
 	taskid := req.GetTaskId()
 
 	var err error
 	if len(taskid) > 0 {
-		host, agentid, err = m.getHostInfoWithTaskID(ctx,
+		host, agentid, configVersion, err = m.getHostInfoWithTaskID(ctx,
 			req.JobId,
 			req.InstanceId,
 			taskid,
 		)
 	} else {
-		host, agentid, taskid, err = m.getHostInfoCurrentTask(
+		host, agentid, taskid, executor, err = m.getHostInfoCurrentTask(
 			ctx,
 			req.JobId,
 			req.InstanceId)
 	}
 
 	if err != nil {
-		m.metrics.TaskListLogsFail.Inc(1)
-		return "", "", "", "", &task.BrowseSandboxResponse{
+		return "", "", "", "", "", &task.BrowseSandboxResponse{
 			Error: &task.BrowseSandboxResponse_Error{
 				OutOfRange: &task.InstanceIdOutOfRange{
 					JobId:         req.JobId,
@@ -1044,8 +2091,7 @@ func (m *serviceHandler) getSandboxPathInfo(
 	}
 
 	if len(host) == 0 || len(agentid) == 0 {
-		m.metrics.TaskListLogsFail.Inc(1)
-		return "", "", "", "", &task.BrowseSandboxResponse{
+		return "", "", "", "", "", &task.BrowseSandboxResponse{
 			Error: &task.BrowseSandboxResponse_Error{
 				NotRunning: &task.TaskNotRunning{
 					Message: "taskinfo does not have hostname or agentID",
@@ -1057,11 +2103,10 @@ func (m *serviceHandler) getSandboxPathInfo(
 	// get framework ID.
 	frameworkid, err := m.getFrameworkID(ctx)
 	if err != nil {
-		m.metrics.TaskListLogsFail.Inc(1)
 		log.WithError(err).WithFields(log.Fields{
 			"req": req,
 		}).Error("failed to get framework id")
-		return "", "", "", "", &task.BrowseSandboxResponse{
+		return "", "", "", "", "", &task.BrowseSandboxResponse{
 			Error: &task.BrowseSandboxResponse_Error{
 				Failure: &task.BrowseSandboxFailure{
 					Message: err.Error(),
@@ -1069,7 +2114,13 @@ func (m *serviceHandler) getSandboxPathInfo(
 			},
 		}
 	}
-	return host, agentid, taskid, frameworkid, nil
+
+	if len(req.GetTaskId()) > 0 {
+		executor = m.getExecutorOfRun(
+			ctx, req.GetJobId(), req.GetInstanceId(), configVersion)
+	}
+	return host, agentid, taskid, util.CreateExecutorID(taskid, executor),
+		frameworkid, nil
 }
 
 // BrowseSandbox returns the list of sandbox files path, with agent name, agent id and mesos master name & port.
@@ -1096,31 +2147,17 @@ func (m *serviceHandler) BrowseSandbox(
 		}, nil
 	}
 
-	hostname, agentID, taskID, frameworkID, resp := m.getSandboxPathInfo(ctx,
-		jobConfig.GetInstanceCount(), req)
+	hostname, agentID, taskID, executorID, frameworkID, resp :=
+		m.getSandboxPathInfo(ctx, jobConfig.GetInstanceCount(), req)
 	if resp != nil {
+		m.metrics.TaskListLogsFail.Inc(1)
 		return resp, nil
 	}
 
-	// Extract the IP address + port of the agent, if possible,
-	// because the hostname may not be resolvable on the network
-	agentIP := hostname
-	agentPort := "5051"
-	agentResponse, err := m.hostMgrClient.GetMesosAgentInfo(ctx,
-		&hostsvc.GetMesosAgentInfoRequest{Hostname: hostname})
-	if err == nil && len(agentResponse.Agents) > 0 {
-		ip, port, err := util.ExtractIPAndPortFromMesosAgentPID(
-			agentResponse.Agents[0].GetPid())
-		if err == nil {
-			agentIP = ip
-			if port != "" {
-				agentPort = port
-			}
-		}
-	} else {
-		log.WithField("hostname", hostname).Info(
-			"Could not get Mesos agent info")
-	}
+	agentIP, agentPort := m.sandboxInfo.GetAgentAddress(ctx, hostname, agentID)
+
+	layout := m.getSandboxLayout(
+		hostname, agentIP, agentPort, executorID, req.GetScope())
 
 	log.WithFields(log.Fields{
 		"hostname":     hostname,
@@ -1129,13 +2166,16 @@ func (m *serviceHandler) BrowseSandbox(
 		"agent_id":     agentID,
 		"task_id":      taskID,
 		"framework_id": frameworkID,
+		"work_dir":     layout.WorkDir,
 	}).Debug("Listing sandbox files")
 
-	var logPaths []string
-	logPaths, err = m.logManager.ListSandboxFilesPaths(m.mesosAgentWorkDir,
-		frameworkID, agentIP, agentPort, agentID, taskID)
+	files, err := m.logManager.ListSandboxFiles(layout,
+		frameworkID, agentIP, agentPort, agentID, taskID, req.GetRecursive())
 
 	if err != nil {
+		// the agent, the Mesos master or the framework may have
+		// re-registered since they were cached
+		m.sandboxInfo.Invalidate()
 		m.metrics.TaskListLogsFail.Inc(1)
 		log.WithError(err).WithFields(log.Fields{
 			"req":          req,
@@ -1158,7 +2198,7 @@ func (m *serviceHandler) BrowseSandbox(
 		}, nil
 	}
 
-	mesosMasterHostPortRespose, err := m.hostMgrClient.GetMesosMasterHostPort(ctx, &hostsvc.MesosMasterHostPortRequest{})
+	mesosMasterHostPortRespose, err := m.sandboxInfo.GetMesosMasterHostPort(ctx)
 	if err != nil {
 		m.metrics.TaskListLogsFail.Inc(1)
 		log.WithError(err).WithFields(log.Fields{
@@ -1179,6 +2219,23 @@ func (m *serviceHandler) BrowseSandbox(
 		}, nil
 	}
 
+	var logPaths []string
+	var sandboxFiles []*task.SandboxFile
+	for _, file := range files {
+		var modificationTime string
+		if !file.ModificationTime.IsZero() {
+			modificationTime = file.ModificationTime.UTC().Format(time.RFC3339)
+		}
+		logPaths = append(logPaths, file.Path)
+		sandboxFiles = append(sandboxFiles, &task.SandboxFile{
+			Path:             file.Path,
+			Size:             file.Size,
+			ModificationTime: modificationTime,
+			Mode:             file.Mode,
+			IsDirectory:      file.IsDirectory,
+		})
+	}
+
 	m.metrics.TaskListLogs.Inc(1)
 	resp = &task.BrowseSandboxResponse{
 		Hostname:            agentIP,
@@ -1186,105 +2243,1834 @@ func (m *serviceHandler) BrowseSandbox(
 		Paths:               logPaths,
 		MesosMasterHostname: mesosMasterHostPortRespose.Hostname,
 		MesosMasterPort:     mesosMasterHostPortRespose.Port,
+		Files:               sandboxFiles,
 	}
 	log.WithField("response", resp).Info("TaskSVC.BrowseSandbox returned")
 	return resp, nil
 }
 
-// TODO: remove this function once eventstream is enabled in RM
-// fillReasonForPendingTasksFromResMgr takes a list of taskinfo and
-// fills in the reason for pending tasks from ResourceManager.
-// All the tasks in `taskInfos` should belong to the same job
-func (m *serviceHandler) fillReasonForPendingTasksFromResMgr(
-	ctx context.Context,
-	jobID *peloton.JobID,
-	taskInfos []*task.TaskInfo,
-) {
-	// only need to consult ResourceManager for PENDING tasks,
-	// because only tasks with PENDING states are being processed by ResourceManager
-	for _, taskInfo := range taskInfos {
-		if taskInfo.GetRuntime().GetState() == task.TaskState_PENDING {
-			// attach the reason from the taskEntry in activeRMTasks
-			taskEntry := m.activeRMTasks.GetTask(
-				util.CreatePelotonTaskID(
-					jobID.GetValue(),
-					taskInfo.GetInstanceId(),
-				),
-			)
-			if taskEntry != nil {
-				taskInfo.GetRuntime().Reason = taskEntry.GetReason()
-			}
-		}
+// TailSandboxFile follows a file in the sandbox of a task and streams its
+// contents as they are written, until the client cancels the stream.
+func (m *serviceHandler) TailSandboxFile(
+	req *task.TailSandboxFileRequest,
+	stream task.TaskManagerServiceTailSandboxFileYARPCServer,
+) error {
+	log.WithField("req", req).Debug("TaskSVC.TailSandboxFile called")
+	m.metrics.TaskAPITailSandboxFile.Inc(1)
+
+	ctx := stream.Context()
+	file, err := m.getSandboxFile(ctx, req.GetJobId(), req.GetInstanceId(),
+		req.GetTaskId(), req.GetPath(), req.GetScope())
+	if err != nil {
+		m.metrics.TaskTailSandboxFileFail.Inc(1)
+		return err
+	}
+
+	err = m.logManager.TailSandboxFile(ctx, file.layout,
+		file.frameworkID, file.agentIP, file.agentPort, file.agentID,
+		file.taskID, file.path, req.GetOffset(),
+		func(offset uint64, data []byte) error {
+			return stream.Send(&task.TailSandboxFileResponse{
+				Offset: offset,
+				Data:   data,
+			})
+		})
+	if err != nil {
+		m.metrics.TaskTailSandboxFileFail.Inc(1)
+		log.WithError(err).WithFields(log.Fields{
+			"req":          req,
+			"hostname":     file.hostname,
+			"framework_id": file.frameworkID,
+			"agent_id":     file.agentID,
+		}).Info("failed to tail sandbox file")
+		return yarpcerrors.InternalErrorf(
+			"tail sandbox file failed on host:%s due to: %v",
+			file.hostname, err)
 	}
+
+	m.metrics.TaskTailSandboxFile.Inc(1)
+	return nil
 }
 
-// GetFrameworkID returns the frameworkID.
-func (m *serviceHandler) getFrameworkID(ctx context.Context) (string, error) {
-	frameworkIDVal, err := m.frameworkInfoStore.GetFrameworkID(ctx, _frameworkName)
+// DownloadSandboxFile returns the contents of a file in the sandbox of a
+// task, proxied from the Mesos agent.
+func (m *serviceHandler) DownloadSandboxFile(
+	ctx context.Context,
+	req *task.DownloadSandboxFileRequest,
+) (*task.DownloadSandboxFileResponse, error) {
+	log.WithField("req", req).Debug("TaskSVC.DownloadSandboxFile called")
+	m.metrics.TaskAPIDownloadSandboxFile.Inc(1)
+
+	file, err := m.getSandboxFile(ctx, req.GetJobId(), req.GetInstanceId(),
+		req.GetTaskId(), req.GetPath(), req.GetScope())
 	if err != nil {
-		return frameworkIDVal, err
+		m.metrics.TaskDownloadSandboxFileFail.Inc(1)
+		return nil, err
 	}
-	if frameworkIDVal == "" {
-		return frameworkIDVal, errEmptyFrameworkID
+
+	chunk, err := m.logManager.ReadSandboxFile(ctx, file.layout,
+		file.frameworkID, file.agentIP, file.agentPort, file.agentID,
+		file.taskID, file.path, req.GetOffset(), int(req.GetLength()))
+	if err != nil {
+		m.metrics.TaskDownloadSandboxFileFail.Inc(1)
+		log.WithError(err).WithFields(log.Fields{
+			"req":          req,
+			"hostname":     file.hostname,
+			"framework_id": file.frameworkID,
+			"agent_id":     file.agentID,
+		}).Info("failed to download sandbox file")
+		return nil, yarpcerrors.InternalErrorf(
+			"download sandbox file failed on host:%s due to: %v",
+			file.hostname, err)
 	}
-	return frameworkIDVal, nil
+
+	m.metrics.TaskDownloadSandboxFile.Inc(1)
+	return &task.DownloadSandboxFileResponse{
+		Offset: chunk.Offset,
+		Data:   chunk.Data,
+		Size:   chunk.Size,
+	}, nil
 }
 
-// getPodEvents returns all the pod events for given
-// job_id + instance_id + optional (run_id)
-func (m *serviceHandler) getPodEvents(
+// GetTaskStateSummary returns the number of tasks of a job in each state,
+// and the number of pending tasks for each reason. The task states are
+// counted by the store, so that the runtimes of the tasks are not loaded.
+func (m *serviceHandler) GetTaskStateSummary(
 	ctx context.Context,
-	id *peloton.JobID,
-	instanceID uint32,
-	runID string) ([]*task.PodEvent, error) {
-	var events []*task.PodEvent
-	for {
-		podEvents, err := m.taskStore.GetPodEvents(ctx, id.GetValue(), instanceID, runID)
-		if err != nil {
-			return nil, err
-		}
-		if len(podEvents) == 0 {
-			break
-		}
+	req *task.GetTaskStateSummaryRequest,
+) (*task.GetTaskStateSummaryResponse, error) {
+	log.WithField("req", req).Debug("TaskSVC.GetTaskStateSummary called")
+	m.metrics.TaskAPIGetTaskStateSummary.Inc(1)
+
+	jobID := req.GetJobId()
+	if len(jobID.GetValue()) == 0 {
+		m.metrics.TaskGetTaskStateSummaryFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf("job id is not provided")
+	}
 
-		taskEvents, err := convertPodEventsFormat(podEvents)
-		if err != nil {
-			return nil, err
-		}
-		events = append(events, taskEvents...)
+	jobConfig, err := handler.GetJobConfigWithoutFillingCache(
+		ctx, jobID, m.jobFactory, m.jobStore)
+	if err != nil {
+		m.metrics.TaskGetTaskStateSummaryFail.Inc(1)
+		return nil, yarpcerrors.NotFoundErrorf(
+			"job %v not found, %v", jobID.GetValue(), err)
+	}
 
-		prevRunID, err := util.ParseRunID(podEvents[0].GetPrevPodId().GetValue())
-		if err != nil {
-			return nil, err
+	stateCounts, err := m.taskStore.GetTaskStateSummaryForJob(ctx, jobID)
+	if err != nil {
+		m.metrics.TaskGetTaskStateSummaryFail.Inc(1)
+		return nil, err
+	}
+
+	resp := &task.GetTaskStateSummaryResponse{
+		StateCounts:         make(map[string]uint32),
+		PendingReasonCounts: make(map[string]uint32),
+	}
+	for state, count := range stateCounts {
+		if count > 0 {
+			resp.StateCounts[state] = count
 		}
-		// Reached last run for this task
-		if prevRunID == 0 {
-			break
+	}
+
+	// only the tasks being processed by ResourceManager have a reason for
+	// being pending, see fillReasonForPendingTasksFromResMgr
+	if resp.StateCounts[task.TaskState_PENDING.String()] > 0 {
+		for i := uint32(0); i < jobConfig.GetInstanceCount(); i++ {
+			taskEntry := m.activeRMTasks.GetTask(
+				util.CreatePelotonTaskID(jobID.GetValue(), i))
+			if taskEntry.GetTaskState() == task.TaskState_PENDING.String() {
+				resp.PendingReasonCounts[taskEntry.GetReason()]++
+			}
 		}
-		runID = podEvents[0].GetPrevPodId().GetValue()
 	}
 
-	return events, nil
+	m.metrics.TaskGetTaskStateSummary.Inc(1)
+	return resp, nil
 }
 
-// getTerminalEvents filters input pod events and return on terminal ones
-func (m *serviceHandler) getTerminalEvents(
-	eventList []*task.PodEvent,
-	lastTaskInfo *task.TaskInfo) []*task.TaskInfo {
-	var taskInfos []*task.TaskInfo
+// Requeue moves a task wedged between placement and launch back to the
+// resource manager. The task is killed in the resource manager, which
+// releases its resources and the host held for it, and its runtime is
+// regenerated so that the goal state engine enqueues a new run of the task.
+func (m *serviceHandler) Requeue(
+	ctx context.Context,
+	req *task.RequeueRequest,
+) (resp *task.RequeueResponse, err error) {
+	log.WithField("request", req).Info("TaskSVC.Requeue called")
+	defer func(ctx context.Context) {
+		m.recordTaskOperation(ctx, "Requeue", req.GetJobId(), req, resp, err)
+	}(ctx)
 
-	for _, event := range eventList {
-		taskState := task.TaskState(task.TaskState_value[event.GetActualState()])
-		if !util.IsPelotonStateTerminal(taskState) {
-			continue
-		}
+	m.metrics.TaskAPIRequeue.Inc(1)
 
-		mesosID := event.GetTaskId().GetValue()
-		prevMesosID := event.GetPrevTaskId().GetValue()
-		agentID := event.GetAgentID()
-		taskInfos = append(taskInfos, &task.TaskInfo{
-			InstanceId: lastTaskInfo.GetInstanceId(),
-			JobId:      lastTaskInfo.GetJobId(),
+	if !m.candidate.IsLeader() {
+		m.metrics.TaskRequeueFail.Inc(1)
+		return nil, yarpcerrors.UnavailableErrorf(
+			"Task Requeue API not supported on non-leader")
+	}
+
+	ctx, cancelFunc := context.WithTimeout(ctx, _rpcTimeout)
+	defer cancelFunc()
+
+	jobID := req.GetJobId()
+	instanceID := req.GetInstanceId()
+
+	cachedJob := m.jobFactory.AddJob(jobID)
+	cachedTask, err := cachedJob.AddTask(ctx, instanceID)
+	if err != nil {
+		m.metrics.TaskRequeueFail.Inc(1)
+		return nil, yarpcerrors.NotFoundErrorf(
+			"task %d of job %v not found, %v",
+			instanceID, jobID.GetValue(), err)
+	}
+
+	// the tasks of a paused job are neither killed nor launched
+	if m.goalStateDriver.IsPaused(jobID) {
+		m.metrics.TaskRequeueFail.Inc(1)
+		return nil, yarpcerrors.FailedPreconditionErrorf(
+			"goal state of the job is paused")
+	}
+
+	runtime, err := cachedTask.GetRuntime(ctx)
+	if err != nil {
+		m.metrics.TaskRequeueFail.Inc(1)
+		return nil, err
+	}
+
+	if !_requeueableStates[runtime.GetState()] {
+		m.metrics.TaskRequeueFail.Inc(1)
+		return nil, yarpcerrors.FailedPreconditionErrorf(
+			"task in state %s cannot be requeued", runtime.GetState())
+	}
+
+	if runtime.GetGoalState() == task.TaskState_KILLED ||
+		runtime.GetGoalState() == task.TaskState_DELETED {
+		m.metrics.TaskRequeueFail.Inc(1)
+		return nil, yarpcerrors.FailedPreconditionErrorf(
+			"task is being stopped")
+	}
+
+	taskConfig, _, err := m.taskStore.GetTaskConfig(
+		ctx, jobID, instanceID, runtime.GetConfigVersion())
+	if err != nil {
+		m.metrics.TaskRequeueFail.Inc(1)
+		return nil, err
+	}
+
+	if err := m.killResMgrTask(ctx, jobID, instanceID); err != nil {
+		m.metrics.TaskRequeueFail.Inc(1)
+		return nil, err
+	}
+
+	// The task may have been sent to the host, or launched on it without
+	// Mesos reporting it yet, so kill the current run to not leave it behind.
+	if runtime.GetState() == task.TaskState_LAUNCHING ||
+		runtime.GetState() == task.TaskState_LAUNCHED {
+		if err := jobmgr_task.KillOrphanTask(ctx, m.hostMgrClient, &task.TaskInfo{
+			JobId:      jobID,
+			InstanceId: instanceID,
+			Runtime:    runtime,
+			Config:     taskConfig,
+		}); err != nil {
+			m.metrics.TaskRequeueFail.Inc(1)
+			return nil, err
+		}
+	}
+
+	newRuntime := proto.Clone(runtime).(*task.RuntimeInfo)
+	taskutil.RegenerateMesosTaskRuntime(
+		jobID,
+		instanceID,
+		newRuntime,
+		taskutil.GetInitialHealthState(taskConfig))
+	newRuntime.Message = "Task requeued by API request"
+
+	// The runtime is replaced only if it has not changed since it was read,
+	// as the checks above and the kill of the current run are based on it.
+	updatedRuntime, err := cachedTask.CompareAndSetRuntime(
+		ctx, newRuntime, cachedJob.GetJobType())
+	if err == jobmgrcommon.UnexpectedVersionError ||
+		(err == nil && updatedRuntime == nil) {
+		m.metrics.TaskRequeueFail.Inc(1)
+		return nil, yarpcerrors.AbortedErrorf(
+			"task changed while being requeued, retry the request")
+	}
+	if err != nil {
+		m.metrics.TaskRequeueFail.Inc(1)
+		return nil, err
+	}
+
+	m.goalStateDriver.EnqueueTask(jobID, instanceID, time.Now())
+	m.goalStateDriver.EnqueueJobWithDefaultDelay(
+		jobID, cachedJob.GetJobType())
+
+	m.metrics.TaskRequeue.Inc(1)
+	return &task.RequeueResponse{
+		MesosTaskId: updatedRuntime.GetMesosTaskId(),
+	}, nil
+}
+
+// killResMgrTask removes a task from the resource manager. A task not
+// found in the resource manager is not treated as an error.
+func (m *serviceHandler) killResMgrTask(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	instanceID uint32) error {
+	resp, err := m.resmgrClient.KillTasks(ctx, &resmgrsvc.KillTasksRequest{
+		Tasks: []*peloton.TaskID{
+			{Value: util.CreatePelotonTaskID(jobID.GetValue(), instanceID)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range resp.GetError() {
+		if e.GetNotFound() != nil {
+			log.WithFields(log.Fields{
+				"job_id":      jobID.GetValue(),
+				"instance_id": instanceID,
+				"error":       e.GetNotFound().GetMessage(),
+			}).Info("task to requeue not found in resmgr")
+			continue
+		}
+		return yarpcerrors.InternalErrorf(
+			"failed to kill task in resmgr: %s",
+			e.GetKillError().GetMessage())
+	}
+	return nil
+}
+
+// GetTaskOperationHistory returns the mutating operations made on the
+// tasks of a job, most recent first. The operations made on a range of
+// instances are read from the operations recorded for each instance,
+// instead of all the operations of the job. The operations can be
+// filtered by the incident ID and change ticket they were annotated with.
+func (m *serviceHandler) GetTaskOperationHistory(
+	ctx context.Context,
+	req *task.GetTaskOperationHistoryRequest,
+) (*task.GetTaskOperationHistoryResponse, error) {
+	log.WithField("request", req).Debug("TaskSVC.GetTaskOperationHistory called")
+	m.metrics.TaskAPIGetTaskOperationHistory.Inc(1)
+
+	if len(req.GetJobId().GetValue()) == 0 {
+		m.metrics.TaskGetTaskOperationHistoryFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf("job id is not provided")
+	}
+
+	var operations []*task.TaskOperation
+	var err error
+	if r := req.GetRange(); r != nil {
+		if r.GetFrom() >= r.GetTo() ||
+			r.GetTo()-r.GetFrom() > _maxOperationInstances {
+			m.metrics.TaskGetTaskOperationHistoryFail.Inc(1)
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"instance range must contain between 1 and %d instances",
+				_maxOperationInstances)
+		}
+		var instanceIDs []uint32
+		for i := r.GetFrom(); i < r.GetTo(); i++ {
+			instanceIDs = append(instanceIDs, i)
+		}
+		operations, err = m.taskOperationOps.GetForInstances(
+			ctx, req.GetJobId(), instanceIDs)
+	} else {
+		operations, err = m.taskOperationOps.GetAll(ctx, req.GetJobId())
+	}
+	if err != nil {
+		m.metrics.TaskGetTaskOperationHistoryFail.Inc(1)
+		return nil, err
+	}
+
+	if len(req.GetIncidentId()) > 0 || len(req.GetChangeTicket()) > 0 {
+		var matched []*task.TaskOperation
+		for _, op := range operations {
+			if len(req.GetIncidentId()) > 0 &&
+				op.GetOperationContext().GetIncidentId() != req.GetIncidentId() {
+				continue
+			}
+			if len(req.GetChangeTicket()) > 0 &&
+				op.GetOperationContext().GetChangeTicket() != req.GetChangeTicket() {
+				continue
+			}
+			matched = append(matched, op)
+		}
+		operations = matched
+	}
+
+	if req.GetLimit() > 0 && uint32(len(operations)) > req.GetLimit() {
+		operations = operations[:req.GetLimit()]
+	}
+
+	m.metrics.TaskGetTaskOperationHistory.Inc(1)
+	return &task.GetTaskOperationHistoryResponse{
+		Operations: operations,
+	}, nil
+}
+
+// GetOperationStatus returns the progress of an asynchronous Start, Stop
+// or Restart call.
+func (m *serviceHandler) GetOperationStatus(
+	ctx context.Context,
+	req *task.GetOperationStatusRequest,
+) (*task.GetOperationStatusResponse, error) {
+	log.WithField("request", req).Debug("TaskSVC.GetOperationStatus called")
+	m.metrics.TaskAPIGetOperationStatus.Inc(1)
+
+	if !m.candidate.IsLeader() {
+		m.metrics.TaskGetOperationStatusFail.Inc(1)
+		return nil, yarpcerrors.UnavailableErrorf(
+			"Task GetOperationStatus API not supported on non-leader")
+	}
+
+	if len(req.GetOperationId()) == 0 {
+		m.metrics.TaskGetOperationStatusFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"operation id is not provided")
+	}
+
+	op := m.operations.Get(req.GetOperationId())
+	if op == nil {
+		m.metrics.TaskGetOperationStatusFail.Inc(1)
+		return nil, yarpcerrors.NotFoundErrorf(
+			"operation %s not found", req.GetOperationId())
+	}
+
+	m.metrics.TaskGetOperationStatus.Inc(1)
+	return operationStatus(op), nil
+}
+
+// PatchInstanceConfig merges a config override into the instance config
+// of a task, and restarts the task with the new config. The override is
+// persisted as a new version of the job config, which only the patched
+// instance moves to. The patched config is validated, and its signature
+// verified, as the config of a job update would be.
+func (m *serviceHandler) PatchInstanceConfig(
+	ctx context.Context,
+	req *task.PatchInstanceConfigRequest,
+) (resp *task.PatchInstanceConfigResponse, err error) {
+	log.WithField("request", req).Info("TaskSVC.PatchInstanceConfig called")
+	defer func(ctx context.Context) {
+		m.recordTaskOperation(
+			ctx, "PatchInstanceConfig", req.GetJobId(), req, resp, err)
+	}(ctx)
+
+	m.metrics.TaskAPIPatchInstanceConfig.Inc(1)
+
+	if !m.candidate.IsLeader() {
+		m.metrics.TaskPatchInstanceConfigFail.Inc(1)
+		return nil, yarpcerrors.UnavailableErrorf(
+			"Task PatchInstanceConfig API not supported on non-leader")
+	}
+
+	if req.GetConfig() == nil {
+		m.metrics.TaskPatchInstanceConfigFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"config override is not provided")
+	}
+
+	ctx, cancelFunc := context.WithTimeout(ctx, _rpcTimeout)
+	defer cancelFunc()
+
+	jobID := req.GetJobId()
+	instanceID := req.GetInstanceId()
+
+	jobConfig, configAddOn, err := m.jobStore.GetJobConfig(
+		ctx, jobID.GetValue())
+	if err != nil {
+		m.metrics.TaskPatchInstanceConfigFail.Inc(1)
+		return nil, err
+	}
+
+	if instanceID >= jobConfig.GetInstanceCount() {
+		m.metrics.TaskPatchInstanceConfigFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"instance %d is out of range", instanceID)
+	}
+
+	cachedJob := m.jobFactory.AddJob(jobID)
+	jobRuntime, err := cachedJob.GetRuntime(ctx)
+	if err != nil {
+		m.metrics.TaskPatchInstanceConfigFail.Inc(1)
+		return nil, err
+	}
+
+	if util.IsPelotonJobStateTerminal(jobRuntime.GetState()) {
+		m.metrics.TaskPatchInstanceConfigFail.Inc(1)
+		return nil, yarpcerrors.FailedPreconditionErrorf(
+			"job is in terminal state %s", jobRuntime.GetState())
+	}
+
+	// the update would move the patched instance back to the config of
+	// the update, so wait for it to finish
+	if updateutil.HasUpdate(jobRuntime) {
+		updateModel, err := m.updateStore.GetUpdateProgress(
+			ctx, jobRuntime.GetUpdateID())
+		if err != nil {
+			m.metrics.TaskPatchInstanceConfigFail.Inc(1)
+			return nil, err
+		}
+		if !cached.IsUpdateStateTerminal(updateModel.GetState()) {
+			m.metrics.TaskPatchInstanceConfigFail.Inc(1)
+			return nil, yarpcerrors.FailedPreconditionErrorf(
+				"job has an update in progress")
+		}
+	}
+
+	cachedTask, err := cachedJob.AddTask(ctx, instanceID)
+	if err != nil {
+		m.metrics.TaskPatchInstanceConfigFail.Inc(1)
+		return nil, yarpcerrors.NotFoundErrorf(
+			"task %d of job %v not found, %v",
+			instanceID, jobID.GetValue(), err)
+	}
+
+	taskRuntime, err := cachedTask.GetRuntime(ctx)
+	if err != nil {
+		m.metrics.TaskPatchInstanceConfigFail.Inc(1)
+		return nil, err
+	}
+
+	if taskRuntime.GetGoalState() == task.TaskState_KILLED ||
+		taskRuntime.GetGoalState() == task.TaskState_DELETED {
+		m.metrics.TaskPatchInstanceConfigFail.Inc(1)
+		return nil, yarpcerrors.FailedPreconditionErrorf(
+			"task is being stopped")
+	}
+
+	newConfig := proto.Clone(jobConfig).(*pb_job.JobConfig)
+	if newConfig.InstanceConfig == nil {
+		newConfig.InstanceConfig = make(map[uint32]*task.TaskConfig)
+	}
+	newConfig.InstanceConfig[instanceID] = taskconfig.Merge(
+		jobConfig.GetInstanceConfig()[instanceID], req.GetConfig())
+
+	if err := jobconfig.ValidateInstanceConfig(
+		newConfig, instanceID); err != nil {
+		m.metrics.TaskPatchInstanceConfigFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf("%v", err)
+	}
+
+	newConfigAddOn, err := m.verifyPatchedConfig(
+		newConfig, configAddOn, req.GetSignatureKeyId(), req.GetSignature())
+	if err != nil {
+		m.metrics.TaskPatchInstanceConfigFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf("%v", err)
+	}
+
+	newUpdatedConfig, err := cachedJob.CompareAndSetConfig(
+		ctx, newConfig, newConfigAddOn)
+	if err != nil {
+		m.metrics.TaskPatchInstanceConfigFail.Inc(1)
+		return nil, err
+	}
+	configVersion := newUpdatedConfig.GetChangeLog().GetVersion()
+
+	// the task configs of all the instances are created at the new
+	// version, as for an update, so that the job config version is
+	// complete even though only the patched instance moves to it
+	newConfig.ChangeLog = newUpdatedConfig.GetChangeLog()
+	if err := cachedJob.CreateTaskConfigs(
+		ctx, jobID, newConfig, newConfigAddOn); err != nil {
+		m.metrics.TaskPatchInstanceConfigFail.Inc(1)
+		return nil, err
+	}
+
+	if err := cachedJob.Update(ctx, &pb_job.JobInfo{
+		Runtime: &pb_job.RuntimeInfo{
+			ConfigurationVersion: configVersion,
+		},
+	}, nil,
+		cached.UpdateCacheAndDB); err != nil {
+		m.metrics.TaskPatchInstanceConfigFail.Inc(1)
+		return nil, err
+	}
+
+	if err := cachedJob.PatchTasks(
+		ctx,
+		map[uint32]jobmgrcommon.RuntimeDiff{
+			instanceID: {
+				jobmgrcommon.DesiredConfigVersionField: configVersion,
+				jobmgrcommon.MessageField:              "Task config patched by API request",
+				jobmgrcommon.FailureCountField:         uint32(0),
+			},
+		},
+	); err != nil {
+		m.metrics.TaskPatchInstanceConfigFail.Inc(1)
+		return nil, err
+	}
+
+	m.goalStateDriver.EnqueueTask(jobID, instanceID, time.Now())
+
+	m.metrics.TaskPatchInstanceConfig.Inc(1)
+	return &task.PatchInstanceConfigResponse{
+		ConfigVersion: configVersion,
+	}, nil
+}
+
+// RunTaskCommand executes a command in the container of a running task,
+// through the host manager, and streams its output until the command
+// exits.
+func (m *serviceHandler) RunTaskCommand(
+	req *task.RunTaskCommandRequest,
+	stream task.TaskManagerServiceRunTaskCommandYARPCServer,
+) (err error) {
+	log.WithField("request", req).Info("TaskSVC.RunTaskCommand called")
+	ctx := stream.Context()
+
+	var resp *task.RunTaskCommandResponse
+	defer func() {
+		m.recordTaskOperation(
+			ctx, "RunTaskCommand", req.GetJobId(), req, resp, err)
+	}()
+
+	m.metrics.TaskAPIRunTaskCommand.Inc(1)
+
+	grant, err := m.authorizeTaskCommand(ctx, req.GetJobId())
+	var hostname string
+	defer func() {
+		// every task command is audited, including the rejected ones
+		entry := log.WithFields(log.Fields{
+			"grant":       grant,
+			"caller":      m.callerIdentity(ctx),
+			"job_id":      req.GetJobId().GetValue(),
+			"instance_id": req.GetInstanceId(),
+			"hostname":    hostname,
+			"command":     req.GetCommand(),
+		})
+		if resp != nil {
+			entry = entry.WithField("exit_code", resp.GetExitCode())
+		}
+		if err != nil {
+			entry = entry.WithError(err)
+		}
+		entry.Info("task command audit")
+	}()
+	if err != nil {
+		m.metrics.TaskRunTaskCommandFail.Inc(1)
+		return err
+	}
+
+	if !m.candidate.IsLeader() {
+		m.metrics.TaskRunTaskCommandFail.Inc(1)
+		return yarpcerrors.UnavailableErrorf(
+			"Task RunTaskCommand API not supported on non-leader")
+	}
+
+	if len(req.GetCommand()) == 0 {
+		m.metrics.TaskRunTaskCommandFail.Inc(1)
+		return yarpcerrors.InvalidArgumentErrorf("command is not provided")
+	}
+
+	cachedJob := m.jobFactory.GetJob(req.GetJobId())
+	if cachedJob == nil {
+		m.metrics.TaskRunTaskCommandFail.Inc(1)
+		return yarpcerrors.NotFoundErrorf(
+			"job %s not found", req.GetJobId().GetValue())
+	}
+	cachedTask := cachedJob.GetTask(req.GetInstanceId())
+	if cachedTask == nil {
+		m.metrics.TaskRunTaskCommandFail.Inc(1)
+		return yarpcerrors.NotFoundErrorf(
+			"instance %d not found", req.GetInstanceId())
+	}
+	runtime, err := cachedTask.GetRuntime(ctx)
+	if err != nil {
+		m.metrics.TaskRunTaskCommandFail.Inc(1)
+		return err
+	}
+	if runtime.GetState() != task.TaskState_RUNNING {
+		m.metrics.TaskRunTaskCommandFail.Inc(1)
+		return yarpcerrors.FailedPreconditionErrorf(
+			"task is not running but %s", runtime.GetState())
+	}
+	hostname = runtime.GetHost()
+
+	hostStream, err := m.hostMgrClient.ExecTaskCommand(ctx,
+		&hostsvc.ExecTaskCommandRequest{
+			Hostname: hostname,
+			TaskId:   runtime.GetMesosTaskId(),
+			Command:  req.GetCommand(),
+		},
+		yarpc.WithHeader(
+			"authorization", "Bearer "+m.taskCommand.HostManagerToken),
+	)
+	if err != nil {
+		m.metrics.TaskRunTaskCommandFail.Inc(1)
+		return err
+	}
+
+	for {
+		var hostResp *hostsvc.ExecTaskCommandResponse
+		hostResp, err = hostStream.Recv()
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			m.metrics.TaskRunTaskCommandFail.Inc(1)
+			return err
+		}
+
+		out := &task.RunTaskCommandResponse{
+			Stdout:   hostResp.GetStdout(),
+			Stderr:   hostResp.GetStderr(),
+			Exited:   hostResp.GetExited(),
+			ExitCode: hostResp.GetExitCode(),
+		}
+		if out.GetExited() {
+			resp = out
+		}
+		if err = stream.Send(out); err != nil {
+			m.metrics.TaskRunTaskCommandFail.Inc(1)
+			return err
+		}
+	}
+
+	m.metrics.TaskRunTaskCommand.Inc(1)
+	return nil
+}
+
+// authorizeTaskCommand returns the name of the grant of the task command
+// config authenticating the call in the context, or an error if the grant
+// does not allow running commands in the tasks of the job.
+func (m *serviceHandler) authorizeTaskCommand(
+	ctx context.Context,
+	jobID *peloton.JobID,
+) (string, error) {
+	if len(m.taskCommand.Grants) == 0 {
+		return "", yarpcerrors.UnimplementedErrorf(
+			"task commands are not enabled")
+	}
+
+	var authorization string
+	if call := yarpc.CallFromContext(ctx); call != nil {
+		authorization = call.Header("authorization")
+	}
+
+	// all the grants are compared, so that the time taken does not
+	// depend on which grant matches the token
+	var matched *TaskCommandGrant
+	for i := range m.taskCommand.Grants {
+		grant := &m.taskCommand.Grants[i]
+		if len(grant.Token) > 0 &&
+			subtle.ConstantTimeCompare(
+				[]byte(authorization),
+				[]byte("Bearer "+grant.Token)) == 1 &&
+			matched == nil {
+			matched = grant
+		}
+	}
+	if matched == nil {
+		return "", yarpcerrors.UnauthenticatedErrorf(
+			"invalid task command token")
+	}
+
+	if !matched.allowsJob(jobID.GetValue()) {
+		return matched.Name, yarpcerrors.PermissionDeniedErrorf(
+			"%s is not allowed to run commands in job %s",
+			matched.Name, jobID.GetValue())
+	}
+	return matched.Name, nil
+}
+
+// verifyPatchedConfig verifies the signature of a job config patched by
+// PatchInstanceConfig, and returns the config add-on with the provenance
+// labels of the patched config. The signature is computed over the patched
+// config without its change log. The provenance of the previous config is
+// dropped, since it does not match the patched config.
+func (m *serviceHandler) verifyPatchedConfig(
+	config *pb_job.JobConfig,
+	configAddOn *models.ConfigAddOn,
+	keyID string,
+	signature []byte,
+) (*models.ConfigAddOn, error) {
+	var provenanceLabels []*peloton.Label
+	if m.configVerifier != nil {
+		signed := proto.Clone(config).(*pb_job.JobConfig)
+		signed.ChangeLog = nil
+		configProvenance, err := m.configVerifier.Verify(
+			signed,
+			&pb_job.ConfigSignature{KeyId: keyID, Signature: signature})
+		if err != nil {
+			log.WithError(err).
+				WithField("key_id", keyID).
+				Warn("Failed to verify patched job config signature")
+			return nil, err
+		}
+		provenanceLabels = configProvenance.Labels()
+	}
+
+	provenanceKeys := make(map[string]bool)
+	for _, key := range []string{
+		common.SystemLabelProvenanceKeyID,
+		common.SystemLabelProvenanceDigest,
+	} {
+		provenanceKeys[fmt.Sprintf(
+			common.SystemLabelKeyTemplate,
+			common.SystemLabelPrefix,
+			key)] = true
+	}
+	newConfigAddOn := &models.ConfigAddOn{}
+	for _, label := range configAddOn.GetSystemLabels() {
+		if !provenanceKeys[label.GetKey()] {
+			newConfigAddOn.SystemLabels = append(
+				newConfigAddOn.SystemLabels, label)
+		}
+	}
+	newConfigAddOn.SystemLabels = append(
+		newConfigAddOn.SystemLabels, provenanceLabels...)
+	return newConfigAddOn, nil
+}
+
+// startAsyncOperation starts processing the instances of a job in the
+// given ranges in the background, batchSize instances at a time, and
+// returns the ID of the operation. finish, if set, is called once all
+// the instances have been processed.
+func (m *serviceHandler) startAsyncOperation(
+	ctx context.Context,
+	operation string,
+	jobID *peloton.JobID,
+	ranges []*task.InstanceRange,
+	batchSize uint32,
+	process asyncOperationFunc,
+	finish func()) (string, error) {
+	cachedConfig, err := m.jobFactory.AddJob(jobID).GetConfig(ctx)
+	if err != nil {
+		log.WithError(err).
+			WithField("job_id", jobID.GetValue()).
+			Error("Failed to get job config")
+		return "", yarpcerrors.NotFoundErrorf(
+			"job %s not found: %v", jobID.GetValue(), err)
+	}
+
+	batches := splitInstanceRanges(
+		ranges, cachedConfig.GetInstanceCount(), batchSize)
+	var totalInstances uint32
+	for _, batch := range batches {
+		totalInstances += batch.GetTo() - batch.GetFrom()
+	}
+
+	op, err := m.operations.Add(operation, jobID.GetValue(), totalInstances)
+	if err != nil {
+		return "", err
+	}
+	go runAsyncOperation(op, batches, process, finish)
+
+	log.WithFields(log.Fields{
+		"job_id":          jobID.GetValue(),
+		"operation":       operation,
+		"operation_id":    op.ID(),
+		"total_instances": totalInstances,
+	}).Info("async operation started")
+	return op.ID(), nil
+}
+
+// getInstanceOutcomes returns the outcomes of the instances of an
+// asynchronous operation, sorted by instance id.
+func getInstanceOutcomes(
+	succeeded []uint32,
+	failed []uint32) []*task.InstanceOutcome {
+	var outcomes []*task.InstanceOutcome
+	for _, instanceID := range succeeded {
+		outcomes = append(outcomes, &task.InstanceOutcome{
+			InstanceId: instanceID,
+			Succeeded:  true,
+		})
+	}
+	for _, instanceID := range failed {
+		outcomes = append(outcomes, &task.InstanceOutcome{
+			InstanceId: instanceID,
+			Message:    "failed to update the task runtime",
+		})
+	}
+	sort.Slice(outcomes, func(i, j int) bool {
+		return outcomes[i].GetInstanceId() < outcomes[j].GetInstanceId()
+	})
+	return outcomes
+}
+
+// yarpcCaller returns the name of the caller of the call in the context.
+func yarpcCaller(ctx context.Context) string {
+	if call := yarpc.CallFromContext(ctx); call != nil {
+		return call.Caller()
+	}
+	return ""
+}
+
+// callerIdentity returns the identity of the caller of the call in the
+// context. It is the identity of the verified client certificate of the
+// call when TLS is enabled. Otherwise the caller header of the call is
+// returned, which any client can set, so it is prefixed to be recorded
+// as advisory only.
+func (m *serviceHandler) callerIdentity(ctx context.Context) string {
+	if peerID := m.tlsProvider.PeerID(ctx); len(peerID) > 0 {
+		return peerID
+	}
+	if caller := yarpcCaller(ctx); len(caller) > 0 {
+		return _unverifiedCallerPrefix + caller
+	}
+	return ""
+}
+
+// operationInstances returns the instances a call was made on, read from
+// the instance of the request, the instances stopped or started by the
+// call, or else the instance ranges of the request. Returns nil if the
+// call was made on all the instances of the job, or on more than
+// _maxOperationInstances instances.
+func operationInstances(req interface{}, resp interface{}) []uint32 {
+	if r, ok := req.(interface{ GetInstanceId() uint32 }); ok {
+		return []uint32{r.GetInstanceId()}
+	}
+
+	var instanceIDs []uint32
+	switch r := resp.(type) {
+	case *task.StopResponse:
+		instanceIDs = r.GetStoppedInstanceIds()
+	case *task.StartResponse:
+		instanceIDs = r.GetStartedInstanceIds()
+	}
+	if len(instanceIDs) == 0 {
+		var ranges []*task.InstanceRange
+		switch r := req.(type) {
+		case interface{ GetRanges() []*task.InstanceRange }:
+			ranges = r.GetRanges()
+		case interface{ GetRange() *task.InstanceRange }:
+			if r.GetRange() != nil {
+				ranges = []*task.InstanceRange{r.GetRange()}
+			}
+		}
+		for _, r := range ranges {
+			for i := r.GetFrom(); i < r.GetTo(); i++ {
+				if len(instanceIDs) == _maxOperationInstances {
+					return nil
+				}
+				instanceIDs = append(instanceIDs, i)
+			}
+		}
+	}
+	if len(instanceIDs) > _maxOperationInstances {
+		return nil
+	}
+	return instanceIDs
+}
+
+// recordTaskOperation adds a mutating operation made on the tasks of a
+// job to the audit trail of the job and of the instances it was made on.
+// Failing to record the operation does not fail the operation itself.
+func (m *serviceHandler) recordTaskOperation(
+	ctx context.Context,
+	operation string,
+	jobID *peloton.JobID,
+	req interface{},
+	resp interface{},
+	err error) {
+	if len(jobID.GetValue()) == 0 {
+		return
+	}
+
+	caller := m.callerIdentity(ctx)
+
+	var result string
+	if err != nil {
+		result = err.Error()
+	} else if b, jsonErr := json.Marshal(resp); jsonErr == nil {
+		result = string(b)
+	}
+
+	var request string
+	if b, jsonErr := json.Marshal(req); jsonErr == nil {
+		request = string(b)
+	}
+
+	var idempotencyKey string
+	if r, ok := req.(interface{ GetIdempotencyKey() string }); ok {
+		idempotencyKey = r.GetIdempotencyKey()
+	}
+
+	var operationContext *task.OperationContext
+	if r, ok := req.(interface {
+		GetOperationContext() *task.OperationContext
+	}); ok {
+		operationContext = r.GetOperationContext()
+	}
+
+	instanceIDs := operationInstances(req, resp)
+	if err := m.taskOperationOps.Create(ctx, jobID, instanceIDs, &task.TaskOperation{
+		Operation:        operation,
+		Caller:           caller,
+		Request:          request,
+		Result:           result,
+		IdempotencyKey:   idempotencyKey,
+		OperationContext: operationContext,
+	}); err != nil {
+		log.WithError(err).
+			WithFields(log.Fields{
+				"job_id":    jobID.GetValue(),
+				"operation": operation,
+			}).
+			Warn("failed to record task operation")
+	}
+}
+
+// reserveIdempotencyKey reserves the idempotency key of a call of the
+// operation on the tasks of a job before the call is processed, so that
+// concurrent retries of the call are not processed twice. If the key was
+// reserved by an earlier call which completed, its response is
+// unmarshalled into resp and true is returned. An error is returned if
+// the earlier call is still in progress, or if it was made with a
+// different request.
+func (m *serviceHandler) reserveIdempotencyKey(
+	ctx context.Context,
+	operation string,
+	jobID *peloton.JobID,
+	idempotencyKey string,
+	req interface{},
+	resp interface{}) (bool, error) {
+	if len(idempotencyKey) == 0 {
+		return false, nil
+	}
+
+	requestHash, err := getRequestHash(req)
+	if err != nil {
+		return false, yarpcerrors.InvalidArgumentErrorf(
+			"failed to marshal request: %v", err)
+	}
+
+	err = m.idempotencyKeyOps.Reserve(
+		ctx, jobID, operation, idempotencyKey, requestHash)
+	if err == nil {
+		return false, nil
+	}
+	if !yarpcerrors.IsAlreadyExists(err) {
+		log.WithError(err).
+			WithField("job_id", jobID.GetValue()).
+			Error("failed to reserve idempotency key")
+		return false, yarpcerrors.InternalErrorf(
+			"failed to reserve idempotency key %s: %v", idempotencyKey, err)
+	}
+
+	reservation, err := m.idempotencyKeyOps.Get(
+		ctx, jobID, operation, idempotencyKey)
+	if err == gocql.ErrNotFound {
+		// the earlier call failed and released the key in the meantime
+		return false, yarpcerrors.AbortedErrorf(
+			"request with idempotency key %s failed, retry", idempotencyKey)
+	}
+	if err != nil {
+		log.WithError(err).
+			WithField("job_id", jobID.GetValue()).
+			Error("failed to get idempotency key")
+		return false, yarpcerrors.InternalErrorf(
+			"failed to look up idempotency key %s: %v", idempotencyKey, err)
+	}
+	if reservation.RequestHash != requestHash {
+		return false, yarpcerrors.InvalidArgumentErrorf(
+			"idempotency key %s was used with a different request",
+			idempotencyKey)
+	}
+	if len(reservation.Response) == 0 {
+		return false, yarpcerrors.AbortedErrorf(
+			"request with idempotency key %s is in progress", idempotencyKey)
+	}
+	if err := json.Unmarshal([]byte(reservation.Response), resp); err != nil {
+		return false, yarpcerrors.InternalErrorf(
+			"failed to unmarshal response of idempotency key %s: %v",
+			idempotencyKey, err)
+	}
+
+	log.WithFields(log.Fields{
+		"job_id":          jobID.GetValue(),
+		"operation":       operation,
+		"idempotency_key": idempotencyKey,
+	}).Info("returning outcome of earlier request with same idempotency key")
+	m.metrics.TaskIdempotentRequestDeduplicated.Inc(1)
+	return true, nil
+}
+
+// getRequestHash returns the hash of a request, to check that the calls
+// made with the same idempotency key have the same request.
+func getRequestHash(req interface{}) (string, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(b)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// completeIdempotencyKey records the response of the call which reserved
+// the idempotency key, or releases the key if the call failed so that it
+// is processed again when retried. The context of the call is not used, as
+// it may have expired by the time the call completes.
+func (m *serviceHandler) completeIdempotencyKey(
+	operation string,
+	jobID *peloton.JobID,
+	idempotencyKey string,
+	resp interface{},
+	callErr error) {
+	if len(idempotencyKey) == 0 {
+		return
+	}
+
+	ctx, cancelFunc := context.WithTimeout(context.Background(), _rpcTimeout)
+	defer cancelFunc()
+
+	var err error
+	if callErr != nil {
+		err = m.idempotencyKeyOps.Delete(ctx, jobID, operation, idempotencyKey)
+	} else if b, jsonErr := json.Marshal(resp); jsonErr != nil {
+		err = m.idempotencyKeyOps.Delete(ctx, jobID, operation, idempotencyKey)
+	} else {
+		err = m.idempotencyKeyOps.SetResponse(
+			ctx, jobID, operation, idempotencyKey, string(b))
+	}
+	if err != nil {
+		log.WithError(err).
+			WithFields(log.Fields{
+				"job_id":          jobID.GetValue(),
+				"operation":       operation,
+				"idempotency_key": idempotencyKey,
+			}).
+			Warn("failed to complete idempotency key")
+	}
+}
+
+// sandboxFile is the location of a file in the sandbox of a task.
+type sandboxFile struct {
+	hostname    string
+	agentIP     string
+	agentPort   string
+	agentID     string
+	taskID      string
+	frameworkID string
+	// layout of the sandboxes on the agent
+	layout *logmanager.SandboxLayout
+	// path of the file relative to the sandbox directory
+	path string
+}
+
+// getSandboxFile returns the location of the file at filePath in the
+// sandbox of the scope of a run of a task. The latest run is used if
+// taskID is empty.
+func (m *serviceHandler) getSandboxFile(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	instanceID uint32,
+	taskID string,
+	filePath string,
+	scope task.SandboxScope) (*sandboxFile, error) {
+	cleanedPath, err := cleanSandboxFilePath(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	jobConfig, err := handler.GetJobConfigWithoutFillingCache(
+		ctx, jobID, m.jobFactory, m.jobStore)
+	if err != nil {
+		return nil, yarpcerrors.NotFoundErrorf(
+			"job %v not found, %v", jobID.GetValue(), err)
+	}
+
+	hostname, agentID, taskID, executorID, frameworkID, resp := m.getSandboxPathInfo(ctx,
+		jobConfig.GetInstanceCount(), &task.BrowseSandboxRequest{
+			JobId:      jobID,
+			InstanceId: instanceID,
+			TaskId:     taskID,
+		})
+	if resp != nil {
+		return nil, convertBrowseSandboxError(resp.GetError())
+	}
+
+	agentIP, agentPort := m.sandboxInfo.GetAgentAddress(ctx, hostname, agentID)
+	layout := m.getSandboxLayout(
+		hostname, agentIP, agentPort, executorID, scope)
+
+	file := &sandboxFile{
+		hostname:    hostname,
+		agentIP:     agentIP,
+		agentPort:   agentPort,
+		agentID:     agentID,
+		taskID:      taskID,
+		frameworkID: frameworkID,
+		layout:      layout,
+		path:        cleanedPath,
+	}
+	log.WithFields(log.Fields{
+		"hostname":     file.hostname,
+		"ip_address":   file.agentIP,
+		"port":         file.agentPort,
+		"agent_id":     file.agentID,
+		"task_id":      file.taskID,
+		"framework_id": file.frameworkID,
+		"work_dir":     file.layout.WorkDir,
+		"path":         file.path,
+	}).Debug("Found sandbox file")
+	return file, nil
+}
+
+// getSandboxLayout returns the layout of the sandboxes of the scope
+// on the agent, for the task run by the executor with executorID.
+func (m *serviceHandler) getSandboxLayout(
+	hostname string,
+	agentIP string,
+	agentPort string,
+	executorID string,
+	scope task.SandboxScope) *logmanager.SandboxLayout {
+	layout := m.sandboxLayouts.
+		GetSandboxLayout(hostname, agentIP, agentPort).
+		WithExecutorID(executorID)
+	if scope == task.SandboxScope_SANDBOX_SCOPE_EXECUTOR {
+		return layout.ExecutorLayout()
+	}
+	return layout
+}
+
+// cleanSandboxFilePath returns the cleaned path of a sandbox file, and
+// fails if the path is empty or points outside of the sandbox.
+func cleanSandboxFilePath(filePath string) (string, error) {
+	if len(filePath) == 0 {
+		return "", yarpcerrors.InvalidArgumentErrorf("path is not provided")
+	}
+	cleaned := path.Clean(filePath)
+	if path.IsAbs(cleaned) ||
+		cleaned == ".." ||
+		strings.HasPrefix(cleaned, "../") {
+		return "", yarpcerrors.InvalidArgumentErrorf(
+			"path %s is outside of the sandbox", filePath)
+	}
+	return cleaned, nil
+}
+
+// convertBrowseSandboxError converts the error returned by
+// getSandboxPathInfo into a YARPC error.
+func convertBrowseSandboxError(err *task.BrowseSandboxResponse_Error) error {
+	switch {
+	case err.GetOutOfRange() != nil:
+		return yarpcerrors.NotFoundErrorf(
+			"instance not found in job %s with %d instances",
+			err.GetOutOfRange().GetJobId().GetValue(),
+			err.GetOutOfRange().GetInstanceCount())
+	case err.GetNotRunning() != nil:
+		return yarpcerrors.FailedPreconditionErrorf(
+			"%s", err.GetNotRunning().GetMessage())
+	default:
+		return yarpcerrors.InternalErrorf("%s", err.GetFailure().GetMessage())
+	}
+}
+
+// GetHostFailureImpact returns the jobs and instances affected by a set of
+// hosts going down. Instances still placed on those hosts are found from
+// the task runtimes in the cache, and instances which have already been
+// replaced are found from the pod events of their previous run.
+func (m *serviceHandler) GetHostFailureImpact(
+	ctx context.Context,
+	req *task.GetHostFailureImpactRequest,
+) (*task.GetHostFailureImpactResponse, error) {
+	log.WithField("req", req).Debug("TaskSVC.GetHostFailureImpact called")
+	m.metrics.TaskAPIGetHostFailureImpact.Inc(1)
+
+	hosts, err := m.getFailedHosts(ctx, req)
+	if err != nil {
+		m.metrics.TaskGetHostFailureImpactFail.Inc(1)
+		return nil, err
+	}
+
+	lookback := _defaultHostFailureLookback
+	if req.GetLookbackSeconds() > 0 {
+		lookback = time.Duration(req.GetLookbackSeconds()) * time.Second
+	}
+	since := time.Now().Add(-lookback)
+
+	resp := &task.GetHostFailureImpactResponse{}
+	for host := range hosts {
+		resp.Hostnames = append(resp.Hostnames, host)
+	}
+	sort.Strings(resp.Hostnames)
+
+	for _, cachedJob := range m.jobFactory.GetAllJobs() {
+		affectedJob, err := m.getAffectedJob(ctx, cachedJob, hosts, since)
+		if err != nil {
+			m.metrics.TaskGetHostFailureImpactFail.Inc(1)
+			return nil, err
+		}
+		if affectedJob == nil {
+			continue
+		}
+
+		affected := uint32(len(affectedJob.GetInstances()))
+		resp.Jobs = append(resp.Jobs, affectedJob)
+		resp.AffectedInstances += affected
+		resp.RecoveredInstances += affected - affectedJob.GetUnavailableInstances()
+	}
+	sort.Slice(resp.Jobs, func(i, j int) bool {
+		return resp.Jobs[i].GetJobId().GetValue() <
+			resp.Jobs[j].GetJobId().GetValue()
+	})
+
+	m.metrics.TaskGetHostFailureImpact.Inc(1)
+	return resp, nil
+}
+
+// getFailedHosts returns the set of hosts which went down, including the
+// hosts of the rack which went down if one is provided.
+func (m *serviceHandler) getFailedHosts(
+	ctx context.Context,
+	req *task.GetHostFailureImpactRequest,
+) (map[string]bool, error) {
+	hosts := make(map[string]bool)
+	for _, host := range req.GetHostnames() {
+		hosts[host] = true
+	}
+
+	if len(req.GetRack()) != 0 {
+		hostRacks, err := m.getHostRacks(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for host, rack := range hostRacks {
+			if rack == req.GetRack() {
+				hosts[host] = true
+			}
+		}
+	}
+
+	if len(hosts) == 0 {
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"no hosts found for the host failure")
+	}
+	return hosts, nil
+}
+
+// getHostRacks returns the rack of each host known to the Mesos master,
+// as provided by the rack attribute of the Mesos agents.
+func (m *serviceHandler) getHostRacks(
+	ctx context.Context,
+) (map[string]string, error) {
+	agentResponse, err := m.hostMgrClient.GetMesosAgentInfo(
+		ctx,
+		&hostsvc.GetMesosAgentInfoRequest{})
+	if err != nil {
+		return nil, yarpcerrors.InternalErrorf(
+			"failed to get the racks of the hosts: %v", err)
+	}
+
+	hostRacks := make(map[string]string)
+	for _, agent := range agentResponse.GetAgents() {
+		for _, attr := range agent.GetAgentInfo().GetAttributes() {
+			if attr.GetName() == _rackAttribute {
+				hostRacks[agent.GetAgentInfo().GetHostname()] =
+					attr.GetText().GetValue()
+			}
+		}
+	}
+	return hostRacks, nil
+}
+
+// getAffectedJob returns the impact of the host failure on a job, or nil
+// if none of its instances were running on the hosts which went down.
+func (m *serviceHandler) getAffectedJob(
+	ctx context.Context,
+	cachedJob cached.Job,
+	hosts map[string]bool,
+	since time.Time,
+) (*task.AffectedJob, error) {
+	var instances []*task.AffectedInstance
+	for instanceID, cachedTask := range cachedJob.GetAllTasks() {
+		runtime, err := cachedTask.GetRuntime(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		instance, err := m.getAffectedInstance(
+			ctx,
+			cachedJob.ID(),
+			instanceID,
+			runtime,
+			hosts,
+			since)
+		if err != nil {
+			return nil, err
+		}
+		if instance != nil {
+			instances = append(instances, instance)
+		}
+	}
+
+	if len(instances) == 0 {
+		return nil, nil
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].GetInstanceId() < instances[j].GetInstanceId()
+	})
+
+	jobConfig, err := cachedJob.GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	affectedJob := &task.AffectedJob{
+		JobId:                       cachedJob.ID(),
+		InstanceCount:               jobConfig.GetInstanceCount(),
+		Instances:                   instances,
+		MaximumUnavailableInstances: jobConfig.GetSLA().GetMaximumUnavailableInstances(),
+	}
+	for _, instance := range instances {
+		if !instance.GetRecovered() {
+			affectedJob.UnavailableInstances++
+		}
+	}
+	affectedJob.SlaViolated = affectedJob.GetMaximumUnavailableInstances() > 0 &&
+		affectedJob.GetUnavailableInstances() >
+			affectedJob.GetMaximumUnavailableInstances()
+	return affectedJob, nil
+}
+
+// getAffectedInstance returns the instance if its current run is placed on
+// one of the hosts which went down, or if its previous run was and the
+// instance has been replaced since. It returns nil otherwise.
+func (m *serviceHandler) getAffectedInstance(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	instanceID uint32,
+	runtime *task.RuntimeInfo,
+	hosts map[string]bool,
+	since time.Time,
+) (*task.AffectedInstance, error) {
+	if hosts[runtime.GetHost()] {
+		return &task.AffectedInstance{
+			InstanceId:      instanceID,
+			Hostname:        runtime.GetHost(),
+			TaskId:          runtime.GetMesosTaskId(),
+			State:           runtime.GetState(),
+			CurrentHostname: runtime.GetHost(),
+		}, nil
+	}
+
+	prevTaskID := runtime.GetPrevMesosTaskId()
+	if len(prevTaskID.GetValue()) == 0 {
+		return nil, nil
+	}
+
+	// only instances replaced within the lookback window are checked to
+	// avoid reading the pod events of every instance
+	updatedAt := time.Unix(0, int64(runtime.GetRevision().GetUpdatedAt()))
+	if updatedAt.Before(since) {
+		return nil, nil
+	}
+
+	podEvents, err := m.taskStore.GetPodEvents(
+		ctx,
+		jobID.GetValue(),
+		instanceID,
+		prevTaskID.GetValue())
+	if err != nil {
+		return nil, yarpcerrors.InternalErrorf(
+			"failed to get pod events for job_id: %s, instance_id: %d: %v",
+			jobID.GetValue(), instanceID, err)
+	}
+
+	for _, event := range podEvents {
+		if !hosts[event.GetHostname()] {
+			continue
+		}
+		return &task.AffectedInstance{
+			InstanceId:      instanceID,
+			Hostname:        event.GetHostname(),
+			TaskId:          prevTaskID,
+			State:           runtime.GetState(),
+			CurrentHostname: runtime.GetHost(),
+			Recovered: runtime.GetState() == task.TaskState_RUNNING ||
+				runtime.GetState() == task.TaskState_SUCCEEDED,
+		}, nil
+	}
+	return nil, nil
+}
+
+// GetDrainHeadroom returns the SLA headroom of the jobs with running
+// instances on the hosts to be drained. The headroom of a job is the
+// maximum number of unavailable instances allowed by its SLA, minus the
+// instances already unavailable and the instances to be drained. Only
+// the jobs with tasks on the hosts as per the tasks by host index of the
+// resource manager are looked at, instead of all the jobs in cache.
+func (m *serviceHandler) GetDrainHeadroom(
+	ctx context.Context,
+	req *task.GetDrainHeadroomRequest,
+) (*task.GetDrainHeadroomResponse, error) {
+	log.WithField("req", req).Debug("TaskSVC.GetDrainHeadroom called")
+	m.metrics.TaskAPIGetDrainHeadroom.Inc(1)
+
+	if len(req.GetHostnames()) == 0 {
+		m.metrics.TaskGetDrainHeadroomFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"no hosts provided to drain")
+	}
+	hosts := make(map[string]bool)
+	for _, host := range req.GetHostnames() {
+		hosts[host] = true
+	}
+
+	jobIDs, err := m.getJobsOnHosts(ctx, req.GetHostnames())
+	if err != nil {
+		m.metrics.TaskGetDrainHeadroomFail.Inc(1)
+		return nil, err
+	}
+
+	resp := &task.GetDrainHeadroomResponse{Safe: true}
+	for _, jobID := range jobIDs {
+		cachedJob := m.jobFactory.GetJob(jobID)
+		if cachedJob == nil {
+			// the job has completed since the tasks were placed
+			continue
+		}
+		headroom, err := m.getDrainHeadroom(ctx, cachedJob, hosts)
+		if err != nil {
+			m.metrics.TaskGetDrainHeadroomFail.Inc(1)
+			return nil, err
+		}
+		if headroom == nil {
+			continue
+		}
+		resp.Jobs = append(resp.Jobs, headroom)
+		if headroom.GetSlaViolated() {
+			resp.Safe = false
+		}
+	}
+
+	m.metrics.TaskGetDrainHeadroom.Inc(1)
+	return resp, nil
+}
+
+// getJobsOnHosts returns the jobs with tasks running on the hosts, sorted
+// by job ID, as per the tasks by host index of the resource manager.
+func (m *serviceHandler) getJobsOnHosts(
+	ctx context.Context,
+	hostnames []string,
+) ([]*peloton.JobID, error) {
+	resp, err := m.resmgrClient.GetTasksByHosts(
+		ctx,
+		&resmgrsvc.GetTasksByHostsRequest{Hostnames: hostnames})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetError() != nil {
+		return nil, yarpcerrors.InternalErrorf(
+			"failed to get tasks on hosts: %s",
+			resp.GetError().GetMessage())
+	}
+
+	jobs := make(map[string]*peloton.JobID)
+	for _, taskList := range resp.GetHostTasksMap() {
+		for _, t := range taskList.GetTasks() {
+			jobs[t.GetJobId().GetValue()] = t.GetJobId()
+		}
+	}
+	jobIDs := make([]*peloton.JobID, 0, len(jobs))
+	for _, jobID := range jobs {
+		jobIDs = append(jobIDs, jobID)
+	}
+	sort.Slice(jobIDs, func(i, j int) bool {
+		return jobIDs[i].GetValue() < jobIDs[j].GetValue()
+	})
+	return jobIDs, nil
+}
+
+// getDrainHeadroom returns the SLA headroom of a job once the hosts are
+// drained, or nil if none of its instances are running on the hosts.
+func (m *serviceHandler) getDrainHeadroom(
+	ctx context.Context,
+	cachedJob cached.Job,
+	hosts map[string]bool,
+) (*task.DrainHeadroom, error) {
+	var drained, unavailable uint32
+	for _, cachedTask := range cachedJob.GetAllTasks() {
+		runtime, err := cachedTask.GetRuntime(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if runtime.GetGoalState() != task.TaskState_RUNNING {
+			continue
+		}
+		if runtime.GetState() != task.TaskState_RUNNING {
+			unavailable++
+		} else if hosts[runtime.GetHost()] {
+			drained++
+		}
+	}
+
+	if drained == 0 {
+		return nil, nil
+	}
+
+	jobConfig, err := cachedJob.GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	headroom := &task.DrainHeadroom{
+		JobId:                       cachedJob.ID(),
+		InstanceCount:               jobConfig.GetInstanceCount(),
+		DrainedInstances:            drained,
+		UnavailableInstances:        unavailable,
+		MaximumUnavailableInstances: jobConfig.GetSLA().GetMaximumUnavailableInstances(),
+	}
+	headroom.Headroom = int32(headroom.GetMaximumUnavailableInstances()) -
+		int32(unavailable) - int32(drained)
+	// jobs without a maximum number of unavailable instances can have
+	// all of their instances drained
+	headroom.SlaViolated = headroom.GetMaximumUnavailableInstances() > 0 &&
+		headroom.GetHeadroom() < 0
+	return headroom, nil
+}
+
+// GetColocation returns the hosts and racks shared by multiple instances
+// of a job, or by instances of the job and instances of another job.
+func (m *serviceHandler) GetColocation(
+	ctx context.Context,
+	req *task.GetColocationRequest,
+) (*task.GetColocationResponse, error) {
+	log.WithField("req", req).Debug("TaskSVC.GetColocation called")
+	m.metrics.TaskAPIGetColocation.Inc(1)
+
+	if len(req.GetJobId().GetValue()) == 0 {
+		m.metrics.TaskGetColocationFail.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf("job id is not provided")
+	}
+
+	jobHosts, err := m.getInstanceHosts(ctx, req.GetJobId())
+	if err != nil {
+		m.metrics.TaskGetColocationFail.Inc(1)
+		return nil, err
+	}
+
+	var otherJobHosts map[uint32]string
+	if len(req.GetOtherJobId().GetValue()) != 0 {
+		otherJobHosts, err = m.getInstanceHosts(ctx, req.GetOtherJobId())
+		if err != nil {
+			m.metrics.TaskGetColocationFail.Inc(1)
+			return nil, err
+		}
+	}
+
+	hostRacks, err := m.getHostRacks(ctx)
+	if err != nil {
+		m.metrics.TaskGetColocationFail.Inc(1)
+		return nil, err
+	}
+
+	m.metrics.TaskGetColocation.Inc(1)
+	return &task.GetColocationResponse{
+		Hosts: getColocationGroups(
+			jobHosts,
+			otherJobHosts,
+			func(host string) string { return host }),
+		Racks: getColocationGroups(
+			jobHosts,
+			otherJobHosts,
+			func(host string) string { return hostRacks[host] }),
+	}, nil
+}
+
+// getInstanceHosts returns the host each placed instance of a job is on.
+func (m *serviceHandler) getInstanceHosts(
+	ctx context.Context,
+	jobID *peloton.JobID,
+) (map[uint32]string, error) {
+	taskInfos, err := m.taskStore.GetTasksForJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if len(taskInfos) == 0 {
+		return nil, yarpcerrors.NotFoundErrorf(
+			"job %s not found", jobID.GetValue())
+	}
+
+	instanceHosts := make(map[uint32]string)
+	for instanceID, taskInfo := range taskInfos {
+		runtime := taskInfo.GetRuntime()
+		if len(runtime.GetHost()) == 0 ||
+			util.IsPelotonStateTerminal(runtime.GetState()) {
+			continue
+		}
+		instanceHosts[instanceID] = runtime.GetHost()
+	}
+	return instanceHosts, nil
+}
+
+// getColocationGroups groups the instances of the job and of the other
+// job by the failure domain returned by domainOf for their host. Only the
+// failure domains shared by multiple instances of the job, or by instances
+// of both jobs, are returned.
+func getColocationGroups(
+	jobHosts map[uint32]string,
+	otherJobHosts map[uint32]string,
+	domainOf func(host string) string,
+) []*task.ColocationGroup {
+	groups := make(map[string]*task.ColocationGroup)
+	getGroup := func(host string) *task.ColocationGroup {
+		domain := domainOf(host)
+		if len(domain) == 0 {
+			return nil
+		}
+		group, ok := groups[domain]
+		if !ok {
+			group = &task.ColocationGroup{Name: domain}
+			groups[domain] = group
+		}
+		return group
+	}
+
+	for instanceID, host := range jobHosts {
+		if group := getGroup(host); group != nil {
+			group.InstanceIds = append(group.InstanceIds, instanceID)
+		}
+	}
+	for instanceID, host := range otherJobHosts {
+		if group := getGroup(host); group != nil {
+			group.OtherJobInstanceIds = append(
+				group.OtherJobInstanceIds, instanceID)
+		}
+	}
+
+	var result []*task.ColocationGroup
+	for _, group := range groups {
+		if len(group.GetInstanceIds()) < 2 &&
+			(len(group.GetInstanceIds()) == 0 ||
+				len(group.GetOtherJobInstanceIds()) == 0) {
+			continue
+		}
+		sortInstanceIDs(group.InstanceIds)
+		sortInstanceIDs(group.OtherJobInstanceIds)
+		result = append(result, group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].GetName() < result[j].GetName()
+	})
+	return result
+}
+
+func sortInstanceIDs(instanceIDs []uint32) {
+	sort.Slice(instanceIDs, func(i, j int) bool {
+		return instanceIDs[i] < instanceIDs[j]
+	})
+}
+
+// fillReasonForPendingTasksFromResMgr takes a list of taskinfo and
+// fills in the reason for pending tasks from the task events
+// pushed by ResourceManager.
+// All the tasks in `taskInfos` should belong to the same job
+func (m *serviceHandler) fillReasonForPendingTasksFromResMgr(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	taskInfos []*task.TaskInfo,
+) {
+	// only need to consult ResourceManager for PENDING tasks,
+	// because only tasks with PENDING states are being processed by ResourceManager
+	for _, taskInfo := range taskInfos {
+		if taskInfo.GetRuntime().GetState() == task.TaskState_PENDING {
+			// attach the reason from the taskEntry in activeRMTasks
+			taskEntry := m.activeRMTasks.GetTask(
+				util.CreatePelotonTaskID(
+					jobID.GetValue(),
+					taskInfo.GetInstanceId(),
+				),
+			)
+			if taskEntry != nil {
+				taskInfo.GetRuntime().Reason = taskEntry.GetReason()
+			}
+		}
+	}
+}
+
+// GetFrameworkID returns the frameworkID.
+func (m *serviceHandler) getFrameworkID(ctx context.Context) (string, error) {
+	frameworkIDVal, err := m.sandboxInfo.GetFrameworkID(ctx)
+	if err != nil {
+		return frameworkIDVal, err
+	}
+	if frameworkIDVal == "" {
+		return frameworkIDVal, errEmptyFrameworkID
+	}
+	return frameworkIDVal, nil
+}
+
+// getPodEvents returns all the pod events for given
+// job_id + instance_id + optional (run_id)
+func (m *serviceHandler) getPodEvents(
+	ctx context.Context,
+	id *peloton.JobID,
+	instanceID uint32,
+	runID string) ([]*task.PodEvent, error) {
+	events, _, err := m.getPodEventsOfRuns(ctx, id, instanceID, runID, 0, 0)
+	return events, err
+}
+
+// getPodEventsOfRuns walks the runs of an instance from runID, or the
+// last run if runID is empty, to the first run. It skips the first
+// runOffset runs and returns the pod events of the next runLimit runs,
+// or all the remaining runs if runLimit is 0, along with whether there
+// are older runs left.
+func (m *serviceHandler) getPodEventsOfRuns(
+	ctx context.Context,
+	id *peloton.JobID,
+	instanceID uint32,
+	runID string,
+	runOffset uint32,
+	runLimit uint32) ([]*task.PodEvent, bool, error) {
+	var events []*task.PodEvent
+	for run := uint32(0); ; run++ {
+		if runLimit > 0 && run >= runOffset+runLimit {
+			return events, true, nil
+		}
+
+		podEvents, err := m.taskStore.GetPodEvents(ctx, id.GetValue(), instanceID, runID)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(podEvents) == 0 {
+			break
+		}
+
+		if run >= runOffset {
+			taskEvents, err := convertPodEventsFormat(podEvents)
+			if err != nil {
+				return nil, false, err
+			}
+			events = append(events, taskEvents...)
+		}
+
+		prevRunID, err := util.ParseRunID(podEvents[0].GetPrevPodId().GetValue())
+		if err != nil {
+			return nil, false, err
+		}
+		// Reached last run for this task
+		if prevRunID == 0 {
+			break
+		}
+		runID = podEvents[0].GetPrevPodId().GetValue()
+	}
+
+	return events, false, nil
+}
+
+// getTerminalEvents filters input pod events and return on terminal ones
+func (m *serviceHandler) getTerminalEvents(
+	eventList []*task.PodEvent,
+	lastTaskInfo *task.TaskInfo) []*task.TaskInfo {
+	var taskInfos []*task.TaskInfo
+
+	for _, event := range eventList {
+		taskState := task.TaskState(task.TaskState_value[event.GetActualState()])
+		if !util.IsPelotonStateTerminal(taskState) {
+			continue
+		}
+
+		mesosID := event.GetTaskId().GetValue()
+		prevMesosID := event.GetPrevTaskId().GetValue()
+		agentID := event.GetAgentID()
+		taskInfos = append(taskInfos, &task.TaskInfo{
+			InstanceId: lastTaskInfo.GetInstanceId(),
+			JobId:      lastTaskInfo.GetJobId(),
 			Config:     lastTaskInfo.GetConfig(),
 			Runtime: &task.RuntimeInfo{
 				State: taskState,
@@ -1355,7 +4141,20 @@ func convertPodEventsFormat(podEvents []*pod.PodEvent) ([]*task.PodEvent, error)
 			DesriedTaskId: &mesosv1.TaskID{
 				Value: &desiredPodID,
 			},
+			OperationContext: getPodEventOperationContext(e),
 		})
 	}
 	return result, nil
 }
+
+// getPodEventOperationContext returns the operation context recorded
+// in a pod event, or nil if there is none.
+func getPodEventOperationContext(e *pod.PodEvent) *task.OperationContext {
+	if len(e.GetIncidentId()) == 0 && len(e.GetChangeTicket()) == 0 {
+		return nil
+	}
+	return &task.OperationContext{
+		IncidentId:   e.GetIncidentId(),
+		ChangeTicket: e.GetChangeTicket(),
+	}
+}
@@ -16,7 +16,13 @@ package tasksvc
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"io"
 	"math"
 	"math/rand"
 	"testing"
@@ -26,19 +32,31 @@ import (
 	mesos_master "github.com/uber/peloton/.gen/mesos/v1/master"
 	"github.com/uber/peloton/.gen/peloton/api/v0/job"
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/query"
 	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	taskmocks "github.com/uber/peloton/.gen/peloton/api/v0/task/mocks"
+	"github.com/uber/peloton/.gen/peloton/api/v0/update"
 	"github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc"
 	hostmocks "github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc/mocks"
 	"github.com/uber/peloton/.gen/peloton/private/models"
+	"github.com/uber/peloton/.gen/peloton/private/resmgr"
 	"github.com/uber/peloton/.gen/peloton/private/resmgrsvc"
 
 	resmocks "github.com/uber/peloton/.gen/peloton/private/resmgrsvc/mocks"
+	commongoalstate "github.com/uber/peloton/pkg/common/goalstate"
 	leadermocks "github.com/uber/peloton/pkg/common/leader/mocks"
+	"github.com/uber/peloton/pkg/jobmgr/asyncop"
+	"github.com/uber/peloton/pkg/jobmgr/cached"
 	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
 	goalstatemocks "github.com/uber/peloton/pkg/jobmgr/goalstate/mocks"
+	"github.com/uber/peloton/pkg/jobmgr/job/provenance"
+	"github.com/uber/peloton/pkg/jobmgr/logmanager"
 	logmanagermocks "github.com/uber/peloton/pkg/jobmgr/logmanager/mocks"
 	activermtaskmocks "github.com/uber/peloton/pkg/jobmgr/task/activermtask/mocks"
+	handlerutil "github.com/uber/peloton/pkg/jobmgr/util/handler"
 	storemocks "github.com/uber/peloton/pkg/storage/mocks"
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
+	objectmocks "github.com/uber/peloton/pkg/storage/objects/mocks"
 
 	v1alphapeloton "github.com/uber/peloton/.gen/peloton/api/v1alpha/peloton"
 	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
@@ -48,11 +66,14 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"github.com/golang/protobuf/proto"
+	"github.com/pborman/uuid"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/suite"
 	"github.com/uber-go/tally"
+	"go.uber.org/atomic"
 	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/yarpc/yarpctest"
 )
 
 const (
@@ -86,12 +107,15 @@ type TaskHandlerTestSuite struct {
 	mockedHostMgr            *hostmocks.MockInternalHostServiceYARPCClient
 	mockedTask               *cachedmocks.MockTask
 	mockedActiveRMTasks      *activermtaskmocks.MockActiveRMTasks
+	mockedTaskOperationOps   *objectmocks.MockTaskOperationOps
+	mockedIdempotencyKeyOps  *objectmocks.MockTaskIdempotencyKeyOps
 }
 
 func (suite *TaskHandlerTestSuite) SetupTest() {
 	mtx := NewMetrics(tally.NoopScope)
 	suite.handler = &serviceHandler{
-		metrics: mtx,
+		metrics:    mtx,
+		operations: asyncop.NewTracker(0),
 	}
 	suite.testJobID = &peloton.JobID{
 		Value: testJob,
@@ -129,6 +153,12 @@ func (suite *TaskHandlerTestSuite) SetupTest() {
 	suite.mockedHostMgr = hostmocks.NewMockInternalHostServiceYARPCClient(suite.ctrl)
 	suite.mockedTask = cachedmocks.NewMockTask(suite.ctrl)
 	suite.mockedActiveRMTasks = activermtaskmocks.NewMockActiveRMTasks(suite.ctrl)
+	suite.mockedTaskOperationOps = objectmocks.NewMockTaskOperationOps(suite.ctrl)
+	suite.mockedTaskOperationOps.EXPECT().
+		Create(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil).
+		AnyTimes()
+	suite.mockedIdempotencyKeyOps = objectmocks.NewMockTaskIdempotencyKeyOps(suite.ctrl)
 
 	suite.handler.jobStore = suite.mockedJobStore
 	suite.handler.taskStore = suite.mockedTaskStore
@@ -140,13 +170,19 @@ func (suite *TaskHandlerTestSuite) SetupTest() {
 	suite.handler.frameworkInfoStore = suite.mockedFrameworkInfoStore
 	suite.handler.logManager = suite.mockedLogManager
 	suite.handler.hostMgrClient = suite.mockedHostMgr
+	suite.handler.sandboxInfo = handlerutil.NewSandboxInfoCache(
+		_frameworkName, suite.mockedFrameworkInfoStore, suite.mockedHostMgr)
+	suite.handler.sandboxLayouts = logmanager.NewSandboxLayoutResolver(
+		nil, "", nil)
 	suite.handler.activeRMTasks = suite.mockedActiveRMTasks
+	suite.handler.taskOperationOps = suite.mockedTaskOperationOps
+	suite.handler.idempotencyKeyOps = suite.mockedIdempotencyKeyOps
 }
 
 func (suite *TaskHandlerTestSuite) TearDownTest() {
 	log.Debug("tearing down")
 	suite.ctrl.Finish()
-	suite.handler.mesosAgentWorkDir = ""
+	suite.handler.sandboxLayouts = nil
 }
 
 func TestPelotonTaskHandler(t *testing.T) {
@@ -237,6 +273,89 @@ func (suite *TaskHandlerTestSuite) TestGetTasks_Batch_Job() {
 	}
 }
 
+// TestGetTasksRunPagination tests paging through the terminal runs of an
+// instance with four failed runs
+func (suite *TaskHandlerTestSuite) TestGetTasksRunPagination() {
+	instanceID := uint32(0)
+	lastTaskInfo := suite.createTestTaskInfo(task.TaskState_FAILED, instanceID)
+	taskInfoMap := map[uint32]*task.TaskInfo{instanceID: lastTaskInfo}
+
+	podID := func(run int) string {
+		if run == 4 {
+			// the last run is looked up without a run ID
+			return ""
+		}
+		return fmt.Sprintf("%s-%d-%d", testJob, instanceID, run)
+	}
+	eventsOfRun := func(run int) []*pod.PodEvent {
+		return []*pod.PodEvent{{
+			PodId: &v1alphapeloton.PodID{
+				Value: fmt.Sprintf("%s-%d-%d", testJob, instanceID, run),
+			},
+			PrevPodId: &v1alphapeloton.PodID{
+				Value: fmt.Sprintf("%s-%d-%d", testJob, instanceID, run-1),
+			},
+			Version:        &v1alphapeloton.EntityVersion{Value: "1"},
+			DesiredVersion: &v1alphapeloton.EntityVersion{Value: "1"},
+			ActualState:    task.TaskState_FAILED.String(),
+			DesiredState:   task.TaskState_SUCCEEDED.String(),
+		}}
+	}
+
+	tt := []struct {
+		runOffset   uint32
+		runLimit    uint32
+		lookedUp    []int
+		results     []int
+		hasMoreRuns bool
+	}{
+		{
+			runOffset:   1,
+			runLimit:    2,
+			lookedUp:    []int{4, 3, 2},
+			results:     []int{3, 2},
+			hasMoreRuns: true,
+		},
+		{
+			runOffset: 3,
+			runLimit:  2,
+			lookedUp:  []int{4, 3, 2, 1},
+			results:   []int{1},
+		},
+	}
+
+	for _, t := range tt {
+		suite.mockedJobFactory.EXPECT().GetJob(suite.testJobID).
+			Return(suite.mockedCachedJob)
+		suite.mockedCachedJob.EXPECT().
+			GetConfig(gomock.Any()).
+			Return(cachedtest.NewMockJobConfig(suite.ctrl, suite.testJobConfig), nil)
+		suite.mockedTaskStore.EXPECT().
+			GetTaskForJob(gomock.Any(), suite.testJobID.GetValue(), instanceID).
+			Return(taskInfoMap, nil)
+		for _, run := range t.lookedUp {
+			suite.mockedTaskStore.EXPECT().
+				GetPodEvents(gomock.Any(), testJob, instanceID, podID(run)).
+				Return(eventsOfRun(run), nil)
+		}
+
+		resp, err := suite.handler.Get(context.Background(), &task.GetRequest{
+			JobId:      suite.testJobID,
+			InstanceId: instanceID,
+			RunOffset:  t.runOffset,
+			RunLimit:   t.runLimit,
+		})
+		suite.NoError(err)
+		suite.Equal(t.hasMoreRuns, resp.GetHasMoreRuns())
+		suite.Len(resp.GetResults(), len(t.results))
+		for i, run := range t.results {
+			suite.Equal(
+				fmt.Sprintf("%s-%d-%d", testJob, instanceID, run),
+				resp.GetResults()[i].GetRuntime().GetMesosTaskId().GetValue())
+		}
+	}
+}
+
 func (suite *TaskHandlerTestSuite) TestGetTasks_Service_Job() {
 	instanceID := uint32(0)
 	lastTaskInfo := suite.createTestTaskInfo(task.TaskState_FAILED, instanceID)
@@ -399,8 +518,9 @@ func (suite *TaskHandlerTestSuite) TestStopTasksWithRanges() {
 		jobmgrcommon.TerminationStatusField: termStatus,
 	}
 
+	isLeader := suite.mockedCandidate.EXPECT().IsLeader().Return(true)
 	gomock.InOrder(
-		suite.mockedCandidate.EXPECT().IsLeader().Return(true),
+		isLeader,
 		suite.mockedJobFactory.EXPECT().
 			AddJob(suite.testJobID).Return(suite.mockedCachedJob),
 		suite.mockedCachedJob.EXPECT().
@@ -411,13 +531,10 @@ func (suite *TaskHandlerTestSuite) TestStopTasksWithRanges() {
 		suite.mockedCachedJob.EXPECT().
 			PatchTasks(gomock.Any(), runtimeDiffs).Return(nil),
 		suite.mockedGoalStateDrive.EXPECT().
-			EnqueueTask(suite.testJobID, uint32(1), gomock.Any()).Return(),
+			EnqueueTaskWithPriority(suite.testJobID, uint32(1), gomock.Any(), commongoalstate.PriorityHigh).Return(),
 		suite.mockedCachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH),
 		suite.mockedGoalStateDrive.EXPECT().
-			JobRuntimeDuration(job.JobType_BATCH).
-			Return(1*time.Second),
-		suite.mockedGoalStateDrive.EXPECT().
-			EnqueueJob(suite.testJobID, gomock.Any()).Return(),
+			EnqueueJobWithDefaultDelay(suite.testJobID, job.JobType_BATCH),
 	)
 
 	var request = &task.StopRequest{
@@ -433,6 +550,336 @@ func (suite *TaskHandlerTestSuite) TestStopTasksWithRanges() {
 	suite.Equal(resp.GetStoppedInstanceIds(), []uint32{1})
 }
 
+// TestStopTasksClearsOperationContext tests that stopping a task without
+// an operation context clears the context of the previous operation
+func (suite *TaskHandlerTestSuite) TestStopTasksClearsOperationContext() {
+	taskInfo := suite.taskInfos[1]
+	taskInfo.Runtime.OperationContext = &task.OperationContext{
+		IncidentId: "INC-1234",
+	}
+	taskRanges := []*task.InstanceRange{{From: 1, To: 2}}
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(cachedtest.NewMockJobConfig(suite.ctrl, suite.testJobConfig), nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTasksForJobByRange(gomock.Any(), suite.testJobID, taskRanges[0]).
+		Return(map[uint32]*task.TaskInfo{1: taskInfo}, nil)
+	suite.mockedCachedJob.EXPECT().
+		PatchTasks(gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context, runtimeDiffs map[uint32]jobmgrcommon.RuntimeDiff) {
+			value, ok := runtimeDiffs[1][jobmgrcommon.OperationContextField]
+			suite.True(ok)
+			suite.Nil(value.(*task.OperationContext))
+		}).
+		Return(nil)
+	suite.mockedGoalStateDrive.EXPECT().
+		EnqueueTaskWithPriority(suite.testJobID, uint32(1), gomock.Any(), commongoalstate.PriorityHigh)
+	suite.mockedCachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
+	suite.mockedGoalStateDrive.EXPECT().
+		EnqueueJobWithDefaultDelay(suite.testJobID, job.JobType_BATCH)
+
+	resp, err := suite.handler.Stop(
+		context.Background(),
+		&task.StopRequest{
+			JobId:  suite.testJobID,
+			Ranges: taskRanges,
+		},
+	)
+	suite.NoError(err)
+	suite.Equal([]uint32{1}, resp.GetStoppedInstanceIds())
+}
+
+// expectStopJob sets up the expectations of stopping the whole test job
+func (suite *TaskHandlerTestSuite) expectStopJob() {
+	expectedJobRuntime := proto.Clone(suite.testJobRuntime).(*job.RuntimeInfo)
+	expectedJobRuntime.GoalState = job.JobState_KILLED
+	expectedJobRuntime.DesiredStateVersion++
+
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).
+		Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(suite.testJobRuntime, nil)
+	suite.mockedCachedJob.EXPECT().
+		CompareAndSetRuntime(gomock.Any(), expectedJobRuntime).
+		Return(expectedJobRuntime, nil)
+	suite.mockedGoalStateDrive.EXPECT().
+		EnqueueJob(suite.testJobID, gomock.Any()).Return()
+}
+
+// TestStopAllTasksWithKillGracePeriod tests that the kill grace period of
+// the request is persisted in the runtime of each task before the whole job
+// is stopped
+func (suite *TaskHandlerTestSuite) TestStopAllTasksWithKillGracePeriod() {
+	killGracePeriod := &task.KillGracePeriod{Seconds: 0}
+	runtimeDiffs := make(map[uint32]jobmgrcommon.RuntimeDiff)
+	for instanceID := range suite.taskInfos {
+		runtimeDiffs[instanceID] = jobmgrcommon.RuntimeDiff{
+			jobmgrcommon.GoalStateField: task.TaskState_KILLED,
+			jobmgrcommon.MessageField:   "Task stop API request",
+			jobmgrcommon.ReasonField:    "",
+			jobmgrcommon.TerminationStatusField: &task.TerminationStatus{
+				Reason: task.TerminationStatus_TERMINATION_STATUS_REASON_KILLED_ON_REQUEST,
+			},
+			jobmgrcommon.KillGracePeriodField: killGracePeriod,
+		}
+	}
+
+	gomock.InOrder(
+		suite.mockedCandidate.EXPECT().IsLeader().Return(true),
+		suite.mockedJobFactory.EXPECT().
+			AddJob(suite.testJobID).Return(suite.mockedCachedJob),
+		suite.mockedCachedJob.EXPECT().
+			GetConfig(gomock.Any()).
+			Return(cachedtest.NewMockJobConfig(suite.ctrl, suite.testJobConfig), nil),
+		suite.mockedTaskStore.EXPECT().
+			GetTasksForJob(gomock.Any(), suite.testJobID).Return(suite.taskInfos, nil),
+		suite.mockedCachedJob.EXPECT().
+			PatchTasks(gomock.Any(), runtimeDiffs).Return(nil),
+	)
+	suite.mockedGoalStateDrive.EXPECT().
+		EnqueueTaskWithPriority(suite.testJobID, gomock.Any(), gomock.Any(), commongoalstate.PriorityHigh).
+		Return().
+		Times(testInstanceCount)
+	suite.expectStopJob()
+
+	resp, err := suite.handler.Stop(
+		context.Background(),
+		&task.StopRequest{
+			JobId:           suite.testJobID,
+			KillGracePeriod: killGracePeriod,
+		},
+	)
+	suite.NoError(err)
+	suite.Empty(resp.GetInvalidInstanceIds())
+	suite.Len(resp.GetStoppedInstanceIds(), testInstanceCount)
+}
+
+// TestStopAllTasksWithReason tests that the reason of the request is
+// persisted in the runtime of each task before the whole job is stopped,
+// while the principal supplied by the client is ignored
+func (suite *TaskHandlerTestSuite) TestStopAllTasksWithReason() {
+	runtimeDiffs := make(map[uint32]jobmgrcommon.RuntimeDiff)
+	for instanceID := range suite.taskInfos {
+		runtimeDiffs[instanceID] = jobmgrcommon.RuntimeDiff{
+			jobmgrcommon.GoalStateField: task.TaskState_KILLED,
+			jobmgrcommon.MessageField:   "Task stop API request: host is unhealthy",
+			jobmgrcommon.ReasonField:    "",
+			jobmgrcommon.TerminationStatusField: &task.TerminationStatus{
+				Reason:  task.TerminationStatus_TERMINATION_STATUS_REASON_KILLED_ON_REQUEST,
+				Message: "host is unhealthy",
+			},
+		}
+	}
+
+	gomock.InOrder(
+		suite.mockedCandidate.EXPECT().IsLeader().Return(true),
+		suite.mockedJobFactory.EXPECT().
+			AddJob(suite.testJobID).Return(suite.mockedCachedJob),
+		suite.mockedCachedJob.EXPECT().
+			GetConfig(gomock.Any()).
+			Return(cachedtest.NewMockJobConfig(suite.ctrl, suite.testJobConfig), nil),
+		suite.mockedTaskStore.EXPECT().
+			GetTasksForJob(gomock.Any(), suite.testJobID).Return(suite.taskInfos, nil),
+		suite.mockedCachedJob.EXPECT().
+			PatchTasks(gomock.Any(), runtimeDiffs).Return(nil),
+	)
+	suite.mockedGoalStateDrive.EXPECT().
+		EnqueueTaskWithPriority(suite.testJobID, gomock.Any(), gomock.Any(), commongoalstate.PriorityHigh).
+		Return().
+		Times(testInstanceCount)
+	suite.expectStopJob()
+
+	resp, err := suite.handler.Stop(
+		context.Background(),
+		&task.StopRequest{
+			JobId:     suite.testJobID,
+			Reason:    "host is unhealthy",
+			Principal: "auto-remediation",
+		},
+	)
+	suite.NoError(err)
+	suite.Empty(resp.GetInvalidInstanceIds())
+	suite.Len(resp.GetStoppedInstanceIds(), testInstanceCount)
+}
+
+// TestStopAllTasksWithOperationContext tests that the operation context of
+// the request is persisted in the runtime of each task before the whole job
+// is stopped
+func (suite *TaskHandlerTestSuite) TestStopAllTasksWithOperationContext() {
+	operationContext := &task.OperationContext{
+		IncidentId:   "INC-1234",
+		ChangeTicket: "CHG-5678",
+	}
+	runtimeDiffs := make(map[uint32]jobmgrcommon.RuntimeDiff)
+	for instanceID := range suite.taskInfos {
+		runtimeDiffs[instanceID] = jobmgrcommon.RuntimeDiff{
+			jobmgrcommon.GoalStateField: task.TaskState_KILLED,
+			jobmgrcommon.MessageField:   "Task stop API request",
+			jobmgrcommon.ReasonField:    "",
+			jobmgrcommon.TerminationStatusField: &task.TerminationStatus{
+				Reason: task.TerminationStatus_TERMINATION_STATUS_REASON_KILLED_ON_REQUEST,
+			},
+			jobmgrcommon.OperationContextField: operationContext,
+		}
+	}
+
+	gomock.InOrder(
+		suite.mockedCandidate.EXPECT().IsLeader().Return(true),
+		suite.mockedJobFactory.EXPECT().
+			AddJob(suite.testJobID).Return(suite.mockedCachedJob),
+		suite.mockedCachedJob.EXPECT().
+			GetConfig(gomock.Any()).
+			Return(cachedtest.NewMockJobConfig(suite.ctrl, suite.testJobConfig), nil),
+		suite.mockedTaskStore.EXPECT().
+			GetTasksForJob(gomock.Any(), suite.testJobID).Return(suite.taskInfos, nil),
+		suite.mockedCachedJob.EXPECT().
+			PatchTasks(gomock.Any(), runtimeDiffs).Return(nil),
+	)
+	suite.mockedGoalStateDrive.EXPECT().
+		EnqueueTaskWithPriority(suite.testJobID, gomock.Any(), gomock.Any(), commongoalstate.PriorityHigh).
+		Return().
+		Times(testInstanceCount)
+	suite.expectStopJob()
+
+	resp, err := suite.handler.Stop(
+		context.Background(),
+		&task.StopRequest{
+			JobId:            suite.testJobID,
+			OperationContext: operationContext,
+		},
+	)
+	suite.NoError(err)
+	suite.Empty(resp.GetInvalidInstanceIds())
+	suite.Len(resp.GetStoppedInstanceIds(), testInstanceCount)
+}
+
+// TestStopAllTasksWithLabelSelector tests that stopping all the tasks with
+// a label selector skips the tasks excluded by their labels
+func (suite *TaskHandlerTestSuite) TestStopAllTasksWithLabelSelector() {
+	canaryLabel := &peloton.Label{Key: "canary", Value: "true"}
+	// the tasks read from DB have no config
+	for _, taskInfo := range suite.taskInfos {
+		taskInfo.Config = nil
+	}
+	suite.mockedCachedJob.EXPECT().
+		GetTaskLabels(gomock.Any()).
+		DoAndReturn(func(instanceID uint32) []*peloton.Label {
+			if instanceID == 0 {
+				return []*peloton.Label{canaryLabel}
+			}
+			return nil
+		}).
+		AnyTimes()
+
+	runtimeDiffs := make(map[uint32]jobmgrcommon.RuntimeDiff)
+	for instanceID := range suite.taskInfos {
+		if instanceID == 0 {
+			continue
+		}
+		runtimeDiffs[instanceID] = jobmgrcommon.RuntimeDiff{
+			jobmgrcommon.GoalStateField: task.TaskState_KILLED,
+			jobmgrcommon.MessageField:   "Task stop API request",
+			jobmgrcommon.ReasonField:    "",
+			jobmgrcommon.TerminationStatusField: &task.TerminationStatus{
+				Reason: task.TerminationStatus_TERMINATION_STATUS_REASON_KILLED_ON_REQUEST,
+			},
+		}
+	}
+
+	gomock.InOrder(
+		suite.mockedCandidate.EXPECT().IsLeader().Return(true),
+		suite.mockedJobFactory.EXPECT().
+			AddJob(suite.testJobID).Return(suite.mockedCachedJob),
+		suite.mockedCachedJob.EXPECT().
+			GetConfig(gomock.Any()).
+			Return(cachedtest.NewMockJobConfig(suite.ctrl, suite.testJobConfig), nil),
+		suite.mockedTaskStore.EXPECT().
+			GetTasksForJob(gomock.Any(), suite.testJobID).Return(suite.taskInfos, nil),
+		suite.mockedCachedJob.EXPECT().
+			PatchTasks(gomock.Any(), runtimeDiffs).Return(nil),
+	)
+	suite.mockedGoalStateDrive.EXPECT().
+		EnqueueTaskWithPriority(suite.testJobID, gomock.Any(), gomock.Any(), commongoalstate.PriorityHigh).
+		Return().
+		Times(testInstanceCount - 1)
+	suite.mockedCachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
+	suite.mockedGoalStateDrive.EXPECT().
+		EnqueueJobWithDefaultDelay(suite.testJobID, job.JobType_BATCH)
+
+	resp, err := suite.handler.Stop(
+		context.Background(),
+		&task.StopRequest{
+			JobId: suite.testJobID,
+			LabelSelector: &task.LabelSelector{
+				ExcludeLabels: []*peloton.Label{canaryLabel},
+			},
+		},
+	)
+	suite.NoError(err)
+	suite.Empty(resp.GetInvalidInstanceIds())
+	suite.Len(resp.GetStoppedInstanceIds(), testInstanceCount-1)
+	suite.NotContains(resp.GetStoppedInstanceIds(), uint32(0))
+}
+
+// TestMatchesLabelSelector tests selecting the tasks by their labels
+func (suite *TaskHandlerTestSuite) TestMatchesLabelSelector() {
+	canary := &peloton.Label{Key: "canary", Value: "true"}
+	zone := &peloton.Label{Key: "zone", Value: "a"}
+	labels := []*peloton.Label{canary, zone}
+
+	suite.True(matchesLabelSelector(nil, labels))
+	suite.True(matchesLabelSelector(&task.LabelSelector{}, nil))
+	suite.True(matchesLabelSelector(&task.LabelSelector{
+		MatchLabels: []*peloton.Label{{Key: "zone", Value: "a"}},
+	}, labels))
+	suite.False(matchesLabelSelector(&task.LabelSelector{
+		MatchLabels: []*peloton.Label{{Key: "zone", Value: "b"}},
+	}, labels))
+	suite.False(matchesLabelSelector(&task.LabelSelector{
+		ExcludeLabels: []*peloton.Label{{Key: "canary", Value: "true"}},
+	}, labels))
+	suite.True(matchesLabelSelector(&task.LabelSelector{
+		ExcludeLabels: []*peloton.Label{{Key: "canary", Value: "true"}},
+	}, []*peloton.Label{zone}))
+}
+
+// TestGetStopMessage tests the message recorded for the tasks stopped
+// by a stop request
+func (suite *TaskHandlerTestSuite) TestGetStopMessage() {
+	suite.Equal("Task stop API request",
+		getStopMessage(&task.StopRequest{}))
+	suite.Equal("Task stop API request: drain",
+		getStopMessage(&task.StopRequest{Reason: "drain"}))
+	suite.Equal("Task stop API request by alice",
+		getStopMessage(&task.StopRequest{Principal: "alice"}))
+	suite.Equal("Task stop API request by alice: drain",
+		getStopMessage(&task.StopRequest{Reason: "drain", Principal: "alice"}))
+}
+
+// TestStopsTaskByTask tests that the tasks are stopped one by one only
+// when the caller overrides fields persisted in the runtime of each task
+func (suite *TaskHandlerTestSuite) TestStopsTaskByTask() {
+	suite.False(stopsTaskByTask(&task.StopRequest{}))
+	// the principal is set from the client certificate of the request
+	suite.False(stopsTaskByTask(&task.StopRequest{Principal: "alice"}))
+	suite.True(stopsTaskByTask(&task.StopRequest{Reason: "drain"}))
+	suite.True(stopsTaskByTask(&task.StopRequest{
+		KillGracePeriod: &task.KillGracePeriod{Seconds: 10},
+	}))
+	suite.True(stopsTaskByTask(&task.StopRequest{
+		LabelSelector: &task.LabelSelector{},
+	}))
+	suite.True(stopsTaskByTask(&task.StopRequest{
+		OperationContext: &task.OperationContext{IncidentId: "INC-1234"},
+	}))
+}
+
 func (suite *TaskHandlerTestSuite) TestStopTasksSkipKillNotRunningTask() {
 	taskInfos := make(map[uint32]*task.TaskInfo)
 	taskInfos[1] = suite.taskInfos[1]
@@ -458,19 +905,15 @@ func (suite *TaskHandlerTestSuite) TestStopTasksSkipKillNotRunningTask() {
 	)
 
 	suite.mockedGoalStateDrive.EXPECT().
-		EnqueueTask(suite.testJobID, uint32(1), gomock.Any()).Return()
+		EnqueueTaskWithPriority(suite.testJobID, uint32(1), gomock.Any(), commongoalstate.PriorityHigh).Return()
 
 	suite.mockedGoalStateDrive.EXPECT().
-		EnqueueTask(suite.testJobID, uint32(2), gomock.Any()).Return()
+		EnqueueTaskWithPriority(suite.testJobID, uint32(2), gomock.Any(), commongoalstate.PriorityHigh).Return()
 
 	suite.mockedCachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
 
 	suite.mockedGoalStateDrive.EXPECT().
-		JobRuntimeDuration(job.JobType_BATCH).
-		Return(1 * time.Second)
-
-	suite.mockedGoalStateDrive.EXPECT().
-		EnqueueJob(suite.testJobID, gomock.Any()).Return()
+		EnqueueJobWithDefaultDelay(suite.testJobID, job.JobType_BATCH)
 
 	var request = &task.StopRequest{
 		JobId:  suite.testJobID,
@@ -626,10 +1069,7 @@ func (suite *TaskHandlerTestSuite) TestStopTasks_PatchFailure() {
 			PatchTasks(gomock.Any(), gomock.Any()).Return(errors.New("test error")),
 		suite.mockedCachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH),
 		suite.mockedGoalStateDrive.EXPECT().
-			JobRuntimeDuration(job.JobType_BATCH).
-			Return(1*time.Second),
-		suite.mockedGoalStateDrive.EXPECT().
-			EnqueueJob(suite.testJobID, gomock.Any()).Return(),
+			EnqueueJobWithDefaultDelay(suite.testJobID, job.JobType_BATCH),
 	)
 
 	var request = &task.StopRequest{
@@ -644,6 +1084,109 @@ func (suite *TaskHandlerTestSuite) TestStopTasks_PatchFailure() {
 	suite.NotNil(resp.GetError())
 }
 
+// TestBatchStop tests stopping tasks across multiple jobs where stopping
+// the tasks of one of the jobs fails. The jobs are stopped in parallel, so
+// only the calls for each job are ordered.
+func (suite *TaskHandlerTestSuite) TestBatchStop() {
+	singleTaskInfo := make(map[uint32]*task.TaskInfo)
+	singleTaskInfo[1] = suite.taskInfos[1]
+
+	taskRanges := []*task.InstanceRange{
+		{
+			From: 1,
+			To:   2,
+		},
+	}
+
+	otherJobID := &peloton.JobID{Value: uuid.New()}
+	otherCachedJob := cachedmocks.NewMockJob(suite.ctrl)
+
+	gomock.InOrder(
+		suite.mockedCandidate.EXPECT().IsLeader().Return(true),
+		suite.mockedJobFactory.EXPECT().
+			AddJob(suite.testJobID).Return(suite.mockedCachedJob),
+		suite.mockedCachedJob.EXPECT().
+			GetConfig(gomock.Any()).
+			Return(cachedtest.NewMockJobConfig(suite.ctrl, suite.testJobConfig), nil),
+		suite.mockedTaskStore.EXPECT().
+			GetTasksForJobByRange(gomock.Any(), suite.testJobID, taskRanges[0]).Return(singleTaskInfo, nil),
+		suite.mockedCachedJob.EXPECT().
+			PatchTasks(gomock.Any(), gomock.Any()).Return(nil),
+		suite.mockedGoalStateDrive.EXPECT().
+			EnqueueTaskWithPriority(suite.testJobID, uint32(1), gomock.Any(), commongoalstate.PriorityHigh).Return(),
+		suite.mockedCachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH),
+		suite.mockedGoalStateDrive.EXPECT().
+			EnqueueJobWithDefaultDelay(suite.testJobID, job.JobType_BATCH),
+	)
+	gomock.InOrder(
+		isLeader,
+		suite.mockedJobFactory.EXPECT().
+			AddJob(otherJobID).Return(otherCachedJob),
+		otherCachedJob.EXPECT().
+			GetConfig(gomock.Any()).
+			Return(nil, yarpcerrors.NotFoundErrorf("test error")),
+	)
+
+	resp, err := suite.handler.BatchStop(
+		context.Background(),
+		&task.BatchStopRequest{
+			Jobs: []*task.StopRequest{
+				{
+					JobId:  suite.testJobID,
+					Ranges: taskRanges,
+				},
+				{
+					JobId: otherJobID,
+				},
+			},
+		},
+	)
+	suite.NoError(err)
+	suite.Len(resp.GetResults(), 2)
+
+	suite.Equal(suite.testJobID, resp.GetResults()[0].GetJobId())
+	suite.Nil(resp.GetResults()[0].GetResult().GetError())
+	suite.Equal([]uint32{1},
+		resp.GetResults()[0].GetResult().GetStoppedInstanceIds())
+
+	suite.Equal(otherJobID, resp.GetResults()[1].GetJobId())
+	suite.NotNil(resp.GetResults()[1].GetResult().GetError().GetNotFound())
+	suite.Empty(resp.GetResults()[1].GetResult().GetStoppedInstanceIds())
+}
+
+// TestBatchStopDuplicateJob tests stopping tasks across multiple jobs
+// when a job is provided twice
+func (suite *TaskHandlerTestSuite) TestBatchStopDuplicateJob() {
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+
+	resp, err := suite.handler.BatchStop(
+		context.Background(),
+		&task.BatchStopRequest{
+			Jobs: []*task.StopRequest{
+				{JobId: suite.testJobID},
+				{JobId: suite.testJobID},
+			},
+		},
+	)
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+	suite.Nil(resp)
+}
+
+// TestBatchStop_NonLeader tests stopping tasks across multiple jobs on
+// non-leader node
+func (suite *TaskHandlerTestSuite) TestBatchStop_NonLeader() {
+	suite.mockedCandidate.EXPECT().IsLeader().Return(false)
+
+	resp, err := suite.handler.BatchStop(
+		context.Background(),
+		&task.BatchStopRequest{
+			Jobs: []*task.StopRequest{{JobId: suite.testJobID}},
+		},
+	)
+	suite.Error(err)
+	suite.Nil(resp)
+}
+
 func (suite *TaskHandlerTestSuite) TestStartAllTasks() {
 	expectedTaskIds := make(map[*mesos.TaskID]bool)
 	runningInstanceID := uint32(3)
@@ -704,16 +1247,12 @@ func (suite *TaskHandlerTestSuite) TestStartAllTasks() {
 	}
 
 	suite.mockedGoalStateDrive.EXPECT().
-		EnqueueTask(suite.testJobID, gomock.Any(), gomock.Any()).Return().AnyTimes()
+		EnqueueTaskWithPriority(suite.testJobID, gomock.Any(), gomock.Any(), commongoalstate.PriorityHigh).Return().AnyTimes()
 
 	suite.mockedCachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
 
 	suite.mockedGoalStateDrive.EXPECT().
-		JobRuntimeDuration(job.JobType_BATCH).
-		Return(1 * time.Second)
-
-	suite.mockedGoalStateDrive.EXPECT().
-		EnqueueJob(suite.testJobID, gomock.Any()).Return()
+		EnqueueJobWithDefaultDelay(suite.testJobID, job.JobType_BATCH)
 
 	var request = &task.StartRequest{
 		JobId: suite.testJobID,
@@ -765,7 +1304,166 @@ func (suite *TaskHandlerTestSuite) TestStartTasks_GetConfigFailure() {
 	suite.NotNil(resp.GetError())
 }
 
-// TestStartTasksTerminatedJob tests starting tasks from a terminated batch job
+// TestStartTasksIdempotencyKey tests that a retried start returns the
+// outcome of the earlier start with the same idempotency key
+func (suite *TaskHandlerTestSuite) TestStartTasksIdempotencyKey() {
+	req := &task.StartRequest{
+		JobId:          suite.testJobID,
+		IdempotencyKey: "key",
+	}
+	requestHash, err := getRequestHash(req)
+	suite.NoError(err)
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedIdempotencyKeyOps.EXPECT().
+		Reserve(gomock.Any(), suite.testJobID, "Start", "key", requestHash).
+		Return(yarpcerrors.AlreadyExistsErrorf("item already exists"))
+	suite.mockedIdempotencyKeyOps.EXPECT().
+		Get(gomock.Any(), suite.testJobID, "Start", "key").
+		Return(&ormobjects.TaskIdempotencyKeyObject{
+			RequestHash: requestHash,
+			Response:    `{"startedInstanceIds":[0,1]}`,
+		}, nil)
+
+	resp, err := suite.handler.Start(context.Background(), req)
+	suite.NoError(err)
+	suite.Equal([]uint32{0, 1}, resp.GetStartedInstanceIds())
+}
+
+// TestStartTasksIdempotencyKeyFirstCall tests that the response of a start
+// is recorded with the idempotency key it reserved
+func (suite *TaskHandlerTestSuite) TestStartTasksIdempotencyKeyFirstCall() {
+	gomock.InOrder(
+		suite.mockedCandidate.EXPECT().IsLeader().Return(true),
+		suite.mockedIdempotencyKeyOps.EXPECT().
+			Reserve(gomock.Any(), suite.testJobID, "Start", "key", gomock.Any()).
+			Return(nil),
+		suite.mockedJobFactory.EXPECT().
+			AddJob(suite.testJobID).Return(suite.mockedCachedJob),
+		suite.mockedCachedJob.EXPECT().
+			GetConfig(gomock.Any()).Return(nil, errors.New("test error")),
+		suite.mockedIdempotencyKeyOps.EXPECT().
+			SetResponse(gomock.Any(), suite.testJobID, "Start", "key", gomock.Any()).
+			Do(func(
+				ctx context.Context,
+				_ *peloton.JobID,
+				_ string,
+				_ string,
+				response string) {
+				// the response is recorded even if the call timed out
+				suite.NoError(ctx.Err())
+				suite.Contains(response, "test error")
+			}).
+			Return(nil),
+	)
+
+	resp, err := suite.handler.Start(
+		context.Background(),
+		&task.StartRequest{
+			JobId:          suite.testJobID,
+			IdempotencyKey: "key",
+		},
+	)
+	suite.NoError(err)
+	suite.NotNil(resp.GetError())
+}
+
+// TestStartTasksIdempotencyKeyMismatch tests rejecting a start which
+// reuses the idempotency key of an earlier start with another request
+func (suite *TaskHandlerTestSuite) TestStartTasksIdempotencyKeyMismatch() {
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedIdempotencyKeyOps.EXPECT().
+		Reserve(gomock.Any(), suite.testJobID, "Start", "key", gomock.Any()).
+		Return(yarpcerrors.AlreadyExistsErrorf("item already exists"))
+	suite.mockedIdempotencyKeyOps.EXPECT().
+		Get(gomock.Any(), suite.testJobID, "Start", "key").
+		Return(&ormobjects.TaskIdempotencyKeyObject{
+			RequestHash: "other-hash",
+			Response:    `{"startedInstanceIds":[0,1]}`,
+		}, nil)
+
+	_, err := suite.handler.Start(
+		context.Background(),
+		&task.StartRequest{
+			JobId:          suite.testJobID,
+			IdempotencyKey: "key",
+		},
+	)
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestStartTasksIdempotencyKeyInProgress tests that a start is not
+// processed while the earlier start with the same idempotency key is
+func (suite *TaskHandlerTestSuite) TestStartTasksIdempotencyKeyInProgress() {
+	req := &task.StartRequest{
+		JobId:          suite.testJobID,
+		IdempotencyKey: "key",
+	}
+	requestHash, err := getRequestHash(req)
+	suite.NoError(err)
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedIdempotencyKeyOps.EXPECT().
+		Reserve(gomock.Any(), suite.testJobID, "Start", "key", requestHash).
+		Return(yarpcerrors.AlreadyExistsErrorf("item already exists"))
+	suite.mockedIdempotencyKeyOps.EXPECT().
+		Get(gomock.Any(), suite.testJobID, "Start", "key").
+		Return(&ormobjects.TaskIdempotencyKeyObject{
+			RequestHash: requestHash,
+		}, nil)
+
+	_, err = suite.handler.Start(context.Background(), req)
+	suite.True(yarpcerrors.IsAborted(err))
+}
+
+// TestStartTasksIdempotencyKeyStoreError tests failing a start when
+// its idempotency key cannot be reserved
+func (suite *TaskHandlerTestSuite) TestStartTasksIdempotencyKeyStoreError() {
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedIdempotencyKeyOps.EXPECT().
+		Reserve(gomock.Any(), suite.testJobID, "Start", "key", gomock.Any()).
+		Return(errors.New("test error"))
+
+	_, err := suite.handler.Start(
+		context.Background(),
+		&task.StartRequest{
+			JobId:          suite.testJobID,
+			IdempotencyKey: "key",
+		},
+	)
+	suite.True(yarpcerrors.IsInternal(err))
+}
+
+// TestRestartTasksIdempotencyKeyFailure tests that the idempotency key of
+// a failed restart is released, so that the restart is processed again
+// when retried
+func (suite *TaskHandlerTestSuite) TestRestartTasksIdempotencyKeyFailure() {
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedIdempotencyKeyOps.EXPECT().
+		Reserve(gomock.Any(), suite.testJobID, "Restart", "key", gomock.Any()).
+		Return(nil)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		ID().Return(suite.testJobID).AnyTimes()
+	suite.mockedTaskStore.EXPECT().
+		GetTasksForJob(gomock.Any(), suite.testJobID).
+		Return(nil, errors.New("test error"))
+	suite.mockedIdempotencyKeyOps.EXPECT().
+		Delete(gomock.Any(), suite.testJobID, "Restart", "key").
+		Return(nil)
+
+	_, err := suite.handler.Restart(
+		context.Background(),
+		&task.RestartRequest{
+			JobId:          suite.testJobID,
+			IdempotencyKey: "key",
+		},
+	)
+	suite.Error(err)
+}
+
+// TestStartTasksTerminatedJob tests starting tasks from a terminated batch job
 func (suite *TaskHandlerTestSuite) TestStartTasksTerminatedJob() {
 	suite.testJobRuntime.State = job.JobState_SUCCEEDED
 	gomock.InOrder(
@@ -929,11 +1627,7 @@ func (suite *TaskHandlerTestSuite) TestStartTasksCompareAndSetAddFailure() {
 	suite.mockedCachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
 
 	suite.mockedGoalStateDrive.EXPECT().
-		JobRuntimeDuration(job.JobType_BATCH).
-		Return(1 * time.Second)
-
-	suite.mockedGoalStateDrive.EXPECT().
-		EnqueueJob(suite.testJobID, gomock.Any()).Return()
+		EnqueueJobWithDefaultDelay(suite.testJobID, job.JobType_BATCH)
 
 	var request = &task.StartRequest{
 		JobId: suite.testJobID,
@@ -996,11 +1690,7 @@ func (suite *TaskHandlerTestSuite) TestStartTasksCompareAndSetGetRuntimeFailure(
 	suite.mockedCachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
 
 	suite.mockedGoalStateDrive.EXPECT().
-		JobRuntimeDuration(job.JobType_BATCH).
-		Return(1 * time.Second)
-
-	suite.mockedGoalStateDrive.EXPECT().
-		EnqueueJob(suite.testJobID, gomock.Any()).Return()
+		EnqueueJobWithDefaultDelay(suite.testJobID, job.JobType_BATCH)
 
 	var request = &task.StartRequest{
 		JobId: suite.testJobID,
@@ -1077,11 +1767,7 @@ func (suite *TaskHandlerTestSuite) TestStartTasksCompareAndSetFailure() {
 	suite.mockedCachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
 
 	suite.mockedGoalStateDrive.EXPECT().
-		JobRuntimeDuration(job.JobType_BATCH).
-		Return(1 * time.Second)
-
-	suite.mockedGoalStateDrive.EXPECT().
-		EnqueueJob(suite.testJobID, gomock.Any()).Return()
+		EnqueueJobWithDefaultDelay(suite.testJobID, job.JobType_BATCH)
 
 	var request = &task.StartRequest{
 		JobId: suite.testJobID,
@@ -1097,6 +1783,73 @@ func (suite *TaskHandlerTestSuite) TestStartTasksCompareAndSetFailure() {
 	suite.Equal(len(resp.GetInvalidInstanceIds()), testInstanceCount)
 }
 
+// TestStartTasksPartialFailure tests that the outcomes of starting
+// instances in parallel are aggregated into sorted started and failed lists
+func (suite *TaskHandlerTestSuite) TestStartTasksPartialFailure() {
+	instanceCount := uint32(20)
+	taskInfos := make(map[uint32]*task.TaskInfo)
+	for i := uint32(0); i < instanceCount; i++ {
+		taskInfos[i] = suite.createTestTaskInfo(task.TaskState_FAILED, i)
+		taskInfos[i].Runtime.GoalState = task.TaskState_KILLED
+	}
+
+	gomock.InOrder(
+		suite.mockedCandidate.EXPECT().IsLeader().Return(true),
+		suite.mockedJobFactory.EXPECT().
+			AddJob(suite.testJobID).Return(suite.mockedCachedJob),
+		suite.mockedCachedJob.EXPECT().
+			GetConfig(gomock.Any()).Return(cachedtest.NewMockJobConfig(suite.ctrl, suite.testJobConfig), nil),
+		suite.mockedCachedJob.EXPECT().
+			GetRuntime(gomock.Any()).
+			Return(suite.testJobRuntime, nil),
+		suite.mockedCachedJob.EXPECT().
+			CompareAndSetRuntime(gomock.Any(), gomock.Any()).
+			Return(&job.RuntimeInfo{}, nil),
+		suite.mockedTaskStore.EXPECT().
+			GetTasksForJob(gomock.Any(), suite.testJobID).Return(taskInfos, nil),
+	)
+
+	var expectedStarted, expectedFailed []uint32
+	for i := uint32(0); i < instanceCount; i++ {
+		// every third instance fails to be written
+		if i%3 == 0 {
+			suite.mockedCachedJob.EXPECT().
+				AddTask(gomock.Any(), i).
+				Return(nil, fmt.Errorf("fake db error"))
+			expectedFailed = append(expectedFailed, i)
+			continue
+		}
+
+		cachedTask := cachedmocks.NewMockTask(suite.ctrl)
+		suite.mockedCachedJob.EXPECT().
+			AddTask(gomock.Any(), i).
+			Return(cachedTask, nil)
+		cachedTask.EXPECT().
+			GetRuntime(gomock.Any()).
+			Return(taskInfos[i].Runtime, nil)
+		cachedTask.EXPECT().
+			CompareAndSetRuntime(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil, nil)
+		suite.mockedGoalStateDrive.EXPECT().
+			EnqueueTaskWithPriority(suite.testJobID, i, gomock.Any(), commongoalstate.PriorityHigh)
+		expectedStarted = append(expectedStarted, i)
+	}
+
+	suite.mockedCachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
+	suite.mockedGoalStateDrive.EXPECT().
+		EnqueueJobWithDefaultDelay(suite.testJobID, job.JobType_BATCH)
+
+	resp, err := suite.handler.Start(
+		context.Background(),
+		&task.StartRequest{JobId: suite.testJobID},
+	)
+
+	suite.NoError(err)
+	suite.Nil(resp.GetError())
+	suite.Equal(expectedStarted, resp.GetStartedInstanceIds())
+	suite.Equal(expectedFailed, resp.GetInvalidInstanceIds())
+}
+
 func (suite *TaskHandlerTestSuite) TestStartTasksWithRanges() {
 	expectedTaskIds := make(map[*mesos.TaskID]bool)
 	for _, taskInfo := range suite.taskInfos {
@@ -1150,16 +1903,12 @@ func (suite *TaskHandlerTestSuite) TestStartTasksWithRanges() {
 		}).Return(nil, nil)
 
 	suite.mockedGoalStateDrive.EXPECT().
-		EnqueueTask(suite.testJobID, gomock.Any(), gomock.Any()).Return()
+		EnqueueTaskWithPriority(suite.testJobID, gomock.Any(), gomock.Any(), commongoalstate.PriorityHigh).Return()
 
 	suite.mockedCachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
 
 	suite.mockedGoalStateDrive.EXPECT().
-		JobRuntimeDuration(job.JobType_BATCH).
-		Return(1 * time.Second)
-
-	suite.mockedGoalStateDrive.EXPECT().
-		EnqueueJob(suite.testJobID, gomock.Any()).Return()
+		EnqueueJobWithDefaultDelay(suite.testJobID, job.JobType_BATCH)
 
 	var request = &task.StartRequest{
 		JobId:  suite.testJobID,
@@ -1515,6 +2264,159 @@ func (suite *TaskHandlerTestSuite) TestGetPodEventsThreeRunsLimitToFive() {
 	suite.NotNil(response)
 }
 
+// createTestPodEvents returns the pod events of a run of the test
+// instance, one per state, sorted from the newest to the oldest.
+func createTestPodEvents(
+	run uint64,
+	timestamp time.Time,
+	states ...task.TaskState) []*pod.PodEvent {
+	var events []*pod.PodEvent
+	for i := len(states) - 1; i >= 0; i-- {
+		events = append(events, &pod.PodEvent{
+			PodId: &v1alphapeloton.PodID{
+				Value: fmt.Sprintf("%s-%d-%d", testJob, testInstanceCount, run),
+			},
+			Version: &v1alphapeloton.EntityVersion{
+				Value: "1",
+			},
+			DesiredVersion: &v1alphapeloton.EntityVersion{
+				Value: "1",
+			},
+			PrevPodId: &v1alphapeloton.PodID{
+				Value: fmt.Sprintf("%s-%d-%d", testJob, testInstanceCount, run-1),
+			},
+			ActualState: states[i].String(),
+			Timestamp: timestamp.Add(time.Duration(i) * time.Minute).
+				Format(time.RFC3339),
+		})
+	}
+	return events
+}
+
+// TestGetPodEventsPagination tests paging through the pod events of
+// multiple runs of a task
+func (suite *TaskHandlerTestSuite) TestGetPodEventsPagination() {
+	request := &task.GetPodEventsRequest{
+		JobId: &peloton.JobID{
+			Value: testJob,
+		},
+		InstanceId: testInstanceCount,
+		Offset:     1,
+		MaxEvents:  2,
+	}
+
+	now := time.Now()
+	suite.mockedTaskStore.EXPECT().
+		GetPodEvents(gomock.Any(), testJob, uint32(testInstanceCount), "").
+		Return(createTestPodEvents(5, now,
+			task.TaskState_RUNNING, task.TaskState_FAILED), nil)
+	suite.mockedTaskStore.EXPECT().
+		GetPodEvents(gomock.Any(), testJob, uint32(testInstanceCount),
+			fmt.Sprintf("%s-%d-%d", testJob, testInstanceCount, 4)).
+		Return(createTestPodEvents(4, now.Add(-time.Hour),
+			task.TaskState_RUNNING, task.TaskState_FAILED), nil)
+
+	response, err := suite.handler.GetPodEvents(context.Background(), request)
+	suite.NoError(err)
+	suite.True(response.GetHasMore())
+	suite.Len(response.GetResult(), 2)
+	suite.Equal(
+		task.TaskState_RUNNING.String(),
+		response.GetResult()[0].GetActualState())
+	suite.Equal(
+		fmt.Sprintf("%s-%d-%d", testJob, testInstanceCount, 5),
+		response.GetResult()[0].GetTaskId().GetValue())
+	suite.Equal(
+		task.TaskState_FAILED.String(),
+		response.GetResult()[1].GetActualState())
+	suite.Equal(
+		fmt.Sprintf("%s-%d-%d", testJob, testInstanceCount, 4),
+		response.GetResult()[1].GetTaskId().GetValue())
+}
+
+// TestGetPodEventsTimeRangeAndStates tests filtering the pod events by
+// timestamp and state, without walking the runs older than the start time
+func (suite *TaskHandlerTestSuite) TestGetPodEventsTimeRangeAndStates() {
+	now := time.Now().Truncate(time.Second)
+	request := &task.GetPodEventsRequest{
+		JobId: &peloton.JobID{
+			Value: testJob,
+		},
+		InstanceId: testInstanceCount,
+		StartTime:  now.Add(-2 * time.Hour).Format(time.RFC3339),
+		EndTime:    now.Format(time.RFC3339),
+		States:     []task.TaskState{task.TaskState_FAILED},
+	}
+
+	suite.mockedTaskStore.EXPECT().
+		GetPodEvents(gomock.Any(), testJob, uint32(testInstanceCount), "").
+		Return(createTestPodEvents(5, now,
+			task.TaskState_RUNNING, task.TaskState_FAILED), nil)
+	suite.mockedTaskStore.EXPECT().
+		GetPodEvents(gomock.Any(), testJob, uint32(testInstanceCount),
+			fmt.Sprintf("%s-%d-%d", testJob, testInstanceCount, 4)).
+		Return(createTestPodEvents(4, now.Add(-time.Hour),
+			task.TaskState_RUNNING, task.TaskState_FAILED), nil)
+	suite.mockedTaskStore.EXPECT().
+		GetPodEvents(gomock.Any(), testJob, uint32(testInstanceCount),
+			fmt.Sprintf("%s-%d-%d", testJob, testInstanceCount, 3)).
+		Return(createTestPodEvents(3, now.Add(-3*time.Hour),
+			task.TaskState_RUNNING, task.TaskState_FAILED), nil)
+
+	response, err := suite.handler.GetPodEvents(context.Background(), request)
+	suite.NoError(err)
+	suite.False(response.GetHasMore())
+	suite.Len(response.GetResult(), 1)
+	suite.Equal(
+		task.TaskState_FAILED.String(),
+		response.GetResult()[0].GetActualState())
+	suite.Equal(
+		fmt.Sprintf("%s-%d-%d", testJob, testInstanceCount, 4),
+		response.GetResult()[0].GetTaskId().GetValue())
+}
+
+// TestGetPodEventsExceedsLimits tests GetPodEvents failure due to more
+// runs or pod events requested than allowed
+func (suite *TaskHandlerTestSuite) TestGetPodEventsExceedsLimits() {
+	suite.handler.pagination = handlerutil.PaginationConfig{
+		MaxPodEventRuns: 5,
+		MaxPodEvents:    100,
+	}
+
+	_, err := suite.handler.GetPodEvents(
+		context.Background(),
+		&task.GetPodEventsRequest{
+			JobId:      &peloton.JobID{Value: testJob},
+			InstanceId: testInstanceCount,
+			Limit:      6,
+		})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+
+	_, err = suite.handler.GetPodEvents(
+		context.Background(),
+		&task.GetPodEventsRequest{
+			JobId:      &peloton.JobID{Value: testJob},
+			InstanceId: testInstanceCount,
+			MaxEvents:  101,
+		})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestGetPodEventsInvalidTime tests GetPodEvents failure due to a start
+// time not in RFC3339 form
+func (suite *TaskHandlerTestSuite) TestGetPodEventsInvalidTime() {
+	request := &task.GetPodEventsRequest{
+		JobId: &peloton.JobID{
+			Value: testJob,
+		},
+		InstanceId: testInstanceCount,
+		StartTime:  "yesterday",
+	}
+
+	_, err := suite.handler.GetPodEvents(context.Background(), request)
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
 // TestGetPodEventsStoreError tests store error while getting pod events
 func (suite *TaskHandlerTestSuite) TestGetPodEventsStoreError() {
 	request := &task.GetPodEventsRequest{
@@ -1638,6 +2540,121 @@ func (suite *TaskHandlerTestSuite) TestGetPodEventsNoEvents() {
 	suite.NoError(err)
 }
 
+// TestGetTaskTimeline tests merging the pod events of the runs of a task
+// with the task events of the resource manager
+func (suite *TaskHandlerTestSuite) TestGetTaskTimeline() {
+	instanceID := uint32(0)
+	runID := func(run int) string {
+		return fmt.Sprintf("%s-%d-%d", testJob, instanceID, run)
+	}
+	podEvent := func(run int, state task.TaskState, timestamp string) *pod.PodEvent {
+		return &pod.PodEvent{
+			PodId:          &v1alphapeloton.PodID{Value: runID(run)},
+			PrevPodId:      &v1alphapeloton.PodID{Value: runID(run - 1)},
+			Version:        &v1alphapeloton.EntityVersion{Value: "1"},
+			DesiredVersion: &v1alphapeloton.EntityVersion{Value: "1"},
+			ActualState:    state.String(),
+			DesiredState:   task.TaskState_RUNNING.String(),
+			Timestamp:      timestamp,
+		}
+	}
+	rmEvent := func(state task.TaskState, timestamp string) *task.TaskEvent {
+		return &task.TaskEvent{
+			Source:    task.TaskEvent_SOURCE_RESMGR,
+			State:     state,
+			Timestamp: timestamp,
+		}
+	}
+
+	suite.mockedTaskStore.EXPECT().
+		GetPodEvents(gomock.Any(), testJob, instanceID, "").
+		Return([]*pod.PodEvent{
+			podEvent(2, task.TaskState_RUNNING, "2019-01-01T00:10:40Z"),
+			podEvent(2, task.TaskState_LAUNCHED, "2019-01-01T00:10:30Z"),
+			podEvent(2, task.TaskState_INITIALIZED, "2019-01-01T00:10:00Z"),
+		}, nil)
+	suite.mockedTaskStore.EXPECT().
+		GetPodEvents(gomock.Any(), testJob, instanceID, runID(1)).
+		Return([]*pod.PodEvent{
+			podEvent(1, task.TaskState_FAILED, "2019-01-01T00:05:00Z"),
+			podEvent(1, task.TaskState_INITIALIZED, "2019-01-01T00:00:00Z"),
+		}, nil)
+	suite.mockedActiveRMTasks.EXPECT().
+		GetTaskEvents(fmt.Sprintf("%s-%d", testJob, instanceID)).
+		Return([]*task.TaskEvent{
+			rmEvent(task.TaskState_PENDING, "2018-12-31T00:00:00Z"),
+			rmEvent(task.TaskState_PENDING, "2019-01-01T00:00:01Z"),
+			rmEvent(task.TaskState_PENDING, "2019-01-01T00:10:00Z"),
+			rmEvent(task.TaskState_PLACED, "2019-01-01T00:10:20Z"),
+		})
+
+	resp, err := suite.handler.GetTaskTimeline(
+		context.Background(),
+		&task.GetTaskTimelineRequest{
+			JobId:      &peloton.JobID{Value: testJob},
+			InstanceId: instanceID,
+		})
+	suite.NoError(err)
+	suite.Len(resp.GetRuns(), 2)
+
+	run := resp.GetRuns()[0]
+	suite.Equal(runID(2), run.GetTaskId().GetValue())
+	var states []task.TaskState
+	var sources []task.TaskEvent_Source
+	for _, event := range run.GetEvents() {
+		states = append(states, event.GetState())
+		sources = append(sources, event.GetSource())
+	}
+	suite.Equal([]task.TaskState{
+		task.TaskState_INITIALIZED,
+		task.TaskState_PENDING,
+		task.TaskState_PLACED,
+		task.TaskState_LAUNCHED,
+		task.TaskState_RUNNING,
+	}, states)
+	suite.Equal([]task.TaskEvent_Source{
+		task.TaskEvent_SOURCE_JOBMGR,
+		task.TaskEvent_SOURCE_RESMGR,
+		task.TaskEvent_SOURCE_RESMGR,
+		task.TaskEvent_SOURCE_HOSTMGR,
+		task.TaskEvent_SOURCE_HOSTMGR,
+	}, sources)
+	suite.Equal(float64(20), run.GetTimeToPlaceSeconds())
+	suite.Equal(float64(10), run.GetTimeToLaunchSeconds())
+
+	// the event before the first run is dropped
+	run = resp.GetRuns()[1]
+	suite.Equal(runID(1), run.GetTaskId().GetValue())
+	suite.Len(run.GetEvents(), 3)
+	suite.Equal(task.TaskState_PENDING, run.GetEvents()[1].GetState())
+	suite.Zero(run.GetTimeToPlaceSeconds())
+	suite.Zero(run.GetTimeToLaunchSeconds())
+}
+
+// TestGetTaskTimelineNoJobID tests getting the timeline of a task
+// without a job id
+func (suite *TaskHandlerTestSuite) TestGetTaskTimelineNoJobID() {
+	_, err := suite.handler.GetTaskTimeline(
+		context.Background(),
+		&task.GetTaskTimelineRequest{})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestGetTaskTimelineStoreError tests getting the timeline of a task
+// when the store fails
+func (suite *TaskHandlerTestSuite) TestGetTaskTimelineStoreError() {
+	suite.mockedTaskStore.EXPECT().
+		GetPodEvents(gomock.Any(), testJob, uint32(0), "").
+		Return(nil, errors.New("test error"))
+
+	_, err := suite.handler.GetTaskTimeline(
+		context.Background(),
+		&task.GetTaskTimelineRequest{
+			JobId: &peloton.JobID{Value: testJob},
+		})
+	suite.True(yarpcerrors.IsInternal(err))
+}
+
 func (suite *TaskHandlerTestSuite) TestBrowseSandboxPreviousTaskRun() {
 	var events []*pod.PodEvent
 	event := &pod.PodEvent{
@@ -1736,7 +2753,8 @@ func (suite *TaskHandlerTestSuite) TestBrowseSandboxListSandboxFileFailure() {
 	mesosAgentDir := "mesosAgentDir"
 	instanceID := uint32(0)
 
-	suite.handler.mesosAgentWorkDir = mesosAgentDir
+	suite.handler.sandboxLayouts = logmanager.NewSandboxLayoutResolver(
+		nil, mesosAgentDir, nil)
 
 	var events []*pod.PodEvent
 	event := &pod.PodEvent{
@@ -1778,13 +2796,19 @@ func (suite *TaskHandlerTestSuite) TestBrowseSandboxListSandboxFileFailure() {
 		suite.mockedFrameworkInfoStore.EXPECT().
 			GetFrameworkID(gomock.Any(), _frameworkName).
 			Return(frameworkID, nil),
+		suite.mockedTaskStore.EXPECT().
+			GetTaskConfig(gomock.Any(), suite.testJobID, instanceID, gomock.Any()).
+			Return(&task.TaskConfig{}, nil, nil),
 		suite.mockedHostMgr.EXPECT().
 			GetMesosAgentInfo(gomock.Any(),
 				&hostsvc.GetMesosAgentInfoRequest{Hostname: hostName}).
 			Return(&hostsvc.GetMesosAgentInfoResponse{}, nil),
 		suite.mockedLogManager.EXPECT().
-			ListSandboxFilesPaths(mesosAgentDir, frameworkID, hostName,
-				"5051", agentID, req.GetTaskId()).
+			ListSandboxFiles(
+				(&logmanager.SandboxLayout{WorkDir: mesosAgentDir}).
+					WithExecutorID(testTaskID),
+				frameworkID, hostName,
+				"5051", agentID, req.GetTaskId(), false).
 			Return(nil, errors.New(
 				"enable to fetch sandbox files from mesos agent")),
 	)
@@ -1793,15 +2817,48 @@ func (suite *TaskHandlerTestSuite) TestBrowseSandboxListSandboxFileFailure() {
 	suite.NotEmpty(resp.GetError().GetFailure())
 }
 
+// newTestSandboxFiles returns the sandbox files returned by the log manager
+// and the sandbox files expected in the BrowseSandbox response
+func newTestSandboxFiles() ([]*logmanager.SandboxFileInfo, []*task.SandboxFile) {
+	files := []*logmanager.SandboxFileInfo{
+		{
+			Path:             "path1",
+			Size:             100,
+			ModificationTime: time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC),
+			Mode:             "-rw-r--r--",
+		},
+		{
+			Path:        "path2",
+			Mode:        "drwxr-xr-x",
+			IsDirectory: true,
+		},
+	}
+	expected := []*task.SandboxFile{
+		{
+			Path:             "path1",
+			Size:             100,
+			ModificationTime: "2019-01-02T03:04:05Z",
+			Mode:             "-rw-r--r--",
+		},
+		{
+			Path:        "path2",
+			Mode:        "drwxr-xr-x",
+			IsDirectory: true,
+		},
+	}
+	return files, expected
+}
+
 func (suite *TaskHandlerTestSuite) TestBrowseSandboxGetMesosMasterInfoFailure() {
 	instanceID := uint32(0)
-	sandboxFilesPaths := []string{"path1", "path2"}
+	sandboxFiles, _ := newTestSandboxFiles()
 	hostName := "peloton-test-host"
 	agentID := "peloton-test-agent"
 	frameworkID := "1234"
 	mesosAgentDir := "mesosAgentDir"
 
-	suite.handler.mesosAgentWorkDir = mesosAgentDir
+	suite.handler.sandboxLayouts = logmanager.NewSandboxLayoutResolver(
+		nil, mesosAgentDir, nil)
 
 	var events []*pod.PodEvent
 	event := &pod.PodEvent{
@@ -1843,14 +2900,20 @@ func (suite *TaskHandlerTestSuite) TestBrowseSandboxGetMesosMasterInfoFailure()
 		suite.mockedFrameworkInfoStore.EXPECT().
 			GetFrameworkID(gomock.Any(), _frameworkName).
 			Return(frameworkID, nil),
+		suite.mockedTaskStore.EXPECT().
+			GetTaskConfig(gomock.Any(), suite.testJobID, instanceID, gomock.Any()).
+			Return(&task.TaskConfig{}, nil, nil),
 		suite.mockedHostMgr.EXPECT().
 			GetMesosAgentInfo(gomock.Any(),
 				&hostsvc.GetMesosAgentInfoRequest{Hostname: hostName}).
 			Return(&hostsvc.GetMesosAgentInfoResponse{}, nil),
 		suite.mockedLogManager.EXPECT().
-			ListSandboxFilesPaths(mesosAgentDir, frameworkID, hostName,
-				"5051", agentID, req.GetTaskId()).
-			Return(sandboxFilesPaths, nil),
+			ListSandboxFiles(
+				(&logmanager.SandboxLayout{WorkDir: mesosAgentDir}).
+					WithExecutorID(testTaskID),
+				frameworkID, hostName,
+				"5051", agentID, req.GetTaskId(), false).
+			Return(sandboxFiles, nil),
 		suite.mockedHostMgr.EXPECT().
 			GetMesosMasterHostPort(gomock.Any(),
 				&hostsvc.MesosMasterHostPortRequest{}).
@@ -1865,12 +2928,14 @@ func (suite *TaskHandlerTestSuite) TestBrowseSandboxListFilesSuccess() {
 
 	instanceID := uint32(0)
 	sandboxFilesPaths := []string{"path1", "path2"}
+	sandboxFiles, expectedFiles := newTestSandboxFiles()
 	hostName := "peloton-test-host"
 	agentID := "peloton-test-agent"
 	frameworkID := "1234"
 	mesosAgentDir := "mesosAgentDir"
 
-	suite.handler.mesosAgentWorkDir = mesosAgentDir
+	suite.handler.sandboxLayouts = logmanager.NewSandboxLayoutResolver(
+		nil, mesosAgentDir, nil)
 
 	var events []*pod.PodEvent
 	event := &pod.PodEvent{
@@ -1906,6 +2971,7 @@ func (suite *TaskHandlerTestSuite) TestBrowseSandboxListFilesSuccess() {
 		Paths:               sandboxFilesPaths,
 		MesosMasterHostname: "master",
 		MesosMasterPort:     "5050",
+		Files:               expectedFiles,
 	}
 
 	gomock.InOrder(
@@ -1921,14 +2987,20 @@ func (suite *TaskHandlerTestSuite) TestBrowseSandboxListFilesSuccess() {
 		suite.mockedFrameworkInfoStore.EXPECT().
 			GetFrameworkID(gomock.Any(), _frameworkName).
 			Return(frameworkID, nil),
+		suite.mockedTaskStore.EXPECT().
+			GetTaskConfig(gomock.Any(), suite.testJobID, instanceID, gomock.Any()).
+			Return(&task.TaskConfig{}, nil, nil),
 		suite.mockedHostMgr.EXPECT().
 			GetMesosAgentInfo(gomock.Any(),
 				&hostsvc.GetMesosAgentInfoRequest{Hostname: hostName}).
 			Return(&hostsvc.GetMesosAgentInfoResponse{}, nil),
 		suite.mockedLogManager.EXPECT().
-			ListSandboxFilesPaths(mesosAgentDir, frameworkID, hostName,
-				"5051", agentID, req.GetTaskId()).
-			Return(sandboxFilesPaths, nil),
+			ListSandboxFiles(
+				(&logmanager.SandboxLayout{WorkDir: mesosAgentDir}).
+					WithExecutorID(testTaskID),
+				frameworkID, hostName,
+				"5051", agentID, req.GetTaskId(), false).
+			Return(sandboxFiles, nil),
 		suite.mockedHostMgr.EXPECT().
 			GetMesosMasterHostPort(gomock.Any(),
 				&hostsvc.MesosMasterHostPortRequest{}).
@@ -1950,6 +3022,7 @@ func (suite *TaskHandlerTestSuite) TestBrowseSandboxListFilesSuccessAgentIP() {
 
 	instanceID := uint32(0)
 	sandboxFilesPaths := []string{"path1", "path2"}
+	sandboxFiles, expectedFiles := newTestSandboxFiles()
 	hostName := "peloton-test-host"
 	agentIP := "1.2.3.4"
 	agentPort := "31000"
@@ -1958,7 +3031,8 @@ func (suite *TaskHandlerTestSuite) TestBrowseSandboxListFilesSuccessAgentIP() {
 	frameworkID := "1234"
 	mesosAgentDir := "mesosAgentDir"
 
-	suite.handler.mesosAgentWorkDir = mesosAgentDir
+	suite.handler.sandboxLayouts = logmanager.NewSandboxLayoutResolver(
+		nil, mesosAgentDir, nil)
 
 	var events []*pod.PodEvent
 	event := &pod.PodEvent{
@@ -1994,6 +3068,7 @@ func (suite *TaskHandlerTestSuite) TestBrowseSandboxListFilesSuccessAgentIP() {
 		Paths:               sandboxFilesPaths,
 		MesosMasterHostname: "master",
 		MesosMasterPort:     "5050",
+		Files:               expectedFiles,
 	}
 
 	agentInfos := make([]*mesos_master.Response_GetAgents_Agent, 1)
@@ -2017,15 +3092,21 @@ func (suite *TaskHandlerTestSuite) TestBrowseSandboxListFilesSuccessAgentIP() {
 		suite.mockedFrameworkInfoStore.EXPECT().
 			GetFrameworkID(gomock.Any(), _frameworkName).
 			Return(frameworkID, nil),
+		suite.mockedTaskStore.EXPECT().
+			GetTaskConfig(gomock.Any(), suite.testJobID, instanceID, gomock.Any()).
+			Return(&task.TaskConfig{}, nil, nil),
 		suite.mockedHostMgr.EXPECT().
 			GetMesosAgentInfo(gomock.Any(),
 				&hostsvc.GetMesosAgentInfoRequest{Hostname: hostName}).
 			Return(&hostsvc.GetMesosAgentInfoResponse{Agents: agentInfos},
 				nil),
 		suite.mockedLogManager.EXPECT().
-			ListSandboxFilesPaths(mesosAgentDir, frameworkID, agentIP,
-				agentPort, agentID, req.GetTaskId()).
-			Return(sandboxFilesPaths, nil),
+			ListSandboxFiles(
+				(&logmanager.SandboxLayout{WorkDir: mesosAgentDir}).
+					WithExecutorID(testTaskID),
+				frameworkID, agentIP,
+				agentPort, agentID, req.GetTaskId(), false).
+			Return(sandboxFiles, nil),
 		suite.mockedHostMgr.EXPECT().
 			GetMesosMasterHostPort(gomock.Any(),
 				&hostsvc.MesosMasterHostPortRequest{}).
@@ -2041,36 +3122,297 @@ func (suite *TaskHandlerTestSuite) TestBrowseSandboxListFilesSuccessAgentIP() {
 	suite.Equal(resp, res)
 }
 
-func (suite *TaskHandlerTestSuite) TestRefreshTask() {
-	runtimes := make(map[uint32]*task.RuntimeInfo)
-	for instID, taskInfo := range suite.taskInfos {
-		runtimes[instID] = taskInfo.GetRuntime()
-	}
+// TestGetSandboxLayoutScope tests the sandbox of the executor of a task
+// running in a nested container is the parent of the sandbox of the task
+func (suite *TaskHandlerTestSuite) TestGetSandboxLayoutScope() {
+	suite.handler.sandboxLayouts = logmanager.NewSandboxLayoutResolver(
+		nil, "mesosAgentDir", &logmanager.SandboxConfig{
+			NestedSandboxDir: "tasks/{task_id}",
+		})
+
+	executorID := util.CustomExecutorIDPrefix + testTaskID
+	suite.Equal((&logmanager.SandboxLayout{
+		WorkDir:          "mesosAgentDir",
+		NestedSandboxDir: "tasks/{task_id}",
+	}).WithExecutorID(executorID),
+		suite.handler.getSandboxLayout("host-0", "1.2.3.4", "5051",
+			executorID, task.SandboxScope_SANDBOX_SCOPE_TASK))
+	suite.Equal((&logmanager.SandboxLayout{
+		WorkDir: "mesosAgentDir",
+	}).WithExecutorID(executorID),
+		suite.handler.getSandboxLayout("host-0", "1.2.3.4", "5051",
+			executorID, task.SandboxScope_SANDBOX_SCOPE_EXECUTOR))
+}
 
-	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
-	suite.mockedJobStore.EXPECT().
-		GetJobConfig(gomock.Any(), suite.testJobID.GetValue()).
-		Return(suite.testJobConfig, &models.ConfigAddOn{}, nil)
-	suite.mockedTaskStore.EXPECT().
-		GetTaskRuntimesForJobByRange(gomock.Any(), suite.testJobID, &task.InstanceRange{
-			From: 0,
-			To:   suite.testJobConfig.GetInstanceCount(),
-		}).Return(runtimes, nil)
-	suite.mockedJobFactory.EXPECT().
-		AddJob(suite.testJobID).Return(suite.mockedCachedJob)
-	suite.mockedCachedJob.EXPECT().
-		ReplaceTasks(runtimes, true).Return(nil)
-	suite.mockedGoalStateDrive.EXPECT().
-		EnqueueTask(suite.testJobID, gomock.Any(), gomock.Any()).Return().Times(int(suite.testJobConfig.GetInstanceCount()))
-	suite.mockedCachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
-	suite.mockedGoalStateDrive.EXPECT().
-		JobRuntimeDuration(job.JobType_BATCH).
-		Return(1 * time.Second)
-	suite.mockedGoalStateDrive.EXPECT().
-		EnqueueJob(suite.testJobID, gomock.Any()).Return()
+// TestTailSandboxFile tests streaming a sandbox file of the previous run
+// of a task
+func (suite *TaskHandlerTestSuite) TestTailSandboxFile() {
+	instanceID := uint32(0)
+	hostName := "peloton-test-host"
+	agentID := "peloton-test-agent"
+	frameworkID := "1234"
+	mesosAgentDir := "mesosAgentDir"
 
-	var request = &task.RefreshRequest{
-		JobId: suite.testJobID,
+	suite.handler.sandboxLayouts = logmanager.NewSandboxLayoutResolver(
+		nil, mesosAgentDir, nil)
+
+	events := []*pod.PodEvent{
+		{
+			PodId: &v1alphapeloton.PodID{
+				Value: testTaskID,
+			},
+			Hostname:    hostName,
+			AgentId:     agentID,
+			ActualState: task.TaskState_FAILED.String(),
+		},
+	}
+
+	req := &task.TailSandboxFileRequest{
+		JobId:      suite.testJobID,
+		InstanceId: instanceID,
+		TaskId:     testTaskID,
+		Path:       "./logs/../stdout",
+		Offset:     -10,
+	}
+
+	stream := taskmocks.NewMockTaskManagerServiceTailSandboxFileYARPCServer(
+		suite.ctrl)
+	stream.EXPECT().Context().Return(context.Background())
+
+	gomock.InOrder(
+		suite.mockedJobFactory.EXPECT().GetJob(suite.testJobID).
+			Return(suite.mockedCachedJob),
+		suite.mockedCachedJob.EXPECT().GetConfig(gomock.Any()).
+			Return(
+				cachedtest.NewMockJobConfig(suite.ctrl, suite.testJobConfig),
+				nil),
+		suite.mockedTaskStore.EXPECT().
+			GetPodEvents(gomock.Any(), suite.testJobID.GetValue(), instanceID, testTaskID).
+			Return(events, nil),
+		suite.mockedFrameworkInfoStore.EXPECT().
+			GetFrameworkID(gomock.Any(), _frameworkName).
+			Return(frameworkID, nil),
+		suite.mockedTaskStore.EXPECT().
+			GetTaskConfig(gomock.Any(), suite.testJobID, instanceID, gomock.Any()).
+			Return(&task.TaskConfig{
+				Executor: &mesos.ExecutorInfo{
+					Type: mesos.ExecutorInfo_CUSTOM.Enum(),
+				},
+			}, nil, nil),
+		suite.mockedHostMgr.EXPECT().
+			GetMesosAgentInfo(gomock.Any(),
+				&hostsvc.GetMesosAgentInfoRequest{Hostname: hostName}).
+			Return(&hostsvc.GetMesosAgentInfoResponse{}, nil),
+		suite.mockedLogManager.EXPECT().
+			TailSandboxFile(gomock.Any(),
+				(&logmanager.SandboxLayout{WorkDir: mesosAgentDir}).
+					WithExecutorID(util.CustomExecutorIDPrefix+testTaskID),
+				frameworkID,
+				hostName, "5051", agentID, testTaskID, "stdout",
+				int64(-10), gomock.Any()).
+			DoAndReturn(func(
+				_ context.Context,
+				_, _, _, _, _, _, _ string,
+				_ int64,
+				send func(uint64, []byte) error) error {
+				return send(100, []byte("hello"))
+			}),
+		stream.EXPECT().
+			Send(&task.TailSandboxFileResponse{
+				Offset: 100,
+				Data:   []byte("hello"),
+			}).
+			Return(nil),
+	)
+
+	suite.NoError(suite.handler.TailSandboxFile(req, stream))
+}
+
+// TestTailSandboxFileInvalidPath tests tailing a file outside of the
+// sandbox
+func (suite *TaskHandlerTestSuite) TestTailSandboxFileInvalidPath() {
+	stream := taskmocks.NewMockTaskManagerServiceTailSandboxFileYARPCServer(
+		suite.ctrl)
+	stream.EXPECT().Context().Return(context.Background()).AnyTimes()
+
+	for _, path := range []string{"", "/etc/passwd", "../other/stdout"} {
+		err := suite.handler.TailSandboxFile(&task.TailSandboxFileRequest{
+			JobId: suite.testJobID,
+			Path:  path,
+		}, stream)
+		suite.True(yarpcerrors.IsInvalidArgument(err))
+	}
+}
+
+// TestTailSandboxFileNotRunning tests tailing a file of a task which has
+// not been placed
+func (suite *TaskHandlerTestSuite) TestTailSandboxFileNotRunning() {
+	singleTaskInfo := make(map[uint32]*task.TaskInfo)
+	singleTaskInfo[0] = suite.taskInfos[0]
+
+	stream := taskmocks.NewMockTaskManagerServiceTailSandboxFileYARPCServer(
+		suite.ctrl)
+	stream.EXPECT().Context().Return(context.Background())
+
+	gomock.InOrder(
+		suite.mockedJobFactory.EXPECT().
+			GetJob(suite.testJobID).Return(suite.mockedCachedJob),
+		suite.mockedCachedJob.EXPECT().
+			GetConfig(gomock.Any()).Return(cachedtest.NewMockJobConfig(suite.ctrl, suite.testJobConfig), nil),
+		suite.mockedTaskStore.EXPECT().
+			GetTaskForJob(gomock.Any(), suite.testJobID.GetValue(), uint32(0)).
+			Return(singleTaskInfo, nil),
+	)
+
+	err := suite.handler.TailSandboxFile(&task.TailSandboxFileRequest{
+		JobId: suite.testJobID,
+		Path:  "stdout",
+	}, stream)
+	suite.True(yarpcerrors.IsFailedPrecondition(err))
+}
+
+// TestDownloadSandboxFile tests downloading a sandbox file of the current
+// run of a task
+func (suite *TaskHandlerTestSuite) TestDownloadSandboxFile() {
+	frameworkID := "1234"
+	mesosAgentDir := "mesosAgentDir"
+	suite.handler.sandboxLayouts = logmanager.NewSandboxLayoutResolver(
+		nil, mesosAgentDir, nil)
+
+	singleTaskInfo := make(map[uint32]*task.TaskInfo)
+	singleTaskInfo[0] = suite.taskInfos[0]
+	singleTaskInfo[0].GetRuntime().Host = "host-0"
+	singleTaskInfo[0].GetRuntime().AgentID = &mesos.AgentID{
+		Value: util.PtrPrintf("host-agent-0"),
+	}
+	taskID := singleTaskInfo[0].GetRuntime().GetMesosTaskId().GetValue()
+
+	chunk := &logmanager.SandboxFileChunk{
+		Offset: 10,
+		Data:   []byte("hello"),
+		Size:   100,
+	}
+
+	gomock.InOrder(
+		suite.mockedJobFactory.EXPECT().
+			GetJob(suite.testJobID).Return(suite.mockedCachedJob),
+		suite.mockedCachedJob.EXPECT().
+			GetConfig(gomock.Any()).Return(cachedtest.NewMockJobConfig(suite.ctrl, suite.testJobConfig), nil),
+		suite.mockedTaskStore.EXPECT().
+			GetTaskForJob(gomock.Any(), suite.testJobID.GetValue(), uint32(0)).
+			Return(singleTaskInfo, nil),
+		suite.mockedFrameworkInfoStore.EXPECT().
+			GetFrameworkID(gomock.Any(), _frameworkName).
+			Return(frameworkID, nil),
+		suite.mockedHostMgr.EXPECT().
+			GetMesosAgentInfo(gomock.Any(),
+				&hostsvc.GetMesosAgentInfoRequest{Hostname: "host-0"}).
+			Return(&hostsvc.GetMesosAgentInfoResponse{}, nil),
+		suite.mockedLogManager.EXPECT().
+			ReadSandboxFile(gomock.Any(),
+				(&logmanager.SandboxLayout{WorkDir: mesosAgentDir}).
+					WithExecutorID(taskID),
+				frameworkID,
+				"host-0", "5051", "host-agent-0", taskID, "stderr",
+				int64(10), 5).
+			Return(chunk, nil),
+	)
+
+	resp, err := suite.handler.DownloadSandboxFile(
+		context.Background(),
+		&task.DownloadSandboxFileRequest{
+			JobId:  suite.testJobID,
+			Path:   "stderr",
+			Offset: 10,
+			Length: 5,
+		})
+	suite.NoError(err)
+	suite.Equal(&task.DownloadSandboxFileResponse{
+		Offset: 10,
+		Data:   []byte("hello"),
+		Size:   100,
+	}, resp)
+}
+
+// TestDownloadSandboxFileReadFailure tests failing to read a sandbox file
+// from the agent
+func (suite *TaskHandlerTestSuite) TestDownloadSandboxFileReadFailure() {
+	singleTaskInfo := make(map[uint32]*task.TaskInfo)
+	singleTaskInfo[0] = suite.taskInfos[0]
+	singleTaskInfo[0].GetRuntime().Host = "host-0"
+	singleTaskInfo[0].GetRuntime().AgentID = &mesos.AgentID{
+		Value: util.PtrPrintf("host-agent-0"),
+	}
+
+	gomock.InOrder(
+		suite.mockedJobFactory.EXPECT().
+			GetJob(suite.testJobID).Return(suite.mockedCachedJob),
+		suite.mockedCachedJob.EXPECT().
+			GetConfig(gomock.Any()).Return(cachedtest.NewMockJobConfig(suite.ctrl, suite.testJobConfig), nil),
+		suite.mockedTaskStore.EXPECT().
+			GetTaskForJob(gomock.Any(), suite.testJobID.GetValue(), uint32(0)).
+			Return(singleTaskInfo, nil),
+		suite.mockedFrameworkInfoStore.EXPECT().
+			GetFrameworkID(gomock.Any(), _frameworkName).
+			Return("1234", nil),
+		suite.mockedHostMgr.EXPECT().
+			GetMesosAgentInfo(gomock.Any(), gomock.Any()).
+			Return(nil, errors.New("hostmgr unavailable")),
+		suite.mockedLogManager.EXPECT().
+			ReadSandboxFile(gomock.Any(), gomock.Any(), gomock.Any(),
+				"host-0", "5051", gomock.Any(), gomock.Any(), "stdout",
+				int64(0), 0).
+			Return(nil, errors.New("agent unavailable")),
+	)
+
+	_, err := suite.handler.DownloadSandboxFile(
+		context.Background(),
+		&task.DownloadSandboxFileRequest{
+			JobId: suite.testJobID,
+			Path:  "stdout",
+		})
+	suite.True(yarpcerrors.IsInternal(err))
+}
+
+// TestDownloadSandboxFileInvalidPath tests downloading a file outside of
+// the sandbox
+func (suite *TaskHandlerTestSuite) TestDownloadSandboxFileInvalidPath() {
+	_, err := suite.handler.DownloadSandboxFile(
+		context.Background(),
+		&task.DownloadSandboxFileRequest{
+			JobId: suite.testJobID,
+			Path:  "../../slaves",
+		})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+func (suite *TaskHandlerTestSuite) TestRefreshTask() {
+	runtimes := make(map[uint32]*task.RuntimeInfo)
+	for instID, taskInfo := range suite.taskInfos {
+		runtimes[instID] = taskInfo.GetRuntime()
+	}
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.testJobID.GetValue()).
+		Return(suite.testJobConfig, &models.ConfigAddOn{}, nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTaskRuntimesForJobByRange(gomock.Any(), suite.testJobID, &task.InstanceRange{
+			From: 0,
+			To:   suite.testJobConfig.GetInstanceCount(),
+		}).Return(runtimes, nil)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		ReplaceTasks(runtimes, true).Return(nil)
+	suite.mockedGoalStateDrive.EXPECT().
+		EnqueueTask(suite.testJobID, gomock.Any(), gomock.Any()).Return().Times(int(suite.testJobConfig.GetInstanceCount()))
+	suite.mockedCachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
+	suite.mockedGoalStateDrive.EXPECT().
+		EnqueueJobWithDefaultDelay(suite.testJobID, job.JobType_BATCH)
+
+	var request = &task.RefreshRequest{
+		JobId: suite.testJobID,
 	}
 	_, err := suite.handler.Refresh(context.Background(), request)
 	suite.NoError(err)
@@ -2155,6 +3497,46 @@ func (suite *TaskHandlerTestSuite) TestListTask() {
 	suite.Equal(pendingTasks, uint32(0))
 }
 
+// TestListTaskByState tests listing only the tasks in the given states
+func (suite *TaskHandlerTestSuite) TestListTaskByState() {
+	runningTasks := uint32(testInstanceCount) / 2
+	pendingTasks := uint32(testInstanceCount) - runningTasks
+	taskInfos := suite.initTestTaskInfo(runningTasks, pendingTasks)
+
+	suite.mockedTaskStore.EXPECT().
+		GetTasksForJob(gomock.Any(), suite.testJobID).
+		Return(taskInfos, nil)
+
+	result, err := suite.handler.List(context.Background(), &task.ListRequest{
+		JobId:  suite.testJobID,
+		States: []task.TaskState{task.TaskState_RUNNING},
+	})
+	suite.NoError(err)
+	suite.Nil(result.GetNotFound())
+	suite.Len(result.GetResult().GetValue(), int(runningTasks))
+	for _, taskInfo := range result.GetResult().GetValue() {
+		suite.Equal(task.TaskState_RUNNING, taskInfo.GetRuntime().GetState())
+	}
+}
+
+// TestListTaskByStateNoMatch tests listing tasks with a state filter which
+// no task matches
+func (suite *TaskHandlerTestSuite) TestListTaskByStateNoMatch() {
+	taskInfos := suite.initTestTaskInfo(uint32(testInstanceCount), 0)
+
+	suite.mockedTaskStore.EXPECT().
+		GetTasksForJob(gomock.Any(), suite.testJobID).
+		Return(taskInfos, nil)
+
+	result, err := suite.handler.List(context.Background(), &task.ListRequest{
+		JobId:  suite.testJobID,
+		States: []task.TaskState{task.TaskState_FAILED},
+	})
+	suite.NoError(err)
+	suite.Nil(result.GetNotFound())
+	suite.Empty(result.GetResult().GetValue())
+}
+
 func (suite *TaskHandlerTestSuite) TestListTaskQueryByRange() {
 	runningTasks := uint32(testInstanceCount) / 2
 	pendingTasks := uint32(testInstanceCount) - runningTasks
@@ -2243,7 +3625,7 @@ func (suite *TaskHandlerTestSuite) TestQueryTask() {
 		GetRuntime(gomock.Any()).
 		Return(suite.testJobRuntime, nil)
 	suite.mockedTaskStore.EXPECT().
-		QueryTasks(gomock.Any(), suite.testJobID, nil).
+		QueryTasks(gomock.Any(), suite.testJobID, gomock.Any()).
 		Return(taskInfos, uint32(testInstanceCount), nil)
 	suite.mockedActiveRMTasks.EXPECT().
 		GetTask(gomock.Any()).
@@ -2293,7 +3675,7 @@ func (suite *TaskHandlerTestSuite) TestQueryTaskQueryTaskErr() {
 		GetRuntime(gomock.Any()).
 		Return(suite.testJobRuntime, nil)
 	suite.mockedTaskStore.EXPECT().
-		QueryTasks(gomock.Any(), suite.testJobID, nil).
+		QueryTasks(gomock.Any(), suite.testJobID, gomock.Any()).
 		Return(taskInfos, uint32(testInstanceCount), errors.New("test error"))
 	_, err := suite.handler.Query(context.Background(), &task.QueryRequest{
 		JobId: suite.testJobID,
@@ -2301,6 +3683,71 @@ func (suite *TaskHandlerTestSuite) TestQueryTaskQueryTaskErr() {
 	suite.NoError(err)
 }
 
+// TestQueryTaskInvalidRegex tests that an invalid regex in the query spec
+// is returned as an invalid argument error
+func (suite *TaskHandlerTestSuite) TestQueryTaskInvalidRegex() {
+	spec := &task.QuerySpec{HostRegex: "host["}
+
+	suite.mockedJobFactory.EXPECT().
+		GetJob(suite.testJobID).
+		Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(suite.testJobRuntime, nil)
+	suite.mockedTaskStore.EXPECT().
+		QueryTasks(gomock.Any(), suite.testJobID, gomock.Any()).
+		Return(nil, uint32(0), yarpcerrors.InvalidArgumentErrorf("invalid host regex"))
+	_, err := suite.handler.Query(context.Background(), &task.QueryRequest{
+		JobId: suite.testJobID,
+		Spec:  spec,
+	})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestQueryTaskPagination tests the default page size and sort order are
+// set on the queries of tasks, and the queries exceeding the maximum page
+// size are rejected
+func (suite *TaskHandlerTestSuite) TestQueryTaskPagination() {
+	spec := &task.QuerySpec{
+		Pagination: &query.PaginationSpec{
+			Limit: 10,
+			OrderBy: []*query.OrderBy{
+				{
+					Order:    query.OrderBy_ASC,
+					Property: &query.PropertyPath{Value: "instanceId"},
+				},
+			},
+		},
+	}
+
+	suite.mockedJobFactory.EXPECT().
+		GetJob(suite.testJobID).
+		Return(suite.mockedCachedJob).
+		Times(2)
+	suite.mockedCachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(suite.testJobRuntime, nil).
+		Times(2)
+	suite.mockedTaskStore.EXPECT().
+		QueryTasks(gomock.Any(), suite.testJobID, spec).
+		Return(nil, uint32(0), nil)
+
+	resp, err := suite.handler.Query(context.Background(), &task.QueryRequest{
+		JobId: suite.testJobID,
+	})
+	suite.NoError(err)
+	suite.Equal(uint32(10), resp.GetPagination().GetLimit())
+
+	suite.handler.pagination.MaxTaskQueryLimit = 100
+	_, err = suite.handler.Query(context.Background(), &task.QueryRequest{
+		JobId: suite.testJobID,
+		Spec: &task.QuerySpec{
+			Pagination: &query.PaginationSpec{Limit: 101},
+		},
+	})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
 func (suite *TaskHandlerTestSuite) TestGetCache_JobNotFound() {
 	instanceID := uint32(0)
 
@@ -2356,12 +3803,87 @@ func (suite *TaskHandlerTestSuite) TestGetCache_SUCCESS() {
 		GetTask(instanceID).Return(suite.mockedTask)
 	suite.mockedTask.EXPECT().
 		GetRuntime(gomock.Any()).Return(suite.taskInfos[instanceID].Runtime, nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTaskConfig(
+			gomock.Any(),
+			suite.testJobID,
+			instanceID,
+			suite.taskInfos[instanceID].GetRuntime().GetConfigVersion()).
+		Return(suite.taskInfos[instanceID].GetConfig(), nil, nil)
+	suite.mockedTask.EXPECT().
+		GetLastRuntimeUpdateTime().Return(time.Time{})
 	resp, err := suite.handler.GetCache(context.Background(), &task.GetCacheRequest{
 		JobId:      suite.testJobID,
 		InstanceId: instanceID,
 	})
 	suite.NoError(err)
 	suite.Equal(resp.Runtime.State, task.TaskState_RUNNING)
+	suite.Equal(suite.taskInfos[instanceID].GetConfig(), resp.GetConfig())
+	suite.Empty(resp.GetLastUpdateTime())
+}
+
+// TestGetCacheConfigAndHealth tests the task config, labels, health state
+// and last update time of the cached task are returned
+func (suite *TaskHandlerTestSuite) TestGetCacheConfigAndHealth() {
+	instanceID := uint32(0)
+	updateTime := time.Date(2019, 5, 1, 10, 0, 0, 0, time.UTC)
+	runtime := &task.RuntimeInfo{
+		State:         task.TaskState_RUNNING,
+		Healthy:       task.HealthState_HEALTHY,
+		ConfigVersion: 3,
+	}
+	taskConfig := &task.TaskConfig{
+		Name: "test-task",
+		Labels: []*peloton.Label{
+			{Key: "key", Value: "value"},
+		},
+	}
+
+	suite.mockedJobFactory.EXPECT().
+		GetJob(gomock.Any()).Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		GetTask(instanceID).Return(suite.mockedTask)
+	suite.mockedTask.EXPECT().
+		GetRuntime(gomock.Any()).Return(runtime, nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTaskConfig(gomock.Any(), suite.testJobID, instanceID, uint64(3)).
+		Return(taskConfig, nil, nil)
+	suite.mockedTask.EXPECT().
+		GetLastRuntimeUpdateTime().Return(updateTime)
+
+	resp, err := suite.handler.GetCache(context.Background(), &task.GetCacheRequest{
+		JobId:      suite.testJobID,
+		InstanceId: instanceID,
+	})
+	suite.NoError(err)
+	suite.Equal(runtime, resp.GetRuntime())
+	suite.Equal(taskConfig, resp.GetConfig())
+	suite.Equal(taskConfig.GetLabels(), resp.GetLabels())
+	suite.Equal(task.HealthState_HEALTHY, resp.GetHealthState())
+	suite.Equal("2019-05-01T10:00:00Z", resp.GetLastUpdateTime())
+}
+
+// TestGetCacheFailToLoadConfig tests GetCache fails if the task config
+// of the cached runtime cannot be loaded
+func (suite *TaskHandlerTestSuite) TestGetCacheFailToLoadConfig() {
+	instanceID := uint32(0)
+
+	suite.mockedJobFactory.EXPECT().
+		GetJob(gomock.Any()).Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		GetTask(instanceID).Return(suite.mockedTask)
+	suite.mockedTask.EXPECT().
+		GetRuntime(gomock.Any()).Return(suite.taskInfos[instanceID].Runtime, nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTaskConfig(gomock.Any(), suite.testJobID, instanceID, gomock.Any()).
+		Return(nil, nil, fmt.Errorf("test err"))
+
+	_, err := suite.handler.GetCache(context.Background(), &task.GetCacheRequest{
+		JobId:      suite.testJobID,
+		InstanceId: instanceID,
+	})
+	suite.Error(err)
+	suite.True(yarpcerrors.IsInternal(err))
 }
 
 // TestRestartNonLeader tests restart call on a non leader jobmgr
@@ -2421,7 +3943,7 @@ func (suite *TaskHandlerTestSuite) TestRestartAllTasks() {
 			}).
 			Return(nil),
 		suite.mockedGoalStateDrive.EXPECT().
-			EnqueueTask(suite.testJobID, gomock.Any(), gomock.Any()).Return().AnyTimes(),
+			EnqueueTaskWithPriority(suite.testJobID, gomock.Any(), gomock.Any(), commongoalstate.PriorityHigh).Return().AnyTimes(),
 	)
 
 	var request = &task.RestartRequest{
@@ -2435,3 +3957,1905 @@ func (suite *TaskHandlerTestSuite) TestRestartAllTasks() {
 	suite.NoError(err)
 	suite.NotNil(resp)
 }
+
+// expectRestartWaves sets the expectations of restarting the tasks in
+// waves, the instances of each wave running their new run with the given
+// health right away. Returns the instances patched by each wave.
+func (suite *TaskHandlerTestSuite) expectRestartWaves(
+	taskInfos map[uint32]*task.TaskInfo,
+	healthy task.HealthState,
+) *[][]uint32 {
+	suite.handler.restart = RestartConfig{
+		WaveCheckInterval: time.Millisecond,
+		WaveTimeout:       time.Second,
+	}
+
+	var waves [][]uint32
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		ID().Return(suite.testJobID).AnyTimes()
+	suite.mockedTaskStore.EXPECT().
+		GetTasksForJob(gomock.Any(), suite.testJobID).Return(taskInfos, nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTasksForJobByRange(gomock.Any(), suite.testJobID, gomock.Any()).
+		DoAndReturn(func(
+			ctx context.Context,
+			jobID *peloton.JobID,
+			instanceRange *task.InstanceRange,
+		) (map[uint32]*task.TaskInfo, error) {
+			result := make(map[uint32]*task.TaskInfo)
+			for i := instanceRange.GetFrom(); i < instanceRange.GetTo(); i++ {
+				result[i] = taskInfos[i]
+			}
+			return result, nil
+		}).
+		AnyTimes()
+	suite.mockedCachedJob.EXPECT().
+		PatchTasks(gomock.Any(), gomock.Any()).
+		Do(func(ctx context.Context, runtimeDiffs map[uint32]jobmgrcommon.RuntimeDiff) {
+			var wave []uint32
+			for instanceID := range runtimeDiffs {
+				wave = append(wave, instanceID)
+			}
+			sortInstanceIDs(wave)
+			waves = append(waves, wave)
+		}).
+		Return(nil).
+		AnyTimes()
+	suite.mockedGoalStateDrive.EXPECT().
+		EnqueueTaskWithPriority(suite.testJobID, gomock.Any(), gomock.Any(), commongoalstate.PriorityHigh).
+		Return().
+		AnyTimes()
+
+	mesosTaskID := &mesos.TaskID{Value: &[]string{testTaskID}[0]}
+	suite.mockedCachedJob.EXPECT().
+		GetTask(gomock.Any()).Return(suite.mockedCachedTask).AnyTimes()
+	suite.mockedCachedTask.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(&task.RuntimeInfo{
+			MesosTaskId:        mesosTaskID,
+			DesiredMesosTaskId: mesosTaskID,
+			State:              task.TaskState_RUNNING,
+			Healthy:            healthy,
+		}, nil).
+		AnyTimes()
+	return &waves
+}
+
+// TestRestartTasksInBatches tests restarting tasks in batches, each batch
+// being persisted once the previous one is healthy
+func (suite *TaskHandlerTestSuite) TestRestartTasksInBatches() {
+	var taskInfos = make(map[uint32]*task.TaskInfo)
+	for i := uint32(0); i < testInstanceCount; i++ {
+		taskInfos[i] = suite.createTestTaskInfo(
+			task.TaskState_RUNNING, i)
+	}
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true).AnyTimes()
+	waves := suite.expectRestartWaves(taskInfos, task.HealthState_HEALTHY)
+
+	resp, err := suite.handler.Restart(
+		context.Background(),
+		&task.RestartRequest{
+			JobId:     suite.testJobID,
+			BatchSize: 2,
+		},
+	)
+	suite.NoError(err)
+	suite.NotEmpty(resp.GetOperationId())
+
+	status := suite.waitForOperation(resp.GetOperationId())
+	suite.Equal(task.OperationState_OPERATION_STATE_SUCCEEDED, status.GetState())
+	suite.Equal(uint32(testInstanceCount), status.GetProcessedInstances())
+	suite.Equal([][]uint32{{0, 1}, {2, 3}}, *waves)
+}
+
+// TestRestartTasksInBatchesUnhealthy tests that the remaining batches of a
+// restart fail when the instances of a batch are not healthy in time
+func (suite *TaskHandlerTestSuite) TestRestartTasksInBatchesUnhealthy() {
+	var taskInfos = make(map[uint32]*task.TaskInfo)
+	for i := uint32(0); i < testInstanceCount; i++ {
+		taskInfos[i] = suite.createTestTaskInfo(
+			task.TaskState_RUNNING, i)
+	}
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true).AnyTimes()
+	waves := suite.expectRestartWaves(taskInfos, task.HealthState_UNHEALTHY)
+	suite.handler.restart.WaveTimeout = 10 * time.Millisecond
+
+	resp, err := suite.handler.Restart(
+		context.Background(),
+		&task.RestartRequest{
+			JobId:     suite.testJobID,
+			BatchSize: 2,
+		},
+	)
+	suite.NoError(err)
+
+	status := suite.waitForOperation(resp.GetOperationId())
+	suite.Equal(task.OperationState_OPERATION_STATE_FAILED, status.GetState())
+	suite.Equal(uint32(testInstanceCount), status.GetProcessedInstances())
+	suite.Equal([][]uint32{{0, 1}}, *waves)
+	for _, outcome := range status.GetOutcomes() {
+		suite.Equal(outcome.GetInstanceId() < 2, outcome.GetSucceeded())
+	}
+}
+
+// TestRestartTasksInBatchesNotLeader tests that the remaining batches of a
+// restart fail when the job manager loses leadership
+func (suite *TaskHandlerTestSuite) TestRestartTasksInBatchesNotLeader() {
+	var taskInfos = make(map[uint32]*task.TaskInfo)
+	for i := uint32(0); i < testInstanceCount; i++ {
+		taskInfos[i] = suite.createTestTaskInfo(
+			task.TaskState_RUNNING, i)
+	}
+	leader := atomic.NewBool(true)
+	suite.mockedCandidate.EXPECT().
+		IsLeader().
+		DoAndReturn(leader.Load).
+		AnyTimes()
+	waves := suite.expectRestartWaves(taskInfos, task.HealthState_UNHEALTHY)
+	suite.handler.restart.WaveTimeout = time.Minute
+
+	resp, err := suite.handler.Restart(
+		context.Background(),
+		&task.RestartRequest{
+			JobId:     suite.testJobID,
+			BatchSize: 2,
+		},
+	)
+	suite.NoError(err)
+	leader.Store(false)
+
+	// the operation status is not available on a non-leader
+	op := suite.handler.operations.Get(resp.GetOperationId())
+	suite.NotNil(op)
+	status := operationStatus(op)
+	for i := 0; i < 100 &&
+		status.GetState() == task.OperationState_OPERATION_STATE_RUNNING; i++ {
+		time.Sleep(10 * time.Millisecond)
+		status = operationStatus(op)
+	}
+	suite.Equal(task.OperationState_OPERATION_STATE_FAILED, status.GetState())
+	suite.Equal([][]uint32{{0, 1}}, *waves)
+	for _, outcome := range status.GetOutcomes() {
+		if outcome.GetInstanceId() >= 2 {
+			suite.Equal(errRestartNotLeader.Error(), outcome.GetMessage())
+		}
+	}
+}
+
+// TestRestartTasksPerFailureDomain tests restarting tasks with a limit on
+// the number of instances restarted at the same time on each rack
+func (suite *TaskHandlerTestSuite) TestRestartTasksPerFailureDomain() {
+	rackAttribute := _rackAttribute
+	hostRacks := map[string]string{"host0": "rack0", "host1": "rack1"}
+	var agents []*mesos_master.Response_GetAgents_Agent
+	for host, rack := range hostRacks {
+		hostname, rackName := host, rack
+		agents = append(agents, &mesos_master.Response_GetAgents_Agent{
+			AgentInfo: &mesos.AgentInfo{
+				Hostname: &hostname,
+				Attributes: []*mesos.Attribute{{
+					Name: &rackAttribute,
+					Type: mesos.Value_TEXT.Enum(),
+					Text: &mesos.Value_Text{Value: &rackName},
+				}},
+			},
+		})
+	}
+
+	// instances 0 and 1 are on rack0, 2 and 3 on rack1
+	var taskInfos = make(map[uint32]*task.TaskInfo)
+	for i := uint32(0); i < testInstanceCount; i++ {
+		taskInfos[i] = suite.createTestTaskInfo(
+			task.TaskState_RUNNING, i)
+		taskInfos[i].Runtime.Host = fmt.Sprintf("host%d", i/2)
+	}
+
+	suite.mockedHostMgr.EXPECT().
+		GetMesosAgentInfo(gomock.Any(), &hostsvc.GetMesosAgentInfoRequest{}).
+		Return(&hostsvc.GetMesosAgentInfoResponse{Agents: agents}, nil)
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true).AnyTimes()
+	waves := suite.expectRestartWaves(taskInfos, task.HealthState_HEALTHY)
+
+	start := time.Now()
+	resp, err := suite.handler.Restart(
+		context.Background(),
+		&task.RestartRequest{
+			JobId:                        suite.testJobID,
+			BatchDelaySeconds:            1,
+			MaxInstancesPerFailureDomain: 1,
+			FailureDomain:                task.RestartRequest_FAILURE_DOMAIN_RACK,
+		},
+	)
+	suite.NoError(err)
+	suite.NotEmpty(resp.GetOperationId())
+
+	status := suite.waitForOperation(resp.GetOperationId())
+	suite.Equal(task.OperationState_OPERATION_STATE_SUCCEEDED, status.GetState())
+	suite.Equal([][]uint32{{0, 2}, {1, 3}}, *waves)
+	// the second wave is restarted after the batch delay
+	suite.True(time.Since(start) >= time.Second)
+}
+
+// TestRestartTasksPerFailureDomainWithoutDelay tests restarting tasks with
+// a limit per failure domain fails without a batch delay
+func (suite *TaskHandlerTestSuite) TestRestartTasksPerFailureDomainWithoutDelay() {
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+
+	_, err := suite.handler.Restart(
+		context.Background(),
+		&task.RestartRequest{
+			JobId:                        suite.testJobID,
+			MaxInstancesPerFailureDomain: 1,
+		},
+	)
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestGetRestartWaves tests assigning the restarted instances to waves
+func (suite *TaskHandlerTestSuite) TestGetRestartWaves() {
+	instanceIDs := []uint32{0, 1, 2, 3, 4, 5}
+	domains := map[uint32]string{
+		0: "host0",
+		1: "host0",
+		2: "host0",
+		3: "host1",
+		4: "host1",
+	}
+
+	tt := []struct {
+		batchSize    uint32
+		maxPerDomain uint32
+		expected     []uint32
+	}{
+		{0, 0, []uint32{0, 0, 0, 0, 0, 0}},
+		{2, 0, []uint32{0, 0, 1, 1, 2, 2}},
+		{0, 1, []uint32{0, 1, 2, 0, 1, 0}},
+		{2, 1, []uint32{0, 1, 2, 0, 1, 2}},
+		{3, 2, []uint32{0, 0, 1, 0, 1, 1}},
+	}
+
+	for _, test := range tt {
+		suite.Equal(test.expected, getRestartWaves(
+			instanceIDs, domains, test.batchSize, test.maxPerDomain))
+	}
+}
+
+// TestGetHostFailureImpact tests getting the instances affected by a rack
+// and a host going down
+func (suite *TaskHandlerTestSuite) TestGetHostFailureImpact() {
+	rackAttribute := _rackAttribute
+	rack := "rack1"
+	rackHost := "host1"
+	otherRackHost := "host4"
+	otherRack := "rack2"
+	mesosTaskIDs := []string{
+		fmt.Sprintf("%s-0-1", testJob),
+		fmt.Sprintf("%s-1-2", testJob),
+		fmt.Sprintf("%s-2-1", testJob),
+	}
+	prevMesosTaskID := fmt.Sprintf("%s-1-1", testJob)
+
+	suite.testJobConfig.SLA = &job.SlaConfig{
+		MaximumUnavailableInstances: 1,
+	}
+
+	agents := []*mesos_master.Response_GetAgents_Agent{
+		{
+			AgentInfo: &mesos.AgentInfo{
+				Hostname: &rackHost,
+				Attributes: []*mesos.Attribute{{
+					Name: &rackAttribute,
+					Type: mesos.Value_TEXT.Enum(),
+					Text: &mesos.Value_Text{Value: &rack},
+				}},
+			},
+		},
+		{
+			AgentInfo: &mesos.AgentInfo{
+				Hostname: &otherRackHost,
+				Attributes: []*mesos.Attribute{{
+					Name: &rackAttribute,
+					Type: mesos.Value_TEXT.Enum(),
+					Text: &mesos.Value_Text{Value: &otherRack},
+				}},
+			},
+		},
+	}
+
+	runtimes := []*task.RuntimeInfo{
+		// still placed on the rack which went down
+		{
+			MesosTaskId: &mesos.TaskID{Value: &mesosTaskIDs[0]},
+			State:       task.TaskState_RUNNING,
+			Host:        rackHost,
+		},
+		// replaced after the host went down
+		{
+			MesosTaskId:     &mesos.TaskID{Value: &mesosTaskIDs[1]},
+			PrevMesosTaskId: &mesos.TaskID{Value: &prevMesosTaskID},
+			State:           task.TaskState_RUNNING,
+			Host:            "host3",
+			Revision: &peloton.ChangeLog{
+				UpdatedAt: uint64(time.Now().UnixNano()),
+			},
+		},
+		// not affected
+		{
+			MesosTaskId: &mesos.TaskID{Value: &mesosTaskIDs[2]},
+			State:       task.TaskState_RUNNING,
+			Host:        "host3",
+		},
+	}
+	cachedTasks := make(map[uint32]cached.Task)
+	for i, runtime := range runtimes {
+		cachedTask := cachedmocks.NewMockTask(suite.ctrl)
+		cachedTask.EXPECT().GetRuntime(gomock.Any()).Return(runtime, nil)
+		cachedTasks[uint32(i)] = cachedTask
+	}
+
+	suite.mockedHostMgr.EXPECT().
+		GetMesosAgentInfo(gomock.Any(), &hostsvc.GetMesosAgentInfoRequest{}).
+		Return(&hostsvc.GetMesosAgentInfoResponse{Agents: agents}, nil)
+	suite.mockedJobFactory.EXPECT().
+		GetAllJobs().
+		Return(map[string]cached.Job{testJob: suite.mockedCachedJob})
+	suite.mockedCachedJob.EXPECT().
+		ID().
+		Return(suite.testJobID).
+		AnyTimes()
+	suite.mockedCachedJob.EXPECT().
+		GetAllTasks().
+		Return(cachedTasks)
+	suite.mockedTaskStore.EXPECT().
+		GetPodEvents(gomock.Any(), testJob, uint32(1), prevMesosTaskID).
+		Return([]*pod.PodEvent{
+			{
+				ActualState: task.TaskState_LOST.String(),
+				Hostname:    "host2",
+			},
+		}, nil)
+	suite.mockedCachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(cachedtest.NewMockJobConfig(suite.ctrl, suite.testJobConfig), nil)
+
+	resp, err := suite.handler.GetHostFailureImpact(
+		context.Background(),
+		&task.GetHostFailureImpactRequest{
+			Hostnames: []string{"host2"},
+			Rack:      rack,
+		})
+	suite.NoError(err)
+	suite.Equal([]string{"host1", "host2"}, resp.GetHostnames())
+	suite.Equal(uint32(2), resp.GetAffectedInstances())
+	suite.Equal(uint32(1), resp.GetRecoveredInstances())
+
+	suite.Len(resp.GetJobs(), 1)
+	affectedJob := resp.GetJobs()[0]
+	suite.Equal(testJob, affectedJob.GetJobId().GetValue())
+	suite.Equal(uint32(testInstanceCount), affectedJob.GetInstanceCount())
+	suite.Equal(uint32(1), affectedJob.GetUnavailableInstances())
+	suite.Equal(uint32(1), affectedJob.GetMaximumUnavailableInstances())
+	suite.False(affectedJob.GetSlaViolated())
+
+	suite.Len(affectedJob.GetInstances(), 2)
+	suite.Equal(uint32(0), affectedJob.GetInstances()[0].GetInstanceId())
+	suite.Equal(rackHost, affectedJob.GetInstances()[0].GetHostname())
+	suite.False(affectedJob.GetInstances()[0].GetRecovered())
+	suite.Equal(uint32(1), affectedJob.GetInstances()[1].GetInstanceId())
+	suite.Equal("host2", affectedJob.GetInstances()[1].GetHostname())
+	suite.Equal(prevMesosTaskID, affectedJob.GetInstances()[1].GetTaskId().GetValue())
+	suite.Equal("host3", affectedJob.GetInstances()[1].GetCurrentHostname())
+	suite.True(affectedJob.GetInstances()[1].GetRecovered())
+}
+
+// TestGetHostFailureImpactNoHosts tests getting the host failure impact
+// without any host
+func (suite *TaskHandlerTestSuite) TestGetHostFailureImpactNoHosts() {
+	_, err := suite.handler.GetHostFailureImpact(
+		context.Background(),
+		&task.GetHostFailureImpactRequest{})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestGetHostFailureImpactHostMgrFailure tests getting the host failure
+// impact of a rack when host manager fails
+func (suite *TaskHandlerTestSuite) TestGetHostFailureImpactHostMgrFailure() {
+	suite.mockedHostMgr.EXPECT().
+		GetMesosAgentInfo(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("test error"))
+
+	_, err := suite.handler.GetHostFailureImpact(
+		context.Background(),
+		&task.GetHostFailureImpactRequest{Rack: "rack1"})
+	suite.True(yarpcerrors.IsInternal(err))
+}
+
+// TestGetDrainHeadroom tests getting the SLA headroom of a job with
+// instances running on the hosts to be drained
+func (suite *TaskHandlerTestSuite) TestGetDrainHeadroom() {
+	suite.testJobConfig.SLA = &job.SlaConfig{
+		MaximumUnavailableInstances: 2,
+	}
+
+	runtimes := []*task.RuntimeInfo{
+		// running on the hosts to be drained
+		{
+			State:     task.TaskState_RUNNING,
+			GoalState: task.TaskState_RUNNING,
+			Host:      "host1",
+		},
+		{
+			State:     task.TaskState_RUNNING,
+			GoalState: task.TaskState_RUNNING,
+			Host:      "host2",
+		},
+		// already unavailable
+		{
+			State:     task.TaskState_PENDING,
+			GoalState: task.TaskState_RUNNING,
+		},
+		// being killed, which does not count against the SLA
+		{
+			State:     task.TaskState_RUNNING,
+			GoalState: task.TaskState_KILLED,
+			Host:      "host1",
+		},
+	}
+	cachedTasks := make(map[uint32]cached.Task)
+	for i, runtime := range runtimes {
+		cachedTask := cachedmocks.NewMockTask(suite.ctrl)
+		cachedTask.EXPECT().GetRuntime(gomock.Any()).Return(runtime, nil)
+		cachedTasks[uint32(i)] = cachedTask
+	}
+
+	completedJobID := &peloton.JobID{Value: uuid.New()}
+	suite.mockedResmgrClient.EXPECT().
+		GetTasksByHosts(gomock.Any(), &resmgrsvc.GetTasksByHostsRequest{
+			Hostnames: []string{"host1", "host2"},
+		}).
+		Return(&resmgrsvc.GetTasksByHostsResponse{
+			HostTasksMap: map[string]*resmgrsvc.TaskList{
+				"host1": {
+					Tasks: []*resmgr.Task{
+						{JobId: suite.testJobID},
+						{JobId: suite.testJobID},
+					},
+				},
+				"host2": {
+					Tasks: []*resmgr.Task{
+						{JobId: suite.testJobID},
+						{JobId: completedJobID},
+					},
+				},
+			},
+		}, nil)
+	suite.mockedJobFactory.EXPECT().
+		GetJob(suite.testJobID).
+		Return(suite.mockedCachedJob)
+	suite.mockedJobFactory.EXPECT().
+		GetJob(completedJobID).
+		Return(nil)
+	suite.mockedCachedJob.EXPECT().
+		ID().
+		Return(suite.testJobID).
+		AnyTimes()
+	suite.mockedCachedJob.EXPECT().
+		GetAllTasks().
+		Return(cachedTasks)
+	suite.mockedCachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(cachedtest.NewMockJobConfig(suite.ctrl, suite.testJobConfig), nil)
+
+	resp, err := suite.handler.GetDrainHeadroom(
+		context.Background(),
+		&task.GetDrainHeadroomRequest{
+			Hostnames: []string{"host1", "host2"},
+		})
+	suite.NoError(err)
+	suite.False(resp.GetSafe())
+
+	suite.Len(resp.GetJobs(), 1)
+	headroom := resp.GetJobs()[0]
+	suite.Equal(testJob, headroom.GetJobId().GetValue())
+	suite.Equal(uint32(testInstanceCount), headroom.GetInstanceCount())
+	suite.Equal(uint32(2), headroom.GetDrainedInstances())
+	suite.Equal(uint32(1), headroom.GetUnavailableInstances())
+	suite.Equal(uint32(2), headroom.GetMaximumUnavailableInstances())
+	suite.Equal(int32(-1), headroom.GetHeadroom())
+	suite.True(headroom.GetSlaViolated())
+}
+
+// TestGetDrainHeadroomResmgrFail tests failing to get the drain headroom
+// when the tasks on the hosts can not be got from resmgr
+func (suite *TaskHandlerTestSuite) TestGetDrainHeadroomResmgrFail() {
+	suite.mockedResmgrClient.EXPECT().
+		GetTasksByHosts(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("resmgr unavailable"))
+	_, err := suite.handler.GetDrainHeadroom(
+		context.Background(),
+		&task.GetDrainHeadroomRequest{Hostnames: []string{"host1"}})
+	suite.Error(err)
+
+	suite.mockedResmgrClient.EXPECT().
+		GetTasksByHosts(gomock.Any(), gomock.Any()).
+		Return(&resmgrsvc.GetTasksByHostsResponse{
+			Error: &resmgrsvc.GetTasksByHostsResponse_Error{
+				Message: "failed",
+			},
+		}, nil)
+	_, err = suite.handler.GetDrainHeadroom(
+		context.Background(),
+		&task.GetDrainHeadroomRequest{Hostnames: []string{"host1"}})
+	suite.True(yarpcerrors.IsInternal(err))
+}
+
+// TestGetDrainHeadroomNoHosts tests getting the drain headroom without
+// any host
+func (suite *TaskHandlerTestSuite) TestGetDrainHeadroomNoHosts() {
+	_, err := suite.handler.GetDrainHeadroom(
+		context.Background(),
+		&task.GetDrainHeadroomRequest{})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestGetColocation tests getting the hosts and racks shared by the
+// instances of a job and of another job
+func (suite *TaskHandlerTestSuite) TestGetColocation() {
+	rackAttribute := _rackAttribute
+	hostRacks := map[string]string{
+		"host1": "rack1",
+		"host2": "rack1",
+		"host3": "rack2",
+	}
+	var agents []*mesos_master.Response_GetAgents_Agent
+	for host, rack := range hostRacks {
+		host, rack := host, rack
+		agents = append(agents, &mesos_master.Response_GetAgents_Agent{
+			AgentInfo: &mesos.AgentInfo{
+				Hostname: &host,
+				Attributes: []*mesos.Attribute{{
+					Name: &rackAttribute,
+					Type: mesos.Value_TEXT.Enum(),
+					Text: &mesos.Value_Text{Value: &rack},
+				}},
+			},
+		})
+	}
+
+	for instanceID, host := range []string{"host1", "host1", "host2", "host3"} {
+		suite.taskInfos[uint32(instanceID)].Runtime.Host = host
+	}
+	// terminal instances are not considered
+	suite.taskInfos[2].Runtime.State = task.TaskState_KILLED
+
+	otherJobID := &peloton.JobID{Value: uuid.New()}
+	otherTaskInfo := suite.createTestTaskInfo(task.TaskState_RUNNING, 0)
+	otherTaskInfo.Runtime.Host = "host3"
+
+	suite.mockedTaskStore.EXPECT().
+		GetTasksForJob(gomock.Any(), suite.testJobID).
+		Return(suite.taskInfos, nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTasksForJob(gomock.Any(), otherJobID).
+		Return(map[uint32]*task.TaskInfo{0: otherTaskInfo}, nil)
+	suite.mockedHostMgr.EXPECT().
+		GetMesosAgentInfo(gomock.Any(), &hostsvc.GetMesosAgentInfoRequest{}).
+		Return(&hostsvc.GetMesosAgentInfoResponse{Agents: agents}, nil)
+
+	resp, err := suite.handler.GetColocation(
+		context.Background(),
+		&task.GetColocationRequest{
+			JobId:      suite.testJobID,
+			OtherJobId: otherJobID,
+		})
+	suite.NoError(err)
+
+	suite.Equal([]*task.ColocationGroup{
+		{
+			Name:        "host1",
+			InstanceIds: []uint32{0, 1},
+		},
+		{
+			Name:                "host3",
+			InstanceIds:         []uint32{3},
+			OtherJobInstanceIds: []uint32{0},
+		},
+	}, resp.GetHosts())
+	suite.Equal([]*task.ColocationGroup{
+		{
+			Name:        "rack1",
+			InstanceIds: []uint32{0, 1},
+		},
+		{
+			Name:                "rack2",
+			InstanceIds:         []uint32{3},
+			OtherJobInstanceIds: []uint32{0},
+		},
+	}, resp.GetRacks())
+}
+
+// TestGetColocationJobNotFound tests getting the co-location of a job
+// which does not exist
+func (suite *TaskHandlerTestSuite) TestGetColocationJobNotFound() {
+	suite.mockedTaskStore.EXPECT().
+		GetTasksForJob(gomock.Any(), suite.testJobID).
+		Return(map[uint32]*task.TaskInfo{}, nil)
+
+	_, err := suite.handler.GetColocation(
+		context.Background(),
+		&task.GetColocationRequest{JobId: suite.testJobID})
+	suite.True(yarpcerrors.IsNotFound(err))
+}
+
+// TestGetColocationNoJobID tests getting the co-location without a job
+func (suite *TaskHandlerTestSuite) TestGetColocationNoJobID() {
+	_, err := suite.handler.GetColocation(
+		context.Background(),
+		&task.GetColocationRequest{})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestGetTaskStateSummary tests getting the number of tasks of a job in
+// each state and the reasons of the pending tasks
+func (suite *TaskHandlerTestSuite) TestGetTaskStateSummary() {
+	suite.mockedJobFactory.EXPECT().
+		GetJob(suite.testJobID).
+		Return(nil)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.testJobID.GetValue()).
+		Return(suite.testJobConfig, &models.ConfigAddOn{}, nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTaskStateSummaryForJob(gomock.Any(), suite.testJobID).
+		Return(map[string]uint32{
+			task.TaskState_RUNNING.String(): 1,
+			task.TaskState_PENDING.String(): 3,
+			task.TaskState_KILLED.String():  0,
+		}, nil)
+	for i, reason := range []string{"", "REASON_A", "REASON_B", "REASON_A"} {
+		taskEntry := &resmgrsvc.GetActiveTasksResponse_TaskEntry{
+			TaskState: task.TaskState_PENDING.String(),
+			Reason:    reason,
+		}
+		if i == 0 {
+			taskEntry = nil
+		}
+		suite.mockedActiveRMTasks.EXPECT().
+			GetTask(util.CreatePelotonTaskID(suite.testJobID.GetValue(), uint32(i))).
+			Return(taskEntry)
+	}
+
+	resp, err := suite.handler.GetTaskStateSummary(
+		context.Background(),
+		&task.GetTaskStateSummaryRequest{JobId: suite.testJobID})
+	suite.NoError(err)
+	suite.Equal(map[string]uint32{
+		task.TaskState_RUNNING.String(): 1,
+		task.TaskState_PENDING.String(): 3,
+	}, resp.GetStateCounts())
+	suite.Equal(map[string]uint32{
+		"REASON_A": 2,
+		"REASON_B": 1,
+	}, resp.GetPendingReasonCounts())
+}
+
+// TestGetTaskStateSummaryNoJobID tests getting the summary without a job
+func (suite *TaskHandlerTestSuite) TestGetTaskStateSummaryNoJobID() {
+	_, err := suite.handler.GetTaskStateSummary(
+		context.Background(),
+		&task.GetTaskStateSummaryRequest{})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestGetTaskStateSummaryJobNotFound tests getting the summary of a job
+// which does not exist
+func (suite *TaskHandlerTestSuite) TestGetTaskStateSummaryJobNotFound() {
+	suite.mockedJobFactory.EXPECT().
+		GetJob(suite.testJobID).
+		Return(nil)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.testJobID.GetValue()).
+		Return(nil, nil, yarpcerrors.NotFoundErrorf("test error"))
+
+	_, err := suite.handler.GetTaskStateSummary(
+		context.Background(),
+		&task.GetTaskStateSummaryRequest{JobId: suite.testJobID})
+	suite.True(yarpcerrors.IsNotFound(err))
+}
+
+// TestGetTaskStateSummaryStoreError tests failing to count the tasks
+func (suite *TaskHandlerTestSuite) TestGetTaskStateSummaryStoreError() {
+	suite.mockedJobFactory.EXPECT().
+		GetJob(suite.testJobID).
+		Return(nil)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.testJobID.GetValue()).
+		Return(suite.testJobConfig, &models.ConfigAddOn{}, nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTaskStateSummaryForJob(gomock.Any(), suite.testJobID).
+		Return(nil, errors.New("test error"))
+
+	_, err := suite.handler.GetTaskStateSummary(
+		context.Background(),
+		&task.GetTaskStateSummaryRequest{JobId: suite.testJobID})
+	suite.Error(err)
+}
+
+// TestRequeue tests requeueing a placed task to resmgr
+func (suite *TaskHandlerTestSuite) TestRequeue() {
+	instanceID := uint32(1)
+	taskInfo := suite.createTestTaskInfo(task.TaskState_PLACED, instanceID)
+	taskInfo.Runtime.GoalState = task.TaskState_RUNNING
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		AddTask(gomock.Any(), instanceID).Return(suite.mockedTask, nil)
+	suite.mockedGoalStateDrive.EXPECT().
+		IsPaused(suite.testJobID).Return(false)
+	suite.mockedTask.EXPECT().
+		GetRuntime(gomock.Any()).Return(taskInfo.GetRuntime(), nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTaskConfig(gomock.Any(), suite.testJobID, instanceID, gomock.Any()).
+		Return(taskInfo.GetConfig(), nil, nil)
+	suite.mockedResmgrClient.EXPECT().
+		KillTasks(gomock.Any(), &resmgrsvc.KillTasksRequest{
+			Tasks: []*peloton.TaskID{
+				{Value: util.CreatePelotonTaskID(
+					suite.testJobID.GetValue(), instanceID)},
+			},
+		}).
+		Return(&resmgrsvc.KillTasksResponse{}, nil)
+	suite.mockedCachedJob.EXPECT().GetJobType().
+		Return(job.JobType_BATCH).Times(2)
+	suite.mockedTask.EXPECT().
+		CompareAndSetRuntime(gomock.Any(), gomock.Any(), job.JobType_BATCH).
+		DoAndReturn(func(
+			ctx context.Context,
+			runtime *task.RuntimeInfo,
+			jobType job.JobType,
+		) (*task.RuntimeInfo, error) {
+			suite.Equal(task.TaskState_INITIALIZED, runtime.GetState())
+			suite.Equal(taskInfo.GetRuntime().GetMesosTaskId(),
+				runtime.GetPrevMesosTaskId())
+			suite.Equal(taskInfo.GetRuntime().GetRevision(),
+				runtime.GetRevision())
+			return runtime, nil
+		})
+	suite.mockedGoalStateDrive.EXPECT().
+		EnqueueTask(suite.testJobID, instanceID, gomock.Any())
+	suite.mockedGoalStateDrive.EXPECT().
+		EnqueueJobWithDefaultDelay(suite.testJobID, job.JobType_BATCH)
+
+	resp, err := suite.handler.Requeue(
+		context.Background(),
+		&task.RequeueRequest{JobId: suite.testJobID, InstanceId: instanceID})
+	suite.NoError(err)
+	suite.NotEqual(
+		taskInfo.GetRuntime().GetMesosTaskId().GetValue(),
+		resp.GetMesosTaskId().GetValue())
+}
+
+// TestRequeueLaunchedTask tests requeueing a launched task, which also
+// kills the current run of the task on the host
+func (suite *TaskHandlerTestSuite) TestRequeueLaunchedTask() {
+	instanceID := uint32(1)
+	taskInfo := suite.createTestTaskInfo(task.TaskState_LAUNCHED, instanceID)
+	taskInfo.Runtime.GoalState = task.TaskState_RUNNING
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		AddTask(gomock.Any(), instanceID).Return(suite.mockedTask, nil)
+	suite.mockedGoalStateDrive.EXPECT().
+		IsPaused(suite.testJobID).Return(false)
+	suite.mockedTask.EXPECT().
+		GetRuntime(gomock.Any()).Return(taskInfo.GetRuntime(), nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTaskConfig(gomock.Any(), suite.testJobID, instanceID, gomock.Any()).
+		Return(taskInfo.GetConfig(), nil, nil)
+	suite.mockedResmgrClient.EXPECT().
+		KillTasks(gomock.Any(), gomock.Any()).
+		Return(&resmgrsvc.KillTasksResponse{
+			Error: []*resmgrsvc.KillTasksResponse_Error{
+				{NotFound: &resmgrsvc.TasksNotFound{Message: "not found"}},
+			},
+		}, nil)
+	suite.mockedHostMgr.EXPECT().
+		KillTasks(gomock.Any(), gomock.Any()).
+		Do(func(ctx context.Context, req *hostsvc.KillTasksRequest) {
+			suite.Equal(
+				[]*mesos.TaskID{taskInfo.GetRuntime().GetMesosTaskId()},
+				req.GetTaskIds())
+		}).
+		Return(&hostsvc.KillTasksResponse{}, nil)
+	suite.mockedCachedJob.EXPECT().GetJobType().
+		Return(job.JobType_BATCH).Times(2)
+	suite.mockedTask.EXPECT().
+		CompareAndSetRuntime(gomock.Any(), gomock.Any(), job.JobType_BATCH).
+		DoAndReturn(func(
+			ctx context.Context,
+			runtime *task.RuntimeInfo,
+			jobType job.JobType,
+		) (*task.RuntimeInfo, error) {
+			return runtime, nil
+		})
+	suite.mockedGoalStateDrive.EXPECT().
+		EnqueueTask(suite.testJobID, instanceID, gomock.Any())
+	suite.mockedGoalStateDrive.EXPECT().
+		EnqueueJobWithDefaultDelay(suite.testJobID, job.JobType_BATCH)
+
+	_, err := suite.handler.Requeue(
+		context.Background(),
+		&task.RequeueRequest{JobId: suite.testJobID, InstanceId: instanceID})
+	suite.NoError(err)
+}
+
+// TestRequeueLaunchingTaskKillError tests that a launching task is not
+// regenerated if its current run fails to be killed on the host
+func (suite *TaskHandlerTestSuite) TestRequeueLaunchingTaskKillError() {
+	instanceID := uint32(1)
+	taskInfo := suite.createTestTaskInfo(task.TaskState_LAUNCHING, instanceID)
+	taskInfo.Runtime.GoalState = task.TaskState_RUNNING
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		AddTask(gomock.Any(), instanceID).Return(suite.mockedTask, nil)
+	suite.mockedGoalStateDrive.EXPECT().
+		IsPaused(suite.testJobID).Return(false)
+	suite.mockedTask.EXPECT().
+		GetRuntime(gomock.Any()).Return(taskInfo.GetRuntime(), nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTaskConfig(gomock.Any(), suite.testJobID, instanceID, gomock.Any()).
+		Return(taskInfo.GetConfig(), nil, nil)
+	suite.mockedResmgrClient.EXPECT().
+		KillTasks(gomock.Any(), gomock.Any()).
+		Return(&resmgrsvc.KillTasksResponse{}, nil)
+	suite.mockedHostMgr.EXPECT().
+		KillTasks(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("hostmgr unavailable"))
+
+	_, err := suite.handler.Requeue(
+		context.Background(),
+		&task.RequeueRequest{JobId: suite.testJobID, InstanceId: instanceID})
+	suite.Error(err)
+}
+
+// TestRequeueConcurrentChange tests that the task is not regenerated if
+// its runtime changed while it was being requeued
+func (suite *TaskHandlerTestSuite) TestRequeueConcurrentChange() {
+	instanceID := uint32(1)
+	taskInfo := suite.createTestTaskInfo(task.TaskState_PLACED, instanceID)
+	taskInfo.Runtime.GoalState = task.TaskState_RUNNING
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		AddTask(gomock.Any(), instanceID).Return(suite.mockedTask, nil)
+	suite.mockedGoalStateDrive.EXPECT().
+		IsPaused(suite.testJobID).Return(false)
+	suite.mockedTask.EXPECT().
+		GetRuntime(gomock.Any()).Return(taskInfo.GetRuntime(), nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTaskConfig(gomock.Any(), suite.testJobID, instanceID, gomock.Any()).
+		Return(taskInfo.GetConfig(), nil, nil)
+	suite.mockedResmgrClient.EXPECT().
+		KillTasks(gomock.Any(), gomock.Any()).
+		Return(&resmgrsvc.KillTasksResponse{}, nil)
+	suite.mockedCachedJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
+	suite.mockedTask.EXPECT().
+		CompareAndSetRuntime(gomock.Any(), gomock.Any(), job.JobType_BATCH).
+		Return(nil, jobmgrcommon.UnexpectedVersionError)
+
+	_, err := suite.handler.Requeue(
+		context.Background(),
+		&task.RequeueRequest{JobId: suite.testJobID, InstanceId: instanceID})
+	suite.True(yarpcerrors.IsAborted(err))
+}
+
+// TestRequeueRunningTask tests that a running task cannot be requeued
+func (suite *TaskHandlerTestSuite) TestRequeueRunningTask() {
+	instanceID := uint32(1)
+	taskInfo := suite.createTestTaskInfo(task.TaskState_RUNNING, instanceID)
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		AddTask(gomock.Any(), instanceID).Return(suite.mockedTask, nil)
+	suite.mockedGoalStateDrive.EXPECT().
+		IsPaused(suite.testJobID).Return(false)
+	suite.mockedTask.EXPECT().
+		GetRuntime(gomock.Any()).Return(taskInfo.GetRuntime(), nil)
+
+	_, err := suite.handler.Requeue(
+		context.Background(),
+		&task.RequeueRequest{JobId: suite.testJobID, InstanceId: instanceID})
+	suite.True(yarpcerrors.IsFailedPrecondition(err))
+}
+
+// TestRequeueResMgrKillError tests that the task is not regenerated if it
+// fails to be killed in resmgr
+func (suite *TaskHandlerTestSuite) TestRequeueResMgrKillError() {
+	instanceID := uint32(1)
+	taskInfo := suite.createTestTaskInfo(task.TaskState_PLACING, instanceID)
+	taskInfo.Runtime.GoalState = task.TaskState_RUNNING
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		AddTask(gomock.Any(), instanceID).Return(suite.mockedTask, nil)
+	suite.mockedGoalStateDrive.EXPECT().
+		IsPaused(suite.testJobID).Return(false)
+	suite.mockedTask.EXPECT().
+		GetRuntime(gomock.Any()).Return(taskInfo.GetRuntime(), nil)
+	suite.mockedTaskStore.EXPECT().
+		GetTaskConfig(gomock.Any(), suite.testJobID, instanceID, gomock.Any()).
+		Return(taskInfo.GetConfig(), nil, nil)
+	suite.mockedResmgrClient.EXPECT().
+		KillTasks(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("resmgr unavailable"))
+
+	_, err := suite.handler.Requeue(
+		context.Background(),
+		&task.RequeueRequest{JobId: suite.testJobID, InstanceId: instanceID})
+	suite.Error(err)
+}
+
+// TestRequeuePausedJob tests that the task of a paused job cannot be
+// requeued
+func (suite *TaskHandlerTestSuite) TestRequeuePausedJob() {
+	instanceID := uint32(1)
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		AddTask(gomock.Any(), instanceID).Return(suite.mockedTask, nil)
+	suite.mockedGoalStateDrive.EXPECT().
+		IsPaused(suite.testJobID).Return(true)
+
+	_, err := suite.handler.Requeue(
+		context.Background(),
+		&task.RequeueRequest{JobId: suite.testJobID, InstanceId: instanceID})
+	suite.True(yarpcerrors.IsFailedPrecondition(err))
+}
+
+// TestRequeueNonLeader tests calling Requeue on a non-leader
+func (suite *TaskHandlerTestSuite) TestRequeueNonLeader() {
+	suite.mockedCandidate.EXPECT().IsLeader().Return(false)
+
+	_, err := suite.handler.Requeue(
+		context.Background(),
+		&task.RequeueRequest{JobId: suite.testJobID})
+	suite.True(yarpcerrors.IsUnavailable(err))
+}
+
+// TestPatchInstanceConfig tests patching the config of an instance
+// restarts only that instance with the new config version
+func (suite *TaskHandlerTestSuite) TestPatchInstanceConfig() {
+	instanceID := uint32(1)
+	jobConfig := &job.JobConfig{
+		InstanceCount: testInstanceCount,
+		DefaultConfig: &task.TaskConfig{
+			Name:     "default",
+			Resource: &task.ResourceConfig{CpuLimit: 1},
+			Command:  &mesos.CommandInfo{Value: util.PtrPrintf("echo test")},
+		},
+		InstanceConfig: map[uint32]*task.TaskConfig{
+			instanceID: {Name: "instance"},
+		},
+		ChangeLog: &peloton.ChangeLog{Version: 3},
+	}
+	override := &task.TaskConfig{
+		Resource: &task.ResourceConfig{CpuLimit: 2},
+	}
+	taskInfo := suite.createTestTaskInfo(task.TaskState_RUNNING, instanceID)
+	respoolLabel := &peloton.Label{
+		Key:   "peloton.resource_pool",
+		Value: "/respool",
+	}
+	configAddOn := &models.ConfigAddOn{
+		SystemLabels: []*peloton.Label{
+			respoolLabel,
+			{Key: "peloton.provenance_digest", Value: "digest"},
+		},
+	}
+	// the provenance of the previous config is dropped
+	expectedConfigAddOn := &models.ConfigAddOn{
+		SystemLabels: []*peloton.Label{respoolLabel},
+	}
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), testJob).
+		Return(jobConfig, configAddOn, nil)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		GetRuntime(gomock.Any()).Return(suite.testJobRuntime, nil)
+	suite.mockedCachedJob.EXPECT().
+		AddTask(gomock.Any(), instanceID).Return(suite.mockedTask, nil)
+	suite.mockedTask.EXPECT().
+		GetRuntime(gomock.Any()).Return(taskInfo.GetRuntime(), nil)
+	suite.mockedCachedJob.EXPECT().
+		CompareAndSetConfig(gomock.Any(), gomock.Any(), expectedConfigAddOn).
+		Do(func(_ context.Context, config *job.JobConfig, _ *models.ConfigAddOn) {
+			suite.Equal("instance", config.GetInstanceConfig()[instanceID].GetName())
+			suite.Equal(2.0,
+				config.GetInstanceConfig()[instanceID].GetResource().GetCpuLimit())
+			// the config read from the store is left untouched
+			suite.Nil(jobConfig.GetInstanceConfig()[instanceID].GetResource())
+		}).
+		Return(&job.JobConfig{ChangeLog: &peloton.ChangeLog{Version: 4}}, nil)
+	suite.mockedCachedJob.EXPECT().
+		CreateTaskConfigs(
+			gomock.Any(),
+			suite.testJobID,
+			gomock.Any(),
+			expectedConfigAddOn).
+		Do(func(
+			_ context.Context,
+			_ *peloton.JobID,
+			config *job.JobConfig,
+			_ *models.ConfigAddOn) {
+			suite.Equal(uint64(4), config.GetChangeLog().GetVersion())
+			suite.Equal(2.0,
+				config.GetInstanceConfig()[instanceID].GetResource().GetCpuLimit())
+		}).
+		Return(nil)
+	suite.mockedCachedJob.EXPECT().
+		Update(gomock.Any(), &job.JobInfo{
+			Runtime: &job.RuntimeInfo{ConfigurationVersion: 4},
+		}, nil, cached.UpdateCacheAndDB).
+		Return(nil)
+	suite.mockedCachedJob.EXPECT().
+		PatchTasks(gomock.Any(), gomock.Any()).
+		Do(func(ctx context.Context, runtimeDiffs map[uint32]jobmgrcommon.RuntimeDiff) {
+			suite.Len(runtimeDiffs, 1)
+			suite.Equal(uint64(4),
+				runtimeDiffs[instanceID][jobmgrcommon.DesiredConfigVersionField])
+		}).
+		Return(nil)
+	suite.mockedGoalStateDrive.EXPECT().
+		EnqueueTask(suite.testJobID, instanceID, gomock.Any())
+
+	resp, err := suite.handler.PatchInstanceConfig(
+		context.Background(),
+		&task.PatchInstanceConfigRequest{
+			JobId:      suite.testJobID,
+			InstanceId: instanceID,
+			Config:     override,
+		})
+	suite.NoError(err)
+	suite.Equal(uint64(4), resp.GetConfigVersion())
+}
+
+// TestPatchInstanceConfigOutOfRange tests patching the config of an
+// instance which does not exist
+func (suite *TaskHandlerTestSuite) TestPatchInstanceConfigOutOfRange() {
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), testJob).
+		Return(suite.testJobConfig, &models.ConfigAddOn{}, nil)
+
+	_, err := suite.handler.PatchInstanceConfig(
+		context.Background(),
+		&task.PatchInstanceConfigRequest{
+			JobId:      suite.testJobID,
+			InstanceId: testInstanceCount,
+			Config:     &task.TaskConfig{},
+		})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestPatchInstanceConfigActiveUpdate tests that the config of an
+// instance cannot be patched while the job is being updated
+func (suite *TaskHandlerTestSuite) TestPatchInstanceConfigActiveUpdate() {
+	updateID := &peloton.UpdateID{Value: uuid.New()}
+	jobRuntime := proto.Clone(suite.testJobRuntime).(*job.RuntimeInfo)
+	jobRuntime.UpdateID = updateID
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), testJob).
+		Return(suite.testJobConfig, &models.ConfigAddOn{}, nil)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		GetRuntime(gomock.Any()).Return(jobRuntime, nil)
+	suite.mockedUpdateStore.EXPECT().
+		GetUpdateProgress(gomock.Any(), updateID).
+		Return(&models.UpdateModel{State: update.State_ROLLING_FORWARD}, nil)
+
+	_, err := suite.handler.PatchInstanceConfig(
+		context.Background(),
+		&task.PatchInstanceConfigRequest{
+			JobId:      suite.testJobID,
+			InstanceId: 0,
+			Config:     &task.TaskConfig{},
+		})
+	suite.True(yarpcerrors.IsFailedPrecondition(err))
+}
+
+// TestPatchInstanceConfigStoppedTask tests that the config of a task
+// being stopped cannot be patched
+func (suite *TaskHandlerTestSuite) TestPatchInstanceConfigStoppedTask() {
+	taskInfo := suite.createTestTaskInfo(task.TaskState_RUNNING, 0)
+	taskInfo.Runtime.GoalState = task.TaskState_KILLED
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobStore.EXPECT().
+		GetJobConfig(gomock.Any(), testJob).
+		Return(suite.testJobConfig, &models.ConfigAddOn{}, nil)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		GetRuntime(gomock.Any()).Return(suite.testJobRuntime, nil)
+	suite.mockedCachedJob.EXPECT().
+		AddTask(gomock.Any(), uint32(0)).Return(suite.mockedTask, nil)
+	suite.mockedTask.EXPECT().
+		GetRuntime(gomock.Any()).Return(taskInfo.GetRuntime(), nil)
+
+	_, err := suite.handler.PatchInstanceConfig(
+		context.Background(),
+		&task.PatchInstanceConfigRequest{
+			JobId:      suite.testJobID,
+			InstanceId: 0,
+			Config:     &task.TaskConfig{},
+		})
+	suite.True(yarpcerrors.IsFailedPrecondition(err))
+}
+
+// TestPatchInstanceConfigInvalid tests that an instance config which
+// is invalid once patched, or is not signed while signatures are
+// required, is rejected
+func (suite *TaskHandlerTestSuite) TestPatchInstanceConfigInvalid() {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	suite.NoError(err)
+	buffer, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	suite.NoError(err)
+	signedVerifier, err := provenance.NewVerifier(&provenance.Config{
+		RequireSignature: true,
+		PublicKeys: map[string]string{
+			"ci": string(pem.EncodeToMemory(&pem.Block{
+				Type:  "PUBLIC KEY",
+				Bytes: buffer,
+			})),
+		},
+	})
+	suite.NoError(err)
+
+	validConfig := proto.Clone(suite.testJobConfig).(*job.JobConfig)
+	validConfig.DefaultConfig = &task.TaskConfig{
+		Command: &mesos.CommandInfo{Value: util.PtrPrintf("echo test")},
+	}
+
+	tt := []struct {
+		name      string
+		jobConfig *job.JobConfig
+		override  *task.TaskConfig
+		verifier  provenance.Verifier
+	}{
+		{
+			name:      "missing command",
+			jobConfig: suite.testJobConfig,
+			override:  &task.TaskConfig{Name: "instance"},
+		},
+		{
+			name:      "executor of a batch task",
+			jobConfig: validConfig,
+			override: &task.TaskConfig{
+				Executor: &mesos.ExecutorInfo{},
+			},
+		},
+		{
+			name:      "unsigned config",
+			jobConfig: validConfig,
+			override:  &task.TaskConfig{Name: "instance"},
+			verifier:  signedVerifier,
+		},
+	}
+
+	for _, test := range tt {
+		suite.handler.configVerifier = test.verifier
+		taskInfo := suite.createTestTaskInfo(task.TaskState_RUNNING, 0)
+
+		suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+		suite.mockedJobStore.EXPECT().
+			GetJobConfig(gomock.Any(), testJob).
+			Return(test.jobConfig, &models.ConfigAddOn{}, nil)
+		suite.mockedJobFactory.EXPECT().
+			AddJob(suite.testJobID).Return(suite.mockedCachedJob)
+		suite.mockedCachedJob.EXPECT().
+			GetRuntime(gomock.Any()).Return(suite.testJobRuntime, nil)
+		suite.mockedCachedJob.EXPECT().
+			AddTask(gomock.Any(), uint32(0)).Return(suite.mockedTask, nil)
+		suite.mockedTask.EXPECT().
+			GetRuntime(gomock.Any()).Return(taskInfo.GetRuntime(), nil)
+
+		_, err := suite.handler.PatchInstanceConfig(
+			context.Background(),
+			&task.PatchInstanceConfigRequest{
+				JobId:      suite.testJobID,
+				InstanceId: 0,
+				Config:     test.override,
+			})
+		suite.True(yarpcerrors.IsInvalidArgument(err), test.name)
+	}
+}
+
+// TestPatchInstanceConfigInvalidRequest tests patching without a
+// config override, and on a non-leader
+func (suite *TaskHandlerTestSuite) TestPatchInstanceConfigInvalidRequest() {
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	_, err := suite.handler.PatchInstanceConfig(
+		context.Background(),
+		&task.PatchInstanceConfigRequest{JobId: suite.testJobID})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(false)
+	_, err = suite.handler.PatchInstanceConfig(
+		context.Background(),
+		&task.PatchInstanceConfigRequest{
+			JobId:  suite.testJobID,
+			Config: &task.TaskConfig{},
+		})
+	suite.True(yarpcerrors.IsUnavailable(err))
+}
+
+// TestRecordTaskOperation tests that a mutating call is recorded
+// along with its result
+func (suite *TaskHandlerTestSuite) TestRecordTaskOperation() {
+	taskOperationOps := objectmocks.NewMockTaskOperationOps(suite.ctrl)
+	suite.handler.taskOperationOps = taskOperationOps
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(false)
+	taskOperationOps.EXPECT().
+		Create(gomock.Any(), suite.testJobID, gomock.Any(), gomock.Any()).
+		Do(func(
+			_ context.Context,
+			_ *peloton.JobID,
+			_ []uint32,
+			operation *task.TaskOperation) {
+			suite.Equal("Stop", operation.GetOperation())
+			suite.Contains(operation.GetRequest(), testJob)
+			suite.Contains(operation.GetResult(), "non-leader")
+		}).
+		Return(errors.New("test error"))
+
+	_, err := suite.handler.Stop(
+		context.Background(),
+		&task.StopRequest{JobId: suite.testJobID})
+	suite.True(yarpcerrors.IsUnavailable(err))
+}
+
+// TestRecordTaskOperationIdempotencyKey tests that the idempotency key
+// of a call is recorded along with its result
+func (suite *TaskHandlerTestSuite) TestRecordTaskOperationIdempotencyKey() {
+	taskOperationOps := objectmocks.NewMockTaskOperationOps(suite.ctrl)
+	suite.handler.taskOperationOps = taskOperationOps
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(false)
+	taskOperationOps.EXPECT().
+		Create(gomock.Any(), suite.testJobID, gomock.Any(), gomock.Any()).
+		Do(func(
+			_ context.Context,
+			_ *peloton.JobID,
+			_ []uint32,
+			operation *task.TaskOperation) {
+			suite.Equal("Restart", operation.GetOperation())
+			suite.Equal("key", operation.GetIdempotencyKey())
+		}).
+		Return(nil)
+
+	_, err := suite.handler.Restart(
+		context.Background(),
+		&task.RestartRequest{
+			JobId:          suite.testJobID,
+			IdempotencyKey: "key",
+		})
+	suite.True(yarpcerrors.IsUnavailable(err))
+}
+
+// TestGetTaskOperationHistory tests getting the operations
+// made on the tasks of a job
+func (suite *TaskHandlerTestSuite) TestGetTaskOperationHistory() {
+	operations := []*task.TaskOperation{
+		{Operation: "Restart", Caller: "peloton-cli"},
+		{Operation: "Stop", Caller: "peloton-cli"},
+		{Operation: "Start", Caller: "peloton-cli"},
+	}
+
+	suite.mockedTaskOperationOps.EXPECT().
+		GetAll(gomock.Any(), suite.testJobID).
+		Return(operations, nil).
+		Times(2)
+
+	resp, err := suite.handler.GetTaskOperationHistory(
+		context.Background(),
+		&task.GetTaskOperationHistoryRequest{JobId: suite.testJobID})
+	suite.NoError(err)
+	suite.Equal(operations, resp.GetOperations())
+
+	resp, err = suite.handler.GetTaskOperationHistory(
+		context.Background(),
+		&task.GetTaskOperationHistoryRequest{
+			JobId: suite.testJobID,
+			Limit: 2,
+		})
+	suite.NoError(err)
+	suite.Equal(operations[:2], resp.GetOperations())
+}
+
+// TestRecordTaskOperationContext tests that the operation context of
+// a request is recorded with the operation
+func (suite *TaskHandlerTestSuite) TestRecordTaskOperationContext() {
+	taskOperationOps := objectmocks.NewMockTaskOperationOps(suite.ctrl)
+	suite.handler.taskOperationOps = taskOperationOps
+
+	operationContext := &task.OperationContext{IncidentId: "INC-1234"}
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(false)
+	taskOperationOps.EXPECT().
+		Create(gomock.Any(), suite.testJobID, gomock.Any(), gomock.Any()).
+		Do(func(
+			_ context.Context,
+			_ *peloton.JobID,
+			_ []uint32,
+			operation *task.TaskOperation) {
+			suite.Equal("Stop", operation.GetOperation())
+			suite.Equal(operationContext, operation.GetOperationContext())
+		}).
+		Return(nil)
+
+	_, err := suite.handler.Stop(
+		context.Background(),
+		&task.StopRequest{
+			JobId:            suite.testJobID,
+			OperationContext: operationContext,
+		})
+	suite.True(yarpcerrors.IsUnavailable(err))
+}
+
+// TestGetTaskOperationHistoryByOperationContext tests getting the
+// operations made on the tasks of a job for an incident or change ticket
+func (suite *TaskHandlerTestSuite) TestGetTaskOperationHistoryByOperationContext() {
+	operations := []*task.TaskOperation{
+		{
+			Operation: "Restart",
+			OperationContext: &task.OperationContext{
+				IncidentId:   "INC-1",
+				ChangeTicket: "CHG-1",
+			},
+		},
+		{Operation: "Stop"},
+		{
+			Operation:        "Start",
+			OperationContext: &task.OperationContext{IncidentId: "INC-1"},
+		},
+		{
+			Operation:        "Stop",
+			OperationContext: &task.OperationContext{IncidentId: "INC-2"},
+		},
+	}
+
+	suite.mockedTaskOperationOps.EXPECT().
+		GetAll(gomock.Any(), suite.testJobID).
+		Return(operations, nil).
+		Times(3)
+
+	resp, err := suite.handler.GetTaskOperationHistory(
+		context.Background(),
+		&task.GetTaskOperationHistoryRequest{
+			JobId:      suite.testJobID,
+			IncidentId: "INC-1",
+		})
+	suite.NoError(err)
+	suite.Equal(
+		[]*task.TaskOperation{operations[0], operations[2]},
+		resp.GetOperations())
+
+	resp, err = suite.handler.GetTaskOperationHistory(
+		context.Background(),
+		&task.GetTaskOperationHistoryRequest{
+			JobId:        suite.testJobID,
+			IncidentId:   "INC-1",
+			ChangeTicket: "CHG-1",
+		})
+	suite.NoError(err)
+	suite.Equal(operations[:1], resp.GetOperations())
+
+	resp, err = suite.handler.GetTaskOperationHistory(
+		context.Background(),
+		&task.GetTaskOperationHistoryRequest{
+			JobId:      suite.testJobID,
+			IncidentId: "INC-1",
+			Limit:      1,
+		})
+	suite.NoError(err)
+	suite.Equal(operations[:1], resp.GetOperations())
+}
+
+// TestGetTaskOperationHistoryNoJobID tests getting the operation
+// history without a job id
+func (suite *TaskHandlerTestSuite) TestGetTaskOperationHistoryNoJobID() {
+	_, err := suite.handler.GetTaskOperationHistory(
+		context.Background(),
+		&task.GetTaskOperationHistoryRequest{})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestGetTaskOperationHistoryStoreError tests getting the operation
+// history when the store fails
+func (suite *TaskHandlerTestSuite) TestGetTaskOperationHistoryStoreError() {
+	suite.mockedTaskOperationOps.EXPECT().
+		GetAll(gomock.Any(), suite.testJobID).
+		Return(nil, errors.New("test error"))
+
+	_, err := suite.handler.GetTaskOperationHistory(
+		context.Background(),
+		&task.GetTaskOperationHistoryRequest{JobId: suite.testJobID})
+	suite.Error(err)
+}
+
+// TestRecordTaskOperationUnverifiedCaller tests that the caller header of
+// a call is recorded as unverified when TLS is disabled, along with the
+// instance the call was made on
+func (suite *TaskHandlerTestSuite) TestRecordTaskOperationUnverifiedCaller() {
+	taskOperationOps := objectmocks.NewMockTaskOperationOps(suite.ctrl)
+	suite.handler.taskOperationOps = taskOperationOps
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(false)
+	taskOperationOps.EXPECT().
+		Create(gomock.Any(), suite.testJobID, []uint32{2}, gomock.Any()).
+		Do(func(
+			_ context.Context,
+			_ *peloton.JobID,
+			_ []uint32,
+			operation *task.TaskOperation) {
+			suite.Equal("Requeue", operation.GetOperation())
+			suite.Equal("unverified:peloton-cli", operation.GetCaller())
+		}).
+		Return(nil)
+
+	_, err := suite.handler.Requeue(
+		yarpctest.ContextWithCall(
+			context.Background(),
+			&yarpctest.Call{Caller: "peloton-cli"},
+		),
+		&task.RequeueRequest{JobId: suite.testJobID, InstanceId: 2})
+	suite.True(yarpcerrors.IsUnavailable(err))
+}
+
+// TestRecordTaskOperationBatchStop tests that a rejected batch stop is
+// recorded for each of its jobs
+func (suite *TaskHandlerTestSuite) TestRecordTaskOperationBatchStop() {
+	taskOperationOps := objectmocks.NewMockTaskOperationOps(suite.ctrl)
+	suite.handler.taskOperationOps = taskOperationOps
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(false)
+	taskOperationOps.EXPECT().
+		Create(gomock.Any(), suite.testJobID, nil, gomock.Any()).
+		Do(func(
+			_ context.Context,
+			_ *peloton.JobID,
+			_ []uint32,
+			operation *task.TaskOperation) {
+			suite.Equal("BatchStop", operation.GetOperation())
+			suite.Contains(operation.GetResult(), "non-leader")
+		}).
+		Return(nil)
+
+	_, err := suite.handler.BatchStop(
+		context.Background(),
+		&task.BatchStopRequest{
+			Jobs: []*task.StopRequest{{JobId: suite.testJobID}},
+		},
+	)
+	suite.True(yarpcerrors.IsUnavailable(err))
+}
+
+// TestOperationInstances tests getting the instances a call was made on
+func (suite *TaskHandlerTestSuite) TestOperationInstances() {
+	tt := []struct {
+		name     string
+		req      interface{}
+		resp     interface{}
+		expected []uint32
+	}{
+		{
+			name:     "instance of the request",
+			req:      &task.RequeueRequest{InstanceId: 3},
+			expected: []uint32{3},
+		},
+		{
+			name: "instances stopped",
+			req: &task.StopRequest{
+				Ranges: []*task.InstanceRange{{From: 0, To: 10}},
+			},
+			resp:     &task.StopResponse{StoppedInstanceIds: []uint32{1, 2}},
+			expected: []uint32{1, 2},
+		},
+		{
+			name: "instance ranges",
+			req: &task.RestartRequest{
+				Ranges: []*task.InstanceRange{{From: 1, To: 3}, {From: 5, To: 6}},
+			},
+			resp:     &task.RestartResponse{},
+			expected: []uint32{1, 2, 5},
+		},
+		{
+			name:     "instance range",
+			req:      &task.RefreshRequest{Range: &task.InstanceRange{From: 0, To: 2}},
+			expected: []uint32{0, 1},
+		},
+		{
+			name: "all instances",
+			req:  &task.StopRequest{},
+			resp: &task.StopResponse{},
+		},
+		{
+			name: "too many instances",
+			req: &task.StartRequest{
+				Ranges: []*task.InstanceRange{{From: 0, To: 1000}},
+			},
+		},
+	}
+
+	for _, test := range tt {
+		suite.Equal(
+			test.expected,
+			operationInstances(test.req, test.resp),
+			test.name)
+	}
+}
+
+// TestGetTaskOperationHistoryByInstance tests getting the operations
+// made on a range of instances of a job
+func (suite *TaskHandlerTestSuite) TestGetTaskOperationHistoryByInstance() {
+	operations := []*task.TaskOperation{
+		{Operation: "Requeue"},
+		{Operation: "Stop", OperationContext: &task.OperationContext{IncidentId: "INC-1"}},
+	}
+
+	suite.mockedTaskOperationOps.EXPECT().
+		GetForInstances(gomock.Any(), suite.testJobID, []uint32{1, 2}).
+		Return(operations, nil).
+		Times(2)
+
+	resp, err := suite.handler.GetTaskOperationHistory(
+		context.Background(),
+		&task.GetTaskOperationHistoryRequest{
+			JobId: suite.testJobID,
+			Range: &task.InstanceRange{From: 1, To: 3},
+		})
+	suite.NoError(err)
+	suite.Equal(operations, resp.GetOperations())
+
+	resp, err = suite.handler.GetTaskOperationHistory(
+		context.Background(),
+		&task.GetTaskOperationHistoryRequest{
+			JobId:      suite.testJobID,
+			Range:      &task.InstanceRange{From: 1, To: 3},
+			IncidentId: "INC-1",
+		})
+	suite.NoError(err)
+	suite.Equal(operations[1:], resp.GetOperations())
+}
+
+// TestGetTaskOperationHistoryInvalidRange tests getting the operations
+// made on an empty or too large range of instances
+func (suite *TaskHandlerTestSuite) TestGetTaskOperationHistoryInvalidRange() {
+	for _, r := range []*task.InstanceRange{
+		{From: 2, To: 2},
+		{From: 0, To: _maxOperationInstances + 1},
+	} {
+		_, err := suite.handler.GetTaskOperationHistory(
+			context.Background(),
+			&task.GetTaskOperationHistoryRequest{
+				JobId: suite.testJobID,
+				Range: r,
+			})
+		suite.True(yarpcerrors.IsInvalidArgument(err))
+	}
+}
+
+// waitForOperation polls the status of an asynchronous operation
+// until it completes
+func (suite *TaskHandlerTestSuite) waitForOperation(
+	operationID string) *task.GetOperationStatusResponse {
+	for i := 0; i < 100; i++ {
+		resp, err := suite.handler.GetOperationStatus(
+			context.Background(),
+			&task.GetOperationStatusRequest{OperationId: operationID})
+		suite.NoError(err)
+		if resp.GetState() != task.OperationState_OPERATION_STATE_RUNNING {
+			return resp
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	suite.Fail("operation did not complete")
+	return nil
+}
+
+// TestStopTasksAsync tests stopping all the tasks of a job in the background
+func (suite *TaskHandlerTestSuite) TestStopTasksAsync() {
+	expectedJobRuntime := proto.Clone(suite.testJobRuntime).(*job.RuntimeInfo)
+	expectedJobRuntime.GoalState = job.JobState_KILLED
+	expectedJobRuntime.DesiredStateVersion++
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true).AnyTimes()
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).
+		Return(suite.mockedCachedJob).
+		AnyTimes()
+	suite.mockedCachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(cachedtest.NewMockJobConfig(suite.ctrl, suite.testJobConfig), nil).
+		Times(2)
+	suite.mockedCachedJob.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(suite.testJobRuntime, nil)
+	suite.mockedCachedJob.EXPECT().
+		CompareAndSetRuntime(gomock.Any(), expectedJobRuntime).
+		Return(expectedJobRuntime, nil)
+	suite.mockedGoalStateDrive.EXPECT().
+		EnqueueJob(suite.testJobID, gomock.Any()).Return()
+
+	resp, err := suite.handler.Stop(
+		context.Background(),
+		&task.StopRequest{
+			JobId: suite.testJobID,
+			Async: true,
+		})
+	suite.NoError(err)
+	suite.NotEmpty(resp.GetOperationId())
+	suite.Empty(resp.GetStoppedInstanceIds())
+
+	status := suite.waitForOperation(resp.GetOperationId())
+	suite.Equal(task.OperationState_OPERATION_STATE_SUCCEEDED, status.GetState())
+	suite.Equal("Stop", status.GetOperation())
+	suite.Equal(uint32(testInstanceCount), status.GetTotalInstances())
+	suite.Equal(uint32(testInstanceCount), status.GetProcessedInstances())
+	suite.Len(status.GetOutcomes(), testInstanceCount)
+	for _, outcome := range status.GetOutcomes() {
+		suite.True(outcome.GetSucceeded())
+	}
+}
+
+// TestRestartTasksAsyncFailure tests failing to restart tasks
+// in the background
+func (suite *TaskHandlerTestSuite) TestRestartTasksAsyncFailure() {
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true).AnyTimes()
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).
+		Return(suite.mockedCachedJob).
+		AnyTimes()
+	suite.mockedCachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(cachedtest.NewMockJobConfig(suite.ctrl, suite.testJobConfig), nil)
+	suite.mockedCachedJob.EXPECT().
+		ID().Return(suite.testJobID).AnyTimes()
+	suite.mockedTaskStore.EXPECT().
+		GetTasksForJobByRange(gomock.Any(), suite.testJobID, gomock.Any()).
+		Return(suite.taskInfos, nil)
+	suite.mockedCachedJob.EXPECT().
+		PatchTasks(gomock.Any(), gomock.Any()).
+		Return(errors.New("test error"))
+
+	resp, err := suite.handler.Restart(
+		context.Background(),
+		&task.RestartRequest{
+			JobId: suite.testJobID,
+			Ranges: []*task.InstanceRange{
+				{From: 0, To: testInstanceCount},
+			},
+			Async: true,
+		})
+	suite.NoError(err)
+	suite.NotEmpty(resp.GetOperationId())
+
+	status := suite.waitForOperation(resp.GetOperationId())
+	suite.Equal(task.OperationState_OPERATION_STATE_FAILED, status.GetState())
+	suite.Equal(uint32(testInstanceCount), status.GetProcessedInstances())
+	suite.Len(status.GetOutcomes(), testInstanceCount)
+	for _, outcome := range status.GetOutcomes() {
+		suite.False(outcome.GetSucceeded())
+		suite.Equal("test error", outcome.GetMessage())
+	}
+}
+
+// TestStartTasksAsyncJobNotFound tests starting tasks of a missing job
+// in the background
+func (suite *TaskHandlerTestSuite) TestStartTasksAsyncJobNotFound() {
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobFactory.EXPECT().
+		AddJob(suite.testJobID).
+		Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		GetConfig(gomock.Any()).
+		Return(nil, errors.New("test error"))
+
+	_, err := suite.handler.Start(
+		context.Background(),
+		&task.StartRequest{
+			JobId: suite.testJobID,
+			Async: true,
+		})
+	suite.True(yarpcerrors.IsNotFound(err))
+}
+
+// TestGetOperationStatusFailure tests the failure cases of getting the
+// status of an asynchronous operation
+func (suite *TaskHandlerTestSuite) TestGetOperationStatusFailure() {
+	suite.mockedCandidate.EXPECT().IsLeader().Return(false)
+	_, err := suite.handler.GetOperationStatus(
+		context.Background(),
+		&task.GetOperationStatusRequest{OperationId: uuid.New()})
+	suite.True(yarpcerrors.IsUnavailable(err))
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true).Times(2)
+	_, err = suite.handler.GetOperationStatus(
+		context.Background(),
+		&task.GetOperationStatusRequest{})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+
+	_, err = suite.handler.GetOperationStatus(
+		context.Background(),
+		&task.GetOperationStatusRequest{OperationId: uuid.New()})
+	suite.True(yarpcerrors.IsNotFound(err))
+}
+
+// setupTaskCommand sets up a grant allowing running commands in the tasks
+// of the test job, and returns a context authenticated by the grant.
+func (suite *TaskHandlerTestSuite) setupTaskCommand() context.Context {
+	suite.handler.taskCommand = TaskCommandConfig{
+		Grants: []TaskCommandGrant{
+			{
+				Name:  "oncall",
+				Token: "token",
+				Jobs:  []string{suite.testJobID.GetValue()},
+			},
+		},
+	}
+	return yarpctest.ContextWithCall(
+		context.Background(),
+		&yarpctest.Call{
+			Headers: map[string]string{"authorization": "Bearer token"},
+		},
+	)
+}
+
+// TestRunTaskCommand tests streaming the output of a command executed in
+// the container of a running task
+func (suite *TaskHandlerTestSuite) TestRunTaskCommand() {
+	taskInfo := suite.createTestTaskInfo(task.TaskState_RUNNING, 0)
+	taskInfo.Runtime.Host = "host-1"
+	req := &task.RunTaskCommandRequest{
+		JobId:      suite.testJobID,
+		InstanceId: 0,
+		Command:    "ls",
+	}
+
+	stream := taskmocks.NewMockTaskManagerServiceRunTaskCommandYARPCServer(
+		suite.ctrl)
+	hostStream := hostmocks.NewMockInternalHostServiceServiceExecTaskCommandYARPCClient(
+		suite.ctrl)
+
+	stream.EXPECT().Context().Return(suite.setupTaskCommand())
+	gomock.InOrder(
+		suite.mockedCandidate.EXPECT().IsLeader().Return(true),
+		suite.mockedJobFactory.EXPECT().
+			GetJob(suite.testJobID).Return(suite.mockedCachedJob),
+		suite.mockedCachedJob.EXPECT().
+			GetTask(uint32(0)).Return(suite.mockedTask),
+		suite.mockedTask.EXPECT().
+			GetRuntime(gomock.Any()).Return(taskInfo.GetRuntime(), nil),
+		suite.mockedHostMgr.EXPECT().
+			ExecTaskCommand(gomock.Any(), &hostsvc.ExecTaskCommandRequest{
+				Hostname: "host-1",
+				TaskId:   taskInfo.GetRuntime().GetMesosTaskId(),
+				Command:  "ls",
+			}, gomock.Any()).
+			Return(hostStream, nil),
+		hostStream.EXPECT().Recv().
+			Return(&hostsvc.ExecTaskCommandResponse{Stdout: []byte("out")}, nil),
+		stream.EXPECT().
+			Send(&task.RunTaskCommandResponse{Stdout: []byte("out")}).
+			Return(nil),
+		hostStream.EXPECT().Recv().
+			Return(&hostsvc.ExecTaskCommandResponse{
+				Exited:   true,
+				ExitCode: 1,
+			}, nil),
+		stream.EXPECT().
+			Send(&task.RunTaskCommandResponse{Exited: true, ExitCode: 1}).
+			Return(nil),
+		hostStream.EXPECT().Recv().Return(nil, io.EOF),
+	)
+
+	suite.NoError(suite.handler.RunTaskCommand(req, stream))
+}
+
+// TestRunTaskCommandNotRunning tests executing a command in a task which
+// is not running
+func (suite *TaskHandlerTestSuite) TestRunTaskCommandNotRunning() {
+	taskInfo := suite.createTestTaskInfo(task.TaskState_PENDING, 0)
+	stream := taskmocks.NewMockTaskManagerServiceRunTaskCommandYARPCServer(
+		suite.ctrl)
+
+	stream.EXPECT().Context().Return(suite.setupTaskCommand())
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobFactory.EXPECT().
+		GetJob(suite.testJobID).Return(suite.mockedCachedJob)
+	suite.mockedCachedJob.EXPECT().
+		GetTask(uint32(0)).Return(suite.mockedTask)
+	suite.mockedTask.EXPECT().
+		GetRuntime(gomock.Any()).Return(taskInfo.GetRuntime(), nil)
+
+	err := suite.handler.RunTaskCommand(&task.RunTaskCommandRequest{
+		JobId:      suite.testJobID,
+		InstanceId: 0,
+		Command:    "ls",
+	}, stream)
+	suite.True(yarpcerrors.IsFailedPrecondition(err))
+}
+
+// TestRunTaskCommandInvalidRequest tests executing a command without a
+// command, and in a job which is not found
+func (suite *TaskHandlerTestSuite) TestRunTaskCommandInvalidRequest() {
+	stream := taskmocks.NewMockTaskManagerServiceRunTaskCommandYARPCServer(
+		suite.ctrl)
+	stream.EXPECT().Context().Return(suite.setupTaskCommand()).Times(2)
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	err := suite.handler.RunTaskCommand(&task.RunTaskCommandRequest{
+		JobId: suite.testJobID,
+	}, stream)
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+
+	suite.mockedCandidate.EXPECT().IsLeader().Return(true)
+	suite.mockedJobFactory.EXPECT().GetJob(suite.testJobID).Return(nil)
+	err = suite.handler.RunTaskCommand(&task.RunTaskCommandRequest{
+		JobId:   suite.testJobID,
+		Command: "ls",
+	}, stream)
+	suite.True(yarpcerrors.IsNotFound(err))
+}
+
+// TestRunTaskCommandUnauthorized tests executing a command without being
+// authorized to
+func (suite *TaskHandlerTestSuite) TestRunTaskCommandUnauthorized() {
+	stream := taskmocks.NewMockTaskManagerServiceRunTaskCommandYARPCServer(
+		suite.ctrl)
+	req := &task.RunTaskCommandRequest{
+		JobId:   suite.testJobID,
+		Command: "ls",
+	}
+
+	// task commands are not enabled
+	stream.EXPECT().Context().Return(context.Background())
+	err := suite.handler.RunTaskCommand(req, stream)
+	suite.True(yarpcerrors.IsUnimplemented(err))
+
+	// the call does not have the token of any grant
+	suite.setupTaskCommand()
+	stream.EXPECT().Context().Return(context.Background())
+	err = suite.handler.RunTaskCommand(req, stream)
+	suite.True(yarpcerrors.IsUnauthenticated(err))
+
+	// the grant does not allow running commands in the job
+	ctx := suite.setupTaskCommand()
+	suite.handler.taskCommand.Grants[0].Jobs = []string{uuid.New()}
+	stream.EXPECT().Context().Return(ctx)
+	err = suite.handler.RunTaskCommand(req, stream)
+	suite.True(yarpcerrors.IsPermissionDenied(err))
+}
@@ -52,6 +52,63 @@ type Metrics struct {
 	TaskListLogs     tally.Counter
 	TaskListLogsFail tally.Counter
 
+	TaskAPIBatchStop  tally.Counter
+	TaskBatchStop     tally.Counter
+	TaskBatchStopFail tally.Counter
+	// Number of jobs of batch stop requests which failed to stop
+	TaskBatchStopJobFail tally.Counter
+
+	TaskAPIGetHostFailureImpact  tally.Counter
+	TaskGetHostFailureImpact     tally.Counter
+	TaskGetHostFailureImpactFail tally.Counter
+
+	TaskAPIGetDrainHeadroom  tally.Counter
+	TaskGetDrainHeadroom     tally.Counter
+	TaskGetDrainHeadroomFail tally.Counter
+
+	TaskAPIGetColocation  tally.Counter
+	TaskGetColocation     tally.Counter
+	TaskGetColocationFail tally.Counter
+
+	TaskAPITailSandboxFile  tally.Counter
+	TaskTailSandboxFile     tally.Counter
+	TaskTailSandboxFileFail tally.Counter
+
+	TaskAPIDownloadSandboxFile  tally.Counter
+	TaskDownloadSandboxFile     tally.Counter
+	TaskDownloadSandboxFileFail tally.Counter
+
+	TaskAPIGetTaskStateSummary  tally.Counter
+	TaskGetTaskStateSummary     tally.Counter
+	TaskGetTaskStateSummaryFail tally.Counter
+
+	TaskAPIRequeue  tally.Counter
+	TaskRequeue     tally.Counter
+	TaskRequeueFail tally.Counter
+
+	TaskAPIGetTaskOperationHistory  tally.Counter
+	TaskGetTaskOperationHistory     tally.Counter
+	TaskGetTaskOperationHistoryFail tally.Counter
+
+	TaskAPIGetOperationStatus  tally.Counter
+	TaskGetOperationStatus     tally.Counter
+	TaskGetOperationStatusFail tally.Counter
+
+	TaskAPIPatchInstanceConfig  tally.Counter
+	TaskPatchInstanceConfig     tally.Counter
+	TaskPatchInstanceConfigFail tally.Counter
+
+	TaskAPIRunTaskCommand  tally.Counter
+	TaskRunTaskCommand     tally.Counter
+	TaskRunTaskCommandFail tally.Counter
+
+	// Number of retried Start, Stop and Restart requests answered with
+	// the outcome of the earlier request with the same idempotency key
+	TaskIdempotentRequestDeduplicated tally.Counter
+
+	// Number of restarts in waves whose remaining waves failed
+	TaskRestartWaveFail tally.Counter
+
 	// Timers
 	TaskQueryHandlerDuration tally.Timer
 }
@@ -94,6 +151,59 @@ func NewMetrics(scope tally.Scope) *Metrics {
 		TaskListLogs:      taskSuccessScope.Counter("list_logs"),
 		TaskListLogsFail:  taskFailScope.Counter("list_logs"),
 
+		TaskAPIBatchStop:     taskAPIScope.Counter("batch_stop"),
+		TaskBatchStop:        taskSuccessScope.Counter("batch_stop"),
+		TaskBatchStopFail:    taskFailScope.Counter("batch_stop"),
+		TaskBatchStopJobFail: taskFailScope.Counter("batch_stop_job"),
+
+		TaskAPIGetHostFailureImpact:  taskAPIScope.Counter("get_host_failure_impact"),
+		TaskGetHostFailureImpact:     taskSuccessScope.Counter("get_host_failure_impact"),
+		TaskGetHostFailureImpactFail: taskFailScope.Counter("get_host_failure_impact"),
+
+		TaskAPIGetDrainHeadroom:  taskAPIScope.Counter("get_drain_headroom"),
+		TaskGetDrainHeadroom:     taskSuccessScope.Counter("get_drain_headroom"),
+		TaskGetDrainHeadroomFail: taskFailScope.Counter("get_drain_headroom"),
+
+		TaskAPIGetColocation:  taskAPIScope.Counter("get_colocation"),
+		TaskGetColocation:     taskSuccessScope.Counter("get_colocation"),
+		TaskGetColocationFail: taskFailScope.Counter("get_colocation"),
+
+		TaskAPITailSandboxFile:  taskAPIScope.Counter("tail_sandbox_file"),
+		TaskTailSandboxFile:     taskSuccessScope.Counter("tail_sandbox_file"),
+		TaskTailSandboxFileFail: taskFailScope.Counter("tail_sandbox_file"),
+
+		TaskAPIDownloadSandboxFile:  taskAPIScope.Counter("download_sandbox_file"),
+		TaskDownloadSandboxFile:     taskSuccessScope.Counter("download_sandbox_file"),
+		TaskDownloadSandboxFileFail: taskFailScope.Counter("download_sandbox_file"),
+
+		TaskAPIGetTaskStateSummary:  taskAPIScope.Counter("get_task_state_summary"),
+		TaskGetTaskStateSummary:     taskSuccessScope.Counter("get_task_state_summary"),
+		TaskGetTaskStateSummaryFail: taskFailScope.Counter("get_task_state_summary"),
+
+		TaskAPIRequeue:  taskAPIScope.Counter("requeue"),
+		TaskRequeue:     taskSuccessScope.Counter("requeue"),
+		TaskRequeueFail: taskFailScope.Counter("requeue"),
+
+		TaskAPIGetTaskOperationHistory:  taskAPIScope.Counter("get_task_operation_history"),
+		TaskGetTaskOperationHistory:     taskSuccessScope.Counter("get_task_operation_history"),
+		TaskGetTaskOperationHistoryFail: taskFailScope.Counter("get_task_operation_history"),
+
+		TaskAPIGetOperationStatus:  taskAPIScope.Counter("get_operation_status"),
+		TaskGetOperationStatus:     taskSuccessScope.Counter("get_operation_status"),
+		TaskGetOperationStatusFail: taskFailScope.Counter("get_operation_status"),
+
+		TaskAPIPatchInstanceConfig:  taskAPIScope.Counter("patch_instance_config"),
+		TaskPatchInstanceConfig:     taskSuccessScope.Counter("patch_instance_config"),
+		TaskPatchInstanceConfigFail: taskFailScope.Counter("patch_instance_config"),
+
+		TaskAPIRunTaskCommand:  taskAPIScope.Counter("run_task_command"),
+		TaskRunTaskCommand:     taskSuccessScope.Counter("run_task_command"),
+		TaskRunTaskCommandFail: taskFailScope.Counter("run_task_command"),
+
+		TaskIdempotentRequestDeduplicated: taskSuccessScope.Counter("idempotent_request_deduplicated"),
+
+		TaskRestartWaveFail: taskFailScope.Counter("restart_wave"),
+
 		TaskQueryHandlerDuration: taskAPIScope.Timer("task_query_duration"),
 	}
 }
@@ -0,0 +1,189 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasksvc
+
+import (
+	"context"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/uber/peloton/pkg/jobmgr/asyncop"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// _asyncOperationBatchSize is the number of instances processed with each
+// call of an asynchronous operation, so that each call completes well
+// within the rpc timeout
+const _asyncOperationBatchSize uint32 = 1000
+
+// asyncOperationFunc processes the instances of a job in a range, and
+// returns the outcomes of the instances changed. An error fails all the
+// instances of the range without an outcome.
+type asyncOperationFunc func(
+	ctx context.Context,
+	instanceRange *task.InstanceRange,
+) ([]*task.InstanceOutcome, error)
+
+// recordInstanceRange adds the result of processing the instances in a
+// range to the operation.
+func recordInstanceRange(
+	op *asyncop.Operation,
+	instanceRange *task.InstanceRange,
+	outcomes []*task.InstanceOutcome,
+	err error) {
+	if err != nil {
+		done := make(map[uint32]bool)
+		for _, outcome := range outcomes {
+			done[outcome.GetInstanceId()] = true
+		}
+		for i := instanceRange.GetFrom(); i < instanceRange.GetTo(); i++ {
+			if !done[i] {
+				outcomes = append(outcomes, &task.InstanceOutcome{
+					InstanceId: i,
+					Message:    err.Error(),
+				})
+			}
+		}
+	}
+	op.Record(
+		instanceRange.GetTo()-instanceRange.GetFrom(),
+		toOperationOutcomes(outcomes),
+		err)
+}
+
+// recordInstanceOutcomes adds the outcomes of instances processed one by
+// one to the operation.
+func recordInstanceOutcomes(
+	op *asyncop.Operation,
+	outcomes []*task.InstanceOutcome) {
+	op.Record(uint32(len(outcomes)), toOperationOutcomes(outcomes), nil)
+}
+
+// toOperationOutcomes converts the outcomes of instances to the outcomes
+// of the items of an operation.
+func toOperationOutcomes(
+	outcomes []*task.InstanceOutcome) []asyncop.Outcome {
+	var result []asyncop.Outcome
+	for _, outcome := range outcomes {
+		result = append(result, asyncop.Outcome{
+			Item:      outcome.GetInstanceId(),
+			Succeeded: outcome.GetSucceeded(),
+			Message:   outcome.GetMessage(),
+		})
+	}
+	return result
+}
+
+// operationStatus returns the progress of an operation on the tasks of
+// a job.
+func operationStatus(op *asyncop.Operation) *task.GetOperationStatusResponse {
+	status := op.Status()
+
+	outcomes := []*task.InstanceOutcome{}
+	for _, outcome := range status.Outcomes {
+		outcomes = append(outcomes, &task.InstanceOutcome{
+			InstanceId: outcome.Item.(uint32),
+			Succeeded:  outcome.Succeeded,
+			Message:    outcome.Message,
+		})
+	}
+
+	state := task.OperationState_OPERATION_STATE_RUNNING
+	switch status.State {
+	case asyncop.Succeeded:
+		state = task.OperationState_OPERATION_STATE_SUCCEEDED
+	case asyncop.Failed:
+		state = task.OperationState_OPERATION_STATE_FAILED
+	}
+
+	return &task.GetOperationStatusResponse{
+		JobId:              &peloton.JobID{Value: status.Subject},
+		Operation:          status.Name,
+		State:              state,
+		TotalInstances:     status.Total,
+		ProcessedInstances: status.Processed,
+		Outcomes:           outcomes,
+	}
+}
+
+// runAsyncOperation processes the instance ranges of an operation on a
+// job one after the other, each with its own timeout. The ranges not
+// processed yet are failed once the operation is stopped. finish, if
+// set, is called once all the ranges have been processed.
+func runAsyncOperation(
+	op *asyncop.Operation,
+	ranges []*task.InstanceRange,
+	process asyncOperationFunc,
+	finish func()) {
+	for _, instanceRange := range ranges {
+		if op.Context().Err() != nil {
+			recordInstanceRange(op, instanceRange, nil, asyncop.ErrStopped)
+			continue
+		}
+
+		ctx, cancelFunc := context.WithTimeout(op.Context(), _rpcTimeout)
+		outcomes, err := process(ctx, instanceRange)
+		cancelFunc()
+
+		if err != nil {
+			log.WithError(err).
+				WithFields(log.Fields{
+					"job_id":       op.Subject(),
+					"operation":    op.Name(),
+					"operation_id": op.ID(),
+					"range":        instanceRange,
+				}).
+				Warn("failed to process instances of async operation")
+		}
+		recordInstanceRange(op, instanceRange, outcomes, err)
+	}
+
+	if finish != nil {
+		finish()
+	}
+	op.Complete()
+}
+
+// splitInstanceRanges clips the ranges to the instance count, and splits
+// them into ranges of at most batchSize instances. The ranges are not
+// split if batchSize is 0. All the instances are covered if no range
+// is given.
+func splitInstanceRanges(
+	ranges []*task.InstanceRange,
+	instanceCount uint32,
+	batchSize uint32) []*task.InstanceRange {
+	if len(ranges) == 0 {
+		ranges = []*task.InstanceRange{{From: 0, To: instanceCount}}
+	}
+
+	var result []*task.InstanceRange
+	for _, r := range ranges {
+		to := r.GetTo()
+		if to > instanceCount {
+			to = instanceCount
+		}
+		for from := r.GetFrom(); from < to; {
+			end := to
+			if batchSize > 0 && end-from > batchSize {
+				end = from + batchSize
+			}
+			result = append(result, &task.InstanceRange{From: from, To: end})
+			from = end
+		}
+	}
+	return result
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasksvc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/uber/peloton/pkg/jobmgr/asyncop"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitInstanceRanges(t *testing.T) {
+	tests := []struct {
+		ranges    []*task.InstanceRange
+		count     uint32
+		batchSize uint32
+		expected  []*task.InstanceRange
+	}{
+		{
+			count:     5,
+			batchSize: 2,
+			expected: []*task.InstanceRange{
+				{From: 0, To: 2}, {From: 2, To: 4}, {From: 4, To: 5},
+			},
+		},
+		{
+			count:     5,
+			batchSize: 0,
+			expected:  []*task.InstanceRange{{From: 0, To: 5}},
+		},
+		{
+			ranges:    []*task.InstanceRange{{From: 1, To: 4}, {From: 8, To: 20}},
+			count:     10,
+			batchSize: 2,
+			expected: []*task.InstanceRange{
+				{From: 1, To: 3}, {From: 3, To: 4}, {From: 8, To: 10},
+			},
+		},
+		{
+			ranges:    []*task.InstanceRange{{From: 12, To: 20}},
+			count:     10,
+			batchSize: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected,
+			splitInstanceRanges(tt.ranges, tt.count, tt.batchSize))
+	}
+}
+
+func TestRunAsyncOperation(t *testing.T) {
+	operations := asyncop.NewTracker(0)
+	op, err := operations.Add("Stop", "job", 4)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		task.OperationState_OPERATION_STATE_RUNNING,
+		operationStatus(op).GetState())
+
+	finished := false
+	runAsyncOperation(
+		op,
+		[]*task.InstanceRange{{From: 0, To: 2}, {From: 2, To: 4}},
+		func(
+			_ context.Context,
+			instanceRange *task.InstanceRange,
+		) ([]*task.InstanceOutcome, error) {
+			if instanceRange.GetFrom() == 0 {
+				return getInstanceOutcomes([]uint32{1}, []uint32{0}), nil
+			}
+			return getInstanceOutcomes([]uint32{2}, nil),
+				errors.New("test error")
+		},
+		func() { finished = true },
+	)
+	assert.True(t, finished)
+
+	status := operationStatus(op)
+	assert.Equal(t, &peloton.JobID{Value: "job"}, status.GetJobId())
+	assert.Equal(t, "Stop", status.GetOperation())
+	assert.Equal(t, task.OperationState_OPERATION_STATE_FAILED, status.GetState())
+	assert.Equal(t, uint32(4), status.GetTotalInstances())
+	assert.Equal(t, uint32(4), status.GetProcessedInstances())
+	assert.Equal(t, []*task.InstanceOutcome{
+		{InstanceId: 0, Message: "failed to update the task runtime"},
+		{InstanceId: 1, Succeeded: true},
+		{InstanceId: 2, Succeeded: true},
+		{InstanceId: 3, Message: "test error"},
+	}, status.GetOutcomes())
+}
+
+func TestRunAsyncOperationStopped(t *testing.T) {
+	operations := asyncop.NewTracker(0)
+	op, err := operations.Add("Stop", "job", 4)
+	assert.NoError(t, err)
+
+	var processed []uint32
+	runAsyncOperation(
+		op,
+		[]*task.InstanceRange{{From: 0, To: 2}, {From: 2, To: 4}},
+		func(
+			ctx context.Context,
+			instanceRange *task.InstanceRange,
+		) ([]*task.InstanceOutcome, error) {
+			processed = append(processed, instanceRange.GetFrom())
+			// the job manager loses leadership while processing the
+			// first range
+			operations.Stop()
+			assert.Error(t, ctx.Err())
+			return getInstanceOutcomes([]uint32{0, 1}, nil), nil
+		},
+		nil,
+	)
+	assert.Equal(t, []uint32{0}, processed)
+
+	status := operationStatus(op)
+	assert.Equal(t, task.OperationState_OPERATION_STATE_FAILED, status.GetState())
+	assert.Equal(t, uint32(4), status.GetProcessedInstances())
+	assert.Equal(t, []*task.InstanceOutcome{
+		{InstanceId: 0, Succeeded: true},
+		{InstanceId: 1, Succeeded: true},
+		{InstanceId: 2, Message: asyncop.ErrStopped.Error()},
+		{InstanceId: 3, Message: asyncop.ErrStopped.Error()},
+	}, status.GetOutcomes())
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasksvc
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber/peloton/pkg/common/lru"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/uber-go/tally"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const (
+	// _defaultMaxRateLimitBuckets is the default number of token buckets
+	// kept, the buckets of the least recent callers are evicted beyond it
+	_defaultMaxRateLimitBuckets = 10000
+
+	// _defaultRateLimitBucketTTL is the default time a token bucket is kept
+	// after the last request of its caller
+	_defaultRateLimitBucketTTL = 10 * time.Minute
+)
+
+// tokenBucket holds the tokens left for a caller of a procedure, which
+// are refilled at the rate of the procedure up to its burst.
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// rateLimiter is a yarpc unary inbound middleware which rejects the
+// requests of a caller of a procedure exceeding its rate limit.
+type rateLimiter struct {
+	sync.Mutex
+
+	config RateLimitConfig
+	// token buckets keyed by procedure and caller. The callers are set by
+	// the clients, so the buckets are bounded and expire to bound the
+	// memory used by callers which come and go.
+	buckets lru.Cache
+	scope   tally.Scope
+
+	// now returns the current time, overridden in tests
+	now func() time.Time
+}
+
+// ensure that rateLimiter implements the yarpc unary inbound middleware
+var _ middleware.UnaryInbound = (*rateLimiter)(nil)
+
+// newRateLimiter returns a new rate limiter for the config.
+func newRateLimiter(config RateLimitConfig, scope tally.Scope) *rateLimiter {
+	maxBuckets := config.MaxBuckets
+	if maxBuckets <= 0 {
+		maxBuckets = _defaultMaxRateLimitBuckets
+	}
+	bucketTTL := config.BucketTTL
+	if bucketTTL <= 0 {
+		bucketTTL = _defaultRateLimitBucketTTL
+	}
+	return &rateLimiter{
+		config:  config,
+		buckets: lru.New(maxBuckets, bucketTTL),
+		scope:   scope.SubScope("rate_limit"),
+		now:     time.Now,
+	}
+}
+
+// Handle rejects the request with a resource-exhausted error if its
+// caller exceeded the rate limit of the procedure, and otherwise
+// passes it to the handler.
+func (l *rateLimiter) Handle(
+	ctx context.Context,
+	req *transport.Request,
+	resw transport.ResponseWriter,
+	h transport.UnaryHandler,
+) error {
+	procedure := getProcedureName(req.Procedure)
+	if !l.allow(procedure, req.Caller) {
+		l.scope.Tagged(map[string]string{"procedure": procedure}).
+			Counter("throttled").Inc(1)
+		log.WithFields(log.Fields{
+			"procedure": procedure,
+			"caller":    req.Caller,
+		}).Debug("request throttled")
+		return yarpcerrors.ResourceExhaustedErrorf(
+			"rate limit exceeded for %s", procedure)
+	}
+	return h.Handle(ctx, req, resw)
+}
+
+// allow takes a token from the bucket of the caller of the procedure,
+// and returns false if there is no token left.
+func (l *rateLimiter) allow(procedure string, caller string) bool {
+	limit := l.config.getProcedureRateLimit(procedure)
+	if limit.Rate <= 0 {
+		return true
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	now := l.now()
+	key := procedure + "::" + caller
+	var bucket *tokenBucket
+	if value, ok := l.buckets.Get(key); ok {
+		bucket = value.(*tokenBucket)
+	} else {
+		bucket = &tokenBucket{
+			tokens:     float64(limit.Burst),
+			lastRefill: now,
+		}
+	}
+	// added back on every request so that the bucket expires only after
+	// the caller is idle for the TTL
+	l.buckets.Add(key, bucket)
+
+	bucket.tokens += now.Sub(bucket.lastRefill).Seconds() * limit.Rate
+	if bucket.tokens > float64(limit.Burst) {
+		bucket.tokens = float64(limit.Burst)
+	}
+	bucket.lastRefill = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// getProcedureName returns the method name of a yarpc procedure,
+// such as "Query" for "peloton.api.v0.task.TaskManager::Query".
+func getProcedureName(procedure string) string {
+	if i := strings.LastIndex(procedure, "::"); i >= 0 {
+		return procedure[i+2:]
+	}
+	return procedure
+}
+
+// applyUnaryInbound returns the procedures with the unary handlers wrapped
+// by the middleware, such as the rate limiter.
+func applyUnaryInbound(
+	procedures []transport.Procedure,
+	mw middleware.UnaryInbound,
+) []transport.Procedure {
+	for i, p := range procedures {
+		if p.HandlerSpec.Type() != transport.Unary {
+			continue
+		}
+		procedures[i].HandlerSpec = transport.NewUnaryHandlerSpec(
+			middleware.ApplyUnaryInbound(p.HandlerSpec.Unary(), mw))
+	}
+	return procedures
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasksvc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// testUnaryHandler counts the requests passed to it
+type testUnaryHandler struct {
+	calls int
+}
+
+func (h *testUnaryHandler) Handle(
+	ctx context.Context,
+	req *transport.Request,
+	resw transport.ResponseWriter,
+) error {
+	h.calls++
+	return nil
+}
+
+func newTestRateLimiter(scope tally.Scope) (*rateLimiter, *time.Time) {
+	now := time.Unix(1000, 0)
+	limiter := newRateLimiter(RateLimitConfig{
+		Enabled: true,
+		Default: ProcedureRateLimit{Rate: 0},
+		Procedures: map[string]ProcedureRateLimit{
+			"Query": {Rate: 2, Burst: 4},
+		},
+	}, scope)
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+// TestRateLimiterAllow tests the requests of each caller of a procedure
+// are limited by a token bucket
+func TestRateLimiterAllow(t *testing.T) {
+	limiter, now := newTestRateLimiter(tally.NoopScope)
+
+	// burst is allowed at once
+	for i := 0; i < 4; i++ {
+		assert.True(t, limiter.allow("Query", "client-a"))
+	}
+	assert.False(t, limiter.allow("Query", "client-a"))
+
+	// other callers have their own bucket
+	assert.True(t, limiter.allow("Query", "client-b"))
+
+	// procedures without a rate are not limited
+	for i := 0; i < 10; i++ {
+		assert.True(t, limiter.allow("Get", "client-a"))
+	}
+
+	// tokens are refilled at the rate
+	*now = now.Add(500 * time.Millisecond)
+	assert.True(t, limiter.allow("Query", "client-a"))
+	assert.False(t, limiter.allow("Query", "client-a"))
+
+	// tokens are refilled up to the burst
+	*now = now.Add(time.Hour)
+	for i := 0; i < 4; i++ {
+		assert.True(t, limiter.allow("Query", "client-a"))
+	}
+	assert.False(t, limiter.allow("Query", "client-a"))
+}
+
+// TestRateLimiterBucketsBounded tests the token buckets of the least
+// recent callers are evicted beyond the maximum number of buckets
+func TestRateLimiterBucketsBounded(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{
+		Enabled:    true,
+		Procedures: map[string]ProcedureRateLimit{"Query": {Rate: 1}},
+		MaxBuckets: 2,
+	}, tally.NoopScope)
+
+	assert.True(t, limiter.allow("Query", "client-a"))
+	assert.False(t, limiter.allow("Query", "client-a"))
+	assert.True(t, limiter.allow("Query", "client-b"))
+	assert.True(t, limiter.allow("Query", "client-c"))
+	assert.Equal(t, 2, limiter.buckets.Len())
+
+	// the bucket of client-a was evicted, so it starts full again
+	assert.True(t, limiter.allow("Query", "client-a"))
+	assert.Equal(t, 2, limiter.buckets.Len())
+}
+
+// TestRateLimiterHandle tests throttled requests are rejected with a
+// resource-exhausted error and counted
+func TestRateLimiterHandle(t *testing.T) {
+	scope := tally.NewTestScope("", map[string]string{})
+	limiter, _ := newTestRateLimiter(scope)
+	h := &testUnaryHandler{}
+	req := &transport.Request{
+		Caller:    "client-a",
+		Procedure: "peloton.api.v0.task.TaskManager::Query",
+	}
+
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, limiter.Handle(context.Background(), req, nil, h))
+	}
+	err := limiter.Handle(context.Background(), req, nil, h)
+	assert.True(t, yarpcerrors.IsResourceExhausted(err))
+	assert.Equal(t, 4, h.calls)
+
+	counters := scope.Snapshot().Counters()
+	assert.Equal(t, int64(1),
+		counters["rate_limit.throttled+procedure=Query"].Value())
+}
+
+// TestGetProcedureRateLimit tests the default rate limit and burst
+func TestGetProcedureRateLimit(t *testing.T) {
+	config := RateLimitConfig{
+		Default: ProcedureRateLimit{Rate: 10},
+		Procedures: map[string]ProcedureRateLimit{
+			"GetPodEvents": {Rate: 0.5},
+		},
+	}
+
+	assert.Equal(t,
+		ProcedureRateLimit{Rate: 10, Burst: 10},
+		config.getProcedureRateLimit("Query"))
+	assert.Equal(t,
+		ProcedureRateLimit{Rate: 0.5, Burst: 1},
+		config.getProcedureRateLimit("GetPodEvents"))
+}
+
+// TestGetProcedureName tests the method name is parsed from procedures
+func TestGetProcedureName(t *testing.T) {
+	assert.Equal(t, "Query",
+		getProcedureName("peloton.api.v0.task.TaskManager::Query"))
+	assert.Equal(t, "Query", getProcedureName("Query"))
+}
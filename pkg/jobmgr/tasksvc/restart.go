@@ -0,0 +1,335 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasksvc
+
+import (
+	"context"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	commongoalstate "github.com/uber/peloton/pkg/common/goalstate"
+	"github.com/uber/peloton/pkg/common/util"
+	"github.com/uber/peloton/pkg/jobmgr/asyncop"
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+	jobmgrcommon "github.com/uber/peloton/pkg/jobmgr/common"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const (
+	_defaultRestartWaveCheckInterval = 5 * time.Second
+	_defaultRestartWaveTimeout       = 30 * time.Minute
+)
+
+// errRestartNotLeader fails the remaining waves of a restart when the job
+// manager loses leadership.
+var errRestartNotLeader = errors.New("job manager is not the leader")
+
+// RestartConfig for the restarts of tasks in waves
+type RestartConfig struct {
+	// Interval at which the instances of a wave are checked, to start
+	// the next wave once they are healthy
+	WaveCheckInterval time.Duration `yaml:"wave_check_interval"`
+
+	// Maximum time the instances of a wave are waited for to be healthy
+	// after the batch delay. The remaining waves of the restart fail if
+	// they are not.
+	WaveTimeout time.Duration `yaml:"wave_timeout"`
+}
+
+func (c *RestartConfig) normalize() {
+	if c.WaveCheckInterval == 0 {
+		c.WaveCheckInterval = _defaultRestartWaveCheckInterval
+	}
+	if c.WaveTimeout == 0 {
+		c.WaveTimeout = _defaultRestartWaveTimeout
+	}
+}
+
+// hasRestartWaves returns whether the instances of the restart are
+// restarted in waves.
+func hasRestartWaves(req *task.RestartRequest) bool {
+	return req.GetBatchSize() > 0 || req.GetMaxInstancesPerFailureDomain() > 0
+}
+
+// restartTasksInWaves restarts the first wave of instances, and the next
+// waves in the background as an asynchronous operation. The desired run of
+// the instances of a wave is only persisted once the batch delay has passed
+// since the previous wave started, and the instances of the previous wave
+// run their new run and are healthy. The remaining waves are not restarted
+// if the job manager loses leadership or shuts down.
+func (m *serviceHandler) restartTasksInWaves(
+	ctx context.Context,
+	cachedJob cached.Job,
+	req *task.RestartRequest,
+	runtimeDiffs map[uint32]jobmgrcommon.RuntimeDiff,
+	domains map[uint32]string) (*task.RestartResponse, error) {
+	instanceIDs := make([]uint32, 0, len(runtimeDiffs))
+	for instanceID := range runtimeDiffs {
+		instanceIDs = append(instanceIDs, instanceID)
+	}
+	sortInstanceIDs(instanceIDs)
+	waves := getRestartWaveInstances(
+		instanceIDs,
+		getRestartWaves(
+			instanceIDs,
+			domains,
+			req.GetBatchSize(),
+			req.GetMaxInstancesPerFailureDomain()))
+
+	if len(waves) > 0 {
+		firstDiffs := make(map[uint32]jobmgrcommon.RuntimeDiff)
+		for _, instanceID := range waves[0] {
+			firstDiffs[instanceID] = runtimeDiffs[instanceID]
+		}
+		if err := m.restartWave(
+			ctx, cachedJob, req.GetJobId(), firstDiffs); err != nil {
+			m.metrics.TaskRestartFail.Inc(1)
+			return nil, err
+		}
+	}
+
+	op, err := m.operations.Add(
+		"Restart", req.GetJobId().GetValue(), uint32(len(instanceIDs)))
+	if err != nil {
+		m.metrics.TaskRestartFail.Inc(1)
+		return nil, err
+	}
+	if len(waves) > 0 {
+		recordInstanceOutcomes(op, getInstanceOutcomes(waves[0], nil))
+	}
+	go m.runRestartWaves(op, cachedJob, req, waves)
+
+	log.WithFields(log.Fields{
+		"job_id":          req.GetJobId().GetValue(),
+		"operation_id":    op.ID(),
+		"total_instances": len(instanceIDs),
+		"waves":           len(waves),
+	}).Info("restart in waves started")
+	m.metrics.TaskRestart.Inc(1)
+	return &task.RestartResponse{OperationId: op.ID()}, nil
+}
+
+// runRestartWaves restarts the waves after the first one, each once the
+// previous one is done.
+func (m *serviceHandler) runRestartWaves(
+	op *asyncop.Operation,
+	cachedJob cached.Job,
+	req *task.RestartRequest,
+	waves [][]uint32) {
+	defer op.Complete()
+
+	batchDelay := time.Duration(req.GetBatchDelaySeconds()) * time.Second
+	waveStart := time.Now()
+	for i := 1; i < len(waves); i++ {
+		err := m.waitRestartWave(
+			op.Context(), cachedJob, waves[i-1], waveStart.Add(batchDelay))
+		if err == nil {
+			waveStart = time.Now()
+			err = m.restartNextWave(op.Context(), cachedJob, req, waves[i])
+		}
+		if err != nil {
+			log.WithError(err).
+				WithFields(log.Fields{
+					"job_id":       req.GetJobId().GetValue(),
+					"operation_id": op.ID(),
+					"wave":         i,
+				}).
+				Warn("failed to restart wave of instances")
+			m.metrics.TaskRestartWaveFail.Inc(1)
+			for _, wave := range waves[i:] {
+				recordInstanceOutcomes(op, getFailedInstanceOutcomes(wave, err))
+			}
+			return
+		}
+		recordInstanceOutcomes(op, getInstanceOutcomes(waves[i], nil))
+	}
+}
+
+// restartNextWave restarts a wave of instances after the first one. The
+// runtime diffs are computed again, as the instances may have been
+// restarted since the restart started.
+func (m *serviceHandler) restartNextWave(
+	ctx context.Context,
+	cachedJob cached.Job,
+	req *task.RestartRequest,
+	instanceIDs []uint32) error {
+	ctx, cancelFunc := context.WithTimeout(ctx, _rpcTimeout)
+	defer cancelFunc()
+
+	runtimeDiffs, _, err := m.getRuntimeDiffsForRestart(
+		ctx,
+		cachedJob,
+		getInstanceRanges(instanceIDs),
+		req.GetOperationContext())
+	if err != nil {
+		return err
+	}
+	return m.restartWave(ctx, cachedJob, req.GetJobId(), runtimeDiffs)
+}
+
+// restartWave persists the desired run of the instances of a wave, and
+// enqueues them into the goal state engine.
+func (m *serviceHandler) restartWave(
+	ctx context.Context,
+	cachedJob cached.Job,
+	jobID *peloton.JobID,
+	runtimeDiffs map[uint32]jobmgrcommon.RuntimeDiff) error {
+	if err := cachedJob.PatchTasks(ctx, runtimeDiffs); err != nil {
+		return err
+	}
+	now := time.Now()
+	for instanceID := range runtimeDiffs {
+		m.goalStateDriver.EnqueueTaskWithPriority(
+			jobID,
+			instanceID,
+			now,
+			commongoalstate.PriorityHigh)
+	}
+	return nil
+}
+
+// waitRestartWave waits for the instances of a wave to be restarted, and
+// for notBefore to pass. Returns an error if the job manager loses
+// leadership or ctx is cancelled, or if the instances are not restarted
+// within the wave timeout after notBefore.
+func (m *serviceHandler) waitRestartWave(
+	ctx context.Context,
+	cachedJob cached.Job,
+	instanceIDs []uint32,
+	notBefore time.Time) error {
+	deadline := notBefore.Add(m.restart.WaveTimeout)
+	for {
+		if ctx.Err() != nil {
+			return asyncop.ErrStopped
+		}
+		if !m.candidate.IsLeader() {
+			return errRestartNotLeader
+		}
+
+		now := time.Now()
+		if !now.Before(notBefore) {
+			restarted, err := m.isRestartWaveDone(ctx, cachedJob, instanceIDs)
+			if err != nil {
+				log.WithError(err).
+					WithField("job_id", cachedJob.ID().GetValue()).
+					Warn("failed to check the instances of restart wave")
+			}
+			if restarted {
+				return nil
+			}
+		}
+		if now.After(deadline) {
+			return yarpcerrors.DeadlineExceededErrorf(
+				"instances of the previous wave were not healthy after %v",
+				m.restart.WaveTimeout)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(m.restart.WaveCheckInterval):
+		}
+	}
+}
+
+// isRestartWaveDone returns whether all the instances of a wave run their
+// new run, and are healthy or have completed.
+func (m *serviceHandler) isRestartWaveDone(
+	ctx context.Context,
+	cachedJob cached.Job,
+	instanceIDs []uint32) (bool, error) {
+	ctx, cancelFunc := context.WithTimeout(ctx, _rpcTimeout)
+	defer cancelFunc()
+
+	for _, instanceID := range instanceIDs {
+		cachedTask := cachedJob.GetTask(instanceID)
+		if cachedTask == nil {
+			// the instance was removed from the job
+			continue
+		}
+		runtime, err := cachedTask.GetRuntime(ctx)
+		if err != nil {
+			return false, err
+		}
+		if !isInstanceRestarted(runtime) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// isInstanceRestarted returns whether the task runs its desired run, and
+// is running and healthy, or has completed.
+func isInstanceRestarted(runtime *task.RuntimeInfo) bool {
+	if runtime.GetMesosTaskId().GetValue() !=
+		runtime.GetDesiredMesosTaskId().GetValue() {
+		return false
+	}
+	if runtime.GetState() == task.TaskState_RUNNING {
+		return runtime.GetHealthy() == task.HealthState_DISABLED ||
+			runtime.GetHealthy() == task.HealthState_HEALTHY
+	}
+	return util.IsPelotonStateTerminal(runtime.GetState()) &&
+		util.IsPelotonStateTerminal(runtime.GetGoalState())
+}
+
+// getRestartWaveInstances groups the sorted instances by the wave they are
+// restarted in.
+func getRestartWaveInstances(
+	instanceIDs []uint32,
+	waves []uint32) [][]uint32 {
+	var result [][]uint32
+	for i, instanceID := range instanceIDs {
+		for len(result) <= int(waves[i]) {
+			result = append(result, nil)
+		}
+		result[waves[i]] = append(result[waves[i]], instanceID)
+	}
+	return result
+}
+
+// getInstanceRanges returns the ranges covering the sorted instances.
+func getInstanceRanges(instanceIDs []uint32) []*task.InstanceRange {
+	var ranges []*task.InstanceRange
+	for _, instanceID := range instanceIDs {
+		if n := len(ranges); n > 0 && ranges[n-1].GetTo() == instanceID {
+			ranges[n-1].To++
+			continue
+		}
+		ranges = append(ranges, &task.InstanceRange{
+			From: instanceID,
+			To:   instanceID + 1,
+		})
+	}
+	return ranges
+}
+
+// getFailedInstanceOutcomes returns the outcomes of the instances of an
+// asynchronous operation failed by err.
+func getFailedInstanceOutcomes(
+	instanceIDs []uint32,
+	err error) []*task.InstanceOutcome {
+	var outcomes []*task.InstanceOutcome
+	for _, instanceID := range instanceIDs {
+		outcomes = append(outcomes, &task.InstanceOutcome{
+			InstanceId: instanceID,
+			Message:    err.Error(),
+		})
+	}
+	return outcomes
+}
@@ -16,21 +16,28 @@ package updatesvc
 
 import (
 	"context"
+	"sort"
 	"time"
 
 	"github.com/uber/peloton/.gen/peloton/api/v0/job"
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/respool"
 	"github.com/uber/peloton/.gen/peloton/api/v0/update"
 	"github.com/uber/peloton/.gen/peloton/api/v0/update/svc"
 	"github.com/uber/peloton/.gen/peloton/private/models"
 
 	"github.com/uber/peloton/pkg/common"
+	"github.com/uber/peloton/pkg/common/rpc"
 	"github.com/uber/peloton/pkg/jobmgr/cached"
 	"github.com/uber/peloton/pkg/jobmgr/goalstate"
+	jobconfig "github.com/uber/peloton/pkg/jobmgr/job/config"
+	"github.com/uber/peloton/pkg/jobmgr/job/provenance"
 	jobutil "github.com/uber/peloton/pkg/jobmgr/util/job"
 	"github.com/uber/peloton/pkg/storage"
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
 
 	"github.com/pborman/uuid"
+	log "github.com/sirupsen/logrus"
 	"github.com/uber-go/tally"
 	"go.uber.org/yarpc"
 	"go.uber.org/yarpc/yarpcerrors"
@@ -41,16 +48,30 @@ func InitServiceHandler(
 	d *yarpc.Dispatcher,
 	parent tally.Scope,
 	jobStore storage.JobStore,
+	taskStore storage.TaskStore,
 	updateStore storage.UpdateStore,
+	ormStore *ormobjects.Store,
 	goalStateDriver goalstate.Driver,
 	jobFactory cached.JobFactory,
+	configVerifier provenance.Verifier,
+	taskBackoffBounds jobconfig.TaskBackoffBounds,
+	tlsProvider *rpc.TLSProvider,
 ) {
 	handler := &serviceHandler{
 		jobStore:        jobStore,
+		taskStore:       taskStore,
 		updateStore:     updateStore,
+		runDurationOps:  ormobjects.NewRunDurationOps(ormStore),
 		goalStateDriver: goalStateDriver,
 		jobFactory:      jobFactory,
 		metrics:         NewMetrics(parent.SubScope("jobmgr").SubScope("update")),
+		configVerifier:  configVerifier,
+		respoolClient: respool.NewResourceManagerYARPCClient(
+			d.ClientConfig(common.PelotonResourceManager),
+		),
+		tlsProvider: tlsProvider,
+
+		taskBackoffBounds: taskBackoffBounds,
 	}
 
 	d.Register(svc.BuildUpdateServiceYARPCProcedures(handler))
@@ -59,10 +80,20 @@ func InitServiceHandler(
 // serviceHandler implements peloton.api.update.svc
 type serviceHandler struct {
 	jobStore        storage.JobStore
+	taskStore       storage.TaskStore
 	updateStore     storage.UpdateStore
+	runDurationOps  ormobjects.RunDurationOps
 	goalStateDriver goalstate.Driver
 	jobFactory      cached.JobFactory
 	metrics         *Metrics
+	configVerifier  provenance.Verifier
+	respoolClient   respool.ResourceManagerYARPCClient
+	// identifies the callers by their client certificates, nil if TLS
+	// is disabled
+	tlsProvider *rpc.TLSProvider
+
+	// bounds of the cluster on the task backoff policy of the jobs
+	taskBackoffBounds jobconfig.TaskBackoffBounds
 }
 
 // validateJobConfigUpdate validates that the job configuration
@@ -91,9 +122,34 @@ func (h *serviceHandler) validateJobConfigUpdate(
 			"resource pool identifier is immutable")
 	}
 
+	// the task backoff policy is bounded as when the job is created
+	if err := jobconfig.ValidateTaskBackoffPolicy(
+		newJobConfig.GetTaskBackoffPolicy(), h.taskBackoffBounds); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// verifyConfigSignature verifies the signature of the job config, and
+// returns the system labels recording its provenance.
+func (h *serviceHandler) verifyConfigSignature(
+	config *job.JobConfig,
+	signature *job.ConfigSignature) ([]*peloton.Label, error) {
+	if h.configVerifier == nil {
+		return nil, nil
+	}
+
+	configProvenance, err := h.configVerifier.Verify(config, signature)
+	if err != nil {
+		log.WithError(err).
+			WithField("key_id", signature.GetKeyId()).
+			Warn("Failed to verify job config signature")
+		return nil, yarpcerrors.InvalidArgumentErrorf("%v", err)
+	}
+	return configProvenance.Labels(), nil
+}
+
 // validateJobRuntime validates that the job state allows updating it
 func (h *serviceHandler) validateJobRuntime(jobRuntime *job.RuntimeInfo) error {
 	// cannot update a job which is still being created
@@ -105,57 +161,89 @@ func (h *serviceHandler) validateJobRuntime(jobRuntime *job.RuntimeInfo) error {
 	return nil
 }
 
-// Create creates an update for a given job ID.
-func (h *serviceHandler) CreateUpdate(
+// validateUpdateRequest validates that the job can be updated with the
+// new configuration, and returns the job runtime along with the previous
+// job configuration.
+func (h *serviceHandler) validateUpdateRequest(
 	ctx context.Context,
-	req *svc.CreateUpdateRequest) (*svc.CreateUpdateResponse, error) {
-	h.metrics.UpdateAPICreate.Inc(1)
-
-	jobID := req.GetJobId()
+	jobID *peloton.JobID,
+	jobConfig *job.JobConfig,
+	updateConfig *update.UpdateConfig,
+) (*job.RuntimeInfo, *job.JobConfig, *models.ConfigAddOn, error) {
 	jobUUID := uuid.Parse(jobID.GetValue())
 	if jobUUID == nil {
 		// job uuid is not a uuid
-		h.metrics.UpdateCreateFail.Inc(1)
-		return nil, yarpcerrors.InvalidArgumentErrorf(
+		return nil, nil, nil, yarpcerrors.InvalidArgumentErrorf(
 			"JobID must be of UUID format")
 	}
 
-	if req.GetUpdateConfig().GetInPlace() {
-		return nil, yarpcerrors.UnimplementedErrorf("in-place update is not supported yet")
+	if updateConfig.GetInPlace() {
+		return nil, nil, nil, yarpcerrors.UnimplementedErrorf(
+			"in-place update is not supported yet")
 	}
 
 	// Validate that the job does exist
 	jobRuntime, err := h.jobStore.GetJobRuntime(ctx, jobID.GetValue())
 	if err != nil {
-		h.metrics.UpdateCreateFail.Inc(1)
-		return nil, yarpcerrors.NotFoundErrorf("job not found")
+		return nil, nil, nil, yarpcerrors.NotFoundErrorf("job not found")
 	}
 
 	// validate the job is in a state where it can be updated
 	if err := h.validateJobRuntime(jobRuntime); err != nil {
-		h.metrics.UpdateCreateFail.Inc(1)
-		return nil, err
+		return nil, nil, nil, err
 	}
 
-	jobConfig := req.GetJobConfig()
-
 	// Get previous job configuration
 	prevJobConfig, prevConfigAddOn, err := h.jobStore.GetJobConfig(ctx, jobID.GetValue())
 	if err != nil {
-		h.metrics.UpdateCreateFail.Inc(1)
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	// check that job type is service
 	if prevJobConfig.GetType() != job.JobType_SERVICE {
-		h.metrics.UpdateCreateFail.Inc(1)
-		return nil, yarpcerrors.InvalidArgumentErrorf(
+		return nil, nil, nil, yarpcerrors.InvalidArgumentErrorf(
 			"job must be of type service")
 	}
 
 	// validate the new configuration
 	if err = h.validateJobConfigUpdate(
 		ctx, jobID, prevJobConfig, jobConfig); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return jobRuntime, prevJobConfig, prevConfigAddOn, nil
+}
+
+// Create creates an update for a given job ID.
+func (h *serviceHandler) CreateUpdate(
+	ctx context.Context,
+	req *svc.CreateUpdateRequest) (*svc.CreateUpdateResponse, error) {
+	h.metrics.UpdateAPICreate.Inc(1)
+
+	jobID := req.GetJobId()
+	jobConfig := req.GetJobConfig()
+
+	// Verify the signature before the config is modified by Peloton
+	provenanceLabels, err := h.verifyConfigSignature(
+		jobConfig, req.GetSignature())
+	if err != nil {
+		h.metrics.UpdateCreateFail.Inc(1)
+		return nil, err
+	}
+
+	jobRuntime, prevJobConfig, prevConfigAddOn, err := h.validateUpdateRequest(
+		ctx, jobID, jobConfig, req.GetUpdateConfig())
+	if err != nil {
+		h.metrics.UpdateCreateFail.Inc(1)
+		return nil, err
+	}
+
+	if err = jobconfig.ValidateTaskSizeOverrideUpdate(
+		ctx,
+		h.respoolClient,
+		h.tlsProvider.PeerID(ctx),
+		jobConfig,
+		prevJobConfig); err != nil {
 		h.metrics.UpdateCreateFail.Inc(1)
 		return nil, err
 	}
@@ -167,7 +255,9 @@ func (h *serviceHandler) CreateUpdate(
 		}
 	}
 	configAddOn := &models.ConfigAddOn{
-		SystemLabels: jobutil.ConstructSystemLabels(jobConfig, respoolPath),
+		SystemLabels: append(
+			jobutil.ConstructSystemLabels(jobConfig, respoolPath),
+			provenanceLabels...),
 	}
 	// add this new update to cache and DB
 	cachedJob := h.jobFactory.AddJob(jobID)
@@ -459,6 +549,122 @@ func (h *serviceHandler) RollbackUpdate(ctx context.Context,
 		"UpdateService.RollbackUpdate is not implemented")
 }
 
+// PlanUpdate computes the rollout plan of an update without creating it.
+func (h *serviceHandler) PlanUpdate(
+	ctx context.Context,
+	req *svc.PlanUpdateRequest,
+) (*svc.PlanUpdateResponse, error) {
+	h.metrics.UpdateAPIPlan.Inc(1)
+
+	jobID := req.GetJobId()
+	jobConfig := req.GetJobConfig()
+
+	_, prevJobConfig, _, err := h.validateUpdateRequest(
+		ctx, jobID, jobConfig, req.GetUpdateConfig())
+	if err != nil {
+		h.metrics.UpdatePlanFail.Inc(1)
+		return nil, err
+	}
+
+	instancesAdded, instancesUpdated, instancesRemoved, instancesUnchanged, err :=
+		cached.GetInstancesToProcessForUpdate(
+			ctx, jobID, prevJobConfig, jobConfig, h.taskStore)
+	if err != nil {
+		h.metrics.UpdatePlanFail.Inc(1)
+		return nil, err
+	}
+	// removed instances are collected from a map
+	sort.Slice(instancesRemoved, func(i, j int) bool {
+		return instancesRemoved[i] < instancesRemoved[j]
+	})
+
+	batches := planUpdateBatches(
+		instancesAdded,
+		instancesUpdated,
+		instancesRemoved,
+		req.GetUpdateConfig().GetBatchSize(),
+	)
+
+	// the start history of the existing instances is used to estimate
+	// how long each batch waits for its instances to come up
+	var existingInstances []uint32
+	existingInstances = append(existingInstances, instancesUpdated...)
+	existingInstances = append(existingInstances, instancesUnchanged...)
+	startDuration := h.estimateInstanceStartDuration(
+		ctx, jobID, existingInstances)
+
+	var duration time.Duration
+	for _, batch := range batches {
+		if len(batch.GetInstancesAdded())+len(batch.GetInstancesUpdated()) > 0 {
+			duration += startDuration
+		}
+	}
+
+	h.metrics.UpdatePlan.Inc(1)
+	return &svc.PlanUpdateResponse{
+		InstanceChanges: getInstanceChanges(
+			instancesAdded,
+			instancesUpdated,
+			instancesRemoved,
+			instancesUnchanged,
+		),
+		Batches:                  batches,
+		EstimatedDurationSeconds: uint32(duration.Seconds()),
+	}, nil
+}
+
+// estimateInstanceStartDuration returns how long an instance of the job
+// is expected to take to start running. It is the median start latency of
+// the recent runs of the job if any were recorded, or else the average
+// start time of the current runs of a sample of the instances. It is 0
+// if neither is available.
+func (h *serviceHandler) estimateInstanceStartDuration(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	instances []uint32,
+) time.Duration {
+	runs, err := h.runDurationOps.GetAll(ctx, jobID)
+	if err != nil {
+		log.WithError(err).
+			WithField("job_id", jobID.GetValue()).
+			Warn("failed to get run durations to estimate update duration")
+	} else if len(runs) > 0 {
+		stats := jobutil.GetRunStatistics(runs)
+		return time.Duration(stats.GetStartLatency().GetP50Ms()) *
+			time.Millisecond
+	}
+
+	var total time.Duration
+	var count int64
+
+	for i, instID := range instances {
+		if i >= _planSampleSize {
+			break
+		}
+
+		events, err := h.taskStore.GetPodEvents(ctx, jobID.GetValue(), instID)
+		if err != nil {
+			log.WithError(err).
+				WithFields(log.Fields{
+					"job_id":      jobID.GetValue(),
+					"instance_id": instID,
+				}).
+				Warn("failed to get pod events to estimate update duration")
+			continue
+		}
+
+		if d, ok := getStartDuration(events); ok {
+			total += d
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0
+	}
+	return total / time.Duration(count)
+}
+
 func (h *serviceHandler) getCachedJobWithUpdateID(
 	ctx context.Context,
 	updateID *peloton.UpdateID,
@@ -16,8 +16,16 @@ package updatesvc
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/uber/peloton/.gen/mesos/v1"
 	"github.com/uber/peloton/.gen/peloton/api/v0/job"
@@ -25,6 +33,7 @@ import (
 	"github.com/uber/peloton/.gen/peloton/api/v0/task"
 	"github.com/uber/peloton/.gen/peloton/api/v0/update"
 	"github.com/uber/peloton/.gen/peloton/api/v0/update/svc"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
 	"github.com/uber/peloton/.gen/peloton/private/models"
 
 	"github.com/golang/mock/gomock"
@@ -34,8 +43,11 @@ import (
 	"github.com/uber/peloton/pkg/jobmgr/cached"
 	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
 	goalstatemocks "github.com/uber/peloton/pkg/jobmgr/goalstate/mocks"
+	"github.com/uber/peloton/pkg/jobmgr/job/provenance"
 	jobutil "github.com/uber/peloton/pkg/jobmgr/util/job"
 	storemocks "github.com/uber/peloton/pkg/storage/mocks"
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
+	objectmocks "github.com/uber/peloton/pkg/storage/objects/mocks"
 	"go.uber.org/yarpc/yarpcerrors"
 )
 
@@ -44,7 +56,9 @@ type UpdateSvcTestSuite struct {
 
 	ctrl            *gomock.Controller
 	jobStore        *storemocks.MockJobStore
+	taskStore       *storemocks.MockTaskStore
 	updateStore     *storemocks.MockUpdateStore
+	runDurationOps  *objectmocks.MockRunDurationOps
 	jobFactory      *cachedmocks.MockJobFactory
 	goalStateDriver *goalstatemocks.MockDriver
 	h               *serviceHandler
@@ -74,7 +88,9 @@ func (suite *UpdateSvcTestSuite) SetupTest() {
 	suite.ctrl = gomock.NewController(suite.T())
 
 	suite.jobStore = storemocks.NewMockJobStore(suite.ctrl)
+	suite.taskStore = storemocks.NewMockTaskStore(suite.ctrl)
 	suite.updateStore = storemocks.NewMockUpdateStore(suite.ctrl)
+	suite.runDurationOps = objectmocks.NewMockRunDurationOps(suite.ctrl)
 	suite.jobFactory = cachedmocks.NewMockJobFactory(suite.ctrl)
 	suite.goalStateDriver = goalstatemocks.NewMockDriver(suite.ctrl)
 
@@ -131,7 +147,9 @@ func (suite *UpdateSvcTestSuite) SetupTest() {
 
 	suite.h = &serviceHandler{
 		jobStore:        suite.jobStore,
+		taskStore:       suite.taskStore,
 		updateStore:     suite.updateStore,
+		runDurationOps:  suite.runDurationOps,
 		goalStateDriver: suite.goalStateDriver,
 		jobFactory:      suite.jobFactory,
 		metrics:         NewMetrics(tally.NoopScope),
@@ -189,6 +207,89 @@ func (suite *UpdateSvcTestSuite) TestCreateSuccess() {
 	suite.NoError(err)
 }
 
+// setupConfigVerifier sets up a verifier requiring the job configs to be
+// signed, and returns the key signing them.
+func (suite *UpdateSvcTestSuite) setupConfigVerifier() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	suite.NoError(err)
+	buffer, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	suite.NoError(err)
+
+	suite.h.configVerifier, err = provenance.NewVerifier(
+		&provenance.Config{
+			RequireSignature: true,
+			PublicKeys: map[string]string{
+				"ci": string(pem.EncodeToMemory(&pem.Block{
+					Type:  "PUBLIC KEY",
+					Bytes: buffer,
+				})),
+			},
+		})
+	suite.NoError(err)
+	return key
+}
+
+// TestCreateSignedConfig tests creating a job update with a signed config
+func (suite *UpdateSvcTestSuite) TestCreateSignedConfig() {
+	key := suite.setupConfigVerifier()
+	digest, err := provenance.Digest(suite.newJobConfig)
+	suite.NoError(err)
+	signature, err := key.Sign(rand.Reader, digest, crypto.SHA256)
+	suite.NoError(err)
+
+	suite.jobFactory.EXPECT().
+		AddJob(suite.jobID).
+		Return(suite.cachedJob)
+	suite.jobStore.EXPECT().
+		GetJobRuntime(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobRuntime, nil)
+	suite.jobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobConfig, &models.ConfigAddOn{}, nil)
+	suite.cachedJob.EXPECT().
+		CreateWorkflow(
+			gomock.Any(),
+			models.WorkflowType_UPDATE,
+			suite.updateConfig,
+			gomock.Any(),
+			gomock.Any(),
+			gomock.Any(),
+		).
+		Return(suite.updateID, nil, nil)
+	suite.goalStateDriver.EXPECT().
+		EnqueueUpdate(gomock.Any(), gomock.Any(), gomock.Any())
+
+	_, err = suite.h.CreateUpdate(
+		context.Background(),
+		&svc.CreateUpdateRequest{
+			JobId:        suite.jobID,
+			JobConfig:    suite.newJobConfig,
+			UpdateConfig: suite.updateConfig,
+			Signature: &job.ConfigSignature{
+				KeyId:     "ci",
+				Signature: signature,
+			},
+		},
+	)
+	suite.NoError(err)
+}
+
+// TestCreateUnsignedConfig tests creating a job update fails with an
+// unsigned config when signatures are required
+func (suite *UpdateSvcTestSuite) TestCreateUnsignedConfig() {
+	suite.setupConfigVerifier()
+
+	_, err := suite.h.CreateUpdate(
+		context.Background(),
+		&svc.CreateUpdateRequest{
+			JobId:        suite.jobID,
+			JobConfig:    suite.newJobConfig,
+			UpdateConfig: suite.updateConfig,
+		},
+	)
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
 // TestAddInstancesSuccess tests successfully add instances
 func (suite *UpdateSvcTestSuite) TestAddInstancesSuccess() {
 	suite.jobFactory.EXPECT().
@@ -518,6 +619,33 @@ func (suite *UpdateSvcTestSuite) TestCreateChangeRespoolID() {
 		"code:invalid-argument message:resource pool identifier is immutable")
 }
 
+// TestCreateInvalidTaskBackoffPolicy tests creating a job update with a
+// task backoff policy out of the bounds of the cluster
+func (suite *UpdateSvcTestSuite) TestCreateInvalidTaskBackoffPolicy() {
+	suite.newJobConfig.TaskBackoffPolicy = &job.TaskBackoffPolicy{
+		Multiplier: math.NaN(),
+	}
+
+	suite.jobStore.EXPECT().
+		GetJobRuntime(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobRuntime, nil)
+
+	suite.jobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobConfig, &models.ConfigAddOn{}, nil)
+
+	_, err := suite.h.CreateUpdate(
+		context.Background(),
+		&svc.CreateUpdateRequest{
+			JobId:        suite.jobID,
+			JobConfig:    suite.newJobConfig,
+			UpdateConfig: suite.updateConfig,
+		},
+	)
+
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
 // TestCreateAddUpdateFail tests failing to create the new update
 // in the DB during the create update request
 func (suite *UpdateSvcTestSuite) TestCreateAddUpdateFail() {
@@ -1148,3 +1276,231 @@ func (suite *UpdateSvcTestSuite) TestResumeProgressUpdateFails() {
 	)
 	suite.Error(err)
 }
+
+// TestPlanUpdateSuccess tests computing the plan of an update which
+// adds, restarts and removes instances
+func (suite *UpdateSvcTestSuite) TestPlanUpdateSuccess() {
+	suite.newJobConfig.InstanceCount = 5
+	now := time.Now()
+
+	suite.jobStore.EXPECT().
+		GetJobRuntime(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobRuntime, nil)
+
+	suite.jobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobConfig, &models.ConfigAddOn{}, nil)
+
+	// instance 4 is added and instance 5 is removed
+	taskRuntimes := make(map[uint32]*task.RuntimeInfo)
+	for _, instID := range []uint32{0, 1, 2, 3, 5} {
+		taskRuntimes[instID] = &task.RuntimeInfo{
+			State:                task.TaskState_RUNNING,
+			ConfigVersion:        suite.jobConfig.GetChangeLog().GetVersion(),
+			DesiredConfigVersion: suite.jobConfig.GetChangeLog().GetVersion(),
+		}
+	}
+	suite.taskStore.EXPECT().
+		GetTaskRuntimesForJobByRange(gomock.Any(), suite.jobID, nil).
+		Return(taskRuntimes, nil)
+
+	// instances 0 and 1 already run the new configuration
+	for _, instID := range []uint32{0, 1} {
+		suite.taskStore.EXPECT().
+			GetTaskConfig(gomock.Any(), suite.jobID, instID, gomock.Any()).
+			Return(suite.newJobConfig.GetDefaultConfig(), &models.ConfigAddOn{}, nil)
+	}
+	for _, instID := range []uint32{2, 3} {
+		suite.taskStore.EXPECT().
+			GetTaskConfig(gomock.Any(), suite.jobID, instID, gomock.Any()).
+			Return(suite.jobConfig.GetDefaultConfig(), &models.ConfigAddOn{}, nil)
+	}
+
+	// no run durations are recorded yet, so the current runs are sampled
+	suite.runDurationOps.EXPECT().
+		GetAll(gomock.Any(), suite.jobID).
+		Return(nil, nil)
+
+	// each instance took 30 seconds to start running
+	suite.taskStore.EXPECT().
+		GetPodEvents(gomock.Any(), suite.jobID.GetValue(), gomock.Any()).
+		Return([]*pod.PodEvent{
+			{
+				ActualState: task.TaskState_RUNNING.String(),
+				Timestamp:   now.Format(time.RFC3339),
+			},
+			{
+				ActualState: task.TaskState_LAUNCHED.String(),
+				Timestamp:   now.Add(-20 * time.Second).Format(time.RFC3339),
+			},
+			{
+				ActualState: task.TaskState_INITIALIZED.String(),
+				Timestamp:   now.Add(-30 * time.Second).Format(time.RFC3339),
+			},
+		}, nil).
+		Times(4)
+
+	resp, err := suite.h.PlanUpdate(
+		context.Background(),
+		&svc.PlanUpdateRequest{
+			JobId:        suite.jobID,
+			JobConfig:    suite.newJobConfig,
+			UpdateConfig: suite.updateConfig,
+		},
+	)
+	suite.NoError(err)
+
+	suite.Equal([]*svc.InstanceChange{
+		{InstanceId: 0, Type: svc.InstanceChangeType_INSTANCE_CHANGE_TYPE_IN_PLACE},
+		{InstanceId: 1, Type: svc.InstanceChangeType_INSTANCE_CHANGE_TYPE_IN_PLACE},
+		{InstanceId: 2, Type: svc.InstanceChangeType_INSTANCE_CHANGE_TYPE_RESTART},
+		{InstanceId: 3, Type: svc.InstanceChangeType_INSTANCE_CHANGE_TYPE_RESTART},
+		{InstanceId: 4, Type: svc.InstanceChangeType_INSTANCE_CHANGE_TYPE_ADD},
+		{InstanceId: 5, Type: svc.InstanceChangeType_INSTANCE_CHANGE_TYPE_REMOVE},
+	}, resp.GetInstanceChanges())
+
+	suite.Equal([]*svc.UpdateBatch{
+		{
+			InstancesAdded:   []uint32{4},
+			InstancesUpdated: []uint32{2},
+		},
+		{
+			InstancesUpdated: []uint32{3},
+			InstancesRemoved: []uint32{5},
+		},
+	}, resp.GetBatches())
+
+	suite.Equal(uint32(60), resp.GetEstimatedDurationSeconds())
+}
+
+// TestPlanUpdateNoHistory tests that the estimated duration of an update
+// is 0 when the instances have no start history
+func (suite *UpdateSvcTestSuite) TestPlanUpdateNoHistory() {
+	suite.newJobConfig.InstanceCount = 1
+
+	suite.jobStore.EXPECT().
+		GetJobRuntime(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobRuntime, nil)
+
+	suite.jobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobConfig, &models.ConfigAddOn{}, nil)
+
+	suite.taskStore.EXPECT().
+		GetTaskRuntimesForJobByRange(gomock.Any(), suite.jobID, nil).
+		Return(map[uint32]*task.RuntimeInfo{
+			0: {
+				ConfigVersion:        suite.jobConfig.GetChangeLog().GetVersion(),
+				DesiredConfigVersion: suite.jobConfig.GetChangeLog().GetVersion(),
+			},
+		}, nil)
+
+	suite.taskStore.EXPECT().
+		GetTaskConfig(gomock.Any(), suite.jobID, uint32(0), gomock.Any()).
+		Return(suite.jobConfig.GetDefaultConfig(), &models.ConfigAddOn{}, nil)
+
+	suite.runDurationOps.EXPECT().
+		GetAll(gomock.Any(), suite.jobID).
+		Return(nil, fmt.Errorf("fake db error"))
+
+	suite.taskStore.EXPECT().
+		GetPodEvents(gomock.Any(), suite.jobID.GetValue(), uint32(0)).
+		Return(nil, fmt.Errorf("fake db error"))
+
+	resp, err := suite.h.PlanUpdate(
+		context.Background(),
+		&svc.PlanUpdateRequest{
+			JobId:     suite.jobID,
+			JobConfig: suite.newJobConfig,
+		},
+	)
+	suite.NoError(err)
+	suite.Equal([]*svc.UpdateBatch{
+		{InstancesUpdated: []uint32{0}},
+	}, resp.GetBatches())
+	suite.Zero(resp.GetEstimatedDurationSeconds())
+}
+
+// TestPlanUpdateJobNotFound tests planning an update of a job
+// which does not exist
+func (suite *UpdateSvcTestSuite) TestPlanUpdateJobNotFound() {
+	suite.jobStore.EXPECT().
+		GetJobRuntime(gomock.Any(), suite.jobID.GetValue()).
+		Return(nil, fmt.Errorf("fake db error"))
+
+	_, err := suite.h.PlanUpdate(
+		context.Background(),
+		&svc.PlanUpdateRequest{
+			JobId:        suite.jobID,
+			JobConfig:    suite.newJobConfig,
+			UpdateConfig: suite.updateConfig,
+		},
+	)
+	suite.True(yarpcerrors.IsNotFound(err))
+}
+
+// TestPlanUpdateGetTaskRuntimesFail tests failing to read the task
+// runtimes while planning an update
+func (suite *UpdateSvcTestSuite) TestPlanUpdateGetTaskRuntimesFail() {
+	suite.jobStore.EXPECT().
+		GetJobRuntime(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobRuntime, nil)
+
+	suite.jobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobConfig, &models.ConfigAddOn{}, nil)
+
+	suite.taskStore.EXPECT().
+		GetTaskRuntimesForJobByRange(gomock.Any(), suite.jobID, nil).
+		Return(nil, fmt.Errorf("fake db error"))
+
+	_, err := suite.h.PlanUpdate(
+		context.Background(),
+		&svc.PlanUpdateRequest{
+			JobId:        suite.jobID,
+			JobConfig:    suite.newJobConfig,
+			UpdateConfig: suite.updateConfig,
+		},
+	)
+	suite.Error(err)
+}
+
+// TestPlanUpdateWithRunStatistics tests that the estimated duration of
+// an update is based on the recorded start latencies of the job
+func (suite *UpdateSvcTestSuite) TestPlanUpdateWithRunStatistics() {
+	suite.newJobConfig.InstanceCount = 2
+
+	suite.jobStore.EXPECT().
+		GetJobRuntime(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobRuntime, nil)
+
+	suite.jobStore.EXPECT().
+		GetJobConfig(gomock.Any(), suite.jobID.GetValue()).
+		Return(suite.jobConfig, &models.ConfigAddOn{}, nil)
+
+	// both instances are added
+	suite.taskStore.EXPECT().
+		GetTaskRuntimesForJobByRange(gomock.Any(), suite.jobID, nil).
+		Return(map[uint32]*task.RuntimeInfo{}, nil)
+
+	suite.runDurationOps.EXPECT().
+		GetAll(gomock.Any(), suite.jobID).
+		Return([]*ormobjects.RunDurationObject{
+			{InstanceID: 0, RunID: 1, StartLatencyMs: 20000},
+			{InstanceID: 0, RunID: 2, StartLatencyMs: 40000},
+			{InstanceID: 1, RunID: 1, StartLatencyMs: 90000},
+		}, nil)
+
+	resp, err := suite.h.PlanUpdate(
+		context.Background(),
+		&svc.PlanUpdateRequest{
+			JobId:        suite.jobID,
+			JobConfig:    suite.newJobConfig,
+			UpdateConfig: &update.UpdateConfig{BatchSize: 1},
+		},
+	)
+	suite.NoError(err)
+	suite.Len(resp.GetBatches(), 2)
+	// two batches taking the median start latency of 40 seconds
+	suite.Equal(uint32(80), resp.GetEstimatedDurationSeconds())
+}
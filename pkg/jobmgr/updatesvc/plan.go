@@ -0,0 +1,136 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatesvc
+
+import (
+	"sort"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	"github.com/uber/peloton/.gen/peloton/api/v0/update/svc"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
+)
+
+// _planSampleSize is the maximum number of instances whose start
+// history is read to estimate the duration of an update
+const _planSampleSize = 10
+
+// planUpdateBatches splits the instances of an update into the batches
+// the update would process them in. Like the update goal state, the
+// instances added are processed first, followed by the instances updated
+// and then the instances removed. All the instances are processed in a
+// single batch if the batch size is 0.
+func planUpdateBatches(
+	instancesAdded []uint32,
+	instancesUpdated []uint32,
+	instancesRemoved []uint32,
+	batchSize uint32,
+) []*svc.UpdateBatch {
+	total := len(instancesAdded) + len(instancesUpdated) + len(instancesRemoved)
+	if total == 0 {
+		return nil
+	}
+
+	size := int(batchSize)
+	if size == 0 {
+		size = total
+	}
+
+	var batches []*svc.UpdateBatch
+	for start := 0; start < total; start += size {
+		end := start + size
+		if end > total {
+			end = total
+		}
+
+		batch := &svc.UpdateBatch{}
+		for i := start; i < end; i++ {
+			switch {
+			case i < len(instancesAdded):
+				batch.InstancesAdded = append(
+					batch.InstancesAdded, instancesAdded[i])
+			case i < len(instancesAdded)+len(instancesUpdated):
+				batch.InstancesUpdated = append(
+					batch.InstancesUpdated,
+					instancesUpdated[i-len(instancesAdded)])
+			default:
+				batch.InstancesRemoved = append(
+					batch.InstancesRemoved,
+					instancesRemoved[i-len(instancesAdded)-len(instancesUpdated)])
+			}
+		}
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// getInstanceChanges returns how the update changes each instance,
+// sorted by instance identifier.
+func getInstanceChanges(
+	instancesAdded []uint32,
+	instancesUpdated []uint32,
+	instancesRemoved []uint32,
+	instancesUnchanged []uint32,
+) []*svc.InstanceChange {
+	var changes []*svc.InstanceChange
+	for _, c := range []struct {
+		instances  []uint32
+		changeType svc.InstanceChangeType
+	}{
+		{instancesAdded, svc.InstanceChangeType_INSTANCE_CHANGE_TYPE_ADD},
+		{instancesUpdated, svc.InstanceChangeType_INSTANCE_CHANGE_TYPE_RESTART},
+		{instancesRemoved, svc.InstanceChangeType_INSTANCE_CHANGE_TYPE_REMOVE},
+		{instancesUnchanged, svc.InstanceChangeType_INSTANCE_CHANGE_TYPE_IN_PLACE},
+	} {
+		for _, instID := range c.instances {
+			changes = append(changes, &svc.InstanceChange{
+				InstanceId: instID,
+				Type:       c.changeType,
+			})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].GetInstanceId() < changes[j].GetInstanceId()
+	})
+	return changes
+}
+
+// getStartDuration returns how long a run took from its first event
+// until it was running. The pod events of the run are sorted by
+// descending timestamp.
+func getStartDuration(events []*pod.PodEvent) (time.Duration, bool) {
+	if len(events) == 0 {
+		return 0, false
+	}
+
+	first, err := time.Parse(time.RFC3339, events[len(events)-1].GetTimestamp())
+	if err != nil {
+		return 0, false
+	}
+
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].GetActualState() != task.TaskState_RUNNING.String() {
+			continue
+		}
+
+		running, err := time.Parse(time.RFC3339, events[i].GetTimestamp())
+		if err != nil || running.Before(first) {
+			return 0, false
+		}
+		return running.Sub(first), true
+	}
+	return 0, false
+}
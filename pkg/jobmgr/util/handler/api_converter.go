@@ -70,6 +70,8 @@ func ConvertTaskStateToPodState(state task.TaskState) pod.PodState {
 		return pod.PodState_POD_STATE_KILLED
 	case task.TaskState_DELETED:
 		return pod.PodState_POD_STATE_DELETED
+	case task.TaskState_FAILED_HOLD:
+		return pod.PodState_POD_STATE_FAILED_HOLD
 	}
 	return pod.PodState_POD_STATE_INVALID
 }
@@ -111,6 +113,8 @@ func ConvertPodStateToTaskState(state pod.PodState) task.TaskState {
 		return task.TaskState_KILLED
 	case pod.PodState_POD_STATE_DELETED:
 		return task.TaskState_DELETED
+	case pod.PodState_POD_STATE_FAILED_HOLD:
+		return task.TaskState_FAILED_HOLD
 	}
 	return task.TaskState_UNKNOWN
 }
@@ -206,7 +210,9 @@ func ConvertTaskConfigToPodSpec(taskConfig *task.TaskConfig) *pod.PodSpec {
 
 	if taskConfig.GetRestartPolicy() != nil {
 		result.RestartPolicy = &pod.RestartPolicy{
-			MaxFailures: taskConfig.GetRestartPolicy().GetMaxFailures(),
+			MaxFailures:            taskConfig.GetRestartPolicy().GetMaxFailures(),
+			MaxCrashLoopFailures:   taskConfig.GetRestartPolicy().GetMaxCrashLoopFailures(),
+			CrashLoopWindowSeconds: taskConfig.GetRestartPolicy().GetCrashLoopWindowSeconds(),
 		}
 	}
 
@@ -277,6 +283,12 @@ func ConvertLabels(labels []*peloton.Label) []*v1alphapeloton.Label {
 func ConvertTaskConstraintsToPodConstraints(constraints []*task.Constraint) []*pod.Constraint {
 	var podConstraints []*pod.Constraint
 	for _, constraint := range constraints {
+		var expressionConstraint *pod.ExpressionConstraint
+		if constraint.GetExpressionConstraint() != nil {
+			expressionConstraint = &pod.ExpressionConstraint{
+				Expression: constraint.GetExpressionConstraint().GetExpression(),
+			}
+		}
 		podConstraints = append(podConstraints, &pod.Constraint{
 			Type: pod.Constraint_Type(constraint.GetType()),
 			LabelConstraint: &pod.LabelConstraint{
@@ -298,6 +310,7 @@ func ConvertTaskConstraintsToPodConstraints(constraints []*task.Constraint) []*p
 			OrConstraint: &pod.OrConstraint{
 				Constraints: ConvertTaskConstraintsToPodConstraints(constraint.GetOrConstraint().GetConstraints()),
 			},
+			ExpressionConstraint: expressionConstraint,
 		})
 	}
 	return podConstraints
@@ -363,6 +376,15 @@ func ConvertJobConfigToJobSpec(config *job.JobConfig) *stateless.JobSpec {
 		instanceSpec[instID] = ConvertTaskConfigToPodSpec(taskConfig)
 	}
 
+	var taskBackoff *stateless.TaskBackoffSpec
+	if config.GetTaskBackoffPolicy() != nil {
+		taskBackoff = &stateless.TaskBackoffSpec{
+			InitialDelayMs: config.GetTaskBackoffPolicy().GetInitialDelayMs(),
+			Multiplier:     config.GetTaskBackoffPolicy().GetMultiplier(),
+			MaxDelayMs:     config.GetTaskBackoffPolicy().GetMaxDelayMs(),
+		}
+	}
+
 	return &stateless.JobSpec{
 		Revision: &v1alphapeloton.Revision{
 			Version:   config.GetChangeLog().GetVersion(),
@@ -386,6 +408,8 @@ func ConvertJobConfigToJobSpec(config *job.JobConfig) *stateless.JobSpec {
 		InstanceSpec: instanceSpec,
 		RespoolId: &v1alphapeloton.ResourcePoolID{
 			Value: config.GetRespoolID().GetValue()},
+		TaskBackoff:             taskBackoff,
+		MaxConcurrentPodActions: config.GetMaxConcurrentTaskActions(),
 	}
 }
 
@@ -503,9 +527,10 @@ func ConvertUpdateModelToWorkflowInfo(
 		}
 	} else if updateInfo.GetType() == models.WorkflowType_RESTART {
 		result.RestartSpec = &stateless.RestartSpec{
-			BatchSize: updateInfo.GetUpdateConfig().GetBatchSize(),
-			Ranges:    util.ConvertInstanceIDListToInstanceRange(updateInfo.GetInstancesUpdated()),
-			InPlace:   updateInfo.GetUpdateConfig().GetInPlace(),
+			BatchSize:         updateInfo.GetUpdateConfig().GetBatchSize(),
+			Ranges:            util.ConvertInstanceIDListToInstanceRange(updateInfo.GetInstancesUpdated()),
+			InPlace:           updateInfo.GetUpdateConfig().GetInPlace(),
+			HealthWaitSeconds: updateInfo.GetUpdateConfig().GetHealthWaitSeconds(),
 		}
 	}
 
@@ -650,6 +675,15 @@ func ConvertJobSpecToJobConfig(spec *stateless.JobSpec) (*job.JobConfig, error)
 		}
 	}
 
+	if spec.GetTaskBackoff() != nil {
+		result.TaskBackoffPolicy = &job.TaskBackoffPolicy{
+			InitialDelayMs: spec.GetTaskBackoff().GetInitialDelayMs(),
+			Multiplier:     spec.GetTaskBackoff().GetMultiplier(),
+			MaxDelayMs:     spec.GetTaskBackoff().GetMaxDelayMs(),
+		}
+	}
+	result.MaxConcurrentTaskActions = spec.GetMaxConcurrentPodActions()
+
 	return result, nil
 }
 
@@ -748,7 +782,9 @@ func ConvertPodSpecToTaskConfig(spec *pod.PodSpec) (*task.TaskConfig, error) {
 
 	if spec.GetRestartPolicy() != nil {
 		result.RestartPolicy = &task.RestartPolicy{
-			MaxFailures: spec.GetRestartPolicy().GetMaxFailures(),
+			MaxFailures:            spec.GetRestartPolicy().GetMaxFailures(),
+			MaxCrashLoopFailures:   spec.GetRestartPolicy().GetMaxCrashLoopFailures(),
+			CrashLoopWindowSeconds: spec.GetRestartPolicy().GetCrashLoopWindowSeconds(),
 		}
 	}
 
@@ -814,6 +850,12 @@ func ConvertPodConstraintsToTaskConstraints(
 			}
 		}
 
+		if podConstraint.GetExpressionConstraint() != nil {
+			taskConstraint.ExpressionConstraint = &task.ExpressionConstraint{
+				Expression: podConstraint.GetExpressionConstraint().GetExpression(),
+			}
+		}
+
 		result = append(result, taskConstraint)
 	}
 
@@ -934,10 +976,18 @@ func convertTaskTerminationStatusToPodTerminationStatus(
 		podReason = pod.TerminationStatus_TERMINATION_STATUS_REASON_PREEMPTED_RESOURCES
 	case task.TerminationStatus_TERMINATION_STATUS_REASON_DEADLINE_TIMEOUT_EXCEEDED:
 		podReason = pod.TerminationStatus_TERMINATION_STATUS_REASON_DEADLINE_TIMEOUT_EXCEEDED
+	case task.TerminationStatus_TERMINATION_STATUS_REASON_OOM:
+		podReason = pod.TerminationStatus_TERMINATION_STATUS_REASON_OOM
+	case task.TerminationStatus_TERMINATION_STATUS_REASON_HEALTH_CHECK_FAILED:
+		podReason = pod.TerminationStatus_TERMINATION_STATUS_REASON_HEALTH_CHECK_FAILED
+	case task.TerminationStatus_TERMINATION_STATUS_REASON_UPDATE_REPLACED:
+		podReason = pod.TerminationStatus_TERMINATION_STATUS_REASON_UPDATE_REPLACED
 	}
 	return &pod.TerminationStatus{
-		Reason:   podReason,
-		ExitCode: termStatus.GetExitCode(),
-		Signal:   termStatus.GetSignal(),
+		Reason:    podReason,
+		ExitCode:  termStatus.GetExitCode(),
+		Signal:    termStatus.GetSignal(),
+		Message:   termStatus.GetMessage(),
+		Principal: termStatus.GetPrincipal(),
 	}
 }
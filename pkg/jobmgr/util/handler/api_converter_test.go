@@ -76,6 +76,7 @@ func (suite *apiConverterTestSuite) TestConvertTaskStateToPodStateAndViceVersa()
 		task.TaskState_KILLING,
 		task.TaskState_KILLED,
 		task.TaskState_DELETED,
+		task.TaskState_FAILED_HOLD,
 	}
 
 	podStates := []pod.PodState{
@@ -96,6 +97,7 @@ func (suite *apiConverterTestSuite) TestConvertTaskStateToPodStateAndViceVersa()
 		pod.PodState_POD_STATE_KILLING,
 		pod.PodState_POD_STATE_KILLED,
 		pod.PodState_POD_STATE_DELETED,
+		pod.PodState_POD_STATE_FAILED_HOLD,
 	}
 
 	for i, taskState := range taskStates {
@@ -540,6 +542,17 @@ func (suite *apiConverterTestSuite) TestConvertTaskConstraintsToPodConstraintsAn
 				},
 			},
 		},
+		{
+			Type: task.Constraint_EXPRESSION_CONSTRAINT,
+			LabelConstraint: &task.LabelConstraint{
+				Label: &peloton.Label{},
+			},
+			AndConstraint: &task.AndConstraint{},
+			OrConstraint:  &task.OrConstraint{},
+			ExpressionConstraint: &task.ExpressionConstraint{
+				Expression: `host("zone") in ["dca1", "dca2"]`,
+			},
+		},
 	}
 
 	podConstraints := []*pod.Constraint{
@@ -625,6 +638,17 @@ func (suite *apiConverterTestSuite) TestConvertTaskConstraintsToPodConstraintsAn
 				},
 			},
 		},
+		{
+			Type: pod.Constraint_CONSTRAINT_TYPE_EXPRESSION,
+			LabelConstraint: &pod.LabelConstraint{
+				Label: &v1alphapeloton.Label{},
+			},
+			AndConstraint: &pod.AndConstraint{},
+			OrConstraint:  &pod.OrConstraint{},
+			ExpressionConstraint: &pod.ExpressionConstraint{
+				Expression: `host("zone") in ["dca1", "dca2"]`,
+			},
+		},
 	}
 
 	suite.Equal(podConstraints, ConvertTaskConstraintsToPodConstraints(taskConstraints))
@@ -766,6 +790,12 @@ func (suite *apiConverterTestSuite) TestConvertJobConfigToJobSpec() {
 		RespoolID: &peloton.ResourcePoolID{
 			Value: "/test/respool",
 		},
+		TaskBackoffPolicy: &job.TaskBackoffPolicy{
+			InitialDelayMs: 1000,
+			Multiplier:     1.5,
+			MaxDelayMs:     60000,
+		},
+		MaxConcurrentTaskActions: 100,
 	}
 
 	jobSpec := ConvertJobConfigToJobSpec(jobConfig)
@@ -801,6 +831,12 @@ func (suite *apiConverterTestSuite) TestConvertJobConfigToJobSpec() {
 	}
 
 	suite.Equal(jobConfig.GetRespoolID().GetValue(), jobSpec.GetRespoolId().GetValue())
+	suite.Equal(&stateless.TaskBackoffSpec{
+		InitialDelayMs: 1000,
+		Multiplier:     1.5,
+		MaxDelayMs:     60000,
+	}, jobSpec.GetTaskBackoff())
+	suite.Equal(uint32(100), jobSpec.GetMaxConcurrentPodActions())
 }
 
 // TestConvertJobSpecToJobConfig tests conversion
@@ -862,6 +898,12 @@ func (suite *apiConverterTestSuite) TestConvertJobSpecToJobConfig() {
 		RespoolId: &v1alphapeloton.ResourcePoolID{
 			Value: "/test/respool",
 		},
+		TaskBackoff: &stateless.TaskBackoffSpec{
+			InitialDelayMs: 1000,
+			Multiplier:     1.5,
+			MaxDelayMs:     60000,
+		},
+		MaxConcurrentPodActions: 100,
 	}
 
 	jobConfig, err := ConvertJobSpecToJobConfig(jobSpec)
@@ -900,6 +942,12 @@ func (suite *apiConverterTestSuite) TestConvertJobSpecToJobConfig() {
 	}
 
 	suite.Equal(jobSpec.GetRespoolId().GetValue(), jobConfig.GetRespoolID().GetValue())
+	suite.Equal(&job.TaskBackoffPolicy{
+		InitialDelayMs: 1000,
+		Multiplier:     1.5,
+		MaxDelayMs:     60000,
+	}, jobConfig.GetTaskBackoffPolicy())
+	suite.Equal(uint32(100), jobConfig.GetMaxConcurrentTaskActions())
 }
 
 func (suite *apiConverterTestSuite) TestConvertUpdateModelToWorkflowStatus() {
@@ -1156,7 +1204,8 @@ func (suite *apiConverterTestSuite) TestConvertUpdateModelToWorkflowInfoRestart(
 		JobConfigVersion:     jobConfigVersion,
 		PrevJobConfigVersion: prevJobConfigVersion,
 		UpdateConfig: &update.UpdateConfig{
-			BatchSize: 10,
+			BatchSize:         10,
+			HealthWaitSeconds: 30,
 		},
 	}
 	runtime := &job.RuntimeInfo{
@@ -1191,6 +1240,9 @@ func (suite *apiConverterTestSuite) TestConvertUpdateModelToWorkflowInfoRestart(
 	suite.Equal(workflowStatus, workflowInfo.GetStatus())
 	suite.Equal(updateModel.GetUpdateConfig().GetBatchSize(), workflowInfo.GetRestartSpec().GetBatchSize())
 	suite.Equal(restartRanges, workflowInfo.GetRestartSpec().GetRanges())
+	suite.Equal(
+		updateModel.GetUpdateConfig().GetHealthWaitSeconds(),
+		workflowInfo.GetRestartSpec().GetHealthWaitSeconds())
 }
 
 // TestConvertStatelessQuerySpecToJobQuerySpec tests conversion
@@ -1438,6 +1490,9 @@ func (suite *apiConverterTestSuite) TestConvertTerminationStatusReason() {
 		task.TerminationStatus_TERMINATION_STATUS_REASON_KILLED_HOST_MAINTENANCE:   pod.TerminationStatus_TERMINATION_STATUS_REASON_KILLED_HOST_MAINTENANCE,
 		task.TerminationStatus_TERMINATION_STATUS_REASON_PREEMPTED_RESOURCES:       pod.TerminationStatus_TERMINATION_STATUS_REASON_PREEMPTED_RESOURCES,
 		task.TerminationStatus_TERMINATION_STATUS_REASON_DEADLINE_TIMEOUT_EXCEEDED: pod.TerminationStatus_TERMINATION_STATUS_REASON_DEADLINE_TIMEOUT_EXCEEDED,
+		task.TerminationStatus_TERMINATION_STATUS_REASON_OOM:                       pod.TerminationStatus_TERMINATION_STATUS_REASON_OOM,
+		task.TerminationStatus_TERMINATION_STATUS_REASON_HEALTH_CHECK_FAILED:       pod.TerminationStatus_TERMINATION_STATUS_REASON_HEALTH_CHECK_FAILED,
+		task.TerminationStatus_TERMINATION_STATUS_REASON_UPDATE_REPLACED:           pod.TerminationStatus_TERMINATION_STATUS_REASON_UPDATE_REPLACED,
 	}
 	// ensure that we have a test-case for every legal value of v0 reason
 	suite.Equal(len(task.TerminationStatus_Reason_name), len(expmap))
@@ -1452,6 +1507,19 @@ func (suite *apiConverterTestSuite) TestConvertTerminationStatusReason() {
 	}
 }
 
+// TestConvertTerminationStatusStopDetails verifies that the reason and
+// principal of a stop request are converted from v0 to v1alpha.
+func (suite *apiConverterTestSuite) TestConvertTerminationStatusStopDetails() {
+	podTermStatus := convertTaskTerminationStatusToPodTerminationStatus(
+		&task.TerminationStatus{
+			Reason:    task.TerminationStatus_TERMINATION_STATUS_REASON_KILLED_ON_REQUEST,
+			Message:   "bad host",
+			Principal: "auto-remediation",
+		})
+	suite.Equal("bad host", podTermStatus.GetMessage())
+	suite.Equal("auto-remediation", podTermStatus.GetPrincipal())
+}
+
 func (suite *apiConverterTestSuite) TestConvertV1InstanceRangeToV0() {
 	from := uint32(5)
 	to := uint32(10)
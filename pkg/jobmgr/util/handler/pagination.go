@@ -0,0 +1,186 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handler
+
+import (
+	"github.com/uber/peloton/.gen/peloton/api/v0/query"
+
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const (
+	// _defaultQueryLimit is the page size of the queries which do not
+	// set one, which is the default of the storage.
+	_defaultQueryLimit uint32 = 10
+
+	_defaultMaxJobQueryLimit   uint32 = 1000
+	_defaultMaxJobQueryResults uint32 = 10000
+	// the queries of pods of the Aurora bridge return all the tasks of a
+	// job in one page, so job service raises it to the maximum number of
+	// tasks per job in its config
+	_defaultMaxTaskQueryLimit uint32 = 10000
+	_defaultMaxPodEventRuns   uint64 = 100
+	_defaultMaxPodEvents      uint32 = 10000
+
+	// Default sort orders of the queries which do not set one
+	_jobQueryDefaultOrderBy  = "creation_time"
+	_taskQueryDefaultOrderBy = "instanceId"
+)
+
+// PaginationConfig bounds the results of the Query and List endpoints of
+// job manager, so that a single unbounded query cannot exhaust its memory.
+// The queries exceeding the limits are rejected. The default of a limit
+// is used if it is zero.
+type PaginationConfig struct {
+	// Maximum number of jobs returned by a page of a job query
+	MaxJobQueryLimit uint32 `yaml:"max_job_query_limit"`
+
+	// Maximum number of jobs matched by a job query, which are paged
+	// through, also known as the max limit of the query
+	MaxJobQueryResults uint32 `yaml:"max_job_query_results"`
+
+	// Maximum number of tasks returned by a page of a task query
+	MaxTaskQueryLimit uint32 `yaml:"max_task_query_limit"`
+
+	// Maximum number of runs of a task whose pod events are returned
+	// by GetPodEvents
+	MaxPodEventRuns uint64 `yaml:"max_pod_event_runs"`
+
+	// Maximum number of pod events returned by GetPodEvents
+	MaxPodEvents uint32 `yaml:"max_pod_events"`
+}
+
+func (c PaginationConfig) maxJobQueryLimit() uint32 {
+	if c.MaxJobQueryLimit == 0 {
+		return _defaultMaxJobQueryLimit
+	}
+	return c.MaxJobQueryLimit
+}
+
+func (c PaginationConfig) maxJobQueryResults() uint32 {
+	if c.MaxJobQueryResults == 0 {
+		return _defaultMaxJobQueryResults
+	}
+	return c.MaxJobQueryResults
+}
+
+func (c PaginationConfig) maxTaskQueryLimit() uint32 {
+	if c.MaxTaskQueryLimit == 0 {
+		return _defaultMaxTaskQueryLimit
+	}
+	return c.MaxTaskQueryLimit
+}
+
+func (c PaginationConfig) maxPodEventRuns() uint64 {
+	if c.MaxPodEventRuns == 0 {
+		return _defaultMaxPodEventRuns
+	}
+	return c.MaxPodEventRuns
+}
+
+func (c PaginationConfig) maxPodEvents() uint32 {
+	if c.MaxPodEvents == 0 {
+		return _defaultMaxPodEvents
+	}
+	return c.MaxPodEvents
+}
+
+// JobQueryPagination returns the pagination of a job query with the
+// default page size and sort order set if they are not, or an invalid
+// argument error if the query exceeds the limits.
+func (c PaginationConfig) JobQueryPagination(
+	spec *query.PaginationSpec) (*query.PaginationSpec, error) {
+	if spec.GetLimit() > c.maxJobQueryLimit() {
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"limit %d exceeds the maximum of %d jobs per page",
+			spec.GetLimit(), c.maxJobQueryLimit())
+	}
+	if spec.GetMaxLimit() > c.maxJobQueryResults() {
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"max limit %d exceeds the maximum of %d jobs per query",
+			spec.GetMaxLimit(), c.maxJobQueryResults())
+	}
+	return withDefaults(spec, &query.OrderBy{
+		Order:    query.OrderBy_DESC,
+		Property: &query.PropertyPath{Value: _jobQueryDefaultOrderBy},
+	}), nil
+}
+
+// TaskQueryPagination returns the pagination of a task query with the
+// default page size and sort order set if they are not, or an invalid
+// argument error if the query exceeds the limits.
+func (c PaginationConfig) TaskQueryPagination(
+	spec *query.PaginationSpec) (*query.PaginationSpec, error) {
+	if spec.GetLimit() > c.maxTaskQueryLimit() {
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"limit %d exceeds the maximum of %d tasks per page",
+			spec.GetLimit(), c.maxTaskQueryLimit())
+	}
+	return withDefaults(spec, &query.OrderBy{
+		Order:    query.OrderBy_ASC,
+		Property: &query.PropertyPath{Value: _taskQueryDefaultOrderBy},
+	}), nil
+}
+
+// PodEventsLimits returns the number of runs walked by GetPodEvents and the
+// maximum number of pod events it returns, given the ones requested, or an
+// invalid argument error if they exceed the limits. Zero stands for no
+// limit in the request, which is replaced by the maximum.
+func (c PaginationConfig) PodEventsLimits(
+	runs uint64,
+	maxEvents uint32) (uint64, uint32, error) {
+	if runs > c.maxPodEventRuns() {
+		return 0, 0, yarpcerrors.InvalidArgumentErrorf(
+			"limit %d exceeds the maximum of %d runs",
+			runs, c.maxPodEventRuns())
+	}
+	if maxEvents > c.maxPodEvents() {
+		return 0, 0, yarpcerrors.InvalidArgumentErrorf(
+			"max events %d exceeds the maximum of %d pod events",
+			maxEvents, c.maxPodEvents())
+	}
+	if runs == 0 {
+		runs = c.maxPodEventRuns()
+	}
+	if maxEvents == 0 {
+		maxEvents = c.maxPodEvents()
+	}
+	return runs, maxEvents, nil
+}
+
+// PodEventsOfRunLimit returns the maximum number of pod events of a run
+// returned by the v1alpha GetPodEvents, whose request does not limit them.
+func (c PaginationConfig) PodEventsOfRunLimit() uint32 {
+	return c.maxPodEvents()
+}
+
+// withDefaults returns a copy of the pagination with the default page size
+// and the given sort order set if they are not.
+func withDefaults(
+	spec *query.PaginationSpec,
+	orderBy *query.OrderBy) *query.PaginationSpec {
+	result := &query.PaginationSpec{
+		Offset:   spec.GetOffset(),
+		Limit:    spec.GetLimit(),
+		OrderBy:  spec.GetOrderBy(),
+		MaxLimit: spec.GetMaxLimit(),
+	}
+	if result.Limit == 0 {
+		result.Limit = _defaultQueryLimit
+	}
+	if len(result.OrderBy) == 0 {
+		result.OrderBy = []*query.OrderBy{orderBy}
+	}
+	return result
+}
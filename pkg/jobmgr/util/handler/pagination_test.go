@@ -0,0 +1,107 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handler
+
+import (
+	"testing"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/query"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+func TestJobQueryPaginationDefaults(t *testing.T) {
+	pagination, err := PaginationConfig{}.JobQueryPagination(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, _defaultQueryLimit, pagination.GetLimit())
+	assert.Len(t, pagination.GetOrderBy(), 1)
+	assert.Equal(t, query.OrderBy_DESC, pagination.GetOrderBy()[0].GetOrder())
+	assert.Equal(t, "creation_time",
+		pagination.GetOrderBy()[0].GetProperty().GetValue())
+}
+
+func TestJobQueryPaginationKeepsRequested(t *testing.T) {
+	spec := &query.PaginationSpec{
+		Offset:   5,
+		Limit:    20,
+		MaxLimit: 200,
+		OrderBy: []*query.OrderBy{
+			{
+				Order:    query.OrderBy_ASC,
+				Property: &query.PropertyPath{Value: "name"},
+			},
+		},
+	}
+	pagination, err := PaginationConfig{}.JobQueryPagination(spec)
+	assert.NoError(t, err)
+	assert.Equal(t, spec, pagination)
+	// the requested pagination is not modified
+	assert.False(t, spec == pagination)
+}
+
+func TestJobQueryPaginationExceedsLimits(t *testing.T) {
+	cfg := PaginationConfig{MaxJobQueryLimit: 50, MaxJobQueryResults: 500}
+
+	_, err := cfg.JobQueryPagination(&query.PaginationSpec{Limit: 51})
+	assert.True(t, yarpcerrors.IsInvalidArgument(err))
+
+	_, err = cfg.JobQueryPagination(&query.PaginationSpec{MaxLimit: 501})
+	assert.True(t, yarpcerrors.IsInvalidArgument(err))
+
+	_, err = cfg.JobQueryPagination(
+		&query.PaginationSpec{Limit: 50, MaxLimit: 500})
+	assert.NoError(t, err)
+}
+
+func TestTaskQueryPagination(t *testing.T) {
+	cfg := PaginationConfig{MaxTaskQueryLimit: 1000}
+
+	pagination, err := cfg.TaskQueryPagination(&query.PaginationSpec{Offset: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(10), pagination.GetOffset())
+	assert.Equal(t, _defaultQueryLimit, pagination.GetLimit())
+	assert.Equal(t, query.OrderBy_ASC, pagination.GetOrderBy()[0].GetOrder())
+	assert.Equal(t, "instanceId",
+		pagination.GetOrderBy()[0].GetProperty().GetValue())
+
+	_, err = cfg.TaskQueryPagination(&query.PaginationSpec{Limit: 1001})
+	assert.True(t, yarpcerrors.IsInvalidArgument(err))
+}
+
+func TestPodEventsLimits(t *testing.T) {
+	cfg := PaginationConfig{MaxPodEventRuns: 10, MaxPodEvents: 100}
+
+	runs, maxEvents, err := cfg.PodEventsLimits(0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), runs)
+	assert.Equal(t, uint32(100), maxEvents)
+
+	runs, maxEvents, err = cfg.PodEventsLimits(2, 20)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), runs)
+	assert.Equal(t, uint32(20), maxEvents)
+
+	_, _, err = cfg.PodEventsLimits(11, 0)
+	assert.True(t, yarpcerrors.IsInvalidArgument(err))
+
+	_, _, err = cfg.PodEventsLimits(0, 101)
+	assert.True(t, yarpcerrors.IsInvalidArgument(err))
+}
+
+func TestPodEventsOfRunLimit(t *testing.T) {
+	assert.Equal(t, _defaultMaxPodEvents, PaginationConfig{}.PodEventsOfRunLimit())
+	assert.Equal(t, uint32(20),
+		PaginationConfig{MaxPodEvents: 20}.PodEventsOfRunLimit())
+}
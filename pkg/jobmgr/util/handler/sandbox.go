@@ -0,0 +1,256 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc"
+
+	"github.com/uber/peloton/pkg/common/util"
+	"github.com/uber/peloton/pkg/storage"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// _defaultSandboxInfoTTL is the time for which the values
+	// of the sandbox info cache are valid
+	_defaultSandboxInfoTTL = 5 * time.Minute
+
+	// _defaultMesosAgentPort is the port of a Mesos agent whose address
+	// could not be looked up
+	_defaultMesosAgentPort = "5051"
+)
+
+// SandboxInfoCache caches the static lookups needed to browse the sandbox
+// of a task: the framework id, the leading Mesos master and the address of
+// the Mesos agents. The cached values expire after a ttl, and are dropped
+// as soon as they are known to be stale, e.g. when an agent re-registered
+// with a new agent id.
+type SandboxInfoCache interface {
+	// GetFrameworkID returns the id of the framework,
+	// or an empty string if the framework has not registered yet.
+	GetFrameworkID(ctx context.Context) (string, error)
+
+	// GetMesosMasterHostPort returns the hostname and port
+	// of the leading Mesos master.
+	GetMesosMasterHostPort(ctx context.Context) (
+		*hostsvc.MesosMasterHostPortResponse, error)
+
+	// GetAgentAddress returns the IP address and port of the Mesos agent
+	// on the host, if possible, because the hostname may not be resolvable
+	// on the network. It falls back to the hostname and the default agent
+	// port. agentID is the id of the agent the caller knows of, a cached
+	// address of an agent with another id is refreshed, as the agent
+	// has re-registered since.
+	GetAgentAddress(
+		ctx context.Context,
+		hostname string,
+		agentID string,
+	) (agentIP string, agentPort string)
+
+	// AddAgentAddress adds the address of the Mesos agent on the host which
+	// is already known, e.g. recorded in the task runtime at launch, so
+	// that it does not have to be looked up.
+	AddAgentAddress(hostname, agentID, agentIP, agentPort string)
+
+	// Invalidate drops all the cached values, it is to be called when
+	// the framework or the Mesos master may have re-registered.
+	Invalidate()
+}
+
+// cachedAgentAddress is the address of a Mesos agent
+type cachedAgentAddress struct {
+	agentID string
+	ip      string
+	port    string
+	expiry  time.Time
+}
+
+// sandboxInfoCache implements SandboxInfoCache
+type sandboxInfoCache struct {
+	sync.Mutex
+
+	frameworkName      string
+	frameworkInfoStore storage.FrameworkInfoStore
+	hostMgrClient      hostsvc.InternalHostServiceYARPCClient
+	ttl                time.Duration
+	now                func() time.Time
+
+	frameworkID       string
+	frameworkIDExpiry time.Time
+	master            *hostsvc.MesosMasterHostPortResponse
+	masterExpiry      time.Time
+	agents            map[string]*cachedAgentAddress
+}
+
+// NewSandboxInfoCache returns a new SandboxInfoCache looking up the
+// framework id of the framework from the store, and the Mesos master and
+// agents from host manager.
+func NewSandboxInfoCache(
+	frameworkName string,
+	frameworkInfoStore storage.FrameworkInfoStore,
+	hostMgrClient hostsvc.InternalHostServiceYARPCClient,
+) SandboxInfoCache {
+	return newSandboxInfoCache(
+		frameworkName,
+		frameworkInfoStore,
+		hostMgrClient,
+		_defaultSandboxInfoTTL,
+		time.Now,
+	)
+}
+
+func newSandboxInfoCache(
+	frameworkName string,
+	frameworkInfoStore storage.FrameworkInfoStore,
+	hostMgrClient hostsvc.InternalHostServiceYARPCClient,
+	ttl time.Duration,
+	now func() time.Time,
+) *sandboxInfoCache {
+	return &sandboxInfoCache{
+		frameworkName:      frameworkName,
+		frameworkInfoStore: frameworkInfoStore,
+		hostMgrClient:      hostMgrClient,
+		ttl:                ttl,
+		now:                now,
+		agents:             make(map[string]*cachedAgentAddress),
+	}
+}
+
+// GetFrameworkID returns the id of the framework.
+func (c *sandboxInfoCache) GetFrameworkID(ctx context.Context) (string, error) {
+	c.Lock()
+	if c.frameworkID != "" && c.now().Before(c.frameworkIDExpiry) {
+		defer c.Unlock()
+		return c.frameworkID, nil
+	}
+	c.Unlock()
+
+	frameworkID, err := c.frameworkInfoStore.GetFrameworkID(
+		ctx, c.frameworkName)
+	if err != nil || frameworkID == "" {
+		return frameworkID, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.frameworkID = frameworkID
+	c.frameworkIDExpiry = c.now().Add(c.ttl)
+	return frameworkID, nil
+}
+
+// GetMesosMasterHostPort returns the hostname and port of the
+// leading Mesos master.
+func (c *sandboxInfoCache) GetMesosMasterHostPort(
+	ctx context.Context,
+) (*hostsvc.MesosMasterHostPortResponse, error) {
+	c.Lock()
+	if c.master != nil && c.now().Before(c.masterExpiry) {
+		defer c.Unlock()
+		return c.master, nil
+	}
+	c.Unlock()
+
+	master, err := c.hostMgrClient.GetMesosMasterHostPort(
+		ctx, &hostsvc.MesosMasterHostPortRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.master = master
+	c.masterExpiry = c.now().Add(c.ttl)
+	return master, nil
+}
+
+// GetAgentAddress returns the IP address and port of the Mesos agent
+// on the host.
+func (c *sandboxInfoCache) GetAgentAddress(
+	ctx context.Context,
+	hostname string,
+	agentID string,
+) (string, string) {
+	c.Lock()
+	if agent, ok := c.agents[hostname]; ok {
+		if c.now().Before(agent.expiry) &&
+			(agentID == "" || agentID == agent.agentID) {
+			defer c.Unlock()
+			return agent.ip, agent.port
+		}
+		delete(c.agents, hostname)
+	}
+	c.Unlock()
+
+	agentResponse, err := c.hostMgrClient.GetMesosAgentInfo(ctx,
+		&hostsvc.GetMesosAgentInfoRequest{Hostname: hostname})
+	if err != nil || len(agentResponse.GetAgents()) == 0 {
+		log.WithField("hostname", hostname).
+			Info("Could not get Mesos agent info")
+		return hostname, _defaultMesosAgentPort
+	}
+
+	agentInfo := agentResponse.GetAgents()[0]
+	ip, port, err := util.ExtractIPAndPortFromMesosAgentPID(
+		agentInfo.GetPid())
+	if err != nil {
+		return hostname, _defaultMesosAgentPort
+	}
+	if port == "" {
+		port = _defaultMesosAgentPort
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.agents[hostname] = &cachedAgentAddress{
+		agentID: agentInfo.GetAgentInfo().GetId().GetValue(),
+		ip:      ip,
+		port:    port,
+		expiry:  c.now().Add(c.ttl),
+	}
+	return ip, port
+}
+
+// AddAgentAddress adds the known address of the Mesos agent on the host.
+func (c *sandboxInfoCache) AddAgentAddress(
+	hostname string,
+	agentID string,
+	agentIP string,
+	agentPort string,
+) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.agents[hostname] = &cachedAgentAddress{
+		agentID: agentID,
+		ip:      agentIP,
+		port:    agentPort,
+		expiry:  c.now().Add(c.ttl),
+	}
+}
+
+// Invalidate drops all the cached values.
+func (c *sandboxInfoCache) Invalidate() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.frameworkID = ""
+	c.master = nil
+	c.agents = make(map[string]*cachedAgentAddress)
+}
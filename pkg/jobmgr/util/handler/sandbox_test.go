@@ -0,0 +1,211 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	mesos "github.com/uber/peloton/.gen/mesos/v1"
+	mesos_master "github.com/uber/peloton/.gen/mesos/v1/master"
+	"github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc"
+	hostmocks "github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc/mocks"
+
+	storemocks "github.com/uber/peloton/pkg/storage/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	_testFrameworkName = "Peloton"
+	_testHostname      = "hostname"
+	_testTTL           = time.Minute
+)
+
+type SandboxInfoCacheTestSuite struct {
+	suite.Suite
+
+	ctrl               *gomock.Controller
+	ctx                context.Context
+	frameworkInfoStore *storemocks.MockFrameworkInfoStore
+	hostMgrClient      *hostmocks.MockInternalHostServiceYARPCClient
+	now                time.Time
+	cache              *sandboxInfoCache
+}
+
+func TestSandboxInfoCache(t *testing.T) {
+	suite.Run(t, new(SandboxInfoCacheTestSuite))
+}
+
+func (suite *SandboxInfoCacheTestSuite) SetupTest() {
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.ctx = context.Background()
+	suite.frameworkInfoStore = storemocks.NewMockFrameworkInfoStore(suite.ctrl)
+	suite.hostMgrClient = hostmocks.NewMockInternalHostServiceYARPCClient(suite.ctrl)
+	suite.now = time.Now()
+	suite.cache = newSandboxInfoCache(
+		_testFrameworkName,
+		suite.frameworkInfoStore,
+		suite.hostMgrClient,
+		_testTTL,
+		func() time.Time { return suite.now },
+	)
+}
+
+func (suite *SandboxInfoCacheTestSuite) TearDownTest() {
+	suite.ctrl.Finish()
+}
+
+// agentInfoResponse returns the agent info of the test host
+// registered with the agent id
+func (suite *SandboxInfoCacheTestSuite) agentInfoResponse(
+	agentID string,
+	pid string,
+) *hostsvc.GetMesosAgentInfoResponse {
+	return &hostsvc.GetMesosAgentInfoResponse{
+		Agents: []*mesos_master.Response_GetAgents_Agent{
+			{
+				AgentInfo: &mesos.AgentInfo{
+					Id: &mesos.AgentID{Value: &agentID},
+				},
+				Pid: &pid,
+			},
+		},
+	}
+}
+
+// TestGetFrameworkID tests the framework id is read from the store
+// only once until it expires.
+func (suite *SandboxInfoCacheTestSuite) TestGetFrameworkID() {
+	suite.frameworkInfoStore.EXPECT().
+		GetFrameworkID(gomock.Any(), _testFrameworkName).
+		Return("framework-1", nil)
+	for i := 0; i < 2; i++ {
+		frameworkID, err := suite.cache.GetFrameworkID(suite.ctx)
+		suite.NoError(err)
+		suite.Equal("framework-1", frameworkID)
+	}
+
+	suite.now = suite.now.Add(_testTTL)
+	suite.frameworkInfoStore.EXPECT().
+		GetFrameworkID(gomock.Any(), _testFrameworkName).
+		Return("framework-2", nil)
+	frameworkID, err := suite.cache.GetFrameworkID(suite.ctx)
+	suite.NoError(err)
+	suite.Equal("framework-2", frameworkID)
+}
+
+// TestGetFrameworkIDNotCached tests an error or an empty framework id
+// is not cached.
+func (suite *SandboxInfoCacheTestSuite) TestGetFrameworkIDNotCached() {
+	gomock.InOrder(
+		suite.frameworkInfoStore.EXPECT().
+			GetFrameworkID(gomock.Any(), _testFrameworkName).
+			Return("", errors.New("test error")),
+		suite.frameworkInfoStore.EXPECT().
+			GetFrameworkID(gomock.Any(), _testFrameworkName).
+			Return("", nil),
+		suite.frameworkInfoStore.EXPECT().
+			GetFrameworkID(gomock.Any(), _testFrameworkName).
+			Return("framework-1", nil),
+	)
+
+	_, err := suite.cache.GetFrameworkID(suite.ctx)
+	suite.Error(err)
+	frameworkID, err := suite.cache.GetFrameworkID(suite.ctx)
+	suite.NoError(err)
+	suite.Empty(frameworkID)
+	frameworkID, err = suite.cache.GetFrameworkID(suite.ctx)
+	suite.NoError(err)
+	suite.Equal("framework-1", frameworkID)
+}
+
+// TestGetMesosMasterHostPort tests the Mesos master is looked up again
+// once invalidated.
+func (suite *SandboxInfoCacheTestSuite) TestGetMesosMasterHostPort() {
+	master := &hostsvc.MesosMasterHostPortResponse{
+		Hostname: "master",
+		Port:     "5050",
+	}
+	suite.hostMgrClient.EXPECT().
+		GetMesosMasterHostPort(gomock.Any(), &hostsvc.MesosMasterHostPortRequest{}).
+		Return(master, nil).
+		Times(2)
+
+	for i := 0; i < 2; i++ {
+		resp, err := suite.cache.GetMesosMasterHostPort(suite.ctx)
+		suite.NoError(err)
+		suite.Equal(master, resp)
+	}
+
+	suite.cache.Invalidate()
+	resp, err := suite.cache.GetMesosMasterHostPort(suite.ctx)
+	suite.NoError(err)
+	suite.Equal(master, resp)
+}
+
+// TestGetAgentAddress tests the address of an agent is looked up again
+// once the agent re-registered with a new agent id.
+func (suite *SandboxInfoCacheTestSuite) TestGetAgentAddress() {
+	gomock.InOrder(
+		suite.hostMgrClient.EXPECT().
+			GetMesosAgentInfo(gomock.Any(),
+				&hostsvc.GetMesosAgentInfoRequest{Hostname: _testHostname}).
+			Return(suite.agentInfoResponse("agent-1", "slave(1)@1.2.3.4:31000"), nil),
+		suite.hostMgrClient.EXPECT().
+			GetMesosAgentInfo(gomock.Any(),
+				&hostsvc.GetMesosAgentInfoRequest{Hostname: _testHostname}).
+			Return(suite.agentInfoResponse("agent-2", "slave(1)@1.2.3.5:31000"), nil),
+	)
+
+	for i := 0; i < 2; i++ {
+		ip, port := suite.cache.GetAgentAddress(suite.ctx, _testHostname, "agent-1")
+		suite.Equal("1.2.3.4", ip)
+		suite.Equal("31000", port)
+	}
+
+	ip, port := suite.cache.GetAgentAddress(suite.ctx, _testHostname, "agent-2")
+	suite.Equal("1.2.3.5", ip)
+	suite.Equal("31000", port)
+}
+
+// TestGetAgentAddressFallback tests the hostname and the default agent
+// port are returned, and not cached, if the agent cannot be looked up.
+func (suite *SandboxInfoCacheTestSuite) TestGetAgentAddressFallback() {
+	suite.hostMgrClient.EXPECT().
+		GetMesosAgentInfo(gomock.Any(),
+			&hostsvc.GetMesosAgentInfoRequest{Hostname: _testHostname}).
+		Return(nil, errors.New("test error")).
+		Times(2)
+
+	for i := 0; i < 2; i++ {
+		ip, port := suite.cache.GetAgentAddress(suite.ctx, _testHostname, "agent-1")
+		suite.Equal(_testHostname, ip)
+		suite.Equal(_defaultMesosAgentPort, port)
+	}
+}
+
+// TestAddAgentAddress tests an added agent address is returned without
+// looking up the agent.
+func (suite *SandboxInfoCacheTestSuite) TestAddAgentAddress() {
+	suite.cache.AddAgentAddress(_testHostname, "agent-1", "1.2.3.4", "31000")
+
+	ip, port := suite.cache.GetAgentAddress(suite.ctx, _testHostname, "agent-1")
+	suite.Equal("1.2.3.4", ip)
+	suite.Equal("31000", port)
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/uber/peloton/pkg/common/taskconfig"
+)
+
+// GetAllocatedResources returns the resources allocated to the running
+// instances of a job, computed from the task stats of the job runtime and
+// the resources of the default task config, so that the tasks of the job
+// do not have to be fetched. It must only be used for jobs without
+// instance configs, use GetInstancesAllocatedResources otherwise.
+func GetAllocatedResources(
+	config *job.JobConfig,
+	runtime *job.RuntimeInfo,
+) *task.ResourceConfig {
+	running := float64(
+		runtime.GetTaskStats()[task.TaskState_RUNNING.String()])
+	resource := config.GetDefaultConfig().GetResource()
+
+	return &task.ResourceConfig{
+		CpuLimit:    resource.GetCpuLimit() * running,
+		MemLimitMb:  resource.GetMemLimitMb() * running,
+		DiskLimitMb: resource.GetDiskLimitMb() * running,
+		GpuLimit:    resource.GetGpuLimit() * running,
+		FdLimit:     resource.GetFdLimit() * uint32(running),
+	}
+}
+
+// GetInstancesAllocatedResources returns the resources allocated to the
+// running instances of a job, with the resources of each instance read
+// from its instance config merged with the default config.
+func GetInstancesAllocatedResources(
+	config *job.JobConfig,
+	runningInstances []uint32,
+) *task.ResourceConfig {
+	allocated := &task.ResourceConfig{}
+	for _, instanceID := range runningInstances {
+		resource := taskconfig.Merge(
+			config.GetDefaultConfig(),
+			config.GetInstanceConfig()[instanceID]).GetResource()
+		allocated.CpuLimit += resource.GetCpuLimit()
+		allocated.MemLimitMb += resource.GetMemLimitMb()
+		allocated.DiskLimitMb += resource.GetDiskLimitMb()
+		allocated.GpuLimit += resource.GetGpuLimit()
+		allocated.FdLimit += resource.GetFdLimit()
+	}
+	return allocated
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"testing"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetAllocatedResources tests the resources of the running
+// instances of a job are summed up
+func TestGetAllocatedResources(t *testing.T) {
+	config := &job.JobConfig{
+		DefaultConfig: &task.TaskConfig{
+			Resource: &task.ResourceConfig{
+				CpuLimit:    1.5,
+				MemLimitMb:  100,
+				DiskLimitMb: 10,
+				FdLimit:     20,
+			},
+		},
+	}
+	runtime := &job.RuntimeInfo{
+		TaskStats: map[string]uint32{
+			task.TaskState_RUNNING.String():   3,
+			task.TaskState_PENDING.String():   2,
+			task.TaskState_SUCCEEDED.String(): 1,
+		},
+	}
+
+	assert.Equal(t, &task.ResourceConfig{
+		CpuLimit:    4.5,
+		MemLimitMb:  300,
+		DiskLimitMb: 30,
+		FdLimit:     60,
+	}, GetAllocatedResources(config, runtime))
+
+	// no running instances
+	assert.Equal(t,
+		&task.ResourceConfig{},
+		GetAllocatedResources(config, &job.RuntimeInfo{}))
+}
+
+// TestGetInstancesAllocatedResources tests the resources of the running
+// instances of a job are summed up with their instance configs
+func TestGetInstancesAllocatedResources(t *testing.T) {
+	config := &job.JobConfig{
+		DefaultConfig: &task.TaskConfig{
+			Resource: &task.ResourceConfig{
+				CpuLimit:   1.5,
+				MemLimitMb: 100,
+				FdLimit:    20,
+			},
+		},
+		InstanceConfig: map[uint32]*task.TaskConfig{
+			1: {
+				Resource: &task.ResourceConfig{
+					CpuLimit:   4,
+					MemLimitMb: 200,
+					GpuLimit:   1,
+				},
+			},
+			2: {Name: "instance-2"},
+		},
+	}
+
+	assert.Equal(t, &task.ResourceConfig{
+		CpuLimit:   7,
+		MemLimitMb: 400,
+		GpuLimit:   1,
+		FdLimit:    40,
+	}, GetInstancesAllocatedResources(config, []uint32{0, 1, 2}))
+
+	// no running instances
+	assert.Equal(t,
+		&task.ResourceConfig{},
+		GetInstancesAllocatedResources(config, nil))
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"sort"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/respool"
+
+	"github.com/uber/peloton/pkg/common"
+	"github.com/uber/peloton/pkg/common/util"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// GetRespoolJobs builds the resource pool tree from the resource pool
+// configs, with the job summaries added to their resource pool. Returns
+// the root resource pool, and the jobs whose resource pool is not in the
+// tree. Resource pools whose parent is not in the tree are added to the
+// root resource pool. The jobs in terminal states are skipped unless
+// includeTerminalJobs is set.
+func GetRespoolJobs(
+	respools map[string]*respool.ResourcePoolConfig,
+	summaries []*job.JobSummary,
+	includeTerminalJobs bool,
+) (*job.RespoolJobs, []*job.JobSummary) {
+	root := &job.RespoolJobs{
+		RespoolID: &peloton.ResourcePoolID{Value: common.RootResPoolID},
+	}
+
+	nodes := map[string]*job.RespoolJobs{common.RootResPoolID: root}
+	for id, config := range respools {
+		nodes[id] = &job.RespoolJobs{
+			RespoolID: &peloton.ResourcePoolID{Value: id},
+			Config:    config,
+		}
+	}
+
+	for id, config := range respools {
+		parent, ok := nodes[config.GetParent().GetValue()]
+		if !ok {
+			log.WithField("respool_id", id).
+				WithField("parent_id", config.GetParent().GetValue()).
+				Warn("Parent of resource pool not found")
+			parent = root
+		}
+		parent.Children = append(parent.Children, nodes[id])
+	}
+
+	var orphans []*job.JobSummary
+	for _, summary := range summaries {
+		if !includeTerminalJobs &&
+			util.IsPelotonJobStateTerminal(summary.GetRuntime().GetState()) {
+			continue
+		}
+
+		node, ok := nodes[summary.GetRespoolID().GetValue()]
+		if !ok {
+			orphans = append(orphans, summary)
+			continue
+		}
+		node.Jobs = append(node.Jobs, summary)
+	}
+
+	for _, node := range nodes {
+		sortRespoolJobs(node)
+	}
+	sortJobSummaries(orphans)
+	return root, orphans
+}
+
+// sortRespoolJobs sorts the children and jobs of a resource pool by ID.
+func sortRespoolJobs(node *job.RespoolJobs) {
+	sort.Slice(node.Children, func(i, j int) bool {
+		return node.Children[i].GetRespoolID().GetValue() <
+			node.Children[j].GetRespoolID().GetValue()
+	})
+	sortJobSummaries(node.Jobs)
+}
+
+// sortJobSummaries sorts job summaries by job ID.
+func sortJobSummaries(summaries []*job.JobSummary) {
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].GetId().GetValue() <
+			summaries[j].GetId().GetValue()
+	})
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"testing"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/respool"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestJobSummary(
+	jobID string,
+	respoolID string,
+	state job.JobState) *job.JobSummary {
+	return &job.JobSummary{
+		Id:        &peloton.JobID{Value: jobID},
+		RespoolID: &peloton.ResourcePoolID{Value: respoolID},
+		Runtime:   &job.RuntimeInfo{State: state},
+	}
+}
+
+// TestGetRespoolJobs tests the resource pool tree is built with the jobs
+// added to their resource pools
+func TestGetRespoolJobs(t *testing.T) {
+	respools := map[string]*respool.ResourcePoolConfig{
+		"pool-a": {
+			Name:   "a",
+			Parent: &peloton.ResourcePoolID{Value: "root"},
+		},
+		"pool-b": {
+			Name:   "b",
+			Parent: &peloton.ResourcePoolID{Value: "root"},
+		},
+		"pool-a1": {
+			Name:   "a1",
+			Parent: &peloton.ResourcePoolID{Value: "pool-a"},
+		},
+		"pool-x": {
+			Name:   "x",
+			Parent: &peloton.ResourcePoolID{Value: "unknown"},
+		},
+	}
+	summaries := []*job.JobSummary{
+		newTestJobSummary("job-2", "pool-a1", job.JobState_RUNNING),
+		newTestJobSummary("job-1", "pool-a1", job.JobState_PENDING),
+		newTestJobSummary("job-3", "pool-b", job.JobState_SUCCEEDED),
+		newTestJobSummary("job-4", "pool-gone", job.JobState_RUNNING),
+	}
+
+	root, orphans := GetRespoolJobs(respools, summaries, false)
+	assert.Equal(t, "root", root.GetRespoolID().GetValue())
+	assert.Nil(t, root.GetConfig())
+	assert.Len(t, root.GetChildren(), 3)
+
+	a := root.GetChildren()[0]
+	assert.Equal(t, "pool-a", a.GetRespoolID().GetValue())
+	assert.Equal(t, respools["pool-a"], a.GetConfig())
+	assert.Empty(t, a.GetJobs())
+	assert.Len(t, a.GetChildren(), 1)
+
+	a1 := a.GetChildren()[0]
+	assert.Equal(t, "pool-a1", a1.GetRespoolID().GetValue())
+	assert.Equal(t, []*job.JobSummary{summaries[1], summaries[0]}, a1.GetJobs())
+
+	// terminal jobs are skipped
+	b := root.GetChildren()[1]
+	assert.Equal(t, "pool-b", b.GetRespoolID().GetValue())
+	assert.Empty(t, b.GetJobs())
+
+	// resource pools with unknown parent are added to the root
+	assert.Equal(t, "pool-x", root.GetChildren()[2].GetRespoolID().GetValue())
+
+	assert.Equal(t, []*job.JobSummary{summaries[3]}, orphans)
+
+	// terminal jobs are included if requested
+	root, _ = GetRespoolJobs(respools, summaries, true)
+	assert.Equal(t,
+		[]*job.JobSummary{summaries[2]},
+		root.GetChildren()[1].GetJobs())
+}
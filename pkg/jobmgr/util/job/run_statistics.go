@@ -0,0 +1,67 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"sort"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
+)
+
+// GetRunStatistics computes the percentiles of the start latencies and
+// run durations of the given runs.
+func GetRunStatistics(runs []*ormobjects.RunDurationObject) *job.RunStatistics {
+	var startLatencies, runDurations []uint64
+	for _, run := range runs {
+		startLatencies = append(startLatencies, run.StartLatencyMs)
+		runDurations = append(runDurations, run.RunDurationMs)
+	}
+
+	return &job.RunStatistics{
+		NumRuns:      uint32(len(runs)),
+		StartLatency: getDurationPercentiles(startLatencies),
+		RunDuration:  getDurationPercentiles(runDurations),
+	}
+}
+
+// getDurationPercentiles returns the nearest-rank percentiles of
+// the durations.
+func getDurationPercentiles(durations []uint64) *job.DurationPercentiles {
+	if len(durations) == 0 {
+		return &job.DurationPercentiles{}
+	}
+
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
+	})
+
+	percentile := func(p int) uint64 {
+		// index of the smallest duration such that at least
+		// p percent of the durations are less or equal to it
+		rank := (p*len(durations) + 99) / 100
+		if rank < 1 {
+			rank = 1
+		}
+		return durations[rank-1]
+	}
+
+	return &job.DurationPercentiles{
+		P50Ms: percentile(50),
+		P95Ms: percentile(95),
+		MaxMs: durations[len(durations)-1],
+	}
+}
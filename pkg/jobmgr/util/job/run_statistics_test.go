@@ -0,0 +1,60 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"testing"
+
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRunStatistics(t *testing.T) {
+	var runs []*ormobjects.RunDurationObject
+	// insert in descending order to make sure the durations get sorted
+	for i := uint64(100); i > 0; i-- {
+		runs = append(runs, &ormobjects.RunDurationObject{
+			StartLatencyMs: i * 10,
+			RunDurationMs:  i * 1000,
+		})
+	}
+
+	stats := GetRunStatistics(runs)
+	assert.Equal(t, uint32(100), stats.GetNumRuns())
+	assert.Equal(t, uint64(500), stats.GetStartLatency().GetP50Ms())
+	assert.Equal(t, uint64(950), stats.GetStartLatency().GetP95Ms())
+	assert.Equal(t, uint64(1000), stats.GetStartLatency().GetMaxMs())
+	assert.Equal(t, uint64(50000), stats.GetRunDuration().GetP50Ms())
+	assert.Equal(t, uint64(95000), stats.GetRunDuration().GetP95Ms())
+	assert.Equal(t, uint64(100000), stats.GetRunDuration().GetMaxMs())
+}
+
+func TestGetRunStatisticsSingleRun(t *testing.T) {
+	stats := GetRunStatistics([]*ormobjects.RunDurationObject{
+		{StartLatencyMs: 20, RunDurationMs: 3000},
+	})
+	assert.Equal(t, uint32(1), stats.GetNumRuns())
+	assert.Equal(t, uint64(20), stats.GetStartLatency().GetP50Ms())
+	assert.Equal(t, uint64(20), stats.GetStartLatency().GetP95Ms())
+	assert.Equal(t, uint64(3000), stats.GetRunDuration().GetMaxMs())
+}
+
+func TestGetRunStatisticsNoRuns(t *testing.T) {
+	stats := GetRunStatistics(nil)
+	assert.Zero(t, stats.GetNumRuns())
+	assert.Zero(t, stats.GetStartLatency().GetP50Ms())
+	assert.Zero(t, stats.GetRunDuration().GetMaxMs())
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package task
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pborman/uuid"
+)
+
+const (
+	// HostnameInstancePlaceholder is replaced by the instance id in the
+	// hostname template of a task config
+	HostnameInstancePlaceholder = "{instance}"
+
+	// HostnameJobIDPlaceholder is replaced by the job id in the hostname
+	// template of a task config
+	HostnameJobIDPlaceholder = "{job_id}"
+
+	// _maxHostnameLength is the max length of a DNS label
+	_maxHostnameLength = 63
+)
+
+// _hostnameRegex matches the hostnames which are valid DNS labels
+var _hostnameRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// RenderHostname returns the hostname of the container of an instance,
+// rendered from the hostname template of its task config.
+func RenderHostname(template string, jobID string, instanceID uint32) string {
+	return strings.NewReplacer(
+		HostnameInstancePlaceholder, strconv.FormatUint(uint64(instanceID), 10),
+		HostnameJobIDPlaceholder, jobID,
+	).Replace(template)
+}
+
+// ValidateHostnameTemplate validates that the hostname template renders to
+// a distinct and valid DNS label for each of the instances of a job.
+func ValidateHostnameTemplate(template string, instanceCount uint32) error {
+	if !strings.Contains(template, HostnameInstancePlaceholder) {
+		return fmt.Errorf("hostname template %q does not contain %s",
+			template, HostnameInstancePlaceholder)
+	}
+
+	// the job id is not known yet when the job is created, so render the
+	// longest hostname with a job id of the same length
+	lastInstance := uint32(0)
+	if instanceCount > 0 {
+		lastInstance = instanceCount - 1
+	}
+	hostname := RenderHostname(template, uuid.NIL.String(), lastInstance)
+	if len(hostname) > _maxHostnameLength {
+		return fmt.Errorf("hostname %q is longer than %d characters",
+			hostname, _maxHostnameLength)
+	}
+	if !_hostnameRegex.MatchString(hostname) {
+		return fmt.Errorf("hostname %q is not a valid DNS label", hostname)
+	}
+	return nil
+}
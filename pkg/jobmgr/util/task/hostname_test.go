@@ -0,0 +1,54 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package task
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRenderHostname tests rendering the hostname of an instance
+func TestRenderHostname(t *testing.T) {
+	assert.Equal(t, "kafka-3", RenderHostname("kafka-{instance}", "job", 3))
+	assert.Equal(t, "job-1-0-zk",
+		RenderHostname("{job_id}-{instance}-zk", "job-1", 0))
+	assert.Equal(t, "kafka", RenderHostname("kafka", "job", 3))
+}
+
+// TestValidateHostnameTemplate tests validating the hostname templates
+func TestValidateHostnameTemplate(t *testing.T) {
+	tests := []struct {
+		template      string
+		instanceCount uint32
+		valid         bool
+	}{
+		{"kafka-{instance}", 3, true},
+		{"zk-{job_id}-{instance}", 5, true},
+		{"kafka-{instance}", 0, true},
+		// all the instances would have the same hostname
+		{"kafka", 3, false},
+		{"Kafka-{instance}", 3, false},
+		{"kafka_{instance}", 3, false},
+		{"-{instance}", 3, false},
+		{strings.Repeat("a", 61) + "-{instance}", 100, false},
+	}
+
+	for _, test := range tests {
+		err := ValidateHostnameTemplate(test.template, test.instanceCount)
+		assert.Equal(t, test.valid, err == nil, test.template)
+	}
+}
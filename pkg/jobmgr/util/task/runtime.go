@@ -63,11 +63,15 @@ func RegenerateMesosTaskRuntime(
 	taskRuntime.Healthy = initHealthyField
 
 	taskRuntime.AgentID = nil
+	taskRuntime.AgentAddress = ""
+	taskRuntime.PlacementInfo = nil
 	taskRuntime.StartTime = ""
 	taskRuntime.CompletionTime = ""
 	taskRuntime.Host = ""
 	taskRuntime.Ports = make(map[string]uint32)
 	taskRuntime.TerminationStatus = nil
+	taskRuntime.KillGracePeriod = nil
+	taskRuntime.Suspended = false
 	taskRuntime.Reason = ""
 	taskRuntime.Message = ""
 }
@@ -90,11 +94,15 @@ func RegenerateMesosTaskIDDiff(
 		jobmgrcommon.HealthyField:            initHealthyField,
 
 		jobmgrcommon.AgentIDField:           nil,
+		jobmgrcommon.AgentAddressField:      "",
 		jobmgrcommon.StartTimeField:         "",
 		jobmgrcommon.CompletionTimeField:    "",
 		jobmgrcommon.HostField:              "",
+		jobmgrcommon.PlacementInfoField:     nil,
 		jobmgrcommon.PortsField:             make(map[string]uint32),
 		jobmgrcommon.TerminationStatusField: nil,
+		jobmgrcommon.KillGracePeriodField:   nil,
+		jobmgrcommon.SuspendedField:         false,
 		jobmgrcommon.MessageField:           "",
 		jobmgrcommon.ReasonField:            "",
 	}
@@ -148,6 +156,21 @@ func IsSystemFailure(runtime *task.RuntimeInfo) bool {
 	return false
 }
 
+// IsHostFailure returns true if the task was lost because the host it was
+// running on failed, was removed from the cluster or restarted, as opposed
+// to the task itself failing.
+func IsHostFailure(runtime *task.RuntimeInfo) bool {
+	switch runtime.GetReason() {
+	case mesos.TaskStatus_REASON_AGENT_DISCONNECTED.String(),
+		mesos.TaskStatus_REASON_AGENT_REMOVED.String(),
+		mesos.TaskStatus_REASON_AGENT_REMOVED_BY_OPERATOR.String(),
+		mesos.TaskStatus_REASON_AGENT_RESTARTED.String(),
+		mesos.TaskStatus_REASON_AGENT_UNKNOWN.String():
+		return true
+	}
+	return false
+}
+
 // GetExitStatusFromMessage extracts the container exit code from message.
 func GetExitStatusFromMessage(message string) (uint32, error) {
 	if strings.HasPrefix(message, _exitStatusPrefix) {
@@ -65,6 +65,10 @@ func TestRegenerateMesosTaskRuntime(t *testing.T) {
 		runtime := &task.RuntimeInfo{
 			MesosTaskId:        &mesos.TaskID{Value: &tt.curMesosTaskID},
 			DesiredMesosTaskId: &mesos.TaskID{Value: &tt.desiredMesosTaskID},
+			KillGracePeriod:    &task.KillGracePeriod{},
+			Suspended:          true,
+			AgentAddress:       "10.0.0.1:5051",
+			PlacementInfo:      &task.PlacementInfo{Strategy: "batch"},
 		}
 		RegenerateMesosTaskRuntime(
 			&peloton.JobID{Value: tt.jobID},
@@ -80,11 +84,15 @@ func TestRegenerateMesosTaskRuntime(t *testing.T) {
 		assert.Equal(t, runtime.Healthy, tt.initHealthState)
 
 		assert.Empty(t, runtime.AgentID)
+		assert.Empty(t, runtime.AgentAddress)
+		assert.Nil(t, runtime.PlacementInfo)
 		assert.Empty(t, runtime.StartTime)
 		assert.Empty(t, runtime.CompletionTime)
 		assert.Empty(t, runtime.Host)
 		assert.Empty(t, runtime.Ports)
 		assert.Empty(t, runtime.TerminationStatus)
+		assert.Empty(t, runtime.KillGracePeriod)
+		assert.False(t, runtime.Suspended)
 	}
 }
 
@@ -127,6 +135,8 @@ func TestRegenerateMesosTaskIDDiff(t *testing.T) {
 		runtime := &task.RuntimeInfo{
 			MesosTaskId:        &mesos.TaskID{Value: &tt.curMesosTaskID},
 			DesiredMesosTaskId: &mesos.TaskID{Value: &tt.desiredMesosTaskID},
+			KillGracePeriod:    &task.KillGracePeriod{},
+			Suspended:          true,
 		}
 		diff := RegenerateMesosTaskIDDiff(
 			&peloton.JobID{Value: tt.jobID},
@@ -145,11 +155,15 @@ func TestRegenerateMesosTaskIDDiff(t *testing.T) {
 		assert.Equal(t, diff[jobmgrcommon.HealthyField], tt.initHealthState)
 
 		assert.Empty(t, diff[jobmgrcommon.AgentIDField])
+		assert.Empty(t, diff[jobmgrcommon.AgentAddressField])
 		assert.Empty(t, diff[jobmgrcommon.StartTimeField])
 		assert.Empty(t, diff[jobmgrcommon.CompletionTimeField])
 		assert.Empty(t, diff[jobmgrcommon.HostField])
+		assert.Empty(t, diff[jobmgrcommon.PlacementInfoField])
 		assert.Empty(t, diff[jobmgrcommon.PortsField])
 		assert.Empty(t, diff[jobmgrcommon.TerminationStatusField])
+		assert.Empty(t, diff[jobmgrcommon.KillGracePeriodField])
+		assert.Equal(t, false, diff[jobmgrcommon.SuspendedField])
 	}
 }
 
@@ -238,6 +252,50 @@ func TestIsSystemFailure(t *testing.T) {
 	}
 }
 
+// TestIsHostFailure tests the task failure reasons which are
+// considered host failures
+func TestIsHostFailure(t *testing.T) {
+	testTable := []struct {
+		taskRuntime   *task.RuntimeInfo
+		isHostFailure bool
+	}{
+		{
+			&task.RuntimeInfo{
+				Reason: mesos.TaskStatus_REASON_AGENT_REMOVED.String(),
+			},
+			true,
+		},
+		{
+			&task.RuntimeInfo{
+				Reason: mesos.TaskStatus_REASON_AGENT_DISCONNECTED.String(),
+			},
+			true,
+		},
+		{
+			&task.RuntimeInfo{
+				Reason: mesos.TaskStatus_REASON_AGENT_RESTARTED.String(),
+			},
+			true,
+		},
+		{
+			&task.RuntimeInfo{
+				Reason: mesos.TaskStatus_REASON_CONTAINER_LAUNCH_FAILED.String(),
+			},
+			false,
+		},
+		{
+			&task.RuntimeInfo{
+				Reason: mesos.TaskStatus_REASON_COMMAND_EXECUTOR_FAILED.String(),
+			},
+			false,
+		},
+	}
+
+	for _, test := range testTable {
+		assert.Equal(t, test.isHostFailure, IsHostFailure(test.taskRuntime))
+	}
+}
+
 // TestGetExitStatusFromMessage tests various cases for
 // GetExitStatusFromMessage
 func TestGetExitStatusFromMessage(t *testing.T) {
@@ -0,0 +1,49 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verifier
+
+import "time"
+
+const (
+	_defaultVerifyInterval = 5 * time.Minute
+	_defaultSampleSize     = 100
+)
+
+// Config for the verification of the task runtimes in the cache
+// against the runtimes in storage
+type Config struct {
+	// Whether the leader periodically verifies a sample of the cached
+	// task runtimes against storage
+	Enabled bool `yaml:"enabled"`
+
+	// Interval at which a sample of the cached tasks is verified
+	Interval time.Duration `yaml:"interval"`
+
+	// Number of cached tasks verified at each interval
+	SampleSize int `yaml:"sample_size"`
+
+	// Whether the divergent tasks are reloaded from storage and enqueued
+	// into the goal state engine, instead of only being reported
+	AutoReload bool `yaml:"auto_reload"`
+}
+
+func (c *Config) normalize() {
+	if c.Interval <= 0 {
+		c.Interval = _defaultVerifyInterval
+	}
+	if c.SampleSize <= 0 {
+		c.SampleSize = _defaultSampleSize
+	}
+}
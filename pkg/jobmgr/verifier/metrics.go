@@ -0,0 +1,50 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verifier
+
+import "github.com/uber-go/tally"
+
+// Metrics is the struct containing all the counters that track the
+// verification of the cached task runtimes
+type Metrics struct {
+	// Number of cached tasks verified against storage
+	TasksVerified tally.Counter
+	// Number of cached tasks whose runtime diverged from storage
+	TasksDiverged tally.Counter
+	// Number of cached tasks which could not be read from storage
+	TaskReadFail tally.Counter
+
+	TaskReloadSuccess tally.Counter
+	TaskReloadFail    tally.Counter
+
+	// Time taken to verify a sample of the cached tasks
+	VerifyDuration tally.Timer
+}
+
+// NewMetrics returns a new Metrics struct, with all metrics
+// initialized and rooted at the given tally.Scope
+func NewMetrics(scope tally.Scope) *Metrics {
+	successScope := scope.Tagged(map[string]string{"result": "success"})
+	failScope := scope.Tagged(map[string]string{"result": "fail"})
+
+	return &Metrics{
+		TasksVerified:     scope.Counter("tasks_verified"),
+		TasksDiverged:     scope.Counter("tasks_diverged"),
+		TaskReadFail:      failScope.Counter("task_read"),
+		TaskReloadSuccess: successScope.Counter("task_reload"),
+		TaskReloadFail:    failScope.Counter("task_reload"),
+		VerifyDuration:    scope.Timer("verify_duration"),
+	}
+}
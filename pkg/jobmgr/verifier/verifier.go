@@ -0,0 +1,200 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verifier
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	pbtask "github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/uber/peloton/pkg/common/background"
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+	"github.com/uber/peloton/pkg/jobmgr/goalstate"
+	"github.com/uber/peloton/pkg/storage"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/uber-go/atomic"
+	"github.com/uber-go/tally"
+)
+
+const (
+	// _verifyWorkName is the name of the background work verifying the
+	// cached task runtimes
+	_verifyWorkName = "cache_verifier"
+
+	// _verifyTimeout is the timeout of verifying a sample of the tasks
+	_verifyTimeout = time.Minute
+)
+
+// Verifier periodically compares a random sample of the task runtimes in
+// the cache with the runtimes in storage, and reloads the divergent ones,
+// which an operator would otherwise do with the Refresh API.
+type Verifier struct {
+	jobFactory      cached.JobFactory
+	taskStore       storage.TaskStore
+	goalStateDriver goalstate.Driver
+
+	sampleSize int
+	autoReload bool
+	interval   time.Duration
+	metrics    *Metrics
+}
+
+// NewVerifier returns a Verifier of the tasks in the cache of the job
+// factory.
+func NewVerifier(
+	jobFactory cached.JobFactory,
+	taskStore storage.TaskStore,
+	goalStateDriver goalstate.Driver,
+	cfg Config,
+	parent tally.Scope,
+) *Verifier {
+	cfg.normalize()
+	return &Verifier{
+		jobFactory:      jobFactory,
+		taskStore:       taskStore,
+		goalStateDriver: goalStateDriver,
+		sampleSize:      cfg.SampleSize,
+		autoReload:      cfg.AutoReload,
+		interval:        cfg.Interval,
+		metrics:         NewMetrics(parent.SubScope("jobmgr").SubScope("verifier")),
+	}
+}
+
+// Work returns the background work verifying the cached tasks, which is
+// meant to run on the leader only.
+func (v *Verifier) Work() background.Work {
+	return background.Work{
+		Name: _verifyWorkName,
+		Func: func(_ *atomic.Bool) {
+			v.verify()
+		},
+		Period: v.interval,
+	}
+}
+
+// verify compares a sample of the cached tasks with storage.
+func (v *Verifier) verify() {
+	ctx, cancel := context.WithTimeout(context.Background(), _verifyTimeout)
+	defer cancel()
+
+	start := time.Now()
+	for _, cachedTask := range v.sample() {
+		if ctx.Err() != nil {
+			break
+		}
+		v.verifyTask(ctx, cachedTask)
+	}
+	v.metrics.VerifyDuration.Record(time.Since(start))
+}
+
+// sample returns a random sample of the tasks in the cache, using
+// reservoir sampling to avoid copying all the cached tasks.
+func (v *Verifier) sample() []cached.Task {
+	var tasks []cached.Task
+	seen := 0
+	for _, cachedJob := range v.jobFactory.GetAllJobs() {
+		for _, cachedTask := range cachedJob.GetAllTasks() {
+			seen++
+			if len(tasks) < v.sampleSize {
+				tasks = append(tasks, cachedTask)
+				continue
+			}
+			if i := rand.Intn(seen); i < v.sampleSize {
+				tasks[i] = cachedTask
+			}
+		}
+	}
+	return tasks
+}
+
+// verifyTask compares the runtime of a cached task with storage, and
+// reloads it from storage if they diverged.
+func (v *Verifier) verifyTask(ctx context.Context, cachedTask cached.Task) {
+	jobID := cachedTask.JobID()
+	instanceID := cachedTask.ID()
+
+	cacheRuntime, err := cachedTask.GetRuntime(ctx)
+	if err != nil {
+		v.metrics.TaskReadFail.Inc(1)
+		return
+	}
+	dbRuntime, err := v.taskStore.GetTaskRuntime(ctx, jobID, instanceID)
+	if err != nil {
+		log.WithError(err).
+			WithField("job_id", jobID.GetValue()).
+			WithField("instance_id", instanceID).
+			Warn("failed to read task runtime to verify the cache")
+		v.metrics.TaskReadFail.Inc(1)
+		return
+	}
+	v.metrics.TasksVerified.Inc(1)
+
+	if !diverged(cacheRuntime, dbRuntime) {
+		return
+	}
+
+	// the runtime is written to storage before the cache, so the task
+	// may have been updated while it was read from storage
+	currentRuntime, err := cachedTask.GetRuntime(ctx)
+	if err != nil ||
+		currentRuntime.GetRevision().GetVersion() !=
+			cacheRuntime.GetRevision().GetVersion() {
+		return
+	}
+
+	v.metrics.TasksDiverged.Inc(1)
+	log.WithField("job_id", jobID.GetValue()).
+		WithField("instance_id", instanceID).
+		WithField("cache_version", cacheRuntime.GetRevision().GetVersion()).
+		WithField("db_version", dbRuntime.GetRevision().GetVersion()).
+		WithField("cache_state", cacheRuntime.GetState().String()).
+		WithField("db_state", dbRuntime.GetState().String()).
+		WithField("auto_reload", v.autoReload).
+		Warn("task runtime in cache diverged from storage")
+
+	if !v.autoReload {
+		return
+	}
+
+	// the runtime is only reloaded if the task was not updated since it
+	// was verified, so that the cache never regresses to an older runtime
+	replaced, err := cachedTask.ReplaceRuntimeIfVersion(
+		dbRuntime, cacheRuntime.GetRevision().GetVersion())
+	if err != nil {
+		log.WithError(err).
+			WithField("job_id", jobID.GetValue()).
+			WithField("instance_id", instanceID).
+			Error("failed to reload divergent task runtime")
+		v.metrics.TaskReloadFail.Inc(1)
+		return
+	}
+	if !replaced {
+		return
+	}
+	v.goalStateDriver.EnqueueTask(jobID, instanceID, time.Now())
+	v.metrics.TaskReloadSuccess.Inc(1)
+}
+
+// diverged returns whether the runtime of a task in the cache differs
+// from its runtime in storage.
+func diverged(cacheRuntime *pbtask.RuntimeInfo, dbRuntime *pbtask.RuntimeInfo) bool {
+	return cacheRuntime.GetRevision().GetVersion() !=
+		dbRuntime.GetRevision().GetVersion() ||
+		cacheRuntime.GetState() != dbRuntime.GetState() ||
+		cacheRuntime.GetGoalState() != dbRuntime.GetGoalState()
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verifier
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	pbtask "github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
+	goalstatemocks "github.com/uber/peloton/pkg/jobmgr/goalstate/mocks"
+	storemocks "github.com/uber/peloton/pkg/storage/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+)
+
+type VerifierTestSuite struct {
+	suite.Suite
+
+	ctrl            *gomock.Controller
+	jobFactory      *cachedmocks.MockJobFactory
+	cachedJob       *cachedmocks.MockJob
+	cachedTask      *cachedmocks.MockTask
+	taskStore       *storemocks.MockTaskStore
+	goalStateDriver *goalstatemocks.MockDriver
+	jobID           *peloton.JobID
+}
+
+func (suite *VerifierTestSuite) SetupTest() {
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.jobFactory = cachedmocks.NewMockJobFactory(suite.ctrl)
+	suite.cachedJob = cachedmocks.NewMockJob(suite.ctrl)
+	suite.cachedTask = cachedmocks.NewMockTask(suite.ctrl)
+	suite.taskStore = storemocks.NewMockTaskStore(suite.ctrl)
+	suite.goalStateDriver = goalstatemocks.NewMockDriver(suite.ctrl)
+	suite.jobID = &peloton.JobID{Value: "job1"}
+}
+
+func (suite *VerifierTestSuite) TearDownTest() {
+	suite.ctrl.Finish()
+}
+
+func TestVerifier(t *testing.T) {
+	suite.Run(t, new(VerifierTestSuite))
+}
+
+// newVerifier returns a verifier of the cached task of the suite
+func (suite *VerifierTestSuite) newVerifier(autoReload bool) *Verifier {
+	suite.jobFactory.EXPECT().
+		GetAllJobs().
+		Return(map[string]cached.Job{suite.jobID.GetValue(): suite.cachedJob})
+	suite.cachedJob.EXPECT().
+		GetAllTasks().
+		Return(map[uint32]cached.Task{0: suite.cachedTask})
+	suite.cachedTask.EXPECT().JobID().Return(suite.jobID).AnyTimes()
+	suite.cachedTask.EXPECT().ID().Return(uint32(0)).AnyTimes()
+
+	return NewVerifier(
+		suite.jobFactory,
+		suite.taskStore,
+		suite.goalStateDriver,
+		Config{Enabled: true, AutoReload: autoReload},
+		tally.NoopScope,
+	)
+}
+
+func newRuntime(version uint64, state pbtask.TaskState) *pbtask.RuntimeInfo {
+	return &pbtask.RuntimeInfo{
+		State:     state,
+		GoalState: pbtask.TaskState_RUNNING,
+		Revision:  &peloton.ChangeLog{Version: version},
+	}
+}
+
+// TestVerifyConsistentTask tests that a task whose cached runtime matches
+// storage is not reloaded
+func (suite *VerifierTestSuite) TestVerifyConsistentTask() {
+	verifier := suite.newVerifier(true)
+	suite.cachedTask.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(newRuntime(2, pbtask.TaskState_RUNNING), nil)
+	suite.taskStore.EXPECT().
+		GetTaskRuntime(gomock.Any(), suite.jobID, uint32(0)).
+		Return(newRuntime(2, pbtask.TaskState_RUNNING), nil)
+
+	verifier.Work().Func(nil)
+}
+
+// TestVerifyDivergentTask tests that a task whose cached runtime diverged
+// from storage is reloaded and enqueued into the goal state engine
+func (suite *VerifierTestSuite) TestVerifyDivergentTask() {
+	verifier := suite.newVerifier(true)
+	dbRuntime := newRuntime(3, pbtask.TaskState_KILLED)
+	suite.cachedTask.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(newRuntime(2, pbtask.TaskState_RUNNING), nil).
+		Times(2)
+	suite.taskStore.EXPECT().
+		GetTaskRuntime(gomock.Any(), suite.jobID, uint32(0)).
+		Return(dbRuntime, nil)
+	suite.cachedTask.EXPECT().
+		ReplaceRuntimeIfVersion(dbRuntime, uint64(2)).
+		Return(true, nil)
+	suite.goalStateDriver.EXPECT().
+		EnqueueTask(suite.jobID, uint32(0), gomock.Any())
+
+	verifier.Work().Func(nil)
+}
+
+// TestVerifyDivergentTaskUpdatedOnReload tests that a divergent task
+// updated right before it is reloaded is neither reloaded nor enqueued
+func (suite *VerifierTestSuite) TestVerifyDivergentTaskUpdatedOnReload() {
+	verifier := suite.newVerifier(true)
+	dbRuntime := newRuntime(3, pbtask.TaskState_KILLED)
+	suite.cachedTask.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(newRuntime(2, pbtask.TaskState_RUNNING), nil).
+		Times(2)
+	suite.taskStore.EXPECT().
+		GetTaskRuntime(gomock.Any(), suite.jobID, uint32(0)).
+		Return(dbRuntime, nil)
+	suite.cachedTask.EXPECT().
+		ReplaceRuntimeIfVersion(dbRuntime, uint64(2)).
+		Return(false, nil)
+
+	verifier.Work().Func(nil)
+}
+
+// TestVerifyDivergentTaskNoReload tests that a divergent task is only
+// reported when the automatic reload is disabled
+func (suite *VerifierTestSuite) TestVerifyDivergentTaskNoReload() {
+	verifier := suite.newVerifier(false)
+	suite.cachedTask.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(newRuntime(2, pbtask.TaskState_RUNNING), nil).
+		Times(2)
+	suite.taskStore.EXPECT().
+		GetTaskRuntime(gomock.Any(), suite.jobID, uint32(0)).
+		Return(newRuntime(3, pbtask.TaskState_KILLED), nil)
+
+	verifier.Work().Func(nil)
+}
+
+// TestVerifyConcurrentlyUpdatedTask tests that a task updated while it is
+// verified is not reloaded
+func (suite *VerifierTestSuite) TestVerifyConcurrentlyUpdatedTask() {
+	verifier := suite.newVerifier(true)
+	gomock.InOrder(
+		suite.cachedTask.EXPECT().
+			GetRuntime(gomock.Any()).
+			Return(newRuntime(2, pbtask.TaskState_RUNNING), nil),
+		suite.taskStore.EXPECT().
+			GetTaskRuntime(gomock.Any(), suite.jobID, uint32(0)).
+			Return(newRuntime(3, pbtask.TaskState_KILLED), nil),
+		suite.cachedTask.EXPECT().
+			GetRuntime(gomock.Any()).
+			Return(newRuntime(3, pbtask.TaskState_KILLED), nil),
+	)
+
+	verifier.Work().Func(nil)
+}
+
+// TestVerifyTaskReadFail tests that a task which cannot be read from
+// storage is skipped
+func (suite *VerifierTestSuite) TestVerifyTaskReadFail() {
+	verifier := suite.newVerifier(true)
+	suite.cachedTask.EXPECT().
+		GetRuntime(gomock.Any()).
+		Return(newRuntime(2, pbtask.TaskState_RUNNING), nil)
+	suite.taskStore.EXPECT().
+		GetTaskRuntime(gomock.Any(), suite.jobID, uint32(0)).
+		Return(nil, errors.New("test error"))
+
+	verifier.Work().Func(nil)
+}
+
+// TestSample tests that the sample of the cached tasks is bounded by
+// the sample size
+func (suite *VerifierTestSuite) TestSample() {
+	tasks := make(map[uint32]cached.Task)
+	for i := uint32(0); i < 10; i++ {
+		tasks[i] = cachedmocks.NewMockTask(suite.ctrl)
+	}
+	suite.jobFactory.EXPECT().
+		GetAllJobs().
+		Return(map[string]cached.Job{suite.jobID.GetValue(): suite.cachedJob})
+	suite.cachedJob.EXPECT().
+		GetAllTasks().
+		Return(tasks)
+
+	verifier := NewVerifier(
+		suite.jobFactory,
+		suite.taskStore,
+		suite.goalStateDriver,
+		Config{Enabled: true, SampleSize: 4},
+		tally.NoopScope,
+	)
+	suite.Len(verifier.sample(), 4)
+}
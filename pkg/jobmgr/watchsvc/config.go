@@ -14,9 +14,33 @@
 
 package watchsvc
 
+import "time"
+
 const (
-	_defaultBufferSize int = 100
-	_defaultMaxClient  int = 1000
+	_defaultBufferSize  int = 100
+	_defaultMaxClient   int = 1000
+	_defaultHistorySize int = 1000
+
+	_defaultCoalesceWindow    = time.Second
+	_defaultHeartbeatInterval = 30 * time.Second
+
+	_defaultFanOutShards    int = 8
+	_defaultFanOutQueueSize int = 1000
+
+	_defaultFirehoseBufferSize int = 10000
+	_defaultFirehoseMaxClient  int = 10
+
+	_defaultJournalRetention         = 10 * time.Minute
+	_defaultJournalMaxSize       int = 100000
+	_defaultJournalFlushInterval     = time.Second
+	_defaultJournalMaxReplaySize int = 1000
+
+	_defaultResourceUsageSampleInterval     = 30 * time.Second
+	_defaultResourceUsageMaxPods        int = 1000
+
+	_defaultResourceUsageMaxConcurrentHosts int = 32
+
+	_defaultRespoolRefreshInterval = time.Minute
 )
 
 // Config for Watch API
@@ -26,6 +50,123 @@ type Config struct {
 
 	// Maximum number of concurrent watch clients
 	MaxClient int `yaml:"max_client"`
+
+	// Number of most recent pod changes kept in memory, so that clients
+	// reconnecting with the revision of the last change they received
+	// can resume their watch without missing changes
+	HistorySize int `yaml:"history_size"`
+
+	// Window within which the consecutive changes of a pod are sent as
+	// a single change with the latest state of the pod, to the clients
+	// asking for the changes to be coalesced
+	CoalesceWindow time.Duration `yaml:"coalesce_window"`
+
+	// Interval after which a heartbeat is sent to the task watch clients
+	// which received no change, so that proxies do not close the idle
+	// streams, and clients can tell an idle watch from a dead connection
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+
+	// Number of shards the task watch clients are spread across by watch
+	// id, the pod changes are fanned out to the clients of each shard by
+	// a goroutine of its own
+	FanOutShards int `yaml:"fan_out_shards"`
+
+	// Number of pod changes queued to each shard before notifying a
+	// change blocks
+	FanOutQueueSize int `yaml:"fan_out_queue_size"`
+
+	// Whether the pod watches and replays must select the pods by
+	// resource pool, so that the controllers of a team can only watch
+	// the pods of the resource pool of the team. The firehose is not
+	// restricted, as its clients are authenticated.
+	RequireRespool bool `yaml:"require_respool"`
+
+	// Interval at which the resource pools selected by the pod filter of
+	// a watch are resolved again, so that the watch follows the resource
+	// pools created under the resource pool of the filter
+	RespoolRefreshInterval time.Duration `yaml:"respool_refresh_interval"`
+
+	// Config of the cluster-wide firehose
+	Firehose FirehoseConfig `yaml:"firehose"`
+
+	// Config of the HTTP gateway streaming the watches as Server-Sent Events
+	Gateway GatewayConfig `yaml:"gateway"`
+
+	// Config of the journal of the pod changes replayed by Replay
+	Journal JournalConfig `yaml:"journal"`
+
+	// Config of the resource usage samples streamed back on the pod
+	// watches including the resource usage
+	ResourceUsage ResourceUsageConfig `yaml:"resource_usage"`
+}
+
+// FirehoseConfig for the firehose of Watch API
+type FirehoseConfig struct {
+	// Token authenticating the firehose clients, which must be sent
+	// with the "Authorization: Bearer <token>" header. The firehose
+	// is disabled if no token is configured.
+	Token string `yaml:"token"`
+
+	// Size of per-client internal buffer
+	BufferSize int `yaml:"buffer_size"`
+
+	// Maximum number of concurrent firehose clients
+	MaxClient int `yaml:"max_client"`
+}
+
+// GatewayConfig for the HTTP gateway of Watch API, which lets browser-based
+// UIs consume the watches without a YARPC client
+type GatewayConfig struct {
+	// Whether the gateway is served by the HTTP port of the job manager.
+	// The gateway is not served when TLS is enabled, since the HTTP port
+	// is plaintext and the gateway does not authenticate its clients.
+	Enabled bool `yaml:"enabled"`
+
+	// Origins of the UIs allowed to open streams from a browser. "*"
+	// allows any origin.
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+// JournalConfig for the journal of the pod changes, which lets clients
+// that were down for longer than the history of the watches catch up on
+// the changes they missed
+type JournalConfig struct {
+	// Period of time the pod changes are kept in the journal for
+	Retention time.Duration `yaml:"retention"`
+
+	// Maximum number of pod changes kept in memory, the oldest changes
+	// are evicted first
+	MaxSize int `yaml:"max_size"`
+
+	// Whether the pod changes are written to Cassandra, so that the
+	// changes evicted from memory can be replayed as well. The changes
+	// journaled before the job manager became the leader are not
+	// replayed. The changes are kept in Cassandra for an hour
+	// at most, regardless of the retention.
+	Persist bool `yaml:"persist"`
+
+	// Interval at which the pod changes are written to Cassandra, by
+	// the leader only
+	FlushInterval time.Duration `yaml:"flush_interval"`
+
+	// Maximum number of pod changes returned by each call to Replay
+	MaxReplaySize int `yaml:"max_replay_size"`
+}
+
+// ResourceUsageConfig for the resource usage of the running pods sampled
+// from the agents for the pod watches including the resource usage
+type ResourceUsageConfig struct {
+	// Interval at which the resource usage of the pods of each watch is
+	// sampled. The resource usage of a host is sampled at most once per
+	// interval, and shared by the watches.
+	SampleInterval time.Duration `yaml:"sample_interval"`
+
+	// Maximum number of running pods sampled for each watch, the pods
+	// are selected in order of pod name
+	MaxPods int `yaml:"max_pods"`
+
+	// Maximum number of hosts sampled concurrently for each watch
+	MaxConcurrentHosts int `yaml:"max_concurrent_hosts"`
 }
 
 func (c *Config) normalize() {
@@ -35,4 +176,49 @@ func (c *Config) normalize() {
 	if c.MaxClient <= 0 {
 		c.MaxClient = _defaultMaxClient
 	}
+	if c.HistorySize <= 0 {
+		c.HistorySize = _defaultHistorySize
+	}
+	if c.CoalesceWindow <= 0 {
+		c.CoalesceWindow = _defaultCoalesceWindow
+	}
+	if c.HeartbeatInterval <= 0 {
+		c.HeartbeatInterval = _defaultHeartbeatInterval
+	}
+	if c.RespoolRefreshInterval <= 0 {
+		c.RespoolRefreshInterval = _defaultRespoolRefreshInterval
+	}
+	if c.FanOutShards <= 0 {
+		c.FanOutShards = _defaultFanOutShards
+	}
+	if c.FanOutQueueSize <= 0 {
+		c.FanOutQueueSize = _defaultFanOutQueueSize
+	}
+	if c.Firehose.BufferSize <= 0 {
+		c.Firehose.BufferSize = _defaultFirehoseBufferSize
+	}
+	if c.Firehose.MaxClient <= 0 {
+		c.Firehose.MaxClient = _defaultFirehoseMaxClient
+	}
+	if c.Journal.Retention <= 0 {
+		c.Journal.Retention = _defaultJournalRetention
+	}
+	if c.Journal.MaxSize <= 0 {
+		c.Journal.MaxSize = _defaultJournalMaxSize
+	}
+	if c.Journal.FlushInterval <= 0 {
+		c.Journal.FlushInterval = _defaultJournalFlushInterval
+	}
+	if c.Journal.MaxReplaySize <= 0 {
+		c.Journal.MaxReplaySize = _defaultJournalMaxReplaySize
+	}
+	if c.ResourceUsage.SampleInterval <= 0 {
+		c.ResourceUsage.SampleInterval = _defaultResourceUsageSampleInterval
+	}
+	if c.ResourceUsage.MaxPods <= 0 {
+		c.ResourceUsage.MaxPods = _defaultResourceUsageMaxPods
+	}
+	if c.ResourceUsage.MaxConcurrentHosts <= 0 {
+		c.ResourceUsage.MaxConcurrentHosts = _defaultResourceUsageMaxConcurrentHosts
+	}
 }
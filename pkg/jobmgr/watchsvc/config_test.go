@@ -26,4 +26,16 @@ func TestConfigNormalize(t *testing.T) {
 	c.normalize()
 	assert.True(t, c.BufferSize > 0)
 	assert.True(t, c.MaxClient > 0)
+	assert.True(t, c.HeartbeatInterval > 0)
+	assert.True(t, c.FanOutShards > 0)
+	assert.True(t, c.FanOutQueueSize > 0)
+	assert.True(t, c.Firehose.BufferSize > 0)
+	assert.True(t, c.Firehose.MaxClient > 0)
+	assert.True(t, c.Journal.Retention > 0)
+	assert.True(t, c.Journal.MaxSize > 0)
+	assert.True(t, c.Journal.FlushInterval > 0)
+	assert.True(t, c.Journal.MaxReplaySize > 0)
+	assert.True(t, c.ResourceUsage.SampleInterval > 0)
+	assert.True(t, c.ResourceUsage.MaxPods > 0)
+	assert.True(t, c.ResourceUsage.MaxConcurrentHosts > 0)
 }
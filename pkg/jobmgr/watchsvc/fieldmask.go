@@ -0,0 +1,118 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watchsvc
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
+
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const (
+	_podNamePath      = "pod_name"
+	_statusPath       = "status"
+	_statusPathPrefix = "status."
+)
+
+// podStatusFields maps the proto names of the fields of PodStatus to
+// their index in the generated struct.
+var podStatusFields = getProtoFieldIndexes(reflect.TypeOf(pod.PodStatus{}))
+
+// getProtoFieldIndexes returns the index of each field of a generated
+// proto struct keyed by the proto name of the field.
+func getProtoFieldIndexes(t reflect.Type) map[string]int {
+	fields := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		for _, part := range strings.Split(t.Field(i).Tag.Get("protobuf"), ",") {
+			if strings.HasPrefix(part, "name=") {
+				fields[strings.TrimPrefix(part, "name=")] = i
+			}
+		}
+	}
+	return fields
+}
+
+// validatePodFieldMask returns an invalid-argument error if any path
+// of the field mask is not a field of PodSummary.
+func validatePodFieldMask(paths []string) error {
+	for _, path := range paths {
+		if path == _podNamePath || path == _statusPath {
+			continue
+		}
+		if strings.HasPrefix(path, _statusPathPrefix) {
+			if _, ok := podStatusFields[strings.TrimPrefix(
+				path, _statusPathPrefix)]; ok {
+				continue
+			}
+		}
+		return yarpcerrors.InvalidArgumentErrorf(
+			"unknown field mask path %q", path)
+	}
+	return nil
+}
+
+// applyPodFieldMask returns a copy of the pod summary with only the
+// fields in the field mask set. The pod name is always set, and the
+// pod summary is returned as is if the field mask is empty.
+func applyPodFieldMask(
+	p *pod.PodSummary,
+	paths []string,
+) *pod.PodSummary {
+	if len(paths) == 0 {
+		return p
+	}
+
+	result := &pod.PodSummary{PodName: p.GetPodName()}
+	if p.GetStatus() == nil {
+		return result
+	}
+
+	src := reflect.ValueOf(p.GetStatus()).Elem()
+	dst := reflect.New(src.Type()).Elem()
+	masked := false
+	for _, path := range paths {
+		if path == _statusPath {
+			result.Status = p.GetStatus()
+			return result
+		}
+		if !strings.HasPrefix(path, _statusPathPrefix) {
+			continue
+		}
+		if i, ok := podStatusFields[strings.TrimPrefix(
+			path, _statusPathPrefix)]; ok {
+			dst.Field(i).Set(src.Field(i))
+			masked = true
+		}
+	}
+	if masked {
+		result.Status = dst.Addr().Interface().(*pod.PodStatus)
+	}
+	return result
+}
+
+// maskPodChange returns the change with only the fields of the pod in
+// the field mask set, or the change as is if the field mask is empty.
+func maskPodChange(change *PodChange, paths []string) *PodChange {
+	if len(paths) == 0 {
+		return change
+	}
+	return &PodChange{
+		Revision: change.Revision,
+		Pod:      applyPodFieldMask(change.Pod, paths),
+	}
+}
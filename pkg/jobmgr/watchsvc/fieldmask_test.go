@@ -0,0 +1,66 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watchsvc
+
+import (
+	"testing"
+
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// TestValidatePodFieldMask tests validation of the field mask paths
+func TestValidatePodFieldMask(t *testing.T) {
+	assert.NoError(t, validatePodFieldMask(nil))
+	assert.NoError(t, validatePodFieldMask(
+		[]string{"pod_name", "status", "status.state", "status.host"}))
+
+	for _, path := range []string{"state", "status.unknown", "spec"} {
+		err := validatePodFieldMask([]string{path})
+		assert.Error(t, err)
+		assert.True(t, yarpcerrors.IsInvalidArgument(err))
+	}
+}
+
+// TestApplyPodFieldMask tests only the fields of the field mask are
+// returned, along with the pod name
+func TestApplyPodFieldMask(t *testing.T) {
+	p := &pod.PodSummary{
+		PodName: &peloton.PodName{Value: "pod-0"},
+		Status: &pod.PodStatus{
+			State:   pod.PodState_POD_STATE_RUNNING,
+			Host:    "host-0",
+			Message: "running",
+		},
+	}
+
+	assert.Equal(t, p, applyPodFieldMask(p, nil))
+	assert.Equal(t, p, applyPodFieldMask(p, []string{"status"}))
+	assert.Equal(t,
+		&pod.PodSummary{PodName: p.GetPodName()},
+		applyPodFieldMask(p, []string{"pod_name"}))
+	assert.Equal(t,
+		&pod.PodSummary{
+			PodName: p.GetPodName(),
+			Status: &pod.PodStatus{
+				State: pod.PodState_POD_STATE_RUNNING,
+				Host:  "host-0",
+			},
+		},
+		applyPodFieldMask(p, []string{"status.state", "status.host"}))
+}
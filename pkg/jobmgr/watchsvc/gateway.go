@@ -0,0 +1,283 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watchsvc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/watch/svc"
+
+	"github.com/gogo/protobuf/jsonpb"
+	log "github.com/sirupsen/logrus"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const (
+	// GatewayPath is the HTTP path of the gateway streaming the watches
+	// as Server-Sent Events
+	GatewayPath = "/watch/events"
+
+	// _gatewayRequestParam is the query parameter holding the watch
+	// request, encoded in JSON
+	_gatewayRequestParam = "request"
+
+	// _lastEventIDHeader is sent by the browsers when reconnecting, with
+	// the id of the last event received
+	_lastEventIDHeader = "Last-Event-ID"
+
+	// names of the events sent by the gateway
+	_watchEvent = "watch"
+	_errorEvent = "error"
+)
+
+// gatewayError is the data of the event sent when the watch stops.
+type gatewayError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// gateway bridges the watches to Server-Sent Events, so that browser-based
+// UIs can consume the changes with an EventSource.
+//
+// The watch request is passed, in JSON, in the "request" query parameter.
+// Each watch response is sent as a "watch" event, with the revision of the
+// response as id, so that a reconnecting EventSource resumes the watch from
+// the last revision it received. The stream ends with an "error" event
+// holding the error code and message the watch stopped with.
+type gateway struct {
+	handler *ServiceHandler
+	metrics *Metrics
+	config  GatewayConfig
+}
+
+// InitWatchGateway registers the gateway streaming the watches of the
+// handler as Server-Sent Events on the mux if it is enabled. The gateway
+// is not registered when TLS is enabled, since the mux is then served in
+// plaintext and the gateway does not authenticate its clients.
+func InitWatchGateway(
+	mux *http.ServeMux,
+	handler *ServiceHandler,
+	config Config,
+	tlsEnabled bool) {
+	if !config.Gateway.Enabled {
+		return
+	}
+	if tlsEnabled {
+		log.Error("Watch gateway is not served over plaintext HTTP " +
+			"when TLS is enabled")
+		return
+	}
+
+	mux.Handle(GatewayPath, newGateway(
+		handler,
+		handler.metrics,
+		config.Gateway,
+	))
+}
+
+func newGateway(
+	handler *ServiceHandler,
+	metrics *Metrics,
+	config GatewayConfig) *gateway {
+	return &gateway{
+		handler: handler,
+		metrics: metrics,
+		config:  config,
+	}
+}
+
+// ServeHTTP streams the changes of a watch until the watch stops or the
+// client disconnects.
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.metrics.GatewayWatch.Inc(1)
+
+	if r.Method != http.MethodGet {
+		g.metrics.GatewayWatchFail.Inc(1)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		g.metrics.GatewayWatchFail.Inc(1)
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	req := &svc.WatchRequest{}
+	if err := jsonpb.UnmarshalString(
+		r.URL.Query().Get(_gatewayRequestParam), req); err != nil {
+		g.metrics.GatewayWatchFail.Inc(1)
+		http.Error(w, "invalid watch request", http.StatusBadRequest)
+		return
+	}
+
+	// resume the watch after the last change received before reconnecting
+	if id := r.Header.Get(_lastEventIDHeader); len(id) != 0 {
+		revision, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			g.metrics.GatewayWatchFail.Inc(1)
+			http.Error(w, "invalid last event id", http.StatusBadRequest)
+			return
+		}
+		req.StartRevision = revision
+		req.IncludeSnapshot = false
+	}
+
+	if origin := r.Header.Get("Origin"); g.isAllowedOrigin(origin) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	stream := &eventStream{
+		ctx:     r.Context(),
+		w:       w,
+		flusher: flusher,
+	}
+
+	// the watch only notices a disconnected client when sending the next
+	// change, cancel it as soon as the client disconnects instead
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-r.Context().Done():
+			if watchID := stream.getWatchID(); len(watchID) != 0 {
+				g.metrics.GatewayWatchDisconnect.Inc(1)
+				g.handler.Cancel(
+					context.Background(),
+					&svc.CancelRequest{WatchId: watchID})
+			}
+		case <-done:
+		}
+	}()
+
+	err := g.handler.Watch(req, stream)
+	if r.Context().Err() != nil {
+		return
+	}
+	if !yarpcerrors.IsCancelled(err) {
+		g.metrics.GatewayWatchFail.Inc(1)
+	}
+	if sendErr := stream.sendError(err); sendErr != nil {
+		log.WithField("watch_id", stream.getWatchID()).
+			WithError(sendErr).
+			Debug("failed to send error event of watch")
+	}
+}
+
+// isAllowedOrigin returns true if a browser on the origin is allowed to
+// read the stream.
+func (g *gateway) isAllowedOrigin(origin string) bool {
+	if len(origin) == 0 {
+		return false
+	}
+	for _, allowed := range g.config.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// eventStream implements svc.WatchServiceServiceWatchYARPCServer by writing
+// the watch responses as Server-Sent Events.
+type eventStream struct {
+	ctx     context.Context
+	w       io.Writer
+	flusher http.Flusher
+
+	sync.Mutex
+	watchID string
+}
+
+// Context returns the context of the HTTP request.
+func (s *eventStream) Context() context.Context {
+	return s.ctx
+}
+
+// Send sends a watch response as a "watch" event.
+func (s *eventStream) Send(
+	resp *svc.WatchResponse,
+	_ ...yarpc.StreamOption) error {
+	s.setWatchID(resp.GetWatchId())
+
+	// the watch id is set before checking the context, so that either the
+	// client disconnection cancels the watch, or the watch stops here
+	if err := s.ctx.Err(); err != nil {
+		return yarpcerrors.CancelledErrorf("client disconnected")
+	}
+
+	data, err := (&jsonpb.Marshaler{}).MarshalToString(resp)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if resp.GetRevision() != 0 {
+		fmt.Fprintf(&b, "id: %d\n", resp.GetRevision())
+	}
+	fmt.Fprintf(&b, "event: %s\ndata: %s\n\n", _watchEvent, data)
+	return s.write(b.Bytes())
+}
+
+// sendError sends the error the watch stopped with as an "error" event.
+func (s *eventStream) sendError(err error) error {
+	yarpcErr := yarpcerrors.FromError(err)
+	data, err := json.Marshal(gatewayError{
+		Code:    yarpcErr.Code().String(),
+		Message: yarpcErr.Message(),
+	})
+	if err != nil {
+		return err
+	}
+	return s.write([]byte(
+		fmt.Sprintf("event: %s\ndata: %s\n\n", _errorEvent, data)))
+}
+
+func (s *eventStream) write(event []byte) error {
+	if _, err := s.w.Write(event); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *eventStream) setWatchID(watchID string) {
+	s.Lock()
+	defer s.Unlock()
+	if len(s.watchID) == 0 {
+		s.watchID = watchID
+	}
+}
+
+func (s *eventStream) getWatchID() string {
+	s.Lock()
+	defer s.Unlock()
+	return s.watchID
+}
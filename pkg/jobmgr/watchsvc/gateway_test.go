@@ -0,0 +1,205 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watchsvc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+)
+
+const _testPodWatchRequest = `{"pod_filter": {"job_id": {"value": "job-1"}}}`
+
+type WatchGatewayTestSuite struct {
+	suite.Suite
+
+	testScope tally.TestScope
+	processor *watchProcessor
+	gateway   *gateway
+}
+
+func (suite *WatchGatewayTestSuite) SetupTest() {
+	suite.testScope = tally.NewTestScope("", map[string]string{})
+	suite.processor = newWatchProcessor(Config{
+		BufferSize:  10,
+		MaxClient:   2,
+		HistorySize: 3,
+	}, nil, suite.testScope)
+
+	metrics := NewMetrics(suite.testScope)
+	suite.gateway = newGateway(
+		NewServiceHandler(metrics, suite.processor, nil, nil, nil, Config{}),
+		metrics,
+		GatewayConfig{
+			Enabled:        true,
+			AllowedOrigins: []string{"https://peloton.example.com"},
+		},
+	)
+}
+
+// TestInitWatchGateway tests that the gateway is registered with the
+// handler if enabled, and never when TLS is enabled
+func (suite *WatchGatewayTestSuite) TestInitWatchGateway() {
+	handler := suite.gateway.handler
+	isRegistered := func(config Config, tlsEnabled bool) bool {
+		mux := http.NewServeMux()
+		InitWatchGateway(mux, handler, config, tlsEnabled)
+		_, pattern := mux.Handler(httptest.NewRequest(
+			http.MethodGet, GatewayPath, nil))
+		return pattern == GatewayPath
+	}
+
+	enabled := Config{Gateway: GatewayConfig{Enabled: true}}
+	suite.True(isRegistered(enabled, false))
+	suite.False(isRegistered(enabled, true))
+	suite.False(isRegistered(Config{}, false))
+}
+
+func TestWatchGateway(t *testing.T) {
+	suite.Run(t, &WatchGatewayTestSuite{})
+}
+
+func (suite *WatchGatewayTestSuite) newRequest(
+	ctx context.Context,
+	method string,
+	watchRequest string) *http.Request {
+	target := GatewayPath + "?" + _gatewayRequestParam + "=" +
+		url.QueryEscape(watchRequest)
+	return httptest.NewRequest(method, target, nil).WithContext(ctx)
+}
+
+// serve runs the gateway in the background, and returns the recorder and
+// a channel closed when the gateway returns.
+func (suite *WatchGatewayTestSuite) serve(
+	r *http.Request) (*httptest.ResponseRecorder, chan struct{}) {
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		suite.gateway.ServeHTTP(w, r)
+	}()
+	return w, done
+}
+
+// waitForWatcher waits for the gateway to create the watch client and
+// returns its id.
+func (suite *WatchGatewayTestSuite) waitForWatcher() string {
+	for i := 0; i < 100; i++ {
+		if watchers := suite.processor.ListClients(); len(watchers) != 0 {
+			return watchers[0].GetWatchId()
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	suite.FailNow("watch client not created")
+	return ""
+}
+
+// TestWatch tests that the watch responses are streamed as "watch" events,
+// and that the stream ends with an "error" event when the watch is closed.
+func (suite *WatchGatewayTestSuite) TestWatch() {
+	r := suite.newRequest(
+		context.Background(), http.MethodGet, _testPodWatchRequest)
+	r.Header.Set("Origin", "https://peloton.example.com")
+	w, done := suite.serve(r)
+
+	watchID := suite.waitForWatcher()
+	suite.NoError(suite.processor.CloseClient(watchID))
+	<-done
+
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal("text/event-stream", w.Header().Get("Content-Type"))
+	suite.Equal("https://peloton.example.com",
+		w.Header().Get("Access-Control-Allow-Origin"))
+
+	body := w.Body.String()
+	suite.Contains(body, fmt.Sprintf("id: %d\n", suite.processor.revision))
+	suite.Contains(body, "event: watch\ndata: ")
+	suite.Contains(body, watchID)
+	suite.True(strings.HasSuffix(body, "\n\n"))
+	suite.Contains(body[strings.LastIndex(body, "event: "):], "event: error\n")
+	suite.Equal(int64(1),
+		suite.testScope.Snapshot().Counters()["watch.gateway_watch+"].Value())
+}
+
+// TestWatchClientDisconnect tests that the watch is cancelled when the
+// client disconnects.
+func (suite *WatchGatewayTestSuite) TestWatchClientDisconnect() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := suite.newRequest(ctx, http.MethodGet, _testPodWatchRequest)
+	r.Header.Set("Origin", "https://unknown.example.com")
+	w, done := suite.serve(r)
+
+	suite.waitForWatcher()
+	cancel()
+	<-done
+
+	suite.Empty(w.Header().Get("Access-Control-Allow-Origin"))
+	suite.NotContains(w.Body.String(), "event: error")
+	suite.Empty(suite.processor.ListClients())
+	suite.Equal(int64(1), suite.testScope.Snapshot().
+		Counters()["watch.gateway_watch_disconnect+"].Value())
+}
+
+// TestWatchLastEventID tests that the watch resumes after the id of the
+// last event received by the client.
+func (suite *WatchGatewayTestSuite) TestWatchLastEventID() {
+	r := suite.newRequest(
+		context.Background(), http.MethodGet, _testPodWatchRequest)
+	r.Header.Set(_lastEventIDHeader,
+		fmt.Sprintf("%d", suite.processor.revision+1))
+	w, done := suite.serve(r)
+	<-done
+
+	// the revision is newer than the revision of the processor
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Contains(w.Body.String(), "event: error\n")
+	suite.Contains(w.Body.String(), `"code":"out-of-range"`)
+	suite.Equal(int64(1), suite.testScope.Snapshot().
+		Counters()["watch.gateway_watch_fail+"].Value())
+}
+
+// TestWatchBadRequest tests the requests rejected before starting the
+// watch.
+func (suite *WatchGatewayTestSuite) TestWatchBadRequest() {
+	tests := []struct {
+		method      string
+		request     string
+		lastEventID string
+		code        int
+	}{
+		{http.MethodPost, _testPodWatchRequest, "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "{not json", "", http.StatusBadRequest},
+		{http.MethodGet, _testPodWatchRequest, "abc", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		r := suite.newRequest(context.Background(), test.method, test.request)
+		if len(test.lastEventID) != 0 {
+			r.Header.Set(_lastEventIDHeader, test.lastEventID)
+		}
+		w := httptest.NewRecorder()
+		suite.gateway.ServeHTTP(w, r)
+		suite.Equal(test.code, w.Code)
+	}
+	suite.Empty(suite.processor.ListClients())
+}
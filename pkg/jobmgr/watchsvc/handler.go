@@ -16,10 +16,22 @@ package watchsvc
 
 import (
 	"context"
+	"crypto/subtle"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/respool"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/peloton"
 	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/watch"
 	"github.com/uber/peloton/.gen/peloton/api/v1alpha/watch/svc"
+	"github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc"
+
+	"github.com/uber/peloton/pkg/common/util"
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+	handlerutil "github.com/uber/peloton/pkg/jobmgr/util/handler"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/uber-go/tally"
@@ -27,37 +39,78 @@ import (
 	"go.uber.org/yarpc/yarpcerrors"
 )
 
+// _snapshotBatchSize is the maximum number of pods sent in each response
+// of the initial snapshot of a watch
+const _snapshotBatchSize = 100
+
 // ServiceHandler implements peloton.api.v1alpha.watch.svc.WatchService
 type ServiceHandler struct {
-	metrics   *Metrics
-	processor WatchProcessor
+	metrics        *Metrics
+	processor      WatchProcessor
+	jobFactory     cached.JobFactory
+	respoolClient  respool.ResourceManagerYARPCClient
+	hostmgrClient  hostsvc.InternalHostServiceYARPCClient
+	firehoseConfig FirehoseConfig
+	requireRespool bool
+	// interval at which the resource pools of a watch are resolved again
+	respoolRefreshInterval time.Duration
+
+	resourceUsageConfig  ResourceUsageConfig
+	resourceUsageSampler *hostUsageSampler
 }
 
 // NewServiceHandler initializes a new instance of ServiceHandler
 func NewServiceHandler(
 	metrics *Metrics,
 	processor WatchProcessor,
+	jobFactory cached.JobFactory,
+	respoolClient respool.ResourceManagerYARPCClient,
+	hostmgrClient hostsvc.InternalHostServiceYARPCClient,
+	config Config,
 ) *ServiceHandler {
+	config.normalize()
 	return &ServiceHandler{
-		metrics:   metrics,
-		processor: processor,
+		metrics:                metrics,
+		processor:              processor,
+		jobFactory:             jobFactory,
+		respoolClient:          respoolClient,
+		hostmgrClient:          hostmgrClient,
+		firehoseConfig:         config.Firehose,
+		requireRespool:         config.RequireRespool,
+		respoolRefreshInterval: config.RespoolRefreshInterval,
+		resourceUsageConfig:    config.ResourceUsage,
+		resourceUsageSampler: newHostUsageSampler(
+			hostmgrClient, config.ResourceUsage.SampleInterval),
 	}
 }
 
 // InitV1AlphaWatchServiceHandler initializes the Watch Service Handler,
-// and registers with yarpc dispatcher.
+// and registers with yarpc dispatcher. The handler is returned so that
+// the gateway can share it.
 func InitV1AlphaWatchServiceHandler(
 	d *yarpc.Dispatcher,
 	parent tally.Scope,
 	config Config,
-) WatchProcessor {
-	InitWatchProcessor(config, parent)
+	jobFactory cached.JobFactory,
+	respoolClient respool.ResourceManagerYARPCClient,
+	hostmgrClient hostsvc.InternalHostServiceYARPCClient,
+) *ServiceHandler {
+	// the processor is initialized along with the store of its journal
+	// by the job manager, before the handler is
+	InitWatchProcessor(config, nil, parent)
 	processor := GetWatchProcessor()
 
-	handler := NewServiceHandler(NewMetrics(parent), processor)
+	handler := NewServiceHandler(
+		NewMetrics(parent),
+		processor,
+		jobFactory,
+		respoolClient,
+		hostmgrClient,
+		config,
+	)
 	d.Register(svc.BuildWatchServiceYARPCProcedures(handler))
 
-	return processor
+	return handler
 }
 
 // Watch creates a watch to get notified about changes to Peloton objects.
@@ -67,12 +120,39 @@ func (h *ServiceHandler) Watch(
 	req *svc.WatchRequest,
 	stream svc.WatchServiceServiceWatchYARPCServer,
 ) error {
-	// Create watch for pod
+	// Create watch for pod, along with the workflows if selected
 	if req.GetPodFilter() != nil {
 		log.WithField("request", req).
 			Debug("starting new pod watch")
 
-		watchID, watchClient, err := h.processor.NewTaskClient()
+		if req.GetIncludeSnapshot() && req.GetStartRevision() != 0 {
+			return yarpcerrors.InvalidArgumentErrorf(
+				"snapshot cannot be included when resuming a watch")
+		}
+
+		if err := validatePodFieldMask(req.GetFieldMask()); err != nil {
+			return err
+		}
+
+		respools, err := h.resolveRespools(
+			stream.Context(), req.GetPodFilter())
+		if err != nil {
+			return err
+		}
+		if respools != nil {
+			ctx, cancel := context.WithCancel(stream.Context())
+			defer cancel()
+
+			go h.refreshRespools(ctx, req.GetPodFilter(), respools)
+		}
+
+		watchID, watchClient, err := h.processor.NewTaskClient(
+			req.GetPodFilter(),
+			respools,
+			req.GetStartRevision(),
+			req.GetCoalesce(),
+			req.GetFieldMask(),
+		)
 		if err != nil {
 			log.WithError(err).
 				Warn("failed to create pod watch client")
@@ -83,8 +163,46 @@ func (h *ServiceHandler) Watch(
 			h.processor.StopTaskClient(watchID)
 		}()
 
+		// the changes of the workflows are streamed back on the same
+		// stream, the workflow client is stopped with the pod watch
+		var workflowInput chan *WorkflowChange
+		var workflowSignal chan StopSignal
+		if req.GetWorkflowFilter() != nil {
+			workflowWatchID, workflowClient, err :=
+				h.processor.NewWorkflowClient(req.GetWorkflowFilter())
+			if err != nil {
+				log.WithError(err).
+					Warn("failed to create workflow watch client")
+				return err
+			}
+
+			defer func() {
+				h.processor.StopWorkflowClient(workflowWatchID)
+			}()
+
+			workflowInput = workflowClient.Input
+			workflowSignal = workflowClient.Signal
+		}
+
+		// the resource usage of the pods is sampled in the background,
+		// and streamed back on the same stream till the watch stops
+		var resourceUsageInput chan []*watch.ResourceUsageSample
+		if req.GetIncludeResourceUsage() {
+			ctx, cancel := context.WithCancel(stream.Context())
+			defer cancel()
+
+			resourceUsageInput = make(chan []*watch.ResourceUsageSample, 1)
+			go h.runResourceUsageSampler(
+				ctx,
+				req.GetPodFilter(),
+				respools,
+				resourceUsageInput,
+			)
+		}
+
 		initResp := &svc.WatchResponse{
-			WatchId: watchID,
+			WatchId:  watchID,
+			Revision: watchClient.Revision,
 		}
 		if err := stream.Send(initResp); err != nil {
 			log.WithField("watch_id", watchID).
@@ -93,12 +211,36 @@ func (h *ServiceHandler) Watch(
 			return err
 		}
 
+		// the client is created before reading the snapshot, so that
+		// no change is missed between the snapshot and the changes
+		if req.GetIncludeSnapshot() {
+			if err := h.sendPodSnapshot(
+				stream,
+				watchID,
+				watchClient.Revision,
+				req.GetPodFilter(),
+				respools,
+				req.GetFieldMask(),
+			); err != nil {
+				log.WithField("watch_id", watchID).
+					WithError(err).
+					Warn("failed to send snapshot for pod watch")
+				return err
+			}
+		}
+
+		revision := watchClient.Revision
 		for {
 			select {
-			case p := <-watchClient.Input:
+			case c := <-watchClient.Input:
 				resp := &svc.WatchResponse{
-					WatchId: watchID,
-					Pods:    []*pod.PodSummary{p},
+					WatchId:  watchID,
+					Revision: c.Revision,
+				}
+				if c.Pod != nil {
+					resp.Pods = []*pod.PodSummary{c.Pod}
+				} else {
+					resp.Heartbeat = true
 				}
 				if err := stream.Send(resp); err != nil {
 					log.WithField("watch_id", watchID).
@@ -106,6 +248,28 @@ func (h *ServiceHandler) Watch(
 						Warn("failed to send response for pod watch")
 					return err
 				}
+				watchClient.MarkSent(c)
+				revision = c.Revision
+			case samples := <-resourceUsageInput:
+				if err := stream.Send(&svc.WatchResponse{
+					WatchId:       watchID,
+					Revision:      revision,
+					ResourceUsage: samples,
+				}); err != nil {
+					log.WithField("watch_id", watchID).
+						WithError(err).
+						Warn("failed to send resource usage for pod watch")
+					return err
+				}
+			case c := <-workflowInput:
+				if err := sendWorkflowChange(stream, watchID, c); err != nil {
+					log.WithField("watch_id", watchID).
+						WithError(err).
+						Warn("failed to send workflow response for pod watch")
+					return err
+				}
+			case s := <-workflowSignal:
+				return h.handleWorkflowSignal(watchID, s)
 			case s := <-watchClient.Signal:
 				log.WithFields(log.Fields{
 					"watch_id": watchID,
@@ -132,6 +296,48 @@ func (h *ServiceHandler) Watch(
 		}
 	}
 
+	// Create watch for workflow
+	if req.GetWorkflowFilter() != nil {
+		log.WithField("request", req).
+			Debug("starting new workflow watch")
+
+		watchID, watchClient, err := h.processor.NewWorkflowClient(
+			req.GetWorkflowFilter())
+		if err != nil {
+			log.WithError(err).
+				Warn("failed to create workflow watch client")
+			return err
+		}
+
+		defer func() {
+			h.processor.StopWorkflowClient(watchID)
+		}()
+
+		initResp := &svc.WatchResponse{
+			WatchId: watchID,
+		}
+		if err := stream.Send(initResp); err != nil {
+			log.WithField("watch_id", watchID).
+				WithError(err).
+				Warn("failed to send initial response for workflow watch")
+			return err
+		}
+
+		for {
+			select {
+			case c := <-watchClient.Input:
+				if err := sendWorkflowChange(stream, watchID, c); err != nil {
+					log.WithField("watch_id", watchID).
+						WithError(err).
+						Warn("failed to send response for workflow watch")
+					return err
+				}
+			case s := <-watchClient.Signal:
+				return h.handleWorkflowSignal(watchID, s)
+			}
+		}
+	}
+
 	// Create watch for job
 	if req.GetStatelessJobFilter() != nil {
 		err := yarpcerrors.UnimplementedErrorf("job watch is not implemented")
@@ -144,6 +350,351 @@ func (h *ServiceHandler) Watch(
 	return err
 }
 
+// sendWorkflowChange streams back the change of a workflow.
+func sendWorkflowChange(
+	stream svc.WatchServiceServiceWatchYARPCServer,
+	watchID string,
+	c *WorkflowChange,
+) error {
+	return stream.Send(&svc.WatchResponse{
+		WatchId:   watchID,
+		Revision:  c.Revision,
+		Workflows: []*watch.WorkflowChange{c.Workflow},
+	})
+}
+
+// handleWorkflowSignal converts the StopSignal of a workflow watch
+// client to the error the watch is stopped with.
+func (h *ServiceHandler) handleWorkflowSignal(
+	watchID string,
+	s StopSignal,
+) error {
+	log.WithFields(log.Fields{
+		"watch_id": watchID,
+		"signal":   s,
+	}).Debug("received workflow signal")
+
+	err := handleSignal(
+		watchID,
+		s,
+		map[StopSignal]tally.Counter{
+			StopSignalCancel:   h.metrics.WatchWorkflowCancel,
+			StopSignalOverflow: h.metrics.WatchWorkflowOverflow,
+		},
+	)
+
+	if !yarpcerrors.IsCancelled(err) {
+		log.WithField("watch_id", watchID).
+			WithError(err).
+			Warn("watch stopped due to workflow signal")
+	}
+
+	return err
+}
+
+// sendPodSnapshot streams back the current state of the pods selected
+// by the filter and by the resource pools resolved from it, with only
+// the fields in the field mask if it is not empty, followed by a response
+// marking the end of the snapshot.
+func (h *ServiceHandler) sendPodSnapshot(
+	stream svc.WatchServiceServiceWatchYARPCServer,
+	watchID string,
+	revision uint64,
+	filter *watch.PodFilter,
+	respools *RespoolSet,
+	fieldMask []string,
+) error {
+	pods, err := h.getPodSnapshot(stream.Context(), filter, respools)
+	if err != nil {
+		return err
+	}
+	for i, p := range pods {
+		pods[i] = applyPodFieldMask(p, fieldMask)
+	}
+
+	var podsNotFound []*peloton.PodName
+	found := make(map[string]bool)
+	for _, p := range pods {
+		found[p.GetPodName().GetValue()] = true
+	}
+	for _, podName := range filter.GetPodNames() {
+		if !found[podName.GetValue()] {
+			podsNotFound = append(podsNotFound, podName)
+		}
+	}
+
+	// send at least one snapshot response, even if no pod is selected
+	for start := 0; ; start += _snapshotBatchSize {
+		end := start + _snapshotBatchSize
+		if end > len(pods) {
+			end = len(pods)
+		}
+		resp := &svc.WatchResponse{
+			WatchId:  watchID,
+			Revision: revision,
+			Pods:     pods[start:end],
+			Snapshot: true,
+		}
+		if end == len(pods) {
+			resp.PodsNotFound = podsNotFound
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+		if end == len(pods) {
+			break
+		}
+	}
+
+	return stream.Send(&svc.WatchResponse{
+		WatchId:  watchID,
+		Revision: revision,
+	})
+}
+
+// getPodSnapshot returns the summaries of the pods of the stateless jobs
+// in the cache which are selected by the filter and by the resource pools
+// resolved from it, sorted by pod name.
+func (h *ServiceHandler) getPodSnapshot(
+	ctx context.Context,
+	filter *watch.PodFilter,
+	respools *RespoolSet,
+) ([]*pod.PodSummary, error) {
+	var pods []*pod.PodSummary
+	for jobID, cachedJob := range h.jobFactory.GetAllJobs() {
+		// for now watch api only supports stateless
+		if cachedJob.GetJobType() != job.JobType_SERVICE ||
+			!MatchJob(filter, jobID) {
+			continue
+		}
+		if respools != nil {
+			respoolID := cachedJob.GetTaskRespoolID()
+			if respoolID == nil {
+				// the config of the job is not cached yet
+				if _, err := cachedJob.GetConfig(ctx); err != nil {
+					return nil, err
+				}
+				respoolID = cachedJob.GetTaskRespoolID()
+			}
+			if !respools.Match(respoolID.GetValue()) {
+				continue
+			}
+		}
+
+		for instanceID, cachedTask := range cachedJob.GetAllTasks() {
+			podName := util.CreatePelotonTaskID(jobID, instanceID)
+			labels := handlerutil.ConvertLabels(
+				cachedJob.GetTaskLabels(instanceID))
+			if !MatchPodName(filter, podName) ||
+				!MatchLabels(filter.GetLabels(), labels) {
+				continue
+			}
+
+			runtime, err := cachedTask.GetRuntime(ctx)
+			if err != nil {
+				return nil, err
+			}
+			pods = append(pods, newPodSummary(jobID, instanceID, runtime))
+		}
+	}
+
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].GetPodName().GetValue() <
+			pods[j].GetPodName().GetValue()
+	})
+	return pods, nil
+}
+
+// resolveRespools returns the ids of the resource pool of the filter and
+// of all its descendants, or nil if the filter does not select the pods
+// by resource pool. Returns an invalid-argument error if the filter has
+// no resource pool, or selects the root resource pool which holds all the
+// others, while the config requires it.
+func (h *ServiceHandler) resolveRespools(
+	ctx context.Context,
+	filter *watch.PodFilter,
+) (*RespoolSet, error) {
+	path := filter.GetRespool().GetValue()
+	if path == "" {
+		if h.requireRespool {
+			h.metrics.WatchPodRespoolRequired.Inc(1)
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"resource pool is required in the pod filter")
+		}
+		return nil, nil
+	}
+	if h.requireRespool && strings.TrimSuffix(path, "/") == "" {
+		h.metrics.WatchPodRespoolRequired.Inc(1)
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"resource pool other than the root is required in the pod filter")
+	}
+
+	resp, err := h.respoolClient.Query(ctx, &respool.QueryRequest{})
+	if err != nil {
+		h.metrics.WatchPodRespoolFail.Inc(1)
+		log.WithField("respool", path).
+			WithError(err).
+			Warn("failed to query resource pools")
+		return nil, err
+	}
+
+	// the descendants of the resource pool are the pools whose path
+	// starts with the path of the resource pool
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	var respoolIDs []string
+	for _, info := range resp.GetResourcePools() {
+		p := info.GetPath().GetValue()
+		if p == path || strings.HasPrefix(p, prefix) {
+			respoolIDs = append(respoolIDs, info.GetId().GetValue())
+		}
+	}
+	if len(respoolIDs) == 0 {
+		h.metrics.WatchPodRespoolFail.Inc(1)
+		return nil, yarpcerrors.NotFoundErrorf(
+			"resource pool %s not found", path)
+	}
+
+	h.metrics.WatchPodRespool.Inc(1)
+	return NewRespoolSet(respoolIDs...), nil
+}
+
+// refreshRespools resolves the resource pools of the filter of a watch
+// again periodically till ctx is done, so that the pods of the resource
+// pools created under the resource pool of the filter after the watch
+// started are selected. The resource pools resolved before are kept if
+// they cannot be resolved again.
+func (h *ServiceHandler) refreshRespools(
+	ctx context.Context,
+	filter *watch.PodFilter,
+	respools *RespoolSet,
+) {
+	ticker := time.NewTicker(h.respoolRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		resolved, err := h.resolveRespools(ctx, filter)
+		if err != nil {
+			continue
+		}
+		respools.update(resolved)
+	}
+}
+
+// Firehose creates a firehose to get notified about the state transitions
+// of all the pods in the cluster. Changed pods are streamed back to the
+// caller till the firehose is cancelled.
+func (h *ServiceHandler) Firehose(
+	req *svc.FirehoseRequest,
+	stream svc.WatchServiceServiceFirehoseYARPCServer,
+) error {
+	if err := h.authenticateFirehose(stream.Context()); err != nil {
+		log.WithError(err).Warn("failed to authenticate firehose")
+		return err
+	}
+
+	if req.GetFilter().GetNumShards() > 1 &&
+		req.GetFilter().GetShard() >= req.GetFilter().GetNumShards() {
+		return yarpcerrors.InvalidArgumentErrorf(
+			"shard must be less than num_shards")
+	}
+
+	if err := validatePodFieldMask(req.GetFieldMask()); err != nil {
+		return err
+	}
+
+	log.WithField("request", req).
+		Debug("starting new firehose")
+
+	watchID, firehoseClient, err := h.processor.NewFirehoseClient(
+		req.GetFilter())
+	if err != nil {
+		log.WithError(err).
+			Warn("failed to create firehose client")
+		return err
+	}
+
+	defer func() {
+		h.processor.StopFirehoseClient(watchID)
+	}()
+
+	initResp := &svc.FirehoseResponse{
+		WatchId: watchID,
+	}
+	if err := stream.Send(initResp); err != nil {
+		log.WithField("watch_id", watchID).
+			WithError(err).
+			Warn("failed to send initial response for firehose")
+		return err
+	}
+
+	for {
+		select {
+		case p := <-firehoseClient.Input:
+			resp := &svc.FirehoseResponse{
+				WatchId: watchID,
+				Pods: []*pod.PodSummary{
+					applyPodFieldMask(p, req.GetFieldMask()),
+				},
+			}
+			if err := stream.Send(resp); err != nil {
+				log.WithField("watch_id", watchID).
+					WithError(err).
+					Warn("failed to send response for firehose")
+				return err
+			}
+		case s := <-firehoseClient.Signal:
+			log.WithFields(log.Fields{
+				"watch_id": watchID,
+				"signal":   s,
+			}).Debug("received signal")
+
+			err := handleSignal(
+				watchID,
+				s,
+				map[StopSignal]tally.Counter{
+					StopSignalCancel:   h.metrics.FirehoseCancel,
+					StopSignalOverflow: h.metrics.FirehoseOverflow,
+				},
+			)
+
+			if !yarpcerrors.IsCancelled(err) {
+				log.WithField("watch_id", watchID).
+					WithError(err).
+					Warn("firehose stopped due to signal")
+			}
+
+			return err
+		}
+	}
+}
+
+// authenticateFirehose returns an error unless the call has the
+// firehose token of the config in its authorization header.
+func (h *ServiceHandler) authenticateFirehose(ctx context.Context) error {
+	if len(h.firehoseConfig.Token) == 0 {
+		return yarpcerrors.UnimplementedErrorf("firehose is not enabled")
+	}
+
+	var authorization string
+	if call := yarpc.CallFromContext(ctx); call != nil {
+		authorization = call.Header("authorization")
+	}
+
+	if subtle.ConstantTimeCompare(
+		[]byte(authorization),
+		[]byte("Bearer "+h.firehoseConfig.Token)) != 1 {
+		h.metrics.FirehoseUnauthenticated.Inc(1)
+		return yarpcerrors.UnauthenticatedErrorf("invalid firehose token")
+	}
+	return nil
+}
+
 // handleSignal converts StopSignal to appropriate yarpcerror
 func handleSignal(
 	watchID string,
@@ -160,6 +711,9 @@ func handleSignal(
 		return yarpcerrors.CancelledErrorf("watch cancelled: %s", watchID)
 	case StopSignalOverflow:
 		return yarpcerrors.InternalErrorf("event overflow: %s", watchID)
+	case StopSignalClose:
+		return yarpcerrors.AbortedErrorf(
+			"watch closed by operator: %s", watchID)
 	default:
 		return yarpcerrors.InternalErrorf("unexpected signal: %s", s)
 	}
@@ -190,9 +744,138 @@ func (h *ServiceHandler) Cancel(
 		return &svc.CancelResponse{}, nil
 	}
 
+	if strings.HasPrefix(watchID, ClientTypeWorkflow.String()) {
+		err := h.processor.StopWorkflowClient(watchID)
+		if err != nil {
+			if yarpcerrors.IsNotFound(err) {
+				h.metrics.CancelNotFound.Inc(1)
+			}
+
+			log.WithField("watch_id", watchID).
+				WithError(err).
+				Warn("failed to stop workflow client")
+
+			return nil, err
+		}
+
+		return &svc.CancelResponse{}, nil
+	}
+
+	if strings.HasPrefix(watchID, ClientTypeFirehose.String()) {
+		err := h.processor.StopFirehoseClient(watchID)
+		if err != nil {
+			if yarpcerrors.IsNotFound(err) {
+				h.metrics.CancelNotFound.Inc(1)
+			}
+
+			log.WithField("watch_id", watchID).
+				WithError(err).
+				Warn("failed to stop firehose client")
+
+			return nil, err
+		}
+
+		return &svc.CancelResponse{}, nil
+	}
+
 	err := yarpcerrors.NotFoundErrorf("invalid watch id")
 	log.WithFields(log.Fields{
 		"watch_id": watchID,
 	}).Warn("invalid watch id")
 	return nil, err
 }
+
+// ListWatchers lists the active watches and firehoses, along with how far
+// behind the server each of them is. As the watches of all the clients
+// are listed, it requires the cluster-wide token of the firehose.
+func (h *ServiceHandler) ListWatchers(
+	ctx context.Context,
+	req *svc.ListWatchersRequest,
+) (*svc.ListWatchersResponse, error) {
+	if err := h.authenticateFirehose(ctx); err != nil {
+		log.WithError(err).Warn("failed to authenticate watchers listing")
+		return nil, err
+	}
+
+	return &svc.ListWatchersResponse{
+		Watchers: h.processor.ListClients(),
+	}, nil
+}
+
+// CloseWatcher force-closes a watch or a firehose. The stream gets an
+// error telling the client it was closed by an operator. As the watch of
+// any client can be closed, it requires the cluster-wide token of the
+// firehose.
+func (h *ServiceHandler) CloseWatcher(
+	ctx context.Context,
+	req *svc.CloseWatcherRequest,
+) (*svc.CloseWatcherResponse, error) {
+	if err := h.authenticateFirehose(ctx); err != nil {
+		log.WithError(err).Warn("failed to authenticate watcher closing")
+		return nil, err
+	}
+
+	watchID := req.GetWatchId()
+
+	if err := h.processor.CloseClient(watchID); err != nil {
+		if yarpcerrors.IsNotFound(err) {
+			h.metrics.CancelNotFound.Inc(1)
+		}
+
+		log.WithField("watch_id", watchID).
+			WithError(err).
+			Warn("failed to close watch client")
+
+		return nil, err
+	}
+
+	h.metrics.CloseWatcher.Inc(1)
+	log.WithField("watch_id", watchID).Info("watch client closed by operator")
+	return &svc.CloseWatcherResponse{}, nil
+}
+
+// Replay replays the pod changes after a revision from the journal of
+// the processor, so that a client which was down for a while can catch
+// up on the changes it missed before resuming its watch.
+func (h *ServiceHandler) Replay(
+	ctx context.Context,
+	req *svc.ReplayRequest,
+) (*svc.ReplayResponse, error) {
+	if err := validatePodFieldMask(req.GetFieldMask()); err != nil {
+		return nil, err
+	}
+
+	respools, err := h.resolveRespools(ctx, req.GetPodFilter())
+	if err != nil {
+		return nil, err
+	}
+
+	changes, revision, hasMore, err := h.processor.Replay(
+		ctx,
+		req.GetPodFilter(),
+		respools,
+		req.GetStartRevision(),
+		req.GetFieldMask(),
+		int(req.GetLimit()),
+	)
+	if err != nil {
+		h.metrics.WatchPodReplayFail.Inc(1)
+		log.WithField("request", req).
+			WithError(err).
+			Warn("failed to replay pod changes")
+		return nil, err
+	}
+
+	resp := &svc.ReplayResponse{
+		Revision: revision,
+		HasMore:  hasMore,
+	}
+	for _, c := range changes {
+		resp.Changes = append(resp.Changes, &svc.PodChange{
+			Revision: c.Revision,
+			Pod:      c.Pod,
+		})
+	}
+	h.metrics.WatchPodReplay.Inc(1)
+	return resp, nil
+}
@@ -18,13 +18,26 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
+	mesos "github.com/uber/peloton/.gen/mesos/v1"
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	v0peloton "github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/respool"
+	respoolmocks "github.com/uber/peloton/.gen/peloton/api/v0/respool/mocks"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/job/stateless"
 	"github.com/uber/peloton/.gen/peloton/api/v1alpha/peloton"
 	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
+	v1respool "github.com/uber/peloton/.gen/peloton/api/v1alpha/respool"
 	"github.com/uber/peloton/.gen/peloton/api/v1alpha/watch"
 	watchsvc "github.com/uber/peloton/.gen/peloton/api/v1alpha/watch/svc"
 	watchsvcmocks "github.com/uber/peloton/.gen/peloton/api/v1alpha/watch/svc/mocks"
+	"github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc"
+	hostmocks "github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc/mocks"
 
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
 	watchmocks "github.com/uber/peloton/pkg/jobmgr/watchsvc/mocks"
 
 	"github.com/golang/mock/gomock"
@@ -33,20 +46,29 @@ import (
 	"github.com/uber-go/tally"
 	"go.uber.org/yarpc"
 	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/yarpc/yarpctest"
 
 	. "github.com/uber/peloton/pkg/jobmgr/watchsvc"
 )
 
+const _testFirehoseToken = "test-token"
+
 type WatchServiceHandlerTestSuite struct {
 	suite.Suite
 
 	handler *ServiceHandler
 
-	ctx         context.Context
-	ctrl        *gomock.Controller
-	testScope   tally.TestScope
-	processor   *watchmocks.MockWatchProcessor
-	watchServer *watchsvcmocks.MockWatchServiceServiceWatchYARPCServer
+	ctx           context.Context
+	ctrl          *gomock.Controller
+	testScope     tally.TestScope
+	processor     *watchmocks.MockWatchProcessor
+	jobFactory    *cachedmocks.MockJobFactory
+	respoolClient *respoolmocks.MockResourceManagerYARPCClient
+	hostmgrClient *hostmocks.MockInternalHostServiceYARPCClient
+	watchServer   *watchsvcmocks.MockWatchServiceServiceWatchYARPCServer
+
+	firehoseCtx    context.Context
+	firehoseServer *watchsvcmocks.MockWatchServiceServiceFirehoseYARPCServer
 }
 
 func (suite *WatchServiceHandlerTestSuite) SetupTest() {
@@ -54,11 +76,28 @@ func (suite *WatchServiceHandlerTestSuite) SetupTest() {
 	suite.ctrl = gomock.NewController(suite.T())
 	suite.testScope = tally.NewTestScope("", map[string]string{})
 	suite.processor = watchmocks.NewMockWatchProcessor(suite.ctrl)
+	suite.jobFactory = cachedmocks.NewMockJobFactory(suite.ctrl)
+	suite.respoolClient = respoolmocks.NewMockResourceManagerYARPCClient(suite.ctrl)
+	suite.hostmgrClient = hostmocks.NewMockInternalHostServiceYARPCClient(suite.ctrl)
 	suite.watchServer = watchsvcmocks.NewMockWatchServiceServiceWatchYARPCServer(suite.ctrl)
+	suite.watchServer.EXPECT().Context().Return(suite.ctx).AnyTimes()
+	suite.firehoseServer = watchsvcmocks.NewMockWatchServiceServiceFirehoseYARPCServer(suite.ctrl)
+	suite.firehoseCtx = yarpctest.ContextWithCall(
+		suite.ctx,
+		&yarpctest.Call{
+			Headers: map[string]string{
+				"authorization": "Bearer " + _testFirehoseToken,
+			},
+		},
+	)
 
 	suite.handler = NewServiceHandler(
 		NewMetrics(suite.testScope),
 		suite.processor,
+		suite.jobFactory,
+		suite.respoolClient,
+		suite.hostmgrClient,
+		Config{Firehose: FirehoseConfig{Token: _testFirehoseToken}},
 	)
 }
 
@@ -73,12 +112,16 @@ func (suite *WatchServiceHandlerTestSuite) TestInitV1AlphaWatchServiceHandler()
 		Name: "test-service",
 	})
 
-	processor := InitV1AlphaWatchServiceHandler(
+	handler := InitV1AlphaWatchServiceHandler(
 		dispatcher,
 		suite.testScope,
 		Config{},
+		suite.jobFactory,
+		suite.respoolClient,
+		suite.hostmgrClient,
 	)
-	suite.NotNil(processor)
+	suite.NotNil(handler)
+	suite.NotNil(handler.processor)
 }
 
 // TestWatch_InvalidRequest checks Watch will return invalid-argument
@@ -108,14 +151,18 @@ func (suite *WatchServiceHandlerTestSuite) TestJobWatch() {
 func (suite *WatchServiceHandlerTestSuite) TestTaskWatch() {
 	watchID := NewWatchID(ClientTypeTask)
 	taskClient := &TaskClient{
+		Revision: 10,
 		// do not set buffer size for input to make sure the
 		// tests sends all the events before sending stop
 		// signal
-		Input:  make(chan *pod.PodSummary),
+		Input:  make(chan *PodChange),
 		Signal: make(chan StopSignal, 1),
 	}
 
-	suite.processor.EXPECT().NewTaskClient().
+	filter := &watch.PodFilter{
+		Labels: []*peloton.Label{{Key: "app", Value: "web"}},
+	}
+	suite.processor.EXPECT().NewTaskClient(filter, nil, uint64(10), false, nil).
 		Return(watchID, taskClient, nil)
 	suite.processor.EXPECT().StopTaskClient(watchID)
 
@@ -130,27 +177,42 @@ func (suite *WatchServiceHandlerTestSuite) TestTaskWatch() {
 
 	suite.watchServer.EXPECT().
 		Send(&watchsvc.WatchResponse{
-			WatchId: watchID,
-			Pods:    nil,
+			WatchId:  watchID,
+			Revision: 10,
+			Pods:     nil,
 		}).
 		Return(nil)
-	for _, p := range pods {
+	for i, p := range pods {
 		suite.watchServer.EXPECT().
 			Send(&watchsvc.WatchResponse{
-				WatchId: watchID,
-				Pods:    []*pod.PodSummary{p},
+				WatchId:  watchID,
+				Revision: uint64(11 + i),
+				Pods:     []*pod.PodSummary{p},
 			}).
 			Return(nil)
 	}
+	// the heartbeat sent when the watch is idle
+	suite.watchServer.EXPECT().
+		Send(&watchsvc.WatchResponse{
+			WatchId:   watchID,
+			Revision:  uint64(10 + len(pods)),
+			Heartbeat: true,
+		}).
+		Return(nil)
 
 	req := &watchsvc.WatchRequest{
-		PodFilter: &watch.PodFilter{},
+		StartRevision: 10,
+		PodFilter:     filter,
 	}
 
 	go func() {
-		for _, p := range pods {
-			taskClient.Input <- p
+		for i, p := range pods {
+			taskClient.Input <- &PodChange{
+				Revision: uint64(11 + i),
+				Pod:      p,
+			}
 		}
+		taskClient.Input <- &PodChange{Revision: uint64(10 + len(pods))}
 		// cancelling task watch
 		taskClient.Signal <- StopSignalCancel
 	}()
@@ -160,10 +222,593 @@ func (suite *WatchServiceHandlerTestSuite) TestTaskWatch() {
 	suite.True(yarpcerrors.IsCancelled(err))
 }
 
+// TestTaskWatch_Workflows tests that the changes of the workflows selected
+// by the workflow filter are streamed back on the same stream as the pods,
+// and that the workflow client is stopped along with the pod watch.
+func (suite *WatchServiceHandlerTestSuite) TestTaskWatch_Workflows() {
+	watchID := NewWatchID(ClientTypeTask)
+	taskClient := &TaskClient{
+		Revision: 10,
+		Input:    make(chan *PodChange),
+		Signal:   make(chan StopSignal, 1),
+	}
+	workflowWatchID := NewWatchID(ClientTypeWorkflow)
+	workflowClient := &WorkflowClient{
+		Input:  make(chan *WorkflowChange),
+		Signal: make(chan StopSignal, 1),
+	}
+
+	podFilter := &watch.PodFilter{JobId: &peloton.JobID{Value: "job-1"}}
+	workflowFilter := &watch.WorkflowFilter{
+		JobIds: []*peloton.JobID{{Value: "job-1"}},
+	}
+	suite.processor.EXPECT().NewTaskClient(podFilter, nil, uint64(0), false, nil).
+		Return(watchID, taskClient, nil)
+	suite.processor.EXPECT().StopTaskClient(watchID)
+	suite.processor.EXPECT().NewWorkflowClient(workflowFilter).
+		Return(workflowWatchID, workflowClient, nil)
+	suite.processor.EXPECT().StopWorkflowClient(workflowWatchID)
+
+	p := &pod.PodSummary{PodName: &peloton.PodName{Value: "job-1-0"}}
+	workflow := &watch.WorkflowChange{
+		JobId: &peloton.JobID{Value: "job-1"},
+		Status: &stateless.WorkflowStatus{
+			State: stateless.WorkflowState_WORKFLOW_STATE_PAUSED,
+		},
+	}
+
+	gomock.InOrder(
+		suite.watchServer.EXPECT().
+			Send(&watchsvc.WatchResponse{
+				WatchId:  watchID,
+				Revision: 10,
+			}).
+			Return(nil),
+		suite.watchServer.EXPECT().
+			Send(&watchsvc.WatchResponse{
+				WatchId:  watchID,
+				Revision: 11,
+				Pods:     []*pod.PodSummary{p},
+			}).
+			Return(nil),
+		suite.watchServer.EXPECT().
+			Send(&watchsvc.WatchResponse{
+				WatchId:   watchID,
+				Revision:  11,
+				Workflows: []*watch.WorkflowChange{workflow},
+			}).
+			Return(nil),
+	)
+
+	req := &watchsvc.WatchRequest{
+		PodFilter:      podFilter,
+		WorkflowFilter: workflowFilter,
+	}
+
+	go func() {
+		taskClient.Input <- &PodChange{Revision: 11, Pod: p}
+		workflowClient.Input <- &WorkflowChange{
+			Revision: 11,
+			Workflow: workflow,
+		}
+		// overflow of the workflow client stops the watch
+		workflowClient.Signal <- StopSignalOverflow
+	}()
+
+	err := suite.handler.Watch(req, suite.watchServer)
+	suite.Error(err)
+	suite.True(yarpcerrors.IsInternal(err))
+}
+
+// TestWorkflowWatch sets up a workflow watch client, and verifies the
+// changes of the workflows are streamed back, finally the test cancels
+// the watch stream.
+func (suite *WatchServiceHandlerTestSuite) TestWorkflowWatch() {
+	watchID := NewWatchID(ClientTypeWorkflow)
+	workflowClient := &WorkflowClient{
+		Input:  make(chan *WorkflowChange),
+		Signal: make(chan StopSignal, 1),
+	}
+
+	filter := &watch.WorkflowFilter{}
+	suite.processor.EXPECT().NewWorkflowClient(filter).
+		Return(watchID, workflowClient, nil)
+	suite.processor.EXPECT().StopWorkflowClient(watchID)
+
+	workflow := &watch.WorkflowChange{
+		JobId:         &peloton.JobID{Value: "job-1"},
+		InstancesDone: []uint32{0, 1},
+	}
+
+	gomock.InOrder(
+		suite.watchServer.EXPECT().
+			Send(&watchsvc.WatchResponse{WatchId: watchID}).
+			Return(nil),
+		suite.watchServer.EXPECT().
+			Send(&watchsvc.WatchResponse{
+				WatchId:   watchID,
+				Revision:  5,
+				Workflows: []*watch.WorkflowChange{workflow},
+			}).
+			Return(nil),
+	)
+
+	go func() {
+		workflowClient.Input <- &WorkflowChange{
+			Revision: 5,
+			Workflow: workflow,
+		}
+		workflowClient.Signal <- StopSignalCancel
+	}()
+
+	err := suite.handler.Watch(
+		&watchsvc.WatchRequest{WorkflowFilter: filter},
+		suite.watchServer,
+	)
+	suite.Error(err)
+	suite.True(yarpcerrors.IsCancelled(err))
+}
+
+// TestWorkflowWatch_MaxClientReached checks Watch will return
+// resource-exhausted error when NewWorkflowClient reached max client.
+func (suite *WatchServiceHandlerTestSuite) TestWorkflowWatch_MaxClientReached() {
+	suite.processor.EXPECT().NewWorkflowClient(gomock.Any()).
+		Return("", nil, yarpcerrors.ResourceExhaustedErrorf("max client reached"))
+
+	err := suite.handler.Watch(
+		&watchsvc.WatchRequest{WorkflowFilter: &watch.WorkflowFilter{}},
+		suite.watchServer,
+	)
+	suite.Error(err)
+	suite.True(yarpcerrors.IsResourceExhausted(err))
+}
+
+// TestTaskWatch_Snapshot tests that the pods selected by the filter
+// are streamed back before the changes when a snapshot is requested.
+func (suite *WatchServiceHandlerTestSuite) TestTaskWatch_Snapshot() {
+	watchID := NewWatchID(ClientTypeTask)
+	taskClient := &TaskClient{
+		Revision: 10,
+		Input:    make(chan *PodChange),
+		Signal:   make(chan StopSignal, 1),
+	}
+
+	filter := &watch.PodFilter{
+		PodNames: []*peloton.PodName{
+			{Value: "job-1-0"},
+			{Value: "job-1-1"},
+			{Value: "job-1-5"},
+		},
+	}
+	suite.processor.EXPECT().NewTaskClient(filter, nil, uint64(0), false, nil).
+		Return(watchID, taskClient, nil)
+	suite.processor.EXPECT().StopTaskClient(watchID)
+
+	cachedJob := cachedmocks.NewMockJob(suite.ctrl)
+	batchJob := cachedmocks.NewMockJob(suite.ctrl)
+	suite.jobFactory.EXPECT().GetAllJobs().Return(map[string]cached.Job{
+		"job-1": cachedJob,
+		"job-2": batchJob,
+	})
+	batchJob.EXPECT().GetJobType().Return(job.JobType_BATCH)
+	cachedJob.EXPECT().GetJobType().Return(job.JobType_SERVICE)
+
+	tasks := map[uint32]cached.Task{}
+	for i := uint32(0); i < 3; i++ {
+		tasks[i] = cachedmocks.NewMockTask(suite.ctrl)
+	}
+	cachedJob.EXPECT().GetAllTasks().Return(tasks)
+	cachedJob.EXPECT().GetTaskLabels(gomock.Any()).
+		Return([]*v0peloton.Label{}).
+		Times(len(tasks))
+	for i := uint32(0); i < 2; i++ {
+		tasks[i].(*cachedmocks.MockTask).EXPECT().
+			GetRuntime(gomock.Any()).
+			Return(&task.RuntimeInfo{State: task.TaskState_RUNNING}, nil)
+	}
+
+	gomock.InOrder(
+		suite.watchServer.EXPECT().
+			Send(&watchsvc.WatchResponse{
+				WatchId:  watchID,
+				Revision: 10,
+			}).
+			Return(nil),
+		suite.watchServer.EXPECT().
+			Send(gomock.Any()).
+			Do(func(resp *watchsvc.WatchResponse) {
+				suite.True(resp.GetSnapshot())
+				suite.Equal(uint64(10), resp.GetRevision())
+				suite.Len(resp.GetPods(), 2)
+				suite.Equal("job-1-0", resp.GetPods()[0].GetPodName().GetValue())
+				suite.Equal("job-1-1", resp.GetPods()[1].GetPodName().GetValue())
+				suite.Equal(
+					[]*peloton.PodName{{Value: "job-1-5"}},
+					resp.GetPodsNotFound(),
+				)
+			}).
+			Return(nil),
+		suite.watchServer.EXPECT().
+			Send(&watchsvc.WatchResponse{
+				WatchId:  watchID,
+				Revision: 10,
+			}).
+			Return(nil),
+	)
+
+	taskClient.Signal <- StopSignalCancel
+
+	err := suite.handler.Watch(&watchsvc.WatchRequest{
+		PodFilter:       filter,
+		IncludeSnapshot: true,
+	}, suite.watchServer)
+	suite.Error(err)
+	suite.True(yarpcerrors.IsCancelled(err))
+}
+
+// TestTaskWatch_SnapshotWithStartRevision checks Watch will return
+// invalid-argument error when a snapshot is requested for a resumed watch.
+func (suite *WatchServiceHandlerTestSuite) TestTaskWatch_SnapshotWithStartRevision() {
+	err := suite.handler.Watch(&watchsvc.WatchRequest{
+		PodFilter:       &watch.PodFilter{},
+		StartRevision:   10,
+		IncludeSnapshot: true,
+	}, suite.watchServer)
+	suite.Error(err)
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestTaskWatch_ResourceUsage tests that the samples of the resource usage
+// of the running pods are streamed back on the same stream as the pods
+// when the watch includes the resource usage.
+func (suite *WatchServiceHandlerTestSuite) TestTaskWatch_ResourceUsage() {
+	handler := NewServiceHandler(
+		NewMetrics(suite.testScope),
+		suite.processor,
+		suite.jobFactory,
+		suite.respoolClient,
+		suite.hostmgrClient,
+		Config{
+			ResourceUsage: ResourceUsageConfig{
+				SampleInterval: time.Millisecond,
+			},
+		},
+	)
+
+	watchID := NewWatchID(ClientTypeTask)
+	taskClient := &TaskClient{
+		Revision: 10,
+		Input:    make(chan *PodChange),
+		Signal:   make(chan StopSignal, 1),
+	}
+
+	filter := &watch.PodFilter{}
+	suite.processor.EXPECT().NewTaskClient(filter, nil, uint64(0), false, nil).
+		Return(watchID, taskClient, nil)
+	suite.processor.EXPECT().StopTaskClient(watchID)
+
+	cachedJob := cachedmocks.NewMockJob(suite.ctrl)
+	cachedTask := cachedmocks.NewMockTask(suite.ctrl)
+	suite.jobFactory.EXPECT().GetAllJobs().
+		Return(map[string]cached.Job{"job-1": cachedJob}).
+		AnyTimes()
+	cachedJob.EXPECT().GetJobType().Return(job.JobType_SERVICE).AnyTimes()
+	cachedJob.EXPECT().GetAllTasks().
+		Return(map[uint32]cached.Task{0: cachedTask}).
+		AnyTimes()
+	cachedJob.EXPECT().GetTaskLabels(uint32(0)).
+		Return([]*v0peloton.Label{}).
+		AnyTimes()
+	mesosTaskID := "job-1-0-1"
+	cachedTask.EXPECT().GetRuntime(gomock.Any()).
+		Return(&task.RuntimeInfo{
+			State:       task.TaskState_RUNNING,
+			Host:        "host-a",
+			MesosTaskId: &mesos.TaskID{Value: &mesosTaskID},
+		}, nil).
+		AnyTimes()
+
+	memRssBytes := uint64(100)
+	suite.hostmgrClient.EXPECT().
+		GetTasksResourceUsage(gomock.Any(), gomock.Any()).
+		Return(&hostsvc.GetTasksResourceUsageResponse{
+			Usages: []*hostsvc.TaskResourceUsage{{
+				TaskId: &mesos.TaskID{Value: &mesosTaskID},
+				Statistics: &mesos.ResourceStatistics{
+					MemRssBytes: &memRssBytes,
+				},
+			}},
+		}, nil).
+		AnyTimes()
+
+	suite.watchServer.EXPECT().
+		Send(&watchsvc.WatchResponse{
+			WatchId:  watchID,
+			Revision: 10,
+		}).
+		Return(nil)
+	suite.watchServer.EXPECT().
+		Send(gomock.Any()).
+		Do(func(resp *watchsvc.WatchResponse) {
+			suite.Equal(uint64(10), resp.GetRevision())
+			suite.Len(resp.GetResourceUsage(), 1)
+			suite.Equal("job-1-0",
+				resp.GetResourceUsage()[0].GetPodName().GetValue())
+			suite.Equal(memRssBytes,
+				resp.GetResourceUsage()[0].GetMemRssBytes())
+
+			// cancelling task watch once the samples are streamed back
+			select {
+			case taskClient.Signal <- StopSignalCancel:
+			default:
+			}
+		}).
+		Return(nil).
+		MinTimes(1)
+
+	err := handler.Watch(&watchsvc.WatchRequest{
+		PodFilter:            filter,
+		IncludeResourceUsage: true,
+	}, suite.watchServer)
+	suite.Error(err)
+	suite.True(yarpcerrors.IsCancelled(err))
+}
+
+// TestTaskWatch_InvalidFieldMask tests that a pod watch with an unknown
+// field mask path is rejected.
+func (suite *WatchServiceHandlerTestSuite) TestTaskWatch_InvalidFieldMask() {
+	err := suite.handler.Watch(&watchsvc.WatchRequest{
+		PodFilter: &watch.PodFilter{},
+		FieldMask: []string{"status.unknown"},
+	}, suite.watchServer)
+	suite.Error(err)
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestTaskWatch_Respool tests that the resource pool of the filter and its
+// descendants are resolved into the resource pools of the watch client.
+func (suite *WatchServiceHandlerTestSuite) TestTaskWatch_Respool() {
+	watchID := NewWatchID(ClientTypeTask)
+	taskClient := &TaskClient{
+		Revision: 10,
+		Signal:   make(chan StopSignal, 1),
+	}
+	taskClient.Signal <- StopSignalCancel
+
+	filter := &watch.PodFilter{
+		Respool: &v1respool.ResourcePoolPath{Value: "/infra"},
+	}
+	suite.respoolClient.EXPECT().
+		Query(gomock.Any(), &respool.QueryRequest{}).
+		Return(&respool.QueryResponse{
+			ResourcePools: []*respool.ResourcePoolInfo{
+				newTestRespoolInfo("root", "/"),
+				newTestRespoolInfo("infra", "/infra"),
+				newTestRespoolInfo("infra-batch", "/infra/batch"),
+				newTestRespoolInfo("infra2", "/infra2"),
+			},
+		}, nil)
+	suite.processor.EXPECT().
+		NewTaskClient(
+			filter,
+			NewRespoolSet("infra", "infra-batch"),
+			uint64(0),
+			false,
+			nil,
+		).
+		Return(watchID, taskClient, nil)
+	suite.processor.EXPECT().StopTaskClient(watchID)
+	suite.watchServer.EXPECT().
+		Send(&watchsvc.WatchResponse{WatchId: watchID, Revision: 10}).
+		Return(nil)
+
+	err := suite.handler.Watch(
+		&watchsvc.WatchRequest{PodFilter: filter}, suite.watchServer)
+	suite.True(yarpcerrors.IsCancelled(err))
+}
+
+// TestTaskWatch_RespoolError tests that a pod watch is rejected when its
+// resource pool is not found or can not be resolved, and when it has no
+// resource pool while the config requires it.
+func (suite *WatchServiceHandlerTestSuite) TestTaskWatch_RespoolError() {
+	req := &watchsvc.WatchRequest{
+		PodFilter: &watch.PodFilter{
+			Respool: &v1respool.ResourcePoolPath{Value: "/infra"},
+		},
+	}
+
+	suite.respoolClient.EXPECT().
+		Query(gomock.Any(), gomock.Any()).
+		Return(&respool.QueryResponse{
+			ResourcePools: []*respool.ResourcePoolInfo{
+				newTestRespoolInfo("infra2", "/infra2"),
+			},
+		}, nil)
+	err := suite.handler.Watch(req, suite.watchServer)
+	suite.True(yarpcerrors.IsNotFound(err))
+
+	suite.respoolClient.EXPECT().
+		Query(gomock.Any(), gomock.Any()).
+		Return(nil, yarpcerrors.UnavailableErrorf("resmgr unavailable"))
+	err = suite.handler.Watch(req, suite.watchServer)
+	suite.True(yarpcerrors.IsUnavailable(err))
+
+	handler := NewServiceHandler(
+		NewMetrics(suite.testScope),
+		suite.processor,
+		suite.jobFactory,
+		suite.respoolClient,
+		suite.hostmgrClient,
+		Config{RequireRespool: true},
+	)
+	err = handler.Watch(
+		&watchsvc.WatchRequest{PodFilter: &watch.PodFilter{}},
+		suite.watchServer,
+	)
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+
+	_, err = handler.Replay(suite.ctx, &watchsvc.ReplayRequest{})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+
+	// the root resource pool selects the pods of all the resource pools
+	err = handler.Watch(
+		&watchsvc.WatchRequest{
+			PodFilter: &watch.PodFilter{
+				Respool: &v1respool.ResourcePoolPath{Value: "/"},
+			},
+		},
+		suite.watchServer,
+	)
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+}
+
+// TestTaskWatch_RespoolRefresh tests that the resource pools of a watch are
+// resolved again while the watch runs, so that the pods of the resource
+// pools created under the resource pool of the filter are selected.
+func (suite *WatchServiceHandlerTestSuite) TestTaskWatch_RespoolRefresh() {
+	handler := NewServiceHandler(
+		NewMetrics(suite.testScope),
+		suite.processor,
+		suite.jobFactory,
+		suite.respoolClient,
+		suite.hostmgrClient,
+		Config{RespoolRefreshInterval: time.Millisecond},
+	)
+
+	watchID := NewWatchID(ClientTypeTask)
+	taskClient := &TaskClient{
+		Revision: 10,
+		Signal:   make(chan StopSignal, 1),
+	}
+	filter := &watch.PodFilter{
+		Respool: &v1respool.ResourcePoolPath{Value: "/infra"},
+	}
+
+	gomock.InOrder(
+		suite.respoolClient.EXPECT().
+			Query(gomock.Any(), &respool.QueryRequest{}).
+			Return(&respool.QueryResponse{
+				ResourcePools: []*respool.ResourcePoolInfo{
+					newTestRespoolInfo("infra", "/infra"),
+				},
+			}, nil),
+		suite.respoolClient.EXPECT().
+			Query(gomock.Any(), &respool.QueryRequest{}).
+			Return(&respool.QueryResponse{
+				ResourcePools: []*respool.ResourcePoolInfo{
+					newTestRespoolInfo("infra", "/infra"),
+					newTestRespoolInfo("infra-new", "/infra/new"),
+				},
+			}, nil).
+			AnyTimes(),
+	)
+	respools := make(chan *RespoolSet, 1)
+	suite.processor.EXPECT().
+		NewTaskClient(filter, gomock.Any(), uint64(0), false, nil).
+		DoAndReturn(func(
+			_ *watch.PodFilter,
+			r *RespoolSet,
+			_ uint64,
+			_ bool,
+			_ []string,
+		) (string, *TaskClient, error) {
+			respools <- r
+			return watchID, taskClient, nil
+		})
+	suite.processor.EXPECT().StopTaskClient(watchID)
+	suite.watchServer.EXPECT().
+		Send(&watchsvc.WatchResponse{WatchId: watchID, Revision: 10}).
+		Return(nil)
+
+	go func() {
+		r := <-respools
+		suite.False(r.Match("infra-new"))
+		for !r.Match("infra-new") {
+			time.Sleep(time.Millisecond)
+		}
+		taskClient.Signal <- StopSignalCancel
+	}()
+
+	err := handler.Watch(
+		&watchsvc.WatchRequest{PodFilter: filter}, suite.watchServer)
+	suite.True(yarpcerrors.IsCancelled(err))
+}
+
+// TestTaskWatch_SnapshotRespoolNotCached tests that the config of a job
+// is loaded into the cache to resolve the resource pool of its pods when
+// it is not cached.
+func (suite *WatchServiceHandlerTestSuite) TestTaskWatch_SnapshotRespoolNotCached() {
+	watchID := NewWatchID(ClientTypeTask)
+	taskClient := &TaskClient{
+		Revision: 10,
+		Input:    make(chan *PodChange),
+		Signal:   make(chan StopSignal, 1),
+	}
+	filter := &watch.PodFilter{
+		Respool: &v1respool.ResourcePoolPath{Value: "/infra"},
+	}
+
+	suite.respoolClient.EXPECT().
+		Query(gomock.Any(), &respool.QueryRequest{}).
+		Return(&respool.QueryResponse{
+			ResourcePools: []*respool.ResourcePoolInfo{
+				newTestRespoolInfo("infra", "/infra"),
+			},
+		}, nil)
+	suite.processor.EXPECT().
+		NewTaskClient(filter, NewRespoolSet("infra"), uint64(0), false, nil).
+		Return(watchID, taskClient, nil)
+	suite.processor.EXPECT().StopTaskClient(watchID)
+
+	cachedJob := cachedmocks.NewMockJob(suite.ctrl)
+	cachedTask := cachedmocks.NewMockTask(suite.ctrl)
+	suite.jobFactory.EXPECT().GetAllJobs().Return(map[string]cached.Job{
+		"job-1": cachedJob,
+	})
+	cachedJob.EXPECT().GetJobType().Return(job.JobType_SERVICE)
+	gomock.InOrder(
+		cachedJob.EXPECT().GetTaskRespoolID().Return(nil),
+		cachedJob.EXPECT().GetConfig(gomock.Any()).Return(nil, nil),
+		cachedJob.EXPECT().GetTaskRespoolID().
+			Return(&v0peloton.ResourcePoolID{Value: "infra"}),
+	)
+	cachedJob.EXPECT().GetAllTasks().
+		Return(map[uint32]cached.Task{0: cachedTask})
+	cachedJob.EXPECT().GetTaskLabels(uint32(0)).Return(nil)
+	cachedTask.EXPECT().GetRuntime(gomock.Any()).
+		Return(&task.RuntimeInfo{State: task.TaskState_RUNNING}, nil)
+
+	gomock.InOrder(
+		suite.watchServer.EXPECT().
+			Send(&watchsvc.WatchResponse{WatchId: watchID, Revision: 10}).
+			Return(nil),
+		suite.watchServer.EXPECT().
+			Send(gomock.Any()).
+			Do(func(resp *watchsvc.WatchResponse) {
+				suite.True(resp.GetSnapshot())
+				suite.Len(resp.GetPods(), 1)
+			}).
+			Return(nil),
+		suite.watchServer.EXPECT().
+			Send(&watchsvc.WatchResponse{WatchId: watchID, Revision: 10}).
+			Return(nil),
+	)
+
+	taskClient.Signal <- StopSignalCancel
+
+	err := suite.handler.Watch(&watchsvc.WatchRequest{
+		PodFilter:       filter,
+		IncludeSnapshot: true,
+	}, suite.watchServer)
+	suite.True(yarpcerrors.IsCancelled(err))
+}
+
 // TestTaskWatch_MaxClientReached checks Watch will return resource-exhausted
 // error when NewTaskClient reached max client.
 func (suite *WatchServiceHandlerTestSuite) TestTaskWatch_MaxClientReached() {
-	suite.processor.EXPECT().NewTaskClient().
+	suite.processor.EXPECT().
+		NewTaskClient(
+			gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 		Return("", nil, yarpcerrors.ResourceExhaustedErrorf("max client reached"))
 
 	req := &watchsvc.WatchRequest{
@@ -182,11 +827,13 @@ func (suite *WatchServiceHandlerTestSuite) TestTaskWatch_InitSendError() {
 		// do not set buffer size for input to make sure the
 		// tests sends all the events before sending stop
 		// signal
-		Input:  make(chan *pod.PodSummary),
+		Input:  make(chan *PodChange),
 		Signal: make(chan StopSignal, 1),
 	}
 
-	suite.processor.EXPECT().NewTaskClient().
+	suite.processor.EXPECT().
+		NewTaskClient(
+			gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 		Return(watchID, taskClient, nil)
 	suite.processor.EXPECT().StopTaskClient(watchID)
 
@@ -217,11 +864,13 @@ func (suite *WatchServiceHandlerTestSuite) TestTaskWatch_SendError() {
 		// do not set buffer size for input to make sure the
 		// tests sends all the events before sending stop
 		// signal
-		Input:  make(chan *pod.PodSummary),
+		Input:  make(chan *PodChange),
 		Signal: make(chan StopSignal, 1),
 	}
 
-	suite.processor.EXPECT().NewTaskClient().
+	suite.processor.EXPECT().
+		NewTaskClient(
+			gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 		Return(watchID, taskClient, nil)
 	suite.processor.EXPECT().StopTaskClient(watchID)
 
@@ -252,7 +901,7 @@ func (suite *WatchServiceHandlerTestSuite) TestTaskWatch_SendError() {
 	}
 
 	go func() {
-		taskClient.Input <- p
+		taskClient.Input <- &PodChange{Pod: p}
 		taskClient.Signal <- StopSignalCancel
 	}()
 
@@ -306,6 +955,279 @@ func (suite *WatchServiceHandlerTestSuite) TestCancel_InvalidWatchID() {
 	suite.True(yarpcerrors.IsNotFound(err))
 }
 
+// TestFirehose sets up a firehose client, and verifies the responses
+// are streamed back with the field mask applied, finally the test
+// cancels the firehose stream.
+func (suite *WatchServiceHandlerTestSuite) TestFirehose() {
+	watchID := NewWatchID(ClientTypeFirehose)
+	filter := &watch.FirehoseFilter{NumShards: 4, Shard: 1}
+	firehoseClient := &FirehoseClient{
+		Filter: filter,
+		// do not set buffer size for input to make sure the
+		// tests sends all the events before sending stop
+		// signal
+		Input:  make(chan *PodChange),
+		Signal: make(chan StopSignal, 1),
+	}
+
+	suite.firehoseServer.EXPECT().Context().Return(suite.firehoseCtx)
+	suite.processor.EXPECT().NewFirehoseClient(filter).
+		Return(watchID, firehoseClient, nil)
+	suite.processor.EXPECT().StopFirehoseClient(watchID)
+
+	p := &pod.PodSummary{
+		PodName: &peloton.PodName{Value: "pod-0"},
+		Status: &pod.PodStatus{
+			State: pod.PodState_POD_STATE_RUNNING,
+			Host:  "host-0",
+		},
+	}
+
+	suite.firehoseServer.EXPECT().
+		Send(&watchsvc.FirehoseResponse{
+			WatchId: watchID,
+		}).
+		Return(nil)
+	suite.firehoseServer.EXPECT().
+		Send(&watchsvc.FirehoseResponse{
+			WatchId: watchID,
+			Pods: []*pod.PodSummary{
+				{
+					PodName: p.GetPodName(),
+					Status: &pod.PodStatus{
+						State: pod.PodState_POD_STATE_RUNNING,
+					},
+				},
+			},
+		}).
+		Return(nil)
+
+	req := &watchsvc.FirehoseRequest{
+		Filter:    filter,
+		FieldMask: []string{"status.state"},
+	}
+
+	go func() {
+		firehoseClient.Input <- p
+		// cancelling firehose
+		firehoseClient.Signal <- StopSignalCancel
+	}()
+
+	err := suite.handler.Firehose(req, suite.firehoseServer)
+	suite.Error(err)
+	suite.True(yarpcerrors.IsCancelled(err))
+}
+
+// TestFirehose_Unauthenticated checks Firehose returns unauthenticated
+// error when the caller does not have the firehose token.
+func (suite *WatchServiceHandlerTestSuite) TestFirehose_Unauthenticated() {
+	suite.firehoseServer.EXPECT().Context().Return(suite.ctx)
+
+	err := suite.handler.Firehose(
+		&watchsvc.FirehoseRequest{},
+		suite.firehoseServer,
+	)
+	suite.Error(err)
+	suite.True(yarpcerrors.IsUnauthenticated(err))
+}
+
+// TestFirehose_NotEnabled checks Firehose returns unimplemented error
+// when no firehose token is configured.
+func (suite *WatchServiceHandlerTestSuite) TestFirehose_NotEnabled() {
+	handler := NewServiceHandler(
+		NewMetrics(suite.testScope),
+		suite.processor,
+		suite.jobFactory,
+		suite.respoolClient,
+		suite.hostmgrClient,
+		Config{},
+	)
+	suite.firehoseServer.EXPECT().Context().Return(suite.firehoseCtx)
+
+	err := handler.Firehose(
+		&watchsvc.FirehoseRequest{},
+		suite.firehoseServer,
+	)
+	suite.Error(err)
+	suite.True(yarpcerrors.IsUnimplemented(err))
+}
+
+// TestFirehose_InvalidRequest checks Firehose returns invalid-argument
+// error for an out of range shard or an unknown field mask path.
+func (suite *WatchServiceHandlerTestSuite) TestFirehose_InvalidRequest() {
+	reqs := []*watchsvc.FirehoseRequest{
+		{
+			Filter: &watch.FirehoseFilter{NumShards: 2, Shard: 2},
+		},
+		{
+			FieldMask: []string{"status.unknown"},
+		},
+	}
+
+	for _, req := range reqs {
+		suite.firehoseServer.EXPECT().Context().Return(suite.firehoseCtx)
+		err := suite.handler.Firehose(req, suite.firehoseServer)
+		suite.Error(err)
+		suite.True(yarpcerrors.IsInvalidArgument(err))
+	}
+}
+
+// TestCancelFirehose tests Cancel requests of a firehose are proxied
+// to watch processor correctly.
+func (suite *WatchServiceHandlerTestSuite) TestCancelFirehose() {
+	watchID := NewWatchID(ClientTypeFirehose)
+
+	suite.processor.EXPECT().StopFirehoseClient(watchID).Return(nil)
+
+	resp, err := suite.handler.Cancel(suite.ctx, &watchsvc.CancelRequest{
+		WatchId: watchID,
+	})
+	suite.NotNil(resp)
+	suite.NoError(err)
+}
+
+// TestCancelWorkflow tests Cancel requests of a workflow watch are
+// proxied to watch processor correctly.
+func (suite *WatchServiceHandlerTestSuite) TestCancelWorkflow() {
+	watchID := NewWatchID(ClientTypeWorkflow)
+
+	suite.processor.EXPECT().StopWorkflowClient(watchID).Return(nil)
+
+	resp, err := suite.handler.Cancel(suite.ctx, &watchsvc.CancelRequest{
+		WatchId: watchID,
+	})
+	suite.NotNil(resp)
+	suite.NoError(err)
+}
+
+// TestListWatchers tests ListWatchers returns the clients of the
+// watch processor.
+func (suite *WatchServiceHandlerTestSuite) TestListWatchers() {
+	watchers := []*watch.WatcherInfo{
+		{
+			WatchId:    NewWatchID(ClientTypeTask),
+			Type:       ClientTypeTask.String(),
+			Buffered:   3,
+			BufferSize: 10,
+			Lag:        5,
+		},
+	}
+	suite.processor.EXPECT().ListClients().Return(watchers)
+
+	resp, err := suite.handler.ListWatchers(
+		suite.firehoseCtx, &watchsvc.ListWatchersRequest{})
+	suite.NoError(err)
+	suite.Equal(watchers, resp.GetWatchers())
+}
+
+// TestListAndCloseWatchersUnauthenticated tests the watchers can be
+// listed and closed only with the token of the firehose.
+func (suite *WatchServiceHandlerTestSuite) TestListAndCloseWatchersUnauthenticated() {
+	_, err := suite.handler.ListWatchers(
+		suite.ctx, &watchsvc.ListWatchersRequest{})
+	suite.True(yarpcerrors.IsUnauthenticated(err))
+
+	_, err = suite.handler.CloseWatcher(
+		suite.ctx,
+		&watchsvc.CloseWatcherRequest{WatchId: NewWatchID(ClientTypeTask)})
+	suite.True(yarpcerrors.IsUnauthenticated(err))
+}
+
+// TestCloseWatcher tests a closed task watch stream gets an aborted
+// error.
+func (suite *WatchServiceHandlerTestSuite) TestCloseWatcher() {
+	watchID := NewWatchID(ClientTypeTask)
+	taskClient := &TaskClient{
+		Input:  make(chan *PodChange),
+		Signal: make(chan StopSignal, 1),
+	}
+
+	suite.processor.EXPECT().NewTaskClient(gomock.Any(), nil, uint64(0), false, nil).
+		Return(watchID, taskClient, nil)
+	suite.processor.EXPECT().StopTaskClient(watchID)
+	suite.processor.EXPECT().CloseClient(watchID).
+		Do(func(string) { taskClient.Signal <- StopSignalClose }).
+		Return(nil)
+	suite.watchServer.EXPECT().
+		Send(&watchsvc.WatchResponse{WatchId: watchID}).
+		Return(nil)
+
+	resp, err := suite.handler.CloseWatcher(
+		suite.firehoseCtx, &watchsvc.CloseWatcherRequest{WatchId: watchID})
+	suite.NoError(err)
+	suite.NotNil(resp)
+
+	err = suite.handler.Watch(
+		&watchsvc.WatchRequest{PodFilter: &watch.PodFilter{}},
+		suite.watchServer,
+	)
+	suite.Error(err)
+	suite.True(yarpcerrors.IsAborted(err))
+}
+
+// TestCloseWatcher_NotFound tests CloseWatcher returns the not-found
+// error of the watch processor.
+func (suite *WatchServiceHandlerTestSuite) TestCloseWatcher_NotFound() {
+	watchID := NewWatchID(ClientTypeFirehose)
+
+	suite.processor.EXPECT().CloseClient(watchID).
+		Return(yarpcerrors.NotFoundErrorf("not found"))
+
+	resp, err := suite.handler.CloseWatcher(
+		suite.firehoseCtx, &watchsvc.CloseWatcherRequest{WatchId: watchID})
+	suite.Nil(resp)
+	suite.True(yarpcerrors.IsNotFound(err))
+}
+
+// newTestRespoolInfo returns the info of a resource pool with the path.
+func newTestRespoolInfo(id string, path string) *respool.ResourcePoolInfo {
+	return &respool.ResourcePoolInfo{
+		Id:   &v0peloton.ResourcePoolID{Value: id},
+		Path: &respool.ResourcePoolPath{Value: path},
+	}
+}
+
 func TestWatchServiceHandler(t *testing.T) {
 	suite.Run(t, &WatchServiceHandlerTestSuite{})
 }
+
+// TestReplay tests Replay returns the changes replayed by the watch
+// processor.
+func (suite *WatchServiceHandlerTestSuite) TestReplay() {
+	filter := &watch.PodFilter{JobId: &peloton.JobID{Value: uuid.New()}}
+	fieldMask := []string{"status.state"}
+	p := &pod.PodSummary{PodName: &peloton.PodName{Value: "pod-0"}}
+	suite.processor.EXPECT().
+		Replay(gomock.Any(), filter, nil, uint64(10), fieldMask, 5).
+		Return([]*PodChange{{Revision: 11, Pod: p}}, uint64(11), true, nil)
+
+	resp, err := suite.handler.Replay(suite.ctx, &watchsvc.ReplayRequest{
+		StartRevision: 10,
+		PodFilter:     filter,
+		FieldMask:     fieldMask,
+		Limit:         5,
+	})
+	suite.NoError(err)
+	suite.Equal([]*watchsvc.PodChange{{Revision: 11, Pod: p}}, resp.GetChanges())
+	suite.Equal(uint64(11), resp.GetRevision())
+	suite.True(resp.GetHasMore())
+}
+
+// TestReplay_Error tests Replay returns the errors of the watch processor,
+// and rejects invalid field masks.
+func (suite *WatchServiceHandlerTestSuite) TestReplay_Error() {
+	_, err := suite.handler.Replay(suite.ctx, &watchsvc.ReplayRequest{
+		FieldMask: []string{"status.invalid"},
+	})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+
+	suite.processor.EXPECT().
+		Replay(gomock.Any(), nil, nil, uint64(10), nil, 0).
+		Return(nil, uint64(0), false,
+			yarpcerrors.OutOfRangeErrorf("start revision 10 is too old"))
+
+	_, err = suite.handler.Replay(suite.ctx, &watchsvc.ReplayRequest{
+		StartRevision: 10,
+	})
+	suite.True(yarpcerrors.IsOutOfRange(err))
+}
@@ -0,0 +1,562 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watchsvc
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/watch"
+
+	"github.com/uber/peloton/pkg/common/lifecycle"
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
+
+	"github.com/gogo/protobuf/proto"
+	log "github.com/sirupsen/logrus"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// _journalStoreTimeout is the timeout of each read and write of the
+// journal store
+const _journalStoreTimeout = 10 * time.Second
+
+// _journalReplayChunkSize is the number of changes in memory copied at
+// a time when replaying, so that a page only copies the changes it
+// matches instead of all the changes after its start revision
+const _journalReplayChunkSize = 1000
+
+// journalEntry is a pod change kept in the journal, along with the time
+// it was journaled at.
+type journalEntry struct {
+	*podHistoryEntry
+	time time.Time
+}
+
+// journalBucket is a bucket of the store the journal wrote changes to,
+// along with the revision of the first change written to it.
+type journalBucket struct {
+	bucket   int64
+	revision uint64
+}
+
+// journal keeps the pod changes of the last retention period, so that
+// clients which were down for longer than the history of the processor
+// can replay the changes they missed. The changes are kept in memory,
+// and optionally written to a store in the background while the job
+// manager is the leader, in which case the changes evicted from memory
+// can be replayed from the store as long as the store holds all of them. The changes journaled before a
+// restart of the job manager are not replayed, as the changes the
+// previous leader did not write can not be told apart from the changes
+// it never made.
+type journal struct {
+	sync.Mutex
+
+	retention     time.Duration
+	maxSize       int
+	maxReplaySize int
+
+	// the journal holds all the changes after since in memory, since
+	// moves forward as the oldest changes are evicted
+	since uint64
+	// revision of the last change journaled
+	revision uint64
+	// the changes in memory, sorted by revision
+	entries []*journalEntry
+
+	// store the changes are written to, nil if the journal is not
+	// persisted
+	store ormobjects.WatchJournalOps
+	// changes not written to the store yet, sorted by revision
+	unflushed []*journalEntry
+	// revision of the last change written to the store
+	persisted uint64
+	// the store and the unflushed changes hold all the changes after
+	// contiguous, contiguous moves forward as unflushed changes are
+	// dropped
+	contiguous uint64
+	// the buckets written to, sorted by bucket
+	buckets []journalBucket
+	// interval the changes are written to the store at
+	flushInterval time.Duration
+	// lifecycle of the writes to the store, which are only made while
+	// the job manager is the leader
+	lifeCycle lifecycle.LifeCycle
+
+	metrics *Metrics
+}
+
+// newJournal returns a journal holding the changes after revision. Once
+// started, the changes are written to the store every flush interval,
+// unless the store is nil.
+func newJournal(
+	cfg JournalConfig,
+	revision uint64,
+	store ormobjects.WatchJournalOps,
+	metrics *Metrics,
+) *journal {
+	return &journal{
+		retention:     cfg.Retention,
+		maxSize:       cfg.MaxSize,
+		maxReplaySize: cfg.MaxReplaySize,
+		since:         revision,
+		revision:      revision,
+		store:         store,
+		persisted:     revision,
+		contiguous:    revision,
+		flushInterval: cfg.FlushInterval,
+		lifeCycle:     lifecycle.NewLifeCycle(),
+		metrics:       metrics,
+	}
+}
+
+// start starts writing the journaled changes to the store, if any.
+func (j *journal) start() {
+	if j.store == nil || !j.lifeCycle.Start() {
+		return
+	}
+	go j.flushLoop(j.lifeCycle.StopCh())
+}
+
+// stop stops writing the journaled changes to the store, and waits for
+// the write in progress if any. The changes not written yet are dropped
+// when the journal is reset.
+func (j *journal) stop() {
+	if !j.lifeCycle.Stop() {
+		return
+	}
+	j.lifeCycle.Wait()
+}
+
+// add journals the change of the entry, and evicts the changes which
+// are older than the retention period.
+func (j *journal) add(e *podHistoryEntry, now time.Time) {
+	j.Lock()
+	defer j.Unlock()
+
+	entry := &journalEntry{podHistoryEntry: e, time: now}
+	j.entries = append(j.entries, entry)
+	j.revision = e.change.Revision
+
+	n := 0
+	cutoff := now.Add(-j.retention)
+	for n < len(j.entries) &&
+		(len(j.entries)-n > j.maxSize || j.entries[n].time.Before(cutoff)) {
+		n++
+	}
+	if n > 0 {
+		j.since = j.entries[n-1].change.Revision
+		j.entries = j.entries[n:]
+	}
+
+	if j.store == nil {
+		return
+	}
+	// the store is not keeping up, drop the oldest change rather than
+	// holding on to the memory, the store no longer holds all the
+	// changes up to it
+	if len(j.unflushed) >= j.maxSize {
+		j.metrics.JournalDropped.Inc(1)
+		j.contiguous = j.unflushed[0].change.Revision
+		j.unflushed = j.unflushed[1:]
+	}
+	j.unflushed = append(j.unflushed, entry)
+}
+
+// reset drops the changes journaled so far, and holds the changes after
+// revision from now on. The changes already written to the store are not
+// replayed, as after a restart of the job manager.
+func (j *journal) reset(revision uint64) {
+	j.Lock()
+	defer j.Unlock()
+
+	j.since = revision
+	j.revision = revision
+	j.entries = nil
+	j.unflushed = nil
+	j.persisted = revision
+	j.contiguous = revision
+	j.buckets = nil
+}
+
+// flushLoop writes the journaled changes to the store every flush
+// interval, until stopped.
+func (j *journal) flushLoop(stopCh <-chan struct{}) {
+	defer j.lifeCycle.StopComplete()
+
+	ticker := time.NewTicker(j.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			j.flush()
+		}
+	}
+}
+
+// flush writes the unflushed changes to the store in order. The writes
+// stop at the first change which can not be written, it and the changes
+// after it are written again at the next flush.
+func (j *journal) flush() {
+	j.Lock()
+	entries := make([]*journalEntry, len(j.unflushed))
+	copy(entries, j.unflushed)
+	j.Unlock()
+
+	written := 0
+	for _, e := range entries {
+		if err := j.write(e); err != nil {
+			log.WithError(err).
+				WithField("revision", e.change.Revision).
+				Warn("failed to write pod change to watch journal, retrying at next flush")
+			j.metrics.JournalFlushFail.Inc(1)
+			break
+		}
+		written++
+	}
+	if written == 0 {
+		return
+	}
+
+	j.Lock()
+	defer j.Unlock()
+
+	last := entries[written-1].change.Revision
+	// the journal was reset while writing
+	if last <= j.persisted {
+		return
+	}
+	// the oldest changes may have been dropped while writing
+	n := sort.Search(len(j.unflushed), func(i int) bool {
+		return j.unflushed[i].change.Revision > last
+	})
+	j.unflushed = j.unflushed[n:]
+	j.persisted = last
+
+	for _, e := range entries[:written] {
+		b := ormobjects.WatchJournalBucket(e.time)
+		if len(j.buckets) == 0 || j.buckets[len(j.buckets)-1].bucket < b {
+			j.buckets = append(j.buckets, journalBucket{
+				bucket:   b,
+				revision: e.change.Revision,
+			})
+		}
+	}
+	cutoff := ormobjects.WatchJournalBucket(time.Now().Add(-j.retention))
+	n = 0
+	for n < len(j.buckets)-1 && j.buckets[n+1].bucket <= cutoff {
+		n++
+	}
+	j.buckets = j.buckets[n:]
+
+	j.metrics.JournalFlush.Inc(1)
+}
+
+// write writes a change to the store.
+func (j *journal) write(e *journalEntry) error {
+	podData, err := proto.Marshal(e.change.Pod)
+	if err != nil {
+		return err
+	}
+	labels, err := json.Marshal(e.labels)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), _journalStoreTimeout)
+	defer cancel()
+	return j.store.Add(ctx, &ormobjects.WatchJournalObject{
+		Revision:   e.change.Revision,
+		JobID:      e.jobID,
+		Pod:        podData,
+		Labels:     labels,
+		RespoolID:  e.respoolID,
+		CreateTime: e.time,
+	})
+}
+
+// journalPage collects the changes of a page of replay, and checks that
+// the changes it is given have consecutive revisions.
+type journalPage struct {
+	filter    *watch.PodFilter
+	respools  *RespoolSet
+	fieldMask []string
+	limit     int
+
+	// revision of the last change given to the page
+	revision uint64
+	changes  []*PodChange
+}
+
+// add adds the change of the entry to the page if it is selected.
+// Returns an out of range error if the change does not follow the last
+// change given to the page, and whether the page is full and another
+// selected change follows it.
+func (p *journalPage) add(e *journalEntry) (bool, error) {
+	if e.change.Revision != p.revision+1 {
+		return false, yarpcerrors.OutOfRangeErrorf(
+			"changes after revision %d are missing", p.revision)
+	}
+	p.revision = e.change.Revision
+
+	if !matchPodFilter(p.filter, p.respools, e) {
+		return false, nil
+	}
+	if len(p.changes) == p.limit {
+		return true, nil
+	}
+	p.changes = append(p.changes, maskPodChange(e.change, p.fieldMask))
+	return false, nil
+}
+
+// replay returns the changes of the pods selected by the filter and by
+// the resource pools resolved from it after startRevision, at most limit
+// of them, along with the revision to
+// continue from, and whether there are more changes after it. The
+// changes older than the changes in memory are read from the store.
+// Returns an out of range error if any of the changes after
+// startRevision are missing.
+func (j *journal) replay(
+	ctx context.Context,
+	filter *watch.PodFilter,
+	respools *RespoolSet,
+	startRevision uint64,
+	fieldMask []string,
+	limit int,
+) ([]*PodChange, uint64, bool, error) {
+	if limit <= 0 || limit > j.maxReplaySize {
+		limit = j.maxReplaySize
+	}
+
+	j.Lock()
+	since, revision := j.since, j.revision
+	persisted, contiguous := j.persisted, j.contiguous
+	buckets := make([]journalBucket, len(j.buckets))
+	copy(buckets, j.buckets)
+	var unflushed []*journalEntry
+	for _, e := range j.unflushed {
+		if e.change.Revision > startRevision && e.change.Revision <= since {
+			unflushed = append(unflushed, e)
+		}
+	}
+	j.Unlock()
+
+	if startRevision > revision {
+		j.metrics.WatchPodReplayOutOfRange.Inc(1)
+		return nil, 0, false, yarpcerrors.OutOfRangeErrorf(
+			"start revision %d is newer than server revision %d",
+			startRevision, revision)
+	}
+
+	p := &journalPage{
+		filter:    filter,
+		respools:  respools,
+		fieldMask: fieldMask,
+		limit:     limit,
+		revision:  startRevision,
+	}
+	full, err := j.replayEvicted(
+		ctx, p, since, persisted, contiguous, buckets, unflushed)
+	if err == nil && !full {
+		full, err = j.replayMemory(p, revision)
+	}
+	if err != nil {
+		if yarpcerrors.IsOutOfRange(err) {
+			j.metrics.WatchPodReplayOutOfRange.Inc(1)
+		}
+		return nil, 0, false, err
+	}
+	if full {
+		// the revision of the last change is the revision the next
+		// page is replayed from
+		return p.changes, p.changes[limit-1].Revision, true, nil
+	}
+	return p.changes, revision, false, nil
+}
+
+// replayEvicted adds the changes evicted from memory up to since to the
+// page, the changes written to the store up to persisted are read from
+// the buckets, and the ones after it are taken from the unflushed
+// changes. Returns whether the page is full.
+func (j *journal) replayEvicted(
+	ctx context.Context,
+	p *journalPage,
+	since uint64,
+	persisted uint64,
+	contiguous uint64,
+	buckets []journalBucket,
+	unflushed []*journalEntry,
+) (bool, error) {
+	if p.revision >= since {
+		return false, nil
+	}
+	if j.store == nil || p.revision < contiguous {
+		return false, yarpcerrors.OutOfRangeErrorf(
+			"start revision %d is too old", p.revision)
+	}
+
+	end := persisted
+	if since < end {
+		end = since
+	}
+	if p.revision < end {
+		full, err := j.replayStore(ctx, p, end, buckets)
+		if err != nil || full {
+			return full, err
+		}
+		if p.revision != end {
+			return false, yarpcerrors.OutOfRangeErrorf(
+				"changes after revision %d are missing", p.revision)
+		}
+	}
+
+	for _, e := range unflushed {
+		if e.change.Revision <= p.revision {
+			continue
+		}
+		full, err := p.add(e)
+		if err != nil || full {
+			return full, err
+		}
+	}
+	if p.revision != since {
+		return false, yarpcerrors.OutOfRangeErrorf(
+			"changes after revision %d are missing", p.revision)
+	}
+	return false, nil
+}
+
+// replayStore adds the changes in the store up to endRevision to the
+// page, reading the buckets from the one holding the change after the
+// last change of the page, until the page is full. Returns whether the
+// page is full.
+func (j *journal) replayStore(
+	ctx context.Context,
+	p *journalPage,
+	endRevision uint64,
+	buckets []journalBucket,
+) (bool, error) {
+	cutoff := time.Now().Add(-j.retention)
+
+	i := sort.Search(len(buckets), func(i int) bool {
+		return buckets[i].revision > p.revision+1
+	})
+	if i > 0 {
+		i--
+	}
+	for ; i < len(buckets) && p.revision < endRevision; i++ {
+		readCtx, cancel := context.WithTimeout(ctx, _journalStoreTimeout)
+		objs, err := j.store.GetAll(readCtx, buckets[i].bucket)
+		cancel()
+		if err != nil {
+			return false, err
+		}
+		sort.Slice(objs, func(a, b int) bool {
+			return objs[a].Revision < objs[b].Revision
+		})
+
+		for _, obj := range objs {
+			if obj.Revision <= p.revision || obj.CreateTime.Before(cutoff) {
+				continue
+			}
+			if obj.Revision > endRevision {
+				break
+			}
+			e, err := newJournalEntry(obj)
+			if err != nil {
+				return false, err
+			}
+			full, err := p.add(e)
+			if err != nil || full {
+				return full, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// replayMemory adds the changes in memory up to endRevision to the
+// page. The changes are copied under the lock a chunk at a time, and
+// matched without it, so that replaying does not hold up the changes
+// being journaled. Returns whether the page is full.
+func (j *journal) replayMemory(p *journalPage, endRevision uint64) (bool, error) {
+	for p.revision < endRevision {
+		j.Lock()
+		i := sort.Search(len(j.entries), func(i int) bool {
+			return j.entries[i].change.Revision > p.revision
+		})
+		n := len(j.entries) - i
+		if n > _journalReplayChunkSize {
+			n = _journalReplayChunkSize
+		}
+		entries := make([]*journalEntry, n)
+		copy(entries, j.entries[i:i+n])
+		j.Unlock()
+
+		if len(entries) == 0 {
+			// the changes were evicted while replaying
+			return false, yarpcerrors.OutOfRangeErrorf(
+				"changes after revision %d are missing", p.revision)
+		}
+		for _, e := range entries {
+			if e.change.Revision > endRevision {
+				return false, nil
+			}
+			full, err := p.add(e)
+			if err != nil || full {
+				return full, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// newJournalEntry returns the journal entry of a change read from the
+// store.
+func newJournalEntry(obj *ormobjects.WatchJournalObject) (*journalEntry, error) {
+	p := &pod.PodSummary{}
+	if err := proto.Unmarshal(obj.Pod, p); err != nil {
+		return nil, yarpcerrors.InternalErrorf(
+			"failed to unmarshal pod of revision %d: %v", obj.Revision, err)
+	}
+	var labels []*peloton.Label
+	if len(obj.Labels) != 0 {
+		if err := json.Unmarshal(obj.Labels, &labels); err != nil {
+			return nil, yarpcerrors.InternalErrorf(
+				"failed to unmarshal labels of revision %d: %v",
+				obj.Revision, err)
+		}
+	}
+
+	return &journalEntry{
+		podHistoryEntry: &podHistoryEntry{
+			change: &PodChange{
+				Revision: obj.Revision,
+				Pod:      p,
+			},
+			jobID:     obj.JobID,
+			labels:    labels,
+			respoolID: obj.RespoolID,
+		},
+		time: obj.CreateTime,
+	}, nil
+}
@@ -0,0 +1,439 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watchsvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/watch"
+
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// fakeJournalStore keeps the journaled changes in memory
+type fakeJournalStore struct {
+	sync.Mutex
+	buckets map[int64][]*ormobjects.WatchJournalObject
+	err     error
+}
+
+func (s *fakeJournalStore) Add(
+	ctx context.Context,
+	obj *ormobjects.WatchJournalObject,
+) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	obj.Bucket = ormobjects.WatchJournalBucket(obj.CreateTime)
+	s.buckets[obj.Bucket] = append(s.buckets[obj.Bucket], obj)
+	return nil
+}
+
+func (s *fakeJournalStore) GetAll(
+	ctx context.Context,
+	bucket int64,
+) ([]*ormobjects.WatchJournalObject, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	objs := append([]*ormobjects.WatchJournalObject(nil), s.buckets[bucket]...)
+	sort.Slice(objs, func(i, j int) bool {
+		return objs[i].Revision < objs[j].Revision
+	})
+	return objs, nil
+}
+
+type JournalTestSuite struct {
+	suite.Suite
+
+	ctx     context.Context
+	config  JournalConfig
+	store   *fakeJournalStore
+	metrics *Metrics
+}
+
+func (suite *JournalTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.config = JournalConfig{
+		Retention: time.Minute,
+		MaxSize:   3,
+		// the changes are flushed explicitly by the tests
+		FlushInterval: time.Hour,
+		MaxReplaySize: 2,
+	}
+	suite.store = &fakeJournalStore{
+		buckets: make(map[int64][]*ormobjects.WatchJournalObject),
+	}
+	suite.metrics = NewMetrics(tally.NoopScope)
+}
+
+func TestJournal(t *testing.T) {
+	suite.Run(t, new(JournalTestSuite))
+}
+
+func newJournalTestEntry(
+	revision uint64,
+	jobID string,
+	instanceID int,
+) *podHistoryEntry {
+	return &podHistoryEntry{
+		change: &PodChange{
+			Revision: revision,
+			Pod: &pod.PodSummary{
+				PodName: &peloton.PodName{
+					Value: fmt.Sprintf("%s-%d", jobID, instanceID),
+				},
+			},
+		},
+		jobID: jobID,
+		labels: []*peloton.Label{
+			{Key: "instance", Value: fmt.Sprint(instanceID)},
+		},
+		respoolID: "respool-" + jobID,
+	}
+}
+
+func revisionsOf(changes []*PodChange) []uint64 {
+	var revisions []uint64
+	for _, c := range changes {
+		revisions = append(revisions, c.Revision)
+	}
+	return revisions
+}
+
+// TestReplay tests replaying the changes in memory page by page
+func (suite *JournalTestSuite) TestReplay() {
+	j := newJournal(suite.config, 100, nil, suite.metrics)
+	now := time.Now()
+	for i := 1; i <= 4; i++ {
+		j.add(newJournalTestEntry(uint64(100+i), "job", i), now)
+	}
+
+	// the oldest change is evicted, as the journal holds 3 changes
+	_, _, _, err := j.replay(suite.ctx, nil, nil, 100, nil, 0)
+	suite.True(yarpcerrors.IsOutOfRange(err))
+
+	changes, revision, hasMore, err := j.replay(
+		suite.ctx, nil, nil, 101, nil, 0)
+	suite.NoError(err)
+	suite.Equal([]uint64{102, 103}, revisionsOf(changes))
+	suite.Equal(uint64(103), revision)
+	suite.True(hasMore)
+
+	changes, revision, hasMore, err = j.replay(
+		suite.ctx, nil, nil, revision, nil, 0)
+	suite.NoError(err)
+	suite.Equal([]uint64{104}, revisionsOf(changes))
+	suite.Equal(uint64(104), revision)
+	suite.False(hasMore)
+
+	changes, revision, hasMore, err = j.replay(
+		suite.ctx, nil, nil, revision, nil, 0)
+	suite.NoError(err)
+	suite.Empty(changes)
+	suite.Equal(uint64(104), revision)
+	suite.False(hasMore)
+
+	_, _, _, err = j.replay(suite.ctx, nil, nil, 105, nil, 0)
+	suite.True(yarpcerrors.IsOutOfRange(err))
+}
+
+// TestReplayFilter tests replaying the changes of the pods selected by
+// a filter, with a field mask
+func (suite *JournalTestSuite) TestReplayFilter() {
+	suite.config.MaxSize = 10
+	j := newJournal(suite.config, 100, nil, suite.metrics)
+	now := time.Now()
+	j.add(newJournalTestEntry(101, "job1", 0), now)
+	j.add(newJournalTestEntry(102, "job2", 0), now)
+	j.add(newJournalTestEntry(103, "job1", 1), now)
+
+	changes, revision, hasMore, err := j.replay(
+		suite.ctx,
+		&watch.PodFilter{JobId: &peloton.JobID{Value: "job1"}},
+		nil,
+		100,
+		[]string{"status.state"},
+		1,
+	)
+	suite.NoError(err)
+	suite.Equal([]uint64{101}, revisionsOf(changes))
+	suite.Equal("job1-0", changes[0].Pod.GetPodName().GetValue())
+	suite.Equal(uint64(101), revision)
+	suite.True(hasMore)
+
+	changes, revision, hasMore, err = j.replay(
+		suite.ctx,
+		&watch.PodFilter{Labels: []*peloton.Label{
+			{Key: "instance", Value: "0"},
+		}},
+		nil,
+		100,
+		nil,
+		0,
+	)
+	suite.NoError(err)
+	suite.Equal([]uint64{101, 102}, revisionsOf(changes))
+	suite.Equal(uint64(103), revision)
+	suite.False(hasMore)
+
+	changes, _, _, err = j.replay(
+		suite.ctx,
+		nil,
+		NewRespoolSet("respool-job2"),
+		100,
+		nil,
+		0,
+	)
+	suite.NoError(err)
+	suite.Equal([]uint64{102}, revisionsOf(changes))
+}
+
+// TestRetention tests that the changes older than the retention period
+// are evicted
+func (suite *JournalTestSuite) TestRetention() {
+	suite.config.MaxSize = 10
+	j := newJournal(suite.config, 100, nil, suite.metrics)
+	now := time.Now()
+	j.add(newJournalTestEntry(101, "job", 0), now.Add(-2*time.Minute))
+	j.add(newJournalTestEntry(102, "job", 0), now)
+
+	suite.Equal(uint64(101), j.since)
+	_, _, _, err := j.replay(suite.ctx, nil, nil, 100, nil, 0)
+	suite.True(yarpcerrors.IsOutOfRange(err))
+
+	changes, _, _, err := j.replay(suite.ctx, nil, nil, 101, nil, 0)
+	suite.NoError(err)
+	suite.Equal([]uint64{102}, revisionsOf(changes))
+}
+
+// TestReplayPersisted tests replaying the changes written to the store
+// and the unflushed changes evicted from memory, followed by the changes
+// in memory
+func (suite *JournalTestSuite) TestReplayPersisted() {
+	now := time.Now()
+	j := newJournal(suite.config, 100, suite.store, suite.metrics)
+	for i := 1; i <= 3; i++ {
+		j.add(newJournalTestEntry(uint64(100+i), "job", i), now)
+	}
+	j.flush()
+	suite.Equal(uint64(103), j.persisted)
+
+	// 104 expires from memory before it is written to the store
+	j.add(newJournalTestEntry(104, "job", 4), now)
+	j.add(newJournalTestEntry(105, "job", 5), now.Add(2*time.Minute))
+	suite.Equal(uint64(104), j.since)
+	suite.Len(j.unflushed, 2)
+
+	j.maxReplaySize = 10
+	changes, revision, hasMore, err := j.replay(
+		suite.ctx, nil, nil, 100, nil, 0)
+	suite.NoError(err)
+	suite.Equal(
+		[]uint64{101, 102, 103, 104, 105},
+		revisionsOf(changes))
+	suite.Equal("job-1", changes[0].Pod.GetPodName().GetValue())
+	suite.Equal(uint64(105), revision)
+	suite.False(hasMore)
+
+	changes, revision, hasMore, err = j.replay(
+		suite.ctx, nil, nil, 101, nil, 2)
+	suite.NoError(err)
+	suite.Equal([]uint64{102, 103}, revisionsOf(changes))
+	suite.Equal(uint64(103), revision)
+	suite.True(hasMore)
+
+	// the labels of the changes read from the store are kept
+	changes, _, _, err = j.replay(
+		suite.ctx,
+		&watch.PodFilter{Labels: []*peloton.Label{
+			{Key: "instance", Value: "3"},
+		}},
+		nil,
+		100,
+		nil,
+		0,
+	)
+	suite.NoError(err)
+	suite.Equal([]uint64{103}, revisionsOf(changes))
+
+	// so are their resource pools
+	changes, _, _, err = j.replay(
+		suite.ctx,
+		nil,
+		NewRespoolSet("respool-other"),
+		100,
+		nil,
+		0,
+	)
+	suite.NoError(err)
+	suite.Empty(changes)
+
+	suite.store.err = errors.New("store unavailable")
+	_, _, _, err = j.replay(suite.ctx, nil, nil, 101, nil, 0)
+	suite.Error(err)
+	suite.False(yarpcerrors.IsOutOfRange(err))
+	suite.store.err = nil
+
+	// the changes journaled before a restart are not replayed
+	j = newJournal(suite.config, 200, suite.store, suite.metrics)
+	j.add(newJournalTestEntry(201, "job", 1), now)
+	_, _, _, err = j.replay(suite.ctx, nil, nil, 101, nil, 0)
+	suite.True(yarpcerrors.IsOutOfRange(err))
+}
+
+// TestReset tests that the changes journaled before a reset are not
+// replayed
+func (suite *JournalTestSuite) TestReset() {
+	now := time.Now()
+	j := newJournal(suite.config, 100, suite.store, suite.metrics)
+	for i := 1; i <= 2; i++ {
+		j.add(newJournalTestEntry(uint64(100+i), "job", i), now)
+	}
+	j.flush()
+
+	j.reset(200)
+	suite.Empty(j.entries)
+	suite.Empty(j.buckets)
+	suite.Equal(uint64(200), j.persisted)
+
+	_, _, _, err := j.replay(suite.ctx, nil, nil, 101, nil, 0)
+	suite.True(yarpcerrors.IsOutOfRange(err))
+
+	j.add(newJournalTestEntry(201, "job", 1), now)
+	changes, revision, hasMore, err := j.replay(
+		suite.ctx, nil, nil, 200, nil, 0)
+	suite.NoError(err)
+	suite.Equal([]uint64{201}, revisionsOf(changes))
+	suite.Equal(uint64(201), revision)
+	suite.False(hasMore)
+}
+
+// TestReplayGap tests that replaying over a change missing from the
+// store fails with out of range
+func (suite *JournalTestSuite) TestReplayGap() {
+	now := time.Now()
+	j := newJournal(suite.config, 100, suite.store, suite.metrics)
+	for i := 1; i <= 6; i++ {
+		j.add(newJournalTestEntry(uint64(100+i), "job", i), now)
+		j.flush()
+	}
+	suite.Equal(uint64(103), j.since)
+
+	bucket := ormobjects.WatchJournalBucket(now)
+	objs := suite.store.buckets[bucket]
+	suite.store.buckets[bucket] = append(objs[:1:1], objs[2:]...)
+
+	_, _, _, err := j.replay(suite.ctx, nil, nil, 100, nil, 0)
+	suite.True(yarpcerrors.IsOutOfRange(err))
+
+	changes, _, _, err := j.replay(suite.ctx, nil, nil, 102, nil, 0)
+	suite.NoError(err)
+	suite.Equal([]uint64{103, 104}, revisionsOf(changes))
+}
+
+// TestFlushFail tests that the changes which can not be written to the
+// store are written at the next flush
+func (suite *JournalTestSuite) TestFlushFail() {
+	j := newJournal(suite.config, 100, suite.store, suite.metrics)
+	suite.store.err = errors.New("store unavailable")
+	j.add(newJournalTestEntry(101, "job", 0), time.Now())
+	j.flush()
+	suite.Len(j.unflushed, 1)
+	suite.Equal(uint64(100), j.persisted)
+
+	suite.store.err = nil
+	j.add(newJournalTestEntry(102, "job", 0), time.Now())
+	j.flush()
+	suite.Empty(j.unflushed)
+	suite.Equal(uint64(102), j.persisted)
+	suite.Len(suite.store.buckets, 1)
+	for _, objs := range suite.store.buckets {
+		suite.Equal([]uint64{101, 102}, []uint64{objs[0].Revision, objs[1].Revision})
+	}
+}
+
+// TestFlushDropped tests that the changes after an unflushed change
+// dropped as the store is not keeping up can not be replayed from
+// before it
+func (suite *JournalTestSuite) TestFlushDropped() {
+	now := time.Now()
+	j := newJournal(suite.config, 100, suite.store, suite.metrics)
+	suite.store.err = errors.New("store unavailable")
+	for i := 1; i <= 5; i++ {
+		j.add(newJournalTestEntry(uint64(100+i), "job", i), now)
+	}
+	j.flush()
+	suite.Equal(uint64(102), j.contiguous)
+
+	suite.store.err = nil
+	j.flush()
+	suite.Equal(uint64(105), j.persisted)
+
+	_, _, _, err := j.replay(suite.ctx, nil, nil, 101, nil, 0)
+	suite.True(yarpcerrors.IsOutOfRange(err))
+
+	changes, _, _, err := j.replay(suite.ctx, nil, nil, 102, nil, 0)
+	suite.NoError(err)
+	suite.Equal([]uint64{103, 104}, revisionsOf(changes))
+}
+
+// TestStartStop tests that the changes are only written to the store
+// while the journal is started
+func (suite *JournalTestSuite) TestStartStop() {
+	suite.config.FlushInterval = time.Millisecond
+	j := newJournal(suite.config, 100, suite.store, suite.metrics)
+	j.add(newJournalTestEntry(101, "job", 0), time.Now())
+
+	j.start()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		j.Lock()
+		persisted := j.persisted
+		j.Unlock()
+		if persisted == 101 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	j.stop()
+	suite.Equal(uint64(101), j.persisted)
+
+	j.add(newJournalTestEntry(102, "job", 0), time.Now())
+	time.Sleep(10 * suite.config.FlushInterval)
+	suite.Len(j.unflushed, 1)
+	suite.Equal(uint64(101), j.persisted)
+
+	// the journal is not written if there is no store
+	j = newJournal(suite.config, 100, nil, suite.metrics)
+	j.start()
+	j.stop()
+}
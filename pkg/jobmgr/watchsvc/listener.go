@@ -15,29 +15,60 @@
 package watchsvc
 
 import (
+	"context"
+	"sync"
+	"time"
+
 	"github.com/uber/peloton/.gen/peloton/api/v0/job"
 	v0peloton "github.com/uber/peloton/.gen/peloton/api/v0/peloton"
 	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/job/stateless"
 	v1peloton "github.com/uber/peloton/.gen/peloton/api/v1alpha/peloton"
 	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/watch"
+	"github.com/uber/peloton/.gen/peloton/private/models"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/uber/peloton/pkg/common/util"
 	handlerutil "github.com/uber/peloton/pkg/jobmgr/util/handler"
+	"github.com/uber/peloton/pkg/storage"
 )
 
-const _listenerName = "WatchListener"
+const (
+	_listenerName = "WatchListener"
+
+	// _respoolLoadTimeout is the timeout of loading the resource pool of
+	// a job whose config is not cached
+	_respoolLoadTimeout = 10 * time.Second
+)
 
 // WatchListener is a job / task runtime event listener which implements
 // cached.JobTaskListener interface, used by watch api.
 type WatchListener struct {
 	processor WatchProcessor
+	// jobStore loads the resource pool of the jobs whose config is not
+	// cached, so that their pods are not dropped by the pod watches
+	// selecting the pods by resource pool
+	jobStore storage.JobStore
+	respools *jobRespools
+}
+
+// jobRespools are the resource pools loaded from the store by job id,
+// kept till the job reaches a terminal state
+type jobRespools struct {
+	sync.Mutex
+	ids map[string]string
 }
 
 // NewWatchListener returns a new instance of watchsvc.WatchListener
-func NewWatchListener(processor WatchProcessor) WatchListener {
+func NewWatchListener(
+	processor WatchProcessor,
+	jobStore storage.JobStore,
+) WatchListener {
 	return WatchListener{
 		processor: processor,
+		jobStore:  jobStore,
+		respools:  &jobRespools{ids: make(map[string]string)},
 	}
 }
 
@@ -54,6 +85,13 @@ func (l WatchListener) JobRuntimeChanged(
 	runtime *job.RuntimeInfo,
 ) {
 	// TODO(kevinxu): to be implemented
+
+	// the resource pool loaded for the job is not needed anymore
+	if jobID != nil && util.IsPelotonJobStateTerminal(runtime.GetState()) {
+		l.respools.Lock()
+		delete(l.respools.ids, jobID.GetValue())
+		l.respools.Unlock()
+	}
 }
 
 // TaskRuntimeChanged is invoked when the runtime for a task is updated
@@ -63,28 +101,127 @@ func (l WatchListener) TaskRuntimeChanged(
 	instanceID uint32,
 	jobType job.JobType,
 	runtime *task.RuntimeInfo,
+	labels []*v0peloton.Label,
+	respoolID *v0peloton.ResourcePoolID,
 ) {
+	if jobID == nil {
+		log.Debug("skip TaskRuntimeChanged due to jobID being nil")
+		return
+	}
+
+	if runtime == nil {
+		log.Debug("skip TaskRuntimeChanged due to runtime being nil")
+		return
+	}
+
+	p := newPodSummary(jobID.GetValue(), instanceID, runtime)
+
+	// firehose streams the pods of all job types
+	l.processor.NotifyFirehoseTaskChange(jobID.GetValue(), p)
+
 	// for now watch api only supports stateless
 	if jobType != job.JobType_SERVICE {
 		log.Debug("skip TaskRuntimeChanged due to not being service type job")
 		return
 	}
 
+	respool := respoolID.GetValue()
+	if respoolID == nil {
+		respool = l.loadRespoolID(jobID.GetValue())
+	}
+
+	l.processor.NotifyTaskChange(
+		jobID.GetValue(),
+		p,
+		handlerutil.ConvertLabels(labels),
+		respool,
+	)
+}
+
+// loadRespoolID returns the resource pool of a job whose config is not
+// cached. The listener may be notified with the job lock held, so the
+// config is not loaded into the cache but from the store, once per job.
+func (l WatchListener) loadRespoolID(jobID string) string {
+	l.respools.Lock()
+	respoolID, ok := l.respools.ids[jobID]
+	l.respools.Unlock()
+	if ok {
+		return respoolID
+	}
+
+	ctx, cancelFunc := context.WithTimeout(
+		context.Background(), _respoolLoadTimeout)
+	defer cancelFunc()
+
+	config, _, err := l.jobStore.GetJobConfig(ctx, jobID)
+	if err != nil {
+		log.WithField("job_id", jobID).
+			WithError(err).
+			Warn("failed to load resource pool of job")
+		return ""
+	}
+
+	respoolID = config.GetRespoolID().GetValue()
+	l.respools.Lock()
+	l.respools.ids[jobID] = respoolID
+	l.respools.Unlock()
+	return respoolID
+}
+
+// UpdateChanged is invoked when the state or the progress of an update
+// of a job is updated in cache and persistent store.
+func (l WatchListener) UpdateChanged(
+	jobID *v0peloton.JobID,
+	updateInfo *models.UpdateModel,
+	instancesDone []uint32,
+) {
 	if jobID == nil {
-		log.Debug("skip TaskRuntimeChanged due to jobID being nil")
+		log.Debug("skip UpdateChanged due to jobID being nil")
 		return
 	}
 
-	if runtime == nil {
-		log.Debug("skip TaskRuntimeChanged due to runtime being nil")
+	if updateInfo == nil {
+		log.Debug("skip UpdateChanged due to updateInfo being nil")
 		return
 	}
 
-	p := &pod.PodSummary{
+	l.processor.NotifyWorkflowChange(
+		newWorkflowChange(jobID.GetValue(), updateInfo, instancesDone))
+}
+
+// newWorkflowChange returns the change of a workflow of a job with the
+// update model, in which the versions of the workflow are not set since
+// they depend on the runtime of the job.
+func newWorkflowChange(
+	jobID string,
+	updateInfo *models.UpdateModel,
+	instancesDone []uint32,
+) *watch.WorkflowChange {
+	return &watch.WorkflowChange{
+		JobId: &v1peloton.JobID{Value: jobID},
+		Status: &stateless.WorkflowStatus{
+			Type:                  stateless.WorkflowType(updateInfo.GetType()),
+			State:                 stateless.WorkflowState(updateInfo.GetState()),
+			PrevState:             stateless.WorkflowState(updateInfo.GetPrevState()),
+			NumInstancesCompleted: updateInfo.GetInstancesDone(),
+			NumInstancesRemaining: updateInfo.GetInstancesTotal() - updateInfo.GetInstancesDone() - updateInfo.GetInstancesFailed(),
+			NumInstancesFailed:    updateInfo.GetInstancesFailed(),
+			InstancesCurrent:      updateInfo.GetInstancesCurrent(),
+		},
+		InstancesDone: instancesDone,
+	}
+}
+
+// newPodSummary returns the summary of a pod of a job with the runtime
+func newPodSummary(
+	jobID string,
+	instanceID uint32,
+	runtime *task.RuntimeInfo,
+) *pod.PodSummary {
+	return &pod.PodSummary{
 		PodName: &v1peloton.PodName{
-			Value: util.CreatePelotonTaskID(jobID.GetValue(), instanceID),
+			Value: util.CreatePelotonTaskID(jobID, instanceID),
 		},
 		Status: handlerutil.ConvertTaskRuntimeToPodStatus(runtime),
 	}
-	l.processor.NotifyTaskChange(p)
 }
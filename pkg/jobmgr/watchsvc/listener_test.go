@@ -20,8 +20,14 @@ import (
 	"github.com/uber/peloton/.gen/peloton/api/v0/job"
 	v0peloton "github.com/uber/peloton/.gen/peloton/api/v0/peloton"
 	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	pbupdate "github.com/uber/peloton/.gen/peloton/api/v0/update"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/job/stateless"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/watch"
+	"github.com/uber/peloton/.gen/peloton/private/models"
 
 	watchmocks "github.com/uber/peloton/pkg/jobmgr/watchsvc/mocks"
+	storemocks "github.com/uber/peloton/pkg/storage/mocks"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/suite"
@@ -36,13 +42,15 @@ type WatchListenerTestSuite struct {
 
 	ctrl      *gomock.Controller
 	processor *watchmocks.MockWatchProcessor
+	jobStore  *storemocks.MockJobStore
 }
 
 func (suite *WatchListenerTestSuite) SetupTest() {
 	suite.ctrl = gomock.NewController(suite.T())
 	suite.processor = watchmocks.NewMockWatchProcessor(suite.ctrl)
 
-	suite.listener = NewWatchListener(suite.processor)
+	suite.jobStore = storemocks.NewMockJobStore(suite.ctrl)
+	suite.listener = NewWatchListener(suite.processor, suite.jobStore)
 }
 
 // TestWatchListenerName checks listener name
@@ -54,7 +62,12 @@ func (suite *WatchListenerTestSuite) TestWatchListenerName() {
 // when TaskRuntimeChanged is called on listener
 func (suite *WatchListenerTestSuite) TestTaskRuntimeChanged() {
 	suite.processor.EXPECT().
-		NotifyTaskChange(gomock.Any()).
+		NotifyFirehoseTaskChange("test-job-1", gomock.Any()).
+		Times(1)
+	suite.processor.EXPECT().
+		NotifyTaskChange("test-job-1", gomock.Any(), []*peloton.Label{
+			{Key: "app", Value: "web"},
+		}, "respool-1").
 		Times(1)
 
 	suite.listener.TaskRuntimeChanged(
@@ -62,20 +75,56 @@ func (suite *WatchListenerTestSuite) TestTaskRuntimeChanged() {
 		0,
 		job.JobType_SERVICE,
 		&task.RuntimeInfo{},
+		[]*v0peloton.Label{{Key: "app", Value: "web"}},
+		&v0peloton.ResourcePoolID{Value: "respool-1"},
 	)
 }
 
+// TestTaskRuntimeChanged_RespoolNotCached checks that the resource pool of
+// a job whose config is not cached is loaded from the store once, and
+// forgotten once the job reaches a terminal state.
+func (suite *WatchListenerTestSuite) TestTaskRuntimeChanged_RespoolNotCached() {
+	jobID := &v0peloton.JobID{Value: "test-job-1"}
+	suite.processor.EXPECT().
+		NotifyFirehoseTaskChange("test-job-1", gomock.Any()).
+		Times(3)
+	suite.processor.EXPECT().
+		NotifyTaskChange("test-job-1", gomock.Any(), gomock.Any(), "respool-1").
+		Times(3)
+	suite.jobStore.EXPECT().
+		GetJobConfig(gomock.Any(), "test-job-1").
+		Return(&job.JobConfig{
+			RespoolID: &v0peloton.ResourcePoolID{Value: "respool-1"},
+		}, nil, nil).
+		Times(2)
+
+	for i := 0; i < 2; i++ {
+		suite.listener.TaskRuntimeChanged(
+			jobID, 0, job.JobType_SERVICE, &task.RuntimeInfo{}, nil, nil)
+	}
+
+	suite.listener.JobRuntimeChanged(
+		jobID, job.JobType_SERVICE, &job.RuntimeInfo{State: job.JobState_KILLED})
+	suite.listener.TaskRuntimeChanged(
+		jobID, 0, job.JobType_SERVICE, &task.RuntimeInfo{}, nil, nil)
+}
+
 // TestTaskRuntimeChanged_NonServiceType checks
 // WatchProcessor.NotifyTaskChange() is not called when not service type
-// event is passed in.
+// event is passed in, while the event is still sent to the firehose.
 func (suite *WatchListenerTestSuite) TestTaskRuntimeChanged_NonServiceType() {
 	// do not expect call to processor.NotifyTaskChange
+	suite.processor.EXPECT().
+		NotifyFirehoseTaskChange("test-job-1", gomock.Any()).
+		Times(1)
 
 	suite.listener.TaskRuntimeChanged(
 		&v0peloton.JobID{Value: "test-job-1"},
 		0,
 		job.JobType_BATCH,
 		&task.RuntimeInfo{},
+		nil,
+		nil,
 	)
 }
 
@@ -89,6 +138,8 @@ func (suite *WatchListenerTestSuite) TestTaskRuntimeChanged_NilFields() {
 		0,
 		job.JobType_SERVICE,
 		&task.RuntimeInfo{},
+		nil,
+		nil,
 	)
 
 	suite.listener.TaskRuntimeChanged(
@@ -96,9 +147,51 @@ func (suite *WatchListenerTestSuite) TestTaskRuntimeChanged_NilFields() {
 		0,
 		job.JobType_SERVICE,
 		nil,
+		nil,
+		nil,
 	)
 }
 
+// TestUpdateChanged checks WatchProcessor.NotifyWorkflowChange() is called
+// with the status of the workflow when UpdateChanged is called on listener
+func (suite *WatchListenerTestSuite) TestUpdateChanged() {
+	suite.processor.EXPECT().
+		NotifyWorkflowChange(gomock.Any()).
+		Do(func(change *watch.WorkflowChange) {
+			suite.Equal("test-job-1", change.GetJobId().GetValue())
+			suite.Equal(stateless.WorkflowType_WORKFLOW_TYPE_UPDATE,
+				change.GetStatus().GetType())
+			suite.Equal(stateless.WorkflowState_WORKFLOW_STATE_ROLLING_FORWARD,
+				change.GetStatus().GetState())
+			suite.Equal(uint32(2), change.GetStatus().GetNumInstancesCompleted())
+			suite.Equal(uint32(1), change.GetStatus().GetNumInstancesFailed())
+			suite.Equal(uint32(2), change.GetStatus().GetNumInstancesRemaining())
+			suite.Equal([]uint32{1}, change.GetInstancesDone())
+		})
+
+	suite.listener.UpdateChanged(
+		&v0peloton.JobID{Value: "test-job-1"},
+		&models.UpdateModel{
+			Type:            models.WorkflowType_UPDATE,
+			State:           pbupdate.State_ROLLING_FORWARD,
+			InstancesTotal:  5,
+			InstancesDone:   2,
+			InstancesFailed: 1,
+		},
+		[]uint32{1},
+	)
+}
+
+// TestUpdateChanged_NilFields checks WatchProcessor.NotifyWorkflowChange()
+// is not called when some of the fields are passed in as nil.
+func (suite *WatchListenerTestSuite) TestUpdateChanged_NilFields() {
+	// do not expect calls to processor.NotifyWorkflowChange
+
+	suite.listener.UpdateChanged(nil, &models.UpdateModel{}, nil)
+	suite.listener.UpdateChanged(
+		&v0peloton.JobID{Value: "test-job-1"}, nil, nil)
+}
+
 func TestWatchListener(t *testing.T) {
 	suite.Run(t, &WatchListenerTestSuite{})
 }
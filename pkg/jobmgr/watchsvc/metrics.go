@@ -23,7 +23,44 @@ type Metrics struct {
 	WatchPodCancel   tally.Counter
 	WatchPodOverflow tally.Counter
 
+	// pod changes dropped because the fan out queue of a shard was full
+	WatchPodFanOutDropped tally.Counter
+
+	WatchPodResume           tally.Counter
+	WatchPodResumeOutOfRange tally.Counter
+	WatchPodCoalesced        tally.Counter
+	WatchPodHeartbeat        tally.Counter
+
+	WatchPodReplay           tally.Counter
+	WatchPodReplayFail       tally.Counter
+	WatchPodReplayOutOfRange tally.Counter
+
+	WatchPodRespool         tally.Counter
+	WatchPodRespoolFail     tally.Counter
+	WatchPodRespoolRequired tally.Counter
+
+	WatchPodResourceUsage         tally.Counter
+	WatchPodResourceUsageFail     tally.Counter
+	WatchPodResourceUsageHostFail tally.Counter
+	WatchPodResourceUsageDropped  tally.Counter
+
+	JournalFlush     tally.Counter
+	JournalFlushFail tally.Counter
+	JournalDropped   tally.Counter
+
+	WatchWorkflowCancel   tally.Counter
+	WatchWorkflowOverflow tally.Counter
+
+	FirehoseCancel          tally.Counter
+	FirehoseOverflow        tally.Counter
+	FirehoseUnauthenticated tally.Counter
+
 	CancelNotFound tally.Counter
+	CloseWatcher   tally.Counter
+
+	GatewayWatch           tally.Counter
+	GatewayWatchFail       tally.Counter
+	GatewayWatchDisconnect tally.Counter
 
 	// Time takes to acquire lock in watch processor
 	ProcessorLockDuration tally.Timer
@@ -36,7 +73,43 @@ func NewMetrics(scope tally.Scope) *Metrics {
 		WatchPodCancel:   subScope.Counter("watch_pod_cancel"),
 		WatchPodOverflow: subScope.Counter("watch_pod_overflow"),
 
+		WatchPodFanOutDropped: subScope.Counter("watch_pod_fan_out_dropped"),
+
+		WatchPodResume:           subScope.Counter("watch_pod_resume"),
+		WatchPodResumeOutOfRange: subScope.Counter("watch_pod_resume_out_of_range"),
+		WatchPodCoalesced:        subScope.Counter("watch_pod_coalesced"),
+		WatchPodHeartbeat:        subScope.Counter("watch_pod_heartbeat"),
+
+		WatchPodReplay:           subScope.Counter("watch_pod_replay"),
+		WatchPodReplayFail:       subScope.Counter("watch_pod_replay_fail"),
+		WatchPodReplayOutOfRange: subScope.Counter("watch_pod_replay_out_of_range"),
+
+		WatchPodRespool:         subScope.Counter("watch_pod_respool"),
+		WatchPodRespoolFail:     subScope.Counter("watch_pod_respool_fail"),
+		WatchPodRespoolRequired: subScope.Counter("watch_pod_respool_required"),
+
+		WatchPodResourceUsage:         subScope.Counter("watch_pod_resource_usage"),
+		WatchPodResourceUsageFail:     subScope.Counter("watch_pod_resource_usage_fail"),
+		WatchPodResourceUsageHostFail: subScope.Counter("watch_pod_resource_usage_host_fail"),
+		WatchPodResourceUsageDropped:  subScope.Counter("watch_pod_resource_usage_dropped"),
+
+		JournalFlush:     subScope.Counter("journal_flush"),
+		JournalFlushFail: subScope.Counter("journal_flush_fail"),
+		JournalDropped:   subScope.Counter("journal_dropped"),
+
+		WatchWorkflowCancel:   subScope.Counter("watch_workflow_cancel"),
+		WatchWorkflowOverflow: subScope.Counter("watch_workflow_overflow"),
+
+		FirehoseCancel:          subScope.Counter("firehose_cancel"),
+		FirehoseOverflow:        subScope.Counter("firehose_overflow"),
+		FirehoseUnauthenticated: subScope.Counter("firehose_unauthenticated"),
+
 		CancelNotFound: subScope.Counter("cancel_not_found"),
+		CloseWatcher:   subScope.Counter("close_watcher"),
+
+		GatewayWatch:           subScope.Counter("gateway_watch"),
+		GatewayWatchFail:       subScope.Counter("gateway_watch_fail"),
+		GatewayWatchDisconnect: subScope.Counter("gateway_watch_disconnect"),
 
 		ProcessorLockDuration: subScope.Timer("processor_lock_duration"),
 	}
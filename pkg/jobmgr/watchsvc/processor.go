@@ -15,13 +15,22 @@
 package watchsvc
 
 import (
+	"context"
 	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/uber/peloton/.gen/peloton/api/v1alpha/job/stateless"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/peloton"
 	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
 	"github.com/uber/peloton/.gen/peloton/api/v1alpha/watch"
 
+	ormobjects "github.com/uber/peloton/pkg/storage/objects"
+
 	"github.com/pborman/uuid"
 	log "github.com/sirupsen/logrus"
 	"github.com/uber-go/tally"
@@ -40,6 +49,8 @@ const (
 	// StopSignalOverflow indicates the watch is aborted due to event
 	// overflow.
 	StopSignalOverflow
+	// StopSignalClose indicates the watch is force-closed by an operator.
+	StopSignalClose
 )
 
 // String returns a user-friendly name for the specific StopSignal
@@ -49,6 +60,8 @@ func (s StopSignal) String() string {
 		return "cancel"
 	case StopSignalOverflow:
 		return "overflow"
+	case StopSignalClose:
+		return "close"
 	default:
 		return "unknown"
 	}
@@ -63,6 +76,11 @@ const (
 	ClientTypeTask ClientType = "task"
 	// ClientTypeJob indicates the watch id belongs to a job watch client
 	ClientTypeJob ClientType = "job"
+	// ClientTypeFirehose indicates the watch id belongs to a firehose client
+	ClientTypeFirehose ClientType = "firehose"
+	// ClientTypeWorkflow indicates the watch id belongs to a workflow
+	// watch client
+	ClientTypeWorkflow ClientType = "workflow"
 )
 
 func (t ClientType) String() string {
@@ -72,37 +90,289 @@ func (t ClientType) String() string {
 // WatchProcessor interface is a central controller which handles watch
 // client lifecycle, and task / job event fan-out.
 type WatchProcessor interface {
-	// NewTaskClient creates a new watch client for task event changes.
+	// NewTaskClient creates a new watch client for the changes of the
+	// tasks selected by the filter. If startRevision is not zero, the
+	// changes received after startRevision which are still in the history
+	// of the processor are sent to the client first. If coalesce is set,
+	// the consecutive changes of a pod within the coalesce window are sent
+	// as a single change with the latest state of the pod. If fieldMask
+	// is not empty, only the fields of the pods in the field mask are sent.
+	// If respools is not nil, only the pods of the jobs in the resource
+	// pools of the set are sent.
 	// Returns the watch id and a new instance of TaskClient.
-	NewTaskClient() (string, *TaskClient, error)
+	NewTaskClient(
+		filter *watch.PodFilter,
+		respools *RespoolSet,
+		startRevision uint64,
+		coalesce bool,
+		fieldMask []string,
+	) (string, *TaskClient, error)
 
 	// StopTaskClient stops a task watch client. Returns "not-found" error
 	// if the corresponding watch client is not found.
 	StopTaskClient(watchID string) error
 
-	// NotifyTaskChange receives pod event, and notifies all the clients
-	// which are interested in the pod.
-	NotifyTaskChange(pod *pod.PodSummary)
+	// NotifyTaskChange receives pod event of a job along with the labels
+	// of the pod and the resource pool of the job, and notifies all the
+	// clients which are interested in the pod.
+	NotifyTaskChange(
+		jobID string,
+		pod *pod.PodSummary,
+		labels []*peloton.Label,
+		respoolID string,
+	)
+
+	// NewWorkflowClient creates a new watch client for the changes of the
+	// workflows of the jobs selected by the filter.
+	// Returns the watch id and a new instance of WorkflowClient.
+	NewWorkflowClient(
+		filter *watch.WorkflowFilter,
+	) (string, *WorkflowClient, error)
+
+	// StopWorkflowClient stops a workflow watch client. Returns "not-found"
+	// error if the corresponding watch client is not found.
+	StopWorkflowClient(watchID string) error
+
+	// NotifyWorkflowChange receives the change of a workflow of a job, and
+	// notifies all the clients which are interested in the job.
+	NotifyWorkflowChange(change *watch.WorkflowChange)
+
+	// NewFirehoseClient creates a new firehose client for the pod events
+	// of all the jobs in the shard of the filter.
+	// Returns the watch id and a new instance of FirehoseClient.
+	NewFirehoseClient(
+		filter *watch.FirehoseFilter,
+	) (string, *FirehoseClient, error)
+
+	// StopFirehoseClient stops a firehose client. Returns "not-found" error
+	// if the corresponding firehose client is not found.
+	StopFirehoseClient(watchID string) error
+
+	// NotifyFirehoseTaskChange receives pod event of a job of any type,
+	// and notifies all the firehose clients of the shard of the job.
+	NotifyFirehoseTaskChange(jobID string, pod *pod.PodSummary)
+
+	// ListClients returns the watch and firehose clients, sorted by
+	// watch id.
+	ListClients() []*watch.WatcherInfo
+
+	// CloseClient force-closes a watch or firehose client of any type.
+	// Returns "not-found" error if the client is not found.
+	CloseClient(watchID string) error
+
+	// Reset re-seeds the revision of the processor when the job manager
+	// gains leadership, and drops the changes received before. The task
+	// clients are stopped, so that they watch again.
+	Reset()
+
+	// Start starts writing the journal of the processor to its store. It
+	// should be called once the job manager gains leadership, after
+	// Reset, so that the journal is only written by the leader.
+	Start()
+
+	// Stop stops writing the journal of the processor to its store. It
+	// should be called when the job manager loses leadership.
+	Stop()
+
+	// Replay returns the changes of the pods selected by the filter after
+	// startRevision from the journal of the processor, at most limit of
+	// them, along with the revision to continue from, and whether there
+	// are more changes after it. If fieldMask is not empty, only the
+	// fields of the pods in the field mask are returned. If respools is
+	// not nil, only the changes of the pods of the jobs in the resource
+	// pools of the set are returned.
+	Replay(
+		ctx context.Context,
+		filter *watch.PodFilter,
+		respools *RespoolSet,
+		startRevision uint64,
+		fieldMask []string,
+		limit int,
+	) ([]*PodChange, uint64, bool, error)
 }
 
 // watchProcessor is an implementation of WatchProcessor interface.
 type watchProcessor struct {
 	sync.Mutex
-	bufferSize  int
-	maxClient   int
-	taskClients map[string]*TaskClient
-	jobClients  map[string]*JobClient
-	metrics     *Metrics
+	bufferSize int
+	maxClient  int
+	jobClients map[string]*JobClient
+	metrics    *Metrics
+
+	// task clients are sharded by watch id, so that the processor lock
+	// is only held to assign the revision of a pod change, while the
+	// change is fanned out to the clients of each shard concurrently
+	taskShards []*taskShard
+
+	workflowClients map[string]*WorkflowClient
+
+	// revision of the last pod change received by the processor. It is
+	// initialized with the creation time of the processor, and re-seeded
+	// with the time the job manager gains leadership, so that the
+	// revisions keep increasing across job manager restarts and leaders.
+	revision uint64
+	// revision the processor was created or last reset with
+	initRevision uint64
+	// ring buffer of the most recent pod changes, the change of revision
+	// r is stored at index r % len(history)
+	history []*podHistoryEntry
+	// window within which the changes of a pod are coalesced
+	coalesceWindow time.Duration
+	// journal of the pod changes of the last few minutes, which outlives
+	// the history when the changes are frequent
+	journal *journal
+
+	// firehose clients are buffered and locked separately, so that the
+	// events of all the jobs do not contend with the watch clients
+	firehoseLock       sync.Mutex
+	firehoseBufferSize int
+	firehoseMaxClient  int
+	firehoseClients    map[string]*FirehoseClient
 }
 
 var processor *watchProcessor
 var onceInitWatchProcessor sync.Once
 
+// taskShard holds the task clients of a shard, keyed by watch id. The pod
+// changes are queued to every shard in the order of their revisions, and
+// fanned out to the clients of the shard by a goroutine of its own.
+type taskShard struct {
+	sync.Mutex
+	clients map[string]*TaskClient
+	events  chan *fanOutEvent
+
+	// revision of the last pod change fanned out to the clients
+	revision uint64
+	// revision of the last pod change dropped because the queue of the
+	// shard was full, accessed atomically
+	dropped uint64
+	// revision of the last dropped pod change whose clients were stopped
+	droppedStopped uint64
+}
+
+// fanOutEvent is either a pod change to fan out to the clients of a shard,
+// or a heartbeat to send to the idle clients of the shard.
+type fanOutEvent struct {
+	entry     *podHistoryEntry
+	heartbeat bool
+}
+
+// RespoolSet is the set of the ids of the resource pools selected by the
+// resource pool path of a pod filter, the resource pool and all of its
+// descendants. The set of a watch is resolved again while the watch runs,
+// so that it follows the changes of the resource pools. A nil set selects
+// the pods of all the resource pools.
+type RespoolSet struct {
+	sync.RWMutex
+	ids map[string]bool
+}
+
+// NewRespoolSet returns the set of the resource pools with the ids.
+func NewRespoolSet(respoolIDs ...string) *RespoolSet {
+	ids := make(map[string]bool)
+	for _, respoolID := range respoolIDs {
+		ids[respoolID] = true
+	}
+	return &RespoolSet{ids: ids}
+}
+
+// Match returns true if the pods of the jobs in the resource pool are
+// selected by the set.
+func (s *RespoolSet) Match(respoolID string) bool {
+	if s == nil {
+		return true
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+	return s.ids[respoolID]
+}
+
+// update replaces the resource pools of the set with the ones of other.
+func (s *RespoolSet) update(other *RespoolSet) {
+	other.RLock()
+	ids := other.ids
+	other.RUnlock()
+
+	s.Lock()
+	defer s.Unlock()
+	s.ids = ids
+}
+
 // TaskClient represents a client which interested in task event changes.
 type TaskClient struct {
 	Filter *watch.PodFilter
-	Input  chan *pod.PodSummary
-	Signal chan StopSignal
+	// Resource pools of the pods sent to the client, as resolved from
+	// the filter, the pods of all the resource pools are sent if nil
+	Respools *RespoolSet
+	// Paths of the fields of the pods sent to the client, all the
+	// fields are sent if empty
+	FieldMask []string
+	// Revision after which the changes are sent to the client
+	Revision uint64
+	Input    chan *PodChange
+	Signal   chan StopSignal
+
+	// coalesce is set if the changes of a pod are coalesced, in which
+	// case the changes are held in pending, by pod name, until they are
+	// flushed to Input at the end of the coalesce window
+	coalesce bool
+	pending  map[string]*PodChange
+
+	// revision of the processor when the client was added to its shard,
+	// the changes up to it are either in the backlog of the client or
+	// not selected by its filter
+	registeredRevision uint64
+
+	// active is set when a change is sent to the client, and reset by
+	// each heartbeat, the client is sent a heartbeat if it was not set
+	// since the previous one
+	active bool
+
+	// number of changes selected by the filter of the client, and number
+	// of them streamed back to the client, accessed atomically as the
+	// latter is set by the stream of the watch. The changes replaced by
+	// a newer change of the same pod when coalescing are not counted.
+	selected   uint64
+	sent       uint64
+	createTime time.Time
+}
+
+// MarkSent records that the change was streamed back to the client, so
+// that the lag of the client can be reported.
+func (c *TaskClient) MarkSent(change *PodChange) {
+	if change.Pod != nil {
+		atomic.AddUint64(&c.sent, 1)
+	}
+}
+
+// lag returns the number of changes selected by the filter of the client
+// which were not streamed back to the client yet.
+func (c *TaskClient) lag() uint64 {
+	selected := atomic.LoadUint64(&c.selected)
+	sent := atomic.LoadUint64(&c.sent)
+	if sent > selected {
+		return 0
+	}
+	return selected - sent
+}
+
+// PodChange is a change of a pod sent to a task watch client, along with
+// its revision. A client reconnecting with the revision of the last change
+// it received resumes its watch from the next change. A change without pod
+// is a heartbeat, sent to a client which received no change for a
+// heartbeat interval, its revision is the revision the client is up to.
+type PodChange struct {
+	Revision uint64
+	Pod      *pod.PodSummary
+}
+
+// podHistoryEntry is a pod change kept in the history of the processor.
+type podHistoryEntry struct {
+	change    *PodChange
+	jobID     string
+	labels    []*peloton.Label
+	respoolID string
 }
 
 // JobClient represents a client which interested in job event changes.
@@ -112,29 +382,87 @@ type JobClient struct {
 	Signal chan StopSignal
 }
 
+// WorkflowClient represents a client which interested in the changes of
+// the workflows of jobs.
+type WorkflowClient struct {
+	Filter *watch.WorkflowFilter
+	Input  chan *WorkflowChange
+	Signal chan StopSignal
+
+	createTime time.Time
+}
+
+// WorkflowChange is a change of a workflow sent to a workflow watch
+// client, along with the revision of the last pod change received by
+// the processor before it.
+type WorkflowChange struct {
+	Revision uint64
+	Workflow *watch.WorkflowChange
+}
+
+// FirehoseClient represents a client which interested in the task event
+// changes of all the jobs in a shard.
+type FirehoseClient struct {
+	Filter *watch.FirehoseFilter
+	Input  chan *pod.PodSummary
+	Signal chan StopSignal
+
+	createTime time.Time
+}
+
 // newWatchProcessor should only be used in unit tests.
 // Call InitWatchProcessor for regular case use.
 func newWatchProcessor(
 	cfg Config,
+	journalStore ormobjects.WatchJournalOps,
 	parent tally.Scope,
 ) *watchProcessor {
 	cfg.normalize()
-	return &watchProcessor{
-		bufferSize:  cfg.BufferSize,
-		maxClient:   cfg.MaxClient,
-		taskClients: make(map[string]*TaskClient),
-		jobClients:  make(map[string]*JobClient),
-		metrics:     NewMetrics(parent),
+	revision := uint64(time.Now().UnixNano())
+	metrics := NewMetrics(parent)
+	p := &watchProcessor{
+		bufferSize: cfg.BufferSize,
+		maxClient:  cfg.MaxClient,
+		jobClients: make(map[string]*JobClient),
+		metrics:    metrics,
+
+		taskShards: make([]*taskShard, cfg.FanOutShards),
+
+		workflowClients: make(map[string]*WorkflowClient),
+
+		revision:     revision,
+		initRevision: revision,
+		history:      make([]*podHistoryEntry, cfg.HistorySize),
+
+		coalesceWindow: cfg.CoalesceWindow,
+		journal:        newJournal(cfg.Journal, revision, journalStore, metrics),
+
+		firehoseBufferSize: cfg.Firehose.BufferSize,
+		firehoseMaxClient:  cfg.Firehose.MaxClient,
+		firehoseClients:    make(map[string]*FirehoseClient),
 	}
+
+	for i := range p.taskShards {
+		p.taskShards[i] = &taskShard{
+			clients:  make(map[string]*TaskClient),
+			events:   make(chan *fanOutEvent, cfg.FanOutQueueSize),
+			revision: revision,
+		}
+		go p.fanOut(p.taskShards[i])
+	}
+	go p.heartbeat(cfg.HeartbeatInterval)
+	return p
 }
 
-// InitWatchProcessor initializes WatchProcessor singleton.
+// InitWatchProcessor initializes WatchProcessor singleton. The journal
+// of the processor is written to journalStore, unless it is nil.
 func InitWatchProcessor(
 	cfg Config,
+	journalStore ormobjects.WatchJournalOps,
 	parent tally.Scope,
 ) {
 	onceInitWatchProcessor.Do(func() {
-		processor = newWatchProcessor(cfg, parent)
+		processor = newWatchProcessor(cfg, journalStore, parent)
 	})
 }
 
@@ -149,47 +477,135 @@ func NewWatchID(clientType ClientType) string {
 	return fmt.Sprintf("%s_%s", clientType, uuid.New())
 }
 
-// NewTaskClient creates a new watch client for task event changes.
+// NewTaskClient creates a new watch client for the changes of the
+// tasks selected by the filter. If startRevision is not zero, the
+// changes received after startRevision which are still in the history
+// of the processor are sent to the client first. If coalesce is set,
+// the consecutive changes of a pod within the coalesce window are sent
+// as a single change with the latest state of the pod. If fieldMask
+// is not empty, only the fields of the pods in the field mask are sent.
+// If respools is not nil, only the pods of the jobs in the resource pools
+// of the set are sent.
 // Returns the watch id and a new instance of TaskClient.
-func (p *watchProcessor) NewTaskClient() (string, *TaskClient, error) {
+func (p *watchProcessor) NewTaskClient(
+	filter *watch.PodFilter,
+	respools *RespoolSet,
+	startRevision uint64,
+	coalesce bool,
+	fieldMask []string,
+) (string, *TaskClient, error) {
 	sw := p.metrics.ProcessorLockDuration.Start()
 	p.Lock()
 	defer p.Unlock()
 	sw.Stop()
 
-	if len(p.taskClients) >= p.maxClient {
+	if p.numTaskClients() >= p.maxClient {
 		return "", nil, yarpcerrors.ResourceExhaustedErrorf("max client reached")
 	}
 
+	revision := p.revision
+	var backlog []*PodChange
+	if startRevision != 0 {
+		// the revision is newer after the processor is reset, the client
+		// needs to resync
+		if startRevision > p.revision {
+			p.metrics.WatchPodResumeOutOfRange.Inc(1)
+			return "", nil, yarpcerrors.OutOfRangeErrorf(
+				"start revision %d is newer than server revision %d",
+				startRevision, p.revision)
+		}
+		if startRevision < p.oldestRevision() {
+			p.metrics.WatchPodResumeOutOfRange.Inc(1)
+			return "", nil, yarpcerrors.OutOfRangeErrorf(
+				"start revision %d is too old", startRevision)
+		}
+
+		for r := startRevision + 1; r <= p.revision; r++ {
+			e := p.history[r%uint64(len(p.history))]
+			if matchPodFilter(filter, respools, e) {
+				backlog = append(backlog, maskPodChange(e.change, fieldMask))
+			}
+		}
+		revision = startRevision
+		p.metrics.WatchPodResume.Inc(1)
+	}
+
 	watchID := NewWatchID(ClientTypeTask)
-	p.taskClients[watchID] = &TaskClient{
-		Input: make(chan *pod.PodSummary, p.bufferSize),
+	c := &TaskClient{
+		Revision: revision,
+		// Make room for the changes since the start revision on top of
+		// the buffer of the client
+		Input: make(chan *PodChange, p.bufferSize+len(backlog)),
 		// Make buffer size 1 so that sender is not blocked when sending
 		// the Signal
-		Signal: make(chan StopSignal, 1),
-		Filter: nil, // TODO(kevinxu): filter is not implemented
+		Signal:     make(chan StopSignal, 1),
+		Filter:     filter,
+		Respools:   respools,
+		FieldMask:  fieldMask,
+		coalesce:   coalesce,
+		pending:    make(map[string]*PodChange),
+		createTime: time.Now(),
+
+		registeredRevision: p.revision,
+		active:             len(backlog) != 0,
+		selected:           uint64(len(backlog)),
+	}
+	for _, change := range backlog {
+		c.Input <- change
 	}
 
-	log.WithField("watch_id", watchID).Info("task watch client created")
-	return watchID, p.taskClients[watchID], nil
+	// the client is added to its shard under the processor lock, so that
+	// it receives every change queued after the registered revision
+	s := p.taskShardOf(watchID)
+	s.Lock()
+	s.clients[watchID] = c
+	s.Unlock()
+
+	log.WithFields(log.Fields{
+		"watch_id":       watchID,
+		"start_revision": startRevision,
+		"backlog":        len(backlog),
+		"coalesce":       coalesce,
+	}).Info("task watch client created")
+	return watchID, c, nil
 }
 
 // StopTaskClient stops a task watch client. Returns "not-found" error
 // if the corresponding watch client is not found.
 func (p *watchProcessor) StopTaskClient(watchID string) error {
+	s := p.taskShardOf(watchID)
 	sw := p.metrics.ProcessorLockDuration.Start()
-	p.Lock()
-	defer p.Unlock()
+	s.Lock()
+	defer s.Unlock()
 	sw.Stop()
 
-	return p.stopTaskClient(watchID, StopSignalCancel)
+	return s.stopClient(watchID, StopSignalCancel)
+}
+
+// taskShardOf returns the shard of the task client of the watch id.
+func (p *watchProcessor) taskShardOf(watchID string) *taskShard {
+	h := fnv.New32a()
+	h.Write([]byte(watchID))
+	return p.taskShards[h.Sum32()%uint32(len(p.taskShards))]
 }
 
-func (p *watchProcessor) stopTaskClient(
+// numTaskClients returns the number of task clients of all the shards.
+func (p *watchProcessor) numTaskClients() int {
+	n := 0
+	for _, s := range p.taskShards {
+		s.Lock()
+		n += len(s.clients)
+		s.Unlock()
+	}
+	return n
+}
+
+// stopClient stops a task client of the shard, the shard must be locked.
+func (s *taskShard) stopClient(
 	watchID string,
 	Signal StopSignal,
 ) error {
-	c, ok := p.taskClients[watchID]
+	c, ok := s.clients[watchID]
 	if !ok {
 		return yarpcerrors.NotFoundErrorf(
 			"watch_id %s not exist for task watch client", watchID)
@@ -201,26 +617,682 @@ func (p *watchProcessor) stopTaskClient(
 	}).Info("stopping task watch client")
 
 	c.Signal <- Signal
-	delete(p.taskClients, watchID)
+	delete(s.clients, watchID)
 
 	return nil
 }
 
-// NotifyTaskChange receives pod event, and notifies all the clients
+// NotifyTaskChange receives pod event of a job along with the labels of
+// the pod and the resource pool of the job, and notifies all the clients
 // which are interested in the pod.
-func (p *watchProcessor) NotifyTaskChange(pod *pod.PodSummary) {
+func (p *watchProcessor) NotifyTaskChange(
+	jobID string,
+	pod *pod.PodSummary,
+	labels []*peloton.Label,
+	respoolID string,
+) {
 	sw := p.metrics.ProcessorLockDuration.Start()
 	p.Lock()
 	defer p.Unlock()
 	sw.Stop()
 
-	for watchID, c := range p.taskClients {
+	p.revision++
+	entry := &podHistoryEntry{
+		change: &PodChange{
+			Revision: p.revision,
+			Pod:      pod,
+		},
+		jobID:     jobID,
+		labels:    labels,
+		respoolID: respoolID,
+	}
+	p.addToHistory(entry)
+	p.journal.add(entry, time.Now())
+
+	// the change is queued under the processor lock, so that every shard
+	// receives the changes in the order of their revisions. The change is
+	// dropped rather than blocking on a shard whose queue is full, and the
+	// clients of the shard which miss it are stopped by its fan out.
+	e := &fanOutEvent{entry: entry}
+	for _, s := range p.taskShards {
+		select {
+		case s.events <- e:
+		default:
+			atomic.StoreUint64(&s.dropped, p.revision)
+			p.metrics.WatchPodFanOutDropped.Inc(1)
+		}
+	}
+}
+
+// fanOut sends the pod changes queued to the shard to the clients of the
+// shard selected by their filters.
+func (p *watchProcessor) fanOut(s *taskShard) {
+	for e := range s.events {
+		if e.heartbeat {
+			p.sendHeartbeats(s)
+			continue
+		}
+
+		entry := e.entry
+		// the change is masked once per field mask, as the clients
+		// watching large pods tend to share the same field mask
+		masked := make(map[string]*PodChange)
+		s.Lock()
+		s.stopDroppedClients()
+		s.revision = entry.change.Revision
+		for watchID, c := range s.clients {
+			if entry.change.Revision <= c.registeredRevision {
+				continue
+			}
+			if !matchPodFilter(c.Filter, c.Respools, entry) {
+				continue
+			}
+
+			key := strings.Join(c.FieldMask, ",")
+			change, ok := masked[key]
+			if !ok {
+				change = maskPodChange(entry.change, c.FieldMask)
+				masked[key] = change
+			}
+
+			if c.coalesce {
+				p.addPendingChange(watchID, c, change)
+				continue
+			}
+			atomic.AddUint64(&c.selected, 1)
+			s.sendChange(watchID, c, change)
+		}
+		s.Unlock()
+	}
+}
+
+// stopDroppedClients stops the clients of the shard which missed a pod
+// change dropped because the queue of the shard was full, so that they
+// watch again from the last revision they received. The shard must be
+// locked.
+func (s *taskShard) stopDroppedClients() {
+	dropped := atomic.LoadUint64(&s.dropped)
+	if dropped <= s.droppedStopped {
+		return
+	}
+	for watchID, c := range s.clients {
+		if c.registeredRevision < dropped {
+			log.WithFields(log.Fields{
+				"watch_id": watchID,
+				"revision": dropped,
+			}).Warn("pod change dropped for task watch client")
+			s.stopClient(watchID, StopSignalOverflow)
+		}
+	}
+	s.droppedStopped = dropped
+}
+
+// sendChange sends the change to a task client of the shard, and stops
+// the client if its buffer is full. Returns false if the client was
+// stopped. The shard must be locked.
+func (s *taskShard) sendChange(
+	watchID string,
+	c *TaskClient,
+	change *PodChange,
+) bool {
+	select {
+	case c.Input <- change:
+		c.active = true
+		return true
+	default:
+		log.WithField("watch_id", watchID).
+			Warn("event overflow for task watch client")
+		s.stopClient(watchID, StopSignalOverflow)
+		return false
+	}
+}
+
+// heartbeat periodically queues a heartbeat to every shard, after the pod
+// changes queued so far.
+func (p *watchProcessor) heartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		p.queueHeartbeats()
+	}
+}
+
+// queueHeartbeats queues a heartbeat to every shard. The heartbeat is
+// dropped for a shard whose queue is full, as its clients are not idle.
+func (p *watchProcessor) queueHeartbeats() {
+	for _, s := range p.taskShards {
+		select {
+		case s.events <- &fanOutEvent{heartbeat: true}:
+		default:
+		}
+	}
+}
+
+// sendHeartbeats sends a heartbeat to the clients of the shard which
+// received no change since the previous heartbeat. The heartbeat is
+// dropped rather than stopping a client with a full buffer, as the
+// client is not idle then.
+func (p *watchProcessor) sendHeartbeats(s *taskShard) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.stopDroppedClients()
+
+	for _, c := range s.clients {
+		// the changes pending for a coalescing client are older than
+		// the revision of the shard, they are sent at the end of the
+		// coalesce window
+		if c.active || len(c.pending) != 0 {
+			c.active = false
+			continue
+		}
+
+		// the changes of the backlog of a client are sent before the
+		// changes fanned out by the shard
+		revision := s.revision
+		if c.registeredRevision > revision {
+			revision = c.registeredRevision
+		}
+
+		select {
+		case c.Input <- &PodChange{Revision: revision}:
+			p.metrics.WatchPodHeartbeat.Inc(1)
+		default:
+		}
+	}
+}
+
+// addPendingChange holds the change for a coalescing client, replacing
+// the pending change of the same pod if any. The pending changes of the
+// client are flushed at the end of the coalesce window, which starts
+// with the first change pending.
+func (p *watchProcessor) addPendingChange(
+	watchID string,
+	c *TaskClient,
+	change *PodChange,
+) {
+	if len(c.pending) == 0 {
+		time.AfterFunc(p.coalesceWindow, func() {
+			p.flushPendingChanges(watchID)
+		})
+	}
+
+	podName := change.Pod.GetPodName().GetValue()
+	if _, ok := c.pending[podName]; ok {
+		p.metrics.WatchPodCoalesced.Inc(1)
+	} else {
+		atomic.AddUint64(&c.selected, 1)
+	}
+	c.pending[podName] = change
+}
+
+// flushPendingChanges sends the pending changes of a coalescing client,
+// in the order of their revisions.
+func (p *watchProcessor) flushPendingChanges(watchID string) {
+	s := p.taskShardOf(watchID)
+	sw := p.metrics.ProcessorLockDuration.Start()
+	s.Lock()
+	defer s.Unlock()
+	sw.Stop()
+
+	c, ok := s.clients[watchID]
+	if !ok || len(c.pending) == 0 {
+		return
+	}
+
+	changes := make([]*PodChange, 0, len(c.pending))
+	for _, change := range c.pending {
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Revision < changes[j].Revision
+	})
+	c.pending = make(map[string]*PodChange)
+
+	for _, change := range changes {
+		if !s.sendChange(watchID, c, change) {
+			return
+		}
+	}
+}
+
+// addToHistory adds the change at p.revision to the history, evicting
+// the oldest change if the history is full.
+func (p *watchProcessor) addToHistory(e *podHistoryEntry) {
+	p.history[p.revision%uint64(len(p.history))] = e
+}
+
+// oldestRevision returns the oldest revision a client can resume its
+// watch from, i.e. the history holds all the changes after it.
+func (p *watchProcessor) oldestRevision() uint64 {
+	if p.revision-p.initRevision < uint64(len(p.history)) {
+		return p.initRevision
+	}
+	return p.revision - uint64(len(p.history))
+}
+
+// Reset re-seeds the revision of the processor with the current time,
+// so that the revisions keep increasing across the leaders, and drops the
+// history and the journal, which may miss the changes made while the job
+// manager was not the leader. The task clients are stopped, so that they
+// watch again from the revision of the last change they received, and
+// resync since it is out of the range of the processor.
+func (p *watchProcessor) Reset() {
+	sw := p.metrics.ProcessorLockDuration.Start()
+	p.Lock()
+	defer p.Unlock()
+	sw.Stop()
+
+	revision := uint64(time.Now().UnixNano())
+	if revision <= p.revision {
+		revision = p.revision + 1
+	}
+	p.revision = revision
+	p.initRevision = revision
+	p.history = make([]*podHistoryEntry, len(p.history))
+	p.journal.reset(revision)
+
+	for _, s := range p.taskShards {
+		s.Lock()
+		for watchID := range s.clients {
+			s.stopClient(watchID, StopSignalOverflow)
+		}
+		s.revision = revision
+		s.Unlock()
+	}
+
+	log.WithField("revision", revision).Info("watch processor reset")
+}
+
+// Start starts writing the journal to its store.
+func (p *watchProcessor) Start() {
+	p.journal.start()
+	log.Info("watch processor started")
+}
+
+// Stop stops writing the journal to its store.
+func (p *watchProcessor) Stop() {
+	p.journal.stop()
+	log.Info("watch processor stopped")
+}
+
+// Replay returns the changes of the pods selected by the filter after
+// startRevision from the journal of the processor, at most limit of
+// them, along with the revision to continue from, and whether there are
+// more changes after it. If fieldMask is not empty, only the fields of
+// the pods in the field mask are returned. If respools is not nil, only
+// the changes of the pods of the jobs in the resource pools of the set
+// are returned.
+func (p *watchProcessor) Replay(
+	ctx context.Context,
+	filter *watch.PodFilter,
+	respools *RespoolSet,
+	startRevision uint64,
+	fieldMask []string,
+	limit int,
+) ([]*PodChange, uint64, bool, error) {
+	return p.journal.replay(
+		ctx, filter, respools, startRevision, fieldMask, limit)
+}
+
+// NewWorkflowClient creates a new watch client for the changes of the
+// workflows of the jobs selected by the filter.
+// Returns the watch id and a new instance of WorkflowClient.
+func (p *watchProcessor) NewWorkflowClient(
+	filter *watch.WorkflowFilter,
+) (string, *WorkflowClient, error) {
+	sw := p.metrics.ProcessorLockDuration.Start()
+	p.Lock()
+	defer p.Unlock()
+	sw.Stop()
+
+	if len(p.workflowClients) >= p.maxClient {
+		return "", nil, yarpcerrors.ResourceExhaustedErrorf("max client reached")
+	}
+
+	watchID := NewWatchID(ClientTypeWorkflow)
+	p.workflowClients[watchID] = &WorkflowClient{
+		Filter: filter,
+		Input:  make(chan *WorkflowChange, p.bufferSize),
+		// Make buffer size 1 so that sender is not blocked when sending
+		// the Signal
+		Signal:     make(chan StopSignal, 1),
+		createTime: time.Now(),
+	}
+
+	log.WithField("watch_id", watchID).Info("workflow watch client created")
+	return watchID, p.workflowClients[watchID], nil
+}
+
+// StopWorkflowClient stops a workflow watch client. Returns "not-found"
+// error if the corresponding watch client is not found.
+func (p *watchProcessor) StopWorkflowClient(watchID string) error {
+	sw := p.metrics.ProcessorLockDuration.Start()
+	p.Lock()
+	defer p.Unlock()
+	sw.Stop()
+
+	return p.stopWorkflowClient(watchID, StopSignalCancel)
+}
+
+func (p *watchProcessor) stopWorkflowClient(
+	watchID string,
+	signal StopSignal,
+) error {
+	c, ok := p.workflowClients[watchID]
+	if !ok {
+		return yarpcerrors.NotFoundErrorf(
+			"watch_id %s not exist for workflow watch client", watchID)
+	}
+
+	log.WithFields(log.Fields{
+		"watch_id": watchID,
+		"signal":   signal,
+	}).Info("stopping workflow watch client")
+
+	c.Signal <- signal
+	delete(p.workflowClients, watchID)
+
+	return nil
+}
+
+// NotifyWorkflowChange receives the change of a workflow of a job, and
+// notifies all the clients which are interested in the job.
+func (p *watchProcessor) NotifyWorkflowChange(change *watch.WorkflowChange) {
+	sw := p.metrics.ProcessorLockDuration.Start()
+	p.Lock()
+	defer p.Unlock()
+	sw.Stop()
+
+	// workflow changes are not kept in the history, so they do not
+	// have a revision of their own
+	c := &WorkflowChange{
+		Revision: p.revision,
+		Workflow: change,
+	}
+	for watchID, wc := range p.workflowClients {
+		if !MatchWorkflowJob(wc.Filter, change.GetJobId().GetValue()) {
+			continue
+		}
+
+		select {
+		case wc.Input <- c:
+		default:
+			log.WithField("watch_id", watchID).
+				Warn("event overflow for workflow watch client")
+			p.stopWorkflowClient(watchID, StopSignalOverflow)
+		}
+	}
+}
+
+// NewFirehoseClient creates a new firehose client for the pod events
+// of all the jobs in the shard of the filter.
+// Returns the watch id and a new instance of FirehoseClient.
+func (p *watchProcessor) NewFirehoseClient(
+	filter *watch.FirehoseFilter,
+) (string, *FirehoseClient, error) {
+	sw := p.metrics.ProcessorLockDuration.Start()
+	p.firehoseLock.Lock()
+	defer p.firehoseLock.Unlock()
+	sw.Stop()
+
+	if len(p.firehoseClients) >= p.firehoseMaxClient {
+		return "", nil, yarpcerrors.ResourceExhaustedErrorf("max client reached")
+	}
+
+	watchID := NewWatchID(ClientTypeFirehose)
+	p.firehoseClients[watchID] = &FirehoseClient{
+		Filter: filter,
+		Input:  make(chan *pod.PodSummary, p.firehoseBufferSize),
+		// Make buffer size 1 so that sender is not blocked when sending
+		// the Signal
+		Signal:     make(chan StopSignal, 1),
+		createTime: time.Now(),
+	}
+
+	log.WithField("watch_id", watchID).Info("firehose client created")
+	return watchID, p.firehoseClients[watchID], nil
+}
+
+// StopFirehoseClient stops a firehose client. Returns "not-found" error
+// if the corresponding firehose client is not found.
+func (p *watchProcessor) StopFirehoseClient(watchID string) error {
+	sw := p.metrics.ProcessorLockDuration.Start()
+	p.firehoseLock.Lock()
+	defer p.firehoseLock.Unlock()
+	sw.Stop()
+
+	return p.stopFirehoseClient(watchID, StopSignalCancel)
+}
+
+func (p *watchProcessor) stopFirehoseClient(
+	watchID string,
+	signal StopSignal,
+) error {
+	c, ok := p.firehoseClients[watchID]
+	if !ok {
+		return yarpcerrors.NotFoundErrorf(
+			"watch_id %s not exist for firehose client", watchID)
+	}
+
+	log.WithFields(log.Fields{
+		"watch_id": watchID,
+		"signal":   signal,
+	}).Info("stopping firehose client")
+
+	c.Signal <- signal
+	delete(p.firehoseClients, watchID)
+
+	return nil
+}
+
+// NotifyFirehoseTaskChange receives pod event of a job of any type,
+// and notifies all the firehose clients of the shard of the job.
+func (p *watchProcessor) NotifyFirehoseTaskChange(
+	jobID string,
+	pod *pod.PodSummary,
+) {
+	sw := p.metrics.ProcessorLockDuration.Start()
+	p.firehoseLock.Lock()
+	defer p.firehoseLock.Unlock()
+	sw.Stop()
+
+	for watchID, c := range p.firehoseClients {
+		if !InFirehoseShard(jobID, c.Filter) {
+			continue
+		}
+
 		select {
 		case c.Input <- pod:
 		default:
 			log.WithField("watch_id", watchID).
-				Warn("event overflow for task watch client")
-			p.stopTaskClient(watchID, StopSignalOverflow)
+				Warn("event overflow for firehose client")
+			p.stopFirehoseClient(watchID, StopSignalOverflow)
 		}
 	}
 }
+
+// ListClients returns the watch and firehose clients, sorted by watch id.
+func (p *watchProcessor) ListClients() []*watch.WatcherInfo {
+	var watchers []*watch.WatcherInfo
+
+	sw := p.metrics.ProcessorLockDuration.Start()
+	p.Lock()
+	sw.Stop()
+	for _, s := range p.taskShards {
+		s.Lock()
+		for watchID, c := range s.clients {
+			watchers = append(watchers, &watch.WatcherInfo{
+				WatchId:    watchID,
+				Type:       ClientTypeTask.String(),
+				PodFilter:  c.Filter,
+				Coalesce:   c.coalesce,
+				Buffered:   uint32(len(c.Input) + len(c.pending)),
+				BufferSize: uint32(cap(c.Input)),
+				Lag:        c.lag(),
+				CreateTime: c.createTime.UTC().Format(time.RFC3339),
+			})
+		}
+		s.Unlock()
+	}
+	for watchID, c := range p.workflowClients {
+		watchers = append(watchers, &watch.WatcherInfo{
+			WatchId:        watchID,
+			Type:           ClientTypeWorkflow.String(),
+			WorkflowFilter: c.Filter,
+			Buffered:       uint32(len(c.Input)),
+			BufferSize:     uint32(cap(c.Input)),
+			CreateTime:     c.createTime.UTC().Format(time.RFC3339),
+		})
+	}
+	p.Unlock()
+
+	sw = p.metrics.ProcessorLockDuration.Start()
+	p.firehoseLock.Lock()
+	sw.Stop()
+	for watchID, c := range p.firehoseClients {
+		watchers = append(watchers, &watch.WatcherInfo{
+			WatchId:        watchID,
+			Type:           ClientTypeFirehose.String(),
+			FirehoseFilter: c.Filter,
+			Buffered:       uint32(len(c.Input)),
+			BufferSize:     uint32(cap(c.Input)),
+			CreateTime:     c.createTime.UTC().Format(time.RFC3339),
+		})
+	}
+	p.firehoseLock.Unlock()
+
+	sort.Slice(watchers, func(i, j int) bool {
+		return watchers[i].GetWatchId() < watchers[j].GetWatchId()
+	})
+	return watchers
+}
+
+// CloseClient force-closes a watch or firehose client of any type.
+// Returns "not-found" error if the client is not found.
+func (p *watchProcessor) CloseClient(watchID string) error {
+	switch {
+	case strings.HasPrefix(watchID, ClientTypeTask.String()):
+		s := p.taskShardOf(watchID)
+		sw := p.metrics.ProcessorLockDuration.Start()
+		s.Lock()
+		defer s.Unlock()
+		sw.Stop()
+		return s.stopClient(watchID, StopSignalClose)
+	case strings.HasPrefix(watchID, ClientTypeWorkflow.String()):
+		sw := p.metrics.ProcessorLockDuration.Start()
+		p.Lock()
+		defer p.Unlock()
+		sw.Stop()
+		return p.stopWorkflowClient(watchID, StopSignalClose)
+	case strings.HasPrefix(watchID, ClientTypeFirehose.String()):
+		sw := p.metrics.ProcessorLockDuration.Start()
+		p.firehoseLock.Lock()
+		defer p.firehoseLock.Unlock()
+		sw.Stop()
+		return p.stopFirehoseClient(watchID, StopSignalClose)
+	}
+	return yarpcerrors.NotFoundErrorf("invalid watch id %s", watchID)
+}
+
+// matchPodFilter returns true if the change of a pod of the entry is
+// selected by the filter of a task watch client, and by the resource
+// pools resolved from it.
+func matchPodFilter(
+	filter *watch.PodFilter,
+	respools *RespoolSet,
+	e *podHistoryEntry,
+) bool {
+	return MatchJob(filter, e.jobID) &&
+		MatchPodName(filter, e.change.Pod.GetPodName().GetValue()) &&
+		MatchLabels(filter.GetLabels(), e.labels) &&
+		respools.Match(e.respoolID)
+}
+
+// MatchJob returns true if the pods of the job are selected by the job ids
+// of a filter. The pods of all the jobs are selected if the filter has no
+// job ids.
+func MatchJob(filter *watch.PodFilter, jobID string) bool {
+	if filter.GetJobId().GetValue() == "" && len(filter.GetJobIds()) == 0 {
+		return true
+	}
+
+	if filter.GetJobId().GetValue() == jobID {
+		return true
+	}
+	for _, id := range filter.GetJobIds() {
+		if id.GetValue() == jobID {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPodName returns true if the pod is selected by the pod names or
+// the pod name prefix of a filter. All the pods are selected if the
+// filter has neither pod names nor a pod name prefix.
+func MatchPodName(filter *watch.PodFilter, podName string) bool {
+	prefix := filter.GetPodNamePrefix()
+	if len(filter.GetPodNames()) == 0 && prefix == "" {
+		return true
+	}
+
+	if prefix != "" && strings.HasPrefix(podName, prefix) {
+		return true
+	}
+	for _, name := range filter.GetPodNames() {
+		if name.GetValue() == podName {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchLabels returns true if the labels of a pod contain all the labels
+// selected by a filter. A pod is always selected if the filter has no
+// labels.
+func MatchLabels(selector []*peloton.Label, labels []*peloton.Label) bool {
+	for _, s := range selector {
+		found := false
+		for _, l := range labels {
+			if l.GetKey() == s.GetKey() && l.GetValue() == s.GetValue() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchWorkflowJob returns true if the workflows of the job are selected
+// by a workflow filter. The workflows of all the jobs are selected if the
+// filter has no job ids.
+func MatchWorkflowJob(filter *watch.WorkflowFilter, jobID string) bool {
+	if len(filter.GetJobIds()) == 0 {
+		return true
+	}
+
+	for _, id := range filter.GetJobIds() {
+		if id.GetValue() == jobID {
+			return true
+		}
+	}
+	return false
+}
+
+// InFirehoseShard returns true if the pods of the job are streamed on
+// the shard of the firehose filter. Jobs are assigned to shards by the
+// FNV-1a hash of their job id.
+func InFirehoseShard(jobID string, filter *watch.FirehoseFilter) bool {
+	if filter.GetNumShards() <= 1 {
+		return true
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(jobID))
+	return h.Sum32()%filter.GetNumShards() == filter.GetShard()
+}
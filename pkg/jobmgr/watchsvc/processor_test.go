@@ -16,11 +16,14 @@ package watchsvc
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/peloton"
 	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/watch"
 
 	"github.com/stretchr/testify/suite"
 	"github.com/uber-go/tally"
@@ -43,26 +46,70 @@ func (suite *WatchProcessorTestSuite) SetupTest() {
 	suite.testScope = tally.NewTestScope("", map[string]string{})
 
 	suite.config = Config{
-		BufferSize: 10,
-		MaxClient:  2,
+		BufferSize:  10,
+		MaxClient:   2,
+		HistorySize: 3,
+		// the pending changes are flushed explicitly by the tests
+		CoalesceWindow: time.Hour,
+		// the heartbeats are queued explicitly by the tests
+		HeartbeatInterval: time.Hour,
+		Firehose: FirehoseConfig{
+			BufferSize: 10,
+			MaxClient:  2,
+		},
 	}
-	suite.processor = newWatchProcessor(suite.config, suite.testScope)
+	suite.processor = newWatchProcessor(suite.config, nil, suite.testScope)
 }
 
 func TestWatchProcessor(t *testing.T) {
 	suite.Run(t, &WatchProcessorTestSuite{})
 }
 
+// waitForFanOut waits for the pod changes notified so far to be fanned out
+// to the task clients of all the shards. A shard updates its revision and
+// sends the change to its clients under its lock.
+func waitForFanOut(p *watchProcessor) {
+	p.Lock()
+	revision := p.revision
+	p.Unlock()
+
+	for _, s := range p.taskShards {
+		for {
+			s.Lock()
+			done := s.revision >= revision
+			s.Unlock()
+			if done {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func (suite *WatchProcessorTestSuite) waitForFanOut() {
+	waitForFanOut(suite.processor.(*watchProcessor))
+}
+
+// sendHeartbeats sends a heartbeat to the idle task clients of all the
+// shards, once the pod changes notified so far are fanned out.
+func (suite *WatchProcessorTestSuite) sendHeartbeats() {
+	p := suite.processor.(*watchProcessor)
+	waitForFanOut(p)
+	for _, s := range p.taskShards {
+		p.sendHeartbeats(s)
+	}
+}
+
 // TestInitWatchProcessor tests initialization of WatchProcessor
 func (suite *WatchProcessorTestSuite) TestInitWatchProcessor() {
 	suite.Nil(GetWatchProcessor())
-	InitWatchProcessor(suite.config, suite.testScope)
+	InitWatchProcessor(suite.config, nil, suite.testScope)
 	suite.NotNil(GetWatchProcessor())
 }
 
 // TestTaskClient tests basic setup and teardown of task watch client
 func (suite *WatchProcessorTestSuite) TestTaskClient() {
-	watchID, c, err := suite.processor.NewTaskClient()
+	watchID, c, err := suite.processor.NewTaskClient(nil, nil, 0, false, nil)
 	suite.NoError(err)
 	suite.NotEmpty(watchID)
 	suite.NotNil(c)
@@ -92,7 +139,7 @@ func (suite *WatchProcessorTestSuite) TestTaskClient() {
 // TestTaskClient_StopNonexistentClient tests an error will be thrown if
 // tearing down a client with unknown watch id.
 func (suite *WatchProcessorTestSuite) TestTaskClient_StopNonexistentClient() {
-	watchID, c, err := suite.processor.NewTaskClient()
+	watchID, c, err := suite.processor.NewTaskClient(nil, nil, 0, false, nil)
 	suite.NoError(err)
 	suite.NotEmpty(watchID)
 	suite.NotNil(c)
@@ -106,7 +153,7 @@ func (suite *WatchProcessorTestSuite) TestTaskClient_StopNonexistentClient() {
 // creating a new client if max number of clients is reached.
 func (suite *WatchProcessorTestSuite) TestTaskClient_MaxClientReached() {
 	for i := 0; i < 3; i++ {
-		watchID, c, err := suite.processor.NewTaskClient()
+		watchID, c, err := suite.processor.NewTaskClient(nil, nil, 0, false, nil)
 		if i < 2 {
 			suite.NoError(err)
 			suite.NotEmpty(watchID)
@@ -122,7 +169,7 @@ func (suite *WatchProcessorTestSuite) TestTaskClient_MaxClientReached() {
 // sent to the client and the client will be closed if the client buffer is
 // overflown.
 func (suite *WatchProcessorTestSuite) TestTaskClient_EventOverflow() {
-	watchID, c, err := suite.processor.NewTaskClient()
+	watchID, c, err := suite.processor.NewTaskClient(nil, nil, 0, false, nil)
 	suite.NoError(err)
 	suite.NotEmpty(watchID)
 	suite.NotNil(c)
@@ -143,13 +190,764 @@ func (suite *WatchProcessorTestSuite) TestTaskClient_EventOverflow() {
 
 	// send number of events equal to buffer size
 	for i := 0; i < 10; i++ {
-		suite.processor.NotifyTaskChange(&pod.PodSummary{})
+		suite.processor.NotifyTaskChange("", &pod.PodSummary{}, nil, "")
 	}
 	time.Sleep(1 * time.Second)
 	suite.Equal(StopSignalUnknown, stopSignal)
 
 	// trigger buffer overflow
-	suite.processor.NotifyTaskChange(&pod.PodSummary{})
+	suite.processor.NotifyTaskChange("", &pod.PodSummary{}, nil, "")
 	wg.Wait()
 	suite.Equal(StopSignalOverflow, stopSignal)
 }
+
+// TestTaskClient_LabelFilter tests that only the events of the pods
+// with the labels of the filter are sent to the client.
+func (suite *WatchProcessorTestSuite) TestTaskClient_LabelFilter() {
+	watchID, c, err := suite.processor.NewTaskClient(&watch.PodFilter{
+		Labels: []*peloton.Label{
+			{Key: "app", Value: "web"},
+			{Key: "env", Value: "prod"},
+		},
+	}, nil, 0, false, nil)
+	suite.NoError(err)
+	suite.NotEmpty(watchID)
+
+	// a task watch client without filter receives all the events
+	_, all, err := suite.processor.NewTaskClient(nil, nil, 0, false, nil)
+	suite.NoError(err)
+
+	suite.processor.NotifyTaskChange("", &pod.PodSummary{}, nil, "")
+	suite.processor.NotifyTaskChange("", &pod.PodSummary{}, []*peloton.Label{
+		{Key: "app", Value: "web"},
+		{Key: "env", Value: "staging"},
+	}, "")
+	suite.waitForFanOut()
+	suite.Len(c.Input, 0)
+	suite.Len(all.Input, 2)
+
+	suite.processor.NotifyTaskChange("", &pod.PodSummary{}, []*peloton.Label{
+		{Key: "env", Value: "prod"},
+		{Key: "team", Value: "infra"},
+		{Key: "app", Value: "web"},
+	}, "")
+	suite.waitForFanOut()
+	suite.Len(c.Input, 1)
+	suite.Len(all.Input, 3)
+}
+
+// TestTaskClient_JobAndPodNameFilter tests that only the events of the
+// pods selected by the job ids and pod names of the filter are sent to
+// the client.
+func (suite *WatchProcessorTestSuite) TestTaskClient_JobAndPodNameFilter() {
+	_, c, err := suite.processor.NewTaskClient(&watch.PodFilter{
+		JobId:         &peloton.JobID{Value: "job-1"},
+		PodNames:      []*peloton.PodName{{Value: "job-1-0"}},
+		PodNamePrefix: "job-2-1",
+		JobIds:        []*peloton.JobID{{Value: "job-2"}},
+	}, nil, 0, false, nil)
+	suite.NoError(err)
+
+	notify := func(jobID string, podName string) {
+		suite.processor.NotifyTaskChange(jobID, &pod.PodSummary{
+			PodName: &peloton.PodName{Value: podName},
+		}, nil, "")
+	}
+
+	notify("job-1", "job-1-0")
+	notify("job-1", "job-1-1")
+	notify("job-2", "job-2-1")
+	notify("job-2", "job-2-12")
+	notify("job-2", "job-2-2")
+	notify("job-3", "job-3-0")
+	suite.waitForFanOut()
+	suite.Len(c.Input, 3)
+	for _, podName := range []string{"job-1-0", "job-2-1", "job-2-12"} {
+		suite.Equal(podName, (<-c.Input).Pod.GetPodName().GetValue())
+	}
+}
+
+// TestTaskClient_RespoolFilter tests that only the events of the pods of
+// the jobs in the resource pools of a client are sent to the client, and
+// to the client resuming its watch.
+func (suite *WatchProcessorTestSuite) TestTaskClient_RespoolFilter() {
+	respools := NewRespoolSet("respool-1", "respool-1-child")
+	_, c, err := suite.processor.NewTaskClient(nil, respools, 0, false, nil)
+	suite.NoError(err)
+	start := c.Revision
+
+	for _, respoolID := range []string{
+		"respool-1", "respool-2", "respool-1-child", ""} {
+		suite.processor.NotifyTaskChange(
+			"job-"+respoolID, &pod.PodSummary{}, nil, respoolID)
+	}
+	suite.waitForFanOut()
+	suite.Len(c.Input, 2)
+	suite.Equal(start+1, (<-c.Input).Revision)
+	suite.Equal(start+3, (<-c.Input).Revision)
+
+	_, resumed, err := suite.processor.NewTaskClient(
+		nil, NewRespoolSet("respool-2"), start+1, false, nil)
+	suite.NoError(err)
+	suite.Len(resumed.Input, 1)
+	suite.Equal(start+2, (<-resumed.Input).Revision)
+}
+
+// TestTaskClient_Resume tests that a client resuming its watch from a
+// revision first receives the changes after that revision which match
+// its filter.
+func (suite *WatchProcessorTestSuite) TestTaskClient_Resume() {
+	notify := func(podName string) {
+		suite.processor.NotifyTaskChange("job-1", &pod.PodSummary{
+			PodName: &peloton.PodName{Value: podName},
+		}, nil, "")
+	}
+
+	watchID, c, err := suite.processor.NewTaskClient(nil, nil, 0, false, nil)
+	suite.NoError(err)
+	start := c.Revision
+
+	notify("job-1-0")
+	notify("job-1-1")
+	notify("job-1-0")
+	suite.waitForFanOut()
+	suite.Len(c.Input, 3)
+	first := <-c.Input
+	suite.Equal(start+1, first.Revision)
+	suite.NoError(suite.processor.StopTaskClient(watchID))
+
+	// resume after the first change, only the changes of the
+	// pods selected by the filter are sent
+	_, c, err = suite.processor.NewTaskClient(&watch.PodFilter{
+		PodNames: []*peloton.PodName{{Value: "job-1-0"}},
+	}, nil, first.Revision, false, nil)
+	suite.NoError(err)
+	suite.Equal(first.Revision, c.Revision)
+	suite.Len(c.Input, 1)
+	change := <-c.Input
+	suite.Equal(start+3, change.Revision)
+	suite.Equal("job-1-0", change.Pod.GetPodName().GetValue())
+
+	notify("job-1-0")
+	suite.waitForFanOut()
+	suite.Len(c.Input, 1)
+	suite.Equal(start+4, (<-c.Input).Revision)
+}
+
+// TestTaskClient_ResumeOutOfRange tests that a client cannot resume its
+// watch from a revision evicted from the history, or from a revision
+// newer than the revision of the processor.
+func (suite *WatchProcessorTestSuite) TestTaskClient_ResumeOutOfRange() {
+	_, c, err := suite.processor.NewTaskClient(nil, nil, 0, false, nil)
+	suite.NoError(err)
+	start := c.Revision
+
+	_, _, err = suite.processor.NewTaskClient(nil, nil, start-1, false, nil)
+	suite.True(yarpcerrors.IsOutOfRange(err))
+
+	for i := 0; i < 4; i++ {
+		suite.processor.NotifyTaskChange("", &pod.PodSummary{}, nil, "")
+	}
+
+	// only the last 3 changes are in the history
+	_, _, err = suite.processor.NewTaskClient(nil, nil, start, false, nil)
+	suite.True(yarpcerrors.IsOutOfRange(err))
+
+	_, _, err = suite.processor.NewTaskClient(nil, nil, start+5, false, nil)
+	suite.True(yarpcerrors.IsOutOfRange(err))
+
+	_, c, err = suite.processor.NewTaskClient(nil, nil, start+1, false, nil)
+	suite.NoError(err)
+	suite.Len(c.Input, 3)
+}
+
+// TestReset tests that resetting the processor re-seeds its revision,
+// drops its history and stops the task clients.
+func (suite *WatchProcessorTestSuite) TestReset() {
+	_, c, err := suite.processor.NewTaskClient(nil, nil, 0, false, nil)
+	suite.NoError(err)
+	suite.processor.NotifyTaskChange("", &pod.PodSummary{}, nil, "")
+	revision := suite.processor.revision
+
+	suite.processor.Reset()
+	suite.Equal(StopSignalOverflow, <-c.Signal)
+	suite.Equal(0, suite.processor.numTaskClients())
+	suite.True(suite.processor.revision > revision)
+
+	// the changes received before the reset are dropped
+	_, _, err = suite.processor.NewTaskClient(
+		nil, nil, revision-1, false, nil)
+	suite.True(yarpcerrors.IsOutOfRange(err))
+	_, _, _, err = suite.processor.Replay(
+		suite.ctx, nil, nil, revision-1, nil, 0)
+	suite.True(yarpcerrors.IsOutOfRange(err))
+
+	_, c, err = suite.processor.NewTaskClient(
+		nil, nil, suite.processor.revision, false, nil)
+	suite.NoError(err)
+	suite.Empty(c.Input)
+}
+
+// TestTaskClient_Coalesce tests the consecutive changes of a pod are sent
+// as a single change with the latest state to a coalescing client, in the
+// order of their revisions, while a regular client receives all of them.
+func (suite *WatchProcessorTestSuite) TestTaskClient_Coalesce() {
+	watchID, c, err := suite.processor.NewTaskClient(nil, nil, 0, true, nil)
+	suite.NoError(err)
+	_, all, err := suite.processor.NewTaskClient(nil, nil, 0, false, nil)
+	suite.NoError(err)
+
+	states := []pod.PodState{
+		pod.PodState_POD_STATE_STARTING,
+		pod.PodState_POD_STATE_RUNNING,
+		pod.PodState_POD_STATE_FAILED,
+	}
+	for _, state := range states {
+		suite.processor.NotifyTaskChange("job-1", &pod.PodSummary{
+			PodName: &peloton.PodName{Value: "job-1-0"},
+			Status:  &pod.PodStatus{State: state},
+		}, nil, "")
+	}
+	suite.processor.NotifyTaskChange("job-1", &pod.PodSummary{
+		PodName: &peloton.PodName{Value: "job-1-1"},
+	}, nil, "")
+	suite.waitForFanOut()
+	suite.Len(all.Input, 4)
+	suite.Len(c.Input, 0)
+
+	suite.processor.(*watchProcessor).flushPendingChanges(watchID)
+	suite.Len(c.Input, 2)
+	first := <-c.Input
+	second := <-c.Input
+	suite.Equal("job-1-0", first.Pod.GetPodName().GetValue())
+	suite.Equal(pod.PodState_POD_STATE_FAILED, first.Pod.GetStatus().GetState())
+	suite.Equal("job-1-1", second.Pod.GetPodName().GetValue())
+	suite.True(first.Revision < second.Revision)
+	suite.Equal(int64(2), suite.testScope.Snapshot().
+		Counters()["watch.watch_pod_coalesced+"].Value())
+
+	// nothing is pending after a flush
+	suite.processor.(*watchProcessor).flushPendingChanges(watchID)
+	suite.Len(c.Input, 0)
+}
+
+// TestTaskClient_Heartbeat tests that a heartbeat with the revision of the
+// last change fanned out is sent to the clients idle since the previous
+// heartbeat.
+func (suite *WatchProcessorTestSuite) TestTaskClient_Heartbeat() {
+	p := suite.processor.(*watchProcessor)
+	_, c, err := p.NewTaskClient(nil, nil, 0, false, nil)
+	suite.NoError(err)
+	watchID, coalesced, err := p.NewTaskClient(nil, nil, 0, true, nil)
+	suite.NoError(err)
+
+	suite.sendHeartbeats()
+	suite.Len(c.Input, 1)
+	heartbeat := <-c.Input
+	suite.Nil(heartbeat.Pod)
+	suite.Equal(p.revision, heartbeat.Revision)
+	<-coalesced.Input
+
+	p.NotifyTaskChange("job-1", &pod.PodSummary{
+		PodName: &peloton.PodName{Value: "job-1-0"},
+	}, nil, "")
+	suite.waitForFanOut()
+	suite.Len(c.Input, 1)
+	change := <-c.Input
+
+	// no heartbeat is sent to a client which received a change, or
+	// which has changes pending since the previous heartbeat
+	suite.sendHeartbeats()
+	suite.Len(c.Input, 0)
+	suite.Len(coalesced.Input, 0)
+
+	p.flushPendingChanges(watchID)
+	<-coalesced.Input
+	suite.sendHeartbeats()
+	suite.Len(c.Input, 1)
+	heartbeat = <-c.Input
+	suite.Nil(heartbeat.Pod)
+	suite.Equal(change.Revision, heartbeat.Revision)
+	suite.Len(coalesced.Input, 0)
+
+	suite.Equal(int64(3), suite.testScope.Snapshot().
+		Counters()["watch.watch_pod_heartbeat+"].Value())
+}
+
+// TestTaskClient_FieldMask tests that only the fields of the pods in the
+// field mask of a client are sent to it, including the changes of its
+// backlog.
+func (suite *WatchProcessorTestSuite) TestTaskClient_FieldMask() {
+	fieldMask := []string{"status.state"}
+	watchID, c1, err := suite.processor.NewTaskClient(
+		nil, nil, 0, false, fieldMask)
+	suite.NoError(err)
+	_, c2, err := suite.processor.NewTaskClient(
+		nil, nil, 0, false, fieldMask)
+	suite.NoError(err)
+
+	p := &pod.PodSummary{
+		PodName: &peloton.PodName{Value: "job-1-0"},
+		Status: &pod.PodStatus{
+			State: pod.PodState_POD_STATE_RUNNING,
+			Host:  "host-0",
+		},
+	}
+	suite.processor.NotifyTaskChange("job-1", p, nil, "")
+	suite.waitForFanOut()
+
+	change1 := <-c1.Input
+	change2 := <-c2.Input
+	suite.Equal(change1, change2)
+	suite.Equal(&pod.PodSummary{
+		PodName: p.GetPodName(),
+		Status:  &pod.PodStatus{State: pod.PodState_POD_STATE_RUNNING},
+	}, change1.Pod)
+	// the change kept in the history is not masked
+	suite.Equal("host-0", p.GetStatus().GetHost())
+
+	// the max client is reached
+	suite.NoError(suite.processor.StopTaskClient(watchID))
+	_, resumed, err := suite.processor.NewTaskClient(
+		nil, nil, change1.Revision-1, false, []string{"status.host"})
+	suite.NoError(err)
+	suite.Len(resumed.Input, 1)
+	change := <-resumed.Input
+	suite.Equal(pod.PodState_POD_STATE_INVALID, change.Pod.GetStatus().GetState())
+	suite.Equal("host-0", change.Pod.GetStatus().GetHost())
+}
+
+// TestMatchJob tests matching the job of a pod against the job ids
+// selected by a filter.
+func (suite *WatchProcessorTestSuite) TestMatchJob() {
+	suite.True(MatchJob(nil, "job-1"))
+	suite.True(MatchJob(&watch.PodFilter{}, "job-1"))
+	suite.True(MatchJob(&watch.PodFilter{JobId: &peloton.JobID{}}, "job-1"))
+
+	filter := &watch.PodFilter{
+		JobId:  &peloton.JobID{Value: "job-1"},
+		JobIds: []*peloton.JobID{{Value: "job-2"}, {Value: "job-3"}},
+	}
+	suite.True(MatchJob(filter, "job-1"))
+	suite.True(MatchJob(filter, "job-3"))
+	suite.False(MatchJob(filter, "job-4"))
+	suite.False(MatchJob(filter, ""))
+
+	suite.True(MatchJob(
+		&watch.PodFilter{JobIds: []*peloton.JobID{{Value: "job-2"}}},
+		"job-2"))
+	suite.False(MatchJob(
+		&watch.PodFilter{JobIds: []*peloton.JobID{{Value: "job-2"}}},
+		"job-1"))
+}
+
+// TestMatchPodName tests matching the name of a pod against the pod
+// names and the pod name prefix selected by a filter.
+func (suite *WatchProcessorTestSuite) TestMatchPodName() {
+	suite.True(MatchPodName(nil, "job-1-0"))
+	suite.True(MatchPodName(&watch.PodFilter{}, "job-1-0"))
+
+	filter := &watch.PodFilter{
+		PodNames: []*peloton.PodName{{Value: "job-1-0"}},
+	}
+	suite.True(MatchPodName(filter, "job-1-0"))
+	suite.False(MatchPodName(filter, "job-1-1"))
+
+	filter.PodNamePrefix = "job-2-"
+	suite.True(MatchPodName(filter, "job-1-0"))
+	suite.True(MatchPodName(filter, "job-2-5"))
+	suite.False(MatchPodName(filter, "job-1-1"))
+
+	suite.True(MatchPodName(&watch.PodFilter{PodNamePrefix: "job-1"}, "job-10-0"))
+	suite.False(MatchPodName(&watch.PodFilter{PodNamePrefix: "job-1"}, "job-2-0"))
+}
+
+// TestMatchLabels tests matching the labels of a pod against the labels
+// selected by a filter.
+func (suite *WatchProcessorTestSuite) TestMatchLabels() {
+	labels := []*peloton.Label{
+		{Key: "app", Value: "web"},
+		{Key: "env", Value: "prod"},
+	}
+
+	suite.True(MatchLabels(nil, nil))
+	suite.True(MatchLabels(nil, labels))
+	suite.True(MatchLabels(labels[:1], labels))
+	suite.True(MatchLabels(labels, labels))
+	suite.False(MatchLabels(labels, nil))
+	suite.False(MatchLabels(labels, labels[1:]))
+	suite.False(MatchLabels(
+		[]*peloton.Label{{Key: "app", Value: "db"}}, labels))
+}
+
+// TestWorkflowClient tests setup and teardown of workflow watch client,
+// and that only the changes of the workflows of the jobs selected by the
+// filter are sent to the client, with the revision of the last pod change.
+func (suite *WatchProcessorTestSuite) TestWorkflowClient() {
+	filter := &watch.WorkflowFilter{
+		JobIds: []*peloton.JobID{{Value: "job-1"}},
+	}
+	watchID, c, err := suite.processor.NewWorkflowClient(filter)
+	suite.NoError(err)
+	suite.NotEmpty(watchID)
+	suite.NotNil(c)
+
+	suite.processor.NotifyTaskChange("job-1", &pod.PodSummary{}, nil, "")
+	revision := suite.processor.(*watchProcessor).revision
+
+	suite.processor.NotifyWorkflowChange(&watch.WorkflowChange{
+		JobId: &peloton.JobID{Value: "job-2"},
+	})
+	suite.Len(c.Input, 0)
+	suite.processor.NotifyWorkflowChange(&watch.WorkflowChange{
+		JobId: &peloton.JobID{Value: "job-1"},
+	})
+	suite.Len(c.Input, 1)
+	change := <-c.Input
+	suite.Equal("job-1", change.Workflow.GetJobId().GetValue())
+	suite.Equal(revision, change.Revision)
+
+	// workflow changes do not move the revision of the pod changes
+	suite.Equal(revision, suite.processor.(*watchProcessor).revision)
+
+	err = suite.processor.StopWorkflowClient(watchID)
+	suite.NoError(err)
+	suite.Equal(StopSignalCancel, <-c.Signal)
+
+	err = suite.processor.StopWorkflowClient(watchID)
+	suite.Error(err)
+	suite.True(yarpcerrors.IsNotFound(err))
+}
+
+// TestWorkflowClient_EventOverflow tests that a "overflow" stop Signal
+// will be sent to the workflow watch client if the client buffer is
+// overflown, and that max number of clients is enforced.
+func (suite *WatchProcessorTestSuite) TestWorkflowClient_EventOverflow() {
+	watchID, c, err := suite.processor.NewWorkflowClient(nil)
+	suite.NoError(err)
+	suite.NotEmpty(watchID)
+
+	_, _, err = suite.processor.NewWorkflowClient(nil)
+	suite.NoError(err)
+	_, _, err = suite.processor.NewWorkflowClient(nil)
+	suite.True(yarpcerrors.IsResourceExhausted(err))
+
+	// send number of events equal to buffer size
+	for i := 0; i < 10; i++ {
+		suite.processor.NotifyWorkflowChange(&watch.WorkflowChange{})
+	}
+	suite.Len(c.Signal, 0)
+
+	// trigger buffer overflow
+	suite.processor.NotifyWorkflowChange(&watch.WorkflowChange{})
+	suite.Equal(StopSignalOverflow, <-c.Signal)
+}
+
+// TestMatchWorkflowJob tests matching the job of a workflow against the
+// job ids selected by a filter.
+func (suite *WatchProcessorTestSuite) TestMatchWorkflowJob() {
+	suite.True(MatchWorkflowJob(nil, "job-1"))
+	suite.True(MatchWorkflowJob(&watch.WorkflowFilter{}, "job-1"))
+
+	filter := &watch.WorkflowFilter{
+		JobIds: []*peloton.JobID{{Value: "job-1"}, {Value: "job-2"}},
+	}
+	suite.True(MatchWorkflowJob(filter, "job-2"))
+	suite.False(MatchWorkflowJob(filter, "job-3"))
+}
+
+// TestFirehoseClient tests setup and teardown of firehose client, and
+// that only the events of the jobs in the shard are sent to the client.
+func (suite *WatchProcessorTestSuite) TestFirehoseClient() {
+	filter := &watch.FirehoseFilter{NumShards: 2, Shard: 0}
+	watchID, c, err := suite.processor.NewFirehoseClient(filter)
+	suite.NoError(err)
+	suite.NotEmpty(watchID)
+	suite.NotNil(c)
+
+	var inShard, notInShard string
+	for i := 0; inShard == "" || notInShard == ""; i++ {
+		jobID := fmt.Sprintf("job-%d", i)
+		if InFirehoseShard(jobID, filter) {
+			inShard = jobID
+		} else {
+			notInShard = jobID
+		}
+	}
+
+	suite.processor.NotifyFirehoseTaskChange(notInShard, &pod.PodSummary{})
+	suite.Len(c.Input, 0)
+	suite.processor.NotifyFirehoseTaskChange(inShard, &pod.PodSummary{})
+	suite.Len(c.Input, 1)
+
+	// task watch clients are not notified about firehose events
+	suite.processor.NotifyTaskChange("", &pod.PodSummary{}, nil, "")
+	suite.Len(c.Input, 1)
+
+	err = suite.processor.StopFirehoseClient(watchID)
+	suite.NoError(err)
+	suite.Equal(StopSignalCancel, <-c.Signal)
+
+	err = suite.processor.StopFirehoseClient(watchID)
+	suite.Error(err)
+	suite.True(yarpcerrors.IsNotFound(err))
+}
+
+// TestFirehoseClient_MaxClientReached tests an error will be thrown when
+// creating a new firehose client if max number of clients is reached,
+// independently from the task watch clients.
+func (suite *WatchProcessorTestSuite) TestFirehoseClient_MaxClientReached() {
+	for i := 0; i < 2; i++ {
+		_, _, err := suite.processor.NewTaskClient(nil, nil, 0, false, nil)
+		suite.NoError(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		watchID, c, err := suite.processor.NewFirehoseClient(nil)
+		if i < 2 {
+			suite.NoError(err)
+			suite.NotEmpty(watchID)
+			suite.NotNil(c)
+		} else {
+			suite.Error(err)
+			suite.True(yarpcerrors.IsResourceExhausted(err))
+		}
+	}
+}
+
+// TestFirehoseClient_EventOverflow tests that a "overflow" stop Signal will
+// be sent to the firehose client if the client buffer is overflown.
+func (suite *WatchProcessorTestSuite) TestFirehoseClient_EventOverflow() {
+	watchID, c, err := suite.processor.NewFirehoseClient(nil)
+	suite.NoError(err)
+	suite.NotEmpty(watchID)
+
+	// send number of events equal to buffer size
+	for i := 0; i < 10; i++ {
+		suite.processor.NotifyFirehoseTaskChange("job", &pod.PodSummary{})
+	}
+	suite.Len(c.Signal, 0)
+
+	// trigger buffer overflow
+	suite.processor.NotifyFirehoseTaskChange("job", &pod.PodSummary{})
+	suite.Equal(StopSignalOverflow, <-c.Signal)
+}
+
+// TestListClients tests the clients of all the types are listed with
+// their buffer utilization and lag.
+func (suite *WatchProcessorTestSuite) TestListClients() {
+	taskWatchID, c, err := suite.processor.NewTaskClient(
+		&watch.PodFilter{JobId: &peloton.JobID{Value: "job"}},
+		nil, 0, false, nil)
+	suite.NoError(err)
+	workflowWatchID, _, err := suite.processor.NewWorkflowClient(nil)
+	suite.NoError(err)
+	firehoseWatchID, _, err := suite.processor.NewFirehoseClient(nil)
+	suite.NoError(err)
+
+	for i := 0; i < 3; i++ {
+		suite.processor.NotifyTaskChange("job", &pod.PodSummary{}, nil, "")
+		// the changes of the other jobs do not add to the lag
+		suite.processor.NotifyTaskChange("other", &pod.PodSummary{}, nil, "")
+	}
+	suite.waitForFanOut()
+	// the first change is streamed back to the client
+	c.MarkSent(<-c.Input)
+
+	watchers := suite.processor.ListClients()
+	suite.Len(watchers, 3)
+	byID := make(map[string]*watch.WatcherInfo)
+	for _, w := range watchers {
+		byID[w.GetWatchId()] = w
+	}
+
+	suite.Equal(ClientTypeTask.String(), byID[taskWatchID].GetType())
+	suite.Equal(uint32(2), byID[taskWatchID].GetBuffered())
+	suite.Equal(uint32(10), byID[taskWatchID].GetBufferSize())
+	suite.Equal(uint64(2), byID[taskWatchID].GetLag())
+	suite.NotEmpty(byID[taskWatchID].GetCreateTime())
+
+	suite.Equal(ClientTypeWorkflow.String(), byID[workflowWatchID].GetType())
+	suite.Equal(uint32(0), byID[workflowWatchID].GetBuffered())
+	suite.Equal(ClientTypeFirehose.String(), byID[firehoseWatchID].GetType())
+	suite.Equal(uint32(10), byID[firehoseWatchID].GetBufferSize())
+}
+
+// TestCloseClient tests that a "close" stop Signal is sent to the closed
+// client of any type, and that its slot is released.
+func (suite *WatchProcessorTestSuite) TestCloseClient() {
+	taskWatchID, tc, err := suite.processor.NewTaskClient(
+		nil, nil, 0, false, nil)
+	suite.NoError(err)
+	workflowWatchID, wc, err := suite.processor.NewWorkflowClient(nil)
+	suite.NoError(err)
+	firehoseWatchID, fc, err := suite.processor.NewFirehoseClient(nil)
+	suite.NoError(err)
+
+	suite.NoError(suite.processor.CloseClient(taskWatchID))
+	suite.Equal(StopSignalClose, <-tc.Signal)
+	suite.NoError(suite.processor.CloseClient(workflowWatchID))
+	suite.Equal(StopSignalClose, <-wc.Signal)
+	suite.NoError(suite.processor.CloseClient(firehoseWatchID))
+	suite.Equal(StopSignalClose, <-fc.Signal)
+	suite.Empty(suite.processor.ListClients())
+
+	err = suite.processor.CloseClient(taskWatchID)
+	suite.True(yarpcerrors.IsNotFound(err))
+	err = suite.processor.CloseClient("invalid")
+	suite.True(yarpcerrors.IsNotFound(err))
+}
+
+// TestTaskClient_FanOutDropped tests that a pod change is dropped rather
+// than blocking on a shard whose queue is full, and that the clients of
+// the shard which miss it are stopped.
+func (suite *WatchProcessorTestSuite) TestTaskClient_FanOutDropped() {
+	p := newWatchProcessor(Config{
+		BufferSize:      10,
+		MaxClient:       2,
+		HistorySize:     10,
+		FanOutShards:    1,
+		FanOutQueueSize: 1,
+	}, nil, suite.testScope)
+	_, c, err := p.NewTaskClient(nil, nil, 0, false, nil)
+	suite.NoError(err)
+
+	// the shard is blocked fanning out the first change, the second
+	// change is queued, and the third one is dropped
+	s := p.taskShards[0]
+	s.Lock()
+	p.NotifyTaskChange("job", &pod.PodSummary{}, nil, "")
+	for len(s.events) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	p.NotifyTaskChange("job", &pod.PodSummary{}, nil, "")
+	p.NotifyTaskChange("job", &pod.PodSummary{}, nil, "")
+	suite.Equal(int64(1), suite.testScope.Snapshot().
+		Counters()["watch.watch_pod_fan_out_dropped+"].Value())
+
+	s.Unlock()
+
+	// a client created after the dropped change receives it with its
+	// backlog, and is not stopped
+	_, resumed, err := p.NewTaskClient(nil, nil, p.revision-1, false, nil)
+	suite.NoError(err)
+
+	suite.Equal(StopSignalOverflow, <-c.Signal)
+	// the queued change is fanned out, the dropped one is not
+	for {
+		s.Lock()
+		revision := s.revision
+		s.Unlock()
+		if revision == p.revision-1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	suite.Len(resumed.Signal, 0)
+	suite.Len(resumed.Input, 1)
+}
+
+// TestTaskClient_Shards tests that the task clients spread across the
+// shards receive every change once, in the order of the revisions,
+// including the clients resuming their watch between the changes.
+func (suite *WatchProcessorTestSuite) TestTaskClient_Shards() {
+	p := newWatchProcessor(Config{
+		BufferSize:   10,
+		MaxClient:    20,
+		HistorySize:  10,
+		FanOutShards: 4,
+	}, nil, suite.testScope)
+
+	var clients []*TaskClient
+	for i := 0; i < 10; i++ {
+		_, c, err := p.NewTaskClient(nil, nil, 0, false, nil)
+		suite.NoError(err)
+		clients = append(clients, c)
+	}
+	start := clients[0].Revision
+
+	p.NotifyTaskChange("job", &pod.PodSummary{}, nil, "")
+	p.NotifyTaskChange("job", &pod.PodSummary{}, nil, "")
+
+	// the changes still queued to the shard of a resumed client are
+	// not sent again on top of its backlog
+	_, resumed, err := p.NewTaskClient(nil, nil, start, false, nil)
+	suite.NoError(err)
+	clients = append(clients, resumed)
+
+	p.NotifyTaskChange("job", &pod.PodSummary{}, nil, "")
+	waitForFanOut(p)
+
+	shards := make(map[*taskShard]bool)
+	for _, s := range p.taskShards {
+		if len(s.clients) != 0 {
+			shards[s] = true
+		}
+	}
+	suite.True(len(shards) > 1)
+
+	for _, c := range clients {
+		suite.Len(c.Input, 3)
+		for i := 0; i < 3; i++ {
+			suite.Equal(start+uint64(i)+1, (<-c.Input).Revision)
+		}
+	}
+}
+
+// BenchmarkNotifyTaskChange benchmarks the fan-out of the pod changes to
+// task clients watching distinct jobs, with an increasing number of shards.
+// The fan-out throughput scales with the number of shards up to the number
+// of available CPUs.
+func BenchmarkNotifyTaskChange(b *testing.B) {
+	numClients := 1000
+	for _, shards := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("shards-%d", shards), func(b *testing.B) {
+			p := newWatchProcessor(Config{
+				MaxClient:    numClients,
+				FanOutShards: shards,
+			}, nil, tally.NoopScope)
+
+			var wg sync.WaitGroup
+			for i := 0; i < numClients; i++ {
+				_, c, err := p.NewTaskClient(&watch.PodFilter{
+					JobId: &peloton.JobID{Value: fmt.Sprintf("job-%d", i)},
+				}, nil, 0, false, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						select {
+						case <-c.Input:
+						case <-c.Signal:
+							return
+						}
+					}
+				}()
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				p.NotifyTaskChange(
+					fmt.Sprintf("job-%d", i%numClients),
+					&pod.PodSummary{},
+					nil,
+					"",
+				)
+			}
+			waitForFanOut(p)
+			b.StopTimer()
+
+			for _, s := range p.taskShards {
+				s.Lock()
+				for watchID := range s.clients {
+					s.stopClient(watchID, StopSignalCancel)
+				}
+				s.Unlock()
+			}
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watchsvc
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	mesos "github.com/uber/peloton/.gen/mesos/v1"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/watch"
+	"github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// _resourceUsageTimeout is the timeout of the call to the host manager
+// sampling the resource usage of the pods on a host
+const _resourceUsageTimeout = 10 * time.Second
+
+// hostUsage is the resource usage of the tasks running on a host, sampled
+// once per sample interval and shared by the watches.
+type hostUsage struct {
+	// time the sample was started at
+	sampled time.Time
+	// closed once the sample is done
+	done chan struct{}
+
+	statistics map[string]*mesos.ResourceStatistics
+	err        error
+}
+
+// hostUsageSampler samples the resource usage of the tasks on the hosts
+// for all the watches, so that each host is called at most once per
+// sample interval however many watches include the resource usage.
+type hostUsageSampler struct {
+	sync.Mutex
+
+	hostmgrClient hostsvc.InternalHostServiceYARPCClient
+	interval      time.Duration
+
+	hosts     map[string]*hostUsage
+	lastPrune time.Time
+}
+
+// newHostUsageSampler returns a sampler of the resource usage of the hosts
+// which keeps the samples for the interval.
+func newHostUsageSampler(
+	hostmgrClient hostsvc.InternalHostServiceYARPCClient,
+	interval time.Duration,
+) *hostUsageSampler {
+	return &hostUsageSampler{
+		hostmgrClient: hostmgrClient,
+		interval:      interval,
+		hosts:         make(map[string]*hostUsage),
+	}
+}
+
+// get returns the resource statistics of the tasks running on a host keyed
+// by mesos task ID, sampling the host if it was not sampled during the
+// last interval.
+func (s *hostUsageSampler) get(
+	ctx context.Context,
+	host string,
+) (map[string]*mesos.ResourceStatistics, error) {
+	now := time.Now()
+
+	s.Lock()
+	usage, ok := s.hosts[host]
+	if !ok || now.Sub(usage.sampled) >= s.interval {
+		usage = &hostUsage{sampled: now, done: make(chan struct{})}
+		s.hosts[host] = usage
+		// the sample is shared by the watches, so it is not cancelled
+		// along with the watch which started it
+		go s.sample(host, usage)
+	}
+	s.pruneLocked(now)
+	s.Unlock()
+
+	select {
+	case <-usage.done:
+		return usage.statistics, usage.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// sample calls the host manager for the resource usage of all the tasks
+// running on a host.
+func (s *hostUsageSampler) sample(host string, usage *hostUsage) {
+	defer close(usage.done)
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(), _resourceUsageTimeout)
+	defer cancel()
+
+	resp, err := s.hostmgrClient.GetTasksResourceUsage(
+		ctx,
+		&hostsvc.GetTasksResourceUsageRequest{Hostname: host})
+	if err != nil {
+		usage.err = err
+		return
+	}
+
+	usage.statistics = make(map[string]*mesos.ResourceStatistics)
+	for _, u := range resp.GetUsages() {
+		usage.statistics[u.GetTaskId().GetValue()] = u.GetStatistics()
+	}
+}
+
+// pruneLocked removes the samples of the hosts which were not sampled
+// during the last interval, at most once per interval.
+func (s *hostUsageSampler) pruneLocked(now time.Time) {
+	if now.Sub(s.lastPrune) < s.interval {
+		return
+	}
+	s.lastPrune = now
+	for host, usage := range s.hosts {
+		if now.Sub(usage.sampled) >= s.interval {
+			delete(s.hosts, host)
+		}
+	}
+}
+
+// runResourceUsageSampler samples the resource usage of the running pods
+// selected by the filter and by the resource pools resolved from it, at
+// each sample interval, and sends the samples to out until the context
+// is cancelled. A round of samples is dropped if the previous one has
+// not been streamed back yet.
+func (h *ServiceHandler) runResourceUsageSampler(
+	ctx context.Context,
+	filter *watch.PodFilter,
+	respools *RespoolSet,
+	out chan<- []*watch.ResourceUsageSample,
+) {
+	ticker := time.NewTicker(h.resourceUsageConfig.SampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		samples, err := h.sampleResourceUsage(ctx, filter, respools)
+		if err != nil {
+			h.metrics.WatchPodResourceUsageFail.Inc(1)
+			log.WithError(err).
+				Warn("failed to sample resource usage for pod watch")
+			continue
+		}
+		if len(samples) == 0 {
+			continue
+		}
+
+		select {
+		case out <- samples:
+			h.metrics.WatchPodResourceUsage.Inc(1)
+		case <-ctx.Done():
+			return
+		default:
+			h.metrics.WatchPodResourceUsageDropped.Inc(1)
+		}
+	}
+}
+
+// sampleResourceUsage returns the samples of the resource usage of the
+// running pods selected by the filter and by the resource pools resolved
+// from it, sorted by pod name. The hosts are sampled concurrently, and
+// the hosts the resource usage cannot be sampled from are skipped.
+func (h *ServiceHandler) sampleResourceUsage(
+	ctx context.Context,
+	filter *watch.PodFilter,
+	respools *RespoolSet,
+) ([]*watch.ResourceUsageSample, error) {
+	pods, err := h.getPodSnapshot(ctx, filter, respools)
+	if err != nil {
+		return nil, err
+	}
+
+	// the pods are grouped by host, the pods with the lowest names are
+	// sampled if there are more than the limit
+	podsByHost := make(map[string][]*pod.PodSummary)
+	count := 0
+	for _, p := range pods {
+		if count >= h.resourceUsageConfig.MaxPods {
+			break
+		}
+		if p.GetStatus().GetState() != pod.PodState_POD_STATE_RUNNING ||
+			p.GetStatus().GetHost() == "" ||
+			p.GetStatus().GetPodId().GetValue() == "" {
+			continue
+		}
+		host := p.GetStatus().GetHost()
+		podsByHost[host] = append(podsByHost[host], p)
+		count++
+	}
+
+	hosts := make([]string, 0, len(podsByHost))
+	for host := range podsByHost {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	hostSamples := make([][]*watch.ResourceUsageSample, len(hosts))
+	sem := make(chan struct{}, h.resourceUsageConfig.MaxConcurrentHosts)
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, host string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			samples, err := h.sampleHostResourceUsage(
+				ctx, host, podsByHost[host])
+			if err != nil {
+				h.metrics.WatchPodResourceUsageHostFail.Inc(1)
+				log.WithField("host", host).
+					WithError(err).
+					Warn("failed to sample resource usage of pods on host")
+				return
+			}
+			hostSamples[i] = samples
+		}(i, host)
+	}
+	wg.Wait()
+
+	var samples []*watch.ResourceUsageSample
+	for _, s := range hostSamples {
+		samples = append(samples, s...)
+	}
+
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].GetPodName().GetValue() <
+			samples[j].GetPodName().GetValue()
+	})
+	return samples, nil
+}
+
+// sampleHostResourceUsage returns the samples of the resource usage of
+// the given pods running on a host, as reported by the agent of the host.
+// The pods the agent has no statistics for are skipped.
+func (h *ServiceHandler) sampleHostResourceUsage(
+	ctx context.Context,
+	host string,
+	pods []*pod.PodSummary,
+) ([]*watch.ResourceUsageSample, error) {
+	statistics, err := h.resourceUsageSampler.get(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []*watch.ResourceUsageSample
+	for _, p := range pods {
+		s, ok := statistics[p.GetStatus().GetPodId().GetValue()]
+		if !ok || s == nil {
+			continue
+		}
+		samples = append(samples, newResourceUsageSample(p, s))
+	}
+	return samples, nil
+}
+
+// newResourceUsageSample converts the resource statistics of the
+// container of a pod reported by its agent to a resource usage sample.
+func newResourceUsageSample(
+	p *pod.PodSummary,
+	s *mesos.ResourceStatistics,
+) *watch.ResourceUsageSample {
+	sec, frac := math.Modf(s.GetTimestamp())
+	return &watch.ResourceUsageSample{
+		PodName: p.GetPodName(),
+		PodId:   p.GetStatus().GetPodId(),
+		Host:    p.GetStatus().GetHost(),
+		Timestamp: time.Unix(int64(sec), int64(frac*float64(time.Second))).
+			UTC().Format(time.RFC3339Nano),
+		CpusUserTimeSecs:      s.GetCpusUserTimeSecs(),
+		CpusSystemTimeSecs:    s.GetCpusSystemTimeSecs(),
+		CpusLimit:             s.GetCpusLimit(),
+		CpusNrThrottled:       s.GetCpusNrThrottled(),
+		CpusThrottledTimeSecs: s.GetCpusThrottledTimeSecs(),
+		MemRssBytes:           s.GetMemRssBytes(),
+		MemLimitBytes:         s.GetMemLimitBytes(),
+		DiskUsedBytes:         s.GetDiskUsedBytes(),
+		DiskLimitBytes:        s.GetDiskLimitBytes(),
+	}
+}
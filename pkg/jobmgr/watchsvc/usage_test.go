@@ -0,0 +1,356 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watchsvc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	mesos "github.com/uber/peloton/.gen/mesos/v1"
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	v0peloton "github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/pod"
+	"github.com/uber/peloton/.gen/peloton/api/v1alpha/watch"
+	"github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc"
+	hostmocks "github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc/mocks"
+
+	"github.com/uber/peloton/pkg/jobmgr/cached"
+	cachedmocks "github.com/uber/peloton/pkg/jobmgr/cached/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+	"go.uber.org/yarpc"
+)
+
+type ResourceUsageTestSuite struct {
+	suite.Suite
+
+	ctrl          *gomock.Controller
+	testScope     tally.TestScope
+	jobFactory    *cachedmocks.MockJobFactory
+	hostmgrClient *hostmocks.MockInternalHostServiceYARPCClient
+
+	handler *ServiceHandler
+}
+
+func (suite *ResourceUsageTestSuite) SetupTest() {
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.testScope = tally.NewTestScope("", map[string]string{})
+	suite.jobFactory = cachedmocks.NewMockJobFactory(suite.ctrl)
+	suite.hostmgrClient = hostmocks.NewMockInternalHostServiceYARPCClient(suite.ctrl)
+
+	suite.handler = NewServiceHandler(
+		NewMetrics(suite.testScope),
+		nil,
+		suite.jobFactory,
+		nil,
+		suite.hostmgrClient,
+		Config{
+			ResourceUsage: ResourceUsageConfig{
+				SampleInterval: time.Millisecond,
+			},
+		},
+	)
+}
+
+func (suite *ResourceUsageTestSuite) TearDownTest() {
+	suite.ctrl.Finish()
+}
+
+func TestResourceUsage(t *testing.T) {
+	suite.Run(t, new(ResourceUsageTestSuite))
+}
+
+// expectRuntimes sets up the cache to return a stateless job with a task
+// for each of the given runtimes, along with a batch job which is skipped.
+func (suite *ResourceUsageTestSuite) expectRuntimes(
+	runtimes []*task.RuntimeInfo,
+) {
+	cachedJob := cachedmocks.NewMockJob(suite.ctrl)
+	batchJob := cachedmocks.NewMockJob(suite.ctrl)
+	suite.jobFactory.EXPECT().GetAllJobs().Return(map[string]cached.Job{
+		"job-1": cachedJob,
+		"job-2": batchJob,
+	}).AnyTimes()
+	batchJob.EXPECT().GetJobType().Return(job.JobType_BATCH).AnyTimes()
+	cachedJob.EXPECT().GetJobType().Return(job.JobType_SERVICE).AnyTimes()
+
+	tasks := map[uint32]cached.Task{}
+	for i, runtime := range runtimes {
+		cachedTask := cachedmocks.NewMockTask(suite.ctrl)
+		cachedTask.EXPECT().GetRuntime(gomock.Any()).
+			Return(runtime, nil).
+			AnyTimes()
+		tasks[uint32(i)] = cachedTask
+	}
+	cachedJob.EXPECT().GetAllTasks().Return(tasks).AnyTimes()
+	cachedJob.EXPECT().GetTaskLabels(gomock.Any()).
+		Return([]*v0peloton.Label{}).
+		AnyTimes()
+}
+
+// newRunningRuntime returns the runtime of a task running on a host.
+func newRunningRuntime(host string, mesosTaskID string) *task.RuntimeInfo {
+	return &task.RuntimeInfo{
+		State:       task.TaskState_RUNNING,
+		Host:        host,
+		MesosTaskId: &mesos.TaskID{Value: &mesosTaskID},
+	}
+}
+
+// newTaskResourceUsage returns the resource usage of a task reported by
+// the host manager.
+func newTaskResourceUsage(
+	mesosTaskID string,
+	memRssBytes uint64,
+) *hostsvc.TaskResourceUsage {
+	timestamp := float64(1500000000)
+	cpusNrThrottled := uint32(3)
+	return &hostsvc.TaskResourceUsage{
+		TaskId: &mesos.TaskID{Value: &mesosTaskID},
+		Statistics: &mesos.ResourceStatistics{
+			Timestamp:       &timestamp,
+			CpusNrThrottled: &cpusNrThrottled,
+			MemRssBytes:     &memRssBytes,
+		},
+	}
+}
+
+// TestSampleResourceUsage tests the resource usage of the running pods is
+// sampled from the hosts they run on, and the hosts failing are skipped.
+func (suite *ResourceUsageTestSuite) TestSampleResourceUsage() {
+	suite.expectRuntimes([]*task.RuntimeInfo{
+		newRunningRuntime("host-b", "job-1-0-1"),
+		newRunningRuntime("host-a", "job-1-1-1"),
+		newRunningRuntime("host-a", "job-1-2-1"),
+		{State: task.TaskState_PENDING},
+		newRunningRuntime("host-c", "job-1-4-1"),
+	})
+
+	suite.hostmgrClient.EXPECT().
+		GetTasksResourceUsage(
+			gomock.Any(),
+			&hostsvc.GetTasksResourceUsageRequest{Hostname: "host-a"}).
+		Return(&hostsvc.GetTasksResourceUsageResponse{
+			Usages: []*hostsvc.TaskResourceUsage{
+				newTaskResourceUsage("job-1-2-1", 200),
+				newTaskResourceUsage("job-1-1-1", 100),
+				newTaskResourceUsage("other-task", 300),
+			},
+		}, nil)
+	suite.hostmgrClient.EXPECT().
+		GetTasksResourceUsage(
+			gomock.Any(),
+			&hostsvc.GetTasksResourceUsageRequest{Hostname: "host-b"}).
+		Return(nil, errors.New("test error"))
+	suite.hostmgrClient.EXPECT().
+		GetTasksResourceUsage(
+			gomock.Any(),
+			&hostsvc.GetTasksResourceUsageRequest{Hostname: "host-c"}).
+		Return(&hostsvc.GetTasksResourceUsageResponse{}, nil)
+
+	samples, err := suite.handler.sampleResourceUsage(
+		context.Background(), &watch.PodFilter{}, nil)
+	suite.NoError(err)
+	suite.Equal([]*watch.ResourceUsageSample{
+		{
+			PodName:         &peloton.PodName{Value: "job-1-1"},
+			PodId:           &peloton.PodID{Value: "job-1-1-1"},
+			Host:            "host-a",
+			Timestamp:       "2017-07-14T02:40:00Z",
+			CpusNrThrottled: 3,
+			MemRssBytes:     100,
+		},
+		{
+			PodName:         &peloton.PodName{Value: "job-1-2"},
+			PodId:           &peloton.PodID{Value: "job-1-2-1"},
+			Host:            "host-a",
+			Timestamp:       "2017-07-14T02:40:00Z",
+			CpusNrThrottled: 3,
+			MemRssBytes:     200,
+		},
+	}, samples)
+	suite.Equal(int64(1), suite.testScope.Snapshot().
+		Counters()["watch.watch_pod_resource_usage_host_fail+"].Value())
+}
+
+// TestSampleResourceUsageMaxPods tests only the running pods with the
+// lowest names are sampled when there are more than the limit.
+func (suite *ResourceUsageTestSuite) TestSampleResourceUsageMaxPods() {
+	suite.handler.resourceUsageConfig.MaxPods = 1
+	suite.expectRuntimes([]*task.RuntimeInfo{
+		{State: task.TaskState_PENDING},
+		newRunningRuntime("host-a", "job-1-1-1"),
+		newRunningRuntime("host-a", "job-1-2-1"),
+	})
+
+	suite.hostmgrClient.EXPECT().
+		GetTasksResourceUsage(
+			gomock.Any(),
+			&hostsvc.GetTasksResourceUsageRequest{Hostname: "host-a"}).
+		Return(&hostsvc.GetTasksResourceUsageResponse{
+			Usages: []*hostsvc.TaskResourceUsage{
+				newTaskResourceUsage("job-1-1-1", 100),
+				newTaskResourceUsage("job-1-2-1", 200),
+			},
+		}, nil)
+
+	samples, err := suite.handler.sampleResourceUsage(
+		context.Background(), &watch.PodFilter{}, nil)
+	suite.NoError(err)
+	suite.Len(samples, 1)
+	suite.Equal("job-1-1-1", samples[0].GetPodId().GetValue())
+}
+
+// TestSampleResourceUsageShared tests the resource usage of a host is
+// sampled once per interval for all the watches.
+func (suite *ResourceUsageTestSuite) TestSampleResourceUsageShared() {
+	suite.handler.resourceUsageSampler = newHostUsageSampler(
+		suite.hostmgrClient, time.Hour)
+	suite.expectRuntimes([]*task.RuntimeInfo{
+		newRunningRuntime("host-a", "job-1-0-1"),
+	})
+
+	suite.hostmgrClient.EXPECT().
+		GetTasksResourceUsage(gomock.Any(), gomock.Any()).
+		Return(&hostsvc.GetTasksResourceUsageResponse{
+			Usages: []*hostsvc.TaskResourceUsage{
+				newTaskResourceUsage("job-1-0-1", 100),
+			},
+		}, nil)
+
+	for i := 0; i < 3; i++ {
+		samples, err := suite.handler.sampleResourceUsage(
+			context.Background(), &watch.PodFilter{}, nil)
+		suite.NoError(err)
+		suite.Len(samples, 1)
+	}
+}
+
+// TestHostUsageSamplerCancelled tests a watch cancelled while the host is
+// sampled does not wait for the sample.
+func (suite *ResourceUsageTestSuite) TestHostUsageSamplerCancelled() {
+	sampler := newHostUsageSampler(suite.hostmgrClient, time.Hour)
+	release := make(chan struct{})
+	suite.hostmgrClient.EXPECT().
+		GetTasksResourceUsage(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(
+			ctx context.Context,
+			req *hostsvc.GetTasksResourceUsageRequest,
+			opts ...yarpc.CallOption,
+		) (*hostsvc.GetTasksResourceUsageResponse, error) {
+			<-release
+			return &hostsvc.GetTasksResourceUsageResponse{}, nil
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := sampler.get(ctx, "host-a")
+	suite.Equal(context.Canceled, err)
+
+	// the sample is still completed for the other watches
+	close(release)
+	statistics, err := sampler.get(context.Background(), "host-a")
+	suite.NoError(err)
+	suite.Empty(statistics)
+}
+
+// TestRunResourceUsageSampler tests the sampler sends the samples at each
+// interval until it is cancelled.
+func (suite *ResourceUsageTestSuite) TestRunResourceUsageSampler() {
+	suite.expectRuntimes([]*task.RuntimeInfo{
+		newRunningRuntime("host-a", "job-1-0-1"),
+	})
+	suite.hostmgrClient.EXPECT().
+		GetTasksResourceUsage(gomock.Any(), gomock.Any()).
+		Return(&hostsvc.GetTasksResourceUsageResponse{
+			Usages: []*hostsvc.TaskResourceUsage{
+				newTaskResourceUsage("job-1-0-1", 100),
+			},
+		}, nil).
+		AnyTimes()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan []*watch.ResourceUsageSample, 1)
+	done := make(chan struct{})
+	go func() {
+		suite.handler.runResourceUsageSampler(
+			ctx, &watch.PodFilter{}, nil, out)
+		close(done)
+	}()
+
+	for i := 0; i < 2; i++ {
+		samples := <-out
+		suite.Len(samples, 1)
+		suite.Equal("job-1-0", samples[0].GetPodName().GetValue())
+	}
+
+	cancel()
+	<-done
+}
+
+// TestNewResourceUsageSample tests the resource statistics of a pod are
+// converted to a sample.
+func (suite *ResourceUsageTestSuite) TestNewResourceUsageSample() {
+	p := &pod.PodSummary{
+		PodName: &peloton.PodName{Value: "job-1-0"},
+		Status: &pod.PodStatus{
+			PodId: &peloton.PodID{Value: "job-1-0-1"},
+			Host:  "host-a",
+		},
+	}
+	timestamp := 1500000000.5
+	cpusUserTimeSecs := 1.5
+	cpusSystemTimeSecs := 0.5
+	cpusLimit := 2.0
+	cpusNrThrottled := uint32(3)
+	cpusThrottledTimeSecs := 0.25
+	memRssBytes := uint64(100)
+	memLimitBytes := uint64(200)
+	diskUsedBytes := uint64(300)
+	diskLimitBytes := uint64(400)
+
+	suite.Equal(&watch.ResourceUsageSample{
+		PodName:               p.GetPodName(),
+		PodId:                 p.GetStatus().GetPodId(),
+		Host:                  "host-a",
+		Timestamp:             "2017-07-14T02:40:00.5Z",
+		CpusUserTimeSecs:      cpusUserTimeSecs,
+		CpusSystemTimeSecs:    cpusSystemTimeSecs,
+		CpusLimit:             cpusLimit,
+		CpusNrThrottled:       cpusNrThrottled,
+		CpusThrottledTimeSecs: cpusThrottledTimeSecs,
+		MemRssBytes:           memRssBytes,
+		MemLimitBytes:         memLimitBytes,
+		DiskUsedBytes:         diskUsedBytes,
+		DiskLimitBytes:        diskLimitBytes,
+	}, newResourceUsageSample(p, &mesos.ResourceStatistics{
+		Timestamp:             &timestamp,
+		CpusUserTimeSecs:      &cpusUserTimeSecs,
+		CpusSystemTimeSecs:    &cpusSystemTimeSecs,
+		CpusLimit:             &cpusLimit,
+		CpusNrThrottled:       &cpusNrThrottled,
+		CpusThrottledTimeSecs: &cpusThrottledTimeSecs,
+		MemRssBytes:           &memRssBytes,
+		MemLimitBytes:         &memLimitBytes,
+		DiskUsedBytes:         &diskUsedBytes,
+		DiskLimitBytes:        &diskLimitBytes,
+	}))
+}
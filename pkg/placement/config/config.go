@@ -22,7 +22,9 @@ import (
 	"github.com/uber/peloton/pkg/common/leader"
 	"github.com/uber/peloton/pkg/common/logging"
 	"github.com/uber/peloton/pkg/common/metrics"
+	"github.com/uber/peloton/pkg/common/rpc"
 	"github.com/uber/peloton/pkg/hostmgr/mesos"
+	"github.com/uber/peloton/pkg/placement/locality"
 	"github.com/uber/peloton/pkg/storage/config"
 )
 
@@ -42,6 +44,7 @@ type Config struct {
 	Health       health.Config         `yaml:"health"`
 	Storage      config.Config         `yaml:"storage"`
 	SentryConfig logging.SentryConfig  `yaml:"sentry"`
+	TLS          rpc.TLSConfig         `yaml:"tls"`
 }
 
 // PlacementStrategy determines the placement strategy that the placement
@@ -98,6 +101,10 @@ type PlacementConfig struct {
 	// MaxDesiredHostPlacementDuration is the max time duration to try to
 	// place a task on the desired host.
 	MaxDesiredHostPlacementDuration time.Duration `yaml:"max_desired_host_placement_duration"`
+
+	// Locality is the config of the data locality hints used by the batch
+	// strategy to place the tasks close to their input datasets.
+	Locality locality.Config `yaml:"locality"`
 }
 
 // MaxRoundsConfig is the config of the maximal number of successful rounds
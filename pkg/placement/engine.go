@@ -24,10 +24,13 @@ import (
 	"github.com/uber-go/tally"
 	"github.com/uber/peloton/pkg/placement/plugins"
 
+	mesos "github.com/uber/peloton/.gen/mesos/v1"
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
 	"github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc"
 	"github.com/uber/peloton/.gen/peloton/private/resmgr"
 	"github.com/uber/peloton/pkg/common/async"
+	"github.com/uber/peloton/pkg/common/constraints"
 	"github.com/uber/peloton/pkg/common/queue"
 	"github.com/uber/peloton/pkg/placement/config"
 	"github.com/uber/peloton/pkg/placement/hosts"
@@ -481,18 +484,23 @@ func (e *engine) findUnusedHosts(
 
 func (e *engine) createPlacement(assigned []*models.Assignment) []*resmgr.Placement {
 	createPlacementStart := time.Now()
+	placeTime := createPlacementStart.UTC().Format(time.RFC3339)
 	// For each offer find all tasks assigned to it.
 	offersToTasks := map[*models.HostOffers][]*models.Task{}
+	placementInfos := map[*models.HostOffers]map[string]*task.PlacementInfo{}
 	for _, placement := range assigned {
-		task := placement.GetTask()
+		placedTask := placement.GetTask()
 		offer := placement.GetHost()
 		if offer == nil {
 			continue
 		}
 		if _, exists := offersToTasks[offer]; !exists {
 			offersToTasks[offer] = []*models.Task{}
+			placementInfos[offer] = map[string]*task.PlacementInfo{}
 		}
-		offersToTasks[offer] = append(offersToTasks[offer], task)
+		offersToTasks[offer] = append(offersToTasks[offer], placedTask)
+		placementInfos[offer][placedTask.GetTask().GetId().GetValue()] =
+			e.createPlacementInfo(placement, placeTime)
 	}
 
 	// For each offer create a placement with all the tasks assigned to it.
@@ -501,12 +509,13 @@ func (e *engine) createPlacement(assigned []*models.Assignment) []*resmgr.Placem
 		taskIDs := e.getTaskIDs(tasks)
 		selectedPorts := e.assignPorts(offer, tasks)
 		placement := &resmgr.Placement{
-			Hostname:    offer.GetOffer().Hostname,
-			AgentId:     offer.GetOffer().AgentId,
-			Type:        e.config.TaskType,
-			Tasks:       taskIDs,
-			Ports:       selectedPorts,
-			HostOfferID: offer.GetOffer().GetId(),
+			Hostname:      offer.GetOffer().Hostname,
+			AgentId:       offer.GetOffer().AgentId,
+			Type:          e.config.TaskType,
+			Tasks:         taskIDs,
+			Ports:         selectedPorts,
+			HostOfferID:   offer.GetOffer().GetId(),
+			PlacementInfo: placementInfos[offer],
 		}
 		resPlacements = append(resPlacements, placement)
 	}
@@ -515,6 +524,30 @@ func (e *engine) createPlacement(assigned []*models.Assignment) []*resmgr.Placem
 	return resPlacements
 }
 
+// createPlacementInfo records why the host of the assignment was chosen,
+// with the result of evaluating the constraints of the task against the
+// labels of the host and of the tasks already running on it.
+func (e *engine) createPlacementInfo(
+	assignment *models.Assignment,
+	placeTime string) *task.PlacementInfo {
+	host := assignment.GetHost()
+	var taskLabels []*mesos.Labels
+	for _, t := range host.GetTasks() {
+		taskLabels = append(taskLabels, t.GetLabels())
+	}
+	return &task.PlacementInfo{
+		MatchedConstraints: constraints.DescribeEvaluation(
+			assignment.GetTask().GetTask().GetConstraint(),
+			constraints.GetHostLabelValues(
+				host.GetOffer().GetHostname(),
+				host.GetOffer().GetAttributes()),
+			constraints.GetTaskLabelValues(taskLabels...)),
+		ScoreSummary: assignment.GetScoreSummary(),
+		Strategy:     string(e.config.Strategy),
+		PlaceTime:    placeTime,
+	}
+}
+
 func (e *engine) cleanup(
 	ctx context.Context,
 	assigned, retryable,
@@ -536,6 +536,7 @@ func TestEngineCreatePlacement(t *testing.T) {
 	host := testutil.SetupHostOffers()
 	assignment1 := testutil.SetupAssignment(deadline, 1)
 	assignment1.SetHost(host)
+	assignment1.SetScoreSummary("first fit, host 1 of 1 candidate hosts")
 	assignment2 := testutil.SetupAssignment(deadline, 1)
 	assignments := []*models.Assignment{
 		assignment1,
@@ -555,6 +556,17 @@ func TestEngineCreatePlacement(t *testing.T) {
 			assignment1.GetTask().GetTask().GetId(),
 		}, placements[0].GetTasks())
 	assert.Equal(t, 3, len(placements[0].GetPorts()))
+
+	info := placements[0].GetPlacementInfo()[assignment1.GetTask().GetTask().GetId().GetValue()]
+	assert.Equal(t, "first fit, host 1 of 1 candidate hosts", info.GetScoreSummary())
+	assert.Equal(t,
+		[]string{
+			"((host label key1=value1 count < 1 and " +
+				"task label key2=value2 count < 1)): matched",
+		},
+		info.GetMatchedConstraints())
+	assert.Equal(t, string(config.Batch), info.GetStrategy())
+	assert.NotEmpty(t, info.GetPlaceTime())
 }
 
 func TestEngineAssignPortsAllFromASingleRange(t *testing.T) {
@@ -0,0 +1,106 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locality
+
+import (
+	"time"
+)
+
+const (
+	_defaultCacheTTL          = 5 * time.Minute
+	_defaultCacheSize         = 10000
+	_defaultLookupTimeout     = 2 * time.Second
+	_defaultLookupConcurrency = 4
+)
+
+// Config is the config of the data locality hints consulted during
+// placement.
+type Config struct {
+	// Enabled turns on the data locality hints.
+	Enabled bool `yaml:"enabled"`
+
+	// CacheTTL is how long the locality of a dataset is cached before
+	// being looked up again. Defaults to 5 minutes.
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+
+	// CacheSize is the maximal number of datasets whose locality is
+	// cached, beyond which the least recently used ones are evicted.
+	// Defaults to 10000.
+	CacheSize int `yaml:"cache_size"`
+
+	// LookupTimeout is the timeout of looking up the locality of a
+	// dataset. Defaults to 2 seconds.
+	LookupTimeout time.Duration `yaml:"lookup_timeout"`
+
+	// LookupConcurrency is the maximal number of datasets looked up
+	// concurrently. Defaults to 4.
+	LookupConcurrency int `yaml:"lookup_concurrency"`
+
+	// HDFS is the config of the provider of the hdfs:// datasets.
+	HDFS HDFSConfig `yaml:"hdfs"`
+
+	// Static is the list of dataset prefixes with a fixed set of local
+	// hosts, used for the object stores such as S3 which do not expose
+	// the location of the data.
+	Static []StaticDataset `yaml:"static"`
+}
+
+// HDFSConfig is the config of the HDFS locality provider.
+type HDFSConfig struct {
+	// WebHDFSAddress is the address of the WebHDFS endpoint of the
+	// namenode, such as http://namenode:9870. The provider is disabled
+	// if unset.
+	WebHDFSAddress string `yaml:"webhdfs_address"`
+
+	// User is the user the WebHDFS requests are made as.
+	User string `yaml:"user"`
+}
+
+// StaticDataset is a dataset prefix and the hosts local to it.
+type StaticDataset struct {
+	// Prefix is the prefix of the dataset URIs, such as s3://bucket/path.
+	Prefix string `yaml:"prefix"`
+
+	// Hosts are the hostnames local to the datasets under the prefix.
+	Hosts []string `yaml:"hosts"`
+}
+
+func (c Config) getCacheTTL() time.Duration {
+	if c.CacheTTL == 0 {
+		return _defaultCacheTTL
+	}
+	return c.CacheTTL
+}
+
+func (c Config) getCacheSize() int {
+	if c.CacheSize <= 0 {
+		return _defaultCacheSize
+	}
+	return c.CacheSize
+}
+
+func (c Config) getLookupConcurrency() int {
+	if c.LookupConcurrency <= 0 {
+		return _defaultLookupConcurrency
+	}
+	return c.LookupConcurrency
+}
+
+func (c Config) getLookupTimeout() time.Duration {
+	if c.LookupTimeout == 0 {
+		return _defaultLookupTimeout
+	}
+	return c.LookupTimeout
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locality
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	_hdfsScheme = "hdfs"
+
+	// _maxHDFSFiles is the maximal number of files of a dataset directory
+	// whose block locations are looked up.
+	_maxHDFSFiles = 100
+)
+
+// hdfsFileStatuses is the response of the LISTSTATUS WebHDFS operation.
+type hdfsFileStatuses struct {
+	FileStatuses struct {
+		FileStatus []struct {
+			PathSuffix string `json:"pathSuffix"`
+			Type       string `json:"type"`
+		} `json:"FileStatus"`
+	} `json:"FileStatuses"`
+}
+
+// hdfsBlockLocations is the response of the GETFILEBLOCKLOCATIONS WebHDFS
+// operation.
+type hdfsBlockLocations struct {
+	BlockLocations struct {
+		BlockLocation []struct {
+			Hosts  []string `json:"hosts"`
+			Length int64    `json:"length"`
+		} `json:"BlockLocation"`
+	} `json:"BlockLocations"`
+}
+
+// hdfsProvider looks up the hosts holding the replicas of the blocks of
+// an HDFS dataset through WebHDFS.
+type hdfsProvider struct {
+	address string
+	user    string
+	client  *http.Client
+}
+
+// NewHDFSProvider returns a provider of the locality of hdfs:// datasets.
+// A dataset is either a file, or a directory whose files are looked up.
+func NewHDFSProvider(config HDFSConfig) Provider {
+	return &hdfsProvider{
+		address: strings.TrimSuffix(config.WebHDFSAddress, "/"),
+		user:    config.User,
+		client:  &http.Client{},
+	}
+}
+
+// Schemes implements Provider.
+func (p *hdfsProvider) Schemes() []string {
+	return []string{_hdfsScheme}
+}
+
+// Locate implements Provider. The score of a host is the fraction of the
+// bytes of the dataset with a replica on the host.
+func (p *hdfsProvider) Locate(
+	ctx context.Context,
+	dataset *url.URL,
+) (map[string]float64, error) {
+	var statuses hdfsFileStatuses
+	if err := p.call(ctx, dataset.Path, "LISTSTATUS", &statuses); err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, status := range statuses.FileStatuses.FileStatus {
+		if status.Type != "FILE" {
+			continue
+		}
+		// the path suffix is empty if the dataset is a file
+		files = append(files, path.Join(dataset.Path, status.PathSuffix))
+		if len(files) == _maxHDFSFiles {
+			break
+		}
+	}
+
+	var total int64
+	hostBytes := make(map[string]int64)
+	for _, file := range files {
+		var locations hdfsBlockLocations
+		if err := p.call(
+			ctx, file, "GETFILEBLOCKLOCATIONS", &locations); err != nil {
+			return nil, err
+		}
+		for _, block := range locations.BlockLocations.BlockLocation {
+			total += block.Length
+			for _, host := range block.Hosts {
+				hostBytes[host] += block.Length
+			}
+		}
+	}
+	if total == 0 {
+		return nil, nil
+	}
+
+	scores := make(map[string]float64, len(hostBytes))
+	for host, bytes := range hostBytes {
+		scores[host] = float64(bytes) / float64(total)
+	}
+	return scores, nil
+}
+
+// call makes a WebHDFS call and decodes its JSON response into result.
+func (p *hdfsProvider) call(
+	ctx context.Context,
+	filePath string,
+	op string,
+	result interface{},
+) error {
+	query := url.Values{"op": []string{op}}
+	if p.user != "" {
+		query.Set("user.name", p.user)
+	}
+	u := fmt.Sprintf("%s/webhdfs/v1%s?%s", p.address, filePath, query.Encode())
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "failed to call %s", op)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf(
+			"%s of %s failed with status %d", op, filePath, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locality
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newWebHDFSServer returns a WebHDFS server serving a dataset directory
+// with two files and a sub-directory.
+func newWebHDFSServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "peloton", r.URL.Query().Get("user.name"))
+			switch fmt.Sprintf("%s %s", r.URL.Query().Get("op"), r.URL.Path) {
+			case "LISTSTATUS /webhdfs/v1/table":
+				fmt.Fprint(w, `{"FileStatuses":{"FileStatus":[
+					{"pathSuffix":"part-0","type":"FILE"},
+					{"pathSuffix":"part-1","type":"FILE"},
+					{"pathSuffix":"_tmp","type":"DIRECTORY"}]}}`)
+			case "GETFILEBLOCKLOCATIONS /webhdfs/v1/table/part-0":
+				fmt.Fprint(w, `{"BlockLocations":{"BlockLocation":[
+					{"hosts":["host1","host2"],"length":300},
+					{"hosts":["host2","host3"],"length":100}]}}`)
+			case "GETFILEBLOCKLOCATIONS /webhdfs/v1/table/part-1":
+				fmt.Fprint(w, `{"BlockLocations":{"BlockLocation":[
+					{"hosts":["host1"],"length":600}]}}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+}
+
+// TestHDFSProviderLocate tests the score of a host is the fraction of
+// the bytes of the dataset it holds a replica of
+func TestHDFSProviderLocate(t *testing.T) {
+	server := newWebHDFSServer(t)
+	defer server.Close()
+
+	p := NewHDFSProvider(HDFSConfig{
+		WebHDFSAddress: server.URL + "/",
+		User:           "peloton",
+	})
+	assert.Equal(t, []string{"hdfs"}, p.Schemes())
+
+	u, err := url.Parse("hdfs://namenode/table")
+	assert.NoError(t, err)
+	scores, err := p.Locate(context.Background(), u)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{
+		"host1": 0.9,
+		"host2": 0.4,
+		"host3": 0.1,
+	}, scores)
+}
+
+// TestHDFSProviderLocateNotFound tests looking up a dataset which
+// does not exist
+func TestHDFSProviderLocateNotFound(t *testing.T) {
+	server := newWebHDFSServer(t)
+	defer server.Close()
+
+	p := NewHDFSProvider(HDFSConfig{
+		WebHDFSAddress: server.URL,
+		User:           "peloton",
+	})
+	u, err := url.Parse("hdfs://namenode/missing")
+	assert.NoError(t, err)
+	_, err = p.Locate(context.Background(), u)
+	assert.Error(t, err)
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locality
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/private/resmgr"
+
+	"github.com/uber/peloton/pkg/common/lru"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/uber-go/tally"
+)
+
+// DatasetsLabel is the key of the task label declaring the input datasets
+// of a task, as a comma separated list of URIs such as
+// hdfs://namenode/path/to/table.
+const DatasetsLabel = "peloton.input_datasets"
+
+// _maxPendingLookups is the maximal number of datasets being looked up,
+// beyond which the lookups of the other datasets are dropped until the
+// next placement round.
+const _maxPendingLookups = 1000
+
+// Provider looks up the hosts local to a dataset.
+type Provider interface {
+	// Schemes returns the URI schemes of the datasets handled by the
+	// provider, such as hdfs.
+	Schemes() []string
+
+	// Locate returns the score of the hosts local to the dataset, between
+	// 0 and 1, keyed by hostname. A score of 1 means all the data of the
+	// dataset is on the host. Hosts without any data are omitted.
+	Locate(ctx context.Context, dataset *url.URL) (map[string]float64, error)
+}
+
+// Hints provides the data locality of the tasks being placed.
+type Hints interface {
+	// Scores returns the score of the hosts local to the input datasets
+	// of a task, between 0 and 1, keyed by hostname. It returns nil if
+	// the task does not declare any input dataset, or the locality of its
+	// datasets is unknown. It does not block on looking up the locality
+	// of the datasets.
+	Scores(task *resmgr.Task) map[string]float64
+}
+
+// hints implements Hints by dispatching each dataset to the provider of
+// its URI scheme, and caching the result. The datasets are looked up in the
+// background, so that the placement of the tasks never waits on a provider.
+type hints struct {
+	sync.Mutex
+
+	providers     map[string]Provider
+	cache         lru.Cache
+	lookupTimeout time.Duration
+	// the datasets being looked up
+	pending map[string]bool
+	// bounds the number of concurrent lookups
+	lookupSlots chan struct{}
+
+	lookups        tally.Counter
+	lookupsFail    tally.Counter
+	lookupsDropped tally.Counter
+	unknownScheme  tally.Counter
+}
+
+// NewHints returns the data locality hints of the config, or nil if the
+// hints are not enabled.
+func NewHints(config Config, scope tally.Scope) Hints {
+	if !config.Enabled {
+		return nil
+	}
+
+	var providers []Provider
+	if config.HDFS.WebHDFSAddress != "" {
+		providers = append(providers, NewHDFSProvider(config.HDFS))
+	}
+	if len(config.Static) > 0 {
+		providers = append(providers, NewStaticProvider(config.Static))
+	}
+	return newHints(config, scope, providers...)
+}
+
+func newHints(config Config, scope tally.Scope, providers ...Provider) *hints {
+	h := &hints{
+		providers:      make(map[string]Provider),
+		cache:          lru.New(config.getCacheSize(), config.getCacheTTL()),
+		lookupTimeout:  config.getLookupTimeout(),
+		pending:        make(map[string]bool),
+		lookupSlots:    make(chan struct{}, config.getLookupConcurrency()),
+		lookups:        scope.Counter("lookups"),
+		lookupsFail:    scope.Counter("lookups_fail"),
+		lookupsDropped: scope.Counter("lookups_dropped"),
+		unknownScheme:  scope.Counter("unknown_scheme"),
+	}
+	for _, provider := range providers {
+		for _, scheme := range provider.Schemes() {
+			h.providers[scheme] = provider
+		}
+	}
+	return h
+}
+
+// Scores implements Hints. The score of a host is the average of its
+// scores for each input dataset of the task. The datasets whose locality
+// is not cached are looked up in the background and skipped, so the task
+// is placed without their locality until the lookup completes, e.g. in the
+// next placement round. A dataset which fails to be looked up is skipped
+// as well.
+func (h *hints) Scores(task *resmgr.Task) map[string]float64 {
+	datasets := GetDatasets(task)
+	if len(datasets) == 0 {
+		return nil
+	}
+
+	var result map[string]float64
+	for _, dataset := range datasets {
+		cached, ok := h.cache.Get(dataset)
+		if !ok {
+			h.prefetch(dataset)
+			continue
+		}
+		scores := cached.(map[string]float64)
+		if len(scores) == 0 {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]float64)
+		}
+		for hostname, score := range scores {
+			result[hostname] += score / float64(len(datasets))
+		}
+	}
+	return result
+}
+
+// prefetch looks up the locality of a dataset in the background, unless
+// it is already being looked up. The lookup is dropped if too many
+// datasets are being looked up.
+func (h *hints) prefetch(dataset string) {
+	u, err := url.Parse(dataset)
+	if err != nil {
+		log.WithError(err).
+			WithField("dataset", dataset).
+			Debug("invalid input dataset")
+		h.unknownScheme.Inc(1)
+		return
+	}
+	provider, ok := h.providers[u.Scheme]
+	if !ok {
+		h.unknownScheme.Inc(1)
+		return
+	}
+
+	h.Lock()
+	defer h.Unlock()
+	if h.pending[dataset] {
+		return
+	}
+	if len(h.pending) >= _maxPendingLookups {
+		h.lookupsDropped.Inc(1)
+		return
+	}
+	h.pending[dataset] = true
+	go h.lookup(dataset, u, provider)
+}
+
+// lookup looks up the locality of a dataset and caches it.
+func (h *hints) lookup(dataset string, u *url.URL, provider Provider) {
+	defer func() {
+		h.Lock()
+		defer h.Unlock()
+		delete(h.pending, dataset)
+	}()
+
+	h.lookupSlots <- struct{}{}
+	defer func() { <-h.lookupSlots }()
+
+	h.lookups.Inc(1)
+	ctx, cancel := context.WithTimeout(context.Background(), h.lookupTimeout)
+	defer cancel()
+	scores, err := provider.Locate(ctx, u)
+	if err != nil {
+		log.WithError(err).
+			WithField("dataset", dataset).
+			Warn("failed to look up the locality of the dataset")
+		h.lookupsFail.Inc(1)
+		// cache the failure too, so that an unavailable provider is not
+		// called for the dataset in every placement round
+		scores = nil
+	}
+	h.cache.Add(dataset, scores)
+}
+
+// GetDatasets returns the input datasets declared in the labels of a task.
+func GetDatasets(task *resmgr.Task) []string {
+	var datasets []string
+	for _, label := range task.GetLabels().GetLabels() {
+		if label.GetKey() != DatasetsLabel {
+			continue
+		}
+		for _, dataset := range strings.Split(label.GetValue(), ",") {
+			if dataset = strings.TrimSpace(dataset); dataset != "" {
+				datasets = append(datasets, dataset)
+			}
+		}
+	}
+	return datasets
+}
@@ -0,0 +1,250 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locality
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/uber/peloton/.gen/mesos/v1"
+	"github.com/uber/peloton/.gen/peloton/private/resmgr"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally"
+)
+
+// fakeProvider is a provider returning fixed scores per dataset path.
+type fakeProvider struct {
+	sync.Mutex
+	scores map[string]map[string]float64
+	err    error
+	calls  int
+}
+
+func (p *fakeProvider) Schemes() []string {
+	return []string{"fake"}
+}
+
+func (p *fakeProvider) Locate(
+	ctx context.Context,
+	dataset *url.URL,
+) (map[string]float64, error) {
+	p.Lock()
+	defer p.Unlock()
+	p.calls++
+	return p.scores[dataset.Path], p.err
+}
+
+func (p *fakeProvider) set(scores map[string]map[string]float64, err error) {
+	p.Lock()
+	defer p.Unlock()
+	p.scores = scores
+	p.err = err
+}
+
+func (p *fakeProvider) getCalls() int {
+	p.Lock()
+	defer p.Unlock()
+	return p.calls
+}
+
+// waitForScores returns the scores of the task once the locality of its
+// datasets has been looked up in the background, and fails the test if it
+// is not within a second.
+func waitForScores(
+	t *testing.T,
+	h Hints,
+	task *resmgr.Task,
+	expected map[string]float64) {
+	var scores map[string]float64
+	for i := 0; i < 1000; i++ {
+		if scores = h.Scores(task); reflect.DeepEqual(expected, scores) {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, expected, scores)
+}
+
+func newTask(datasets string) *resmgr.Task {
+	key := DatasetsLabel
+	return &resmgr.Task{
+		Labels: &mesos_v1.Labels{
+			Labels: []*mesos_v1.Label{{Key: &key, Value: &datasets}},
+		},
+	}
+}
+
+// TestGetDatasets tests parsing the input datasets label of a task
+func TestGetDatasets(t *testing.T) {
+	assert.Empty(t, GetDatasets(&resmgr.Task{}))
+	assert.Equal(t,
+		[]string{"hdfs://nn/a", "s3://bucket/b"},
+		GetDatasets(newTask(" hdfs://nn/a, ,s3://bucket/b")))
+}
+
+// TestHintsScores tests the scores of the hosts are averaged over the
+// datasets of the task, and datasets of unknown schemes are skipped
+func TestHintsScores(t *testing.T) {
+	provider := &fakeProvider{
+		scores: map[string]map[string]float64{
+			"/a": {"host1": 1, "host2": 0.5},
+			"/b": {"host2": 1},
+		},
+	}
+	h := newHints(Config{}, tally.NoopScope, provider)
+
+	// the datasets are looked up in the background
+	waitForScores(t, h,
+		newTask("fake://nn/a,fake://nn/b"),
+		map[string]float64{"host1": 0.5, "host2": 0.75})
+
+	assert.Equal(t,
+		map[string]float64{"host1": 0.5, "host2": 0.25},
+		h.Scores(newTask("fake://nn/a,unknown://nn/b")))
+
+	assert.Nil(t, h.Scores(newTask("unknown://nn/b")))
+	assert.Nil(t, h.Scores(&resmgr.Task{}))
+	assert.Equal(t, 2, provider.getCalls())
+}
+
+// TestHintsCache tests the locality of a dataset is cached, including
+// failed lookups, until the cache TTL expires
+func TestHintsCache(t *testing.T) {
+	provider := &fakeProvider{err: errors.New("unavailable")}
+	h := newHints(Config{CacheTTL: 100 * time.Millisecond}, tally.NoopScope, provider)
+
+	assert.Nil(t, h.Scores(newTask("fake://nn/a")))
+	for i := 0; i < 1000 && h.cache.Len() == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Nil(t, h.Scores(newTask("fake://nn/a")))
+	assert.Equal(t, 1, provider.getCalls())
+
+	provider.set(map[string]map[string]float64{"/a": {"host1": 1}}, nil)
+	time.Sleep(100 * time.Millisecond)
+	waitForScores(t, h,
+		newTask("fake://nn/a"),
+		map[string]float64{"host1": 1})
+	assert.Equal(t, 2, provider.getCalls())
+}
+
+// TestHintsCacheSize tests the least recently used datasets are evicted
+// from the cache once it is full
+func TestHintsCacheSize(t *testing.T) {
+	provider := &fakeProvider{
+		scores: map[string]map[string]float64{
+			"/a": {"host1": 1},
+			"/b": {"host2": 1},
+		},
+	}
+	h := newHints(Config{CacheSize: 1}, tally.NoopScope, provider)
+
+	waitForScores(t, h,
+		newTask("fake://nn/a"),
+		map[string]float64{"host1": 1})
+	waitForScores(t, h,
+		newTask("fake://nn/b"),
+		map[string]float64{"host2": 1})
+	assert.Equal(t, 1, h.cache.Len())
+
+	// a was evicted, and is looked up again
+	waitForScores(t, h,
+		newTask("fake://nn/a"),
+		map[string]float64{"host1": 1})
+	assert.Equal(t, 3, provider.getCalls())
+}
+
+// TestHintsPendingLookups tests a dataset is looked up once while its
+// lookup is in progress
+func TestHintsPendingLookups(t *testing.T) {
+	provider := &blockingProvider{release: make(chan struct{})}
+	h := newHints(Config{}, tally.NoopScope, provider)
+
+	assert.Nil(t, h.Scores(newTask("fake://nn/a")))
+	assert.Nil(t, h.Scores(newTask("fake://nn/a")))
+	h.Lock()
+	assert.Len(t, h.pending, 1)
+	h.Unlock()
+
+	close(provider.release)
+	waitForScores(t, h,
+		newTask("fake://nn/a"),
+		map[string]float64{"host1": 1})
+	assert.Equal(t, int32(1), atomic.LoadInt32(&provider.calls))
+}
+
+// blockingProvider is a provider whose lookups block until released.
+type blockingProvider struct {
+	release chan struct{}
+	calls   int32
+}
+
+func (p *blockingProvider) Schemes() []string {
+	return []string{"fake"}
+}
+
+func (p *blockingProvider) Locate(
+	ctx context.Context,
+	dataset *url.URL,
+) (map[string]float64, error) {
+	atomic.AddInt32(&p.calls, 1)
+	<-p.release
+	return map[string]float64{"host1": 1}, nil
+}
+
+// TestNewHints tests the hints are only created if enabled
+func TestNewHints(t *testing.T) {
+	assert.Nil(t, NewHints(Config{}, tally.NoopScope))
+
+	h := NewHints(Config{
+		Enabled: true,
+		Static: []StaticDataset{
+			{Prefix: "s3://bucket", Hosts: []string{"host1"}},
+		},
+	}, tally.NoopScope)
+	waitForScores(t, h,
+		newTask("s3://bucket/table"),
+		map[string]float64{"host1": 1})
+}
+
+// TestStaticProvider tests the hosts of the longest matching prefix
+// are returned
+func TestStaticProvider(t *testing.T) {
+	p := NewStaticProvider([]StaticDataset{
+		{Prefix: "s3://bucket", Hosts: []string{"host1"}},
+		{Prefix: "s3://bucket/hot", Hosts: []string{"host2", "host3"}},
+		{Prefix: "gs://bucket", Hosts: []string{"host4"}},
+	})
+	assert.Equal(t, []string{"s3", "gs"}, p.Schemes())
+
+	for dataset, expected := range map[string]map[string]float64{
+		"s3://bucket/cold":  {"host1": 1},
+		"s3://bucket/hot/a": {"host2": 1, "host3": 1},
+		"s3://other/a":      nil,
+	} {
+		u, err := url.Parse(dataset)
+		assert.NoError(t, err)
+		scores, err := p.Locate(context.Background(), u)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, scores, dataset)
+	}
+}
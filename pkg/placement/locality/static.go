@@ -0,0 +1,75 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locality
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// staticProvider scores the hosts configured for the longest prefix
+// matching a dataset. It is used for the object stores such as S3, whose
+// locality is not exposed, but which can be cached or replicated close to
+// a known set of hosts.
+type staticProvider struct {
+	datasets []StaticDataset
+	schemes  []string
+}
+
+// NewStaticProvider returns a provider of the locality of the datasets
+// under the configured prefixes.
+func NewStaticProvider(datasets []StaticDataset) Provider {
+	p := &staticProvider{datasets: datasets}
+	seen := make(map[string]bool)
+	for _, dataset := range datasets {
+		u, err := url.Parse(dataset.Prefix)
+		if err != nil || u.Scheme == "" || seen[u.Scheme] {
+			continue
+		}
+		seen[u.Scheme] = true
+		p.schemes = append(p.schemes, u.Scheme)
+	}
+	return p
+}
+
+// Schemes implements Provider.
+func (p *staticProvider) Schemes() []string {
+	return p.schemes
+}
+
+// Locate implements Provider. All the hosts of the matching prefix have
+// a score of 1.
+func (p *staticProvider) Locate(
+	ctx context.Context,
+	dataset *url.URL,
+) (map[string]float64, error) {
+	var match *StaticDataset
+	for i, d := range p.datasets {
+		if strings.HasPrefix(dataset.String(), d.Prefix) &&
+			(match == nil || len(d.Prefix) > len(match.Prefix)) {
+			match = &p.datasets[i]
+		}
+	}
+	if match == nil {
+		return nil, nil
+	}
+
+	scores := make(map[string]float64, len(match.Hosts))
+	for _, host := range match.Hosts {
+		scores[host] = 1
+	}
+	return scores, nil
+}
@@ -20,6 +20,9 @@ type Assignment struct {
 	HostOffers *HostOffers `json:"host"`
 	Task       *Task       `json:"task"`
 	Reason     string
+	// ScoreSummary is the summary of how the host was chosen among the
+	// candidate hosts, set by the strategy when the task is assigned.
+	ScoreSummary string
 }
 
 // GetHost returns the host that the task was assigned to.
@@ -52,6 +55,16 @@ func (a *Assignment) SetReason(reason string) {
 	a.Reason = reason
 }
 
+// GetScoreSummary returns the summary of how the host was chosen
+func (a *Assignment) GetScoreSummary() string {
+	return a.ScoreSummary
+}
+
+// SetScoreSummary sets the summary of how the host was chosen
+func (a *Assignment) SetScoreSummary(summary string) {
+	a.ScoreSummary = summary
+}
+
 // NewAssignment will create a new empty assignment from a task.
 func NewAssignment(task *Task) *Assignment {
 	return &Assignment{
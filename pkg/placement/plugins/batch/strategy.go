@@ -15,11 +15,15 @@
 package batch
 
 import (
+	"fmt"
+	"sort"
+
 	log "github.com/sirupsen/logrus"
 
 	"github.com/uber/peloton/.gen/mesos/v1"
 	"github.com/uber/peloton/.gen/peloton/private/hostmgr/hostsvc"
 	"github.com/uber/peloton/pkg/hostmgr/scalar"
+	"github.com/uber/peloton/pkg/placement/locality"
 	"github.com/uber/peloton/pkg/placement/models"
 	"github.com/uber/peloton/pkg/placement/plugins"
 )
@@ -30,18 +34,49 @@ func New() plugins.Strategy {
 	return &batch{}
 }
 
+// NewWithLocality creates a new batch placement strategy which places the
+// tasks declaring input datasets on the hosts local to their data first.
+func NewWithLocality(hints locality.Hints) plugins.Strategy {
+	log.Info("Using batch placement strategy with data locality hints.")
+	return &batch{hints: hints}
+}
+
 // batch is the batch placement strategy which just fills up offers with tasks one at a time.
-type batch struct{}
+type batch struct {
+	// hints is the data locality of the tasks, nil if not enabled.
+	hints locality.Hints
+}
+
+// hostResources are the resources of a host offer not yet assigned to
+// a task.
+type hostResources struct {
+	scalar scalar.Resources
+	ports  uint64
+}
 
 // PlaceOnce is an implementation of the placement.Strategy interface.
 func (batch *batch) PlaceOnce(unassigned []*models.Assignment, hosts []*models.HostOffers) {
+	remaining := make(map[*models.HostOffers]*hostResources, len(hosts))
 	for _, host := range hosts {
+		remaining[host] = &hostResources{
+			scalar: scalar.FromMesosResources(host.GetOffer().GetResources()),
+			ports:  batch.availablePorts(host.GetOffer().GetResources()),
+		}
+	}
+
+	if batch.hints != nil {
+		unassigned = batch.placeLocal(unassigned, hosts, remaining)
+	}
+
+	for i, host := range hosts {
 		log.WithFields(log.Fields{
 			"unassigned": unassigned,
 			"hosts":      hosts,
 		}).Debug("PlaceOnce batch strategy called")
 
-		unassigned = batch.fillOffer(host, unassigned)
+		summary := fmt.Sprintf(
+			"first fit, host %d of %d candidate hosts", i+1, len(hosts))
+		unassigned = batch.fillOffer(host, remaining[host], unassigned, summary)
 	}
 
 	log.WithFields(log.Fields{
@@ -51,6 +86,45 @@ func (batch *batch) PlaceOnce(unassigned []*models.Assignment, hosts []*models.H
 	}).Info("PlaceOnce batch strategy returned")
 }
 
+// placeLocal assigns the tasks with a data locality hint to the host with
+// the best locality score which fits them, and returns the tasks which are
+// not assigned in their original order.
+func (batch *batch) placeLocal(
+	unassigned []*models.Assignment,
+	hosts []*models.HostOffers,
+	remaining map[*models.HostOffers]*hostResources) []*models.Assignment {
+	var result []*models.Assignment
+	for _, placement := range unassigned {
+		scores := batch.hints.Scores(placement.GetTask().GetTask())
+
+		var candidates []*models.HostOffers
+		for _, host := range hosts {
+			if scores[host.GetOffer().GetHostname()] > 0 {
+				candidates = append(candidates, host)
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return scores[candidates[i].GetOffer().GetHostname()] >
+				scores[candidates[j].GetOffer().GetHostname()]
+		})
+
+		assigned := false
+		for _, host := range candidates {
+			summary := fmt.Sprintf(
+				"data locality score %.2f, best of %d local hosts",
+				scores[host.GetOffer().GetHostname()], len(candidates))
+			if batch.tryAssign(host, remaining[host], placement, summary) {
+				assigned = true
+				break
+			}
+		}
+		if !assigned {
+			result = append(result, placement)
+		}
+	}
+	return result
+}
+
 func (batch *batch) availablePorts(resources []*mesos_v1.Resource) uint64 {
 	var ports uint64
 	for _, resource := range resources {
@@ -67,35 +141,53 @@ func (batch *batch) availablePorts(resources []*mesos_v1.Resource) uint64 {
 // fillOffer assigns in sequence as many tasks as possible to the given offers in a host,
 // and returns a list of tasks not assigned to that host.
 
-func (batch *batch) fillOffer(host *models.HostOffers, unassigned []*models.Assignment) []*models.Assignment {
-	remainPorts := batch.availablePorts(host.GetOffer().GetResources())
-	remain := scalar.FromMesosResources(host.GetOffer().GetResources())
+func (batch *batch) fillOffer(
+	host *models.HostOffers,
+	remain *hostResources,
+	unassigned []*models.Assignment,
+	summary string) []*models.Assignment {
 	for i, placement := range unassigned {
-		resmgrTask := placement.GetTask().GetTask()
-		usedPorts := uint64(resmgrTask.GetNumPorts())
-		if usedPorts > remainPorts {
-			log.WithFields(log.Fields{
-				"resmgr_task":         resmgrTask,
-				"num_available_ports": remainPorts,
-			}).Debug("Insufficient ports resources.")
+		if !batch.tryAssign(host, remain, placement, summary) {
 			return unassigned[i:]
 		}
+	}
+	return nil
+}
 
-		usage := scalar.FromResourceConfig(placement.GetTask().GetTask().GetResource())
-		trySubtract, ok := remain.TrySubtract(usage)
-		if !ok {
-			log.WithFields(log.Fields{
-				"remain": remain,
-				"usage":  usage,
-			}).Debug("Insufficient resources remain")
-			return unassigned[i:]
-		}
+// tryAssign assigns the task to the host if it fits in the remaining
+// resources of the host, and subtracts the resources of the task from
+// them. The summary of how the host was chosen is recorded in the
+// assignment.
+func (batch *batch) tryAssign(
+	host *models.HostOffers,
+	remain *hostResources,
+	placement *models.Assignment,
+	summary string) bool {
+	resmgrTask := placement.GetTask().GetTask()
+	usedPorts := uint64(resmgrTask.GetNumPorts())
+	if usedPorts > remain.ports {
+		log.WithFields(log.Fields{
+			"resmgr_task":         resmgrTask,
+			"num_available_ports": remain.ports,
+		}).Debug("Insufficient ports resources.")
+		return false
+	}
 
-		remainPorts -= usedPorts
-		remain = trySubtract
-		placement.SetHost(host)
+	usage := scalar.FromResourceConfig(resmgrTask.GetResource())
+	trySubtract, ok := remain.scalar.TrySubtract(usage)
+	if !ok {
+		log.WithFields(log.Fields{
+			"remain": remain.scalar,
+			"usage":  usage,
+		}).Debug("Insufficient resources remain")
+		return false
 	}
-	return nil
+
+	remain.ports -= usedPorts
+	remain.scalar = trySubtract
+	placement.SetHost(host)
+	placement.SetScoreSummary(summary)
+	return true
 }
 
 func (batch *batch) getHostFilter(assignment *models.Assignment) *hostsvc.HostFilter {
@@ -15,10 +15,12 @@
 package batch
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/uber/peloton/.gen/peloton/private/resmgr"
 	"github.com/uber/peloton/pkg/placement/models"
 	"github.com/uber/peloton/pkg/placement/testutil"
 )
@@ -39,6 +41,9 @@ func TestBatchPlace(t *testing.T) {
 	assert.Equal(t, offers[0], assignments[0].GetHost())
 	assert.Equal(t, offers[1], assignments[1].GetHost())
 	assert.Nil(t, assignments[2].GetHost())
+	assert.Equal(t, "first fit, host 2 of 2 candidate hosts",
+		assignments[1].GetScoreSummary())
+	assert.Empty(t, assignments[2].GetScoreSummary())
 }
 
 func TestBatchPlaceOneFreeHost(t *testing.T) {
@@ -59,6 +64,51 @@ func TestBatchPlaceOneFreeHost(t *testing.T) {
 	assert.Equal(t, offers[0], assignments[1].GetHost())
 }
 
+// localityHints are fixed data locality hints keyed by task name.
+type localityHints map[string]map[string]float64
+
+func (h localityHints) Scores(task *resmgr.Task) map[string]float64 {
+	return h[task.GetName()]
+}
+
+func TestBatchPlaceWithLocality(t *testing.T) {
+	assignments := []*models.Assignment{
+		testutil.SetupAssignment(time.Now().Add(10*time.Second), 1),
+		testutil.SetupAssignment(time.Now().Add(10*time.Second), 1),
+		testutil.SetupAssignment(time.Now().Add(10*time.Second), 1),
+		testutil.SetupAssignment(time.Now().Add(10*time.Second), 1),
+	}
+	for i, assignment := range assignments {
+		assignment.GetTask().GetTask().Name = fmt.Sprintf("task-%d", i)
+		assignment.GetTask().GetTask().Resource.CpuLimit = 20
+	}
+	offers := []*models.HostOffers{
+		testutil.SetupHostOffers(),
+		testutil.SetupHostOffers(),
+		testutil.SetupHostOffers(),
+	}
+	for i, offer := range offers {
+		offer.GetOffer().Hostname = fmt.Sprintf("host-%d", i)
+	}
+	hints := localityHints{
+		"task-0": {"host-1": 0.2, "host-2": 0.9},
+		"task-1": {"host-2": 1},
+		// host-2 is full by the time task-2 is placed
+		"task-2": {"host-2": 1},
+	}
+
+	strategy := NewWithLocality(hints)
+	strategy.PlaceOnce(assignments, offers)
+
+	assert.Equal(t, offers[2], assignments[0].GetHost())
+	assert.Equal(t, offers[2], assignments[1].GetHost())
+	assert.Equal(t, "data locality score 0.90, best of 2 local hosts",
+		assignments[0].GetScoreSummary())
+	// the tasks without a local host fill the hosts in order
+	assert.Equal(t, offers[0], assignments[2].GetHost())
+	assert.Equal(t, offers[0], assignments[3].GetHost())
+}
+
 func TestBatchFiltersWithResources(t *testing.T) {
 	assignments := []*models.Assignment{
 		testutil.SetupAssignment(time.Now().Add(10*time.Second), 1),
@@ -112,6 +112,9 @@ func makeAffinityRequirements(constraint *task.Constraint) placement.Requirement
 			subRequirements = append(subRequirements, subRequirement)
 		}
 		return requirements.NewOrRequirement(subRequirements...)
+	case task.Constraint_EXPRESSION_CONSTRAINT:
+		return newExpressionRequirement(
+			constraint.GetExpressionConstraint().GetExpression())
 	default:
 		if constraint != nil {
 			log.WithField("type", constraint.GetType()).
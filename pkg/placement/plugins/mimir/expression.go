@@ -0,0 +1,87 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mimir
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/uber/peloton/pkg/common/constraints"
+	"github.com/uber/peloton/pkg/placement/plugins/mimir/lib/model/placement"
+)
+
+// expressionRequirement is a requirement which passes for the groups whose
+// labels and relations satisfy a constraint expression.
+type expressionRequirement struct {
+	expression *constraints.Expression
+}
+
+// newExpressionRequirement returns a requirement for the expression, or a
+// requirement which never passes if the expression is not valid.
+func newExpressionRequirement(text string) placement.Requirement {
+	expression, err := constraints.CompileExpression(text)
+	if err != nil {
+		log.WithError(err).
+			WithField("expression", text).
+			Warn("invalid constraint expression")
+		return placement.FailedRequirement()
+	}
+	return &expressionRequirement{expression: expression}
+}
+
+// Passed returns true iff the labels of the group and the relations of the
+// tasks placed on it satisfy the expression.
+func (requirement *expressionRequirement) Passed(group *placement.Group, scopeSet *placement.ScopeSet,
+	entity *placement.Entity, transcript *placement.Transcript) bool {
+	match, err := requirement.expression.Evaluate(groupExpressionContext{group: group})
+	if err != nil || !match {
+		transcript.IncFailed()
+		return false
+	}
+	transcript.IncPassed()
+	return true
+}
+
+func (requirement *expressionRequirement) String() string {
+	return fmt.Sprintf("requires that the expression %v is satisfied",
+		requirement.expression)
+}
+
+func (requirement *expressionRequirement) Composite() (bool, string) {
+	return false, "expression"
+}
+
+// groupExpressionContext evaluates expressions against the labels and
+// the relations of a group.
+type groupExpressionContext struct {
+	group *placement.Group
+}
+
+func (c groupExpressionContext) HostLabelValues(key string) []string {
+	var values []string
+	for _, label := range c.group.Labels.Find(makeLabel(key, "*")) {
+		names := label.Names()
+		values = append(values, names[len(names)-1])
+	}
+	return values
+}
+
+func (c groupExpressionContext) TaskLabelCount(key, value string) uint32 {
+	if len(value) == 0 {
+		value = "*"
+	}
+	return uint32(c.group.Relations.Count(makeLabel(key, value)))
+}
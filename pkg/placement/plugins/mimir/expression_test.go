@@ -0,0 +1,65 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mimir
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	"github.com/uber/peloton/pkg/placement/plugins/mimir/lib/model/labels"
+	"github.com/uber/peloton/pkg/placement/plugins/mimir/lib/model/placement"
+)
+
+func expressionConstraint(expression string) *task.Constraint {
+	return &task.Constraint{
+		Type: task.Constraint_EXPRESSION_CONSTRAINT,
+		ExpressionConstraint: &task.ExpressionConstraint{
+			Expression: expression,
+		},
+	}
+}
+
+func TestExpressionRequirement(t *testing.T) {
+	group := placement.NewGroup("host1")
+	group.Labels.Add(labels.NewLabel(HostName, "host1"))
+	group.Labels.Add(labels.NewLabel("zone", "dca1"))
+	group.Labels.Add(labels.NewLabel("cores", "32"))
+	group.Relations.Add(labels.NewLabel("app", "web"))
+	group.Relations.Add(labels.NewLabel("app", "web"))
+	group.Relations.Add(labels.NewLabel("app", "db"))
+
+	tt := []struct {
+		expression string
+		passed     bool
+	}{
+		{`host("zone") == "dca1"`, true},
+		{`host("zone") in ["phx2", "sjc1"]`, false},
+		{`host("cores") >= 16 && tasks("app", "web") < 3`, true},
+		{`tasks("app", "web") < 2`, false},
+		{`tasks("app") == 3`, true},
+		{`host("missing") > 1`, false},
+		{`host("zone") ==`, false},
+	}
+
+	for _, test := range tt {
+		requirement := makeAffinityRequirements(expressionConstraint(test.expression))
+		transcript := placement.NewTranscript("transcript")
+		assert.Equal(t, test.passed,
+			requirement.Passed(group, placement.NewScopeSet(nil), nil, transcript),
+			test.expression)
+	}
+}
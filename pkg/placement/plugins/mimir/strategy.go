@@ -15,6 +15,7 @@
 package mimir
 
 import (
+	"fmt"
 	"math"
 
 	log "github.com/sirupsen/logrus"
@@ -113,6 +114,10 @@ func (mimir *mimir) updateAssignments(
 		}
 		host := groupsToHosts[assignment.AssignedGroup]
 		pelotonAssignment.SetHost(host)
+		pelotonAssignment.SetScoreSummary(fmt.Sprintf(
+			"best ranked of %d hosts passing the requirements, %d hosts failed",
+			assignment.Transcript.GroupsPassed,
+			assignment.Transcript.GroupsFailed))
 	}
 }
 
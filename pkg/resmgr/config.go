@@ -49,4 +49,12 @@ type Config struct {
 
 	// RecoveryConfig to recover jobs on resmgr restart
 	RecoveryConfig *common.RecoveryConfig `yaml:"recovery"`
+
+	// Period to run priority inversion detection. The detection is
+	// disabled if unset.
+	PriorityInversionPeriod time.Duration `yaml:"priority_inversion_period"`
+
+	// Minimum time a higher priority task has to be waiting while lower
+	// priority tasks are running to be reported as a priority inversion
+	PriorityInversionThreshold time.Duration `yaml:"priority_inversion_threshold"`
 }
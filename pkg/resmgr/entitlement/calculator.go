@@ -158,6 +158,8 @@ func (c *Calculator) calculateEntitlement(ctx context.Context) error {
 	rootResPool.CalculateSlackDemand()
 	// Invoking the Allocation calculation
 	rootResPool.CalculateTotalAllocatedResources()
+	// Tracking how long the resource pools burst above their reservation
+	c.updateBurstState(time.Now())
 	// Calculate Total Entitlement for non-revocable resources root respool's children
 	c.setEntitlementForChildren(rootResPool)
 	// Calculate entitlement for revocable resources and
@@ -168,6 +170,15 @@ func (c *Calculator) calculateEntitlement(ctx context.Context) error {
 	return nil
 }
 
+// updateBurstState updates the burst state of all the resource pools,
+// which caps their limit for the entitlement calculation
+func (c *Calculator) updateBurstState(now time.Time) {
+	nodes := c.resPoolTree.GetAllNodes(false)
+	for e := nodes.Front(); e != nil; e = e.Next() {
+		e.Value.(respool.ResPool).UpdateBurstState(now)
+	}
+}
+
 // getChildShare returns the combined share of the childrens
 func (c *Calculator) getChildShare(resp respool.ResPool, kind string) float64 {
 	if resp == nil {
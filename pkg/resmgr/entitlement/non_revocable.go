@@ -189,9 +189,11 @@ func (c *Calculator) calculateDemandForRespool(
 	// If demand is less then limit then we use demand for
 	// entitlement calculation otherwise we use limit as demand
 	// to cap the allocation till limit.
+	// The limit accounts for the burst ceiling of the resource pool.
+	limit := n.GetLimit()
 	limitedDemand := demand
-	for kind, res := range resConfig {
-		limitedDemand.Set(kind, math.Min(demand.Get(kind), res.GetLimit()))
+	for kind := range resConfig {
+		limitedDemand.Set(kind, math.Min(demand.Get(kind), limit.Get(kind)))
 	}
 
 	log.WithFields(log.Fields{
@@ -327,11 +329,8 @@ func (c *Calculator) distributeUnclaimedResources(
 
 				// We need to cap the limit here for free resources
 				// as we can not give more then limit to resource pool
-				if value > n.Resources()[kind].GetLimit() {
-					assignments[n.ID()].Set(
-						kind,
-						n.Resources()[kind].GetLimit(),
-					)
+				if limit := n.GetLimit().Get(kind); value > limit {
+					assignments[n.ID()].Set(kind, limit)
 				} else {
 					assignments[n.ID()].Set(kind, value)
 				}
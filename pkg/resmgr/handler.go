@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sort"
 	"sync/atomic"
 	"time"
 
@@ -63,6 +64,10 @@ const (
 
 const _eventStreamBufferSize = 1000
 
+// _defaultPendingTaskAgingLimit is the default number of oldest waiting tasks
+// returned per resource pool by GetPendingTaskAging
+const _defaultPendingTaskAgingLimit = 10
+
 // ServiceHandler implements peloton.private.resmgr.ResourceManagerService
 type ServiceHandler struct {
 	// lifecycle manager
@@ -155,7 +160,6 @@ func initEventStreamHandler(d *yarpc.Dispatcher, bufferSize int, parentScope tal
 		bufferSize,
 		[]string{
 			common.PelotonJobManager,
-			common.PelotonResourceManager,
 		},
 		nil,
 		parentScope)
@@ -254,8 +258,11 @@ func (h *ServiceHandler) enqueueGang(
 		if !(h.isTaskPresent(task)) {
 			// If the task is not present in the tracker
 			// this means its a new task and needs to be
-			// added to tracker
-			failedTask, err = h.addTask(task, respool)
+			// validated by the resource pool and added to tracker
+			failedTask, err = h.validateTask(task, respool)
+			if err == nil {
+				failedTask, err = h.addTask(task, respool)
+			}
 		} else {
 			// This is the already present task,
 			// We need to check if it has same mesos
@@ -360,6 +367,24 @@ func (h *ServiceHandler) markingTasksFailInGang(gang *resmgrsvc.Gang,
 	return failed
 }
 
+// validateTask checks that the task is allowed to be enqueued to the respool
+func (h *ServiceHandler) validateTask(newTask *resmgr.Task, respool respool.ResPool,
+) (*resmgrsvc.EnqueueGangsFailure_FailedTask, error) {
+	if err := respool.ValidateTask(newTask); err != nil {
+		log.WithError(err).
+			WithFields(log.Fields{
+				"task_id":    newTask.GetId().GetValue(),
+				"respool_id": respool.ID(),
+			}).Info("Task rejected by resource pool")
+		return &resmgrsvc.EnqueueGangsFailure_FailedTask{
+			Task:      newTask,
+			Message:   err.Error(),
+			Errorcode: resmgrsvc.EnqueueGangsFailure_ENQUEUE_GANGS_FAILURE_ERROR_CODE_TASK_REJECTED,
+		}, err
+	}
+	return nil, nil
+}
+
 // addTask adds the task to RMTracker based on the respool
 func (h *ServiceHandler) addTask(newTask *resmgr.Task, respool respool.ResPool,
 ) (*resmgrsvc.EnqueueGangsFailure_FailedTask, error) {
@@ -985,6 +1010,179 @@ func (h *ServiceHandler) getPendingGangs(node respool.ResPool,
 	return gangsInQueue, nil
 }
 
+// GetPriorityInversions returns the tasks which have been waiting for longer
+// than the threshold while lower priority tasks of the same resource pool are
+// running. The response is sorted with the longest waiting task first.
+func (h *ServiceHandler) GetPriorityInversions(
+	ctx context.Context,
+	req *resmgrsvc.GetPriorityInversionsRequest,
+) (*resmgrsvc.GetPriorityInversionsResponse, error) {
+	log.WithField("req", req).Info("GetPriorityInversions called")
+
+	threshold := h.config.PriorityInversionThreshold
+	if req.GetThresholdSeconds() > 0 {
+		threshold = time.Duration(req.GetThresholdSeconds()) * time.Second
+	}
+
+	inversions := rmtask.FindPriorityInversions(
+		h.rmTracker,
+		req.GetRespoolId().GetValue(),
+		threshold,
+		time.Now().UTC(),
+	)
+
+	var result []*resmgrsvc.PriorityInversion
+	for _, inversion := range inversions {
+		var lowerPriorityTasks []*peloton.TaskID
+		for _, t := range inversion.LowerPriorityTasks {
+			lowerPriorityTasks = append(lowerPriorityTasks, t.Task().GetId())
+		}
+		result = append(result, &resmgrsvc.PriorityInversion{
+			TaskId:         inversion.Task.Task().GetId(),
+			Priority:       inversion.Task.Task().GetPriority(),
+			State:          inversion.State.String(),
+			WaitingSeconds: inversion.WaitingDuration.Seconds(),
+			RespoolId: &peloton.ResourcePoolID{
+				Value: inversion.Task.Respool().ID(),
+			},
+			LowerPriorityTasks:    lowerPriorityTasks,
+			LowestRunningPriority: inversion.LowestRunningPriority,
+			Cause:                 toPriorityInversionCause(inversion.Cause),
+		})
+	}
+
+	log.WithField("num_inversions", len(result)).
+		Debug("GetPriorityInversions returned")
+	return &resmgrsvc.GetPriorityInversionsResponse{
+		Inversions: result,
+	}, nil
+}
+
+func toPriorityInversionCause(
+	cause rmtask.InversionCause) resmgrsvc.PriorityInversionCause {
+	switch cause {
+	case rmtask.InversionCauseCapacityBound:
+		return resmgrsvc.PriorityInversionCause_PRIORITY_INVERSION_CAUSE_CAPACITY_BOUND
+	case rmtask.InversionCauseConstraintBound:
+		return resmgrsvc.PriorityInversionCause_PRIORITY_INVERSION_CAUSE_CONSTRAINT_BOUND
+	}
+	return resmgrsvc.PriorityInversionCause_PRIORITY_INVERSION_CAUSE_UNKNOWN
+}
+
+// GetPendingTaskAging returns the age distribution of the tasks waiting to be
+// placed in each resource pool along with the oldest waiting tasks. The
+// resource pools with the oldest waiting tasks are returned first.
+func (h *ServiceHandler) GetPendingTaskAging(
+	ctx context.Context,
+	req *resmgrsvc.GetPendingTaskAgingRequest,
+) (*resmgrsvc.GetPendingTaskAgingResponse, error) {
+	log.WithField("req", req).Info("GetPendingTaskAging called")
+
+	respoolID := req.GetRespoolId().GetValue()
+	if respoolID != "" {
+		if _, err := h.resPoolTree.Get(req.GetRespoolId()); err != nil {
+			return &resmgrsvc.GetPendingTaskAgingResponse{},
+				status.Errorf(codes.NotFound,
+					"resource pool ID not found:%s", respoolID)
+		}
+	}
+
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = _defaultPendingTaskAgingLimit
+	}
+
+	agingByPool := rmtask.GetRespoolAging(
+		h.rmTracker,
+		respoolID,
+		limit,
+		time.Now().UTC(),
+	)
+
+	var respools []*resmgrsvc.GetPendingTaskAgingResponse_RespoolAging
+	for poolID, aging := range agingByPool {
+		var buckets []*resmgrsvc.GetPendingTaskAgingResponse_AgeBucket
+		for i, count := range aging.BucketCounts {
+			var upperBound float64
+			if i < len(rmtask.AgingBuckets) {
+				upperBound = rmtask.AgingBuckets[i].Seconds()
+			}
+			buckets = append(buckets,
+				&resmgrsvc.GetPendingTaskAgingResponse_AgeBucket{
+					UpperBoundSeconds: upperBound,
+					Count:             count,
+				})
+		}
+
+		var oldestTasks []*resmgrsvc.GetPendingTaskAgingResponse_WaitingTask
+		for _, t := range aging.OldestTasks {
+			oldestTasks = append(oldestTasks,
+				&resmgrsvc.GetPendingTaskAgingResponse_WaitingTask{
+					TaskId:     t.Task.Task().GetId(),
+					State:      t.State.String(),
+					AgeSeconds: t.Age.Seconds(),
+					Reason:     t.Reason,
+				})
+		}
+
+		respools = append(respools,
+			&resmgrsvc.GetPendingTaskAgingResponse_RespoolAging{
+				RespoolId:   &peloton.ResourcePoolID{Value: poolID},
+				Buckets:     buckets,
+				OldestTasks: oldestTasks,
+			})
+	}
+
+	sort.SliceStable(respools, func(i, j int) bool {
+		return respools[i].GetOldestTasks()[0].GetAgeSeconds() >
+			respools[j].GetOldestTasks()[0].GetAgeSeconds()
+	})
+
+	log.WithField("num_respools", len(respools)).
+		Debug("GetPendingTaskAging returned")
+	return &resmgrsvc.GetPendingTaskAgingResponse{
+		Respools: respools,
+	}, nil
+}
+
+// UpdateStartAfter changes the start time of the gangs of a job which are
+// held by the resource pool until the job is scheduled to start.
+func (h *ServiceHandler) UpdateStartAfter(
+	ctx context.Context,
+	req *resmgrsvc.UpdateStartAfterRequest,
+) (*resmgrsvc.UpdateStartAfterResponse, error) {
+	log.WithField("req", req).Info("UpdateStartAfter called")
+
+	var startAfter time.Time
+	if req.GetStartAfter() != "" {
+		var err error
+		startAfter, err = time.Parse(time.RFC3339, req.GetStartAfter())
+		if err != nil {
+			return &resmgrsvc.UpdateStartAfterResponse{},
+				status.Errorf(codes.InvalidArgument,
+					"invalid start time:%s", req.GetStartAfter())
+		}
+	}
+
+	resPool, err := h.resPoolTree.Get(req.GetRespoolId())
+	if err != nil {
+		return &resmgrsvc.UpdateStartAfterResponse{},
+			status.Errorf(codes.NotFound,
+				"resource pool ID not found:%s",
+				req.GetRespoolId().GetValue())
+	}
+
+	numGangs := resPool.UpdateStartAfter(req.GetJobId(), startAfter)
+
+	log.WithField("job_id", req.GetJobId().GetValue()).
+		WithField("start_after", req.GetStartAfter()).
+		WithField("num_gangs", numGangs).
+		Debug("UpdateStartAfter returned")
+	return &resmgrsvc.UpdateStartAfterResponse{
+		NumGangs: numGangs,
+	}, nil
+}
+
 // KillTasks kills the task
 func (h *ServiceHandler) KillTasks(
 	ctx context.Context,
@@ -1448,6 +1448,216 @@ func (s *HandlerTestSuite) TestGetPendingTasks() {
 // Test helpers
 // -----------------
 
+func (s *HandlerTestSuite) TestEnqueueGangsTaskRejected() {
+	respoolID := &peloton.ResourcePoolID{Value: "respool3"}
+	gang := s.pendingGang0()
+	gang.Tasks[0].Id = &peloton.TaskID{Value: "job-rejected-1"}
+
+	mr := rm.NewMockResPool(s.ctrl)
+	mr.EXPECT().ID().Return(respoolID.GetValue()).AnyTimes()
+	mr.EXPECT().ValidateTask(gang.GetTasks()[0]).
+		Return(errors.New("task too large"))
+
+	mt := rm.NewMockTree(s.ctrl)
+	mt.EXPECT().Get(respoolID).Return(mr, nil)
+
+	handler := &ServiceHandler{
+		metrics:     NewMetrics(tally.NoopScope),
+		resPoolTree: mt,
+		rmTracker:   s.rmTaskTracker,
+		config: Config{
+			RmTaskConfig: tasktestutil.CreateTaskConfig(),
+		},
+	}
+
+	resp, err := handler.EnqueueGangs(s.context, &resmgrsvc.EnqueueGangsRequest{
+		ResPool: respoolID,
+		Gangs:   []*resmgrsvc.Gang{gang},
+	})
+	s.NoError(err)
+	s.Len(resp.GetError().GetFailure().GetFailed(), 1)
+	failed := resp.GetError().GetFailure().GetFailed()[0]
+	s.Equal(gang.GetTasks()[0].GetId(), failed.GetTask().GetId())
+	s.Equal("task too large", failed.GetMessage())
+	s.Equal(
+		resmgrsvc.EnqueueGangsFailure_ENQUEUE_GANGS_FAILURE_ERROR_CODE_TASK_REJECTED,
+		failed.GetErrorcode())
+	s.Nil(s.rmTaskTracker.GetTask(gang.GetTasks()[0].GetId()))
+}
+
+func (s *HandlerTestSuite) TestGetPriorityInversions() {
+	tracker := task_mocks.NewMockTracker(s.ctrl)
+	handler := &ServiceHandler{
+		metrics:   NewMetrics(tally.NoopScope),
+		rmTracker: tracker,
+		config:    Config{},
+	}
+
+	node, err := s.resTree.Get(&peloton.ResourcePoolID{Value: "respool3"})
+	s.NoError(err)
+
+	createRMTask := func(id string, priority uint32) *rm_task.RMTask {
+		rmTask, err := rm_task.CreateRMTask(
+			tally.NoopScope,
+			&resmgr.Task{
+				Id:       &peloton.TaskID{Value: id},
+				Priority: priority,
+			},
+			nil,
+			node,
+			&rm_task.Config{
+				LaunchingTimeout: 1 * time.Minute,
+				PlacingTimeout:   1 * time.Minute,
+				PolicyName:       rm_task.ExponentialBackOffPolicy,
+			},
+		)
+		s.NoError(err)
+		return rmTask
+	}
+	pending := createRMTask("job1-1", 2)
+	running := createRMTask("job2-1", 1)
+
+	tracker.EXPECT().GetActiveTasks("", "respool3", gomock.Any()).
+		Return(map[string][]*rm_task.RMTask{
+			task.TaskState_PENDING.String(): {pending},
+			task.TaskState_RUNNING.String(): {running},
+		})
+	resp, err := handler.GetPriorityInversions(
+		s.context,
+		&resmgrsvc.GetPriorityInversionsRequest{
+			RespoolId: &peloton.ResourcePoolID{Value: "respool3"},
+		})
+	s.NoError(err)
+	s.Len(resp.GetInversions(), 1)
+	inversion := resp.GetInversions()[0]
+	s.Equal("job1-1", inversion.GetTaskId().GetValue())
+	s.Equal(uint32(2), inversion.GetPriority())
+	s.Equal(task.TaskState_PENDING.String(), inversion.GetState())
+	s.Equal("respool3", inversion.GetRespoolId().GetValue())
+	s.Equal(uint32(1), inversion.GetLowestRunningPriority())
+	s.Equal("job2-1", inversion.GetLowerPriorityTasks()[0].GetValue())
+	s.Equal(
+		resmgrsvc.PriorityInversionCause_PRIORITY_INVERSION_CAUSE_CAPACITY_BOUND,
+		inversion.GetCause())
+
+	// the tasks have not been waiting long enough
+	tracker.EXPECT().GetActiveTasks("", "", gomock.Any()).
+		Return(map[string][]*rm_task.RMTask{
+			task.TaskState_PENDING.String(): {pending},
+			task.TaskState_RUNNING.String(): {running},
+		})
+	resp, err = handler.GetPriorityInversions(
+		s.context,
+		&resmgrsvc.GetPriorityInversionsRequest{
+			ThresholdSeconds: 3600,
+		})
+	s.NoError(err)
+	s.Empty(resp.GetInversions())
+}
+
+func (s *HandlerTestSuite) TestGetPendingTaskAging() {
+	tracker := task_mocks.NewMockTracker(s.ctrl)
+	handler := &ServiceHandler{
+		metrics:     NewMetrics(tally.NoopScope),
+		resPoolTree: s.resTree,
+		rmTracker:   tracker,
+	}
+
+	node, err := s.resTree.Get(&peloton.ResourcePoolID{Value: "respool3"})
+	s.NoError(err)
+
+	rmTask, err := rm_task.CreateRMTask(
+		tally.NoopScope,
+		&resmgr.Task{Id: &peloton.TaskID{Value: "job1-1"}},
+		nil,
+		node,
+		&rm_task.Config{
+			LaunchingTimeout: 1 * time.Minute,
+			PlacingTimeout:   1 * time.Minute,
+			PolicyName:       rm_task.ExponentialBackOffPolicy,
+		},
+	)
+	s.NoError(err)
+	s.NoError(rmTask.TransitTo(task.TaskState_PENDING.String()))
+	rmTask.RunTimeStats().EnqueueTime = time.Now().Add(-2 * time.Minute)
+
+	tracker.EXPECT().GetActiveTasks("", "respool3", gomock.Any()).
+		Return(map[string][]*rm_task.RMTask{
+			task.TaskState_PENDING.String(): {rmTask},
+		})
+	resp, err := handler.GetPendingTaskAging(
+		s.context,
+		&resmgrsvc.GetPendingTaskAgingRequest{
+			RespoolId: &peloton.ResourcePoolID{Value: "respool3"},
+		})
+	s.NoError(err)
+	s.Len(resp.GetRespools(), 1)
+
+	aging := resp.GetRespools()[0]
+	s.Equal("respool3", aging.GetRespoolId().GetValue())
+	s.Len(aging.GetBuckets(), len(rm_task.AgingBuckets)+1)
+	s.Equal(uint32(1), aging.GetBuckets()[1].GetCount())
+	s.Equal(rm_task.AgingBuckets[1].Seconds(),
+		aging.GetBuckets()[1].GetUpperBoundSeconds())
+	s.Zero(aging.GetBuckets()[len(rm_task.AgingBuckets)].GetUpperBoundSeconds())
+	s.Len(aging.GetOldestTasks(), 1)
+	s.Equal("job1-1", aging.GetOldestTasks()[0].GetTaskId().GetValue())
+	s.Equal(task.TaskState_PENDING.String(),
+		aging.GetOldestTasks()[0].GetState())
+
+	// unknown resource pool
+	_, err = handler.GetPendingTaskAging(
+		s.context,
+		&resmgrsvc.GetPendingTaskAgingRequest{
+			RespoolId: &peloton.ResourcePoolID{Value: "does-not-exist"},
+		})
+	s.Error(err)
+}
+
+func (s *HandlerTestSuite) TestUpdateStartAfter() {
+	gang := s.pendingGang0()
+	gang.Tasks[0].StartAfter = time.Now().Add(time.Hour).Format(time.RFC3339)
+	enqResp, err := s.handler.EnqueueGangs(
+		s.context,
+		&resmgrsvc.EnqueueGangsRequest{
+			ResPool: &peloton.ResourcePoolID{Value: "respool3"},
+			Gangs:   []*resmgrsvc.Gang{gang},
+		})
+	s.NoError(err)
+	s.Nil(enqResp.GetError())
+
+	startAfter := time.Now().Add(2 * time.Hour).Format(time.RFC3339)
+	resp, err := s.handler.UpdateStartAfter(
+		s.context,
+		&resmgrsvc.UpdateStartAfterRequest{
+			JobId:      &peloton.JobID{Value: "job1"},
+			RespoolId:  &peloton.ResourcePoolID{Value: "respool3"},
+			StartAfter: startAfter,
+		})
+	s.NoError(err)
+	s.Equal(uint32(1), resp.GetNumGangs())
+	s.Equal(startAfter, gang.GetTasks()[0].GetStartAfter())
+
+	// invalid start time
+	_, err = s.handler.UpdateStartAfter(
+		s.context,
+		&resmgrsvc.UpdateStartAfterRequest{
+			JobId:      &peloton.JobID{Value: "job1"},
+			RespoolId:  &peloton.ResourcePoolID{Value: "respool3"},
+			StartAfter: "tomorrow",
+		})
+	s.Error(err)
+
+	// unknown resource pool
+	_, err = s.handler.UpdateStartAfter(
+		s.context,
+		&resmgrsvc.UpdateStartAfterRequest{
+			JobId:     &peloton.JobID{Value: "job1"},
+			RespoolId: &peloton.ResourcePoolID{Value: "does-not-exist"},
+		})
+	s.Error(err)
+}
+
 func (s *HandlerTestSuite) getEntitlement() *scalar.Resources {
 	return &scalar.Resources{
 		CPU:    100,
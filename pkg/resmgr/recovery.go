@@ -55,6 +55,7 @@ var (
 	taskStatesToSkip = map[task.TaskState]bool{
 		task.TaskState_SUCCEEDED:   true,
 		task.TaskState_FAILED:      true,
+		task.TaskState_FAILED_HOLD: true,
 		task.TaskState_KILLED:      true,
 		task.TaskState_LOST:        true,
 		task.TaskState_INITIALIZED: true,
@@ -15,9 +15,13 @@
 package respool
 
 import (
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
 	"github.com/uber/peloton/.gen/peloton/private/resmgr"
 	"github.com/uber/peloton/.gen/peloton/private/resmgrsvc"
 
+	"github.com/uber/peloton/pkg/common"
 	"github.com/uber/peloton/pkg/resmgr/scalar"
 
 	"github.com/pkg/errors"
@@ -28,6 +32,9 @@ var (
 	errGangInvalid          = errors.New("gang is invalid")
 	errGangValidationFailed = errors.New("gang validation failed")
 	errResourcePoolFull     = errors.New("resource pool full")
+	errTaskTooLarge         = errors.New(
+		"task requests more resources than allowed in resource pool")
+	errInvalidStartAfter = errors.New("task start time is invalid")
 
 	errSkipControllerGang = errors.New(
 		"skipping controller gang from admitting")
@@ -162,6 +169,78 @@ func reservationAdmitter(gang *resmgrsvc.Gang, pool *resPool) bool {
 		LessThanOrEqual(reservation)
 }
 
+// returns an error if the task is not allowed to be enqueued to the pool
+type taskValidator func(task *resmgr.Task, pool *resPool) error
+
+// returns an error if the task requests more resources than a single task is
+// allowed to in the pool, unless the task has the override label of the pool
+func taskSizeValidator(task *resmgr.Task, pool *resPool) error {
+	limit := pool.poolConfig.GetTaskResourceLimit()
+	if limit == nil {
+		return nil
+	}
+
+	if hasLabel(task, limit.GetOverrideLabel()) {
+		log.WithFields(log.Fields{
+			"respool_id": pool.ID(),
+			"task_id":    task.GetId().GetValue(),
+		}).Info("task resource limit overridden")
+		return nil
+	}
+
+	resource := task.GetResource()
+	for _, check := range []struct {
+		kind      string
+		requested float64
+		limit     float64
+	}{
+		{common.CPU, resource.GetCpuLimit(), limit.GetCpuLimit()},
+		{common.MEMORY, resource.GetMemLimitMb(), limit.GetMemLimitMb()},
+		{common.DISK, resource.GetDiskLimitMb(), limit.GetDiskLimitMb()},
+		{common.GPU, resource.GetGpuLimit(), limit.GetGpuLimit()},
+	} {
+		if check.limit > 0 && check.requested > check.limit {
+			return errors.Wrapf(
+				errTaskTooLarge,
+				"%s requested:%v limit:%v",
+				check.kind,
+				check.requested,
+				check.limit)
+		}
+	}
+	return nil
+}
+
+// returns an error if the start time of the task is not a valid time
+func startAfterValidator(task *resmgr.Task, pool *resPool) error {
+	if task.GetStartAfter() == "" {
+		return nil
+	}
+	if _, err := time.Parse(time.RFC3339, task.GetStartAfter()); err != nil {
+		return errors.Wrap(errInvalidStartAfter, err.Error())
+	}
+	return nil
+}
+
+// returns true if the task has the label with the same value
+func hasLabel(task *resmgr.Task, label *peloton.Label) bool {
+	if label.GetKey() == "" {
+		return false
+	}
+	for _, l := range task.GetLabels().GetLabels() {
+		if l.GetKey() == label.GetKey() && l.GetValue() == label.GetValue() {
+			return true
+		}
+	}
+	return false
+}
+
+// the validators every task has to pass to be enqueued to a resource pool
+var taskValidators = []taskValidator{
+	taskSizeValidator,
+	startAfterValidator,
+}
+
 type admissionController struct {
 	admitters []admitter
 }
@@ -15,6 +15,7 @@
 package respool
 
 import (
+	mesos "github.com/uber/peloton/.gen/mesos/v1"
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
 	"github.com/uber/peloton/.gen/peloton/api/v0/respool"
 	"github.com/uber/peloton/.gen/peloton/api/v0/task"
@@ -664,6 +665,90 @@ func (s *ResPoolSuite) TestBatchAdmissionController_NPAdmitter() {
 	}
 }
 
+func (s *ResPoolSuite) TestValidateTask_TaskSizeValidator() {
+	overrideKey := "task-size-override"
+	overrideValue := "secret"
+	wrongValue := "wrong"
+
+	poolConfig := &respool.ResourcePoolConfig{
+		Name:      _testResPoolName,
+		Parent:    &_rootResPoolID,
+		Resources: s.getResources(),
+		Policy:    respool.SchedulingPolicy_PriorityFIFO,
+		TaskResourceLimit: &respool.TaskResourceLimit{
+			CpuLimit: 2,
+			OverrideLabel: &peloton.Label{
+				Key:   overrideKey,
+				Value: overrideValue,
+			},
+		},
+	}
+
+	tt := []struct {
+		cpu    float64
+		labels []*mesos.Label
+		err    bool
+	}{
+		{ // Tests a task within the limit
+			cpu: 2,
+			err: false,
+		},
+		{ // Tests a task above the limit
+			cpu: 3,
+			err: true,
+		},
+		{ // Tests a task above the limit with the wrong override label
+			cpu: 3,
+			labels: []*mesos.Label{
+				{Key: &overrideKey, Value: &wrongValue},
+			},
+			err: true,
+		},
+		{ // Tests a task above the limit with the override label
+			cpu: 3,
+			labels: []*mesos.Label{
+				{Key: &overrideKey, Value: &overrideValue},
+			},
+			err: false,
+		},
+	}
+
+	rp := s.respoolWithConfig(poolConfig)
+	for _, t := range tt {
+		task := s.getTasks()[0]
+		task.Resource.CpuLimit = t.cpu
+		task.Labels = &mesos.Labels{Labels: t.labels}
+
+		err := rp.ValidateTask(task)
+		if t.err {
+			s.Error(err)
+			s.Contains(err.Error(), errTaskTooLarge.Error())
+		} else {
+			s.NoError(err)
+		}
+	}
+
+	// a pool without a task resource limit accepts any task
+	task := s.getTasks()[0]
+	task.Resource.CpuLimit = 1000
+	s.NoError(s.createTestResourcePool().ValidateTask(task))
+}
+
+func (s *ResPoolSuite) TestValidateTask_StartAfterValidator() {
+	rp := s.createTestResourcePool()
+
+	task := s.getTasks()[0]
+	s.NoError(rp.ValidateTask(task))
+
+	task.StartAfter = "2019-06-01T10:00:00Z"
+	s.NoError(rp.ValidateTask(task))
+
+	task.StartAfter = "tomorrow"
+	err := rp.ValidateTask(task)
+	s.Error(err)
+	s.Contains(err.Error(), errInvalidStartAfter.Error())
+}
+
 func assertFailedAdmission(s *ResPoolSuite, resPool *resPool,
 	controller bool, preemptible bool) {
 	// gang resources shouldn't account for respool allocation
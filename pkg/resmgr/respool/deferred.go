@@ -0,0 +1,115 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package respool
+
+import (
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/private/resmgrsvc"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// deferredGang is a gang held by the resource pool until its start time.
+type deferredGang struct {
+	gang       *resmgrsvc.Gang
+	startAfter time.Time
+}
+
+// gangStartAfter returns the time before which the gang is not admitted,
+// or the zero time if the gang can be admitted right away. The start time
+// of the tasks is validated when they are enqueued.
+func gangStartAfter(gang *resmgrsvc.Gang) time.Time {
+	tasks := gang.GetTasks()
+	if len(tasks) == 0 || tasks[0].GetStartAfter() == "" {
+		return time.Time{}
+	}
+
+	// all tasks in a gang belong to the same job, and so have the same
+	// start time
+	startAfter, err := time.Parse(time.RFC3339, tasks[0].GetStartAfter())
+	if err != nil {
+		return time.Time{}
+	}
+	return startAfter
+}
+
+// deferGang holds the gang until its start time.
+func (n *resPool) deferGang(gang *resmgrsvc.Gang, startAfter time.Time) {
+	n.Lock()
+	defer n.Unlock()
+
+	n.deferredGangs = append(n.deferredGangs, &deferredGang{
+		gang:       gang,
+		startAfter: startAfter,
+	})
+}
+
+// enqueueDueGangs moves the deferred gangs whose start time has come to
+// the pending queue.
+func (n *resPool) enqueueDueGangs(now time.Time) {
+	n.Lock()
+	var dueGangs []*resmgrsvc.Gang
+	deferredGangs := n.deferredGangs[:0]
+	for _, deferred := range n.deferredGangs {
+		if deferred.startAfter.After(now) {
+			deferredGangs = append(deferredGangs, deferred)
+			continue
+		}
+		dueGangs = append(dueGangs, deferred.gang)
+	}
+	n.deferredGangs = deferredGangs
+	n.Unlock()
+
+	for _, gang := range dueGangs {
+		if err := n.enqueueGang(gang); err != nil {
+			log.WithField("respool_id", n.id).
+				WithError(err).
+				Error("failed to enqueue deferred gang")
+		}
+	}
+}
+
+// UpdateStartAfter changes the time before which the deferred gangs of the
+// job are not admitted. The gangs are moved to the pending queue on the
+// next dequeue if the new start time has come.
+func (n *resPool) UpdateStartAfter(
+	jobID *peloton.JobID,
+	startAfter time.Time) uint32 {
+	n.Lock()
+	defer n.Unlock()
+
+	var startAfterStr string
+	if !startAfter.IsZero() {
+		startAfterStr = startAfter.Format(time.RFC3339)
+	}
+
+	var count uint32
+	for _, deferred := range n.deferredGangs {
+		tasks := deferred.gang.GetTasks()
+		if len(tasks) == 0 ||
+			tasks[0].GetJobId().GetValue() != jobID.GetValue() {
+			continue
+		}
+
+		deferred.startAfter = startAfter
+		for _, task := range tasks {
+			task.StartAfter = startAfterStr
+		}
+		count++
+	}
+	return count
+}
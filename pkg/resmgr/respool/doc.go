@@ -40,6 +40,12 @@ Entitlement is calculated, based on resource pools limit, reservation,
 slack limit, demand (resource requried)  for pending tasks and
 allocation (already admitted tasks).
 
+Resources with a burst ceiling cap the entitlement of the resource pool at
+the ceiling instead of the limit, while the non-revocable allocation has been
+above the reservation for less than the max duration of the burst policy.
+Once it elapsed, the entitlement is capped at the reservation, and the tasks
+allocated above it become candidates for preemption.
+
 Admission cycle iterates over above mentioned 4 queues in following sequeunce
 for each leaf resource pools
 1) Non-Preemptible
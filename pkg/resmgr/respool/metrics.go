@@ -52,6 +52,7 @@ type Metrics struct {
 	RevocableQueueSize  tally.Gauge
 	ControllerQueueSize tally.Gauge
 	NPQueueSize         tally.Gauge
+	DeferredGangs       tally.Gauge
 
 	TotalAllocation          scalar.GaugeMaps
 	NonPreemptibleAllocation scalar.GaugeMaps
@@ -75,6 +76,9 @@ type Metrics struct {
 
 	ControllerLimit scalar.GaugeMaps
 	SlackLimit      scalar.GaugeMaps
+
+	BurstDuration tally.Gauge
+	BurstExpired  tally.Gauge
 }
 
 // NewMetrics returns a new instance of respool.Metrics.
@@ -95,6 +99,7 @@ func NewMetrics(scope tally.Scope) *Metrics {
 	reservationScope := scope.SubScope("reservation")
 	limitScope := scope.SubScope("limit")
 	shareScope := scope.SubScope("share")
+	burstScope := scope.SubScope("burst")
 	return &Metrics{
 		APICreateResourcePool:          apiScope.Counter("create_resource_pool"),
 		CreateResourcePoolSuccess:      successScope.Counter("create_resource_pool"),
@@ -126,6 +131,7 @@ func NewMetrics(scope tally.Scope) *Metrics {
 		RevocableQueueSize:  queueScope.Gauge("revocable_queue_size"),
 		ControllerQueueSize: queueScope.Gauge("controller_queue_size"),
 		NPQueueSize:         queueScope.Gauge("np_queue_size"),
+		DeferredGangs:       queueScope.Gauge("deferred_gangs"),
 
 		TotalAllocation: scalar.NewGaugeMaps(allocationScope),
 		NonPreemptibleAllocation: scalar.NewGaugeMaps(allocationScope.
@@ -157,5 +163,8 @@ func NewMetrics(scope tally.Scope) *Metrics {
 			"controller_limit")),
 		SlackLimit: scalar.NewGaugeMaps(limitScope.SubScope(
 			"slack_limit")),
+
+		BurstDuration: burstScope.Gauge("duration_secs"),
+		BurstExpired:  burstScope.Gauge("expired"),
 	}
 }
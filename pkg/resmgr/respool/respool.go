@@ -18,9 +18,11 @@ import (
 	"container/list"
 	"math"
 	"sync"
+	"time"
 
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
 	"github.com/uber/peloton/.gen/peloton/api/v0/respool"
+	"github.com/uber/peloton/.gen/peloton/private/resmgr"
 	"github.com/uber/peloton/.gen/peloton/private/resmgrsvc"
 
 	"github.com/uber/peloton/pkg/common"
@@ -79,6 +81,9 @@ type ResPool interface {
 	// Aggregates the child reservations by resource type.
 	AggregatedChildrenReservations() (map[string]float64, error)
 
+	// ValidateTask returns an error if the task is not allowed to be
+	// enqueued into the resource pool.
+	ValidateTask(task *resmgr.Task) error
 	// Enqueues gang (task list) into resource pool pending queue.
 	EnqueueGang(gang *resmgrsvc.Gang) error
 	// Dequeues gangs (task list) from the resource pool.
@@ -155,10 +160,24 @@ type ResPool interface {
 	// can be used by revocable tasks.
 	GetSlackLimit() *scalar.Resources
 
+	// UpdateBurstState tracks how long the non-slack allocation of the
+	// resource pool has been above its reservation, for the resources
+	// with a burst ceiling.
+	UpdateBurstState(now time.Time)
+	// GetLimit returns the max resources the resource pool can be entitled
+	// to. The resources with a burst ceiling are capped at the ceiling
+	// while the pool bursts, and at the reservation once the max burst
+	// duration elapsed.
+	GetLimit() *scalar.Resources
+
 	// AddInvalidTask will add the killed tasks to respool which can be
 	// discarded asynchronously which scheduling.
 	AddInvalidTask(task *peloton.TaskID)
 
+	// UpdateStartAfter changes the time before which the deferred gangs of
+	// the job are not admitted, and returns the number of gangs changed.
+	UpdateStartAfter(jobID *peloton.JobID, startAfter time.Time) uint32
+
 	// UpdateResourceMetrics updates metrics for this resource pool
 	// on each entitlement cycle calculation (15s)
 	UpdateResourceMetrics()
@@ -230,6 +249,9 @@ type resPool struct {
 	// in that case the task is moved to the revocable queue so that it
 	// doesn't block the rest of the tasks in pending queue.
 	revocableQueue queue.Queue
+	// gangs whose start time has not come yet. They are moved to the
+	// pending queue once it has, and do not add to the demand until then.
+	deferredGangs []*deferredGang
 
 	// The max limit of resources controller tasks can use in this pool
 	controllerLimit *scalar.Resources
@@ -237,6 +259,18 @@ type resPool struct {
 	// the max limit of resources revocable tasks can use in this pool.
 	slackLimit *scalar.Resources
 
+	// the limit of the resources, capped at the burst ceiling of the
+	// resources which burst. nil if the pool does not burst.
+	burstLimit *scalar.Resources
+	// the max duration the non-slack allocation can stay above the
+	// reservation of the resources which burst
+	maxBurstDuration time.Duration
+	// the time the non-slack allocation went above the reservation, zero
+	// while the allocation is within the reservation
+	burstStartTime time.Time
+	// set once the pool burst for longer than maxBurstDuration
+	burstExpired bool
+
 	// set of invalid tasks which will be discarded during admission control.
 	invalidTasks map[string]bool
 
@@ -380,6 +414,20 @@ func (n *resPool) IsLeaf() bool {
 	return n.isLeaf()
 }
 
+// ValidateTask runs the task validators of the resource pool and returns
+// the first error, if any.
+func (n *resPool) ValidateTask(task *resmgr.Task) error {
+	n.RLock()
+	defer n.RUnlock()
+
+	for _, validate := range taskValidators {
+		if err := validate(task, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // EnqueueGang inserts a gang, which is a task list representing a gang
 // of 1 or more (same priority) tasks, into pending queue.
 func (n *resPool) EnqueueGang(gang *resmgrsvc.Gang) error {
@@ -392,6 +440,17 @@ func (n *resPool) EnqueueGang(gang *resmgrsvc.Gang) error {
 		return errors.Errorf("resource pool %s is not a leaf node", n.id)
 	}
 
+	if startAfter := gangStartAfter(gang); startAfter.After(time.Now()) {
+		n.deferGang(gang, startAfter)
+		return nil
+	}
+
+	return n.enqueueGang(gang)
+}
+
+// enqueueGang inserts the gang into the pending queue and adds its
+// resources to the demand.
+func (n *resPool) enqueueGang(gang *resmgrsvc.Gang) error {
 	if err := n.pendingQueue.Enqueue(gang); err != nil {
 		return err
 	}
@@ -428,6 +487,9 @@ func (n *resPool) DequeueGangs(limit int) ([]*resmgrsvc.Gang, error) {
 		return nil, err
 	}
 
+	// move the deferred gangs whose start time has come to the pending queue
+	n.enqueueDueGangs(time.Now())
+
 	var err error
 	var gangList []*resmgrsvc.Gang
 
@@ -701,6 +763,7 @@ func (n *resPool) initialize(cfg *respool.ResourcePoolConfig) {
 	n.initControllerLimit(cfg)
 	n.initSlackLimit(cfg)
 	n.initReservation(cfg)
+	n.initBurst(cfg)
 }
 
 // initializes the reserved resources
@@ -795,6 +858,103 @@ func (n *resPool) GetSlackLimit() *scalar.Resources {
 	return n.slackLimit
 }
 
+// initBurst initializes the burst ceiling of the resources which can burst
+// above the reservation.
+func (n *resPool) initBurst(cfg *respool.ResourcePoolConfig) {
+	n.burstLimit = nil
+	n.maxBurstDuration = time.Duration(
+		cfg.GetBurstPolicy().GetMaxDurationSecs()) * time.Second
+	if n.maxBurstDuration == 0 {
+		return
+	}
+
+	bursts := false
+	burstLimit := getLimits(n.resourceConfigs)
+	for kind, res := range n.resourceConfigs {
+		if res.GetBurst() > 0 {
+			burstLimit.Set(kind, math.Min(res.GetLimit(), res.GetBurst()))
+			bursts = true
+		}
+	}
+	if !bursts {
+		return
+	}
+	n.burstLimit = burstLimit
+
+	log.WithFields(log.Fields{
+		"burst_limit":        burstLimit,
+		"max_burst_duration": n.maxBurstDuration,
+		"respool_id":         n.id,
+	}).Info("Setting burst limit")
+}
+
+// UpdateBurstState tracks how long the non-slack allocation of the resource
+// pool has been above its reservation, for the resources with a burst
+// ceiling.
+func (n *resPool) UpdateBurstState(now time.Time) {
+	n.Lock()
+	defer n.Unlock()
+
+	bursting := false
+	if n.burstLimit != nil {
+		allocation := n.allocation.GetByType(scalar.NonSlackAllocation)
+		for kind, res := range n.resourceConfigs {
+			if res.GetBurst() > 0 &&
+				allocation.Get(kind) > res.GetReservation() {
+				bursting = true
+			}
+		}
+	}
+
+	if !bursting {
+		n.burstStartTime = time.Time{}
+		n.burstExpired = false
+		n.metrics.BurstDuration.Update(0)
+		n.metrics.BurstExpired.Update(0)
+		return
+	}
+
+	if n.burstStartTime.IsZero() {
+		n.burstStartTime = now
+	}
+	burstDuration := now.Sub(n.burstStartTime)
+	if !n.burstExpired && burstDuration >= n.maxBurstDuration {
+		log.WithFields(log.Fields{
+			"respool_id":     n.id,
+			"burst_start":    n.burstStartTime,
+			"burst_duration": burstDuration,
+		}).Info("Burst duration elapsed, capping entitlement at reservation")
+		n.burstExpired = true
+	}
+
+	n.metrics.BurstDuration.Update(burstDuration.Seconds())
+	if n.burstExpired {
+		n.metrics.BurstExpired.Update(1)
+	} else {
+		n.metrics.BurstExpired.Update(0)
+	}
+}
+
+// GetLimit returns the max resources the resource pool can be entitled to.
+func (n *resPool) GetLimit() *scalar.Resources {
+	n.RLock()
+	defer n.RUnlock()
+
+	if n.burstLimit == nil {
+		return getLimits(n.resourceConfigs)
+	}
+
+	limit := n.burstLimit.Clone()
+	if n.burstExpired {
+		for kind, res := range n.resourceConfigs {
+			if res.GetBurst() > 0 {
+				limit.Set(kind, res.GetReservation())
+			}
+		}
+	}
+	return limit
+}
+
 // SetEntitlement sets the entitlement of non-revocable resources
 // for non-revocable tasks + revocable tasks for this resource pool.
 func (n *resPool) SetEntitlement(res *scalar.Resources) {
@@ -1095,6 +1255,7 @@ func (n *resPool) updateDynamicResourceMetrics() {
 	n.metrics.RevocableQueueSize.Update(float64(n.aggregateQueueByType(RevocableQueue)))
 	n.metrics.ControllerQueueSize.Update(float64(n.aggregateQueueByType(ControllerQueue)))
 	n.metrics.NPQueueSize.Update(float64(n.aggregateQueueByType(NonPreemptibleQueue)))
+	n.metrics.DeferredGangs.Update(float64(len(n.deferredGangs)))
 }
 
 // aggregateQueueByType aggreagates the queue size for leaf resource pools
@@ -18,6 +18,7 @@ import (
 	"container/list"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
 	pb_respool "github.com/uber/peloton/.gen/peloton/api/v0/respool"
@@ -962,6 +963,76 @@ func (s *ResPoolSuite) TestTaskValidation() {
 	s.Equal(float64(0), newDemand.GPU)
 }
 
+// TestResPoolDeferredGang tests that gangs whose start time has not come
+// are held without adding to the demand, and are admitted once it has
+func (s *ResPoolSuite) TestResPoolDeferredGang() {
+	resPoolNode := s.createTestResourcePool()
+	resPoolNode.SetNonSlackEntitlement(s.getEntitlement())
+	rp := resPoolNode.(*resPool)
+
+	tasks := s.getTasks()
+	tasks[0].StartAfter = time.Now().Add(time.Hour).Format(time.RFC3339)
+	tasks[1].StartAfter = time.Now().Add(-time.Hour).Format(time.RFC3339)
+	for _, t := range tasks[:2] {
+		s.NoError(resPoolNode.EnqueueGang(makeTaskGang(t)))
+	}
+	s.Len(rp.deferredGangs, 1)
+	s.Equal(float64(1), resPoolNode.GetDemand().CPU)
+
+	gangs, err := resPoolNode.DequeueGangs(2)
+	s.NoError(err)
+	s.Len(gangs, 1)
+	s.Equal(tasks[1].GetId(), gangs[0].GetTasks()[0].GetId())
+
+	// the start time of the held gang comes
+	rp.enqueueDueGangs(time.Now().Add(2 * time.Hour))
+	s.Empty(rp.deferredGangs)
+	s.Equal(float64(1), resPoolNode.GetDemand().CPU)
+
+	gangs, err = resPoolNode.DequeueGangs(2)
+	s.NoError(err)
+	s.Len(gangs, 1)
+	s.Equal(tasks[0].GetId(), gangs[0].GetTasks()[0].GetId())
+}
+
+// TestResPoolUpdateStartAfter tests changing the start time of the held
+// gangs of a job
+func (s *ResPoolSuite) TestResPoolUpdateStartAfter() {
+	resPoolNode := s.createTestResourcePool()
+	resPoolNode.SetNonSlackEntitlement(s.getEntitlement())
+	rp := resPoolNode.(*resPool)
+
+	startAfter := time.Now().Add(time.Hour).Format(time.RFC3339)
+	for _, t := range s.getTasks() {
+		t.StartAfter = startAfter
+		s.NoError(resPoolNode.EnqueueGang(makeTaskGang(t)))
+	}
+	s.Len(rp.deferredGangs, 4)
+
+	// postpone job1
+	newStartAfter := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+	s.Equal(uint32(2), resPoolNode.UpdateStartAfter(
+		&peloton.JobID{Value: "job1"}, newStartAfter))
+	for _, deferred := range rp.deferredGangs[:2] {
+		s.True(newStartAfter.Equal(deferred.startAfter))
+		s.Equal(
+			newStartAfter.Format(time.RFC3339),
+			deferred.gang.GetTasks()[0].GetStartAfter())
+	}
+
+	// start job2 right away
+	s.Equal(uint32(2), resPoolNode.UpdateStartAfter(
+		&peloton.JobID{Value: "job2"}, time.Time{}))
+	gangs, err := resPoolNode.DequeueGangs(4)
+	s.NoError(err)
+	s.Len(gangs, 2)
+	for _, gang := range gangs {
+		s.Equal("job2", gang.GetTasks()[0].GetJobId().GetValue())
+		s.Empty(gang.GetTasks()[0].GetStartAfter())
+	}
+	s.Len(rp.deferredGangs, 2)
+}
+
 func (s *ResPoolSuite) TestSetParent() {
 	resPool := s.createTestResourcePool()
 	s.Equal(resPool.GetPath(), "/"+_testResPoolName)
@@ -1186,6 +1257,57 @@ func (s *ResPoolSuite) TestResPoolControllerLimit() {
 	s.Equal(float64(1), resPool.controllerLimit.CPU)
 }
 
+// TestResPoolBurst tests that the limit of a resource pool is capped at the
+// burst ceiling while it bursts above its reservation, and at the
+// reservation once the max burst duration elapsed
+func (s *ResPoolSuite) TestResPoolBurst() {
+	resources := s.getResources()
+	resources[0].Burst = 500
+	poolConfig := &pb_respool.ResourcePoolConfig{
+		Name:        _testResPoolName,
+		Parent:      &_rootResPoolID,
+		Resources:   resources,
+		Policy:      pb_respool.SchedulingPolicy_PriorityFIFO,
+		BurstPolicy: &pb_respool.BurstPolicy{MaxDurationSecs: 60},
+	}
+	node, err := NewRespool(tally.NoopScope, uuid.New(), s.root,
+		poolConfig, s.cfg)
+	s.NoError(err)
+
+	// not bursting, the cpu limit is capped at the burst ceiling
+	now := time.Now()
+	node.UpdateBurstState(now)
+	s.Equal(float64(500), node.GetLimit().CPU)
+	s.Equal(float64(1000), node.GetLimit().MEMORY)
+
+	// bursting above the cpu reservation
+	burstAlloc := scalar.GetTaskAllocation(&resmgr.Task{
+		Resource: &task.ResourceConfig{CpuLimit: 200},
+	})
+	s.NoError(node.AddToAllocation(burstAlloc))
+	node.UpdateBurstState(now)
+	node.UpdateBurstState(now.Add(59 * time.Second))
+	s.Equal(float64(500), node.GetLimit().CPU)
+
+	// the burst duration elapsed
+	node.UpdateBurstState(now.Add(60 * time.Second))
+	s.Equal(float64(100), node.GetLimit().CPU)
+	s.Equal(float64(1000), node.GetLimit().DISK)
+
+	// back within the reservation
+	s.NoError(node.SubtractFromAllocation(burstAlloc))
+	node.UpdateBurstState(now.Add(61 * time.Second))
+	s.Equal(float64(500), node.GetLimit().CPU)
+}
+
+// TestResPoolNoBurst tests that the limit of a resource pool without
+// burst policy is the limit of its resources
+func (s *ResPoolSuite) TestResPoolNoBurst() {
+	node := s.createTestResourcePool()
+	node.UpdateBurstState(time.Now())
+	s.Equal(getLimits(node.Resources()), node.GetLimit())
+}
+
 func (s *ResPoolSuite) TestAggregateQueue() {
 	respool1ID := peloton.ResourcePoolID{Value: "respool1"}
 	respool2ID := peloton.ResourcePoolID{Value: "respool2"}
@@ -63,6 +63,8 @@ func NewResourcePoolConfigValidator(rTree Tree) (Validator, error) {
 			ValidateSiblings,
 			ValidateChildrenReservations,
 			ValidateControllerLimit,
+			ValidateTaskResourceLimit,
+			ValidateBurst,
 		},
 	)
 }
@@ -349,3 +351,65 @@ func ValidateControllerLimit(_ Tree,
 	}
 	return nil
 }
+
+// ValidateTaskResourceLimit validates the task resource limit
+func ValidateTaskResourceLimit(_ Tree,
+	resourcePoolConfigData ResourcePoolConfigData) error {
+	limit := resourcePoolConfigData.ResourcePoolConfig.GetTaskResourceLimit()
+	if limit == nil {
+		return nil
+	}
+
+	if limit.GetCpuLimit() < 0 ||
+		limit.GetMemLimitMb() < 0 ||
+		limit.GetDiskLimitMb() < 0 ||
+		limit.GetGpuLimit() < 0 {
+		return errors.New("task resource limit cannot be negative")
+	}
+
+	overrideLabel := limit.GetOverrideLabel()
+	if overrideLabel != nil &&
+		(overrideLabel.GetKey() == "" || overrideLabel.GetValue() == "") {
+		return errors.New("task resource limit, " +
+			"override label needs both a key and a value")
+	}
+
+	for _, principal := range limit.GetOverridePrincipals() {
+		if principal == "" {
+			return errors.New("task resource limit, " +
+				"override principal cannot be empty")
+		}
+	}
+	return nil
+}
+
+// ValidateBurst validates the burst ceilings and the burst policy
+func ValidateBurst(_ Tree,
+	resourcePoolConfigData ResourcePoolConfigData) error {
+	resPoolConfig := resourcePoolConfigData.ResourcePoolConfig
+
+	bursts := false
+	for _, res := range resPoolConfig.GetResources() {
+		if res.GetBurst() == 0 {
+			continue
+		}
+		if res.GetBurst() < res.GetReservation() ||
+			res.GetBurst() > res.GetLimit() {
+			return errors.Errorf(
+				"resource %s, burst %v is not between reservation %v "+
+					"and limit %v",
+				res.GetKind(),
+				res.GetBurst(),
+				res.GetReservation(),
+				res.GetLimit(),
+			)
+		}
+		bursts = true
+	}
+
+	if bursts && resPoolConfig.GetBurstPolicy().GetMaxDurationSecs() == 0 {
+		return errors.New("burst policy, " +
+			"max duration is required for resources with a burst")
+	}
+	return nil
+}
@@ -789,6 +789,140 @@ func (s *resPoolConfigValidatorSuite) TestValidateControllerLimit() {
 	}
 }
 
+func (s *resPoolConfigValidatorSuite) TestValidateTaskResourceLimit() {
+	rv := &resourcePoolConfigValidator{resTree: s.resourceTree}
+	_, err := rv.Register(
+		[]ResourcePoolConfigValidatorFunc{
+			ValidateTaskResourceLimit,
+		},
+	)
+	s.NoError(err)
+
+	tt := []struct {
+		limit *pb_respool.TaskResourceLimit
+		err   error
+	}{
+		{
+			limit: nil,
+			err:   nil,
+		},
+		{
+			limit: &pb_respool.TaskResourceLimit{CpuLimit: -1},
+			err:   errors.New("task resource limit cannot be negative"),
+		},
+		{
+			limit: &pb_respool.TaskResourceLimit{
+				CpuLimit:      32,
+				OverrideLabel: &peloton.Label{Key: "override"},
+			},
+			err: errors.New("task resource limit, " +
+				"override label needs both a key and a value"),
+		},
+		{
+			limit: &pb_respool.TaskResourceLimit{
+				CpuLimit:           32,
+				OverridePrincipals: []string{""},
+			},
+			err: errors.New("task resource limit, " +
+				"override principal cannot be empty"),
+		},
+		{
+			limit: &pb_respool.TaskResourceLimit{
+				CpuLimit: 32,
+				OverrideLabel: &peloton.Label{
+					Key:   "override",
+					Value: "secret",
+				},
+				OverridePrincipals: []string{"spiffe://peloton/admin"},
+			},
+			err: nil,
+		},
+	}
+
+	for _, t := range tt {
+		resourcePoolConfigData := ResourcePoolConfigData{
+			ResourcePoolConfig: &pb_respool.ResourcePoolConfig{
+				TaskResourceLimit: t.limit,
+			},
+		}
+		err = rv.Validate(resourcePoolConfigData)
+		if t.err != nil {
+			s.EqualError(t.err, err.Error())
+		} else {
+			s.NoError(err)
+		}
+	}
+}
+
+func (s *resPoolConfigValidatorSuite) TestValidateBurst() {
+	rv := &resourcePoolConfigValidator{resTree: s.resourceTree}
+	_, err := rv.Register(
+		[]ResourcePoolConfigValidatorFunc{
+			ValidateBurst,
+		},
+	)
+	s.NoError(err)
+
+	policy := &pb_respool.BurstPolicy{MaxDurationSecs: 600}
+
+	tt := []struct {
+		burst  float64
+		policy *pb_respool.BurstPolicy
+		err    error
+	}{
+		{
+			burst:  0,
+			policy: nil,
+			err:    nil,
+		},
+		{
+			burst:  15,
+			policy: policy,
+			err:    nil,
+		},
+		{
+			burst:  5,
+			policy: policy,
+			err: errors.New("resource cpu, burst 5 is not between " +
+				"reservation 10 and limit 20"),
+		},
+		{
+			burst:  25,
+			policy: policy,
+			err: errors.New("resource cpu, burst 25 is not between " +
+				"reservation 10 and limit 20"),
+		},
+		{
+			burst:  15,
+			policy: nil,
+			err: errors.New("burst policy, " +
+				"max duration is required for resources with a burst"),
+		},
+	}
+
+	for _, t := range tt {
+		resourcePoolConfigData := ResourcePoolConfigData{
+			ResourcePoolConfig: &pb_respool.ResourcePoolConfig{
+				Resources: []*pb_respool.ResourceConfig{
+					{
+						Kind:        "cpu",
+						Reservation: 10,
+						Limit:       20,
+						Burst:       t.burst,
+					},
+				},
+				BurstPolicy: t.policy,
+			},
+		}
+		err = rv.Validate(resourcePoolConfigData)
+		if t.err != nil {
+			s.EqualError(t.err, err.Error())
+		} else {
+			s.NoError(err)
+		}
+	}
+}
+
 func TestResPoolConfigValidator(t *testing.T) {
 	suite.Run(t, new(resPoolConfigValidatorSuite))
 }
@@ -50,6 +50,7 @@ type Server struct {
 	reconciler            ServerProcess
 	drainer               ServerProcess
 	preemptor             ServerProcess
+	inversionDetector     ServerProcess
 
 	// TODO move these to use ServerProcess
 	getTaskScheduler func() task.Scheduler
@@ -67,7 +68,8 @@ func NewServer(
 	entitlementCalculator ServerProcess,
 	reconciler ServerProcess,
 	preemptor ServerProcess,
-	drainer ServerProcess) *Server {
+	drainer ServerProcess,
+	inversionDetector ServerProcess) *Server {
 	return &Server{
 		ID:                    leader.NewID(httpPort, grpcPort),
 		role:                  common.ResourceManagerRole,
@@ -80,6 +82,7 @@ func NewServer(
 		reconciler:            reconciler,
 		preemptor:             preemptor,
 		drainer:               drainer,
+		inversionDetector:     inversionDetector,
 		metrics:               NewMetrics(parent),
 	}
 }
@@ -164,6 +167,13 @@ func (s *Server) GainedLeadershipCallback() error {
 		return err
 	}
 
+	// Start the priority inversion detector
+	if err := s.inversionDetector.Start(); err != nil {
+		log.WithError(err).
+			Error("Failed to start priority inversion detector")
+		return err
+	}
+
 	return nil
 }
 
@@ -175,6 +185,11 @@ func (s *Server) LostLeadershipCallback() error {
 	log.WithField("role", s.role).Info("Lost leadership")
 	s.metrics.Elected.Update(0.0)
 
+	if err := s.inversionDetector.Stop(); err != nil {
+		log.Errorf("Failed to stop priority inversion detector")
+		return err
+	}
+
 	if err := s.drainer.Stop(); err != nil {
 		log.Errorf("Failed to stop host drainer")
 		return err
@@ -172,6 +172,24 @@ func TestServer_GainedLeadershipCallback(t *testing.T) {
 				reconciler:            &FakeServerProcess{nil},
 				preemptor:             &FakeServerProcess{nil},
 				drainer:               &FakeServerProcess{nil},
+				inversionDetector:     &FakeServerProcess{errFake},
+			},
+			wantErr: errFake,
+		},
+		{
+			s: &Server{
+				role:                  "testResMgr",
+				metrics:               NewMetrics(tally.NoopScope),
+				resTree:               &FakeServerProcess{nil},
+				recoveryHandler:       &FakeServerProcess{nil},
+				resMgrHandler:         &FakeServerProcess{nil},
+				resPoolHandler:        &FakeServerProcess{nil},
+				entitlementCalculator: &FakeServerProcess{nil},
+				getTaskScheduler:      mockSchedulerWithErr(nil, t),
+				reconciler:            &FakeServerProcess{nil},
+				preemptor:             &FakeServerProcess{nil},
+				drainer:               &FakeServerProcess{nil},
+				inversionDetector:     &FakeServerProcess{nil},
 			},
 			wantErr: nil,
 		},
@@ -194,39 +212,51 @@ func TestServer_LostLeadershipCallback(t *testing.T) {
 	}{
 		{
 			s: &Server{
-				role:    "testResMgr",
-				metrics: NewMetrics(tally.NoopScope),
-				drainer: &FakeServerProcess{errFake},
+				role:              "testResMgr",
+				metrics:           NewMetrics(tally.NoopScope),
+				inversionDetector: &FakeServerProcess{errFake},
+			},
+			wantErr: errFake,
+		},
+		{
+			s: &Server{
+				role:              "testResMgr",
+				metrics:           NewMetrics(tally.NoopScope),
+				drainer:           &FakeServerProcess{errFake},
+				inversionDetector: &FakeServerProcess{nil},
 			},
 			wantErr: errFake,
 		},
 		{
 			s: &Server{
-				role:      "testResMgr",
-				metrics:   NewMetrics(tally.NoopScope),
-				drainer:   &FakeServerProcess{nil},
-				preemptor: &FakeServerProcess{errFake},
+				role:              "testResMgr",
+				metrics:           NewMetrics(tally.NoopScope),
+				drainer:           &FakeServerProcess{nil},
+				inversionDetector: &FakeServerProcess{nil},
+				preemptor:         &FakeServerProcess{errFake},
 			},
 			wantErr: errFake,
 		},
 		{
 			s: &Server{
-				role:       "testResMgr",
-				metrics:    NewMetrics(tally.NoopScope),
-				drainer:    &FakeServerProcess{nil},
-				preemptor:  &FakeServerProcess{nil},
-				reconciler: &FakeServerProcess{errFake},
+				role:              "testResMgr",
+				metrics:           NewMetrics(tally.NoopScope),
+				drainer:           &FakeServerProcess{nil},
+				inversionDetector: &FakeServerProcess{nil},
+				preemptor:         &FakeServerProcess{nil},
+				reconciler:        &FakeServerProcess{errFake},
 			},
 			wantErr: errFake,
 		},
 		{
 			s: &Server{
-				role:             "testResMgr",
-				metrics:          NewMetrics(tally.NoopScope),
-				drainer:          &FakeServerProcess{nil},
-				preemptor:        &FakeServerProcess{nil},
-				reconciler:       &FakeServerProcess{nil},
-				getTaskScheduler: mockSchedulerWithErr(errFake, t),
+				role:              "testResMgr",
+				metrics:           NewMetrics(tally.NoopScope),
+				drainer:           &FakeServerProcess{nil},
+				inversionDetector: &FakeServerProcess{nil},
+				preemptor:         &FakeServerProcess{nil},
+				reconciler:        &FakeServerProcess{nil},
+				getTaskScheduler:  mockSchedulerWithErr(errFake, t),
 			},
 			wantErr: errFake,
 		},
@@ -235,6 +265,7 @@ func TestServer_LostLeadershipCallback(t *testing.T) {
 				role:                  "testResMgr",
 				metrics:               NewMetrics(tally.NoopScope),
 				drainer:               &FakeServerProcess{nil},
+				inversionDetector:     &FakeServerProcess{nil},
 				preemptor:             &FakeServerProcess{nil},
 				reconciler:            &FakeServerProcess{nil},
 				getTaskScheduler:      mockSchedulerWithErr(nil, t),
@@ -247,6 +278,7 @@ func TestServer_LostLeadershipCallback(t *testing.T) {
 				role:                  "testResMgr",
 				metrics:               NewMetrics(tally.NoopScope),
 				drainer:               &FakeServerProcess{nil},
+				inversionDetector:     &FakeServerProcess{nil},
 				preemptor:             &FakeServerProcess{nil},
 				reconciler:            &FakeServerProcess{nil},
 				getTaskScheduler:      mockSchedulerWithErr(nil, t),
@@ -260,6 +292,7 @@ func TestServer_LostLeadershipCallback(t *testing.T) {
 				role:                  "testResMgr",
 				metrics:               NewMetrics(tally.NoopScope),
 				drainer:               &FakeServerProcess{nil},
+				inversionDetector:     &FakeServerProcess{nil},
 				preemptor:             &FakeServerProcess{nil},
 				reconciler:            &FakeServerProcess{nil},
 				getTaskScheduler:      mockSchedulerWithErr(nil, t),
@@ -274,6 +307,7 @@ func TestServer_LostLeadershipCallback(t *testing.T) {
 				role:                  "testResMgr",
 				metrics:               NewMetrics(tally.NoopScope),
 				drainer:               &FakeServerProcess{nil},
+				inversionDetector:     &FakeServerProcess{nil},
 				preemptor:             &FakeServerProcess{nil},
 				reconciler:            &FakeServerProcess{nil},
 				entitlementCalculator: &FakeServerProcess{nil},
@@ -289,6 +323,7 @@ func TestServer_LostLeadershipCallback(t *testing.T) {
 				role:                  "testResMgr",
 				metrics:               NewMetrics(tally.NoopScope),
 				drainer:               &FakeServerProcess{nil},
+				inversionDetector:     &FakeServerProcess{nil},
 				preemptor:             &FakeServerProcess{nil},
 				reconciler:            &FakeServerProcess{nil},
 				entitlementCalculator: &FakeServerProcess{nil},
@@ -305,6 +340,7 @@ func TestServer_LostLeadershipCallback(t *testing.T) {
 				role:                  "testResMgr",
 				metrics:               NewMetrics(tally.NoopScope),
 				drainer:               &FakeServerProcess{nil},
+				inversionDetector:     &FakeServerProcess{nil},
 				preemptor:             &FakeServerProcess{nil},
 				reconciler:            &FakeServerProcess{nil},
 				entitlementCalculator: &FakeServerProcess{nil},
@@ -340,6 +376,7 @@ func TestServer_GetID(t *testing.T) {
 		&FakeServerProcess{nil},
 		&FakeServerProcess{nil},
 		&FakeServerProcess{nil},
+		&FakeServerProcess{nil},
 	)
 
 	assert.NotNil(t, s)
@@ -366,6 +403,7 @@ func TestServer_ShutDownCallback(t *testing.T) {
 		&FakeServerProcess{nil},
 		&FakeServerProcess{nil},
 		&FakeServerProcess{nil},
+		&FakeServerProcess{nil},
 	)
 
 	assert.NoError(t, s.ShutDownCallback())
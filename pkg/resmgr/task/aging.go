@@ -0,0 +1,116 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package task
+
+import (
+	"sort"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+)
+
+// AgingBuckets are the upper bounds of the age buckets used to report the
+// age distribution of waiting tasks. Tasks older than the last bound are
+// counted in an extra, unbounded, bucket.
+var AgingBuckets = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+}
+
+// WaitingTaskAge describes how long a task has been waiting to be placed
+// and why it is still waiting.
+type WaitingTaskAge struct {
+	// The waiting task
+	Task *RMTask
+	// The current state of the task
+	State task.TaskState
+	// How long the task has been waiting
+	Age time.Duration
+	// The reason of the last state transition of the task
+	Reason string
+}
+
+// RespoolAging is the age distribution of the waiting tasks of a resource
+// pool.
+type RespoolAging struct {
+	// The resource pool ID
+	RespoolID string
+	// BucketCounts[i] is the number of tasks whose age is below
+	// AgingBuckets[i] and not below AgingBuckets[i-1]. The last element
+	// counts the tasks older than all the buckets.
+	BucketCounts []uint32
+	// The oldest waiting tasks, oldest first
+	OldestTasks []*WaitingTaskAge
+}
+
+// GetRespoolAging returns the age distribution of the waiting tasks of the
+// tracker grouped by resource pool, along with the limit oldest tasks of
+// each pool. If respoolID is non-empty only that resource pool is reported.
+func GetRespoolAging(
+	tracker activeTasksTracker,
+	respoolID string,
+	limit int,
+	now time.Time) map[string]*RespoolAging {
+	tasksByPool := make(map[string][]*WaitingTaskAge)
+
+	tasksByState := tracker.GetActiveTasks("", respoolID, waitingStates)
+	for _, state := range waitingStates {
+		for _, t := range tasksByState[state] {
+			poolID := t.Respool().ID()
+			tasksByPool[poolID] = append(tasksByPool[poolID], &WaitingTaskAge{
+				Task:   t,
+				State:  task.TaskState(task.TaskState_value[state]),
+				Age:    now.Sub(t.RunTimeStats().EnqueueTime),
+				Reason: t.GetCurrentState().Reason,
+			})
+		}
+	}
+
+	result := make(map[string]*RespoolAging)
+	for poolID, tasks := range tasksByPool {
+		aging := &RespoolAging{
+			RespoolID:    poolID,
+			BucketCounts: make([]uint32, len(AgingBuckets)+1),
+		}
+		for _, t := range tasks {
+			aging.BucketCounts[agingBucket(t.Age)]++
+		}
+
+		sort.SliceStable(tasks, func(i, j int) bool {
+			return tasks[i].Age > tasks[j].Age
+		})
+		if limit > 0 && len(tasks) > limit {
+			tasks = tasks[:limit]
+		}
+		aging.OldestTasks = tasks
+
+		result[poolID] = aging
+	}
+	return result
+}
+
+// agingBucket returns the index of the bucket the age falls in.
+func agingBucket(age time.Duration) int {
+	for i, bound := range AgingBuckets {
+		if age < bound {
+			return i
+		}
+	}
+	return len(AgingBuckets)
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	"github.com/uber/peloton/.gen/peloton/private/resmgr"
+
+	"github.com/uber/peloton/pkg/common/statemachine"
+	"github.com/uber/peloton/pkg/resmgr/respool/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally"
+)
+
+func TestGetRespoolAging(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pool := mocks.NewMockResPool(ctrl)
+	pool.EXPECT().ID().Return("pool1").AnyTimes()
+	pool.EXPECT().GetPath().Return("/pool1").AnyTimes()
+
+	now := time.Now()
+	createTask := func(id string, age time.Duration) *RMTask {
+		rmTask, err := CreateRMTask(
+			tally.NoopScope,
+			&resmgr.Task{Id: &peloton.TaskID{Value: id}},
+			nil,
+			pool,
+			&Config{
+				LaunchingTimeout: time.Minute,
+				PlacingTimeout:   time.Minute,
+			},
+		)
+		assert.NoError(t, err)
+		assert.NoError(t, rmTask.TransitTo(
+			task.TaskState_PENDING.String(),
+			statemachine.WithReason("waiting for entitlement")))
+		rmTask.RunTimeStats().EnqueueTime = now.Add(-age)
+		return rmTask
+	}
+
+	young := createTask("young", 10*time.Second)
+	middle := createTask("middle", 10*time.Minute)
+	old := createTask("old", 2*time.Hour)
+	ancient := createTask("ancient", 48*time.Hour)
+
+	tracker := &fakeActiveTasksTracker{
+		tasks: map[string][]*RMTask{
+			task.TaskState_PENDING.String(): {young, middle, old, ancient},
+		},
+	}
+
+	result := GetRespoolAging(tracker, "", 2, now)
+	assert.Len(t, result, 1)
+
+	aging := result["pool1"]
+	assert.Equal(t, "pool1", aging.RespoolID)
+	assert.Equal(t, []uint32{1, 0, 1, 0, 1, 0, 1}, aging.BucketCounts)
+
+	assert.Len(t, aging.OldestTasks, 2)
+	assert.Equal(t, ancient, aging.OldestTasks[0].Task)
+	assert.Equal(t, 48*time.Hour, aging.OldestTasks[0].Age)
+	assert.Equal(t, task.TaskState_PENDING, aging.OldestTasks[0].State)
+	assert.Equal(t, "waiting for entitlement", aging.OldestTasks[0].Reason)
+	assert.Equal(t, old, aging.OldestTasks[1].Task)
+}
+
+func TestAgingBucket(t *testing.T) {
+	assert.Equal(t, 0, agingBucket(0))
+	assert.Equal(t, 1, agingBucket(time.Minute))
+	assert.Equal(t, len(AgingBuckets), agingBucket(30*24*time.Hour))
+}
@@ -0,0 +1,273 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package task
+
+import (
+	"sort"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/uber/peloton/pkg/common/lifecycle"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/uber-go/tally"
+)
+
+// InversionCause is a hint for why a higher priority task is still waiting
+// while lower priority tasks of the same resource pool are running.
+type InversionCause int
+
+const (
+	// InversionCauseUnknown means that the cause could not be determined.
+	InversionCauseUnknown InversionCause = iota
+	// InversionCauseCapacityBound means that the task is waiting for
+	// resources, either for entitlement in its resource pool or for
+	// capacity in the cluster.
+	InversionCauseCapacityBound
+	// InversionCauseConstraintBound means that the task has been admitted
+	// but its placement constraints can't be satisfied.
+	InversionCauseConstraintBound
+)
+
+// String returns the string representation of the cause.
+func (c InversionCause) String() string {
+	switch c {
+	case InversionCauseCapacityBound:
+		return "capacity_bound"
+	case InversionCauseConstraintBound:
+		return "constraint_bound"
+	}
+	return "unknown"
+}
+
+var (
+	// the states in which a task is waiting to be placed
+	waitingStates = []string{
+		task.TaskState_PENDING.String(),
+		task.TaskState_READY.String(),
+		task.TaskState_PLACING.String(),
+	}
+
+	// the states in which a task holds resources on a host
+	runningStates = []string{
+		task.TaskState_LAUNCHING.String(),
+		task.TaskState_LAUNCHED.String(),
+		task.TaskState_RUNNING.String(),
+	}
+)
+
+// PriorityInversion describes a task which has been waiting for longer than
+// the threshold while lower priority tasks of the same resource pool are
+// running.
+type PriorityInversion struct {
+	// The waiting task
+	Task *RMTask
+	// The state the waiting task is in
+	State task.TaskState
+	// How long the task has been waiting
+	WaitingDuration time.Duration
+	// The running tasks in the same resource pool with a lower priority
+	LowerPriorityTasks []*RMTask
+	// The lowest priority among the running tasks
+	LowestRunningPriority uint32
+	// Hint for why the task is still waiting
+	Cause InversionCause
+}
+
+// FindPriorityInversions returns the priority inversions among the active
+// tasks of the tracker. If respoolID is non-empty only tasks of that
+// resource pool are considered. A task is only reported if it has been
+// waiting for at least threshold.
+func FindPriorityInversions(
+	tracker activeTasksTracker,
+	respoolID string,
+	threshold time.Duration,
+	now time.Time) []*PriorityInversion {
+	var inversions []*PriorityInversion
+
+	tasksByState := tracker.GetActiveTasks(
+		"",
+		respoolID,
+		append(append([]string{}, waitingStates...), runningStates...),
+	)
+
+	// group the running tasks by resource pool
+	running := make(map[string][]*RMTask)
+	for _, state := range runningStates {
+		for _, t := range tasksByState[state] {
+			poolID := t.Respool().ID()
+			running[poolID] = append(running[poolID], t)
+		}
+	}
+
+	for _, state := range waitingStates {
+		for _, t := range tasksByState[state] {
+			waiting := now.Sub(t.RunTimeStats().EnqueueTime)
+			if waiting < threshold {
+				continue
+			}
+
+			priority := t.Task().GetPriority()
+			var lower []*RMTask
+			lowest := priority
+			for _, r := range running[t.Respool().ID()] {
+				if r.Task().GetPriority() >= priority {
+					continue
+				}
+				lower = append(lower, r)
+				if r.Task().GetPriority() < lowest {
+					lowest = r.Task().GetPriority()
+				}
+			}
+			if len(lower) == 0 {
+				continue
+			}
+
+			inversions = append(inversions, &PriorityInversion{
+				Task:                  t,
+				State:                 task.TaskState(task.TaskState_value[state]),
+				WaitingDuration:       waiting,
+				LowerPriorityTasks:    lower,
+				LowestRunningPriority: lowest,
+				Cause:                 inversionCause(t, state),
+			})
+		}
+	}
+
+	// report the longest waiting tasks first
+	sort.SliceStable(inversions, func(i, j int) bool {
+		return inversions[i].WaitingDuration > inversions[j].WaitingDuration
+	})
+	return inversions
+}
+
+// inversionCause returns the hint for why the task is still waiting.
+// A task in PENDING has not been admitted by its resource pool, so it is
+// bound by the entitlement of the pool. An admitted task which can't be
+// placed is bound by its constraints if it has any, otherwise by the
+// capacity of the cluster.
+func inversionCause(t *RMTask, state string) InversionCause {
+	if state == task.TaskState_PENDING.String() {
+		return InversionCauseCapacityBound
+	}
+	if t.Task().GetConstraint() != nil || t.Task().GetDesiredHost() != "" {
+		return InversionCauseConstraintBound
+	}
+	return InversionCauseCapacityBound
+}
+
+// InversionDetector periodically looks for priority inversions in the
+// resource pools and publishes them as metrics.
+type InversionDetector struct {
+	lifeCycle       lifecycle.LifeCycle
+	tracker         activeTasksTracker
+	detectionPeriod time.Duration
+	threshold       time.Duration
+	metrics         *inversionMetrics
+}
+
+// NewInversionDetector returns a new priority inversion detector
+func NewInversionDetector(
+	tracker activeTasksTracker,
+	parent tally.Scope,
+	detectionPeriod time.Duration,
+	threshold time.Duration,
+) *InversionDetector {
+	return &InversionDetector{
+		tracker:         tracker,
+		detectionPeriod: detectionPeriod,
+		threshold:       threshold,
+		metrics:         newInversionMetrics(parent.SubScope("instance")),
+		lifeCycle:       lifecycle.NewLifeCycle(),
+	}
+}
+
+// Start starts the inversion detector. The detector is not started if its
+// detection period is not set.
+func (d *InversionDetector) Start() error {
+	if d.detectionPeriod <= 0 {
+		log.WithField("detection_period", d.detectionPeriod).
+			Warn("Inversion Detector is disabled, detection period is not set")
+		return nil
+	}
+
+	if !d.lifeCycle.Start() {
+		log.Warn(
+			"Inversion Detector is already running, no action will be performed")
+		return nil
+	}
+
+	go func() {
+		defer d.lifeCycle.StopComplete()
+
+		ticker := time.NewTicker(d.detectionPeriod)
+		defer ticker.Stop()
+
+		log.Info("Starting Inversion Detector")
+		for {
+			select {
+			case <-d.lifeCycle.StopCh():
+				log.Info("Exiting Inversion Detector")
+				return
+			case <-ticker.C:
+			}
+
+			d.run()
+		}
+	}()
+	return nil
+}
+
+func (d *InversionDetector) run() {
+	inversions := FindPriorityInversions(
+		d.tracker,
+		"",
+		d.threshold,
+		time.Now().UTC(),
+	)
+
+	counts := make(map[InversionCause]float64)
+	for _, inversion := range inversions {
+		counts[inversion.Cause]++
+		log.WithFields(log.Fields{
+			"task_id":                 inversion.Task.Task().GetId().GetValue(),
+			"respool_id":              inversion.Task.Respool().ID(),
+			"priority":                inversion.Task.Task().GetPriority(),
+			"lowest_running_priority": inversion.LowestRunningPriority,
+			"waiting_duration":        inversion.WaitingDuration,
+			"cause":                   inversion.Cause.String(),
+		}).Debug("Priority inversion detected")
+	}
+
+	d.metrics.PriorityInversions.Update(float64(len(inversions)))
+	for cause, gauge := range d.metrics.PriorityInversionsByCause {
+		gauge.Update(counts[cause])
+	}
+}
+
+// Stop stops the inversion detector
+func (d *InversionDetector) Stop() error {
+	if !d.lifeCycle.Stop() {
+		log.Warn(
+			"Inversion Detector is already stopped, no action will be performed")
+		return nil
+	}
+
+	// Wait for inversion detector to be stopped
+	d.lifeCycle.Wait()
+	log.Info("Inversion Detector Stopped")
+	return nil
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	"github.com/uber/peloton/.gen/peloton/private/resmgr"
+
+	"github.com/uber/peloton/pkg/resmgr/respool"
+	"github.com/uber/peloton/pkg/resmgr/respool/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally"
+)
+
+func newInversionTestTask(
+	id string,
+	priority uint32,
+	pool respool.ResPool,
+	enqueueTime time.Time) *RMTask {
+	return &RMTask{
+		task: &resmgr.Task{
+			Id:       &peloton.TaskID{Value: id},
+			Priority: priority,
+		},
+		respool: pool,
+		runTimeStats: &RunTimeStats{
+			EnqueueTime: enqueueTime,
+		},
+	}
+}
+
+func TestFindPriorityInversions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pool1 := mocks.NewMockResPool(ctrl)
+	pool1.EXPECT().ID().Return("pool1").AnyTimes()
+	pool2 := mocks.NewMockResPool(ctrl)
+	pool2.EXPECT().ID().Return("pool2").AnyTimes()
+
+	now := time.Now()
+	old := now.Add(-time.Hour)
+
+	pending := newInversionTestTask("pending", 2, pool1, old)
+	placing := newInversionTestTask("placing", 3, pool1, old)
+	placing.task.Constraint = &task.Constraint{}
+	recent := newInversionTestTask("recent", 2, pool1, now)
+	otherPool := newInversionTestTask("other-pool", 2, pool2, old)
+	running := newInversionTestTask("running", 1, pool1, old)
+	launched := newInversionTestTask("launched", 2, pool1, old)
+
+	tracker := &fakeActiveTasksTracker{
+		tasks: map[string][]*RMTask{
+			task.TaskState_PENDING.String():  {pending, recent, otherPool},
+			task.TaskState_PLACING.String():  {placing},
+			task.TaskState_RUNNING.String():  {running},
+			task.TaskState_LAUNCHED.String(): {launched},
+		},
+	}
+
+	inversions := FindPriorityInversions(tracker, "", time.Minute, now)
+	assert.Len(t, inversions, 2)
+
+	for _, inversion := range inversions {
+		switch inversion.Task {
+		case pending:
+			assert.Equal(t, task.TaskState_PENDING, inversion.State)
+			assert.Equal(t, InversionCauseCapacityBound, inversion.Cause)
+			assert.Equal(t, []*RMTask{running}, inversion.LowerPriorityTasks)
+			assert.Equal(t, uint32(1), inversion.LowestRunningPriority)
+		case placing:
+			assert.Equal(t, task.TaskState_PLACING, inversion.State)
+			assert.Equal(t, InversionCauseConstraintBound, inversion.Cause)
+			assert.Len(t, inversion.LowerPriorityTasks, 2)
+			assert.Equal(t, uint32(1), inversion.LowestRunningPriority)
+		default:
+			assert.Fail(t, "unexpected inversion",
+				inversion.Task.Task().GetId().GetValue())
+		}
+		assert.Equal(t, time.Hour, inversion.WaitingDuration)
+	}
+}
+
+func TestFindPriorityInversionsNoRunningTasks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pool := mocks.NewMockResPool(ctrl)
+	pool.EXPECT().ID().Return("pool1").AnyTimes()
+
+	now := time.Now()
+	tracker := &fakeActiveTasksTracker{
+		tasks: map[string][]*RMTask{
+			task.TaskState_READY.String(): {
+				newInversionTestTask("ready", 2, pool, now.Add(-time.Hour)),
+			},
+		},
+	}
+
+	assert.Empty(t, FindPriorityInversions(tracker, "", time.Minute, now))
+}
+
+func TestInversionDetectorStartStop(t *testing.T) {
+	detector := NewInversionDetector(
+		&fakeActiveTasksTracker{},
+		tally.NoopScope,
+		10*time.Millisecond,
+		time.Minute,
+	)
+
+	assert.NoError(t, detector.Start())
+	// starting again is a no-op
+	assert.NoError(t, detector.Start())
+	time.Sleep(20 * time.Millisecond)
+	assert.NoError(t, detector.Stop())
+	// stopping again is a no-op
+	assert.NoError(t, detector.Stop())
+}
+
+func TestInversionDetectorDisabled(t *testing.T) {
+	detector := NewInversionDetector(
+		&fakeActiveTasksTracker{},
+		tally.NoopScope,
+		0,
+		time.Minute,
+	)
+
+	// the detector is not started without a detection period
+	assert.NoError(t, detector.Start())
+	assert.NoError(t, detector.Stop())
+}
@@ -81,3 +81,27 @@ func NewMetrics(scope tally.Scope) *Metrics {
 		ReconciliationFail:    failScope.Counter("run"),
 	}
 }
+
+// inversionMetrics are the metrics of the priority inversion detector.
+type inversionMetrics struct {
+	PriorityInversions        tally.Gauge
+	PriorityInversionsByCause map[InversionCause]tally.Gauge
+}
+
+// newInversionMetrics returns the metrics of the priority inversion
+// detector.
+func newInversionMetrics(scope tally.Scope) *inversionMetrics {
+	inversionScope := scope.SubScope("priority_inversion")
+
+	return &inversionMetrics{
+		PriorityInversions: inversionScope.Gauge("inversions"),
+		PriorityInversionsByCause: map[InversionCause]tally.Gauge{
+			InversionCauseCapacityBound: inversionScope.Tagged(
+				map[string]string{"cause": InversionCauseCapacityBound.String()},
+			).Gauge("inversions_by_cause"),
+			InversionCauseConstraintBound: inversionScope.Tagged(
+				map[string]string{"cause": InversionCauseConstraintBound.String()},
+			).Gauge("inversions_by_cause"),
+		},
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+	pb_eventstream "github.com/uber/peloton/.gen/peloton/private/eventstream"
 	"github.com/uber/peloton/.gen/peloton/private/resmgr"
 	"github.com/uber/peloton/.gen/peloton/private/resmgrsvc"
 
@@ -48,6 +49,8 @@ var (
 // RunTimeStats is the container for run time stats of the resmgr task
 type RunTimeStats struct {
 	StartTime time.Time
+	// Time at which the task was added to the tracker
+	EnqueueTime time.Time
 }
 
 // RMTaskState represents the state of the rm task
@@ -93,7 +96,8 @@ func CreateRMTask(
 		respool:             respool,
 		config:              taskConfig,
 		runTimeStats: &RunTimeStats{
-			StartTime: time.Time{},
+			StartTime:   time.Time{},
+			EnqueueTime: time.Now().UTC(),
 		},
 		transitionObserver: NewTransitionObserver(
 			taskConfig.EnableSLATracking,
@@ -549,6 +553,8 @@ func (rmTask *RMTask) transitionCallBack(t *state.Transition) error {
 		rmTask.Task().GetTaskId().GetValue(),
 		tState)
 
+	rmTask.publishEvent(tState, t.Reason)
+
 	// we only care about running state here
 	if tState == task.TaskState_RUNNING {
 		// update the start time
@@ -622,31 +628,27 @@ func (rmTask *RMTask) preTimeoutCallback(t *state.Transition) error {
 	return nil
 }
 
-// TODO : Commenting it for now to not publish yet, Until we have solution for
-// event race : T936171
-// updateStatus creates and send the task event to event stream
-//func (rmTask *RMTask) updateStatus(status string) {
-//
-//
-//
-//
-//	//t := time.Now()
-//	//// Create Peloton task event
-//	//taskEvent := &task.TaskEvent{
-//	//	Source:    task.TaskEvent_SOURCE_RESMGR,
-//	//	State:     task.TaskState(task.TaskState_value[status]),
-//	//	TaskId:    rmTask.task.Id,
-//	//	Timestamp: t.Format(time.RFC3339),
-//	//}
-//	//
-//	//event := &pb_eventstream.Event{
-//	//	PelotonTaskEvent: taskEvent,
-//	//	Type:             pb_eventstream.Event_PELOTON_TASK_EVENT,
-//	//}
-//	//
-//	//err := rmTask.statusUpdateHandler.AddEvent(event)
-//	//if err != nil {
-//	//	log.WithError(err).WithField("Event", event).
-//	//		Error("Cannot add status update")
-//	//}
-//}
+// publishEvent sends the task event of a transition of the task to the
+// event stream, from which the job manager learns the reason of the
+// pending tasks.
+func (rmTask *RMTask) publishEvent(taskState task.TaskState, reason string) {
+	if rmTask.statusUpdateHandler == nil {
+		return
+	}
+
+	event := &pb_eventstream.Event{
+		Type: pb_eventstream.Event_PELOTON_TASK_EVENT,
+		PelotonTaskEvent: &task.TaskEvent{
+			Source:    task.TaskEvent_SOURCE_RESMGR,
+			State:     taskState,
+			TaskId:    rmTask.Task().GetId(),
+			Reason:    reason,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+	if err := rmTask.statusUpdateHandler.AddEvent(event); err != nil {
+		log.WithError(err).
+			WithField("task_id", rmTask.Task().GetId().GetValue()).
+			Error("Cannot add task event to the event stream")
+	}
+}
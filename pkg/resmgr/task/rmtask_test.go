@@ -28,6 +28,8 @@ import (
 	"github.com/uber/peloton/.gen/peloton/private/resmgr"
 	"github.com/uber/peloton/.gen/peloton/private/resmgrsvc"
 
+	"github.com/uber/peloton/pkg/common"
+	"github.com/uber/peloton/pkg/common/eventstream"
 	"github.com/uber/peloton/pkg/common/statemachine"
 	sm_mock "github.com/uber/peloton/pkg/common/statemachine/mocks"
 	rc "github.com/uber/peloton/pkg/resmgr/common"
@@ -548,6 +550,44 @@ func (s *RMTaskTestSuite) TestRMTaskTransitToError() {
 	s.EqualValues("failed to transition rmtask: fake error", err.Error())
 }
 
+// TestRMTaskPublishEvents tests that the transitions of the task and their
+// reason are sent to the event stream
+func (s *RMTaskTestSuite) TestRMTaskPublishEvents() {
+	handler := eventstream.NewEventStreamHandler(
+		10,
+		[]string{common.PelotonJobManager},
+		nil,
+		tally.NoopScope)
+
+	node, err := s.resTree.Get(&peloton.ResourcePoolID{Value: "respool3"})
+	s.NoError(err)
+	rmTask, err := CreateRMTask(
+		tally.NoopScope,
+		s.createTask(1),
+		handler,
+		node,
+		&Config{
+			LaunchingTimeout: 2 * time.Second,
+			PlacingTimeout:   2 * time.Second,
+		})
+	s.NoError(err)
+
+	s.NoError(rmTask.TransitTo(
+		task.TaskState_PENDING.String(),
+		statemachine.WithReason("waiting for admission")))
+
+	events, err := handler.GetEvents()
+	s.NoError(err)
+	s.Len(events, 1)
+	s.Equal(task.TaskState_PENDING, events[0].GetPelotonTaskEvent().GetState())
+	s.Equal("waiting for admission",
+		events[0].GetPelotonTaskEvent().GetReason())
+	s.Equal(rmTask.Task().GetId().GetValue(),
+		events[0].GetPelotonTaskEvent().GetTaskId().GetValue())
+	s.Equal(task.TaskEvent_SOURCE_RESMGR,
+		events[0].GetPelotonTaskEvent().GetSource())
+}
+
 func (s *RMTaskTestSuite) TestAddBackOffError() {
 	errStateMachine := sm_mock.NewMockStateMachine(s.ctrl)
 	errStateMachine.EXPECT().GetTimeOutRules().Return(make(map[statemachine.
@@ -251,6 +251,9 @@ func (tr *tracker) DeleteTask(t *peloton.TaskID) {
 func (tr *tracker) deleteTask(t *peloton.TaskID) {
 	if rmTask, exists := tr.tasks[t.Value]; exists {
 		tr.clearPlacement(rmTask)
+		// the consumers of the event stream forget the tasks in the
+		// UNKNOWN state
+		rmTask.publishEvent(task.TaskState_UNKNOWN, "")
 	}
 	delete(tr.tasks, t.Value)
 	tr.metrics.TasksCountInTracker.Update(float64(tr.GetSize()))
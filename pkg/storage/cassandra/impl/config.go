@@ -38,4 +38,19 @@ type CassandraConn struct {
 	TimeoutLimit       int           `yaml:"timeoutLimit"`  // number of timeouts allowed
 	CQLVersion         string        `yaml:"cqlVersion"`    // set only on C* 3.x
 	MaxGoRoutines      int           `yaml:"maxGoroutines"` // a capacity limit
+
+	// SpeculativeExecution enables speculative retries of reads, which
+	// are idempotent, when set
+	SpeculativeExecution *SpeculativeExecutionConfig `yaml:"speculativeExecution"`
+	// ConsistencyOverrides maps a table name to the consistency of the
+	// queries on the table, overriding the default consistency
+	ConsistencyOverrides map[string]string `yaml:"consistencyOverrides"`
+}
+
+// SpeculativeExecutionConfig describes the speculative retries of reads.
+// If a read has not completed after Delay, it is sent to another host,
+// up to NumAttempts additional times, and the fastest response is used.
+type SpeculativeExecutionConfig struct {
+	NumAttempts int           `yaml:"numAttempts"`
+	Delay       time.Duration `yaml:"delay"`
 }
@@ -71,6 +71,10 @@ func (b ExecutorBase) buildQuery(ctx context.Context, stmt api.Statement) (*gocq
 
 	qu := s.cSession.Query(uql, args...).WithContext(ctx)
 
+	if consistency, ok := s.consistencyOverrides[stmt.GetData().GetResource()]; ok {
+		qu.Consistency(consistency)
+	}
+	// consistency set on the context takes precedence over the config
 	if queryOverrides, ok := queryOverridesFromContext(ctx); ok {
 		if isConsistencyOverridden(queryOverrides) {
 			qu.Consistency(queryOverrides.Consistency.Value)
@@ -93,6 +97,12 @@ func (r ReadExecutor) Execute(ctx context.Context, stmt api.Statement) (api.Resu
 		return nil, err
 	}
 
+	// reads are idempotent, so they can be safely sent to other replicas
+	// when the first one is slow to respond
+	if s.speculativeExecution != nil {
+		qu.Idempotent(true).SetSpeculativeExecutionPolicy(s.speculativeExecution)
+	}
+
 	selectStmt := stmt.(qb.SelectBuilder)
 	if psize, ok := options["PageSize"]; ok && psize.(int) > 0 {
 		qu.PageSize(psize.(int))
@@ -15,6 +15,7 @@
 package impl
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/gocql/gocql"
@@ -26,6 +27,12 @@ import (
 
 // CreateStore is to create clusters and connections
 func CreateStore(storeConfig *CassandraConn, keySpace string, scope tally.Scope) (*Store, error) {
+	consistencyOverrides, err := newConsistencyOverrides(storeConfig)
+	if err != nil {
+		log.WithError(err).Error("Invalid consistency override")
+		return nil, err
+	}
+
 	cluster := newCluster(storeConfig)
 	cluster.Keyspace = keySpace
 
@@ -50,6 +57,9 @@ func CreateStore(storeConfig *CassandraConn, keySpace string, scope tally.Scope)
 		maxBatch:       50,
 		maxConcurrency: int32(storeConfig.MaxGoRoutines),
 		metrics:        NewMetrics(storeScope),
+
+		speculativeExecution: newSpeculativeExecutionPolicy(storeConfig),
+		consistencyOverrides: consistencyOverrides,
 	}
 	log.WithFields(log.Fields{
 		"key_space":      keySpace,
@@ -98,6 +108,8 @@ const (
 	defaultPageSize        = 1000
 	defaultConcurrency     = 1000
 	defaultPort            = 9042
+
+	defaultSpeculativeExecutionDelay = 100 * time.Millisecond
 )
 
 // NewCluster returns a clusterConfig object
@@ -176,3 +188,39 @@ func newCluster(storeConfig *CassandraConn) *gocql.ClusterConfig {
 
 	return cluster
 }
+
+// newSpeculativeExecutionPolicy returns the speculative execution policy
+// of the reads, or nil if speculative execution is not configured.
+func newSpeculativeExecutionPolicy(
+	storeConfig *CassandraConn) gocql.SpeculativeExecutionPolicy {
+	config := storeConfig.SpeculativeExecution
+	if config == nil || config.NumAttempts <= 0 {
+		return nil
+	}
+
+	delay := config.Delay
+	if delay <= 0 {
+		delay = defaultSpeculativeExecutionDelay
+	}
+	return &gocql.SimpleSpeculativeExecution{
+		NumAttempts:  config.NumAttempts,
+		TimeoutDelay: delay,
+	}
+}
+
+// newConsistencyOverrides returns the consistency of the queries of each
+// table configured with a consistency override, or an error if one of the
+// consistencies is unknown.
+func newConsistencyOverrides(
+	storeConfig *CassandraConn) (map[string]gocql.Consistency, error) {
+	overrides := make(map[string]gocql.Consistency)
+	for table, consistency := range storeConfig.ConsistencyOverrides {
+		var c gocql.Consistency
+		if err := c.UnmarshalText([]byte(consistency)); err != nil {
+			return nil, fmt.Errorf(
+				"consistency override of table %s: %v", table, err)
+		}
+		overrides[table] = c
+	}
+	return overrides, nil
+}
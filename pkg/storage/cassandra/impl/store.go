@@ -40,6 +40,12 @@ type Store struct {
 	maxBatch       int
 	maxConcurrency int32
 	metrics        Metrics
+
+	// speculativeExecution is the speculative execution policy of the
+	// reads, nil if reads are not speculatively retried
+	speculativeExecution gocql.SpeculativeExecutionPolicy
+	// consistencyOverrides is the consistency of the queries per table
+	consistencyOverrides map[string]gocql.Consistency
 }
 
 // Metrics is a struct for tracking execute statement / executeBatch statements
@@ -0,0 +1,156 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// _fileNameRegex matches the names of the migration files, e.g.
+// 0001_task_add_version.up.cql
+var _fileNameRegex = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.cql$`)
+
+// Migration is a versioned change of the schema of the keyspace.
+type Migration struct {
+	// Version orders the migrations, and identifies the migration in the
+	// table tracking the applied migrations
+	Version uint64
+	// Name of the migration, from the name of its files
+	Name string
+	// Up are the statements applying the migration
+	Up []string
+	// Down are the statements reverting the migration
+	Down []string
+	// Checksum of the up file, to detect migrations modified after being
+	// applied
+	Checksum string
+}
+
+// Load reads the migrations from the up and down CQL files in the
+// directory, sorted by version. It fails if a version has no up file or
+// more than one name.
+func Load(dir string) ([]*Migration, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read migrations directory")
+	}
+
+	byVersion := make(map[uint64]*Migration)
+	for _, f := range files {
+		match := _fileNameRegex.FindStringSubmatch(f.Name())
+		if f.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid version of %s", f.Name())
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, errors.Errorf(
+				"migrations %s and %s have the same version %d",
+				m.Name, match[2], version)
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", f.Name())
+		}
+		if match[3] == "up" {
+			m.Up = splitStatements(string(content))
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = splitStatements(string(content))
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if len(m.Checksum) == 0 {
+			return nil, errors.Errorf(
+				"migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// splitStatements splits the content of a CQL file into statements,
+// dropping the comments. Semicolons in quoted strings, such as the
+// options of lucene indexes, do not end a statement.
+func splitStatements(content string) []string {
+	var statements []string
+	var current strings.Builder
+	var quote byte
+
+	flush := func() {
+		if s := strings.TrimSpace(current.String()); len(s) != 0 {
+			statements = append(statements, s)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.HasPrefix(content[i:], "/*"):
+			end := strings.Index(content[i+2:], "*/")
+			if end < 0 {
+				i = len(content)
+			} else {
+				i += end + 3
+			}
+			continue
+		case strings.HasPrefix(content[i:], "--") ||
+			strings.HasPrefix(content[i:], "//"):
+			end := strings.IndexByte(content[i:], '\n')
+			if end < 0 {
+				i = len(content)
+			} else {
+				i += end
+			}
+			continue
+		case c == ';':
+			flush()
+			continue
+		}
+		current.WriteByte(c)
+	}
+	flush()
+	return statements
+}
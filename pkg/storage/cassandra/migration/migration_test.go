@@ -0,0 +1,129 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MigrationTestSuite struct {
+	suite.Suite
+
+	dir string
+}
+
+func (suite *MigrationTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "migrations")
+	suite.NoError(err)
+	suite.dir = dir
+}
+
+func (suite *MigrationTestSuite) TearDownTest() {
+	os.RemoveAll(suite.dir)
+}
+
+func TestMigrationTestSuite(t *testing.T) {
+	suite.Run(t, new(MigrationTestSuite))
+}
+
+func (suite *MigrationTestSuite) writeFile(name, content string) {
+	suite.NoError(ioutil.WriteFile(
+		filepath.Join(suite.dir, name), []byte(content), 0644))
+}
+
+// TestLoad tests loading the migrations sorted by version
+func (suite *MigrationTestSuite) TestLoad() {
+	suite.writeFile("0010_add_index.up.cql", "CREATE INDEX i ON t (c);")
+	suite.writeFile("0010_add_index.down.cql", "DROP INDEX i;")
+	suite.writeFile("0002_create_table.up.cql",
+		"CREATE TABLE t (k int PRIMARY KEY);\nALTER TABLE t ADD c int;")
+	suite.writeFile("README.md", "not a migration")
+
+	migrations, err := Load(suite.dir)
+	suite.NoError(err)
+	suite.Len(migrations, 2)
+
+	suite.Equal(uint64(2), migrations[0].Version)
+	suite.Equal("create_table", migrations[0].Name)
+	suite.Equal([]string{
+		"CREATE TABLE t (k int PRIMARY KEY)",
+		"ALTER TABLE t ADD c int",
+	}, migrations[0].Up)
+	suite.Empty(migrations[0].Down)
+
+	suite.Equal(uint64(10), migrations[1].Version)
+	suite.Equal([]string{"DROP INDEX i"}, migrations[1].Down)
+	suite.NotEqual(migrations[0].Checksum, migrations[1].Checksum)
+}
+
+// TestLoadDuplicateVersion tests that two migrations can not have the
+// same version
+func (suite *MigrationTestSuite) TestLoadDuplicateVersion() {
+	suite.writeFile("0001_a.up.cql", "DROP TABLE a;")
+	suite.writeFile("0001_b.up.cql", "DROP TABLE b;")
+
+	_, err := Load(suite.dir)
+	suite.Error(err)
+}
+
+// TestLoadMissingUp tests that a migration needs an up file
+func (suite *MigrationTestSuite) TestLoadMissingUp() {
+	suite.writeFile("0001_a.down.cql", "DROP TABLE a;")
+
+	_, err := Load(suite.dir)
+	suite.Error(err)
+}
+
+// TestLoadStoreMigrations tests loading the migrations of the store
+func (suite *MigrationTestSuite) TestLoadStoreMigrations() {
+	migrations, err := Load("../migrations")
+	suite.NoError(err)
+	suite.NotEmpty(migrations)
+	for i, m := range migrations {
+		suite.NotEmpty(m.Up, m.Name)
+		if i > 0 {
+			suite.True(m.Version > migrations[i-1].Version)
+		}
+	}
+}
+
+// TestSplitStatements tests splitting statements around comments and
+// quoted strings
+func (suite *MigrationTestSuite) TestSplitStatements() {
+	content := `/* create the
+table; */
+CREATE TABLE t (k int PRIMARY KEY); -- trailing; comment
+// line comment
+CREATE CUSTOM INDEX i ON t ()
+USING 'com.stratio.cassandra.lucene.Index'
+WITH OPTIONS = {
+   'schema': '{ fields: { k: {type: "integer"}; } }'
+}
+
+`
+	suite.Equal([]string{
+		"CREATE TABLE t (k int PRIMARY KEY)",
+		`CREATE CUSTOM INDEX i ON t ()
+USING 'com.stratio.cassandra.lucene.Index'
+WITH OPTIONS = {
+   'schema': '{ fields: { k: {type: "integer"}; } }'
+}`,
+	}, splitStatements(content))
+}
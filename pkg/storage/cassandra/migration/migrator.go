@@ -0,0 +1,395 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrNotBaselined is returned when migrating a keyspace which has tables but
+// no migration recorded, neither in the tracking table nor in the table of
+// the legacy migrate tool. The migrations already applied need to be
+// recorded by Baseline first.
+var ErrNotBaselined = errors.New(
+	"keyspace has tables but no applied migration, run baseline first")
+
+// AppliedMigration is a migration recorded in the tracking table.
+type AppliedMigration struct {
+	Version   uint64
+	Name      string
+	AppliedAt time.Time
+	Checksum  string
+}
+
+// Status is the state of a migration in the keyspace.
+type Status struct {
+	Migration *Migration
+	// Applied is the record of the migration in the tracking table, nil if
+	// the migration is pending
+	Applied *AppliedMigration
+	// Modified is set if the up file changed since the migration was applied
+	Modified bool
+}
+
+// store reads and writes the schema of the keyspace.
+type store interface {
+	// CreateTrackingTable creates the table recording the applied
+	// migrations if it does not exist.
+	CreateTrackingTable() error
+	// HasTrackingTable returns true if the table recording the applied
+	// migrations exists.
+	HasTrackingTable() (bool, error)
+	// AppliedMigrations returns the migrations recorded in the tracking
+	// table, keyed by version. The tracking table must exist.
+	AppliedMigrations() (map[uint64]*AppliedMigration, error)
+	// HasTables returns true if the keyspace has tables other than the
+	// tracking tables.
+	HasTables() (bool, error)
+	// LegacyMigrations returns the versions of the migrations recorded
+	// by the legacy migrate tool, nil if the keyspace was not migrated
+	// by it.
+	LegacyMigrations() ([]uint64, error)
+	// Exec runs a statement of a migration.
+	Exec(statement string) error
+	// Record records a migration as applied.
+	Record(applied *AppliedMigration) error
+	// Remove removes the record of a reverted migration.
+	Remove(version uint64) error
+}
+
+// Migrator applies and reverts the migrations of a keyspace, recording
+// the applied migrations in a tracking table of the keyspace.
+type Migrator struct {
+	store      store
+	migrations []*Migration
+}
+
+// New returns a migrator of the keyspace of the session with the
+// migrations of the directory.
+func New(
+	session *gocql.Session,
+	keyspace string,
+	dir string) (*Migrator, error) {
+	migrations, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	return newMigrator(newCQLStore(session, keyspace), migrations), nil
+}
+
+func newMigrator(s store, migrations []*Migration) *Migrator {
+	return &Migrator{
+		store:      s,
+		migrations: migrations,
+	}
+}
+
+// Status returns the state of all the migrations, ordered by version,
+// without changing the keyspace.
+func (m *Migrator) Status() ([]*Status, error) {
+	applied, err := m.recordedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	return m.statuses(applied), nil
+}
+
+// statuses returns the state of all the migrations given the applied
+// migrations, ordered by version.
+func (m *Migrator) statuses(
+	applied map[uint64]*AppliedMigration) []*Status {
+	var statuses []*Status
+	for _, migration := range m.migrations {
+		status := &Status{Migration: migration}
+		if a, ok := applied[migration.Version]; ok {
+			status.Applied = a
+			status.Modified = len(a.Checksum) != 0 &&
+				a.Checksum != migration.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Pending returns the migrations Up would apply, in order, without
+// changing the keyspace. The migrations applied by the legacy migrate
+// tool, which Up records first, are not pending. Returns ErrNotBaselined
+// if the keyspace needs to be baselined before being migrated.
+func (m *Migrator) Pending() ([]*Migration, error) {
+	applied, err := m.recordedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 {
+		applied, err = m.unrecordedMigrations()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var pending []*Migration
+	for _, status := range m.statuses(applied) {
+		if status.Applied == nil {
+			pending = append(pending, status.Migration)
+			continue
+		}
+		if status.Modified {
+			log.WithFields(log.Fields{
+				"version": status.Migration.Version,
+				"name":    status.Migration.Name,
+			}).Warn("migration modified after being applied")
+		}
+	}
+	return pending, nil
+}
+
+// Up applies the pending migrations in order, and returns the applied
+// migrations. The tracking table is created first, and the migrations
+// applied by the legacy migrate tool are recorded in it. It stops at the
+// first failed statement, leaving the failed migration pending.
+func (m *Migrator) Up() ([]*Migration, error) {
+	if err := m.checkBaselined(); err != nil {
+		return nil, err
+	}
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []*Migration
+	for _, migration := range pending {
+		for _, statement := range migration.Up {
+			if err := m.store.Exec(statement); err != nil {
+				return applied, errors.Wrapf(err,
+					"failed to apply migration %d_%s",
+					migration.Version, migration.Name)
+			}
+		}
+		if err := m.record(migration); err != nil {
+			return applied, err
+		}
+		applied = append(applied, migration)
+
+		log.WithFields(log.Fields{
+			"version": migration.Version,
+			"name":    migration.Name,
+		}).Info("migration applied")
+	}
+	return applied, nil
+}
+
+// Down reverts the applied migrations with a version greater than the
+// target version, in reverse order, and returns the reverted migrations.
+// A negative target version reverts all the applied migrations.
+func (m *Migrator) Down(target int64) ([]*Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Migration.Version > statuses[j].Migration.Version
+	})
+
+	var reverted []*Migration
+	for _, status := range statuses {
+		migration := status.Migration
+		if status.Applied == nil ||
+			(target >= 0 && migration.Version <= uint64(target)) {
+			continue
+		}
+		for _, statement := range migration.Down {
+			if err := m.store.Exec(statement); err != nil {
+				return reverted, errors.Wrapf(err,
+					"failed to revert migration %d_%s",
+					migration.Version, migration.Name)
+			}
+		}
+		if err := m.store.Remove(migration.Version); err != nil {
+			return reverted, errors.Wrapf(err,
+				"failed to remove record of migration %d_%s",
+				migration.Version, migration.Name)
+		}
+		reverted = append(reverted, migration)
+
+		log.WithFields(log.Fields{
+			"version": migration.Version,
+			"name":    migration.Name,
+		}).Info("migration reverted")
+	}
+	return reverted, nil
+}
+
+// Baseline records the migrations up to the version as applied without
+// running them, for keyspaces migrated before the tracking table existed.
+func (m *Migrator) Baseline(version uint64) ([]*Migration, error) {
+	if err := m.store.CreateTrackingTable(); err != nil {
+		return nil, errors.Wrap(err, "failed to create tracking table")
+	}
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	found := false
+	for _, status := range statuses {
+		if status.Migration.Version == version {
+			found = true
+		}
+	}
+	if !found {
+		return nil, errors.Errorf("no migration with version %d", version)
+	}
+
+	var recorded []*Migration
+	for _, status := range statuses {
+		if status.Applied != nil || status.Migration.Version > version {
+			continue
+		}
+		if err := m.record(status.Migration); err != nil {
+			return recorded, err
+		}
+		recorded = append(recorded, status.Migration)
+	}
+	return recorded, nil
+}
+
+// recordedMigrations returns the migrations recorded in the tracking table,
+// none if the tracking table does not exist yet.
+func (m *Migrator) recordedMigrations() (map[uint64]*AppliedMigration, error) {
+	exists, err := m.store.HasTrackingTable()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read tables of keyspace")
+	}
+	if !exists {
+		return make(map[uint64]*AppliedMigration), nil
+	}
+	applied, err := m.store.AppliedMigrations()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read applied migrations")
+	}
+	return applied, nil
+}
+
+// unrecordedMigrations returns the migrations applied to a keyspace which
+// has no migration recorded in the tracking table, i.e. the ones applied
+// by the legacy migrate tool, without recording them. Returns
+// ErrNotBaselined if the keyspace has tables but no applied migration.
+func (m *Migrator) unrecordedMigrations() (map[uint64]*AppliedMigration, error) {
+	versions, err := m.store.LegacyMigrations()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read legacy migrations")
+	}
+	applied := make(map[uint64]*AppliedMigration)
+	for _, version := range versions {
+		applied[version] = &AppliedMigration{Version: version}
+	}
+	if len(applied) != 0 {
+		return applied, nil
+	}
+
+	hasTables, err := m.store.HasTables()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read tables of keyspace")
+	}
+	if hasTables {
+		return nil, ErrNotBaselined
+	}
+	return applied, nil
+}
+
+// checkBaselined returns ErrNotBaselined if the keyspace has tables which
+// were not created by the recorded migrations. The tracking table is
+// created if it does not exist. The migrations applied by
+// the legacy migrate tool are recorded as applied first.
+func (m *Migrator) checkBaselined() error {
+	if err := m.store.CreateTrackingTable(); err != nil {
+		return errors.Wrap(err, "failed to create tracking table")
+	}
+	applied, err := m.store.AppliedMigrations()
+	if err != nil {
+		return errors.Wrap(err, "failed to read applied migrations")
+	}
+	if len(applied) != 0 {
+		return nil
+	}
+	imported, err := m.importLegacy()
+	if err != nil {
+		return err
+	}
+	if imported {
+		return nil
+	}
+	hasTables, err := m.store.HasTables()
+	if err != nil {
+		return errors.Wrap(err, "failed to read tables of keyspace")
+	}
+	if hasTables {
+		return ErrNotBaselined
+	}
+	return nil
+}
+
+// importLegacy records the migrations applied by the legacy migrate tool
+// as applied, without running them. Returns false if the keyspace was not
+// migrated by the legacy migrate tool.
+func (m *Migrator) importLegacy() (bool, error) {
+	versions, err := m.store.LegacyMigrations()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read legacy migrations")
+	}
+	if len(versions) == 0 {
+		return false, nil
+	}
+
+	legacy := make(map[uint64]bool)
+	for _, version := range versions {
+		legacy[version] = true
+	}
+	for _, migration := range m.migrations {
+		if !legacy[migration.Version] {
+			continue
+		}
+		if err := m.record(migration); err != nil {
+			return false, err
+		}
+		delete(legacy, migration.Version)
+
+		log.WithFields(log.Fields{
+			"version": migration.Version,
+			"name":    migration.Name,
+		}).Info("legacy migration imported")
+	}
+	for version := range legacy {
+		log.WithField("version", version).
+			Warn("legacy migration not found in migrations")
+	}
+	return true, nil
+}
+
+func (m *Migrator) record(migration *Migration) error {
+	err := m.store.Record(&AppliedMigration{
+		Version:   migration.Version,
+		Name:      migration.Name,
+		AppliedAt: time.Now().UTC(),
+		Checksum:  migration.Checksum,
+	})
+	return errors.Wrapf(err, "failed to record migration %d_%s",
+		migration.Version, migration.Name)
+}
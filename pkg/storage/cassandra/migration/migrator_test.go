@@ -0,0 +1,247 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeStore records the statements run and the applied migrations
+type fakeStore struct {
+	// trackingTable is set once the tracking table is created
+	trackingTable bool
+	applied       map[uint64]*AppliedMigration
+	legacy        []uint64
+	hasTables     bool
+	statements    []string
+	failOn        string
+}
+
+func (s *fakeStore) CreateTrackingTable() error {
+	s.trackingTable = true
+	return nil
+}
+
+func (s *fakeStore) HasTrackingTable() (bool, error) {
+	return s.trackingTable, nil
+}
+
+func (s *fakeStore) AppliedMigrations() (map[uint64]*AppliedMigration, error) {
+	applied := make(map[uint64]*AppliedMigration)
+	for v, a := range s.applied {
+		applied[v] = a
+	}
+	return applied, nil
+}
+
+func (s *fakeStore) HasTables() (bool, error) {
+	return s.hasTables, nil
+}
+
+func (s *fakeStore) LegacyMigrations() ([]uint64, error) {
+	return s.legacy, nil
+}
+
+func (s *fakeStore) Exec(statement string) error {
+	if statement == s.failOn {
+		return errors.New("statement failed")
+	}
+	s.statements = append(s.statements, statement)
+	return nil
+}
+
+func (s *fakeStore) Record(applied *AppliedMigration) error {
+	if !s.trackingTable {
+		return errors.New("no tracking table")
+	}
+	s.applied[applied.Version] = applied
+	return nil
+}
+
+func (s *fakeStore) Remove(version uint64) error {
+	delete(s.applied, version)
+	return nil
+}
+
+type MigratorTestSuite struct {
+	suite.Suite
+
+	store    *fakeStore
+	migrator *Migrator
+}
+
+func (suite *MigratorTestSuite) SetupTest() {
+	suite.store = &fakeStore{applied: make(map[uint64]*AppliedMigration)}
+	suite.migrator = newMigrator(suite.store, []*Migration{
+		{
+			Version:  0,
+			Name:     "bootstrap",
+			Up:       []string{"CREATE TABLE a", "CREATE TABLE b"},
+			Down:     []string{"DROP TABLE b", "DROP TABLE a"},
+			Checksum: "0",
+		},
+		{
+			Version:  1,
+			Name:     "add_c",
+			Up:       []string{"ALTER TABLE a ADD c"},
+			Down:     []string{"ALTER TABLE a DROP c"},
+			Checksum: "1",
+		},
+		{
+			Version:  2,
+			Name:     "add_d",
+			Up:       []string{"ALTER TABLE a ADD d"},
+			Down:     []string{"ALTER TABLE a DROP d"},
+			Checksum: "2",
+		},
+	})
+}
+
+func TestMigratorTestSuite(t *testing.T) {
+	suite.Run(t, new(MigratorTestSuite))
+}
+
+// TestUp tests applying the pending migrations in order
+func (suite *MigratorTestSuite) TestUp() {
+	pending, err := suite.migrator.Pending()
+	suite.NoError(err)
+	suite.Len(pending, 3)
+	suite.Empty(suite.store.statements)
+	suite.False(suite.store.trackingTable)
+
+	applied, err := suite.migrator.Up()
+	suite.NoError(err)
+	suite.Len(applied, 3)
+	suite.Equal([]string{
+		"CREATE TABLE a",
+		"CREATE TABLE b",
+		"ALTER TABLE a ADD c",
+		"ALTER TABLE a ADD d",
+	}, suite.store.statements)
+	suite.Len(suite.store.applied, 3)
+
+	pending, err = suite.migrator.Pending()
+	suite.NoError(err)
+	suite.Empty(pending)
+}
+
+// TestUpFailure tests that a failed migration stays pending, and stops
+// the following migrations
+func (suite *MigratorTestSuite) TestUpFailure() {
+	suite.store.failOn = "ALTER TABLE a ADD c"
+
+	applied, err := suite.migrator.Up()
+	suite.Error(err)
+	suite.Len(applied, 1)
+	suite.Len(suite.store.applied, 1)
+
+	suite.store.failOn = ""
+	pending, err := suite.migrator.Pending()
+	suite.NoError(err)
+	suite.Len(pending, 2)
+	suite.Equal(uint64(1), pending[0].Version)
+}
+
+// TestUpNotBaselined tests that migrating a keyspace with tables but no
+// recorded migrations fails until it is baselined
+func (suite *MigratorTestSuite) TestUpNotBaselined() {
+	suite.store.hasTables = true
+
+	_, err := suite.migrator.Pending()
+	suite.Equal(ErrNotBaselined, err)
+	_, err = suite.migrator.Up()
+	suite.Equal(ErrNotBaselined, err)
+	suite.Empty(suite.store.statements)
+
+	recorded, err := suite.migrator.Baseline(1)
+	suite.NoError(err)
+	suite.Len(recorded, 2)
+	suite.Empty(suite.store.statements)
+
+	applied, err := suite.migrator.Up()
+	suite.NoError(err)
+	suite.Len(applied, 1)
+	suite.Equal([]string{"ALTER TABLE a ADD d"}, suite.store.statements)
+}
+
+// TestUpLegacyMigrated tests that migrating a keyspace migrated by the
+// legacy migrate tool imports its applied migrations, and applies only
+// the following ones
+func (suite *MigratorTestSuite) TestUpLegacyMigrated() {
+	suite.store.hasTables = true
+	suite.store.legacy = []uint64{0, 1}
+
+	// the legacy migrations are not recorded until migrated
+	pending, err := suite.migrator.Pending()
+	suite.NoError(err)
+	suite.Len(pending, 1)
+	suite.Equal(uint64(2), pending[0].Version)
+	suite.Empty(suite.store.applied)
+	suite.False(suite.store.trackingTable)
+
+	applied, err := suite.migrator.Up()
+	suite.NoError(err)
+	suite.Len(applied, 1)
+	suite.Equal([]string{"ALTER TABLE a ADD d"}, suite.store.statements)
+	suite.Len(suite.store.applied, 3)
+	suite.Equal("1", suite.store.applied[1].Checksum)
+}
+
+// TestBaselineUnknownVersion tests baselining to an unknown version
+func (suite *MigratorTestSuite) TestBaselineUnknownVersion() {
+	_, err := suite.migrator.Baseline(5)
+	suite.Error(err)
+	suite.Empty(suite.store.applied)
+}
+
+// TestDown tests reverting migrations in reverse order
+func (suite *MigratorTestSuite) TestDown() {
+	_, err := suite.migrator.Up()
+	suite.NoError(err)
+	suite.store.statements = nil
+
+	reverted, err := suite.migrator.Down(0)
+	suite.NoError(err)
+	suite.Len(reverted, 2)
+	suite.Equal([]string{
+		"ALTER TABLE a DROP d",
+		"ALTER TABLE a DROP c",
+	}, suite.store.statements)
+	suite.Len(suite.store.applied, 1)
+
+	reverted, err = suite.migrator.Down(-1)
+	suite.NoError(err)
+	suite.Len(reverted, 1)
+	suite.Empty(suite.store.applied)
+}
+
+// TestStatusModified tests that the migrations changed after being
+// applied are reported
+func (suite *MigratorTestSuite) TestStatusModified() {
+	_, err := suite.migrator.Baseline(0)
+	suite.NoError(err)
+	suite.store.applied[0].Checksum = "changed"
+
+	statuses, err := suite.migrator.Status()
+	suite.NoError(err)
+	suite.Len(statuses, 3)
+	suite.NotNil(statuses[0].Applied)
+	suite.True(statuses[0].Modified)
+	suite.Nil(statuses[1].Applied)
+	suite.False(statuses[1].Modified)
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// TrackingTable is the table of the keyspace recording the applied
+// migrations.
+const TrackingTable = "schema_versions"
+
+// LegacyTrackingTable is the table of the keyspace recording the migrations
+// applied by the legacy migrate tool, one row per version.
+const LegacyTrackingTable = "schema_migrations"
+
+const (
+	_createTrackingTableStmt = `CREATE TABLE IF NOT EXISTS ` + TrackingTable + ` (
+		version bigint PRIMARY KEY,
+		name text,
+		applied_at timestamp,
+		checksum text
+	)`
+	_selectAppliedStmt = `SELECT version, name, applied_at, checksum FROM ` +
+		TrackingTable
+	_insertAppliedStmt = `INSERT INTO ` + TrackingTable +
+		` (version, name, applied_at, checksum) VALUES (?, ?, ?, ?)`
+	_deleteAppliedStmt = `DELETE FROM ` + TrackingTable + ` WHERE version = ?`
+	_selectTablesStmt  = `SELECT table_name FROM system_schema.tables
+		WHERE keyspace_name = ?`
+	_selectLegacyStmt = `SELECT version FROM ` + LegacyTrackingTable
+)
+
+// cqlStore is the store of the schema of a Cassandra keyspace. The schema
+// changes are run at consistency ALL, so that the following migrations see
+// them on every node.
+type cqlStore struct {
+	session  *gocql.Session
+	keyspace string
+}
+
+func newCQLStore(session *gocql.Session, keyspace string) *cqlStore {
+	return &cqlStore{
+		session:  session,
+		keyspace: keyspace,
+	}
+}
+
+func (s *cqlStore) CreateTrackingTable() error {
+	return s.Exec(_createTrackingTableStmt)
+}
+
+func (s *cqlStore) HasTrackingTable() (bool, error) {
+	return s.hasTable(TrackingTable)
+}
+
+func (s *cqlStore) AppliedMigrations() (map[uint64]*AppliedMigration, error) {
+	iter := s.session.Query(_selectAppliedStmt).
+		Consistency(gocql.All).
+		Iter()
+
+	applied := make(map[uint64]*AppliedMigration)
+	var version int64
+	var name, checksum string
+	var appliedAt time.Time
+	for iter.Scan(&version, &name, &appliedAt, &checksum) {
+		applied[uint64(version)] = &AppliedMigration{
+			Version:   uint64(version),
+			Name:      name,
+			AppliedAt: appliedAt,
+			Checksum:  checksum,
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+func (s *cqlStore) HasTables() (bool, error) {
+	iter := s.session.Query(_selectTablesStmt, s.keyspace).Iter()
+
+	hasTables := false
+	var table string
+	for iter.Scan(&table) {
+		if table != TrackingTable && table != LegacyTrackingTable {
+			hasTables = true
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return false, err
+	}
+	return hasTables, nil
+}
+
+func (s *cqlStore) LegacyMigrations() ([]uint64, error) {
+	hasLegacyTable, err := s.hasTable(LegacyTrackingTable)
+	if err != nil {
+		return nil, err
+	}
+	if !hasLegacyTable {
+		return nil, nil
+	}
+
+	iter := s.session.Query(_selectLegacyStmt).
+		Consistency(gocql.All).
+		Iter()
+
+	var versions []uint64
+	var version int64
+	for iter.Scan(&version) {
+		versions = append(versions, uint64(version))
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// hasTable returns true if the keyspace has the table.
+func (s *cqlStore) hasTable(name string) (bool, error) {
+	iter := s.session.Query(_selectTablesStmt, s.keyspace).Iter()
+
+	found := false
+	var table string
+	for iter.Scan(&table) {
+		if table == name {
+			found = true
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return false, err
+	}
+	return found, nil
+}
+
+func (s *cqlStore) Exec(statement string) error {
+	return s.session.Query(statement).
+		Consistency(gocql.All).
+		Exec()
+}
+
+func (s *cqlStore) Record(applied *AppliedMigration) error {
+	return s.session.Query(
+		_insertAppliedStmt,
+		int64(applied.Version),
+		applied.Name,
+		applied.AppliedAt,
+		applied.Checksum).
+		Consistency(gocql.All).
+		Exec()
+}
+
+func (s *cqlStore) Remove(version uint64) error {
+	return s.session.Query(_deleteAppliedStmt, int64(version)).
+		Consistency(gocql.All).
+		Exec()
+}
@@ -31,6 +31,7 @@ import (
 	datastore "github.com/uber/peloton/pkg/storage/cassandra/api"
 	datastoremocks "github.com/uber/peloton/pkg/storage/cassandra/api/mocks"
 	datastoreimpl "github.com/uber/peloton/pkg/storage/cassandra/impl"
+	"github.com/uber/peloton/pkg/storage/encryption"
 
 	"github.com/golang/mock/gomock"
 	"github.com/pborman/uuid"
@@ -66,6 +67,7 @@ func (suite *MockDatastoreTestSuite) SetupTest() {
 		metrics:     storage.NewMetrics(testScope.SubScope("storage")),
 		Conf:        &Config{},
 		retryPolicy: nil,
+		encrypter:   encryption.NewNoopEncrypter(),
 	}
 
 	queryBuilder := &datastoreimpl.QueryBuilder{}
@@ -21,8 +21,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -46,10 +48,10 @@ import (
 	"github.com/uber/peloton/pkg/storage"
 	"github.com/uber/peloton/pkg/storage/cassandra/api"
 	"github.com/uber/peloton/pkg/storage/cassandra/impl"
+	"github.com/uber/peloton/pkg/storage/cassandra/migration"
+	"github.com/uber/peloton/pkg/storage/encryption"
 	qb "github.com/uber/peloton/pkg/storage/querybuilder"
 
-	_ "github.com/gemnasium/migrate/driver/cassandra" // Pull in C* driver for migrate
-	"github.com/gemnasium/migrate/migrate"
 	"github.com/gocql/gocql"
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
@@ -81,6 +83,12 @@ const (
 	updatesByJobView       = "mv_updates_by_job"
 	resPoolsTable          = "respools"
 	volumeTable            = "persistent_volumes"
+	taskOperationsTable    = "task_operations"
+
+	taskOperationsByInstanceTable = "task_operations_by_instance"
+	// allInstancesOperations is the instance ID of the partition of the
+	// operations made on all the instances of a job
+	allInstancesOperations = math.MaxInt32
 
 	// DB field names
 	creationTimeField   = "creation_time"
@@ -122,43 +130,36 @@ type Config struct {
 	// MaxUpdatesPerJob controls the maximum number of
 	// updates per job kept in the database
 	MaxUpdatesPerJob int `yaml:"max_updates_job"`
+	// Encryption is the config for the encryption of sensitive fields,
+	// such as secrets, update opaque data and selected job labels
+	Encryption encryption.Config `yaml:"encryption"`
 }
 
 type luceneClauses []string
 
 // AutoMigrate migrates the db schemas for cassandra
+// The code assumes that the keyspace (indicated by StoreName) is already created
 func (c *Config) AutoMigrate() []error {
-	connString := c.MigrateString()
-	errs, ok := migrate.UpSync(connString, c.Migrations)
-	log.Infof("UpSync complete")
-	if !ok {
-		log.Errorf("UpSync failed with errors: %v", errs)
-		return errs
+	migrator, err := c.NewMigrator()
+	if err != nil {
+		return []error{err}
 	}
+	if _, err := migrator.Up(); err != nil {
+		log.WithError(err).Error("Migration failed")
+		return []error{err}
+	}
+	log.Info("Migration complete")
 	return nil
 }
 
-// MigrateString returns the db string required for database migration
-// The code assumes that the keyspace (indicated by StoreName) is already created
-func (c *Config) MigrateString() string {
-	// see https://github.com/gemnasium/migrate/pull/17 on why disable_init_host_lookup is needed
-	// This is for making local testing faster with docker running on mac
-	connStr := fmt.Sprintf("cassandra://%v:%v/%v?protocol=4&disable_init_host_lookup",
-		c.CassandraConn.ContactPoints[0],
-		c.CassandraConn.Port,
-		c.StoreName)
-
-	if len(c.CassandraConn.Username) != 0 {
-		connStr = fmt.Sprintf("cassandra://%v:%v@%v:%v/%v",
-			c.CassandraConn.Username,
-			c.CassandraConn.Password,
-			c.CassandraConn.ContactPoints[0],
-			c.CassandraConn.Port,
-			c.StoreName)
-	}
-	connStr = strings.Replace(connStr, " ", "", -1)
-	log.Infof("Cassandra migration string %v", connStr)
-	return connStr
+// NewMigrator returns the migrator of the keyspace with the migrations
+// of the config
+func (c *Config) NewMigrator() (*migration.Migrator, error) {
+	session, err := impl.CreateStoreSession(c.CassandraConn, c.StoreName)
+	if err != nil {
+		return nil, err
+	}
+	return migration.New(session, c.StoreName, c.Migrations)
 }
 
 // Store implements JobStore, TaskStore, UpdateStore, FrameworkInfoStore,
@@ -170,6 +171,8 @@ type Store struct {
 	metrics     *storage.Metrics
 	Conf        *Config
 	retryPolicy backoff.RetryPolicy
+	// encrypter encrypts the sensitive fields stored in the DB
+	encrypter encryption.Encrypter
 }
 
 // NewStore creates a Store
@@ -179,11 +182,17 @@ func NewStore(config *Config, scope tally.Scope) (*Store, error) {
 		log.Errorf("Failed to NewStore, err=%v", err)
 		return nil, err
 	}
+	encrypter, err := encryption.New(&config.Encryption)
+	if err != nil {
+		log.Errorf("Failed to create encrypter, err=%v", err)
+		return nil, err
+	}
 	return &Store{
 		DataStore:   dataStore,
 		metrics:     storage.NewMetrics(scope.SubScope("storage")),
 		Conf:        config,
 		retryPolicy: backoff.NewRetryPolicy(5, 50*time.Millisecond),
+		encrypter:   encrypter,
 	}, nil
 }
 
@@ -337,6 +346,13 @@ func (s *Store) CreateJobConfig(
 	configAddOn *models.ConfigAddOn, version uint64, owner string) error {
 	jobID := id.GetValue()
 
+	jobConfig, err := encryption.EncryptJobConfigLabels(s.encrypter, jobConfig)
+	if err != nil {
+		log.WithError(err).Error("Failed to encrypt jobConfig labels")
+		s.metrics.JobMetrics.JobCreateConfigFail.Inc(1)
+		return err
+	}
+
 	configBuffer, err := proto.Marshal(jobConfig)
 	if err != nil {
 		log.WithError(err).Error("Failed to marshal jobConfig")
@@ -569,6 +585,14 @@ func (s *Store) GetJobConfigWithVersion(ctx context.Context,
 			s.metrics.JobMetrics.JobGetFail.Inc(1)
 			return nil, nil, err
 		}
+		if err := encryption.DecryptJobConfigLabels(
+			s.encrypter, jobConfig); err != nil {
+			log.WithError(err).
+				WithField("job_id", jobID).
+				Error("Failed to decrypt jobConfig labels")
+			s.metrics.JobMetrics.JobGetFail.Inc(1)
+			return nil, nil, err
+		}
 		if jobConfig.GetChangeLog().GetVersion() < 1 {
 			// Older job which does not have changelog.
 			// TODO (zhixin): remove this after no more job in the system
@@ -792,16 +816,10 @@ func (s *Store) QueryJobs(ctx context.Context, respoolID *peloton.ResourcePoolID
 
 	log.WithField("where", where).Debug("query string")
 
+	// Only the IDs of the matching jobs are read to count them, the rest
+	// of their index is read for the jobs of the page only
 	queryBuilder := s.DataStore.NewQuery()
-	stmt := queryBuilder.Select("job_id",
-		"name",
-		"owner",
-		"job_type",
-		"respool_id",
-		"instance_count",
-		"labels",
-		"runtime_info").
-		From(jobIndexTable)
+	stmt := queryBuilder.Select("job_id").From(jobIndexTable)
 	stmt = stmt.Where(where)
 
 	allResults, err := s.executeRead(ctx, stmt)
@@ -836,7 +854,11 @@ func (s *Store) QueryJobs(ctx context.Context, respoolID *peloton.ResourcePoolID
 	if end > uint32(len(allResults)) {
 		end = uint32(len(allResults))
 	}
-	allResults = allResults[:end]
+	allResults, err = s.getJobIndexRows(ctx, allResults[:end])
+	if err != nil {
+		s.metrics.JobMetrics.JobQueryFail.Inc(1)
+		return nil, nil, 0, err
+	}
 
 	summaryResults, err := s.getJobSummaryFromResultMap(ctx, allResults)
 	if summaryOnly {
@@ -1145,6 +1167,8 @@ func (s *Store) addPodEvent(
 			"volumeID",
 			"message",
 			"reason",
+			"incident_id",
+			"change_ticket",
 			"pod_status").
 		Values(
 			jobID.GetValue(),
@@ -1163,6 +1187,8 @@ func (s *Store) addPodEvent(
 			runtime.GetVolumeID().GetValue(),
 			runtime.GetMessage(),
 			runtime.GetReason(),
+			runtime.GetOperationContext().GetIncidentId(),
+			runtime.GetOperationContext().GetChangeTicket(),
 			podStatus).Into(podEventsTable)
 
 	err = s.applyStatement(ctx, stmt, runtime.GetMesosTaskId().GetValue())
@@ -1262,6 +1288,8 @@ func (s *Store) GetPodEvents(
 		podEvent.AgentId = value["agent_id"].(string)
 		podEvent.Hostname = value["hostname"].(string)
 		podEvent.Healthy = value["healthy"].(string)
+		podEvent.IncidentId, _ = value["incident_id"].(string)
+		podEvent.ChangeTicket, _ = value["change_ticket"].(string)
 
 		podEvents = append(podEvents, podEvent)
 	}
@@ -1650,8 +1678,40 @@ func specContains(specifier []string, item string) bool {
 	return util.Contains(specifier, item)
 }
 
+// specContainsTerminationReason returns true if the termination reason
+// is one of the reasons specified, or if no reason is specified.
+func specContainsTerminationReason(
+	reasons []task.TerminationStatus_Reason,
+	reason task.TerminationStatus_Reason) bool {
+	if len(reasons) == 0 {
+		return true
+	}
+	for _, r := range reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// compileSpecRegex compiles the regular expression of a query spec field,
+// it returns nil if no regular expression is specified.
+func compileSpecRegex(expr string) (*regexp.Regexp, error) {
+	if len(expr) == 0 {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
+
+// specMatches returns true if the item matches the regular expression,
+// or if no regular expression is specified.
+func specMatches(re *regexp.Regexp, item string) bool {
+	return re == nil || re.MatchString(item)
+}
+
 // GetTasksByQuerySpec returns the tasks for a peloton job which satisfy the QuerySpec
 // field 'state' is filtered by DB query,  field 'name', 'host' is filter
+// in memory, as are the host and message regular expressions.
 func (s *Store) GetTasksByQuerySpec(
 	ctx context.Context,
 	jobID *peloton.JobID,
@@ -1660,9 +1720,20 @@ func (s *Store) GetTasksByQuerySpec(
 	taskStates := spec.GetTaskStates()
 	names := spec.GetNames()
 	hosts := spec.GetHosts()
+	terminationReasons := spec.GetTerminationReasons()
+
+	hostRegex, err := compileSpecRegex(spec.GetHostRegex())
+	if err != nil {
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"invalid host regex: %v", err)
+	}
+	messageRegex, err := compileSpecRegex(spec.GetMessageRegex())
+	if err != nil {
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"invalid message regex: %v", err)
+	}
 
 	var tasks map[uint32]*task.TaskInfo
-	var err error
 
 	if len(taskStates) == 0 {
 		//Get all tasks for the job if query doesn't specify the task state(s)
@@ -1688,7 +1759,13 @@ func (s *Store) GetTasksByQuerySpec(
 		taskName := task.GetConfig().GetName()
 		taskHost := task.GetRuntime().GetHost()
 
-		if specContains(names, taskName) && specContains(hosts, taskHost) {
+		if specContains(names, taskName) &&
+			specContains(hosts, taskHost) &&
+			specMatches(hostRegex, taskHost) &&
+			specMatches(messageRegex, task.GetRuntime().GetMessage()) &&
+			specContainsTerminationReason(
+				terminationReasons,
+				task.GetRuntime().GetTerminationStatus().GetReason()) {
 			filteredTasks[task.InstanceId] = task
 		}
 		// Deleting a task, to let it GC and not block memory till entire task list if iterated.
@@ -1699,6 +1776,7 @@ func (s *Store) GetTasksByQuerySpec(
 		"query_type": "In memory filtering",
 		"Names":      names,
 		"hosts":      hosts,
+		"host_regex": spec.GetHostRegex(),
 		"task_size":  len(tasks),
 		"duration":   time.Since(start).Seconds(),
 	}).Debug("Query in memory filtering time")
@@ -2045,6 +2123,33 @@ func (s *Store) deletePodEventsOnDeleteJob(
 	return nil
 }
 
+// deleteTaskOperationsByInstanceOnDeleteJob deletes the operations
+// recorded for each instance of a job, and for all its instances. The
+// operations of the instances removed from the job expire on their own.
+func (s *Store) deleteTaskOperationsByInstanceOnDeleteJob(
+	ctx context.Context,
+	jobID string) error {
+	queryBuilder := s.DataStore.NewQuery()
+	jobConfig, _, err := s.GetJobConfig(ctx, jobID)
+	if err != nil {
+		return err
+	}
+
+	instanceIDs := []uint32{allInstancesOperations}
+	for i := uint32(0); i < jobConfig.GetInstanceCount(); i++ {
+		instanceIDs = append(instanceIDs, i)
+	}
+	for _, instanceID := range instanceIDs {
+		stmt := queryBuilder.Delete(taskOperationsByInstanceTable).
+			Where(qb.Eq{"job_id": jobID}).
+			Where(qb.Eq{"instance_id": instanceID})
+		if err := s.applyStatement(ctx, stmt, jobID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // DeleteJob deletes a job and associated tasks, by job id.
 // TODO: This implementation is not perfect, as if it's getting an transient
 // error, the job or some tasks may not be fully deleted.
@@ -2066,6 +2171,17 @@ func (s *Store) DeleteJob(ctx context.Context, jobID string) error {
 		return err
 	}
 
+	stmt = queryBuilder.Delete(taskOperationsTable).Where(qb.Eq{"job_id": jobID})
+	if err := s.applyStatement(ctx, stmt, jobID); err != nil {
+		s.metrics.JobMetrics.JobDeleteFail.Inc(1)
+		return err
+	}
+
+	if err := s.deleteTaskOperationsByInstanceOnDeleteJob(ctx, jobID); err != nil {
+		s.metrics.JobMetrics.JobDeleteFail.Inc(1)
+		return err
+	}
+
 	// Delete all updates for the job
 	updateIDs, err := s.GetUpdatesForJob(ctx, jobID)
 	if err != nil {
@@ -2438,6 +2554,59 @@ func (s *Store) GetAllJobsInJobIndex(ctx context.Context) ([]*job.JobSummary, er
 // This is a helper function used by QueryJobs(). Do not use it for
 // anything other than QueryJobs; consider using ORM directly.
 // TODO Remove this when QueryJobs() uses ORM.
+// getJobIndexRows returns the job index rows of the jobs of the given
+// rows, in the same order. The jobs deleted since are skipped.
+func (s *Store) getJobIndexRows(
+	ctx context.Context,
+	idResults []map[string]interface{},
+) ([]map[string]interface{}, error) {
+	if len(idResults) == 0 {
+		return nil, nil
+	}
+
+	var jobIDs []string
+	for _, value := range idResults {
+		id, ok := value["job_id"].(qb.UUID)
+		if !ok {
+			return nil, yarpcerrors.InternalErrorf(
+				"invalid job_id %v", value["job_id"])
+		}
+		jobIDs = append(jobIDs, id.String())
+	}
+
+	queryBuilder := s.DataStore.NewQuery()
+	stmt := queryBuilder.Select(
+		"job_id",
+		"name",
+		"owner",
+		"job_type",
+		"respool_id",
+		"instance_count",
+		"labels",
+		"runtime_info").
+		From(jobIndexTable).
+		Where(qb.Eq{"job_id": jobIDs})
+
+	allResults, err := s.executeRead(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make(map[string]map[string]interface{})
+	for _, value := range allResults {
+		if id, ok := value["job_id"].(qb.UUID); ok {
+			rows[id.String()] = value
+		}
+	}
+	var results []map[string]interface{}
+	for _, jobID := range jobIDs {
+		if row, ok := rows[jobID]; ok {
+			results = append(results, row)
+		}
+	}
+	return results, nil
+}
+
 func (s *Store) getJobSummaryFromIndex(
 	ctx context.Context, id *peloton.JobID) (*job.JobSummary, error) {
 	queryBuilder := s.DataStore.NewQuery()
@@ -2587,6 +2756,10 @@ func (s *Store) QueryTasks(
 	jobID *peloton.JobID,
 	spec *task.QuerySpec) ([]*task.TaskInfo, uint32, error) {
 
+	if desc, ok := isSortedByInstanceIDOnly(spec); ok {
+		return s.queryTasksByInstanceID(ctx, jobID, spec, desc)
+	}
+
 	tasks, err := s.GetTasksByQuerySpec(ctx, jobID, spec)
 	if err != nil {
 		s.metrics.TaskMetrics.TaskQueryTasksFail.Inc(1)
@@ -2640,6 +2813,131 @@ func (s *Store) QueryTasks(
 	return result, uint32(len(sortedTasksResult)), nil
 }
 
+// isSortedByInstanceIDOnly returns true if the tasks matching the query
+// can be found from their instance ID and state only, and are sorted by
+// instance ID, along with whether the order is descending.
+func isSortedByInstanceIDOnly(spec *task.QuerySpec) (bool, bool) {
+	if len(spec.GetNames()) != 0 ||
+		len(spec.GetHosts()) != 0 ||
+		len(spec.GetHostRegex()) != 0 ||
+		len(spec.GetMessageRegex()) != 0 ||
+		len(spec.GetTerminationReasons()) != 0 {
+		return false, false
+	}
+	orderByList := spec.GetPagination().GetOrderBy()
+	switch {
+	case len(orderByList) == 0:
+		return false, true
+	case len(orderByList) == 1 &&
+		orderByList[0].GetProperty().GetValue() == instanceIDField:
+		return orderByList[0].GetOrder() == query.OrderBy_DESC, true
+	}
+	return false, false
+}
+
+// queryTasksByInstanceID returns the tasks in the given states, sorted by
+// instance ID, in the offset..offset+limit range. Only the instance IDs of
+// the matching tasks are read to count them, and only the tasks of the
+// range are read, so that the memory used does not grow with the number of
+// tasks of the job.
+func (s *Store) queryTasksByInstanceID(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	spec *task.QuerySpec,
+	desc bool) ([]*task.TaskInfo, uint32, error) {
+	queryBuilder := s.DataStore.NewQuery()
+	stmt := queryBuilder.Select("instance_id").
+		From(taskRuntimeTable).
+		Where(qb.Eq{"job_id": jobID.GetValue()})
+	if len(spec.GetTaskStates()) != 0 {
+		var taskStates []string
+		for _, state := range spec.GetTaskStates() {
+			taskStates = append(taskStates, state.String())
+		}
+		stmt = queryBuilder.Select("instance_id").
+			From(taskJobStateView).
+			Where(qb.Eq{"job_id": jobID.GetValue(), "state": taskStates})
+	}
+
+	allResults, err := s.executeRead(ctx, stmt)
+	if err != nil {
+		log.WithError(err).
+			WithField("job_id", jobID.GetValue()).
+			WithField("states", spec.GetTaskStates()).
+			Error("QueryTasks failed to get the instances of the job")
+		s.metrics.TaskMetrics.TaskQueryTasksFail.Inc(1)
+		return nil, 0, err
+	}
+
+	instanceIDs := make([]uint32, 0, len(allResults))
+	for _, value := range allResults {
+		instanceID, ok := value["instance_id"].(int)
+		if !ok {
+			s.metrics.TaskMetrics.TaskQueryTasksFail.Inc(1)
+			return nil, 0, yarpcerrors.InternalErrorf(
+				"invalid instance_id %v", value["instance_id"])
+		}
+		instanceIDs = append(instanceIDs, uint32(instanceID))
+	}
+	sort.Slice(instanceIDs, func(i, j int) bool {
+		if desc {
+			return instanceIDs[i] > instanceIDs[j]
+		}
+		return instanceIDs[i] < instanceIDs[j]
+	})
+
+	total := uint32(len(instanceIDs))
+	offset := spec.GetPagination().GetOffset()
+	limit := _defaultQueryLimit
+	if spec.GetPagination().GetLimit() != 0 {
+		limit = spec.GetPagination().GetLimit()
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	if offset >= end {
+		s.metrics.TaskMetrics.TaskQueryTasks.Inc(1)
+		return nil, total, nil
+	}
+	instanceIDs = instanceIDs[offset:end]
+
+	var result []*task.TaskInfo
+	if len(spec.GetTaskStates()) == 0 {
+		// all the instances of the job match, so the range of instances of
+		// the page holds the tasks of the page only
+		first, last := instanceIDs[0], instanceIDs[len(instanceIDs)-1]
+		if desc {
+			first, last = last, first
+		}
+		tasks, err := s.GetTasksForJobByRange(ctx, jobID, &task.InstanceRange{
+			From: first,
+			To:   last + 1,
+		})
+		if err != nil {
+			s.metrics.TaskMetrics.TaskQueryTasksFail.Inc(1)
+			return nil, 0, err
+		}
+		for _, instanceID := range instanceIDs {
+			if taskInfo, ok := tasks[instanceID]; ok {
+				result = append(result, taskInfo)
+			}
+		}
+	} else {
+		for _, instanceID := range instanceIDs {
+			taskInfo, err := s.getTask(ctx, jobID.GetValue(), instanceID)
+			if err != nil {
+				s.metrics.TaskMetrics.TaskQueryTasksFail.Inc(1)
+				return nil, 0, err
+			}
+			result = append(result, taskInfo)
+		}
+	}
+
+	s.metrics.TaskMetrics.TaskQueryTasks.Inc(1)
+	return result, total, nil
+}
+
 // CreatePersistentVolume creates a persistent volume entry.
 func (s *Store) CreatePersistentVolume(ctx context.Context, volume *pb_volume.PersistentVolumeInfo) error {
 
@@ -2757,6 +3055,17 @@ func (s *Store) CreateUpdate(
 		return err
 	}
 
+	opaqueData, err := s.encrypter.Encrypt(
+		[]byte(updateInfo.GetOpaqueData().GetData()))
+	if err != nil {
+		log.WithError(err).
+			WithField("update_id", updateInfo.GetUpdateID().GetValue()).
+			WithField("job_id", updateInfo.GetJobID().GetValue()).
+			Error("failed to encrypt opaque data")
+		s.metrics.UpdateMetrics.UpdateCreateFail.Inc(1)
+		return err
+	}
+
 	// Insert the update into the DB. Use CAS to ensure
 	// that it does not exist already.
 	queryBuilder := s.DataStore.NewQuery()
@@ -2795,7 +3104,7 @@ func (s *Store) CreateUpdate(
 			updateInfo.GetJobID().GetValue(),
 			updateInfo.GetJobConfigVersion(),
 			updateInfo.GetPrevJobConfigVersion(),
-			updateInfo.GetOpaqueData().GetData(),
+			opaqueData,
 			time.Now()).
 		IfNotExist()
 
@@ -3110,6 +3419,15 @@ func (s *Store) GetUpdate(ctx context.Context, id *peloton.UpdateID) (
 			return nil, err
 		}
 
+		opaqueData, err := s.encrypter.Decrypt(record.OpaqueData)
+		if err != nil {
+			log.WithError(err).
+				WithField("update_id", id.GetValue()).
+				Info("failed to decrypt opaque data")
+			s.metrics.UpdateMetrics.UpdateGetFail.Inc(1)
+			return nil, err
+		}
+
 		updateInfo := &models.UpdateModel{
 			UpdateID:             id,
 			UpdateConfig:         updateConfig,
@@ -3128,7 +3446,7 @@ func (s *Store) GetUpdate(ctx context.Context, id *peloton.UpdateID) (
 			InstancesCurrent:     record.GetProcessingInstances(),
 			CreationTime:         record.CreationTime.Format(time.RFC3339Nano),
 			UpdateTime:           record.UpdateTime.Format(time.RFC3339Nano),
-			OpaqueData:           &peloton.OpaqueData{Data: record.OpaqueData},
+			OpaqueData:           &peloton.OpaqueData{Data: string(opaqueData)},
 		}
 		s.metrics.UpdateMetrics.UpdateGet.Inc(1)
 		return updateInfo, nil
@@ -3238,7 +3556,16 @@ func (s *Store) WriteUpdateProgress(
 		Set("update_time", time.Now().UTC())
 
 	if updateInfo.GetOpaqueData() != nil {
-		stmt = stmt.Set("opaque_data", updateInfo.GetOpaqueData().GetData())
+		opaqueData, err := s.encrypter.Encrypt(
+			[]byte(updateInfo.GetOpaqueData().GetData()))
+		if err != nil {
+			log.WithError(err).
+				WithField("update_id", updateInfo.GetUpdateID().GetValue()).
+				Error("failed to encrypt opaque data")
+			s.metrics.UpdateMetrics.UpdateWriteProgressFail.Inc(1)
+			return err
+		}
+		stmt = stmt.Set("opaque_data", opaqueData)
 	}
 
 	stmt = stmt.Where(qb.Eq{"update_id": updateInfo.GetUpdateID().GetValue()})
@@ -3490,6 +3817,14 @@ func (s *Store) getJobSummaryFromResultMap(
 					WithField("labels", labelBuffer).
 					Info("failed to unmarshal labels")
 			}
+			summary.Labels, err = encryption.DecryptLabels(
+				s.encrypter, summary.GetLabels())
+			if err != nil {
+				log.WithError(err).
+					WithField("job_id", summary.GetId().GetValue()).
+					Info("failed to decrypt labels")
+				return nil, err
+			}
 		}
 		summaryResults = append(summaryResults, summary)
 	}
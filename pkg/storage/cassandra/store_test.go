@@ -2910,6 +2910,11 @@ func (suite *CassandraStoreTestSuite) TestTaskQueryFilter() {
 		jobConfig.InstanceConfig[uint32(i)] = taskInfo.Config
 		taskInfo.Runtime.State = task.TaskState(i % 16)
 		taskInfo.Runtime.Host = hosts[i%4]
+		if i%10 == 0 {
+			taskInfo.Runtime.TerminationStatus = &task.TerminationStatus{
+				Reason: task.TerminationStatus_TERMINATION_STATUS_REASON_OOM,
+			}
+		}
 		runtimes[uint32(i)] = taskInfo.Runtime
 	}
 
@@ -2987,6 +2992,45 @@ func (suite *CassandraStoreTestSuite) TestTaskQueryFilter() {
 	suite.Nil(err)
 	suite.Equal(6, len(tasks))
 
+	// testing filtering on host regex
+	tasks, _, err = taskStore.QueryTasks(context.Background(), &jobID, &task.QuerySpec{
+		HostRegex: "host[12]",
+	})
+	suite.Nil(err)
+	suite.Equal(50, len(tasks))
+
+	// testing filtering on state and message regex
+	tasks, _, err = taskStore.QueryTasks(context.Background(), &jobID, &task.QuerySpec{
+		TaskStates:   []task.TaskState{task.TaskState(task.TaskState_RUNNING)},
+		MessageRegex: "^no such message$",
+	})
+	suite.Nil(err)
+	suite.Equal(0, len(tasks))
+
+	// testing invalid regex
+	_, _, err = taskStore.QueryTasks(context.Background(), &jobID, &task.QuerySpec{
+		HostRegex: "host[",
+	})
+	suite.True(yarpcerrors.IsInvalidArgument(err))
+
+	// testing filtering on termination reason
+	tasks, _, err = taskStore.QueryTasks(context.Background(), &jobID, &task.QuerySpec{
+		TerminationReasons: []task.TerminationStatus_Reason{
+			task.TerminationStatus_TERMINATION_STATUS_REASON_OOM,
+			task.TerminationStatus_TERMINATION_STATUS_REASON_HEALTH_CHECK_FAILED,
+		},
+	})
+	suite.Nil(err)
+	suite.Equal(10, len(tasks))
+
+	tasks, _, err = taskStore.QueryTasks(context.Background(), &jobID, &task.QuerySpec{
+		TerminationReasons: []task.TerminationStatus_Reason{
+			task.TerminationStatus_TERMINATION_STATUS_REASON_UPDATE_REPLACED,
+		},
+	})
+	suite.Nil(err)
+	suite.Equal(0, len(tasks))
+
 }
 
 func (suite *CassandraStoreTestSuite) TestQueryTasks() {
@@ -3139,6 +3183,52 @@ func (suite *CassandraStoreTestSuite) TestQueryTasksSortByInstanceID() {
 	suite.NoError(taskStore.DeleteTaskRuntime(context.Background(), &jobID, uint32(0)))
 }
 
+// Test task query paging through the tasks sorted by instance ID
+func (suite *CassandraStoreTestSuite) TestQueryTasksPageByInstanceID() {
+	var jobID = peloton.JobID{Value: uuid.New()}
+	var taskStore = suite.createTasksForSortBy(jobID)
+
+	tasks, total, err := taskStore.QueryTasks(
+		context.Background(), &jobID, &task.QuerySpec{
+			Pagination: &query.PaginationSpec{Offset: 10, Limit: 20},
+		})
+	suite.NoError(err)
+	suite.Equal(uint32(100), total)
+	suite.Len(tasks, 20)
+	for i, taskInfo := range tasks {
+		suite.Equal(uint32(10+i), taskInfo.GetInstanceId())
+		suite.Equal(fmt.Sprintf("task_%d", 10+i), taskInfo.GetConfig().GetName())
+	}
+
+	tasks, total, err = taskStore.QueryTasks(
+		context.Background(), &jobID, &task.QuerySpec{
+			TaskStates: []task.TaskState{task.TaskState_INITIALIZED},
+			Pagination: &query.PaginationSpec{
+				Offset: 95,
+				Limit:  10,
+				OrderBy: []*query.OrderBy{{
+					Order:    query.OrderBy_DESC,
+					Property: &query.PropertyPath{Value: instanceIDField},
+				}},
+			},
+		})
+	suite.NoError(err)
+	suite.Equal(uint32(100), total)
+	suite.Len(tasks, 5)
+	for i, taskInfo := range tasks {
+		suite.Equal(uint32(4-i), taskInfo.GetInstanceId())
+	}
+
+	tasks, total, err = taskStore.QueryTasks(
+		context.Background(), &jobID, &task.QuerySpec{
+			Pagination: &query.PaginationSpec{Offset: 100},
+		})
+	suite.NoError(err)
+	suite.Equal(uint32(100), total)
+	suite.Empty(tasks)
+	suite.NoError(taskStore.DeleteTaskRuntime(context.Background(), &jobID, uint32(0)))
+}
+
 // Test task query with sort by message
 func (suite *CassandraStoreTestSuite) TestQueryTasksSortByMessage() {
 	var jobID = peloton.JobID{Value: uuid.New()}
@@ -3402,6 +3492,10 @@ func (suite *CassandraStoreTestSuite) TestGetPodEvent() {
 		},
 		ConfigVersion:        3,
 		DesiredConfigVersion: 4,
+		OperationContext: &task.OperationContext{
+			IncidentId:   "INC-1234",
+			ChangeTicket: "CHG-5678",
+		},
 	}
 
 	store.addPodEvent(context.Background(), jobID, 0, runtime)
@@ -3412,6 +3506,8 @@ func (suite *CassandraStoreTestSuite) TestGetPodEvent() {
 		"7ac74273-4ef0-4ca4-8fd2-34bc52aeac06-0-2")
 	suite.Equal(len(podEvents), 1)
 	suite.NoError(err)
+	suite.Equal("INC-1234", podEvents[0].GetIncidentId())
+	suite.Equal("CHG-5678", podEvents[0].GetChangeTicket())
 
 	mesosTaskID = "7ac74273-4ef0-4ca4-8fd2-34bc52aeac06-0-3"
 	prevMesosTaskID = "7ac74273-4ef0-4ca4-8fd2-34bc52aeac06-0-2"
@@ -20,17 +20,17 @@ import (
 	"path"
 	"strings"
 
-	_ "github.com/gemnasium/migrate/driver/cassandra" // Pull in C* driver for migrate
-	"github.com/gemnasium/migrate/migrate"
 	log "github.com/sirupsen/logrus"
 	"github.com/uber/peloton/pkg/storage/cassandra/impl"
 )
 
 func downSync(cfg *Config) []error {
-	connString := cfg.MigrateString()
-	errors, ok := migrate.DownSync(connString, cfg.Migrations)
-	if !ok {
-		return errors
+	migrator, err := cfg.NewMigrator()
+	if err != nil {
+		return []error{err}
+	}
+	if _, err := migrator.Down(-1); err != nil {
+		return []error{err}
 	}
 	return nil
 }
@@ -0,0 +1,38 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encryption
+
+// Config is the config for the encryption of sensitive fields in the
+// storage layer
+type Config struct {
+	// Enabled turns on the encryption of sensitive fields. Fields stored
+	// in plaintext before encryption was enabled can still be read.
+	Enabled bool `yaml:"enabled"`
+
+	// CurrentKeyID is the ID of the key used to encrypt new values. The
+	// other keys are only used to decrypt values encrypted before the
+	// current key was rotated in, which are encrypted with the current key
+	// when they are written again. An old key can only be removed once
+	// no value encrypted with it is left.
+	CurrentKeyID string `yaml:"current_key_id"`
+
+	// Keys are the base64 encoded 256-bit master keys of the local KMS
+	// indexed by key ID.
+	Keys map[string]string `yaml:"keys"`
+
+	// SensitiveLabelKeys are the keys of the job labels whose values are
+	// encrypted.
+	SensitiveLabelKeys []string `yaml:"sensitive_label_keys"`
+}
@@ -0,0 +1,303 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encryption
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+const (
+	// _envelopePrefix prefixes the values encrypted by the Encrypter so
+	// that they can be told apart from values stored in plaintext.
+	_envelopePrefix = "enc:v1:"
+
+	// _dataKeySize is the size in bytes of the data keys
+	_dataKeySize = 32
+)
+
+// envelope is an encrypted value along with the data key used to encrypt
+// it, wrapped by the master key KeyID of the KMS.
+type envelope struct {
+	KeyID      string `json:"kid"`
+	WrappedKey []byte `json:"key"`
+	Data       []byte `json:"data"`
+}
+
+// Encrypter encrypts and decrypts the sensitive fields of the storage layer
+// using envelope encryption.
+type Encrypter interface {
+	// Encrypt encrypts the plaintext with a new data key and returns the
+	// encoded envelope.
+	Encrypt(plaintext []byte) (string, error)
+
+	// Decrypt decrypts a value returned by Encrypt. Values which are not
+	// envelopes are assumed to have been stored in plaintext and are
+	// returned as is.
+	Decrypt(value string) ([]byte, error)
+
+	// IsSensitiveLabel returns true if the values of the labels with the
+	// key are encrypted.
+	IsSensitiveLabel(key string) bool
+}
+
+// encrypter implements the Encrypter interface with a KMS.
+type encrypter struct {
+	kms             KMS
+	sensitiveLabels map[string]bool
+}
+
+// New returns an Encrypter for the config. If encryption is disabled, the
+// returned Encrypter stores the values in plaintext.
+func New(config *Config) (Encrypter, error) {
+	if config == nil || !config.Enabled {
+		return NewNoopEncrypter(), nil
+	}
+
+	kms, err := NewLocalKMS(config.Keys, config.CurrentKeyID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create KMS")
+	}
+	return NewEncrypter(kms, config.SensitiveLabelKeys), nil
+}
+
+// NewEncrypter returns an Encrypter which wraps its data keys with the KMS
+// and encrypts the values of the labels with the sensitive label keys.
+func NewEncrypter(kms KMS, sensitiveLabelKeys []string) Encrypter {
+	e := &encrypter{
+		kms:             kms,
+		sensitiveLabels: make(map[string]bool),
+	}
+	for _, key := range sensitiveLabelKeys {
+		e.sensitiveLabels[key] = true
+	}
+	return e
+}
+
+// Encrypt encrypts the plaintext with a new data key and returns the
+// encoded envelope.
+func (e *encrypter) Encrypt(plaintext []byte) (string, error) {
+	dataKey := make([]byte, _dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", errors.Wrap(err, "failed to generate data key")
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	data, err := seal(aead, plaintext)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encrypt data")
+	}
+
+	keyID := e.kms.CurrentKeyID()
+	wrappedKey, err := e.kms.WrapKey(keyID, dataKey)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to wrap data key")
+	}
+
+	buffer, err := json.Marshal(&envelope{
+		KeyID:      keyID,
+		WrappedKey: wrappedKey,
+		Data:       data,
+	})
+	if err != nil {
+		return "", err
+	}
+	return _envelopePrefix + base64.StdEncoding.EncodeToString(buffer), nil
+}
+
+// Decrypt decrypts a value returned by Encrypt.
+func (e *encrypter) Decrypt(value string) ([]byte, error) {
+	env, err := decodeEnvelope(value)
+	if err != nil {
+		return nil, err
+	}
+	if env == nil {
+		return []byte(value), nil
+	}
+
+	dataKey, err := e.kms.UnwrapKey(env.KeyID, env.WrappedKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unwrap data key")
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(aead, env.Data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt data")
+	}
+	return plaintext, nil
+}
+
+// IsSensitiveLabel returns true if the values of the labels with the key
+// are encrypted.
+func (e *encrypter) IsSensitiveLabel(key string) bool {
+	return e.sensitiveLabels[key]
+}
+
+// noopEncrypter is the Encrypter used when encryption is disabled.
+type noopEncrypter struct{}
+
+// NewNoopEncrypter returns an Encrypter which stores the values in
+// plaintext.
+func NewNoopEncrypter() Encrypter {
+	return noopEncrypter{}
+}
+
+// Encrypt returns the plaintext as is.
+func (noopEncrypter) Encrypt(plaintext []byte) (string, error) {
+	return string(plaintext), nil
+}
+
+// Decrypt returns the value as is, and fails if the value was encrypted
+// since no key is available to decrypt it.
+func (noopEncrypter) Decrypt(value string) ([]byte, error) {
+	if isEnvelope(value) {
+		return nil, errors.New("encrypted value found with encryption disabled")
+	}
+	return []byte(value), nil
+}
+
+// IsSensitiveLabel always returns false.
+func (noopEncrypter) IsSensitiveLabel(key string) bool {
+	return false
+}
+
+// isEnvelope returns true if the value was returned by Encrypt.
+func isEnvelope(value string) bool {
+	return strings.HasPrefix(value, _envelopePrefix)
+}
+
+// decodeEnvelope decodes a value returned by Encrypt. It returns nil if the
+// value is not an envelope.
+func decodeEnvelope(value string) (*envelope, error) {
+	if !isEnvelope(value) {
+		return nil, nil
+	}
+	buffer, err := base64.StdEncoding.DecodeString(
+		strings.TrimPrefix(value, _envelopePrefix))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode envelope")
+	}
+	env := &envelope{}
+	if err := json.Unmarshal(buffer, env); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal envelope")
+	}
+	return env, nil
+}
+
+// EncryptLabels returns the labels with the values of the sensitive labels
+// encrypted. The labels passed in are not modified.
+func EncryptLabels(
+	e Encrypter,
+	labels []*peloton.Label) ([]*peloton.Label, error) {
+	return transformLabels(e, labels, func(value string) (string, error) {
+		return e.Encrypt([]byte(value))
+	})
+}
+
+// DecryptLabels returns the labels with the values of the sensitive labels
+// decrypted. The labels passed in are not modified.
+func DecryptLabels(
+	e Encrypter,
+	labels []*peloton.Label) ([]*peloton.Label, error) {
+	return transformLabels(e, labels, func(value string) (string, error) {
+		plaintext, err := e.Decrypt(value)
+		return string(plaintext), err
+	})
+}
+
+// transformLabels applies transform to the values of the sensitive labels.
+// The labels are only copied if any of them is sensitive.
+func transformLabels(
+	e Encrypter,
+	labels []*peloton.Label,
+	transform func(string) (string, error)) ([]*peloton.Label, error) {
+	if !hasSensitiveLabels(e, labels) {
+		return labels, nil
+	}
+
+	result := make([]*peloton.Label, 0, len(labels))
+	for _, label := range labels {
+		if !e.IsSensitiveLabel(label.GetKey()) {
+			result = append(result, label)
+			continue
+		}
+		value, err := transform(label.GetValue())
+		if err != nil {
+			return nil, errors.Wrapf(err,
+				"failed to transform label %q", label.GetKey())
+		}
+		result = append(result, &peloton.Label{
+			Key:   label.GetKey(),
+			Value: value,
+		})
+	}
+	return result, nil
+}
+
+// EncryptJobConfigLabels returns the job config with the values of its
+// sensitive labels encrypted. The config is only copied if it has any
+// sensitive label.
+func EncryptJobConfigLabels(
+	e Encrypter,
+	config *job.JobConfig) (*job.JobConfig, error) {
+	if !hasSensitiveLabels(e, config.GetLabels()) {
+		return config, nil
+	}
+	labels, err := EncryptLabels(e, config.GetLabels())
+	if err != nil {
+		return nil, err
+	}
+	result := proto.Clone(config).(*job.JobConfig)
+	result.Labels = labels
+	return result, nil
+}
+
+// DecryptJobConfigLabels decrypts in place the values of the sensitive
+// labels of the job config.
+func DecryptJobConfigLabels(e Encrypter, config *job.JobConfig) error {
+	labels, err := DecryptLabels(e, config.GetLabels())
+	if err != nil {
+		return err
+	}
+	if config != nil {
+		config.Labels = labels
+	}
+	return nil
+}
+
+// hasSensitiveLabels returns true if any of the labels is sensitive.
+func hasSensitiveLabels(e Encrypter, labels []*peloton.Label) bool {
+	for _, label := range labels {
+		if e.IsSensitiveLabel(label.GetKey()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encryption
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type EncrypterTestSuite struct {
+	suite.Suite
+
+	config    *Config
+	encrypter Encrypter
+}
+
+func (suite *EncrypterTestSuite) SetupTest() {
+	suite.config = &Config{
+		Enabled:      true,
+		CurrentKeyID: "k1",
+		Keys: map[string]string{
+			"k1": newTestKey(1),
+		},
+		SensitiveLabelKeys: []string{"token"},
+	}
+
+	var err error
+	suite.encrypter, err = New(suite.config)
+	suite.NoError(err)
+}
+
+func TestEncrypter(t *testing.T) {
+	suite.Run(t, new(EncrypterTestSuite))
+}
+
+// TestEncryptDecrypt tests that an encrypted value can be decrypted
+func (suite *EncrypterTestSuite) TestEncryptDecrypt() {
+	value, err := suite.encrypter.Encrypt([]byte("secret"))
+	suite.NoError(err)
+	suite.True(strings.HasPrefix(value, _envelopePrefix))
+	suite.NotContains(value, "secret")
+
+	// every value is encrypted with its own data key
+	other, err := suite.encrypter.Encrypt([]byte("secret"))
+	suite.NoError(err)
+	suite.NotEqual(value, other)
+
+	plaintext, err := suite.encrypter.Decrypt(value)
+	suite.NoError(err)
+	suite.Equal([]byte("secret"), plaintext)
+}
+
+// TestDecryptPlaintext tests that values stored before encryption was
+// enabled are returned as is
+func (suite *EncrypterTestSuite) TestDecryptPlaintext() {
+	plaintext, err := suite.encrypter.Decrypt("legacy")
+	suite.NoError(err)
+	suite.Equal([]byte("legacy"), plaintext)
+}
+
+// TestDecryptFailure tests that decrypting a corrupted envelope or an
+// envelope whose master key is unknown fails
+func (suite *EncrypterTestSuite) TestDecryptFailure() {
+	_, err := suite.encrypter.Decrypt(_envelopePrefix + "not base64!")
+	suite.Error(err)
+
+	value, err := suite.encrypter.Encrypt([]byte("secret"))
+	suite.NoError(err)
+
+	other, err := New(&Config{
+		Enabled:      true,
+		CurrentKeyID: "k2",
+		Keys: map[string]string{
+			"k2": newTestKey(2),
+		},
+	})
+	suite.NoError(err)
+	_, err = other.Decrypt(value)
+	suite.Error(err)
+}
+
+// TestRotate tests that values encrypted with an old key can still be
+// decrypted once a new key is rotated in, and that new values are
+// encrypted with the new key
+func (suite *EncrypterTestSuite) TestRotate() {
+	value, err := suite.encrypter.Encrypt([]byte("secret"))
+	suite.NoError(err)
+
+	suite.config.Keys["k2"] = newTestKey(2)
+	suite.config.CurrentKeyID = "k2"
+	rotated, err := New(suite.config)
+	suite.NoError(err)
+
+	plaintext, err := rotated.Decrypt(value)
+	suite.NoError(err)
+	suite.Equal([]byte("secret"), plaintext)
+
+	newValue, err := rotated.Encrypt(plaintext)
+	suite.NoError(err)
+	env, err := decodeEnvelope(newValue)
+	suite.NoError(err)
+	suite.Equal("k2", env.KeyID)
+
+	plaintext, err = rotated.Decrypt(newValue)
+	suite.NoError(err)
+	suite.Equal([]byte("secret"), plaintext)
+}
+
+// TestJobConfigLabels tests that only the sensitive labels of a job config
+// are encrypted
+func (suite *EncrypterTestSuite) TestJobConfigLabels() {
+	config := &job.JobConfig{
+		Name: "job",
+		Labels: []*peloton.Label{
+			{Key: "team", Value: "peloton"},
+			{Key: "token", Value: "secret"},
+		},
+	}
+
+	encrypted, err := EncryptJobConfigLabels(suite.encrypter, config)
+	suite.NoError(err)
+	suite.Equal("job", encrypted.GetName())
+	suite.Equal(config.Labels[0], encrypted.Labels[0])
+	suite.NotEqual("secret", encrypted.Labels[1].GetValue())
+	// the config passed in is not modified
+	suite.Equal("secret", config.Labels[1].GetValue())
+
+	suite.NoError(DecryptJobConfigLabels(suite.encrypter, encrypted))
+	suite.Equal(config.GetLabels(), encrypted.GetLabels())
+}
+
+// TestJobConfigNoSensitiveLabels tests that a job config without sensitive
+// labels is not copied
+func (suite *EncrypterTestSuite) TestJobConfigNoSensitiveLabels() {
+	config := &job.JobConfig{
+		Labels: []*peloton.Label{{Key: "team", Value: "peloton"}},
+	}
+	encrypted, err := EncryptJobConfigLabels(suite.encrypter, config)
+	suite.NoError(err)
+	suite.True(config == encrypted)
+
+	encrypted, err = EncryptJobConfigLabels(suite.encrypter, nil)
+	suite.NoError(err)
+	suite.Nil(encrypted)
+	suite.NoError(DecryptJobConfigLabels(suite.encrypter, nil))
+}
+
+func TestNoopEncrypter(t *testing.T) {
+	e, err := New(&Config{})
+	assert.NoError(t, err)
+
+	value, err := e.Encrypt([]byte("secret"))
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", value)
+
+	plaintext, err := e.Decrypt(value)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("secret"), plaintext)
+	assert.False(t, e.IsSensitiveLabel("token"))
+
+	// encrypted values cannot be read once encryption is disabled
+	_, err = e.Decrypt(_envelopePrefix + "data")
+	assert.Error(t, err)
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	_, err := New(&Config{Enabled: true, CurrentKeyID: "k1"})
+	assert.Error(t, err)
+}
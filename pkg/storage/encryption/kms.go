@@ -0,0 +1,129 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// _masterKeySize is the size in bytes of the master keys of the local KMS
+const _masterKeySize = 32
+
+// KMS is a key management service which wraps the data keys used to
+// encrypt the sensitive fields with its master keys. The master keys never
+// leave the KMS.
+type KMS interface {
+	// CurrentKeyID returns the ID of the master key used to wrap new
+	// data keys.
+	CurrentKeyID() string
+
+	// WrapKey encrypts a data key with the master key keyID.
+	WrapKey(keyID string, dataKey []byte) ([]byte, error)
+
+	// UnwrapKey decrypts a data key wrapped with the master key keyID.
+	UnwrapKey(keyID string, wrappedKey []byte) ([]byte, error)
+}
+
+// localKMS is a KMS whose master keys are provided in the configuration.
+type localKMS struct {
+	currentKeyID string
+	keys         map[string]cipher.AEAD
+}
+
+// NewLocalKMS returns a KMS whose master keys are the base64 encoded keys
+// indexed by key ID.
+func NewLocalKMS(keys map[string]string, currentKeyID string) (KMS, error) {
+	if _, ok := keys[currentKeyID]; !ok {
+		return nil, errors.Errorf("current key %q not found", currentKeyID)
+	}
+
+	kms := &localKMS{
+		currentKeyID: currentKeyID,
+		keys:         make(map[string]cipher.AEAD),
+	}
+	for keyID, encodedKey := range keys {
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode key %q", keyID)
+		}
+		if len(key) != _masterKeySize {
+			return nil, errors.Errorf(
+				"key %q must be %d bytes long", keyID, _masterKeySize)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		kms.keys[keyID] = aead
+	}
+	return kms, nil
+}
+
+// CurrentKeyID returns the ID of the master key used to wrap new data keys.
+func (k *localKMS) CurrentKeyID() string {
+	return k.currentKeyID
+}
+
+// WrapKey encrypts a data key with the master key keyID.
+func (k *localKMS) WrapKey(keyID string, dataKey []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, errors.Errorf("key %q not found", keyID)
+	}
+	return seal(aead, dataKey)
+}
+
+// UnwrapKey decrypts a data key wrapped with the master key keyID.
+func (k *localKMS) UnwrapKey(keyID string, wrappedKey []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, errors.Errorf("key %q not found", keyID)
+	}
+	return open(aead, wrappedKey)
+}
+
+// newAEAD returns an AES-GCM cipher for the key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plaintext with a random nonce, which is prepended to
+// the returned ciphertext.
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts a ciphertext returned by seal.
+func open(aead cipher.AEAD, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce := ciphertext[:aead.NonceSize()]
+	return aead.Open(nil, nonce, ciphertext[aead.NonceSize():], nil)
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestKey returns a base64 encoded master key filled with b
+func newTestKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(
+		bytes.Repeat([]byte{b}, _masterKeySize))
+}
+
+func TestLocalKMSWrapUnwrap(t *testing.T) {
+	kms, err := NewLocalKMS(map[string]string{
+		"k1": newTestKey(1),
+		"k2": newTestKey(2),
+	}, "k2")
+	assert.NoError(t, err)
+	assert.Equal(t, "k2", kms.CurrentKeyID())
+
+	dataKey := []byte("data key")
+	wrapped, err := kms.WrapKey("k1", dataKey)
+	assert.NoError(t, err)
+	assert.NotEqual(t, dataKey, wrapped)
+
+	unwrapped, err := kms.UnwrapKey("k1", wrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+
+	// unwrapping with another key fails
+	_, err = kms.UnwrapKey("k2", wrapped)
+	assert.Error(t, err)
+
+	_, err = kms.WrapKey("k3", dataKey)
+	assert.Error(t, err)
+	_, err = kms.UnwrapKey("k3", wrapped)
+	assert.Error(t, err)
+}
+
+func TestNewLocalKMSInvalidKeys(t *testing.T) {
+	_, err := NewLocalKMS(map[string]string{"k1": newTestKey(1)}, "k2")
+	assert.Error(t, err)
+
+	_, err = NewLocalKMS(map[string]string{"k1": "not base64!"}, "k1")
+	assert.Error(t, err)
+
+	_, err = NewLocalKMS(map[string]string{
+		"k1": base64.StdEncoding.EncodeToString([]byte("short")),
+	}, "k1")
+	assert.Error(t, err)
+}
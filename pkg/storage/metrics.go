@@ -101,6 +101,14 @@ type OrmJobMetrics struct {
 	JobNameToIDGetAll     tally.Counter
 	JobNameToIDGetAllFail tally.Counter
 
+	// job_external_ref_to_id
+	JobExternalRefCreate     tally.Counter
+	JobExternalRefCreateFail tally.Counter
+	JobExternalRefGet        tally.Counter
+	JobExternalRefGetFail    tally.Counter
+	JobExternalRefDelete     tally.Counter
+	JobExternalRefDeleteFail tally.Counter
+
 	// job_config
 	JobConfigCreate     tally.Counter
 	JobConfigCreateFail tally.Counter
@@ -118,6 +126,28 @@ type OrmJobMetrics struct {
 	SecretInfoUpdateFail tally.Counter
 	SecretInfoDelete     tally.Counter
 	SecretInfoDeleteFail tally.Counter
+
+	// cluster_snapshots
+	ClusterSnapshotAdd     tally.Counter
+	ClusterSnapshotAddFail tally.Counter
+	ClusterSnapshotGet     tally.Counter
+	ClusterSnapshotGetFail tally.Counter
+
+	// goal_state_pauses
+	GoalStatePauseSet     tally.Counter
+	GoalStatePauseSetFail tally.Counter
+	GoalStatePauseGet     tally.Counter
+	GoalStatePauseGetFail tally.Counter
+
+	// host_groups
+	HostGroupCreate     tally.Counter
+	HostGroupCreateFail tally.Counter
+	HostGroupUpdate     tally.Counter
+	HostGroupUpdateFail tally.Counter
+	HostGroupGet        tally.Counter
+	HostGroupGetFail    tally.Counter
+	HostGroupDelete     tally.Counter
+	HostGroupDeleteFail tally.Counter
 }
 
 // TaskMetrics is a struct for tracking all the task related counters in the storage layer
@@ -305,6 +335,30 @@ type OrmTaskMetrics struct {
 	PodEventsAddFail tally.Counter
 	PodEventsGet     tally.Counter
 	PodEventsGetFail tally.Counter
+
+	TaskOperationsAdd     tally.Counter
+	TaskOperationsAddFail tally.Counter
+	TaskOperationsGet     tally.Counter
+	TaskOperationsGetFail tally.Counter
+
+	TaskIdempotencyKeysAdd        tally.Counter
+	TaskIdempotencyKeysAddFail    tally.Counter
+	TaskIdempotencyKeysGet        tally.Counter
+	TaskIdempotencyKeysGetFail    tally.Counter
+	TaskIdempotencyKeysUpdate     tally.Counter
+	TaskIdempotencyKeysUpdateFail tally.Counter
+	TaskIdempotencyKeysDelete     tally.Counter
+	TaskIdempotencyKeysDeleteFail tally.Counter
+
+	RunDurationsAdd     tally.Counter
+	RunDurationsAddFail tally.Counter
+	RunDurationsGet     tally.Counter
+	RunDurationsGetFail tally.Counter
+
+	WatchJournalAdd     tally.Counter
+	WatchJournalAddFail tally.Counter
+	WatchJournalGet     tally.Counter
+	WatchJournalGetFail tally.Counter
 }
 
 // Metrics is a struct for tracking all the general purpose counters that have relevance to the storage
@@ -585,6 +639,12 @@ func NewMetrics(scope tally.Scope) *Metrics {
 	jobNameToIDFailScope := jobNameToIDScope.Tagged(
 		map[string]string{"result": "fail"})
 
+	jobExternalRefScope := ormScope.SubScope("job_external_ref_to_id")
+	jobExternalRefSuccessScope := jobExternalRefScope.Tagged(
+		map[string]string{"result": "success"})
+	jobExternalRefFailScope := jobExternalRefScope.Tagged(
+		map[string]string{"result": "fail"})
+
 	jobConfigScope := ormScope.SubScope("job_config")
 	jobConfigSuccessScope := jobConfigScope.Tagged(
 		map[string]string{"result": "success"})
@@ -597,12 +657,54 @@ func NewMetrics(scope tally.Scope) *Metrics {
 	podEventsFailScope := podEventsScope.Tagged(
 		map[string]string{"result": "fail"})
 
+	taskOperationsScope := ormScope.SubScope("task_operations")
+	taskOperationsSuccessScope := taskOperationsScope.Tagged(
+		map[string]string{"result": "success"})
+	taskOperationsFailScope := taskOperationsScope.Tagged(
+		map[string]string{"result": "fail"})
+
+	taskIdempotencyKeysScope := ormScope.SubScope("task_idempotency_keys")
+	taskIdempotencyKeysSuccessScope := taskIdempotencyKeysScope.Tagged(
+		map[string]string{"result": "success"})
+	taskIdempotencyKeysFailScope := taskIdempotencyKeysScope.Tagged(
+		map[string]string{"result": "fail"})
+
+	runDurationsScope := ormScope.SubScope("run_durations")
+	runDurationsSuccessScope := runDurationsScope.Tagged(
+		map[string]string{"result": "success"})
+	runDurationsFailScope := runDurationsScope.Tagged(
+		map[string]string{"result": "fail"})
+
+	watchJournalScope := ormScope.SubScope("watch_journal")
+	watchJournalSuccessScope := watchJournalScope.Tagged(
+		map[string]string{"result": "success"})
+	watchJournalFailScope := watchJournalScope.Tagged(
+		map[string]string{"result": "fail"})
+
 	secretInfoScope := ormScope.SubScope("secret_info")
 	secretInfoSuccessScope := secretInfoScope.Tagged(
 		map[string]string{"result": "success"})
 	secretInfoFailScope := secretInfoScope.Tagged(
 		map[string]string{"result": "fail"})
 
+	clusterSnapshotScope := ormScope.SubScope("cluster_snapshots")
+	clusterSnapshotSuccessScope := clusterSnapshotScope.Tagged(
+		map[string]string{"result": "success"})
+	clusterSnapshotFailScope := clusterSnapshotScope.Tagged(
+		map[string]string{"result": "fail"})
+
+	goalStatePauseScope := ormScope.SubScope("goal_state_pauses")
+	goalStatePauseSuccessScope := goalStatePauseScope.Tagged(
+		map[string]string{"result": "success"})
+	goalStatePauseFailScope := goalStatePauseScope.Tagged(
+		map[string]string{"result": "fail"})
+
+	hostGroupScope := ormScope.SubScope("host_groups")
+	hostGroupSuccessScope := hostGroupScope.Tagged(
+		map[string]string{"result": "success"})
+	hostGroupFailScope := hostGroupScope.Tagged(
+		map[string]string{"result": "fail"})
+
 	ormJobMetrics := &OrmJobMetrics{
 		JobIndexCreate:     jobIndexSuccessScope.Counter("create"),
 		JobIndexCreateFail: jobIndexFailScope.Counter("create"),
@@ -618,6 +720,13 @@ func NewMetrics(scope tally.Scope) *Metrics {
 		JobNameToIDGetAll:     jobNameToIDSuccessScope.Counter("get_all"),
 		JobNameToIDGetAllFail: jobNameToIDFailScope.Counter("get_all"),
 
+		JobExternalRefCreate:     jobExternalRefSuccessScope.Counter("create"),
+		JobExternalRefCreateFail: jobExternalRefFailScope.Counter("create"),
+		JobExternalRefGet:        jobExternalRefSuccessScope.Counter("get"),
+		JobExternalRefGetFail:    jobExternalRefFailScope.Counter("get"),
+		JobExternalRefDelete:     jobExternalRefSuccessScope.Counter("delete"),
+		JobExternalRefDeleteFail: jobExternalRefFailScope.Counter("delete"),
+
 		JobConfigCreate:     jobConfigSuccessScope.Counter("create"),
 		JobConfigCreateFail: jobConfigFailScope.Counter("create"),
 		JobConfigGet:        jobConfigSuccessScope.Counter("get"),
@@ -633,6 +742,25 @@ func NewMetrics(scope tally.Scope) *Metrics {
 		SecretInfoUpdateFail: secretInfoFailScope.Counter("update"),
 		SecretInfoDelete:     secretInfoSuccessScope.Counter("delete"),
 		SecretInfoDeleteFail: secretInfoFailScope.Counter("delete"),
+
+		ClusterSnapshotAdd:     clusterSnapshotSuccessScope.Counter("add"),
+		ClusterSnapshotAddFail: clusterSnapshotFailScope.Counter("add"),
+		ClusterSnapshotGet:     clusterSnapshotSuccessScope.Counter("get"),
+		ClusterSnapshotGetFail: clusterSnapshotFailScope.Counter("get"),
+
+		GoalStatePauseSet:     goalStatePauseSuccessScope.Counter("set"),
+		GoalStatePauseSetFail: goalStatePauseFailScope.Counter("set"),
+		GoalStatePauseGet:     goalStatePauseSuccessScope.Counter("get"),
+		GoalStatePauseGetFail: goalStatePauseFailScope.Counter("get"),
+
+		HostGroupCreate:     hostGroupSuccessScope.Counter("create"),
+		HostGroupCreateFail: hostGroupFailScope.Counter("create"),
+		HostGroupUpdate:     hostGroupSuccessScope.Counter("update"),
+		HostGroupUpdateFail: hostGroupFailScope.Counter("update"),
+		HostGroupGet:        hostGroupSuccessScope.Counter("get"),
+		HostGroupGetFail:    hostGroupFailScope.Counter("get"),
+		HostGroupDelete:     hostGroupSuccessScope.Counter("delete"),
+		HostGroupDeleteFail: hostGroupFailScope.Counter("delete"),
 	}
 
 	ormTaskMetrics := &OrmTaskMetrics{
@@ -640,6 +768,30 @@ func NewMetrics(scope tally.Scope) *Metrics {
 		PodEventsAddFail: podEventsFailScope.Counter("add"),
 		PodEventsGet:     podEventsSuccessScope.Counter("get"),
 		PodEventsGetFail: podEventsFailScope.Counter("get"),
+
+		TaskOperationsAdd:     taskOperationsSuccessScope.Counter("add"),
+		TaskOperationsAddFail: taskOperationsFailScope.Counter("add"),
+		TaskOperationsGet:     taskOperationsSuccessScope.Counter("get"),
+		TaskOperationsGetFail: taskOperationsFailScope.Counter("get"),
+
+		TaskIdempotencyKeysAdd:        taskIdempotencyKeysSuccessScope.Counter("add"),
+		TaskIdempotencyKeysAddFail:    taskIdempotencyKeysFailScope.Counter("add"),
+		TaskIdempotencyKeysGet:        taskIdempotencyKeysSuccessScope.Counter("get"),
+		TaskIdempotencyKeysGetFail:    taskIdempotencyKeysFailScope.Counter("get"),
+		TaskIdempotencyKeysUpdate:     taskIdempotencyKeysSuccessScope.Counter("update"),
+		TaskIdempotencyKeysUpdateFail: taskIdempotencyKeysFailScope.Counter("update"),
+		TaskIdempotencyKeysDelete:     taskIdempotencyKeysSuccessScope.Counter("delete"),
+		TaskIdempotencyKeysDeleteFail: taskIdempotencyKeysFailScope.Counter("delete"),
+
+		RunDurationsAdd:     runDurationsSuccessScope.Counter("add"),
+		RunDurationsAddFail: runDurationsFailScope.Counter("add"),
+		RunDurationsGet:     runDurationsSuccessScope.Counter("get"),
+		RunDurationsGetFail: runDurationsFailScope.Counter("get"),
+
+		WatchJournalAdd:     watchJournalSuccessScope.Counter("add"),
+		WatchJournalAddFail: watchJournalFailScope.Counter("add"),
+		WatchJournalGet:     watchJournalSuccessScope.Counter("get"),
+		WatchJournalGetFail: watchJournalFailScope.Counter("get"),
 	}
 
 	metrics := &Metrics{
@@ -0,0 +1,145 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objects
+
+import (
+	"context"
+	"time"
+
+	"github.com/uber/peloton/pkg/storage/objects/base"
+)
+
+// ClusterSnapshotBucketSize is the period of time the snapshots in the
+// same partition of the cluster_snapshot_times table were taken within.
+const ClusterSnapshotBucketSize = 24 * time.Hour
+
+// init adds the ClusterSnapshotObject and ClusterSnapshotTimeObject
+// instances to the global list of storage objects
+func init() {
+	Objs = append(Objs, &ClusterSnapshotObject{}, &ClusterSnapshotTimeObject{})
+}
+
+// ClusterSnapshotObject corresponds to a row in cluster_snapshots table.
+type ClusterSnapshotObject struct {
+	// base.Object DB specific annotations
+	base.Object `cassandra:"name=cluster_snapshots, primaryKey=((snapshot_time))"`
+	// SnapshotTime is the time the snapshot was taken at
+	SnapshotTime time.Time `column:"name=snapshot_time"`
+	// Data is the encoded state of the cluster
+	Data []byte `column:"name=data"`
+}
+
+// ClusterSnapshotTimeObject corresponds to a row in cluster_snapshot_times
+// table, which indexes the times of the snapshots by day so that the
+// snapshot nearest to a point in time is found without reading the others.
+type ClusterSnapshotTimeObject struct {
+	// base.Object DB specific annotations
+	base.Object `cassandra:"name=cluster_snapshot_times, primaryKey=((day), snapshot_time)"`
+	// Day is the day the snapshot was taken, see ClusterSnapshotDay
+	Day int64 `column:"name=day"`
+	// SnapshotTime is the time the snapshot was taken at
+	SnapshotTime time.Time `column:"name=snapshot_time"`
+}
+
+// ClusterSnapshotDay returns the day of the snapshots taken at t.
+func ClusterSnapshotDay(t time.Time) int64 {
+	return t.UnixNano() / int64(ClusterSnapshotBucketSize)
+}
+
+// ClusterSnapshotOps provides methods for manipulating cluster_snapshots
+// and cluster_snapshot_times tables.
+type ClusterSnapshotOps interface {
+	// Add adds a snapshot of the cluster state, and indexes its time by
+	// the day it was taken.
+	Add(ctx context.Context, obj *ClusterSnapshotObject) error
+
+	// Get returns the snapshot taken at the given time.
+	Get(ctx context.Context, snapshotTime time.Time) (*ClusterSnapshotObject, error)
+
+	// GetTimes returns the times of the snapshots taken on a day, sorted.
+	GetTimes(ctx context.Context, day int64) ([]time.Time, error)
+}
+
+// ensure that default implementation (clusterSnapshotOps) satisfies the
+// interface
+var _ ClusterSnapshotOps = (*clusterSnapshotOps)(nil)
+
+// clusterSnapshotOps implements ClusterSnapshotOps using a particular Store
+type clusterSnapshotOps struct {
+	store *Store
+}
+
+// NewClusterSnapshotOps constructs a ClusterSnapshotOps object for
+// provided Store.
+func NewClusterSnapshotOps(s *Store) ClusterSnapshotOps {
+	return &clusterSnapshotOps{store: s}
+}
+
+// Add adds a snapshot of the cluster state, and indexes its time by the
+// day it was taken. The snapshot is written first, so that an indexed
+// time always has a snapshot.
+func (d *clusterSnapshotOps) Add(
+	ctx context.Context,
+	obj *ClusterSnapshotObject,
+) error {
+	if err := d.store.oClient.Create(ctx, obj); err != nil {
+		d.store.metrics.OrmJobMetrics.ClusterSnapshotAddFail.Inc(1)
+		return err
+	}
+	if err := d.store.oClient.Create(ctx, &ClusterSnapshotTimeObject{
+		Day:          ClusterSnapshotDay(obj.SnapshotTime),
+		SnapshotTime: obj.SnapshotTime,
+	}); err != nil {
+		d.store.metrics.OrmJobMetrics.ClusterSnapshotAddFail.Inc(1)
+		return err
+	}
+	d.store.metrics.OrmJobMetrics.ClusterSnapshotAdd.Inc(1)
+	return nil
+}
+
+// Get returns the snapshot taken at the given time.
+func (d *clusterSnapshotOps) Get(
+	ctx context.Context,
+	snapshotTime time.Time,
+) (*ClusterSnapshotObject, error) {
+	obj := &ClusterSnapshotObject{SnapshotTime: snapshotTime}
+	if err := d.store.oClient.Get(ctx, obj); err != nil {
+		d.store.metrics.OrmJobMetrics.ClusterSnapshotGetFail.Inc(1)
+		return nil, err
+	}
+	d.store.metrics.OrmJobMetrics.ClusterSnapshotGet.Inc(1)
+	return obj, nil
+}
+
+// GetTimes returns the times of the snapshots taken on a day, sorted.
+func (d *clusterSnapshotOps) GetTimes(
+	ctx context.Context,
+	day int64,
+) ([]time.Time, error) {
+	result, err := d.store.oClient.GetAll(ctx, &ClusterSnapshotTimeObject{
+		Day: day,
+	})
+	if err != nil {
+		d.store.metrics.OrmJobMetrics.ClusterSnapshotGetFail.Inc(1)
+		return nil, err
+	}
+
+	var times []time.Time
+	for _, value := range result {
+		times = append(times, value.(*ClusterSnapshotTimeObject).SnapshotTime)
+	}
+	d.store.metrics.OrmJobMetrics.ClusterSnapshotGet.Inc(1)
+	return times, nil
+}
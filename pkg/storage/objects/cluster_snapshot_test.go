@@ -0,0 +1,73 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objects
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ClusterSnapshotObjectTestSuite struct {
+	suite.Suite
+}
+
+func (s *ClusterSnapshotObjectTestSuite) SetupTest() {
+}
+
+func TestClusterSnapshotObjectSuite(t *testing.T) {
+	suite.Run(t, new(ClusterSnapshotObjectTestSuite))
+}
+
+// TestClusterSnapshotOps tests adding the snapshots of the cluster state,
+// getting the times of the snapshots of a day and getting a snapshot
+func (s *ClusterSnapshotObjectTestSuite) TestClusterSnapshotOps() {
+	db := NewClusterSnapshotOps(testStore)
+	ctx := context.Background()
+	// use a day far in the past, so that the test does not see the
+	// snapshots added by the other tests
+	snapshotTime := time.Unix(0, 0).UTC().Add(
+		time.Duration(rand.Int63n(1<<12)) * ClusterSnapshotBucketSize)
+	day := ClusterSnapshotDay(snapshotTime)
+
+	times, err := db.GetTimes(ctx, day)
+	s.NoError(err)
+	s.Empty(times)
+
+	s.NoError(db.Add(ctx, &ClusterSnapshotObject{
+		SnapshotTime: snapshotTime.Add(time.Hour),
+		Data:         []byte("snapshot-2"),
+	}))
+	s.NoError(db.Add(ctx, &ClusterSnapshotObject{
+		SnapshotTime: snapshotTime,
+		Data:         []byte("snapshot-1"),
+	}))
+
+	times, err = db.GetTimes(ctx, day)
+	s.NoError(err)
+	s.Len(times, 2)
+	s.True(snapshotTime.Equal(times[0]))
+	s.True(snapshotTime.Add(time.Hour).Equal(times[1]))
+
+	snapshot, err := db.Get(ctx, times[1])
+	s.NoError(err)
+	s.Equal([]byte("snapshot-2"), snapshot.Data)
+
+	_, err = db.Get(ctx, snapshotTime.Add(time.Minute))
+	s.Error(err)
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objects
+
+import (
+	"context"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	"github.com/uber/peloton/pkg/storage/objects/base"
+
+	"github.com/gocql/gocql"
+)
+
+// init adds a GoalStatePauseObject instance to the global list of
+// storage objects
+func init() {
+	Objs = append(Objs, &GoalStatePauseObject{})
+}
+
+// GoalStatePauseObject corresponds to a row in goal_state_pauses table.
+type GoalStatePauseObject struct {
+	// base.Object DB specific annotations
+	base.Object `cassandra:"name=goal_state_pauses, primaryKey=((job_type))"`
+	// JobType is the name of the job type
+	JobType string `column:"name=job_type"`
+	// Paused is whether the goal state actions of the job type are paused
+	Paused bool `column:"name=paused"`
+	// UpdateTime is the time the job type was last paused or resumed
+	UpdateTime time.Time `column:"name=update_time"`
+}
+
+// GoalStatePauseOps provides methods for manipulating goal_state_pauses
+// table.
+type GoalStatePauseOps interface {
+	// Set records whether the goal state actions of a job type are paused.
+	Set(ctx context.Context, jobType job.JobType, paused bool) error
+
+	// Get returns whether the goal state actions of a job type are paused.
+	// A job type which was never paused is not paused.
+	Get(ctx context.Context, jobType job.JobType) (bool, error)
+}
+
+// ensure that default implementation (goalStatePauseOps) satisfies the
+// interface
+var _ GoalStatePauseOps = (*goalStatePauseOps)(nil)
+
+// goalStatePauseOps implements GoalStatePauseOps using a particular Store
+type goalStatePauseOps struct {
+	store *Store
+}
+
+// NewGoalStatePauseOps constructs a GoalStatePauseOps object for
+// provided Store.
+func NewGoalStatePauseOps(s *Store) GoalStatePauseOps {
+	return &goalStatePauseOps{store: s}
+}
+
+// Set records whether the goal state actions of a job type are paused.
+func (d *goalStatePauseOps) Set(
+	ctx context.Context,
+	jobType job.JobType,
+	paused bool,
+) error {
+	obj := &GoalStatePauseObject{
+		JobType:    jobType.String(),
+		Paused:     paused,
+		UpdateTime: time.Now().UTC(),
+	}
+
+	if err := d.store.oClient.Create(ctx, obj); err != nil {
+		d.store.metrics.OrmJobMetrics.GoalStatePauseSetFail.Inc(1)
+		return err
+	}
+	d.store.metrics.OrmJobMetrics.GoalStatePauseSet.Inc(1)
+	return nil
+}
+
+// Get returns whether the goal state actions of a job type are paused.
+// A job type which was never paused is not paused.
+func (d *goalStatePauseOps) Get(
+	ctx context.Context,
+	jobType job.JobType,
+) (bool, error) {
+	obj := &GoalStatePauseObject{
+		JobType: jobType.String(),
+	}
+
+	if err := d.store.oClient.Get(ctx, obj); err != nil {
+		if err == gocql.ErrNotFound {
+			d.store.metrics.OrmJobMetrics.GoalStatePauseGet.Inc(1)
+			return false, nil
+		}
+		d.store.metrics.OrmJobMetrics.GoalStatePauseGetFail.Inc(1)
+		return false, err
+	}
+	d.store.metrics.OrmJobMetrics.GoalStatePauseGet.Inc(1)
+	return obj.Paused, nil
+}
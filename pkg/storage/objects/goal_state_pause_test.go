@@ -0,0 +1,89 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objects
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/job"
+	ormmocks "github.com/uber/peloton/pkg/storage/orm/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+)
+
+type GoalStatePauseObjectTestSuite struct {
+	suite.Suite
+}
+
+func TestGoalStatePauseObjectSuite(t *testing.T) {
+	suite.Run(t, new(GoalStatePauseObjectTestSuite))
+}
+
+// TestGoalStatePauseOps tests pausing and resuming a job type in DB
+func (s *GoalStatePauseObjectTestSuite) TestGoalStatePauseOps() {
+	db := NewGoalStatePauseOps(testStore)
+	ctx := context.Background()
+	// use an unknown job type, so that the test does not see the
+	// job types paused by the other tests
+	jobType := job.JobType(100 + rand.Int31n(1<<20))
+
+	paused, err := db.Get(ctx, jobType)
+	s.NoError(err)
+	s.False(paused)
+
+	s.NoError(db.Set(ctx, jobType, true))
+	paused, err = db.Get(ctx, jobType)
+	s.NoError(err)
+	s.True(paused)
+
+	s.NoError(db.Set(ctx, jobType, false))
+	paused, err = db.Get(ctx, jobType)
+	s.NoError(err)
+	s.False(paused)
+}
+
+// TestGoalStatePauseOpsClientFail tests failure cases due to ORM Client
+// errors
+func (s *GoalStatePauseObjectTestSuite) TestGoalStatePauseOpsClientFail() {
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	mockClient := ormmocks.NewMockClient(ctrl)
+	mockStore := &Store{
+		oClient:   mockClient,
+		metrics:   testStore.metrics,
+		encrypter: testStore.encrypter,
+	}
+	db := NewGoalStatePauseOps(mockStore)
+
+	mockClient.EXPECT().Create(gomock.Any(), gomock.Any()).
+		Return(errors.New("create failed"))
+	mockClient.EXPECT().Get(gomock.Any(), gomock.Any()).
+		Return(errors.New("get failed"))
+
+	ctx := context.Background()
+
+	err := db.Set(ctx, job.JobType_BATCH, true)
+	s.Error(err)
+	s.Equal("create failed", err.Error())
+
+	_, err = db.Get(ctx, job.JobType_BATCH)
+	s.Error(err)
+	s.Equal("get failed", err.Error())
+}
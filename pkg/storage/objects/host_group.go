@@ -0,0 +1,210 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objects
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/uber/peloton/pkg/storage/objects/base"
+)
+
+// _hostGroupBucket is the bucket of the host_groups table all the groups
+// are kept in, so that they can all be read with a single query
+const _hostGroupBucket = 0
+
+// init adds a HostGroupObject instance to the global list of storage
+// objects
+func init() {
+	Objs = append(Objs, &HostGroupObject{})
+}
+
+// HostGroupObject corresponds to a row in host_groups table.
+type HostGroupObject struct {
+	// base.Object DB specific annotations
+	base.Object `cassandra:"name=host_groups, primaryKey=((bucket), name)"`
+	// Bucket of the group, all the groups are in the same bucket
+	Bucket int64 `column:"name=bucket"`
+	// Name of the group
+	Name string `column:"name=name"`
+	// Hostnames of the hosts of the group in JSON format
+	Hostnames string `column:"name=hostnames"`
+	// StartTime is the time the drain of the group was started
+	StartTime time.Time `column:"name=start_time"`
+	// CompletionTime is the time the drain of the group was completed,
+	// zero while the group is being drained
+	CompletionTime time.Time `column:"name=completion_time"`
+}
+
+// HostGroup is a group of hosts drained together by a host image rollout
+// system.
+type HostGroup struct {
+	Name           string
+	Hostnames      []string
+	StartTime      time.Time
+	CompletionTime time.Time
+}
+
+// Completed returns whether the drain of the group is completed.
+func (g *HostGroup) Completed() bool {
+	return !g.CompletionTime.IsZero()
+}
+
+// newHostGroup returns the host group of a row in host_groups table.
+func newHostGroup(obj *HostGroupObject) (*HostGroup, error) {
+	group := &HostGroup{
+		Name:           obj.Name,
+		StartTime:      obj.StartTime,
+		CompletionTime: obj.CompletionTime,
+	}
+	if err := json.Unmarshal([]byte(obj.Hostnames), &group.Hostnames); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// HostGroupOps provides methods for manipulating host_groups table.
+type HostGroupOps interface {
+	// Create creates a host group.
+	Create(ctx context.Context, group *HostGroup) error
+
+	// Complete records the time the drain of a host group was completed.
+	Complete(ctx context.Context, name string, completionTime time.Time) error
+
+	// Get returns a host group.
+	Get(ctx context.Context, name string) (*HostGroup, error)
+
+	// GetAll returns all the host groups.
+	GetAll(ctx context.Context) ([]*HostGroup, error)
+
+	// Delete deletes a host group.
+	Delete(ctx context.Context, name string) error
+}
+
+// ensure that default implementation (hostGroupOps) satisfies the
+// interface
+var _ HostGroupOps = (*hostGroupOps)(nil)
+
+// hostGroupOps implements HostGroupOps using a particular Store
+type hostGroupOps struct {
+	store *Store
+}
+
+// NewHostGroupOps constructs a HostGroupOps object for provided Store.
+func NewHostGroupOps(s *Store) HostGroupOps {
+	return &hostGroupOps{store: s}
+}
+
+// Create creates a host group.
+func (d *hostGroupOps) Create(ctx context.Context, group *HostGroup) error {
+	hostnames, err := json.Marshal(group.Hostnames)
+	if err != nil {
+		d.store.metrics.OrmJobMetrics.HostGroupCreateFail.Inc(1)
+		return err
+	}
+
+	obj := &HostGroupObject{
+		Bucket:    _hostGroupBucket,
+		Name:      group.Name,
+		Hostnames: string(hostnames),
+		StartTime: group.StartTime,
+	}
+	if err := d.store.oClient.Create(ctx, obj); err != nil {
+		d.store.metrics.OrmJobMetrics.HostGroupCreateFail.Inc(1)
+		return err
+	}
+	d.store.metrics.OrmJobMetrics.HostGroupCreate.Inc(1)
+	return nil
+}
+
+// Complete records the time the drain of a host group was completed.
+func (d *hostGroupOps) Complete(
+	ctx context.Context,
+	name string,
+	completionTime time.Time,
+) error {
+	obj := &HostGroupObject{
+		Bucket:         _hostGroupBucket,
+		Name:           name,
+		CompletionTime: completionTime,
+	}
+	if err := d.store.oClient.Update(ctx, obj, "CompletionTime"); err != nil {
+		d.store.metrics.OrmJobMetrics.HostGroupUpdateFail.Inc(1)
+		return err
+	}
+	d.store.metrics.OrmJobMetrics.HostGroupUpdate.Inc(1)
+	return nil
+}
+
+// Get returns a host group.
+func (d *hostGroupOps) Get(
+	ctx context.Context,
+	name string,
+) (*HostGroup, error) {
+	obj := &HostGroupObject{
+		Bucket: _hostGroupBucket,
+		Name:   name,
+	}
+	if err := d.store.oClient.Get(ctx, obj); err != nil {
+		d.store.metrics.OrmJobMetrics.HostGroupGetFail.Inc(1)
+		return nil, err
+	}
+
+	group, err := newHostGroup(obj)
+	if err != nil {
+		d.store.metrics.OrmJobMetrics.HostGroupGetFail.Inc(1)
+		return nil, err
+	}
+	d.store.metrics.OrmJobMetrics.HostGroupGet.Inc(1)
+	return group, nil
+}
+
+// GetAll returns all the host groups.
+func (d *hostGroupOps) GetAll(ctx context.Context) ([]*HostGroup, error) {
+	result, err := d.store.oClient.GetAll(ctx, &HostGroupObject{
+		Bucket: _hostGroupBucket,
+	})
+	if err != nil {
+		d.store.metrics.OrmJobMetrics.HostGroupGetFail.Inc(1)
+		return nil, err
+	}
+
+	var groups []*HostGroup
+	for _, value := range result {
+		group, err := newHostGroup(value.(*HostGroupObject))
+		if err != nil {
+			d.store.metrics.OrmJobMetrics.HostGroupGetFail.Inc(1)
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	d.store.metrics.OrmJobMetrics.HostGroupGet.Inc(1)
+	return groups, nil
+}
+
+// Delete deletes a host group.
+func (d *hostGroupOps) Delete(ctx context.Context, name string) error {
+	obj := &HostGroupObject{
+		Bucket: _hostGroupBucket,
+		Name:   name,
+	}
+	if err := d.store.oClient.Delete(ctx, obj); err != nil {
+		d.store.metrics.OrmJobMetrics.HostGroupDeleteFail.Inc(1)
+		return err
+	}
+	d.store.metrics.OrmJobMetrics.HostGroupDelete.Inc(1)
+	return nil
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objects
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ormmocks "github.com/uber/peloton/pkg/storage/orm/mocks"
+
+	"github.com/gocql/gocql"
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type HostGroupObjectTestSuite struct {
+	suite.Suite
+}
+
+func TestHostGroupObjectSuite(t *testing.T) {
+	suite.Run(t, new(HostGroupObjectTestSuite))
+}
+
+// getHostGroup returns the group with the name among the groups in DB
+func (s *HostGroupObjectTestSuite) getHostGroup(
+	db HostGroupOps,
+	name string,
+) *HostGroup {
+	groups, err := db.GetAll(context.Background())
+	s.NoError(err)
+	for _, group := range groups {
+		if group.Name == name {
+			return group
+		}
+	}
+	return nil
+}
+
+// TestHostGroupOps tests creating, completing and deleting a host group
+// in DB
+func (s *HostGroupObjectTestSuite) TestHostGroupOps() {
+	db := NewHostGroupOps(testStore)
+	ctx := context.Background()
+	// the groups of the other tests are in the same partition
+	name := uuid.New()
+	startTime := time.Now().UTC().Truncate(time.Millisecond)
+
+	s.NoError(db.Create(ctx, &HostGroup{
+		Name:      name,
+		Hostnames: []string{"host1", "host2"},
+		StartTime: startTime,
+	}))
+	group, err := db.Get(ctx, name)
+	s.NoError(err)
+	s.Equal(name, group.Name)
+	s.Equal([]string{"host1", "host2"}, group.Hostnames)
+	s.True(startTime.Equal(group.StartTime))
+	s.False(group.Completed())
+	s.Equal(group, s.getHostGroup(db, name))
+
+	completionTime := startTime.Add(time.Hour)
+	s.NoError(db.Complete(ctx, name, completionTime))
+	group = s.getHostGroup(db, name)
+	s.NotNil(group)
+	s.Equal([]string{"host1", "host2"}, group.Hostnames)
+	s.True(group.Completed())
+	s.True(completionTime.Equal(group.CompletionTime))
+
+	s.NoError(db.Delete(ctx, name))
+	_, err = db.Get(ctx, name)
+	s.Equal(gocql.ErrNotFound, err)
+	s.Nil(s.getHostGroup(db, name))
+}
+
+// TestHostGroupOpsClientFail tests failure cases due to ORM Client errors
+func (s *HostGroupObjectTestSuite) TestHostGroupOpsClientFail() {
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	mockClient := ormmocks.NewMockClient(ctrl)
+	mockStore := &Store{
+		oClient:   mockClient,
+		metrics:   testStore.metrics,
+		encrypter: testStore.encrypter,
+	}
+	db := NewHostGroupOps(mockStore)
+
+	mockClient.EXPECT().Create(gomock.Any(), gomock.Any()).
+		Return(errors.New("create failed"))
+	mockClient.EXPECT().Update(gomock.Any(), gomock.Any(), "CompletionTime").
+		Return(errors.New("update failed"))
+	mockClient.EXPECT().Get(gomock.Any(), gomock.Any()).
+		Return(errors.New("get failed"))
+	mockClient.EXPECT().GetAll(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("getall failed"))
+	mockClient.EXPECT().Delete(gomock.Any(), gomock.Any()).
+		Return(errors.New("delete failed"))
+
+	ctx := context.Background()
+
+	err := db.Create(ctx, &HostGroup{Name: "group"})
+	s.Error(err)
+	s.Equal("create failed", err.Error())
+
+	err = db.Complete(ctx, "group", time.Now())
+	s.Error(err)
+	s.Equal("update failed", err.Error())
+
+	_, err = db.Get(ctx, "group")
+	s.Error(err)
+	s.Equal("get failed", err.Error())
+
+	_, err = db.GetAll(ctx)
+	s.Error(err)
+	s.Equal("getall failed", err.Error())
+
+	err = db.Delete(ctx, "group")
+	s.Error(err)
+	s.Equal("delete failed", err.Error())
+}
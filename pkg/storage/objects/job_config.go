@@ -25,6 +25,7 @@ import (
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
 	"github.com/uber/peloton/.gen/peloton/private/models"
 
+	"github.com/uber/peloton/pkg/storage/encryption"
 	"github.com/uber/peloton/pkg/storage/objects/base"
 
 	"github.com/gogo/protobuf/proto"
@@ -179,6 +180,12 @@ func (d *jobConfigOps) Create(
 	version uint64,
 ) error {
 
+	config, err := encryption.EncryptJobConfigLabels(d.store.encrypter, config)
+	if err != nil {
+		d.store.metrics.OrmJobMetrics.JobConfigCreateFail.Inc(1)
+		return errors.Wrap(err, "Failed to encrypt labels")
+	}
+
 	obj, err := newJobConfigObject(id, version, config, configAddOn)
 	if err != nil {
 		d.store.metrics.OrmJobMetrics.JobConfigCreateFail.Inc(1)
@@ -217,6 +224,12 @@ func (d *jobConfigOps) Get(
 		return nil, nil, errors.Wrap(err, "Failed to unmarshal config")
 	}
 
+	if err := encryption.DecryptJobConfigLabels(
+		d.store.encrypter, config); err != nil {
+		d.store.metrics.OrmJobMetrics.JobConfigGetFail.Inc(1)
+		return nil, nil, errors.Wrap(err, "Failed to decrypt labels")
+	}
+
 	configAddOn, err := obj.toConfigAddOn()
 	if err != nil {
 		d.store.metrics.OrmJobMetrics.JobConfigGetFail.Inc(1)
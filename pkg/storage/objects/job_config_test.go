@@ -81,7 +81,11 @@ func (s *JobConfigObjectTestSuite) TestCreateGetDeleteJobConfigFail() {
 	defer ctrl.Finish()
 
 	mockClient := ormmocks.NewMockClient(ctrl)
-	mockStore := &Store{oClient: mockClient, metrics: testStore.metrics}
+	mockStore := &Store{
+		oClient:   mockClient,
+		metrics:   testStore.metrics,
+		encrypter: testStore.encrypter,
+	}
 	configOps := NewJobConfigOps(mockStore)
 
 	mockClient.EXPECT().CreateIfNotExists(gomock.Any(), gomock.Any()).
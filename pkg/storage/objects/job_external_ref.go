@@ -0,0 +1,135 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objects
+
+import (
+	"context"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+
+	"github.com/uber/peloton/pkg/storage/objects/base"
+)
+
+// init adds a JobExternalRefObject instance to the global list of storage objects
+func init() {
+	Objs = append(Objs, &JobExternalRefObject{})
+}
+
+// JobExternalRefObject corresponds to a row in job_external_ref_to_id table.
+type JobExternalRefObject struct {
+	// DB specific annotations
+	base.Object `cassandra:"name=job_external_ref_to_id, primaryKey=((external_ref_id))"`
+
+	// Reference ID supplied by an external system at job creation
+	ExternalRefID string `column:"name=external_ref_id"`
+	// JobID of the job
+	JobID string `column:"name=job_id"`
+	// Time when the job was created
+	CreateTime time.Time `column:"name=create_time"`
+}
+
+// JobExternalRefOps provides methods for manipulating
+// job_external_ref_to_id table.
+type JobExternalRefOps interface {
+	// Create maps an external reference ID to a job. An error of
+	// type AlreadyExists is returned if the external reference ID
+	// is already mapped to a job.
+	Create(
+		ctx context.Context,
+		externalRefID string,
+		id *peloton.JobID,
+	) error
+
+	// Get returns the job the external reference ID is mapped to.
+	Get(
+		ctx context.Context,
+		externalRefID string,
+	) (*peloton.JobID, error)
+
+	// Delete removes the mapping of an external reference ID to a job.
+	Delete(ctx context.Context, externalRefID string) error
+}
+
+// ensure that default implementation (jobExternalRefOps) satisfies the interface
+var _ JobExternalRefOps = (*jobExternalRefOps)(nil)
+
+// jobExternalRefOps implements JobExternalRefOps using a particular Store
+type jobExternalRefOps struct {
+	store *Store
+}
+
+// NewJobExternalRefOps constructs a JobExternalRefOps object for provided Store.
+func NewJobExternalRefOps(s *Store) JobExternalRefOps {
+	return &jobExternalRefOps{store: s}
+}
+
+// Create creates a JobExternalRefObject in db if the external reference ID
+// is not mapped to a job yet
+func (d *jobExternalRefOps) Create(
+	ctx context.Context,
+	externalRefID string,
+	id *peloton.JobID,
+) error {
+	obj := &JobExternalRefObject{
+		ExternalRefID: externalRefID,
+		JobID:         id.GetValue(),
+		CreateTime:    time.Now().UTC(),
+	}
+
+	if err := d.store.oClient.CreateIfNotExists(ctx, obj); err != nil {
+		d.store.metrics.OrmJobMetrics.JobExternalRefCreateFail.Inc(1)
+		return err
+	}
+
+	d.store.metrics.OrmJobMetrics.JobExternalRefCreate.Inc(1)
+	return nil
+}
+
+// Get gets the jobID for an external reference ID from DB
+func (d *jobExternalRefOps) Get(
+	ctx context.Context,
+	externalRefID string,
+) (*peloton.JobID, error) {
+	obj := &JobExternalRefObject{
+		ExternalRefID: externalRefID,
+	}
+
+	if err := d.store.oClient.Get(ctx, obj); err != nil {
+		d.store.metrics.OrmJobMetrics.JobExternalRefGetFail.Inc(1)
+		return nil, err
+	}
+
+	d.store.metrics.OrmJobMetrics.JobExternalRefGet.Inc(1)
+	return &peloton.JobID{Value: obj.JobID}, nil
+}
+
+// Delete removes the JobExternalRefObject of an external reference ID from DB
+func (d *jobExternalRefOps) Delete(
+	ctx context.Context,
+	externalRefID string,
+) error {
+	obj := &JobExternalRefObject{
+		ExternalRefID: externalRefID,
+	}
+
+	if err := d.store.oClient.Delete(ctx, obj); err != nil {
+		d.store.metrics.OrmJobMetrics.JobExternalRefDeleteFail.Inc(1)
+		return err
+	}
+
+	d.store.metrics.OrmJobMetrics.JobExternalRefDelete.Inc(1)
+	return nil
+}
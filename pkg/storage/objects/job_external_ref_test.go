@@ -0,0 +1,106 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objects
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	ormmocks "github.com/uber/peloton/pkg/storage/orm/mocks"
+
+	"github.com/gocql/gocql"
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+type JobExternalRefObjectTestSuite struct {
+	suite.Suite
+}
+
+func TestJobExternalRefObjectSuite(t *testing.T) {
+	suite.Run(t, new(JobExternalRefObjectTestSuite))
+}
+
+// TestCreateGetJobExternalRef tests creating and getting
+// JobExternalRefObject in DB
+func (s *JobExternalRefObjectTestSuite) TestCreateGetJobExternalRef() {
+	db := NewJobExternalRefOps(testStore)
+	ctx := context.Background()
+
+	refID := "workflow-run-" + uuid.New()
+	jobID := &peloton.JobID{Value: uuid.New()}
+
+	_, err := db.Get(ctx, refID)
+	s.Equal(gocql.ErrNotFound, err)
+
+	s.NoError(db.Create(ctx, refID, jobID))
+
+	id, err := db.Get(ctx, refID)
+	s.NoError(err)
+	s.Equal(jobID.GetValue(), id.GetValue())
+
+	// the external reference ID cannot be mapped to another job
+	err = db.Create(ctx, refID, &peloton.JobID{Value: uuid.New()})
+	s.True(yarpcerrors.IsAlreadyExists(err))
+
+	id, err = db.Get(ctx, refID)
+	s.NoError(err)
+	s.Equal(jobID.GetValue(), id.GetValue())
+
+	// the external reference ID can be mapped again once deleted
+	s.NoError(db.Delete(ctx, refID))
+	_, err = db.Get(ctx, refID)
+	s.Equal(gocql.ErrNotFound, err)
+	s.NoError(db.Create(ctx, refID, jobID))
+}
+
+// TestJobExternalRefOpsClientFail tests failure cases due to ORM Client errors
+func (s *JobExternalRefObjectTestSuite) TestJobExternalRefOpsClientFail() {
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	mockClient := ormmocks.NewMockClient(ctrl)
+	mockStore := &Store{
+		oClient:   mockClient,
+		metrics:   testStore.metrics,
+		encrypter: testStore.encrypter,
+	}
+	db := NewJobExternalRefOps(mockStore)
+
+	mockClient.EXPECT().CreateIfNotExists(gomock.Any(), gomock.Any()).
+		Return(errors.New("create failed"))
+	mockClient.EXPECT().Get(gomock.Any(), gomock.Any()).
+		Return(errors.New("get failed"))
+	mockClient.EXPECT().Delete(gomock.Any(), gomock.Any()).
+		Return(errors.New("delete failed"))
+
+	ctx := context.Background()
+
+	err := db.Create(ctx, "test", &peloton.JobID{Value: uuid.New()})
+	s.Error(err)
+	s.Equal("create failed", err.Error())
+
+	_, err = db.Get(ctx, "test")
+	s.Error(err)
+	s.Equal("get failed", err.Error())
+
+	err = db.Delete(ctx, "test")
+	s.Error(err)
+	s.Equal("delete failed", err.Error())
+}
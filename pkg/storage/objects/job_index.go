@@ -21,6 +21,7 @@ import (
 
 	"github.com/uber/peloton/.gen/peloton/api/v0/job"
 	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/pkg/storage/encryption"
 	"github.com/uber/peloton/pkg/storage/objects/base"
 
 	"github.com/pkg/errors"
@@ -248,6 +249,11 @@ func (d *jobIndexOps) Create(
 	config *job.JobConfig,
 	runtime *job.RuntimeInfo,
 ) error {
+	config, err := encryption.EncryptJobConfigLabels(d.store.encrypter, config)
+	if err != nil {
+		d.store.metrics.OrmJobMetrics.JobIndexCreateFail.Inc(1)
+		return errors.Wrap(err, "Failed to encrypt labels")
+	}
 	obj, err := newJobIndexObject(id, config, runtime)
 	if err != nil {
 		d.store.metrics.OrmJobMetrics.JobIndexCreateFail.Inc(1)
@@ -286,7 +292,13 @@ func (d *jobIndexOps) GetSummary(
 	if err != nil {
 		return nil, err
 	}
-	return jobIndexObject.ToJobSummary(), nil
+	summary := jobIndexObject.ToJobSummary()
+	summary.Labels, err = encryption.DecryptLabels(
+		d.store.encrypter, summary.GetLabels())
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to decrypt labels")
+	}
+	return summary, nil
 }
 
 // Update updates a JobIndexObject in db
@@ -299,6 +311,11 @@ func (d *jobIndexOps) Update(
 	if config == nil && runtime == nil {
 		return nil
 	}
+	config, err := encryption.EncryptJobConfigLabels(d.store.encrypter, config)
+	if err != nil {
+		d.store.metrics.OrmJobMetrics.JobIndexUpdateFail.Inc(1)
+		return errors.Wrap(err, "Failed to encrypt labels")
+	}
 	obj, err := newJobIndexObject(id, config, runtime)
 	if err != nil {
 		d.store.metrics.OrmJobMetrics.JobIndexUpdateFail.Inc(1)
@@ -268,7 +268,11 @@ func (s *JobIndexObjectTestSuite) TestJobIndexOpsClientFail() {
 	defer ctrl.Finish()
 
 	mockClient := ormmocks.NewMockClient(ctrl)
-	mockStore := &Store{oClient: mockClient, metrics: testStore.metrics}
+	mockStore := &Store{
+		oClient:   mockClient,
+		metrics:   testStore.metrics,
+		encrypter: testStore.encrypter,
+	}
 	indexOps := NewJobIndexOps(mockStore)
 
 	mockClient.EXPECT().Create(gomock.Any(), gomock.Any()).
@@ -106,7 +106,11 @@ func (s *JobNameToIDObjectTestSuite) TestJobNameToIDOpsClientFail() {
 	defer ctrl.Finish()
 
 	mockClient := ormmocks.NewMockClient(ctrl)
-	mockStore := &Store{oClient: mockClient, metrics: testStore.metrics}
+	mockStore := &Store{
+		oClient:   mockClient,
+		metrics:   testStore.metrics,
+		encrypter: testStore.encrypter,
+	}
 	db := NewJobNameToIDOps(mockStore)
 
 	mockClient.EXPECT().Create(gomock.Any(), gomock.Any()).
@@ -0,0 +1,128 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objects
+
+import (
+	"context"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+
+	"github.com/uber/peloton/pkg/storage/objects/base"
+)
+
+// init adds a RunDurationObject instance to the global list of storage objects
+func init() {
+	Objs = append(Objs, &RunDurationObject{})
+}
+
+// RunDurationObject corresponds to a row in run_durations table.
+type RunDurationObject struct {
+	// base.Object DB specific annotations
+	base.Object `cassandra:"name=run_durations, primaryKey=((job_id), instance_id, run_id)"`
+	// JobID of the job (uuid)
+	JobID string `column:"name=job_id"`
+	// InstanceID of the task
+	InstanceID uint32 `column:"name=instance_id"`
+	// RunID of the run of the task
+	RunID uint64 `column:"name=run_id"`
+	// StartLatencyMs is the time the run took from being initialized
+	// until running, in milliseconds
+	StartLatencyMs uint64 `column:"name=start_latency_ms"`
+	// RunDurationMs is the time the run was running until it completed,
+	// in milliseconds
+	RunDurationMs uint64 `column:"name=run_duration_ms"`
+}
+
+// RunDurationOps provides methods for manipulating run_durations table.
+type RunDurationOps interface {
+	// Create records the durations of a completed run of a task.
+	Create(
+		ctx context.Context,
+		jobID *peloton.JobID,
+		instanceID uint32,
+		runID uint64,
+		startLatency time.Duration,
+		runDuration time.Duration,
+	) error
+
+	// GetAll returns the durations of the completed runs of the tasks
+	// of a job which have not expired yet.
+	GetAll(
+		ctx context.Context,
+		jobID *peloton.JobID,
+	) ([]*RunDurationObject, error)
+}
+
+// ensure that default implementation (runDurationOps) satisfies the interface
+var _ RunDurationOps = (*runDurationOps)(nil)
+
+// runDurationOps implements RunDurationOps using a particular Store
+type runDurationOps struct {
+	store *Store
+}
+
+// NewRunDurationOps constructs a RunDurationOps object for provided Store.
+func NewRunDurationOps(s *Store) RunDurationOps {
+	return &runDurationOps{store: s}
+}
+
+// Create records the durations of a completed run of a task. Recording
+// the same run again overwrites its durations.
+func (d *runDurationOps) Create(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	instanceID uint32,
+	runID uint64,
+	startLatency time.Duration,
+	runDuration time.Duration,
+) error {
+	obj := &RunDurationObject{
+		JobID:          jobID.GetValue(),
+		InstanceID:     instanceID,
+		RunID:          runID,
+		StartLatencyMs: uint64(startLatency / time.Millisecond),
+		RunDurationMs:  uint64(runDuration / time.Millisecond),
+	}
+
+	if err := d.store.oClient.Create(ctx, obj); err != nil {
+		d.store.metrics.OrmTaskMetrics.RunDurationsAddFail.Inc(1)
+		return err
+	}
+	d.store.metrics.OrmTaskMetrics.RunDurationsAdd.Inc(1)
+	return nil
+}
+
+// GetAll returns the durations of the completed runs of the tasks
+// of a job which have not expired yet.
+func (d *runDurationOps) GetAll(
+	ctx context.Context,
+	jobID *peloton.JobID,
+) ([]*RunDurationObject, error) {
+	result, err := d.store.oClient.GetAll(ctx, &RunDurationObject{
+		JobID: jobID.GetValue(),
+	})
+	if err != nil {
+		d.store.metrics.OrmTaskMetrics.RunDurationsGetFail.Inc(1)
+		return nil, err
+	}
+
+	var runs []*RunDurationObject
+	for _, value := range result {
+		runs = append(runs, value.(*RunDurationObject))
+	}
+	d.store.metrics.OrmTaskMetrics.RunDurationsGet.Inc(1)
+	return runs, nil
+}
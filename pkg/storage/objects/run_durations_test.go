@@ -0,0 +1,62 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objects
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type RunDurationObjectTestSuite struct {
+	suite.Suite
+}
+
+func (s *RunDurationObjectTestSuite) SetupTest() {
+}
+
+func TestRunDurationObjectSuite(t *testing.T) {
+	suite.Run(t, new(RunDurationObjectTestSuite))
+}
+
+// TestRunDurationOps tests recording and getting the run durations of a job
+func (s *RunDurationObjectTestSuite) TestRunDurationOps() {
+	db := NewRunDurationOps(testStore)
+	ctx := context.Background()
+	jobID := &peloton.JobID{Value: uuid.New()}
+
+	runs, err := db.GetAll(ctx, jobID)
+	s.NoError(err)
+	s.Empty(runs)
+
+	s.NoError(db.Create(ctx, jobID, 0, 1, 2*time.Second, time.Minute))
+	s.NoError(db.Create(ctx, jobID, 1, 1, 3*time.Second, 2*time.Minute))
+	// recording the same run again overwrites it
+	s.NoError(db.Create(ctx, jobID, 1, 1, 4*time.Second, 2*time.Minute))
+
+	runs, err = db.GetAll(ctx, jobID)
+	s.NoError(err)
+	s.Len(runs, 2)
+	s.Equal(uint32(0), runs[0].InstanceID)
+	s.Equal(uint64(2000), runs[0].StartLatencyMs)
+	s.Equal(uint64(60000), runs[0].RunDurationMs)
+	s.Equal(uint32(1), runs[1].InstanceID)
+	s.Equal(uint64(4000), runs[1].StartLatencyMs)
+}
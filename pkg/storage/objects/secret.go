@@ -137,7 +137,12 @@ func (s *secretInfoOps) CreateSecret(
 	now time.Time,
 	secretID, secretString, secretPath string,
 ) error {
-	obj, err := newSecretObject(jobID, now, secretID, secretString, secretPath)
+	data, err := s.store.encrypter.Encrypt([]byte(secretString))
+	if err != nil {
+		s.store.metrics.OrmJobMetrics.SecretInfoCreateFail.Inc(1)
+		return errors.Wrap(err, "Failed to encrypt secret data")
+	}
+	obj, err := newSecretObject(jobID, now, secretID, data, secretPath)
 	if err != nil {
 		s.store.metrics.OrmJobMetrics.SecretInfoCreateFail.Inc(1)
 		return errors.Wrap(err, "Failed to construct SecretInfoObject")
@@ -163,6 +168,12 @@ func (s *secretInfoOps) GetSecret(
 		s.store.metrics.OrmJobMetrics.SecretInfoGetFail.Inc(1)
 		return nil, err
 	}
+	data, err := s.store.encrypter.Decrypt(secretInfoObject.Data)
+	if err != nil {
+		s.store.metrics.OrmJobMetrics.SecretInfoGetFail.Inc(1)
+		return nil, errors.Wrap(err, "Failed to decrypt secret data")
+	}
+	secretInfoObject.Data = string(data)
 	s.store.metrics.OrmJobMetrics.SecretInfoGet.Inc(1)
 	return secretInfoObject, nil
 }
@@ -172,10 +183,15 @@ func (s *secretInfoOps) UpdateSecretData(
 	ctx context.Context,
 	secretID, secretString string,
 ) error {
+	data, err := s.store.encrypter.Encrypt([]byte(secretString))
+	if err != nil {
+		s.store.metrics.OrmJobMetrics.SecretInfoUpdateFail.Inc(1)
+		return errors.Wrap(err, "Failed to encrypt secret data")
+	}
 	secretInfoObject := &SecretInfoObject{
 		SecretID: secretID,
 		Valid:    true,
-		Data:     secretString,
+		Data:     data,
 	}
 	fieldToUpdate := []string{"Data"}
 	if err := s.store.oClient.Update(ctx, secretInfoObject, fieldToUpdate...); err != nil {
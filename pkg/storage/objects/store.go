@@ -18,6 +18,7 @@ import (
 	pelotonstore "github.com/uber/peloton/pkg/storage"
 	"github.com/uber/peloton/pkg/storage/cassandra"
 	escassandra "github.com/uber/peloton/pkg/storage/connectors/cassandra"
+	"github.com/uber/peloton/pkg/storage/encryption"
 	"github.com/uber/peloton/pkg/storage/objects/base"
 	"github.com/uber/peloton/pkg/storage/orm"
 
@@ -33,6 +34,8 @@ var Objs []base.Object
 type Store struct {
 	oClient orm.Client
 	metrics *pelotonstore.Metrics
+	// encrypter encrypts the sensitive fields stored in the DB
+	encrypter encryption.Encrypter
 }
 
 // NewCassandraStore creates a new Cassandra storage client
@@ -50,8 +53,13 @@ func NewCassandraStore(
 	if err != nil {
 		return nil, err
 	}
+	encrypter, err := encryption.New(&config.Encryption)
+	if err != nil {
+		return nil, err
+	}
 	return &Store{
-		oClient: oclient,
-		metrics: pelotonstore.NewMetrics(scope),
+		oClient:   oclient,
+		metrics:   pelotonstore.NewMetrics(scope),
+		encrypter: encrypter,
 	}, nil
 }
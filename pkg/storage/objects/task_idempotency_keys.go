@@ -0,0 +1,198 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objects
+
+import (
+	"context"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+
+	"github.com/uber/peloton/pkg/storage/objects/base"
+)
+
+// init adds a TaskIdempotencyKeyObject instance to the global list of
+// storage objects
+func init() {
+	Objs = append(Objs, &TaskIdempotencyKeyObject{})
+}
+
+// TaskIdempotencyKeyObject corresponds to a row in task_idempotency_keys
+// table.
+type TaskIdempotencyKeyObject struct {
+	// base.Object DB specific annotations
+	base.Object `cassandra:"name=task_idempotency_keys, primaryKey=((job_id, operation, idempotency_key))"`
+	// JobID of the job (uuid)
+	JobID string `column:"name=job_id"`
+	// Operation is the name of the API called
+	Operation string `column:"name=operation"`
+	// IdempotencyKey provided by the client with the call
+	IdempotencyKey string `column:"name=idempotency_key"`
+	// RequestHash is the hash of the request of the call
+	RequestHash string `column:"name=request_hash"`
+	// Response of the call in JSON format, empty until the call completes
+	Response string `column:"name=response"`
+	// CreateTime is the time the key was reserved
+	CreateTime time.Time `column:"name=create_time"`
+}
+
+// TaskIdempotencyKeyOps provides methods for manipulating
+// task_idempotency_keys table.
+type TaskIdempotencyKeyOps interface {
+	// Reserve reserves the idempotency key of a call made on the tasks of
+	// a job. An error of type AlreadyExists is returned if the key is
+	// already reserved for the operation on the job.
+	Reserve(
+		ctx context.Context,
+		jobID *peloton.JobID,
+		operation string,
+		idempotencyKey string,
+		requestHash string,
+	) error
+
+	// Get returns the reservation of the idempotency key of a call made on
+	// the tasks of a job.
+	Get(
+		ctx context.Context,
+		jobID *peloton.JobID,
+		operation string,
+		idempotencyKey string,
+	) (*TaskIdempotencyKeyObject, error)
+
+	// SetResponse records the response of the call which reserved the
+	// idempotency key.
+	SetResponse(
+		ctx context.Context,
+		jobID *peloton.JobID,
+		operation string,
+		idempotencyKey string,
+		response string,
+	) error
+
+	// Delete releases the idempotency key, so that the call is made again
+	// when retried.
+	Delete(
+		ctx context.Context,
+		jobID *peloton.JobID,
+		operation string,
+		idempotencyKey string,
+	) error
+}
+
+// ensure that default implementation (taskIdempotencyKeyOps) satisfies
+// the interface
+var _ TaskIdempotencyKeyOps = (*taskIdempotencyKeyOps)(nil)
+
+// taskIdempotencyKeyOps implements TaskIdempotencyKeyOps using a
+// particular Store
+type taskIdempotencyKeyOps struct {
+	store *Store
+}
+
+// NewTaskIdempotencyKeyOps constructs a TaskIdempotencyKeyOps object for
+// provided Store.
+func NewTaskIdempotencyKeyOps(s *Store) TaskIdempotencyKeyOps {
+	return &taskIdempotencyKeyOps{store: s}
+}
+
+// Reserve reserves the idempotency key of a call with a CAS write.
+func (d *taskIdempotencyKeyOps) Reserve(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	operation string,
+	idempotencyKey string,
+	requestHash string,
+) error {
+	obj := &TaskIdempotencyKeyObject{
+		JobID:          jobID.GetValue(),
+		Operation:      operation,
+		IdempotencyKey: idempotencyKey,
+		RequestHash:    requestHash,
+		CreateTime:     time.Now().UTC(),
+	}
+
+	if err := d.store.oClient.CreateIfNotExists(ctx, obj); err != nil {
+		d.store.metrics.OrmTaskMetrics.TaskIdempotencyKeysAddFail.Inc(1)
+		return err
+	}
+	d.store.metrics.OrmTaskMetrics.TaskIdempotencyKeysAdd.Inc(1)
+	return nil
+}
+
+// Get returns the reservation of the idempotency key of a call.
+func (d *taskIdempotencyKeyOps) Get(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	operation string,
+	idempotencyKey string,
+) (*TaskIdempotencyKeyObject, error) {
+	obj := &TaskIdempotencyKeyObject{
+		JobID:          jobID.GetValue(),
+		Operation:      operation,
+		IdempotencyKey: idempotencyKey,
+	}
+
+	if err := d.store.oClient.Get(ctx, obj); err != nil {
+		d.store.metrics.OrmTaskMetrics.TaskIdempotencyKeysGetFail.Inc(1)
+		return nil, err
+	}
+	d.store.metrics.OrmTaskMetrics.TaskIdempotencyKeysGet.Inc(1)
+	return obj, nil
+}
+
+// SetResponse records the response of the call which reserved the
+// idempotency key.
+func (d *taskIdempotencyKeyOps) SetResponse(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	operation string,
+	idempotencyKey string,
+	response string,
+) error {
+	obj := &TaskIdempotencyKeyObject{
+		JobID:          jobID.GetValue(),
+		Operation:      operation,
+		IdempotencyKey: idempotencyKey,
+		Response:       response,
+	}
+
+	if err := d.store.oClient.Update(ctx, obj, "Response"); err != nil {
+		d.store.metrics.OrmTaskMetrics.TaskIdempotencyKeysUpdateFail.Inc(1)
+		return err
+	}
+	d.store.metrics.OrmTaskMetrics.TaskIdempotencyKeysUpdate.Inc(1)
+	return nil
+}
+
+// Delete releases the idempotency key of a call.
+func (d *taskIdempotencyKeyOps) Delete(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	operation string,
+	idempotencyKey string,
+) error {
+	obj := &TaskIdempotencyKeyObject{
+		JobID:          jobID.GetValue(),
+		Operation:      operation,
+		IdempotencyKey: idempotencyKey,
+	}
+
+	if err := d.store.oClient.Delete(ctx, obj); err != nil {
+		d.store.metrics.OrmTaskMetrics.TaskIdempotencyKeysDeleteFail.Inc(1)
+		return err
+	}
+	d.store.metrics.OrmTaskMetrics.TaskIdempotencyKeysDelete.Inc(1)
+	return nil
+}
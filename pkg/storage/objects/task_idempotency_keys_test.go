@@ -0,0 +1,114 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objects
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	ormmocks "github.com/uber/peloton/pkg/storage/orm/mocks"
+
+	"github.com/gocql/gocql"
+	"github.com/golang/mock/gomock"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+type TaskIdempotencyKeyObjectTestSuite struct {
+	suite.Suite
+}
+
+func TestTaskIdempotencyKeyObjectSuite(t *testing.T) {
+	suite.Run(t, new(TaskIdempotencyKeyObjectTestSuite))
+}
+
+// TestTaskIdempotencyKeyOps tests reserving, completing and releasing
+// an idempotency key in DB
+func (s *TaskIdempotencyKeyObjectTestSuite) TestTaskIdempotencyKeyOps() {
+	db := NewTaskIdempotencyKeyOps(testStore)
+	ctx := context.Background()
+	jobID := &peloton.JobID{Value: uuid.New()}
+
+	_, err := db.Get(ctx, jobID, "Start", "key")
+	s.Equal(gocql.ErrNotFound, err)
+
+	s.NoError(db.Reserve(ctx, jobID, "Start", "key", "hash"))
+	err = db.Reserve(ctx, jobID, "Start", "key", "other-hash")
+	s.True(yarpcerrors.IsAlreadyExists(err))
+	// the key is reserved per operation
+	s.NoError(db.Reserve(ctx, jobID, "Stop", "key", "hash"))
+
+	obj, err := db.Get(ctx, jobID, "Start", "key")
+	s.NoError(err)
+	s.Equal("hash", obj.RequestHash)
+	s.Empty(obj.Response)
+
+	s.NoError(db.SetResponse(ctx, jobID, "Start", "key", `{"startedInstanceIds":[0]}`))
+	obj, err = db.Get(ctx, jobID, "Start", "key")
+	s.NoError(err)
+	s.Equal("hash", obj.RequestHash)
+	s.Equal(`{"startedInstanceIds":[0]}`, obj.Response)
+
+	s.NoError(db.Delete(ctx, jobID, "Start", "key"))
+	_, err = db.Get(ctx, jobID, "Start", "key")
+	s.Equal(gocql.ErrNotFound, err)
+	s.NoError(db.Reserve(ctx, jobID, "Start", "key", "other-hash"))
+}
+
+// TestTaskIdempotencyKeyOpsClientFail tests failure cases due to ORM
+// Client errors
+func (s *TaskIdempotencyKeyObjectTestSuite) TestTaskIdempotencyKeyOpsClientFail() {
+	ctrl := gomock.NewController(s.T())
+	defer ctrl.Finish()
+
+	mockClient := ormmocks.NewMockClient(ctrl)
+	mockStore := &Store{
+		oClient:   mockClient,
+		metrics:   testStore.metrics,
+		encrypter: testStore.encrypter,
+	}
+	db := NewTaskIdempotencyKeyOps(mockStore)
+	jobID := &peloton.JobID{Value: uuid.New()}
+
+	mockClient.EXPECT().CreateIfNotExists(gomock.Any(), gomock.Any()).
+		Return(errors.New("create failed"))
+	mockClient.EXPECT().Get(gomock.Any(), gomock.Any()).
+		Return(errors.New("get failed"))
+	mockClient.EXPECT().Update(gomock.Any(), gomock.Any(), "Response").
+		Return(errors.New("update failed"))
+	mockClient.EXPECT().Delete(gomock.Any(), gomock.Any()).
+		Return(errors.New("delete failed"))
+
+	ctx := context.Background()
+
+	err := db.Reserve(ctx, jobID, "Start", "key", "hash")
+	s.Error(err)
+	s.Equal("create failed", err.Error())
+
+	_, err = db.Get(ctx, jobID, "Start", "key")
+	s.Error(err)
+	s.Equal("get failed", err.Error())
+
+	err = db.SetResponse(ctx, jobID, "Start", "key", "{}")
+	s.Error(err)
+	s.Equal("update failed", err.Error())
+
+	err = db.Delete(ctx, jobID, "Start", "key")
+	s.Error(err)
+	s.Equal("delete failed", err.Error())
+}
@@ -0,0 +1,299 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objects
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/uber/peloton/pkg/storage/objects/base"
+
+	"github.com/gocql/gocql"
+)
+
+// _allInstances is the instance ID of the partition of the operations
+// made on all the instances of a job, which are returned along with the
+// operations made on any instance of the job.
+const _allInstances = math.MaxInt32
+
+// init adds the task operation objects to the global list of storage objects
+func init() {
+	Objs = append(Objs, &TaskOperationObject{}, &TaskOperationByInstanceObject{})
+}
+
+// TaskOperationObject corresponds to a row in task_operations table.
+// The rows are partitioned by job, expire after 90 days, and are deleted
+// along with the job.
+type TaskOperationObject struct {
+	// base.Object DB specific annotations
+	base.Object `cassandra:"name=task_operations, primaryKey=((job_id), operation_time)"`
+	// JobID of the job (uuid)
+	JobID string `column:"name=job_id"`
+	// OperationTime of the operation
+	OperationTime gocql.UUID `column:"name=operation_time"`
+	// Operation is the name of the API called
+	Operation string `column:"name=operation"`
+	// Caller is the identity of the caller
+	Caller string `column:"name=caller"`
+	// Request of the call in JSON format
+	Request string `column:"name=request"`
+	// Result of the call, either the response in JSON format or the error
+	Result string `column:"name=result"`
+	// IdempotencyKey provided by the client with the call
+	IdempotencyKey string `column:"name=idempotency_key"`
+	// IncidentID of the operation context of the call
+	IncidentID string `column:"name=incident_id"`
+	// ChangeTicket of the operation context of the call
+	ChangeTicket string `column:"name=change_ticket"`
+}
+
+// TaskOperationByInstanceObject corresponds to a row in
+// task_operations_by_instance table. The operations of a job are recorded
+// again in the partition of each instance they were made on, or in the
+// partition of _allInstances if they were made on all the instances.
+type TaskOperationByInstanceObject struct {
+	// base.Object DB specific annotations
+	base.Object `cassandra:"name=task_operations_by_instance, primaryKey=((job_id,instance_id), operation_time)"`
+	// JobID of the job (uuid)
+	JobID string `column:"name=job_id"`
+	// InstanceID of the task the operation was made on
+	InstanceID uint32 `column:"name=instance_id"`
+	// OperationTime of the operation
+	OperationTime gocql.UUID `column:"name=operation_time"`
+	// Operation is the name of the API called
+	Operation string `column:"name=operation"`
+	// Caller is the identity of the caller
+	Caller string `column:"name=caller"`
+	// Request of the call in JSON format
+	Request string `column:"name=request"`
+	// Result of the call, either the response in JSON format or the error
+	Result string `column:"name=result"`
+	// IdempotencyKey provided by the client with the call
+	IdempotencyKey string `column:"name=idempotency_key"`
+	// IncidentID of the operation context of the call
+	IncidentID string `column:"name=incident_id"`
+	// ChangeTicket of the operation context of the call
+	ChangeTicket string `column:"name=change_ticket"`
+}
+
+// TaskOperationOps provides methods for manipulating task_operations table.
+type TaskOperationOps interface {
+	// Create adds an operation made on the given instances of a job, or
+	// on all its instances if none is given.
+	Create(
+		ctx context.Context,
+		jobID *peloton.JobID,
+		instanceIDs []uint32,
+		operation *task.TaskOperation,
+	) error
+
+	// GetAll returns the operations made on the tasks of a job,
+	// most recent first.
+	GetAll(
+		ctx context.Context,
+		jobID *peloton.JobID,
+	) ([]*task.TaskOperation, error)
+
+	// GetForInstances returns the operations made on the given instances
+	// of a job, including the ones made on all its instances,
+	// most recent first.
+	GetForInstances(
+		ctx context.Context,
+		jobID *peloton.JobID,
+		instanceIDs []uint32,
+	) ([]*task.TaskOperation, error)
+}
+
+// ensure that default implementation (taskOperationOps) satisfies the interface
+var _ TaskOperationOps = (*taskOperationOps)(nil)
+
+// taskOperationOps implements TaskOperationOps using a particular Store
+type taskOperationOps struct {
+	store *Store
+}
+
+// NewTaskOperationOps constructs a TaskOperationOps object for provided Store.
+func NewTaskOperationOps(s *Store) TaskOperationOps {
+	return &taskOperationOps{store: s}
+}
+
+// Create adds an operation made on the given instances of a job, or on
+// all its instances if none is given. The operation is added to the
+// operations of the job, and to the operations of each instance. The
+// timestamp of the operation is set to the time it is added.
+func (d *taskOperationOps) Create(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	instanceIDs []uint32,
+	operation *task.TaskOperation,
+) error {
+	operationTime := gocql.TimeUUID()
+	obj := &TaskOperationObject{
+		JobID:          jobID.GetValue(),
+		OperationTime:  operationTime,
+		Operation:      operation.GetOperation(),
+		Caller:         operation.GetCaller(),
+		Request:        operation.GetRequest(),
+		Result:         operation.GetResult(),
+		IdempotencyKey: operation.GetIdempotencyKey(),
+		IncidentID:     operation.GetOperationContext().GetIncidentId(),
+		ChangeTicket:   operation.GetOperationContext().GetChangeTicket(),
+	}
+
+	if err := d.store.oClient.Create(ctx, obj); err != nil {
+		d.store.metrics.OrmTaskMetrics.TaskOperationsAddFail.Inc(1)
+		return err
+	}
+
+	if len(instanceIDs) == 0 {
+		instanceIDs = []uint32{_allInstances}
+	}
+	for _, instanceID := range instanceIDs {
+		if err := d.store.oClient.Create(ctx, &TaskOperationByInstanceObject{
+			JobID:          obj.JobID,
+			InstanceID:     instanceID,
+			OperationTime:  operationTime,
+			Operation:      obj.Operation,
+			Caller:         obj.Caller,
+			Request:        obj.Request,
+			Result:         obj.Result,
+			IdempotencyKey: obj.IdempotencyKey,
+			IncidentID:     obj.IncidentID,
+			ChangeTicket:   obj.ChangeTicket,
+		}); err != nil {
+			d.store.metrics.OrmTaskMetrics.TaskOperationsAddFail.Inc(1)
+			return err
+		}
+	}
+	d.store.metrics.OrmTaskMetrics.TaskOperationsAdd.Inc(1)
+	return nil
+}
+
+// GetAll returns the operations made on the tasks of a job,
+// most recent first.
+func (d *taskOperationOps) GetAll(
+	ctx context.Context,
+	jobID *peloton.JobID,
+) ([]*task.TaskOperation, error) {
+	result, err := d.store.oClient.GetAll(ctx, &TaskOperationObject{
+		JobID: jobID.GetValue(),
+	})
+	if err != nil {
+		d.store.metrics.OrmTaskMetrics.TaskOperationsGetFail.Inc(1)
+		return nil, err
+	}
+
+	var operations []*task.TaskOperation
+	for _, value := range result {
+		obj := value.(*TaskOperationObject)
+		operations = append(operations, newTaskOperation(
+			obj.OperationTime,
+			obj.Operation,
+			obj.Caller,
+			obj.Request,
+			obj.Result,
+			obj.IdempotencyKey,
+			obj.IncidentID,
+			obj.ChangeTicket,
+		))
+	}
+	d.store.metrics.OrmTaskMetrics.TaskOperationsGet.Inc(1)
+	return operations, nil
+}
+
+// GetForInstances returns the operations made on the given instances of a
+// job, including the ones made on all its instances, most recent first.
+// An operation made on several of the instances is returned once.
+func (d *taskOperationOps) GetForInstances(
+	ctx context.Context,
+	jobID *peloton.JobID,
+	instanceIDs []uint32,
+) ([]*task.TaskOperation, error) {
+	var objs []*TaskOperationByInstanceObject
+	seen := make(map[gocql.UUID]bool)
+	partitions := append(append([]uint32{}, instanceIDs...), _allInstances)
+	for _, instanceID := range partitions {
+		result, err := d.store.oClient.GetAll(ctx, &TaskOperationByInstanceObject{
+			JobID:      jobID.GetValue(),
+			InstanceID: instanceID,
+		})
+		if err != nil {
+			d.store.metrics.OrmTaskMetrics.TaskOperationsGetFail.Inc(1)
+			return nil, err
+		}
+		for _, value := range result {
+			obj := value.(*TaskOperationByInstanceObject)
+			if seen[obj.OperationTime] {
+				continue
+			}
+			seen[obj.OperationTime] = true
+			objs = append(objs, obj)
+		}
+	}
+
+	sort.Slice(objs, func(i, j int) bool {
+		return objs[i].OperationTime.Time().After(objs[j].OperationTime.Time())
+	})
+
+	var operations []*task.TaskOperation
+	for _, obj := range objs {
+		operations = append(operations, newTaskOperation(
+			obj.OperationTime,
+			obj.Operation,
+			obj.Caller,
+			obj.Request,
+			obj.Result,
+			obj.IdempotencyKey,
+			obj.IncidentID,
+			obj.ChangeTicket,
+		))
+	}
+	d.store.metrics.OrmTaskMetrics.TaskOperationsGet.Inc(1)
+	return operations, nil
+}
+
+// newTaskOperation converts the columns of a row of the task operations
+// tables to a task operation.
+func newTaskOperation(
+	operationTime gocql.UUID,
+	name string,
+	caller string,
+	request string,
+	result string,
+	idempotencyKey string,
+	incidentID string,
+	changeTicket string,
+) *task.TaskOperation {
+	operation := &task.TaskOperation{
+		Operation:      name,
+		Caller:         caller,
+		Request:        request,
+		Result:         result,
+		Timestamp:      operationTime.Time().Format(time.RFC3339),
+		IdempotencyKey: idempotencyKey,
+	}
+	if len(incidentID) > 0 || len(changeTicket) > 0 {
+		operation.OperationContext = &task.OperationContext{
+			IncidentId:   incidentID,
+			ChangeTicket: changeTicket,
+		}
+	}
+	return operation
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objects
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uber/peloton/.gen/peloton/api/v0/peloton"
+	"github.com/uber/peloton/.gen/peloton/api/v0/task"
+
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type TaskOperationObjectTestSuite struct {
+	suite.Suite
+}
+
+func (s *TaskOperationObjectTestSuite) SetupTest() {
+}
+
+func TestTaskOperationObjectSuite(t *testing.T) {
+	suite.Run(t, new(TaskOperationObjectTestSuite))
+}
+
+// TestTaskOperationOps tests adding and getting the task operations of a job
+func (s *TaskOperationObjectTestSuite) TestTaskOperationOps() {
+	db := NewTaskOperationOps(testStore)
+	ctx := context.Background()
+	jobID := &peloton.JobID{Value: uuid.New()}
+
+	operations, err := db.GetAll(ctx, jobID)
+	s.NoError(err)
+	s.Empty(operations)
+
+	for _, name := range []string{"Stop", "Start"} {
+		s.NoError(db.Create(ctx, jobID, nil, &task.TaskOperation{
+			Operation:      name,
+			Caller:         "peloton-cli",
+			Request:        "{}",
+			Result:         "{}",
+			IdempotencyKey: name + "-key",
+			OperationContext: &task.OperationContext{
+				IncidentId: name + "-incident",
+			},
+		}))
+	}
+
+	operations, err = db.GetAll(ctx, jobID)
+	s.NoError(err)
+	s.Len(operations, 2)
+	// the most recent operation is returned first
+	s.Equal("Start", operations[0].GetOperation())
+	s.Equal("Stop", operations[1].GetOperation())
+	s.Equal("peloton-cli", operations[0].GetCaller())
+	s.NotEmpty(operations[0].GetTimestamp())
+	s.Equal("Start-key", operations[0].GetIdempotencyKey())
+	s.Equal(
+		"Start-incident",
+		operations[0].GetOperationContext().GetIncidentId())
+	s.Empty(operations[0].GetOperationContext().GetChangeTicket())
+}
+
+// TestTaskOperationOpsForInstances tests getting the task operations made
+// on some instances of a job
+func (s *TaskOperationObjectTestSuite) TestTaskOperationOpsForInstances() {
+	db := NewTaskOperationOps(testStore)
+	ctx := context.Background()
+	jobID := &peloton.JobID{Value: uuid.New()}
+
+	s.NoError(db.Create(ctx, jobID, nil, &task.TaskOperation{
+		Operation: "Start",
+	}))
+	s.NoError(db.Create(ctx, jobID, []uint32{0, 1}, &task.TaskOperation{
+		Operation: "Stop",
+	}))
+	s.NoError(db.Create(ctx, jobID, []uint32{2}, &task.TaskOperation{
+		Operation: "Requeue",
+	}))
+
+	// the operations made on all the instances are returned for each
+	// instance, and the ones made on several instances are returned once
+	operations, err := db.GetForInstances(ctx, jobID, []uint32{0, 1})
+	s.NoError(err)
+	s.Len(operations, 2)
+	s.Equal("Stop", operations[0].GetOperation())
+	s.Equal("Start", operations[1].GetOperation())
+
+	operations, err = db.GetForInstances(ctx, jobID, []uint32{2})
+	s.NoError(err)
+	s.Len(operations, 2)
+	s.Equal("Requeue", operations[0].GetOperation())
+	s.Equal("Start", operations[1].GetOperation())
+
+	operations, err = db.GetForInstances(ctx, jobID, []uint32{3})
+	s.NoError(err)
+	s.Len(operations, 1)
+
+	// all the operations are returned for the job
+	operations, err = db.GetAll(ctx, jobID)
+	s.NoError(err)
+	s.Len(operations, 3)
+}
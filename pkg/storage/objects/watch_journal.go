@@ -0,0 +1,119 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objects
+
+import (
+	"context"
+	"time"
+
+	"github.com/uber/peloton/pkg/storage/objects/base"
+)
+
+// WatchJournalBucketSize is the period of time the pod changes journaled
+// in the same partition of the watch_journal table were journaled within.
+const WatchJournalBucketSize = time.Minute
+
+// init adds a WatchJournalObject instance to the global list of storage
+// objects
+func init() {
+	Objs = append(Objs, &WatchJournalObject{})
+}
+
+// WatchJournalObject corresponds to a row in watch_journal table.
+type WatchJournalObject struct {
+	// base.Object DB specific annotations
+	base.Object `cassandra:"name=watch_journal, primaryKey=((bucket), revision)"`
+	// Bucket is the minute the change was journaled at, see
+	// WatchJournalBucket
+	Bucket int64 `column:"name=bucket"`
+	// Revision of the change in the watch API
+	Revision uint64 `column:"name=revision"`
+	// JobID of the job of the pod (uuid)
+	JobID string `column:"name=job_id"`
+	// Pod is the marshaled PodSummary of the change
+	Pod []byte `column:"name=pod"`
+	// Labels is the marshaled labels of the pod
+	Labels []byte `column:"name=labels"`
+	// RespoolID is the id of the resource pool of the job
+	RespoolID string `column:"name=respool_id"`
+	// CreateTime is the time the change was journaled at
+	CreateTime time.Time `column:"name=create_time"`
+}
+
+// WatchJournalBucket returns the bucket of the changes journaled at t.
+func WatchJournalBucket(t time.Time) int64 {
+	return t.UnixNano() / int64(WatchJournalBucketSize)
+}
+
+// WatchJournalOps provides methods for manipulating watch_journal table.
+type WatchJournalOps interface {
+	// Add journals a pod change. The bucket of the change is derived
+	// from its create time.
+	Add(ctx context.Context, obj *WatchJournalObject) error
+
+	// GetAll returns the pod changes journaled in a bucket which have
+	// not expired yet, sorted by revision.
+	GetAll(ctx context.Context, bucket int64) ([]*WatchJournalObject, error)
+}
+
+// ensure that default implementation (watchJournalOps) satisfies the interface
+var _ WatchJournalOps = (*watchJournalOps)(nil)
+
+// watchJournalOps implements WatchJournalOps using a particular Store
+type watchJournalOps struct {
+	store *Store
+}
+
+// NewWatchJournalOps constructs a WatchJournalOps object for provided Store.
+func NewWatchJournalOps(s *Store) WatchJournalOps {
+	return &watchJournalOps{store: s}
+}
+
+// Add journals a pod change. The bucket of the change is derived from
+// its create time.
+func (d *watchJournalOps) Add(
+	ctx context.Context,
+	obj *WatchJournalObject,
+) error {
+	obj.Bucket = WatchJournalBucket(obj.CreateTime)
+	if err := d.store.oClient.Create(ctx, obj); err != nil {
+		d.store.metrics.OrmTaskMetrics.WatchJournalAddFail.Inc(1)
+		return err
+	}
+	d.store.metrics.OrmTaskMetrics.WatchJournalAdd.Inc(1)
+	return nil
+}
+
+// GetAll returns the pod changes journaled in a bucket which have not
+// expired yet, sorted by revision.
+func (d *watchJournalOps) GetAll(
+	ctx context.Context,
+	bucket int64,
+) ([]*WatchJournalObject, error) {
+	result, err := d.store.oClient.GetAll(ctx, &WatchJournalObject{
+		Bucket: bucket,
+	})
+	if err != nil {
+		d.store.metrics.OrmTaskMetrics.WatchJournalGetFail.Inc(1)
+		return nil, err
+	}
+
+	var changes []*WatchJournalObject
+	for _, value := range result {
+		changes = append(changes, value.(*WatchJournalObject))
+	}
+	d.store.metrics.OrmTaskMetrics.WatchJournalGet.Inc(1)
+	return changes, nil
+}
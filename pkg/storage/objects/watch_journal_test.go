@@ -0,0 +1,78 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objects
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type WatchJournalObjectTestSuite struct {
+	suite.Suite
+}
+
+func (s *WatchJournalObjectTestSuite) SetupTest() {
+}
+
+func TestWatchJournalObjectSuite(t *testing.T) {
+	suite.Run(t, new(WatchJournalObjectTestSuite))
+}
+
+// TestWatchJournalOps tests journaling the pod changes and getting the
+// changes of a bucket
+func (s *WatchJournalObjectTestSuite) TestWatchJournalOps() {
+	db := NewWatchJournalOps(testStore)
+	ctx := context.Background()
+	// use a bucket far in the past, so that the test does not see the
+	// changes journaled by the other tests
+	createTime := time.Unix(0, 0).Add(
+		time.Duration(rand.Int63n(1<<30)) * WatchJournalBucketSize)
+	bucket := WatchJournalBucket(createTime)
+	jobID := uuid.New()
+
+	changes, err := db.GetAll(ctx, bucket)
+	s.NoError(err)
+	s.Empty(changes)
+
+	s.NoError(db.Add(ctx, &WatchJournalObject{
+		Revision:   2,
+		JobID:      jobID,
+		Pod:        []byte("pod-2"),
+		CreateTime: createTime,
+	}))
+	s.NoError(db.Add(ctx, &WatchJournalObject{
+		Revision:   1,
+		JobID:      jobID,
+		Pod:        []byte("pod-1"),
+		Labels:     []byte("labels"),
+		RespoolID:  "respool-1",
+		CreateTime: createTime,
+	}))
+
+	changes, err = db.GetAll(ctx, bucket)
+	s.NoError(err)
+	s.Len(changes, 2)
+	s.Equal(uint64(1), changes[0].Revision)
+	s.Equal(jobID, changes[0].JobID)
+	s.Equal([]byte("pod-1"), changes[0].Pod)
+	s.Equal([]byte("labels"), changes[0].Labels)
+	s.Equal("respool-1", changes[0].RespoolID)
+	s.Equal(uint64(2), changes[1].Revision)
+}
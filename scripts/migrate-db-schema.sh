@@ -4,7 +4,9 @@ set -ex
 
 
 main() {
-    host="localhost:9042"
+    host="localhost"
+    port="9042"
+    config="config/jobmgr/base.yaml"
     db="peloton_test"
     path="pkg/storage/cassandra/migrations"
     option=""
@@ -12,7 +14,11 @@ main() {
     while [ $# -gt 0 ] ; do
         case "$1" in
             "--host" )
-                host="$2"
+                host="${2%%:*}"
+                [[ "$2" == *:* ]] && port="${2##*:}"
+                shift 2 ;;
+            "--config" )
+                config="$2"
                 shift 2 ;;
             "--db" )
                 db="$2"
@@ -32,14 +38,11 @@ main() {
         esac
     done
 
-    # Set username & password if specified
-    [ ! -z $username ] && [ ! -z $password ] && host="${username}:${password}@${host}"
-
-    [ -z $option ] && echo "Option missing: please specify up / down" && exit 1
+    [ -z $option ] && echo "Option missing: please specify status / pending / up / down / baseline" && exit 1
 
     cat << EOF
     Please confirm the migration info :
-    host : $host
+    host : $host:$port
     db   : $db
     migration file path : $path
     migration option : $option
@@ -48,8 +51,10 @@ EOF
     read -r -p "Are you sure you want to proceed? [y/N] " response
     if [[ "$response" =~ ^([yY][eE][sS]|[yY])+$ ]]
     then
-        $GOPATH/bin/migrate --url "cassandra://${host}/${db}?protocol=4&consistency=all&disable_init_host_lookup" \
-        --path ${path} \
+        CASSANDRA_USERNAME="${username}" CASSANDRA_PASSWORD="${password}" \
+        ./bin/peloton-migrate --config "${config}" \
+        --cassandra-hosts "${host}" --cassandra-port "${port}" \
+        --cassandra-store "${db}" --migrations "${path}" \
         ${option} \
         > >(tee -a stdout.log) 2> >(tee -a stderr.log >&2)
     else
@@ -59,10 +64,11 @@ EOF
 
 
 usage() {
-    echo "usage: $0 [--option MIGRATION_OPTION (up or down)]"
+    echo "usage: $0 [--option MIGRATION_OPTION (status, pending, up, 'down --to VERSION' or 'baseline VERSION')]"
     echo "[--host CASSANDRA_HOST_PORT] [--db CASSANDRA_DB_NAME]"
     echo "[--username USER] [--password PASSWORD]"
-    echo "[--path PATH_TO_MIGRATION_FILES]"
+    echo "[--path PATH_TO_MIGRATION_FILES] [--config STORAGE_CONFIG_FILE]"
+    echo "requires bin/peloton-migrate, built by 'make migrate'"
 }
 
 